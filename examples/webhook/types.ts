@@ -260,7 +260,16 @@ export interface CanonicalResponse {
 // =============================================================================
 
 /** Pipeline stage action */
-export type StageAction = "allow" | "deny" | "mutate";
+export type StageAction = "allow" | "deny" | "mutate" | "route";
+
+/** Provider/model override returned by a "route" action */
+export interface StageRoute {
+    /** Configured provider name to serve the request */
+    provider?: string;
+
+    /** Model to request */
+    model?: string;
+}
 
 /** Metadata passed to pipeline stages */
 export interface StageMetadata {
@@ -303,7 +312,7 @@ export interface StageInput {
  * This is the JSON your webhook must return.
  */
 export interface StageOutput {
-    /** Action to take: "allow", "deny", or "mutate" */
+    /** Action to take: "allow", "deny", "mutate", or "route" */
     action: StageAction;
 
     /** 
@@ -320,6 +329,12 @@ export interface StageOutput {
 
     /** Reason for denial (only for "deny" action) */
     deny_reason?: string;
+
+    /**
+     * Provider/model override (only for "route" action in "request" phase).
+     * Unknown providers fail the stage; the last routing stage wins.
+     */
+    route?: StageRoute;
 }
 
 // =============================================================================
@@ -350,6 +365,14 @@ export function mutateRequest(request: CanonicalRequest): StageOutput {
     return { action: "mutate", request };
 }
 
+/**
+ * Create a route response for request phase.
+ * Use to choose the provider and/or model that serves the request.
+ */
+export function route(target: StageRoute): StageOutput {
+    return { action: "route", route: target };
+}
+
 /**
  * Create a mutate response for response phase.
  * Use to modify the response before it reaches the client.
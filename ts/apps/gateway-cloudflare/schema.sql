@@ -1,5 +1,10 @@
 -- D1 Database Schema for polyglot-llm-gateway
 -- Run with: wrangler d1 execute polyglot-gateway --file=./schema.sql
+--
+-- The schema after the latest storage migration (STORAGE_MIGRATIONS in
+-- gateway-core). With storage.autoMigrate set, the gateway creates and
+-- upgrades the schema itself, including databases created from an older
+-- copy of this file; edit the migrations, not just this file.
 
 -- Conversations table
 CREATE TABLE IF NOT EXISTS conversations (
@@ -23,10 +28,12 @@ CREATE TABLE IF NOT EXISTS messages (
   content TEXT NOT NULL,
   usage TEXT,
   timestamp TEXT NOT NULL,
+  content_hash TEXT,
   FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 );
 
 CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_hash ON messages(conversation_id, content_hash);
 
 -- Responses table (for Responses API)
 CREATE TABLE IF NOT EXISTS responses (
@@ -42,6 +49,7 @@ CREATE TABLE IF NOT EXISTS responses (
   error TEXT,
   usage TEXT,
   metadata TEXT DEFAULT '{}',
+  timings TEXT, -- per-phase latency JSON
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL
 );
@@ -82,5 +90,194 @@ CREATE INDEX IF NOT EXISTS idx_shadow_divergence ON shadow_results(has_structura
 CREATE TABLE IF NOT EXISTS thread_state (
   thread_key TEXT PRIMARY KEY,
   response_id TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  key_hash TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_state_key_hash ON thread_state(key_hash);
+CREATE INDEX IF NOT EXISTS idx_thread_state_updated ON thread_state(updated_at);
+
+-- Idempotency keys table (Idempotency-Key replay)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  tenant_id TEXT NOT NULL,
+  idempotency_key TEXT NOT NULL,
+  interaction_id TEXT NOT NULL,
+  request_hash TEXT,
+  status TEXT NOT NULL,
+  response TEXT,
+  created_at TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_expires ON idempotency_keys(expires_at);
+
+-- Usage records table (tenant budgets)
+CREATE TABLE IF NOT EXISTS usage_records (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant_id TEXT NOT NULL,
+  interaction_id TEXT NOT NULL,
+  model TEXT NOT NULL,
+  total_tokens INTEGER NOT NULL,
+  cost_usd REAL NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_tenant_created ON usage_records(tenant_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_interaction ON usage_records(interaction_id);
+
+-- Tenants created through the admin API (config tenants are not stored)
+CREATE TABLE IF NOT EXISTS tenants (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  api_keys TEXT NOT NULL,
+  routing TEXT,
+  disabled INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL
 );
+
+-- Message batches submitted through the anthropic frontdoor
+CREATE TABLE IF NOT EXISTS message_batches (
+  id TEXT PRIMARY KEY,
+  provider_batch_id TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  key_id TEXT,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  request_count INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  results_recorded_at TEXT
+);
+
+-- Correlation header values by interaction, for admin lookups by client request ID
+CREATE TABLE IF NOT EXISTS metadata_index (
+  interaction_id TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  key TEXT NOT NULL,
+  value TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (interaction_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metadata_index_lookup ON metadata_index(key, value, tenant_id);
+
+-- Per-request outcomes, aggregated for tenant usage reports
+CREATE TABLE IF NOT EXISTS request_stats (
+  interaction_id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  model TEXT NOT NULL,
+  prompt_tokens INTEGER NOT NULL,
+  completion_tokens INTEGER NOT NULL,
+  total_tokens INTEGER NOT NULL,
+  latency_ms INTEGER,
+  error INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  end_user TEXT,
+  language TEXT,
+  safety_flags TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_created ON request_stats(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_end_user ON request_stats(tenant_id, end_user, created_at);
+CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_language ON request_stats(tenant_id, language, created_at);
+CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_flagged ON request_stats(tenant_id, created_at) WHERE safety_flags IS NOT NULL;
+
+-- Synthetic provider probe results, kept apart from interactions and usage
+CREATE TABLE IF NOT EXISTS probe_results (
+  id TEXT PRIMARY KEY,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  success INTEGER NOT NULL,
+  status_code INTEGER,
+  error TEXT,
+  latency_ms INTEGER NOT NULL,
+  usage TEXT,
+  cost_usd REAL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_probe_results_provider_created ON probe_results(provider, created_at);
+
+-- Provider calls behind each interaction; its usage is their sum
+CREATE TABLE IF NOT EXISTS interaction_attempts (
+  interaction_id TEXT NOT NULL,
+  attempt INTEGER NOT NULL,
+  tenant_id TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  prompt_tokens INTEGER,
+  completion_tokens INTEGER,
+  total_tokens INTEGER,
+  cost_usd REAL,
+  duration_ms INTEGER NOT NULL,
+  outcome TEXT NOT NULL,
+  error TEXT,
+  status_code INTEGER,
+  upstream_error TEXT,
+  served INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (interaction_id, attempt)
+);
+
+CREATE INDEX IF NOT EXISTS idx_interaction_attempts_tenant_created ON interaction_attempts(tenant_id, created_at);
+
+-- Thread bundles imported from other deployments, by content checksum
+CREATE TABLE IF NOT EXISTS thread_imports (
+  checksum TEXT PRIMARY KEY,
+  conversations INTEGER NOT NULL,
+  responses INTEGER NOT NULL,
+  thread_state INTEGER NOT NULL,
+  remapped TEXT NOT NULL,
+  imported_at TEXT NOT NULL
+);
+
+-- Latest SLO window per app, so restarts keep it
+CREATE TABLE IF NOT EXISTS slo_snapshots (
+  app TEXT PRIMARY KEY,
+  config_key TEXT NOT NULL,
+  minutes TEXT NOT NULL,
+  hours TEXT NOT NULL,
+  last_alert_at TEXT,
+  saved_at TEXT NOT NULL
+);
+
+-- Tenant secrets, sealed with the storage encryption key
+CREATE TABLE IF NOT EXISTS tenant_secrets (
+  tenant_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  value TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, name)
+);
+
+-- Request interaction records, written in_progress at request start
+CREATE TABLE IF NOT EXISTS interactions (
+  id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  frontdoor TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  stream INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL,
+  request TEXT,
+  status_code INTEGER,
+  error_type TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_interactions_status_created ON interactions(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_interactions_tenant_status ON interactions(tenant_id, status, created_at);
+
+-- Applied storage migrations
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_at TEXT NOT NULL
+);
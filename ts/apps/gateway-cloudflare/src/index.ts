@@ -57,6 +57,10 @@ export default {
                         routing: {
                             defaultProvider: 'openai',
                         },
+                        storage: {
+                            type: 'd1',
+                            autoMigrate: true,
+                        },
                     })
                     : new KVConfigProvider(env.CONFIG_KV),
                 auth: isDev
@@ -66,6 +70,8 @@ export default {
                 events: env.USAGE_QUEUE
                     ? new QueueEventPublisher(env.USAGE_QUEUE, ctx)
                     : new NullEventPublisher(),
+                // Worker vars and secrets back ${env:VAR} in header rules
+                env: env as unknown as Record<string, string | undefined>,
             });
         }
 
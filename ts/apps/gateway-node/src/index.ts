@@ -3,13 +3,18 @@
  */
 
 import { existsSync } from 'node:fs';
-import { createServer, type IncomingMessage, type ServerResponse } from 'node:http';
-import { Gateway, type ConfigProvider } from '@polyglot-llm-gateway/gateway-core';
+import { Gateway, AdminHandler, type ConfigProvider } from '@polyglot-llm-gateway/gateway-core';
 import {
     FileConfigProvider,
+    createNodeHTTPClient,
+    createNodeEventSink,
+    createNodeSpillStorage,
+    loadEncodingFile,
+    GatewayServer,
+    ADMIN_PREFIX,
     EnvConfigProvider,
     StaticAuthProvider,
-    MemoryStorageProvider,
+    createNodeStorage,
     NullEventPublisher,
 } from '@polyglot-llm-gateway/gateway-adapter-node';
 
@@ -26,12 +31,65 @@ function createConfigProvider(): ConfigProvider {
     return new EnvConfigProvider(process.env as Record<string, string>);
 }
 
-// Create gateway
+// Create gateway, with the storage the config asks for
+const configProvider = createConfigProvider();
+const storage = createNodeStorage((await configProvider.load()).storage);
+const auth = createAuthProvider();
 const gateway = new Gateway({
-    config: createConfigProvider(),
-    auth: createAuthProvider(),
-    storage: new MemoryStorageProvider(),
+    config: configProvider,
+    auth,
+    storage,
     events: new NullEventPublisher(),
+    httpClientFactory: (provider) => createNodeHTTPClient(provider.http),
+    webhookClientFactory: (stage) => createNodeHTTPClient(stage),
+    eventSinkFactory: createNodeEventSink,
+    spillStorageFactory: createNodeSpillStorage,
+    encodingLoader: loadEncodingFile,
+    env: process.env,
+});
+
+// Admin API, mounted under /admin or served from its own listener
+const admin = new AdminHandler({
+    storage: gateway.storage,
+    config: configProvider,
+    auth,
+    providerHealth: () => gateway.providerHealth(),
+    probes: (provider, limit) => gateway.probeReport(provider, limit),
+    slo: () => gateway.sloReport(),
+    models: () => gateway.modelCatalog.list(),
+    templates: () => gateway.promptTemplates(),
+    latency: () => gateway.latencySummary(),
+    budget: (tenantId) => gateway.budgetStatus(tenantId),
+    usage: gateway.usageReports,
+    endUserHash: (endUserId) => gateway.endUserHash(endUserId),
+    events: () => gateway.eventStats(),
+    mirrors: () => gateway.mirrorStats(),
+    deadlines: () => gateway.deadlineStats(),
+    callBudgets: () => gateway.callBudgetStats(),
+    parameterPolicies: () => gateway.parameterPolicies(),
+    clientAborts: () => gateway.clientAbortStats(),
+    usageDiscrepancies: () => gateway.usageDiscrepancyStats(),
+    coalescing: () => gateway.coalescingStats(),
+    messageDedup: () => gateway.messageDedupStats(),
+    spill: () => gateway.spillStats(),
+    storageHealth: () => gateway.storageHealthStats(),
+    classification: () => gateway.classificationStats(),
+    concurrency: () => gateway.concurrencyStats(),
+    unmatchedRoutes: () => gateway.unmatchedRouteStats(),
+    routes: () => gateway.routes(),
+    routing: (query) => gateway.explainRouting(query),
+    replaySpill: () => gateway.replaySpill(),
+    rewrap: (batchSize) => gateway.rewrapStorage(batchSize),
+    console: (request) => gateway.consoleExecute(request),
+    tenants: gateway.tenants,
+    logging: gateway.logControl,
+    secrets: gateway.secrets,
+    metadataIndex: gateway.metadataIndex,
+    attempts: gateway.attempts,
+    threadState: gateway.threadState,
+    modelMigrations: gateway.modelMigrations,
+    dualWrite: gateway.dualWrite,
+    tails: gateway.interactionTails,
 });
 
 // Load configuration
@@ -40,71 +98,32 @@ await gateway.reload();
 // Start watching for config changes (if supported)
 await gateway.startWatching();
 
-// Create HTTP server
-const server = createServer(async (req: IncomingMessage, res: ServerResponse) => {
-    try {
-        // Convert Node request to Web Request
-        const url = `http://${req.headers.host}${req.url}`;
-        const headers = new Headers();
-        for (const [key, value] of Object.entries(req.headers)) {
-            if (value) {
-                headers.set(key, Array.isArray(value) ? value.join(', ') : value);
-            }
-        }
-
-        let body: ArrayBuffer | undefined = undefined;
-        if (req.method !== 'GET' && req.method !== 'HEAD') {
-            const bodyBuffer = await readBody(req);
-            if (bodyBuffer.byteLength > 0) {
-                body = bodyBuffer;
-            }
-        }
-
-        const webRequest = new Request(url, {
-            method: req.method ?? 'GET',
-            headers,
-            ...(body !== undefined ? { body } : {}),
-        });
+// Create HTTP listeners
+const { admin: adminListener } = await configProvider.load();
+const server = new GatewayServer({ gateway, admin, adminListener });
+const addresses = await server.listen(PORT);
 
-        // Handle request
-        const webResponse = await gateway.fetch(webRequest);
-
-        // Convert Web Response to Node response
-        res.statusCode = webResponse.status;
-        webResponse.headers.forEach((value, key) => {
-            res.setHeader(key, value);
-        });
-
-        // Read and write body
-        const responseBody = await webResponse.arrayBuffer();
-        if (responseBody.byteLength > 0) {
-            res.write(Buffer.from(responseBody));
-        }
-
-        res.end();
-    } catch (error) {
-        console.error('Request error:', error);
-        res.statusCode = 500;
-        res.setHeader('Content-Type', 'application/json');
-        res.end(JSON.stringify({ error: { message: 'Internal server error' } }));
-    }
-});
+console.log(`Gateway listening on http://localhost:${addresses.data.port}`);
+if (addresses.admin) {
+    console.log(`Admin listening on http://${addresses.admin.address}:${addresses.admin.port}${ADMIN_PREFIX}`);
+}
 
-server.listen(PORT, () => {
-    console.log(`Gateway listening on http://localhost:${PORT}`);
-});
+// Graceful shutdown for both listeners
+for (const signal of ['SIGINT', 'SIGTERM'] as const) {
+    process.once(signal, () => {
+        console.log(`Received ${signal}, shutting down`);
+        Promise.all([server.close(), gateway.close()]).then(
+            () => process.exit(0),
+            (error) => {
+                console.error('Shutdown error:', error);
+                process.exit(1);
+            },
+        );
+    });
+}
 
 // Utility functions
 
-async function readBody(req: IncomingMessage): Promise<ArrayBuffer> {
-    const chunks: Buffer[] = [];
-    for await (const chunk of req) {
-        chunks.push(chunk);
-    }
-    const buffer = Buffer.concat(chunks);
-    return buffer.buffer.slice(buffer.byteOffset, buffer.byteOffset + buffer.byteLength);
-}
-
 function createAuthProvider(): StaticAuthProvider {
     const provider = new StaticAuthProvider();
 
@@ -8,6 +8,9 @@ import type {
     StorageProvider,
     Conversation,
     StoredMessage,
+    StoredThread,
+    AddMessageOptions,
+    AddMessageResult,
     ResponseRecord,
     InteractionSummary,
     InteractionListOptions,
@@ -15,6 +18,43 @@ import type {
     DivergenceListOptions,
     ShadowResult,
     InteractionEvent,
+    IdempotencyRecord,
+    StoredHTTPResponse,
+    UsageRecord,
+    UsageTotals,
+    UsageSumOptions,
+    RequestStatRecord,
+    RequestStatClassificationFilter,
+    UsageStatsRow,
+    ErasureSelector,
+    ErasureCounts,
+    StoredTenant,
+    BatchRecord,
+    InteractionMetadataRecord,
+    ThreadStateEntry,
+    ThreadStateListOptions,
+    ThreadImportBatch,
+    ThreadImportRecord,
+    ProbeResultRecord,
+    SloSnapshotRecord,
+    SecretRecord,
+    InteractionAttemptRecord,
+    AttemptUsageRow,
+    InteractionRecord,
+    InteractionUpdate,
+    SensitiveField,
+    SensitiveValue,
+    MigrationResult,
+    MigrationStatus,
+    MigrationDatabase,
+} from '@polyglot-llm-gateway/gateway-core';
+import {
+    ERASED,
+    emptyErasureCounts,
+    scrubShadowResult,
+    MigrationRunner,
+    STORAGE_MIGRATIONS,
+    sha256,
 } from '@polyglot-llm-gateway/gateway-core';
 import { D1_TABLES } from '../bindings.js';
 
@@ -22,6 +62,171 @@ import { D1_TABLES } from '../bindings.js';
 // D1 Storage Provider
 // ============================================================================
 
+/**
+ * ORDER BY for list queries, matching the memory store: `orderBy` timestamp
+ * (default updated_at), newest first unless `order` is "asc", ties broken
+ * by ID.
+ */
+function orderClause(options: ListOptions | undefined): string {
+    const column = options?.orderBy === 'createdAt' ? 'created_at' : 'updated_at';
+    const direction = options?.order === 'asc' ? 'ASC' : 'DESC';
+    return `ORDER BY ${column} ${direction}, id ${direction}`;
+}
+
+/**
+ * Union of conversation and response summaries matching the type and
+ * tenant filters, for listing and counting.
+ */
+function interactionsQuery(options: InteractionListOptions | undefined): { sql: string; params: string[] } {
+    const tenant = options?.tenantId ? [options.tenantId] : [];
+    const status = options?.status ? [options.status] : [];
+    const where = (...conditions: (string | false)[]) => {
+        const applied = conditions.filter((c): c is string => c !== false);
+        return applied.length > 0 ? `WHERE ${applied.join(' AND ')}` : '';
+    };
+    const tenantCondition = tenant.length > 0 && 'tenant_id = ?';
+    const statusCondition = status.length > 0 && 'status = ?';
+    const selects: string[] = [];
+    const params: string[] = [];
+
+    // Requests are listed only when asked for; conversations have no
+    // status, so a status filter leaves them out
+    const type = options?.type;
+    if ((type === undefined || type === 'conversation') && status.length === 0) {
+        selects.push(`
+        SELECT 'conversation' AS type, id, tenant_id, app_name, model,
+          (SELECT COUNT(*) FROM ${D1_TABLES.MESSAGES} WHERE conversation_id = c.id) AS message_count,
+          NULL AS status, NULL AS provider, created_at, updated_at
+        FROM ${D1_TABLES.CONVERSATIONS} c ${where(tenantCondition)}`);
+        params.push(...tenant);
+    }
+    if (type === undefined || type === 'response') {
+        selects.push(`
+        SELECT 'response' AS type, id, tenant_id, app_name, model,
+          NULL AS message_count, status, NULL AS provider, created_at, updated_at
+        FROM ${D1_TABLES.RESPONSES} ${where(tenantCondition, statusCondition)}`);
+        params.push(...tenant, ...status);
+    }
+    if (type === 'request') {
+        selects.push(`
+        SELECT 'request' AS type, id, tenant_id, app_name, model,
+          NULL AS message_count, status, provider, created_at, updated_at
+        FROM ${D1_TABLES.INTERACTIONS} ${where(tenantCondition, statusCondition)}`);
+        params.push(...tenant, ...status);
+    }
+
+    return { sql: selects.join('\n        UNION ALL'), params };
+}
+
+/**
+ * Tenant filter for tables keyed by interaction ID. Events and shadow
+ * results have no tenant column, so ownership follows the conversation,
+ * response, or request record they belong to. Binds the tenant ID four
+ * times; '' matches all.
+ */
+const OWNED_INTERACTION = `(? = '' OR interaction_id IN (
+          SELECT id FROM ${D1_TABLES.CONVERSATIONS} WHERE tenant_id = ?
+          UNION SELECT id FROM ${D1_TABLES.RESPONSES} WHERE tenant_id = ?
+          UNION SELECT id FROM ${D1_TABLES.INTERACTIONS} WHERE tenant_id = ?
+        ))`;
+
+/** IDs per IN list, well under D1's bound parameter limit. */
+const ERASURE_BATCH = 50;
+
+/**
+ * WHERE clause selecting a table's rows matching an erasure selector.
+ * Metadata keys are restricted to [A-Za-z0-9_.-] by the admin API, so
+ * quoting them into the JSON path is safe.
+ */
+function erasureWhere(selector: ErasureSelector): { sql: string; params: string[] } {
+    const clauses: string[] = [];
+    const params: string[] = [];
+
+    if (selector.tenantId) {
+        clauses.push('tenant_id = ?');
+        params.push(selector.tenantId);
+    }
+    if (selector.metadata) {
+        clauses.push('json_extract(metadata, ?) = ?');
+        params.push(`$."${selector.metadata.key}"`, selector.metadata.value);
+    }
+    if (selector.threadKey) {
+        clauses.push('thread_key = ?');
+        params.push(selector.threadKey);
+    }
+    if (selector.interactionIds) {
+        clauses.push(`id IN (${selector.interactionIds.map(() => '?').join(', ')})`);
+        params.push(...selector.interactionIds);
+    }
+
+    return { sql: clauses.join(' AND '), params };
+}
+
+/**
+ * Where each sensitive field is stored. JSON columns hold the value
+ * JSON-encoded; text columns hold it as-is.
+ */
+const SENSITIVE_COLUMNS: Record<SensitiveField, { table: string; column: string; json: boolean }> = {
+    'responses.request': { table: D1_TABLES.RESPONSES, column: 'request', json: true },
+    'responses.response': { table: D1_TABLES.RESPONSES, column: 'response', json: true },
+    'responses.error': { table: D1_TABLES.RESPONSES, column: 'error', json: true },
+    'messages.content': { table: D1_TABLES.MESSAGES, column: 'content', json: false },
+    'interaction_events.payload': { table: D1_TABLES.INTERACTION_EVENTS, column: 'payload', json: true },
+    'interactions.request': { table: D1_TABLES.INTERACTIONS, column: 'request', json: false },
+};
+
+/**
+ * Splits IDs into batches of ERASURE_BATCH.
+ */
+function batches(ids: string[]): string[][] {
+    const out: string[][] = [];
+    for (let i = 0; i < ids.length; i += ERASURE_BATCH) {
+        out.push(ids.slice(i, i + ERASURE_BATCH));
+    }
+    return out;
+}
+
+/**
+ * Maps a request_stats row to a RequestStatRecord.
+ */
+function rowToRequestStat(row: RequestStatRow): RequestStatRecord {
+    return {
+        tenantId: row.tenant_id,
+        interactionId: row.interaction_id,
+        model: row.model,
+        promptTokens: row.prompt_tokens,
+        completionTokens: row.completion_tokens,
+        totalTokens: row.total_tokens,
+        latencyMs: row.latency_ms ?? undefined,
+        error: row.error === 1,
+        createdAt: new Date(row.created_at),
+        endUser: row.end_user ?? undefined,
+        language: row.language ?? undefined,
+        safetyFlags: row.safety_flags ? JSON.parse(row.safety_flags) as string[] : undefined,
+    };
+}
+
+/**
+ * Maps an interactions row to an InteractionRecord.
+ */
+function rowToInteraction(row: InteractionRecordRow): InteractionRecord {
+    return {
+        id: row.id,
+        tenantId: row.tenant_id,
+        appName: row.app_name ?? undefined,
+        frontdoor: row.frontdoor,
+        provider: row.provider,
+        model: row.model,
+        stream: row.stream === 1,
+        status: row.status as InteractionRecord['status'],
+        request: row.request ?? undefined,
+        statusCode: row.status_code ?? undefined,
+        errorType: row.error_type ?? undefined,
+        createdAt: new Date(row.created_at),
+        updatedAt: new Date(row.updated_at),
+    };
+}
+
 /**
  * Storage provider backed by Cloudflare D1.
  */
@@ -53,34 +258,20 @@ export class D1StorageProvider implements StorageProvider {
 
         // Save messages
         for (const msg of conversation.messages) {
-            await this.db
-                .prepare(`
-          INSERT INTO ${D1_TABLES.MESSAGES} (id, conversation_id, role, content, usage, timestamp)
-          VALUES (?, ?, ?, ?, ?, ?)
-          ON CONFLICT(id) DO NOTHING
-        `)
-                .bind(
-                    msg.id,
-                    conversation.id,
-                    msg.role,
-                    msg.content,
-                    JSON.stringify(msg.usage ?? null),
-                    msg.timestamp.toISOString(),
-                )
-                .run();
+            await this.insertMessage(conversation.id, msg);
         }
     }
 
-    async getConversation(id: string): Promise<Conversation | null> {
+    async getConversation(id: string, tenantId: string): Promise<Conversation | null> {
         const row = await this.db
-            .prepare(`SELECT * FROM ${D1_TABLES.CONVERSATIONS} WHERE id = ?`)
-            .bind(id)
+            .prepare(`SELECT * FROM ${D1_TABLES.CONVERSATIONS} WHERE id = ? AND (? = '' OR tenant_id = ?)`)
+            .bind(id, tenantId, tenantId)
             .first<ConversationRow>();
 
         if (!row) return null;
 
         const messages = await this.db
-            .prepare(`SELECT * FROM ${D1_TABLES.MESSAGES} WHERE conversation_id = ? ORDER BY timestamp ASC`)
+            .prepare(`SELECT * FROM ${D1_TABLES.MESSAGES} WHERE conversation_id = ? ORDER BY timestamp ASC, rowid ASC`)
             .bind(id)
             .all<MessageRow>();
 
@@ -90,13 +281,7 @@ export class D1StorageProvider implements StorageProvider {
             appName: row.app_name ?? undefined,
             model: row.model ?? undefined,
             metadata: JSON.parse(row.metadata || '{}'),
-            messages: messages.results.map((m) => ({
-                id: m.id,
-                role: m.role as StoredMessage['role'],
-                content: m.content,
-                usage: m.usage ? JSON.parse(m.usage) : undefined,
-                timestamp: new Date(m.timestamp),
-            })),
+            messages: messages.results.map((m) => this.rowToMessage(m)),
             createdAt: new Date(row.created_at),
             updatedAt: new Date(row.updated_at),
         };
@@ -113,17 +298,164 @@ export class D1StorageProvider implements StorageProvider {
             .prepare(`
         SELECT * FROM ${D1_TABLES.CONVERSATIONS}
         WHERE tenant_id = ?
-        ORDER BY updated_at DESC
+        ${orderClause(options)}
         LIMIT ? OFFSET ?
       `)
             .bind(tenantId, limit, offset)
             .all<ConversationRow>();
 
         return Promise.all(
-            rows.results.map((row) => this.getConversation(row.id).then((c) => c!)),
+            rows.results.map((row) => this.getConversation(row.id, tenantId).then((c) => c!)),
         );
     }
 
+    private async insertMessage(conversationId: string, msg: StoredMessage): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.MESSAGES} (id, conversation_id, role, content, usage, timestamp, content_hash)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO NOTHING
+      `)
+            .bind(
+                msg.id,
+                conversationId,
+                msg.role,
+                msg.content,
+                JSON.stringify(msg.usage ?? null),
+                msg.timestamp.toISOString(),
+                msg.contentHash ?? null,
+            )
+            .run();
+    }
+
+    private rowToMessage(row: MessageRow): StoredMessage {
+        return {
+            id: row.id,
+            role: row.role as StoredMessage['role'],
+            content: row.content,
+            usage: row.usage ? JSON.parse(row.usage) : undefined,
+            timestamp: new Date(row.timestamp),
+            contentHash: row.content_hash ?? undefined,
+        };
+    }
+
+    // ---- Threads ----
+    // Threads are conversations without an app or model.
+
+    async createThread(thread: StoredThread): Promise<void> {
+        await this.saveConversation(thread);
+    }
+
+    async getThread(id: string, tenantId: string): Promise<StoredThread | null> {
+        const conversation = await this.getConversation(id, tenantId);
+        if (!conversation) return null;
+
+        return {
+            id: conversation.id,
+            tenantId: conversation.tenantId,
+            messages: conversation.messages,
+            metadata: conversation.metadata,
+            createdAt: conversation.createdAt,
+            updatedAt: conversation.updatedAt,
+        };
+    }
+
+    async addMessage(threadId: string, message: StoredMessage, options?: AddMessageOptions): Promise<AddMessageResult | null> {
+        const window = message.contentHash ? options?.dedupWindow : undefined;
+        const since = window?.seconds === undefined
+            ? null
+            : new Date(message.timestamp.getTime() - window.seconds * 1000).toISOString();
+
+        // One statement, so concurrent retries can't both insert: the hash
+        // checks use idx_messages_conversation_hash
+        const inserted = await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.MESSAGES} (id, conversation_id, role, content, usage, timestamp, content_hash)
+        SELECT ?, ?, ?, ?, ?, ?, ?
+        WHERE EXISTS (SELECT 1 FROM ${D1_TABLES.CONVERSATIONS} WHERE id = ?)
+          AND NOT EXISTS (
+            SELECT 1 FROM (
+              SELECT content_hash FROM ${D1_TABLES.MESSAGES}
+              WHERE conversation_id = ?
+              ORDER BY timestamp DESC, rowid DESC
+              LIMIT ?
+            ) WHERE content_hash = ?
+          )
+          AND NOT EXISTS (
+            SELECT 1 FROM ${D1_TABLES.MESSAGES}
+            WHERE conversation_id = ? AND content_hash = ? AND timestamp >= ?
+          )
+        ON CONFLICT(id) DO NOTHING
+      `)
+            .bind(
+                message.id,
+                threadId,
+                message.role,
+                message.content,
+                JSON.stringify(message.usage ?? null),
+                message.timestamp.toISOString(),
+                message.contentHash ?? null,
+                threadId,
+                threadId,
+                window?.messages ?? 0,
+                window ? message.contentHash! : null,
+                threadId,
+                window ? message.contentHash! : null,
+                since,
+            )
+            .run();
+
+        if (inserted.meta.changes > 0) {
+            await this.db
+                .prepare(`UPDATE ${D1_TABLES.CONVERSATIONS} SET updated_at = ? WHERE id = ?`)
+                .bind(new Date().toISOString(), threadId)
+                .run();
+            return { id: message.id };
+        }
+
+        // Message IDs are global: the ID may be another thread's
+        const sameId = await this.db
+            .prepare(`SELECT conversation_id FROM ${D1_TABLES.MESSAGES} WHERE id = ?`)
+            .bind(message.id)
+            .first<{ conversation_id: string }>();
+        if (sameId) {
+            return sameId.conversation_id === threadId ? { id: message.id, duplicate: 'id' } : null;
+        }
+
+        const sameContent = window && await this.db
+            .prepare(`
+        SELECT id FROM ${D1_TABLES.MESSAGES}
+        WHERE conversation_id = ? AND content_hash = ?
+        ORDER BY timestamp DESC, rowid DESC
+        LIMIT 1
+      `)
+            .bind(threadId, message.contentHash!)
+            .first<{ id: string }>();
+        return sameContent ? { id: sameContent.id, duplicate: 'content' } : null;
+    }
+
+    async listMessages(threadId: string, options?: ListOptions): Promise<StoredMessage[]> {
+        const direction = options?.order === 'desc' ? 'DESC' : 'ASC';
+        const rows = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.MESSAGES}
+        WHERE conversation_id = ?
+        ORDER BY timestamp ${direction}, rowid ${direction}
+        LIMIT ? OFFSET ?
+      `)
+            .bind(threadId, options?.limit ?? -1, options?.offset ?? 0)
+            .all<MessageRow>();
+
+        return rows.results.map((row) => this.rowToMessage(row));
+    }
+
+    async deleteThread(id: string): Promise<void> {
+        await this.db.batch([
+            this.db.prepare(`DELETE FROM ${D1_TABLES.MESSAGES} WHERE conversation_id = ?`).bind(id),
+            this.db.prepare(`DELETE FROM ${D1_TABLES.CONVERSATIONS} WHERE id = ?`).bind(id),
+        ]);
+    }
+
     // ---- Responses ----
 
     async saveResponse(response: ResponseRecord): Promise<void> {
@@ -131,14 +463,15 @@ export class D1StorageProvider implements StorageProvider {
             .prepare(`
         INSERT INTO ${D1_TABLES.RESPONSES} (
           id, tenant_id, app_name, thread_key, previous_response_id,
-          model, status, request, response, error, usage, metadata, created_at, updated_at
+          model, status, request, response, error, usage, metadata, timings, created_at, updated_at
         )
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         ON CONFLICT(id) DO UPDATE SET
           status = excluded.status,
           response = excluded.response,
           error = excluded.error,
           usage = excluded.usage,
+          timings = excluded.timings,
           updated_at = excluded.updated_at
       `)
             .bind(
@@ -154,16 +487,17 @@ export class D1StorageProvider implements StorageProvider {
                 JSON.stringify(response.error ?? null),
                 JSON.stringify(response.usage ?? null),
                 JSON.stringify(response.metadata ?? {}),
+                JSON.stringify(response.timings ?? null),
                 response.createdAt.toISOString(),
                 response.updatedAt.toISOString(),
             )
             .run();
     }
 
-    async getResponse(id: string): Promise<ResponseRecord | null> {
+    async getResponse(id: string, tenantId: string): Promise<ResponseRecord | null> {
         const row = await this.db
-            .prepare(`SELECT * FROM ${D1_TABLES.RESPONSES} WHERE id = ?`)
-            .bind(id)
+            .prepare(`SELECT * FROM ${D1_TABLES.RESPONSES} WHERE id = ? AND (? = '' OR tenant_id = ?)`)
+            .bind(id, tenantId, tenantId)
             .first<ResponseRow>();
 
         if (!row) return null;
@@ -182,7 +516,7 @@ export class D1StorageProvider implements StorageProvider {
             .prepare(`
         SELECT * FROM ${D1_TABLES.RESPONSES}
         WHERE tenant_id = ?
-        ORDER BY updated_at DESC
+        ${orderClause(options)}
         LIMIT ? OFFSET ?
       `)
             .bind(tenantId, limit, offset)
@@ -198,50 +532,39 @@ export class D1StorageProvider implements StorageProvider {
     ): Promise<InteractionSummary[]> {
         const limit = options?.limit ?? 50;
         const offset = options?.offset ?? 0;
+        const { sql, params } = interactionsQuery(options);
 
-        // Union query across conversations and responses
         const rows = await this.db
             .prepare(`
-        SELECT 'conversation' as type, id, tenant_id, app_name, model, created_at, updated_at
-        FROM ${D1_TABLES.CONVERSATIONS}
-        ${options?.tenantId ? 'WHERE tenant_id = ?' : ''}
-        UNION ALL
-        SELECT 'response' as type, id, tenant_id, app_name, model, created_at, updated_at
-        FROM ${D1_TABLES.RESPONSES}
-        ${options?.tenantId ? 'WHERE tenant_id = ?' : ''}
-        ORDER BY updated_at DESC
+        ${sql}
+        ${orderClause(options)}
         LIMIT ? OFFSET ?
       `)
-            .bind(
-                ...(options?.tenantId ? [options.tenantId, options.tenantId] : []),
-                limit,
-                offset,
-            )
+            .bind(...params, limit, offset)
             .all<InteractionRow>();
 
         return rows.results.map((row) => ({
             id: row.id,
-            type: row.type as 'conversation' | 'response',
+            type: row.type as InteractionSummary['type'],
             tenantId: row.tenant_id,
             appName: row.app_name ?? undefined,
             model: row.model ?? undefined,
+            messageCount: row.message_count ?? undefined,
+            status: row.status ?? undefined,
+            provider: row.provider ?? undefined,
             createdAt: new Date(row.created_at),
             updatedAt: new Date(row.updated_at),
         }));
     }
 
     async getInteractionCount(options?: InteractionListOptions): Promise<number> {
-        const convCount = await this.db
-            .prepare(`SELECT COUNT(*) as count FROM ${D1_TABLES.CONVERSATIONS} ${options?.tenantId ? 'WHERE tenant_id = ?' : ''}`)
-            .bind(...(options?.tenantId ? [options.tenantId] : []))
-            .first<{ count: number }>();
-
-        const respCount = await this.db
-            .prepare(`SELECT COUNT(*) as count FROM ${D1_TABLES.RESPONSES} ${options?.tenantId ? 'WHERE tenant_id = ?' : ''}`)
-            .bind(...(options?.tenantId ? [options.tenantId] : []))
+        const { sql, params } = interactionsQuery(options);
+        const row = await this.db
+            .prepare(`SELECT COUNT(*) as count FROM (${sql})`)
+            .bind(...params)
             .first<{ count: number }>();
 
-        return (convCount?.count ?? 0) + (respCount?.count ?? 0);
+        return row?.count ?? 0;
     }
 
     async saveEvent(event: InteractionEvent): Promise<void> {
@@ -260,14 +583,14 @@ export class D1StorageProvider implements StorageProvider {
             .run();
     }
 
-    async getEvents(interactionId: string): Promise<InteractionEvent[]> {
+    async getEvents(interactionId: string, tenantId: string): Promise<InteractionEvent[]> {
         const rows = await this.db
             .prepare(`
         SELECT * FROM ${D1_TABLES.INTERACTION_EVENTS}
-        WHERE interaction_id = ?
+        WHERE interaction_id = ? AND ${OWNED_INTERACTION}
         ORDER BY timestamp ASC
       `)
-            .bind(interactionId)
+            .bind(interactionId, tenantId, tenantId, tenantId, tenantId)
             .all<EventRow>();
 
         return rows.results.map((row) => ({
@@ -305,23 +628,23 @@ export class D1StorageProvider implements StorageProvider {
             .run();
     }
 
-    async getShadowResults(interactionId: string): Promise<ShadowResult[]> {
+    async getShadowResults(interactionId: string, tenantId: string): Promise<ShadowResult[]> {
         const rows = await this.db
             .prepare(`
         SELECT * FROM ${D1_TABLES.SHADOW_RESULTS}
-        WHERE interaction_id = ?
+        WHERE interaction_id = ? AND ${OWNED_INTERACTION}
         ORDER BY created_at ASC
       `)
-            .bind(interactionId)
+            .bind(interactionId, tenantId, tenantId, tenantId, tenantId)
             .all<ShadowRow>();
 
         return rows.results.map(this.rowToShadowResult);
     }
 
-    async getShadowResult(id: string): Promise<ShadowResult | null> {
+    async getShadowResult(id: string, tenantId: string): Promise<ShadowResult | null> {
         const row = await this.db
-            .prepare(`SELECT * FROM ${D1_TABLES.SHADOW_RESULTS} WHERE id = ?`)
-            .bind(id)
+            .prepare(`SELECT * FROM ${D1_TABLES.SHADOW_RESULTS} WHERE id = ? AND ${OWNED_INTERACTION}`)
+            .bind(id, tenantId, tenantId, tenantId, tenantId)
             .first<ShadowRow>();
 
         if (!row) return null;
@@ -333,19 +656,18 @@ export class D1StorageProvider implements StorageProvider {
     ): Promise<ShadowResult[]> {
         const limit = options?.limit ?? 50;
         const offset = options?.offset ?? 0;
-        const structuralOnly = options?.structuralOnly ?? true;
 
         const rows = await this.db
             .prepare(`
         SELECT * FROM ${D1_TABLES.SHADOW_RESULTS}
-        WHERE has_structural_divergence = ?
-        ${options?.tenantId ? 'AND interaction_id IN (SELECT id FROM conversations WHERE tenant_id = ?)' : ''}
-        ORDER BY created_at DESC
+        WHERE divergences != '[]'
+        ${options?.structuralOnly ? 'AND has_structural_divergence = 1' : ''}
+        ${options?.tenantId ? `AND ${OWNED_INTERACTION}` : ''}
+        ${orderClause({ ...options, orderBy: 'createdAt' })}
         LIMIT ? OFFSET ?
       `)
             .bind(
-                structuralOnly ? 1 : 0,
-                ...(options?.tenantId ? [options.tenantId] : []),
+                ...(options?.tenantId ? [options.tenantId, options.tenantId, options.tenantId, options.tenantId] : []),
                 limit,
                 offset,
             )
@@ -359,13 +681,14 @@ export class D1StorageProvider implements StorageProvider {
     async setThreadState(threadKey: string, responseId: string): Promise<void> {
         await this.db
             .prepare(`
-        INSERT INTO ${D1_TABLES.THREAD_STATE} (thread_key, response_id, updated_at)
-        VALUES (?, ?, ?)
+        INSERT INTO ${D1_TABLES.THREAD_STATE} (thread_key, response_id, updated_at, key_hash)
+        VALUES (?, ?, ?, ?)
         ON CONFLICT(thread_key) DO UPDATE SET
           response_id = excluded.response_id,
-          updated_at = excluded.updated_at
+          updated_at = excluded.updated_at,
+          key_hash = excluded.key_hash
       `)
-            .bind(threadKey, responseId, new Date().toISOString())
+            .bind(threadKey, responseId, new Date().toISOString(), await sha256(threadKey))
             .run();
     }
 
@@ -378,100 +701,1208 @@ export class D1StorageProvider implements StorageProvider {
         return row?.response_id ?? null;
     }
 
-    // ---- Helpers ----
+    async listThreadState(options?: ThreadStateListOptions): Promise<ThreadStateEntry[]> {
+        await this.hashThreadKeys();
+        const rows = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.THREAD_STATE}
+        WHERE key_hash > ?
+        ORDER BY key_hash
+        LIMIT ?
+      `)
+            .bind(options?.cursor ?? '', options?.limit ?? 50)
+            .all<ThreadStateRow>();
 
-    private rowToResponse(row: ResponseRow): ResponseRecord {
-        return {
-            id: row.id,
-            tenantId: row.tenant_id,
-            appName: row.app_name ?? undefined,
-            threadKey: row.thread_key ?? undefined,
-            previousResponseId: row.previous_response_id ?? undefined,
-            model: row.model,
-            status: row.status as ResponseRecord['status'],
-            request: row.request ? JSON.parse(row.request) : undefined,
-            response: row.response ? JSON.parse(row.response) : undefined,
-            error: row.error ? JSON.parse(row.error) : undefined,
-            usage: row.usage ? JSON.parse(row.usage) : undefined,
-            metadata: JSON.parse(row.metadata || '{}'),
-            createdAt: new Date(row.created_at),
-            updatedAt: new Date(row.updated_at),
-        };
+        return rows.results.map(this.rowToThreadState);
     }
 
-    private rowToShadowResult(row: ShadowRow): ShadowResult {
+    async findThreadState(keyHash: string): Promise<ThreadStateEntry | null> {
+        await this.hashThreadKeys();
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.THREAD_STATE} WHERE key_hash = ?`)
+            .bind(keyHash)
+            .first<ThreadStateRow>();
+
+        return row ? this.rowToThreadState(row) : null;
+    }
+
+    async deleteThreadState(threadKey: string): Promise<boolean> {
+        const result = await this.db
+            .prepare(`DELETE FROM ${D1_TABLES.THREAD_STATE} WHERE thread_key = ?`)
+            .bind(threadKey)
+            .run();
+
+        return result.meta.changes > 0;
+    }
+
+    async purgeThreadState(before: Date): Promise<number> {
+        const result = await this.db
+            .prepare(`DELETE FROM ${D1_TABLES.THREAD_STATE} WHERE updated_at < ?`)
+            .bind(before.toISOString())
+            .run();
+
+        return result.meta.changes;
+    }
+
+    /**
+     * Hashes the keys of rows written before key hashes were stored.
+     */
+    private async hashThreadKeys(): Promise<void> {
+        const update = this.db.prepare(`UPDATE ${D1_TABLES.THREAD_STATE} SET key_hash = ? WHERE thread_key = ?`);
+        for (;;) {
+            const rows = await this.db
+                .prepare(`SELECT thread_key FROM ${D1_TABLES.THREAD_STATE} WHERE key_hash IS NULL LIMIT 100`)
+                .all<{ thread_key: string }>();
+            if (rows.results.length === 0) return;
+            await this.db.batch(await Promise.all(rows.results.map(
+                async (row) => update.bind(await sha256(row.thread_key), row.thread_key),
+            )));
+        }
+    }
+
+    // ---- Idempotency Keys ----
+
+    async claimIdempotencyKey(record: IdempotencyRecord): Promise<IdempotencyRecord | null> {
+        for (;;) {
+            const claimed = await this.upsertIdempotencyKey(record);
+            if (claimed) {
+                return null;
+            }
+
+            // The row can expire or be released between the upsert and this
+            // read; claim again rather than report a key nobody holds
+            const existing = await this.getIdempotencyRecord(record.tenantId, record.key);
+            if (existing) {
+                return existing;
+            }
+        }
+    }
+
+    /**
+     * Inserts a claim, replacing an expired one. Returns whether the row
+     * was written.
+     */
+    private async upsertIdempotencyKey(record: IdempotencyRecord): Promise<boolean> {
+        const result = await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.IDEMPOTENCY_KEYS} (
+          tenant_id, idempotency_key, interaction_id, request_hash, status, response, created_at, expires_at
+        )
+        VALUES (?, ?, ?, ?, ?, NULL, ?, ?)
+        ON CONFLICT(tenant_id, idempotency_key) DO UPDATE SET
+          interaction_id = excluded.interaction_id,
+          request_hash = excluded.request_hash,
+          status = excluded.status,
+          response = NULL,
+          created_at = excluded.created_at,
+          expires_at = excluded.expires_at
+        WHERE ${D1_TABLES.IDEMPOTENCY_KEYS}.expires_at <= ?
+      `)
+            .bind(
+                record.tenantId,
+                record.key,
+                record.interactionId,
+                record.requestHash ?? null,
+                record.status,
+                record.createdAt.toISOString(),
+                record.expiresAt.toISOString(),
+                // Expiry is checked against the clock, not createdAt, so a
+                // retry sees rows that expired since the first attempt
+                new Date().toISOString(),
+            )
+            .run();
+
+        return result.meta.changes > 0;
+    }
+
+    async getIdempotencyRecord(tenantId: string, key: string): Promise<IdempotencyRecord | null> {
+        const row = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.IDEMPOTENCY_KEYS}
+        WHERE tenant_id = ? AND idempotency_key = ? AND expires_at > ?
+      `)
+            .bind(tenantId, key, new Date().toISOString())
+            .first<IdempotencyRow>();
+
+        if (!row) return null;
+
         return {
-            id: row.id,
+            tenantId: row.tenant_id,
+            key: row.idempotency_key,
             interactionId: row.interaction_id,
-            providerName: row.provider_name,
-            request: row.request ? JSON.parse(row.request) : undefined,
+            requestHash: row.request_hash ?? undefined,
+            status: row.status as IdempotencyRecord['status'],
             response: row.response ? JSON.parse(row.response) : undefined,
-            error: row.error ? JSON.parse(row.error) : undefined,
-            durationMs: row.duration_ms,
-            divergences: JSON.parse(row.divergences),
-            hasStructuralDivergence: row.has_structural_divergence === 1,
             createdAt: new Date(row.created_at),
+            expiresAt: new Date(row.expires_at),
         };
     }
-}
 
-// ============================================================================
-// Internal Row Types
-// ============================================================================
+    async renewIdempotencyKey(tenantId: string, key: string, interactionId: string, expiresAt: Date): Promise<void> {
+        await this.db
+            .prepare(`
+        UPDATE ${D1_TABLES.IDEMPOTENCY_KEYS}
+        SET expires_at = ?
+        WHERE tenant_id = ? AND idempotency_key = ? AND interaction_id = ? AND status = 'in_progress'
+      `)
+            .bind(expiresAt.toISOString(), tenantId, key, interactionId)
+            .run();
+    }
 
-interface ConversationRow {
-    id: string;
-    tenant_id: string;
-    app_name: string | null;
-    model: string | null;
-    metadata: string;
-    created_at: string;
-    updated_at: string;
-}
+    async completeIdempotencyKey(
+        tenantId: string,
+        key: string,
+        response: StoredHTTPResponse,
+        expiresAt: Date,
+    ): Promise<void> {
+        await this.db
+            .prepare(`
+        UPDATE ${D1_TABLES.IDEMPOTENCY_KEYS}
+        SET status = 'completed', response = ?, expires_at = ?
+        WHERE tenant_id = ? AND idempotency_key = ?
+      `)
+            .bind(JSON.stringify(response), expiresAt.toISOString(), tenantId, key)
+            .run();
+    }
 
-interface MessageRow {
-    id: string;
-    conversation_id: string;
-    role: string;
-    content: string;
-    usage: string | null;
-    timestamp: string;
-}
+    async releaseIdempotencyKey(tenantId: string, key: string, interactionId: string): Promise<void> {
+        await this.db
+            .prepare(`
+        DELETE FROM ${D1_TABLES.IDEMPOTENCY_KEYS}
+        WHERE tenant_id = ? AND idempotency_key = ? AND interaction_id = ?
+      `)
+            .bind(tenantId, key, interactionId)
+            .run();
+    }
 
-interface ResponseRow {
-    id: string;
-    tenant_id: string;
-    app_name: string | null;
-    thread_key: string | null;
-    previous_response_id: string | null;
-    model: string;
-    status: string;
-    request: string | null;
-    response: string | null;
-    error: string | null;
-    usage: string | null;
-    metadata: string;
-    created_at: string;
-    updated_at: string;
-}
+    // ---- Usage ----
 
-interface InteractionRow {
-    type: string;
-    id: string;
-    tenant_id: string;
-    app_name: string | null;
-    model: string | null;
-    created_at: string;
-    updated_at: string;
-}
+    async recordUsage(record: UsageRecord): Promise<void> {
+        await this.db
+            .prepare(
+                `INSERT OR IGNORE INTO ${D1_TABLES.USAGE} (tenant_id, interaction_id, model, total_tokens, cost_usd, created_at)
+         VALUES (?, ?, ?, ?, ?, ?)`,
+            )
+            .bind(
+                record.tenantId,
+                record.interactionId,
+                record.model,
+                record.totalTokens,
+                record.costUsd,
+                record.createdAt.toISOString(),
+            )
+            .run();
+    }
 
-interface EventRow {
-    id: string;
-    interaction_id: string;
-    type: string;
-    payload: string | null;
-    timestamp: string;
-}
+    async sumUsage(tenantId: string, since: Date, options: UsageSumOptions = {}): Promise<UsageTotals> {
+        // Exclusions go in as one JSON array to stay under D1's bound parameter limit
+        const row = await this.db
+            .prepare(`
+        SELECT COALESCE(SUM(total_tokens), 0) AS tokens, COALESCE(SUM(cost_usd), 0) AS cost_usd, COUNT(*) AS requests
+        FROM ${D1_TABLES.USAGE}
+        WHERE tenant_id = ? AND created_at >= ? AND (? IS NULL OR created_at < ?)
+          AND interaction_id NOT IN (SELECT value FROM json_each(?))
+      `)
+            .bind(
+                tenantId,
+                since.toISOString(),
+                options.before?.toISOString() ?? null,
+                options.before?.toISOString() ?? null,
+                JSON.stringify(options.excluding ?? []),
+            )
+            .first<{ tokens: number; cost_usd: number; requests: number }>();
+
+        return {
+            tokens: row?.tokens ?? 0,
+            costUsd: row?.cost_usd ?? 0,
+            requests: row?.requests ?? 0,
+        };
+    }
+
+    // ---- Usage Stats ----
+
+    async recordRequestStat(record: RequestStatRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT OR REPLACE INTO ${D1_TABLES.REQUEST_STATS}
+          (interaction_id, tenant_id, model, prompt_tokens, completion_tokens, total_tokens, latency_ms, error, created_at, end_user,
+           language, safety_flags)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      `)
+            .bind(
+                record.interactionId,
+                record.tenantId,
+                record.model,
+                record.promptTokens,
+                record.completionTokens,
+                record.totalTokens,
+                record.latencyMs ?? null,
+                record.error ? 1 : 0,
+                record.createdAt.toISOString(),
+                record.endUser ?? null,
+                record.language ?? null,
+                record.safetyFlags?.length ? JSON.stringify(record.safetyFlags) : null,
+            )
+            .run();
+    }
+
+    async aggregateUsageStats(
+        tenantId: string,
+        from: Date,
+        to: Date,
+        options: { byEndUser?: boolean | undefined; byLanguage?: boolean | undefined } = {},
+    ): Promise<UsageStatsRow[]> {
+        const range = [tenantId, from.toISOString(), to.toISOString()];
+        const endUser = options.byEndUser ? ', end_user' : options.byLanguage ? ', language' : '';
+        const flagged = options.byLanguage ? ', SUM(safety_flags IS NOT NULL) AS safety_flagged' : '';
+        const [totals, latencies] = await Promise.all([
+            this.db
+                .prepare(`
+        SELECT substr(created_at, 1, 10) AS day, model${endUser}, COUNT(*) AS requests, SUM(error) AS errors,
+          SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens,
+          SUM(total_tokens) AS total_tokens${flagged}
+        FROM ${D1_TABLES.REQUEST_STATS}
+        WHERE tenant_id = ? AND created_at >= ? AND created_at < ?
+        GROUP BY day, model${endUser}
+        ORDER BY day, model${endUser}
+      `)
+                .bind(...range)
+                .all<UsageStatsDbRow>(),
+            // Nearest-rank p95: the ceil(0.95 * n)th smallest latency per group
+            this.db
+                .prepare(`
+        SELECT day, model${endUser}, latency_ms FROM (
+          SELECT substr(created_at, 1, 10) AS day, model${endUser}, latency_ms,
+            ROW_NUMBER() OVER (PARTITION BY substr(created_at, 1, 10), model${endUser} ORDER BY latency_ms) AS rank,
+            COUNT(*) OVER (PARTITION BY substr(created_at, 1, 10), model${endUser}) AS n
+          FROM ${D1_TABLES.REQUEST_STATS}
+          WHERE tenant_id = ? AND created_at >= ? AND created_at < ? AND latency_ms IS NOT NULL
+        ) WHERE rank = (n * 95 + 99) / 100
+      `)
+                .bind(...range)
+                .all<{ day: string; model: string; end_user?: string | null; language?: string | null; latency_ms: number }>(),
+        ]);
+
+        const groupKey = (row: { day: string; model: string; end_user?: string | null; language?: string | null }) =>
+            `${row.day}\u0000${row.model}\u0000${row.end_user ?? row.language ?? ''}`;
+        const p95 = new Map(latencies.results.map((row) => [groupKey(row), row.latency_ms] as const));
+        return totals.results.map((row) => ({
+            day: row.day,
+            model: row.model,
+            ...(options.byEndUser && { endUser: row.end_user ?? undefined }),
+            ...(options.byLanguage && { language: row.language ?? undefined }),
+            requests: row.requests,
+            errors: row.errors,
+            promptTokens: row.prompt_tokens,
+            completionTokens: row.completion_tokens,
+            totalTokens: row.total_tokens,
+            p95LatencyMs: p95.get(groupKey(row)),
+            ...(options.byLanguage && { safetyFlagged: row.safety_flagged ?? 0 }),
+        }));
+    }
+
+    async findRequestStatsByEndUser(tenantId: string, endUser: string): Promise<RequestStatRecord[]> {
+        const result = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.REQUEST_STATS}
+        WHERE end_user = ? AND (? = '' OR tenant_id = ?)
+        ORDER BY created_at DESC, interaction_id DESC
+      `)
+            .bind(endUser, tenantId, tenantId)
+            .all<RequestStatRow>();
+
+        return result.results.map(rowToRequestStat);
+    }
+
+    async findRequestStatsByClassification(
+        tenantId: string,
+        filter: RequestStatClassificationFilter,
+    ): Promise<RequestStatRecord[]> {
+        const clauses = ["(? = '' OR tenant_id = ?)"];
+        const params: unknown[] = [tenantId, tenantId];
+        if (filter.language !== undefined) {
+            clauses.push('language = ?');
+            params.push(filter.language);
+        }
+        if (filter.safety === '*') {
+            clauses.push('safety_flags IS NOT NULL');
+        } else if (filter.safety !== undefined) {
+            clauses.push('EXISTS (SELECT 1 FROM json_each(safety_flags) WHERE value = ?)');
+            params.push(filter.safety);
+        }
+        const result = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.REQUEST_STATS}
+        WHERE ${clauses.join(' AND ')}
+        ORDER BY created_at DESC, interaction_id DESC
+      `)
+            .bind(...params)
+            .all<RequestStatRow>();
+
+        return result.results.map(rowToRequestStat);
+    }
+
+    // ---- Erasure ----
+
+    async eraseInteractions(selector: ErasureSelector): Promise<ErasureCounts> {
+        const counts = emptyErasureCounts();
+        if (!selector.metadata && !selector.threadKey && !selector.interactionIds) {
+            return counts;
+        }
+        if (selector.interactionIds && selector.interactionIds.length > ERASURE_BATCH) {
+            for (const interactionIds of batches(selector.interactionIds)) {
+                const batch = await this.eraseInteractions({ ...selector, interactionIds });
+                for (const kind of Object.keys(counts) as (keyof ErasureCounts)[]) {
+                    counts[kind] += batch[kind];
+                }
+            }
+            return counts;
+        }
+
+        const tombstone = JSON.stringify(ERASED);
+        const where = erasureWhere(selector);
+
+        // Conversations have no thread key, so a thread selector never matches them
+        const conversationIds = selector.threadKey ? [] : await this.selectIds(D1_TABLES.CONVERSATIONS, where);
+        const responseIds = await this.selectIds(D1_TABLES.RESPONSES, where);
+        // Interaction records carry their tenant but no metadata or thread
+        // key, so only an ID selector finds them directly
+        const interactionIds = selector.metadata || selector.threadKey ? [] : await this.selectIds(D1_TABLES.INTERACTIONS, where);
+
+        // A bare ID selector also reaches events and shadows recorded under
+        // a gateway interaction ID with no stored conversation or response
+        const erased = new Set([...conversationIds, ...responseIds, ...interactionIds]);
+        if (!selector.tenantId && !selector.metadata && !selector.threadKey) {
+            for (const id of selector.interactionIds ?? []) erased.add(id);
+        }
+
+        for (const ids of batches(conversationIds)) {
+            const marks = ids.map(() => '?').join(', ');
+            counts.messages += await this.changes(
+                `UPDATE ${D1_TABLES.MESSAGES} SET content = ?, content_hash = NULL WHERE conversation_id IN (${marks})`,
+                ERASED, ...ids,
+            );
+            counts.conversations += await this.changes(
+                `UPDATE ${D1_TABLES.CONVERSATIONS} SET metadata = '{}' WHERE id IN (${marks})`,
+                ...ids,
+            );
+        }
+
+        for (const ids of batches(responseIds)) {
+            counts.responses += await this.changes(`
+        UPDATE ${D1_TABLES.RESPONSES}
+        SET request = ?, response = ?, metadata = '{}',
+          error = CASE WHEN error IS NULL OR error = 'null' THEN error ELSE ? END
+        WHERE id IN (${ids.map(() => '?').join(', ')})
+      `, tombstone, tombstone, tombstone, ...ids);
+        }
+
+        for (const ids of batches([...erased])) {
+            const marks = ids.map(() => '?').join(', ');
+            counts.events += await this.changes(
+                `UPDATE ${D1_TABLES.INTERACTION_EVENTS} SET payload = ? WHERE interaction_id IN (${marks})`,
+                tombstone, ...ids,
+            );
+            counts.interactions += await this.changes(
+                `UPDATE ${D1_TABLES.INTERACTIONS} SET request = ? WHERE request IS NOT NULL AND id IN (${marks})`,
+                ERASED, ...ids,
+            );
+
+            // Shadow scrubbing rewrites nested JSON, so it is done row by row
+            const shadows = await this.db
+                .prepare(`SELECT * FROM ${D1_TABLES.SHADOW_RESULTS} WHERE interaction_id IN (${marks})`)
+                .bind(...ids)
+                .all<ShadowRow>();
+            for (const row of shadows.results) {
+                const scrubbed = scrubShadowResult(this.rowToShadowResult(row));
+                await this.db
+                    .prepare(`UPDATE ${D1_TABLES.SHADOW_RESULTS} SET response = ?, error = ?, divergences = ? WHERE id = ?`)
+                    .bind(
+                        JSON.stringify(scrubbed.response ?? null),
+                        JSON.stringify(scrubbed.error ?? null),
+                        JSON.stringify(scrubbed.divergences),
+                        row.id,
+                    )
+                    .run();
+            }
+            counts.shadowResults += shadows.results.length;
+
+            counts.idempotencyKeys += await this.changes(`
+        UPDATE ${D1_TABLES.IDEMPOTENCY_KEYS} SET response = json_set(response, '$.body', ?)
+        WHERE response IS NOT NULL AND (? = '' OR tenant_id = ?) AND interaction_id IN (${marks})
+      `, ERASED, selector.tenantId ?? '', selector.tenantId ?? '', ...ids);
+        }
+
+        if (selector.threadKey) {
+            await this.changes(`DELETE FROM ${D1_TABLES.THREAD_STATE} WHERE thread_key = ?`, selector.threadKey);
+        }
+        return counts;
+    }
+
+    private async selectIds(table: string, where: { sql: string; params: string[] }): Promise<string[]> {
+        const rows = await this.db
+            .prepare(`SELECT id FROM ${table} WHERE ${where.sql}`)
+            .bind(...where.params)
+            .all<{ id: string }>();
+        return rows.results.map((row) => row.id);
+    }
+
+    private async changes(sql: string, ...params: string[]): Promise<number> {
+        const result = await this.db.prepare(sql).bind(...params).run();
+        return result.meta.changes;
+    }
+
+    // ---- Tenants ----
+
+    async saveTenant(tenant: StoredTenant): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.TENANTS} (id, name, api_keys, routing, disabled, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+          name = excluded.name,
+          api_keys = excluded.api_keys,
+          routing = excluded.routing,
+          disabled = excluded.disabled,
+          updated_at = excluded.updated_at
+      `)
+            .bind(
+                tenant.id,
+                tenant.name,
+                JSON.stringify(tenant.apiKeys),
+                tenant.routing ? JSON.stringify(tenant.routing) : null,
+                tenant.disabled ? 1 : 0,
+                tenant.createdAt.toISOString(),
+                tenant.updatedAt.toISOString(),
+            )
+            .run();
+    }
+
+    async getTenant(id: string): Promise<StoredTenant | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.TENANTS} WHERE id = ?`)
+            .bind(id)
+            .first<TenantRow>();
+        return row ? this.rowToTenant(row) : null;
+    }
+
+    async listTenants(): Promise<StoredTenant[]> {
+        const result = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.TENANTS} ORDER BY id`)
+            .all<TenantRow>();
+        return result.results.map((row) => this.rowToTenant(row));
+    }
+
+    // ---- Thread Transfer ----
+
+    async importThreadBatch(batch: ThreadImportBatch): Promise<void> {
+        const conversation = this.db.prepare(`
+      INSERT INTO ${D1_TABLES.CONVERSATIONS} (id, tenant_id, app_name, model, metadata, created_at, updated_at)
+      VALUES (?, ?, ?, ?, ?, ?, ?)
+      ON CONFLICT(id) DO UPDATE SET
+        tenant_id = excluded.tenant_id,
+        app_name = excluded.app_name,
+        model = excluded.model,
+        metadata = excluded.metadata,
+        created_at = excluded.created_at,
+        updated_at = excluded.updated_at
+    `);
+        const message = this.db.prepare(`
+      INSERT INTO ${D1_TABLES.MESSAGES} (id, conversation_id, role, content, usage, timestamp)
+      VALUES (?, ?, ?, ?, ?, ?)
+      ON CONFLICT(id) DO UPDATE SET
+        conversation_id = excluded.conversation_id,
+        role = excluded.role,
+        content = excluded.content,
+        usage = excluded.usage,
+        timestamp = excluded.timestamp
+    `);
+        const response = this.db.prepare(`
+      INSERT INTO ${D1_TABLES.RESPONSES} (
+        id, tenant_id, app_name, thread_key, previous_response_id,
+        model, status, request, response, error, usage, metadata, timings, created_at, updated_at
+      )
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      ON CONFLICT(id) DO UPDATE SET
+        tenant_id = excluded.tenant_id,
+        app_name = excluded.app_name,
+        thread_key = excluded.thread_key,
+        previous_response_id = excluded.previous_response_id,
+        model = excluded.model,
+        status = excluded.status,
+        request = excluded.request,
+        response = excluded.response,
+        error = excluded.error,
+        usage = excluded.usage,
+        metadata = excluded.metadata,
+        timings = excluded.timings,
+        created_at = excluded.created_at,
+        updated_at = excluded.updated_at
+    `);
+        const threadState = this.db.prepare(`
+      INSERT INTO ${D1_TABLES.THREAD_STATE} (thread_key, response_id, updated_at, key_hash)
+      VALUES (?, ?, ?, ?)
+      ON CONFLICT(thread_key) DO UPDATE SET
+        response_id = excluded.response_id,
+        updated_at = excluded.updated_at,
+        key_hash = excluded.key_hash
+    `);
+
+        // One batch is one transaction
+        await this.db.batch([
+            ...batch.conversations.flatMap((c) => [
+                conversation.bind(
+                    c.id,
+                    c.tenantId,
+                    c.appName ?? null,
+                    c.model ?? null,
+                    JSON.stringify(c.metadata ?? {}),
+                    c.createdAt.toISOString(),
+                    c.updatedAt.toISOString(),
+                ),
+                ...c.messages.map((m) => message.bind(
+                    m.id,
+                    c.id,
+                    m.role,
+                    m.content,
+                    JSON.stringify(m.usage ?? null),
+                    m.timestamp.toISOString(),
+                )),
+            ]),
+            ...batch.responses.map((r) => response.bind(
+                r.id,
+                r.tenantId,
+                r.appName ?? null,
+                r.threadKey ?? null,
+                r.previousResponseId ?? null,
+                r.model,
+                r.status,
+                JSON.stringify(r.request ?? null),
+                JSON.stringify(r.response ?? null),
+                JSON.stringify(r.error ?? null),
+                JSON.stringify(r.usage ?? null),
+                JSON.stringify(r.metadata ?? {}),
+                JSON.stringify(r.timings ?? null),
+                r.createdAt.toISOString(),
+                r.updatedAt.toISOString(),
+            )),
+            ...await Promise.all(batch.threadState.map(async (e) => threadState.bind(
+                e.threadKey,
+                e.responseId,
+                e.updatedAt.toISOString(),
+                await sha256(e.threadKey),
+            ))),
+        ]);
+    }
+
+    async getThreadImport(checksum: string): Promise<ThreadImportRecord | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.THREAD_IMPORTS} WHERE checksum = ?`)
+            .bind(checksum)
+            .first<ThreadImportRow>();
+        return row
+            ? {
+                checksum: row.checksum,
+                conversations: row.conversations,
+                responses: row.responses,
+                threadState: row.thread_state,
+                remapped: JSON.parse(row.remapped),
+                importedAt: new Date(row.imported_at),
+            }
+            : null;
+    }
+
+    async recordThreadImport(record: ThreadImportRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.THREAD_IMPORTS} (checksum, conversations, responses, thread_state, remapped, imported_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(checksum) DO NOTHING
+      `)
+            .bind(
+                record.checksum,
+                record.conversations,
+                record.responses,
+                record.threadState,
+                JSON.stringify(record.remapped),
+                record.importedAt.toISOString(),
+            )
+            .run();
+    }
+
+    // ---- Message Batches ----
+
+    async saveBatch(record: BatchRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.MESSAGE_BATCHES}
+          (id, provider_batch_id, provider, key_id, tenant_id, app_name, request_count, created_at, results_recorded_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+          results_recorded_at = excluded.results_recorded_at
+      `)
+            .bind(
+                record.id,
+                record.providerBatchId,
+                record.provider,
+                record.keyId ?? null,
+                record.tenantId,
+                record.appName ?? null,
+                record.requestCount,
+                record.createdAt.toISOString(),
+                record.resultsRecordedAt?.toISOString() ?? null,
+            )
+            .run();
+    }
+
+    async getBatch(id: string, tenantId: string): Promise<BatchRecord | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.MESSAGE_BATCHES} WHERE id = ? AND (? = '' OR tenant_id = ?)`)
+            .bind(id, tenantId, tenantId)
+            .first<BatchRow>();
+        if (!row) return null;
+
+        return {
+            id: row.id,
+            providerBatchId: row.provider_batch_id,
+            provider: row.provider,
+            keyId: row.key_id ?? undefined,
+            tenantId: row.tenant_id,
+            appName: row.app_name ?? undefined,
+            requestCount: row.request_count,
+            createdAt: new Date(row.created_at),
+            resultsRecordedAt: row.results_recorded_at ? new Date(row.results_recorded_at) : undefined,
+        };
+    }
+
+    // ---- Metadata Index ----
+
+    async indexMetadata(record: InteractionMetadataRecord): Promise<void> {
+        const statement = this.db.prepare(`
+        INSERT OR REPLACE INTO ${D1_TABLES.METADATA_INDEX}
+          (interaction_id, tenant_id, app_name, key, value, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+      `);
+        await this.db.batch(Object.entries(record.metadata).map(([key, value]) => statement.bind(
+            record.interactionId,
+            record.tenantId,
+            record.appName ?? null,
+            key,
+            value,
+            record.createdAt.toISOString(),
+        )));
+    }
+
+    async findByMetadata(tenantId: string, key: string, value: string): Promise<InteractionMetadataRecord[]> {
+        const result = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.METADATA_INDEX} WHERE interaction_id IN (
+          SELECT interaction_id FROM ${D1_TABLES.METADATA_INDEX}
+          WHERE key = ? AND value = ? AND (? = '' OR tenant_id = ?)
+        )
+        ORDER BY created_at DESC, interaction_id DESC
+      `)
+            .bind(key, value, tenantId, tenantId)
+            .all<MetadataRow>();
+
+        const records = new Map<string, InteractionMetadataRecord>();
+        for (const row of result.results) {
+            let record = records.get(row.interaction_id);
+            if (!record) {
+                record = {
+                    interactionId: row.interaction_id,
+                    tenantId: row.tenant_id,
+                    appName: row.app_name ?? undefined,
+                    metadata: {},
+                    createdAt: new Date(row.created_at),
+                };
+                records.set(row.interaction_id, record);
+            }
+            record.metadata[row.key] = row.value;
+        }
+        return [...records.values()];
+    }
+
+    // ---- Probe Results ----
+
+    async saveProbeResult(result: ProbeResultRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT INTO ${D1_TABLES.PROBE_RESULTS}
+          (id, provider, model, success, status_code, error, latency_ms, usage, cost_usd, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      `)
+            .bind(
+                result.id,
+                result.provider,
+                result.model,
+                result.success ? 1 : 0,
+                result.statusCode ?? null,
+                result.error ?? null,
+                result.latencyMs,
+                result.usage ? JSON.stringify(result.usage) : null,
+                result.costUsd ?? null,
+                result.createdAt.toISOString(),
+            )
+            .run();
+    }
+
+    async listProbeResults(provider: string, limit: number): Promise<ProbeResultRecord[]> {
+        const result = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.PROBE_RESULTS}
+        WHERE provider = ?
+        ORDER BY created_at DESC, id DESC
+        LIMIT ?
+      `)
+            .bind(provider, limit)
+            .all<ProbeResultRow>();
+
+        return result.results.map((row) => ({
+            id: row.id,
+            provider: row.provider,
+            model: row.model,
+            success: row.success === 1,
+            statusCode: row.status_code ?? undefined,
+            error: row.error ?? undefined,
+            latencyMs: row.latency_ms,
+            usage: row.usage ? JSON.parse(row.usage) : undefined,
+            costUsd: row.cost_usd ?? undefined,
+            createdAt: new Date(row.created_at),
+        }));
+    }
+
+    // ---- SLO Snapshots ----
+
+    async saveSloSnapshot(snapshot: SloSnapshotRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT OR REPLACE INTO ${D1_TABLES.SLO_SNAPSHOTS} (app, config_key, minutes, hours, last_alert_at, saved_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+      `)
+            .bind(
+                snapshot.app,
+                snapshot.configKey,
+                JSON.stringify(snapshot.minutes),
+                JSON.stringify(snapshot.hours),
+                snapshot.lastAlertAt?.toISOString() ?? null,
+                snapshot.savedAt.toISOString(),
+            )
+            .run();
+    }
+
+    async getSloSnapshot(app: string): Promise<SloSnapshotRecord | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.SLO_SNAPSHOTS} WHERE app = ?`)
+            .bind(app)
+            .first<SloSnapshotRow>();
+
+        if (!row) return null;
+        return {
+            app: row.app,
+            configKey: row.config_key,
+            minutes: JSON.parse(row.minutes),
+            hours: JSON.parse(row.hours),
+            lastAlertAt: row.last_alert_at ? new Date(row.last_alert_at) : undefined,
+            savedAt: new Date(row.saved_at),
+        };
+    }
+
+    // ---- Tenant Secrets ----
+
+    async saveSecret(secret: SecretRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT OR REPLACE INTO ${D1_TABLES.TENANT_SECRETS} (tenant_id, name, value, version, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+      `)
+            .bind(
+                secret.tenantId,
+                secret.name,
+                secret.value,
+                secret.version,
+                secret.createdAt.toISOString(),
+                secret.updatedAt.toISOString(),
+            )
+            .run();
+    }
+
+    async getSecret(tenantId: string, name: string): Promise<SecretRecord | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.TENANT_SECRETS} WHERE tenant_id = ? AND name = ?`)
+            .bind(tenantId, name)
+            .first<SecretRow>();
+
+        return row ? this.rowToSecret(row) : null;
+    }
+
+    async listSecrets(tenantId?: string): Promise<SecretRecord[]> {
+        const rows = tenantId === undefined
+            ? await this.db
+                .prepare(`SELECT * FROM ${D1_TABLES.TENANT_SECRETS} ORDER BY tenant_id, name`)
+                .all<SecretRow>()
+            : await this.db
+                .prepare(`SELECT * FROM ${D1_TABLES.TENANT_SECRETS} WHERE tenant_id = ? ORDER BY name`)
+                .bind(tenantId)
+                .all<SecretRow>();
+
+        return rows.results.map((row) => this.rowToSecret(row));
+    }
+
+    async deleteSecret(tenantId: string, name: string): Promise<boolean> {
+        const result = await this.db
+            .prepare(`DELETE FROM ${D1_TABLES.TENANT_SECRETS} WHERE tenant_id = ? AND name = ?`)
+            .bind(tenantId, name)
+            .run();
+
+        return result.meta.changes > 0;
+    }
+
+    private rowToSecret(row: SecretRow): SecretRecord {
+        return {
+            tenantId: row.tenant_id,
+            name: row.name,
+            value: row.value,
+            version: row.version,
+            createdAt: new Date(row.created_at),
+            updatedAt: new Date(row.updated_at),
+        };
+    }
+
+    // ---- Sensitive Values ----
+
+    async scanSensitiveValues(field: SensitiveField, after: string | undefined, limit: number): Promise<SensitiveValue[]> {
+        const { table, column, json } = SENSITIVE_COLUMNS[field];
+        const rows = await this.db
+            .prepare(`
+        SELECT id, ${column} AS value FROM ${table}
+        WHERE id > ? AND ${column} IS NOT NULL ${json ? `AND ${column} != 'null'` : ''}
+        ORDER BY id ASC
+        LIMIT ?
+      `)
+            .bind(after ?? '', limit)
+            .all<{ id: string; value: string }>();
+
+        return rows.results.map((row) => ({ field, id: row.id, value: json ? JSON.parse(row.value) : row.value }));
+    }
+
+    async updateSensitiveValue(value: SensitiveValue): Promise<void> {
+        const { table, column, json } = SENSITIVE_COLUMNS[value.field];
+        await this.db
+            .prepare(`UPDATE ${table} SET ${column} = ? WHERE id = ?`)
+            .bind(json ? JSON.stringify(value.value) : value.value, value.id)
+            .run();
+    }
+
+    // ---- Interaction Attempts ----
+
+    async saveAttempts(records: InteractionAttemptRecord[]): Promise<void> {
+        const interactionId = records[0]?.interactionId;
+        if (interactionId === undefined) return;
+        const insert = this.db.prepare(`
+        INSERT INTO ${D1_TABLES.INTERACTION_ATTEMPTS}
+          (interaction_id, attempt, tenant_id, provider, model, reason, prompt_tokens, completion_tokens,
+           total_tokens, cost_usd, duration_ms, outcome, error, status_code, upstream_error, served, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      `);
+        await this.db.batch([
+            this.db.prepare(`DELETE FROM ${D1_TABLES.INTERACTION_ATTEMPTS} WHERE interaction_id = ?`).bind(interactionId),
+            ...records.map((record) => insert.bind(
+                record.interactionId,
+                record.attempt,
+                record.tenantId,
+                record.provider,
+                record.model,
+                record.reason,
+                record.usage?.promptTokens ?? null,
+                record.usage?.completionTokens ?? null,
+                record.usage?.totalTokens ?? null,
+                record.costUsd ?? null,
+                record.durationMs,
+                record.outcome,
+                record.error ?? null,
+                record.statusCode ?? null,
+                record.upstreamError ?? null,
+                record.served ? 1 : 0,
+                record.createdAt.toISOString(),
+            )),
+        ]);
+    }
+
+    async listAttempts(interactionId: string, tenantId: string): Promise<InteractionAttemptRecord[]> {
+        const result = await this.db
+            .prepare(`
+        SELECT * FROM ${D1_TABLES.INTERACTION_ATTEMPTS}
+        WHERE interaction_id = ? AND (? = '' OR tenant_id = ?)
+        ORDER BY attempt
+      `)
+            .bind(interactionId, tenantId, tenantId)
+            .all<AttemptRow>();
+
+        return result.results.map((row) => ({
+            interactionId: row.interaction_id,
+            attempt: row.attempt,
+            tenantId: row.tenant_id,
+            provider: row.provider,
+            model: row.model,
+            reason: row.reason as InteractionAttemptRecord['reason'],
+            usage: row.total_tokens === null ? undefined : {
+                promptTokens: row.prompt_tokens ?? 0,
+                completionTokens: row.completion_tokens ?? 0,
+                totalTokens: row.total_tokens,
+            },
+            costUsd: row.cost_usd ?? undefined,
+            durationMs: row.duration_ms,
+            outcome: row.outcome as InteractionAttemptRecord['outcome'],
+            error: row.error ?? undefined,
+            statusCode: row.status_code ?? undefined,
+            upstreamError: row.upstream_error ?? undefined,
+            served: row.served === 1,
+            createdAt: new Date(row.created_at),
+        }));
+    }
+
+    async aggregateAttempts(tenantId: string, from: Date, to: Date): Promise<AttemptUsageRow[]> {
+        const result = await this.db
+            .prepare(`
+        SELECT substr(created_at, 1, 10) AS day, model, reason, COUNT(*) AS attempts,
+          SUM(outcome = 'error') AS errors, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+          COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+          COALESCE(SUM(total_tokens), 0) AS total_tokens, COALESCE(SUM(cost_usd), 0) AS cost_usd
+        FROM ${D1_TABLES.INTERACTION_ATTEMPTS}
+        WHERE tenant_id = ? AND created_at >= ? AND created_at < ?
+        GROUP BY day, model, reason
+        ORDER BY day, model, reason
+      `)
+            .bind(tenantId, from.toISOString(), to.toISOString())
+            .all<AttemptUsageDbRow>();
+
+        return result.results.map((row) => ({
+            day: row.day,
+            model: row.model,
+            reason: row.reason as AttemptUsageRow['reason'],
+            attempts: row.attempts,
+            errors: row.errors,
+            promptTokens: row.prompt_tokens,
+            completionTokens: row.completion_tokens,
+            totalTokens: row.total_tokens,
+            costUsd: row.cost_usd,
+        }));
+    }
+
+    // ---- Interaction Records ----
+
+    async recordInteraction(record: InteractionRecord): Promise<void> {
+        await this.db
+            .prepare(`
+        INSERT OR REPLACE INTO ${D1_TABLES.INTERACTIONS}
+          (id, tenant_id, app_name, frontdoor, provider, model, stream, status, request, status_code, error_type,
+           created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      `)
+            .bind(
+                record.id,
+                record.tenantId,
+                record.appName ?? null,
+                record.frontdoor,
+                record.provider,
+                record.model,
+                record.stream ? 1 : 0,
+                record.status,
+                record.request ?? null,
+                record.statusCode ?? null,
+                record.errorType ?? null,
+                record.createdAt.toISOString(),
+                record.updatedAt.toISOString(),
+            )
+            .run();
+    }
+
+    async updateInteraction(id: string, update: InteractionUpdate): Promise<boolean> {
+        const result = await this.db
+            .prepare(`
+        UPDATE ${D1_TABLES.INTERACTIONS}
+        SET status = ?, status_code = ?, error_type = ?, updated_at = ?
+        WHERE id = ?
+      `)
+            .bind(update.status, update.statusCode ?? null, update.errorType ?? null, update.updatedAt.toISOString(), id)
+            .run();
+        return result.meta.changes > 0;
+    }
+
+    async getInteraction(id: string, tenantId: string): Promise<InteractionRecord | null> {
+        const row = await this.db
+            .prepare(`SELECT * FROM ${D1_TABLES.INTERACTIONS} WHERE id = ? AND (? = '' OR tenant_id = ?)`)
+            .bind(id, tenantId, tenantId)
+            .first<InteractionRecordRow>();
+        return row ? rowToInteraction(row) : null;
+    }
+
+    async abandonInteractions(before: Date, updatedAt: Date): Promise<number> {
+        const result = await this.db
+            .prepare(`
+        UPDATE ${D1_TABLES.INTERACTIONS}
+        SET status = 'abandoned', updated_at = ?
+        WHERE status = 'in_progress' AND created_at < ?
+      `)
+            .bind(updatedAt.toISOString(), before.toISOString())
+            .run();
+        return result.meta.changes;
+    }
+
+    // ---- Health ----
+
+    async ping(): Promise<void> {
+        await this.db.prepare('SELECT 1').first();
+    }
+
+    // ---- Schema Migrations ----
+
+    async migrate(): Promise<MigrationResult> {
+        return new MigrationRunner(this.migrationDatabase(), STORAGE_MIGRATIONS).migrate();
+    }
+
+    async migrationStatus(): Promise<MigrationStatus[]> {
+        return new MigrationRunner(this.migrationDatabase(), STORAGE_MIGRATIONS).status();
+    }
+
+    /**
+     * D1 runs a batch as one transaction, so each migration is atomic.
+     */
+    private migrationDatabase(): MigrationDatabase {
+        return {
+            dialect: 'sqlite',
+            exec: async (statements) => {
+                await this.db.batch(statements.map((s) => this.db.prepare(s.sql).bind(...(s.params ?? []))));
+            },
+            query: async (sql, params = []) => {
+                const result = await this.db.prepare(sql).bind(...params).all<Record<string, unknown>>();
+                return result.results;
+            },
+        };
+    }
+
+    // ---- Helpers ----
+
+    private rowToTenant(row: TenantRow): StoredTenant {
+        return {
+            id: row.id,
+            name: row.name,
+            apiKeys: JSON.parse(row.api_keys),
+            routing: row.routing ? JSON.parse(row.routing) : undefined,
+            disabled: row.disabled === 1,
+            createdAt: new Date(row.created_at),
+            updatedAt: new Date(row.updated_at),
+        };
+    }
+
+    private rowToResponse(row: ResponseRow): ResponseRecord {
+        return {
+            id: row.id,
+            tenantId: row.tenant_id,
+            appName: row.app_name ?? undefined,
+            threadKey: row.thread_key ?? undefined,
+            previousResponseId: row.previous_response_id ?? undefined,
+            model: row.model,
+            status: row.status as ResponseRecord['status'],
+            request: row.request ? JSON.parse(row.request) : undefined,
+            response: row.response ? JSON.parse(row.response) : undefined,
+            error: row.error ? JSON.parse(row.error) : undefined,
+            usage: row.usage ? JSON.parse(row.usage) : undefined,
+            metadata: JSON.parse(row.metadata || '{}'),
+            timings: row.timings ? JSON.parse(row.timings) : undefined,
+            createdAt: new Date(row.created_at),
+            updatedAt: new Date(row.updated_at),
+        };
+    }
+
+    private rowToShadowResult(row: ShadowRow): ShadowResult {
+        return {
+            id: row.id,
+            interactionId: row.interaction_id,
+            providerName: row.provider_name,
+            request: row.request ? JSON.parse(row.request) : undefined,
+            response: row.response ? JSON.parse(row.response) : undefined,
+            error: row.error ? JSON.parse(row.error) : undefined,
+            durationMs: row.duration_ms,
+            divergences: JSON.parse(row.divergences),
+            hasStructuralDivergence: row.has_structural_divergence === 1,
+            createdAt: new Date(row.created_at),
+        };
+    }
+
+    private rowToThreadState(row: ThreadStateRow): ThreadStateEntry {
+        return {
+            threadKey: row.thread_key,
+            keyHash: row.key_hash,
+            responseId: row.response_id,
+            updatedAt: new Date(row.updated_at),
+        };
+    }
+}
+
+// ============================================================================
+// Internal Row Types
+// ============================================================================
+
+interface ConversationRow {
+    id: string;
+    tenant_id: string;
+    app_name: string | null;
+    model: string | null;
+    metadata: string;
+    created_at: string;
+    updated_at: string;
+}
+
+interface MessageRow {
+    id: string;
+    conversation_id: string;
+    role: string;
+    content: string;
+    usage: string | null;
+    timestamp: string;
+    content_hash: string | null;
+}
+
+interface ResponseRow {
+    id: string;
+    tenant_id: string;
+    app_name: string | null;
+    thread_key: string | null;
+    previous_response_id: string | null;
+    model: string;
+    status: string;
+    request: string | null;
+    response: string | null;
+    error: string | null;
+    usage: string | null;
+    metadata: string;
+    timings: string | null;
+    created_at: string;
+    updated_at: string;
+}
+
+interface InteractionRow {
+    type: string;
+    id: string;
+    tenant_id: string;
+    app_name: string | null;
+    model: string | null;
+    message_count: number | null;
+    status: string | null;
+    provider: string | null;
+    created_at: string;
+    updated_at: string;
+}
+
+interface EventRow {
+    id: string;
+    interaction_id: string;
+    type: string;
+    payload: string | null;
+    timestamp: string;
+}
 
 interface ShadowRow {
     id: string;
@@ -485,3 +1916,168 @@ interface ShadowRow {
     has_structural_divergence: number;
     created_at: string;
 }
+
+interface IdempotencyRow {
+    tenant_id: string;
+    idempotency_key: string;
+    interaction_id: string;
+    request_hash: string | null;
+    status: string;
+    response: string | null;
+    created_at: string;
+    expires_at: string;
+}
+
+interface TenantRow {
+    id: string;
+    name: string;
+    api_keys: string;
+    routing: string | null;
+    disabled: number;
+    created_at: string;
+    updated_at: string;
+}
+
+interface UsageStatsDbRow {
+    day: string;
+    model: string;
+    end_user?: string | null;
+    language?: string | null;
+    requests: number;
+    errors: number;
+    prompt_tokens: number;
+    completion_tokens: number;
+    total_tokens: number;
+    safety_flagged?: number | null;
+}
+
+interface RequestStatRow {
+    interaction_id: string;
+    tenant_id: string;
+    model: string;
+    prompt_tokens: number;
+    completion_tokens: number;
+    total_tokens: number;
+    latency_ms: number | null;
+    error: number;
+    created_at: string;
+    end_user: string | null;
+    language: string | null;
+    safety_flags: string | null;
+}
+
+interface ThreadStateRow {
+    thread_key: string;
+    response_id: string;
+    updated_at: string;
+    key_hash: string;
+}
+
+interface ThreadImportRow {
+    checksum: string;
+    conversations: number;
+    responses: number;
+    thread_state: number;
+    remapped: string;
+    imported_at: string;
+}
+
+interface ProbeResultRow {
+    id: string;
+    provider: string;
+    model: string;
+    success: number;
+    status_code: number | null;
+    error: string | null;
+    latency_ms: number;
+    usage: string | null;
+    cost_usd: number | null;
+    created_at: string;
+}
+
+interface SloSnapshotRow {
+    app: string;
+    config_key: string;
+    minutes: string;
+    hours: string;
+    last_alert_at: string | null;
+    saved_at: string;
+}
+
+interface SecretRow {
+    tenant_id: string;
+    name: string;
+    value: string;
+    version: number;
+    created_at: string;
+    updated_at: string;
+}
+
+interface AttemptRow {
+    interaction_id: string;
+    attempt: number;
+    tenant_id: string;
+    provider: string;
+    model: string;
+    reason: string;
+    prompt_tokens: number | null;
+    completion_tokens: number | null;
+    total_tokens: number | null;
+    cost_usd: number | null;
+    duration_ms: number;
+    outcome: string;
+    error: string | null;
+    status_code: number | null;
+    upstream_error: string | null;
+    served: number;
+    created_at: string;
+}
+
+interface InteractionRecordRow {
+    id: string;
+    tenant_id: string;
+    app_name: string | null;
+    frontdoor: string;
+    provider: string;
+    model: string;
+    stream: number;
+    status: string;
+    request: string | null;
+    status_code: number | null;
+    error_type: string | null;
+    created_at: string;
+    updated_at: string;
+}
+
+interface AttemptUsageDbRow {
+    day: string;
+    model: string;
+    reason: string;
+    attempts: number;
+    errors: number;
+    prompt_tokens: number;
+    completion_tokens: number;
+    total_tokens: number;
+    cost_usd: number;
+}
+
+interface MetadataRow {
+    interaction_id: string;
+    tenant_id: string;
+    app_name: string | null;
+    key: string;
+    value: string;
+    created_at: string;
+}
+
+interface BatchRow {
+    id: string;
+    provider_batch_id: string;
+    provider: string;
+    key_id: string | null;
+    tenant_id: string;
+    app_name: string | null;
+    request_count: number;
+    created_at: string;
+    results_recorded_at: string | null;
+}
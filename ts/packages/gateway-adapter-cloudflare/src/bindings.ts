@@ -58,4 +58,16 @@ export const D1_TABLES = {
     INTERACTION_EVENTS: 'interaction_events',
     SHADOW_RESULTS: 'shadow_results',
     THREAD_STATE: 'thread_state',
+    IDEMPOTENCY_KEYS: 'idempotency_keys',
+    USAGE: 'usage_records',
+    TENANTS: 'tenants',
+    MESSAGE_BATCHES: 'message_batches',
+    METADATA_INDEX: 'metadata_index',
+    REQUEST_STATS: 'request_stats',
+    PROBE_RESULTS: 'probe_results',
+    INTERACTION_ATTEMPTS: 'interaction_attempts',
+    THREAD_IMPORTS: 'thread_imports',
+    SLO_SNAPSHOTS: 'slo_snapshots',
+    TENANT_SECRETS: 'tenant_secrets',
+    INTERACTIONS: 'interactions',
 } as const;
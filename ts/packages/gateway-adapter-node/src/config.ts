@@ -13,7 +13,67 @@ import type {
     WatchableConfigProvider,
     GatewayConfig,
     ConfigChangeCallback,
+    ProviderHTTPConfig,
+    ProviderDeadlineConfig,
+    ProviderKeyConfig,
+    ProviderProbeConfig,
+    PipelineConfig,
+    PipelineStageCacheConfig,
+    StageConditionConfig,
+    DenyResponseConfig,
+    StreamThrottleConfig,
+    HeaderRulesConfig,
+    GatewayToolsConfig,
+    MirrorConfig,
+    CorsConfig,
+    AppMiddlewareConfig,
+    ResponseTransformConfig,
+    JsonOutputValidationConfig,
+    JsonOutputInvalidAction,
+    RecordingConfig,
+    RecordingMode,
+    RecordingTriggers,
+    EventGranularity,
+    EndUserForwarding,
+    ErrorPassthroughConfig,
+    CoalescingConfig,
+    ThreadSummaryConfig,
+    MessageDedupConfig,
+    EndpointPassthroughConfig,
+    ResponseClassificationConfig,
+    CallBudgetConfig,
+    UsageCheckConfig,
+    ParameterPolicyConfig,
+    ModelParameterPolicyConfig,
+    ParameterRule,
+    PolicyParameter,
+    BudgetConfig,
+    EventsConfig,
+    SpillConfig,
+    StorageHealthConfig,
+    StorageConfig,
+    StorageEncryptionConfig,
+    AffinityConfig,
+    ConcurrencyConfig,
+    TenantConcurrencyConfig,
+    RequestPriority,
+    MaxTokensDefault,
+    PrefillStrategy,
+    SloConfig,
+    SecretRef,
+    ModelInfo,
 } from '@polyglot-llm-gateway/gateway-core';
+import {
+    validateHeaderRules,
+    validateCorrelationHeaders,
+    validateCors,
+    validateProviderVersioning,
+    compileTransforms,
+    isSecretReference,
+    STAGE_CACHE_KEY_FIELDS,
+    type StageCacheKeyField,
+} from '@polyglot-llm-gateway/gateway-core';
+import { loadCABundle } from './http.js';
 
 /**
  * Options for the file config provider.
@@ -65,7 +125,83 @@ export class FileConfigProvider implements WatchableConfigProvider {
         }
 
         // Normalize the config (cast through unknown to handle the raw parsed data)
-        return this.normalizeConfig(config as unknown as Record<string, unknown>);
+        const normalized = this.normalizeConfig(config as unknown as Record<string, unknown>);
+        this.validateCABundles(normalized);
+        this.validateHeaders(normalized);
+        this.validateTransforms(normalized);
+        return normalized;
+    }
+
+    /**
+     * Fails the load if any app or provider header rule touches a
+     * protected header, an app's correlation header list or CORS config is
+     * invalid, or a provider pins an invalid API version or beta feature.
+     */
+    private validateHeaders(config: GatewayConfig): void {
+        for (const provider of config.providers) {
+            try {
+                if (provider.headers) validateHeaderRules(provider.headers);
+                validateProviderVersioning(provider);
+            } catch (error) {
+                throw new Error(`Invalid config for provider '${provider.name}': ${(error as Error).message}`);
+            }
+        }
+
+        for (const app of config.apps) {
+            if (app.headers || app.correlationHeaders || app.cors) {
+                try {
+                    if (app.headers) validateHeaderRules(app.headers);
+                    validateCorrelationHeaders(app.correlationHeaders);
+                    validateCors(app.cors);
+                } catch (error) {
+                    throw new Error(`Invalid config for app '${app.name}': ${(error as Error).message}`);
+                }
+            }
+        }
+    }
+
+    /**
+     * Fails the load if any app's response transforms are invalid (e.g.,
+     * a regex that doesn't compile).
+     */
+    private validateTransforms(config: GatewayConfig): void {
+        for (const app of config.apps) {
+            try {
+                compileTransforms(app.transforms);
+            } catch (error) {
+                throw new Error(`Invalid config for app '${app.name}': ${(error as Error).message}`);
+            }
+        }
+    }
+
+    /**
+     * Fails the load if any provider or webhook CA bundle cannot be read
+     * or parsed, rather than surfacing it on the first upstream request.
+     */
+    private validateCABundles(config: GatewayConfig): void {
+        for (const provider of config.providers) {
+            if (provider.http?.caBundlePath) {
+                try {
+                    loadCABundle(provider.http.caBundlePath);
+                } catch (error) {
+                    throw new Error(`Invalid config for provider '${provider.name}': ${(error as Error).message}`);
+                }
+            }
+        }
+
+        for (const app of config.apps) {
+            for (const stage of app.pipeline?.stages ?? []) {
+                if (stage.caBundlePath) {
+                    try {
+                        loadCABundle(stage.caBundlePath);
+                    } catch (error) {
+                        throw new Error(
+                            `Invalid config for pipeline stage '${app.name}/${stage.name}': ${(error as Error).message}`,
+                        );
+                    }
+                }
+            }
+        }
     }
 
     /**
@@ -103,10 +239,11 @@ export class FileConfigProvider implements WatchableConfigProvider {
     }
 
     /**
-     * Expands environment variable references like ${VAR_NAME}.
+     * Expands environment variable references like ${VAR_NAME}. References
+     * like ${env:VAR_NAME} are left for the gateway to expand per request.
      */
     private expandEnvVars(content: string): string {
-        return content.replace(/\$\{([^}]+)\}/g, (_, varName) => {
+        return content.replace(/\$\{(?!env:)([^}]+)\}/g, (_, varName) => {
             const value = this.env[varName];
             if (value === undefined) {
                 console.warn(`Environment variable ${varName} not set`);
@@ -116,6 +253,1012 @@ export class FileConfigProvider implements WatchableConfigProvider {
         });
     }
 
+    /**
+     * Normalizes a provider's HTTP client settings.
+     */
+    private normalizeProviderHTTP(h: Record<string, unknown>): ProviderHTTPConfig {
+        return {
+            maxIdleConns: (h.max_idle_conns ?? h.maxIdleConns) as number | undefined,
+            maxIdleConnsPerHost: (h.max_idle_conns_per_host ?? h.maxIdleConnsPerHost) as number | undefined,
+            maxConnsPerHost: (h.max_conns_per_host ?? h.maxConnsPerHost) as number | undefined,
+            idleConnTimeout: (h.idle_conn_timeout ?? h.idleConnTimeout) as string | undefined,
+            dialTimeout: (h.dial_timeout ?? h.dialTimeout) as string | undefined,
+            tlsHandshakeTimeout: (h.tls_handshake_timeout ?? h.tlsHandshakeTimeout) as string | undefined,
+            responseHeaderTimeout: (h.response_header_timeout ?? h.responseHeaderTimeout) as string | undefined,
+            proxyUrl: (h.proxy_url ?? h.proxyUrl) as string | undefined,
+            caBundlePath: (h.ca_bundle_path ?? h.caBundlePath) as string | undefined,
+            insecureSkipVerify: (h.insecure_skip_verify ?? h.insecureSkipVerify) as boolean | undefined,
+        };
+    }
+
+    /**
+     * Normalizes a provider's deadline propagation settings.
+     */
+    private normalizeProviderDeadline(d: Record<string, unknown>, provider: string): ProviderDeadlineConfig {
+        const tokensPerSecond = (d.tokens_per_second ?? d.tokensPerSecond) as number | undefined;
+        if (tokensPerSecond !== undefined && !(typeof tokensPerSecond === 'number' && tokensPerSecond > 0)) {
+            throw new Error(`Invalid config for provider '${provider}': deadline.tokens_per_second must be a positive number`);
+        }
+        return {
+            header: d.header as boolean | undefined,
+            tokensPerSecond,
+        };
+    }
+
+    /**
+     * Normalizes a provider's synthetic probe.
+     */
+    private normalizeProviderProbe(raw: unknown, provider: string): ProviderProbeConfig | undefined {
+        if (!raw) return undefined;
+        const p = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for provider '${provider}': probe.${message}`);
+        };
+
+        if (typeof p.model !== 'string' || p.model === '') {
+            fail('model is required');
+        }
+        const window = p.window as number | undefined;
+        if (window !== undefined && !(Number.isInteger(window) && window > 0)) {
+            fail(`window must be a positive integer, got ${String(window)}`);
+        }
+        const minSuccessRate = (p.min_success_rate ?? p.minSuccessRate) as number | undefined;
+        if (minSuccessRate !== undefined && !(typeof minSuccessRate === 'number' && minSuccessRate >= 0 && minSuccessRate <= 1)) {
+            fail(`min_success_rate must be between 0 and 1, got ${String(minSuccessRate)}`);
+        }
+        const a = p.alert as Record<string, unknown> | undefined;
+        if (a && (typeof a.webhook !== 'string' || a.webhook === '')) {
+            fail('alert.webhook is required');
+        }
+
+        return {
+            interval: p.interval as string | undefined,
+            model: p.model as string,
+            prompt: p.prompt as string | undefined,
+            maxTokens: (p.max_tokens ?? p.maxTokens) as number | undefined,
+            timeout: p.timeout as string | undefined,
+            window,
+            minSuccessRate,
+            alert: a && {
+                webhook: a.webhook as string,
+                maxP95: (a.max_p95 ?? a.maxP95) as string | undefined,
+                cooldown: a.cooldown as string | undefined,
+                headers: a.headers as Record<string, string> | undefined,
+            },
+        };
+    }
+
+    /**
+     * Normalizes a provider's key list; entries are bare keys or {key, label}.
+     */
+    private normalizeProviderKeys(raw: unknown): ProviderKeyConfig[] | undefined {
+        if (!Array.isArray(raw)) return undefined;
+        return raw.map((k: unknown) => {
+            if (typeof k === 'string') return { key: k };
+            const entry = k as Record<string, unknown>;
+            return { key: entry.key as string, label: entry.label as string | undefined };
+        });
+    }
+
+    /**
+     * Normalizes a provider's api_key: a string, or a secret reference.
+     */
+    private normalizeProviderKey(raw: unknown, provider: string): { apiKey: string; apiKeySecret?: SecretRef | undefined } {
+        if (raw !== null && typeof raw === 'object') {
+            return { apiKey: '', apiKeySecret: this.normalizeSecretRef(raw, `provider '${provider}'`, 'api_key') };
+        }
+        return { apiKey: (raw ?? '') as string };
+    }
+
+    /**
+     * Validates a secret reference ({secret: "<tenant>/<name>"}).
+     */
+    private normalizeSecretRef(raw: unknown, where: string, field: string): SecretRef | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        const secret = (raw as Record<string, unknown>).secret;
+        if (typeof raw !== 'object' || typeof secret !== 'string' || !isSecretReference(secret)) {
+            throw new Error(`Invalid config for ${where}: ${field} must be {secret: "<tenant>/<name>"}`);
+        }
+        return { secret };
+    }
+
+    /**
+     * Normalizes an app's stream throttle.
+     */
+    private normalizeStreamThrottle(raw: unknown): StreamThrottleConfig | undefined {
+        if (!raw) return undefined;
+        const t = raw as Record<string, unknown>;
+        return {
+            tokensPerSecond: (t.tokens_per_second ?? t.tokensPerSecond) as number,
+            burst: t.burst as number | undefined,
+        };
+    }
+
+    /**
+     * Normalizes app or provider upstream header rules.
+     */
+    private normalizeHeaderRules(raw: unknown): HeaderRulesConfig | undefined {
+        if (!raw) return undefined;
+        const h = raw as Record<string, unknown>;
+        return {
+            passthrough: h.passthrough as string[] | undefined,
+            inject: h.inject as Record<string, string> | undefined,
+            strip: h.strip as string[] | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's gateway tools.
+     */
+    private normalizeGatewayTools(raw: unknown): GatewayToolsConfig | undefined {
+        if (!raw) return undefined;
+        const t = raw as Record<string, unknown>;
+        return {
+            tools: Array.isArray(t.tools) ? t.tools as string[] : [],
+            maxIterations: (t.max_iterations ?? t.maxIterations) as number | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's request mirror.
+     */
+    private normalizeMirror(raw: unknown): MirrorConfig | undefined {
+        if (!raw) return undefined;
+        const m = raw as Record<string, unknown>;
+        return {
+            url: m.url as string,
+            sampleRate: (m.sample_rate ?? m.sampleRate) as number | undefined,
+            apiKey: (m.api_key ?? m.apiKey) as string | undefined,
+            headers: m.headers as Record<string, string> | undefined,
+            maxInFlight: (m.max_in_flight ?? m.maxInFlight) as number | undefined,
+            timeout: m.timeout as string | undefined,
+        };
+    }
+
+    private normalizeCors(raw: unknown): CorsConfig | undefined {
+        if (!raw) return undefined;
+        const c = raw as Record<string, unknown>;
+        return {
+            allowedOrigins: (c.allowed_origins ?? c.allowedOrigins) as string[],
+            allowedHeaders: (c.allowed_headers ?? c.allowedHeaders) as string[] | undefined,
+            exposeHeaders: (c.expose_headers ?? c.exposeHeaders) as string[] | undefined,
+            maxAge: (c.max_age ?? c.maxAge) as number | undefined,
+            allowCredentials: (c.allow_credentials ?? c.allowCredentials) as boolean | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's middleware list. Entries are a bare name or an
+     * object with a name and the middleware's parameters alongside it;
+     * names are checked against the registry when the gateway loads.
+     */
+    private normalizeMiddleware(raw: unknown, appName: string): AppMiddlewareConfig[] | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (!Array.isArray(raw)) {
+            throw new Error(`Invalid config for app '${appName}': middleware must be a list`);
+        }
+        return raw.map((entry: unknown, i) => {
+            if (typeof entry === 'string' && entry) {
+                return { name: entry };
+            }
+            const { name, ...params } = (entry ?? {}) as Record<string, unknown>;
+            if (typeof name !== 'string' || !name) {
+                throw new Error(`Invalid config for app '${appName}': middleware[${i}] needs a name`);
+            }
+            return { name, params };
+        });
+    }
+
+    /**
+     * Normalizes an app's JSON output validation. `true` enables it with
+     * the defaults.
+     */
+    private normalizeJsonOutputValidation(raw: unknown, appName: string): JsonOutputValidationConfig | undefined {
+        if (!raw) return undefined;
+        if (raw === true) return {};
+        const v = raw as Record<string, unknown>;
+        const onInvalid = (v.on_invalid ?? v.onInvalid) as JsonOutputInvalidAction | undefined;
+        if (onInvalid !== undefined && onInvalid !== 'error' && onInvalid !== 'repair') {
+            throw new Error(
+                `Invalid config for app '${appName}': validate_json_output.on_invalid must be 'error' or 'repair', got '${onInvalid}'`,
+            );
+        }
+        return { onInvalid };
+    }
+
+    /**
+     * Normalizes an app's request coalescing. `true` enables it with the
+     * defaults.
+     */
+    private normalizeCoalescing(raw: unknown, appName: string): CoalescingConfig | undefined {
+        if (!raw) return undefined;
+        if (raw === true) return {};
+        const c = raw as Record<string, unknown>;
+        const windowMs = (c.window_ms ?? c.windowMs) as number | undefined;
+        if (windowMs !== undefined && !(typeof windowMs === 'number' && windowMs > 0)) {
+            throw new Error(
+                `Invalid config for app '${appName}': coalesce.window_ms must be a positive number, got ${String(windowMs)}`,
+            );
+        }
+        return {
+            enabled: c.enabled as boolean | undefined,
+            windowMs,
+            includeSampled: (c.include_sampled ?? c.includeSampled) as boolean | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's thread summaries: the summarizer's provider and
+     * model and the token threshold are required.
+     */
+    private normalizeThreadSummary(raw: unknown, appName: string): ThreadSummaryConfig | undefined {
+        if (!raw) return undefined;
+        const t = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for app '${appName}': thread_summary.${message}`);
+        };
+        for (const key of ['provider', 'model'] as const) {
+            if (typeof t[key] !== 'string' || !t[key]) {
+                fail(`${key} is required`);
+            }
+        }
+        const thresholdTokens = (t.threshold_tokens ?? t.thresholdTokens) as number | undefined;
+        if (!(typeof thresholdTokens === 'number' && Number.isInteger(thresholdTokens) && thresholdTokens > 0)) {
+            fail(`threshold_tokens must be a positive integer, got ${String(thresholdTokens)}`);
+        }
+        const keepRecent = (t.keep_recent ?? t.keepRecent) as number | undefined;
+        if (keepRecent !== undefined && !(typeof keepRecent === 'number' && Number.isInteger(keepRecent) && keepRecent >= 0)) {
+            fail(`keep_recent must be a non-negative integer, got ${String(keepRecent)}`);
+        }
+        return {
+            enabled: t.enabled as boolean | undefined,
+            provider: t.provider as string,
+            model: t.model as string,
+            thresholdTokens: thresholdTokens!,
+            keepRecent,
+            countUsage: (t.count_usage ?? t.countUsage) as boolean | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's thread message dedup. A boolean only turns it on
+     * or off, with the default window.
+     */
+    private normalizeMessageDedup(raw: unknown, appName: string): MessageDedupConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (typeof raw === 'boolean') return { enabled: raw };
+        const d = raw as Record<string, unknown>;
+        const windowMessages = (d.window_messages ?? d.windowMessages) as number | undefined;
+        const windowSeconds = (d.window_seconds ?? d.windowSeconds) as number | undefined;
+        for (const [key, value] of [['window_messages', windowMessages], ['window_seconds', windowSeconds]] as const) {
+            if (value !== undefined && !(typeof value === 'number' && Number.isInteger(value) && value > 0)) {
+                throw new Error(
+                    `Invalid config for app '${appName}': message_dedup.${key} must be a positive integer, got ${String(value)}`,
+                );
+            }
+        }
+        return {
+            enabled: d.enabled as boolean | undefined,
+            windowMessages,
+            windowSeconds,
+        };
+    }
+
+    /**
+     * Normalizes an app's endpoint passthrough. A boolean only turns it on
+     * or off; only anthropic apps can forward endpoints.
+     */
+    private normalizePassthrough(raw: unknown, appName: string, frontdoor: string): EndpointPassthroughConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for app '${appName}': passthrough${message}`);
+        };
+        if (frontdoor !== 'anthropic') {
+            fail(` requires the anthropic frontdoor, got '${frontdoor}'`);
+        }
+        if (typeof raw === 'boolean') return { enabled: raw };
+        const p = raw as Record<string, unknown>;
+        for (const key of ['allow', 'deny'] as const) {
+            const patterns = p[key];
+            if (patterns !== undefined && (!Array.isArray(patterns) || patterns.some((path) => typeof path !== 'string' || !path.startsWith('/')))) {
+                fail(`.${key} must be a list of paths`);
+            }
+        }
+        return {
+            enabled: p.enabled as boolean | undefined,
+            allow: p.allow as string[] | undefined,
+            deny: p.deny as string[] | undefined,
+        };
+    }
+
+    /**
+     * Normalizes an app's response classification. A boolean only turns it
+     * on or off; safety classification needs a provider.
+     */
+    private normalizeClassification(raw: unknown, appName: string): ResponseClassificationConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (typeof raw === 'boolean') return { enabled: raw };
+        const c = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for app '${appName}': classification.${message}`);
+        };
+        let safety: ResponseClassificationConfig['safety'];
+        if (c.safety !== undefined && c.safety !== null) {
+            const s = c.safety as Record<string, unknown>;
+            if (typeof s.provider !== 'string' || !s.provider) {
+                fail('safety.provider is required');
+            }
+            if (s.model !== undefined && typeof s.model !== 'string') {
+                fail('safety.model must be a string');
+            }
+            safety = { provider: s.provider as string, model: s.model as string | undefined };
+        }
+        return {
+            enabled: c.enabled as boolean | undefined,
+            language: c.language as boolean | undefined,
+            safety,
+        };
+    }
+
+    /**
+     * Normalizes a call budget, the gateway's default or an app's. `where`
+     * prefixes validation errors.
+     */
+    private normalizeCallBudget(raw: unknown, where: string): CallBudgetConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        const b = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for ${where}call_budget.${message}`);
+        };
+        const maxCalls = (b.max_calls ?? b.maxCalls) as number | undefined;
+        if (maxCalls !== undefined && !(Number.isInteger(maxCalls) && maxCalls > 0)) {
+            fail(`max_calls must be a positive integer, got ${String(maxCalls)}`);
+        }
+        const maxTokens = (b.max_tokens ?? b.maxTokens) as number | undefined;
+        if (maxTokens !== undefined && !(Number.isInteger(maxTokens) && maxTokens > 0)) {
+            fail(`max_tokens must be a positive integer, got ${String(maxTokens)}`);
+        }
+        const maxTime = (b.max_time ?? b.maxTime) as string | undefined;
+        if (maxTime !== undefined && typeof maxTime !== 'string') {
+            fail(`max_time must be a duration such as "60s", got ${String(maxTime)}`);
+        }
+        return { maxCalls, maxTokens, maxTime };
+    }
+
+    /**
+     * Normalizes the usage check: `enabled`, the relative `threshold`
+     * (above 0) over which counts disagree, and `min_tokens`.
+     */
+    private normalizeUsageCheck(raw: unknown): UsageCheckConfig {
+        const c = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for usage_check.${message}`);
+        };
+        if (c.enabled !== undefined && typeof c.enabled !== 'boolean') {
+            fail(`enabled must be a boolean, got ${String(c.enabled)}`);
+        }
+        const threshold = c.threshold as number | undefined;
+        if (threshold !== undefined && !(typeof threshold === 'number' && threshold > 0)) {
+            fail(`threshold must be a number above 0, got ${String(threshold)}`);
+        }
+        const minTokens = (c.min_tokens ?? c.minTokens) as number | undefined;
+        if (minTokens !== undefined && !(Number.isInteger(minTokens) && minTokens >= 0)) {
+            fail(`min_tokens must be a non-negative integer, got ${String(minTokens)}`);
+        }
+        return { enabled: c.enabled as boolean | undefined, threshold, minTokens };
+    }
+
+    /**
+     * Normalizes a parameter policy: `strict`, a rule (`min`, `max`,
+     * `forbidden`, `force`) per parameter under its wire name, and
+     * `exclusive` groups. Values are checked when the gateway loads.
+     */
+    private normalizeParameterPolicy(raw: unknown, name: string): ParameterPolicyConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (typeof raw !== 'object' || Array.isArray(raw)) {
+            throw new Error(`Invalid config for ${name} must be a map of parameter rules`);
+        }
+        const p = raw as Record<string, unknown>;
+        const rule = (snake: PolicyParameter, camel: string): ParameterRule | undefined => {
+            const r = p[snake] ?? p[camel];
+            if (r === undefined || r === null) return undefined;
+            if (typeof r !== 'object' || Array.isArray(r)) {
+                throw new Error(`Invalid config for ${name}.${snake} must be a map of min, max, forbidden and force`);
+            }
+            const { min, max, forbidden, force } = r as Record<string, unknown>;
+            return {
+                min: min as number | undefined,
+                max: max as number | undefined,
+                forbidden: forbidden as boolean | undefined,
+                force: force as number | undefined,
+            };
+        };
+        if (p.exclusive !== undefined && !Array.isArray(p.exclusive)) {
+            throw new Error(`Invalid config for ${name}.exclusive must be a list of parameter groups, such as [[temperature, top_p]]`);
+        }
+        return {
+            strict: p.strict as boolean | undefined,
+            temperature: rule('temperature', 'temperature'),
+            topP: rule('top_p', 'topP'),
+            frequencyPenalty: rule('frequency_penalty', 'frequencyPenalty'),
+            presencePenalty: rule('presence_penalty', 'presencePenalty'),
+            maxTokens: rule('max_tokens', 'maxTokens'),
+            exclusive: p.exclusive as PolicyParameter[][] | undefined,
+        };
+    }
+
+    /**
+     * Normalizes the per-model parameter policies: each a parameter policy
+     * with the `models` (names, or prefixes ending in `*`) it applies to.
+     */
+    private normalizeParameterPolicies(raw: unknown): ModelParameterPolicyConfig[] | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (!Array.isArray(raw)) {
+            throw new Error('Invalid config for parameter_policies must be a list of policies');
+        }
+        return raw.map((entry: unknown, i) => {
+            const name = `parameter_policies[${i}]`;
+            const models = (entry as Record<string, unknown> | null)?.models;
+            if (!Array.isArray(models) || !models.every((m) => typeof m === 'string' && m)) {
+                throw new Error(`Invalid config for ${name}.models must be a list of model names, or prefixes ending in *`);
+            }
+            return { ...this.normalizeParameterPolicy(entry, name), models };
+        });
+    }
+
+    /**
+     * Normalizes an app's interaction recording. `always_full_on` is a list
+     * of triggers (`error`, `{slow_ms: 5000}`, `{header: x-debug-record}`)
+     * or a map of them.
+     */
+    private normalizeRecording(raw: unknown, appName: string): RecordingConfig | undefined {
+        if (!raw) return undefined;
+        const r = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for app '${appName}': recording.${message}`);
+        };
+
+        const mode = r.mode as RecordingMode | undefined;
+        if (mode !== undefined && mode !== 'full' && mode !== 'sampled' && mode !== 'errors_only') {
+            fail(`mode must be 'full', 'sampled' or 'errors_only', got '${mode}'`);
+        }
+        const sampleRate = (r.sample_rate ?? r.sampleRate) as number | undefined;
+        if (sampleRate !== undefined && !(typeof sampleRate === 'number' && sampleRate >= 0 && sampleRate <= 1)) {
+            fail(`sample_rate must be between 0 and 1, got ${String(sampleRate)}`);
+        }
+
+        const rawTriggers = r.always_full_on ?? r.alwaysFullOn;
+        let alwaysFullOn: RecordingTriggers | undefined;
+        if (rawTriggers) {
+            const entries = Array.isArray(rawTriggers) ? rawTriggers : [rawTriggers];
+            alwaysFullOn = {};
+            for (const entry of entries) {
+                if (entry === 'error') {
+                    alwaysFullOn.error = true;
+                    continue;
+                }
+                if (typeof entry !== 'object' || entry === null) {
+                    fail(`always_full_on entries must be 'error', slow_ms or header, got '${String(entry)}'`);
+                }
+                const t = entry as Record<string, unknown>;
+                for (const [key, value] of Object.entries(t)) {
+                    if (key === 'error') {
+                        alwaysFullOn.error = value === true;
+                    } else if (key === 'slow_ms' || key === 'slowMs') {
+                        if (typeof value !== 'number' || value <= 0) {
+                            fail(`always_full_on.slow_ms must be a positive number, got ${String(value)}`);
+                        }
+                        alwaysFullOn.slowMs = value as number;
+                    } else if (key === 'header') {
+                        if (typeof value !== 'string' || value === '') {
+                            fail('always_full_on.header must be a header name');
+                        }
+                        alwaysFullOn.header = value as string;
+                    } else {
+                        fail(`always_full_on has unknown trigger '${key}'`);
+                    }
+                }
+            }
+        }
+
+        const rawResponseMaxBytes = (r.raw_response_max_bytes ?? r.rawResponseMaxBytes) as number | undefined;
+        if (rawResponseMaxBytes !== undefined && !(Number.isInteger(rawResponseMaxBytes) && rawResponseMaxBytes >= 0)) {
+            fail(`raw_response_max_bytes must be a non-negative integer, got ${String(rawResponseMaxBytes)}`);
+        }
+
+        return { mode, sampleRate, alwaysFullOn, rawResponseMaxBytes };
+    }
+
+    /**
+     * Validates an app's outbound SSE event size limit.
+     */
+    private normalizeMaxSseEventBytes(raw: unknown, appName: string): number | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (!(typeof raw === 'number' && Number.isInteger(raw) && raw >= 1024)) {
+            throw new Error(
+                `Invalid config for app '${appName}': max_sse_event_bytes must be an integer of at least 1024, got ${String(raw)}`,
+            );
+        }
+        return raw;
+    }
+
+    /**
+     * Validates an app's stream event granularity.
+     */
+    private normalizeEventGranularity(raw: unknown, appName: string): EventGranularity | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (raw !== 'chunk' && raw !== 'compacted') {
+            throw new Error(
+                `Invalid config for app '${appName}': event_granularity must be 'chunk' or 'compacted', got '${String(raw)}'`,
+            );
+        }
+        return raw;
+    }
+
+    /**
+     * Normalizes what an app forwards upstream as the end-user ID.
+     */
+    private normalizeEndUserForwarding(raw: unknown, appName: string): EndUserForwarding | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (raw !== 'raw' && raw !== 'hash') {
+            throw new Error(
+                `Invalid config for app '${appName}': forward_end_user must be 'raw' or 'hash', got '${String(raw)}'`,
+            );
+        }
+        return raw;
+    }
+
+    /**
+     * Normalizes an app's max_tokens default: model_max, fixed:N, or reject.
+     */
+    private normalizeMaxTokensDefault(raw: unknown, appName: string): MaxTokensDefault | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (raw === 'model_max' || raw === 'reject') {
+            return { strategy: raw };
+        }
+        const fixed = typeof raw === 'string' ? /^fixed:(\d+)$/.exec(raw) : null;
+        if (fixed && Number(fixed[1]) > 0) {
+            return { strategy: 'fixed', tokens: Number(fixed[1]) };
+        }
+        throw new Error(
+            `Invalid config for app '${appName}': max_tokens_default must be 'model_max', 'fixed:N' or 'reject', got '${String(raw)}'`,
+        );
+    }
+
+    /**
+     * Normalizes an app's prefill strategy.
+     */
+    private normalizePrefill(raw: unknown, appName: string): PrefillStrategy | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (raw !== 'instruction' && raw !== 'passthrough' && raw !== 'reject') {
+            throw new Error(
+                `Invalid config for app '${appName}': prefill must be 'instruction', 'passthrough' or 'reject', got '${String(raw)}'`,
+            );
+        }
+        return raw;
+    }
+
+    /**
+     * Normalizes an app's SLO.
+     */
+    private normalizeSlo(raw: unknown, appName: string): SloConfig | undefined {
+        if (!raw) return undefined;
+        const s = raw as Record<string, unknown>;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for app '${appName}': slo.${message}`);
+        };
+
+        const latencyP95Ms = (s.latency_p95_ms ?? s.latencyP95Ms) as number | undefined;
+        if (latencyP95Ms !== undefined && !(typeof latencyP95Ms === 'number' && latencyP95Ms > 0)) {
+            fail(`latency_p95_ms must be a positive number, got ${String(latencyP95Ms)}`);
+        }
+        const availability = s.availability as number | undefined;
+        if (availability !== undefined && !(typeof availability === 'number' && availability > 0 && availability < 100)) {
+            fail(`availability must be a percentage between 0 and 100, got ${String(availability)}`);
+        }
+        if (latencyP95Ms === undefined && availability === undefined) {
+            fail('latency_p95_ms or availability is required');
+        }
+        const a = s.alert as Record<string, unknown> | undefined;
+        if (a && (typeof a.webhook !== 'string' || a.webhook === '')) {
+            fail('alert.webhook is required');
+        }
+        const fastBurnRate = a && (a.fast_burn_rate ?? a.fastBurnRate) as number | undefined;
+        if (fastBurnRate !== undefined && !(typeof fastBurnRate === 'number' && fastBurnRate > 0)) {
+            fail(`alert.fast_burn_rate must be a positive number, got ${String(fastBurnRate)}`);
+        }
+
+        return {
+            latencyP95Ms,
+            availability,
+            window: s.window as string | undefined,
+            alert: a && {
+                webhook: a.webhook as string,
+                fastBurnRate,
+                shortWindow: (a.short_window ?? a.shortWindow) as string | undefined,
+                longWindow: (a.long_window ?? a.longWindow) as string | undefined,
+                cooldown: a.cooldown as string | undefined,
+                headers: a.headers as Record<string, string> | undefined,
+            },
+        };
+    }
+
+    /**
+     * Normalizes an app's priority class.
+     */
+    private normalizePriority(raw: unknown, appName: string): RequestPriority | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (raw !== 'high' && raw !== 'normal' && raw !== 'low') {
+            throw new Error(
+                `Invalid config for app '${appName}': priority must be 'high', 'normal' or 'low', got '${String(raw)}'`,
+            );
+        }
+        return raw;
+    }
+
+    /**
+     * Normalizes an app's error passthrough. A boolean only turns it on or
+     * off.
+     */
+    private normalizeErrorPassthrough(raw: unknown, appName: string): ErrorPassthroughConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (typeof raw === 'boolean') return { enabled: raw };
+        const e = raw as Record<string, unknown>;
+        const redact = e.redact;
+        if (redact !== undefined && (!Array.isArray(redact) || redact.some((name) => typeof name !== 'string' || name === ''))) {
+            throw new Error(`Invalid config for app '${appName}': error_passthrough.redact must be a list of header or field names`);
+        }
+        return { enabled: e.enabled as boolean | undefined, redact: redact as string[] | undefined };
+    }
+
+    /**
+     * Normalizes an app's response transforms.
+     */
+    private normalizeTransforms(raw: unknown): ResponseTransformConfig[] | undefined {
+        if (!Array.isArray(raw)) return undefined;
+        return raw.map((t: Record<string, unknown>) => ({
+            type: t.type as ResponseTransformConfig['type'],
+            pattern: t.pattern as string | undefined,
+            replacement: t.replacement as string | undefined,
+            flags: t.flags as string | undefined,
+            maxChars: (t.max_chars ?? t.maxChars) as number | undefined,
+            path: t.path as string | undefined,
+        }));
+    }
+
+    /**
+     * Normalizes a tenant's monthly budget.
+     */
+    private normalizeBudget(raw: unknown): BudgetConfig | undefined {
+        if (!raw) return undefined;
+        const b = raw as Record<string, unknown>;
+        return {
+            monthlyTokens: (b.monthly_tokens ?? b.monthlyTokens) as number | undefined,
+            monthlyCostUsd: (b.monthly_cost_usd ?? b.monthlyCostUsd) as number | undefined,
+            action: b.action as 'block' | 'warn' | undefined,
+        };
+    }
+
+    /**
+     * Normalizes provider call concurrency caps. Caps and the queue size
+     * must be positive integers, and the low priority share a fraction.
+     */
+    private normalizeConcurrency(raw: unknown): ConcurrencyConfig | undefined {
+        if (!raw) return undefined;
+        const c = raw as Record<string, unknown>;
+        const config: ConcurrencyConfig = {
+            maxInFlight: (c.max_in_flight ?? c.maxInFlight) as number | undefined,
+            tenantMaxInFlight: (c.tenant_max_in_flight ?? c.tenantMaxInFlight) as number | undefined,
+            tenantProviderMaxInFlight: (c.tenant_provider_max_in_flight ?? c.tenantProviderMaxInFlight) as number | undefined,
+            queueSize: (c.queue_size ?? c.queueSize) as number | undefined,
+            queueTimeout: (c.queue_timeout ?? c.queueTimeout) as string | undefined,
+            priorityQueueTimeouts: (c.priority_queue_timeouts ?? c.priorityQueueTimeouts) as
+                Partial<Record<RequestPriority, string>> | undefined,
+            lowPriorityShare: (c.low_priority_share ?? c.lowPriorityShare) as number | undefined,
+        };
+        const counts: [string, number | undefined][] = [
+            ['max_in_flight', config.maxInFlight],
+            ['tenant_max_in_flight', config.tenantMaxInFlight],
+            ['tenant_provider_max_in_flight', config.tenantProviderMaxInFlight],
+            ['queue_size', config.queueSize],
+        ];
+        for (const [field, value] of counts) {
+            if (value !== undefined && !(Number.isInteger(value) && value > 0)) {
+                throw new Error(`Invalid config for concurrency: ${field} must be a positive integer`);
+            }
+        }
+        for (const priority of Object.keys(config.priorityQueueTimeouts ?? {})) {
+            if (priority !== 'high' && priority !== 'normal' && priority !== 'low') {
+                throw new Error(`Invalid config for concurrency: unknown priority '${priority}' in priority_queue_timeouts`);
+            }
+        }
+        const share = config.lowPriorityShare;
+        if (share !== undefined && !(typeof share === 'number' && share >= 0 && share <= 1)) {
+            throw new Error('Invalid config for concurrency: low_priority_share must be between 0 and 1');
+        }
+        return config;
+    }
+
+    /**
+     * Normalizes a tenant's concurrency cap and fair-share weight.
+     */
+    private normalizeTenantConcurrency(raw: unknown, tenant: string): TenantConcurrencyConfig | undefined {
+        if (!raw) return undefined;
+        const c = raw as Record<string, unknown>;
+        const maxInFlight = (c.max_in_flight ?? c.maxInFlight) as number | undefined;
+        const weight = c.weight as number | undefined;
+        if (maxInFlight !== undefined && !(Number.isInteger(maxInFlight) && maxInFlight > 0)) {
+            throw new Error(`Invalid config for tenant '${tenant}': concurrency.max_in_flight must be a positive integer`);
+        }
+        if (weight !== undefined && !(typeof weight === 'number' && weight > 0)) {
+            throw new Error(`Invalid config for tenant '${tenant}': concurrency.weight must be a positive number`);
+        }
+        return { maxInFlight, weight };
+    }
+
+    /**
+     * Normalizes thread affinity routing.
+     */
+    private normalizeAffinity(a: Record<string, unknown>): AffinityConfig {
+        return {
+            enabled: a.enabled as boolean | undefined,
+            ttl: a.ttl as string | undefined,
+            maxEntries: (a.max_entries ?? a.maxEntries) as number | undefined,
+        };
+    }
+
+    /**
+     * Normalizes the analytics event sink.
+     */
+    private normalizeEvents(raw: Record<string, unknown>): EventsConfig {
+        const webhook = raw.webhook as Record<string, unknown> | undefined;
+        const kafka = raw.kafka as Record<string, unknown> | undefined;
+        return {
+            sink: raw.sink as 'webhook' | 'kafka',
+            payload: raw.payload as 'summary' | 'full' | undefined,
+            redactContent: (raw.redact_content ?? raw.redactContent) as boolean | undefined,
+            queueSize: (raw.queue_size ?? raw.queueSize) as number | undefined,
+            batchSize: (raw.batch_size ?? raw.batchSize) as number | undefined,
+            flushInterval: (raw.flush_interval ?? raw.flushInterval) as string | undefined,
+            retries: raw.retries as number | undefined,
+            webhook: webhook
+                ? {
+                    url: webhook.url as string,
+                    headers: webhook.headers as Record<string, string> | undefined,
+                    timeout: webhook.timeout as string | undefined,
+                }
+                : undefined,
+            kafka: kafka
+                ? {
+                    brokers: Array.isArray(kafka.brokers) ? kafka.brokers as string[] : [],
+                    topic: kafka.topic as string,
+                    clientId: (kafka.client_id ?? kafka.clientId) as string | undefined,
+                    tls: kafka.tls as boolean | undefined,
+                    sasl: kafka.sasl as NonNullable<EventsConfig['kafka']>['sasl'],
+                }
+                : undefined,
+        };
+    }
+
+    /**
+     * Normalizes the write spill.
+     */
+    private normalizeSpill(raw: unknown): SpillConfig | undefined {
+        if (!raw) return undefined;
+        const spill = raw as Record<string, unknown>;
+        if (typeof spill.dir !== 'string' || spill.dir === '') {
+            throw new Error('Invalid config for storage: spill.dir is required');
+        }
+        const maxBytes = (spill.max_bytes ?? spill.maxBytes) as number | undefined;
+        const segmentBytes = (spill.segment_bytes ?? spill.segmentBytes) as number | undefined;
+        for (const [name, value] of [['max_bytes', maxBytes], ['segment_bytes', segmentBytes]] as const) {
+            if (value !== undefined && (typeof value !== 'number' || value <= 0)) {
+                throw new Error(`Invalid config for storage: spill.${name} must be a positive number`);
+            }
+        }
+        return {
+            dir: spill.dir,
+            maxBytes,
+            segmentBytes,
+            replayInterval: (spill.replay_interval ?? spill.replayInterval) as string | undefined,
+        };
+    }
+
+    /**
+     * Normalizes storage health supervision.
+     */
+    private normalizeStorageHealth(raw: unknown): StorageHealthConfig | undefined {
+        if (!raw) return undefined;
+        const health = raw as Record<string, unknown>;
+        const failureThreshold = (health.failure_threshold ?? health.failureThreshold) as number | undefined;
+        if (failureThreshold !== undefined && (!Number.isInteger(failureThreshold) || failureThreshold <= 0)) {
+            throw new Error('Invalid config for storage: health.failure_threshold must be a positive integer');
+        }
+        return {
+            failureThreshold,
+            probeInterval: (health.probe_interval ?? health.probeInterval) as string | undefined,
+        };
+    }
+
+    /**
+     * Normalizes a dual-write storage migration (type "dual"): its primary
+     * and secondary stores, read side, fail mode, and queue size.
+     */
+    private normalizeDualStorage(storage: Record<string, unknown>): Partial<StorageConfig> {
+        if (storage.type !== 'dual') return {};
+        const backend = (name: 'primary' | 'secondary'): StorageConfig => {
+            const b = storage[name] as Record<string, unknown> | undefined;
+            if (!b || typeof b.type !== 'string') {
+                throw new Error(`Invalid config for storage: dual storage needs ${name}.type`);
+            }
+            if (b.type === 'dual') {
+                throw new Error(`Invalid config for storage: ${name} cannot itself be dual`);
+            }
+            return b as unknown as StorageConfig;
+        };
+        const readFrom = (storage.read_from ?? storage.readFrom) as StorageConfig['readFrom'];
+        if (readFrom !== undefined && readFrom !== 'primary' && readFrom !== 'secondary') {
+            throw new Error(`Invalid config for storage: read_from must be primary or secondary, got '${String(readFrom)}'`);
+        }
+        const failMode = (storage.fail_mode ?? storage.failMode) as StorageConfig['failMode'];
+        if (failMode !== undefined && failMode !== 'primary_only' && failMode !== 'require_both') {
+            throw new Error(`Invalid config for storage: fail_mode must be primary_only or require_both, got '${String(failMode)}'`);
+        }
+        const maxQueue = (storage.max_queue ?? storage.maxQueue) as number | undefined;
+        if (maxQueue !== undefined && (!Number.isInteger(maxQueue) || maxQueue <= 0)) {
+            throw new Error('Invalid config for storage: max_queue must be a positive integer');
+        }
+        return { primary: backend('primary'), secondary: backend('secondary'), readFrom, failMode, maxQueue };
+    }
+
+    /**
+     * Normalizes storage encryption, reading keys given as key_file.
+     */
+    private normalizeEncryption(raw: unknown): StorageEncryptionConfig | undefined {
+        if (!raw) return undefined;
+        const encryption = raw as Record<string, unknown>;
+        const keys = Array.isArray(encryption.keys) ? encryption.keys as Record<string, unknown>[] : [];
+        if (keys.length === 0) {
+            throw new Error('Invalid config for storage: encryption.keys needs at least one key');
+        }
+        return {
+            keys: keys.map((k) => {
+                if (typeof k.id !== 'string' || k.id === '') {
+                    throw new Error('Invalid config for storage: encryption key id is required');
+                }
+                const keyFile = (k.key_file ?? k.keyFile) as string | undefined;
+                if (keyFile !== undefined && k.key !== undefined) {
+                    throw new Error(`Invalid config for storage: encryption key '${k.id}' sets both key and key_file`);
+                }
+                const key = keyFile !== undefined ? readFileSync(keyFile, 'utf-8').trim() : k.key;
+                if (typeof key !== 'string' || key === '') {
+                    throw new Error(`Invalid config for storage: encryption key '${k.id}' needs key or key_file`);
+                }
+                return { id: k.id, key };
+            }),
+        };
+    }
+
+    /**
+     * Normalizes an app's webhook pipeline.
+     */
+    private normalizePipeline(p: Record<string, unknown>, app: string): PipelineConfig {
+        const stages = Array.isArray(p.stages) ? p.stages as Record<string, unknown>[] : [];
+        return {
+            stages: stages.map((s) => ({
+                name: s.name as string,
+                type: s.type as 'pre' | 'post',
+                url: s.url as string,
+                timeout: s.timeout as string | undefined,
+                onError: (s.on_error ?? s.onError) as 'allow' | 'deny' | undefined,
+                retries: s.retries as number | undefined,
+                squelch: s.squelch as boolean | undefined,
+                headers: s.headers as Record<string, string> | undefined,
+                order: s.order as number | undefined,
+                proxyUrl: (s.proxy_url ?? s.proxyUrl) as string | undefined,
+                caBundlePath: (s.ca_bundle_path ?? s.caBundlePath) as string | undefined,
+                insecureSkipVerify: (s.insecure_skip_verify ?? s.insecureSkipVerify) as boolean | undefined,
+                cache: this.normalizeStageCache(s.cache, `${app}/${s.name as string}`),
+                signingSecret: this.normalizeSecretRef(
+                    s.signing_secret ?? s.signingSecret,
+                    `pipeline stage '${app}/${s.name as string}'`,
+                    'signing_secret',
+                ),
+                onDeny: this.normalizeDenyResponse(s.on_deny ?? s.onDeny, `pipeline stage '${app}/${s.name as string}'`),
+                when: this.normalizeStageCondition(s.when, `${app}/${s.name as string}`),
+            })),
+        };
+    }
+
+    /**
+     * Validates what a client gets in place of a denied response.
+     */
+    private normalizeDenyResponse(raw: unknown, where: string): DenyResponseConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        const fail = (message: string): never => {
+            throw new Error(`Invalid config for ${where}: on_deny.${message}`);
+        };
+        const d = raw as Record<string, unknown>;
+        if (d.mode !== 'static' && d.mode !== 'template' && d.mode !== 'error') {
+            fail(`mode must be 'static', 'template' or 'error', got '${String(d.mode)}'`);
+        }
+        if (d.content !== undefined && typeof d.content !== 'string') {
+            fail('content must be a string');
+        }
+        return {
+            mode: d.mode as DenyResponseConfig['mode'],
+            content: d.content as string | undefined,
+            includeReason: (d.include_reason ?? d.includeReason) as boolean | undefined,
+        };
+    }
+
+    /**
+     * Normalizes a pipeline stage's result cache, rejecting unknown key fields.
+     */
+    private normalizeStageCache(raw: unknown, stage: string): PipelineStageCacheConfig | undefined {
+        if (!raw) return undefined;
+        const c = raw as Record<string, unknown>;
+        const key = c.key as string[] | undefined;
+        for (const field of key ?? []) {
+            if (!STAGE_CACHE_KEY_FIELDS.includes(field as StageCacheKeyField)) {
+                throw new Error(`Invalid config for pipeline stage '${stage}': unknown cache key field '${field}'`);
+            }
+        }
+        return {
+            ttl: c.ttl as string | undefined,
+            key,
+            cacheDenies: (c.cache_denies ?? c.cacheDenies) as boolean | undefined,
+            maxEntries: (c.max_entries ?? c.maxEntries) as number | undefined,
+        };
+    }
+
+    /**
+     * Normalizes a pipeline stage's `when` condition: snake_case keys, and a
+     * single tenant, app, or finish reason as a list. Unknown keys are
+     * rejected; the gateway validates the rest on load.
+     */
+    private normalizeStageCondition(raw: unknown, stage: string, path = 'when'): StageConditionConfig | undefined {
+        if (raw === undefined || raw === null) return undefined;
+        if (typeof raw !== 'object' || Array.isArray(raw)) {
+            throw new Error(`Invalid config for pipeline stage '${stage}': ${path} must be a condition object`);
+        }
+        const w = raw as Record<string, unknown>;
+        const known = [
+            'all', 'any', 'model', 'model_prefix', 'model_pattern', 'tenant', 'app', 'stream', 'has_tools',
+            'min_messages', 'max_messages', 'min_tokens', 'max_tokens', 'metadata', 'sample_rate',
+            'finish_reason', 'min_content_length', 'max_content_length',
+        ];
+        for (const key of Object.keys(w)) {
+            if (!known.includes(key)) {
+                throw new Error(`Invalid config for pipeline stage '${stage}': unknown condition '${path}.${key}'`);
+            }
+        }
+        const names = (value: unknown) => (typeof value === 'string' ? [value] : value) as string[] | undefined;
+        const conditions = (key: 'all' | 'any') => Array.isArray(w[key])
+            ? (w[key] as unknown[]).map((c, i) => this.normalizeStageCondition(c, stage, `${path}.${key}[${i}]`)!)
+            : w[key] as StageConditionConfig[] | undefined;
+        return {
+            all: conditions('all'),
+            any: conditions('any'),
+            model: w.model as string | undefined,
+            modelPrefix: w.model_prefix as string | undefined,
+            modelPattern: w.model_pattern as string | undefined,
+            tenant: names(w.tenant),
+            app: names(w.app),
+            stream: w.stream as boolean | undefined,
+            hasTools: w.has_tools as boolean | undefined,
+            minMessages: w.min_messages as number | undefined,
+            maxMessages: w.max_messages as number | undefined,
+            minTokens: w.min_tokens as number | undefined,
+            maxTokens: w.max_tokens as number | undefined,
+            metadata: w.metadata as Record<string, string> | undefined,
+            sampleRate: w.sample_rate as number | undefined,
+            finishReason: names(w.finish_reason),
+            minContentLength: w.min_content_length as number | undefined,
+            maxContentLength: w.max_content_length as number | undefined,
+        };
+    }
+
     /**
      * Normalizes the config to ensure required fields.
      */
@@ -127,12 +1270,33 @@ export class FileConfigProvider implements WatchableConfigProvider {
 
         // Server config
         if (raw.server) {
-            config.server = raw.server as GatewayConfig['server'];
+            const server = raw.server as Record<string, unknown>;
+            config.server = {
+                port: server.port as number | undefined,
+                maxRequestBytes: (server.max_request_bytes ?? server.maxRequestBytes) as number | undefined,
+            };
+        }
+
+        // Admin listener (split-horizon control plane)
+        if (raw.admin) {
+            const admin = raw.admin as Record<string, unknown>;
+            config.admin = {
+                port: admin.port as number,
+                bind: admin.bind as string | undefined,
+            };
         }
 
         // Storage config
         if (raw.storage) {
-            config.storage = raw.storage as GatewayConfig['storage'];
+            const storage = raw.storage as Record<string, unknown>;
+            config.storage = {
+                ...(storage as unknown as NonNullable<GatewayConfig['storage']>),
+                autoMigrate: (storage.auto_migrate ?? storage.autoMigrate) as boolean | undefined,
+                spill: this.normalizeSpill(storage.spill),
+                encryption: this.normalizeEncryption(storage.encryption),
+                health: this.normalizeStorageHealth(storage.health),
+                ...this.normalizeDualStorage(storage),
+            };
         }
 
         // Providers (with snake_case to camelCase conversion)
@@ -140,13 +1304,28 @@ export class FileConfigProvider implements WatchableConfigProvider {
             config.providers = raw.providers.map((p: Record<string, unknown>) => ({
                 name: p.name as string,
                 type: p.type as string,
-                apiKey: (p.api_key ?? p.apiKey) as string,
+                ...this.normalizeProviderKey(p.api_key ?? p.apiKey, p.name as string),
+                apiKeys: this.normalizeProviderKeys(p.api_keys ?? p.apiKeys),
+                keyCooldown: (p.key_cooldown ?? p.keyCooldown) as string | undefined,
                 baseUrl: (p.base_url ?? p.baseUrl) as string | undefined,
                 supportsResponses: (p.supports_responses ?? p.supportsResponses) as boolean | undefined,
                 enablePassthrough: (p.enable_passthrough ?? p.enablePassthrough) as boolean | undefined,
                 useResponsesApi: (p.use_responses_api ?? p.useResponsesApi) as boolean | undefined,
                 responsesThreadKeyPath: (p.responses_thread_key_path ?? p.responsesThreadKeyPath) as string | undefined,
                 responsesThreadPersistence: (p.responses_thread_persistence ?? p.responsesThreadPersistence) as boolean | undefined,
+                fanOutChoices: (p.fan_out_choices ?? p.fanOutChoices) as boolean | undefined,
+                http: p.http ? this.normalizeProviderHTTP(p.http as Record<string, unknown>) : undefined,
+                requestTimeout: (p.request_timeout ?? p.requestTimeout) as string | undefined,
+                streamIdleTimeout: (p.stream_idle_timeout ?? p.streamIdleTimeout) as string | undefined,
+                modelListTtl: (p.model_list_ttl ?? p.modelListTtl) as string | undefined,
+                deadline: p.deadline ? this.normalizeProviderDeadline(p.deadline as Record<string, unknown>, p.name as string) : undefined,
+                headers: this.normalizeHeaderRules(p.headers),
+                apiVersion: (p.api_version ?? p.apiVersion) as string | undefined,
+                betaFeatures: (p.beta_features ?? p.betaFeatures) as string[] | undefined,
+                allowUnknown: (p.allow_unknown ?? p.allowUnknown) as boolean | undefined,
+                organization: p.organization as string | undefined,
+                project: p.project as string | undefined,
+                probe: this.normalizeProviderProbe(p.probe, p.name as string),
             }));
         }
 
@@ -158,8 +1337,48 @@ export class FileConfigProvider implements WatchableConfigProvider {
                 path: a.path as string,
                 provider: a.provider as string | undefined,
                 defaultModel: (a.default_model ?? a.defaultModel) as string | undefined,
+                allowedModels: (a.allowed_models ?? a.allowedModels) as string[] | undefined,
                 enableResponses: (a.enable_responses ?? a.enableResponses) as boolean | undefined,
                 forceStore: (a.force_store ?? a.forceStore) as boolean | undefined,
+                pipeline: a.pipeline ? this.normalizePipeline(a.pipeline as Record<string, unknown>, a.name as string) : undefined,
+                onDeny: this.normalizeDenyResponse(a.on_deny ?? a.onDeny, `app '${a.name as string}'`),
+                streamThrottle: this.normalizeStreamThrottle(a.stream_throttle ?? a.streamThrottle),
+                headers: this.normalizeHeaderRules(a.headers),
+                gatewayTools: this.normalizeGatewayTools(a.gateway_tools ?? a.gatewayTools),
+                fanOutPrompts: (a.fan_out_prompts ?? a.fanOutPrompts) as boolean | undefined,
+                strictCapabilities: (a.strict_capabilities ?? a.strictCapabilities) as boolean | undefined,
+                maxTokensDefault: this.normalizeMaxTokensDefault(a.max_tokens_default ?? a.maxTokensDefault, a.name as string),
+                prefill: this.normalizePrefill(a.prefill, a.name as string),
+                slo: this.normalizeSlo(a.slo, a.name as string),
+                usageTrailer: (a.usage_trailer ?? a.usageTrailer) as boolean | undefined,
+                embedWarnings: (a.embed_warnings ?? a.embedWarnings) as boolean | undefined,
+                toolArgumentEvents: (a.tool_argument_events ?? a.toolArgumentEvents) as boolean | undefined,
+                maxSseEventBytes: this.normalizeMaxSseEventBytes(a.max_sse_event_bytes ?? a.maxSseEventBytes, a.name as string),
+                explainRouting: (a.explain_routing ?? a.explainRouting) as boolean | undefined,
+                mirror: this.normalizeMirror(a.mirror),
+                transforms: this.normalizeTransforms(a.transforms),
+                correlationHeaders: (a.correlation_headers ?? a.correlationHeaders) as string[] | undefined,
+                validateJsonOutput: this.normalizeJsonOutputValidation(
+                    a.validate_json_output ?? a.validateJsonOutput,
+                    a.name as string,
+                ),
+                recording: this.normalizeRecording(a.recording, a.name as string),
+                eventGranularity: this.normalizeEventGranularity(a.event_granularity ?? a.eventGranularity, a.name as string),
+                cors: this.normalizeCors(a.cors),
+                middleware: this.normalizeMiddleware(a.middleware, a.name as string),
+                forwardEndUser: this.normalizeEndUserForwarding(a.forward_end_user ?? a.forwardEndUser, a.name as string),
+                errorPassthrough: this.normalizeErrorPassthrough(a.error_passthrough ?? a.errorPassthrough, a.name as string),
+                priority: this.normalizePriority(a.priority, a.name as string),
+                coalesce: this.normalizeCoalescing(a.coalesce, a.name as string),
+                threadSummary: this.normalizeThreadSummary(a.thread_summary ?? a.threadSummary, a.name as string),
+                messageDedup: this.normalizeMessageDedup(a.message_dedup ?? a.messageDedup, a.name as string),
+                passthrough: this.normalizePassthrough(a.passthrough, a.name as string, a.frontdoor as string),
+                classification: this.normalizeClassification(a.classification, a.name as string),
+                callBudget: this.normalizeCallBudget(a.call_budget ?? a.callBudget, `app '${a.name as string}': `),
+                parameterPolicy: this.normalizeParameterPolicy(
+                    a.parameter_policy ?? a.parameterPolicy,
+                    `app '${a.name as string}': parameter_policy`,
+                ),
             }));
         } else if (Array.isArray(raw.frontdoors)) {
             // Legacy frontdoors format
@@ -169,10 +1388,62 @@ export class FileConfigProvider implements WatchableConfigProvider {
                 path: fd.path as string,
                 provider: fd.provider as string | undefined,
                 defaultModel: (fd.default_model ?? fd.defaultModel) as string | undefined,
+                allowedModels: (fd.allowed_models ?? fd.allowedModels) as string[] | undefined,
                 enableResponses: (fd.enable_responses ?? fd.enableResponses) as boolean | undefined,
+                streamThrottle: this.normalizeStreamThrottle(fd.stream_throttle ?? fd.streamThrottle),
+                headers: this.normalizeHeaderRules(fd.headers),
+                gatewayTools: this.normalizeGatewayTools(fd.gateway_tools ?? fd.gatewayTools),
+                fanOutPrompts: (fd.fan_out_prompts ?? fd.fanOutPrompts) as boolean | undefined,
+                mirror: this.normalizeMirror(fd.mirror),
+                transforms: this.normalizeTransforms(fd.transforms),
+            }));
+        }
+
+        // Gateway tools
+        if (Array.isArray(raw.tools)) {
+            config.tools = raw.tools.map((t: Record<string, unknown>) => ({
+                name: t.name as string,
+                description: t.description as string | undefined,
+                url: t.url as string,
+                parameters: t.parameters as Record<string, unknown> | undefined,
+                headers: t.headers as Record<string, string> | undefined,
+                timeout: t.timeout as string | undefined,
             }));
         }
 
+        // Prompt templates, as a name-keyed map or a list
+        if (raw.templates && typeof raw.templates === 'object') {
+            const entries: [string | undefined, Record<string, unknown>][] = Array.isArray(raw.templates)
+                ? raw.templates.map((t: Record<string, unknown>): [undefined, Record<string, unknown>] => [undefined, t])
+                : Object.entries(raw.templates as Record<string, Record<string, unknown>>);
+            config.templates = entries.map(([name, t]) => {
+                if (typeof t.text !== 'string') {
+                    throw new Error(`Invalid config for template '${name ?? t.name}': text is required`);
+                }
+                return {
+                    name: name ?? t.name as string,
+                    text: t.text,
+                    required: (t.required ?? t.required_variables ?? t.requiredVariables) as string[] | undefined,
+                    placement: t.placement as 'system' | 'user' | undefined,
+                };
+            });
+        }
+
+        // Token count encodings
+        const tokenCount = (raw.token_count ?? raw.tokenCount) as Record<string, unknown> | undefined;
+        if (tokenCount) {
+            const encodings = (tokenCount.encodings ?? {}) as Record<string, unknown>;
+            for (const [family, path] of Object.entries(encodings)) {
+                if (family !== 'o200k' && family !== 'cl100k') {
+                    throw new Error(`Invalid config for token_count: unknown encoding '${family}' (o200k or cl100k)`);
+                }
+                if (typeof path !== 'string' || path === '') {
+                    throw new Error(`Invalid config for token_count: encodings.${family} must be a file path`);
+                }
+            }
+            config.tokenCount = { encodings: encodings as { o200k?: string; cl100k?: string } };
+        }
+
         // Routing
         if (raw.routing) {
             const routing = raw.routing as Record<string, unknown>;
@@ -185,9 +1456,85 @@ export class FileConfigProvider implements WatchableConfigProvider {
                         provider: r.provider as string,
                     }))
                     : [],
+                affinity: routing.affinity ? this.normalizeAffinity(routing.affinity as Record<string, unknown>) : undefined,
+            };
+        }
+
+        // Provider call concurrency
+        if (raw.concurrency) {
+            config.concurrency = this.normalizeConcurrency(raw.concurrency);
+        }
+
+        // End-user ID hashing
+        const endUsers = (raw.end_users ?? raw.endUsers) as Record<string, unknown> | undefined;
+        if (endUsers) {
+            if (endUsers.salt !== undefined && (typeof endUsers.salt !== 'string' || endUsers.salt === '')) {
+                throw new Error('Invalid config for end_users: salt must be a non-empty string');
+            }
+            config.endUsers = { salt: endUsers.salt as string | undefined };
+        }
+        const hashing = config.apps.find((a) => a.forwardEndUser === 'hash');
+        if (hashing && !config.endUsers?.salt) {
+            throw new Error(`Invalid config for app '${hashing.name}': forward_end_user 'hash' needs end_users.salt`);
+        }
+
+        // Outbound call budget per request
+        const callBudget = raw.call_budget ?? raw.callBudget;
+        if (callBudget) {
+            config.callBudget = this.normalizeCallBudget(callBudget, '');
+        }
+
+        // Sampling parameter policies by model
+        config.parameterPolicies = this.normalizeParameterPolicies(raw.parameter_policies ?? raw.parameterPolicies);
+
+        // Checks of provider-reported completion tokens
+        const usageCheck = raw.usage_check ?? raw.usageCheck;
+        if (usageCheck) {
+            config.usageCheck = this.normalizeUsageCheck(usageCheck);
+        }
+
+        // Sweeping of abandoned interactions
+        const interactionStatus = (raw.interaction_status ?? raw.interactionStatus) as Record<string, unknown> | undefined;
+        if (interactionStatus) {
+            config.interactionStatus = {
+                abandonAfter: (interactionStatus.abandon_after ?? interactionStatus.abandonAfter) as string | undefined,
+                sweepInterval: (interactionStatus.sweep_interval ?? interactionStatus.sweepInterval) as string | undefined,
+            };
+        }
+
+        // Idempotency
+        if (raw.idempotency) {
+            const idempotency = raw.idempotency as Record<string, unknown>;
+            config.idempotency = {
+                enabled: idempotency.enabled as boolean | undefined,
+                ttl: idempotency.ttl as string | undefined,
             };
         }
 
+        // Analytics event sink
+        if (raw.events) {
+            config.events = this.normalizeEvents(raw.events as Record<string, unknown>);
+        }
+
+        // Model catalog
+        if (Array.isArray(raw.models)) {
+            config.models = raw.models.map((m: Record<string, unknown>) => {
+                const pricing = m.pricing as Record<string, unknown> | undefined;
+                return {
+                    id: m.id as string,
+                    apiType: (m.api_type ?? m.apiType) as ModelInfo['apiType'],
+                    provider: m.provider as string | undefined,
+                    contextWindow: (m.context_window ?? m.contextWindow) as number | undefined,
+                    maxOutputTokens: (m.max_output_tokens ?? m.maxOutputTokens) as number | undefined,
+                    supportsTools: (m.supports_tools ?? m.supportsTools) as boolean | undefined,
+                    supportsVision: (m.supports_vision ?? m.supportsVision) as boolean | undefined,
+                    pricing: pricing
+                        ? { input: pricing.input as number, output: pricing.output as number }
+                        : undefined,
+                };
+            });
+        }
+
         // Tenants
         if (Array.isArray(raw.tenants)) {
             config.tenants = raw.tenants.map((t: Record<string, unknown>) => ({
@@ -197,8 +1544,12 @@ export class FileConfigProvider implements WatchableConfigProvider {
                     ? t.api_keys.map((k: Record<string, unknown>) => ({
                         keyHash: (k.key_hash ?? k.keyHash) as string,
                         description: k.description as string | undefined,
+                        app: k.app as string | undefined,
                     }))
                     : undefined,
+                budget: this.normalizeBudget(t.budget),
+                allowedProviders: (t.allowed_providers ?? t.allowedProviders) as string[] | undefined,
+                concurrency: this.normalizeTenantConcurrency(t.concurrency, t.id as string),
             }));
         }
 
@@ -0,0 +1,261 @@
+import { describe, it, expect } from 'vitest';
+import {
+    AdminHandler,
+    DualWriteStorage,
+    createInteractionEvent,
+    dualWriteOf,
+    UNSCOPED_TENANT,
+    type BackfillJob,
+    type Conversation,
+    type StorageProvider,
+} from '@polyglot-llm-gateway/gateway-core';
+import { MemoryStorageProvider, createNodeStorage } from './index';
+
+const at = (minute: number) => new Date(Date.UTC(2025, 0, 1, 0, minute));
+
+function conversation(id: string, minute: number, overrides: Partial<Conversation> = {}): Conversation {
+    return {
+        id,
+        tenantId: 'acme',
+        model: 'gpt-4o',
+        messages: [{ id: `${id}-m1`, role: 'user', content: 'Hi', timestamp: at(minute) }],
+        createdAt: at(minute),
+        updatedAt: at(minute),
+        ...overrides,
+    };
+}
+
+/** A memory store whose writes wait until released, or fail. */
+class SlowStore extends MemoryStorageProvider {
+    private gate: Promise<void> | undefined;
+    private release: (() => void) | undefined;
+    failing = false;
+
+    hold(): void {
+        this.gate = new Promise((resolve) => {
+            this.release = resolve;
+        });
+    }
+
+    open(): void {
+        this.release?.();
+        this.gate = undefined;
+    }
+
+    override async saveConversation(c: Conversation): Promise<void> {
+        await this.gate;
+        if (this.failing) {
+            throw new Error('secondary is read-only');
+        }
+        return super.saveConversation(c);
+    }
+}
+
+function dual(options: ConstructorParameters<typeof DualWriteStorage>[2] = {}) {
+    const primary = new MemoryStorageProvider();
+    const secondary = new SlowStore();
+    const dw = new DualWriteStorage(primary, secondary, options);
+    return { primary, secondary, dw, store: dw.storage };
+}
+
+describe('DualWriteStorage', () => {
+    it('should write to both stores and read from the configured side', async () => {
+        const { primary, secondary, dw, store } = dual();
+
+        await store.saveConversation(conversation('c1', 1));
+        await store.setThreadState('thread-1', 'resp_1');
+        await store.saveEvent(createInteractionEvent('stream_start', 'c1', {}));
+        await dw.flush();
+
+        for (const side of [primary, secondary]) {
+            expect(await side.getConversation('c1', 'acme')).not.toBeNull();
+            expect(await side.getThreadState('thread-1')).toBe('resp_1');
+            expect(await side.getEvents('c1', UNSCOPED_TENANT)).toHaveLength(1);
+        }
+        expect(dw.stats()).toMatchObject({ readFrom: 'primary', mirrored: 3, failed: 0, queued: 0 });
+
+        // Rows only one side has show which side serves reads
+        await primary.saveConversation(conversation('only-primary', 2));
+        await secondary.saveConversation(conversation('only-secondary', 2));
+        expect(await store.getConversation('only-primary', 'acme')).not.toBeNull();
+        dw.configure({ readFrom: 'secondary' });
+        expect(await store.getConversation('only-primary', 'acme')).toBeNull();
+        expect(await store.getConversation('only-secondary', 'acme')).not.toBeNull();
+    });
+
+    it('should let reads of the secondary see the writes queued before them', async () => {
+        const { secondary, store } = dual({ readFrom: 'secondary' });
+        secondary.hold();
+
+        await store.saveConversation(conversation('c1', 1));
+        const read = store.getConversation('c1', 'acme');
+        secondary.open();
+
+        expect((await read)?.id).toBe('c1');
+    });
+
+    it('should mirror what was written, even if the caller changes it afterwards', async () => {
+        const { secondary, dw, store } = dual();
+        secondary.hold();
+
+        const saved = conversation('c1', 1);
+        await store.saveConversation(saved);
+        saved.model = 'changed';
+        secondary.open();
+        await dw.flush();
+
+        expect((await secondary.getConversation('c1', 'acme'))?.model).toBe('gpt-4o');
+    });
+
+    it('should count secondary failures without failing writes in primary_only mode', async () => {
+        const { primary, secondary, dw, store } = dual();
+        secondary.failing = true;
+
+        await store.saveConversation(conversation('c1', 1));
+        await dw.flush();
+
+        expect(await primary.getConversation('c1', 'acme')).not.toBeNull();
+        expect(dw.stats()).toMatchObject({
+            failed: 1,
+            failures: { saveConversation: 1 },
+            lastError: 'secondary is read-only',
+        });
+
+        dw.configure({ failMode: 'require_both' });
+        await expect(store.saveConversation(conversation('c2', 2))).rejects.toThrow('secondary is read-only');
+        expect(dw.stats().failed).toBe(2);
+    });
+
+    it('should drop secondary writes beyond the queue and count them', async () => {
+        const { secondary, dw, store } = dual({ maxQueue: 2 });
+        secondary.hold();
+
+        for (const id of ['c1', 'c2', 'c3', 'c4']) {
+            await store.saveConversation(conversation(id, 1));
+        }
+        expect(dw.stats()).toMatchObject({ queued: 2, dropped: 2 });
+
+        secondary.open();
+        await dw.flush();
+        expect(dw.stats()).toMatchObject({ queued: 0, mirrored: 2 });
+        expect(await secondary.getConversation('c3', 'acme')).toBeNull();
+    });
+
+    it('should only offer what the primary implements', () => {
+        const { storage } = new DualWriteStorage(new MemoryStorageProvider(), new MemoryStorageProvider());
+
+        expect(typeof storage.saveTenant).toBe('function');
+        expect(storage.saveAttempts).toBeUndefined();
+    });
+
+    it('should be built from a storage config of type dual', () => {
+        const storage = createNodeStorage({
+            type: 'dual',
+            primary: { type: 'memory' },
+            secondary: { type: 'memory' },
+            readFrom: 'secondary',
+        });
+
+        expect(dualWriteOf(storage)?.stats().readFrom).toBe('secondary');
+    });
+});
+
+describe('Dual-write maintenance', () => {
+    const operator = { Authorization: 'Bearer ops' };
+    const auth = {
+        authenticate: async (token: string) => ({ tenantId: token, scopes: token === 'ops' ? ['admin'] : [], metadata: {} }),
+        getTenant: async () => null,
+    };
+
+    async function seed(primary: StorageProvider): Promise<void> {
+        for (let i = 0; i < 5; i++) {
+            await primary.saveConversation(conversation(`c${i}`, i));
+            await primary.saveEvent(createInteractionEvent('stream_start', `c${i}`, {}));
+        }
+        await primary.saveResponse({
+            id: 'resp_1', tenantId: 'acme', model: 'gpt-4o', status: 'completed', createdAt: at(9), updatedAt: at(9),
+        });
+        await primary.setThreadState('thread-1', 'resp_1');
+        await primary.saveTenant!({ id: 'acme', name: 'Acme', apiKeys: [], disabled: false, createdAt: at(0), updatedAt: at(0) });
+    }
+
+    async function finished(admin: AdminHandler, job: BackfillJob): Promise<BackfillJob> {
+        for (;;) {
+            const polled = await (await admin.handle(new Request(`http://localhost/api/maintenance/backfill/${job.id}`, {
+                headers: operator,
+            }))).json() as BackfillJob;
+            if (polled.status !== 'running') return polled;
+            await new Promise((resolve) => setTimeout(resolve, 5));
+        }
+    }
+
+    it('should backfill historical rows in batches, skipping those already copied', async () => {
+        const primary = new MemoryStorageProvider();
+        const secondary = new MemoryStorageProvider();
+        await seed(primary);
+        const dw = new DualWriteStorage(primary, secondary);
+        const admin = new AdminHandler({ storage: dw.storage, dualWrite: dw, auth });
+
+        // Written after dual-writing began: already on both sides
+        await dw.storage.saveConversation(conversation('live', 20));
+        await dw.flush();
+
+        const started = await admin.handle(new Request('http://localhost/api/maintenance/backfill?batch_size=2', {
+            method: 'POST',
+            headers: operator,
+        }));
+        expect(started.status).toBe(202);
+        const job = await finished(admin, await started.json() as BackfillJob);
+
+        expect(job).toMatchObject({
+            status: 'completed',
+            batchSize: 2,
+            total: 7,
+            counts: { interactions: 7, conversations: 5, responses: 1, events: 5, threadState: 1, tenants: 1, skipped: 1, failed: 0 },
+        });
+        expect(await secondary.getConversation('c3', 'acme')).toEqual(await primary.getConversation('c3', 'acme'));
+        expect(await secondary.getEvents('c3', UNSCOPED_TENANT)).toEqual(await primary.getEvents('c3', UNSCOPED_TENANT));
+        expect(await secondary.getThreadState('thread-1')).toBe('resp_1');
+        expect(await secondary.getTenant('acme')).not.toBeNull();
+
+        const again = await finished(admin, await dw.startBackfill());
+        expect(again.counts).toMatchObject({ conversations: 0, events: 0, threadState: 0, tenants: 0, skipped: 9 });
+    });
+
+    it('should report sampled interactions that differ before cutover', async () => {
+        const primary = new MemoryStorageProvider();
+        const secondary = new MemoryStorageProvider();
+        await seed(primary);
+        await secondary.saveConversation(conversation('c1', 1, { model: 'gpt-4o-mini' }));
+        await secondary.saveConversation(conversation('c2', 2));
+        const dw = new DualWriteStorage(primary, secondary);
+        const admin = new AdminHandler({ storage: dw.storage, dualWrite: dw, auth });
+        const check = async (query: string) => admin.handle(new Request(`http://localhost/api/maintenance/consistency${query}`, {
+            headers: operator,
+        }));
+
+        const report = await (await check('?sample=50')).json();
+        expect(report.sampled).toBe(6);
+        expect(report.matched).toBe(0);
+        expect(report.mismatches).toEqual(expect.arrayContaining([
+            { id: 'c0', type: 'conversation', problem: 'missing' },
+            { id: 'c1', type: 'conversation', problem: 'different' },
+            { id: 'c2', type: 'conversation', problem: 'events_differ' },
+            { id: 'resp_1', type: 'response', problem: 'missing' },
+        ]));
+
+        await dw.storage.saveConversation(conversation('c1', 1));
+        await dw.flush();
+        await finished(admin, await dw.startBackfill());
+        expect(await (await check('')).json()).toEqual({ sampled: 6, matched: 6, mismatches: [] });
+        expect((await (await check('?sample=3')).json()).sampled).toBe(3);
+
+        expect((await check('?sample=0')).status).toBe(400);
+        expect((await admin.handle(new Request('http://localhost/api/maintenance/consistency', {
+            headers: { Authorization: 'Bearer acme' },
+        }))).status).toBe(403);
+        expect((await new AdminHandler({ storage: primary, auth }).handle(new Request('http://localhost/api/maintenance/consistency', {
+            headers: operator,
+        }))).status).toBe(503);
+    });
+});
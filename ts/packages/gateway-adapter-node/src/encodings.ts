@@ -0,0 +1,19 @@
+/**
+ * tiktoken rank files for exact token counts.
+ *
+ * @module encodings
+ */
+
+import { readFileSync } from 'node:fs';
+
+/**
+ * Reads a tiktoken rank file named in `token_count.encodings` (Gateway's
+ * encodingLoader). Errors name the file.
+ */
+export function loadEncodingFile(path: string): string {
+    try {
+        return readFileSync(path, 'utf-8');
+    } catch (error) {
+        throw new Error(`Failed to read encoding ${path}: ${(error as Error).message}`);
+    }
+}
@@ -0,0 +1,64 @@
+import { describe, it, expect, vi } from 'vitest';
+import { createLifecycleEvent } from '@polyglot-llm-gateway/gateway-core';
+import { KafkaEventSink, createNodeEventSink, type KafkaProducer } from './events';
+
+function fakeProducer(options: { failConnect?: boolean } = {}) {
+    const producer = {
+        connect: vi.fn(async () => {
+            if (options.failConnect) throw new Error('broker unreachable');
+        }),
+        send: vi.fn(async () => []),
+        disconnect: vi.fn(async () => { }),
+    };
+    return producer satisfies KafkaProducer;
+}
+
+describe('KafkaEventSink', () => {
+    it('should produce one message per event keyed by interaction', async () => {
+        const producer = fakeProducer();
+        const sink = new KafkaEventSink({ topic: 'llm-events', producer: async () => producer });
+
+        const first = createLifecycleEvent('interaction_completed', 'int-1', 'tenant-1');
+        const second = createLifecycleEvent('interaction_completed', 'int-2', 'tenant-1');
+        await sink.send([first, second]);
+        await sink.send([first]);
+
+        expect(producer.connect).toHaveBeenCalledTimes(1);
+        const record = producer.send.mock.calls[0]![0] as any;
+        expect(record.topic).toBe('llm-events');
+        expect(record.messages.map((m: any) => m.key)).toEqual(['int-1', 'int-2']);
+        expect(JSON.parse(record.messages[0].value)).toMatchObject({
+            id: first.id,
+            type: 'interaction_completed',
+            timestamp: first.timestamp.toISOString(),
+        });
+
+        await sink.close();
+        expect(producer.disconnect).toHaveBeenCalled();
+    });
+
+    it('should reconnect after a failed connect', async () => {
+        const broken = fakeProducer({ failConnect: true });
+        const healthy = fakeProducer();
+        const factory = vi.fn()
+            .mockResolvedValueOnce(broken)
+            .mockResolvedValueOnce(healthy);
+        const sink = new KafkaEventSink({ topic: 'llm-events', producer: factory });
+        const event = createLifecycleEvent('interaction_completed', 'int-1', 'tenant-1');
+
+        await expect(sink.send([event])).rejects.toThrow('broker unreachable');
+        await sink.send([event]);
+
+        expect(factory).toHaveBeenCalledTimes(2);
+        expect(healthy.send).toHaveBeenCalledTimes(1);
+    });
+});
+
+describe('createNodeEventSink', () => {
+    it('should only handle the kafka sink', () => {
+        expect(createNodeEventSink({ sink: 'webhook', webhook: { url: 'https://hooks.example.com' } })).toBeUndefined();
+        expect(createNodeEventSink({ sink: 'kafka', kafka: { brokers: ['localhost:9092'], topic: 'llm-events' } }))
+            .toBeInstanceOf(KafkaEventSink);
+        expect(() => createNodeEventSink({ sink: 'kafka' })).toThrow('events.kafka.brokers');
+    });
+});
@@ -0,0 +1,131 @@
+/**
+ * Kafka analytics event sink for Node.js.
+ *
+ * Produces each event as one message keyed by interaction ID, so all
+ * events of an interaction land on the same partition. The client library
+ * (kafkajs) is loaded on first use and is only needed when `events.sink`
+ * is "kafka"; install it alongside the gateway to enable this sink.
+ *
+ * @module events
+ */
+
+import {
+    serializeEvent,
+    type EventSink,
+    type EventsConfig,
+    type EventsKafkaConfig,
+    type LifecycleEvent,
+} from '@polyglot-llm-gateway/gateway-core';
+
+// ============================================================================
+// Kafka Sink
+// ============================================================================
+
+/** Default Kafka client ID. */
+const DEFAULT_KAFKA_CLIENT_ID = 'polyglot-llm-gateway';
+
+/**
+ * The producer surface the sink uses (a subset of kafkajs's Producer).
+ */
+export interface KafkaProducer {
+    connect(): Promise<void>;
+    send(record: { topic: string; messages: Array<{ key: string; value: string }> }): Promise<unknown>;
+    disconnect(): Promise<void>;
+}
+
+/**
+ * Kafka sink options.
+ */
+export interface KafkaEventSinkOptions {
+    /** Topic to produce to. */
+    topic: string;
+
+    /** Creates the (unconnected) producer; called again after a failed connect. */
+    producer: () => Promise<KafkaProducer>;
+}
+
+/**
+ * Event sink that produces batches to a Kafka topic.
+ */
+export class KafkaEventSink implements EventSink {
+    readonly name = 'kafka';
+    private connecting: Promise<KafkaProducer> | undefined;
+
+    constructor(private readonly options: KafkaEventSinkOptions) { }
+
+    async send(events: LifecycleEvent[]): Promise<void> {
+        const producer = await this.connect();
+        await producer.send({
+            topic: this.options.topic,
+            messages: events.map((event) => ({
+                key: event.interactionId,
+                value: JSON.stringify(serializeEvent(event)),
+            })),
+        });
+    }
+
+    async close(): Promise<void> {
+        const producer = await this.connecting?.catch(() => undefined);
+        this.connecting = undefined;
+        await producer?.disconnect();
+    }
+
+    private connect(): Promise<KafkaProducer> {
+        this.connecting ??= this.options.producer().then(async (producer) => {
+            await producer.connect();
+            return producer;
+        });
+        // Let the next batch retry the connection
+        this.connecting.catch(() => {
+            this.connecting = undefined;
+        });
+        return this.connecting;
+    }
+}
+
+/**
+ * Creates a Kafka sink from config, loading kafkajs when the first batch
+ * is sent.
+ */
+export function createKafkaEventSink(config: EventsKafkaConfig): KafkaEventSink {
+    return new KafkaEventSink({
+        topic: config.topic,
+        producer: async () => {
+            const { Kafka } = await loadKafkaJS();
+            return new Kafka({
+                clientId: config.clientId ?? DEFAULT_KAFKA_CLIENT_ID,
+                brokers: config.brokers,
+                ssl: config.tls ?? false,
+                sasl: config.sasl,
+            }).producer();
+        },
+    });
+}
+
+/**
+ * Creates the Node.js-specific sink for an events config (Gateway's
+ * eventSinkFactory). Returns undefined for sinks gateway-core provides.
+ */
+export function createNodeEventSink(config: EventsConfig): EventSink | undefined {
+    if (config.sink !== 'kafka') {
+        return undefined;
+    }
+    if (!config.kafka?.topic || !config.kafka.brokers?.length) {
+        throw new Error('events.kafka.brokers and events.kafka.topic are required for the kafka sink');
+    }
+    return createKafkaEventSink(config.kafka);
+}
+
+interface KafkaJS {
+    Kafka: new (config: Record<string, unknown>) => { producer(): KafkaProducer };
+}
+
+async function loadKafkaJS(): Promise<KafkaJS> {
+    // A variable specifier keeps kafkajs an optional, untyped dependency
+    const specifier = 'kafkajs';
+    try {
+        return await import(specifier) as KafkaJS;
+    } catch {
+        throw new Error('The kafka event sink requires the kafkajs package; install it with `pnpm add kafkajs`');
+    }
+}
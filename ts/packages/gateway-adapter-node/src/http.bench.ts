@@ -0,0 +1,57 @@
+import { readFileSync } from 'node:fs';
+import https from 'node:https';
+import type { AddressInfo } from 'node:net';
+import { fileURLToPath } from 'node:url';
+import { afterAll, beforeAll, bench, describe } from 'vitest';
+import { createNodeHTTPClient, type NodeHTTPClient } from './http';
+
+const fixture = (name: string) => fileURLToPath(new URL(`./__fixtures__/${name}`, import.meta.url));
+const CA_BUNDLE = fixture('test-ca.pem');
+
+const CONCURRENCY = 32;
+
+let server: https.Server;
+let url: string;
+
+beforeAll(async () => {
+    server = https.createServer(
+        {
+            key: readFileSync(fixture('test-ca-leaf-key.pem')),
+            cert: readFileSync(fixture('test-ca-leaf-cert.pem')),
+        },
+        (_req, res) => {
+            res.setHeader('Content-Type', 'application/json');
+            res.end('{"id":"chatcmpl-bench","choices":[]}');
+        },
+    );
+    await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+    url = `https://localhost:${(server.address() as AddressInfo).port}/v1/chat/completions`;
+});
+
+afterAll(() => {
+    server.closeAllConnections();
+    server.close();
+});
+
+async function burst(client: NodeHTTPClient): Promise<void> {
+    await Promise.all(
+        Array.from({ length: CONCURRENCY }, async () => {
+            const res = await client.fetch(url, { method: 'POST', body: '{}' });
+            await res.text();
+        }),
+    );
+}
+
+describe(`${CONCURRENCY} concurrent requests over TLS`, () => {
+    const narrow = createNodeHTTPClient({ maxIdleConnsPerHost: 2, caBundlePath: CA_BUNDLE });
+    const tuned = createNodeHTTPClient({ maxIdleConnsPerHost: CONCURRENCY, caBundlePath: CA_BUNDLE });
+
+    afterAll(() => {
+        narrow.close();
+        tuned.close();
+    });
+
+    bench('max_idle_conns_per_host=2', () => burst(narrow));
+
+    bench(`max_idle_conns_per_host=${CONCURRENCY}`, () => burst(tuned));
+});
@@ -0,0 +1,193 @@
+import { describe, it, expect, beforeAll, afterAll, vi } from 'vitest';
+import { readFileSync, writeFileSync, mkdtempSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { fileURLToPath } from 'node:url';
+import http from 'node:http';
+import https from 'node:https';
+import net from 'node:net';
+import type { AddressInfo } from 'node:net';
+import { NodeHTTPClient, loadCABundle } from './http';
+import { FileConfigProvider } from './config';
+
+const fixture = (name: string) => fileURLToPath(new URL(`./__fixtures__/${name}`, import.meta.url));
+const CA_BUNDLE = fixture('test-ca.pem');
+
+/** TLS server presenting a leaf certificate issued by the test CA. */
+let server: https.Server;
+let target: string;
+
+/** CONNECT proxy that records the targets it tunnels to. */
+let proxy: http.Server;
+let proxyUrl: string;
+const tunneled: string[] = [];
+
+const clients: NodeHTTPClient[] = [];
+const client = (config: ConstructorParameters<typeof NodeHTTPClient>[0]) => {
+    const c = new NodeHTTPClient(config);
+    clients.push(c);
+    return c;
+};
+
+beforeAll(async () => {
+    server = https.createServer({
+        key: readFileSync(fixture('test-ca-leaf-key.pem')),
+        cert: readFileSync(fixture('test-ca-leaf-cert.pem')),
+    }, (_req, res) => res.end('ok'));
+    await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+    target = `https://localhost:${(server.address() as AddressInfo).port}/`;
+
+    proxy = http.createServer();
+    proxy.on('connect', (req, socket, head) => {
+        tunneled.push(req.url ?? '');
+        const [host, port] = (req.url ?? '').split(':');
+        const upstream = net.connect(Number(port), host === 'localhost' ? '127.0.0.1' : host, () => {
+            socket.write('HTTP/1.1 200 Connection Established\r\n\r\n');
+            upstream.write(head);
+            upstream.pipe(socket);
+            socket.pipe(upstream);
+        });
+        upstream.on('error', () => socket.destroy());
+    });
+    await new Promise<void>((resolve) => proxy.listen(0, '127.0.0.1', resolve));
+    proxyUrl = `http://127.0.0.1:${(proxy.address() as AddressInfo).port}`;
+});
+
+afterAll(async () => {
+    for (const c of clients) c.close();
+    server.closeAllConnections();
+    proxy.closeAllConnections();
+    await new Promise((resolve) => server.close(resolve));
+    await new Promise((resolve) => proxy.close(resolve));
+});
+
+describe('NodeHTTPClient TLS trust', () => {
+    it('should reject a certificate from an untrusted private CA by default', async () => {
+        await expect(client({}).fetch(target)).rejects.toThrow();
+    });
+
+    it('should trust certificates issued by the configured CA bundle', async () => {
+        const res = await client({ caBundlePath: CA_BUNDLE }).fetch(target);
+
+        expect(res.status).toBe(200);
+        expect(await res.text()).toBe('ok');
+    });
+
+    it('should skip verification when insecure_skip_verify is set', async () => {
+        const res = await client({ insecureSkipVerify: true }).fetch(target);
+
+        expect(res.status).toBe(200);
+    });
+});
+
+describe('NodeHTTPClient proxy isolation', () => {
+    it('should tunnel only the client configured with a proxy', async () => {
+        tunneled.length = 0;
+        const proxied = client({ proxyUrl, caBundlePath: CA_BUNDLE });
+        const direct = client({ caBundlePath: CA_BUNDLE });
+
+        expect((await proxied.fetch(target)).status).toBe(200);
+        expect((await direct.fetch(target)).status).toBe(200);
+
+        expect(tunneled).toEqual([new URL(target).host]);
+    });
+});
+
+describe('NodeHTTPClient pool tuning', () => {
+    /** Plain HTTP servers that hold responses until `size` requests are in. */
+    const pools: http.Server[] = [];
+    const targets: string[] = [];
+    let size = 1;
+    let held: http.ServerResponse[] = [];
+
+    beforeAll(async () => {
+        for (let i = 0; i < 2; i++) {
+            const pool = http.createServer((_req, res) => {
+                held.push(res);
+                if (held.length >= size) {
+                    for (const r of held) r.end('ok');
+                    held = [];
+                }
+            });
+            await new Promise<void>((resolve) => pool.listen(0, '127.0.0.1', resolve));
+            pools.push(pool);
+            targets.push(`http://127.0.0.1:${(pool.address() as AddressInfo).port}/`);
+        }
+    });
+
+    afterAll(async () => {
+        for (const pool of pools) {
+            pool.closeAllConnections();
+            await new Promise((resolve) => pool.close(resolve));
+        }
+    });
+
+    async function burst(c: NodeHTTPClient, n: number, url = targets[0]!): Promise<void> {
+        size = n;
+        await Promise.all(Array.from({ length: n }, async () => (await c.fetch(url)).text()));
+    }
+
+    it('should keep at most max_idle_conns_per_host idle and reuse them', async () => {
+        const c = client({ maxIdleConnsPerHost: 2 });
+
+        await burst(c, 6);
+        expect(c.stats().newConnections).toBe(6);
+        await vi.waitFor(() => expect(c.stats().idleConnections).toBe(2));
+
+        await burst(c, 2);
+        expect(c.stats()).toMatchObject({ newConnections: 6, reusedConnections: 2, activeRequests: 0 });
+    });
+
+    it('should open no more than max_conns_per_host connections', async () => {
+        const c = client({ maxConnsPerHost: 2 });
+        size = 1;
+
+        await Promise.all(Array.from({ length: 6 }, async () => (await c.fetch(targets[0]!)).text()));
+
+        expect(c.stats()).toMatchObject({ newConnections: 2, reusedConnections: 4 });
+    });
+
+    it('should cap idle connections across hosts at max_idle_conns', async () => {
+        const c = client({ maxIdleConns: 3, maxIdleConnsPerHost: 4 });
+
+        await burst(c, 4);
+        await vi.waitFor(() => expect(c.stats().idleConnections).toBe(3));
+        await burst(c, 4, targets[1]);
+        await vi.waitFor(() => expect(c.stats().idleConnections).toBe(3));
+        expect(c.stats().newConnections).toBe(8);
+    });
+});
+
+describe('loadCABundle', () => {
+    const dir = mkdtempSync(join(tmpdir(), 'ca-bundle-'));
+
+    it('should name the path when the bundle is missing', () => {
+        const path = join(dir, 'missing.pem');
+
+        expect(() => loadCABundle(path)).toThrow(path);
+    });
+
+    it('should name the path when the bundle has no certificates', () => {
+        const path = join(dir, 'garbage.pem');
+        writeFileSync(path, 'not a certificate');
+
+        expect(() => loadCABundle(path)).toThrow(`CA bundle ${path} contains no PEM certificates`);
+    });
+
+    it('should fail config validation for a bad provider bundle', async () => {
+        const bundle = join(dir, 'missing.pem');
+        const path = join(dir, 'config.yaml');
+        writeFileSync(path, [
+            'providers:',
+            '  - name: openai',
+            '    type: openai',
+            '    api_key: k',
+            '    http:',
+            `      ca_bundle_path: ${bundle}`,
+        ].join('\n'));
+
+        await expect(new FileConfigProvider({ path, env: {} }).load()).rejects.toThrow(
+            new RegExp(`provider 'openai'.*${bundle}`),
+        );
+    });
+});
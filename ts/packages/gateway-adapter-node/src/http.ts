@@ -0,0 +1,395 @@
+/**
+ * Tuned HTTP client for outbound provider calls on Node.js.
+ *
+ * Global fetch pools connections per origin with fixed limits, which under
+ * load leads to fresh TLS handshakes per request. This client exposes the
+ * pool knobs from ProviderHTTPConfig on keep-alive agents and counts new vs
+ * reused connections for the admin provider health endpoint. Each client
+ * carries its own proxy and TLS trust settings, so one provider's corporate
+ * proxy or private CA never leaks into another provider or a webhook.
+ *
+ * @module http
+ */
+
+import { readFileSync } from 'node:fs';
+import { X509Certificate } from 'node:crypto';
+import http from 'node:http';
+import https from 'node:https';
+import tls from 'node:tls';
+import type { Duplex } from 'node:stream';
+import { Readable } from 'node:stream';
+import type { Socket } from 'node:net';
+import {
+    parseDuration,
+    type ProviderHTTPConfig,
+    type ProviderHTTPClient,
+    type HTTPPoolStats,
+} from '@polyglot-llm-gateway/gateway-core';
+
+// ============================================================================
+// Defaults
+// ============================================================================
+
+const DEFAULT_MAX_IDLE_CONNS = 100;
+const DEFAULT_MAX_IDLE_CONNS_PER_HOST = 32;
+const DEFAULT_IDLE_CONN_TIMEOUT_MS = 90_000;
+const DEFAULT_DIAL_TIMEOUT_MS = 30_000;
+const DEFAULT_TLS_HANDSHAKE_TIMEOUT_MS = 10_000;
+
+/** PEM certificate block. */
+const PEM_CERTIFICATE = /-----BEGIN CERTIFICATE-----[\s\S]+?-----END CERTIFICATE-----/g;
+
+/** Responses that never carry a body. */
+const NULL_BODY_STATUSES = new Set([101, 204, 205, 304]);
+
+// ============================================================================
+// Node HTTP Client
+// ============================================================================
+
+/**
+ * Provider HTTP client backed by node:http/node:https keep-alive agents.
+ */
+export class NodeHTTPClient implements ProviderHTTPClient {
+    private readonly httpAgent: http.Agent;
+    private readonly httpsAgent: https.Agent;
+    private readonly proxy: URL | undefined;
+    private readonly maxIdleConns: number;
+    private readonly dialTimeoutMs: number;
+    private readonly tlsHandshakeTimeoutMs: number;
+    private readonly responseHeaderTimeoutMs: number;
+
+    private newConnections = 0;
+    private reusedConnections = 0;
+    private activeRequests = 0;
+
+    constructor(config: ProviderHTTPConfig = {}) {
+        this.maxIdleConns = config.maxIdleConns ?? DEFAULT_MAX_IDLE_CONNS;
+        this.dialTimeoutMs = parseDuration(config.dialTimeout, DEFAULT_DIAL_TIMEOUT_MS);
+        this.tlsHandshakeTimeoutMs = parseDuration(
+            config.tlsHandshakeTimeout,
+            DEFAULT_TLS_HANDSHAKE_TIMEOUT_MS,
+        );
+        this.responseHeaderTimeoutMs = parseDuration(config.responseHeaderTimeout, 0);
+        this.proxy = config.proxyUrl ? new URL(config.proxyUrl) : undefined;
+
+        const agentOptions: http.AgentOptions = {
+            keepAlive: true,
+            maxSockets: config.maxConnsPerHost && config.maxConnsPerHost > 0
+                ? config.maxConnsPerHost
+                : Infinity,
+            maxFreeSockets: config.maxIdleConnsPerHost ?? DEFAULT_MAX_IDLE_CONNS_PER_HOST,
+            timeout: parseDuration(config.idleConnTimeout, DEFAULT_IDLE_CONN_TIMEOUT_MS),
+            scheduling: 'lifo',
+        };
+
+        // A private CA extends the default roots rather than replacing them
+        const tlsOptions: https.AgentOptions = {
+            ...agentOptions,
+            ...(config.caBundlePath
+                ? { ca: [...tls.rootCertificates, ...loadCABundle(config.caBundlePath)] }
+                : {}),
+            ...(config.insecureSkipVerify ? { rejectUnauthorized: false } : {}),
+        };
+
+        this.httpAgent = new http.Agent(agentOptions);
+        this.httpsAgent = this.proxy
+            ? new TunnelingAgent(tlsOptions, this.proxy, this.dialTimeoutMs)
+            : new https.Agent(tlsOptions);
+
+        // Enforce the cross-host idle limit as sockets return to the pool
+        for (const agent of [this.httpAgent, this.httpsAgent]) {
+            agent.on('free', (socket: Socket) => {
+                if (this.idleConnections() > this.maxIdleConns) {
+                    socket.destroy();
+                }
+            });
+        }
+    }
+
+    /**
+     * Fetch implementation routed through the tuned agents.
+     */
+    readonly fetch: typeof globalThis.fetch = async (input, init) => {
+        const request = new Request(input, init);
+        const url = new URL(request.url);
+        const isHTTPS = url.protocol === 'https:';
+        const body = request.body ? Buffer.from(await request.arrayBuffer()) : undefined;
+
+        const headers: Record<string, string> = {};
+        request.headers.forEach((value, name) => {
+            headers[name] = value;
+        });
+        if (body) {
+            headers['content-length'] = String(body.byteLength);
+        }
+
+        // Plain-HTTP targets behind a proxy use absolute-form request targets
+        const viaProxy = this.proxy !== undefined && !isHTTPS;
+        const options: http.RequestOptions = viaProxy
+            ? {
+                host: this.proxy!.hostname,
+                port: this.proxy!.port || 80,
+                path: url.href,
+                headers: { ...headers, host: url.host, ...proxyAuthHeader(this.proxy!) },
+            }
+            : {
+                host: url.hostname,
+                port: url.port || (isHTTPS ? 443 : 80),
+                path: `${url.pathname}${url.search}`,
+                headers,
+            };
+
+        return new Promise<Response>((resolve, reject) => {
+            const signal = request.signal;
+            if (signal.aborted) {
+                reject(signal.reason);
+                return;
+            }
+
+            const transport = isHTTPS ? https : http;
+            const req = transport.request({
+                ...options,
+                method: request.method,
+                agent: isHTTPS ? this.httpsAgent : this.httpAgent,
+            });
+
+            this.activeRequests++;
+            let finished = false;
+            const done = (): void => {
+                if (!finished) {
+                    finished = true;
+                    this.activeRequests--;
+                    signal.removeEventListener('abort', onAbort);
+                }
+            };
+
+            const onAbort = (): void => {
+                req.destroy(signal.reason instanceof Error ? signal.reason : new Error('Request aborted'));
+            };
+            signal.addEventListener('abort', onAbort, { once: true });
+
+            req.on('socket', (socket: Socket) => {
+                if (req.reusedSocket) {
+                    this.reusedConnections++;
+                    return;
+                }
+                this.newConnections++;
+                this.armHandshakeTimers(req, socket, isHTTPS && !viaProxy);
+            });
+
+            let headerTimer: NodeJS.Timeout | undefined;
+            if (this.responseHeaderTimeoutMs > 0) {
+                req.on('finish', () => {
+                    headerTimer = setTimeout(() => {
+                        req.destroy(new Error(
+                            `Timed out after ${this.responseHeaderTimeoutMs}ms waiting for response headers`,
+                        ));
+                    }, this.responseHeaderTimeoutMs);
+                });
+            }
+
+            req.on('response', (res) => {
+                clearTimeout(headerTimer);
+                res.on('close', done);
+
+                const responseHeaders = new Headers();
+                for (const [name, value] of Object.entries(res.headers)) {
+                    if (Array.isArray(value)) {
+                        for (const v of value) responseHeaders.append(name, v);
+                    } else if (value !== undefined) {
+                        responseHeaders.set(name, value);
+                    }
+                }
+
+                const status = res.statusCode ?? 500;
+                const hasBody = request.method !== 'HEAD' && !NULL_BODY_STATUSES.has(status);
+                if (!hasBody) {
+                    res.resume();
+                }
+
+                resolve(new Response(
+                    hasBody ? (Readable.toWeb(res) as unknown as ReadableStream<Uint8Array>) : null,
+                    { status, statusText: res.statusMessage ?? '', headers: responseHeaders },
+                ));
+            });
+
+            req.on('error', (error) => {
+                clearTimeout(headerTimer);
+                done();
+                reject(error);
+            });
+
+            req.end(body);
+        });
+    };
+
+    /**
+     * Returns connection pool statistics.
+     */
+    stats(): HTTPPoolStats {
+        return {
+            newConnections: this.newConnections,
+            reusedConnections: this.reusedConnections,
+            activeRequests: this.activeRequests,
+            idleConnections: this.idleConnections(),
+        };
+    }
+
+    /**
+     * Closes idle pooled connections. In-flight requests run to completion.
+     */
+    close(): void {
+        for (const agent of [this.httpAgent, this.httpsAgent]) {
+            for (const sockets of Object.values(agent.freeSockets)) {
+                for (const socket of sockets ?? []) {
+                    socket.destroy();
+                }
+            }
+        }
+    }
+
+    /**
+     * Arms dial and TLS handshake timers for a freshly created socket.
+     */
+    private armHandshakeTimers(req: http.ClientRequest, socket: Socket, expectTLS: boolean): void {
+        const fail = (message: string) => (): void => {
+            req.destroy(new Error(message));
+        };
+
+        const startTLSTimer = (): void => {
+            if (!expectTLS) return;
+            const timer = setTimeout(
+                fail(`TLS handshake timed out after ${this.tlsHandshakeTimeoutMs}ms`),
+                this.tlsHandshakeTimeoutMs,
+            );
+            socket.once('secureConnect', () => clearTimeout(timer));
+            socket.once('close', () => clearTimeout(timer));
+        };
+
+        if (!socket.connecting) {
+            // Tunneled sockets arrive connected; only the handshake remains
+            startTLSTimer();
+            return;
+        }
+
+        const dialTimer = setTimeout(
+            fail(`Connection timed out after ${this.dialTimeoutMs}ms`),
+            this.dialTimeoutMs,
+        );
+        socket.once('connect', () => {
+            clearTimeout(dialTimer);
+            startTLSTimer();
+        });
+        socket.once('close', () => clearTimeout(dialTimer));
+    }
+
+    private idleConnections(): number {
+        let count = 0;
+        for (const agent of [this.httpAgent, this.httpsAgent]) {
+            for (const sockets of Object.values(agent.freeSockets)) {
+                count += sockets?.length ?? 0;
+            }
+        }
+        return count;
+    }
+}
+
+// ============================================================================
+// Proxy Tunneling
+// ============================================================================
+
+/**
+ * HTTPS agent that reaches its targets through an HTTP CONNECT proxy.
+ */
+class TunnelingAgent extends https.Agent {
+    constructor(
+        options: https.AgentOptions,
+        private readonly proxy: URL,
+        private readonly dialTimeoutMs: number,
+    ) {
+        super(options);
+    }
+
+    createConnection(
+        options: tls.ConnectionOptions & { host?: string; port?: number },
+        callback: (error: Error | null, socket?: Duplex) => void,
+    ): undefined {
+        const target = `${options.host}:${options.port ?? 443}`;
+        const connectRequest = http.request({
+            host: this.proxy.hostname,
+            port: this.proxy.port || 80,
+            method: 'CONNECT',
+            path: target,
+            headers: { host: target, ...proxyAuthHeader(this.proxy) },
+            timeout: this.dialTimeoutMs,
+        });
+
+        connectRequest.once('connect', (res, socket) => {
+            if (res.statusCode !== 200) {
+                socket.destroy();
+                callback(new Error(`Proxy CONNECT to ${target} failed with status ${res.statusCode}`));
+                return;
+            }
+            callback(null, tls.connect({ ...options, socket }));
+        });
+        connectRequest.once('timeout', () => {
+            connectRequest.destroy(new Error(`Proxy connection timed out after ${this.dialTimeoutMs}ms`));
+        });
+        connectRequest.once('error', (error) => callback(error));
+        connectRequest.end();
+
+        return undefined;
+    }
+}
+
+function proxyAuthHeader(proxy: URL): Record<string, string> {
+    if (!proxy.username) {
+        return {};
+    }
+    const credentials = `${decodeURIComponent(proxy.username)}:${decodeURIComponent(proxy.password)}`;
+    return { 'proxy-authorization': `Basic ${Buffer.from(credentials).toString('base64')}` };
+}
+
+// ============================================================================
+// CA Bundles
+// ============================================================================
+
+/**
+ * Reads and parses a PEM CA bundle. Errors name the bundle path so a bad
+ * ca_bundle_path fails config validation with an actionable message.
+ */
+export function loadCABundle(path: string): string[] {
+    let pem: string;
+    try {
+        pem = readFileSync(path, 'utf-8');
+    } catch (error) {
+        throw new Error(`Failed to read CA bundle ${path}: ${(error as Error).message}`);
+    }
+
+    const certificates = pem.match(PEM_CERTIFICATE) ?? [];
+    if (certificates.length === 0) {
+        throw new Error(`CA bundle ${path} contains no PEM certificates`);
+    }
+    for (const certificate of certificates) {
+        try {
+            new X509Certificate(certificate);
+        } catch (error) {
+            throw new Error(`Invalid certificate in CA bundle ${path}: ${(error as Error).message}`);
+        }
+    }
+    return certificates;
+}
+
+// ============================================================================
+// Factory Function
+// ============================================================================
+
+/**
+ * Creates a tuned HTTP client from provider HTTP configuration.
+ * Suitable as Gateway's httpClientFactory: `(p) => createNodeHTTPClient(p.http)`,
+ * and as its webhookClientFactory since pipeline stages carry the same
+ * proxy and TLS fields.
+ */
+export function createNodeHTTPClient(config?: ProviderHTTPConfig): NodeHTTPClient {
+    return new NodeHTTPClient(config);
+}
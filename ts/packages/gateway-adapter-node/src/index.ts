@@ -7,6 +7,42 @@
 // File-based config provider
 export { FileConfigProvider, type FileConfigProviderOptions } from './config.js';
 
+// Tuned outbound HTTP client
+export { NodeHTTPClient, createNodeHTTPClient, loadCABundle } from './http.js';
+
+// Kafka analytics event sink
+export {
+    KafkaEventSink,
+    createKafkaEventSink,
+    createNodeEventSink,
+    type KafkaProducer,
+    type KafkaEventSinkOptions,
+} from './events.js';
+
+// File-backed write spill
+export {
+    FileSpillStorage,
+    createNodeSpillStorage,
+    DEFAULT_SPILL_MAX_BYTES,
+    DEFAULT_SPILL_SEGMENT_BYTES,
+    type FileSpillStorageOptions,
+} from './spill.js';
+
+// tiktoken rank files
+export { loadEncodingFile } from './encodings.js';
+
+// Data plane and admin listeners
+export {
+    GatewayServer,
+    toWebRequest,
+    writeWebResponse,
+    ADMIN_PREFIX,
+    DEFAULT_ADMIN_BIND,
+    type GatewayServerOptions,
+    type GatewayServerAddresses,
+} from './server.js';
+
+import { createHash } from 'node:crypto';
 import type {
     ConfigProvider,
     GatewayConfig,
@@ -18,13 +54,47 @@ import type {
     LifecycleEvent,
     ProviderConfig,
     Conversation,
+    StoredMessage,
+    StoredThread,
+    AddMessageOptions,
+    AddMessageResult,
     ResponseRecord,
     InteractionSummary,
+    InteractionRecord,
+    InteractionUpdate,
     InteractionEvent,
     ShadowResult,
     ListOptions,
     InteractionListOptions,
     DivergenceListOptions,
+    IdempotencyRecord,
+    StoredHTTPResponse,
+    UsageRecord,
+    UsageTotals,
+    UsageSumOptions,
+    ErasureSelector,
+    ErasureCounts,
+    StoredTenant,
+    SensitiveField,
+    SensitiveValue,
+    ThreadStateEntry,
+    ThreadStateListOptions,
+    ThreadImportBatch,
+    ThreadImportRecord,
+    StorageConfig,
+    Logger,
+} from '@polyglot-llm-gateway/gateway-core';
+import {
+    DualWriteStorage,
+    dualWriteOptions,
+    UNSCOPED_TENANT,
+    ERASED,
+    emptyErasureCounts,
+    matchesErasure,
+    scrubMessage,
+    scrubShadowResult,
+    scrubHTTPResponse,
+    findDuplicateMessage,
 } from '@polyglot-llm-gateway/gateway-core';
 
 // ============================================================================
@@ -115,56 +185,91 @@ export class StaticAuthProvider implements AuthProvider {
 // ============================================================================
 
 /**
- * In-memory storage provider for development.
+ * In-memory storage provider for development and tests.
+ *
+ * Records are copied on save and on return, so callers can't mutate stored
+ * state. Lists are ordered by the requested timestamp with ties broken by
+ * ID, so paging is deterministic.
  */
 export class MemoryStorageProvider implements StorageProvider {
     private readonly conversations = new Map<string, Conversation>();
     private readonly responses = new Map<string, ResponseRecord>();
+    private readonly interactions = new Map<string, InteractionRecord>();
     private readonly events = new Map<string, InteractionEvent[]>();
     private readonly shadowResults = new Map<string, ShadowResult[]>();
-    private readonly threadState = new Map<string, string>();
+    private readonly threadState = new Map<string, ThreadStateEntry>();
+    private readonly threads = new Map<string, StoredThread>();
+    private readonly idempotencyKeys = new Map<string, IdempotencyRecord>();
+    private readonly usage = new Map<string, UsageRecord>();
+    private readonly tenants = new Map<string, StoredTenant>();
+    private readonly threadImports = new Map<string, ThreadImportRecord>();
 
     // Conversations
     async saveConversation(conversation: Conversation): Promise<void> {
-        this.conversations.set(conversation.id, conversation);
+        this.conversations.set(conversation.id, structuredClone(conversation));
     }
 
-    async getConversation(id: string): Promise<Conversation | null> {
-        return this.conversations.get(id) ?? null;
+    async getConversation(id: string, tenantId: string): Promise<Conversation | null> {
+        const conversation = this.conversations.get(id);
+        return conversation && ownedBy(conversation.tenantId, tenantId) ? structuredClone(conversation) : null;
     }
 
     async listConversations(tenantId: string, options?: ListOptions): Promise<Conversation[]> {
-        const limit = options?.limit ?? 50;
-        return Array.from(this.conversations.values())
-            .filter((c) => c.tenantId === tenantId)
-            .sort((a, b) => b.updatedAt.getTime() - a.updatedAt.getTime())
-            .slice(0, limit);
+        const owned = Array.from(this.conversations.values()).filter((c) => c.tenantId === tenantId);
+        return paginate(owned, options).map((c) => structuredClone(c));
+    }
+
+    async deleteConversation(id: string): Promise<void> {
+        this.conversations.delete(id);
     }
 
     // Responses
     async saveResponse(response: ResponseRecord): Promise<void> {
-        this.responses.set(response.id, response);
+        this.responses.set(response.id, structuredClone(response));
     }
 
-    async getResponse(id: string): Promise<ResponseRecord | null> {
-        return this.responses.get(id) ?? null;
+    async getResponse(id: string, tenantId: string): Promise<ResponseRecord | null> {
+        const response = this.responses.get(id);
+        return response && ownedBy(response.tenantId, tenantId) ? structuredClone(response) : null;
     }
 
     async listResponses(tenantId: string, options?: ListOptions): Promise<ResponseRecord[]> {
-        const limit = options?.limit ?? 50;
-        return Array.from(this.responses.values())
-            .filter((r) => r.tenantId === tenantId)
-            .sort((a, b) => b.updatedAt.getTime() - a.updatedAt.getTime())
-            .slice(0, limit);
+        const owned = Array.from(this.responses.values()).filter((r) => r.tenantId === tenantId);
+        return paginate(owned, options).map((r) => structuredClone(r));
+    }
+
+    async updateResponse(id: string, updates: Partial<ResponseRecord>): Promise<void> {
+        const response = this.responses.get(id);
+        if (!response) return;
+
+        // The ID and owner are fixed
+        const { id: _, tenantId: __, ...changes } = structuredClone(updates);
+        this.responses.set(id, { ...response, ...changes, updatedAt: changes.updatedAt ?? new Date() });
+    }
+
+    async deleteResponse(id: string): Promise<void> {
+        this.responses.delete(id);
     }
 
     // Interactions
     async listInteractions(options?: InteractionListOptions): Promise<InteractionSummary[]> {
-        const limit = options?.limit ?? 50;
+        return paginate(this.interactionSummaries(options), options);
+    }
+
+    async getInteractionCount(options?: InteractionListOptions): Promise<number> {
+        return this.interactionSummaries(options).length;
+    }
+
+    private interactionSummaries(options: InteractionListOptions | undefined): InteractionSummary[] {
+        // Requests are listed only when asked for
+        const matches = (type: InteractionSummary['type'], tenantId: string, status?: string) =>
+            (options?.type ? options.type === type : type !== 'request')
+            && (!options?.tenantId || options.tenantId === tenantId)
+            && (!options?.status || options.status === status);
         const all: InteractionSummary[] = [];
 
         for (const c of this.conversations.values()) {
-            if (options?.tenantId && c.tenantId !== options.tenantId) continue;
+            if (!matches('conversation', c.tenantId)) continue;
             all.push({
                 id: c.id,
                 type: 'conversation',
@@ -172,13 +277,13 @@ export class MemoryStorageProvider implements StorageProvider {
                 appName: c.appName,
                 model: c.model,
                 messageCount: c.messages.length,
-                createdAt: c.createdAt,
-                updatedAt: c.updatedAt,
+                createdAt: new Date(c.createdAt),
+                updatedAt: new Date(c.updatedAt),
             });
         }
 
         for (const r of this.responses.values()) {
-            if (options?.tenantId && r.tenantId !== options.tenantId) continue;
+            if (!matches('response', r.tenantId, r.status)) continue;
             all.push({
                 id: r.id,
                 type: 'response',
@@ -186,80 +291,475 @@ export class MemoryStorageProvider implements StorageProvider {
                 appName: r.appName,
                 model: r.model,
                 status: r.status,
-                createdAt: r.createdAt,
-                updatedAt: r.updatedAt,
+                createdAt: new Date(r.createdAt),
+                updatedAt: new Date(r.updatedAt),
             });
         }
 
-        return all
-            .sort((a, b) => b.updatedAt.getTime() - a.updatedAt.getTime())
-            .slice(0, limit);
+        for (const r of this.interactions.values()) {
+            if (!matches('request', r.tenantId, r.status)) continue;
+            all.push({
+                id: r.id,
+                type: 'request',
+                tenantId: r.tenantId,
+                appName: r.appName,
+                model: r.model,
+                provider: r.provider,
+                status: r.status,
+                createdAt: new Date(r.createdAt),
+                updatedAt: new Date(r.updatedAt),
+            });
+        }
+
+        return all;
     }
 
-    async getInteractionCount(options?: InteractionListOptions): Promise<number> {
-        let count = 0;
-        for (const c of this.conversations.values()) {
-            if (!options?.tenantId || c.tenantId === options.tenantId) count++;
-        }
-        for (const r of this.responses.values()) {
-            if (!options?.tenantId || r.tenantId === options.tenantId) count++;
+    async recordInteraction(record: InteractionRecord): Promise<void> {
+        this.interactions.set(record.id, structuredClone(record));
+    }
+
+    async updateInteraction(id: string, update: InteractionUpdate): Promise<boolean> {
+        const record = this.interactions.get(id);
+        if (!record) return false;
+        this.interactions.set(id, { ...record, ...structuredClone(update) });
+        return true;
+    }
+
+    async getInteraction(id: string, tenantId: string): Promise<InteractionRecord | null> {
+        const record = this.interactions.get(id);
+        return record && ownedBy(record.tenantId, tenantId) ? structuredClone(record) : null;
+    }
+
+    async abandonInteractions(before: Date, updatedAt: Date): Promise<number> {
+        let abandoned = 0;
+        for (const record of this.interactions.values()) {
+            if (record.status === 'in_progress' && record.createdAt < before) {
+                record.status = 'abandoned';
+                record.updatedAt = updatedAt;
+                abandoned++;
+            }
         }
-        return count;
+        return abandoned;
     }
 
     async saveEvent(event: InteractionEvent): Promise<void> {
         const existing = this.events.get(event.interactionId) ?? [];
-        existing.push(event);
+        existing.push(structuredClone(event));
         this.events.set(event.interactionId, existing);
     }
 
-    async getEvents(interactionId: string): Promise<InteractionEvent[]> {
-        return this.events.get(interactionId) ?? [];
+    async getEvents(interactionId: string, tenantId: string): Promise<InteractionEvent[]> {
+        if (!this.ownsInteraction(interactionId, tenantId)) return [];
+        // Stable sort keeps insertion order for equal timestamps
+        return (this.events.get(interactionId) ?? [])
+            .map((e) => structuredClone(e))
+            .sort((a, b) => a.timestamp.getTime() - b.timestamp.getTime());
     }
 
     // Shadow Results
     async saveShadowResult(result: ShadowResult): Promise<void> {
         const existing = this.shadowResults.get(result.interactionId) ?? [];
-        existing.push(result);
+        existing.push(structuredClone(result));
         this.shadowResults.set(result.interactionId, existing);
     }
 
-    async getShadowResults(interactionId: string): Promise<ShadowResult[]> {
-        return this.shadowResults.get(interactionId) ?? [];
+    async getShadowResults(interactionId: string, tenantId: string): Promise<ShadowResult[]> {
+        if (!this.ownsInteraction(interactionId, tenantId)) return [];
+        return (this.shadowResults.get(interactionId) ?? [])
+            .map((r) => structuredClone(r))
+            .sort((a, b) => a.createdAt.getTime() - b.createdAt.getTime());
     }
 
-    async getShadowResult(id: string): Promise<ShadowResult | null> {
+    async getShadowResult(id: string, tenantId: string): Promise<ShadowResult | null> {
         for (const results of this.shadowResults.values()) {
             const result = results.find((r) => r.id === id);
-            if (result) return result;
+            if (result) {
+                return this.ownsInteraction(result.interactionId, tenantId) ? structuredClone(result) : null;
+            }
         }
         return null;
     }
 
     async listDivergentShadowResults(options?: DivergenceListOptions): Promise<ShadowResult[]> {
-        const limit = options?.limit ?? 50;
         const all: ShadowResult[] = [];
 
         for (const results of this.shadowResults.values()) {
             for (const result of results) {
+                if (result.divergences.length === 0) continue;
                 if (options?.structuralOnly && !result.hasStructuralDivergence) continue;
+                if (options?.tenantId && !this.ownsInteraction(result.interactionId, options.tenantId)) continue;
                 all.push(result);
             }
         }
 
-        return all
-            .sort((a, b) => b.createdAt.getTime() - a.createdAt.getTime())
-            .slice(0, limit);
+        return paginate(all, { ...options, orderBy: 'createdAt' }).map((r) => structuredClone(r));
+    }
+
+    /**
+     * Events and shadow results carry no tenant of their own; ownership
+     * follows the conversation or response they were recorded against.
+     */
+    private ownsInteraction(interactionId: string, tenantId: string): boolean {
+        if (tenantId === UNSCOPED_TENANT) return true;
+        const owner = this.conversations.get(interactionId)?.tenantId
+            ?? this.responses.get(interactionId)?.tenantId
+            ?? this.interactions.get(interactionId)?.tenantId;
+        return owner !== undefined && ownedBy(owner, tenantId);
     }
 
     // Thread State
     async setThreadState(threadKey: string, responseId: string): Promise<void> {
-        this.threadState.set(threadKey, responseId);
+        const keyHash = createHash('sha256').update(threadKey).digest('hex');
+        this.threadState.set(threadKey, { threadKey, keyHash, responseId, updatedAt: new Date() });
     }
 
     async getThreadState(threadKey: string): Promise<string | null> {
-        return this.threadState.get(threadKey) ?? null;
+        return this.threadState.get(threadKey)?.responseId ?? null;
+    }
+
+    async listThreadState(options?: ThreadStateListOptions): Promise<ThreadStateEntry[]> {
+        const cursor = options?.cursor ?? '';
+        return Array.from(this.threadState.values())
+            .filter((e) => e.keyHash > cursor)
+            .sort((a, b) => compareIds(a.keyHash, b.keyHash))
+            .slice(0, options?.limit ?? 50)
+            .map((e) => structuredClone(e));
+    }
+
+    async findThreadState(keyHash: string): Promise<ThreadStateEntry | null> {
+        for (const entry of this.threadState.values()) {
+            if (entry.keyHash === keyHash) return structuredClone(entry);
+        }
+        return null;
+    }
+
+    async deleteThreadState(threadKey: string): Promise<boolean> {
+        return this.threadState.delete(threadKey);
+    }
+
+    async purgeThreadState(before: Date): Promise<number> {
+        let purged = 0;
+        for (const [threadKey, entry] of this.threadState) {
+            if (entry.updatedAt < before) {
+                this.threadState.delete(threadKey);
+                purged++;
+            }
+        }
+        return purged;
+    }
+
+    // Threads
+    async createThread(thread: StoredThread): Promise<void> {
+        this.threads.set(thread.id, structuredClone(thread));
+    }
+
+    async getThread(id: string, tenantId: string): Promise<StoredThread | null> {
+        const thread = this.threads.get(id);
+        return thread && ownedBy(thread.tenantId, tenantId) ? structuredClone(thread) : null;
+    }
+
+    async addMessage(threadId: string, message: StoredMessage, options?: AddMessageOptions): Promise<AddMessageResult | null> {
+        const thread = this.threads.get(threadId);
+        if (!thread) return null;
+
+        const duplicate = findDuplicateMessage(thread.messages, message, options?.dedupWindow);
+        if (duplicate) {
+            return { id: duplicate.message.id, duplicate: duplicate.reason };
+        }
+
+        thread.messages.push(structuredClone(message));
+        thread.updatedAt = new Date();
+        return { id: message.id };
+    }
+
+    async listMessages(threadId: string, options?: ListOptions): Promise<StoredMessage[]> {
+        const messages = this.threads.get(threadId)?.messages ?? [];
+        const offset = options?.offset ?? 0;
+        const ordered = options?.order === 'desc' ? [...messages].reverse() : messages;
+        return ordered
+            .slice(offset, offset + (options?.limit ?? ordered.length))
+            .map((m) => structuredClone(m));
+    }
+
+    async deleteThread(id: string): Promise<void> {
+        this.threads.delete(id);
+    }
+
+    // Idempotency Keys
+    async claimIdempotencyKey(record: IdempotencyRecord): Promise<IdempotencyRecord | null> {
+        const existing = await this.getIdempotencyRecord(record.tenantId, record.key);
+        if (existing) return existing;
+
+        this.idempotencyKeys.set(`${record.tenantId}:${record.key}`, structuredClone(record));
+        return null;
+    }
+
+    async getIdempotencyRecord(tenantId: string, key: string): Promise<IdempotencyRecord | null> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        if (!record) return null;
+
+        if (record.expiresAt.getTime() <= Date.now()) {
+            this.idempotencyKeys.delete(`${tenantId}:${key}`);
+            return null;
+        }
+        return structuredClone(record);
+    }
+
+    async renewIdempotencyKey(tenantId: string, key: string, interactionId: string, expiresAt: Date): Promise<void> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        if (record?.interactionId !== interactionId || record.status !== 'in_progress') return;
+
+        record.expiresAt = expiresAt;
     }
+
+    async completeIdempotencyKey(tenantId: string, key: string, response: StoredHTTPResponse, expiresAt: Date): Promise<void> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        if (!record) return;
+
+        record.status = 'completed';
+        record.response = structuredClone(response);
+        record.expiresAt = expiresAt;
+    }
+
+    async releaseIdempotencyKey(tenantId: string, key: string, interactionId: string): Promise<void> {
+        if (this.idempotencyKeys.get(`${tenantId}:${key}`)?.interactionId !== interactionId) return;
+
+        this.idempotencyKeys.delete(`${tenantId}:${key}`);
+    }
+
+    // Usage
+    async recordUsage(record: UsageRecord): Promise<void> {
+        if (!this.usage.has(record.interactionId)) {
+            this.usage.set(record.interactionId, structuredClone(record));
+        }
+    }
+
+    async sumUsage(tenantId: string, since: Date, options: UsageSumOptions = {}): Promise<UsageTotals> {
+        const totals: UsageTotals = { tokens: 0, costUsd: 0, requests: 0 };
+        for (const record of this.usage.values()) {
+            if (
+                record.tenantId === tenantId
+                && record.createdAt >= since
+                && (!options.before || record.createdAt < options.before)
+                && !options.excluding?.includes(record.interactionId)
+            ) {
+                totals.tokens += record.totalTokens;
+                totals.costUsd += record.costUsd;
+                totals.requests++;
+            }
+        }
+        return totals;
+    }
+
+    // Erasure
+    async eraseInteractions(selector: ErasureSelector): Promise<ErasureCounts> {
+        const counts = emptyErasureCounts();
+        const erased = new Set<string>();
+
+        for (const conversation of this.conversations.values()) {
+            if (!matchesErasure(conversation, selector)) continue;
+            conversation.messages = conversation.messages.map(scrubMessage);
+            conversation.metadata = undefined;
+            counts.conversations++;
+            counts.messages += conversation.messages.length;
+            erased.add(conversation.id);
+        }
+
+        for (const response of this.responses.values()) {
+            if (!matchesErasure(response, selector)) continue;
+            response.request = ERASED;
+            response.response = ERASED;
+            if (response.error !== undefined) response.error = ERASED;
+            response.metadata = undefined;
+            counts.responses++;
+            erased.add(response.id);
+        }
+
+        for (const thread of this.threads.values()) {
+            if (!matchesErasure(thread, selector)) continue;
+            thread.messages = thread.messages.map(scrubMessage);
+            thread.metadata = undefined;
+            counts.threads++;
+            counts.messages += thread.messages.length;
+        }
+
+        // A bare ID selector also reaches events and shadows recorded under
+        // a gateway interaction ID with no stored conversation or response
+        if (!selector.tenantId && !selector.metadata && !selector.threadKey) {
+            for (const id of selector.interactionIds ?? []) erased.add(id);
+        }
+
+        // Interaction records carry their tenant, so an ID selector finds
+        // them directly; the rest follow their conversation or response
+        for (const record of this.interactions.values()) {
+            if (matchesErasure(record, selector)) erased.add(record.id);
+        }
+        for (const id of erased) {
+            const record = this.interactions.get(id);
+            if (record?.request === undefined) continue;
+            record.request = ERASED;
+            counts.interactions++;
+        }
+
+        for (const id of erased) {
+            const events = this.events.get(id) ?? [];
+            for (const event of events) {
+                event.payload = ERASED;
+            }
+            counts.events += events.length;
+
+            const shadows = (this.shadowResults.get(id) ?? []).map(scrubShadowResult);
+            if (shadows.length > 0) this.shadowResults.set(id, shadows);
+            counts.shadowResults += shadows.length;
+        }
+
+        for (const record of this.idempotencyKeys.values()) {
+            if (!erased.has(record.interactionId) || !record.response) continue;
+            if (selector.tenantId && record.tenantId !== selector.tenantId) continue;
+            record.response = scrubHTTPResponse(record.response);
+            counts.idempotencyKeys++;
+        }
+
+        if (selector.threadKey) {
+            this.threadState.delete(selector.threadKey);
+        }
+        return counts;
+    }
+
+    // Sensitive Values
+    async scanSensitiveValues(field: SensitiveField, after: string | undefined, limit: number): Promise<SensitiveValue[]> {
+        return this.sensitiveSlots(field)
+            .filter((slot) => (after === undefined || compareIds(slot.id, after) > 0) && slot.get() != null)
+            .sort((a, b) => compareIds(a.id, b.id))
+            .slice(0, limit)
+            .map((slot) => ({ field, id: slot.id, value: structuredClone(slot.get()) }));
+    }
+
+    async updateSensitiveValue(value: SensitiveValue): Promise<void> {
+        this.sensitiveSlots(value.field).find((slot) => slot.id === value.id)?.set(structuredClone(value.value));
+    }
+
+    /**
+     * Every stored value of a sensitive field, addressed by row ID.
+     */
+    private sensitiveSlots(field: SensitiveField): { id: string; get: () => unknown; set: (value: unknown) => void }[] {
+        switch (field) {
+            case 'responses.request':
+            case 'responses.response':
+            case 'responses.error': {
+                const column = field.slice('responses.'.length) as 'request' | 'response' | 'error';
+                return Array.from(this.responses.values(), (r) => ({
+                    id: r.id,
+                    get: () => r[column],
+                    set: (value: unknown) => void (r[column] = value),
+                }));
+            }
+            case 'messages.content':
+                return [...this.conversations.values(), ...this.threads.values()].flatMap((c) => c.messages.map((m) => ({
+                    id: m.id,
+                    get: () => m.content,
+                    set: (value: unknown) => void (m.content = value as string),
+                })));
+            case 'interaction_events.payload':
+                return Array.from(this.events.values()).flat().map((e) => ({
+                    id: e.id,
+                    get: () => e.payload,
+                    set: (value: unknown) => void (e.payload = value),
+                }));
+            case 'interactions.request':
+                return Array.from(this.interactions.values(), (r) => ({
+                    id: r.id,
+                    get: () => r.request,
+                    set: (value: unknown) => void (r.request = value as string),
+                }));
+        }
+    }
+
+    // Tenants
+    async saveTenant(tenant: StoredTenant): Promise<void> {
+        this.tenants.set(tenant.id, structuredClone(tenant));
+    }
+
+    async getTenant(id: string): Promise<StoredTenant | null> {
+        const tenant = this.tenants.get(id);
+        return tenant ? structuredClone(tenant) : null;
+    }
+
+    async listTenants(): Promise<StoredTenant[]> {
+        return Array.from(this.tenants.values())
+            .sort((a, b) => compareIds(a.id, b.id))
+            .map((t) => structuredClone(t));
+    }
+
+    // Thread Transfer
+    async importThreadBatch(batch: ThreadImportBatch): Promise<void> {
+        for (const conversation of batch.conversations) {
+            this.conversations.set(conversation.id, structuredClone(conversation));
+        }
+        for (const response of batch.responses) {
+            this.responses.set(response.id, structuredClone(response));
+        }
+        for (const { threadKey, responseId, updatedAt } of batch.threadState) {
+            const keyHash = createHash('sha256').update(threadKey).digest('hex');
+            this.threadState.set(threadKey, { threadKey, keyHash, responseId, updatedAt: new Date(updatedAt) });
+        }
+    }
+
+    async getThreadImport(checksum: string): Promise<ThreadImportRecord | null> {
+        const record = this.threadImports.get(checksum);
+        return record ? structuredClone(record) : null;
+    }
+
+    async recordThreadImport(record: ThreadImportRecord): Promise<void> {
+        if (!this.threadImports.has(record.checksum)) {
+            this.threadImports.set(record.checksum, structuredClone(record));
+        }
+    }
+}
+
+/**
+ * Creates the storage a config asks for. Memory is the only store built
+ * in, so every other type gets one; "dual" builds its primary and
+ * secondary that way and writes to both.
+ */
+export function createNodeStorage(config?: StorageConfig, logger?: Logger): StorageProvider {
+    if (config?.type === 'dual' && config.primary && config.secondary) {
+        const primary = createNodeStorage(config.primary, logger);
+        const secondary = createNodeStorage(config.secondary, logger);
+        return new DualWriteStorage(primary, secondary, dualWriteOptions(config), logger).storage;
+    }
+    return new MemoryStorageProvider();
+}
+
+/**
+ * Sorts by `orderBy` ("createdAt" or the default "updatedAt"), newest first
+ * unless `order` is "asc", breaking ties by ID, then applies offset and limit
+ * (default 50).
+ */
+function paginate<T extends { id: string; createdAt: Date; updatedAt?: Date }>(
+    items: T[],
+    options: ListOptions | undefined,
+): T[] {
+    const key = options?.orderBy === 'createdAt' ? 'createdAt' : 'updatedAt';
+    const direction = options?.order === 'asc' ? 1 : -1;
+    const time = (item: T) => (item[key] ?? item.createdAt).getTime();
+    const offset = options?.offset ?? 0;
+
+    return [...items]
+        .sort((a, b) => direction * (time(a) - time(b) || compareIds(a.id, b.id)))
+        .slice(offset, offset + (options?.limit ?? 50));
+}
+
+/** Compares IDs by code unit, as SQL's default collation does. */
+function compareIds(a: string, b: string): number {
+    return a < b ? -1 : a > b ? 1 : 0;
+}
+
+/**
+ * Reports whether a record owned by `owner` is visible to `tenantId`.
+ */
+function ownedBy(owner: string, tenantId: string): boolean {
+    return tenantId === UNSCOPED_TENANT || owner === tenantId;
 }
 
 // ============================================================================
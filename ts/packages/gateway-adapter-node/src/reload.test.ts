@@ -0,0 +1,78 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { mkdtempSync, rmSync, writeFileSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { Gateway, createProviderRegistry } from '@polyglot-llm-gateway/gateway-core';
+import { FileConfigProvider } from './config';
+
+function configFile(template: string): string {
+    return `
+apps:
+  - name: chat
+    frontdoor: openai
+    path: /v1
+providers:
+  - name: mock
+    type: mock
+    api_key: sk-mock
+routing:
+  default_provider: mock
+templates:
+  support:
+    text: "${template}"
+`;
+}
+
+describe('Config watching', () => {
+    let dir: string | undefined;
+    let gateway: Gateway | undefined;
+
+    afterEach(() => {
+        gateway?.stopWatching();
+        gateway = undefined;
+        if (dir) rmSync(dir, { recursive: true, force: true });
+        dir = undefined;
+    });
+
+    it('should serve edited prompt templates once the watcher reloads', async () => {
+        dir = mkdtempSync(join(tmpdir(), 'gateway-reload-'));
+        const path = join(dir, 'config.yaml');
+        writeFileSync(path, configFile('You answer billing questions.'));
+
+        const provider = {
+            name: 'mock',
+            apiType: 'openai' as const,
+            complete: vi.fn(async (req: { model: string; systemPrompt?: string }) => ({
+                id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'openai' as const,
+                choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content: 'Hi' } }],
+                usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            })),
+            stream: vi.fn(),
+        };
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', () => provider);
+        gateway = new Gateway({
+            config: new FileConfigProvider({ path, pollInterval: 10, env: {} }),
+            auth: { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null },
+            providerRegistry,
+        });
+        await gateway.reload();
+        await gateway.startWatching();
+
+        const systemPrompt = async () => {
+            const response = await gateway!.fetch(new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                headers: { Authorization: 'Bearer acme', 'Content-Type': 'application/json' },
+                body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], template: { name: 'support' } }),
+            }));
+            expect(response.status).toBe(200);
+            return provider.complete.mock.calls.at(-1)![0].systemPrompt;
+        };
+        expect(await systemPrompt()).toBe('You answer billing questions.');
+
+        writeFileSync(path, configFile('You answer shipping questions.'));
+        await vi.waitFor(async () => {
+            expect(await systemPrompt()).toBe('You answer shipping questions.');
+        }, { timeout: 5000, interval: 50 });
+    });
+});
@@ -0,0 +1,98 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { GatewayServer } from './server';
+
+const gateway = {
+    async fetch(request: Request) {
+        return Response.json({ plane: 'data', path: new URL(request.url).pathname });
+    },
+};
+
+const admin = {
+    async handle(request: Request) {
+        return Response.json({ plane: 'admin', path: new URL(request.url).pathname });
+    },
+};
+
+let server: GatewayServer | undefined;
+
+afterEach(async () => {
+    await server?.close();
+    server = undefined;
+});
+
+describe('GatewayServer', () => {
+    it('should mount /admin on the data plane when no admin listener is set', async () => {
+        server = new GatewayServer({ gateway, admin });
+        const { data, admin: adminAddress } = await server.listen(0, '127.0.0.1');
+
+        const res = await fetch(`http://127.0.0.1:${data.port}/admin/api/stats`);
+
+        expect(adminAddress).toBeUndefined();
+        expect(await res.json()).toEqual({ plane: 'admin', path: '/api/stats' });
+    });
+
+    it('should serve admin only from its own listener in split mode', async () => {
+        server = new GatewayServer({ gateway, admin, adminListener: { port: 0 } });
+        const addresses = await server.listen(0, '127.0.0.1');
+        const dataUrl = `http://127.0.0.1:${addresses.data.port}`;
+        const adminUrl = `http://127.0.0.1:${addresses.admin!.port}`;
+
+        expect(addresses.admin!.address).toBe('127.0.0.1');
+        expect((await fetch(`${dataUrl}/admin/api/stats`)).status).toBe(404);
+        expect(await (await fetch(`${dataUrl}/v1/models`)).json()).toEqual({ plane: 'data', path: '/v1/models' });
+
+        expect(await (await fetch(`${adminUrl}/admin/api/stats`)).json()).toEqual({ plane: 'admin', path: '/api/stats' });
+        expect((await fetch(`${adminUrl}/healthz`)).status).toBe(200);
+        expect((await fetch(`${adminUrl}/v1/models`)).status).toBe(404);
+    });
+
+    it('should abort the request signal when the client disconnects', async () => {
+        let abortedAt = 0;
+        const slow = {
+            async fetch(request: Request) {
+                await new Promise((resolve) => request.signal.addEventListener('abort', resolve, { once: true }));
+                abortedAt = Date.now();
+                return new Response(null, { status: 499 });
+            },
+        };
+        server = new GatewayServer({ gateway: slow, admin });
+        const { data } = await server.listen(0, '127.0.0.1');
+
+        const disconnectedAt = Date.now() + 100;
+        await expect(fetch(`http://127.0.0.1:${data.port}/v1/chat/completions`, {
+            method: 'POST',
+            body: '{}',
+            signal: AbortSignal.timeout(100),
+        })).rejects.toThrow();
+
+        await expect.poll(() => abortedAt).toBeGreaterThan(0);
+        expect(abortedAt - disconnectedAt).toBeLessThan(500);
+    });
+
+    it('should append the connection address to X-Forwarded-For', async () => {
+        const forwarded = {
+            async fetch(request: Request) {
+                return Response.json({ forwarded: request.headers.get('x-forwarded-for') });
+            },
+        };
+        server = new GatewayServer({ gateway: forwarded, admin });
+        const { data } = await server.listen(0, '127.0.0.1');
+
+        const res = await fetch(`http://127.0.0.1:${data.port}/v1/models`, {
+            headers: { 'X-Forwarded-For': '203.0.113.9' },
+        });
+
+        expect(await res.json()).toEqual({ forwarded: '203.0.113.9, 127.0.0.1' });
+    });
+
+    it('should close both listeners on shutdown', async () => {
+        server = new GatewayServer({ gateway, admin, adminListener: { port: 0 } });
+        await server.listen(0, '127.0.0.1');
+
+        await server.close();
+
+        expect(server.data.listening).toBe(false);
+        expect(server.admin!.listening).toBe(false);
+        server = undefined;
+    });
+});
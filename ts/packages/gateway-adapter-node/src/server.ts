@@ -0,0 +1,295 @@
+/**
+ * Node.js HTTP listeners for the gateway data plane and admin control plane.
+ *
+ * By default the admin API is mounted under /admin on the data plane
+ * listener. With an admin listener configured it is served only from a
+ * second server (typically bound to loopback) so the control plane can be
+ * firewalled separately; /admin then 404s on the data plane.
+ *
+ * @module server
+ */
+
+import { createServer, type IncomingMessage, type Server, type ServerResponse } from 'node:http';
+import type { AddressInfo } from 'node:net';
+import type { AdminListenerConfig } from '@polyglot-llm-gateway/gateway-core';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Path prefix of the admin API. */
+export const ADMIN_PREFIX = '/admin';
+
+/** Default bind address for a separate admin listener. */
+export const DEFAULT_ADMIN_BIND = '127.0.0.1';
+
+/**
+ * Options for the gateway server.
+ */
+export interface GatewayServerOptions {
+    /** Data plane handler (typically the Gateway). */
+    gateway: { fetch(request: Request): Promise<Response>; stopWatching?(): void };
+
+    /** Admin API handler. */
+    admin?: { handle(request: Request): Promise<Response> } | undefined;
+
+    /** Serve the admin API on its own listener instead of under /admin. */
+    adminListener?: AdminListenerConfig | undefined;
+}
+
+/**
+ * Bound addresses of the running listeners.
+ */
+export interface GatewayServerAddresses {
+    /** Data plane address. */
+    data: AddressInfo;
+
+    /** Admin address (split mode only). */
+    admin?: AddressInfo | undefined;
+}
+
+// ============================================================================
+// Gateway Server
+// ============================================================================
+
+/**
+ * Runs the data plane listener and, in split mode, the admin listener,
+ * with a shared shutdown path.
+ */
+export class GatewayServer {
+    /** Data plane server. */
+    readonly data: Server;
+
+    /** Admin server (split mode only). */
+    readonly admin?: Server;
+
+    private readonly options: GatewayServerOptions;
+
+    constructor(options: GatewayServerOptions) {
+        this.options = options;
+        this.data = createServer((req, res) => {
+            void serve(req, res, (request) => this.dispatchData(request));
+        });
+        if (options.admin && options.adminListener) {
+            this.admin = createServer((req, res) => {
+                void serve(req, res, (request) => this.dispatchAdmin(request));
+            });
+        }
+    }
+
+    /** Whether the admin API has its own listener. */
+    get split(): boolean {
+        return this.admin !== undefined;
+    }
+
+    /**
+     * Starts the listeners. The admin listener uses its configured port and
+     * bind address; pass port 0 in config for an ephemeral port.
+     */
+    async listen(port: number, host?: string): Promise<GatewayServerAddresses> {
+        const data = await listen(this.data, port, host);
+        if (!this.admin) {
+            return { data };
+        }
+
+        const { port: adminPort, bind } = this.options.adminListener!;
+        const admin = await listen(this.admin, adminPort, bind ?? DEFAULT_ADMIN_BIND);
+        return { data, admin };
+    }
+
+    /**
+     * Stops accepting connections on both listeners, waits for in-flight
+     * requests to finish, and stops the config watcher.
+     */
+    async close(): Promise<void> {
+        this.options.gateway.stopWatching?.();
+        await Promise.all([
+            close(this.data),
+            this.admin ? close(this.admin) : Promise.resolve(),
+        ]);
+    }
+
+    private dispatchData(request: Request): Promise<Response> {
+        const url = new URL(request.url);
+        if (isAdminPath(url.pathname)) {
+            if (this.split || !this.options.admin) {
+                return Promise.resolve(notFound());
+            }
+            return this.options.admin.handle(stripAdminPrefix(request, url));
+        }
+        return this.options.gateway.fetch(request);
+    }
+
+    private dispatchAdmin(request: Request): Promise<Response> {
+        const url = new URL(request.url);
+        if (url.pathname === '/healthz' || url.pathname === '/health') {
+            return Promise.resolve(Response.json({ status: 'ok' }));
+        }
+        if (url.pathname === '/readyz') {
+            return this.options.gateway.fetch(request);
+        }
+        if (!isAdminPath(url.pathname)) {
+            return Promise.resolve(notFound());
+        }
+        return this.options.admin!.handle(stripAdminPrefix(request, url));
+    }
+}
+
+// ============================================================================
+// Node <-> Web Conversion
+// ============================================================================
+
+/**
+ * Converts a Node request to a Web Request, runs the handler, and streams
+ * the Web Response back.
+ */
+async function serve(
+    req: IncomingMessage,
+    res: ServerResponse,
+    handler: (request: Request) => Promise<Response>,
+): Promise<void> {
+    try {
+        const response = await handler(await toWebRequest(req, res));
+        await writeWebResponse(res, response);
+    } catch (error) {
+        console.error('Request error:', error);
+        if (!res.headersSent) {
+            res.statusCode = 500;
+            res.setHeader('Content-Type', 'application/json');
+        }
+        res.end(JSON.stringify({ error: { message: 'Internal server error' } }));
+    }
+}
+
+/**
+ * Converts a Node request to a Web Request, with the connection's address
+ * appended to X-Forwarded-For. The body is streamed, not buffered, so the
+ * gateway can refuse one over its size limit without reading it all. With the response given, the request's signal aborts if
+ * the client disconnects before the response is finished, so provider
+ * calls made for it are cancelled too.
+ */
+export async function toWebRequest(req: IncomingMessage, res?: ServerResponse): Promise<Request> {
+    const url = `http://${req.headers.host ?? 'localhost'}${req.url ?? '/'}`;
+    const headers = new Headers();
+    for (const [key, value] of Object.entries(req.headers)) {
+        if (value) {
+            headers.set(key, Array.isArray(value) ? value.join(', ') : value);
+        }
+    }
+    // The connection's address goes last, as a proxy would add it; hops
+    // before it came from the client
+    const address = req.socket?.remoteAddress;
+    if (address) {
+        const forwarded = headers.get('x-forwarded-for');
+        headers.set('x-forwarded-for', forwarded ? `${forwarded}, ${address}` : address);
+    }
+
+    const body = req.method !== 'GET' && req.method !== 'HEAD' ? streamBody(req) : undefined;
+
+    const controller = new AbortController();
+    res?.on('close', () => {
+        if (!res.writableFinished) {
+            controller.abort(new DOMException('Client disconnected', 'AbortError'));
+        }
+    });
+
+    return new Request(url, {
+        method: req.method ?? 'GET',
+        headers,
+        signal: controller.signal,
+        ...(body !== undefined ? { body, duplex: 'half' } : {}),
+    } as RequestInit);
+}
+
+/**
+ * Streams a Node request's body, reading as it is pulled. Cancelling it
+ * (a body refused part way) discards the rest rather than destroying the
+ * socket, so the refusal can still be sent.
+ */
+function streamBody(req: IncomingMessage): ReadableStream<Uint8Array> {
+    const onData = (chunk: Buffer, controller: ReadableStreamDefaultController<Uint8Array>): void => {
+        controller.enqueue(new Uint8Array(chunk.buffer, chunk.byteOffset, chunk.byteLength));
+        if ((controller.desiredSize ?? 0) <= 0) {
+            req.pause();
+        }
+    };
+    let listeners: { data: (chunk: Buffer) => void; end: () => void } | undefined;
+    return new ReadableStream<Uint8Array>({
+        start(controller) {
+            listeners = { data: (chunk) => onData(chunk, controller), end: () => controller.close() };
+            req.on('data', listeners.data);
+            req.once('end', listeners.end);
+            req.once('error', (error) => controller.error(error));
+            req.pause();
+        },
+        pull() {
+            req.resume();
+        },
+        cancel() {
+            req.off('data', listeners!.data);
+            req.off('end', listeners!.end);
+            req.resume();
+        },
+    });
+}
+
+/**
+ * Writes a Web Response to a Node response, flushing body chunks as they
+ * arrive so SSE streams are not buffered.
+ */
+export async function writeWebResponse(res: ServerResponse, response: Response): Promise<void> {
+    res.statusCode = response.status;
+    response.headers.forEach((value, key) => {
+        res.setHeader(key, value);
+    });
+
+    if (!response.body) {
+        res.end();
+        return;
+    }
+
+    const reader = response.body.getReader();
+    res.on('close', () => {
+        reader.cancel().catch(() => undefined);
+    });
+    while (true) {
+        const { done, value } = await reader.read();
+        if (done) break;
+        res.write(value);
+    }
+    res.end();
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+function isAdminPath(path: string): boolean {
+    return path === ADMIN_PREFIX || path.startsWith(`${ADMIN_PREFIX}/`);
+}
+
+function stripAdminPrefix(request: Request, url: URL): Request {
+    url.pathname = url.pathname.slice(ADMIN_PREFIX.length) || '/';
+    return new Request(url, request);
+}
+
+function notFound(): Response {
+    return Response.json({ error: { message: 'Not found', type: 'not_found' } }, { status: 404 });
+}
+
+function listen(server: Server, port: number, host?: string): Promise<AddressInfo> {
+    return new Promise((resolve, reject) => {
+        server.once('error', reject);
+        server.listen(port, host, () => {
+            server.off('error', reject);
+            resolve(server.address() as AddressInfo);
+        });
+    });
+}
+
+function close(server: Server): Promise<void> {
+    return new Promise((resolve, reject) => {
+        server.close((error) => (error ? reject(error) : resolve()));
+        server.closeIdleConnections();
+    });
+}
@@ -0,0 +1,97 @@
+import { describe, it, expect, vi } from 'vitest';
+import { mkdtempSync, readdirSync, readFileSync, writeFileSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import {
+    WriteSpill,
+    SpillFullError,
+    encodeSpillFrame,
+    decodeSpillFrames,
+    type UsageRecord,
+} from '@polyglot-llm-gateway/gateway-core';
+import { FileSpillStorage } from './spill';
+import { MemoryStorageProvider } from './index';
+
+const frame = (text: string) => encodeSpillFrame(new TextEncoder().encode(text));
+const spillDir = () => mkdtempSync(join(tmpdir(), 'spill-'));
+
+const usage = (interactionId: string): UsageRecord => ({
+    tenantId: 'acme',
+    interactionId,
+    model: 'gpt-4o',
+    totalTokens: 15,
+    costUsd: 0.01,
+    createdAt: new Date('2025-01-02T08:00:00Z'),
+});
+
+describe('FileSpillStorage', () => {
+    it('should rotate the active file at the segment size and list segments oldest first', async () => {
+        let now = 1_700_000_000_000;
+        const storage = new FileSpillStorage({ dir: spillDir(), segmentBytes: 40, clock: () => now++ });
+
+        for (const text of ['first record', 'second record', 'third record']) {
+            await storage.append(frame(text));
+        }
+        await storage.rotate();
+
+        const segments = await storage.segments();
+        expect(segments).toHaveLength(3);
+        const decoded = await Promise.all(segments.map(async (s) => decodeSpillFrames(await storage.read(s))));
+        expect(decoded.map((d) => new TextDecoder().decode(d.payloads[0]))).toEqual(['first record', 'second record', 'third record']);
+    });
+
+    it('should refuse appends past the size cap until segments are removed', async () => {
+        const storage = new FileSpillStorage({ dir: spillDir(), maxBytes: 50 });
+
+        await storage.append(frame('x'.repeat(30)));
+        await expect(storage.append(frame('y'.repeat(30)))).rejects.toBeInstanceOf(SpillFullError);
+
+        await storage.rotate();
+        await storage.remove((await storage.segments())[0]!);
+        await expect(storage.append(frame('y'.repeat(30)))).resolves.toBeUndefined();
+    });
+
+    it('should seal an active file left by a crash instead of appending after its partial frame', async () => {
+        const dir = spillDir();
+        const whole = frame('before the crash');
+        const cut = frame('cut short');
+        writeFileSync(join(dir, 'active.spill'), Buffer.concat([whole, cut.subarray(0, cut.length - 3)]));
+
+        const storage = new FileSpillStorage({ dir });
+        await storage.append(frame('after the restart'));
+        await storage.rotate();
+
+        const segments = await storage.segments();
+        expect(segments).toHaveLength(2);
+        const crashed = decodeSpillFrames(await storage.read(segments[0]!));
+        expect(crashed.payloads.map((p) => new TextDecoder().decode(p))).toEqual(['before the crash']);
+        expect(crashed.damaged).toBe(true);
+        const fresh = decodeSpillFrames(await storage.read(segments[1]!));
+        expect(fresh).toMatchObject({ damaged: false });
+        expect(readdirSync(dir)).not.toContain('active.spill');
+    });
+});
+
+describe('WriteSpill over files', () => {
+    it('should replay records spilled before a restart exactly once', async () => {
+        const dir = spillDir();
+        const store = new MemoryStorageProvider();
+        const recordUsage = vi.spyOn(store, 'recordUsage').mockRejectedValue(new Error('SQLITE_FULL: database or disk is full'));
+        const targets = { usage: store, requestStats: { recordRequestStat: vi.fn() } };
+
+        const before = new WriteSpill({ storage: new FileSpillStorage({ dir }), targets });
+        await before.write({ kind: 'usage', record: usage('int_1') });
+        await before.write({ kind: 'usage', record: usage('int_2') });
+        await before.close();
+        expect(readFileSync(join(dir, 'active.spill')).length).toBeGreaterThan(0);
+
+        recordUsage.mockRestore();
+        const after = new WriteSpill({ storage: new FileSpillStorage({ dir }), targets });
+        expect(await after.replay()).toMatchObject({ replayed: 2, remaining: 0 });
+        expect(await after.replay()).toMatchObject({ replayed: 0, remaining: 0 });
+
+        expect(await store.sumUsage('acme', new Date(0))).toEqual({ tokens: 30, costUsd: 0.02, requests: 2 });
+        expect(readdirSync(dir)).toEqual([]);
+        await after.close();
+    });
+});
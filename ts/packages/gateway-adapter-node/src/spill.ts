@@ -0,0 +1,175 @@
+/**
+ * File-backed write spill storage for Node.js.
+ *
+ * Frames are appended (and fsynced) to `active.spill` in the spill
+ * directory. Rotation renames it to a `segment-<time>-<seq>.spill` file,
+ * which is replayed and then deleted. An active file left by a previous
+ * process is rotated on startup, so a frame cut short by a crash is found
+ * by replay instead of having new frames appended after it.
+ *
+ * @module spill
+ */
+
+import { mkdir, open, readdir, readFile, rename, stat, unlink } from 'node:fs/promises';
+import { join } from 'node:path';
+import {
+    SpillFullError,
+    type SpillConfig,
+    type SpillStorage,
+} from '@polyglot-llm-gateway/gateway-core';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default cap on the total size of spill files. */
+export const DEFAULT_SPILL_MAX_BYTES = 64 * 1024 * 1024;
+
+/** Default size at which the active spill file is rotated. */
+export const DEFAULT_SPILL_SEGMENT_BYTES = 4 * 1024 * 1024;
+
+const ACTIVE_FILE = 'active.spill';
+const SEGMENT_PATTERN = /^segment-\d{13}-\d{6}\.spill$/;
+
+// ============================================================================
+// File Spill Storage
+// ============================================================================
+
+/**
+ * File spill storage options.
+ */
+export interface FileSpillStorageOptions {
+    /** Spill directory; created if missing. */
+    dir: string;
+
+    /** Cap on the total size of spill files in bytes. */
+    maxBytes?: number | undefined;
+
+    /** Size at which the active file is rotated, in bytes. */
+    segmentBytes?: number | undefined;
+
+    /** Clock for segment names, for tests. */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * SpillStorage over a directory of append-only files.
+ */
+export class FileSpillStorage implements SpillStorage {
+    private readonly dir: string;
+    private readonly maxBytes: number;
+    private readonly segmentBytes: number;
+    private readonly clock: () => number;
+    private ready: Promise<void> | undefined;
+    private sequence = 0;
+    private activeBytes = 0;
+    private totalBytes = 0;
+
+    constructor(options: FileSpillStorageOptions) {
+        this.dir = options.dir;
+        this.maxBytes = options.maxBytes ?? DEFAULT_SPILL_MAX_BYTES;
+        this.segmentBytes = options.segmentBytes ?? DEFAULT_SPILL_SEGMENT_BYTES;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    async append(frame: Uint8Array): Promise<void> {
+        await this.init();
+        if (this.totalBytes + frame.length > this.maxBytes) {
+            throw new SpillFullError(`Spill directory ${this.dir} is at its ${this.maxBytes} byte cap`);
+        }
+        if (this.activeBytes > 0 && this.activeBytes + frame.length > this.segmentBytes) {
+            await this.rotate();
+        }
+
+        const file = await open(this.path(ACTIVE_FILE), 'a');
+        try {
+            await file.write(frame);
+            await file.sync();
+        } catch (error) {
+            // Drop a partial frame so later appends start on a frame boundary
+            await file.truncate(this.activeBytes).catch(() => undefined);
+            throw error;
+        } finally {
+            await file.close();
+        }
+        this.activeBytes += frame.length;
+        this.totalBytes += frame.length;
+    }
+
+    async rotate(): Promise<void> {
+        await this.init();
+        if (this.activeBytes === 0) return;
+        await this.seal();
+    }
+
+    async segments(): Promise<string[]> {
+        await this.init();
+        return (await readdir(this.dir)).filter((name) => SEGMENT_PATTERN.test(name)).sort();
+    }
+
+    async read(segment: string): Promise<Uint8Array> {
+        return new Uint8Array(await readFile(this.path(segment)));
+    }
+
+    async remove(segment: string): Promise<void> {
+        const { size } = await stat(this.path(segment));
+        await unlink(this.path(segment));
+        this.totalBytes = Math.max(0, this.totalBytes - size);
+    }
+
+    // ---- Private Methods ----
+
+    private init(): Promise<void> {
+        this.ready ??= this.load().catch((error: unknown) => {
+            this.ready = undefined;
+            throw error;
+        });
+        return this.ready;
+    }
+
+    /**
+     * Creates the directory and counts existing files. A leftover active
+     * file is sealed as-is; a damaged tail is reported when it's replayed.
+     */
+    private async load(): Promise<void> {
+        await mkdir(this.dir, { recursive: true });
+        this.totalBytes = 0;
+        for (const name of await readdir(this.dir)) {
+            if (name === ACTIVE_FILE || SEGMENT_PATTERN.test(name)) {
+                this.totalBytes += (await stat(this.path(name))).size;
+            }
+        }
+        const active = await stat(this.path(ACTIVE_FILE)).catch(() => undefined);
+        if (active && active.size > 0) {
+            await this.seal();
+        }
+        this.activeBytes = 0;
+    }
+
+    private async seal(): Promise<void> {
+        const sequence = String(this.sequence++ % 1_000_000).padStart(6, '0');
+        const time = String(this.clock()).padStart(13, '0');
+        await rename(this.path(ACTIVE_FILE), this.path(`segment-${time}-${sequence}.spill`));
+        this.activeBytes = 0;
+    }
+
+    private path(name: string): string {
+        return join(this.dir, name);
+    }
+}
+
+// ============================================================================
+// Factory
+// ============================================================================
+
+/**
+ * Creates the spill storage for a `storage.spill` config (Gateway's
+ * spillStorageFactory).
+ */
+export function createNodeSpillStorage(config: SpillConfig): SpillStorage {
+    return new FileSpillStorage({
+        dir: config.dir,
+        maxBytes: config.maxBytes,
+        segmentBytes: config.segmentBytes,
+    });
+}
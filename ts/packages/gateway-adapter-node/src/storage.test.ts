@@ -0,0 +1,601 @@
+/**
+ * Storage conformance suite.
+ *
+ * A table of behaviors every StorageProvider must share, run against each
+ * implementation below so they can't drift apart. The D1 provider runs
+ * only inside a Workers runtime; its queries mirror the ordering and
+ * filtering rules checked here.
+ */
+
+import { describe, it, expect } from 'vitest';
+import {
+    DualWriteStorage,
+    UNSCOPED_TENANT,
+    ERASED,
+    messageContentHash,
+    type Conversation,
+    type InteractionRecord,
+    type ResponseRecord,
+    type ShadowResult,
+    type StorageProvider,
+    type StoredThread,
+} from '@polyglot-llm-gateway/gateway-core';
+import { MemoryStorageProvider } from './index';
+
+const at = (minute: number) => new Date(Date.UTC(2025, 0, 1, 0, minute));
+
+function conversation(id: string, tenantId: string, minute: number, overrides: Partial<Conversation> = {}): Conversation {
+    return {
+        id,
+        tenantId,
+        model: 'gpt-4o',
+        messages: [
+            { id: `${id}-m1`, role: 'user', content: 'Hi', timestamp: at(minute) },
+            { id: `${id}-m2`, role: 'assistant', content: 'Hello', timestamp: at(minute) },
+        ],
+        createdAt: at(minute),
+        updatedAt: at(minute),
+        ...overrides,
+    };
+}
+
+function response(id: string, tenantId: string, minute: number, overrides: Partial<ResponseRecord> = {}): ResponseRecord {
+    return {
+        id,
+        tenantId,
+        model: 'claude-sonnet-4',
+        status: 'completed',
+        createdAt: at(minute),
+        updatedAt: at(minute),
+        ...overrides,
+    };
+}
+
+function request(id: string, tenantId: string, minute: number, status: InteractionRecord['status']): InteractionRecord {
+    return {
+        id,
+        tenantId,
+        frontdoor: 'openai',
+        provider: 'mock',
+        model: 'gpt-4o',
+        stream: false,
+        status,
+        createdAt: at(minute),
+        updatedAt: at(minute),
+    };
+}
+
+function shadow(id: string, interactionId: string, minute: number, divergence?: 'structural' | 'minor'): ShadowResult {
+    return {
+        id,
+        interactionId,
+        providerName: 'shadow',
+        durationMs: 10,
+        divergences: divergence
+            ? [{
+                type: divergence === 'structural' ? 'tool_call_count' : 'content_length',
+                description: 'differs',
+                severity: divergence === 'structural' ? 'critical' : 'info',
+            }]
+            : [],
+        hasStructuralDivergence: divergence === 'structural',
+        createdAt: at(minute),
+    };
+}
+
+/** Implementations under test. */
+const providers: [string, () => StorageProvider][] = [
+    ['memory', () => new MemoryStorageProvider()],
+    ['dual, reading the primary', () => new DualWriteStorage(new MemoryStorageProvider(), new MemoryStorageProvider()).storage],
+    ['dual, reading the secondary', () => new DualWriteStorage(
+        new MemoryStorageProvider(),
+        new MemoryStorageProvider(),
+        { readFrom: 'secondary' },
+    ).storage],
+];
+
+/** Behaviors every implementation must share. */
+const behaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['scopes single-item getters to the owning tenant', async (store) => {
+        await store.saveConversation(conversation('c1', 'tenant-a', 1));
+        await store.saveResponse(response('r1', 'tenant-a', 2));
+
+        expect(await store.getConversation('c1', 'tenant-b')).toBeNull();
+        expect(await store.getResponse('r1', 'tenant-b')).toBeNull();
+        expect((await store.getConversation('c1', 'tenant-a'))?.messages).toHaveLength(2);
+        expect((await store.getResponse('r1', UNSCOPED_TENANT))?.tenantId).toBe('tenant-a');
+    }],
+
+    ['returns copies that callers cannot use to mutate stored state', async (store) => {
+        const saved = conversation('c1', 'tenant-a', 1);
+        await store.saveConversation(saved);
+        saved.messages.push({ id: 'late', role: 'user', content: 'x', timestamp: at(2) });
+
+        const first = (await store.getConversation('c1', 'tenant-a'))!;
+        first.messages.length = 0;
+        first.model = 'changed';
+
+        const second = (await store.getConversation('c1', 'tenant-a'))!;
+        expect(second.messages).toHaveLength(2);
+        expect(second.model).toBe('gpt-4o');
+
+        await store.saveResponse(response('r1', 'tenant-a', 1, { metadata: { k: 'v' } }));
+        const [listed] = await store.listResponses('tenant-a');
+        listed!.metadata!['k'] = 'changed';
+        expect((await store.getResponse('r1', 'tenant-a'))?.metadata).toEqual({ k: 'v' });
+    }],
+
+    ['lists interactions newest first with ties broken by ID', async (store) => {
+        await store.saveConversation(conversation('c-b', 'tenant-a', 5));
+        await store.saveConversation(conversation('c-a', 'tenant-a', 5));
+        await store.saveResponse(response('r1', 'tenant-a', 7));
+        await store.saveResponse(response('r2', 'tenant-a', 1));
+
+        const desc = await store.listInteractions({ tenantId: 'tenant-a' });
+        expect(desc.map((i) => i.id)).toEqual(['r1', 'c-b', 'c-a', 'r2']);
+
+        const asc = await store.listInteractions({ tenantId: 'tenant-a', order: 'asc' });
+        expect(asc.map((i) => i.id)).toEqual(['r2', 'c-a', 'c-b', 'r1']);
+    }],
+
+    ['pages interactions with limit and offset', async (store) => {
+        for (let i = 0; i < 5; i++) {
+            await store.saveResponse(response(`r${i}`, 'tenant-a', i));
+        }
+
+        const page = await store.listInteractions({ tenantId: 'tenant-a', limit: 2, offset: 1 });
+        expect(page.map((i) => i.id)).toEqual(['r3', 'r2']);
+        expect(await store.listInteractions({ tenantId: 'tenant-a', offset: 5 })).toEqual([]);
+    }],
+
+    ['filters interactions by type and tenant, and counts the same set', async (store) => {
+        await store.saveConversation(conversation('c1', 'tenant-a', 1));
+        await store.saveConversation(conversation('c2', 'tenant-b', 2));
+        await store.saveResponse(response('r1', 'tenant-a', 3, { status: 'failed' }));
+
+        const conversations = await store.listInteractions({ type: 'conversation' });
+        expect(conversations.map((i) => i.id)).toEqual(['c2', 'c1']);
+        expect(await store.getInteractionCount({ type: 'conversation' })).toBe(2);
+
+        const tenantA = await store.listInteractions({ tenantId: 'tenant-a' });
+        expect(tenantA.map((i) => i.id)).toEqual(['r1', 'c1']);
+        expect(await store.getInteractionCount({ tenantId: 'tenant-a' })).toBe(2);
+
+        expect(await store.getInteractionCount({ tenantId: 'tenant-a', type: 'response' })).toBe(1);
+        expect(await store.getInteractionCount()).toBe(3);
+    }],
+
+    ['summarizes message counts and response status', async (store) => {
+        await store.saveConversation(conversation('c1', 'tenant-a', 1));
+        await store.saveResponse(response('r1', 'tenant-a', 2, { status: 'incomplete' }));
+
+        const [r1, c1] = await store.listInteractions({ tenantId: 'tenant-a' });
+        expect(r1).toMatchObject({ id: 'r1', type: 'response', status: 'incomplete' });
+        expect(c1).toMatchObject({ id: 'c1', type: 'conversation', messageCount: 2 });
+        expect(c1!.createdAt).toEqual(at(1));
+    }],
+
+    ['updates a response without changing its owner', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1, { status: 'in_progress' }));
+        await store.updateResponse!('r1', { status: 'cancelled', tenantId: 'tenant-b', updatedAt: at(9) });
+
+        const updated = await store.getResponse('r1', 'tenant-a');
+        expect(updated?.status).toBe('cancelled');
+        expect(updated?.updatedAt).toEqual(at(9));
+        expect(updated?.model).toBe('claude-sonnet-4');
+    }],
+
+    ['returns events in time order, only to the owning tenant', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1));
+        await store.saveEvent({ id: 'e2', interactionId: 'r1', type: 'response', timestamp: at(2), payload: { n: 2 } });
+        await store.saveEvent({ id: 'e1', interactionId: 'r1', type: 'request', timestamp: at(1), payload: { n: 1 } });
+
+        const events = await store.getEvents('r1', 'tenant-a');
+        expect(events.map((e) => e.id)).toEqual(['e1', 'e2']);
+        expect(events[0]!.payload).toEqual({ n: 1 });
+        expect(await store.getEvents('r1', 'tenant-b')).toEqual([]);
+        expect(await store.getEvents('unknown', 'tenant-a')).toEqual([]);
+    }],
+
+    ['scopes shadow results through their interaction', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1));
+        await store.saveShadowResult(shadow('s1', 'r1', 1, 'structural'));
+
+        expect(await store.getShadowResults('r1', 'tenant-a')).toHaveLength(1);
+        expect(await store.getShadowResults('r1', 'tenant-b')).toEqual([]);
+        expect((await store.getShadowResult('s1', 'tenant-a'))?.providerName).toBe('shadow');
+        expect(await store.getShadowResult('s1', 'tenant-b')).toBeNull();
+        expect(await store.getShadowResult('s1', UNSCOPED_TENANT)).not.toBeNull();
+    }],
+
+    ['lists only divergent shadow results, newest first', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1));
+        await store.saveResponse(response('r2', 'tenant-b', 1));
+        await store.saveShadowResult(shadow('same', 'r1', 1));
+        await store.saveShadowResult(shadow('minor', 'r1', 2, 'minor'));
+        await store.saveShadowResult(shadow('structural', 'r1', 3, 'structural'));
+        await store.saveShadowResult(shadow('other', 'r2', 4, 'structural'));
+
+        const all = await store.listDivergentShadowResults();
+        expect(all.map((r) => r.id)).toEqual(['other', 'structural', 'minor']);
+
+        const structural = await store.listDivergentShadowResults({ tenantId: 'tenant-a', structuralOnly: true });
+        expect(structural.map((r) => r.id)).toEqual(['structural']);
+
+        const page = await store.listDivergentShadowResults({ limit: 1, offset: 1 });
+        expect(page.map((r) => r.id)).toEqual(['structural']);
+    }],
+
+    ['lists, finds, deletes, and purges thread state by key hash', async (store) => {
+        await store.setThreadState('th-1', 'r1');
+        await store.setThreadState('th-2', 'r2');
+        await store.setThreadState('th-3', 'r3');
+
+        const all = await store.listThreadState();
+        expect(all.map((e) => e.keyHash)).toEqual(all.map((e) => e.keyHash).sort());
+        const first = await store.listThreadState({ limit: 2 });
+        const rest = await store.listThreadState({ cursor: first[1]!.keyHash });
+        expect([...first, ...rest]).toEqual(all);
+
+        const found = await store.findThreadState(all.find((e) => e.threadKey === 'th-2')!.keyHash);
+        expect(found).toMatchObject({ threadKey: 'th-2', responseId: 'r2' });
+        expect(found!.keyHash).toMatch(/^[0-9a-f]{64}$/);
+        expect(await store.findThreadState('0'.repeat(64))).toBeNull();
+
+        expect(await store.deleteThreadState('th-2')).toBe(true);
+        expect(await store.deleteThreadState('th-2')).toBe(false);
+        expect(await store.getThreadState('th-2')).toBeNull();
+
+        expect(await store.purgeThreadState(new Date(0))).toBe(0);
+        expect(await store.purgeThreadState(new Date(Date.now() + 60_000))).toBe(2);
+        expect(await store.listThreadState()).toEqual([]);
+    }],
+];
+
+/** Thread behaviors, for providers that implement the optional ThreadStore. */
+const threadBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['stores thread messages in order and scopes threads by tenant', async (store) => {
+        const thread: StoredThread = { id: 't1', tenantId: 'tenant-a', messages: [], createdAt: at(1), updatedAt: at(1) };
+        await store.createThread!(thread);
+        await store.addMessage!('t1', { id: 'm1', role: 'user', content: 'one', timestamp: at(2) });
+        await store.addMessage!('t1', { id: 'm2', role: 'assistant', content: 'two', timestamp: at(3) });
+        await store.addMessage!('t1', { id: 'm3', role: 'user', content: 'three', timestamp: at(4) });
+
+        expect(await store.getThread!('t1', 'tenant-b')).toBeNull();
+        expect((await store.getThread!('t1', 'tenant-a'))?.messages).toHaveLength(3);
+
+        const messages = await store.listMessages!('t1');
+        expect(messages.map((m) => m.id)).toEqual(['m1', 'm2', 'm3']);
+
+        const page = await store.listMessages!('t1', { order: 'desc', limit: 2 });
+        expect(page.map((m) => m.id)).toEqual(['m3', 'm2']);
+
+        messages[0]!.content = 'changed';
+        expect((await store.listMessages!('t1'))[0]!.content).toBe('one');
+
+        await store.deleteThread!('t1');
+        expect(await store.getThread!('t1', 'tenant-a')).toBeNull();
+    }],
+    ['skips appends that repeat a message ID or recent content', async (store) => {
+        await store.createThread!({ id: 't1', tenantId: 'tenant-a', messages: [], createdAt: at(1), updatedAt: at(1) });
+        const reply = async (id: string, minute: number) => ({
+            id, role: 'assistant', content: 'Hello', timestamp: at(minute), contentHash: await messageContentHash('assistant', 'Hello'),
+        });
+        const window = { dedupWindow: { messages: 2, seconds: 60 } };
+
+        expect(await store.addMessage!('t1', { id: 'm1', role: 'user', content: 'Hi', timestamp: at(2) })).toEqual({ id: 'm1' });
+        expect(await store.addMessage!('t1', await reply('m2', 3), window)).toEqual({ id: 'm2' });
+        expect(await store.addMessage!('t1', { id: 'm1', role: 'user', content: 'Hi', timestamp: at(4) })).toEqual({ id: 'm1', duplicate: 'id' });
+        expect(await store.addMessage!('t1', await reply('m3', 5), window)).toEqual({ id: 'm2', duplicate: 'content' });
+        // Without a window, or once outside it, the same content is appended
+        expect(await store.addMessage!('t1', await reply('m4', 6))).toEqual({ id: 'm4' });
+        await store.addMessage!('t1', { id: 'm5', role: 'user', content: 'More', timestamp: at(7) });
+        await store.addMessage!('t1', { id: 'm6', role: 'user', content: 'Again', timestamp: at(8) });
+        expect(await store.addMessage!('t1', await reply('m7', 9), window)).toEqual({ id: 'm7' });
+
+        expect((await store.listMessages!('t1')).map((m) => m.id)).toEqual(['m1', 'm2', 'm4', 'm5', 'm6', 'm7']);
+        expect(await store.addMessage!('missing', await reply('m8', 10))).toBeNull();
+    }],
+];
+
+/** Erasure behaviors, for providers that implement the optional ErasureStore. */
+const erasureBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['leaves no trace of erased prompt text while keeping usage', async (store) => {
+        const prompt = 'My card number is 4111 1111 1111 1111';
+        const user = { user_id: 'u-42' };
+        await store.saveConversation(conversation('c1', 'tenant-a', 1, {
+            metadata: user,
+            messages: [{ id: 'c1-m1', role: 'user', content: prompt, timestamp: at(1) }],
+        }));
+        await store.saveResponse(response('r1', 'tenant-a', 2, {
+            metadata: user,
+            request: { input: prompt },
+            response: { output_text: `You said: ${prompt}` },
+            usage: { promptTokens: 12, completionTokens: 8, totalTokens: 20 },
+        }));
+        await store.saveEvent({ id: 'e1', interactionId: 'r1', type: 'request', timestamp: at(2), payload: { prompt } });
+        await store.saveShadowResult({
+            ...shadow('s1', 'c1', 3),
+            response: {
+                id: 'x', model: 'm', content: prompt,
+                usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            },
+        });
+        await store.recordInteraction?.({ ...request('r1', 'tenant-a', 2, 'completed'), request: JSON.stringify({ prompt }) });
+        await store.saveConversation(conversation('c2', 'tenant-a', 4, { metadata: { user_id: 'u-7' } }));
+        await store.saveConversation(conversation('c3', 'tenant-b', 5, { metadata: user }));
+
+        const counts = await store.eraseInteractions!({ tenantId: 'tenant-a', metadata: { key: 'user_id', value: 'u-42' } });
+        expect(counts).toMatchObject({ conversations: 1, messages: 1, responses: 1, events: 1, shadowResults: 1 });
+        expect(counts.interactions).toBe(store.recordInteraction ? 1 : 0);
+
+        const stored = JSON.stringify([
+            await store.getConversation('c1', UNSCOPED_TENANT),
+            await store.getResponse('r1', UNSCOPED_TENANT),
+            await store.getEvents('r1', UNSCOPED_TENANT),
+            await store.getShadowResults('c1', UNSCOPED_TENANT),
+            await store.getInteraction?.('r1', UNSCOPED_TENANT),
+        ]);
+        expect(stored).not.toContain('4111');
+        expect(stored).not.toContain('u-42');
+
+        const erased = await store.getResponse('r1', UNSCOPED_TENANT);
+        expect(erased?.request).toBe(ERASED);
+        expect(erased?.usage?.totalTokens).toBe(20);
+        expect(erased?.model).toBe('claude-sonnet-4');
+
+        expect((await store.getConversation('c2', 'tenant-a'))?.messages[0]!.content).toBe('Hi');
+        expect((await store.getConversation('c3', 'tenant-b'))?.messages[0]!.content).toBe('Hi');
+    }],
+    ['drops the content hash of erased messages', async (store) => {
+        const content = 'Your PIN is 1234';
+        await store.saveConversation(conversation('c1', 'tenant-a', 1, {
+            metadata: { user_id: 'u-42' },
+            messages: [{
+                id: 'c1-m1', role: 'assistant', content, timestamp: at(1), contentHash: await messageContentHash('assistant', content),
+            }],
+        }));
+
+        await store.eraseInteractions!({ tenantId: 'tenant-a', metadata: { key: 'user_id', value: 'u-42' } });
+
+        const [message] = (await store.getConversation('c1', UNSCOPED_TENANT))!.messages;
+        expect(message!.content).toBe(ERASED);
+        expect(message!.contentHash).toBeUndefined();
+    }],
+
+    ['erases by thread key or explicit IDs', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1, { threadKey: 'th-1', request: { input: 'one' } }));
+        await store.saveResponse(response('r2', 'tenant-a', 2, { threadKey: 'th-2', request: { input: 'two' } }));
+        await store.saveConversation(conversation('c1', 'tenant-a', 3));
+        await store.setThreadState('th-1', 'r1');
+
+        expect((await store.eraseInteractions!({ threadKey: 'th-1' })).responses).toBe(1);
+        expect((await store.getResponse('r1', 'tenant-a'))?.request).toBe(ERASED);
+        expect((await store.getResponse('r2', 'tenant-a'))?.request).toEqual({ input: 'two' });
+        expect(await store.getThreadState('th-1')).toBeNull();
+
+        const counts = await store.eraseInteractions!({ interactionIds: ['c1', 'r2'] });
+        expect(counts).toMatchObject({ conversations: 1, messages: 2, responses: 1 });
+        expect((await store.getConversation('c1', 'tenant-a'))?.messages.map((m) => m.content)).toEqual([ERASED, ERASED]);
+
+        expect(await store.eraseInteractions!({ tenantId: 'tenant-a' })).toMatchObject({ conversations: 0, responses: 0 });
+    }],
+
+    ['erases the request kept in an interaction record by ID, within its tenant', async (store) => {
+        if (!store.recordInteraction) return;
+        const kept = (id: string, tenantId: string) => ({ ...request(id, tenantId, 1, 'completed'), request: '{"input":"secret"}' });
+        await store.recordInteraction(kept('i1', 'tenant-a'));
+        await store.recordInteraction(kept('i2', 'tenant-b'));
+        await store.saveEvent({ id: 'e1', interactionId: 'i1', type: 'request', timestamp: at(1), payload: { input: 'secret' } });
+
+        const counts = await store.eraseInteractions!({ tenantId: 'tenant-a', interactionIds: ['i1', 'i2'] });
+
+        expect(counts).toMatchObject({ interactions: 1, events: 1 });
+        expect((await store.getInteraction!('i1', 'tenant-a'))?.request).toBe(ERASED);
+        expect((await store.getInteraction!('i1', 'tenant-a'))?.status).toBe('completed');
+        expect((await store.getInteraction!('i2', 'tenant-b'))?.request).toBe('{"input":"secret"}');
+    }],
+];
+
+/** Behaviors of the optional TenantStore methods. */
+const tenantBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['saves, replaces, and lists tenants by ID', async (store) => {
+        const tenant = {
+            id: 'tnt-b',
+            name: 'Beta',
+            apiKeys: [{ keyHash: 'hash-b' }],
+            routing: { rules: [{ modelPrefix: 'gpt', provider: 'openai' }] },
+            disabled: false,
+            createdAt: at(1),
+            updatedAt: at(1),
+        };
+        await store.saveTenant!(tenant);
+        await store.saveTenant!({ ...tenant, id: 'tnt-a', name: 'Alpha', routing: undefined });
+        await store.saveTenant!({ ...tenant, disabled: true, updatedAt: at(2) });
+
+        expect(await store.getTenant!('tnt-b')).toEqual({ ...tenant, disabled: true, updatedAt: at(2) });
+        expect(await store.getTenant!('tnt-c')).toBeNull();
+        expect((await store.listTenants!()).map((t) => [t.id, t.disabled])).toEqual([['tnt-a', false], ['tnt-b', true]]);
+    }],
+];
+
+/** Behaviors of the optional UsageStore methods. */
+const usageBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['records an interaction\'s usage once however often it is retried', async (store) => {
+        const record = {
+            tenantId: 'tenant-a', interactionId: 'int-1', model: 'gpt-4o', totalTokens: 15, costUsd: 0.5, createdAt: at(1),
+        };
+        await store.recordUsage!(record);
+        await store.recordUsage!(record);
+        await store.recordUsage!({ ...record, interactionId: 'int-2' });
+
+        expect(await store.sumUsage!('tenant-a', at(0))).toEqual({ tokens: 30, costUsd: 1, requests: 2 });
+    }],
+    ['sums usage before a cutoff, leaving out given interactions', async (store) => {
+        const record = { tenantId: 'tenant-a', model: 'gpt-4o', totalTokens: 10, costUsd: 0, createdAt: at(1) };
+        await store.recordUsage!({ ...record, interactionId: 'int-1' });
+        await store.recordUsage!({ ...record, interactionId: 'int-2' });
+        await store.recordUsage!({ ...record, interactionId: 'int-3', createdAt: at(5) });
+
+        expect(await store.sumUsage!('tenant-a', at(0), { before: at(5), excluding: ['int-2'] }))
+            .toEqual({ tokens: 10, costUsd: 0, requests: 1 });
+    }],
+];
+
+/** Behaviors of the optional SensitiveValueStore methods. */
+const sensitiveValueBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['scans sensitive values in ID order after a cursor and overwrites them', async (store) => {
+        await store.saveResponse(response('r2', 'tenant-a', 1, { request: { input: 'two' } }));
+        await store.saveResponse(response('r1', 'tenant-a', 2, { request: { input: 'one' } }));
+        await store.saveResponse(response('r3', 'tenant-a', 3));
+        await store.saveConversation(conversation('c1', 'tenant-a', 1));
+
+        const first = await store.scanSensitiveValues!('responses.request', undefined, 1);
+        const rest = await store.scanSensitiveValues!('responses.request', first[0]!.id, 10);
+        expect([...first, ...rest]).toEqual([
+            { field: 'responses.request', id: 'r1', value: { input: 'one' } },
+            { field: 'responses.request', id: 'r2', value: { input: 'two' } },
+        ]);
+        expect((await store.scanSensitiveValues!('messages.content', undefined, 10)).map((v) => [v.id, v.value]))
+            .toEqual([['c1-m1', 'Hi'], ['c1-m2', 'Hello']]);
+
+        await store.updateSensitiveValue!({ field: 'responses.request', id: 'r1', value: 'sealed' });
+        await store.updateSensitiveValue!({ field: 'messages.content', id: 'c1-m2', value: 'sealed' });
+
+        expect((await store.getResponse('r1', 'tenant-a'))?.request).toBe('sealed');
+        expect((await store.getConversation('c1', 'tenant-a'))?.messages.map((m) => m.content)).toEqual(['Hi', 'sealed']);
+    }],
+
+    ['scans and overwrites requests kept in interaction records', async (store) => {
+        if (!store.recordInteraction) return;
+        await store.recordInteraction({ ...request('i1', 'tenant-a', 1, 'completed'), request: '{"input":"one"}' });
+        await store.recordInteraction(request('i2', 'tenant-a', 2, 'completed'));
+
+        expect(await store.scanSensitiveValues!('interactions.request', undefined, 10)).toEqual([
+            { field: 'interactions.request', id: 'i1', value: '{"input":"one"}' },
+        ]);
+
+        await store.updateSensitiveValue!({ field: 'interactions.request', id: 'i1', value: 'sealed' });
+
+        expect((await store.getInteraction!('i1', 'tenant-a'))?.request).toBe('sealed');
+    }],
+];
+
+/** Behaviors of the optional ThreadTransferStore methods. */
+const threadTransferBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['imports a batch over existing records, keeping thread state update times', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1, { status: 'in_progress' }));
+
+        await store.importThreadBatch!({
+            conversations: [conversation('c1', 'tenant-b', 2)],
+            responses: [response('r1', 'tenant-b', 1, { previousResponseId: 'r0', threadKey: 'response:r0' })],
+            threadState: [{ threadKey: 'response:r1', responseId: 'r1', updatedAt: at(3) }],
+        });
+
+        expect((await store.getConversation('c1', 'tenant-b'))?.messages).toHaveLength(2);
+        expect(await store.getResponse('r1', 'tenant-b')).toMatchObject({
+            status: 'completed', previousResponseId: 'r0', threadKey: 'response:r0',
+        });
+        expect(await store.getThreadState('response:r1')).toBe('r1');
+        expect((await store.listThreadState())[0]?.updatedAt).toEqual(at(3));
+    }],
+
+    ['records each bundle checksum once', async (store) => {
+        const record = { checksum: 'abc', conversations: 1, responses: 2, threadState: 3, remapped: { r1: 'resp_x' }, importedAt: at(1) };
+        expect(await store.getThreadImport!('abc')).toBeNull();
+
+        await store.recordThreadImport!(record);
+        await store.recordThreadImport!({ ...record, responses: 0, importedAt: at(2) });
+
+        expect(await store.getThreadImport!('abc')).toEqual(record);
+    }],
+];
+
+/** Behaviors of the optional interaction record methods. */
+const interactionRecordBehaviors: [string, (store: StorageProvider) => Promise<void>][] = [
+    ['upserts a request record and updates it when it ends', async (store) => {
+        await store.recordInteraction!(request('i1', 'tenant-a', 1, 'in_progress'));
+        await store.recordInteraction!({ ...request('i1', 'tenant-a', 1, 'in_progress'), model: 'gpt-4o-mini' });
+
+        expect(await store.updateInteraction!('i1', { status: 'completed', statusCode: 200, updatedAt: at(2) })).toBe(true);
+        expect(await store.updateInteraction!('missing', { status: 'completed', updatedAt: at(2) })).toBe(false);
+        expect(await store.getInteraction!('i1', 'tenant-a')).toMatchObject({
+            model: 'gpt-4o-mini', status: 'completed', statusCode: 200, updatedAt: at(2),
+        });
+        expect(await store.getInteraction!('i1', 'tenant-b')).toBeNull();
+    }],
+
+    ['lists requests only when asked for, by status', async (store) => {
+        await store.saveResponse(response('r1', 'tenant-a', 1, { status: 'in_progress' }));
+        await store.recordInteraction!(request('i1', 'tenant-a', 2, 'in_progress'));
+        await store.recordInteraction!(request('i2', 'tenant-a', 3, 'completed'));
+
+        expect((await store.listInteractions({ tenantId: 'tenant-a' })).map((i) => i.id)).toEqual(['r1']);
+        const running = await store.listInteractions({ type: 'request', status: 'in_progress' });
+        expect(running).toEqual([expect.objectContaining({ id: 'i1', type: 'request', provider: 'mock', status: 'in_progress' })]);
+        expect(await store.getInteractionCount({ type: 'request' })).toBe(2);
+    }],
+
+    ['abandons only requests in_progress since before the cutoff', async (store) => {
+        await store.recordInteraction!(request('old', 'tenant-a', 1, 'in_progress'));
+        await store.recordInteraction!(request('recent', 'tenant-a', 20, 'in_progress'));
+        await store.recordInteraction!(request('done', 'tenant-a', 1, 'completed'));
+
+        expect(await store.abandonInteractions!(at(10), at(30))).toBe(1);
+
+        const statuses = await store.listInteractions({ type: 'request', orderBy: 'createdAt', order: 'asc' });
+        expect(statuses.map((i) => [i.id, i.status])).toEqual([
+            ['done', 'completed'],
+            ['old', 'abandoned'],
+            ['recent', 'in_progress'],
+        ]);
+    }],
+];
+
+describe.each(providers)('%s storage conformance', (_, create) => {
+    it.each(behaviors)('%s', async (_name, behavior) => {
+        await behavior(create());
+    });
+
+    it.each(threadBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.createThread) return;
+        await behavior(store);
+    });
+
+    it.each(erasureBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.eraseInteractions) return;
+        await behavior(store);
+    });
+
+    it.each(tenantBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.saveTenant) return;
+        await behavior(store);
+    });
+
+    it.each(usageBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.recordUsage) return;
+        await behavior(store);
+    });
+
+    it.each(sensitiveValueBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.scanSensitiveValues) return;
+        await behavior(store);
+    });
+
+    it.each(interactionRecordBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.recordInteraction) return;
+        await behavior(store);
+    });
+
+    it.each(threadTransferBehaviors)('%s', async (_name, behavior) => {
+        const store = create();
+        if (!store.importThreadBatch) return;
+        await behavior(store);
+    });
+});
@@ -105,6 +105,93 @@ describe('AdminHandler Integration', () => {
         });
     });
 
+    describe('GET /api/interactions/:id/shadows/:shadowId/diff', () => {
+        const now = new Date();
+        const primary = {
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'gpt-4o', sourceAPIType: 'openai',
+            usage: { promptTokens: 10, completionTokens: 5, totalTokens: 15 },
+            choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'Paris.' } }],
+        };
+        const shadows: Record<string, any> = {
+            shadow_ok: {
+                id: 'shadow_ok', interactionId: 'resp_1', providerName: 'claude', durationMs: 120,
+                response: {
+                    id: 'msg-1', model: 'claude', content: 'The capital is Paris.', finishReason: 'stop',
+                    usage: { promptTokens: 12, completionTokens: 7, totalTokens: 19 },
+                },
+                divergences: [], hasStructuralDivergence: false, createdAt: now,
+            },
+            shadow_err: {
+                id: 'shadow_err', interactionId: 'resp_1', providerName: 'claude', durationMs: 30000,
+                error: { type: 'execution_error', message: 'timeout' },
+                divergences: [], hasStructuralDivergence: true, createdAt: now,
+            },
+            shadow_other: {
+                id: 'shadow_other', interactionId: 'resp_2', providerName: 'claude', durationMs: 1,
+                divergences: [], hasStructuralDivergence: false, createdAt: now,
+            },
+        };
+        const storage = {
+            async getResponse(id: string) {
+                return id === 'resp_1'
+                    ? { id, tenantId: 't', model: 'gpt-4o', status: 'completed', response: primary, createdAt: now, updatedAt: now }
+                    : null;
+            },
+            async getConversation() {
+                return null;
+            },
+            async getShadowResult(id: string) {
+                return shadows[id] ?? null;
+            },
+        } as any;
+
+        const get = (path: string) =>
+            new AdminHandler({ startTime, storage }).handle(new Request(`http://localhost${path}`));
+
+        it('should return a structured diff against the primary response', async () => {
+            const response = await get('/api/interactions/resp_1/shadows/shadow_ok/diff');
+            expect(response.status).toBe(200);
+
+            const body = await response.json();
+            expect(body.providerName).toBe('claude');
+            expect(body.diff.finishReason.equal).toBe(true);
+            expect(body.diff.usage.totalTokens).toEqual({ primary: 15, shadow: 19, delta: 4 });
+            expect(body.diff.latencyMs).toEqual({ primary: null, shadow: 120, delta: null });
+            expect(body.diff.text.unified).toContain('-Paris.\n+The capital is Paris.');
+        });
+
+        it('should diff an errored shadow as empty output', async () => {
+            const body = await (await get('/api/interactions/resp_1/shadows/shadow_err/diff')).json();
+
+            expect(body.diff.errors).toEqual({ primary: null, shadow: 'timeout' });
+            expect(body.diff.empty).toEqual({ primary: false, shadow: true });
+            expect(body.diff.finishReason).toEqual({ primary: 'stop', shadow: null, equal: false });
+        });
+
+        it('should return 404 for a shadow of another interaction', async () => {
+            expect((await get('/api/interactions/resp_1/shadows/shadow_other/diff')).status).toBe(404);
+            expect((await get('/api/interactions/resp_2/shadows/shadow_other/diff')).status).toBe(404);
+        });
+    });
+
+    describe('GET /api/schema/canonical-request', () => {
+        it('should return the canonical request JSON Schema', async () => {
+            const response = await handler.handle(new Request('http://localhost/api/schema/canonical-request'));
+            expect(response.status).toBe(200);
+
+            const body = await response.json();
+            expect(body.title).toBe('CanonicalRequest');
+            expect(body.required).toContain('messages');
+            expect(body.$defs).toHaveProperty('Message');
+        });
+
+        it('should also return the canonical response JSON Schema', async () => {
+            const response = await handler.handle(new Request('http://localhost/api/schema/canonical-response'));
+            expect(response.status).toBe(200);
+            expect((await response.json()).title).toBe('CanonicalResponse');
+        });
+    });
+
     describe('unknown routes', () => {
         it('should return 404 for unknown paths', async () => {
             const request = new Request('http://localhost/api/unknown', {
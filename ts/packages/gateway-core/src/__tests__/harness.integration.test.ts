@@ -0,0 +1,93 @@
+/**
+ * Cross-cutting end-to-end scenarios, run over HTTP through the test
+ * harness.
+ */
+
+import { describe, it, expect, afterEach } from 'vitest';
+import { harness, scenario, parseSSE, ScriptedProvider, type TestGateway } from './harness/index.js';
+
+describe('End-to-end scenarios', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    it('should stream through a pipeline that mutates the request and a model rewrite', async () => {
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                pipeline: { stages: [{ name: 'policy', type: 'pre', url: 'http://hooks/policy' }] },
+                modelRouting: { rewrites: [{ modelExact: 'gpt-4o', provider: 'fast', model: 'fast-mini' }] },
+            })
+            .provider(new ScriptedProvider('main', { text: 'From main' }))
+            .provider(new ScriptedProvider('fast', { chunks: ['Hello', ' there', '!'] }))
+            .webhook(() => ({ action: 'modify', request: { systemPrompt: 'Answer briefly.', temperature: 0 } }))
+            .start();
+
+        const [turn] = await scenario(gateway).turn({
+            path: '/v1/chat/completions',
+            body: { model: 'gpt-4o', stream: true, messages: [{ role: 'user', content: 'Hi' }] },
+            provider: 'fast',
+            sent: (request) => expect(request).toMatchObject({
+                model: 'fast-mini',
+                systemPrompt: 'Answer briefly.',
+                temperature: 0,
+                messages: [{ role: 'user', content: 'Hi' }],
+            }),
+            events: [
+                { type: 'start' },
+                { type: 'text', text: 'Hello there!' },
+                { type: 'stop', reason: 'stop' },
+                { type: 'done' },
+                { type: 'gateway', name: 'gateway.warnings', data: [expect.objectContaining({ code: 'model_rewrite' })] },
+            ],
+            stored: ['stream_start', 'first_token', 'stream_transcript', 'stream_end'],
+        }).run();
+
+        // The webhook saw the rewritten model, before its own changes
+        expect(gateway.webhookCalls).toHaveLength(1);
+        expect(gateway.webhookCalls[0]!.payload.request).toMatchObject({ model: 'fast-mini' });
+        expect(gateway.webhookCalls[0]!.payload.request).not.toHaveProperty('systemPrompt');
+        const models = parseSSE(turn!.body).filter((f) => f.data.startsWith('{"id"')).map((f) => JSON.parse(f.data).model);
+        expect(new Set(models)).toEqual(new Set(['fast-mini']));
+    });
+
+    it('should carry a previous_response_id chain across three turns', async () => {
+        gateway = await harness()
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses' })
+            .provider(new ScriptedProvider('mock', (_, call) => ({ text: `Answer ${call + 1}` })))
+            .start();
+        const ask = (input: string) => (previous: { json?: any }[]) => ({
+            model: 'gpt-4o',
+            input,
+            previousResponseId: previous[previous.length - 1]?.json.id,
+        });
+        const history = (count: number) => (request: { messages: unknown[] }) => expect(request.messages).toHaveLength(count);
+
+        const turns = await scenario(gateway)
+            .turn({ path: '/v1/responses', body: ask('What is 1 + 1?'), sent: history(1), stored: ['response'] })
+            .turn({ path: '/v1/responses', body: ask('And doubled?'), sent: history(3), stored: ['response'] })
+            .turn({
+                path: '/v1/responses',
+                body: ask('And once more?'),
+                sent: (request) => expect(request.messages).toEqual([
+                    { role: 'user', content: 'What is 1 + 1?' },
+                    expect.objectContaining({ role: 'assistant', content: 'Answer 1' }),
+                    { role: 'user', content: 'And doubled?' },
+                    expect.objectContaining({ role: 'assistant', content: 'Answer 2' }),
+                    { role: 'user', content: 'And once more?' },
+                ]),
+                stored: ['response'],
+                golden: 'responses-chain-turn3',
+            })
+            .run();
+
+        const ids = turns.map((t) => t.json.id);
+        expect(new Set(ids).size).toBe(3);
+        expect(ids.map((id) => gateway!.store.responses.get(id)?.previousResponseId)).toEqual([undefined, ids[0], ids[1]]);
+    });
+});
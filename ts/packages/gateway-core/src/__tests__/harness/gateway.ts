@@ -0,0 +1,251 @@
+/**
+ * Boots a gateway for end-to-end tests: scripted providers, an in-memory
+ * store, and a real HTTP server on an ephemeral port.
+ *
+ * @module __tests__/harness/gateway
+ */
+
+import { createServer, type IncomingMessage, type Server, type ServerResponse } from 'node:http';
+import type { AddressInfo } from 'node:net';
+import { Gateway, type GatewayOptions } from '../../gateway.js';
+import { createProviderRegistry } from '../../ports/provider.js';
+import type { AppConfig, GatewayConfig } from '../../ports/config.js';
+import type { StorageProvider } from '../../ports/storage.js';
+import type { LifecycleEvent } from '../../domain/events.js';
+import { MemoryStore } from './store.js';
+import type { ScriptedProvider } from './provider.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Answers a pipeline webhook call with the JSON it should return
+ * (e.g. `{ action: 'deny', reason }`).
+ */
+export type WebhookHandler = (url: string, payload: any) => unknown;
+
+/**
+ * A pipeline webhook call the gateway made.
+ */
+export interface WebhookCall {
+    url: string;
+    payload: any;
+    /** Headers the call was sent with, signature included. */
+    headers: Headers;
+    /** The body exactly as sent. */
+    body: string;
+}
+
+/** Tenant requests authenticate as unless they say otherwise. */
+export const DEFAULT_TENANT = 'acme';
+
+/** The fetch requests go out through, kept before any test stubs it. */
+const httpFetch = globalThis.fetch.bind(globalThis);
+
+// ============================================================================
+// Builder
+// ============================================================================
+
+/**
+ * Starts describing a test gateway.
+ */
+export function harness(): HarnessBuilder {
+    return new HarnessBuilder();
+}
+
+/**
+ * Collects apps, providers, and config, then boots the gateway. The first
+ * provider added is the default route.
+ */
+export class HarnessBuilder {
+    private readonly apps: AppConfig[] = [];
+    private readonly providers: ScriptedProvider[] = [];
+    private extra: Partial<GatewayConfig> = {};
+    private overrides: Partial<GatewayOptions> = {};
+    private onWebhook: WebhookHandler = () => ({ action: 'continue' });
+
+    /** Adds an app. */
+    app(app: AppConfig): this {
+        this.apps.push(app);
+        return this;
+    }
+
+    /** Adds a provider, registered under its name. */
+    provider(provider: ScriptedProvider): this {
+        this.providers.push(provider);
+        return this;
+    }
+
+    /** Answers the apps' pipeline webhooks (default: continue). */
+    webhook(handler: WebhookHandler): this {
+        this.onWebhook = handler;
+        return this;
+    }
+
+    /** Sets config beyond the apps and providers (routing, events, ...). */
+    config(config: Partial<GatewayConfig>): this {
+        this.extra = { ...this.extra, ...config };
+        return this;
+    }
+
+    /** Sets gateway options over the harness's own (logger, auth, storage, ...). */
+    options(options: Partial<GatewayOptions>): this {
+        this.overrides = { ...this.overrides, ...options };
+        return this;
+    }
+
+    /**
+     * Boots the gateway and listens on an ephemeral port.
+     */
+    async start(): Promise<TestGateway> {
+        if (this.providers.length === 0) {
+            throw new Error('harness needs at least one provider');
+        }
+        const providerRegistry = createProviderRegistry();
+        for (const provider of this.providers) {
+            providerRegistry.register(provider.name, (config) => {
+                provider.config = config;
+                return provider;
+            });
+        }
+        const config: GatewayConfig = {
+            apps: this.apps,
+            providers: this.providers.map((p) => ({ name: p.name, type: p.name, apiKey: `sk-${p.name}` })),
+            routing: { defaultProvider: this.providers[0]!.name },
+            ...this.extra,
+        };
+
+        const store = new MemoryStore();
+        const webhookCalls: WebhookCall[] = [];
+        const published: LifecycleEvent[] = [];
+        const onWebhook = this.onWebhook;
+        const gateway = new Gateway({
+            config: { load: async () => config },
+            auth: {
+                authenticate: async (token: string) => ({ tenantId: token, scopes: ['*'], metadata: {} }),
+                getTenant: async () => null,
+            },
+            // Only what the recorded paths use; the rest of the store is optional to them
+            storage: store as unknown as StorageProvider,
+            providerRegistry,
+            events: { publish: async (event) => void published.push(structuredClone(event)) },
+            webhookClientFactory: () => ({
+                fetch: async (input, init) => {
+                    const url = String(input);
+                    const body = String(init?.body);
+                    const payload = JSON.parse(body) as unknown;
+                    webhookCalls.push({ url, payload, headers: new Headers(init?.headers), body });
+                    return Response.json(await onWebhook(url, payload));
+                },
+            }),
+            ...this.overrides,
+        });
+
+        const server = createServer((req, res) => {
+            bridge(gateway, req, res).catch((error: unknown) => {
+                res.destroy(error instanceof Error ? error : new Error(String(error)));
+            });
+        });
+        await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+
+        return new TestGateway(gateway, server, store, new Map(this.providers.map((p) => [p.name, p])), webhookCalls, published);
+    }
+}
+
+// ============================================================================
+// Test Gateway
+// ============================================================================
+
+/**
+ * A running test gateway.
+ */
+export class TestGateway {
+    /** Base URL of the HTTP server. */
+    readonly url: string;
+
+    constructor(
+        readonly gateway: Gateway,
+        private readonly server: Server,
+        readonly store: MemoryStore,
+        readonly providers: Map<string, ScriptedProvider>,
+        readonly webhookCalls: WebhookCall[],
+        /** Lifecycle events published (interaction_completed, ...). */
+        readonly published: LifecycleEvent[],
+    ) {
+        const { port } = server.address() as AddressInfo;
+        this.url = `http://127.0.0.1:${port}`;
+    }
+
+    /** A provider by name. */
+    provider(name: string): ScriptedProvider {
+        const provider = this.providers.get(name);
+        if (!provider) {
+            throw new Error(`no provider '${name}'`);
+        }
+        return provider;
+    }
+
+    /**
+     * Sends a JSON request over HTTP, authenticated as the default tenant
+     * unless the headers carry their own Authorization.
+     */
+    post(path: string, body: unknown, headers: Record<string, string> = {}): Promise<Response> {
+        return this.request(path, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json', ...headers },
+            body: JSON.stringify(body),
+        });
+    }
+
+    /**
+     * Sends a request over HTTP, authenticated as the default tenant unless
+     * it carries its own Authorization.
+     */
+    request(path: string, init: RequestInit = {}): Promise<Response> {
+        const headers = new Headers(init.headers);
+        if (!headers.has('Authorization')) {
+            headers.set('Authorization', `Bearer ${DEFAULT_TENANT}`);
+        }
+        return httpFetch(`${this.url}${path}`, { ...init, headers });
+    }
+
+    /** Stops the server and the gateway. */
+    async close(): Promise<void> {
+        await new Promise<void>((resolve) => this.server.close(() => resolve()));
+        await this.gateway.close();
+    }
+}
+
+// ============================================================================
+// HTTP Bridge
+// ============================================================================
+
+/**
+ * Serves one Node request through the gateway, streaming the body back.
+ */
+async function bridge(gateway: Gateway, req: IncomingMessage, res: ServerResponse): Promise<void> {
+    const chunks: Buffer[] = [];
+    for await (const chunk of req) {
+        chunks.push(chunk as Buffer);
+    }
+    const headers = new Headers();
+    for (const [name, value] of Object.entries(req.headers)) {
+        for (const v of Array.isArray(value) ? value : value === undefined ? [] : [value]) {
+            headers.append(name, v);
+        }
+    }
+    const response = await gateway.fetch(new Request(`http://${req.headers.host}${req.url}`, {
+        method: req.method,
+        headers,
+        body: chunks.length > 0 ? Buffer.concat(chunks) : undefined,
+    }));
+
+    res.writeHead(response.status, Object.fromEntries(response.headers));
+    if (response.body) {
+        for await (const chunk of response.body) {
+            res.write(chunk);
+        }
+    }
+    res.end();
+}
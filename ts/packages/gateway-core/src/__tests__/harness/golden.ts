@@ -0,0 +1,50 @@
+/**
+ * Golden files for full response bodies. Run with UPDATE_GOLDEN=1 to
+ * write them; otherwise a body must match its file.
+ *
+ * @module __tests__/harness/golden
+ */
+
+import { existsSync, mkdirSync, readFileSync, writeFileSync } from 'node:fs';
+import { dirname } from 'node:path';
+import { fileURLToPath } from 'node:url';
+import { expect } from 'vitest';
+
+/** Where golden files live. */
+const GOLDEN_DIR = fileURLToPath(new URL('../golden/', import.meta.url));
+
+/** Fields that differ on every run, replaced before comparing. */
+const VOLATILE_FIELDS = new Set(['id', 'created', 'created_at', 'createdAt', 'previous_response_id', 'previousResponseId']);
+
+/**
+ * Replaces volatile fields (IDs, timestamps) with placeholders, at any depth.
+ */
+export function normalize(value: unknown): unknown {
+    if (Array.isArray(value)) {
+        return value.map(normalize);
+    }
+    if (value && typeof value === 'object') {
+        return Object.fromEntries(Object.entries(value).map(([key, v]) => [
+            key,
+            VOLATILE_FIELDS.has(key) && v != null ? `<${key}>` : normalize(v),
+        ]));
+    }
+    return value;
+}
+
+/**
+ * Checks a body against `golden/<name>.json`, after normalizing it.
+ */
+export function expectGolden(name: string, body: unknown): void {
+    const path = `${GOLDEN_DIR}${name}.json`;
+    const actual = `${JSON.stringify(normalize(body), null, 4)}\n`;
+    if (process.env.UPDATE_GOLDEN === '1') {
+        mkdirSync(dirname(path), { recursive: true });
+        writeFileSync(path, actual);
+        return;
+    }
+    if (!existsSync(path)) {
+        throw new Error(`missing golden file ${name}.json; run with UPDATE_GOLDEN=1 to write it`);
+    }
+    expect(actual, `golden file ${name}.json`).toBe(readFileSync(path, 'utf8'));
+}
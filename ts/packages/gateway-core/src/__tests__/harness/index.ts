@@ -0,0 +1,14 @@
+/**
+ * End-to-end test harness: boots a gateway over HTTP with scripted
+ * providers and an in-memory store, and runs conversations against it.
+ *
+ * @module __tests__/harness
+ */
+
+export { harness, HarnessBuilder, TestGateway, DEFAULT_TENANT, type WebhookHandler, type WebhookCall } from './gateway.js';
+export { ScriptedProvider, type Reply, type Script } from './provider.js';
+export { MemoryStore } from './store.js';
+export { scenario, Scenario, type Turn, type TurnResult } from './scenario.js';
+export { parseSSE, streamEvents, collapse, streamText, type SSEFrame, type StreamEvent, type StreamFormat } from './sse.js';
+export { expectGolden, normalize } from './golden.js';
+export { spyLogger, logged, type SpyLogger } from './logger.js';
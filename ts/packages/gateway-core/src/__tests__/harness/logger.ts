@@ -0,0 +1,43 @@
+/**
+ * Logger for end-to-end tests: every level is a spy, and children log to
+ * their parent, so a test can assert on anything the gateway logged.
+ *
+ * @module __tests__/harness/logger
+ */
+
+import { vi, type Mock } from 'vitest';
+import type { Logger } from '../../utils/logging.js';
+
+/** Logger signature of each level. */
+type LogMethod = (message: string, fields?: Record<string, unknown>) => void;
+
+/**
+ * A logger whose levels are spies.
+ */
+export interface SpyLogger extends Logger {
+    debug: Mock<LogMethod>;
+    info: Mock<LogMethod>;
+    warn: Mock<LogMethod>;
+    error: Mock<LogMethod>;
+}
+
+/**
+ * Creates a logger whose levels are spies.
+ */
+export function spyLogger(): SpyLogger {
+    const logger: SpyLogger = {
+        debug: vi.fn<LogMethod>(),
+        info: vi.fn<LogMethod>(),
+        warn: vi.fn<LogMethod>(),
+        error: vi.fn<LogMethod>(),
+        child: () => logger,
+    };
+    return logger;
+}
+
+/**
+ * Fields of every call to one level with the given message.
+ */
+export function logged(method: Mock<LogMethod>, message: string): Record<string, any>[] {
+    return method.mock.calls.filter(([m]) => m === message).map(([, fields]) => fields ?? {});
+}
@@ -0,0 +1,165 @@
+/**
+ * Scripted mock provider for end-to-end tests.
+ *
+ * @module __tests__/harness/provider
+ */
+
+import type {
+    APIType,
+    CanonicalEvent,
+    CanonicalRequest,
+    CanonicalResponse,
+    FinishReason,
+    Usage,
+} from '../../domain/types.js';
+import type { Provider, ProviderFactoryConfig } from '../../ports/provider.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * One scripted provider reply.
+ */
+export interface Reply {
+    /** Reply text; streamed as `chunks` when given, otherwise whole. */
+    text?: string | undefined;
+
+    /** Text pieces a stream delivers, one delta each (default: [text]). */
+    chunks?: string[] | undefined;
+
+    /** Tool calls, with arguments as JSON text. */
+    toolCalls?: Array<{ id: string; name: string; arguments: string }> | undefined;
+
+    /** Finish reason (default: tool_calls with tool calls, else stop). */
+    finishReason?: FinishReason | undefined;
+
+    /** Usage (default: 10 prompt and 5 completion tokens). */
+    usage?: Usage | undefined;
+
+    /** Stream events to send verbatim instead of ones built from the reply. */
+    events?: CanonicalEvent[] | undefined;
+
+    /** Delay before replying, in milliseconds. */
+    delayMs?: number | undefined;
+
+    /** Error to throw instead of replying. */
+    error?: Error | undefined;
+}
+
+/**
+ * How a provider replies: always the same, in turn (the last repeating),
+ * or computed from the request and its call number.
+ */
+export type Script = Reply | Reply[] | ((request: CanonicalRequest, call: number) => Reply);
+
+/** Default usage of a scripted reply. */
+const DEFAULT_USAGE: Usage = { promptTokens: 10, completionTokens: 5, totalTokens: 15 };
+
+// ============================================================================
+// Scripted Provider
+// ============================================================================
+
+/**
+ * A provider that answers from a script and keeps every request it got.
+ */
+export class ScriptedProvider implements Provider {
+    readonly name: string;
+    readonly apiType: APIType;
+    readonly requests: CanonicalRequest[] = [];
+
+    /** Config the gateway last created the provider with (credentials, ...). */
+    config: ProviderFactoryConfig | undefined;
+
+    private readonly script: Script;
+
+    constructor(name: string, script: Script, apiType: APIType = 'openai') {
+        this.name = name;
+        this.apiType = apiType;
+        this.script = script;
+    }
+
+    /** The last request received. */
+    get lastRequest(): CanonicalRequest | undefined {
+        return this.requests[this.requests.length - 1];
+    }
+
+    async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+        const reply = await this.next(request);
+        const call = this.requests.length;
+        return {
+            id: `chatcmpl-mock-${call}`,
+            object: 'chat.completion',
+            created: 0,
+            model: request.model,
+            choices: [{
+                index: 0,
+                message: {
+                    role: 'assistant',
+                    content: reply.text ?? reply.chunks?.join('') ?? '',
+                    toolCalls: reply.toolCalls?.map((tc) => ({
+                        id: tc.id,
+                        type: 'function' as const,
+                        function: { name: tc.name, arguments: tc.arguments },
+                    })),
+                },
+                finishReason: finishReasonOf(reply),
+            }],
+            usage: reply.usage ?? DEFAULT_USAGE,
+        };
+    }
+
+    async *stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent, void, void> {
+        const reply = await this.next(request);
+        if (reply.events) {
+            yield* reply.events;
+            return;
+        }
+
+        const call = this.requests.length;
+        yield { type: 'message_start', role: 'assistant', model: request.model, responseId: `chatcmpl-mock-${call}` };
+        let index = 0;
+        const chunks = reply.chunks ?? (reply.text !== undefined ? [reply.text] : []);
+        if (chunks.length > 0) {
+            yield { type: 'content_block_start', index, contentBlock: { type: 'text', text: '' } };
+            for (const chunk of chunks) {
+                yield { type: 'content_block_delta', index, contentDelta: chunk };
+            }
+            yield { type: 'content_block_stop', index };
+            index++;
+        }
+        for (const [i, tc] of (reply.toolCalls ?? []).entries()) {
+            yield {
+                type: 'content_block_start',
+                index,
+                toolCall: { index: i, id: tc.id, type: 'function', function: { name: tc.name, arguments: '' } },
+            };
+            yield { type: 'content_block_delta', index, toolCall: { index: i, function: { arguments: tc.arguments } } };
+            yield { type: 'content_block_stop', index };
+            index++;
+        }
+        yield { type: 'message_stop', finishReason: finishReasonOf(reply), usage: reply.usage ?? DEFAULT_USAGE };
+        yield { type: 'done' };
+    }
+
+    private async next(request: CanonicalRequest): Promise<Reply> {
+        this.requests.push(request);
+        const call = this.requests.length - 1;
+        const reply = typeof this.script === 'function'
+            ? this.script(request, call)
+            : Array.isArray(this.script)
+                ? this.script[Math.min(call, this.script.length - 1)]!
+                : this.script;
+        if (reply.delayMs) {
+            await new Promise((resolve) => setTimeout(resolve, reply.delayMs));
+        }
+        if (reply.error) {
+            throw reply.error;
+        }
+        return reply;
+    }
+}
+
+function finishReasonOf(reply: Reply): FinishReason {
+    return reply.finishReason ?? (reply.toolCalls?.length ? 'tool_calls' : 'stop');
+}
@@ -0,0 +1,178 @@
+/**
+ * Scenario DSL for end-to-end tests: a conversation as a list of turns,
+ * each with the routing, stored interaction events, stream events, and
+ * body it should produce.
+ *
+ * @module __tests__/harness/scenario
+ */
+
+import { expect, vi } from 'vitest';
+import type { InteractionEventType } from '../../domain/events.js';
+import type { CanonicalRequest } from '../../domain/types.js';
+import { INTERACTION_ID_HEADER } from '../../correlation/index.js';
+import type { TestGateway } from './gateway.js';
+import { expectGolden } from './golden.js';
+import { collapse, streamEvents, type StreamEvent, type StreamFormat } from './sse.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * One request of a scenario and what it should produce.
+ */
+export interface Turn {
+    /** Request path. */
+    path: string;
+
+    /** Request body, or one built from the turns before it. */
+    body: object | ((previous: TurnResult[]) => object);
+
+    /** Extra request headers. */
+    headers?: Record<string, string> | undefined;
+
+    /** Stream format to parse (default: anthropic for /messages, else openai). */
+    format?: StreamFormat | undefined;
+
+    /** Expected status (default: 200). */
+    status?: number | undefined;
+
+    /** Provider that should serve the turn. */
+    provider?: string | undefined;
+
+    /** Checks on the request the provider got. */
+    sent?: ((request: CanonicalRequest) => void) | undefined;
+
+    /** Interaction events the turn should store, in order, among others. */
+    stored?: InteractionEventType[] | undefined;
+
+    /** Stream events, with text and tool argument runs joined. */
+    events?: StreamEvent[] | undefined;
+
+    /** Golden file the (JSON) body should match. */
+    golden?: string | undefined;
+}
+
+/**
+ * What a turn produced.
+ */
+export interface TurnResult {
+    status: number;
+    headers: Headers;
+    body: string;
+
+    /** The body as JSON, for non-streaming turns. */
+    json?: any;
+
+    /** The stream's events, collapsed, for streaming turns. */
+    events?: StreamEvent[] | undefined;
+
+    /** The gateway's interaction ID. */
+    interactionId: string;
+
+    /** The request the serving provider got. */
+    sent?: CanonicalRequest | undefined;
+}
+
+// ============================================================================
+// Scenario
+// ============================================================================
+
+/**
+ * Starts a scenario against a test gateway.
+ */
+export function scenario(gateway: TestGateway): Scenario {
+    return new Scenario(gateway);
+}
+
+/**
+ * A conversation run turn by turn; each turn is checked before the next
+ * is sent.
+ */
+export class Scenario {
+    private readonly turns: Turn[] = [];
+
+    constructor(private readonly gateway: TestGateway) { }
+
+    /** Adds a turn. */
+    turn(turn: Turn): this {
+        this.turns.push(turn);
+        return this;
+    }
+
+    /**
+     * Sends every turn and checks its expectations.
+     */
+    async run(): Promise<TurnResult[]> {
+        const results: TurnResult[] = [];
+        for (const [i, turn] of this.turns.entries()) {
+            results.push(await this.runTurn(turn, results, `turn ${i + 1}`));
+        }
+        return results;
+    }
+
+    private async runTurn(turn: Turn, previous: TurnResult[], label: string): Promise<TurnResult> {
+        const counts = new Map([...this.gateway.providers].map(([name, p]) => [name, p.requests.length]));
+        const body = typeof turn.body === 'function' ? turn.body(previous) : turn.body;
+        const response = await this.gateway.post(turn.path, body, turn.headers);
+        const text = await response.text();
+        const streamed = response.headers.get('Content-Type')?.startsWith('text/event-stream') ?? false;
+
+        const served = [...this.gateway.providers].filter(([name, p]) => p.requests.length > counts.get(name)!);
+        const result: TurnResult = {
+            status: response.status,
+            headers: response.headers,
+            body: text,
+            json: streamed ? undefined : parseJSON(text),
+            events: streamed ? collapse(streamEvents(text, turn.format ?? formatOf(turn.path))) : undefined,
+            interactionId: response.headers.get(INTERACTION_ID_HEADER) ?? '',
+            sent: served[0]?.[1].lastRequest,
+        };
+
+        expect(result.status, `${label} status: ${text}`).toBe(turn.status ?? 200);
+        if (turn.provider !== undefined) {
+            expect(served.map(([name]) => name), `${label} provider`).toEqual([turn.provider]);
+        }
+        if (turn.sent) {
+            expect(result.sent, `${label} provider request`).toBeDefined();
+            turn.sent(result.sent!);
+        }
+        if (turn.events) {
+            expect(result.events, `${label} stream events`).toEqual(turn.events);
+        }
+        if (turn.stored) {
+            // Some events are saved after the response completes
+            await vi.waitFor(() => {
+                const types = this.gateway.store.eventsFor(result.interactionId).map((e) => e.type);
+                expect(inOrder(types, turn.stored!), `${label} stored ${types.join(', ')}`).toBe(true);
+            });
+        }
+        if (turn.golden) {
+            expectGolden(turn.golden, result.json);
+        }
+        return result;
+    }
+}
+
+function formatOf(path: string): StreamFormat {
+    return path.endsWith('/messages') ? 'anthropic' : 'openai';
+}
+
+function parseJSON(text: string): unknown {
+    try {
+        return JSON.parse(text) as unknown;
+    } catch {
+        return undefined;
+    }
+}
+
+/**
+ * Whether `expected` appears in `actual` in order, other entries between.
+ */
+function inOrder<T>(actual: T[], expected: T[]): boolean {
+    let next = 0;
+    for (const item of actual) {
+        if (item === expected[next]) next++;
+    }
+    return next === expected.length;
+}
@@ -0,0 +1,181 @@
+/**
+ * SSE parsing for end-to-end tests. OpenAI and Anthropic streams are
+ * reduced to the same event list, so a scenario can expect one sequence
+ * from either frontdoor.
+ *
+ * @module __tests__/harness/sse
+ */
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * One SSE frame.
+ */
+export interface SSEFrame {
+    /** Event name, if the frame has one. */
+    event?: string | undefined;
+
+    /** Data (multi-line data joined with newlines). */
+    data: string;
+
+    /** Event ID, if the frame has one. */
+    id?: string | undefined;
+}
+
+/** Stream formats the event list can be built from. */
+export type StreamFormat = 'openai' | 'anthropic';
+
+/**
+ * A protocol-neutral stream event. Stop reasons use OpenAI's names.
+ */
+export type StreamEvent =
+    | { type: 'start' }
+    | { type: 'text'; text: string }
+    | { type: 'tool_call'; id: string | undefined; name: string | undefined }
+    | { type: 'tool_args'; text: string }
+    | { type: 'stop'; reason: string | null }
+    | { type: 'gateway'; name: string; data: unknown }
+    | { type: 'error'; message: string }
+    | { type: 'done' };
+
+/** Anthropic stop reasons by OpenAI finish reason. */
+const STOP_REASONS: Record<string, string> = {
+    end_turn: 'stop',
+    stop_sequence: 'stop',
+    max_tokens: 'length',
+    tool_use: 'tool_calls',
+    refusal: 'content_filter',
+};
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+/**
+ * Splits an SSE body into frames. Comment lines are skipped.
+ */
+export function parseSSE(body: string): SSEFrame[] {
+    const frames: SSEFrame[] = [];
+    for (const block of body.split(/\r?\n\r?\n/)) {
+        const frame: SSEFrame = { data: '' };
+        const data: string[] = [];
+        for (const line of block.split(/\r?\n/)) {
+            const colon = line.indexOf(':');
+            if (colon === 0 || line === '') continue;
+            const field = colon < 0 ? line : line.slice(0, colon);
+            const value = colon < 0 ? '' : line.slice(colon + 1).replace(/^ /, '');
+            if (field === 'data') data.push(value);
+            else if (field === 'event') frame.event = value;
+            else if (field === 'id') frame.id = value;
+        }
+        if (data.length > 0 || frame.event !== undefined) {
+            frame.data = data.join('\n');
+            frames.push(frame);
+        }
+    }
+    return frames;
+}
+
+/**
+ * Reduces a stream to protocol-neutral events. Gateway extension events
+ * (gateway.*) are kept by name.
+ */
+export function streamEvents(body: string, format: StreamFormat): StreamEvent[] {
+    const events: StreamEvent[] = [];
+    for (const frame of parseSSE(body)) {
+        if (frame.event?.startsWith('gateway.')) {
+            events.push({ type: 'gateway', name: frame.event, data: JSON.parse(frame.data) as unknown });
+            continue;
+        }
+        if (frame.data === '[DONE]') {
+            events.push({ type: 'done' });
+            continue;
+        }
+        const json = JSON.parse(frame.data) as any;
+        if (json.error) {
+            events.push({ type: 'error', message: String(json.error.message ?? json.error) });
+            continue;
+        }
+        events.push(...(format === 'openai' ? openAIEvents(json, events.length === 0) : anthropicEvents(json)));
+    }
+    return events;
+}
+
+function openAIEvents(chunk: any, first: boolean): StreamEvent[] {
+    const events: StreamEvent[] = [];
+    const choice = chunk.choices?.[0];
+    if (first) {
+        events.push({ type: 'start' });
+    }
+    if (!choice) {
+        return events;
+    }
+    if (choice.delta?.content) {
+        events.push({ type: 'text', text: choice.delta.content });
+    }
+    for (const tc of choice.delta?.tool_calls ?? []) {
+        if (tc.id || tc.function?.name) {
+            events.push({ type: 'tool_call', id: tc.id, name: tc.function?.name });
+        }
+        if (tc.function?.arguments) {
+            events.push({ type: 'tool_args', text: tc.function.arguments });
+        }
+    }
+    if (choice.finish_reason) {
+        events.push({ type: 'stop', reason: choice.finish_reason });
+    }
+    return events;
+}
+
+function anthropicEvents(event: any): StreamEvent[] {
+    switch (event.type) {
+        case 'message_start':
+            return [{ type: 'start' }];
+        case 'content_block_start':
+            return event.content_block?.type === 'tool_use'
+                ? [{ type: 'tool_call', id: event.content_block.id, name: event.content_block.name }]
+                : [];
+        case 'content_block_delta':
+            if (event.delta?.type === 'text_delta' && event.delta.text) {
+                return [{ type: 'text', text: event.delta.text }];
+            }
+            if (event.delta?.type === 'input_json_delta' && event.delta.partial_json) {
+                return [{ type: 'tool_args', text: event.delta.partial_json }];
+            }
+            return [];
+        case 'message_delta':
+            return event.delta?.stop_reason !== undefined
+                ? [{ type: 'stop', reason: STOP_REASONS[event.delta.stop_reason] ?? event.delta.stop_reason }]
+                : [];
+        case 'message_stop':
+            return [{ type: 'done' }];
+        default:
+            return [];
+    }
+}
+
+/**
+ * Joins runs of text, and of tool argument, events, so the list no longer
+ * depends on how the provider chunked its output.
+ */
+export function collapse(events: StreamEvent[]): StreamEvent[] {
+    const out: StreamEvent[] = [];
+    for (const event of events) {
+        const last = out[out.length - 1];
+        if (last && (event.type === 'text' || event.type === 'tool_args') && last.type === event.type) {
+            last.text += event.text;
+        } else {
+            out.push({ ...event });
+        }
+    }
+    return out;
+}
+
+/**
+ * The text a stream delivered.
+ */
+export function streamText(events: StreamEvent[]): string {
+    return events.map((e) => (e.type === 'text' ? e.text : '')).join('');
+}
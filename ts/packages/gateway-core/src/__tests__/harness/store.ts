@@ -0,0 +1,189 @@
+/**
+ * In-memory store for end-to-end tests: the interaction events and
+ * records, Responses API records, threads, and idempotency keys a gateway
+ * saves.
+ *
+ * @module __tests__/harness/store
+ */
+
+import type { InteractionEvent, InteractionEventType } from '../../domain/events.js';
+import type {
+    AddMessageOptions,
+    AddMessageResult,
+    IdempotencyRecord,
+    InteractionListOptions,
+    InteractionRecord,
+    InteractionSummary,
+    InteractionUpdate,
+    ResponseRecord,
+    StoredHTTPResponse,
+    StoredMessage,
+    StoredThread,
+} from '../../ports/storage.js';
+import { findDuplicateMessage } from '../../messagededup/dedup.js';
+
+/**
+ * Keeps what the gateway stores, copied on save.
+ */
+export class MemoryStore {
+    readonly events: InteractionEvent[] = [];
+    readonly interactions = new Map<string, InteractionRecord>();
+    readonly responses = new Map<string, ResponseRecord>();
+    readonly threads = new Map<string, StoredThread>();
+    readonly idempotencyKeys = new Map<string, IdempotencyRecord>();
+
+    async saveEvent(event: InteractionEvent): Promise<void> {
+        this.events.push(structuredClone(event));
+    }
+
+    async getEvents(interactionId: string): Promise<InteractionEvent[]> {
+        return this.eventsFor(interactionId);
+    }
+
+    async recordInteraction(record: InteractionRecord): Promise<void> {
+        this.interactions.set(record.id, structuredClone(record));
+    }
+
+    async updateInteraction(id: string, update: InteractionUpdate): Promise<boolean> {
+        const record = this.interactions.get(id);
+        if (!record) return false;
+        this.interactions.set(id, { ...record, ...structuredClone(update) });
+        return true;
+    }
+
+    async getInteraction(id: string, tenantId: string): Promise<InteractionRecord | null> {
+        const record = this.interactions.get(id);
+        return record && (tenantId === '' || record.tenantId === tenantId) ? structuredClone(record) : null;
+    }
+
+    async abandonInteractions(before: Date, updatedAt: Date): Promise<number> {
+        const lost = [...this.interactions.values()].filter((r) => r.status === 'in_progress' && r.createdAt < before);
+        for (const record of lost) {
+            this.interactions.set(record.id, { ...record, status: 'abandoned', updatedAt });
+        }
+        return lost.length;
+    }
+
+    async listInteractions(options?: InteractionListOptions): Promise<InteractionSummary[]> {
+        // Only the request records; newest first
+        const offset = options?.offset ?? 0;
+        return this.requests(options)
+            .sort((a, b) => b.createdAt.getTime() - a.createdAt.getTime())
+            .slice(offset, offset + (options?.limit ?? 50))
+            .map((r) => ({
+                id: r.id,
+                type: 'request',
+                tenantId: r.tenantId,
+                appName: r.appName,
+                model: r.model,
+                provider: r.provider,
+                status: r.status,
+                createdAt: r.createdAt,
+                updatedAt: r.updatedAt,
+            }));
+    }
+
+    async getInteractionCount(options?: InteractionListOptions): Promise<number> {
+        return this.requests(options).length;
+    }
+
+    async getConversation(): Promise<null> {
+        // The harness paths record responses, not conversations
+        return null;
+    }
+
+    async saveResponse(record: ResponseRecord): Promise<void> {
+        this.responses.set(record.id, structuredClone(record));
+    }
+
+    async getResponse(id: string, tenantId: string): Promise<ResponseRecord | null> {
+        const record = this.responses.get(id);
+        return record && (tenantId === '' || record.tenantId === tenantId) ? structuredClone(record) : null;
+    }
+
+    async updateResponse(id: string, updates: Partial<ResponseRecord>): Promise<void> {
+        const record = this.responses.get(id);
+        if (record) {
+            this.responses.set(id, { ...record, ...structuredClone(updates) });
+        }
+    }
+
+    async listResponses(tenantId: string): Promise<ResponseRecord[]> {
+        return [...this.responses.values()].filter((r) => r.tenantId === tenantId).map((r) => structuredClone(r));
+    }
+
+    async createThread(thread: StoredThread): Promise<void> {
+        this.threads.set(thread.id, structuredClone(thread));
+    }
+
+    async getThread(id: string, tenantId: string): Promise<StoredThread | null> {
+        const thread = this.threads.get(id);
+        return thread && thread.tenantId === tenantId ? structuredClone(thread) : null;
+    }
+
+    async addMessage(threadId: string, message: StoredMessage, options?: AddMessageOptions): Promise<AddMessageResult | null> {
+        const thread = this.threads.get(threadId);
+        if (!thread) return null;
+        const duplicate = findDuplicateMessage(thread.messages, message, options?.dedupWindow);
+        if (duplicate) {
+            return { id: duplicate.message.id, duplicate: duplicate.reason };
+        }
+        thread.messages.push(structuredClone(message));
+        return { id: message.id };
+    }
+
+    async listMessages(threadId: string): Promise<StoredMessage[]> {
+        return structuredClone(this.threads.get(threadId)?.messages ?? []);
+    }
+
+    async claimIdempotencyKey(record: IdempotencyRecord): Promise<IdempotencyRecord | null> {
+        const existing = await this.getIdempotencyRecord(record.tenantId, record.key);
+        if (existing) return existing;
+        this.idempotencyKeys.set(`${record.tenantId}:${record.key}`, structuredClone(record));
+        return null;
+    }
+
+    async getIdempotencyRecord(tenantId: string, key: string): Promise<IdempotencyRecord | null> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        return record && record.expiresAt.getTime() > Date.now() ? structuredClone(record) : null;
+    }
+
+    async renewIdempotencyKey(tenantId: string, key: string, interactionId: string, expiresAt: Date): Promise<void> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        if (record?.interactionId === interactionId && record.status === 'in_progress') {
+            this.idempotencyKeys.set(`${tenantId}:${key}`, { ...record, expiresAt });
+        }
+    }
+
+    async completeIdempotencyKey(tenantId: string, key: string, response: StoredHTTPResponse, expiresAt: Date): Promise<void> {
+        const record = this.idempotencyKeys.get(`${tenantId}:${key}`);
+        if (record) {
+            this.idempotencyKeys.set(`${tenantId}:${key}`, { ...record, status: 'completed', response: structuredClone(response), expiresAt });
+        }
+    }
+
+    async releaseIdempotencyKey(tenantId: string, key: string, interactionId: string): Promise<void> {
+        if (this.idempotencyKeys.get(`${tenantId}:${key}`)?.interactionId === interactionId) {
+            this.idempotencyKeys.delete(`${tenantId}:${key}`);
+        }
+    }
+
+    private requests(options: InteractionListOptions | undefined): InteractionRecord[] {
+        return [...this.interactions.values()].filter((r) =>
+            (!options?.tenantId || r.tenantId === options.tenantId) && (!options?.status || r.status === options.status));
+    }
+
+    /**
+     * Events saved for one interaction, in order.
+     */
+    eventsFor(interactionId: string): InteractionEvent[] {
+        return this.events.filter((e) => e.interactionId === interactionId);
+    }
+
+    /**
+     * Events of one type, across interactions.
+     */
+    eventsOf(type: InteractionEventType): InteractionEvent[] {
+        return this.events.filter((e) => e.type === type);
+    }
+}
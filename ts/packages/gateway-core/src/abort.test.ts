@@ -0,0 +1,78 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { createServer, type Server } from 'node:http';
+import type { AddressInfo } from 'node:net';
+import { Gateway } from './gateway';
+import type { LifecycleEvent } from './domain/events';
+
+/** Mock upstream that answers after 2s, noting when a connection closes. */
+let server: Server;
+let baseUrl: string;
+let closedAt = 0;
+
+beforeAll(async () => {
+    server = createServer((req, res) => {
+        const timer = setTimeout(() => res.writeHead(200, { 'Content-Type': 'application/json' }).end(JSON.stringify({
+            id: 'chatcmpl-1',
+            object: 'chat.completion',
+            created: 0,
+            model: 'gpt-4o',
+            choices: [{ index: 0, message: { role: 'assistant', content: 'Hello' }, finish_reason: 'stop' }],
+            usage: { prompt_tokens: 3, completion_tokens: 1, total_tokens: 4 },
+        })), 2000);
+        res.on('close', () => {
+            clearTimeout(timer);
+            closedAt = Date.now();
+        });
+        req.resume();
+    });
+    await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+    baseUrl = `http://127.0.0.1:${(server.address() as AddressInfo).port}`;
+});
+
+afterAll(async () => {
+    server.closeAllConnections();
+    await new Promise((resolve) => server.close(resolve));
+});
+
+describe('Client aborts', () => {
+    it('should cancel the provider call and record the request as cancelled', async () => {
+        const published: LifecycleEvent[] = [];
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'chat', frontdoor: 'openai', path: '/v1' }],
+                    providers: [{ name: 'openai', type: 'openai', apiKey: 'sk-test', baseUrl }],
+                    routing: { defaultProvider: 'openai' },
+                }),
+            },
+            auth: {
+                authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }),
+                getTenant: async () => null,
+            },
+            events: { publish: async (e: LifecycleEvent) => void published.push(e), close: async () => { } },
+        });
+        const client = new AbortController();
+        const abortedAt = Date.now() + 100;
+        setTimeout(() => client.abort(), 100);
+
+        await gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] }),
+            signal: client.signal,
+        }));
+
+        // The upstream connection is torn down at the abort, not after 2s
+        await expect.poll(() => closedAt).toBeGreaterThan(0);
+        expect(closedAt - abortedAt).toBeLessThan(500);
+
+        await expect.poll(() => published.find((e) => e.type === 'interaction_completed')).toBeDefined();
+        const completed = published.find((e) => e.type === 'interaction_completed')!;
+        expect(completed.data).toMatchObject({ status: 'cancelled', statusCode: 499, errorType: 'client_cancelled', model: 'gpt-4o' });
+
+        const [stats] = gateway.clientAbortStats();
+        expect(stats).toMatchObject({ app: 'chat', aborted: 1 });
+        expect(stats!.elapsedMs.p50).toBeGreaterThan(50);
+        expect(stats!.elapsedMs.p50).toBeLessThan(1000);
+    });
+});
@@ -2,18 +2,188 @@
  * Admin handler for control plane API.
  *
  * Provides REST endpoints for gateway administration:
- * - /api/stats - System statistics
- * - /api/overview - Configuration overview
- * - /api/interactions - List/view interactions
+ * - /api/stats - System statistics:
+ *   - uptime and memory
+ *   - latency percentiles
+ *   - analytics, mirror, and spill counters
+ *   - deadline, call budget, and client abort counters
+ *   - per-tenant provider concurrency
+ *   - storage health and dual-write counters
+ *   - usage discrepancies per provider and model
+ *   - coalesced requests and thread appends skipped as duplicates
+ *   - response classification counters
+ *   - unmatched request counts
+ * - /api/overview - Configuration overview, with the parameter policies of apps and models
+ * - /api/interactions - List/view interactions (with their provider attempts), or find them by:
+ *   - status=<in_progress|completed|failed|cancelled|abandoned> - lifecycle status
+ *   - metadata.<key>=<value> - correlation header
+ *   - end_user=<id or hash> - end user
+ *   - language=<code>, safety=<category or *> - response classification
+ *   - finish_reason=length (with app=, model=) - cut off at max_tokens; counted per app and model
+ *   - has_usage_discrepancy=true (with provider=, model=) - reported tokens disagreed with the output; counted per provider and model
  * - /api/threads - List/view threads
  * - /api/responses - List/view responses
+ * - /api/interactions/:id/shadows/:shadowId/diff - Primary vs shadow diff
+ * - /api/interactions/:id/provider-request - Exact request bodies sent upstream, with their SHA-256
+ * - /api/interactions/:id/tail - Follow an interaction as SSE: its saved events, new ones as they are logged, then its final status (?wait for one not started yet)
+ * - /api/providers/health - Provider status and connection pool stats
+ * - /api/providers/:name/probes - Recent synthetic probe results and rolling success rate
+ * - /api/slo - Per-app SLO compliance, error budget remaining, and burn rates
+ * - /api/models - Effective model catalog
+ * - /api/routes - Every method and path served, with the app and frontdoor that serve it
+ * - /api/routing/test - How a model would be routed for an app and tenant (?model, ?app, ?tenant), without calling anything
+ * - /api/templates - Prompt templates with their versions
+ * - /api/tenants - List tenants; create one (with its initial API key)
+ * - /api/tenants/:id - Get, update, or disable (DELETE) a tenant
+ * - /api/tenants/:id/enable, /api/tenants/:id/disable - Toggle a tenant
+ * - /api/tenants/:id/budget - Monthly budget consumption
+ * - /api/tenants/:id/secrets - A tenant's secrets' metadata (values are never returned)
+ * - /api/tenants/:id/secrets/:name - Secret metadata; PUT sets or rotates its value, DELETE removes it
+ * - /api/tenants/:id/usage - Usage report by model and day, or ?group_by=reason to split provider attempts by reason, ?group_by=end_user by end-user hash, or ?group_by=language by response language with safety-flagged counts (as the tenant's GET /v1/usage), with the period's usage discrepancies per provider and model
+ * - /api/schema/canonical-request - Canonical request JSON Schema
+ * - /api/schema/canonical-response - Canonical response JSON Schema
+ * - /api/openapi/data-plane.json - OpenAPI document for every route the data plane serves
+ * - /api/openapi/admin.json - OpenAPI document for this API
+ * - /api/privacy/erase - Start erasing an end user's stored interactions
+ * - /api/privacy/jobs/:id - Erasure job status and scrubbed row counts
+ * - /api/logging - Log level and scoped debug overrides; PUT changes, DELETE resets
+ * - /api/maintenance/replay-spill - Replay spilled usage writes now (POST)
+ * - /api/maintenance/rewrap - Re-encrypt stored data under the newest storage key (POST, ?batch_size)
+ * - /api/maintenance/migrate-model - Rewrite a model to another at runtime, optionally resetting its threads (POST)
+ * - /api/maintenance/migrate-model/:id - Model migration job status and cleared mapping counts
+ * - /api/maintenance/backfill - Copy the primary store's rows to the secondary while dual-writing (POST, ?batch_size)
+ * - /api/maintenance/backfill/:id - Backfill job status and copied row counts
+ * - /api/maintenance/consistency - Compare a sample of interactions on both dual-write stores (?sample)
+ * - /api/console/execute - Run a test request through an app, showing each stage; dry_run skips the provider (POST)
+ * - /api/thread-state - Thread state mappings by key hash (?limit, ?cursor)
+ * - /api/thread-state/:hash - A mapping with the interactions that resolved or updated it; DELETE removes it
+ * - /api/thread-state/purge - Remove mappings not updated within ?older_than (POST)
+ * - /api/export/threads - Export tenants' conversations, responses, and thread state as a portable bundle (POST)
+ * - /api/import/threads - Import a bundle from another deployment, remapping taken IDs; repeats are no-ops (POST)
+ *
+ * When an auth provider is configured every endpoint except /api/health
+ * requires a bearer token. Tokens with an operator scope see all tenants;
+ * other tokens only see their own tenant's data.
+ *
+ * Requests are routed through the admin operations the OpenAPI document
+ * is built from (see admin/routes), whose auth requirements decide which
+ * tokens each endpoint accepts.
  *
  * @module admin/handler
  */
 
-import type { StorageProvider } from '../ports/storage.js';
+import type {
+    StorageProvider,
+    ErasureSelector,
+    InteractionMetadataRecord,
+    MetadataIndexStore,
+    AttemptStore,
+    InteractionLifecycleStatus,
+    InteractionListOptions,
+    InteractionAttemptRecord,
+    RequestStatClassificationFilter,
+    RequestStatRecord,
+    ThreadStateEntry,
+    ThreadStateStore,
+} from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+import type { AuthProvider } from '../ports/auth.js';
+import { extractBearerToken } from '../ports/auth.js';
 import type { ConfigProvider } from '../ports/config.js';
+import type { HTTPPoolStats } from '../ports/provider.js';
+import type { ProviderKeyHealth } from '../providers/keys.js';
+import type { ProbeReport, ProbeSummary } from '../probe/prober.js';
+import type { SloReport } from '../slo/tracker.js';
+import type { ModelInfo } from '../domain/catalog.js';
+import type { InteractionEvent } from '../domain/events.js';
+import type { CanonicalResponse } from '../domain/types.js';
+import { CANONICAL_REQUEST_SCHEMA, CANONICAL_RESPONSE_SCHEMA } from '../domain/schema.js';
+import { buildAdminDocument, buildDataPlaneDocument } from '../openapi/document.js';
+import { matchAdminRoute, type AdminRouteKey } from './routes.js';
+import {
+    type DiffSide,
+    diffResponses,
+    fromCanonicalResponse,
+    fromShadowResponse,
+} from '../domain/divergence.js';
 import type { Logger } from '../utils/logging.js';
+import { defaultLogger, LOG_LEVELS } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+import type { LogControl, LoggingChange, LogScope } from '../logging/control.js';
+import type { LatencySummary } from '../utils/timings.js';
+import type { BudgetStatus } from '../budget/accountant.js';
+import type { UsageReports } from '../usage/report.js';
+import type { EventSinkStats } from '../analytics/publisher.js';
+import type { MirrorStats } from '../mirror/mirror.js';
+import type { ClientAbortStats, DeadlineCancellationStats } from '../providers/deadline.js';
+import type { CallBudgetStats } from '../callbudget/budget.js';
+import type { ParameterPolicySummary } from '../parampolicy/policy.js';
+import type { CoalescingStats } from '../coalescing/coalescer.js';
+import type { MessageDedupStats } from '../messagededup/dedup.js';
+import type { RoutingDecision, RoutingTestQuery } from '../router.js';
+import type { SpillReplayResult, SpillStats } from '../spill/queue.js';
+import type { StorageHealthStats } from '../storagehealth/supervisor.js';
+import type { ClassificationStats } from '../classification/worker.js';
+import { FINISH_REASON_METADATA_KEY } from '../warnings/truncation.js';
+import { USAGE_DISCREPANCY_METADATA_KEY, type UsageDiscrepancyStats } from '../usagecheck/check.js';
+import type { ConcurrencyStats } from '../concurrency/scheduler.js';
+import type { RegisteredRoute } from '../routes/table.js';
+import type { UnmatchedRouteStats } from '../routes/unmatched.js';
+import { MAX_REWRAP_BATCH_SIZE, type RewrapResult } from '../encryption/rewrap.js';
+import {
+    ConsoleLimiter,
+    parseConsoleRequest,
+    type ConsoleRate,
+    type ConsoleRequest,
+    type ConsoleResult,
+} from '../console/execute.js';
+import type { TenantRegistry, CreateTenantInput, UpdateTenantInput } from '../tenants/registry.js';
+import type { SecretManager } from '../secrets/manager.js';
+import { expandTranscripts } from '../recorder/stream.js';
+import type { ProviderRequestPayload } from '../recorder/raw.js';
+import type { InteractionTails, TailMessage } from '../tail/tails.js';
+import type { RoutingConfig, RoutingRule } from '../ports/config.js';
+import { APIError } from '../domain/errors.js';
+import type { PromptTemplate } from '../templates/prompt.js';
+import { ErasureJobs, isErasureStore } from '../privacy/erasure.js';
+import type { ModelMigrationJobs, ModelMigrationRequest } from '../modelmigration/jobs.js';
+import type { DualWriteStats, DualWriteStorage } from '../dualwrite/storage.js';
+import {
+    DEFAULT_CONSISTENCY_SAMPLE,
+    MAX_BACKFILL_BATCH_SIZE,
+    MAX_CONSISTENCY_SAMPLE,
+} from '../dualwrite/backfill.js';
+import { isMetadataIndexStore } from '../correlation/store.js';
+import { isAttemptStore } from '../usage/attempts.js';
+import { INTERACTION_LIFECYCLE_STATUSES } from '../interactions/index.js';
+import { parseAffinityState, threadStateIndexKey, THREAD_STATE_TOUCHED } from '../affinity/affinity.js';
+import {
+    exportThreads,
+    importThreads,
+    isThreadTransferStore,
+    parseThreadBundle,
+    MAX_IMPORT_BATCH_SIZE,
+    type ThreadImportOptions,
+} from '../transfer/bundle.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default thread state page size. */
+const DEFAULT_THREAD_STATE_PAGE = 50;
+
+/** Largest thread state page size. */
+const MAX_THREAD_STATE_PAGE = 500;
+
+/** Most recent interactions shown in a thread state chain. */
+const MAX_THREAD_STATE_CHAIN = 100;
+
+/** Default time a tail waits for an interaction not seen yet (ms). */
+const DEFAULT_TAIL_WAIT_MS = 10_000;
+
+/** Longest a tail waits for an interaction not seen yet (ms). */
+const MAX_TAIL_WAIT_MS = 60_000;
 
 // ============================================================================
 // Types
@@ -34,8 +204,128 @@ export interface AdminHandlerOptions {
 
     /** Gateway start time. */
     startTime?: Date | undefined;
+
+    /** Provider health source (typically Gateway.providerHealth). */
+    providerHealth?: (() => ProviderHealthSummary[]) | undefined;
+
+    /** Provider probe results source (typically Gateway.probeReport). */
+    probes?: ((provider: string, limit: number) => Promise<ProbeReport | undefined>) | undefined;
+
+    /** Per-app SLO source (typically Gateway.sloReport). */
+    slo?: (() => SloReport[]) | undefined;
+
+    /** Model catalog source (typically Gateway.modelCatalog.list). */
+    models?: (() => ModelInfo[]) | undefined;
+
+    /** Prompt template source (typically Gateway.promptTemplates). */
+    templates?: (() => PromptTemplate[]) | undefined;
+
+    /** Latency percentile source (typically Gateway.latencySummary). */
+    latency?: (() => LatencySummary[]) | undefined;
+
+    /** Tenant budget source (typically Gateway.budgetStatus). */
+    budget?: ((tenantId: string) => Promise<BudgetStatus | undefined>) | undefined;
+
+    /** Tenant usage reports (typically Gateway.usageReports). */
+    usage?: UsageReports | undefined;
+
+    /** Salted end-user ID hashes (typically Gateway.endUserHash). */
+    endUserHash?: ((endUserId: string) => Promise<string | undefined>) | undefined;
+
+    /** Analytics sink counters source (typically Gateway.eventStats). */
+    events?: (() => EventSinkStats | undefined) | undefined;
+
+    /** Request mirroring counters source (typically Gateway.mirrorStats). */
+    mirrors?: (() => MirrorStats[]) | undefined;
+
+    /** Deadline cancellation counters source (typically Gateway.deadlineStats). */
+    deadlines?: (() => DeadlineCancellationStats[]) | undefined;
+
+    /** Call budget exhaustion counters source (typically Gateway.callBudgetStats). */
+    callBudgets?: (() => CallBudgetStats[]) | undefined;
+
+    /** Parameter policies source (typically Gateway.parameterPolicies). */
+    parameterPolicies?: (() => ParameterPolicySummary) | undefined;
+
+    /** Client abort counters source (typically Gateway.clientAbortStats). */
+    clientAborts?: (() => ClientAbortStats[]) | undefined;
+
+    /** Usage check counters source (typically Gateway.usageDiscrepancyStats). */
+    usageDiscrepancies?: (() => UsageDiscrepancyStats[]) | undefined;
+
+    /** Request coalescing counters source (typically Gateway.coalescingStats). */
+    coalescing?: (() => CoalescingStats[]) | undefined;
+
+    /** Thread message dedup counters source (typically Gateway.messageDedupStats). */
+    messageDedup?: (() => MessageDedupStats[]) | undefined;
+
+    /** Write spill counters source (typically Gateway.spillStats). */
+    spill?: (() => SpillStats | undefined) | undefined;
+
+    /** Storage health source (typically Gateway.storageHealthStats). */
+    storageHealth?: (() => StorageHealthStats | undefined) | undefined;
+
+    /** Response classification counters source (typically Gateway.classificationStats). */
+    classification?: (() => ClassificationStats) | undefined;
+
+    /** Provider calls in flight and queued per tenant (typically Gateway.concurrencyStats). */
+    concurrency?: (() => ConcurrencyStats | undefined) | undefined;
+
+    /** Unmatched request counters source (typically Gateway.unmatchedRouteStats). */
+    unmatchedRoutes?: (() => UnmatchedRouteStats[]) | undefined;
+
+    /** Registered routes source (typically Gateway.routes). */
+    routes?: (() => RegisteredRoute[]) | undefined;
+
+    /** Dry-run routing (typically Gateway.explainRouting). */
+    routing?: ((query: RoutingTestQuery) => Promise<RoutingDecision>) | undefined;
+
+    /** Replays spilled usage writes (typically Gateway.replaySpill). */
+    replaySpill?: (() => Promise<SpillReplayResult | undefined>) | undefined;
+
+    /** Re-encrypts stored data under the newest key (typically Gateway.rewrapStorage). */
+    rewrap?: ((batchSize?: number) => Promise<RewrapResult | undefined>) | undefined;
+
+    /** Runs console test requests (typically Gateway.consoleExecute). */
+    console?: ((request: ConsoleRequest) => Promise<ConsoleResult>) | undefined;
+
+    /** Console rate limit per caller (default: 5 per minute). */
+    consoleRate?: ConsoleRate | undefined;
+
+    /** Tenant registry (typically Gateway.tenants). */
+    tenants?: TenantRegistry | undefined;
+
+    /** Runtime log level control (typically Gateway.logControl). */
+    logging?: LogControl | undefined;
+
+    /** Tenant secrets (typically Gateway.secrets). */
+    secrets?: SecretManager | undefined;
+
+    /** Correlation metadata index (typically Gateway.metadataIndex; default: storage, if it has one). */
+    metadataIndex?: MetadataIndexStore | undefined;
+
+    /** Provider attempts per interaction (typically Gateway.attempts; default: storage, if it has one). */
+    attempts?: Pick<AttemptStore, 'listAttempts'> | undefined;
+
+    /** Thread state mappings (typically Gateway.threadState; default: storage). */
+    threadState?: ThreadStateStore | undefined;
+
+    /** Runtime model migrations (typically Gateway.modelMigrations). */
+    modelMigrations?: ModelMigrationJobs | undefined;
+
+    /** Dual-write storage migration (typically Gateway.dualWrite). */
+    dualWrite?: DualWriteStorage | undefined;
+
+    /** Live interaction events (typically Gateway.interactionTails). */
+    tails?: InteractionTails | undefined;
+
+    /** Auth provider. When unset the admin API is unauthenticated and unscoped. */
+    auth?: AuthProvider | undefined;
 }
 
+/** Scopes that grant access to every tenant's data. */
+export const ADMIN_OPERATOR_SCOPES = ['*', 'admin'];
+
 /**
  * Stats response.
  */
@@ -44,6 +334,51 @@ export interface StatsResponse {
     uptimeMs: number;
     runtime: string;
     memory?: MemoryStats | undefined;
+
+    /** p50/p95/p99 per provider and model and timing phase. */
+    latency?: LatencySummary[] | undefined;
+
+    /** Analytics sink publish lag and failure counters. */
+    events?: EventSinkStats | undefined;
+
+    /** Request mirroring success and failure counters per app. */
+    mirrors?: MirrorStats[] | undefined;
+
+    /** Provider calls cancelled by a request deadline, per provider. */
+    deadlines?: DeadlineCancellationStats[] | undefined;
+
+    /** Requests whose call budget refused an automatic provider call, per app and limit. */
+    callBudgets?: CallBudgetStats[] | undefined;
+
+    /** Non-streaming requests abandoned by their client, per app, with time to abort. */
+    clientAborts?: ClientAbortStats[] | undefined;
+
+    /** Responses whose reported completion tokens were checked, and disagreed, per provider and model. */
+    usageDiscrepancies?: UsageDiscrepancyStats[] | undefined;
+
+    /** Provider calls made and requests coalesced into them, per app. */
+    coalescing?: CoalescingStats[] | undefined;
+
+    /** Thread appends skipped as duplicates, per app, by ID and by content. */
+    messageDedup?: MessageDedupStats[] | undefined;
+
+    /** Spilled usage writes waiting for replay, and replay failures. */
+    spill?: SpillStats | undefined;
+
+    /** Whether storage is degraded, and writes skipped or refused because of it. */
+    storage?: StorageHealthStats | undefined;
+
+    /** Secondary writes mirrored, queued, dropped, and failed while dual-writing. */
+    dualWrite?: DualWriteStats | undefined;
+
+    /** Responses classified, classifier failures, and jobs dropped or waiting. */
+    classification?: ClassificationStats | undefined;
+
+    /** Provider calls in flight and queued per tenant, with queue wait percentiles. */
+    concurrency?: ConcurrencyStats | undefined;
+
+    /** Requests with no route or a method it doesn't accept, per path prefix. */
+    unmatchedRoutes?: UnmatchedRouteStats[] | undefined;
 }
 
 /**
@@ -66,6 +401,9 @@ export interface OverviewResponse {
     providers: ProviderSummary[];
     frontdoors: FrontdoorSummary[];
     routing: AdminRoutingSummary;
+
+    /** Sampling parameter policies, per app and per model pattern. */
+    parameterPolicies?: ParameterPolicySummary | undefined;
 }
 
 /**
@@ -94,6 +432,26 @@ export interface ProviderSummary {
     name: string;
     type: string;
     baseUrl?: string | undefined;
+    apiVersion?: string | undefined;
+    betaFeatures?: string[] | undefined;
+    organization?: string | undefined;
+    project?: string | undefined;
+}
+
+/**
+ * Provider health entry.
+ */
+export interface ProviderHealthSummary {
+    name: string;
+    type: string;
+    configured: boolean;
+    http?: HTTPPoolStats | undefined;
+    keys?: ProviderKeyHealth[] | undefined;
+    probe?: ProbeSummary | undefined;
+    apiVersion?: string | undefined;
+    betaFeatures?: string[] | undefined;
+    organization?: string | undefined;
+    project?: string | undefined;
 }
 
 /**
@@ -131,18 +489,122 @@ export interface AdminInteractionSummary {
     model?: string | undefined;
     provider?: string | undefined;
     durationMs?: number | undefined;
+    metadata?: Record<string, string> | undefined;
+    endUser?: string | undefined;
+    language?: string | undefined;
+    safetyFlags?: string[] | undefined;
     createdAt: number;
     updatedAt: number;
 }
 
+/**
+ * One provider call behind an interaction, for detail display.
+ */
+export type AdminInteractionAttempt = Omit<InteractionAttemptRecord, 'interactionId' | 'tenantId' | 'createdAt'> & {
+    createdAt: number;
+};
+
+/**
+ * A thread state mapping. Thread keys can carry end-user identifiers, so
+ * mappings are addressed by the key's SHA-256 hash.
+ */
+export interface AdminThreadState {
+    keyHash: string;
+
+    /** affinity for provider pins, response for Responses API chains. */
+    kind: 'affinity' | 'response';
+
+    /** Pinned provider (affinity mappings). */
+    provider?: string | undefined;
+
+    /** Current response ID (response mappings). */
+    responseId?: string | undefined;
+
+    updatedAt: number;
+}
+
+/**
+ * A thread state lookup or write by an interaction.
+ */
+export interface AdminThreadStateTouch {
+    interactionId: string;
+    tenantId: string;
+    type: 'thread_resolve' | 'thread_update';
+    provider?: string | undefined;
+    outcome?: string | undefined;
+    timestamp: number;
+}
+
+/**
+ * Thread state list response.
+ */
+export interface AdminThreadStateListResponse {
+    entries: AdminThreadState[];
+
+    /** Cursor for the next page, or null on the last page. */
+    nextCursor: string | null;
+}
+
+/**
+ * Thread state detail response.
+ */
+export interface AdminThreadStateDetail extends AdminThreadState {
+    /** Interactions that resolved or updated the mapping, oldest first. */
+    chain: AdminThreadStateTouch[];
+}
+
 /**
  * Interactions list response.
  */
 export interface AdminInteractionsListResponse {
     interactions: AdminInteractionSummary[];
     total: number;
+
+    /** Matches per app and model for finish_reason lookups, or per provider and model for has_usage_discrepancy. */
+    breakdown?: AdminFinishReasonCount[] | AdminUsageDiscrepancyCount[] | undefined;
+}
+
+/**
+ * Interactions with a usage discrepancy, for one provider and model.
+ */
+export interface AdminUsageDiscrepancyCount {
+    provider: string;
+    model: string;
+    count: number;
+
+    /** Completion tokens reported, summed (less reasoning tokens). */
+    reportedTokens: number;
+
+    /** Tokens counted in the output relayed, summed. */
+    countedTokens: number;
+}
+
+/**
+ * Interactions with a finish reason, for one app and model.
+ */
+export interface AdminFinishReasonCount {
+    app?: string | undefined;
+    model: string;
+    count: number;
 }
 
+/**
+ * What an admin endpoint is called with.
+ */
+interface AdminCall {
+    request: Request;
+    url: URL;
+
+    /** Path parameters of the matched route, decoded. */
+    params: Record<string, string>;
+
+    /** The caller's tenant, or UNSCOPED_TENANT for operators. */
+    tenantId: string;
+    operator: boolean;
+}
+
+type AdminEndpoint = (call: AdminCall) => Response | Promise<Response>;
+
 // ============================================================================
 // Admin Handler
 // ============================================================================
@@ -155,12 +617,97 @@ export class AdminHandler {
     private readonly config?: ConfigProvider;
     private readonly logger?: Logger;
     private readonly startTime: Date;
+    private readonly providerHealth?: () => ProviderHealthSummary[];
+    private readonly auth?: AuthProvider;
+    private readonly models?: () => ModelInfo[];
+    private readonly templates?: () => PromptTemplate[];
+    private readonly latency?: () => LatencySummary[];
+    private readonly budget?: (tenantId: string) => Promise<BudgetStatus | undefined>;
+    private readonly probes?: (provider: string, limit: number) => Promise<ProbeReport | undefined>;
+    private readonly slo?: () => SloReport[];
+    private readonly usage?: UsageReports | undefined;
+    private readonly endUserHash?: ((endUserId: string) => Promise<string | undefined>) | undefined;
+    private readonly events?: () => EventSinkStats | undefined;
+    private readonly mirrors?: () => MirrorStats[];
+    private readonly deadlines?: () => DeadlineCancellationStats[];
+    private readonly callBudgets?: () => CallBudgetStats[];
+    private readonly parameterPolicies?: () => ParameterPolicySummary;
+    private readonly clientAborts?: () => ClientAbortStats[];
+    private readonly usageDiscrepancies?: () => UsageDiscrepancyStats[];
+    private readonly coalescing?: () => CoalescingStats[];
+    private readonly messageDedup?: () => MessageDedupStats[];
+    private readonly spill?: () => SpillStats | undefined;
+    private readonly storageHealth?: () => StorageHealthStats | undefined;
+    private readonly classification?: () => ClassificationStats;
+    private readonly concurrency?: () => ConcurrencyStats | undefined;
+    private readonly unmatchedRoutes?: () => UnmatchedRouteStats[];
+    private readonly routes?: () => RegisteredRoute[];
+    private readonly routing?: (query: RoutingTestQuery) => Promise<RoutingDecision>;
+    private readonly replaySpill?: () => Promise<SpillReplayResult | undefined>;
+    private readonly rewrap?: (batchSize?: number) => Promise<RewrapResult | undefined>;
+    private readonly threadState?: ThreadStateStore | undefined;
+    private readonly console?: (request: ConsoleRequest) => Promise<ConsoleResult>;
+    private readonly consoleLimiter: ConsoleLimiter;
+    private readonly tenants?: TenantRegistry;
+    private readonly logging?: LogControl;
+    private readonly secrets?: SecretManager;
+    private readonly metadataIndex?: MetadataIndexStore;
+    private readonly attempts?: Pick<AttemptStore, 'listAttempts'> | undefined;
+    private readonly erasures?: ErasureJobs;
+    private readonly modelMigrations?: ModelMigrationJobs | undefined;
+    private readonly dualWrite?: DualWriteStorage | undefined;
+    private readonly tails?: InteractionTails | undefined;
 
     constructor(options: AdminHandlerOptions = {}) {
         this.storage = options.storage;
         this.config = options.config;
         this.logger = options.logger;
         this.startTime = options.startTime ?? new Date();
+        this.providerHealth = options.providerHealth;
+        this.auth = options.auth;
+        this.models = options.models;
+        this.templates = options.templates;
+        this.latency = options.latency;
+        this.budget = options.budget;
+        this.probes = options.probes;
+        this.slo = options.slo;
+        this.usage = options.usage;
+        this.endUserHash = options.endUserHash;
+        this.events = options.events;
+        this.mirrors = options.mirrors;
+        this.deadlines = options.deadlines;
+        this.callBudgets = options.callBudgets;
+        this.parameterPolicies = options.parameterPolicies;
+        this.clientAborts = options.clientAborts;
+        this.usageDiscrepancies = options.usageDiscrepancies;
+        this.coalescing = options.coalescing;
+        this.messageDedup = options.messageDedup;
+        this.spill = options.spill;
+        this.storageHealth = options.storageHealth;
+        this.classification = options.classification;
+        this.concurrency = options.concurrency;
+        this.unmatchedRoutes = options.unmatchedRoutes;
+        this.routes = options.routes;
+        this.routing = options.routing;
+        this.replaySpill = options.replaySpill;
+        this.rewrap = options.rewrap;
+        this.threadState = options.threadState ?? options.storage;
+        this.modelMigrations = options.modelMigrations;
+        this.dualWrite = options.dualWrite;
+        this.tails = options.tails;
+        this.console = options.console;
+        this.consoleLimiter = new ConsoleLimiter(options.consoleRate);
+        this.tenants = options.tenants;
+        this.logging = options.logging;
+        this.secrets = options.secrets;
+        this.metadataIndex = options.metadataIndex
+            ?? (isMetadataIndexStore(options.storage) ? options.storage : undefined);
+        this.attempts = options.attempts
+            ?? (isAttemptStore(options.storage) ? options.storage : undefined);
+        if (isErasureStore(options.storage)) {
+            // Erasures are always audited, even without an admin logger
+            this.erasures = new ErasureJobs({ store: options.storage, logger: options.logger ?? defaultLogger });
+        }
     }
 
     /**
@@ -172,62 +719,34 @@ export class AdminHandler {
         const method = request.method;
 
         try {
-            // GET /api/stats
-            if (method === 'GET' && path === '/api/stats') {
-                return this.handleStats();
-            }
-
-            // GET /api/overview
-            if (method === 'GET' && path === '/api/overview') {
-                return this.handleOverview();
-            }
-
-            // GET /api/interactions
-            if (method === 'GET' && path === '/api/interactions') {
-                const limit = parseInt(url.searchParams.get('limit') ?? '50', 10);
-                const offset = parseInt(url.searchParams.get('offset') ?? '0', 10);
-                return this.handleListInteractions({ limit, offset });
-            }
-
-            // GET /api/interactions/:id
-            const interactionMatch = path.match(/^\/api\/interactions\/([^/]+)$/);
-            if (method === 'GET' && interactionMatch) {
-                return this.handleGetInteraction(interactionMatch[1]!);
-            }
-
-            // GET /api/threads
-            if (method === 'GET' && path === '/api/threads') {
-                const limit = parseInt(url.searchParams.get('limit') ?? '50', 10);
-                const offset = parseInt(url.searchParams.get('offset') ?? '0', 10);
-                return this.handleListThreads({ limit, offset });
+            // /health is served as /api/health, before any auth
+            const route = matchAdminRoute(method, path === '/health' ? '/api/health' : path);
+            if (route?.operation.auth === 'public') {
+                return await this.endpoints[route.key]({ request, url, params: route.params, tenantId: UNSCOPED_TENANT, operator: true });
             }
 
-            // GET /api/threads/:id
-            const threadMatch = path.match(/^\/api\/threads\/([^/]+)$/);
-            if (method === 'GET' && threadMatch) {
-                return this.handleGetThread(threadMatch[1]!);
+            const tenantId = await this.resolveTenantScope(request);
+            if (tenantId === null) {
+                return this.errorResponse(401, 'Unauthorized');
             }
-
-            // GET /api/responses
-            if (method === 'GET' && path === '/api/responses') {
-                const limit = parseInt(url.searchParams.get('limit') ?? '50', 10);
-                const offset = parseInt(url.searchParams.get('offset') ?? '0', 10);
-                return this.handleListResponses({ limit, offset });
+            if (!route) {
+                return this.errorResponse(404, 'Not Found');
             }
 
-            // GET /api/responses/:id
-            const responseMatch = path.match(/^\/api\/responses\/([^/]+)$/);
-            if (method === 'GET' && responseMatch) {
-                return this.handleGetResponse(responseMatch[1]!);
+            // Operator routes refuse tenant tokens; other tenants' paths are not found
+            const operator = tenantId === UNSCOPED_TENANT;
+            if (!operator && route.operation.auth === 'operator') {
+                return this.errorResponse(403, 'Forbidden');
             }
-
-            // GET /api/health
-            if (method === 'GET' && (path === '/api/health' || path === '/health')) {
-                return this.jsonResponse({ status: 'ok' });
+            if (!operator && route.operation.auth === 'own_tenant' && route.params['id'] !== tenantId) {
+                return this.errorResponse(404, 'Not Found');
             }
-
-            return this.errorResponse(404, 'Not Found');
+            return await this.endpoints[route.key]({ request, url, params: route.params, tenantId, operator });
         } catch (error) {
+            // Degraded storage is answered as such, not as an internal error
+            if (error instanceof APIError && (error.statusCode < 500 || error.code === 'storage_unavailable')) {
+                return this.errorResponse(error.statusCode, error.message);
+            }
             this.logger?.error('Admin API error', {
                 path,
                 error: error instanceof Error ? error.message : String(error),
@@ -246,8 +765,131 @@ export class AdminHandler {
         return path.startsWith('/api/') || path === '/health';
     }
 
+    // ---- Endpoints ----
+
+    /**
+     * The endpoint serving each admin operation, by method and path.
+     */
+    private readonly endpoints: Record<AdminRouteKey, AdminEndpoint> = {
+        'GET /api/health': () => this.jsonResponse({ status: 'ok' }),
+        'GET /api/stats': () => this.handleStats(),
+        'GET /api/overview': () => this.handleOverview(),
+        'GET /api/providers/health': () => this.handleProviderHealth(),
+        'GET /api/providers/:name/probes': ({ url, params }) =>
+            this.handleGetProbes(params['name']!, parseInt(url.searchParams.get('limit') ?? '50', 10)),
+        'GET /api/slo': () => this.handleSlo(),
+        'GET /api/models': () => this.handleModels(),
+        'GET /api/routes': () => this.handleRoutes(),
+        // Tenant-scoped callers test their own tenant's routing
+        'GET /api/routing/test': ({ url, tenantId, operator }) => this.handleRoutingTest({
+            model: url.searchParams.get('model') ?? undefined,
+            app: url.searchParams.get('app') ?? undefined,
+            tenant: operator ? url.searchParams.get('tenant') ?? undefined : tenantId,
+        }),
+        'GET /api/templates': () => this.handleTemplates(),
+        'GET /api/schema/canonical-request': () => this.jsonResponse(CANONICAL_REQUEST_SCHEMA),
+        'GET /api/schema/canonical-response': () => this.jsonResponse(CANONICAL_RESPONSE_SCHEMA),
+        'GET /api/openapi/data-plane.json': () => this.jsonResponse(buildDataPlaneDocument(this.routes?.())),
+        'GET /api/openapi/admin.json': () => this.jsonResponse(buildAdminDocument()),
+        'POST /api/privacy/erase': ({ request }) => this.handleErase(request),
+        'GET /api/privacy/jobs/:id': ({ params }) => this.handleGetErasureJob(params['id']!),
+        'GET /api/logging': ({ request }) => this.handleLogging('GET', request),
+        'PUT /api/logging': ({ request }) => this.handleLogging('PUT', request),
+        'DELETE /api/logging': ({ request }) => this.handleLogging('DELETE', request),
+        'POST /api/maintenance/replay-spill': () => this.handleReplaySpill(),
+        'POST /api/maintenance/rewrap': ({ url }) => this.handleRewrap(url.searchParams.get('batch_size')),
+        'POST /api/maintenance/migrate-model': ({ request }) => this.handleMigrateModel(request),
+        'GET /api/maintenance/migrate-model/:id': ({ params }) => this.handleGetModelMigration(params['id']!),
+        'POST /api/maintenance/backfill': ({ url }) => this.handleStartBackfill(url.searchParams.get('batch_size')),
+        'GET /api/maintenance/backfill/:id': ({ params }) => this.handleGetBackfill(params['id']!),
+        'GET /api/maintenance/consistency': ({ url }) => this.handleCheckConsistency(url.searchParams.get('sample')),
+        'GET /api/thread-state': ({ url }) => this.handleListThreadState(url.searchParams),
+        'POST /api/thread-state/purge': ({ url }) => this.handlePurgeThreadState(url.searchParams.get('older_than')),
+        'GET /api/thread-state/:hash': ({ params }) => this.handleGetThreadState(params['hash']!),
+        'DELETE /api/thread-state/:hash': ({ params }) => this.handleDeleteThreadState(params['hash']!),
+        'POST /api/export/threads': ({ request }) => this.handleExportThreads(request),
+        'POST /api/import/threads': ({ request }) => this.handleImportThreads(request),
+        'POST /api/console/execute': ({ request }) => this.handleConsoleExecute(request),
+        'GET /api/tenants': () => this.handleListTenants(),
+        'POST /api/tenants': ({ request }) => this.handleCreateTenant(request),
+        'GET /api/tenants/:id': ({ params }) => this.handleGetTenant(params['id']!),
+        'PATCH /api/tenants/:id': ({ params, request }) => this.handleUpdateTenant(params['id']!, request),
+        'DELETE /api/tenants/:id': ({ params }) => this.handleSetTenantDisabled(params['id']!, true),
+        'POST /api/tenants/:id/enable': ({ params }) => this.handleSetTenantDisabled(params['id']!, false),
+        'POST /api/tenants/:id/disable': ({ params }) => this.handleSetTenantDisabled(params['id']!, true),
+        'GET /api/tenants/:id/budget': ({ params }) => this.handleGetBudget(params['id']!),
+        // Values are write-only
+        'GET /api/tenants/:id/secrets': ({ params }) => this.handleListSecrets(params['id']!),
+        'GET /api/tenants/:id/secrets/:name': ({ params }) => this.handleGetSecret(params['id']!, params['name']!),
+        'PUT /api/tenants/:id/secrets/:name': ({ params, request }) => this.handleSetSecret(params['id']!, params['name']!, request),
+        'DELETE /api/tenants/:id/secrets/:name': ({ params }) => this.handleDeleteSecret(params['id']!, params['name']!),
+        'GET /api/tenants/:id/usage': ({ params, url }) => this.handleGetUsage(params['id']!, url.searchParams),
+        'GET /api/interactions': ({ url, tenantId }) => this.handleQueryInteractions(tenantId, url.searchParams),
+        'GET /api/interactions/:id': ({ params, tenantId }) => this.handleGetInteraction(params['id']!, tenantId),
+        'GET /api/interactions/:id/events': ({ params, url, tenantId }) =>
+            this.handleGetInteractionEvents(params['id']!, tenantId, url.searchParams.get('expand') === 'chunks'),
+        'GET /api/interactions/:id/tail': ({ params, url, request, tenantId }) =>
+            this.handleTailInteraction(params['id']!, tenantId, url.searchParams.get('wait'), request.signal),
+        'GET /api/interactions/:id/provider-request': ({ params, tenantId }) => this.handleGetProviderRequest(params['id']!, tenantId),
+        'GET /api/interactions/:id/shadows/:shadowId/diff': ({ params, tenantId }) =>
+            this.handleGetShadowDiff(params['id']!, params['shadowId']!, tenantId),
+        'GET /api/threads': ({ url, tenantId }) => this.handleListThreads(tenantId, pageOptions(url.searchParams)),
+        'GET /api/threads/:id': ({ params, tenantId }) => this.handleGetThread(params['id']!, tenantId),
+        'GET /api/responses': ({ url, tenantId }) => this.handleListResponses(tenantId, pageOptions(url.searchParams)),
+        'GET /api/responses/:id': ({ params, tenantId }) => this.handleGetResponse(params['id']!, tenantId),
+        'GET /api/shadows/:id': ({ params, tenantId }) => this.handleGetShadow(params['id']!, tenantId),
+    };
+
     // ---- Endpoint Handlers ----
 
+    /**
+     * Lists interactions, or finds them by the first filter the query
+     * names: end user, lifecycle status, usage discrepancy, finish reason,
+     * classification, then correlation metadata.
+     */
+    private async handleQueryInteractions(tenantId: string, query: URLSearchParams): Promise<Response> {
+        const { limit, offset } = pageOptions(query);
+        const endUser = query.get('end_user');
+        if (endUser) {
+            return this.handleFindEndUserInteractions(tenantId, endUser, { limit, offset });
+        }
+        const status = query.get('status');
+        if (status) {
+            if (!INTERACTION_LIFECYCLE_STATUSES.includes(status as InteractionLifecycleStatus)) {
+                return this.errorResponse(400, `status must be one of ${INTERACTION_LIFECYCLE_STATUSES.join(', ')}`);
+            }
+            return this.handleListInteractions(tenantId, { limit, offset, type: 'request', status, orderBy: 'createdAt' });
+        }
+        const discrepancy = query.get('has_usage_discrepancy');
+        if (discrepancy) {
+            if (discrepancy !== 'true') {
+                return this.errorResponse(400, 'has_usage_discrepancy must be true');
+            }
+            return this.handleFindUsageDiscrepancies(tenantId, {
+                provider: query.get('provider') || undefined,
+                model: query.get('model') || undefined,
+            }, { limit, offset });
+        }
+        const finishReason = query.get('finish_reason');
+        if (finishReason) {
+            return this.handleFindInteractionsByFinishReason(tenantId, finishReason, {
+                app: query.get('app') || undefined,
+                model: query.get('model') || undefined,
+            }, { limit, offset });
+        }
+        const language = query.get('language') || undefined;
+        const safety = query.get('safety') || undefined;
+        if (language || safety) {
+            return this.handleFindClassifiedInteractions(tenantId, { language, safety }, { limit, offset });
+        }
+        const metadata = [...query]
+            .filter(([name]) => name.startsWith('metadata.') && name.length > 'metadata.'.length)
+            .map(([name, value]) => ({ key: name.slice('metadata.'.length).toLowerCase(), value }));
+        return metadata.length > 0
+            ? this.handleFindInteractions(tenantId, metadata, { limit, offset })
+            : this.handleListInteractions(tenantId, { limit, offset });
+    }
+
     private handleStats(): Response {
         const now = Date.now();
         const uptimeMs = now - this.startTime.getTime();
@@ -256,6 +898,21 @@ export class AdminHandler {
             uptime: this.formatDuration(uptimeMs),
             uptimeMs,
             runtime: this.getRuntime(),
+            latency: this.latency?.(),
+            events: this.events?.(),
+            mirrors: this.mirrors?.(),
+            deadlines: this.deadlines?.(),
+            callBudgets: this.callBudgets?.(),
+            clientAborts: this.clientAborts?.(),
+            usageDiscrepancies: this.usageDiscrepancies?.(),
+            coalescing: this.coalescing?.(),
+            messageDedup: this.messageDedup?.(),
+            spill: this.spill?.(),
+            storage: this.storageHealth?.(),
+            dualWrite: this.dualWrite?.stats(),
+            classification: this.classification?.(),
+            concurrency: this.concurrency?.(),
+            unmatchedRoutes: this.unmatchedRoutes?.(),
         };
 
         // Add memory stats if available (Node.js)
@@ -272,108 +929,939 @@ export class AdminHandler {
         return this.jsonResponse(stats);
     }
 
-    private async handleOverview(): Promise<Response> {
-        const overview: OverviewResponse = {
-            mode: 'single-tenant',
-            storage: {
-                enabled: this.storage !== undefined,
-                type: this.storage ? 'configured' : 'none',
-            },
-            apps: [],
-            providers: [],
-            frontdoors: [],
-            routing: {
-                rules: [],
-            },
-        };
-
-        // Get config if available - ConfigProvider doesn't have getConfig, skip for now
-        // TODO: Add config introspection method to ConfigProvider
-
+    private handleProviderHealth(): Response {
+        if (!this.providerHealth) {
+            return this.errorResponse(503, 'Provider health not available');
+        }
+        return this.jsonResponse({ providers: this.providerHealth() });
+    }
 
+    private async handleGetProbes(provider: string, limit: number): Promise<Response> {
+        if (!this.probes) {
+            return this.errorResponse(503, 'Provider probes not available');
+        }
+        const report = await this.probes(provider, limit);
+        if (!report) {
+            return this.errorResponse(404, 'No probe configured for provider');
+        }
+        return this.jsonResponse(report);
+    }
 
-        return this.jsonResponse(overview);
+    private handleSlo(): Response {
+        if (!this.slo) {
+            return this.errorResponse(503, 'SLO tracking not available');
+        }
+        return this.jsonResponse({ apps: this.slo() });
     }
 
-    private async handleListInteractions(options: {
-        limit: number;
-        offset: number;
-    }): Promise<Response> {
-        if (!this.storage) {
-            return this.errorResponse(503, 'Storage not configured');
+    private handleModels(): Response {
+        if (!this.models) {
+            return this.errorResponse(503, 'Model catalog not available');
         }
+        return this.jsonResponse({ models: this.models() });
+    }
 
-        // For now, return empty list - would need InteractionStore interface
-        const response: AdminInteractionsListResponse = {
-            interactions: [],
-            total: 0,
-        };
+    private handleRoutes(): Response {
+        if (!this.routes) {
+            return this.errorResponse(503, 'Routes not available');
+        }
+        return this.jsonResponse({ routes: this.routes() });
+    }
 
-        return this.jsonResponse(response);
+    private async handleRoutingTest(query: RoutingTestQuery): Promise<Response> {
+        if (!this.routing) {
+            return this.errorResponse(503, 'Routing not available');
+        }
+        try {
+            return this.jsonResponse({ decision: await this.routing(query) });
+        } catch (error) {
+            if (error instanceof APIError && error.statusCode < 500) {
+                return this.errorResponse(error.statusCode, error.message);
+            }
+            throw error;
+        }
     }
 
-    private async handleGetInteraction(id: string): Promise<Response> {
-        if (!this.storage) {
-            return this.errorResponse(503, 'Storage not configured');
+    private handleTemplates(): Response {
+        if (!this.templates) {
+            return this.errorResponse(503, 'Prompt templates not available');
         }
+        return this.jsonResponse({ templates: this.templates() });
+    }
 
-        // Would need InteractionStore interface
-        return this.errorResponse(404, 'Interaction not found');
+    private async handleGetBudget(tenantId: string): Promise<Response> {
+        if (!this.budget) {
+            return this.errorResponse(503, 'Budgets not available');
+        }
+        const status = await this.budget(tenantId);
+        if (!status) {
+            return this.errorResponse(404, 'No budget configured for tenant');
+        }
+        return this.jsonResponse(status);
     }
 
-    private async handleListThreads(options: {
-        limit: number;
-        offset: number;
-    }): Promise<Response> {
-        if (!this.storage?.listConversations) {
-            return this.errorResponse(503, 'Thread storage not configured');
+    private async handleGetUsage(tenantId: string, params: URLSearchParams): Promise<Response> {
+        if (!this.usage) {
+            return this.errorResponse(503, 'Usage reports not available');
+        }
+        let range;
+        let groupBy;
+        try {
+            range = this.usage.parseRange(params);
+            groupBy = this.usage.parseGrouping(params);
+        } catch (error) {
+            return this.errorResponse(400, (error as Error).message);
+        }
+        const report = await this.usage.report(tenantId, range, groupBy);
+        if (!this.metadataIndex) {
+            return this.jsonResponse(report);
         }
 
-        const conversations = await this.storage.listConversations('default', {
-            limit: options.limit,
-            offset: options.offset,
-        });
+        // Discrepancies are counted from the index, over the same days
+        const from = Date.parse(`${range.start}T00:00:00.000Z`);
+        const to = Date.parse(`${range.end}T00:00:00.000Z`) + 86_400_000;
+        const records = (await this.metadataIndex.findByMetadata(tenantId, USAGE_DISCREPANCY_METADATA_KEY, 'true'))
+            .filter((r) => r.createdAt.getTime() >= from && r.createdAt.getTime() < to);
+        return this.jsonResponse({ ...report, usageDiscrepancies: countUsageDiscrepancies(records) });
+    }
 
-        const threads = conversations.map((conv) => ({
-            id: conv.id,
-            createdAt: conv.createdAt.getTime(),
-            updatedAt: conv.updatedAt?.getTime() ?? conv.createdAt.getTime(),
-            messageCount: 0, // Would need to query messages
-        }));
+    private async handleReplaySpill(): Promise<Response> {
+        const result = await this.replaySpill?.();
+        if (!result) {
+            return this.errorResponse(503, 'Write spill not configured');
+        }
+        return this.jsonResponse(result);
+    }
 
-        return this.jsonResponse({ threads, total: threads.length });
+    private async handleRewrap(batchSizeParam: string | null): Promise<Response> {
+        let batchSize: number | undefined;
+        if (batchSizeParam !== null) {
+            batchSize = Number(batchSizeParam);
+            if (!Number.isInteger(batchSize) || batchSize <= 0 || batchSize > MAX_REWRAP_BATCH_SIZE) {
+                return this.errorResponse(400, `batch_size must be an integer from 1 to ${MAX_REWRAP_BATCH_SIZE}`);
+            }
+        }
+        const result = await this.rewrap?.(batchSize);
+        if (!result) {
+            return this.errorResponse(503, 'Storage encryption not configured');
+        }
+        return this.jsonResponse(result);
     }
 
-    private async handleGetThread(id: string): Promise<Response> {
-        if (!this.storage?.getConversation) {
-            return this.errorResponse(503, 'Thread storage not configured');
+    private async handleListThreadState(params: URLSearchParams): Promise<Response> {
+        if (!this.threadState) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const limit = Number(params.get('limit') ?? DEFAULT_THREAD_STATE_PAGE);
+        if (!Number.isInteger(limit) || limit <= 0 || limit > MAX_THREAD_STATE_PAGE) {
+            return this.errorResponse(400, `limit must be an integer from 1 to ${MAX_THREAD_STATE_PAGE}`);
         }
+        const entries = await this.threadState.listThreadState({ limit, cursor: params.get('cursor') ?? undefined });
+        const body: AdminThreadStateListResponse = {
+            entries: entries.map(toAdminThreadState),
+            nextCursor: entries.length === limit ? entries[entries.length - 1]!.keyHash : null,
+        };
+        return this.jsonResponse(body);
+    }
 
-        const conv = await this.storage.getConversation(id);
-        if (!conv) {
-            return this.errorResponse(404, 'Thread not found');
+    private async handleGetThreadState(keyHash: string): Promise<Response> {
+        if (!this.threadState) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const entry = await this.threadState.findThreadState(keyHash);
+        if (!entry) {
+            return this.errorResponse(404, 'Thread state not found');
         }
+        const body: AdminThreadStateDetail = {
+            ...toAdminThreadState(entry),
+            chain: await this.threadStateChain(keyHash),
+        };
+        return this.jsonResponse(body);
+    }
 
-        return this.jsonResponse({
-            id: conv.id,
-            createdAt: conv.createdAt.getTime(),
-            updatedAt: conv.updatedAt?.getTime() ?? conv.createdAt.getTime(),
-            metadata: conv.metadata,
-        });
+    /**
+     * Lists the thread_resolve and thread_update events recorded for a key,
+     * found through the metadata index. Empty without an index.
+     */
+    private async threadStateChain(keyHash: string): Promise<AdminThreadStateTouch[]> {
+        if (!this.metadataIndex || !this.storage) {
+            return [];
+        }
+        const records = (await this.metadataIndex.findByMetadata(
+            UNSCOPED_TENANT, threadStateIndexKey(keyHash), THREAD_STATE_TOUCHED,
+        )).slice(0, MAX_THREAD_STATE_CHAIN).reverse();
+
+        // Oldest interaction first, so same-millisecond events keep their order
+        const chain: AdminThreadStateTouch[] = [];
+        for (const record of records) {
+            for (const event of await this.storage.getEvents(record.interactionId, UNSCOPED_TENANT)) {
+                if (event.type !== 'thread_resolve' && event.type !== 'thread_update') continue;
+                const payload = (event.payload ?? {}) as { keyHash?: string; provider?: string; outcome?: string };
+                if (payload.keyHash !== keyHash) continue;
+                chain.push({
+                    interactionId: record.interactionId,
+                    tenantId: record.tenantId,
+                    type: event.type,
+                    provider: payload.provider,
+                    outcome: payload.outcome,
+                    timestamp: event.timestamp.getTime(),
+                });
+            }
+        }
+        return chain.sort((a, b) => a.timestamp - b.timestamp);
     }
 
-    private async handleListResponses(options: {
-        limit: number;
-        offset: number;
-    }): Promise<Response> {
-        if (!this.storage?.listResponses) {
-            return this.errorResponse(503, 'Response storage not configured');
+    private async handleDeleteThreadState(keyHash: string): Promise<Response> {
+        if (!this.threadState) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const entry = await this.threadState.findThreadState(keyHash);
+        if (!entry || !(await this.threadState.deleteThreadState(entry.threadKey))) {
+            return this.errorResponse(404, 'Thread state not found');
         }
+        (this.logger ?? defaultLogger).info('thread_state_deleted', { audit: true, keyHash });
+        return this.jsonResponse({ deleted: true, keyHash });
+    }
 
-        const records = await this.storage.listResponses('default', {
-            limit: options.limit,
-            offset: options.offset,
-        });
+    private async handlePurgeThreadState(olderThan: string | null): Promise<Response> {
+        if (!this.threadState) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const ageMs = olderThan === null ? NaN : parseDuration(olderThan, NaN);
+        if (!(ageMs > 0)) {
+            return this.errorResponse(400, 'older_than must be a positive duration (e.g. "24h")');
+        }
+        const before = new Date(Date.now() - ageMs);
+        const purged = await this.threadState.purgeThreadState(before);
+        (this.logger ?? defaultLogger).info('thread_state_purged', { audit: true, olderThan, purged });
+        return this.jsonResponse({ purged, before: before.getTime() });
+    }
+
+    private async handleExportThreads(request: Request): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        const { tenant_ids: tenantIds } = body;
+        if (!Array.isArray(tenantIds) || tenantIds.length === 0
+            || !tenantIds.every((id) => typeof id === 'string' && id)) {
+            return this.errorResponse(400, 'tenant_ids must be a non-empty array of strings');
+        }
+        const bundle = await exportThreads(this.storage, tenantIds as string[]);
+        (this.logger ?? defaultLogger).info('threads_exported', {
+            audit: true,
+            checksum: bundle.checksum,
+            tenants: bundle.tenants,
+            conversations: bundle.conversations.length,
+            responses: bundle.responses.length,
+            threadState: bundle.threadState.length,
+        });
+        return this.jsonResponse(bundle);
+    }
+
+    private async handleImportThreads(request: Request): Promise<Response> {
+        if (!isThreadTransferStore(this.storage)) {
+            return this.errorResponse(503, 'Thread import not supported by storage');
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        const bundle = parseThreadBundle(body.bundle);
+        if (typeof bundle === 'string') {
+            return this.errorResponse(400, bundle);
+        }
+        const options = parseThreadImportOptions(body);
+        if (typeof options === 'string') {
+            return this.errorResponse(400, options);
+        }
+        const result = await importThreads(this.storage, bundle, {
+            ...options,
+            tenantExists: async (id) => this.tenants?.get(id) !== undefined || (await this.auth?.getTenant(id)) != null,
+        });
+        (this.logger ?? defaultLogger).info('threads_imported', {
+            audit: true,
+            checksum: result.checksum,
+            duplicate: result.duplicate,
+            conversations: result.conversations,
+            responses: result.responses,
+            threadState: result.threadState,
+            remapped: Object.keys(result.remapped).length,
+        });
+        return this.jsonResponse({ ...result, importedAt: result.importedAt.getTime() });
+    }
+
+    private async handleConsoleExecute(request: Request): Promise<Response> {
+        if (!this.console) {
+            return this.errorResponse(503, 'Console not available');
+        }
+        let body: unknown;
+        try {
+            body = await request.json();
+        } catch {
+            return this.errorResponse(400, 'Invalid JSON body');
+        }
+        const input = parseConsoleRequest(body);
+        if (typeof input === 'string') {
+            return this.errorResponse(400, input);
+        }
+        const retryAfter = this.consoleLimiter.throttle(request.headers.get('Authorization') ?? '');
+        if (retryAfter > 0) {
+            const response = this.errorResponse(429, 'Too many console requests; try again later');
+            response.headers.set('Retry-After', String(retryAfter));
+            return response;
+        }
+        return this.jsonResponse(await this.console(input));
+    }
+
+    private async handleErase(request: Request): Promise<Response> {
+        if (!this.erasures) {
+            return this.errorResponse(503, 'Erasure not supported by storage');
+        }
+        let body: unknown;
+        try {
+            body = await request.json();
+        } catch {
+            return this.errorResponse(400, 'Invalid JSON body');
+        }
+        const selector = parseErasureSelector(body);
+        if (typeof selector === 'string') {
+            return this.errorResponse(400, selector);
+        }
+        return this.jsonResponse(this.erasures.start(selector), 202);
+    }
+
+    private handleGetErasureJob(id: string): Response {
+        const job = this.erasures?.get(id);
+        return job ? this.jsonResponse(job) : this.errorResponse(404, 'Erasure job not found');
+    }
+
+    private async handleMigrateModel(request: Request): Promise<Response> {
+        if (!this.modelMigrations) {
+            return this.errorResponse(503, 'Model migration not configured');
+        }
+        let body: unknown;
+        try {
+            body = await request.json();
+        } catch {
+            return this.errorResponse(400, 'Invalid JSON body');
+        }
+        const migration = parseModelMigration(body);
+        if (typeof migration === 'string') {
+            return this.errorResponse(400, migration);
+        }
+        return this.jsonResponse(this.modelMigrations.start(migration), 202);
+    }
+
+    private handleGetModelMigration(id: string): Response {
+        const job = this.modelMigrations?.get(id);
+        return job ? this.jsonResponse(job) : this.errorResponse(404, 'Model migration job not found');
+    }
+
+    private async handleStartBackfill(batchSizeParam: string | null): Promise<Response> {
+        if (!this.dualWrite) {
+            return this.errorResponse(503, 'Dual-write storage not configured');
+        }
+        let batchSize: number | undefined;
+        if (batchSizeParam !== null) {
+            batchSize = Number(batchSizeParam);
+            if (!Number.isInteger(batchSize) || batchSize <= 0 || batchSize > MAX_BACKFILL_BATCH_SIZE) {
+                return this.errorResponse(400, `batch_size must be an integer from 1 to ${MAX_BACKFILL_BATCH_SIZE}`);
+            }
+        }
+        return this.jsonResponse(await this.dualWrite.startBackfill(batchSize), 202);
+    }
+
+    private handleGetBackfill(id: string): Response {
+        const job = this.dualWrite?.getBackfill(id);
+        return job ? this.jsonResponse(job) : this.errorResponse(404, 'Backfill job not found');
+    }
+
+    private async handleCheckConsistency(sampleParam: string | null): Promise<Response> {
+        if (!this.dualWrite) {
+            return this.errorResponse(503, 'Dual-write storage not configured');
+        }
+        const sample = sampleParam === null ? DEFAULT_CONSISTENCY_SAMPLE : Number(sampleParam);
+        if (!Number.isInteger(sample) || sample <= 0 || sample > MAX_CONSISTENCY_SAMPLE) {
+            return this.errorResponse(400, `sample must be an integer from 1 to ${MAX_CONSISTENCY_SAMPLE}`);
+        }
+        return this.jsonResponse(await this.dualWrite.checkConsistency(sample));
+    }
+
+    private async handleLogging(method: string, request: Request): Promise<Response> {
+        if (!this.logging) {
+            return this.errorResponse(503, 'Logging control not available');
+        }
+        if (method === 'GET') {
+            return this.jsonResponse(this.logging.state());
+        }
+        if (method === 'DELETE') {
+            return this.jsonResponse(this.logging.reset());
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        const change = parseLoggingChange(body);
+        if (typeof change === 'string') {
+            return this.errorResponse(400, change);
+        }
+        return this.jsonResponse(this.logging.set(change));
+    }
+
+    private handleListTenants(): Response {
+        if (!this.tenants) {
+            return this.errorResponse(503, 'Tenant registry not available');
+        }
+        return this.jsonResponse({ tenants: this.tenants.list() });
+    }
+
+    private handleGetTenant(id: string): Response {
+        const tenant = this.tenants?.get(id);
+        return tenant ? this.jsonResponse(tenant) : this.errorResponse(404, 'Tenant not found');
+    }
+
+    private async handleCreateTenant(request: Request): Promise<Response> {
+        if (!this.tenants?.writable) {
+            return this.errorResponse(503, 'Tenant storage not available');
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        const input = parseCreateTenant(body);
+        if (typeof input === 'string') {
+            return this.errorResponse(400, input);
+        }
+        return this.jsonResponse(await this.tenants.create(input), 201);
+    }
+
+    private async handleListSecrets(tenantId: string): Promise<Response> {
+        if (!this.secrets) {
+            return this.errorResponse(503, 'Secrets not available');
+        }
+        return this.jsonResponse({ secrets: await this.secrets.list(tenantId) });
+    }
+
+    private async handleGetSecret(tenantId: string, name: string): Promise<Response> {
+        if (!this.secrets) {
+            return this.errorResponse(503, 'Secrets not available');
+        }
+        return this.jsonResponse(await this.secrets.get(tenantId, name));
+    }
+
+    private async handleSetSecret(tenantId: string, name: string, request: Request): Promise<Response> {
+        if (!this.secrets?.writable) {
+            return this.errorResponse(503, 'Secrets require storage encryption keys');
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        if (typeof body.value !== 'string' || !body.value) {
+            return this.errorResponse(400, 'value must be a non-empty string');
+        }
+        return this.jsonResponse(await this.secrets.set(tenantId, name, body.value));
+    }
+
+    private async handleDeleteSecret(tenantId: string, name: string): Promise<Response> {
+        if (!this.secrets) {
+            return this.errorResponse(503, 'Secrets not available');
+        }
+        await this.secrets.delete(tenantId, name);
+        return this.jsonResponse({ deleted: true, reference: `${tenantId}/${name}` });
+    }
+
+    private async handleUpdateTenant(id: string, request: Request): Promise<Response> {
+        if (!this.tenants?.writable) {
+            return this.errorResponse(503, 'Tenant storage not available');
+        }
+        const body = await readJSONObject(request);
+        if (typeof body === 'string') {
+            return this.errorResponse(400, body);
+        }
+        const input = parseUpdateTenant(body);
+        if (typeof input === 'string') {
+            return this.errorResponse(400, input);
+        }
+        return this.jsonResponse(await this.tenants.update(id, input));
+    }
+
+    private async handleSetTenantDisabled(id: string, disabled: boolean): Promise<Response> {
+        if (!this.tenants?.writable) {
+            return this.errorResponse(503, 'Tenant storage not available');
+        }
+        return this.jsonResponse(await this.tenants.setDisabled(id, disabled));
+    }
+
+    private async handleOverview(): Promise<Response> {
+        const overview: OverviewResponse = {
+            mode: 'single-tenant',
+            storage: {
+                enabled: this.storage !== undefined,
+                type: this.storage ? 'configured' : 'none',
+            },
+            apps: [],
+            providers: (this.providerHealth?.() ?? []).map((p) => ({
+                name: p.name,
+                type: p.type,
+                apiVersion: p.apiVersion,
+                betaFeatures: p.betaFeatures,
+                organization: p.organization,
+                project: p.project,
+            })),
+            frontdoors: [],
+            routing: {
+                rules: [],
+            },
+            parameterPolicies: this.parameterPolicies?.(),
+        };
+
+        // Get config if available - ConfigProvider doesn't have getConfig, skip for now
+        // TODO: Add config introspection method to ConfigProvider
+
+
+
+        return this.jsonResponse(overview);
+    }
+
+    private async handleListInteractions(tenantId: string, options: InteractionListOptions): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const filter = {
+            ...options,
+            tenantId: tenantId === UNSCOPED_TENANT ? undefined : tenantId,
+        };
+        const [summaries, total] = await Promise.all([
+            this.storage.listInteractions(filter),
+            this.storage.getInteractionCount(filter),
+        ]);
+
+        const response: AdminInteractionsListResponse = {
+            interactions: summaries.map((s) => ({
+                id: s.id,
+                type: s.type,
+                status: s.status,
+                model: s.model,
+                provider: s.provider,
+                createdAt: s.createdAt.getTime(),
+                updatedAt: s.updatedAt.getTime(),
+            })),
+            total,
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    /**
+     * Finds interactions by correlation metadata: one indexed lookup on the
+     * first filter, the rest matched against the records it returns.
+     */
+    private async handleFindInteractions(
+        tenantId: string,
+        filters: { key: string; value: string }[],
+        options: { limit: number; offset: number },
+    ): Promise<Response> {
+        if (!this.metadataIndex) {
+            return this.errorResponse(503, 'Metadata index not configured');
+        }
+
+        const [first, ...rest] = filters;
+        const records = (await this.metadataIndex.findByMetadata(tenantId, first!.key, first!.value))
+            .filter((r) => rest.every((f) => r.metadata[f.key] === f.value));
+
+        const response: AdminInteractionsListResponse = {
+            interactions: records.slice(options.offset, options.offset + options.limit).map((r) => ({
+                id: r.interactionId,
+                type: 'request',
+                metadata: r.metadata,
+                createdAt: r.createdAt.getTime(),
+                updatedAt: r.createdAt.getTime(),
+            })),
+            total: records.length,
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    /**
+     * Finds interactions by end user. The value may be the client's ID or
+     * the hash reports show (and apps forwarding hashes send upstream), so
+     * both the value and its hash are looked up.
+     */
+    private async handleFindEndUserInteractions(
+        tenantId: string,
+        endUser: string,
+        options: { limit: number; offset: number },
+    ): Promise<Response> {
+        const hash = await this.endUserHash?.(endUser);
+        const found = await Promise.all([...new Set([endUser, hash ?? endUser])]
+            .map((value) => this.usage?.findByEndUser(tenantId, value)));
+        if (found.some((records) => records === undefined)) {
+            return this.errorResponse(503, 'End-user lookup not available');
+        }
+        const records = found.flatMap((r) => r!).sort((a, b) => b.createdAt.getTime() - a.createdAt.getTime());
+
+        const response: AdminInteractionsListResponse = {
+            interactions: records.slice(options.offset, options.offset + options.limit).map(requestSummary),
+            total: records.length,
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    /**
+     * Finds interactions by finish reason, optionally for one app or
+     * model, with their count per app and model. Only length stops are
+     * indexed.
+     */
+    private async handleFindInteractionsByFinishReason(
+        tenantId: string,
+        finishReason: string,
+        filter: { app?: string | undefined; model?: string | undefined },
+        options: { limit: number; offset: number },
+    ): Promise<Response> {
+        if (!this.metadataIndex) {
+            return this.errorResponse(503, 'Metadata index not configured');
+        }
+
+        const records = (await this.metadataIndex.findByMetadata(tenantId, FINISH_REASON_METADATA_KEY, finishReason))
+            .filter((r) => (!filter.app || r.appName === filter.app) && (!filter.model || r.metadata['model'] === filter.model));
+        const counts = new Map<string, AdminFinishReasonCount>();
+        for (const r of records) {
+            const model = r.metadata['model'] ?? 'unknown';
+            const key = `${r.appName ?? ''}\u0000${model}`;
+            const count = counts.get(key) ?? { app: r.appName, model, count: 0 };
+            count.count++;
+            counts.set(key, count);
+        }
+
+        const response: AdminInteractionsListResponse = {
+            interactions: records.slice(options.offset, options.offset + options.limit).map((r) => ({
+                id: r.interactionId,
+                type: 'request',
+                model: r.metadata['model'],
+                metadata: r.metadata,
+                createdAt: r.createdAt.getTime(),
+                updatedAt: r.createdAt.getTime(),
+            })),
+            total: records.length,
+            breakdown: [...counts.values()].sort((a, b) => b.count - a.count),
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    /**
+     * Finds interactions whose reported completion tokens disagreed with
+     * the output relayed, optionally for one provider or model, with their
+     * count per provider and model.
+     */
+    private async handleFindUsageDiscrepancies(
+        tenantId: string,
+        filter: { provider?: string | undefined; model?: string | undefined },
+        options: { limit: number; offset: number },
+    ): Promise<Response> {
+        if (!this.metadataIndex) {
+            return this.errorResponse(503, 'Metadata index not configured');
+        }
+
+        const records = (await this.metadataIndex.findByMetadata(tenantId, USAGE_DISCREPANCY_METADATA_KEY, 'true'))
+            .filter((r) => (!filter.provider || r.metadata['provider'] === filter.provider)
+                && (!filter.model || r.metadata['model'] === filter.model));
+
+        const response: AdminInteractionsListResponse = {
+            interactions: records.slice(options.offset, options.offset + options.limit).map((r) => ({
+                id: r.interactionId,
+                type: 'request',
+                model: r.metadata['model'],
+                metadata: r.metadata,
+                createdAt: r.createdAt.getTime(),
+                updatedAt: r.createdAt.getTime(),
+            })),
+            total: records.length,
+            breakdown: countUsageDiscrepancies(records),
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    private async handleFindClassifiedInteractions(
+        tenantId: string,
+        filter: RequestStatClassificationFilter,
+        options: { limit: number; offset: number },
+    ): Promise<Response> {
+        const records = await this.usage?.findByClassification(tenantId, filter);
+        if (!records) {
+            return this.errorResponse(503, 'Classification lookup not available');
+        }
+
+        const response: AdminInteractionsListResponse = {
+            interactions: records.slice(options.offset, options.offset + options.limit).map(requestSummary),
+            total: records.length,
+        };
+
+        return this.jsonResponse(response);
+    }
+
+    private async handleGetInteraction(id: string, tenantId: string): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const conv = await this.storage.getConversation(id, tenantId);
+        if (conv) {
+            return this.jsonResponse({
+                id: conv.id,
+                type: 'conversation',
+                model: conv.model,
+                messageCount: conv.messages.length,
+                attempts: await this.listAttempts(id, tenantId),
+                createdAt: conv.createdAt.getTime(),
+                updatedAt: conv.updatedAt.getTime(),
+            });
+        }
+
+        const record = await this.storage.getResponse(id, tenantId);
+        if (record) {
+            return this.jsonResponse({
+                id: record.id,
+                type: 'response',
+                status: record.status,
+                model: record.model,
+                timings: record.timings,
+                attempts: await this.listAttempts(id, tenantId),
+                createdAt: record.createdAt.getTime(),
+                updatedAt: record.updatedAt.getTime(),
+            });
+        }
+
+        return this.errorResponse(404, 'Interaction not found');
+    }
+
+    /**
+     * Lists an interaction's provider attempts, or undefined without an
+     * attempt store.
+     */
+    private async listAttempts(id: string, tenantId: string): Promise<AdminInteractionAttempt[] | undefined> {
+        if (!this.attempts) {
+            return undefined;
+        }
+        return (await this.attempts.listAttempts(id, tenantId)).map((a) => ({
+            attempt: a.attempt,
+            provider: a.provider,
+            model: a.model,
+            reason: a.reason,
+            usage: a.usage,
+            costUsd: a.costUsd,
+            durationMs: a.durationMs,
+            outcome: a.outcome,
+            error: a.error,
+            statusCode: a.statusCode,
+            upstreamError: a.upstreamError,
+            served: a.served,
+            createdAt: a.createdAt.getTime(),
+        }));
+    }
+
+    private async handleGetInteractionEvents(id: string, tenantId: string, expandChunks: boolean): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const [conv, record] = await Promise.all([
+            this.storage.getConversation(id, tenantId),
+            this.storage.getResponse(id, tenantId),
+        ]);
+        if (!conv && !record) {
+            return this.errorResponse(404, 'Interaction not found');
+        }
+
+        // Stream transcripts are stored compacted; expanding restores one
+        // stream_chunk event per stream event
+        const stored = await this.storage.getEvents(id, tenantId);
+        const events = expandChunks ? expandTranscripts(stored) : stored;
+        return this.jsonResponse({
+            events: events.map((e) => ({ ...e, timestamp: e.timestamp.getTime() })),
+        });
+    }
+
+    /**
+     * Follows an interaction as an SSE stream: its saved events, then live
+     * ones as they are logged (each an interaction_event), then an end
+     * event with its final status. An interaction not routed yet is waited
+     * for up to `wait`; one in_progress on another gateway ends at once
+     * with that status. Interactions of other tenants are not found.
+     */
+    private async handleTailInteraction(
+        id: string,
+        tenantId: string,
+        wait: string | null,
+        abort: AbortSignal,
+    ): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+        const waitMs = wait === null ? DEFAULT_TAIL_WAIT_MS : parseDuration(wait, NaN);
+        if (!(waitMs >= 0)) {
+            return this.errorResponse(400, 'wait must be a duration (e.g. "30s")');
+        }
+
+        // Subscribed before reading storage, so no event falls between the
+        // saved ones and the live ones
+        let live = this.tails?.subscribe(id, tenantId);
+        let status: string | undefined;
+        let lifecycle: InteractionLifecycleStatus | undefined;
+        if (!live) {
+            const [conv, record, interaction] = await Promise.all([
+                this.storage.getConversation(id, tenantId),
+                this.storage.getResponse(id, tenantId),
+                this.storage.getInteraction?.(id, tenantId),
+            ]);
+            lifecycle = interaction?.status;
+            status = record?.status
+                ?? (lifecycle !== 'in_progress' ? lifecycle : undefined)
+                ?? (conv ? 'completed' : undefined);
+        }
+        if (!live && !status && await this.tails?.waitFor(id, Math.min(waitMs, MAX_TAIL_WAIT_MS), abort)) {
+            live = this.tails!.subscribe(id, tenantId);
+        }
+        status ??= live ? undefined : lifecycle;
+        if (!live && !status) {
+            return this.errorResponse(404, 'Interaction not found');
+        }
+
+        const saved = await this.storage.getEvents(id, tenantId);
+        return this.sseResponse(tailFrames(saved, live, status));
+    }
+
+    /**
+     * Returns the bodies sent upstream for an interaction, in the order
+     * sent (retries and fallbacks add one each); the last is the call that
+     * answered or failed the request.
+     */
+    private async handleGetProviderRequest(id: string, tenantId: string): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const [conv, record] = await Promise.all([
+            this.storage.getConversation(id, tenantId),
+            this.storage.getResponse(id, tenantId),
+        ]);
+        if (!conv && !record) {
+            return this.errorResponse(404, 'Interaction not found');
+        }
+
+        const requests = (await this.storage.getEvents(id, tenantId))
+            .filter((e) => e.type === 'provider_request')
+            .sort((a, b) => a.timestamp.getTime() - b.timestamp.getTime())
+            .map((e) => ({ ...(e.payload as ProviderRequestPayload), createdAt: e.timestamp.getTime() }));
+        const last = requests[requests.length - 1];
+        if (!last) {
+            return this.errorResponse(404, 'No provider request recorded for this interaction');
+        }
+        return this.jsonResponse({ id, ...last, requests });
+    }
+
+    private async handleGetShadow(id: string, tenantId: string): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const result = await this.storage.getShadowResult(id, tenantId);
+        if (!result) {
+            return this.errorResponse(404, 'Shadow result not found');
+        }
+
+        return this.jsonResponse({ ...result, createdAt: result.createdAt.getTime() });
+    }
+
+    private async handleGetShadowDiff(id: string, shadowId: string, tenantId: string): Promise<Response> {
+        if (!this.storage) {
+            return this.errorResponse(503, 'Storage not configured');
+        }
+
+        const primary = await this.loadPrimarySide(id, tenantId);
+        if (!primary) {
+            return this.errorResponse(404, 'Interaction not found');
+        }
+
+        const shadow = await this.storage.getShadowResult(shadowId, tenantId);
+        if (!shadow || shadow.interactionId !== id) {
+            return this.errorResponse(404, 'Shadow result not found');
+        }
+
+        return this.jsonResponse({
+            interactionId: id,
+            shadowId: shadow.id,
+            providerName: shadow.providerName,
+            diff: diffResponses(primary, {
+                response: shadow.response ? fromShadowResponse(shadow.response) : undefined,
+                error: shadow.error?.message,
+                latencyMs: shadow.durationMs,
+            }),
+        });
+    }
+
+    private async handleListThreads(tenantId: string, options: {
+        limit: number;
+        offset: number;
+    }): Promise<Response> {
+        if (!this.storage?.listConversations) {
+            return this.errorResponse(503, 'Thread storage not configured');
+        }
+
+        const conversations = await this.storage.listConversations(this.listTenant(tenantId), {
+            limit: options.limit,
+            offset: options.offset,
+        });
+
+        const threads = conversations.map((conv) => ({
+            id: conv.id,
+            createdAt: conv.createdAt.getTime(),
+            updatedAt: conv.updatedAt?.getTime() ?? conv.createdAt.getTime(),
+            messageCount: 0, // Would need to query messages
+        }));
+
+        return this.jsonResponse({ threads, total: threads.length });
+    }
+
+    private async handleGetThread(id: string, tenantId: string): Promise<Response> {
+        if (!this.storage?.getConversation) {
+            return this.errorResponse(503, 'Thread storage not configured');
+        }
+
+        const conv = await this.storage.getConversation(id, tenantId);
+        if (!conv) {
+            return this.errorResponse(404, 'Thread not found');
+        }
+
+        return this.jsonResponse({
+            id: conv.id,
+            createdAt: conv.createdAt.getTime(),
+            updatedAt: conv.updatedAt?.getTime() ?? conv.createdAt.getTime(),
+            metadata: conv.metadata,
+        });
+    }
+
+    private async handleListResponses(tenantId: string, options: {
+        limit: number;
+        offset: number;
+    }): Promise<Response> {
+        if (!this.storage?.listResponses) {
+            return this.errorResponse(503, 'Response storage not configured');
+        }
+
+        const records = await this.storage.listResponses(this.listTenant(tenantId), {
+            limit: options.limit,
+            offset: options.offset,
+        });
 
         const responses = records.map((r) => ({
             id: r.id,
@@ -386,12 +1874,12 @@ export class AdminHandler {
         return this.jsonResponse({ responses, total: responses.length });
     }
 
-    private async handleGetResponse(id: string): Promise<Response> {
+    private async handleGetResponse(id: string, tenantId: string): Promise<Response> {
         if (!this.storage?.getResponse) {
             return this.errorResponse(503, 'Response storage not configured');
         }
 
-        const record = await this.storage.getResponse(id);
+        const record = await this.storage.getResponse(id, tenantId);
         if (!record) {
             return this.errorResponse(404, 'Response not found');
         }
@@ -407,6 +1895,60 @@ export class AdminHandler {
 
     // ---- Helpers ----
 
+    /**
+     * Loads the primary side of a shadow comparison from a stored response
+     * record or, failing that, the last assistant turn of a conversation.
+     * Primary latency is not recorded, so it is left unset.
+     */
+    private async loadPrimarySide(id: string, tenantId: string): Promise<DiffSide | null> {
+        const record = await this.storage!.getResponse(id, tenantId);
+        if (record) {
+            const response = record.response as CanonicalResponse | undefined;
+            return {
+                response: response?.choices ? fromCanonicalResponse(response) : undefined,
+                error: record.error !== undefined ? errorMessage(record.error) : undefined,
+            };
+        }
+
+        const conv = await this.storage!.getConversation(id, tenantId);
+        if (conv) {
+            const last = conv.messages.filter((m) => m.role === 'assistant').pop();
+            return {
+                response: last ? { content: last.content, toolCalls: [], usage: last.usage } : undefined,
+            };
+        }
+
+        return null;
+    }
+
+    /**
+     * Resolves the tenant a request may see: UNSCOPED_TENANT for operators
+     * (or when no auth provider is configured), the caller's tenant
+     * otherwise, or null if the request is not authenticated.
+     */
+    private async resolveTenantScope(request: Request): Promise<string | null> {
+        if (!this.auth) {
+            return UNSCOPED_TENANT;
+        }
+
+        const token = extractBearerToken(request.headers.get('Authorization'));
+        if (!token) return null;
+
+        const ctx = await this.auth.authenticate(token);
+        if (!ctx) return null;
+
+        return ctx.scopes.some((s) => ADMIN_OPERATOR_SCOPES.includes(s))
+            ? UNSCOPED_TENANT
+            : ctx.tenantId;
+    }
+
+    /**
+     * List endpoints need a concrete tenant; unscoped callers see 'default'.
+     */
+    private listTenant(tenantId: string): string {
+        return tenantId === UNSCOPED_TENANT ? 'default' : tenantId;
+    }
+
     private jsonResponse(data: unknown, status = 200): Response {
         return new Response(JSON.stringify(data), {
             status,
@@ -414,6 +1956,34 @@ export class AdminHandler {
         });
     }
 
+    private sseResponse(frames: AsyncGenerator<string, void, void>): Response {
+        const encoder = new TextEncoder();
+        const body = new ReadableStream<Uint8Array>({
+            async pull(controller) {
+                try {
+                    const { value, done } = await frames.next();
+                    if (done) {
+                        controller.close();
+                    } else {
+                        controller.enqueue(encoder.encode(value));
+                    }
+                } catch (error) {
+                    controller.error(error);
+                }
+            },
+            cancel() {
+                void frames.return(undefined);
+            },
+        });
+        return new Response(body, {
+            headers: {
+                'Content-Type': 'text/event-stream',
+                'Cache-Control': 'no-cache',
+                'Connection': 'keep-alive',
+            },
+        });
+    }
+
     private errorResponse(status: number, message: string): Response {
         return new Response(JSON.stringify({ error: message }), {
             status,
@@ -461,3 +2031,340 @@ export class AdminHandler {
 // Declare globals for runtime detection
 declare const Deno: unknown;
 declare const Bun: unknown;
+
+/**
+ * Reads ?limit and ?offset (default: the first 50).
+ */
+function pageOptions(query: URLSearchParams): { limit: number; offset: number } {
+    return {
+        limit: parseInt(query.get('limit') ?? '50', 10),
+        offset: parseInt(query.get('offset') ?? '0', 10),
+    };
+}
+
+/**
+ * Extracts a message from a stored error of unknown shape.
+ */
+function errorMessage(error: unknown): string {
+    if (typeof error === 'object' && error !== null && 'message' in error) {
+        return String((error as { message: unknown }).message);
+    }
+    return String(error);
+}
+
+/**
+ * An interaction tail's SSE frames: the saved events, the live ones not
+ * already sent, and the final status (the stored one when not live).
+ */
+async function* tailFrames(
+    saved: InteractionEvent[],
+    live: AsyncGenerator<TailMessage, void, void> | undefined,
+    status: string | undefined,
+): AsyncGenerator<string, void, void> {
+    const frame = (event: string, data: unknown): string => `event: ${event}\ndata: ${JSON.stringify(data)}\n\n`;
+    const sent = new Set<string>();
+    for (const event of saved) {
+        sent.add(event.id);
+        yield frame('interaction_event', { ...event, timestamp: event.timestamp.getTime() });
+    }
+    for await (const message of live ?? []) {
+        if (message.type === 'end') {
+            status = message.status;
+        } else if (!sent.has(message.event.id)) {
+            sent.add(message.event.id);
+            yield frame('interaction_event', { ...message.event, timestamp: message.event.timestamp.getTime() });
+        }
+    }
+    yield frame('end', { status });
+}
+
+/**
+ * Shapes a request's stats as an interaction summary.
+ */
+function requestSummary(record: RequestStatRecord): AdminInteractionSummary {
+    return {
+        id: record.interactionId,
+        type: 'request',
+        status: record.error ? 'failed' : 'completed',
+        model: record.model,
+        durationMs: record.latencyMs,
+        endUser: record.endUser,
+        language: record.language,
+        safetyFlags: record.safetyFlags,
+        createdAt: record.createdAt.getTime(),
+        updatedAt: record.createdAt.getTime(),
+    };
+}
+
+/**
+ * Counts indexed usage discrepancies per provider and model, most first.
+ */
+function countUsageDiscrepancies(records: InteractionMetadataRecord[]): AdminUsageDiscrepancyCount[] {
+    const counts = new Map<string, AdminUsageDiscrepancyCount>();
+    for (const r of records) {
+        const provider = r.metadata['provider'] ?? 'unknown';
+        const model = r.metadata['model'] ?? 'unknown';
+        const key = `${provider}\u0000${model}`;
+        const count = counts.get(key) ?? { provider, model, count: 0, reportedTokens: 0, countedTokens: 0 };
+        count.count++;
+        count.reportedTokens += Number(r.metadata['usage_reported_tokens'] ?? 0);
+        count.countedTokens += Number(r.metadata['usage_counted_tokens'] ?? 0);
+        counts.set(key, count);
+    }
+    return [...counts.values()].sort((a, b) => b.count - a.count);
+}
+
+/**
+ * Shapes a stored mapping for the admin API. Affinity values are provider
+ * pins; any other value is the thread's current response ID.
+ */
+function toAdminThreadState(entry: ThreadStateEntry): AdminThreadState {
+    const affinity = entry.threadKey.startsWith('affinity:') ? parseAffinityState(entry.responseId) : undefined;
+    return {
+        keyHash: entry.keyHash,
+        kind: affinity ? 'affinity' : 'response',
+        provider: affinity?.provider,
+        responseId: affinity ? undefined : entry.responseId,
+        updatedAt: entry.updatedAt.getTime(),
+    };
+}
+
+/** Metadata keys an erasure may select on. */
+const ERASURE_METADATA_KEY = /^[A-Za-z0-9_.-]+$/;
+
+/**
+ * Parses a POST /api/privacy/erase body, or returns what is wrong with it.
+ * Metadata values are only unique within a tenant, so a metadata selector
+ * needs tenant_id.
+ */
+function parseErasureSelector(body: unknown): ErasureSelector | string {
+    if (typeof body !== 'object' || body === null || Array.isArray(body)) {
+        return 'Body must be a JSON object';
+    }
+    const {
+        tenant_id: tenantId,
+        metadata,
+        thread_key: threadKey,
+        interaction_ids: interactionIds,
+    } = body as Record<string, unknown>;
+    const selector: ErasureSelector = {};
+
+    if (tenantId !== undefined) {
+        if (typeof tenantId !== 'string' || !tenantId) {
+            return 'tenant_id must be a non-empty string';
+        }
+        selector.tenantId = tenantId;
+    }
+
+    if (metadata !== undefined) {
+        const { key, value } = (metadata ?? {}) as Record<string, unknown>;
+        if (typeof key !== 'string' || !ERASURE_METADATA_KEY.test(key) || typeof value !== 'string' || !value) {
+            return 'metadata must be {"key": ..., "value": ...} with a non-empty key and value';
+        }
+        if (!selector.tenantId) {
+            return 'metadata requires tenant_id';
+        }
+        selector.metadata = { key, value };
+    }
+
+    if (threadKey !== undefined) {
+        if (typeof threadKey !== 'string' || !threadKey) {
+            return 'thread_key must be a non-empty string';
+        }
+        selector.threadKey = threadKey;
+    }
+
+    if (interactionIds !== undefined) {
+        if (!Array.isArray(interactionIds) || interactionIds.length === 0
+            || !interactionIds.every((id) => typeof id === 'string' && id)) {
+            return 'interaction_ids must be a non-empty array of strings';
+        }
+        selector.interactionIds = interactionIds as string[];
+    }
+
+    if (!selector.metadata && !selector.threadKey && !selector.interactionIds) {
+        return 'One of metadata, thread_key, or interaction_ids is required';
+    }
+    return selector;
+}
+
+/**
+ * Parses a POST /api/maintenance/migrate-model body, or returns what is
+ * wrong with it.
+ */
+function parseModelMigration(body: unknown): ModelMigrationRequest | string {
+    if (typeof body !== 'object' || body === null || Array.isArray(body)) {
+        return 'Body must be a JSON object';
+    }
+    const { from_model: fromModel, to_model: toModel, mode = 'reroute_only' } = body as Record<string, unknown>;
+    if (typeof fromModel !== 'string' || !fromModel || typeof toModel !== 'string' || !toModel) {
+        return 'from_model and to_model must be non-empty strings';
+    }
+    if (fromModel === toModel) {
+        return 'from_model and to_model must differ';
+    }
+    if (mode !== 'reroute_only' && mode !== 'reset_threads') {
+        return "mode must be 'reroute_only' or 'reset_threads'";
+    }
+    return { fromModel, toModel, mode };
+}
+
+/**
+ * Parses the options of a POST /api/import/threads body: tenant_map
+ * renames exported tenants, batch_size sets the records per transaction.
+ */
+function parseThreadImportOptions(
+    body: Record<string, unknown>,
+): Pick<ThreadImportOptions, 'tenantMap' | 'batchSize'> | string {
+    const { tenant_map: tenantMap, batch_size: batchSize } = body;
+    if (tenantMap !== undefined && (typeof tenantMap !== 'object' || tenantMap === null || Array.isArray(tenantMap)
+        || !Object.values(tenantMap).every((id) => typeof id === 'string' && id))) {
+        return 'tenant_map must map exported tenant IDs to non-empty tenant IDs';
+    }
+    if (batchSize !== undefined
+        && (!Number.isInteger(batchSize) || (batchSize as number) <= 0 || (batchSize as number) > MAX_IMPORT_BATCH_SIZE)) {
+        return `batch_size must be an integer from 1 to ${MAX_IMPORT_BATCH_SIZE}`;
+    }
+    return {
+        tenantMap: tenantMap as Record<string, string> | undefined,
+        batchSize: batchSize as number | undefined,
+    };
+}
+
+/**
+ * Reads a JSON object body, or returns an error message.
+ */
+async function readJSONObject(request: Request): Promise<Record<string, unknown> | string> {
+    let body: unknown;
+    try {
+        body = await request.json();
+    } catch {
+        return 'Invalid JSON body';
+    }
+    if (typeof body !== 'object' || body === null || Array.isArray(body)) {
+        return 'Body must be a JSON object';
+    }
+    return body as Record<string, unknown>;
+}
+
+/**
+ * Validates a tenant creation body: {id?, name, routing?}.
+ */
+function parseCreateTenant(body: Record<string, unknown>): CreateTenantInput | string {
+    const { id, name, routing } = body;
+    if (id !== undefined && (typeof id !== 'string' || !id)) {
+        return 'id must be a non-empty string';
+    }
+    if (typeof name !== 'string' || !name) {
+        return 'name is required';
+    }
+    const parsedRouting = routing === undefined ? undefined : parseTenantRouting(routing);
+    if (typeof parsedRouting === 'string') {
+        return parsedRouting;
+    }
+    return { id: id as string | undefined, name, routing: parsedRouting };
+}
+
+/**
+ * Validates a tenant update body: {name?, routing?}; routing null reverts
+ * to the global routing.
+ */
+function parseUpdateTenant(body: Record<string, unknown>): UpdateTenantInput | string {
+    const { name, routing } = body;
+    if (name !== undefined && (typeof name !== 'string' || !name)) {
+        return 'name must be a non-empty string';
+    }
+    if (name === undefined && routing === undefined) {
+        return 'One of name or routing is required';
+    }
+    const newName = name as string | undefined;
+    if (routing === undefined || routing === null) {
+        return { name: newName, routing };
+    }
+    const parsedRouting = parseTenantRouting(routing);
+    return typeof parsedRouting === 'string' ? parsedRouting : { name: newName, routing: parsedRouting };
+}
+
+/**
+ * Validates routing: {rules?: [{model_prefix | model_exact, provider}], default_provider?}.
+ */
+function parseTenantRouting(raw: unknown): RoutingConfig | string {
+    if (typeof raw !== 'object' || raw === null || Array.isArray(raw)) {
+        return 'routing must be an object';
+    }
+    const { rules, default_provider: defaultProvider } = raw as Record<string, unknown>;
+    if (defaultProvider !== undefined && (typeof defaultProvider !== 'string' || !defaultProvider)) {
+        return 'routing.default_provider must be a non-empty string';
+    }
+    if (rules !== undefined && !Array.isArray(rules)) {
+        return 'routing.rules must be an array';
+    }
+
+    const parsed: RoutingRule[] = [];
+    for (const [i, rule] of ((rules as unknown[] | undefined) ?? []).entries()) {
+        const {
+            model_prefix: modelPrefix,
+            model_exact: modelExact,
+            provider,
+        } = (rule ?? {}) as Record<string, unknown>;
+        if (typeof provider !== 'string' || !provider) {
+            return `routing.rules[${i}].provider is required`;
+        }
+        if (typeof modelPrefix !== 'string' && typeof modelExact !== 'string') {
+            return `routing.rules[${i}] needs model_prefix or model_exact`;
+        }
+        parsed.push({
+            provider,
+            ...(typeof modelPrefix === 'string' && { modelPrefix }),
+            ...(typeof modelExact === 'string' && { modelExact }),
+        });
+    }
+    if (parsed.length === 0 && defaultProvider === undefined) {
+        return 'routing needs rules or a default_provider';
+    }
+    return { rules: parsed, defaultProvider: defaultProvider as string | undefined };
+}
+
+/**
+ * Validates a logging change: {level?, scopes?: [{tenant?, provider?, app?}], ttl?}.
+ */
+function parseLoggingChange(body: Record<string, unknown>): LoggingChange | string {
+    const { level, scopes, ttl } = body;
+    if (level !== undefined && (typeof level !== 'string' || !Object.keys(LOG_LEVELS).includes(level))) {
+        return `level must be one of ${Object.keys(LOG_LEVELS).join(', ')}`;
+    }
+
+    let ttlMs: number | undefined;
+    if (ttl !== undefined) {
+        ttlMs = typeof ttl === 'string' ? parseDuration(ttl, NaN) : NaN;
+        if (!(ttlMs > 0)) {
+            return 'ttl must be a positive duration (e.g. "15m")';
+        }
+    }
+
+    if (scopes !== undefined && !Array.isArray(scopes)) {
+        return 'scopes must be an array';
+    }
+    const parsed: LogScope[] = [];
+    for (const [i, scope] of (scopes ?? []).entries()) {
+        if (typeof scope !== 'object' || scope === null) {
+            return `scopes[${i}] must be an object`;
+        }
+        const { tenant, provider, app } = scope as Record<string, unknown>;
+        for (const [name, value] of Object.entries({ tenant, provider, app })) {
+            if (value !== undefined && (typeof value !== 'string' || !value)) {
+                return `scopes[${i}].${name} must be a non-empty string`;
+            }
+        }
+        if (tenant === undefined && provider === undefined && app === undefined) {
+            return `scopes[${i}] needs tenant, provider, or app`;
+        }
+        parsed.push({
+            ...(tenant !== undefined && { tenant: tenant as string }),
+            ...(provider !== undefined && { provider: provider as string }),
+            ...(app !== undefined && { app: app as string }),
+        });
+    }
+
+    return { level: level as LoggingChange['level'], scopes: parsed, ttlMs };
+}
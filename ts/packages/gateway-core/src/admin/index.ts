@@ -7,6 +7,7 @@
 export {
     // Handler
     AdminHandler,
+    ADMIN_OPERATOR_SCOPES,
     type AdminHandlerOptions,
 
     // Response types
@@ -16,9 +17,23 @@ export {
     type StorageSummary,
     type AppSummary,
     type ProviderSummary,
+    type ProviderHealthSummary,
     type FrontdoorSummary,
     type AdminRoutingSummary,
     type AdminRoutingRule,
     type AdminInteractionSummary,
+    type AdminInteractionAttempt,
     type AdminInteractionsListResponse,
+    type AdminFinishReasonCount,
+    type AdminThreadState,
+    type AdminThreadStateTouch,
+    type AdminThreadStateListResponse,
+    type AdminThreadStateDetail,
 } from './handler.js';
+
+export {
+    matchAdminRoute,
+    adminRouteKey,
+    type AdminRouteKey,
+    type AdminRouteMatch,
+} from './routes.js';
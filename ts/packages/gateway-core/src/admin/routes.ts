@@ -0,0 +1,95 @@
+/**
+ * Admin route table.
+ *
+ * Matches admin requests against the operations the admin OpenAPI
+ * document lists, so the handler serves exactly what is documented: an
+ * operation's method and path select its endpoint, and its auth
+ * requirement decides who may call it. Paths with fewer parameters are
+ * tried first, so /api/thread-state/purge is never taken for a hash.
+ *
+ * @module admin/routes
+ */
+
+import { errInvalidRequest } from '../domain/errors.js';
+import { ADMIN_OPERATIONS, type OpenAPIOperation } from '../openapi/operations.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+type RouteKey<T> = T extends { method: infer M extends string; path: infer P extends string } ? `${M} ${P}` : never;
+
+/**
+ * An admin operation's method and path, as `GET /api/tenants/:id`.
+ */
+export type AdminRouteKey = RouteKey<(typeof ADMIN_OPERATIONS)[number]>;
+
+/**
+ * The operation a request matched.
+ */
+export interface AdminRouteMatch {
+    key: AdminRouteKey;
+    operation: OpenAPIOperation;
+
+    /** Path parameters by name, decoded. */
+    params: Record<string, string>;
+}
+
+interface CompiledRoute {
+    key: AdminRouteKey;
+    operation: OpenAPIOperation;
+    pattern: RegExp;
+    names: string[];
+}
+
+// ============================================================================
+// Matching
+// ============================================================================
+
+const ROUTES: CompiledRoute[] = ADMIN_OPERATIONS
+    .map((operation) => compile(operation))
+    .sort((a, b) => a.names.length - b.names.length);
+
+/**
+ * The method and path key of an operation.
+ */
+export function adminRouteKey(operation: Pick<OpenAPIOperation, 'method' | 'path'>): AdminRouteKey {
+    return `${operation.method} ${operation.path}` as AdminRouteKey;
+}
+
+/**
+ * Finds the admin operation serving a method and path, if any. Fails with
+ * a 400 when a path parameter is not valid percent-encoding.
+ */
+export function matchAdminRoute(method: string, path: string): AdminRouteMatch | undefined {
+    for (const route of ROUTES) {
+        if (route.operation.method !== method) {
+            continue;
+        }
+        const match = route.pattern.exec(path);
+        if (match) {
+            const params = Object.fromEntries(route.names.map((name, i) => [name, decodeParam(match[i + 1]!)]));
+            return { key: route.key, operation: route.operation, params };
+        }
+    }
+    return undefined;
+}
+
+function decodeParam(value: string): string {
+    try {
+        return decodeURIComponent(value);
+    } catch {
+        throw errInvalidRequest('Malformed percent-encoding in path');
+    }
+}
+
+function compile(operation: OpenAPIOperation): CompiledRoute {
+    const names: string[] = [];
+    const source = operation.path
+        .replace(/[.*+?^${}()|[\]\\]/g, '\\$&')
+        .replace(/:(\w+)/g, (_, name: string) => {
+            names.push(name);
+            return '([^/]+)';
+        });
+    return { key: adminRouteKey(operation), operation, pattern: new RegExp(`^${source}$`), names };
+}
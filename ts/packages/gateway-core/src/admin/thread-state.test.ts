@@ -0,0 +1,162 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from './index';
+import type { ThreadStateEntry } from '../ports/index';
+import type { InteractionEvent } from '../domain/events';
+import { sha256 } from '../utils/crypto';
+import { harness, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+function memoryStorage() {
+    const states = new Map<string, ThreadStateEntry>();
+    const events: InteractionEvent[] = [];
+    return {
+        states,
+        events,
+        setThreadState: async (threadKey: string, responseId: string) => {
+            states.set(threadKey, { threadKey, keyHash: await sha256(threadKey), responseId, updatedAt: new Date() });
+        },
+        getThreadState: async (threadKey: string) => states.get(threadKey)?.responseId ?? null,
+        listThreadState: async (options: { limit?: number; cursor?: string } = {}) => [...states.values()]
+            .filter((e) => !options.cursor || e.keyHash > options.cursor)
+            .sort((a, b) => a.keyHash.localeCompare(b.keyHash))
+            .slice(0, options.limit ?? 50),
+        findThreadState: async (keyHash: string) => [...states.values()].find((e) => e.keyHash === keyHash) ?? null,
+        deleteThreadState: async (threadKey: string) => states.delete(threadKey),
+        purgeThreadState: async (before: Date) => {
+            let purged = 0;
+            for (const [key, entry] of states) {
+                if (entry.updatedAt < before) {
+                    states.delete(key);
+                    purged++;
+                }
+            }
+            return purged;
+        },
+        saveEvent: async (event: InteractionEvent) => {
+            events.push(event);
+        },
+        getEvents: async (interactionId: string) => events.filter((e) => e.interactionId === interactionId),
+    };
+}
+
+describe('Admin thread state API', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const storage = memoryStorage();
+        const logger = spyLogger();
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .config({
+                providers: [{ name: 'mock', type: 'mock', apiKey: 'sk-mock', responsesThreadKeyPath: 'metadata.user_id' }],
+                routing: { defaultProvider: 'mock', affinity: { ttl: '1h' } },
+            })
+            .options({ storage: storage as any })
+            .start();
+        const gw = gateway;
+        const admin = new AdminHandler({
+            storage: storage as any,
+            threadState: gw.gateway.threadState,
+            metadataIndex: gw.gateway.metadataIndex,
+            logger,
+        });
+        const send = (userId: string) =>
+            gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], metadata: { user_id: userId } });
+        const call = (path: string, method = 'GET') => admin.handle(new Request(`http://localhost${path}`, { method }));
+        return { gateway: gw.gateway, storage, logger, send, call };
+    }
+
+    it('should list mappings by key hash without exposing thread keys', async () => {
+        const { gateway, storage, send, call } = await setup();
+        await gateway.reload();
+        await send('alice@example.com');
+        // Affinity maps both the thread and the response it produced
+        await vi.waitFor(() => expect(storage.states.size).toBe(2));
+        await storage.setThreadState('thread:t1', 'resp_9');
+
+        const response = await call('/api/thread-state');
+        const text = await response.text();
+        const body = JSON.parse(text);
+
+        expect(response.status).toBe(200);
+        expect(text).not.toContain('alice@example.com');
+        expect(body.nextCursor).toBeNull();
+        expect(body.entries).toHaveLength(3);
+        expect(body.entries).toContainEqual(expect.objectContaining({
+            keyHash: await sha256('affinity:acme:thread:alice@example.com'),
+            kind: 'affinity',
+            provider: 'mock',
+        }));
+        expect(body.entries).toContainEqual(expect.objectContaining({
+            keyHash: await sha256('thread:t1'), kind: 'response', responseId: 'resp_9',
+        }));
+
+        const page = await (await call('/api/thread-state?limit=1')).json();
+        expect(page.entries).toEqual([body.entries[0]]);
+        expect(page.nextCursor).toBe(body.entries[0].keyHash);
+        expect((await call('/api/thread-state?limit=0')).status).toBe(400);
+    });
+
+    it('should show the interactions that resolved and updated a mapping', async () => {
+        const { gateway, storage, send, call } = await setup();
+        await gateway.reload();
+        const first = (await send('u1')).headers.get('X-Gateway-Interaction-Id');
+        await vi.waitFor(() => expect(storage.states.size).toBe(2));
+        const second = (await send('u1')).headers.get('X-Gateway-Interaction-Id');
+        const keyHash = await sha256('affinity:acme:thread:u1');
+
+        await vi.waitFor(async () => {
+            const body = await (await call(`/api/thread-state/${keyHash}`)).json();
+            expect(body.chain.map((c: any) => [c.interactionId, c.type, c.outcome ?? null])).toEqual([
+                [first, 'thread_resolve', 'miss'],
+                [first, 'thread_update', null],
+                [second, 'thread_resolve', 'hit'],
+                [second, 'thread_update', null],
+            ]);
+            expect(body.chain[2]).toMatchObject({ tenantId: 'acme', provider: 'mock' });
+        });
+        expect((await call(`/api/thread-state/${'0'.repeat(64)}`)).status).toBe(404);
+    });
+
+    it('should delete a mapping and drop it from the affinity cache, with an audit log', async () => {
+        const { gateway, storage, logger, send, call } = await setup();
+        await gateway.reload();
+        await send('u1');
+        await vi.waitFor(() => expect(storage.states.size).toBe(2));
+        const keyHash = await sha256('affinity:acme:thread:u1');
+
+        const response = await call(`/api/thread-state/${keyHash}`, 'DELETE');
+
+        expect(await response.json()).toEqual({ deleted: true, keyHash });
+        expect(storage.states.has('affinity:acme:thread:u1')).toBe(false);
+        expect(logger.info).toHaveBeenCalledWith('thread_state_deleted', { audit: true, keyHash });
+        expect((await call(`/api/thread-state/${keyHash}`, 'DELETE')).status).toBe(404);
+
+        // The next turn no longer finds the thread's provider in memory either
+        const next = (await send('u1')).headers.get('X-Gateway-Interaction-Id');
+        await vi.waitFor(() => expect(storage.events).toContainEqual(expect.objectContaining({
+            interactionId: next, type: 'thread_resolve', payload: { keyHash, outcome: 'miss' },
+        })));
+    });
+
+    it('should purge mappings older than a duration', async () => {
+        const { gateway, storage, logger, call } = await setup();
+        await gateway.reload();
+        await storage.setThreadState('thread:old', 'resp_1');
+        await storage.setThreadState('thread:new', 'resp_2');
+        storage.states.get('thread:old')!.updatedAt = new Date(Date.now() - 2 * 86_400_000);
+
+        const response = await call('/api/thread-state/purge?older_than=24h', 'POST');
+
+        expect(await response.json()).toMatchObject({ purged: 1 });
+        expect([...storage.states.keys()]).toEqual(['thread:new']);
+        expect(logger.info).toHaveBeenCalledWith('thread_state_purged', { audit: true, olderThan: '24h', purged: 1 });
+        expect((await call('/api/thread-state/purge', 'POST')).status).toBe(400);
+        expect((await call('/api/thread-state/purge?older_than=soon', 'POST')).status).toBe(400);
+    });
+});
@@ -0,0 +1,196 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { Router } from './router';
+import { ThreadAffinity, threadKeysOf } from './affinity/index';
+import { createProviderRegistry, type ProviderCredentials } from './ports/index';
+
+function memoryThreadState() {
+    const states = new Map<string, string>();
+    return {
+        states,
+        getThreadState: vi.fn(async (key: string) => states.get(key) ?? null),
+        setThreadState: vi.fn(async (key: string, value: string) => {
+            states.set(key, value);
+        }),
+    };
+}
+
+describe('ThreadAffinity', () => {
+    it('should scope threads by tenant', async () => {
+        const affinity = new ThreadAffinity();
+        affinity.remember('acme', ['thread:u1'], 'anthropic');
+
+        expect(await affinity.lookup('acme', 'thread:u1')).toBe('anthropic');
+        expect(await affinity.lookup('globex', 'thread:u1')).toBeUndefined();
+    });
+
+    it('should forget threads after the TTL and evict the least recent past the size bound', async () => {
+        let now = 0;
+        const affinity = new ThreadAffinity({ ttlMs: 1000, maxEntries: 2, now: () => now });
+        affinity.remember('t', ['a'], 'p1');
+        affinity.remember('t', ['b'], 'p1');
+        await affinity.lookup('t', 'a');
+        affinity.remember('t', ['c'], 'p1');
+
+        expect(affinity.size).toBe(2);
+        expect(await affinity.lookup('t', 'b')).toBeUndefined();
+        expect(await affinity.lookup('t', 'a')).toBe('p1');
+
+        now = 1000;
+        expect(await affinity.lookup('t', 'a')).toBeUndefined();
+    });
+
+    it('should fall back to the store on a cache miss', async () => {
+        const store = memoryThreadState();
+        new ThreadAffinity({ store: store as any }).remember('t', ['thread:u1'], 'anthropic');
+        await vi.waitFor(() => expect(store.setThreadState).toHaveBeenCalled());
+
+        const restarted = new ThreadAffinity({ store: store as any });
+
+        expect(await restarted.lookup('t', 'thread:u1')).toBe('anthropic');
+        expect(store.getThreadState).toHaveBeenCalledWith('affinity:t:thread:u1');
+        expect(restarted.size).toBe(1);
+    });
+
+    it('should treat a store failure as a miss', async () => {
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+        const store = { getThreadState: vi.fn().mockRejectedValue(new Error('db down')), setThreadState: vi.fn() };
+        const affinity = new ThreadAffinity({ store: store as any, logger: logger as any });
+
+        expect(await affinity.lookup('t', 'thread:u1')).toBeUndefined();
+        expect(logger.warn).toHaveBeenCalledWith('thread_affinity_lookup_failed', { error: 'db down' });
+    });
+
+    it('should key threads by the thread key path and the previous response', () => {
+        const body = { metadata: { user_id: 'u1' }, previous_response_id: 'resp_1' };
+
+        expect(threadKeysOf(body, 'metadata.user_id')).toEqual(['thread:u1', 'response:resp_1']);
+        expect(threadKeysOf({ model: 'gpt-4o' }, 'metadata.user_id')).toEqual([]);
+    });
+});
+
+describe('Router.allows', () => {
+    const routing = {
+        rules: [
+            { modelPrefix: 'gpt', provider: 'primary' },
+            { modelPrefix: 'gpt', provider: 'backup' },
+        ],
+        defaultProvider: 'primary',
+    };
+
+    it('should allow any provider a matching rule targets', () => {
+        const router = new Router({ defaultRouting: routing });
+
+        expect(router.allows('backup', 'gpt-4o')).toBe(true);
+        expect(router.allows('legacy', 'gpt-4o')).toBe(false);
+        expect(router.allows('backup', 'claude-3')).toBe(false);
+    });
+
+    it('should allow only the forced provider of an app', () => {
+        const router = new Router({ defaultRouting: routing });
+        const app = { name: 'pinned', frontdoor: 'openai', path: '/v1', provider: 'primary' };
+
+        expect(router.allows('backup', 'gpt-4o', app)).toBe(false);
+        expect(router.allows('primary', 'gpt-4o', app)).toBe(true);
+    });
+});
+
+describe('Gateway thread affinity', () => {
+    function setup() {
+        const credentials: Record<string, ProviderCredentials> = {};
+        const providers = Object.fromEntries(['primary', 'backup'].map((name) => [name, {
+            name,
+            apiType: 'openai' as const,
+            complete: vi.fn(async () => ({
+                id: `resp_${name}`, object: 'chat.completion', created: 0, model: 'gpt-4o', sourceAPIType: 'openai' as const,
+                choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content: name } }],
+                usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            })),
+            stream: vi.fn(),
+        }]));
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', (config) => {
+            credentials[config.name] = config.credentials!;
+            return providers[config.name]!;
+        });
+        const store = memoryThreadState();
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+        logger.child.mockReturnValue(logger);
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'default', frontdoor: 'openai', path: '/v1' }],
+                    providers: ['primary', 'backup'].map((name) => ({
+                        name, type: 'mock', apiKey: `sk-${name}`, responsesThreadKeyPath: 'metadata.user_id',
+                    })),
+                    routing: {
+                        rules: [{ modelPrefix: 'gpt', provider: 'primary' }, { modelPrefix: 'gpt', provider: 'backup' }],
+                        defaultProvider: 'primary',
+                        affinity: { ttl: '1h' },
+                    },
+                }),
+            },
+            auth: { authenticate: async () => ({ tenantId: 't', scopes: [], metadata: {} }), getTenant: async () => null },
+            storage: store as any,
+            providerRegistry,
+            logger: logger as any,
+        });
+        const send = (userId: string) => gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], metadata: { user_id: userId } }),
+        }));
+        const stick = (userId: string, provider: string) => store.states.set(
+            `affinity:t:thread:${userId}`,
+            JSON.stringify({ provider, updatedAt: Date.now() }),
+        );
+        return { gateway, providers, credentials, store, logger, send, stick };
+    }
+
+    it('should keep a thread on the provider found in the store', async () => {
+        const { gateway, providers, send, stick } = setup();
+        await gateway.reload();
+        stick('u1', 'backup');
+
+        await send('u1');
+        await send('u1');
+        await send('u2');
+
+        expect(providers.backup!.complete).toHaveBeenCalledTimes(2);
+        expect(providers.primary!.complete).toHaveBeenCalledTimes(1);
+    });
+
+    it('should break affinity when the provider fails over, and flag it', async () => {
+        const { gateway, providers, credentials, logger, send, stick } = setup();
+        await gateway.reload();
+        stick('u1', 'backup');
+        const backup = credentials.backup!;
+        backup.report(backup.acquire(), 429);
+
+        const response = await send('u1');
+
+        expect(response.status).toBe(200);
+        expect(providers.primary!.complete).toHaveBeenCalledTimes(1);
+        expect(providers.backup!.complete).not.toHaveBeenCalled();
+        expect(logger.info).toHaveBeenCalledWith('interaction_metadata', expect.objectContaining({ affinity_break: 'unhealthy' }));
+
+        // The thread now continues on the provider that served it
+        await send('u1');
+        expect(providers.primary!.complete).toHaveBeenCalledTimes(2);
+    });
+
+    it('should break affinity when routing no longer allows the provider', async () => {
+        const { gateway, providers, logger, send, stick } = setup();
+        await gateway.reload();
+        stick('u1', 'retired');
+
+        await send('u1');
+
+        expect(providers.primary!.complete).toHaveBeenCalledTimes(1);
+        expect(logger.info).toHaveBeenCalledWith('thread_affinity_broken', {
+            provider: 'retired',
+            selected: 'primary',
+            reason: 'not_routable',
+        });
+    });
+});
@@ -0,0 +1,301 @@
+/**
+ * Thread affinity: keeps the turns of one conversation on one provider.
+ *
+ * Switching providers mid-conversation changes the reply style and loses
+ * provider-side prompt caching. Once a threaded request has been served, the
+ * provider is remembered under its thread key, per tenant, in a bounded TTL
+ * cache backed by thread state storage (so affinity survives restarts and is
+ * shared across instances). The gateway prefers the remembered provider
+ * while it is healthy and routing still allows it.
+ *
+ * @module affinity/affinity
+ */
+
+import type { ThreadStateStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default time a thread stays on its provider after its last turn. */
+export const DEFAULT_AFFINITY_TTL_MS = 60 * 60 * 1000;
+
+/** Default number of threads remembered in memory. */
+export const DEFAULT_AFFINITY_MAX_ENTRIES = 10_000;
+
+/** Thread state key prefix, keeping affinity apart from Responses threading. */
+const STORE_PREFIX = 'affinity:';
+
+/** Metadata index value marking an interaction that touched a thread state mapping. */
+export const THREAD_STATE_TOUCHED = 'touched';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Thread affinity options.
+ */
+export interface ThreadAffinityOptions {
+    /** How long a thread stays on its provider after its last turn (ms). */
+    ttlMs?: number | undefined;
+
+    /** Maximum threads remembered in memory; the least recent are evicted. */
+    maxEntries?: number | undefined;
+
+    /** Thread state storage consulted on a cache miss. */
+    store?: Pick<ThreadStateStore, 'getThreadState' | 'setThreadState'> | undefined;
+
+    /** Logger for storage failures. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests. */
+    now?: (() => number) | undefined;
+}
+
+/**
+ * A thread state lookup or write made for an interaction, recorded as a
+ * thread_resolve or thread_update event.
+ */
+export interface ThreadStateTouch {
+    /** Thread key (unscoped). */
+    threadKey: string;
+
+    /** Provider found or written. */
+    provider?: string | undefined;
+
+    /** Lookup outcome: served by the remembered provider, not found, or not usable. */
+    outcome?: 'hit' | 'miss' | 'broken' | undefined;
+}
+
+/** A thread's provider, as cached and stored. */
+interface AffinityEntry {
+    provider: string;
+    updatedAt: number;
+
+    /** Model the last turn was served with (absent on entries written before it was recorded). */
+    model?: string | undefined;
+}
+
+// ============================================================================
+// Thread Affinity
+// ============================================================================
+
+/**
+ * Remembers which provider served each thread, per tenant.
+ */
+export class ThreadAffinity {
+    private readonly entries = new Map<string, AffinityEntry>();
+    private readonly ttlMs: number;
+    private readonly maxEntries: number;
+    private readonly store: Pick<ThreadStateStore, 'getThreadState' | 'setThreadState'> | undefined;
+    private readonly logger: Logger | undefined;
+    private readonly now: () => number;
+
+    constructor(options: ThreadAffinityOptions = {}) {
+        this.ttlMs = options.ttlMs ?? DEFAULT_AFFINITY_TTL_MS;
+        this.maxEntries = options.maxEntries ?? DEFAULT_AFFINITY_MAX_ENTRIES;
+        this.store = options.store;
+        this.logger = options.logger;
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Returns the provider that served a thread's previous turn, checking
+     * the cache and then storage. Expired and unreadable entries are misses.
+     */
+    async lookup(tenantId: string, threadKey: string): Promise<string | undefined> {
+        const key = scopedKey(tenantId, threadKey);
+        const cached = this.entries.get(key);
+        if (cached) {
+            if (this.fresh(cached)) {
+                this.cache(key, cached);
+                return cached.provider;
+            }
+            this.entries.delete(key);
+        }
+        if (!this.store) {
+            return undefined;
+        }
+
+        let stored: AffinityEntry | undefined;
+        try {
+            stored = parseAffinityState(await this.store.getThreadState(STORE_PREFIX + key));
+        } catch (error) {
+            this.logger?.warn('thread_affinity_lookup_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return undefined;
+        }
+        if (!stored || !this.fresh(stored)) {
+            return undefined;
+        }
+        this.cache(key, stored);
+        return stored.provider;
+    }
+
+    /**
+     * Records the provider and model that served a turn under each of its
+     * thread keys. Storage is written in the background.
+     */
+    remember(tenantId: string, threadKeys: string[], provider: string, model?: string): void {
+        const entry: AffinityEntry = { provider, updatedAt: this.now(), ...(model !== undefined && { model }) };
+        for (const threadKey of threadKeys) {
+            const key = scopedKey(tenantId, threadKey);
+            this.cache(key, entry);
+            this.store?.setThreadState(affinityStateKey(tenantId, threadKey), JSON.stringify(entry)).catch((error: unknown) => {
+                this.logger?.warn('thread_affinity_save_failed', {
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            });
+        }
+    }
+
+    /**
+     * Drops a stored thread state key from the cache, so a mapping deleted
+     * from storage isn't served from memory.
+     */
+    forget(stateKey: string): void {
+        if (stateKey.startsWith(STORE_PREFIX)) {
+            this.entries.delete(stateKey.slice(STORE_PREFIX.length));
+        }
+    }
+
+    /**
+     * Drops cached threads last served by a model.
+     */
+    forgetModel(model: string): void {
+        for (const [key, entry] of this.entries) {
+            if (entry.model === model) {
+                this.entries.delete(key);
+            }
+        }
+    }
+
+    /**
+     * Drops cached threads last served before a time.
+     */
+    forgetBefore(before: Date): void {
+        for (const [key, entry] of this.entries) {
+            if (entry.updatedAt < before.getTime()) {
+                this.entries.delete(key);
+            }
+        }
+    }
+
+    /**
+     * Number of threads remembered in memory.
+     */
+    get size(): number {
+        return this.entries.size;
+    }
+
+    private fresh(entry: AffinityEntry): boolean {
+        return this.now() - entry.updatedAt < this.ttlMs;
+    }
+
+    private cache(key: string, entry: AffinityEntry): void {
+        // Re-inserting keeps the map in least-recently-used order
+        this.entries.delete(key);
+        this.entries.set(key, entry);
+        while (this.entries.size > this.maxEntries) {
+            this.entries.delete(this.entries.keys().next().value!);
+        }
+    }
+}
+
+// ============================================================================
+// Thread Keys
+// ============================================================================
+
+/**
+ * Returns a request's thread keys: the value at the Responses thread key
+ * path (e.g. metadata.user_id), and the previous response it continues.
+ * A request with neither is not threaded.
+ */
+export function threadKeysOf(body: Record<string, unknown>, threadKeyPath?: string): string[] {
+    const keys: string[] = [];
+    const value = threadKeyPath ? valueAt(body, threadKeyPath) : undefined;
+    if (typeof value === 'string' && value) {
+        keys.push(`thread:${value}`);
+    }
+    const previous = body.previous_response_id ?? body.previousResponseId;
+    if (typeof previous === 'string' && previous) {
+        keys.push(responseThreadKey(previous));
+    }
+    return keys;
+}
+
+/**
+ * Thread key under which a response is remembered, so the request that
+ * continues it (previous_response_id) finds its provider.
+ */
+export function responseThreadKey(responseId: string): string {
+    return `response:${responseId}`;
+}
+
+function valueAt(body: Record<string, unknown>, path: string): unknown {
+    let current: unknown = body;
+    for (const segment of path.split('.')) {
+        if (typeof current !== 'object' || current === null) {
+            return undefined;
+        }
+        current = (current as Record<string, unknown>)[segment];
+    }
+    return current;
+}
+
+function scopedKey(tenantId: string, threadKey: string): string {
+    return `${tenantId}:${threadKey}`;
+}
+
+/**
+ * Thread state key under which a tenant's thread provider is stored.
+ */
+export function affinityStateKey(tenantId: string, threadKey: string): string {
+    return STORE_PREFIX + scopedKey(tenantId, threadKey);
+}
+
+/**
+ * Splits a thread state key written by affinityStateKey into its tenant
+ * and thread key. Other keys are undefined.
+ */
+export function parseAffinityStateKey(stateKey: string): { tenantId: string; threadKey: string } | undefined {
+    if (!stateKey.startsWith(STORE_PREFIX)) return undefined;
+    const scoped = stateKey.slice(STORE_PREFIX.length);
+    const separator = scoped.indexOf(':');
+    if (separator < 0) return undefined;
+    return { tenantId: scoped.slice(0, separator), threadKey: scoped.slice(separator + 1) };
+}
+
+/**
+ * Metadata index key for the interactions that touched a thread state
+ * mapping, by key hash.
+ */
+export function threadStateIndexKey(keyHash: string): string {
+    return `thread_state.${keyHash}`;
+}
+
+/**
+ * Parses a stored thread state value as an affinity entry. Values written
+ * by anything else are undefined.
+ */
+export function parseAffinityState(
+    value: string | null,
+): { provider: string; updatedAt: number; model?: string | undefined } | undefined {
+    if (!value) return undefined;
+    try {
+        const parsed = JSON.parse(value) as Partial<AffinityEntry>;
+        if (typeof parsed.provider === 'string' && typeof parsed.updatedAt === 'number') {
+            return {
+                provider: parsed.provider,
+                updatedAt: parsed.updatedAt,
+                ...(typeof parsed.model === 'string' && { model: parsed.model }),
+            };
+        }
+    } catch {
+        // Not an affinity entry
+    }
+    return undefined;
+}
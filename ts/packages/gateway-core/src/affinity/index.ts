@@ -0,0 +1,20 @@
+/**
+ * Thread affinity exports.
+ *
+ * @module affinity
+ */
+
+export {
+    ThreadAffinity,
+    threadKeysOf,
+    responseThreadKey,
+    affinityStateKey,
+    parseAffinityStateKey,
+    parseAffinityState,
+    threadStateIndexKey,
+    THREAD_STATE_TOUCHED,
+    DEFAULT_AFFINITY_TTL_MS,
+    DEFAULT_AFFINITY_MAX_ENTRIES,
+    type ThreadAffinityOptions,
+    type ThreadStateTouch,
+} from './affinity.js';
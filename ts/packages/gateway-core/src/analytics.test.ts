@@ -0,0 +1,255 @@
+import { describe, it, expect, vi } from 'vitest';
+import {
+    SinkEventPublisher,
+    WebhookEventSink,
+    createEventSink,
+    shapeInteractionData,
+    REDACTED,
+    type EventSink,
+} from './analytics/index';
+import { createLifecycleEvent, type InteractionCompletedData, type LifecycleEvent } from './domain/events';
+
+const event = (id: string) => createLifecycleEvent('interaction_completed', id, 'tenant-1');
+
+const tick = () => new Promise((resolve) => setTimeout(resolve, 0));
+
+class RecordingSink implements EventSink {
+    readonly name = 'recording';
+    batches: LifecycleEvent[][] = [];
+    failuresLeft = 0;
+    closed = false;
+
+    async send(events: LifecycleEvent[]): Promise<void> {
+        if (this.failuresLeft > 0) {
+            this.failuresLeft--;
+            throw new Error('sink unavailable');
+        }
+        this.batches.push(events);
+    }
+
+    async close(): Promise<void> {
+        this.closed = true;
+    }
+}
+
+const mockLogger = () => ({
+    debug: vi.fn(),
+    info: vi.fn(),
+    warn: vi.fn(),
+    error: vi.fn(),
+}) as any;
+
+describe('SinkEventPublisher', () => {
+    it('should deliver once a batch fills', async () => {
+        const sink = new RecordingSink();
+        const publisher = new SinkEventPublisher({ sink, batchSize: 2, flushIntervalMs: 60_000 });
+
+        await publisher.publish(event('a'));
+        await tick();
+        expect(sink.batches).toHaveLength(0);
+
+        await publisher.publish(event('b'));
+        await tick();
+        expect(sink.batches).toHaveLength(1);
+        expect(sink.batches[0]!.map((e) => e.interactionId)).toEqual(['a', 'b']);
+        expect(publisher.stats().published).toBe(2);
+    });
+
+    it('should deliver a partial batch after the flush interval', async () => {
+        vi.useFakeTimers();
+        try {
+            const sink = new RecordingSink();
+            const publisher = new SinkEventPublisher({ sink, batchSize: 10, flushIntervalMs: 1000 });
+
+            await publisher.publish(event('a'));
+            await vi.advanceTimersByTimeAsync(999);
+            expect(sink.batches).toHaveLength(0);
+
+            await vi.advanceTimersByTimeAsync(1);
+            expect(sink.batches).toHaveLength(1);
+        } finally {
+            vi.useRealTimers();
+        }
+    });
+
+    it('should drop and count events when the queue is full', async () => {
+        const sink = new RecordingSink();
+        const logger = mockLogger();
+        const publisher = new SinkEventPublisher({ sink, queueSize: 2, batchSize: 10, flushIntervalMs: 60_000, logger });
+
+        for (const id of ['a', 'b', 'c', 'd']) {
+            await publisher.publish(event(id));
+        }
+
+        expect(publisher.stats().queued).toBe(2);
+        expect(publisher.stats().dropped).toBe(2);
+        expect(logger.warn).toHaveBeenCalledTimes(1);
+        expect(logger.warn).toHaveBeenCalledWith('event_queue_full', expect.objectContaining({ queueSize: 2 }));
+
+        await publisher.flush();
+        expect(sink.batches.flat().map((e) => e.interactionId)).toEqual(['a', 'b']);
+    });
+
+    it('should retry a failed batch', async () => {
+        const sink = new RecordingSink();
+        sink.failuresLeft = 2;
+        const logger = mockLogger();
+        const publisher = new SinkEventPublisher({ sink, retries: 3, retryDelayMs: 1, logger });
+
+        await publisher.publish(event('a'));
+        await publisher.flush();
+
+        expect(sink.batches).toHaveLength(1);
+        expect(publisher.stats()).toMatchObject({ published: 1, failures: 2, deadLettered: 0 });
+        expect(logger.warn).toHaveBeenCalledWith('event_delivery_failed', expect.objectContaining({ attempt: 0 }));
+    });
+
+    it('should dead-letter a batch once retries run out', async () => {
+        const sink = new RecordingSink();
+        sink.failuresLeft = 10;
+        const logger = mockLogger();
+        const publisher = new SinkEventPublisher({ sink, retries: 1, retryDelayMs: 1, logger });
+
+        await publisher.publish(event('a'));
+        await publisher.flush();
+
+        expect(sink.batches).toHaveLength(0);
+        expect(publisher.stats()).toMatchObject({ published: 0, failures: 2, deadLettered: 1, queued: 0 });
+        expect(logger.error).toHaveBeenCalledWith('event_dead_letter', expect.objectContaining({
+            sink: 'recording',
+            count: 1,
+            events: [expect.objectContaining({ interactionId: 'a', type: 'interaction_completed' })],
+        }));
+    });
+
+    it('should report lag of the oldest queued event', async () => {
+        const sink = new RecordingSink();
+        let now = Date.now();
+        const publisher = new SinkEventPublisher({ sink, batchSize: 10, flushIntervalMs: 60_000, clock: () => now });
+
+        expect(publisher.stats().lagMs).toBe(0);
+        const queued = event('a');
+        await publisher.publish(queued);
+        now = queued.timestamp.getTime() + 250;
+        expect(publisher.stats().lagMs).toBe(250);
+
+        await publisher.flush();
+        expect(publisher.stats().lagMs).toBe(0);
+        expect(publisher.stats().lastPublishLagMs).toBe(250);
+    });
+
+    it('should flush and close the sink on close', async () => {
+        const sink = new RecordingSink();
+        const publisher = new SinkEventPublisher({ sink, flushIntervalMs: 60_000 });
+
+        await publisher.publish(event('a'));
+        await publisher.close();
+
+        expect(sink.batches).toHaveLength(1);
+        expect(sink.closed).toBe(true);
+    });
+});
+
+describe('WebhookEventSink', () => {
+    it('should POST the batch as JSON', async () => {
+        const fetch = vi.fn(async () => new Response(null, { status: 202 }));
+        const sink = new WebhookEventSink({ url: 'https://hooks.example.com/events', headers: { 'X-Token': 't' }, fetch });
+
+        const sent = event('a');
+        await sink.send([sent]);
+
+        const [url, init] = fetch.mock.calls[0] as any;
+        expect(url).toBe('https://hooks.example.com/events');
+        expect(init.method).toBe('POST');
+        expect(init.headers['X-Token']).toBe('t');
+        const body = JSON.parse(init.body);
+        expect(body.events).toHaveLength(1);
+        expect(body.events[0]).toMatchObject({
+            id: sent.id,
+            type: 'interaction_completed',
+            interactionId: 'a',
+            tenantId: 'tenant-1',
+            timestamp: sent.timestamp.toISOString(),
+        });
+    });
+
+    it('should fail the batch on a non-2xx response', async () => {
+        const fetch = vi.fn(async () => new Response('nope', { status: 503 }));
+        const sink = new WebhookEventSink({ url: 'https://hooks.example.com/events', fetch });
+
+        await expect(sink.send([event('a')])).rejects.toThrow('503');
+    });
+
+    it('should be created from config', () => {
+        expect(createEventSink({ sink: 'webhook', webhook: { url: 'https://hooks.example.com' } }).name).toBe('webhook');
+        expect(() => createEventSink({ sink: 'webhook' })).toThrow('events.webhook.url');
+        expect(() => createEventSink({ sink: 'kafka' })).toThrow('not available');
+    });
+});
+
+describe('shapeInteractionData', () => {
+    const data = (): InteractionCompletedData => ({
+        appName: 'app',
+        frontdoor: 'openai',
+        providerName: 'openai',
+        model: 'gpt-4o',
+        stream: false,
+        statusCode: 200,
+        usage: { promptTokens: 10, completionTokens: 5, totalTokens: 15 },
+        totalDurationMs: 120,
+        request: {
+            model: 'gpt-4o',
+            systemPrompt: 'be terse',
+            messages: [
+                { role: 'user', content: 'secret question' },
+                {
+                    role: 'assistant',
+                    content: '',
+                    toolCalls: [{ id: 'call_1', type: 'function', function: { name: 'lookup', arguments: '{"q":"secret"}' } }],
+                },
+                { role: 'tool', content: 'secret result', toolCallId: 'call_1' },
+            ],
+            rawRequest: new TextEncoder().encode('{}'),
+        } as any,
+        response: {
+            id: 'resp_1',
+            object: 'chat.completion',
+            created: 0,
+            model: 'gpt-4o',
+            choices: [{ index: 0, message: { role: 'assistant', content: 'secret answer' }, finishReason: 'stop' }],
+            usage: { promptTokens: 10, completionTokens: 5, totalTokens: 15 },
+            rawResponse: new TextEncoder().encode('{}'),
+        } as any,
+    });
+
+    it('should drop bodies in summary mode', () => {
+        const shaped = shapeInteractionData(data(), { payload: 'summary' });
+
+        expect(shaped.request).toBeUndefined();
+        expect(shaped.response).toBeUndefined();
+        expect(shaped.usage?.totalTokens).toBe(15);
+        expect(shapeInteractionData(data(), undefined).request).toBeUndefined();
+    });
+
+    it('should keep bodies without raw bytes in full mode', () => {
+        const shaped = shapeInteractionData(data(), { payload: 'full' });
+
+        expect(shaped.request?.messages[0]!.content).toBe('secret question');
+        expect(shaped.request?.rawRequest).toBeUndefined();
+        expect(shaped.response?.choices[0]!.message.content).toBe('secret answer');
+        expect(shaped.response?.rawResponse).toBeUndefined();
+    });
+
+    it('should redact content but keep structure', () => {
+        const shaped = shapeInteractionData(data(), { payload: 'full', redactContent: true });
+        const messages = shaped.request!.messages;
+
+        expect(shaped.request?.systemPrompt).toBe(REDACTED);
+        expect(messages[0]).toMatchObject({ role: 'user', content: REDACTED });
+        expect(messages[1]!.content).toBe('');
+        expect(messages[1]!.toolCalls![0]).toMatchObject({ id: 'call_1', function: { name: 'lookup', arguments: REDACTED } });
+        expect(messages[2]).toMatchObject({ role: 'tool', content: REDACTED, toolCallId: 'call_1' });
+        expect(shaped.response?.choices[0]!.message.content).toBe(REDACTED);
+        expect(JSON.stringify(shaped)).not.toContain('secret');
+    });
+});
@@ -0,0 +1,25 @@
+/**
+ * Analytics event sink exports.
+ *
+ * @module analytics
+ */
+
+export {
+    WebhookEventSink,
+    createEventSink,
+    serializeEvent,
+    type EventSink,
+    type SerializedEvent,
+    type WebhookEventSinkOptions,
+} from './sink.js';
+export {
+    SinkEventPublisher,
+    createSinkEventPublisher,
+    DEFAULT_EVENT_QUEUE_SIZE,
+    DEFAULT_EVENT_BATCH_SIZE,
+    DEFAULT_EVENT_FLUSH_MS,
+    DEFAULT_EVENT_RETRIES,
+    type EventSinkStats,
+    type SinkEventPublisherOptions,
+} from './publisher.js';
+export { shapeInteractionData, REDACTED } from './payload.js';
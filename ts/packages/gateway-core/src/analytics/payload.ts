@@ -0,0 +1,75 @@
+/**
+ * Shapes interaction_completed payloads for external sinks.
+ *
+ * @module analytics/payload
+ */
+
+import type { InteractionCompletedData } from '../domain/events.js';
+import type { CanonicalRequest, CanonicalResponse, Message } from '../domain/types.js';
+import type { EventsConfig } from '../ports/config.js';
+
+/** Replacement for redacted text. */
+export const REDACTED = '[redacted]';
+
+/**
+ * Applies the configured payload mode: "summary" drops the canonical
+ * bodies; "full" keeps them without raw bytes, redacting content when
+ * configured.
+ */
+export function shapeInteractionData(
+    data: InteractionCompletedData,
+    config: Pick<EventsConfig, 'payload' | 'redactContent'> | undefined,
+): InteractionCompletedData {
+    const { request, response, ...summary } = data;
+    if (config?.payload !== 'full') {
+        return summary;
+    }
+
+    return {
+        ...summary,
+        ...(request && { request: shapeRequest(request, config.redactContent ?? false) }),
+        ...(response && { response: shapeResponse(response, config.redactContent ?? false) }),
+    };
+}
+
+function shapeRequest(request: CanonicalRequest, redact: boolean): CanonicalRequest {
+    const { rawRequest: _, ...rest } = request;
+    if (!redact) return rest;
+
+    return {
+        ...rest,
+        messages: rest.messages.map(redactMessage),
+        systemPrompt: redactText(rest.systemPrompt),
+        instructions: redactText(rest.instructions),
+    };
+}
+
+function shapeResponse(response: CanonicalResponse, redact: boolean): CanonicalResponse {
+    const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...rest } = response;
+    if (!redact) return rest;
+
+    return {
+        ...rest,
+        choices: rest.choices.map((c) => ({ ...c, message: redactMessage(c.message) })),
+    };
+}
+
+/**
+ * Replaces a message's text, rich content, and tool arguments; roles,
+ * names, and tool call IDs are kept.
+ */
+function redactMessage(message: Message): Message {
+    return {
+        ...message,
+        content: redactText(message.content) ?? '',
+        richContent: undefined,
+        toolCalls: message.toolCalls?.map((tc) => ({
+            ...tc,
+            function: { ...tc.function, arguments: REDACTED },
+        })),
+    };
+}
+
+function redactText(text: string | undefined): string | undefined {
+    return text ? REDACTED : text;
+}
@@ -0,0 +1,272 @@
+/**
+ * Queued, batched event publishing to an external sink.
+ *
+ * publish() only appends to a bounded in-memory queue, so it never waits on
+ * the sink. A single delivery loop drains the queue in batches, retrying a
+ * failed batch with exponential backoff and dead-lettering it to the error
+ * log once retries run out. When the queue is full, new events are dropped
+ * and counted rather than applying backpressure to requests.
+ *
+ * @module analytics/publisher
+ */
+
+import type { LifecycleEvent } from '../domain/events.js';
+import type { EventPublisher } from '../ports/events.js';
+import type { EventsConfig } from '../ports/config.js';
+import type { Logger } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+import { serializeEvent, type EventSink } from './sink.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default queue bound. */
+export const DEFAULT_EVENT_QUEUE_SIZE = 10_000;
+
+/** Default events per delivery. */
+export const DEFAULT_EVENT_BATCH_SIZE = 100;
+
+/** Default longest wait for a full batch. */
+export const DEFAULT_EVENT_FLUSH_MS = 1000;
+
+/** Default retries per batch. */
+export const DEFAULT_EVENT_RETRIES = 3;
+
+/**
+ * Sink publisher options.
+ */
+export interface SinkEventPublisherOptions {
+    /** Where batches are delivered. */
+    sink: EventSink;
+
+    /** Events held before new ones are dropped. */
+    queueSize?: number | undefined;
+
+    /** Events per delivery. */
+    batchSize?: number | undefined;
+
+    /** Longest an event waits for a full batch (ms). */
+    flushIntervalMs?: number | undefined;
+
+    /** Retries per batch before dead-lettering. */
+    retries?: number | undefined;
+
+    /** Delay before the first retry (ms); doubles on each retry. */
+    retryDelayMs?: number | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests. */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * Delivery counters, as reported by /admin/api/stats.
+ */
+export interface EventSinkStats {
+    /** Sink name. */
+    sink: string;
+
+    /** Events waiting for delivery. */
+    queued: number;
+
+    /** Events delivered. */
+    published: number;
+
+    /** Events dropped because the queue was full. */
+    dropped: number;
+
+    /** Failed delivery attempts (each retry counts). */
+    failures: number;
+
+    /** Events written to the dead-letter log after exhausting retries. */
+    deadLettered: number;
+
+    /** Age of the oldest queued event (ms); 0 when the queue is empty. */
+    lagMs: number;
+
+    /** Age of the oldest event in the last delivered batch (ms). */
+    lastPublishLagMs?: number | undefined;
+}
+
+// ============================================================================
+// Sink Event Publisher
+// ============================================================================
+
+/**
+ * EventPublisher that delivers to an EventSink in the background.
+ */
+export class SinkEventPublisher implements EventPublisher {
+    private readonly sink: EventSink;
+    private readonly queueSize: number;
+    private readonly batchSize: number;
+    private readonly flushIntervalMs: number;
+    private readonly retries: number;
+    private readonly retryDelayMs: number;
+    private readonly logger?: Logger;
+    private readonly clock: () => number;
+
+    private readonly queue: LifecycleEvent[] = [];
+    private flushTimer: ReturnType<typeof setTimeout> | undefined;
+    private delivering: Promise<void> | undefined;
+    private dropWarned = false;
+
+    private published = 0;
+    private dropped = 0;
+    private failures = 0;
+    private deadLettered = 0;
+    private lastPublishLagMs: number | undefined;
+
+    constructor(options: SinkEventPublisherOptions) {
+        this.sink = options.sink;
+        this.queueSize = options.queueSize ?? DEFAULT_EVENT_QUEUE_SIZE;
+        this.batchSize = Math.max(1, options.batchSize ?? DEFAULT_EVENT_BATCH_SIZE);
+        this.flushIntervalMs = options.flushIntervalMs ?? DEFAULT_EVENT_FLUSH_MS;
+        this.retries = options.retries ?? DEFAULT_EVENT_RETRIES;
+        this.retryDelayMs = options.retryDelayMs ?? 500;
+        this.logger = options.logger;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /**
+     * Queues an event. Resolves immediately; delivery happens in the
+     * background.
+     */
+    async publish(event: LifecycleEvent): Promise<void> {
+        if (this.queue.length >= this.queueSize) {
+            this.dropped++;
+            if (!this.dropWarned) {
+                // Warn once per overflow episode, not per event
+                this.dropWarned = true;
+                this.logger?.warn('event_queue_full', { sink: this.sink.name, queueSize: this.queueSize });
+            }
+            return;
+        }
+        this.dropWarned = false;
+        this.queue.push(event);
+
+        if (this.queue.length >= this.batchSize) {
+            this.startDelivery();
+        } else {
+            this.scheduleFlush();
+        }
+    }
+
+    /**
+     * Delivers everything queued and waits for it.
+     */
+    async flush(): Promise<void> {
+        while (this.queue.length > 0 || this.delivering) {
+            this.startDelivery();
+            await this.delivering;
+        }
+    }
+
+    /**
+     * Flushes and closes the sink.
+     */
+    async close(): Promise<void> {
+        await this.flush();
+        await this.sink.close?.();
+    }
+
+    /**
+     * Returns delivery counters.
+     */
+    stats(): EventSinkStats {
+        const oldest = this.queue[0];
+        return {
+            sink: this.sink.name,
+            queued: this.queue.length,
+            published: this.published,
+            dropped: this.dropped,
+            failures: this.failures,
+            deadLettered: this.deadLettered,
+            lagMs: oldest ? Math.max(0, this.clock() - oldest.timestamp.getTime()) : 0,
+            lastPublishLagMs: this.lastPublishLagMs,
+        };
+    }
+
+    // ---- Delivery ----
+
+    private scheduleFlush(): void {
+        if (this.flushTimer || this.delivering) return;
+        this.flushTimer = setTimeout(() => {
+            this.flushTimer = undefined;
+            this.startDelivery();
+        }, this.flushIntervalMs);
+    }
+
+    private startDelivery(): void {
+        if (this.flushTimer) {
+            clearTimeout(this.flushTimer);
+            this.flushTimer = undefined;
+        }
+        if (this.delivering || this.queue.length === 0) return;
+
+        this.delivering = this.drain().finally(() => {
+            this.delivering = undefined;
+            // Events queued after the loop's last check
+            if (this.queue.length > 0) this.scheduleFlush();
+        });
+    }
+
+    private async drain(): Promise<void> {
+        while (this.queue.length > 0) {
+            await this.deliver(this.queue.splice(0, this.batchSize));
+        }
+    }
+
+    private async deliver(batch: LifecycleEvent[]): Promise<void> {
+        for (let attempt = 0; ; attempt++) {
+            try {
+                await this.sink.send(batch);
+                this.published += batch.length;
+                this.lastPublishLagMs = Math.max(0, this.clock() - batch[0]!.timestamp.getTime());
+                return;
+            } catch (error) {
+                this.failures++;
+                const message = error instanceof Error ? error.message : String(error);
+
+                if (attempt >= this.retries) {
+                    this.deadLettered += batch.length;
+                    this.logger?.error('event_dead_letter', {
+                        sink: this.sink.name,
+                        error: message,
+                        count: batch.length,
+                        events: batch.map(serializeEvent),
+                    });
+                    return;
+                }
+
+                this.logger?.warn('event_delivery_failed', { sink: this.sink.name, error: message, attempt });
+                await new Promise((resolve) => setTimeout(resolve, this.retryDelayMs * 2 ** attempt));
+            }
+        }
+    }
+}
+
+// ============================================================================
+// Factory
+// ============================================================================
+
+/**
+ * Creates a publisher for a sink using the queue and batch settings of an
+ * events config.
+ */
+export function createSinkEventPublisher(
+    config: EventsConfig,
+    sink: EventSink,
+    logger?: Logger,
+): SinkEventPublisher {
+    return new SinkEventPublisher({
+        sink,
+        queueSize: config.queueSize,
+        batchSize: config.batchSize,
+        flushIntervalMs: parseDuration(config.flushInterval, DEFAULT_EVENT_FLUSH_MS),
+        retries: config.retries,
+        logger,
+    });
+}
@@ -0,0 +1,143 @@
+/**
+ * Analytics event sinks.
+ *
+ * A sink delivers one batch of lifecycle events to an external system.
+ * Queueing, batching, and retries live in SinkEventPublisher; a sink only
+ * has to send a batch or throw.
+ *
+ * @module analytics/sink
+ */
+
+import type { LifecycleEvent } from '../domain/events.js';
+import type { EventsConfig } from '../ports/config.js';
+import { parseDuration } from '../utils/duration.js';
+
+// ============================================================================
+// Sink Interface
+// ============================================================================
+
+/**
+ * Delivers batches of events to an external system.
+ */
+export interface EventSink {
+    /** Sink name, for logs and stats (e.g., "webhook"). */
+    readonly name: string;
+
+    /**
+     * Sends a batch. Throws if the batch was not accepted; the publisher
+     * retries the whole batch.
+     */
+    send(events: LifecycleEvent[]): Promise<void>;
+
+    /**
+     * Releases connections.
+     */
+    close?(): Promise<void>;
+}
+
+/**
+ * An event as sent to sinks: plain JSON with an ISO timestamp.
+ */
+export interface SerializedEvent {
+    id: string;
+    type: LifecycleEvent['type'];
+    interactionId: string;
+    tenantId: string;
+    timestamp: string;
+    data?: LifecycleEvent['data'];
+}
+
+/**
+ * Converts an event to its wire form.
+ */
+export function serializeEvent(event: LifecycleEvent): SerializedEvent {
+    return {
+        id: event.id,
+        type: event.type,
+        interactionId: event.interactionId,
+        tenantId: event.tenantId,
+        timestamp: event.timestamp.toISOString(),
+        data: event.data,
+    };
+}
+
+// ============================================================================
+// Webhook Sink
+// ============================================================================
+
+/**
+ * Webhook sink options.
+ */
+export interface WebhookEventSinkOptions {
+    /** Endpoint URL. */
+    url: string;
+
+    /** Additional request headers. */
+    headers?: Record<string, string> | undefined;
+
+    /** Request timeout in milliseconds (default 10000). */
+    timeoutMs?: number | undefined;
+
+    /** Fetch implementation (defaults to global fetch). */
+    fetch?: typeof fetch | undefined;
+}
+
+/**
+ * POSTs each batch as `{"events": [...]}`. Any non-2xx response fails the
+ * batch.
+ */
+export class WebhookEventSink implements EventSink {
+    readonly name = 'webhook';
+    private readonly fetchFn: typeof fetch;
+
+    constructor(private readonly options: WebhookEventSinkOptions) {
+        this.fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+    }
+
+    async send(events: LifecycleEvent[]): Promise<void> {
+        const controller = new AbortController();
+        const timeoutId = setTimeout(() => controller.abort(), this.options.timeoutMs ?? 10_000);
+
+        try {
+            const response = await this.fetchFn(this.options.url, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    ...this.options.headers,
+                },
+                body: JSON.stringify({ events: events.map(serializeEvent) }),
+                signal: controller.signal,
+            });
+            if (!response.ok) {
+                throw new Error(`Event webhook returned ${response.status}`);
+            }
+        } finally {
+            clearTimeout(timeoutId);
+        }
+    }
+}
+
+// ============================================================================
+// Factory
+// ============================================================================
+
+/**
+ * Creates the built-in sink for a config. Sinks that need a runtime client
+ * library (Kafka) are created by the runtime adapter instead.
+ */
+export function createEventSink(config: EventsConfig, options?: { fetch?: typeof fetch | undefined }): EventSink {
+    switch (config.sink) {
+        case 'webhook':
+            if (!config.webhook?.url) {
+                throw new Error('events.webhook.url is required for the webhook sink');
+            }
+            return new WebhookEventSink({
+                url: config.webhook.url,
+                headers: config.webhook.headers,
+                timeoutMs: parseDuration(config.webhook.timeout, 10_000),
+                fetch: options?.fetch,
+            });
+        default:
+            throw new Error(`Event sink '${config.sink}' is not available in this runtime`);
+    }
+}
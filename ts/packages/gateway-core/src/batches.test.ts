@@ -0,0 +1,188 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { matchBatchRoute } from './batches/index';
+
+const UPSTREAM_ID = 'msgbatch_upstream1';
+
+const message = {
+    id: 'msg_1',
+    type: 'message',
+    role: 'assistant',
+    model: 'claude-sonnet-4',
+    content: [{ type: 'text', text: 'Hello' }],
+    stop_reason: 'end_turn',
+    stop_sequence: null,
+    usage: { input_tokens: 1_000_000, output_tokens: 0 },
+};
+
+const resultLines = [
+    { custom_id: 'first', result: { type: 'succeeded', message } },
+    { custom_id: 'second', result: { type: 'errored', error: { type: 'invalid_request_error', message: 'bad' } } },
+];
+
+/** Mocked Anthropic Message Batches API: in progress until fetched, then ended. */
+function anthropicBatches() {
+    const calls: { method: string; path: string; apiKey: string; body?: any }[] = [];
+    const batch = (status: string) => ({
+        id: UPSTREAM_ID,
+        type: 'message_batch',
+        processing_status: status,
+        request_counts: { processing: 0, succeeded: 1, errored: 1, canceled: 0, expired: 0 },
+        results_url: status === 'ended' ? `https://api.anthropic.com/v1/messages/batches/${UPSTREAM_ID}/results` : null,
+    });
+    const fetch = vi.fn(async (url: string, init: RequestInit) => {
+        const path = new URL(url).pathname;
+        calls.push({
+            method: init.method ?? 'GET',
+            path,
+            apiKey: (init.headers as Record<string, string>)['x-api-key']!,
+            body: init.body ? JSON.parse(String(init.body)) : undefined,
+        });
+        if (path.endsWith('/results')) {
+            return new Response(resultLines.map((line) => JSON.stringify(line)).join('\n') + '\n', {
+                headers: { 'Content-Type': 'application/binary' },
+            });
+        }
+        if (path.endsWith('/cancel')) {
+            return Response.json(batch('canceling'));
+        }
+        return Response.json(batch(init.method === 'POST' ? 'in_progress' : 'ended'));
+    });
+    return { fetch, calls };
+}
+
+function setup() {
+    const upstream = anthropicBatches();
+    const events = { publish: vi.fn(async () => {}) };
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [{
+                    name: 'claude',
+                    frontdoor: 'anthropic',
+                    path: '/v1/messages',
+                    modelRouting: { rewrites: [{ modelExact: 'fast', provider: 'anthropic', model: 'claude-sonnet-4' }] },
+                }],
+                providers: [
+                    { name: 'anthropic', type: 'anthropic', apiKey: 'sk-ant-provider' },
+                    { name: 'openai', type: 'openai', apiKey: 'sk-openai' },
+                ],
+                routing: {
+                    rules: [{ modelPrefix: 'claude', provider: 'anthropic' }, { modelPrefix: 'gpt', provider: 'openai' }],
+                },
+            }),
+        },
+        auth: {
+            authenticate: async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }),
+            getTenant: async () => null,
+        },
+        events,
+        httpClientFactory: () => ({ fetch: upstream.fetch as any }),
+    });
+    const send = (method: string, path: string, tenant = 'acme', body?: unknown) => gateway.fetch(new Request(`http://gw${path}`, {
+        method,
+        headers: { Authorization: `Bearer ${tenant}`, 'Content-Type': 'application/json' },
+        ...(body !== undefined && { body: JSON.stringify(body) }),
+    }));
+    return { gateway, upstream, events, send };
+}
+
+const params = (model: string) => ({ model, max_tokens: 16, messages: [{ role: 'user', content: 'Hi' }] });
+
+describe('matchBatchRoute', () => {
+    it('should match each batch endpoint', () => {
+        expect(matchBatchRoute('/v1/messages/batches')).toEqual({ action: 'create' });
+        expect(matchBatchRoute('/v1/messages/batches/gwbatch_1')).toEqual({ action: 'get', id: 'gwbatch_1' });
+        expect(matchBatchRoute('/v1/messages/batches/gwbatch_1/results')).toEqual({ action: 'results', id: 'gwbatch_1' });
+        expect(matchBatchRoute('/v1/messages/batches/gwbatch_1/cancel')).toEqual({ action: 'cancel', id: 'gwbatch_1' });
+        expect(matchBatchRoute('/v1/messages')).toBeUndefined();
+    });
+});
+
+describe('Message batch passthrough', () => {
+    it('should submit, poll, fetch results, and cancel a batch', async () => {
+        const { gateway, upstream, events, send } = setup();
+        await gateway.reload();
+
+        const created = await send('POST', '/v1/messages/batches', 'acme', {
+            requests: [
+                { custom_id: 'first', params: params('fast') },
+                { custom_id: 'second', params: params('claude-sonnet-4') },
+            ],
+        });
+        expect(created.status).toBe(200);
+        const batch = await created.json();
+        expect(batch.id).toMatch(/^gwbatch_/);
+        expect(batch.processing_status).toBe('in_progress');
+
+        // Models are rewritten per request; the client's key never goes upstream
+        expect(upstream.calls[0]).toMatchObject({ method: 'POST', path: '/v1/messages/batches', apiKey: 'sk-ant-provider' });
+        expect(upstream.calls[0]!.body.requests.map((r: any) => r.params.model)).toEqual(['claude-sonnet-4', 'claude-sonnet-4']);
+
+        const polled = await (await send('GET', `/v1/messages/batches/${batch.id}`)).json();
+        expect(polled.id).toBe(batch.id);
+        expect(polled.results_url).toBe(`http://gw/v1/messages/batches/${batch.id}/results`);
+        expect(upstream.calls[1]!.path).toBe(`/v1/messages/batches/${UPSTREAM_ID}`);
+
+        const results = await send('GET', `/v1/messages/batches/${batch.id}/results`);
+        const lines = (await results.text()).trim().split('\n').map((line) => JSON.parse(line));
+        expect(lines).toEqual(resultLines);
+
+        // One interaction per result line, flagged as batch and batch-priced
+        const recorded = events.publish.mock.calls
+            .map(([event]: any[]) => event)
+            .filter((event: any) => event.data.batch);
+        expect(recorded.map((event: any) => [event.interactionId, event.data.batch.result])).toEqual([
+            [`${batch.id}/first`, 'succeeded'],
+            [`${batch.id}/second`, 'errored'],
+        ]);
+        expect(recorded[0].data.costUsd).toBe(1.5);
+
+        // Fetching results again doesn't record them twice
+        await (await send('GET', `/v1/messages/batches/${batch.id}/results`)).text();
+        expect(events.publish.mock.calls.filter(([event]: any[]) => event.data.batch)).toHaveLength(2);
+
+        const canceled = await (await send('POST', `/v1/messages/batches/${batch.id}/cancel`)).json();
+        expect(canceled).toMatchObject({ id: batch.id, processing_status: 'canceling' });
+        expect(upstream.calls.at(-1)).toMatchObject({ method: 'POST', path: `/v1/messages/batches/${UPSTREAM_ID}/cancel` });
+    });
+
+    it('should hide batches from other tenants', async () => {
+        const { gateway, send } = setup();
+        await gateway.reload();
+        const batch = await (await send('POST', '/v1/messages/batches', 'acme', {
+            requests: [{ custom_id: 'first', params: params('claude-sonnet-4') }],
+        })).json();
+
+        expect((await send('GET', `/v1/messages/batches/${batch.id}`, 'globex')).status).toBe(404);
+        expect((await send('POST', `/v1/messages/batches/${batch.id}/cancel`, 'globex')).status).toBe(404);
+    });
+
+    it('should return 501 when the batch routes to a non-Anthropic provider', async () => {
+        const { gateway, upstream, send } = setup();
+        await gateway.reload();
+
+        const response = await send('POST', '/v1/messages/batches', 'acme', {
+            requests: [{ custom_id: 'first', params: params('gpt-4o') }],
+        });
+
+        expect(response.status).toBe(501);
+        expect((await response.json()).error.message).toContain("routes to 'openai'");
+        expect(upstream.fetch).not.toHaveBeenCalled();
+    });
+
+    it('should reject a batch that spans providers', async () => {
+        const { gateway, upstream, send } = setup();
+        await gateway.reload();
+
+        const response = await send('POST', '/v1/messages/batches', 'acme', {
+            requests: [
+                { custom_id: 'first', params: params('claude-sonnet-4') },
+                { custom_id: 'second', params: params('gpt-4o') },
+            ],
+        });
+
+        expect(response.status).toBe(400);
+        expect(upstream.fetch).not.toHaveBeenCalled();
+    });
+});
@@ -0,0 +1,396 @@
+/**
+ * Anthropic Message Batches passthrough.
+ *
+ * Batches are submitted through the anthropic frontdoor so they still get
+ * tenant auth, the app's model resolution, and usage recording. Each entry's
+ * model is routed and rewritten like a single request; every entry must land
+ * on the same Anthropic provider. The gateway keeps a record mapping its own
+ * batch ID to the provider's and the owning tenant, and the first time a
+ * batch's results are fetched, records one interaction per result line,
+ * flagged as batch so online metrics can exclude them.
+ *
+ * @module batches/batches
+ */
+
+import type { CanonicalResponse } from '../domain/types.js';
+import { APIError, isAPIError, errInvalidRequest, errNotFound } from '../domain/errors.js';
+import { anthropicCodec } from '../codecs/anthropic.js';
+import { invalidField } from '../codecs/validation.js';
+import type { BatchRecord, BatchStore } from '../ports/storage.js';
+import type { FrontdoorContext, FrontdoorResponse } from '../frontdoors/types.js';
+import { resolveRequestModel } from '../frontdoors/models.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import { randomUUID } from '../utils/crypto.js';
+import type { Logger } from '../utils/logging.js';
+import type { AnthropicBatchClient, BatchCallResult } from './client.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Batch requests are billed at half the list price. */
+export const BATCH_PRICE_FACTOR = 0.5;
+
+/** Prefix of gateway batch IDs. */
+export const BATCH_ID_PREFIX = 'gwbatch_';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** A batch endpoint. */
+export type BatchAction = 'create' | 'get' | 'results' | 'cancel';
+
+/**
+ * A request path matched to a batch endpoint.
+ */
+export interface BatchRoute {
+    /** Endpoint. */
+    action: BatchAction;
+
+    /** Gateway batch ID (all but create). */
+    id?: string | undefined;
+}
+
+/**
+ * One line of a batch's results.
+ */
+export interface MessageBatchResult {
+    /** The client's ID for the request. */
+    customId: string;
+
+    /** Outcome: succeeded, errored, canceled, or expired. */
+    type: string;
+
+    /** The response, when the request succeeded. */
+    response?: CanonicalResponse | undefined;
+}
+
+/**
+ * MessageBatches options.
+ */
+export interface MessageBatchesOptions {
+    /** Batch record storage. */
+    store: BatchStore;
+
+    /** Returns a batch client for a provider, or undefined if it isn't an Anthropic provider. */
+    client: (provider: string) => AnthropicBatchClient | undefined;
+
+    /** Records a result line as an interaction. */
+    onResult?: ((batch: BatchRecord, result: MessageBatchResult) => void) | undefined;
+}
+
+// ============================================================================
+// Routing
+// ============================================================================
+
+const BATCH_PATH = /\/messages\/batches(?:\/([^/]+)(?:\/(results|cancel))?)?\/?$/;
+
+/**
+ * Matches a batch endpoint path.
+ */
+export function matchBatchRoute(path: string): BatchRoute | undefined {
+    const match = BATCH_PATH.exec(path);
+    if (!match) {
+        return undefined;
+    }
+    if (!match[1]) {
+        return { action: 'create' };
+    }
+    const id = decodeURIComponent(match[1]);
+    return { action: (match[2] as BatchAction | undefined) ?? 'get', id };
+}
+
+/**
+ * Returns the model used to route a batch submission: that of its first
+ * request. The frontdoor checks every request routes the same way.
+ */
+export function batchRoutingModel(body: { requests?: unknown }): string | undefined {
+    if (!Array.isArray(body.requests)) {
+        return undefined;
+    }
+    const params = (body.requests[0] as { params?: { model?: unknown } } | undefined)?.params;
+    return typeof params?.model === 'string' ? params.model : undefined;
+}
+
+// ============================================================================
+// Message Batches
+// ============================================================================
+
+const METHODS: Record<BatchAction, string> = {
+    create: 'POST',
+    get: 'GET',
+    results: 'GET',
+    cancel: 'POST',
+};
+
+/**
+ * Handles the batch endpoints of the anthropic frontdoor.
+ */
+export class MessageBatches {
+    private readonly store: BatchStore;
+    private readonly client: MessageBatchesOptions['client'];
+    private readonly onResult: MessageBatchesOptions['onResult'];
+
+    constructor(options: MessageBatchesOptions) {
+        this.store = options.store;
+        this.client = options.client;
+        this.onResult = options.onResult;
+    }
+
+    /**
+     * Handles a batch endpoint request.
+     */
+    async handle(ctx: FrontdoorContext, route: BatchRoute): Promise<FrontdoorResponse> {
+        if (ctx.request.method !== METHODS[route.action]) {
+            return errorResponse(errInvalidRequest('Method not allowed'), 405);
+        }
+
+        try {
+            if (route.action === 'create') {
+                return await this.create(ctx);
+            }
+
+            const record = await this.store.getBatch(route.id!, ctx.auth.tenantId);
+            if (!record) {
+                return errorResponse(errNotFound(`Batch '${route.id}' not found`));
+            }
+            const client = this.client(record.provider);
+            if (!client) {
+                return notImplemented(record.provider);
+            }
+
+            switch (route.action) {
+                case 'get':
+                    return await this.relay(ctx, record, await client.get(record.providerBatchId, record.keyId));
+                case 'cancel':
+                    ctx.logger?.info('message_batch_cancel', { batchId: record.id });
+                    return await this.relay(ctx, record, await client.cancel(record.providerBatchId, record.keyId));
+                default:
+                    return await this.results(ctx, record, await client.results(record.providerBatchId, record.keyId));
+            }
+        } catch (error) {
+            if (isAPIError(error)) {
+                return errorResponse(error);
+            }
+            ctx.logger?.error('message_batch_error', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return errorResponse(new APIError('server', error instanceof Error ? error.message : 'Internal error'));
+        }
+    }
+
+    /**
+     * Routes and rewrites each request's model, submits the batch, and
+     * records it under a new gateway ID.
+     */
+    private async create(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
+        let body: Record<string, unknown>;
+        try {
+            body = JSON.parse(await ctx.request.text()) as Record<string, unknown>;
+        } catch {
+            throw errInvalidRequest('Failed to parse request body');
+        }
+        const requests = body?.requests;
+        if (!Array.isArray(requests) || requests.length === 0) {
+            throw invalidField('requests', 'must be a non-empty array');
+        }
+
+        const provider = ctx.provider.name;
+        const steps: TransformationStep[] = [];
+        for (const [i, entry] of requests.entries()) {
+            const params = (entry as { params?: unknown } | null)?.params;
+            if (typeof (entry as { custom_id?: unknown } | null)?.custom_id !== 'string'
+                || typeof params !== 'object' || params === null) {
+                throw invalidField(`requests[${i}]`, 'needs a custom_id and params');
+            }
+
+            const request = params as { model?: string };
+            const selection = ctx.routeModel?.(request.model || ctx.app?.defaultModel || '');
+            if (selection && selection.providerName !== provider) {
+                throw errInvalidRequest(
+                    `requests[${i}]: model '${request.model}' routes to provider '${selection.providerName}', `
+                    + `not '${provider}'; a batch must go to a single provider`,
+                );
+            }
+            steps.push(...resolveRequestModel(request, ctx.app, selection ? selection.model : ctx.modelRewrite));
+        }
+
+        const client = this.client(provider);
+        if (!client) {
+            return notImplemented(provider);
+        }
+
+        const { response, keyId } = await client.create(body, ctx.upstreamHeaders);
+        if (!response.ok) {
+            return passthroughError(response);
+        }
+        const batch = await response.json() as Record<string, unknown>;
+
+        const record: BatchRecord = {
+            id: `${BATCH_ID_PREFIX}${randomUUID()}`,
+            providerBatchId: String(batch.id),
+            provider,
+            keyId,
+            tenantId: ctx.auth.tenantId,
+            appName: ctx.app?.name,
+            requestCount: requests.length,
+            createdAt: new Date(),
+        };
+        await this.store.saveBatch(record);
+        ctx.logger?.info('message_batch_created', {
+            batchId: record.id,
+            providerBatchId: record.providerBatchId,
+            requests: record.requestCount,
+        });
+
+        return {
+            response: json(this.rewrite(ctx, record, batch)),
+            metadata: { batch_id: record.id },
+            transformations: steps,
+        };
+    }
+
+    /**
+     * Returns an upstream batch object under the gateway's batch ID.
+     */
+    private async relay(ctx: FrontdoorContext, record: BatchRecord, call: BatchCallResult): Promise<FrontdoorResponse> {
+        if (!call.response.ok) {
+            return passthroughError(call.response);
+        }
+        const batch = await call.response.json() as Record<string, unknown>;
+        return {
+            response: json(this.rewrite(ctx, record, batch)),
+            metadata: { batch_id: record.id },
+        };
+    }
+
+    /**
+     * Streams a batch's results to the client. The first fetch records each
+     * result line as an interaction; later fetches only pass them through.
+     */
+    private async results(ctx: FrontdoorContext, record: BatchRecord, call: BatchCallResult): Promise<FrontdoorResponse> {
+        const { response } = call;
+        if (!response.ok || !response.body) {
+            return passthroughError(response);
+        }
+
+        let body: ReadableStream<Uint8Array> = response.body;
+        if (!record.resultsRecordedAt && this.onResult) {
+            // Marked before reading so a concurrent fetch can't record twice
+            await this.store.saveBatch({ ...record, resultsRecordedAt: new Date() });
+            const onResult = this.onResult;
+            body = body.pipeThrough(tapLines((line) => {
+                const result = parseResultLine(line, ctx.logger);
+                if (result) onResult(record, result);
+            }));
+        }
+
+        return {
+            response: new Response(body, {
+                status: 200,
+                headers: { 'Content-Type': response.headers.get('content-type') ?? 'application/x-jsonl' },
+            }),
+            metadata: { batch_id: record.id },
+        };
+    }
+
+    /**
+     * Replaces the provider's batch ID and results URL with the gateway's.
+     */
+    private rewrite(ctx: FrontdoorContext, record: BatchRecord, batch: Record<string, unknown>): Record<string, unknown> {
+        const url = new URL(ctx.request.url);
+        const base = url.pathname.slice(0, url.pathname.indexOf('/batches') + '/batches'.length);
+        return {
+            ...batch,
+            id: record.id,
+            results_url: batch.results_url
+                ? `${url.origin}${base}/${encodeURIComponent(record.id)}/results`
+                : batch.results_url,
+        };
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Parses a results line: {custom_id, result: {type, message?}}.
+ */
+function parseResultLine(line: string, logger: Logger | undefined): MessageBatchResult | undefined {
+    if (!line.trim()) {
+        return undefined;
+    }
+    try {
+        const parsed = JSON.parse(line) as { custom_id?: unknown; result?: { type?: unknown; message?: unknown } };
+        const type = typeof parsed.result?.type === 'string' ? parsed.result.type : 'unknown';
+        return {
+            customId: String(parsed.custom_id ?? ''),
+            type,
+            response: type === 'succeeded' && parsed.result?.message
+                ? anthropicCodec.decodeResponse(JSON.stringify(parsed.result.message))
+                : undefined,
+        };
+    } catch (error) {
+        logger?.warn('message_batch_result_unparsed', {
+            error: error instanceof Error ? error.message : String(error),
+        });
+        return undefined;
+    }
+}
+
+/**
+ * Passes bytes through unchanged, calling onLine for each complete line.
+ */
+function tapLines(onLine: (line: string) => void): TransformStream<Uint8Array, Uint8Array> {
+    const decoder = new TextDecoder();
+    let buffer = '';
+    return new TransformStream({
+        transform(chunk, controller) {
+            controller.enqueue(chunk);
+            buffer += decoder.decode(chunk, { stream: true });
+            const lines = buffer.split('\n');
+            buffer = lines.pop() ?? '';
+            for (const line of lines) onLine(line);
+        },
+        flush() {
+            buffer += decoder.decode();
+            if (buffer) onLine(buffer);
+        },
+    });
+}
+
+function json(body: unknown): Response {
+    return new Response(JSON.stringify(body), {
+        status: 200,
+        headers: { 'Content-Type': 'application/json' },
+    });
+}
+
+async function passthroughError(response: Response): Promise<FrontdoorResponse> {
+    return {
+        response: new Response(await response.text(), {
+            status: response.status,
+            headers: { 'Content-Type': response.headers.get('content-type') ?? 'application/json' },
+        }),
+    };
+}
+
+function notImplemented(provider: string): FrontdoorResponse {
+    return errorResponse(new APIError(
+        'invalid_request',
+        `Message batches are only supported on Anthropic providers; this request routes to '${provider}'`,
+        { statusCode: 501 },
+    ));
+}
+
+function errorResponse(error: APIError, status?: number): FrontdoorResponse {
+    const { body } = anthropicCodec.encodeError(error);
+    return {
+        response: new Response(body, {
+            status: status ?? error.statusCode,
+            headers: { 'Content-Type': 'application/json' },
+        }),
+    };
+}
@@ -0,0 +1,131 @@
+/**
+ * Anthropic Message Batches API client.
+ *
+ * Makes the upstream calls for batch passthrough with the provider's own
+ * credentials; client headers are never forwarded, only the app and
+ * provider header rules.
+ *
+ * @module batches/client
+ */
+
+import type { UpstreamHeaderSet } from '../domain/types.js';
+import type { KeyPool } from '../providers/keys.js';
+import { anthropicVersionHeaders } from '../providers/versions.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+const DEFAULT_BASE_URL = 'https://api.anthropic.com';
+const BATCHES_PATH = '/v1/messages/batches';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Batch client options.
+ */
+export interface AnthropicBatchClientOptions {
+    /** Provider base URL (default: https://api.anthropic.com). */
+    baseUrl?: string | undefined;
+
+    /** Provider key pool. */
+    credentials: KeyPool;
+
+    /** Fetch implementation (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+
+    /** The provider's pinned anthropic-version. */
+    apiVersion?: string | undefined;
+
+    /** The provider's anthropic-beta features. */
+    betaFeatures?: string[] | undefined;
+}
+
+/**
+ * An upstream batch response and the key that made the call.
+ */
+export interface BatchCallResult {
+    /** Upstream response, unread. */
+    response: Response;
+
+    /** ID of the provider key used. */
+    keyId: string;
+}
+
+// ============================================================================
+// Anthropic Batch Client
+// ============================================================================
+
+/**
+ * Calls the Anthropic Message Batches API.
+ */
+export class AnthropicBatchClient {
+    private readonly baseUrl: string;
+    private readonly credentials: KeyPool;
+    private readonly fetchFn: typeof fetch;
+    private readonly versionHeaders: Record<string, string>;
+
+    constructor(options: AnthropicBatchClientOptions) {
+        this.baseUrl = (options.baseUrl ?? DEFAULT_BASE_URL).replace(/\/$/, '');
+        this.credentials = options.credentials;
+        this.fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+        this.versionHeaders = anthropicVersionHeaders(options.apiVersion, options.betaFeatures);
+    }
+
+    /**
+     * Submits a batch (POST /v1/messages/batches).
+     */
+    create(body: unknown, headers?: UpstreamHeaderSet): Promise<BatchCallResult> {
+        return this.call('POST', BATCHES_PATH, undefined, headers, JSON.stringify(body));
+    }
+
+    /**
+     * Gets a batch's status.
+     */
+    get(providerBatchId: string, keyId?: string): Promise<BatchCallResult> {
+        return this.call('GET', `${BATCHES_PATH}/${encodeURIComponent(providerBatchId)}`, keyId);
+    }
+
+    /**
+     * Gets a finished batch's results, as JSON Lines.
+     */
+    results(providerBatchId: string, keyId?: string): Promise<BatchCallResult> {
+        return this.call('GET', `${BATCHES_PATH}/${encodeURIComponent(providerBatchId)}/results`, keyId);
+    }
+
+    /**
+     * Cancels a batch.
+     */
+    cancel(providerBatchId: string, keyId?: string): Promise<BatchCallResult> {
+        return this.call('POST', `${BATCHES_PATH}/${encodeURIComponent(providerBatchId)}/cancel`, keyId);
+    }
+
+    /**
+     * Makes a call with the given key, or the next pooled key when it is
+     * unset or no longer configured.
+     */
+    private async call(
+        method: string,
+        path: string,
+        keyId: string | undefined,
+        upstreamHeaders?: UpstreamHeaderSet,
+        body?: string,
+    ): Promise<BatchCallResult> {
+        const lease = (keyId && this.credentials.lease(keyId)) || this.credentials.acquire();
+        const headers: Record<string, string> = {
+            'x-api-key': lease.key,
+            ...this.versionHeaders,
+            'Content-Type': 'application/json',
+        };
+
+        const response = await this.fetchFn(`${this.baseUrl}${path}`, {
+            method,
+            headers: upstreamHeaders?.apply(headers) ?? headers,
+            body,
+        });
+        this.credentials.report(lease, response.status);
+        return { response, keyId: lease.id };
+    }
+}
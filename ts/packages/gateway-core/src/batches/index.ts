@@ -0,0 +1,25 @@
+/**
+ * Message batch exports.
+ *
+ * @module batches
+ */
+
+export {
+    MessageBatches,
+    matchBatchRoute,
+    batchRoutingModel,
+    BATCH_PRICE_FACTOR,
+    BATCH_ID_PREFIX,
+    type BatchAction,
+    type BatchRoute,
+    type MessageBatchResult,
+    type MessageBatchesOptions,
+} from './batches.js';
+
+export {
+    AnthropicBatchClient,
+    type AnthropicBatchClientOptions,
+    type BatchCallResult,
+} from './client.js';
+
+export { MemoryBatchStore, isBatchStore } from './store.js';
@@ -0,0 +1,49 @@
+/**
+ * In-memory message batch store.
+ *
+ * @module batches/store
+ */
+
+import type { BatchRecord, BatchStore, StorageProvider } from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Batch Store
+// ============================================================================
+
+/**
+ * Process-local batch store.
+ * Used when the configured storage provider does not implement BatchStore.
+ */
+export class MemoryBatchStore implements BatchStore {
+    private readonly batches = new Map<string, BatchRecord>();
+
+    async saveBatch(record: BatchRecord): Promise<void> {
+        this.batches.set(record.id, { ...record });
+    }
+
+    async getBatch(id: string, tenantId: string): Promise<BatchRecord | null> {
+        const record = this.batches.get(id);
+        if (!record || (tenantId !== UNSCOPED_TENANT && record.tenantId !== tenantId)) {
+            return null;
+        }
+        return { ...record };
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements BatchStore.
+ */
+export function isBatchStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & BatchStore {
+    return (
+        storage !== undefined &&
+        typeof storage.saveBatch === 'function' &&
+        typeof storage.getBatch === 'function'
+    );
+}
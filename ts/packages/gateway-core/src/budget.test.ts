@@ -0,0 +1,237 @@
+import { describe, it, expect, vi } from 'vitest';
+import {
+    BudgetAccountant,
+    MemoryUsageStore,
+    BUDGET_WARNING_INTERVAL_MS,
+    describeExceeded,
+    formatRemaining,
+    meterStream,
+} from './budget/index';
+
+const usage = (totalTokens: number) => ({ promptTokens: 0, completionTokens: totalTokens, totalTokens });
+
+const flush = () => new Promise((resolve) => setTimeout(resolve, 0));
+
+function fakeClock(start: string) {
+    let now = Date.parse(start);
+    const clock = () => now;
+    clock.advance = (ms: number) => {
+        now += ms;
+    };
+    clock.set = (iso: string) => {
+        now = Date.parse(iso);
+    };
+    return clock;
+}
+
+describe('BudgetAccountant', () => {
+    it('should report remaining budget and mark it exceeded', async () => {
+        const accountant = new BudgetAccountant({ store: new MemoryUsageStore() });
+        const budget = { monthlyTokens: 100 };
+
+        let status = await accountant.status('t1', budget);
+        expect(status.exceeded).toBe(false);
+        expect(status.action).toBe('block');
+        expect(formatRemaining(status)).toBe('100');
+
+        accountant.record('t1', 'int-1', 'gpt-4o', usage(60), 0.5);
+        accountant.record('t1', 'int-2', 'gpt-4o', usage(40), 0.5);
+
+        status = await accountant.status('t1', budget);
+        expect(status.tokens).toBe(100);
+        expect(status.requests).toBe(2);
+        expect(status.exceeded).toBe(true);
+        expect(describeExceeded(status)).toContain('100 of 100 tokens');
+    });
+
+    it('should evaluate cost limits', async () => {
+        const accountant = new BudgetAccountant({ store: new MemoryUsageStore() });
+        const budget = { monthlyCostUsd: 1, action: 'warn' as const };
+
+        await accountant.status('t1', budget);
+        accountant.record('t1', 'int-1', 'gpt-4o', usage(10), 0.25);
+
+        const status = await accountant.status('t1', budget);
+        expect(status.exceeded).toBe(false);
+        expect(status.action).toBe('warn');
+        expect(formatRemaining(status)).toBe('0.75');
+    });
+
+    it('should rebuild the counter from the store after a restart', async () => {
+        const store = new MemoryUsageStore();
+        const first = new BudgetAccountant({ store });
+        first.record('t1', 'int-1', 'gpt-4o', usage(70), 0);
+        await flush();
+
+        const restarted = new BudgetAccountant({ store });
+        const status = await restarted.status('t1', { monthlyTokens: 100 });
+        expect(status.tokens).toBe(70);
+        expect(status.remaining.tokens).toBe(30);
+    });
+
+    it('should reset at month rollover', async () => {
+        const clock = fakeClock('2026-01-31T23:59:00Z');
+        const accountant = new BudgetAccountant({ store: new MemoryUsageStore(), clock });
+        const budget = { monthlyTokens: 100 };
+
+        await accountant.status('t1', budget);
+        accountant.record('t1', 'int-1', 'gpt-4o', usage(150), 0);
+        await flush();
+        expect((await accountant.status('t1', budget)).exceeded).toBe(true);
+
+        clock.set('2026-02-01T00:00:01Z');
+        const status = await accountant.status('t1', budget);
+        expect(status.tokens).toBe(0);
+        expect(status.exceeded).toBe(false);
+        expect(status.periodStart.toISOString()).toBe('2026-02-01T00:00:00.000Z');
+        expect(status.resetsAt.toISOString()).toBe('2026-03-01T00:00:00.000Z');
+    });
+
+    it('should refresh a stale counter from the store', async () => {
+        const clock = fakeClock('2026-05-10T00:00:00Z');
+        const store = new MemoryUsageStore();
+        const accountant = new BudgetAccountant({ store, clock, refreshIntervalMs: 1000 });
+        const budget = { monthlyTokens: 100 };
+
+        await accountant.status('t1', budget);
+
+        // Usage recorded by another instance
+        await store.recordUsage({
+            tenantId: 't1',
+            interactionId: 'int-other',
+            model: 'gpt-4o',
+            totalTokens: 80,
+            costUsd: 0,
+            createdAt: new Date(clock()),
+        });
+        expect((await accountant.status('t1', budget)).tokens).toBe(0);
+
+        clock.advance(1000);
+        await accountant.status('t1', budget);
+        await flush();
+        expect((await accountant.status('t1', budget)).tokens).toBe(80);
+    });
+
+    it('should keep usage recorded while the counter is being rebuilt', async () => {
+        const clock = fakeClock('2026-05-10T00:00:00Z');
+        const store = new MemoryUsageStore();
+        const writes: Array<() => void> = [];
+        const recordUsage = store.recordUsage.bind(store);
+        store.recordUsage = (record) => new Promise<void>((resolve) => {
+            writes.push(() => void recordUsage(record).then(resolve));
+        });
+        let sum!: () => void;
+        const sumUsage = store.sumUsage.bind(store);
+        store.sumUsage = (...args) => new Promise((resolve) => {
+            sum = () => void sumUsage(...args).then(resolve);
+        });
+        const accountant = new BudgetAccountant({ store, clock, refreshIntervalMs: 1000 });
+        const budget = { monthlyTokens: 100 };
+
+        // Recorded before the first load, still being written when it queries
+        accountant.record('t1', 'int-1', 'gpt-4o', usage(10), 0);
+        const first = accountant.status('t1', budget);
+        // Recorded while the query runs
+        accountant.record('t1', 'int-2', 'gpt-4o', usage(20), 0);
+        sum();
+        expect((await first).tokens).toBe(30);
+
+        writes.splice(0).forEach((write) => write());
+        await flush();
+        clock.advance(1000);
+        await accountant.status('t1', budget);
+        // Recorded during the background refresh
+        accountant.record('t1', 'int-3', 'gpt-4o', usage(40), 0);
+        sum();
+        await flush();
+
+        const status = await accountant.status('t1', budget);
+        expect(status.tokens).toBe(70);
+        expect(status.requests).toBe(3);
+    });
+
+    it('should not count usage twice when its write lands before the rebuild reads', async () => {
+        const clock = fakeClock('2026-05-10T00:00:00Z');
+        const store = new MemoryUsageStore();
+        const writes: Array<() => void> = [];
+        const recordUsage = store.recordUsage.bind(store);
+        store.recordUsage = (record) => new Promise<void>((resolve) => {
+            writes.push(() => void recordUsage(record).then(resolve));
+        });
+        let sum!: () => void;
+        const sumUsage = store.sumUsage.bind(store);
+        store.sumUsage = (...args) => new Promise((resolve) => {
+            sum = () => void sumUsage(...args).then(resolve);
+        });
+        const accountant = new BudgetAccountant({ store, clock });
+
+        accountant.record('t1', 'int-1', 'gpt-4o', usage(10), 0);
+        const status = accountant.status('t1', { monthlyTokens: 100 });
+        accountant.record('t1', 'int-2', 'gpt-4o', usage(20), 0);
+
+        // Both writes complete before sumUsage resolves
+        writes.splice(0).forEach((write) => write());
+        await flush();
+        sum();
+
+        expect((await status).tokens).toBe(30);
+        expect((await status).requests).toBe(2);
+    });
+
+    it('should fail open when the store is unavailable', async () => {
+        const store = {
+            recordUsage: vi.fn().mockResolvedValue(undefined),
+            sumUsage: vi.fn().mockRejectedValue(new Error('db down')),
+        };
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn() } as any;
+        const accountant = new BudgetAccountant({ store, logger });
+
+        const status = await accountant.status('t1', { monthlyTokens: 100 });
+        expect(status.exceeded).toBe(false);
+        expect(logger.error).toHaveBeenCalledWith('budget_refresh_failed', expect.anything());
+    });
+
+    it('should rate limit warnings per tenant', () => {
+        const clock = fakeClock('2026-05-10T00:00:00Z');
+        const accountant = new BudgetAccountant({ store: new MemoryUsageStore(), clock });
+
+        expect(accountant.shouldWarn('t1')).toBe(true);
+        expect(accountant.shouldWarn('t1')).toBe(false);
+        expect(accountant.shouldWarn('t2')).toBe(true);
+
+        clock.advance(BUDGET_WARNING_INTERVAL_MS);
+        expect(accountant.shouldWarn('t1')).toBe(true);
+    });
+});
+
+describe('meterStream', () => {
+    it('should report the last usage once the stream ends', async () => {
+        async function* source() {
+            yield { type: 'content_delta', content: 'hi' } as any;
+            yield { type: 'done', usage: usage(12) } as any;
+        }
+        const onUsage = vi.fn();
+
+        const events = [];
+        for await (const event of meterStream(source(), onUsage)) {
+            events.push(event);
+        }
+
+        expect(events).toHaveLength(2);
+        expect(onUsage).toHaveBeenCalledTimes(1);
+        expect(onUsage).toHaveBeenCalledWith(usage(12));
+    });
+
+    it('should not report when the stream carried no usage', async () => {
+        async function* source() {
+            yield { type: 'content_delta', content: 'hi' } as any;
+        }
+        const onUsage = vi.fn();
+
+        for await (const _ of meterStream(source(), onUsage)) {
+            // drain
+        }
+
+        expect(onUsage).not.toHaveBeenCalled();
+    });
+});
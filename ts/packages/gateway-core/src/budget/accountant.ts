@@ -0,0 +1,312 @@
+/**
+ * Tenant budget accounting.
+ *
+ * Keeps a running usage counter per tenant for the current month so budget
+ * checks don't query storage on every request. A counter is rebuilt from
+ * the usage store the first time a tenant is seen (e.g., after a restart)
+ * and at month rollover, and refreshed in the background once it is older
+ * than the refresh interval, which also picks up usage recorded by other
+ * gateway instances. A rebuild sums the store up to the time it starts,
+ * leaving out records still being written, and adds those and anything
+ * recorded since from memory, so no record is counted twice.
+ *
+ * @module budget/accountant
+ */
+
+import type { BudgetConfig } from '../ports/config.js';
+import type { UsageRecord, UsageStore, UsageTotals } from '../ports/storage.js';
+import type { Usage } from '../domain/types.js';
+import type { Logger } from '../utils/logging.js';
+import { monthStart, nextMonthStart } from './period.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Response header carrying the remaining budget. */
+export const BUDGET_REMAINING_HEADER = 'x-gateway-budget-remaining';
+
+/** Default counter refresh interval. */
+export const DEFAULT_BUDGET_REFRESH_MS = 60_000;
+
+/** Minimum time between budget warnings for one tenant. */
+export const BUDGET_WARNING_INTERVAL_MS = 60 * 60 * 1000;
+
+/**
+ * A tenant's consumption against its budget for the current month.
+ */
+export interface BudgetStatus {
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** Tokens used this month. */
+    tokens: number;
+
+    /** Estimated USD spent this month. */
+    costUsd: number;
+
+    /** Requests this month. */
+    requests: number;
+
+    /** Configured limits. */
+    limits: {
+        monthlyTokens?: number | undefined;
+        monthlyCostUsd?: number | undefined;
+    };
+
+    /** Remaining budget per configured limit; negative once exceeded. */
+    remaining: {
+        tokens?: number | undefined;
+        costUsd?: number | undefined;
+    };
+
+    /** Whether any limit is used up. */
+    exceeded: boolean;
+
+    /** What happens once exceeded. */
+    action: 'block' | 'warn';
+
+    /** Start of the current period. */
+    periodStart: Date;
+
+    /** When the budget resets. */
+    resetsAt: Date;
+}
+
+/**
+ * Budget accountant options.
+ */
+export interface BudgetAccountantOptions {
+    /** Where usage is recorded and summed. */
+    store: UsageStore;
+
+    /** How old a counter may get before it is refreshed from the store. */
+    refreshIntervalMs?: number | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Time source in milliseconds; replaceable in tests. */
+    clock?: (() => number) | undefined;
+}
+
+interface Counter extends UsageTotals {
+    periodStart: number;
+    refreshedAt: number;
+}
+
+// ============================================================================
+// Budget Accountant
+// ============================================================================
+
+/**
+ * Tracks per-tenant monthly usage and evaluates budgets against it.
+ */
+export class BudgetAccountant {
+    private readonly store: UsageStore;
+    private readonly refreshIntervalMs: number;
+    private readonly logger?: Logger | undefined;
+    private readonly clock: () => number;
+    private readonly counters = new Map<string, Counter>();
+    private readonly loading = new Map<string, Promise<Counter>>();
+    /** Records whose write to the store has not finished, by tenant. */
+    private readonly unpersisted = new Map<string, Set<UsageRecord>>();
+    /** Records a load in flight adds to the store's sum, by tenant. */
+    private readonly unsummed = new Map<string, UsageRecord[]>();
+    private readonly warnedAt = new Map<string, number>();
+
+    constructor(options: BudgetAccountantOptions) {
+        this.store = options.store;
+        this.refreshIntervalMs = options.refreshIntervalMs ?? DEFAULT_BUDGET_REFRESH_MS;
+        this.logger = options.logger;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /**
+     * Returns the tenant's consumption against `budget`.
+     */
+    async status(tenantId: string, budget: BudgetConfig): Promise<BudgetStatus> {
+        const counter = await this.counter(tenantId);
+        const remaining: BudgetStatus['remaining'] = {};
+        if (budget.monthlyTokens !== undefined) {
+            remaining.tokens = budget.monthlyTokens - counter.tokens;
+        }
+        if (budget.monthlyCostUsd !== undefined) {
+            remaining.costUsd = budget.monthlyCostUsd - counter.costUsd;
+        }
+
+        return {
+            tenantId,
+            tokens: counter.tokens,
+            costUsd: counter.costUsd,
+            requests: counter.requests,
+            limits: { monthlyTokens: budget.monthlyTokens, monthlyCostUsd: budget.monthlyCostUsd },
+            remaining,
+            exceeded: (remaining.tokens !== undefined && remaining.tokens <= 0)
+                || (remaining.costUsd !== undefined && remaining.costUsd <= 0),
+            action: budget.action ?? 'block',
+            periodStart: new Date(counter.periodStart),
+            resetsAt: new Date(nextMonthStart(counter.periodStart)),
+        };
+    }
+
+    /**
+     * Records a completed request's usage: counted immediately and
+     * persisted to the store in the background.
+     */
+    record(tenantId: string, interactionId: string, model: string, usage: Usage, costUsd: number | undefined): void {
+        const now = this.clock();
+        const record: UsageRecord = {
+            tenantId,
+            interactionId,
+            model,
+            totalTokens: usage.totalTokens,
+            costUsd: costUsd ?? 0,
+            createdAt: new Date(now),
+        };
+
+        const counter = this.counters.get(tenantId);
+        if (counter && counter.periodStart === monthStart(now)) {
+            counter.tokens += record.totalTokens;
+            counter.costUsd += record.costUsd;
+            counter.requests++;
+        }
+        this.unsummed.get(tenantId)?.push(record);
+
+        const unpersisted = this.unpersisted.get(tenantId) ?? new Set();
+        unpersisted.add(record);
+        this.unpersisted.set(tenantId, unpersisted);
+        this.store.recordUsage(record)
+            .catch((error: unknown) => {
+                this.logger?.error('usage_record_failed', {
+                    tenantId,
+                    interactionId,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            })
+            .finally(() => {
+                unpersisted.delete(record);
+                if (unpersisted.size === 0 && this.unpersisted.get(tenantId) === unpersisted) {
+                    this.unpersisted.delete(tenantId);
+                }
+            });
+    }
+
+    /**
+     * Returns true at most once per warning interval per tenant, to rate
+     * limit over-budget warnings.
+     */
+    shouldWarn(tenantId: string): boolean {
+        const now = this.clock();
+        const last = this.warnedAt.get(tenantId);
+        if (last !== undefined && now - last < BUDGET_WARNING_INTERVAL_MS) {
+            return false;
+        }
+        this.warnedAt.set(tenantId, now);
+        return true;
+    }
+
+    /**
+     * Returns the tenant's counter for the current month, rebuilding it on
+     * first use and at month rollover.
+     */
+    private async counter(tenantId: string): Promise<Counter> {
+        const now = this.clock();
+        const period = monthStart(now);
+        const counter = this.counters.get(tenantId);
+
+        if (!counter || counter.periodStart !== period) {
+            try {
+                return await this.load(tenantId, period);
+            } catch (error) {
+                // Fail open: an unreachable store must not block every request
+                this.logger?.error('budget_refresh_failed', {
+                    tenantId,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+                return { tokens: 0, costUsd: 0, requests: 0, periodStart: period, refreshedAt: now };
+            }
+        }
+
+        if (now - counter.refreshedAt >= this.refreshIntervalMs) {
+            // Keep enforcing with the running counter while refreshing
+            counter.refreshedAt = now;
+            this.load(tenantId, period).catch((error: unknown) => {
+                this.logger?.warn('budget_refresh_failed', {
+                    tenantId,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            });
+        }
+        return counter;
+    }
+
+    /**
+     * Sums the tenant's usage for the period from the store and replaces
+     * its counter. Records not yet persisted when the query starts and
+     * those recorded while it runs may or may not reach the store before
+     * it reads, so the query leaves them out, by interaction ID and by
+     * time, and they are added from memory instead. Concurrent loads for
+     * one tenant share a query.
+     */
+    private load(tenantId: string, period: number): Promise<Counter> {
+        let pending = this.loading.get(tenantId);
+        if (!pending) {
+            const startedAt = this.clock();
+            const unsummed = [...(this.unpersisted.get(tenantId) ?? [])];
+            this.unsummed.set(tenantId, unsummed);
+            const sum = this.store.sumUsage(tenantId, new Date(period), {
+                before: new Date(startedAt),
+                excluding: unsummed.map((record) => record.interactionId),
+            });
+            pending = sum
+                .then((totals) => {
+                    const counter: Counter = { ...totals, periodStart: period, refreshedAt: startedAt };
+                    for (const record of unsummed) {
+                        if (monthStart(record.createdAt.getTime()) === period) {
+                            counter.tokens += record.totalTokens;
+                            counter.costUsd += record.costUsd;
+                            counter.requests++;
+                        }
+                    }
+                    this.counters.set(tenantId, counter);
+                    return counter;
+                })
+                .finally(() => {
+                    this.loading.delete(tenantId);
+                    this.unsummed.delete(tenantId);
+                });
+            this.loading.set(tenantId, pending);
+        }
+        return pending;
+    }
+}
+
+/**
+ * Formats the remaining budget for the response header: remaining tokens
+ * when a token limit is set, otherwise remaining USD.
+ */
+export function formatRemaining(status: BudgetStatus): string | undefined {
+    if (status.remaining.tokens !== undefined) {
+        return String(status.remaining.tokens);
+    }
+    if (status.remaining.costUsd !== undefined) {
+        return status.remaining.costUsd.toFixed(2);
+    }
+    return undefined;
+}
+
+/**
+ * Describes an exceeded budget for the 429 error body.
+ */
+export function describeExceeded(status: BudgetStatus): string {
+    const used: string[] = [];
+    if (status.remaining.tokens !== undefined && status.remaining.tokens <= 0) {
+        used.push(`${status.tokens} of ${status.limits.monthlyTokens} tokens`);
+    }
+    if (status.remaining.costUsd !== undefined && status.remaining.costUsd <= 0) {
+        used.push(`$${status.costUsd.toFixed(2)} of $${status.limits.monthlyCostUsd!.toFixed(2)}`);
+    }
+    return `Monthly budget exceeded for tenant '${status.tenantId}': used ${used.join(' and ')}. `
+        + `The budget resets at ${status.resetsAt.toISOString()}.`;
+}
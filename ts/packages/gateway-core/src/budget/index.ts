@@ -0,0 +1,19 @@
+/**
+ * Budget module exports.
+ *
+ * @module budget
+ */
+
+export {
+    BudgetAccountant,
+    formatRemaining,
+    describeExceeded,
+    BUDGET_REMAINING_HEADER,
+    DEFAULT_BUDGET_REFRESH_MS,
+    BUDGET_WARNING_INTERVAL_MS,
+    type BudgetStatus,
+    type BudgetAccountantOptions,
+} from './accountant.js';
+export { MemoryUsageStore, isUsageStore } from './store.js';
+export { meterStream } from './meter.js';
+export { monthStart, nextMonthStart } from './period.js';
@@ -0,0 +1,30 @@
+/**
+ * Usage metering for streamed responses.
+ *
+ * @module budget/meter
+ */
+
+import type { CanonicalEvent, Usage } from '../domain/types.js';
+
+/**
+ * Passes a stream through and reports the last usage it carried once the
+ * stream ends, including when the consumer stops early.
+ */
+export async function* meterStream(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    onUsage: (usage: Usage) => void,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    let usage: Usage | undefined;
+    try {
+        for await (const event of source) {
+            if (event.usage) {
+                usage = event.usage;
+            }
+            yield event;
+        }
+    } finally {
+        if (usage) {
+            onUsage(usage);
+        }
+    }
+}
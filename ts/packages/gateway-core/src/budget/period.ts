@@ -0,0 +1,21 @@
+/**
+ * Budget periods: calendar months in UTC.
+ *
+ * @module budget/period
+ */
+
+/**
+ * Start of the UTC month containing `ms`, in milliseconds.
+ */
+export function monthStart(ms: number): number {
+    const date = new Date(ms);
+    return Date.UTC(date.getUTCFullYear(), date.getUTCMonth(), 1);
+}
+
+/**
+ * Start of the UTC month after the one containing `ms`, in milliseconds.
+ */
+export function nextMonthStart(ms: number): number {
+    const date = new Date(ms);
+    return Date.UTC(date.getUTCFullYear(), date.getUTCMonth() + 1, 1);
+}
@@ -0,0 +1,76 @@
+/**
+ * In-memory usage store.
+ *
+ * @module budget/store
+ */
+
+import type { StorageProvider, UsageRecord, UsageStore, UsageSumOptions, UsageTotals } from '../ports/storage.js';
+import { monthStart } from './period.js';
+
+// ============================================================================
+// Memory Usage Store
+// ============================================================================
+
+/**
+ * Process-local usage store.
+ * Used when the configured storage provider does not implement UsageStore;
+ * budgets then start from zero after a restart.
+ */
+export class MemoryUsageStore implements UsageStore {
+    private records: UsageRecord[] = [];
+    private recorded = new Set<string>();
+
+    async recordUsage(record: UsageRecord): Promise<void> {
+        if (this.recorded.has(record.interactionId)) {
+            return;
+        }
+        this.records.push(record);
+        this.recorded.add(record.interactionId);
+        this.prune(record.createdAt.getTime());
+    }
+
+    async sumUsage(tenantId: string, since: Date, options: UsageSumOptions = {}): Promise<UsageTotals> {
+        const totals: UsageTotals = { tokens: 0, costUsd: 0, requests: 0 };
+        for (const record of this.records) {
+            if (
+                record.tenantId === tenantId
+                && record.createdAt >= since
+                && (!options.before || record.createdAt < options.before)
+                && !options.excluding?.includes(record.interactionId)
+            ) {
+                totals.tokens += record.totalTokens;
+                totals.costUsd += record.costUsd;
+                totals.requests++;
+            }
+        }
+        return totals;
+    }
+
+    /**
+     * Drops records from before the previous month, which no budget reads.
+     */
+    private prune(now: number): void {
+        const cutoff = monthStart(monthStart(now) - 1);
+        if (this.records.length > 0 && this.records[0]!.createdAt.getTime() < cutoff) {
+            this.records = this.records.filter((r) => r.createdAt.getTime() >= cutoff);
+            this.recorded = new Set(this.records.map((r) => r.interactionId));
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements UsageStore.
+ */
+export function isUsageStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & UsageStore {
+    return (
+        storage !== undefined &&
+        typeof storage.recordUsage === 'function' &&
+        typeof storage.sumUsage === 'function'
+    );
+}
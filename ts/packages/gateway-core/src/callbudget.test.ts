@@ -0,0 +1,148 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { RequestBudget, resolveCallBudget, withCallBudget, DEFAULT_MAX_CALLS } from './callbudget/index';
+import { runToolLoop, calculatorTool } from './tools/index';
+import { harness, ScriptedProvider, type TestGateway, type Reply } from './__tests__/harness/index';
+
+const request = { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] } as any;
+
+/** A model that keeps calling the calculator with arguments it can't parse. */
+const failingToolCall: Reply = { toolCalls: [{ id: 'c1', name: 'calculator', arguments: '{"expression": "6 *"}' }] };
+
+describe('RequestBudget', () => {
+    it('should resolve app limits over the defaults, field by field', () => {
+        expect(resolveCallBudget(undefined, undefined)).toEqual({ maxCalls: DEFAULT_MAX_CALLS, maxTokens: undefined, maxTimeMs: undefined });
+        expect(resolveCallBudget({ maxCalls: 4, maxTokens: 1000 }, { maxCalls: 2, maxTime: '30s' }))
+            .toEqual({ maxCalls: 2, maxTokens: 1000, maxTimeMs: 30_000 });
+    });
+
+    it('should refuse automatic calls over the budget but always admit the client\'s own', () => {
+        const onExhausted = vi.fn();
+        const budget = new RequestBudget({ maxCalls: 2 }, onExhausted);
+
+        budget.admit('primary');
+        budget.admit('retry');
+        expect(() => budget.admit('tool_loop_iteration')).toThrow(expect.objectContaining({ code: 'budget_exceeded', statusCode: 502 }));
+        expect(() => budget.admit('retry')).toThrow();
+        budget.admit(undefined);
+
+        expect(budget.spent().calls).toBe(3);
+        expect(budget.exhausted).toBe('max_calls');
+        expect(onExhausted).toHaveBeenCalledTimes(1);
+        expect(onExhausted).toHaveBeenCalledWith('max_calls');
+    });
+
+    it('should stop automatic calls once the tokens or time are spent', () => {
+        const tokens = new RequestBudget({ maxCalls: 10, maxTokens: 20 });
+        tokens.admit('primary');
+        tokens.spend({ promptTokens: 15, completionTokens: 5, totalTokens: 20 });
+        expect(tokens.allows()).toBe(false);
+        expect(tokens.exhausted).toBe('max_tokens');
+
+        const time = new RequestBudget({ maxCalls: 10, maxTimeMs: 1000 }, undefined, Date.now() - 1000);
+        expect(time.check()).toBe('max_time');
+    });
+
+    it('should count streamed usage however the stream ends', async () => {
+        const budget = new RequestBudget({ maxCalls: 10 });
+        const provider = withCallBudget(new ScriptedProvider('mock', { text: 'Hi' }), budget);
+
+        for await (const event of provider.stream(request)) {
+            if (event.type === 'content_block_delta') break;
+        }
+        expect(budget.spent()).toEqual({ calls: 1, tokens: 0 });
+        for await (const _ of provider.stream(request)) { /* drain */ }
+        expect(budget.spent()).toEqual({ calls: 2, tokens: 15 });
+    });
+});
+
+describe('Tool loop budget', () => {
+    it('should return the last answer without its gateway calls when the budget is spent', async () => {
+        const budget = new RequestBudget({ maxCalls: 2 });
+        const mock = new ScriptedProvider('mock', { ...failingToolCall, text: 'Let me work that out.' });
+
+        const response = await runToolLoop(withCallBudget(mock, budget), request, {
+            tools: [calculatorTool],
+            maxIterations: 10,
+            interactionId: 'i',
+            tenantId: 't',
+            budget,
+        });
+
+        expect(mock.requests).toHaveLength(2);
+        expect(response.choices[0]!.message).toMatchObject({ content: 'Let me work that out.', toolCalls: undefined });
+        expect(response.choices[0]!.finishReason).toBe('stop');
+        expect(response.usage?.totalTokens).toBe(30);
+    });
+
+    it('should fail with budget_exceeded when the last answer has nothing to show', async () => {
+        const budget = new RequestBudget({ maxCalls: 3 });
+        const mock = new ScriptedProvider('mock', failingToolCall);
+
+        await expect(runToolLoop(withCallBudget(mock, budget), request, {
+            tools: [calculatorTool],
+            maxIterations: 10,
+            interactionId: 'i',
+            tenantId: 't',
+            budget,
+        })).rejects.toMatchObject({ code: 'budget_exceeded' });
+        expect(mock.requests).toHaveLength(3);
+    });
+});
+
+describe('Call budget', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(script: Reply[] | Reply, maxCalls: number) {
+        const mock = new ScriptedProvider('mock', script);
+        gateway = await harness()
+            .app({
+                name: 'agents',
+                frontdoor: 'openai',
+                path: '/v1',
+                gatewayTools: { tools: ['calculator'], maxIterations: 20 },
+                validateJsonOutput: { onInvalid: 'repair' },
+                callBudget: { maxCalls },
+            })
+            .provider(mock)
+            .config({ callBudget: { maxCalls: 50 } })
+            .start();
+        const response = await gateway.post('/v1/chat/completions', {
+            model: 'gpt-4o',
+            response_format: { type: 'json_object' },
+            messages: [{ role: 'user', content: 'What is 6 * 7? Answer in JSON.' }],
+        });
+        return { mock, response, json: await response.json() as any };
+    }
+
+    it('should hold a failing tool loop to the app\'s ceiling of provider calls', async () => {
+        const { mock, response, json } = await setup(failingToolCall, 4);
+
+        expect(mock.requests).toHaveLength(4);
+        expect(response.status).toBe(502);
+        expect(json.error).toMatchObject({ code: 'budget_exceeded' });
+        expect(gateway!.gateway.callBudgetStats()).toEqual([{ app: 'agents', exhausted: { max_calls: 1 } }]);
+    });
+
+    it('should count JSON repair retries against the same budget as the tool loop', async () => {
+        const { mock, response, json } = await setup([failingToolCall, failingToolCall, { text: 'forty-two' }], 3);
+
+        // Two tool rounds and the invalid answer; the repair is refused
+        expect(mock.requests).toHaveLength(3);
+        expect(response.status).toBe(502);
+        expect(json.error).toMatchObject({ code: 'budget_exceeded' });
+    });
+
+    it('should leave requests within the budget alone', async () => {
+        const { mock, response, json } = await setup([failingToolCall, { text: 'forty-two' }, { text: '{"answer": 42}' }], 3);
+
+        expect(mock.requests).toHaveLength(3);
+        expect(response.status).toBe(200);
+        expect(JSON.parse(json.choices[0].message.content)).toEqual({ answer: 42 });
+        expect(gateway!.gateway.callBudgetStats()).toEqual([]);
+    });
+});
@@ -0,0 +1,213 @@
+/**
+ * Per-request outbound call budgets.
+ *
+ * Tool loops, JSON repairs, retries, failover, and hedging each call a
+ * provider again on their own, and together they can turn one client
+ * request into many upstream calls. A RequestBudget caps a request's
+ * provider calls, upstream tokens, and the time after which the gateway
+ * stops starting calls of its own. Every call counts against it; once it
+ * is spent, automatic calls are refused, and the features making them
+ * fall back to the best result they already have.
+ *
+ * @module callbudget/budget
+ */
+
+import type { Usage } from '../domain/types.js';
+import type { AttemptReason } from '../ports/provider.js';
+import type { CallBudgetConfig } from '../ports/config.js';
+import { APIError } from '../domain/errors.js';
+import { parseDuration } from '../utils/duration.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default provider calls per client request. */
+export const DEFAULT_MAX_CALLS = 10;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** A budget limit, as named in config and interaction metadata. */
+export type CallBudgetLimit = 'max_calls' | 'max_tokens' | 'max_time';
+
+/**
+ * A request's resolved limits. Unset limits are unlimited.
+ */
+export interface CallBudgetLimits {
+    maxCalls: number;
+    maxTokens?: number | undefined;
+    maxTimeMs?: number | undefined;
+}
+
+/**
+ * Requests that exhausted their call budget, per app.
+ */
+export interface CallBudgetStats {
+    /** App name ('' for requests outside any app). */
+    app: string;
+
+    /** Requests whose budget refused an automatic call, by the limit reached. */
+    exhausted: Partial<Record<CallBudgetLimit, number>>;
+}
+
+// ============================================================================
+// Limits
+// ============================================================================
+
+/**
+ * Resolves a request's limits: the app's settings over the gateway's,
+ * field by field.
+ */
+export function resolveCallBudget(
+    defaults: CallBudgetConfig | undefined,
+    app: CallBudgetConfig | undefined,
+): CallBudgetLimits {
+    const maxTime = app?.maxTime ?? defaults?.maxTime;
+    return {
+        maxCalls: app?.maxCalls ?? defaults?.maxCalls ?? DEFAULT_MAX_CALLS,
+        maxTokens: app?.maxTokens ?? defaults?.maxTokens,
+        maxTimeMs: maxTime === undefined ? undefined : parseDuration(maxTime, 0),
+    };
+}
+
+/**
+ * Whether a call is one the gateway makes on its own, rather than the
+ * call the client's request asked for.
+ */
+export function isAutomaticCall(attempt: AttemptReason | undefined): boolean {
+    return attempt !== undefined && attempt !== 'primary';
+}
+
+// ============================================================================
+// Request Budget
+// ============================================================================
+
+/**
+ * One request's outbound budget.
+ */
+export class RequestBudget {
+    private readonly limits: CallBudgetLimits;
+    private readonly startedAt: number;
+    private readonly onExhausted: ((limit: CallBudgetLimit) => void) | undefined;
+    private calls = 0;
+    private tokens = 0;
+    private exhaustedBy: CallBudgetLimit | undefined;
+
+    constructor(
+        limits: CallBudgetLimits,
+        onExhausted?: (limit: CallBudgetLimit) => void,
+        startedAt = Date.now(),
+    ) {
+        this.limits = limits;
+        this.onExhausted = onExhausted;
+        this.startedAt = startedAt;
+    }
+
+    /** The limit that first refused a call, if one has. */
+    get exhausted(): CallBudgetLimit | undefined {
+        return this.exhaustedBy;
+    }
+
+    /**
+     * The limit a further automatic call would exceed, or undefined when
+     * one may be made.
+     */
+    check(): CallBudgetLimit | undefined {
+        const { maxCalls, maxTokens, maxTimeMs } = this.limits;
+        if (this.calls >= maxCalls) return 'max_calls';
+        if (maxTokens !== undefined && this.tokens >= maxTokens) return 'max_tokens';
+        if (maxTimeMs !== undefined && Date.now() - this.startedAt >= maxTimeMs) return 'max_time';
+        return undefined;
+    }
+
+    /**
+     * Whether an automatic call may be made. A refusal marks the budget
+     * exhausted.
+     */
+    allows(): boolean {
+        const limit = this.check();
+        if (limit) {
+            this.exhaust(limit);
+        }
+        return limit === undefined;
+    }
+
+    /**
+     * Counts a call about to be made. Automatic calls over the budget are
+     * refused with a budget_exceeded error; the client's own call always
+     * goes out.
+     */
+    admit(attempt: AttemptReason | undefined): void {
+        if (isAutomaticCall(attempt) && !this.allows()) {
+            throw errCallBudgetExceeded(this.exhaustedBy!);
+        }
+        this.calls++;
+    }
+
+    /**
+     * Counts a call's upstream tokens.
+     */
+    spend(usage: Usage | undefined): void {
+        this.tokens += usage?.totalTokens ?? 0;
+    }
+
+    /** Calls made and tokens spent so far. */
+    spent(): { calls: number; tokens: number } {
+        return { calls: this.calls, tokens: this.tokens };
+    }
+
+    private exhaust(limit: CallBudgetLimit): void {
+        if (this.exhaustedBy === undefined) {
+            this.exhaustedBy = limit;
+            this.onExhausted?.(limit);
+        }
+    }
+}
+
+/**
+ * Creates the error for an automatic call refused by the request's call
+ * budget, when there is no usable result to return instead (502).
+ */
+export function errCallBudgetExceeded(limit: CallBudgetLimit): APIError {
+    return new APIError('server', `Request call budget exhausted (${limit}); no further provider calls were made`, {
+        code: 'budget_exceeded',
+        statusCode: 502,
+    });
+}
+
+/**
+ * Whether an error is a call budget refusal.
+ */
+export function isCallBudgetExceeded(error: unknown): boolean {
+    return error instanceof APIError && error.code === 'budget_exceeded' && error.type === 'server';
+}
+
+// ============================================================================
+// Exhaustion Counters
+// ============================================================================
+
+/**
+ * Counts requests that exhausted their call budget.
+ */
+export class CallBudgetExhaustions {
+    private readonly apps = new Map<string, Partial<Record<CallBudgetLimit, number>>>();
+
+    /**
+     * Records one exhausted request.
+     */
+    record(app: string, limit: CallBudgetLimit): void {
+        const counts = this.apps.get(app) ?? {};
+        counts[limit] = (counts[limit] ?? 0) + 1;
+        this.apps.set(app, counts);
+    }
+
+    /**
+     * Returns the counters, sorted by app name.
+     */
+    stats(): CallBudgetStats[] {
+        return Array.from(this.apps, ([app, exhausted]) => ({ app, exhausted: { ...exhausted } }))
+            .sort((a, b) => a.app.localeCompare(b.app));
+    }
+}
@@ -0,0 +1,23 @@
+/**
+ * Request call budget exports.
+ *
+ * @module callbudget
+ */
+
+export {
+    RequestBudget,
+    CallBudgetExhaustions,
+    DEFAULT_MAX_CALLS,
+    resolveCallBudget,
+    isAutomaticCall,
+    errCallBudgetExceeded,
+    isCallBudgetExceeded,
+    type CallBudgetLimit,
+    type CallBudgetLimits,
+    type CallBudgetStats,
+} from './budget.js';
+
+export {
+    BudgetedProvider,
+    withCallBudget,
+} from './provider.js';
@@ -0,0 +1,70 @@
+/**
+ * Call budget enforcement.
+ *
+ * Every provider call a request makes passes through this wrapper, so
+ * the budget holds whichever feature makes the call: automatic calls
+ * over it are refused before they reach the upstream, and each call's
+ * tokens are counted once it reports usage.
+ *
+ * @module callbudget/provider
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Usage,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { RequestBudget } from './budget.js';
+
+// ============================================================================
+// Budgeted Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so its calls count against a request's budget.
+ */
+export class BudgetedProvider extends ProviderWrapper {
+    private readonly budget: RequestBudget;
+
+    constructor(inner: Provider, budget: RequestBudget) {
+        super(inner);
+        this.budget = budget;
+    }
+
+    /**
+     * Completes a request, if the budget admits the call.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        this.budget.admit(options?.attempt);
+        const response = await this.inner.complete(request, options);
+        this.budget.spend(response.usage);
+        return response;
+    }
+
+    /**
+     * Streams a request, if the budget admits the call. Tokens are counted
+     * however the stream ends.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        this.budget.admit(options?.attempt);
+        let usage: Usage | undefined;
+        try {
+            for await (const event of this.inner.stream(request, options)) {
+                usage = event.usage ?? usage;
+                yield event;
+            }
+        } finally {
+            this.budget.spend(usage);
+        }
+    }
+}
+
+/**
+ * Counts a provider's calls against a request's budget.
+ */
+export function withCallBudget(provider: Provider, budget: RequestBudget): Provider {
+    return new BudgetedProvider(provider, budget);
+}
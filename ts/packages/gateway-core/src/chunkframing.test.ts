@@ -0,0 +1,147 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { readFileSync } from 'node:fs';
+import { fileURLToPath } from 'node:url';
+import type { CanonicalEvent } from './domain/types';
+import { frameChatChunks } from './frontdoors/chunks';
+import { harness, parseSSE, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+/** Streams recorded from the OpenAI API, in __tests__/recordings. */
+function recording(name: string): string {
+    return readFileSync(fileURLToPath(new URL(`./__tests__/recordings/openai-stream-${name}.sse`, import.meta.url)), 'utf8');
+}
+
+/**
+ * A stream's framing: per chunk, the choice's delta (null fields dropped)
+ * and finish_reason, or the usage chunk; then [DONE].
+ */
+function framing(body: string): unknown[] {
+    return parseSSE(body).map((frame) => {
+        if (frame.data === '[DONE]') return '[DONE]';
+        const chunk = JSON.parse(frame.data);
+        if (chunk.choices.length === 0) return { usage: Object.keys(chunk.usage ?? {}).length > 0 };
+        const [choice] = chunk.choices;
+        const delta = Object.fromEntries(Object.entries(choice.delta).filter(([, v]) => v !== null));
+        return { index: choice.index, delta, finish_reason: choice.finish_reason };
+    });
+}
+
+const tool = { index: 0, id: 'call_Xk2pQ7fVb9mLr4TnWcYd8sEa', type: 'function' as const, function: { name: 'get_weather', arguments: '' } };
+const ARGS = ['{"', 'location', '":"', 'Paris', ', France', '"}'];
+const usage = { promptTokens: 14, completionTokens: 8, totalTokens: 22 };
+
+/** Events as the Anthropic codec decodes a stream. */
+function anthropic(text: string[], withTool: boolean): CanonicalEvent[] {
+    const events: CanonicalEvent[] = [
+        { type: 'message_start', role: 'assistant', model: 'claude-sonnet-4', usage: { promptTokens: 14, completionTokens: 0, totalTokens: 14 } },
+    ];
+    if (text.length > 0) {
+        events.push({ type: 'content_block_start', index: 0, contentBlock: { type: 'text', text: '' } });
+        events.push(...text.map((t): CanonicalEvent => ({ type: 'content_delta', contentDelta: t, index: 0 })));
+        events.push({ type: 'content_block_stop', index: 0 });
+    }
+    if (withTool) {
+        events.push({ type: 'content_block_start', index: 1, toolCall: tool });
+        events.push(...ARGS.map((a): CanonicalEvent => ({ type: 'content_block_delta', index: 1, toolCall: { index: 0, function: { arguments: a } } })));
+        events.push({ type: 'content_block_stop', index: 1 });
+    }
+    events.push(
+        { type: 'message_delta', finishReason: withTool ? 'tool_calls' : 'stop', usage },
+        { type: 'message_stop' },
+        { type: 'done' },
+    );
+    return events;
+}
+
+describe('Chat completion chunk framing', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function stream(events: CanonicalEvent[], options: Record<string, unknown> = {}): Promise<string> {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('claude', { events }, 'anthropic'))
+            .start();
+        const response = await gateway.post('/v1/chat/completions', {
+            model: 'gpt-4o',
+            stream: true,
+            messages: [{ role: 'user', content: 'Weather in Paris?' }],
+            ...options,
+        });
+        return response.text();
+    }
+
+    it('should frame a text-only Anthropic stream as OpenAI does', async () => {
+        const body = await stream(anthropic(['The', ' capital', ' of France is Paris.'], false), { stream_options: { include_usage: true } });
+
+        expect(framing(body)).toEqual(framing(recording('text')));
+    });
+
+    it('should frame a tool call Anthropic stream as OpenAI does', async () => {
+        const body = await stream(anthropic([], true));
+
+        expect(framing(body)).toEqual(framing(recording('tool-call')));
+    });
+
+    it('should frame text followed by a tool call as OpenAI does', async () => {
+        const body = await stream(anthropic(['Let me check', ' the weather.'], true));
+
+        expect(framing(body)).toEqual(framing(recording('mixed')));
+    });
+
+    it('should reframe upstreams that repeat the role or finish early', async () => {
+        const events: CanonicalEvent[] = [
+            { type: 'content_delta', role: 'assistant', contentDelta: 'Let me check' },
+            { type: 'content_delta', role: 'assistant', contentDelta: ' the weather.' },
+            { type: 'content_delta', role: 'assistant', toolCall: tool },
+            ...ARGS.slice(0, 3).map((a): CanonicalEvent => ({ type: 'content_delta', toolCall: { index: 0, function: { arguments: a } } })),
+            // The finish reason arrives before the last argument deltas
+            { type: 'content_delta', toolCall: { index: 0, function: { arguments: ARGS[3]! } }, finishReason: 'tool_calls', usage },
+            ...ARGS.slice(4).map((a): CanonicalEvent => ({ type: 'content_delta', toolCall: { index: 0, function: { arguments: a } } })),
+            { type: 'done' },
+        ];
+
+        expect(framing(await stream(events))).toEqual(framing(recording('mixed')));
+    });
+});
+
+describe('frameChatChunks', () => {
+    async function frame(events: CanonicalEvent[]): Promise<CanonicalEvent[]> {
+        const framed: CanonicalEvent[] = [];
+        for await (const event of frameChatChunks((async function* () { yield* events; })())) {
+            framed.push(event);
+        }
+        return framed;
+    }
+
+    it('should open and finish every choice, each in index order', async () => {
+        const framed = await frame([
+            { type: 'content_delta', choiceIndex: 1, contentDelta: 'B' },
+            { type: 'content_delta', choiceIndex: 0, contentDelta: 'A', finishReason: 'length' },
+            { type: 'message_delta', choiceIndex: 1, finishReason: 'stop', usage },
+            { type: 'done' },
+        ]);
+
+        expect(framed.map((e) => [e.choiceIndex ?? 0, e.role ?? e.contentDelta ?? e.finishReason])).toEqual([
+            [1, 'assistant'], [1, 'B'],
+            [0, 'assistant'], [0, 'A'],
+            [0, 'length'], [1, 'stop'],
+            [0, undefined],
+        ]);
+        expect(framed[5]!.usage).toEqual(usage);
+        expect(framed[6]!.type).toBe('done');
+    });
+
+    it('should still send a role and a finish for a stream with no content', async () => {
+        const framed = await frame([{ type: 'message_start', role: 'assistant' }, { type: 'done' }]);
+
+        expect(framed).toEqual([
+            { type: 'content_delta', choiceIndex: undefined, role: 'assistant', contentDelta: '' },
+            { type: 'message_delta', choiceIndex: undefined, finishReason: 'stop', stopSequence: undefined, usage: undefined },
+            { type: 'done' },
+        ]);
+    });
+});
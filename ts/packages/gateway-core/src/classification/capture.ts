@@ -0,0 +1,75 @@
+/**
+ * Response text capture for classification.
+ *
+ * Wraps a provider so the text of the first choice is handed on once the
+ * response is complete: a complete response's message content, or, for
+ * a stream, its content deltas accumulated to the end. Tool calls are not
+ * text and aren't captured.
+ *
+ * @module classification/capture
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+
+// ============================================================================
+// Capturing Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so each response's text is captured.
+ */
+export class TextCapturingProvider extends ProviderWrapper {
+    private readonly onText: (text: string) => void;
+
+    constructor(inner: Provider, onText: (text: string) => void) {
+        super(inner);
+        this.onText = onText;
+    }
+
+    /**
+     * Completes a request, capturing the first choice's text.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, options);
+        const content = response.choices[0]?.message.content;
+        this.onText(typeof content === 'string' ? content : '');
+        return response;
+    }
+
+    /**
+     * Streams a request, capturing the first choice's text once the
+     * stream ends. The text is handed on at the done event, since
+     * consumers stop reading there.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        let text = '';
+        let captured = false;
+        for await (const event of this.inner.stream(request, options)) {
+            if (event.contentDelta && (event.choiceIndex ?? 0) === 0) {
+                text += event.contentDelta;
+            }
+            if (event.type === 'done' && !captured) {
+                captured = true;
+                this.onText(text);
+            }
+            yield event;
+        }
+        if (!captured) {
+            this.onText(text);
+        }
+    }
+}
+
+/**
+ * Captures a provider's response text when `onText` is given. Returns the
+ * provider unchanged otherwise.
+ */
+export function withTextCapture(provider: Provider, onText: ((text: string) => void) | undefined): Provider {
+    return onText ? new TextCapturingProvider(provider, onText) : provider;
+}
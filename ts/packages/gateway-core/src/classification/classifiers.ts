@@ -0,0 +1,161 @@
+/**
+ * Response classifiers.
+ *
+ * A classifier reads a response's text and returns tags for it: the
+ * built-in language classifier runs the local detector; the moderation
+ * classifier asks an OpenAI provider's moderation endpoint which safety
+ * categories the text falls in.
+ *
+ * @module classification/classifiers
+ */
+
+import type { ProviderCredentials } from '../ports/provider.js';
+import { detectLanguage } from './language.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default moderation model. */
+export const DEFAULT_MODERATION_MODEL = 'omni-moderation-latest';
+
+/** How long a moderation call may take. */
+export const DEFAULT_MODERATION_TIMEOUT_MS = 10_000;
+
+const DEFAULT_BASE_URL = 'https://api.openai.com';
+const MODERATIONS_PATH = '/v1/moderations';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Tags for one response. Unset fields weren't classified.
+ */
+export interface ResponseClassification {
+    /** Detected language (ISO 639-1). */
+    language?: string | undefined;
+
+    /** Safety categories the response was flagged for; empty when none. */
+    safetyFlags?: string[] | undefined;
+}
+
+/**
+ * Classifies response text.
+ */
+export interface ResponseClassifier {
+    /** Classifier name, for logs. */
+    readonly name: string;
+
+    /** Returns the text's tags; rejects when it can't classify. */
+    classify(text: string): Promise<ResponseClassification>;
+}
+
+/**
+ * Where a provider's moderation requests go.
+ */
+export interface ModerationTarget {
+    /** Provider base URL (default: https://api.openai.com). */
+    baseUrl?: string | undefined;
+
+    /** Provider key source. */
+    credentials: ProviderCredentials;
+
+    /** Fetch implementation (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+}
+
+/**
+ * ModerationClassifier options.
+ */
+export interface ModerationClassifierOptions {
+    /** Provider name, for errors. */
+    provider: string;
+
+    /** The provider's target, or undefined if it isn't an OpenAI provider. */
+    target: ModerationTarget | undefined;
+
+    /** Moderation model (default: omni-moderation-latest). */
+    model?: string | undefined;
+
+    /** Call timeout in ms (default 10000). */
+    timeoutMs?: number | undefined;
+}
+
+// ============================================================================
+// Classifiers
+// ============================================================================
+
+/**
+ * Built-in language classifier.
+ */
+export const languageClassifier: ResponseClassifier = {
+    name: 'language',
+    async classify(text) {
+        return { language: detectLanguage(text) };
+    },
+};
+
+/**
+ * Safety classifier backed by an OpenAI moderation endpoint. Flags are
+ * the categories the endpoint marks true (e.g. harassment, violence).
+ */
+export class ModerationClassifier implements ResponseClassifier {
+    readonly name = 'moderation';
+    private readonly provider: string;
+    private readonly target: ModerationTarget | undefined;
+    private readonly model: string;
+    private readonly timeoutMs: number;
+
+    constructor(options: ModerationClassifierOptions) {
+        this.provider = options.provider;
+        this.target = options.target;
+        this.model = options.model ?? DEFAULT_MODERATION_MODEL;
+        this.timeoutMs = options.timeoutMs ?? DEFAULT_MODERATION_TIMEOUT_MS;
+    }
+
+    async classify(text: string): Promise<ResponseClassification> {
+        const target = this.target;
+        if (!target) {
+            throw new Error(`Safety classification needs an OpenAI provider; '${this.provider}' is not one`);
+        }
+        const lease = target.credentials.acquire();
+        const response = await (target.fetch ?? globalThis.fetch.bind(globalThis))(
+            `${(target.baseUrl ?? DEFAULT_BASE_URL).replace(/\/$/, '')}${MODERATIONS_PATH}`,
+            {
+                method: 'POST',
+                headers: { 'Authorization': `Bearer ${lease.key}`, 'Content-Type': 'application/json' },
+                body: JSON.stringify({ model: this.model, input: text }),
+                signal: AbortSignal.timeout(this.timeoutMs),
+            },
+        );
+        target.credentials.report(lease, response.status);
+        if (!response.ok) {
+            throw new Error(`Moderation request failed with status ${response.status}`);
+        }
+        const body = await response.json() as { results?: Array<{ categories?: Record<string, boolean> }> };
+        const flags = new Set<string>();
+        for (const result of body.results ?? []) {
+            for (const [category, flagged] of Object.entries(result.categories ?? {})) {
+                if (flagged) {
+                    flags.add(category);
+                }
+            }
+        }
+        return { safetyFlags: [...flags].sort() };
+    }
+}
+
+/**
+ * Shapes a classification as interaction metadata for the request log.
+ */
+export function classificationMetadata(result: ResponseClassification): Record<string, string> {
+    const metadata: Record<string, string> = {};
+    if (result.language) {
+        metadata['response_language'] = result.language;
+    }
+    if (result.safetyFlags?.length) {
+        metadata['safety_flags'] = result.safetyFlags.join(',');
+    }
+    return metadata;
+}
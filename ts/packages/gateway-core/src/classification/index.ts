@@ -0,0 +1,30 @@
+/**
+ * Response classification exports.
+ *
+ * @module classification
+ */
+
+export { detectLanguage, MIN_LANGUAGE_LETTERS } from './language.js';
+
+export {
+    languageClassifier,
+    classificationMetadata,
+    ModerationClassifier,
+    DEFAULT_MODERATION_MODEL,
+    DEFAULT_MODERATION_TIMEOUT_MS,
+    type ResponseClassification,
+    type ResponseClassifier,
+    type ModerationTarget,
+    type ModerationClassifierOptions,
+} from './classifiers.js';
+
+export {
+    ClassificationWorker,
+    DEFAULT_CLASSIFICATION_CONCURRENCY,
+    DEFAULT_CLASSIFICATION_QUEUE_SIZE,
+    type ClassificationJob,
+    type ClassificationWorkerOptions,
+    type ClassificationStats,
+} from './worker.js';
+
+export { TextCapturingProvider, withTextCapture } from './capture.js';
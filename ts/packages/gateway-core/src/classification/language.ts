@@ -0,0 +1,147 @@
+/**
+ * Response language detection.
+ *
+ * A fast detector with no network calls or dependencies. Text in a script
+ * used by one language (Hangul, kana, Greek, Thai, ...) is decided by the
+ * script alone. Latin-script text is compared against small profiles of
+ * each language's most common character trigrams: the language whose
+ * frequent trigrams cover the text best wins. Text too short to tell, or
+ * that no profile covers, is left undetected.
+ *
+ * @module classification/language
+ */
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Letters needed before a language is detected. */
+export const MIN_LANGUAGE_LETTERS = 20;
+
+/** Most frequent trigrams of the text compared against the profiles. */
+const TEXT_TRIGRAMS = 300;
+
+/** Share of the best score the runner-up must stay under for Latin text. */
+const MARGIN = 0.9;
+
+/**
+ * Most common trigrams of each Latin-script language, most frequent
+ * first; a space marks a word boundary.
+ */
+const LATIN_PROFILES: Record<string, string> = {
+    en: ' th|the|he |and| an|nd |ing| to|ng |of | of|ion| in|ed |er |to |is |ent|tio| a |on |in |at |es |for|re | co|hat|tha| be|it |as |ter| is|ere|or | wh|his|you| yo|ou |ati|con| fo| it|ly |all|ver| re|ith|wit| wi|thi|are| ha|hav|ave|ve |not| no|ot ',
+    es: ' de|de | la|la |os | qu|que|el | el|es |ue |en | en|as |ent| co|ión|ón |aci|lo | lo|con|ado|los|nte|est| es|cio|ien|ara|par| pa|ra | se|do |er |ar |una| un|por| po|or | y |al |le |sta|mos|las| su|ida|nes|ero|tra|pro| pr|com| ca|ués|ust| us|ndo',
+    fr: ' de|es |de | le|le |ent| la|la |nt |les| co|ion|on | et|et |re |que| qu|ue | pa|des|men|tio|ons| po|ait|our|ous| vo|vou|pou|ur |est| es|ne |ans| da|dan|par|une| un| se|ais| à |eme|res|ell|qui|ui |te | ce|ce |pas|son| so|aux| au|ez | ne|ité|té ',
+    de: 'en |er | de|der|ie |die| di|ich|ein| ei|ch |sch|und| un|nd |ung|che|den|in |ine|gen| ge|te |cht|ten|ist| is|st |ier| da|das|as | zu|zu |ber|ent|nen|ns | si|sie| ni|nic|ht |auf| au|mit| mi|it |ere|lic|ver| ve|eit|hen|ach|sen| we|ür | fü|für|sin|ind',
+    it: ' di|di |la | la|che| ch|he |to | il|il |el |del| de|ell|lla|per| pe|er | co|one|ne |on |ion|zio|ent|re | in|are|ato|no |le | un|con|nte|tto|sta|gli| gl|li |ere|ti |ta |ano| no|non|ono|ra | è |ri |ess|pro| so|ia |io |una|ame|men',
+    pt: ' de|de |os | qu|que|ue | co|do | do|ão |ção|as |da | da|ent| a | e |es | o |em | em|com|par| pa|ara|ra |nte|men|est| es|uma| um|um |açã|ado|no | no|se | se|mos|ica|dos|não| nã|ões|cia|ida|ter|por| po|or |ade|ais|voc|ocê| vo|con|ndo|mai|ma ',
+    nl: 'en | de|de |an |van| va|et |het| he| ee|een|er |ijk|ing| in|in |aar|ver| ve|oor|nde| en|cht| op|op |te | te|ten|ond|den|ere|gen| ge|ie |die| di|dat| da|at |is | is|ij |lij|eer|ik | ik|zij| zi|wor|nie|iet|aan| aa|jn |ijn|oe |moe|kun|unt',
+};
+
+/** Ranked trigram profiles, by language. */
+const PROFILES: ReadonlyArray<{ language: string; ranks: ReadonlyMap<string, number> }> = Object.entries(LATIN_PROFILES)
+    .map(([language, trigrams]) => {
+        const ranks = new Map<string, number>();
+        for (const trigram of trigrams.split('|')) {
+            if (trigram.length === 3 && !ranks.has(trigram)) {
+                ranks.set(trigram, ranks.size);
+            }
+        }
+        return { language, ranks };
+    });
+
+/**
+ * Scripts that decide the language by themselves, checked in order.
+ * Kana is checked before Han so Japanese isn't taken for Chinese.
+ */
+const SCRIPTS: ReadonlyArray<{ pattern: RegExp; language: string }> = [
+    { pattern: /\p{Script=Hangul}/gu, language: 'ko' },
+    { pattern: /[\p{Script=Hiragana}\p{Script=Katakana}]/gu, language: 'ja' },
+    { pattern: /\p{Script=Han}/gu, language: 'zh' },
+    { pattern: /\p{Script=Greek}/gu, language: 'el' },
+    { pattern: /\p{Script=Hebrew}/gu, language: 'he' },
+    { pattern: /\p{Script=Arabic}/gu, language: 'ar' },
+    { pattern: /\p{Script=Devanagari}/gu, language: 'hi' },
+    { pattern: /\p{Script=Thai}/gu, language: 'th' },
+];
+
+/** Letters only Ukrainian writes in Cyrillic. */
+const UKRAINIAN_LETTERS = /[іїєґ]/iu;
+
+// ============================================================================
+// Detection
+// ============================================================================
+
+/**
+ * Detects the language of text, as an ISO 639-1 code, or undefined when
+ * it can't tell.
+ */
+export function detectLanguage(text: string): string | undefined {
+    const letters = text.match(/\p{L}/gu)?.length ?? 0;
+    if (letters < MIN_LANGUAGE_LETTERS) {
+        return undefined;
+    }
+
+    const latin = text.match(/\p{Script=Latin}/gu)?.length ?? 0;
+    const cyrillic = text.match(/\p{Script=Cyrillic}/gu)?.length ?? 0;
+    if (cyrillic > letters / 2) {
+        return UKRAINIAN_LETTERS.test(text) ? 'uk' : 'ru';
+    }
+    // Kana among Han is Japanese even when Han characters outnumber it
+    for (const { pattern, language } of SCRIPTS) {
+        const count = text.match(pattern)?.length ?? 0;
+        if (count > 0 && (count > letters / 2 || (language === 'ja' && count >= letters / 10))) {
+            return language;
+        }
+    }
+    if (latin <= letters / 2) {
+        return undefined;
+    }
+    return detectLatin(text);
+}
+
+/**
+ * Picks the Latin-script profile that covers the text's most frequent
+ * trigrams best, weighting each by its rank in the profile.
+ */
+function detectLatin(text: string): string | undefined {
+    const trigrams = textTrigrams(text);
+    let best: { language: string; score: number } | undefined;
+    let runnerUp = 0;
+    for (const { language, ranks } of PROFILES) {
+        let score = 0;
+        for (const [trigram, count] of trigrams) {
+            const rank = ranks.get(trigram);
+            if (rank !== undefined) {
+                score += count * (ranks.size - rank);
+            }
+        }
+        if (!best || score > best.score) {
+            runnerUp = best?.score ?? 0;
+            best = { language, score };
+        } else if (score > runnerUp) {
+            runnerUp = score;
+        }
+    }
+    if (!best || best.score === 0 || runnerUp >= best.score * MARGIN) {
+        return undefined;
+    }
+    return best.language;
+}
+
+/**
+ * Counts the text's trigrams (each word padded with spaces), keeping the
+ * most frequent.
+ */
+function textTrigrams(text: string): Array<[string, number]> {
+    const counts = new Map<string, number>();
+    for (const word of text.toLowerCase().split(/[^\p{L}]+/u)) {
+        if (!word) continue;
+        const padded = ` ${word} `;
+        for (let i = 0; i + 3 <= padded.length; i++) {
+            const trigram = padded.slice(i, i + 3);
+            counts.set(trigram, (counts.get(trigram) ?? 0) + 1);
+        }
+    }
+    return [...counts].sort((a, b) => b[1] - a[1]).slice(0, TEXT_TRIGRAMS);
+}
@@ -0,0 +1,141 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { ClassificationWorker, detectLanguage, type ResponseClassifier } from './index';
+import { harness, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const FRENCH = "Bien sûr ! Voici comment configurer la passerelle : définissez la clé du fournisseur dans votre environnement.";
+const ENGLISH = 'Sure! Here is how you can configure the gateway: set the provider key in your environment and restart it.';
+
+describe('detectLanguage', () => {
+    it('should detect languages by trigrams and by script', () => {
+        expect(detectLanguage(ENGLISH)).toBe('en');
+        expect(detectLanguage(FRENCH)).toBe('fr');
+        expect(detectLanguage('Natürlich! So konfigurieren Sie das Gateway: Setzen Sie den Schlüssel des Anbieters.')).toBe('de');
+        expect(detectLanguage('Claro, aquí tienes cómo configurar el servicio: pon la clave del proveedor en tu entorno.')).toBe('es');
+        expect(detectLanguage('素早い茶色の狐が怠け者の犬を飛び越えて、それから暖かい午後の日差しの中で眠ります。')).toBe('ja');
+        expect(detectLanguage('Быстрая коричневая лиса перепрыгивает через ленивую собаку.')).toBe('ru');
+    });
+
+    it('should leave text too short to tell undetected', () => {
+        expect(detectLanguage('ok')).toBeUndefined();
+        expect(detectLanguage('{"id": 42}')).toBeUndefined();
+    });
+});
+
+describe('ClassificationWorker', () => {
+    it('should bound concurrency, drop past the queue, and only log failures', async () => {
+        const logger = spyLogger();
+        const worker = new ClassificationWorker({ concurrency: 1, queueSize: 1, logger });
+        let release!: () => void;
+        const gate = new Promise<void>((resolve) => (release = resolve));
+        const slow: ResponseClassifier = { name: 'slow', classify: vi.fn(async () => (await gate, { language: 'en' })) };
+        const broken: ResponseClassifier = { name: 'broken', classify: vi.fn(async () => { throw new Error('moderation down'); }) };
+        const results: unknown[] = [];
+
+        expect(worker.enqueue({ text: 'a', classifiers: [slow, broken], done: (r) => results.push(r) })).toBe(true);
+        expect(worker.enqueue({ text: 'b', classifiers: [slow], done: (r) => results.push(r) })).toBe(true);
+        expect(worker.enqueue({ text: 'c', classifiers: [slow], done: (r) => results.push(r) })).toBe(false);
+        expect(worker.stats()).toMatchObject({ inFlight: 1, queued: 1, dropped: 1 });
+        expect(results).toEqual([{}]);
+
+        release();
+        await worker.drain();
+
+        expect(results).toEqual([{}, { language: 'en' }, { language: 'en' }]);
+        expect(broken.classify).toHaveBeenCalledTimes(1);
+        expect(logger.warn).toHaveBeenCalledWith('response_classification_failed', { classifier: 'broken', error: 'moderation down' });
+        expect(worker.stats()).toMatchObject({ classified: 2, failed: 1, inFlight: 0, queued: 0 });
+    });
+});
+
+describe('Response classification', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        vi.unstubAllGlobals();
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const auth = {
+            authenticate: async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }),
+            getTenant: async () => null,
+        };
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', classification: { safety: { provider: 'mock' } } })
+            .app({ name: 'plain', frontdoor: 'openai', path: '/plain' })
+            .provider(new ScriptedProvider('mock', [
+                { text: ENGLISH },
+                { text: FRENCH },
+                { chunks: FRENCH.split(/(?= )/) },
+                { text: 'ok' },
+            ]))
+            .options({ auth })
+            .start();
+        const gw = gateway;
+        const chat = (path = '/v1', stream = false) =>
+            gw.post(`${path}/chat/completions`, { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], stream });
+        const admin = new AdminHandler({ auth, usage: gw.gateway.usageReports });
+        const list = async (query: string) => (await admin.handle(new Request(
+            `http://localhost/api/interactions?${query}`,
+            { headers: { Authorization: 'Bearer acme' } },
+        ))).json();
+        return { gateway: gw.gateway, chat, list };
+    }
+
+    it('should tag responses after they are sent and expose them in reports and the admin API', async () => {
+        const moderation = vi.fn(async (_url: string, init: RequestInit) => {
+            const { input } = JSON.parse(init.body as string);
+            return Response.json({
+                results: [{ flagged: input === FRENCH, categories: { harassment: false, violence: input === FRENCH } }],
+            });
+        });
+        vi.stubGlobal('fetch', moderation);
+        const { gateway, chat, list } = await setup();
+
+        expect((await chat()).status).toBe(200);
+        expect((await chat()).status).toBe(200);
+        const streamed = await chat('/v1', true);
+        await streamed.text();
+        await chat('/plain');
+        await vi.waitFor(() => expect(moderation).toHaveBeenCalledTimes(3));
+        await gateway.close();
+
+        expect(moderation).toHaveBeenCalledTimes(3);
+        expect(moderation.mock.calls[0]![0]).toBe('https://api.openai.com/v1/moderations');
+        expect(JSON.parse(moderation.mock.calls[0]![1].body as string)).toMatchObject({ model: 'omni-moderation-latest' });
+
+        const range = gateway.usageReports.parseRange(new URLSearchParams());
+        const report = await gateway.usageReports.report('acme', range, 'language');
+        expect(report.group_by).toBe('language');
+        expect(report.data).toEqual([
+            expect.objectContaining({ language: null, requests: 1, safety_flagged: 0 }),
+            expect.objectContaining({ language: 'en', requests: 1, safety_flagged: 0 }),
+            expect.objectContaining({ language: 'fr', requests: 2, safety_flagged: 2 }),
+        ]);
+        expect((await gateway.usageReports.report('acme', range)).data[0]).not.toHaveProperty('language');
+
+        const french = await list('language=fr');
+        expect(french.total).toBe(2);
+        expect(french.interactions[0]).toMatchObject({ type: 'request', language: 'fr', safetyFlags: ['violence'] });
+        expect((await list('safety=violence')).total).toBe(2);
+        expect((await list('safety=*&language=en')).total).toBe(0);
+    });
+
+    it('should record stats without the failed classifier and never retry it', async () => {
+        const moderation = vi.fn(async () => new Response('unavailable', { status: 503 }));
+        vi.stubGlobal('fetch', moderation);
+        const { gateway, chat } = await setup();
+
+        expect((await chat()).status).toBe(200);
+        await vi.waitFor(() => expect(moderation).toHaveBeenCalledTimes(1));
+        await gateway.close();
+
+        expect(moderation).toHaveBeenCalledTimes(1);
+        expect(gateway.classificationStats()).toMatchObject({ classified: 1, failed: 1 });
+        const range = gateway.usageReports.parseRange(new URLSearchParams());
+        const report = await gateway.usageReports.report('acme', range, 'language');
+        expect(report.data).toEqual([expect.objectContaining({ language: 'en', requests: 1, safety_flagged: 0 })]);
+    });
+});
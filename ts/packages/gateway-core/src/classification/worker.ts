@@ -0,0 +1,199 @@
+/**
+ * Background response classification.
+ *
+ * Classification runs after the response has been sent, so it never adds
+ * latency. Jobs run with bounded concurrency; beyond it they wait in a
+ * bounded queue, and beyond that they are dropped and counted. A
+ * classifier that fails is logged and left out of the result, never
+ * retried: the job still completes with whatever the other classifiers
+ * returned.
+ *
+ * @module classification/worker
+ */
+
+import type { Logger } from '../utils/logging.js';
+import type { ResponseClassification, ResponseClassifier } from './classifiers.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default jobs classified at once. */
+export const DEFAULT_CLASSIFICATION_CONCURRENCY = 4;
+
+/** Default jobs waiting for a slot before new ones are dropped. */
+export const DEFAULT_CLASSIFICATION_QUEUE_SIZE = 1000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * One response to classify.
+ */
+export interface ClassificationJob {
+    /** The response text. */
+    text: string;
+
+    /** Classifiers to run. */
+    classifiers: ResponseClassifier[];
+
+    /** Request logger, for failures. */
+    logger?: Logger | undefined;
+
+    /** Receives the result, empty when the job was dropped. Called exactly once. */
+    done: (result: ResponseClassification) => void;
+}
+
+/**
+ * ClassificationWorker options.
+ */
+export interface ClassificationWorkerOptions {
+    /** Jobs classified at once (default 4). */
+    concurrency?: number | undefined;
+
+    /** Jobs waiting for a slot before new ones are dropped (default 1000). */
+    queueSize?: number | undefined;
+
+    /** Logger for drops. */
+    logger?: Logger | undefined;
+}
+
+/**
+ * Classification counters, as reported by /admin/api/stats.
+ */
+export interface ClassificationStats {
+    /** Jobs completed. */
+    classified: number;
+
+    /** Classifier calls that failed. */
+    failed: number;
+
+    /** Jobs dropped because the queue was full. */
+    dropped: number;
+
+    /** Jobs running. */
+    inFlight: number;
+
+    /** Jobs waiting for a slot. */
+    queued: number;
+}
+
+// ============================================================================
+// Classification Worker
+// ============================================================================
+
+/**
+ * Runs classification jobs in the background with bounded concurrency.
+ */
+export class ClassificationWorker {
+    private readonly concurrency: number;
+    private readonly queueSize: number;
+    private readonly logger?: Logger | undefined;
+    private readonly queue: ClassificationJob[] = [];
+    private readonly idle: Array<() => void> = [];
+    private inFlight = 0;
+    private classified = 0;
+    private failed = 0;
+    private dropped = 0;
+
+    constructor(options: ClassificationWorkerOptions = {}) {
+        this.concurrency = Math.max(1, options.concurrency ?? DEFAULT_CLASSIFICATION_CONCURRENCY);
+        this.queueSize = options.queueSize ?? DEFAULT_CLASSIFICATION_QUEUE_SIZE;
+        this.logger = options.logger;
+    }
+
+    /**
+     * Queues a job. Returns false if it was dropped, in which case its
+     * done callback has already been called with an empty result.
+     */
+    enqueue(job: ClassificationJob): boolean {
+        if (this.inFlight < this.concurrency) {
+            this.start(job);
+            return true;
+        }
+        if (this.queue.length >= this.queueSize) {
+            this.dropped++;
+            (job.logger ?? this.logger)?.warn('response_classification_dropped', { queued: this.queue.length });
+            finish(job, {});
+            return false;
+        }
+        this.queue.push(job);
+        return true;
+    }
+
+    /**
+     * Resolves once every queued and running job has completed.
+     */
+    drain(): Promise<void> {
+        if (this.inFlight === 0 && this.queue.length === 0) {
+            return Promise.resolve();
+        }
+        return new Promise((resolve) => this.idle.push(resolve));
+    }
+
+    /**
+     * Returns the worker's counters.
+     */
+    stats(): ClassificationStats {
+        return {
+            classified: this.classified,
+            failed: this.failed,
+            dropped: this.dropped,
+            inFlight: this.inFlight,
+            queued: this.queue.length,
+        };
+    }
+
+    // ---- Helpers ----
+
+    private start(job: ClassificationJob): void {
+        this.inFlight++;
+        void this.run(job).finally(() => {
+            this.inFlight--;
+            this.classified++;
+            const next = this.queue.shift();
+            if (next) {
+                this.start(next);
+            } else if (this.inFlight === 0) {
+                for (const resolve of this.idle.splice(0)) {
+                    resolve();
+                }
+            }
+        });
+    }
+
+    private async run(job: ClassificationJob): Promise<void> {
+        const results = await Promise.allSettled(job.classifiers.map((classifier) => classifier.classify(job.text)));
+        const merged: ResponseClassification = {};
+        results.forEach((result, i) => {
+            if (result.status === 'fulfilled') {
+                Object.assign(merged, definedFields(result.value));
+                return;
+            }
+            this.failed++;
+            (job.logger ?? this.logger)?.warn('response_classification_failed', {
+                classifier: job.classifiers[i]!.name,
+                error: result.reason instanceof Error ? result.reason.message : String(result.reason),
+            });
+        });
+        finish(job, merged);
+    }
+}
+
+/**
+ * Calls a job's done callback, logging (not throwing) what it throws.
+ */
+function finish(job: ClassificationJob, result: ResponseClassification): void {
+    try {
+        job.done(result);
+    } catch (error) {
+        job.logger?.error('response_classification_done_failed', {
+            error: error instanceof Error ? error.message : String(error),
+        });
+    }
+}
+
+function definedFields(result: ResponseClassification): ResponseClassification {
+    return Object.fromEntries(Object.entries(result).filter(([, value]) => value !== undefined));
+}
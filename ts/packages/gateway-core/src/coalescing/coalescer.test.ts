@@ -0,0 +1,177 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { errOverloaded } from '../domain/errors';
+import { RequestCoalescer } from './index';
+import type { CanonicalRequest, CanonicalResponse } from '../domain/types';
+import type { CoalescingConfig } from '../ports/index';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+function gate() {
+    let open!: () => void;
+    const opened = new Promise<void>((resolve) => {
+        open = resolve;
+    });
+    return { opened, open };
+}
+
+/**
+ * A provider whose calls all wait for a gate to open, counting them as
+ * they arrive.
+ */
+class GatedProvider extends ScriptedProvider {
+    calls = 0;
+
+    constructor(private readonly opened: Promise<void>, fail: boolean) {
+        super('mock', fail ? { error: errOverloaded('Provider overloaded') } : { text: 'Hello' });
+    }
+
+    override async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+        this.calls++;
+        await this.opened;
+        return super.complete(request);
+    }
+}
+
+describe('Request coalescing', () => {
+    const gateways: TestGateway[] = [];
+
+    afterEach(async () => {
+        await Promise.all(gateways.splice(0).map((gw) => gw.close()));
+    });
+
+    async function setup(coalesce: CoalescingConfig | undefined, fail = false) {
+        const { opened, open } = gate();
+        const provider = new GatedProvider(opened, fail);
+        const logger = spyLogger();
+        const gw = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', coalesce })
+            .provider(provider)
+            .options({ logger })
+            .start();
+        gateways.push(gw);
+        const send = (body: Record<string, unknown> = {}) =>
+            gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...body });
+        const joined = () => logged(logger.info, 'interaction_metadata')
+            .filter((fields) => fields.coalesced === 'true')
+            .map((fields) => fields.coalesced_with);
+        return { gateway: gw.gateway, provider, send, open, joined };
+    }
+
+    it('should make one provider call for 50 simultaneous identical requests', async () => {
+        const { gateway, provider, send, open, joined } = await setup({});
+
+        const pending = Array.from({ length: 50 }, () => send());
+        await vi.waitFor(() => expect(gateway.coalescingStats()).toEqual([{ app: 'chat', calls: 1, coalesced: 49 }]));
+        open();
+        const responses = await Promise.all(pending);
+        const bodies = await Promise.all(responses.map((r) => r.json()));
+
+        expect(provider.calls).toBe(1);
+        expect(responses.every((r) => r.status === 200)).toBe(true);
+        expect(new Set(bodies.map((b) => b.choices[0].message.content))).toEqual(new Set(['Hello']));
+        expect(joined()).toHaveLength(49);
+        expect(new Set(joined()).size).toBe(1);
+    });
+
+    it('should give every joined request the leader\'s error', async () => {
+        const { provider, send, open, gateway } = await setup({}, true);
+
+        const pending = Array.from({ length: 5 }, () => send());
+        await vi.waitFor(() => expect(gateway.coalescingStats()[0]?.coalesced).toBe(4));
+        open();
+        const responses = await Promise.all(pending);
+
+        expect(provider.calls).toBe(1);
+        expect(new Set(responses.map((r) => r.status))).toEqual(new Set([503]));
+    });
+
+    it('should leave requests with a temperature above 0 alone unless the app includes them', async () => {
+        const sampled = await setup({});
+        const pending = [sampled.send({ temperature: 0.7 }), sampled.send({ temperature: 0.7 })];
+        await vi.waitFor(() => expect(sampled.provider.calls).toBe(2));
+        sampled.open();
+        await Promise.all(pending);
+
+        const included = await setup({ includeSampled: true });
+        const joined = [included.send({ temperature: 0.7 }), included.send({ temperature: 0.7 })];
+        await vi.waitFor(() => expect(included.gateway.coalescingStats()[0]?.coalesced).toBe(1));
+        included.open();
+        await Promise.all(joined);
+        expect(included.provider.calls).toBe(1);
+    });
+
+    it('should not coalesce apps that have not opted in or different requests', async () => {
+        const off = await setup(undefined);
+        const pending = [off.send(), off.send()];
+        await vi.waitFor(() => expect(off.provider.calls).toBe(2));
+        off.open();
+        await Promise.all(pending);
+        expect(off.gateway.coalescingStats()).toEqual([]);
+
+        const on = await setup({});
+        const different = [on.send(), on.send({ max_tokens: 50 })];
+        await vi.waitFor(() => expect(on.provider.calls).toBe(2));
+        on.open();
+        await Promise.all(different);
+    });
+});
+
+describe('RequestCoalescer', () => {
+    const response = { id: 'r1', model: 'm', choices: [], rawResponse: new Uint8Array([1]) } as unknown as CanonicalResponse;
+    const call = (interactionId: string, signal?: AbortSignal) => ({ app: 'chat', key: 'k', interactionId, windowMs: 1000, signal });
+
+    it('should release a caller that gives up at once, cancelling the call only when all have', async () => {
+        const coalescer = new RequestCoalescer();
+        const { opened, open } = gate();
+        let upstream: AbortSignal | undefined;
+        const execute = vi.fn(async (signal: AbortSignal) => {
+            upstream = signal;
+            await opened;
+            return response;
+        });
+        const leader = new AbortController();
+        const follower = new AbortController();
+
+        const led = coalescer.run(call('int-1', leader.signal), execute);
+        const followed = coalescer.run(call('int-2', follower.signal), execute);
+        follower.abort(new Error('client went away'));
+
+        await expect(followed).rejects.toThrow('client went away');
+        expect(upstream!.aborted).toBe(false);
+
+        open();
+        await expect(led).resolves.toEqual({ response });
+        expect(execute).toHaveBeenCalledTimes(1);
+        expect(coalescer.inflight).toBe(0);
+    });
+
+    it('should cancel the shared call when every caller gives up', async () => {
+        const coalescer = new RequestCoalescer();
+        let upstream: AbortSignal | undefined;
+        const execute = (signal: AbortSignal) => new Promise<CanonicalResponse>((_, reject) => {
+            upstream = signal;
+            signal.addEventListener('abort', () => reject(signal.reason));
+        });
+        const first = new AbortController();
+        const second = new AbortController();
+
+        const runs = [coalescer.run(call('int-1', first.signal), execute), coalescer.run(call('int-2', second.signal), execute)];
+        first.abort(new Error('gone'));
+        second.abort(new Error('gone'));
+
+        await expect(Promise.all(runs)).rejects.toThrow('gone');
+        expect(upstream!.aborted).toBe(true);
+        expect(coalescer.inflight).toBe(0);
+    });
+
+    it('should give joiners their own copy without the raw provider body', async () => {
+        const coalescer = new RequestCoalescer();
+        const execute = async () => response;
+
+        const [led, joined] = await Promise.all([coalescer.run(call('int-1'), execute), coalescer.run(call('int-2'), execute)]);
+
+        expect(led.response).toBe(response);
+        expect(joined.leader).toBe('int-1');
+        expect(joined.response).not.toBe(response);
+        expect(joined.response).toEqual({ id: 'r1', model: 'm', choices: [] });
+    });
+});
@@ -0,0 +1,193 @@
+/**
+ * Single-flight coalescing of identical provider calls.
+ *
+ * During an incident, client retry storms send the same prompt dozens of
+ * times at once. For apps with coalescing on, a non-streaming call
+ * identical to one already in flight (same app, tenant and canonical
+ * request, started within the window) joins that call instead of making
+ * its own: the provider is called once and every caller gets the same
+ * response, or the same error. A caller that gives up stops waiting at
+ * once; the shared call is cancelled only when every caller has.
+ *
+ * @module coalescing/coalescer
+ */
+
+import type { CanonicalResponse } from '../domain/types.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default time after a call starts during which identical calls join it. */
+export const DEFAULT_COALESCING_WINDOW_MS = 2000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Coalescing counters for one app.
+ */
+export interface CoalescingStats {
+    /** App name. */
+    app: string;
+
+    /** Provider calls made for coalescable requests. */
+    calls: number;
+
+    /** Requests that joined a call in flight instead of making their own. */
+    coalesced: number;
+}
+
+/**
+ * One caller's share of a coalescable call.
+ */
+export interface CoalescedCall {
+    /** App the request came in on. */
+    app: string;
+
+    /** Identity of the call (see coalescingKey). */
+    key: string;
+
+    /** The caller's interaction ID. */
+    interactionId: string;
+
+    /** How long after a call starts others may join it (ms). */
+    windowMs: number;
+
+    /** Aborted when the caller gives up. */
+    signal?: AbortSignal | undefined;
+}
+
+/**
+ * A caller's result.
+ */
+export interface CoalescedResult {
+    /** The response. Joiners get their own copy, without the raw provider body. */
+    response: CanonicalResponse;
+
+    /** Interaction ID of the call joined; unset for the caller that made it. */
+    leader?: string | undefined;
+}
+
+/** A call in flight. */
+interface Flight {
+    key: string;
+    interactionId: string;
+    startedAt: number;
+    controller: AbortController;
+    result: Promise<CanonicalResponse>;
+    snapshot?: CanonicalResponse;
+
+    /** Callers still waiting. */
+    waiting: number;
+}
+
+// ============================================================================
+// Coalescer
+// ============================================================================
+
+/**
+ * Shares in-flight provider calls between identical requests.
+ */
+export class RequestCoalescer {
+    private readonly flights = new Map<string, Flight>();
+    private readonly counts = new Map<string, { calls: number; coalesced: number }>();
+
+    /**
+     * Runs a call, or joins the identical one in flight. execute makes the
+     * provider call; its signal aborts when every caller has given up.
+     */
+    async run(
+        call: CoalescedCall,
+        execute: (signal: AbortSignal) => Promise<CanonicalResponse>,
+    ): Promise<CoalescedResult> {
+        const counts = this.countsFor(call.app);
+        const existing = this.flights.get(call.key);
+        if (existing && Date.now() - existing.startedAt <= call.windowMs) {
+            counts.coalesced++;
+            await this.wait(existing, call.signal);
+            return { response: structuredClone(existing.snapshot!), leader: existing.interactionId };
+        }
+
+        const controller = new AbortController();
+        let flight!: Flight;
+        // The joiners' copy is taken before any caller can change the response
+        const result = execute(controller.signal)
+            .then((response) => {
+                const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...shared } = response;
+                flight.snapshot = structuredClone(shared);
+                return response;
+            })
+            .finally(() => this.land(flight));
+        flight = {
+            key: call.key,
+            interactionId: call.interactionId,
+            startedAt: Date.now(),
+            controller,
+            result,
+            waiting: 0,
+        };
+        this.flights.set(call.key, flight);
+        counts.calls++;
+
+        return { response: await this.wait(flight, call.signal) };
+    }
+
+    /**
+     * Returns the counters, sorted by app name.
+     */
+    stats(): CoalescingStats[] {
+        return Array.from(this.counts, ([app, { calls, coalesced }]) => ({ app, calls, coalesced }))
+            .sort((a, b) => a.app.localeCompare(b.app));
+    }
+
+    /** Number of calls in flight. */
+    get inflight(): number {
+        return this.flights.size;
+    }
+
+    /**
+     * Waits for a flight's result, or until the caller gives up. The last
+     * caller to give up cancels the call.
+     */
+    private wait(flight: Flight, signal: AbortSignal | undefined): Promise<CanonicalResponse> {
+        flight.waiting++;
+        if (!signal) {
+            return flight.result;
+        }
+        return new Promise<CanonicalResponse>((resolve, reject) => {
+            const abandon = (): void => {
+                reject(signal.reason);
+                if (--flight.waiting === 0) {
+                    this.land(flight);
+                    flight.controller.abort(signal.reason);
+                }
+            };
+            if (signal.aborted) {
+                abandon();
+                return;
+            }
+            signal.addEventListener('abort', abandon, { once: true });
+            flight.result
+                .then(resolve, reject)
+                .finally(() => signal.removeEventListener('abort', abandon));
+        });
+    }
+
+    /** Stops a flight taking joiners. */
+    private land(flight: Flight): void {
+        if (this.flights.get(flight.key) === flight) {
+            this.flights.delete(flight.key);
+        }
+    }
+
+    private countsFor(app: string): { calls: number; coalesced: number } {
+        let counts = this.counts.get(app);
+        if (!counts) {
+            counts = { calls: 0, coalesced: 0 };
+            this.counts.set(app, counts);
+        }
+        return counts;
+    }
+}
@@ -0,0 +1,20 @@
+/**
+ * Request coalescing exports.
+ *
+ * @module coalescing
+ */
+
+export {
+    RequestCoalescer,
+    DEFAULT_COALESCING_WINDOW_MS,
+    type CoalescingStats,
+    type CoalescedCall,
+    type CoalescedResult,
+} from './coalescer.js';
+
+export {
+    CoalescingProvider,
+    coalescingKey,
+    withCoalescing,
+    type CoalescingOptions,
+} from './provider.js';
@@ -0,0 +1,108 @@
+/**
+ * Coalescing providers.
+ *
+ * Routes an app's non-streaming calls through the gateway's
+ * RequestCoalescer. Streams are never coalesced, and neither are calls
+ * with a temperature above 0 unless the app includes them: their callers
+ * expect different samples.
+ *
+ * @module coalescing/provider
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { CoalescingConfig } from '../ports/config.js';
+import { sha256 } from '../utils/crypto.js';
+import { DEFAULT_COALESCING_WINDOW_MS, type RequestCoalescer } from './coalescer.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * One request's coalescing settings.
+ */
+export interface CoalescingOptions {
+    /** App the request came in on. */
+    app: string;
+
+    /** The request's interaction ID. */
+    interactionId: string;
+
+    /** Aborted when the client request is abandoned. */
+    signal?: AbortSignal | undefined;
+
+    /** Called when the request joined another's call, with that call's interaction ID. */
+    onJoin?: ((leader: string) => void) | undefined;
+}
+
+// ============================================================================
+// Coalescing Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so identical concurrent calls share one upstream call.
+ */
+export class CoalescingProvider extends ProviderWrapper {
+    private readonly coalescer: RequestCoalescer;
+    private readonly config: CoalescingConfig;
+    private readonly options: CoalescingOptions;
+
+    constructor(inner: Provider, coalescer: RequestCoalescer, config: CoalescingConfig, options: CoalescingOptions) {
+        super(inner);
+        this.coalescer = coalescer;
+        this.config = config;
+        this.options = options;
+    }
+
+    /**
+     * Completes a request, joining an identical call in flight if there is one.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        if (!this.config.includeSampled && request.temperature !== undefined && request.temperature > 0) {
+            return this.inner.complete(request, options);
+        }
+
+        const { response, leader } = await this.coalescer.run({
+            app: this.options.app,
+            key: await coalescingKey(this.options.app, this.name, request),
+            interactionId: this.options.interactionId,
+            windowMs: this.config.windowMs ?? DEFAULT_COALESCING_WINDOW_MS,
+            signal: options?.signal ?? this.options.signal,
+        }, (signal) => this.inner.complete(request, { ...options, signal }));
+        if (leader) {
+            this.options.onJoin?.(leader);
+        }
+        return response;
+    }
+}
+
+/**
+ * Identity of a provider call for coalescing: the app, the provider, and
+ * the canonical request (which carries the tenant), without what differs
+ * between clients sending the same request.
+ */
+export async function coalescingKey(app: string, provider: string, request: CanonicalRequest): Promise<string> {
+    const { rawRequest: _, userAgent: __, upstreamHeaders: ___, ...identity } = request;
+    return sha256(JSON.stringify([app, provider, identity]));
+}
+
+/**
+ * Coalesces an app's identical calls. Returns the provider unchanged when
+ * the app doesn't have coalescing on.
+ */
+export function withCoalescing(
+    provider: Provider,
+    coalescer: RequestCoalescer,
+    config: CoalescingConfig | undefined,
+    options: CoalescingOptions,
+): Provider {
+    if (!config || config.enabled === false) {
+        return provider;
+    }
+    return new CoalescingProvider(provider, coalescer, config, options);
+}
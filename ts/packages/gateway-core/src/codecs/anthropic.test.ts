@@ -1,5 +1,6 @@
 import { describe, it, expect } from 'vitest';
 import { AnthropicCodec } from './anthropic';
+import { OpenAICodec } from './openai';
 import type { CanonicalRequest, CanonicalResponse } from '../domain/types';
 
 describe('AnthropicCodec', () => {
@@ -213,4 +214,283 @@ describe('AnthropicCodec', () => {
             expect(error.message).toBe('Invalid API key');
         });
     });
+
+    describe('extended thinking', () => {
+        it('should decode the thinking request field', () => {
+            const request = codec.decodeRequest(JSON.stringify({
+                model: 'claude-sonnet-4',
+                max_tokens: 16000,
+                thinking: { type: 'enabled', budget_tokens: 10000 },
+                messages: [{ role: 'user', content: [{ type: 'text', text: 'Hi' }] }],
+            }));
+
+            expect(request.thinking).toEqual({ type: 'enabled', budgetTokens: 10000 });
+        });
+
+        const encodeEffort = (maxTokens: number) => JSON.parse(new TextDecoder().decode(codec.encodeRequest({
+            tenantId: 'test',
+            model: 'claude-sonnet-4',
+            messages: [{ role: 'user', content: 'Hi' }],
+            stream: false,
+            maxTokens,
+            reasoningEffort: 'medium',
+            sourceAPIType: 'openai',
+        })));
+
+        it('should map reasoning effort to a thinking budget', () => {
+            const parsed = encodeEffort(16000);
+
+            expect(parsed.thinking).toEqual({ type: 'enabled', budget_tokens: 4096 });
+            expect(parsed.max_tokens).toBe(16000);
+        });
+
+        it('should fit a mapped budget under max_tokens rather than raise it', () => {
+            const narrowed = encodeEffort(3000);
+            const dropped = encodeEffort(1000);
+
+            expect(narrowed.thinking).toEqual({ type: 'enabled', budget_tokens: 2999 });
+            expect(narrowed.max_tokens).toBe(3000);
+            expect(dropped).not.toHaveProperty('thinking');
+            expect(dropped.max_tokens).toBe(1000);
+        });
+
+        it('should round-trip thinking blocks in responses', () => {
+            const response = codec.decodeResponse(JSON.stringify({
+                id: 'msg_1',
+                type: 'message',
+                role: 'assistant',
+                model: 'claude-sonnet-4',
+                content: [
+                    { type: 'thinking', thinking: 'Let me think', signature: 'sig' },
+                    { type: 'text', text: 'Answer' },
+                ],
+                stop_reason: 'end_turn',
+                stop_sequence: null,
+                usage: { input_tokens: 10, output_tokens: 20 },
+            }));
+
+            expect(response.choices[0]?.message.content).toBe('Answer');
+
+            const parsed = JSON.parse(new TextDecoder().decode(codec.encodeResponse(response)));
+            expect(parsed.content[0]).toEqual({ type: 'thinking', thinking: 'Let me think', signature: 'sig' });
+            expect(parsed.content[1]).toEqual({ type: 'text', text: 'Answer' });
+        });
+
+        it('should decode thinking and signature deltas', () => {
+            const thinking = codec.decodeStreamChunk(JSON.stringify({
+                type: 'content_block_delta',
+                index: 0,
+                delta: { type: 'thinking_delta', thinking: 'Hmm' },
+            }));
+            const signature = codec.decodeStreamChunk(JSON.stringify({
+                type: 'content_block_delta',
+                index: 0,
+                delta: { type: 'signature_delta', signature: 'abc' },
+            }));
+
+            expect(thinking?.thinkingDelta).toBe('Hmm');
+            expect(thinking?.contentDelta).toBeUndefined();
+            expect(signature?.signatureDelta).toBe('abc');
+
+            const encoded = JSON.parse(codec.encodeStreamEvent(thinking!));
+            expect(encoded.delta).toEqual({ type: 'thinking_delta', thinking: 'Hmm' });
+        });
+    });
+
+    describe('stop sequences', () => {
+        const openai = new OpenAICodec();
+        const decode = (bytes: Uint8Array) => JSON.parse(new TextDecoder().decode(bytes));
+
+        it('should round-trip multiple stop sequences through OpenAI requests', () => {
+            const request = codec.decodeRequest(JSON.stringify({
+                model: 'claude-3-sonnet-20240229',
+                max_tokens: 1024,
+                stop_sequences: ['###', 'END'],
+                messages: [{ role: 'user', content: 'Count' }],
+            }));
+            expect(request.stop).toEqual(['###', 'END']);
+
+            const openaiBody = decode(openai.encodeRequest(request));
+            expect(openaiBody.stop).toEqual(['###', 'END']);
+
+            const back = decode(codec.encodeRequest(openai.decodeRequest(JSON.stringify(openaiBody))));
+            expect(back.stop_sequences).toEqual(['###', 'END']);
+        });
+
+        it('should keep the matched stop sequence from an Anthropic response', () => {
+            const response = codec.decodeResponse(JSON.stringify({
+                id: 'msg_123',
+                type: 'message',
+                role: 'assistant',
+                model: 'claude-3-sonnet-20240229',
+                content: [{ type: 'text', text: '1 2 3 ' }],
+                stop_reason: 'stop_sequence',
+                stop_sequence: 'END',
+                usage: { input_tokens: 10, output_tokens: 6 },
+            }));
+
+            expect(response.choices[0]?.finishReason).toBe('stop');
+            expect(response.choices[0]?.stopSequence).toBe('END');
+
+            const openaiBody = decode(openai.encodeResponse(response));
+            expect(openaiBody.choices[0].finish_reason).toBe('stop');
+            expect(openaiBody.choices[0].stop_reason).toBe('END');
+
+            const back = decode(codec.encodeResponse(openai.decodeResponse(JSON.stringify(openaiBody))));
+            expect(back.stop_reason).toBe('stop_sequence');
+            expect(back.stop_sequence).toBe('END');
+        });
+
+        it('should report end_turn without a stop sequence', () => {
+            const response = codec.decodeResponse(JSON.stringify({
+                id: 'msg_123',
+                type: 'message',
+                role: 'assistant',
+                model: 'claude-3-sonnet-20240229',
+                content: [{ type: 'text', text: 'Done' }],
+                stop_reason: 'end_turn',
+                stop_sequence: null,
+                usage: { input_tokens: 10, output_tokens: 1 },
+            }));
+
+            expect(response.choices[0]?.stopSequence).toBeUndefined();
+            expect(decode(openai.encodeResponse(response)).choices[0].stop_reason).toBeUndefined();
+
+            const encoded = decode(codec.encodeResponse(response));
+            expect(encoded.stop_reason).toBe('end_turn');
+            expect(encoded.stop_sequence).toBeNull();
+        });
+
+        it('should carry the stop sequence on streamed message_delta events', () => {
+            const event = codec.decodeStreamChunk(JSON.stringify({
+                type: 'message_delta',
+                delta: { stop_reason: 'stop_sequence', stop_sequence: '###' },
+                usage: { output_tokens: 4 },
+            }));
+
+            expect(event?.finishReason).toBe('stop');
+            expect(event?.stopSequence).toBe('###');
+
+            const chunk = JSON.parse(openai.encodeStreamEvent(event!));
+            expect(chunk.choices[0].finish_reason).toBe('stop');
+            expect(chunk.choices[0].stop_reason).toBe('###');
+
+            const back = JSON.parse(codec.encodeStreamEvent(event!));
+            expect(back).toEqual({
+                type: 'message_delta',
+                delta: { stop_reason: 'stop_sequence', stop_sequence: '###' },
+                usage: { output_tokens: 4 },
+            });
+        });
+    });
+
+    describe('end user', () => {
+        const openai = new OpenAICodec();
+        const decode = (bytes: Uint8Array) => JSON.parse(new TextDecoder().decode(bytes));
+
+        it('should forward metadata.user_id as OpenAI user and back', () => {
+            const request = codec.decodeRequest(JSON.stringify({
+                model: 'claude-3-sonnet-20240229',
+                max_tokens: 1024,
+                metadata: { user_id: 'user-1234' },
+                messages: [{ role: 'user', content: 'Hi' }],
+            }));
+            expect(request.endUserId).toBe('user-1234');
+            expect(decode(codec.encodeRequest(request)).metadata).toEqual({ user_id: 'user-1234' });
+
+            const openaiBody = decode(openai.encodeRequest(request));
+            expect(openaiBody.user).toBe('user-1234');
+
+            const back = decode(codec.encodeRequest(openai.decodeRequest(JSON.stringify(openaiBody))));
+            expect(back.metadata).toEqual({ user_id: 'user-1234' });
+        });
+    });
+
+    describe('tool results', () => {
+        const png = 'iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==';
+        const decode = (bytes: Uint8Array) => JSON.parse(new TextDecoder().decode(bytes));
+        const messages = [
+            { role: 'user', content: 'Open the page' },
+            {
+                role: 'assistant',
+                content: [
+                    { type: 'tool_use', id: 'toolu_1', name: 'screenshot', input: {} },
+                    { type: 'tool_use', id: 'toolu_2', name: 'title', input: { tab: 1 } },
+                ],
+            },
+            {
+                role: 'user',
+                content: [
+                    {
+                        type: 'tool_result',
+                        tool_use_id: 'toolu_1',
+                        content: [
+                            { type: 'text', text: 'Screenshot:' },
+                            { type: 'image', source: { type: 'base64', media_type: 'image/png', data: png } },
+                        ],
+                    },
+                    { type: 'tool_result', tool_use_id: 'toolu_2', content: 'Example Domain' },
+                    { type: 'text', text: 'What do you see?' },
+                ],
+            },
+        ];
+
+        it('should decode tool use and tool results, keeping images', () => {
+            const request = codec.decodeRequest(JSON.stringify({ model: 'claude-sonnet-4', max_tokens: 256, messages }));
+
+            expect(request.messages).toEqual([
+                { role: 'user', content: 'Open the page' },
+                {
+                    role: 'assistant',
+                    content: '',
+                    toolCalls: [
+                        { id: 'toolu_1', type: 'function', function: { name: 'screenshot', arguments: '{}' } },
+                        { id: 'toolu_2', type: 'function', function: { name: 'title', arguments: '{"tab":1}' } },
+                    ],
+                },
+                {
+                    role: 'tool',
+                    content: 'Screenshot:',
+                    toolCallId: 'toolu_1',
+                    richContent: {
+                        parts: [
+                            { type: 'text', text: 'Screenshot:' },
+                            { type: 'image', source: { type: 'base64', mediaType: 'image/png', data: png } },
+                        ],
+                    },
+                },
+                { role: 'tool', content: 'Example Domain', toolCallId: 'toolu_2' },
+                { role: 'user', content: 'What do you see?' },
+            ]);
+        });
+
+        it('should encode tool results back into one user turn', () => {
+            const request = codec.decodeRequest(JSON.stringify({ model: 'claude-sonnet-4', max_tokens: 256, messages }));
+
+            const body = decode(codec.encodeRequest(request));
+
+            expect(body.messages).toHaveLength(3);
+            expect(body.messages[2]).toEqual(messages[2]);
+        });
+
+        it('should send OpenAI image URLs inline when they are data URLs', () => {
+            const request = new OpenAICodec().decodeRequest(JSON.stringify({
+                model: 'gpt-4o',
+                messages: [{
+                    role: 'user',
+                    content: [
+                        { type: 'text', text: 'Compare' },
+                        { type: 'image_url', image_url: { url: `data:image/png;base64,${png}` } },
+                        { type: 'image_url', image_url: { url: 'https://example.com/cat.png' } },
+                    ],
+                }],
+            }));
+
+            expect(decode(codec.encodeRequest(request)).messages[0].content).toEqual([
+                { type: 'text', text: 'Compare' },
+                { type: 'image', source: { type: 'base64', media_type: 'image/png', data: png } },
+                { type: 'image', source: { type: 'url', url: 'https://example.com/cat.png' } },
+            ]);
+        });
+    });
 });
@@ -10,26 +10,38 @@ import type {
     CanonicalResponse,
     CanonicalEvent,
     Message,
+    ContentPart,
     ToolCall,
     ToolDefinition,
     Choice,
     Usage,
 } from '../domain/types.js';
+import { getImageParts, getThinkingParts, isInstructionMessage, reasoningEffortToBudget } from '../domain/types.js';
 import {
     APIError,
     toAnthropicError,
     isAPIError,
     errServer,
 } from '../domain/errors.js';
-import type { Codec, StreamMetadata } from './types.js';
-import { toText, toBytes, safeParseJSON } from './types.js';
+import type { Codec, RequestBodyInput, StreamMetadata } from './types.js';
+import { toText, toBytes, safeParseJSON, parseRequestJSON } from './types.js';
+
+/**
+ * max_tokens sent when a request has none, which Anthropic requires. The
+ * frontdoors default it from the model catalog first; this covers models
+ * the catalog doesn't know.
+ */
+const FALLBACK_MAX_TOKENS = 4096;
+
+/** Smallest thinking budget_tokens Anthropic accepts. */
+const MIN_THINKING_BUDGET = 1024;
 
 // ============================================================================
 // Anthropic API Types
 // ============================================================================
 
 /** Anthropic messages request. */
-interface AnthropicRequest {
+export interface AnthropicRequest {
     model: string;
     messages: AnthropicMessage[];
     max_tokens: number;
@@ -41,52 +53,67 @@ interface AnthropicRequest {
     tools?: AnthropicTool[];
     tool_choice?: AnthropicToolChoice;
     metadata?: { user_id?: string };
+    thinking?: AnthropicThinkingConfig;
+}
+
+/** Anthropic extended thinking configuration. */
+export interface AnthropicThinkingConfig {
+    type: 'enabled' | 'disabled';
+    budget_tokens?: number;
 }
 
 /** Anthropic system block. */
-interface AnthropicSystemBlock {
+export interface AnthropicSystemBlock {
     type: 'text';
     text: string;
 }
 
 /** Anthropic message. */
-interface AnthropicMessage {
+export interface AnthropicMessage {
     role: 'user' | 'assistant';
     content: AnthropicContentBlock[];
 }
 
 /** Anthropic content block. */
-interface AnthropicContentBlock {
-    type: 'text' | 'image' | 'tool_use' | 'tool_result';
+export interface AnthropicContentBlock {
+    type: 'text' | 'image' | 'tool_use' | 'tool_result' | 'thinking' | 'redacted_thinking';
     text?: string;
+    thinking?: string;
+    signature?: string;
+    data?: string;
     id?: string;
     name?: string;
     input?: unknown;
     tool_use_id?: string;
-    content?: string;
+    content?: string | AnthropicContentBlock[];
     is_error?: boolean;
-    source?: {
-        type: 'base64';
-        media_type: string;
-        data: string;
-    };
+    source?: AnthropicImageSource;
+}
+
+/** Anthropic image source: inline base64 data or a URL. */
+export interface AnthropicImageSource {
+    type: 'base64' | 'url';
+    media_type?: string;
+    data?: string;
+    url?: string;
 }
 
 /** Anthropic tool definition. */
-interface AnthropicTool {
+export interface AnthropicTool {
     name: string;
     description?: string;
     input_schema: Record<string, unknown>;
 }
 
 /** Anthropic tool choice. */
-interface AnthropicToolChoice {
-    type: 'auto' | 'any' | 'tool';
+export interface AnthropicToolChoice {
+    type: 'auto' | 'any' | 'tool' | 'none';
     name?: string;
+    disable_parallel_tool_use?: boolean;
 }
 
 /** Anthropic messages response. */
-interface AnthropicResponse {
+export interface AnthropicResponse {
     id: string;
     type: 'message';
     role: 'assistant';
@@ -98,16 +125,19 @@ interface AnthropicResponse {
 }
 
 /** Anthropic response content. */
-interface AnthropicResponseContent {
-    type: 'text' | 'tool_use';
+export interface AnthropicResponseContent {
+    type: 'text' | 'tool_use' | 'thinking' | 'redacted_thinking';
     text?: string;
+    thinking?: string;
+    signature?: string;
+    data?: string;
     id?: string;
     name?: string;
     input?: unknown;
 }
 
 /** Anthropic usage. */
-interface AnthropicUsage {
+export interface AnthropicUsage {
     input_tokens: number;
     output_tokens: number;
 }
@@ -128,13 +158,22 @@ interface AnthropicErrorResponse {
 /** Anthropic streaming event types. */
 type AnthropicStreamEvent =
     | { type: 'message_start'; message: AnthropicResponse }
-    | { type: 'message_delta'; delta: { stop_reason?: string }; usage?: AnthropicUsage }
+    | { type: 'message_delta'; delta: { stop_reason?: string; stop_sequence?: string | null }; usage?: AnthropicUsage }
     | { type: 'message_stop' }
     | { type: 'content_block_start'; index: number; content_block: AnthropicResponseContent }
-    | { type: 'content_block_delta'; index: number; delta: { type: string; text?: string } }
+    | { type: 'content_block_delta'; index: number; delta: AnthropicStreamDelta }
     | { type: 'content_block_stop'; index: number }
     | { type: 'ping' };
 
+/** Anthropic content block delta. */
+interface AnthropicStreamDelta {
+    type: 'text_delta' | 'thinking_delta' | 'signature_delta' | 'input_json_delta' | string;
+    text?: string;
+    thinking?: string;
+    signature?: string;
+    partial_json?: string;
+}
+
 // ============================================================================
 // Anthropic Codec
 // ============================================================================
@@ -148,8 +187,8 @@ export class AnthropicCodec implements Codec {
 
     // ---- Request handling ----
 
-    decodeRequest(body: Uint8Array | string): CanonicalRequest {
-        const json = safeParseJSON<AnthropicRequest>(toText(body));
+    decodeRequest(body: RequestBodyInput): CanonicalRequest {
+        const json = parseRequestJSON<AnthropicRequest>(body);
         if (!json) {
             throw new APIError('invalid_request', 'Invalid JSON in request body');
         }
@@ -230,9 +269,12 @@ export class AnthropicCodec implements Codec {
 function apiRequestToCanonical(req: AnthropicRequest): CanonicalRequest {
     const messages: Message[] = [];
 
-    // Add system messages first
-    if (req.system) {
-        for (const sys of req.system) {
+    // Add system messages first (the API accepts a plain string or text blocks)
+    const system = req.system as string | AnthropicSystemBlock[] | undefined;
+    if (typeof system === 'string') {
+        messages.push({ role: 'system', content: system });
+    } else if (system) {
+        for (const sys of system) {
             messages.push({
                 role: 'system',
                 content: sys.text,
@@ -242,11 +284,43 @@ function apiRequestToCanonical(req: AnthropicRequest): CanonicalRequest {
 
     // Add conversation messages
     for (const msg of req.messages) {
-        const content = collapseContentBlocks(msg.content);
-        messages.push({
+        const blocks = toContentBlocks(msg.content as string | AnthropicContentBlock[]);
+
+        if (msg.role === 'user') {
+            // Tool results become tool messages, ahead of anything else the turn says
+            const results = blocks.filter((b) => b.type === 'tool_result');
+            for (const result of results) {
+                messages.push(toolResultToMessage(result));
+            }
+            const rest = blocks.filter((b) => b.type !== 'tool_result');
+            if (rest.length > 0 || results.length === 0) {
+                messages.push(withImages({ role: 'user', content: collapseContentBlocks(rest) }, rest));
+            }
+            continue;
+        }
+
+        const message: Message = {
             role: msg.role as Message['role'],
-            content,
-        });
+            content: collapseContentBlocks(blocks),
+        };
+
+        // Preserve thinking blocks so they can be echoed back on the next turn
+        if (msg.role === 'assistant' && blocks.some(isThinkingBlock)) {
+            message.richContent = { parts: blocksToParts(blocks) };
+        }
+
+        const toolCalls = blocks
+            .filter((b) => b.type === 'tool_use')
+            .map((b): ToolCall => ({
+                id: b.id ?? '',
+                type: 'function',
+                function: { name: b.name ?? '', arguments: JSON.stringify(b.input ?? {}) },
+            }));
+        if (toolCalls.length > 0) {
+            message.toolCalls = toolCalls;
+        }
+
+        messages.push(message);
     }
 
     // Convert tools
@@ -270,6 +344,9 @@ function apiRequestToCanonical(req: AnthropicRequest): CanonicalRequest {
             case 'any':
                 toolChoice = 'required';
                 break;
+            case 'none':
+                toolChoice = 'none';
+                break;
             case 'tool':
                 if (req.tool_choice.name) {
                     toolChoice = { type: 'function', function: { name: req.tool_choice.name } };
@@ -289,10 +366,22 @@ function apiRequestToCanonical(req: AnthropicRequest): CanonicalRequest {
         stop: req.stop_sequences,
         tools,
         toolChoice,
+        parallelToolCalls: req.tool_choice?.disable_parallel_tool_use ? false : undefined,
+        thinking: req.thinking
+            ? { type: req.thinking.type, budgetTokens: req.thinking.budget_tokens }
+            : undefined,
+        endUserId: req.metadata?.user_id,
         sourceAPIType: 'anthropic',
     };
 }
 
+/**
+ * Normalizes message content, which the API accepts as a string shorthand.
+ */
+function toContentBlocks(content: string | AnthropicContentBlock[]): AnthropicContentBlock[] {
+    return typeof content === 'string' ? [{ type: 'text', text: content }] : content;
+}
+
 /**
  * Collapses Anthropic content blocks to a single string.
  */
@@ -303,6 +392,123 @@ function collapseContentBlocks(blocks: AnthropicContentBlock[]): string {
         .join('');
 }
 
+/**
+ * Converts a tool_result block to a tool message. Content given as blocks
+ * keeps its images as rich content.
+ */
+function toolResultToMessage(block: AnthropicContentBlock): Message {
+    const content = block.content ?? '';
+    const message: Message = { role: 'tool', content: '', toolCallId: block.tool_use_id };
+    if (typeof content === 'string') {
+        message.content = content;
+        return message;
+    }
+    message.content = collapseContentBlocks(content);
+    return withImages(message, content);
+}
+
+/**
+ * Keeps a message's text and image blocks, in order, as rich content
+ * when any of them is an image.
+ */
+function withImages(message: Message, blocks: AnthropicContentBlock[]): Message {
+    if (blocks.some((b) => b.type === 'image')) {
+        message.richContent = { parts: blocksToParts(blocks) };
+    }
+    return message;
+}
+
+/**
+ * Converts an image block to a canonical image part.
+ */
+function imageBlockToPart(block: AnthropicContentBlock): ContentPart {
+    const source = block.source;
+    if (source?.type === 'url') {
+        return { type: 'image_url', imageUrl: { url: source.url ?? '' } };
+    }
+    return {
+        type: 'image',
+        source: { type: 'base64', mediaType: source?.media_type ?? '', data: source?.data ?? '' },
+    };
+}
+
+/**
+ * Converts canonical text and image parts to Anthropic blocks. Data URLs
+ * are sent inline; other URLs are left for Anthropic to fetch.
+ */
+function contentPartsToBlocks(parts: ContentPart[]): AnthropicContentBlock[] {
+    const blocks: AnthropicContentBlock[] = [];
+    for (const p of parts) {
+        switch (p.type) {
+            case 'text':
+                blocks.push({ type: 'text', text: p.text ?? '' });
+                break;
+            case 'image':
+                if (p.source) {
+                    blocks.push({
+                        type: 'image',
+                        source: { type: 'base64', media_type: p.source.mediaType, data: p.source.data },
+                    });
+                }
+                break;
+            case 'image_url': {
+                const url = p.imageUrl?.url ?? '';
+                const inline = /^data:([^;,]+);base64,(.*)$/s.exec(url);
+                blocks.push({
+                    type: 'image',
+                    source: inline
+                        ? { type: 'base64', media_type: inline[1], data: inline[2] }
+                        : { type: 'url', url },
+                });
+                break;
+            }
+        }
+    }
+    return blocks;
+}
+
+/**
+ * Returns true for thinking and redacted thinking blocks.
+ */
+function isThinkingBlock(block: { type: string }): boolean {
+    return block.type === 'thinking' || block.type === 'redacted_thinking';
+}
+
+/**
+ * Converts Anthropic text, image and thinking blocks to canonical content parts.
+ */
+function blocksToParts(blocks: Array<AnthropicContentBlock | AnthropicResponseContent>): ContentPart[] {
+    const parts: ContentPart[] = [];
+    for (const b of blocks) {
+        switch (b.type) {
+            case 'text':
+                parts.push({ type: 'text', text: b.text ?? '' });
+                break;
+            case 'thinking':
+                parts.push({ type: 'thinking', thinking: b.thinking ?? '', signature: b.signature });
+                break;
+            case 'redacted_thinking':
+                parts.push({ type: 'redacted_thinking', data: b.data });
+                break;
+            case 'image':
+                parts.push(imageBlockToPart(b));
+                break;
+        }
+    }
+    return parts;
+}
+
+/**
+ * Converts canonical thinking parts to Anthropic blocks.
+ */
+function thinkingPartsToBlocks(parts: ContentPart[]): AnthropicContentBlock[] {
+    return parts.map((p): AnthropicContentBlock => (
+        p.type === 'redacted_thinking'
+            ? { type: 'redacted_thinking', data: p.data }
+            : { type: 'thinking', thinking: p.thinking ?? '', signature: p.signature }
+    ));
+}
+
 /**
  * Converts canonical request to Anthropic API format.
  */
@@ -320,38 +526,44 @@ function canonicalToApiRequest(req: CanonicalRequest): AnthropicRequest {
         system.push({ type: 'text', text: req.instructions });
     }
 
-    // Convert messages
+    // Convert messages (Anthropic has no developer role; its messages are system text too)
     for (const m of req.messages) {
-        if (m.role === 'system') {
+        if (isInstructionMessage(m)) {
             system.push({ type: 'text', text: m.content });
             continue;
         }
 
         let content: AnthropicContentBlock[];
 
-        if (m.role === 'tool') {
-            // Tool results go in user messages
-            content = [
-                {
-                    type: 'tool_result',
-                    tool_use_id: m.toolCallId,
-                    content: m.content,
-                },
-            ];
-            messages.push({ role: 'user', content });
-        } else if (m.role === 'user' && m.toolCallId) {
-            // User message with tool call ID is a tool result
-            content = [
-                {
-                    type: 'tool_result',
-                    tool_use_id: m.toolCallId,
-                    content: m.content,
-                },
-            ];
-            messages.push({ role: 'user', content });
+        const previous = messages[messages.length - 1];
+        const answersTools = previous?.role === 'user' && previous.content.some((b) => b.type === 'tool_result');
+
+        if (m.role === 'tool' || (m.role === 'user' && m.toolCallId)) {
+            // Tool results go in user messages; a user message with a tool
+            // call ID is one too. Results answering the same turn share one.
+            const images = getImageParts(m).length > 0;
+            const result: AnthropicContentBlock = {
+                type: 'tool_result',
+                tool_use_id: m.toolCallId,
+                content: images ? contentPartsToBlocks(m.richContent?.parts ?? []) : m.content,
+            };
+            if (answersTools) {
+                previous.content.push(result);
+            } else {
+                messages.push({ role: 'user', content: [result] });
+            }
+        } else if (m.role === 'user') {
+            content = getImageParts(m).length > 0
+                ? contentPartsToBlocks(m.richContent?.parts ?? [])
+                : [{ type: 'text', text: m.content }];
+            if (answersTools) {
+                previous.content.push(...content);
+            } else {
+                messages.push({ role: 'user', content });
+            }
         } else if (m.role === 'assistant' && m.toolCalls?.length) {
-            // Assistant with tool calls
-            content = [];
+            // Assistant with tool calls (thinking blocks must precede tool_use)
+            content = thinkingPartsToBlocks(getThinkingParts(m));
             if (m.content) {
                 content.push({ type: 'text', text: m.content });
             }
@@ -372,19 +584,16 @@ function canonicalToApiRequest(req: CanonicalRequest): AnthropicRequest {
             }
             messages.push({ role: 'assistant', content });
         } else {
-            // Regular message
-            content = [{ type: 'text', text: m.content }];
-            messages.push({
-                role: m.role as 'user' | 'assistant',
-                content,
-            });
+            // Regular assistant message
+            content = [...thinkingPartsToBlocks(getThinkingParts(m)), { type: 'text', text: m.content }];
+            messages.push({ role: 'assistant', content });
         }
     }
 
     const apiReq: AnthropicRequest = {
         model: req.model,
         messages,
-        max_tokens: req.maxTokens ?? 4096, // Anthropic requires max_tokens
+        max_tokens: req.maxTokens ?? FALLBACK_MAX_TOKENS,
         stream: req.stream,
     };
 
@@ -404,16 +613,42 @@ function canonicalToApiRequest(req: CanonicalRequest): AnthropicRequest {
         apiReq.stop_sequences = req.stop;
     }
 
-    // Convert tool choice
-    if (req.toolChoice) {
-        if (req.toolChoice === 'auto') {
-            apiReq.tool_choice = { type: 'auto' };
-        } else if (req.toolChoice === 'required') {
-            apiReq.tool_choice = { type: 'any' };
-        } else if (typeof req.toolChoice === 'object') {
-            apiReq.tool_choice = { type: 'tool', name: req.toolChoice.function.name };
+    if (req.endUserId !== undefined) {
+        apiReq.metadata = { user_id: req.endUserId };
+    }
+
+    // Extended thinking (map OpenAI reasoning_effort when routed
+    // cross-provider). budget_tokens must be less than max_tokens: a caller's
+    // budget is fitted when the request is planned, leaving only the
+    // fallback max_tokens to make room for; a mapped budget gives way to
+    // max_tokens instead, and is left off under the minimum budget.
+    if (req.thinking) {
+        apiReq.thinking = { type: req.thinking.type, budget_tokens: req.thinking.budgetTokens };
+        const budget = req.thinking.type === 'enabled' ? req.thinking.budgetTokens : undefined;
+        if (budget !== undefined && req.maxTokens === undefined && apiReq.max_tokens <= budget) {
+            apiReq.max_tokens = budget + FALLBACK_MAX_TOKENS;
         }
-        // 'none' - just don't send tools
+    } else if (req.reasoningEffort) {
+        const budget = Math.min(reasoningEffortToBudget(req.reasoningEffort), apiReq.max_tokens - 1);
+        if (budget >= MIN_THINKING_BUDGET) {
+            apiReq.thinking = { type: 'enabled', budget_tokens: budget };
+        }
+    }
+
+    // Convert tool choice
+    if (req.toolChoice === 'auto') {
+        apiReq.tool_choice = { type: 'auto' };
+    } else if (req.toolChoice === 'required') {
+        apiReq.tool_choice = { type: 'any' };
+    } else if (req.toolChoice === 'none') {
+        apiReq.tool_choice = { type: 'none' };
+    } else if (typeof req.toolChoice === 'object') {
+        apiReq.tool_choice = { type: 'tool', name: req.toolChoice.function.name };
+    }
+
+    // Parallel tool use is disabled through tool_choice; "none" has no tools to limit
+    if (req.parallelToolCalls === false && req.tools?.length && apiReq.tool_choice?.type !== 'none') {
+        apiReq.tool_choice = { ...(apiReq.tool_choice ?? { type: 'auto' }), disable_parallel_tool_use: true };
     }
 
     // Convert tools
@@ -434,6 +669,7 @@ function canonicalToApiRequest(req: CanonicalRequest): AnthropicRequest {
 function apiResponseToCanonical(resp: AnthropicResponse): CanonicalResponse {
     let content = '';
     const toolCalls: ToolCall[] = [];
+    const hasThinking = resp.content.some(isThinkingBlock);
 
     for (const c of resp.content) {
         if (c.type === 'text') {
@@ -461,6 +697,7 @@ function apiResponseToCanonical(resp: AnthropicResponse): CanonicalResponse {
         role: 'assistant',
         content,
         toolCalls: toolCalls.length > 0 ? toolCalls : undefined,
+        richContent: hasThinking ? { parts: blocksToParts(resp.content) } : undefined,
     };
 
     return {
@@ -473,6 +710,7 @@ function apiResponseToCanonical(resp: AnthropicResponse): CanonicalResponse {
                 index: 0,
                 message,
                 finishReason,
+                stopSequence: resp.stop_sequence ?? undefined,
             },
         ],
         usage: {
@@ -489,7 +727,9 @@ function apiResponseToCanonical(resp: AnthropicResponse): CanonicalResponse {
  */
 function canonicalToApiResponse(resp: CanonicalResponse): AnthropicResponse {
     const choice = resp.choices[0];
-    const content: AnthropicResponseContent[] = [];
+    const content: AnthropicResponseContent[] = choice
+        ? thinkingPartsToBlocks(getThinkingParts(choice.message)) as AnthropicResponseContent[]
+        : [];
 
     if (choice?.message.content) {
         content.push({ type: 'text', text: choice.message.content });
@@ -523,8 +763,8 @@ function canonicalToApiResponse(resp: CanonicalResponse): AnthropicResponse {
         role: 'assistant',
         model: resp.model,
         content,
-        stop_reason: mapFinishReason(choice?.finishReason ?? null),
-        stop_sequence: null,
+        stop_reason: choice?.stopSequence ? 'stop_sequence' : mapFinishReason(choice?.finishReason ?? null),
+        stop_sequence: choice?.stopSequence ?? null,
         usage: {
             input_tokens: resp.usage.promptTokens,
             output_tokens: resp.usage.completionTokens,
@@ -550,6 +790,29 @@ function streamEventToCanonical(event: AnthropicStreamEvent): CanonicalEvent {
             };
 
         case 'content_block_delta':
+            if (event.delta.type === 'thinking_delta') {
+                return {
+                    type: 'content_block_delta',
+                    thinkingDelta: event.delta.thinking ?? '',
+                    index: event.index,
+                };
+            }
+            if (event.delta.type === 'signature_delta') {
+                return {
+                    type: 'content_block_delta',
+                    signatureDelta: event.delta.signature ?? '',
+                    index: event.index,
+                };
+            }
+            if (event.delta.type === 'input_json_delta') {
+                // Forwarded as-is; the tool call index is the block index
+                // until the provider renumbers it among the tool calls
+                return {
+                    type: 'content_block_delta',
+                    toolCall: { index: event.index, function: { arguments: event.delta.partial_json ?? '' } },
+                    index: event.index,
+                };
+            }
             return {
                 type: 'content_delta',
                 contentDelta: event.delta.text,
@@ -562,6 +825,7 @@ function streamEventToCanonical(event: AnthropicStreamEvent): CanonicalEvent {
                 finishReason: event.delta.stop_reason
                     ? mapStopReason(event.delta.stop_reason) ?? undefined
                     : undefined,
+                stopSequence: event.delta.stop_sequence ?? undefined,
                 usage: event.usage
                     ? {
                         promptTokens: 0,
@@ -575,9 +839,23 @@ function streamEventToCanonical(event: AnthropicStreamEvent): CanonicalEvent {
             return { type: 'message_stop' };
 
         case 'content_block_start':
+            if (event.content_block.type === 'tool_use') {
+                // Arguments follow as input_json_delta events
+                return {
+                    type: 'content_block_start',
+                    index: event.index,
+                    toolCall: {
+                        index: event.index,
+                        id: event.content_block.id,
+                        type: 'function',
+                        function: { name: event.content_block.name, arguments: '' },
+                    },
+                };
+            }
             return {
                 type: 'content_block_start',
                 index: event.index,
+                contentBlock: blocksToParts([event.content_block])[0],
             };
 
         case 'content_block_stop':
@@ -598,6 +876,54 @@ function streamEventToCanonical(event: AnthropicStreamEvent): CanonicalEvent {
  * Converts canonical event to Anthropic streaming event.
  */
 function canonicalToStreamEvent(event: CanonicalEvent): object {
+    if (event.thinkingDelta !== undefined) {
+        return {
+            type: 'content_block_delta',
+            index: event.index ?? 0,
+            delta: { type: 'thinking_delta', thinking: event.thinkingDelta },
+        };
+    }
+
+    if (event.signatureDelta !== undefined) {
+        return {
+            type: 'content_block_delta',
+            index: event.index ?? 0,
+            delta: { type: 'signature_delta', signature: event.signatureDelta },
+        };
+    }
+
+    if (event.type === 'content_block_start' && event.contentBlock) {
+        const block = event.contentBlock;
+        return {
+            type: 'content_block_start',
+            index: event.index ?? 0,
+            content_block: block.type === 'text'
+                ? { type: 'text', text: '' }
+                : thinkingPartsToBlocks([{ ...block, thinking: '' }])[0],
+        };
+    }
+
+    // Tool use blocks decoded from an Anthropic stream
+    if (event.toolCall && (event.type === 'content_block_start' || event.type === 'content_block_delta')) {
+        const tc = event.toolCall;
+        if (event.type === 'content_block_start') {
+            return {
+                type: 'content_block_start',
+                index: event.index ?? 0,
+                content_block: { type: 'tool_use', id: tc.id, name: tc.function?.name ?? '', input: {} },
+            };
+        }
+        return {
+            type: 'content_block_delta',
+            index: event.index ?? 0,
+            delta: { type: 'input_json_delta', partial_json: tc.function?.arguments ?? '' },
+        };
+    }
+
+    if (event.type === 'content_block_stop') {
+        return { type: 'content_block_stop', index: event.index ?? 0 };
+    }
+
     if (event.contentDelta) {
         return {
             type: 'content_block_delta',
@@ -609,6 +935,21 @@ function canonicalToStreamEvent(event: CanonicalEvent): object {
         };
     }
 
+    // Other APIs end on a stop event carrying the finish reason, which
+    // Anthropic sends in message_delta ahead of message_stop
+    if (event.type === 'message_delta' || (event.type === 'message_stop' && event.finishReason)) {
+        return {
+            type: 'message_delta',
+            delta: {
+                stop_reason: event.stopSequence
+                    ? 'stop_sequence'
+                    : mapFinishReason((event.finishReason ?? null) as Choice['finishReason']),
+                stop_sequence: event.stopSequence ?? null,
+            },
+            usage: { output_tokens: event.usage?.completionTokens ?? 0 },
+        };
+    }
+
     if (event.type === 'message_stop' || event.type === 'done') {
         return { type: 'message_stop' };
     }
@@ -0,0 +1,212 @@
+import { describe, it, expect, vi } from 'vitest';
+import { CohereCodec } from './cohere';
+import { Gateway } from '../gateway';
+import { createProviderRegistry } from '../ports/index';
+import type { CanonicalEvent, CanonicalResponse } from '../domain/types';
+
+/** A logger that drops everything, children included. */
+function silentLogger() {
+    const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    logger.child.mockReturnValue(logger);
+    return logger;
+}
+
+const chatRequest = {
+    model: 'command-r',
+    preamble: 'You are terse.',
+    chat_history: [
+        { role: 'USER', message: 'Hi' },
+        { role: 'CHATBOT', message: 'Hello!' },
+    ],
+    message: 'Capital of France?',
+    max_tokens: 64,
+    temperature: 0.3,
+    p: 0.9,
+    stop_sequences: ['\n\n'],
+};
+
+const canonicalResponse: CanonicalResponse = {
+    id: 'resp_1',
+    object: 'chat.completion',
+    created: 1700000000,
+    model: 'gpt-4o',
+    sourceAPIType: 'openai',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'Paris' }, finishReason: 'stop' }],
+    usage: { promptTokens: 12, completionTokens: 1, totalTokens: 13 },
+};
+
+describe('CohereCodec', () => {
+    const codec = new CohereCodec();
+    const json = (bytes: Uint8Array) => JSON.parse(new TextDecoder().decode(bytes));
+
+    it('should decode preamble, chat history and message', () => {
+        const request = codec.decodeRequest(JSON.stringify(chatRequest));
+
+        expect(request).toMatchObject({
+            model: 'command-r',
+            systemPrompt: 'You are terse.',
+            messages: [
+                { role: 'user', content: 'Hi' },
+                { role: 'assistant', content: 'Hello!' },
+                { role: 'user', content: 'Capital of France?' },
+            ],
+            maxTokens: 64,
+            temperature: 0.3,
+            topP: 0.9,
+            stop: ['\n\n'],
+            stream: false,
+            sourceAPIType: 'cohere',
+        });
+    });
+
+    it('should encode a request back to the Cohere shape', () => {
+        const request = codec.decodeRequest(JSON.stringify(chatRequest));
+
+        expect(json(codec.encodeRequest(request))).toEqual(chatRequest);
+    });
+
+    it('should encode a response with finish reason and billed units', () => {
+        expect(json(codec.encodeResponse(canonicalResponse))).toEqual({
+            response_id: 'resp_1',
+            text: 'Paris',
+            generation_id: 'resp_1',
+            finish_reason: 'COMPLETE',
+            meta: { billed_units: { input_tokens: 12, output_tokens: 1 } },
+        });
+    });
+
+    it.each([
+        ['length', undefined, 'MAX_TOKENS'],
+        ['content_filter', undefined, 'ERROR_TOXIC'],
+        ['stop', '\n\n', 'STOP_SEQUENCE'],
+    ] as const)('should map finish reason %s', (finishReason, stopSequence, expected) => {
+        const response = {
+            ...canonicalResponse,
+            choices: [{ ...canonicalResponse.choices[0]!, finishReason, stopSequence }],
+        };
+
+        expect(json(codec.encodeResponse(response)).finish_reason).toBe(expected);
+    });
+
+    it('should decode a response', () => {
+        const response = codec.decodeResponse(JSON.stringify({
+            response_id: 'r1',
+            text: 'Paris',
+            generation_id: 'g1',
+            finish_reason: 'MAX_TOKENS',
+            meta: { billed_units: { input_tokens: 5, output_tokens: 7 } },
+        }));
+
+        expect(response.id).toBe('r1');
+        expect(response.choices[0]).toMatchObject({ message: { content: 'Paris' }, finishReason: 'length' });
+        expect(response.usage).toEqual({ promptTokens: 5, completionTokens: 7, totalTokens: 12 });
+    });
+
+    it('should encode and decode stream events', () => {
+        const start = codec.encodeStreamEvent({ type: 'message_start' }, { id: 'g1' });
+        const text = codec.encodeStreamEvent({ type: 'content_delta', contentDelta: 'Par' });
+        const end = codec.encodeStreamEnd(canonicalResponse);
+
+        expect(JSON.parse(start)).toEqual({ is_finished: false, event_type: 'stream-start', generation_id: 'g1' });
+        expect(JSON.parse(text)).toEqual({ is_finished: false, event_type: 'text-generation', text: 'Par' });
+        expect(JSON.parse(end)).toMatchObject({
+            is_finished: true,
+            event_type: 'stream-end',
+            finish_reason: 'COMPLETE',
+            response: { text: 'Paris' },
+        });
+        expect(codec.encodeStreamEvent({ type: 'message_delta', usage: canonicalResponse.usage })).toBe('');
+
+        expect(codec.decodeStreamChunk(text)).toEqual({ type: 'content_delta', contentDelta: 'Par' });
+        expect(codec.decodeStreamChunk(end)).toMatchObject({
+            type: 'message_stop',
+            finishReason: 'stop',
+            usage: { promptTokens: 12, completionTokens: 1, totalTokens: 13 },
+        });
+    });
+
+    it('should encode errors as a message body', () => {
+        const { body, status } = codec.encodeError(new Error('boom'));
+
+        expect(JSON.parse(body)).toEqual({ message: 'boom' });
+        expect(status).toBe(500);
+    });
+});
+
+describe('Cohere frontdoor', () => {
+    function setup(events: CanonicalEvent[] = []) {
+        const provider = {
+            name: 'openai',
+            apiType: 'openai' as const,
+            complete: vi.fn(async () => canonicalResponse),
+            async *stream() {
+                yield* events;
+            },
+        };
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', () => provider);
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'cohere', frontdoor: 'cohere', path: '/cohere' }],
+                    providers: [{ name: 'openai', type: 'mock', apiKey: '' }],
+                    routing: { defaultProvider: 'openai' },
+                }),
+            },
+            auth: { authenticate: async () => ({ tenantId: 't', scopes: [], metadata: {} }), getTenant: async () => null },
+            providerRegistry,
+            logger: silentLogger() as any,
+        });
+        const send = (body: unknown) => gateway.fetch(new Request('http://localhost/cohere/v1/chat', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            body: JSON.stringify(body),
+        }));
+        return { gateway, provider, send };
+    }
+
+    it('should serve a chat request through the configured provider', async () => {
+        const { gateway, provider, send } = setup();
+        await gateway.reload();
+
+        const response = await send({ ...chatRequest, documents: [{ title: 'a', snippet: 'b' }] });
+
+        expect(response.status).toBe(200);
+        expect(await response.json()).toMatchObject({ text: 'Paris', finish_reason: 'COMPLETE' });
+        expect(provider.complete.mock.calls[0]![0]).toMatchObject({
+            systemPrompt: 'You are terse.',
+            messages: [{ content: 'Hi' }, { content: 'Hello!' }, { content: 'Capital of France?' }],
+        });
+    });
+
+    it('should stream text-generation events and a final stream-end with the full reply', async () => {
+        const { gateway, send } = setup([
+            { type: 'content_delta', contentDelta: 'Pa' },
+            { type: 'content_delta', contentDelta: 'ris' },
+            { type: 'message_stop', finishReason: 'stop', usage: { promptTokens: 3, completionTokens: 2, totalTokens: 5 } },
+            { type: 'done' },
+        ]);
+        await gateway.reload();
+
+        const response = await send({ ...chatRequest, stream: true });
+        const lines = (await response.text()).trim().split('\n').map((line) => JSON.parse(line));
+
+        expect(response.headers.get('Content-Type')).toBe('application/stream+json');
+        expect(lines.map((l) => l.event_type)).toEqual(['stream-start', 'text-generation', 'text-generation', 'stream-end']);
+        expect(lines[3]).toMatchObject({
+            is_finished: true,
+            finish_reason: 'COMPLETE',
+            response: { text: 'Paris', meta: { billed_units: { input_tokens: 3, output_tokens: 2 } } },
+        });
+    });
+
+    it('should reject a request without a message', async () => {
+        const { gateway, send } = setup();
+        await gateway.reload();
+
+        const response = await send({ model: 'command-r' });
+
+        expect(response.status).toBe(400);
+        expect(await response.json()).toEqual({ message: 'message: is required' });
+    });
+});
@@ -0,0 +1,412 @@
+/**
+ * Cohere codec - translates between the Cohere v1 chat API (/v1/chat) and
+ * canonical format.
+ *
+ * Cohere takes the latest user turn as `message`, earlier turns as
+ * `chat_history` (USER/CHATBOT/SYSTEM/TOOL entries), and the system prompt as
+ * `preamble`. Streams are newline-delimited JSON events rather than SSE, and
+ * end with a `stream-end` event carrying the whole reply, which a stateless
+ * event encoder can't produce; see encodeStreamEnd.
+ *
+ * @module codecs/cohere
+ */
+
+import type {
+    APIType,
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    FinishReason,
+    Message,
+    MessageRole,
+    ToolCall,
+    Usage,
+} from '../domain/types.js';
+import { getMessageContent } from '../domain/types.js';
+import {
+    APIError,
+    isAPIError,
+    errServer,
+} from '../domain/errors.js';
+import type { Codec, RequestBodyInput, StreamMetadata } from './types.js';
+import { toText, toBytes, safeParseJSON, parseRequestJSON } from './types.js';
+
+// ============================================================================
+// Cohere API Types
+// ============================================================================
+
+/** Cohere chat history role. */
+export type CohereRole = 'USER' | 'CHATBOT' | 'SYSTEM' | 'TOOL';
+
+/** Cohere chat history entry. */
+export interface CohereMessage {
+    role: CohereRole;
+    message?: string;
+}
+
+/** Cohere chat request. */
+export interface CohereRequest {
+    message: string;
+    model?: string;
+    preamble?: string;
+    chat_history?: CohereMessage[];
+    stream?: boolean;
+    max_tokens?: number;
+    temperature?: number;
+    p?: number;
+    stop_sequences?: string[];
+    documents?: unknown[];
+    connectors?: unknown[];
+}
+
+/** Cohere tool call; Cohere gives calls no IDs. */
+export interface CohereToolCall {
+    name: string;
+    parameters: Record<string, unknown>;
+}
+
+/** Cohere usage, as billed. */
+export interface CohereMeta {
+    billed_units?: {
+        input_tokens?: number;
+        output_tokens?: number;
+    };
+}
+
+/** Cohere chat response. */
+export interface CohereResponse {
+    response_id: string;
+    text: string;
+    generation_id: string;
+    finish_reason: string;
+    tool_calls?: CohereToolCall[];
+    meta?: CohereMeta;
+}
+
+/** Cohere stream event. */
+interface CohereStreamEvent {
+    is_finished: boolean;
+    event_type: string;
+    generation_id?: string;
+    text?: string;
+    finish_reason?: string;
+    response?: CohereResponse;
+    error?: string;
+}
+
+/**
+ * A decoded Cohere request, with the fields the gateway accepts but can't
+ * translate.
+ */
+export interface DecodedCohereRequest {
+    /** Canonical request. */
+    request: CanonicalRequest;
+
+    /** Number of grounding documents sent; they are not passed upstream. */
+    documents: number;
+
+    /** Number of connectors requested; they are not passed upstream. */
+    connectors: number;
+}
+
+// ============================================================================
+// Cohere Codec
+// ============================================================================
+
+/**
+ * Cohere chat codec implementation.
+ */
+export class CohereCodec implements Codec {
+    readonly name = 'cohere';
+    readonly apiType: APIType = 'cohere';
+
+    // ---- Request handling ----
+
+    decodeRequest(body: RequestBodyInput): CanonicalRequest {
+        return decodeCohereRequest(body).request;
+    }
+
+    encodeRequest(request: CanonicalRequest): Uint8Array {
+        // The latest user turn is the message; everything before it is
+        // history. An empty one stays in history, since an empty message
+        // reads as a continuation without a new turn.
+        const history = [...request.messages];
+        const last = history[history.length - 1];
+        const message = last?.role === 'user' && getMessageContent(last) !== '' ? getMessageContent(history.pop()!) : '';
+
+        const apiReq: CohereRequest = {
+            message,
+            model: request.model,
+            preamble: request.systemPrompt,
+            chat_history: history.length > 0
+                ? history.map((m) => ({ role: toCohereRole(m.role), message: getMessageContent(m) }))
+                : undefined,
+            stream: request.stream || undefined,
+            max_tokens: request.maxTokens,
+            temperature: request.temperature,
+            p: request.topP,
+            stop_sequences: request.stop?.length ? request.stop : undefined,
+        };
+        return toBytes(JSON.stringify(apiReq));
+    }
+
+    // ---- Response handling ----
+
+    decodeResponse(body: Uint8Array | string): CanonicalResponse {
+        const json = safeParseJSON<CohereResponse>(toText(body));
+        if (!json) {
+            throw new APIError('server', 'Invalid JSON in response body');
+        }
+        return apiResponseToCanonical(json);
+    }
+
+    encodeResponse(response: CanonicalResponse): Uint8Array {
+        return toBytes(JSON.stringify(canonicalToApiResponse(response)));
+    }
+
+    // ---- Streaming ----
+
+    decodeStreamChunk(chunk: string): CanonicalEvent | null {
+        const json = safeParseJSON<CohereStreamEvent>(chunk);
+        if (!json) return null;
+
+        switch (json.event_type) {
+            case 'stream-start':
+                return { type: 'message_start', role: 'assistant', responseId: json.generation_id };
+            case 'text-generation':
+                return { type: 'content_delta', contentDelta: json.text ?? '' };
+            case 'stream-end':
+                return {
+                    type: 'message_stop',
+                    finishReason: fromCohereFinishReason(json.finish_reason ?? '', !!json.response?.tool_calls?.length)
+                        ?? undefined,
+                    usage: json.response?.meta ? usageToCanonical(json.response.meta) : undefined,
+                };
+            default:
+                return null;
+        }
+    }
+
+    /**
+     * Encodes the start and text events. Other events have no Cohere
+     * equivalent and encode to an empty string; the finish is sent with
+     * encodeStreamEnd once the whole reply is known.
+     */
+    encodeStreamEvent(event: CanonicalEvent, metadata?: StreamMetadata): string {
+        if (event.type === 'message_start') {
+            const start: CohereStreamEvent = {
+                is_finished: false,
+                event_type: 'stream-start',
+                generation_id: metadata?.id ?? event.responseId ?? '',
+            };
+            return JSON.stringify(start);
+        }
+        if (event.contentDelta) {
+            const text: CohereStreamEvent = {
+                is_finished: false,
+                event_type: 'text-generation',
+                text: event.contentDelta,
+            };
+            return JSON.stringify(text);
+        }
+        return '';
+    }
+
+    /**
+     * Encodes the final stream-end event from the accumulated reply. When
+     * the stream failed, the event reports an ERROR finish and the message.
+     */
+    encodeStreamEnd(response: CanonicalResponse, error?: Error): string {
+        const full = canonicalToApiResponse(response);
+        if (error) {
+            full.finish_reason = 'ERROR';
+        }
+        const end: CohereStreamEvent = {
+            is_finished: true,
+            event_type: 'stream-end',
+            finish_reason: full.finish_reason,
+            response: full,
+            error: error?.message,
+        };
+        return JSON.stringify(end);
+    }
+
+    // ---- Errors ----
+
+    decodeError(body: Uint8Array | string, status: number): Error {
+        const json = safeParseJSON<{ message?: string }>(toText(body));
+        if (!json?.message) {
+            return errServer('Unknown error').withStatusCode(status);
+        }
+        return new APIError(status >= 500 ? 'server' : 'invalid_request', json.message, {
+            statusCode: status,
+            sourceAPI: 'cohere',
+        });
+    }
+
+    encodeError(error: Error): { body: string; status: number } {
+        const apiError = isAPIError(error) ? error : errServer(error.message);
+        return {
+            body: JSON.stringify({ message: apiError.message }),
+            status: apiError.statusCode,
+        };
+    }
+}
+
+// ============================================================================
+// Conversion Functions
+// ============================================================================
+
+/**
+ * Decodes a Cohere chat request body. Documents and connectors are counted
+ * so the frontdoor can record that they were dropped.
+ */
+export function decodeCohereRequest(body: RequestBodyInput): DecodedCohereRequest {
+    const req = parseRequestJSON<CohereRequest>(body);
+    if (!req || typeof req !== 'object') {
+        throw new APIError('invalid_request', 'Invalid JSON in request body');
+    }
+
+    const messages: Message[] = (req.chat_history ?? []).map((entry) => ({
+        role: fromCohereRole(entry.role),
+        content: entry.message ?? '',
+    }));
+    // An empty message continues the conversation without a new user turn
+    if (req.message) {
+        messages.push({ role: 'user', content: req.message });
+    }
+
+    return {
+        request: {
+            tenantId: '', // Set by gateway
+            model: req.model ?? '',
+            messages,
+            stream: req.stream ?? false,
+            maxTokens: req.max_tokens,
+            temperature: req.temperature,
+            topP: req.p,
+            stop: req.stop_sequences,
+            systemPrompt: req.preamble,
+            sourceAPIType: 'cohere',
+        },
+        documents: req.documents?.length ?? 0,
+        connectors: req.connectors?.length ?? 0,
+    };
+}
+
+function apiResponseToCanonical(json: CohereResponse): CanonicalResponse {
+    const toolCalls = json.tool_calls?.map((call, i): ToolCall => ({
+        id: `call_${i}`,
+        type: 'function',
+        function: { name: call.name, arguments: JSON.stringify(call.parameters ?? {}) },
+    }));
+
+    return {
+        id: json.response_id || json.generation_id,
+        object: 'chat.completion',
+        created: Math.floor(Date.now() / 1000),
+        model: '',
+        choices: [{
+            index: 0,
+            message: { role: 'assistant', content: json.text ?? '', toolCalls: toolCalls?.length ? toolCalls : undefined },
+            finishReason: fromCohereFinishReason(json.finish_reason, !!toolCalls?.length),
+        }],
+        usage: json.meta ? usageToCanonical(json.meta) : { promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+        sourceAPIType: 'cohere',
+    };
+}
+
+/**
+ * Converts the first choice of a canonical response to a Cohere response.
+ * Cohere has a single reply per request.
+ */
+function canonicalToApiResponse(response: CanonicalResponse): CohereResponse {
+    const choice = response.choices[0];
+    const toolCalls = choice?.message.toolCalls?.map((call): CohereToolCall => ({
+        name: call.function.name,
+        parameters: safeParseJSON<Record<string, unknown>>(call.function.arguments) ?? {},
+    }));
+
+    return {
+        response_id: response.id,
+        text: choice ? getMessageContent(choice.message) : '',
+        generation_id: response.id,
+        finish_reason: toCohereFinishReason(choice?.finishReason ?? null, choice?.stopSequence),
+        tool_calls: toolCalls?.length ? toolCalls : undefined,
+        meta: {
+            billed_units: {
+                input_tokens: response.usage.promptTokens,
+                output_tokens: response.usage.completionTokens,
+            },
+        },
+    };
+}
+
+function toCohereRole(role: MessageRole): CohereRole {
+    switch (role) {
+        case 'system':
+        case 'developer':
+            return 'SYSTEM';
+        case 'assistant':
+            return 'CHATBOT';
+        case 'tool':
+            return 'TOOL';
+        default:
+            return 'USER';
+    }
+}
+
+function fromCohereRole(role: CohereRole): MessageRole {
+    switch (role) {
+        case 'SYSTEM':
+            return 'system';
+        case 'CHATBOT':
+            return 'assistant';
+        case 'TOOL':
+            return 'tool';
+        default:
+            return 'user';
+    }
+}
+
+/**
+ * Maps a canonical finish reason to Cohere's (COMPLETE, STOP_SEQUENCE,
+ * MAX_TOKENS, ERROR_TOXIC).
+ */
+function toCohereFinishReason(reason: FinishReason | null, stopSequence?: string): string {
+    switch (reason) {
+        case 'length':
+            return 'MAX_TOKENS';
+        case 'content_filter':
+            return 'ERROR_TOXIC';
+        default:
+            return stopSequence ? 'STOP_SEQUENCE' : 'COMPLETE';
+    }
+}
+
+function fromCohereFinishReason(reason: string, hasToolCalls: boolean): FinishReason | null {
+    switch (reason) {
+        case 'COMPLETE':
+        case 'STOP_SEQUENCE':
+            return hasToolCalls ? 'tool_calls' : 'stop';
+        case 'MAX_TOKENS':
+            return 'length';
+        case 'ERROR_TOXIC':
+            return 'content_filter';
+        default:
+            return null;
+    }
+}
+
+function usageToCanonical(meta: CohereMeta): Usage {
+    const promptTokens = meta.billed_units?.input_tokens ?? 0;
+    const completionTokens = meta.billed_units?.output_tokens ?? 0;
+    return {
+        promptTokens,
+        completionTokens,
+        totalTokens: promptTokens + completionTokens,
+    };
+}
+
+// Export singleton instance
+export const cohereCodec = new CohereCodec();
@@ -0,0 +1,375 @@
+/**
+ * Legacy completions codec - translates between the OpenAI text completions
+ * API (/v1/completions) and canonical format.
+ *
+ * The legacy API takes a bare `prompt` instead of messages. A prompt is
+ * decoded as a single user message so it can be served by any chat provider.
+ *
+ * @module codecs/completions
+ */
+
+import type {
+    APIType,
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Choice,
+    FinishReason,
+    Usage,
+} from '../domain/types.js';
+import { getMessageContent } from '../domain/types.js';
+import {
+    APIError,
+    toOpenAIError,
+    isAPIError,
+    errServer,
+} from '../domain/errors.js';
+import type { Codec, RequestBodyInput, StreamMetadata } from './types.js';
+import { toText, toBytes, safeParseJSON, parseRequestJSON } from './types.js';
+import { invalidField } from './validation.js';
+
+// ============================================================================
+// Legacy Completions API Types
+// ============================================================================
+
+/** Legacy text completion request. */
+export interface CompletionsRequest {
+    model: string;
+    prompt?: string | string[] | number[] | number[][];
+    suffix?: string;
+    max_tokens?: number;
+    temperature?: number;
+    top_p?: number;
+    frequency_penalty?: number;
+    presence_penalty?: number;
+    n?: number;
+    stream?: boolean;
+    logprobs?: number | null;
+    echo?: boolean;
+    stop?: string | string[];
+    best_of?: number;
+    user?: string;
+}
+
+/** Legacy text completion response. */
+export interface CompletionsResponse {
+    id: string;
+    object: string;
+    created: number;
+    model: string;
+    choices: CompletionsChoice[];
+    usage?: CompletionsUsage;
+    system_fingerprint?: string;
+    warning?: string;
+}
+
+/** Legacy completion choice, also used for streaming chunks. */
+export interface CompletionsChoice {
+    text: string;
+    index: number;
+    logprobs: null;
+    finish_reason: string | null;
+}
+
+/** Legacy usage. */
+export interface CompletionsUsage {
+    prompt_tokens: number;
+    completion_tokens: number;
+    total_tokens: number;
+}
+
+/**
+ * A decoded legacy completions request: one canonical request per prompt,
+ * plus the legacy-only fields the frontdoor applies itself.
+ */
+export interface DecodedCompletionsRequest {
+    /** Canonical requests, one per prompt, each with a single user message. */
+    requests: CanonicalRequest[];
+
+    /** Prompts, in order. */
+    prompts: string[];
+
+    /** Whether to prepend each prompt to its completions. */
+    echo: boolean;
+
+    /** Text after the completion (insertion); chat models can't honor it. */
+    suffix?: string | undefined;
+
+    /** Requested number of logprobs; chat providers don't return them. */
+    logprobs?: number | undefined;
+
+    /** Requested server-side candidates; can't be ranked without logprobs. */
+    bestOf?: number | undefined;
+}
+
+// ============================================================================
+// Completions Codec
+// ============================================================================
+
+/**
+ * Legacy completions codec implementation.
+ */
+export class CompletionsCodec implements Codec {
+    readonly name = 'completions';
+    readonly apiType: APIType = 'completions';
+
+    // ---- Request handling ----
+
+    /**
+     * Decodes a single-prompt request. Use decodeCompletionsRequest for
+     * array prompts.
+     */
+    decodeRequest(body: RequestBodyInput): CanonicalRequest {
+        const decoded = decodeCompletionsRequest(body);
+        if (decoded.requests.length !== 1) {
+            throw invalidField('prompt', 'must be a single prompt');
+        }
+        return decoded.requests[0]!;
+    }
+
+    encodeRequest(request: CanonicalRequest): Uint8Array {
+        // Flatten the conversation into a single document
+        const sections = request.messages.map((m) => getMessageContent(m));
+        if (request.systemPrompt) {
+            sections.unshift(request.systemPrompt);
+        }
+
+        const apiReq: CompletionsRequest = {
+            model: request.model,
+            prompt: sections.join('\n\n'),
+            stream: request.stream || undefined,
+            max_tokens: request.maxTokens,
+            temperature: request.temperature,
+            top_p: request.topP,
+            frequency_penalty: request.frequencyPenalty,
+            presence_penalty: request.presencePenalty,
+            n: request.n !== undefined && request.n > 1 ? request.n : undefined,
+            stop: request.stop?.length ? request.stop : undefined,
+        };
+        return toBytes(JSON.stringify(apiReq));
+    }
+
+    // ---- Response handling ----
+
+    decodeResponse(body: Uint8Array | string): CanonicalResponse {
+        const json = safeParseJSON<CompletionsResponse>(toText(body));
+        if (!json) {
+            throw new APIError('server', 'Invalid JSON in response body');
+        }
+        return {
+            id: json.id,
+            object: json.object,
+            created: json.created,
+            model: json.model,
+            choices: json.choices.map((c): Choice => ({
+                index: c.index,
+                message: { role: 'assistant', content: c.text },
+                finishReason: c.finish_reason as FinishReason | null,
+            })),
+            usage: json.usage ? usageToCanonical(json.usage) : { promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+            sourceAPIType: 'completions',
+            systemFingerprint: json.system_fingerprint,
+        };
+    }
+
+    encodeResponse(response: CanonicalResponse): Uint8Array {
+        const apiResp: CompletionsResponse = {
+            id: toCompletionId(response.id),
+            object: 'text_completion',
+            created: response.created,
+            model: response.model,
+            choices: response.choices.map((c): CompletionsChoice => ({
+                text: getMessageContent(c.message),
+                index: c.index,
+                logprobs: null,
+                finish_reason: toLegacyFinishReason(c.finishReason),
+            })),
+            usage: usageToApi(response.usage),
+            system_fingerprint: response.systemFingerprint,
+        };
+        return toBytes(JSON.stringify(apiResp));
+    }
+
+    // ---- Streaming ----
+
+    decodeStreamChunk(chunk: string): CanonicalEvent | null {
+        if (chunk.trim() === '[DONE]') {
+            return { type: 'done' };
+        }
+
+        const json = safeParseJSON<CompletionsResponse>(chunk);
+        if (!json) return null;
+
+        const choice = json.choices[0];
+        const event: CanonicalEvent = {
+            type: choice?.finish_reason ? 'message_stop' : 'content_delta',
+            responseId: json.id,
+            model: json.model,
+            choiceIndex: choice?.index,
+            contentDelta: choice?.text || undefined,
+            finishReason: choice?.finish_reason ?? undefined,
+        };
+        if (json.usage) {
+            event.usage = usageToCanonical(json.usage);
+        }
+        return event;
+    }
+
+    encodeStreamEvent(event: CanonicalEvent, metadata?: StreamMetadata): string {
+        const chunk: CompletionsResponse = {
+            id: toCompletionId(metadata?.id ?? event.responseId ?? ''),
+            object: 'text_completion',
+            created: metadata?.created ?? Math.floor(Date.now() / 1000),
+            model: metadata?.model ?? event.model ?? '',
+            choices: [],
+            system_fingerprint: metadata?.systemFingerprint,
+        };
+
+        // A usage-only event is sent as a chunk with no choices
+        if (event.contentDelta !== undefined || event.finishReason !== undefined) {
+            chunk.choices.push({
+                text: event.contentDelta ?? '',
+                index: event.choiceIndex ?? 0,
+                logprobs: null,
+                finish_reason: toLegacyFinishReason(event.finishReason ?? null),
+            });
+        }
+        if (event.usage) {
+            chunk.usage = usageToApi(event.usage);
+        }
+        if (event.warning) {
+            chunk.warning = event.warning;
+        }
+        return JSON.stringify(chunk);
+    }
+
+    // ---- Errors ----
+
+    decodeError(body: Uint8Array | string, status: number): Error {
+        const json = safeParseJSON<{ error?: { message: string; param?: string | null } }>(toText(body));
+        if (!json?.error) {
+            return errServer('Unknown error').withStatusCode(status);
+        }
+        return new APIError(status >= 500 ? 'server' : 'invalid_request', json.error.message, {
+            param: json.error.param ?? undefined,
+            statusCode: status,
+            sourceAPI: 'completions',
+        });
+    }
+
+    encodeError(error: Error): { body: string; status: number } {
+        const apiError = isAPIError(error) ? error : errServer(error.message);
+        return {
+            body: JSON.stringify(toOpenAIError(apiError)),
+            status: apiError.statusCode,
+        };
+    }
+}
+
+// ============================================================================
+// Conversion Functions
+// ============================================================================
+
+/**
+ * Decodes a legacy completions request body. A string prompt yields one
+ * canonical request; an array prompt yields one per element.
+ */
+export function decodeCompletionsRequest(body: RequestBodyInput): DecodedCompletionsRequest {
+    const req = parseRequestJSON<CompletionsRequest>(body);
+    if (!req || typeof req !== 'object') {
+        throw new APIError('invalid_request', 'Invalid JSON in request body');
+    }
+
+    const prompts = decodePrompt(req.prompt);
+    const stop = req.stop
+        ? Array.isArray(req.stop)
+            ? req.stop
+            : [req.stop]
+        : undefined;
+
+    const requests = prompts.map((prompt): CanonicalRequest => ({
+        tenantId: '', // Set by gateway
+        model: req.model,
+        messages: [{ role: 'user', content: prompt }],
+        stream: req.stream ?? false,
+        maxTokens: req.max_tokens,
+        temperature: req.temperature,
+        topP: req.top_p,
+        frequencyPenalty: req.frequency_penalty,
+        presencePenalty: req.presence_penalty,
+        n: req.n,
+        stop,
+        sourceAPIType: 'completions',
+    }));
+
+    return {
+        requests,
+        prompts,
+        echo: req.echo ?? false,
+        suffix: req.suffix ?? undefined,
+        logprobs: req.logprobs ?? undefined,
+        bestOf: req.best_of ?? undefined,
+    };
+}
+
+/**
+ * Normalizes a prompt to a list of strings. Token-ID prompts are rejected:
+ * chat providers only accept text.
+ */
+function decodePrompt(prompt: CompletionsRequest['prompt']): string[] {
+    if (prompt === undefined || prompt === null) {
+        // The legacy API defaults to <|endoftext|>, i.e. an empty document
+        return [''];
+    }
+    if (typeof prompt === 'string') {
+        return [prompt];
+    }
+    if (Array.isArray(prompt)) {
+        if (prompt.length === 0) {
+            throw invalidField('prompt', 'must not be empty');
+        }
+        return prompt.map((item, i) => {
+            if (typeof item !== 'string') {
+                throw invalidField(`prompt[${i}]`, 'token ID prompts are not supported; send text');
+            }
+            return item;
+        });
+    }
+    throw invalidField('prompt', 'must be a string or an array of strings');
+}
+
+/**
+ * Maps a canonical finish reason to the legacy values (stop, length,
+ * content_filter).
+ */
+function toLegacyFinishReason(reason: string | null): string | null {
+    if (reason === null) return null;
+    return reason === 'length' || reason === 'content_filter' ? reason : 'stop';
+}
+
+/**
+ * Gives a response ID the legacy `cmpl-` prefix.
+ */
+function toCompletionId(id: string): string {
+    if (!id || id.startsWith('cmpl-')) return id;
+    return `cmpl-${id.replace(/^chatcmpl-/, '')}`;
+}
+
+function usageToCanonical(usage: CompletionsUsage): Usage {
+    return {
+        promptTokens: usage.prompt_tokens,
+        completionTokens: usage.completion_tokens,
+        totalTokens: usage.total_tokens,
+    };
+}
+
+function usageToApi(usage: Usage): CompletionsUsage {
+    return {
+        prompt_tokens: usage.promptTokens,
+        completion_tokens: usage.completionTokens,
+        total_tokens: usage.totalTokens,
+    };
+}
+
+// Export singleton instance
+export const completionsCodec = new CompletionsCodec();
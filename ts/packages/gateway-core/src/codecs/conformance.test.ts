@@ -0,0 +1,498 @@
+/**
+ * Codec conformance suite.
+ *
+ * Round-trips a corpus of canonical fixtures through every registered codec
+ * (encode, then decode) and across every pair of codecs, and fails on any
+ * difference that isn't in the codec's lossy allowlist. Adding a codec to
+ * defaultCodecRegistry enrolls it automatically; it must then either
+ * preserve every fixture or document each loss below.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { defaultCodecRegistry } from './index';
+import type { Codec } from './types';
+import { CANONICAL_REQUEST_SCHEMA, CANONICAL_RESPONSE_SCHEMA } from '../domain/schema';
+import { parseToolChoice, type CanonicalRequest, type CanonicalResponse, type ToolChoice } from '../domain/types';
+
+// ============================================================================
+// Fixtures
+// ============================================================================
+
+const weatherTool = {
+    name: 'get_weather',
+    type: 'function' as const,
+    function: {
+        name: 'get_weather',
+        description: 'Get the current weather',
+        parameters: {
+            type: 'object',
+            properties: { city: { type: 'string' } },
+            required: ['city'],
+        },
+    },
+};
+
+const weatherCall = {
+    id: 'call_1',
+    type: 'function' as const,
+    function: { name: 'get_weather', arguments: '{"city":"Paris"}' },
+};
+
+const screenshotCall = {
+    id: 'call_2',
+    type: 'function' as const,
+    function: { name: 'screenshot', arguments: '{}' },
+};
+
+/** A 1x1 transparent PNG. */
+const PIXEL_PNG = 'iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==';
+
+const base = {
+    tenantId: '',
+    model: 'test-model',
+    stream: false,
+    maxTokens: 256,
+    sourceAPIType: 'openai' as const,
+};
+
+const requestFixtures: Record<string, CanonicalRequest> = {
+    'text': {
+        ...base,
+        messages: [{ role: 'user', content: 'Hello' }],
+        temperature: 0.5,
+        topP: 0.9,
+    },
+    'system-prompt': {
+        ...base,
+        systemPrompt: 'Answer in one word.',
+        messages: [{ role: 'user', content: 'Capital of France?' }],
+    },
+    'tools': {
+        ...base,
+        messages: [{ role: 'user', content: 'Weather in Paris?' }],
+        tools: [weatherTool],
+        toolChoice: { type: 'function', function: { name: 'get_weather' } },
+    },
+    'sequential-tools': {
+        ...base,
+        messages: [{ role: 'user', content: 'Weather in Paris and Rome?' }],
+        tools: [weatherTool],
+        toolChoice: 'required',
+        parallelToolCalls: false,
+    },
+    'tool-results': {
+        ...base,
+        messages: [
+            { role: 'user', content: 'Weather in Paris?' },
+            { role: 'assistant', content: '', toolCalls: [weatherCall] },
+            { role: 'tool', content: '18C and sunny', toolCallId: 'call_1' },
+        ],
+        tools: [weatherTool],
+    },
+    'tool-result-image': {
+        ...base,
+        messages: [
+            { role: 'user', content: 'Take a screenshot' },
+            { role: 'assistant', content: '', toolCalls: [screenshotCall] },
+            {
+                role: 'tool',
+                content: 'Captured:',
+                toolCallId: 'call_2',
+                richContent: {
+                    parts: [
+                        { type: 'text', text: 'Captured:' },
+                        { type: 'image', source: { type: 'base64', mediaType: 'image/png', data: PIXEL_PNG } },
+                    ],
+                },
+            },
+        ],
+    },
+    'images': {
+        ...base,
+        messages: [{
+            role: 'user',
+            content: 'What is in this picture?',
+            richContent: {
+                parts: [
+                    { type: 'text', text: 'What is in this picture?' },
+                    { type: 'image_url', imageUrl: { url: 'https://example.com/cat.png' } },
+                ],
+            },
+        }],
+    },
+    'developer-message': {
+        ...base,
+        messages: [
+            { role: 'developer', content: 'Answer in French.' },
+            { role: 'user', content: 'Capital of Italy?' },
+        ],
+    },
+    'prefill': {
+        ...base,
+        messages: [
+            { role: 'user', content: 'List three colors as JSON.' },
+            { role: 'assistant', content: '{"colors": [' },
+        ],
+    },
+    'stop-sequences': {
+        ...base,
+        stream: true,
+        messages: [{ role: 'user', content: 'Count to ten' }],
+        stop: ['5', 'five'],
+    },
+};
+
+const responseBase = {
+    id: 'resp_1',
+    object: 'chat.completion',
+    created: 1700000000,
+    model: 'test-model',
+    sourceAPIType: 'openai' as const,
+};
+
+const responseFixtures: Record<string, CanonicalResponse> = {
+    'text': {
+        ...responseBase,
+        choices: [{ index: 0, message: { role: 'assistant', content: 'Paris' }, finishReason: 'stop' }],
+        usage: { promptTokens: 10, completionTokens: 1, totalTokens: 11 },
+    },
+    'tool-calls': {
+        ...responseBase,
+        choices: [{
+            index: 0,
+            message: { role: 'assistant', content: '', toolCalls: [weatherCall] },
+            finishReason: 'tool_calls',
+        }],
+        usage: { promptTokens: 20, completionTokens: 8, totalTokens: 28 },
+    },
+    'stop-sequence': {
+        ...responseBase,
+        choices: [{ index: 0, message: { role: 'assistant', content: '1 2 3 ' }, finishReason: 'stop', stopSequence: 'five' }],
+        usage: { promptTokens: 12, completionTokens: 6, totalTokens: 18 },
+    },
+    'usage': {
+        ...responseBase,
+        choices: [{ index: 0, message: { role: 'assistant', content: 'Let me think' }, finishReason: 'length' }],
+        usage: { promptTokens: 30, completionTokens: 16, totalTokens: 46, reasoningTokens: 12 },
+    },
+};
+
+// ============================================================================
+// Lossy Allowlist
+// ============================================================================
+
+/**
+ * A documented loss. Paths use `[]` for any array index and cover their
+ * descendants.
+ */
+interface AllowedLoss {
+    paths: string[];
+    reason: string;
+}
+
+const requestLosses: Record<string, AllowedLoss[]> = {
+    openai: [
+        {
+            paths: ['messages[].richContent'],
+            reason: 'Tool messages carry text only; the gateway moves tool result images to a user message first',
+        },
+    ],
+    anthropic: [
+        { paths: ['messages[].role'], reason: 'There is no developer role; developer messages are sent as system text' },
+    ],
+    completions: [
+        {
+            paths: ['messages', 'tools', 'toolChoice', 'parallelToolCalls', 'responseFormat', 'thinking', 'reasoningEffort', 'metadata'],
+            reason: 'The conversation is flattened into a single text prompt with no tools or structured options',
+        },
+    ],
+    cohere: [
+        {
+            paths: ['tools', 'toolChoice', 'parallelToolCalls', 'responseFormat', 'thinking', 'reasoningEffort', 'metadata'],
+            reason: 'Chat requests are translated without tool definitions or structured options',
+        },
+        {
+            paths: ['messages[].toolCalls', 'messages[].toolCallId', 'messages[].richContent'],
+            reason: 'Chat history entries carry text only',
+        },
+        { paths: ['messages[].role'], reason: 'There is no developer role; developer messages are sent as SYSTEM entries' },
+    ],
+};
+
+const responseLosses: Record<string, AllowedLoss[]> = {
+    openai: [],
+    anthropic: [
+        { paths: ['created', 'object'], reason: 'Messages responses carry no timestamp or object type' },
+        { paths: ['usage.reasoningTokens'], reason: 'Thinking tokens are not reported separately' },
+    ],
+    completions: [
+        { paths: ['id', 'object'], reason: 'Legacy responses use cmpl- IDs and the text_completion object' },
+        {
+            paths: ['choices[].message.toolCalls', 'choices[].finishReason'],
+            reason: 'Legacy completions have no tool calls',
+        },
+        { paths: ['choices[].stopSequence'], reason: 'Legacy completions do not report the matched stop sequence' },
+        { paths: ['usage.reasoningTokens'], reason: 'Legacy usage has no token details' },
+    ],
+    cohere: [
+        { paths: ['created', 'object', 'model'], reason: 'Chat responses carry no timestamp, object type or model' },
+        { paths: ['choices[].message.toolCalls'], reason: 'Tool calls have no IDs' },
+        { paths: ['choices[].stopSequence'], reason: 'STOP_SEQUENCE does not say which sequence matched' },
+        { paths: ['usage.reasoningTokens'], reason: 'Billed units have no token details' },
+    ],
+};
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/** Fields set by the gateway or identifying the source API, not the content. */
+const REQUEST_ENVELOPE = ['tenantId', 'sourceAPIType', 'rawRequest', 'userAgent', 'upstreamHeaders'];
+const RESPONSE_ENVELOPE = ['sourceAPIType', 'rawResponse', 'providerRequestBody'];
+
+/**
+ * Drops undefined fields and the envelope, and lifts a system prompt into a
+ * leading system message so both representations compare equal.
+ */
+function normalizeRequest(request: CanonicalRequest): Record<string, any> {
+    const json = JSON.parse(JSON.stringify(request));
+    for (const key of REQUEST_ENVELOPE) delete json[key];
+    if (json.systemPrompt) {
+        json.messages = [{ role: 'system', content: json.systemPrompt }, ...json.messages];
+        delete json.systemPrompt;
+    }
+    return json;
+}
+
+function normalizeResponse(response: CanonicalResponse): Record<string, any> {
+    const json = JSON.parse(JSON.stringify(response));
+    for (const key of RESPONSE_ENVELOPE) delete json[key];
+    return json;
+}
+
+/**
+ * Returns the paths at which two JSON values differ.
+ */
+function diffPaths(a: unknown, b: unknown, path = ''): string[] {
+    if (Array.isArray(a) && Array.isArray(b)) {
+        if (a.length !== b.length) return [path];
+        return a.flatMap((item, i) => diffPaths(item, b[i], `${path}[${i}]`));
+    }
+    if (a && b && typeof a === 'object' && typeof b === 'object' && !Array.isArray(a) && !Array.isArray(b)) {
+        const keys = new Set([...Object.keys(a), ...Object.keys(b)]);
+        return [...keys].flatMap((key) => diffPaths(
+            (a as Record<string, unknown>)[key],
+            (b as Record<string, unknown>)[key],
+            path ? `${path}.${key}` : key,
+        ));
+    }
+    return a === b ? [] : [path];
+}
+
+function isAllowed(path: string, losses: AllowedLoss[]): boolean {
+    const general = path.replace(/\[\d+\]/g, '[]');
+    return losses.some((loss) => loss.paths.some((p) =>
+        general === p || general.startsWith(`${p}.`) || general.startsWith(`${p}[`)));
+}
+
+function unexpectedLosses(before: unknown, after: unknown, losses: AllowedLoss[]): string[] {
+    return diffPaths(before, after).filter((path) => !isAllowed(path, losses));
+}
+
+function roundTripRequest(codec: Codec, request: CanonicalRequest): CanonicalRequest {
+    return codec.decodeRequest(codec.encodeRequest(request));
+}
+
+function roundTripResponse(codec: Codec, response: CanonicalResponse): CanonicalResponse {
+    return codec.decodeResponse(codec.encodeResponse(response));
+}
+
+const codecs = defaultCodecRegistry.list().map((apiType) => defaultCodecRegistry.get(apiType)!);
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+describe('codec conformance', () => {
+    it('should document losses for every registered codec', () => {
+        for (const codec of codecs) {
+            expect(requestLosses, codec.name).toHaveProperty(codec.name);
+            expect(responseLosses, codec.name).toHaveProperty(codec.name);
+        }
+    });
+
+    describe.each(codecs.map((codec) => [codec.name, codec] as const))('%s', (name, codec) => {
+        it.each(Object.entries(requestFixtures))('should round-trip the %s request', (_, fixture) => {
+            const decoded = roundTripRequest(codec, fixture);
+
+            expect(decoded.sourceAPIType).toBe(codec.apiType);
+            expect(unexpectedLosses(normalizeRequest(fixture), normalizeRequest(decoded), requestLosses[name]!))
+                .toEqual([]);
+        });
+
+        it.each(Object.entries(responseFixtures))('should round-trip the %s response', (_, fixture) => {
+            const decoded = roundTripResponse(codec, fixture);
+
+            expect(unexpectedLosses(normalizeResponse(fixture), normalizeResponse(decoded), responseLosses[name]!))
+                .toEqual([]);
+        });
+    });
+
+    describe('cross-codec matrix', () => {
+        const pairs = codecs.flatMap((from) => codecs.map((to) => [from.name, to.name, from, to] as const));
+
+        it.each(pairs)('should translate %s requests through %s', (_, toName, from, to) => {
+            for (const fixture of Object.values(requestFixtures)) {
+                const source = roundTripRequest(from, fixture);
+                const translated = roundTripRequest(to, source);
+
+                expect(unexpectedLosses(normalizeRequest(source), normalizeRequest(translated), requestLosses[toName]!))
+                    .toEqual([]);
+            }
+        });
+
+        it.each(pairs)('should translate %s responses through %s', (_, toName, from, to) => {
+            for (const fixture of Object.values(responseFixtures)) {
+                const source = roundTripResponse(from, fixture);
+                const translated = roundTripResponse(to, source);
+
+                expect(unexpectedLosses(normalizeResponse(source), normalizeResponse(translated), responseLosses[toName]!))
+                    .toEqual([]);
+            }
+        });
+    });
+
+    describe('tool choice', () => {
+        const openai = defaultCodecRegistry.get('openai')!;
+        const anthropic = defaultCodecRegistry.get('anthropic')!;
+        const json = (bytes: Uint8Array) => JSON.parse(new TextDecoder().decode(bytes));
+        const messages = [{ role: 'user', content: 'Weather in Paris?' }];
+        const openaiTools = [{ type: 'function', function: weatherTool.function }];
+        const anthropicTools = [{ name: 'get_weather', input_schema: weatherTool.function.parameters }];
+
+        /** [mode, canonical, OpenAI tool_choice, Anthropic tool_choice] */
+        const modes: [string, ToolChoice, unknown, unknown][] = [
+            ['auto', 'auto', 'auto', { type: 'auto' }],
+            ['none', 'none', 'none', { type: 'none' }],
+            ['required', 'required', 'required', { type: 'any' }],
+            [
+                'specific',
+                { type: 'function', function: { name: 'get_weather' } },
+                { type: 'function', function: { name: 'get_weather' } },
+                { type: 'tool', name: 'get_weather' },
+            ],
+        ];
+
+        it.each(modes)('should translate %s from OpenAI to Anthropic', (_, canonical, openaiChoice, anthropicChoice) => {
+            const request = openai.decodeRequest(JSON.stringify({
+                model: 'test-model', messages, tools: openaiTools, tool_choice: openaiChoice,
+            }));
+
+            expect(request.toolChoice).toEqual(canonical);
+            expect(json(anthropic.encodeRequest(request)).tool_choice).toEqual(anthropicChoice);
+        });
+
+        it.each(modes)('should translate %s from Anthropic to OpenAI', (_, canonical, openaiChoice, anthropicChoice) => {
+            const request = anthropic.decodeRequest(JSON.stringify({
+                model: 'test-model', max_tokens: 256, messages, tools: anthropicTools, tool_choice: anthropicChoice,
+            }));
+
+            expect(request.toolChoice).toEqual(canonical);
+            expect(json(openai.encodeRequest(request)).tool_choice).toEqual(openaiChoice);
+        });
+
+        it('should map parallel_tool_calls=false to disable_parallel_tool_use and back', () => {
+            const request = openai.decodeRequest(JSON.stringify({
+                model: 'test-model', messages, tools: openaiTools, tool_choice: 'required', parallel_tool_calls: false,
+            }));
+            expect(request.parallelToolCalls).toBe(false);
+
+            const anthropicBody = json(anthropic.encodeRequest(request));
+            expect(anthropicBody.tool_choice).toEqual({ type: 'any', disable_parallel_tool_use: true });
+
+            const openaiBody = json(openai.encodeRequest(anthropic.decodeRequest(JSON.stringify(anthropicBody))));
+            expect(openaiBody.tool_choice).toBe('required');
+            expect(openaiBody.parallel_tool_calls).toBe(false);
+        });
+
+        it('should disable parallel tool use without an explicit tool choice', () => {
+            const request = openai.decodeRequest(JSON.stringify({
+                model: 'test-model', messages, tools: openaiTools, parallel_tool_calls: false,
+            }));
+
+            expect(json(anthropic.encodeRequest(request)).tool_choice).toEqual({ type: 'auto', disable_parallel_tool_use: true });
+        });
+
+        it('should not limit parallel tool use when tools are forbidden', () => {
+            const request = openai.decodeRequest(JSON.stringify({
+                model: 'test-model', messages, tools: openaiTools, tool_choice: 'none', parallel_tool_calls: false,
+            }));
+
+            expect(json(anthropic.encodeRequest(request)).tool_choice).toEqual({ type: 'none' });
+        });
+
+        it('should parse both function shapes and reject unknown values', () => {
+            const specific = { type: 'function', function: { name: 'get_weather' } };
+
+            expect(parseToolChoice({ type: 'function', function: { name: 'get_weather' } })).toEqual(specific);
+            expect(parseToolChoice({ type: 'function', name: 'get_weather' })).toEqual(specific);
+            expect(parseToolChoice('any')).toBeUndefined();
+            expect(parseToolChoice({ type: 'function', function: {} })).toBeUndefined();
+            expect(parseToolChoice(undefined)).toBeUndefined();
+        });
+    });
+
+    describe('allowlist matching', () => {
+        const losses = [{ paths: ['messages[].toolCalls'], reason: 'test' }];
+
+        it('should cover indexed paths and their descendants', () => {
+            expect(isAllowed('messages[1].toolCalls', losses)).toBe(true);
+            expect(isAllowed('messages[1].toolCalls[0].id', losses)).toBe(true);
+        });
+
+        it('should not cover siblings or prefixes of other fields', () => {
+            expect(isAllowed('messages[1].content', losses)).toBe(false);
+            expect(isAllowed('messages[1].toolCallsExtra', losses)).toBe(false);
+        });
+    });
+});
+
+describe('canonical schema', () => {
+    /**
+     * Returns fixture fields the schema doesn't declare, resolving $refs and
+     * descending into objects and arrays.
+     */
+    function undeclared(schema: Record<string, any>, value: unknown, root: Record<string, any>, path = ''): string[] {
+        if (typeof schema.$ref === 'string') {
+            return undeclared(root.$defs[schema.$ref.replace('#/$defs/', '')], value, root, path);
+        }
+        if (Array.isArray(value)) {
+            return schema.items
+                ? value.flatMap((item, i) => undeclared(schema.items, item, root, `${path}[${i}]`))
+                : [];
+        }
+        if (value && typeof value === 'object' && schema.properties && schema.additionalProperties === false) {
+            return Object.entries(value).flatMap(([key, child]) => {
+                const childPath = path ? `${path}.${key}` : key;
+                const childSchema = schema.properties[key];
+                return childSchema ? undeclared(childSchema, child, root, childPath) : [childPath];
+            });
+        }
+        return [];
+    }
+
+    it.each(Object.entries(requestFixtures))('should declare every field of the %s request', (_, fixture) => {
+        expect(undeclared(CANONICAL_REQUEST_SCHEMA, fixture, CANONICAL_REQUEST_SCHEMA)).toEqual([]);
+    });
+
+    it.each(Object.entries(responseFixtures))('should declare every field of the %s response', (_, fixture) => {
+        expect(undeclared(CANONICAL_RESPONSE_SCHEMA, fixture, CANONICAL_RESPONSE_SCHEMA)).toEqual([]);
+    });
+
+    it('should require the fields every request and response carries', () => {
+        expect(CANONICAL_REQUEST_SCHEMA.required).toEqual(['model', 'messages', 'stream', 'sourceAPIType']);
+        expect(CANONICAL_RESPONSE_SCHEMA.required).toEqual(
+            ['id', 'object', 'created', 'model', 'choices', 'usage', 'sourceAPIType'],
+        );
+    });
+});
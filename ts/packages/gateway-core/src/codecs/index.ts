@@ -5,8 +5,8 @@
  */
 
 // Types
-export type { Codec, StreamMetadata, CodecRegistry } from './types.js';
-export { createCodecRegistry, toText, toBytes, safeParseJSON } from './types.js';
+export type { Codec, StreamMetadata, CodecRegistry, RequestBodyInput } from './types.js';
+export { createCodecRegistry, toText, toBytes, safeParseJSON, parseRequestJSON } from './types.js';
 
 // OpenAI
 export { OpenAICodec, openaiCodec } from './openai.js';
@@ -14,6 +14,33 @@ export { OpenAICodec, openaiCodec } from './openai.js';
 // Anthropic
 export { AnthropicCodec, anthropicCodec } from './anthropic.js';
 
+// Legacy completions
+export {
+    CompletionsCodec,
+    completionsCodec,
+    decodeCompletionsRequest,
+    type DecodedCompletionsRequest,
+} from './completions.js';
+
+// Cohere
+export {
+    CohereCodec,
+    cohereCodec,
+    decodeCohereRequest,
+    type DecodedCohereRequest,
+} from './cohere.js';
+
+// Request validation
+export {
+    validateOpenAIRequest,
+    validateCompletionsRequest,
+    validateAnthropicRequest,
+    validateResponsesRequest,
+    validateCohereRequest,
+    invalidField,
+    parseJSONBody,
+} from './validation.js';
+
 // Images
 export {
     ImageFetcher,
@@ -26,10 +53,15 @@ export {
 import { createCodecRegistry } from './types.js';
 import { openaiCodec } from './openai.js';
 import { anthropicCodec } from './anthropic.js';
+import { completionsCodec } from './completions.js';
+import { cohereCodec } from './cohere.js';
 
 /**
- * Default codec registry with OpenAI and Anthropic codecs.
+ * Default codec registry with the OpenAI, Anthropic, legacy completions and
+ * Cohere codecs.
  */
 export const defaultCodecRegistry = createCodecRegistry();
 defaultCodecRegistry.register(openaiCodec);
 defaultCodecRegistry.register(anthropicCodec);
+defaultCodecRegistry.register(completionsCodec);
+defaultCodecRegistry.register(cohereCodec);
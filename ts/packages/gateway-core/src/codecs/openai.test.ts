@@ -1,5 +1,6 @@
 import { describe, it, expect } from 'vitest';
 import { OpenAICodec } from './openai';
+import { AnthropicCodec } from './anthropic';
 import type { CanonicalRequest, CanonicalResponse, CanonicalEvent } from '../domain/types';
 
 describe('OpenAICodec', () => {
@@ -196,6 +197,82 @@ describe('OpenAICodec', () => {
         });
     });
 
+    describe('multiple choices', () => {
+        it('should carry n through the canonical request', () => {
+            const request = codec.decodeRequest(JSON.stringify({
+                model: 'gpt-4',
+                messages: [{ role: 'user', content: 'Hi' }],
+                n: 3,
+            }));
+            expect(request.n).toBe(3);
+
+            const parsed = JSON.parse(new TextDecoder().decode(codec.encodeRequest(request)));
+            expect(parsed.n).toBe(3);
+        });
+
+        it('should route stream chunks by choice index', () => {
+            const event = codec.decodeStreamChunk(JSON.stringify({
+                id: 'chatcmpl-1',
+                object: 'chat.completion.chunk',
+                created: 1699000000,
+                model: 'gpt-4',
+                choices: [{ index: 2, delta: { content: 'Hi' }, finish_reason: null }],
+            }));
+            expect(event?.choiceIndex).toBe(2);
+
+            const chunk = JSON.parse(codec.encodeStreamEvent(event!));
+            expect(chunk.choices[0].index).toBe(2);
+        });
+    });
+
+    describe('stop sequences', () => {
+        const completion = (stopReason: unknown) => JSON.stringify({
+            id: 'chatcmpl-123',
+            object: 'chat.completion',
+            created: 1699000000,
+            model: 'llama-3-70b',
+            choices: [{
+                index: 0,
+                message: { role: 'assistant', content: '1 2 3 ' },
+                finish_reason: 'stop',
+                stop_reason: stopReason,
+            }],
+            usage: { prompt_tokens: 10, completion_tokens: 6, total_tokens: 16 },
+        });
+
+        it('should read the matched stop sequence from stop_reason', () => {
+            const response = codec.decodeResponse(completion('END'));
+            expect(response.choices[0]?.stopSequence).toBe('END');
+
+            const anthropic = JSON.parse(new TextDecoder().decode(new AnthropicCodec().encodeResponse(response)));
+            expect(anthropic.stop_reason).toBe('stop_sequence');
+            expect(anthropic.stop_sequence).toBe('END');
+
+            const back = JSON.parse(new TextDecoder().decode(codec.encodeResponse(response)));
+            expect(back.choices[0].stop_reason).toBe('END');
+        });
+
+        it('should ignore stop token IDs', () => {
+            const response = codec.decodeResponse(completion(128009));
+            expect(response.choices[0]?.stopSequence).toBeUndefined();
+        });
+
+        it('should read stop_reason from the final stream chunk', () => {
+            const event = codec.decodeStreamChunk(JSON.stringify({
+                id: 'chatcmpl-1',
+                object: 'chat.completion.chunk',
+                created: 1699000000,
+                model: 'llama-3-70b',
+                choices: [{ index: 0, delta: {}, finish_reason: 'stop', stop_reason: '###' }],
+            }));
+            expect(event?.type).toBe('message_stop');
+            expect(event?.stopSequence).toBe('###');
+
+            const chunk = JSON.parse(codec.encodeStreamEvent(event!));
+            expect(chunk.choices[0].stop_reason).toBe('###');
+        });
+    });
+
     describe('encodeError / decodeError', () => {
         it('should encode error to OpenAI format', () => {
             const error = new Error('Test error');
@@ -221,4 +298,24 @@ describe('OpenAICodec', () => {
             expect(error.message).toBe('Model not found');
         });
     });
+
+    describe('end user', () => {
+        it('should carry user through the canonical request', () => {
+            const request = codec.decodeRequest(JSON.stringify({
+                model: 'gpt-4',
+                messages: [{ role: 'user', content: 'Hi' }],
+                user: 'user-1234',
+            }));
+            expect(request.endUserId).toBe('user-1234');
+
+            const parsed = JSON.parse(new TextDecoder().decode(codec.encodeRequest(request)));
+            expect(parsed.user).toBe('user-1234');
+        });
+
+        it('should omit user when the request names no end user', () => {
+            const request = codec.decodeRequest(JSON.stringify({ model: 'gpt-4', messages: [{ role: 'user', content: 'Hi' }] }));
+            const parsed = JSON.parse(new TextDecoder().decode(codec.encodeRequest(request)));
+            expect(parsed).not.toHaveProperty('user');
+        });
+    });
 });
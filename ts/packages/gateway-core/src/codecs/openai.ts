@@ -15,38 +15,53 @@ import type {
     Choice,
     Usage,
     ToolCallChunk,
+    ReasoningEffort,
+    ContentPart,
+    Logprobs,
+    TokenLogprob,
 } from '../domain/types.js';
+import { budgetToReasoningEffort, getImageParts, parseToolChoice } from '../domain/types.js';
 import {
     APIError,
     toOpenAIError,
     isAPIError,
     errServer,
 } from '../domain/errors.js';
-import type { Codec, StreamMetadata } from './types.js';
-import { toText, toBytes, safeParseJSON } from './types.js';
+import type { Codec, RequestBodyInput, StreamMetadata } from './types.js';
+import { toText, toBytes, safeParseJSON, parseRequestJSON } from './types.js';
 
 // ============================================================================
 // OpenAI API Types
 // ============================================================================
 
 /** OpenAI chat completion request. */
-interface OpenAIRequest {
+export interface OpenAIRequest {
     model: string;
-    messages: OpenAIMessage[];
+    messages: OpenAIRequestMessage[];
     stream?: boolean;
     max_tokens?: number;
     max_completion_tokens?: number;
     temperature?: number;
     top_p?: number;
+    frequency_penalty?: number;
+    presence_penalty?: number;
+    n?: number;
     stop?: string | string[];
     tools?: OpenAITool[];
     tool_choice?: unknown;
+    parallel_tool_calls?: boolean;
     response_format?: { type: string; json_schema?: unknown };
     user?: string;
+    reasoning_effort?: ReasoningEffort;
+    logprobs?: boolean;
+    top_logprobs?: number;
+    store?: boolean;
+    metadata?: Record<string, string>;
+    stream_options?: { include_usage?: boolean };
 }
 
 /** OpenAI message. */
-interface OpenAIMessage {
+export interface OpenAIMessage {
     role: string;
     content: string | null;
     name?: string;
@@ -54,8 +69,20 @@ interface OpenAIMessage {
     tool_call_id?: string;
 }
 
+/** OpenAI request message, whose content may be a list of parts. */
+export interface OpenAIRequestMessage extends Omit<OpenAIMessage, 'content'> {
+    content: string | OpenAIContentPart[] | null;
+}
+
+/** OpenAI message content part. */
+export interface OpenAIContentPart {
+    type: 'text' | 'image_url';
+    text?: string;
+    image_url?: { url: string; detail?: 'auto' | 'low' | 'high' };
+}
+
 /** OpenAI tool call. */
-interface OpenAIToolCall {
+export interface OpenAIToolCall {
     id: string;
     type: 'function';
     function: {
@@ -65,7 +92,7 @@ interface OpenAIToolCall {
 }
 
 /** OpenAI tool definition. */
-interface OpenAITool {
+export interface OpenAITool {
     type: 'function';
     function: {
         name: string;
@@ -75,7 +102,7 @@ interface OpenAITool {
 }
 
 /** OpenAI chat completion response. */
-interface OpenAIResponse {
+export interface OpenAIResponse {
     id: string;
     object: string;
     created: number;
@@ -83,21 +110,45 @@ interface OpenAIResponse {
     choices: OpenAIChoice[];
     usage: OpenAIUsage;
     system_fingerprint?: string;
+    metadata?: Record<string, string>;
 }
 
 /** OpenAI choice. */
-interface OpenAIChoice {
+export interface OpenAIChoice {
     index: number;
     message: OpenAIMessage;
     finish_reason: string | null;
-    logprobs?: unknown;
+    /** Matched stop sequence (OpenAI-compatible servers; a token ID on some). */
+    stop_reason?: string | number | null;
+    logprobs?: OpenAILogprobs | null;
+}
+
+/** OpenAI choice (or chunk choice) logprobs. */
+interface OpenAILogprobs {
+    content: OpenAITokenLogprob[] | null;
+    refusal?: OpenAITokenLogprob[] | null;
+}
+
+/** OpenAI token logprob. */
+interface OpenAITokenLogprob extends OpenAITopLogprob {
+    top_logprobs: OpenAITopLogprob[];
+}
+
+/** OpenAI top logprob candidate. */
+interface OpenAITopLogprob {
+    token: string;
+    logprob: number;
+    bytes: number[] | null;
 }
 
 /** OpenAI usage. */
-interface OpenAIUsage {
+export interface OpenAIUsage {
     prompt_tokens: number;
     completion_tokens: number;
     total_tokens: number;
+    completion_tokens_details?: {
+        reasoning_tokens?: number;
+    };
 }
 
 /** OpenAI streaming chunk. */
@@ -107,8 +158,9 @@ interface OpenAIChunk {
     created: number;
     model: string;
     choices: OpenAIChunkChoice[];
-    usage?: OpenAIUsage;
+    usage?: OpenAIUsage | null;
     system_fingerprint?: string;
+    warning?: string;
 }
 
 /** OpenAI chunk choice. */
@@ -119,7 +171,9 @@ interface OpenAIChunkChoice {
         content?: string | null;
         tool_calls?: OpenAIToolCallChunk[];
     };
+    logprobs?: OpenAILogprobs | null;
     finish_reason: string | null;
+    stop_reason?: string | number | null;
 }
 
 /** OpenAI tool call chunk. */
@@ -156,8 +210,8 @@ export class OpenAICodec implements Codec {
 
     // ---- Request handling ----
 
-    decodeRequest(body: Uint8Array | string): CanonicalRequest {
-        const json = safeParseJSON<OpenAIRequest>(toText(body));
+    decodeRequest(body: RequestBodyInput): CanonicalRequest {
+        const json = parseRequestJSON<OpenAIRequest>(body);
         if (!json) {
             throw new APIError('invalid_request', 'Invalid JSON in request body');
         }
@@ -245,7 +299,7 @@ export class OpenAICodec implements Codec {
 function apiRequestToCanonical(req: OpenAIRequest): CanonicalRequest {
     const messages: Message[] = req.messages.map((m) => ({
         role: m.role as Message['role'],
-        content: m.content ?? '',
+        ...decodeContent(m.content),
         name: m.name,
         toolCallId: m.tool_call_id,
         toolCalls: m.tool_calls?.map((tc): ToolCall => ({
@@ -295,19 +349,71 @@ function apiRequestToCanonical(req: OpenAIRequest): CanonicalRequest {
         maxTokens,
         temperature: req.temperature,
         topP: req.top_p,
+        frequencyPenalty: req.frequency_penalty,
+        presencePenalty: req.presence_penalty,
+        n: req.n,
         stop,
         tools,
-        toolChoice: req.tool_choice as CanonicalRequest['toolChoice'],
+        toolChoice: parseToolChoice(req.tool_choice),
+        parallelToolCalls: req.parallel_tool_calls,
         responseFormat,
+        reasoningEffort: req.reasoning_effort,
+        logprobs: req.logprobs ?? undefined,
+        topLogprobs: req.top_logprobs ?? undefined,
+        endUserId: req.user,
+        metadata: req.metadata,
+        store: req.store ?? undefined,
+        streamIncludeUsage: req.stream_options?.include_usage ?? undefined,
         sourceAPIType: 'openai',
     };
 }
 
+/**
+ * Decodes message content. Parts are joined into the text content, and
+ * kept as rich content when any of them is an image.
+ */
+function decodeContent(content: string | OpenAIContentPart[] | null): Pick<Message, 'content' | 'richContent'> {
+    if (!Array.isArray(content)) {
+        return { content: content ?? '' };
+    }
+    const parts = content.map((p): ContentPart => (
+        p.type === 'image_url'
+            ? { type: 'image_url', imageUrl: p.image_url }
+            : { type: 'text', text: p.text ?? '' }
+    ));
+    const text = parts.filter((p) => p.type === 'text').map((p) => p.text).join('');
+    return parts.some((p) => p.type === 'image_url')
+        ? { content: text, richContent: { parts } }
+        : { content: text };
+}
+
+/**
+ * Encodes message content: text, or text and image parts for user
+ * messages with images. Inline images are sent as data URLs. Other roles
+ * can't carry images, so only their text is sent.
+ */
+function encodeContent(m: Message): string | OpenAIContentPart[] {
+    if (m.role !== 'user' || getImageParts(m).length === 0) {
+        return m.content;
+    }
+    const parts: OpenAIContentPart[] = [];
+    for (const p of m.richContent?.parts ?? []) {
+        if (p.type === 'text') {
+            parts.push({ type: 'text', text: p.text ?? '' });
+        } else if (p.type === 'image_url' && p.imageUrl) {
+            parts.push({ type: 'image_url', image_url: p.imageUrl });
+        } else if (p.type === 'image' && p.source) {
+            parts.push({ type: 'image_url', image_url: { url: `data:${p.source.mediaType};base64,${p.source.data}` } });
+        }
+    }
+    return parts;
+}
+
 /**
  * Converts canonical request to OpenAI API format.
  */
 function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
-    const messages: OpenAIMessage[] = [];
+    const messages: OpenAIRequestMessage[] = [];
 
     // Add system prompt if set separately
     if (req.systemPrompt) {
@@ -321,9 +427,9 @@ function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
 
     // Add conversation messages
     for (const m of req.messages) {
-        const msg: OpenAIMessage = {
+        const msg: OpenAIRequestMessage = {
             role: m.role,
-            content: m.content,
+            content: encodeContent(m),
             name: m.name,
             tool_call_id: m.toolCallId,
         };
@@ -348,6 +454,11 @@ function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
         stream: req.stream,
     };
 
+    // The gateway stores responses itself; store and metadata stay here
+    if (req.stream && req.streamIncludeUsage) {
+        apiReq.stream_options = { include_usage: true };
+    }
+
     if (req.maxTokens) {
         apiReq.max_completion_tokens = req.maxTokens;
     }
@@ -360,6 +471,18 @@ function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
         apiReq.top_p = req.topP;
     }
 
+    if (req.frequencyPenalty !== undefined) {
+        apiReq.frequency_penalty = req.frequencyPenalty;
+    }
+
+    if (req.presencePenalty !== undefined) {
+        apiReq.presence_penalty = req.presencePenalty;
+    }
+
+    if (req.n !== undefined && req.n > 1) {
+        apiReq.n = req.n;
+    }
+
     if (req.stop?.length) {
         apiReq.stop = req.stop;
     }
@@ -375,6 +498,25 @@ function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
         };
     }
 
+    // Reasoning effort (map Anthropic thinking budget when routed cross-provider)
+    if (req.reasoningEffort) {
+        apiReq.reasoning_effort = req.reasoningEffort;
+    } else if (req.thinking?.type === 'enabled') {
+        apiReq.reasoning_effort = budgetToReasoningEffort(req.thinking.budgetTokens);
+    }
+
+    if (req.logprobs !== undefined) {
+        apiReq.logprobs = req.logprobs;
+    }
+
+    if (req.topLogprobs !== undefined) {
+        apiReq.top_logprobs = req.topLogprobs;
+    }
+
+    if (req.endUserId !== undefined) {
+        apiReq.user = req.endUserId;
+    }
+
     if (req.tools?.length) {
         apiReq.tools = req.tools.map((t): OpenAITool => ({
             type: 'function',
@@ -384,6 +526,11 @@ function canonicalToApiRequest(req: CanonicalRequest): OpenAIRequest {
                 parameters: t.function.parameters,
             },
         }));
+
+        // Only accepted alongside tools
+        if (req.parallelToolCalls !== undefined) {
+            apiReq.parallel_tool_calls = req.parallelToolCalls;
+        }
     }
 
     return apiReq;
@@ -415,7 +562,8 @@ function apiResponseToCanonical(resp: OpenAIResponse): CanonicalResponse {
             index: c.index,
             message: msg,
             finishReason: c.finish_reason as Choice['finishReason'],
-            logprobs: c.logprobs,
+            stopSequence: stopSequenceFrom(c.stop_reason),
+            logprobs: c.logprobs && logprobsToCanonical(c.logprobs),
         };
     });
 
@@ -425,11 +573,7 @@ function apiResponseToCanonical(resp: OpenAIResponse): CanonicalResponse {
         created: resp.created,
         model: resp.model,
         choices,
-        usage: {
-            promptTokens: resp.usage.prompt_tokens,
-            completionTokens: resp.usage.completion_tokens,
-            totalTokens: resp.usage.total_tokens,
-        },
+        usage: usageToCanonical(resp.usage),
         sourceAPIType: 'openai',
         systemFingerprint: resp.system_fingerprint,
     };
@@ -460,7 +604,9 @@ function canonicalToApiResponse(resp: CanonicalResponse): OpenAIResponse {
         return {
             index: c.index,
             message: msg,
+            logprobs: c.logprobs && logprobsToApi(c.logprobs),
             finish_reason: c.finishReason,
+            stop_reason: c.stopSequence,
         };
     });
 
@@ -470,12 +616,9 @@ function canonicalToApiResponse(resp: CanonicalResponse): OpenAIResponse {
         created: resp.created,
         model: resp.model,
         choices,
-        usage: {
-            prompt_tokens: resp.usage.promptTokens,
-            completion_tokens: resp.usage.completionTokens,
-            total_tokens: resp.usage.totalTokens,
-        },
+        usage: usageToApi(resp.usage),
         system_fingerprint: resp.systemFingerprint,
+        metadata: resp.metadata,
     };
 }
 
@@ -491,11 +634,16 @@ function apiChunkToCanonical(chunk: OpenAIChunk): CanonicalEvent {
 
     const choice = chunk.choices[0];
     if (choice) {
+        event.choiceIndex = choice.index;
         event.role = choice.delta.role;
         event.contentDelta = choice.delta.content ?? undefined;
+        if (choice.logprobs) {
+            event.logprobs = logprobsToCanonical(choice.logprobs);
+        }
 
         if (choice.finish_reason) {
             event.finishReason = choice.finish_reason;
+            event.stopSequence = stopSequenceFrom(choice.stop_reason);
             event.type = 'message_stop';
         }
 
@@ -518,11 +666,7 @@ function apiChunkToCanonical(chunk: OpenAIChunk): CanonicalEvent {
 
     // Handle usage in final chunk
     if (chunk.usage) {
-        event.usage = {
-            promptTokens: chunk.usage.prompt_tokens,
-            completionTokens: chunk.usage.completion_tokens,
-            totalTokens: chunk.usage.total_tokens,
-        };
+        event.usage = usageToCanonical(chunk.usage);
     }
 
     return event;
@@ -535,6 +679,20 @@ function canonicalToApiChunk(
     event: CanonicalEvent,
     metadata?: StreamMetadata,
 ): OpenAIChunk {
+    // A done event with a gateway warning, or the stream's usage when
+    // include_usage asked for it, is sent as a chunk with no choices
+    if (event.type === 'done') {
+        return {
+            id: metadata?.id ?? '',
+            object: 'chat.completion.chunk',
+            created: metadata?.created ?? Math.floor(Date.now() / 1000),
+            model: metadata?.model ?? event.model ?? '',
+            choices: [],
+            ...(metadata?.includeUsage && event.usage && { usage: usageToApi(event.usage), system_fingerprint: metadata.systemFingerprint }),
+            warning: event.warning,
+        };
+    }
+
     const chunk: OpenAIChunk = {
         id: metadata?.id ?? '',
         object: 'chat.completion.chunk',
@@ -542,12 +700,14 @@ function canonicalToApiChunk(
         model: metadata?.model ?? event.model ?? '',
         choices: [
             {
-                index: 0,
+                index: event.choiceIndex ?? 0,
                 delta: {
                     role: event.role,
                     content: event.contentDelta,
                 },
+                logprobs: event.logprobs && logprobsToApi(event.logprobs),
                 finish_reason: event.finishReason ?? null,
+                stop_reason: event.stopSequence,
             },
         ],
         system_fingerprint: metadata?.systemFingerprint,
@@ -565,18 +725,84 @@ function canonicalToApiChunk(
         ];
     }
 
-    // Handle usage
-    if (event.usage) {
-        chunk.usage = {
-            prompt_tokens: event.usage.promptTokens,
-            completion_tokens: event.usage.completionTokens,
-            total_tokens: event.usage.totalTokens,
-        };
+    // Handle usage; with include_usage it comes only in the final chunk
+    if (metadata?.includeUsage) {
+        chunk.usage = null;
+    } else if (event.usage) {
+        chunk.usage = usageToApi(event.usage);
     }
 
     return chunk;
 }
 
+/**
+ * Converts OpenAI logprobs to canonical form.
+ */
+function logprobsToCanonical(logprobs: OpenAILogprobs): Logprobs {
+    const tokens = (list: OpenAITokenLogprob[] | null): TokenLogprob[] | null => list && list.map((t) => ({
+        token: t.token,
+        logprob: t.logprob,
+        bytes: t.bytes,
+        topLogprobs: t.top_logprobs.map((top) => ({ token: top.token, logprob: top.logprob, bytes: top.bytes })),
+    }));
+    return {
+        content: tokens(logprobs.content),
+        refusal: logprobs.refusal === undefined ? undefined : tokens(logprobs.refusal),
+    };
+}
+
+/**
+ * Converts canonical logprobs to OpenAI form, in OpenAI's field order.
+ */
+function logprobsToApi(logprobs: Logprobs): OpenAILogprobs {
+    const tokens = (list: TokenLogprob[] | null): OpenAITokenLogprob[] | null => list && list.map((t) => ({
+        token: t.token,
+        logprob: t.logprob,
+        bytes: t.bytes,
+        top_logprobs: t.topLogprobs.map((top) => ({ token: top.token, logprob: top.logprob, bytes: top.bytes })),
+    }));
+    const api: OpenAILogprobs = { content: tokens(logprobs.content) };
+    if (logprobs.refusal !== undefined) {
+        api.refusal = tokens(logprobs.refusal);
+    }
+    return api;
+}
+
+/**
+ * Reads a matched stop sequence from `stop_reason`, ignoring the stop token
+ * IDs some servers report there.
+ */
+function stopSequenceFrom(stopReason: string | number | null | undefined): string | undefined {
+    return typeof stopReason === 'string' ? stopReason : undefined;
+}
+
+/**
+ * Converts OpenAI usage to canonical usage.
+ */
+function usageToCanonical(usage: OpenAIUsage): Usage {
+    return {
+        promptTokens: usage.prompt_tokens,
+        completionTokens: usage.completion_tokens,
+        totalTokens: usage.total_tokens,
+        reasoningTokens: usage.completion_tokens_details?.reasoning_tokens,
+    };
+}
+
+/**
+ * Converts canonical usage to OpenAI usage.
+ */
+function usageToApi(usage: Usage): OpenAIUsage {
+    const apiUsage: OpenAIUsage = {
+        prompt_tokens: usage.promptTokens,
+        completion_tokens: usage.completionTokens,
+        total_tokens: usage.totalTokens,
+    };
+    if (usage.reasoningTokens !== undefined) {
+        apiUsage.completion_tokens_details = { reasoning_tokens: usage.reasoningTokens };
+    }
+    return apiUsage;
+}
+
 /**
  * Maps OpenAI error type to canonical error type.
  */
@@ -10,6 +10,7 @@ import type {
     CanonicalResponse,
     CanonicalEvent,
 } from '../domain/types.js';
+import type { RequestBody } from '../ingest/body.js';
 
 // ============================================================================
 // Codec Interface
@@ -30,7 +31,7 @@ export interface Codec {
     /**
      * Decodes an API request body to canonical format.
      */
-    decodeRequest(body: Uint8Array | string): CanonicalRequest;
+    decodeRequest(body: RequestBodyInput): CanonicalRequest;
 
     /**
      * Encodes a canonical request to API format.
@@ -92,6 +93,9 @@ export interface StreamMetadata {
 
     /** System fingerprint. */
     systemFingerprint?: string | undefined;
+
+    /** Usage goes in its own chunk at the end, and other chunks carry none (OpenAI `stream_options.include_usage`). */
+    includeUsage?: boolean | undefined;
 }
 
 // ============================================================================
@@ -160,6 +164,27 @@ export function toText(input: Uint8Array | string): string {
     return new TextDecoder().decode(input);
 }
 
+/**
+ * A request body to decode: raw, or read by the gateway (whose parse is
+ * shared with validation).
+ */
+export type RequestBodyInput = Uint8Array | string | RequestBody;
+
+/**
+ * Parses a request body's JSON, returning null on error. A body read by
+ * the gateway is parsed at most once, however many times it's decoded.
+ */
+export function parseRequestJSON<T>(body: RequestBodyInput): T | null {
+    if (typeof body === 'string' || body instanceof Uint8Array) {
+        return safeParseJSON<T>(toText(body));
+    }
+    try {
+        return body.json() as T;
+    } catch {
+        return null;
+    }
+}
+
 /**
  * Converts a string to Uint8Array.
  */
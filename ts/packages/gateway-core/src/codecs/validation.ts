@@ -0,0 +1,629 @@
+/**
+ * Request body validation for client-facing APIs.
+ *
+ * Runs on the raw JSON before decoding so malformed requests fail with a 400
+ * naming the offending field (e.g. "messages[2].role: must be one of ...")
+ * instead of being decoded into silently-wrong values or failing deep inside
+ * a provider call. Unknown fields are not errors; they are returned so the
+ * caller can record them.
+ *
+ * @module codecs/validation
+ */
+
+import { APIError, errInvalidRequest } from '../domain/errors.js';
+
+// ============================================================================
+// Field Helpers
+// ============================================================================
+
+type JSONObject = Record<string, unknown>;
+
+/**
+ * Creates the error for an invalid field.
+ */
+export function invalidField(path: string, message: string): APIError {
+    return errInvalidRequest(`${path}: ${message}`).withParam(path);
+}
+
+function isObject(value: unknown): value is JSONObject {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
+
+function describe(value: unknown): string {
+    if (value === null) return 'null';
+    if (Array.isArray(value)) return 'array';
+    return typeof value;
+}
+
+interface NumberRule {
+    required?: boolean;
+    integer?: boolean;
+    min?: number;
+    max?: number;
+}
+
+/**
+ * Typed accessors over one JSON object that report errors by field path.
+ */
+class Fields {
+    constructor(
+        readonly value: JSONObject,
+        private readonly path: string,
+    ) { }
+
+    static root(value: unknown, what: string): Fields {
+        if (!isObject(value)) {
+            throw errInvalidRequest(`${what} must be a JSON object, got ${describe(value)}`);
+        }
+        return new Fields(value, '');
+    }
+
+    at(key: string): string {
+        return this.path ? `${this.path}.${key}` : key;
+    }
+
+    has(key: string): boolean {
+        return this.value[key] !== undefined && this.value[key] !== null;
+    }
+
+    string(key: string, required = false): string | undefined {
+        const v = this.value[key];
+        if (v === undefined || v === null) {
+            if (required) throw invalidField(this.at(key), 'is required');
+            return undefined;
+        }
+        if (typeof v !== 'string') {
+            throw invalidField(this.at(key), `must be a string, got ${describe(v)}`);
+        }
+        return v;
+    }
+
+    number(key: string, rule: NumberRule = {}): number | undefined {
+        const v = this.value[key];
+        if (v === undefined || v === null) {
+            if (rule.required) throw invalidField(this.at(key), 'is required');
+            return undefined;
+        }
+        if (typeof v !== 'number' || !Number.isFinite(v)) {
+            throw invalidField(this.at(key), `must be a number, got ${describe(v)}`);
+        }
+        if (rule.integer && !Number.isInteger(v)) {
+            throw invalidField(this.at(key), 'must be an integer');
+        }
+        if (rule.min !== undefined && v < rule.min) {
+            throw invalidField(this.at(key), `must be >= ${rule.min}`);
+        }
+        if (rule.max !== undefined && v > rule.max) {
+            throw invalidField(this.at(key), `must be <= ${rule.max}`);
+        }
+        return v;
+    }
+
+    boolean(key: string): boolean | undefined {
+        const v = this.value[key];
+        if (v === undefined || v === null) return undefined;
+        if (typeof v !== 'boolean') {
+            throw invalidField(this.at(key), `must be a boolean, got ${describe(v)}`);
+        }
+        return v;
+    }
+
+    oneOf<T extends string>(key: string, values: readonly T[], required = false): T | undefined {
+        const v = this.string(key, required);
+        if (v !== undefined && !values.includes(v as T)) {
+            throw invalidField(this.at(key), `must be one of ${values.join(', ')}`);
+        }
+        return v as T | undefined;
+    }
+
+    object(key: string, required = false): Fields | undefined {
+        const v = this.value[key];
+        if (v === undefined || v === null) {
+            if (required) throw invalidField(this.at(key), 'is required');
+            return undefined;
+        }
+        if (!isObject(v)) {
+            throw invalidField(this.at(key), `must be an object, got ${describe(v)}`);
+        }
+        return new Fields(v, this.at(key));
+    }
+
+    /**
+     * Returns each element of an array field as Fields (elements must be objects).
+     */
+    objects(key: string, options: { required?: boolean; nonEmpty?: boolean } = {}): Fields[] | undefined {
+        const items = this.array(key, options);
+        return items?.map((item, i) => {
+            const path = `${this.at(key)}[${i}]`;
+            if (!isObject(item)) {
+                throw invalidField(path, `must be an object, got ${describe(item)}`);
+            }
+            return new Fields(item, path);
+        });
+    }
+
+    array(key: string, options: { required?: boolean; nonEmpty?: boolean } = {}): unknown[] | undefined {
+        const v = this.value[key];
+        if (v === undefined || v === null) {
+            if (options.required) throw invalidField(this.at(key), 'is required');
+            return undefined;
+        }
+        if (!Array.isArray(v)) {
+            throw invalidField(this.at(key), `must be an array, got ${describe(v)}`);
+        }
+        if (options.nonEmpty && v.length === 0) {
+            throw invalidField(this.at(key), 'must not be empty');
+        }
+        return v;
+    }
+
+    stringArray(key: string, maxItems?: number): void {
+        const items = this.array(key);
+        if (!items) return;
+        if (maxItems !== undefined && items.length > maxItems) {
+            throw invalidField(this.at(key), `must have at most ${maxItems} items`);
+        }
+        items.forEach((item, i) => {
+            if (typeof item !== 'string') {
+                throw invalidField(`${this.at(key)}[${i}]`, `must be a string, got ${describe(item)}`);
+            }
+        });
+    }
+
+    exclusive(a: string, b: string): void {
+        if (this.has(a) && this.has(b)) {
+            throw invalidField(this.at(b), `cannot be combined with ${a}`);
+        }
+    }
+
+    unknown(known: ReadonlySet<string>): string[] {
+        return Object.keys(this.value).filter((k) => !known.has(k));
+    }
+}
+
+const TOOL_NAME_PATTERN = /^[a-zA-Z0-9_-]{1,64}$/;
+
+/**
+ * Validates the gateway's prompt template extension: a template name and
+ * scalar variables.
+ */
+function validateTemplateRef(req: Fields): void {
+    const template = req.object('template');
+    if (!template) return;
+
+    template.string('name', true);
+    const variables = template.object('variables');
+    for (const [key, value] of Object.entries(variables?.value ?? {})) {
+        if (value !== null && !['string', 'number', 'boolean'].includes(typeof value)) {
+            throw invalidField(variables!.at(key), `must be a string, number, or boolean, got ${describe(value)}`);
+        }
+    }
+}
+
+/**
+ * Parses a request body as JSON, failing with a 400 on syntax errors.
+ */
+export function parseJSONBody(text: string): unknown {
+    try {
+        return JSON.parse(text);
+    } catch (error) {
+        throw errInvalidRequest(
+            `Invalid JSON in request body: ${error instanceof Error ? error.message : String(error)}`,
+        );
+    }
+}
+
+// ============================================================================
+// OpenAI Chat Completions
+// ============================================================================
+
+const OPENAI_ROLES = ['system', 'developer', 'user', 'assistant', 'tool', 'function'] as const;
+
+const OPENAI_FIELDS = new Set([
+    'model', 'messages', 'stream', 'stream_options', 'max_tokens', 'max_completion_tokens',
+    'temperature', 'top_p', 'n', 'stop', 'tools', 'tool_choice', 'parallel_tool_calls',
+    'response_format', 'user', 'reasoning_effort', 'seed', 'presence_penalty',
+    'frequency_penalty', 'logit_bias', 'logprobs', 'top_logprobs', 'store', 'metadata',
+    'template',
+]);
+
+/**
+ * Validates an OpenAI chat completions request body.
+ * Returns the names of unrecognized top-level fields.
+ */
+export function validateOpenAIRequest(body: unknown): string[] {
+    const req = Fields.root(body, 'Request body');
+
+    req.string('model');
+    req.boolean('stream');
+    req.exclusive('max_tokens', 'max_completion_tokens');
+    req.number('max_tokens', { integer: true, min: 1 });
+    req.number('max_completion_tokens', { integer: true, min: 1 });
+    req.number('temperature', { min: 0, max: 2 });
+    req.number('top_p', { min: 0, max: 1 });
+    req.number('n', { integer: true, min: 1, max: 128 });
+    req.number('presence_penalty', { min: -2, max: 2 });
+    req.number('frequency_penalty', { min: -2, max: 2 });
+    req.number('seed', { integer: true });
+    req.string('user');
+    req.oneOf('reasoning_effort', ['low', 'medium', 'high']);
+    const logprobs = req.boolean('logprobs');
+    if (req.number('top_logprobs', { integer: true, min: 0, max: 20 }) !== undefined && logprobs !== true) {
+        throw invalidField('top_logprobs', 'requires logprobs to be true');
+    }
+    validateTemplateRef(req);
+
+    const stop = req.value['stop'];
+    if (typeof stop !== 'string') {
+        req.stringArray('stop', 4);
+    }
+
+    const messages = req.objects('messages', { required: true, nonEmpty: true })!;
+    for (const message of messages) {
+        validateOpenAIMessage(message);
+    }
+
+    const toolNames = new Set<string>();
+    const tools = req.objects('tools');
+    tools?.forEach((tool) => {
+        tool.oneOf('type', ['function'], true);
+        const fn = tool.object('function', true)!;
+        const name = fn.string('name', true)!;
+        if (!TOOL_NAME_PATTERN.test(name)) {
+            throw invalidField(fn.at('name'), 'must match ^[a-zA-Z0-9_-]{1,64}$');
+        }
+        fn.string('description');
+        fn.object('parameters');
+        toolNames.add(name);
+    });
+    req.boolean('parallel_tool_calls');
+
+    const toolChoice = req.value['tool_choice'];
+    if (typeof toolChoice === 'string') {
+        req.oneOf('tool_choice', ['none', 'auto', 'required']);
+        if (toolChoice === 'required' && !tools?.length) {
+            throw invalidField('tool_choice', 'requires tools to be provided');
+        }
+    } else if (toolChoice !== undefined && toolChoice !== null) {
+        const choice = req.object('tool_choice')!;
+        choice.oneOf('type', ['function'], true);
+        const fn = choice.object('function', true)!;
+        const name = fn.string('name', true)!;
+        if (!tools?.length) {
+            throw invalidField('tool_choice', 'requires tools to be provided');
+        }
+        if (!toolNames.has(name)) {
+            throw invalidField(fn.at('name'), `no tool named '${name}' in tools`);
+        }
+    }
+
+    const format = req.object('response_format');
+    if (format) {
+        const type = format.oneOf('type', ['text', 'json_object', 'json_schema'], true);
+        if (type === 'json_schema') {
+            format.object('json_schema', true);
+        }
+    }
+
+    return req.unknown(OPENAI_FIELDS);
+}
+
+function validateOpenAIMessage(message: Fields): void {
+    const role = message.oneOf('role', OPENAI_ROLES, true);
+    message.string('name');
+
+    const content = message.value['content'];
+    if (Array.isArray(content)) {
+        message.objects('content')!.forEach((part) => part.string('type', true));
+    } else if (content !== undefined && content !== null) {
+        message.string('content');
+    } else if (role !== 'assistant') {
+        throw invalidField(message.at('content'), 'is required');
+    }
+
+    if (role === 'tool') {
+        message.string('tool_call_id', true);
+    }
+
+    const toolCalls = message.objects('tool_calls');
+    if (toolCalls && role !== 'assistant') {
+        throw invalidField(message.at('tool_calls'), 'is only allowed on assistant messages');
+    }
+    toolCalls?.forEach((call) => {
+        call.string('id', true);
+        call.oneOf('type', ['function'], true);
+        const fn = call.object('function', true)!;
+        fn.string('name', true);
+        fn.string('arguments', true);
+    });
+
+    if (role === 'assistant' && (content === undefined || content === null) && !toolCalls?.length) {
+        throw invalidField(message.at('content'), 'is required unless tool_calls is set');
+    }
+}
+
+// ============================================================================
+// OpenAI Legacy Completions
+// ============================================================================
+
+const COMPLETIONS_FIELDS = new Set([
+    'model', 'prompt', 'suffix', 'max_tokens', 'temperature', 'top_p', 'n', 'stream',
+    'stream_options', 'logprobs', 'echo', 'stop', 'presence_penalty', 'frequency_penalty',
+    'best_of', 'logit_bias', 'user', 'seed',
+]);
+
+/**
+ * Validates an OpenAI legacy completions request body.
+ * Returns the names of unrecognized top-level fields.
+ */
+export function validateCompletionsRequest(body: unknown): string[] {
+    const req = Fields.root(body, 'Request body');
+
+    req.string('model');
+    req.boolean('stream');
+    req.boolean('echo');
+    req.string('suffix');
+    req.number('max_tokens', { integer: true, min: 1 });
+    req.number('temperature', { min: 0, max: 2 });
+    req.number('top_p', { min: 0, max: 1 });
+    const n = req.number('n', { integer: true, min: 1, max: 128 });
+    const bestOf = req.number('best_of', { integer: true, min: 1, max: 20 });
+    req.number('logprobs', { integer: true, min: 0, max: 5 });
+    req.number('presence_penalty', { min: -2, max: 2 });
+    req.number('frequency_penalty', { min: -2, max: 2 });
+    req.number('seed', { integer: true });
+    req.string('user');
+
+    if (bestOf !== undefined && bestOf < (n ?? 1)) {
+        throw invalidField('best_of', 'must be greater than or equal to n');
+    }
+
+    const prompt = req.value['prompt'];
+    if (Array.isArray(prompt) && prompt.length === 0) {
+        throw invalidField('prompt', 'must not be empty');
+    } else if (prompt !== undefined && prompt !== null && typeof prompt !== 'string' && !Array.isArray(prompt)) {
+        throw invalidField('prompt', `must be a string or an array, got ${describe(prompt)}`);
+    }
+
+    const stop = req.value['stop'];
+    if (typeof stop !== 'string') {
+        req.stringArray('stop', 4);
+    }
+
+    return req.unknown(COMPLETIONS_FIELDS);
+}
+
+// ============================================================================
+// Anthropic Messages
+// ============================================================================
+
+const ANTHROPIC_BLOCK_TYPES = [
+    'text', 'image', 'tool_use', 'tool_result', 'thinking', 'redacted_thinking', 'document',
+] as const;
+
+const ANTHROPIC_FIELDS = new Set([
+    'model', 'messages', 'max_tokens', 'system', 'stream', 'temperature', 'top_p', 'top_k',
+    'stop_sequences', 'tools', 'tool_choice', 'metadata', 'thinking', 'service_tier',
+]);
+
+/**
+ * Validates an Anthropic messages request body.
+ * Returns the names of unrecognized top-level fields.
+ */
+export function validateAnthropicRequest(body: unknown): string[] {
+    const req = Fields.root(body, 'Request body');
+
+    req.string('model');
+    const maxTokens = req.number('max_tokens', { required: true, integer: true, min: 1 })!;
+    req.boolean('stream');
+    req.number('temperature', { min: 0, max: 1 });
+    req.number('top_p', { min: 0, max: 1 });
+    req.number('top_k', { integer: true, min: 0 });
+    req.stringArray('stop_sequences');
+
+    const system = req.value['system'];
+    if (typeof system !== 'string') {
+        req.objects('system')?.forEach((block) => {
+            block.oneOf('type', ['text'], true);
+            block.string('text', true);
+        });
+    }
+
+    const messages = req.objects('messages', { required: true, nonEmpty: true })!;
+    for (const message of messages) {
+        message.oneOf('role', ['user', 'assistant'], true);
+        if (typeof message.value['content'] === 'string') {
+            continue;
+        }
+        const blocks = message.objects('content', { required: true })!;
+        blocks.forEach(validateAnthropicBlock);
+    }
+
+    const toolNames = new Set<string>();
+    const tools = req.objects('tools');
+    tools?.forEach((tool) => {
+        // Server tools (e.g. web_search_20250305) carry a type and no input_schema
+        if (tool.has('type') && tool.value['type'] !== 'custom') {
+            tool.string('type');
+            toolNames.add(tool.string('name', true)!);
+            return;
+        }
+        const name = tool.string('name', true)!;
+        if (!TOOL_NAME_PATTERN.test(name)) {
+            throw invalidField(tool.at('name'), 'must match ^[a-zA-Z0-9_-]{1,64}$');
+        }
+        tool.string('description');
+        tool.object('input_schema', true);
+        toolNames.add(name);
+    });
+
+    const toolChoice = req.object('tool_choice');
+    if (toolChoice) {
+        const type = toolChoice.oneOf('type', ['auto', 'any', 'tool', 'none'], true);
+        const name = type === 'tool' ? toolChoice.string('name', true)! : undefined;
+        toolChoice.boolean('disable_parallel_tool_use');
+        if (type !== 'none' && !tools?.length) {
+            throw invalidField('tool_choice', 'requires tools to be provided');
+        }
+        if (name !== undefined && !toolNames.has(name)) {
+            throw invalidField(toolChoice.at('name'), `no tool named '${name}' in tools`);
+        }
+    }
+
+    req.object('metadata')?.string('user_id');
+
+    const thinking = req.object('thinking');
+    if (thinking) {
+        const type = thinking.oneOf('type', ['enabled', 'disabled'], true);
+        if (type === 'enabled') {
+            const budget = thinking.number('budget_tokens', { required: true, integer: true, min: 1024 })!;
+            if (budget >= maxTokens) {
+                throw invalidField(thinking.at('budget_tokens'), 'must be less than max_tokens');
+            }
+        }
+    }
+
+    return req.unknown(ANTHROPIC_FIELDS);
+}
+
+function validateAnthropicBlock(block: Fields): void {
+    const type = block.oneOf('type', ANTHROPIC_BLOCK_TYPES, true);
+    switch (type) {
+        case 'text':
+            block.string('text', true);
+            break;
+        case 'image':
+        case 'document':
+            block.object('source', true)!.string('type', true);
+            break;
+        case 'tool_use':
+            block.string('id', true);
+            block.string('name', true);
+            block.object('input', true);
+            break;
+        case 'tool_result':
+            block.string('tool_use_id', true);
+            block.boolean('is_error');
+            if (typeof block.value['content'] !== 'string') {
+                block.objects('content')?.forEach(validateAnthropicBlock);
+            }
+            break;
+        case 'thinking':
+            block.string('thinking', true);
+            block.string('signature', true);
+            break;
+        case 'redacted_thinking':
+            block.string('data', true);
+            break;
+    }
+}
+
+// ============================================================================
+// Responses API
+// ============================================================================
+
+const RESPONSES_FIELDS = new Set([
+    'model', 'input', 'instructions', 'tools', 'tool_choice', 'toolChoice', 'metadata',
+    'max_output_tokens', 'maxOutputTokens', 'temperature', 'top_p', 'topP', 'stream', 'store',
+    'previous_response_id', 'previousResponseId', 'reasoning', 'text', 'parallel_tool_calls',
+    'truncation', 'user', 'include', 'template',
+]);
+
+/**
+ * Validates a Responses API create request body.
+ * Accepts both the wire (snake_case) and camelCase spellings the frontdoor reads.
+ * Returns the names of unrecognized top-level fields.
+ */
+export function validateResponsesRequest(body: unknown): string[] {
+    const req = Fields.root(body, 'Request body');
+
+    // The frontdoor requires model once the app's default is applied
+    req.string('model');
+    req.string('instructions');
+    validateTemplateRef(req);
+    req.boolean('stream');
+    req.boolean('store');
+    req.number('temperature', { min: 0, max: 2 });
+    for (const key of ['top_p', 'topP']) {
+        req.number(key, { min: 0, max: 1 });
+    }
+    for (const key of ['max_output_tokens', 'maxOutputTokens']) {
+        req.number(key, { integer: true, min: 1 });
+    }
+    for (const key of ['previous_response_id', 'previousResponseId']) {
+        req.string(key);
+    }
+
+    const metadata = req.object('metadata');
+    if (metadata) {
+        for (const key of Object.keys(metadata.value)) {
+            metadata.string(key);
+        }
+    }
+
+    const input = req.value['input'];
+    if (input === undefined || input === null || input === '') {
+        throw invalidField('input', 'is required');
+    }
+    if (typeof input !== 'string') {
+        const items = req.objects('input', { nonEmpty: true })!;
+        for (const item of items) {
+            const type = item.oneOf('type', ['message', 'item_reference', 'function_call', 'function_call_output']);
+            if (type === undefined || type === 'message') {
+                item.oneOf('role', ['user', 'assistant', 'system', 'developer'], true);
+                if (typeof item.value['content'] !== 'string') {
+                    item.objects('content', { required: true })!
+                        .forEach((part) => part.string('type', true));
+                }
+            } else if (type === 'item_reference') {
+                item.string('id', true);
+            }
+        }
+    }
+
+    req.objects('tools')?.forEach((tool) => {
+        if (tool.string('type', true) === 'function') {
+            tool.object('function', true)!.string('name', true);
+        }
+    });
+
+    return req.unknown(RESPONSES_FIELDS);
+}
+
+// ============================================================================
+// Cohere Chat
+// ============================================================================
+
+const COHERE_ROLES = ['USER', 'CHATBOT', 'SYSTEM', 'TOOL'] as const;
+
+const COHERE_FIELDS = new Set([
+    'message', 'model', 'preamble', 'chat_history', 'stream', 'max_tokens', 'temperature', 'p',
+    'stop_sequences', 'documents', 'connectors',
+]);
+
+/**
+ * Validates a Cohere chat request body.
+ * Returns the names of unrecognized top-level fields.
+ */
+export function validateCohereRequest(body: unknown): string[] {
+    const req = Fields.root(body, 'Request body');
+
+    req.string('message', true);
+    req.string('model');
+    req.string('preamble');
+    req.boolean('stream');
+    req.number('max_tokens', { integer: true, min: 1 });
+    req.number('temperature', { min: 0, max: 5 });
+    req.number('p', { min: 0, max: 1 });
+    req.stringArray('stop_sequences', 5);
+
+    req.objects('chat_history')?.forEach((entry) => {
+        // Tool entries carry tool_results, which are not translated
+        const role = entry.oneOf('role', COHERE_ROLES, true);
+        entry.string('message', role !== 'TOOL');
+    });
+    req.objects('documents');
+    req.array('connectors');
+
+    return req.unknown(COHERE_FIELDS);
+}
@@ -0,0 +1,157 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openAIFrontdoor } from './frontdoors/index';
+import { completionsCodec, decodeCompletionsRequest } from './codecs/index';
+
+const usage = { promptTokens: 3, completionTokens: 2, totalTokens: 5 };
+
+function mockProvider() {
+    return {
+        name: 'mock',
+        apiType: 'openai',
+        complete: vi.fn(async (req: any) => ({
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'openai',
+            usage,
+            choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: ` after ${req.messages[0].content}` } }],
+        })),
+        stream: vi.fn(async function* (req: any) {
+            yield { type: 'message_start', role: 'assistant' };
+            yield { type: 'content_delta', contentDelta: `re:${req.messages[0].content}` };
+            yield { type: 'message_stop', finishReason: 'stop' };
+            yield { type: 'message_delta', usage };
+            yield { type: 'done' };
+        }),
+    };
+}
+
+function completions(body: unknown, provider = mockProvider(), app?: unknown) {
+    return openAIFrontdoor.handle({
+        request: new Request('http://localhost/v1/completions', {
+            method: 'POST',
+            body: JSON.stringify(body),
+        }),
+        provider,
+        app,
+        auth: { tenantId: 't', scopes: [], metadata: {} },
+        interactionId: 'int-1',
+    } as any);
+}
+
+async function readSSE(response: Response): Promise<any[]> {
+    const text = await response.text();
+    return text
+        .split('\n\n')
+        .filter((line) => line.startsWith('data: ') && line !== 'data: [DONE]')
+        .map((line) => JSON.parse(line.slice('data: '.length)));
+}
+
+describe('decodeCompletionsRequest', () => {
+    it('should decode a string prompt as a single user message', () => {
+        const decoded = decodeCompletionsRequest(JSON.stringify({
+            model: 'gpt-3.5-turbo-instruct', prompt: 'Say hi', max_tokens: 5, stop: '\n', echo: true,
+        }));
+
+        expect(decoded.requests).toHaveLength(1);
+        expect(decoded.requests[0]).toMatchObject({
+            model: 'gpt-3.5-turbo-instruct',
+            messages: [{ role: 'user', content: 'Say hi' }],
+            maxTokens: 5,
+            stop: ['\n'],
+            sourceAPIType: 'completions',
+        });
+        expect(decoded.echo).toBe(true);
+    });
+
+    it('should reject token ID prompts', () => {
+        expect(() => decodeCompletionsRequest(JSON.stringify({ model: 'm', prompt: [1, 2, 3] })))
+            .toThrow(/prompt\[0\]/);
+    });
+});
+
+describe('completions codec', () => {
+    it('should encode stream events in the legacy chunk shape', () => {
+        const chunk = JSON.parse(completionsCodec.encodeStreamEvent(
+            { type: 'content_delta', contentDelta: 'Hi', choiceIndex: 1 },
+            { id: 'cmpl-x', model: 'm', created: 1 },
+        ));
+
+        expect(chunk).toEqual({
+            id: 'cmpl-x',
+            object: 'text_completion',
+            created: 1,
+            model: 'm',
+            choices: [{ text: 'Hi', index: 1, logprobs: null, finish_reason: null }],
+        });
+    });
+});
+
+describe('OpenAI frontdoor /v1/completions', () => {
+    it('should serve a prompt through the chat path and encode a text completion', async () => {
+        const provider = mockProvider();
+        const result = await completions({ model: 'gpt-4o', prompt: 'Once', echo: true }, provider);
+
+        expect(result.response.status).toBe(200);
+        expect(provider.complete).toHaveBeenCalledWith(expect.objectContaining({
+            messages: [{ role: 'user', content: 'Once' }],
+            sourceAPIType: 'completions',
+        }));
+
+        const body = await result.response.json();
+        expect(body).toMatchObject({
+            id: 'cmpl-1',
+            object: 'text_completion',
+            choices: [{ text: 'Once after Once', index: 0, logprobs: null, finish_reason: 'stop' }],
+            usage: { prompt_tokens: 3, completion_tokens: 2, total_tokens: 5 },
+        });
+        expect(result.canonicalRequest?.sourceAPIType).toBe('completions');
+        expect(result.metadata).toMatchObject({ frontdoor_api: 'completions' });
+        expect(result.transformations?.[0]).toMatchObject({ stage: 'completions_prompt', codec: 'completions' });
+    });
+
+    it('should record unsupported legacy fields as warnings', async () => {
+        const result = await completions({ model: 'gpt-4o', prompt: 'x', suffix: 'end', logprobs: 2 });
+
+        expect(result.response.status).toBe(200);
+        expect(result.transformations?.[0]?.warnings).toHaveLength(2);
+    });
+
+    it('should reject array prompts unless fan-out is enabled', async () => {
+        const provider = mockProvider();
+        const result = await completions({ model: 'gpt-4o', prompt: ['a', 'b'] }, provider);
+
+        expect(result.response.status).toBe(400);
+        expect((await result.response.json()).error.param).toBe('prompt');
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+
+    it('should fan array prompts out into choices when enabled', async () => {
+        const provider = mockProvider();
+        const result = await completions(
+            { model: 'gpt-4o', prompt: ['a', 'b'] },
+            provider,
+            { name: 'legacy', frontdoor: 'openai', path: '/', fanOutPrompts: true },
+        );
+
+        const body = await result.response.json();
+        expect(provider.complete).toHaveBeenCalledTimes(2);
+        expect(body.choices.map((c: any) => [c.index, c.text])).toEqual([[0, ' after a'], [1, ' after b']]);
+        expect(body.usage.total_tokens).toBe(10);
+    });
+
+    it('should stream text deltas in the legacy chunk shape', async () => {
+        const result = await completions(
+            { model: 'gpt-4o', prompt: ['a', 'b'], stream: true },
+            mockProvider(),
+            { name: 'legacy', frontdoor: 'openai', path: '/', fanOutPrompts: true },
+        );
+
+        const chunks = await readSSE(result.response);
+        expect(chunks.every((c) => c.object === 'text_completion' && c.id === 'cmpl-int-1')).toBe(true);
+        expect(chunks.flatMap((c) => c.choices.map((ch: any) => [ch.index, ch.text, ch.finish_reason]))).toEqual([
+            [0, 're:a', null],
+            [0, '', 'stop'],
+            [1, 're:b', null],
+            [1, '', 'stop'],
+        ]);
+        expect(chunks.at(-1)).toMatchObject({ choices: [], usage: { total_tokens: 10 } });
+    });
+});
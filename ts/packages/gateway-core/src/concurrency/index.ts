@@ -0,0 +1,33 @@
+/**
+ * Tenant concurrency exports.
+ *
+ * @module concurrency
+ */
+
+export {
+    TenantScheduler,
+    ConcurrencyLimitError,
+    DEFAULT_QUEUE_SIZE,
+    DEFAULT_QUEUE_TIMEOUT_MS,
+    DEFAULT_LOW_PRIORITY_SHARE,
+    REQUEST_PRIORITIES,
+    type ConcurrencyLimits,
+    type TenantConcurrencyLimits,
+    type ConcurrencySlot,
+    type ConcurrencyStats,
+    type TenantConcurrencyStats,
+    type PriorityConcurrencyStats,
+} from './scheduler.js';
+
+export {
+    PRIORITY_HEADER,
+    PRIORITY_OVERRIDE_SCOPE,
+    PRIORITY_SCOPE_PREFIX,
+    classifyPriority,
+} from './priority.js';
+
+export {
+    ConcurrencyLimitedProvider,
+    withConcurrencyLimit,
+    type ConcurrencyObserver,
+} from './provider.js';
@@ -0,0 +1,62 @@
+/**
+ * Request priority classification.
+ *
+ * Each request's provider calls queue in one priority class: high, normal
+ * or low. The class comes from, in order of precedence, the
+ * X-Gateway-Priority header (honored only for keys with the
+ * priority:override scope, so ordinary clients can't jump the queue), a
+ * priority:<class> scope on the API key, and the app's priority. Anything
+ * else is normal.
+ *
+ * @module concurrency/priority
+ */
+
+import type { AppConfig, RequestPriority } from '../ports/config.js';
+import type { AuthContext } from '../ports/auth.js';
+import { REQUEST_PRIORITIES } from './scheduler.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Request header that sets the priority class, for trusted keys. */
+export const PRIORITY_HEADER = 'X-Gateway-Priority';
+
+/** Key scope that makes the priority header trusted. */
+export const PRIORITY_OVERRIDE_SCOPE = 'priority:override';
+
+/** Prefix of the key scope that sets a key's priority class (e.g. priority:low). */
+export const PRIORITY_SCOPE_PREFIX = 'priority:';
+
+// ============================================================================
+// Classification
+// ============================================================================
+
+/**
+ * Classifies a request. Unknown header or scope values are ignored.
+ */
+export function classifyPriority(
+    app: Pick<AppConfig, 'priority'> | undefined,
+    auth: Pick<AuthContext, 'scopes'>,
+    headers: Headers,
+): RequestPriority {
+    if (auth.scopes.includes(PRIORITY_OVERRIDE_SCOPE)) {
+        const requested = parsePriority(headers.get(PRIORITY_HEADER)?.trim().toLowerCase());
+        if (requested) {
+            return requested;
+        }
+    }
+    for (const scope of auth.scopes) {
+        const scoped = scope.startsWith(PRIORITY_SCOPE_PREFIX)
+            ? parsePriority(scope.slice(PRIORITY_SCOPE_PREFIX.length))
+            : undefined;
+        if (scoped) {
+            return scoped;
+        }
+    }
+    return app?.priority ?? 'normal';
+}
+
+function parsePriority(value: string | undefined): RequestPriority | undefined {
+    return REQUEST_PRIORITIES.find((priority) => priority === value);
+}
@@ -0,0 +1,117 @@
+/**
+ * Concurrency-limited providers.
+ *
+ * Holds a tenant scheduler slot for each provider call, queued in the
+ * request's priority class: acquired before the call and released when
+ * the response arrives or, for streams, when the stream ends.
+ *
+ * @module concurrency/provider
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { RequestPriority } from '../ports/config.js';
+import { ConcurrencyLimitError, type ConcurrencySlot, type TenantScheduler } from './scheduler.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Observes one request's scheduling.
+ */
+export interface ConcurrencyObserver {
+    /** Called when a call starts, with its queue wait and depth. */
+    onStart?: ((slot: ConcurrencySlot) => void) | undefined;
+
+    /** Called when a call is rejected for lack of capacity. */
+    onReject?: ((error: ConcurrencyLimitError) => void) | undefined;
+}
+
+// ============================================================================
+// Limited Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so each call holds a slot of one tenant's capacity.
+ */
+export class ConcurrencyLimitedProvider extends ProviderWrapper {
+    private readonly scheduler: TenantScheduler;
+    private readonly tenantId: string;
+    private readonly observer: ConcurrencyObserver;
+    private readonly priority: RequestPriority;
+
+    constructor(
+        inner: Provider,
+        scheduler: TenantScheduler,
+        tenantId: string,
+        observer: ConcurrencyObserver = {},
+        priority: RequestPriority = 'normal',
+    ) {
+        super(inner);
+        this.scheduler = scheduler;
+        this.tenantId = tenantId;
+        this.observer = observer;
+        this.priority = priority;
+    }
+
+    /**
+     * Completes a request once capacity allows.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const slot = await this.acquire(options);
+        try {
+            return await this.inner.complete(request, options);
+        } finally {
+            slot.release();
+        }
+    }
+
+    /**
+     * Streams a request once capacity allows, holding the slot until the
+     * stream ends.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const slot = await this.acquire(options);
+        try {
+            yield* this.inner.stream(request, options);
+        } finally {
+            slot.release();
+        }
+    }
+
+    private async acquire(options: ProviderCallOptions | undefined): Promise<ConcurrencySlot> {
+        try {
+            const slot = await this.scheduler.acquire(this.tenantId, this.name, options, this.priority);
+            this.observer.onStart?.(slot);
+            return slot;
+        } catch (error) {
+            if (error instanceof ConcurrencyLimitError) {
+                this.observer.onReject?.(error);
+            }
+            throw error;
+        }
+    }
+}
+
+/**
+ * Schedules a tenant's provider calls. Returns the provider unchanged when
+ * no concurrency limit is configured.
+ */
+export function withConcurrencyLimit(
+    provider: Provider,
+    scheduler: TenantScheduler,
+    tenantId: string,
+    observer?: ConcurrencyObserver,
+    priority?: RequestPriority,
+): Provider {
+    if (!scheduler.enabled) {
+        return provider;
+    }
+    return new ConcurrencyLimitedProvider(provider, scheduler, tenantId, observer, priority);
+}
@@ -0,0 +1,329 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import type { ConcurrencyConfig } from '../ports/index';
+import type { CanonicalRequest, CanonicalResponse } from '../domain/types';
+import { TenantScheduler, ConcurrencyLimitError, withConcurrencyLimit, classifyPriority } from './index';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const PROVIDER_MS = 30;
+
+const sleep = (ms: number) => new Promise((resolve) => setTimeout(resolve, ms));
+
+/**
+ * A provider that takes PROVIDER_MS per call and tracks calls in flight
+ * per tenant.
+ */
+class TrackingProvider extends ScriptedProvider {
+    readonly inFlight: Record<string, number> = {};
+    readonly peak: Record<string, number> = {};
+    total = 0;
+    peakTotal = 0;
+
+    constructor() {
+        super('mock', { text: 'ok', delayMs: PROVIDER_MS });
+    }
+
+    override async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+        const tenant = request.tenantId;
+        this.inFlight[tenant] = (this.inFlight[tenant] ?? 0) + 1;
+        this.peak[tenant] = Math.max(this.peak[tenant] ?? 0, this.inFlight[tenant]!);
+        this.peakTotal = Math.max(this.peakTotal, ++this.total);
+        try {
+            return await super.complete(request);
+        } finally {
+            this.inFlight[tenant]!--;
+            this.total--;
+        }
+    }
+}
+
+describe('Tenant concurrency under load', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    /**
+     * Gateway over a TrackingProvider. API keys are the tenant ID, then any
+     * scopes after '+'; the /batch app is low priority.
+     */
+    async function setup(concurrency: ConcurrencyConfig) {
+        const provider = new TrackingProvider();
+        const logger = spyLogger();
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .app({ name: 'batch', frontdoor: 'openai', path: '/batch', priority: 'low' })
+            .provider(provider)
+            .config({ concurrency })
+            .options({
+                auth: {
+                    authenticate: async (token: string) => {
+                        const [tenantId, ...scopes] = token.split('+');
+                        return { tenantId: tenantId!, scopes, metadata: {} };
+                    },
+                    getTenant: async () => null,
+                },
+                logger,
+            })
+            .start();
+        const gw = gateway;
+        const chat = (tenant: string, path = '/v1', headers: Record<string, string> = {}) => gw.post(
+            `${path}/chat/completions`,
+            { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] },
+            { Authorization: `Bearer ${tenant}`, ...headers },
+        );
+        const timed = async (tenant: string, path?: string, headers?: Record<string, string>) => {
+            const start = Date.now();
+            const response = await chat(tenant, path, headers);
+            await response.arrayBuffer();
+            return { status: response.status, ms: Date.now() - start };
+        };
+        return { gateway: gw.gateway, logger, peak: provider.peak, chat, timed, peakTotal: () => provider.peakTotal };
+    }
+
+    it('should hold a noisy tenant to its share while a quiet tenant stays fast', async () => {
+        const { gateway, peak, timed, peakTotal } = await setup({ maxInFlight: 4, tenantMaxInFlight: 3, queueTimeout: '5s' });
+        await gateway.reload();
+
+        const noisy = Array.from({ length: 30 }, () => timed('noisy'));
+        await sleep(5);
+        const quiet: number[] = [];
+        for (let i = 0; i < 5; i++) {
+            const { status, ms } = await timed('quiet');
+            expect(status).toBe(200);
+            quiet.push(ms);
+        }
+        const results = await Promise.all(noisy);
+
+        expect(results.every((r) => r.status === 200)).toBe(true);
+        expect(peak.noisy).toBe(3);
+        expect(peakTotal()).toBeLessThanOrEqual(4);
+        // The quiet tenant never waits behind the noisy tenant's backlog
+        expect(Math.max(...quiet)).toBeLessThan(PROVIDER_MS * 2.5);
+        expect(Math.max(...results.map((r) => r.ms))).toBeGreaterThan(PROVIDER_MS * 8);
+        expect(gateway.concurrencyStats()!.tenants).toMatchObject([
+            { tenantId: 'noisy', inFlight: 0, queued: 0, admitted: 30, rejected: 0 },
+            { tenantId: 'quiet', inFlight: 0, queued: 0, admitted: 5, rejected: 0 },
+        ]);
+    });
+
+    it('should reject with 429 and Retry-After once the tenant queue is full', async () => {
+        const { gateway, chat, timed } = await setup({ tenantMaxInFlight: 1, queueSize: 2, queueTimeout: '5s' });
+        await gateway.reload();
+
+        const admitted = [timed('noisy'), timed('noisy'), timed('noisy')];
+        await sleep(5);
+        const rejected = await chat('noisy');
+
+        expect(rejected.status).toBe(429);
+        expect(rejected.headers.get('Retry-After')).toBe('5');
+        expect((await rejected.json()).error.code).toBe('concurrency_limit_exceeded');
+        expect((await Promise.all(admitted)).map((r) => r.status)).toEqual([200, 200, 200]);
+        expect(gateway.concurrencyStats()!.tenants[0]).toMatchObject({ admitted: 3, rejected: 1 });
+    });
+
+    it('should record queue wait and depth in the interaction timings', async () => {
+        const { gateway, logger, chat } = await setup({ tenantMaxInFlight: 1 });
+        await gateway.reload();
+
+        await Promise.all([chat('acme'), chat('acme')]);
+
+        await vi.waitFor(() => {
+            const timings = logged(logger.info, 'interaction_timings');
+            expect(timings).toHaveLength(2);
+            expect(timings).toContainEqual(expect.objectContaining({ queueWaitMs: 0, queueDepth: 0 }));
+            const queued = timings.find((t) => t.queueDepth === 1);
+            expect(queued!.queueWaitMs).toBeGreaterThanOrEqual(PROVIDER_MS - 5);
+        });
+    });
+
+    it('should keep high priority waits bounded while low priority queues', async () => {
+        const { gateway, logger, timed } = await setup({ maxInFlight: 2, queueTimeout: '5s' });
+        await gateway.reload();
+
+        const low = Array.from({ length: 30 }, () => timed('acme', '/batch'));
+        await sleep(5);
+        const high: number[] = [];
+        for (let i = 0; i < 5; i++) {
+            const { status, ms } = await timed('acme+priority:override', '/v1', { 'X-Gateway-Priority': 'high' });
+            expect(status).toBe(200);
+            high.push(ms);
+        }
+        const queuedLow = gateway.concurrencyStats()!.priorities.find((p) => p.priority === 'low')!.queued;
+        const results = await Promise.all(low);
+
+        expect(results.every((r) => r.status === 200)).toBe(true);
+        // Each high priority call waits for at most the next free slot,
+        // never behind the low priority backlog
+        expect(Math.max(...high)).toBeLessThan(PROVIDER_MS * 3);
+        expect(queuedLow).toBeGreaterThan(0);
+        const [highStats, normalStats, lowStats] = gateway.concurrencyStats()!.priorities;
+        expect(highStats).toMatchObject({ priority: 'high', admitted: 5, rejected: 0 });
+        expect(highStats!.waitMs.p95).toBeLessThanOrEqual(PROVIDER_MS * 1.5);
+        expect(normalStats).toMatchObject({ priority: 'normal', admitted: 0 });
+        expect(lowStats).toMatchObject({ priority: 'low', queued: 0, admitted: 30 });
+        expect(lowStats!.waitMs.p95).toBeGreaterThan(PROVIDER_MS * 8);
+        expect(logger.info).toHaveBeenCalledWith('interaction_metadata', { priority: 'high' });
+        expect(logger.info).toHaveBeenCalledWith('interaction_metadata', { priority: 'low' });
+    });
+
+    it('should not schedule calls without a configured cap', async () => {
+        const { gateway } = await setup({ queueSize: 10 });
+        await gateway.reload();
+
+        expect(gateway.concurrencyStats()).toBeUndefined();
+    });
+});
+
+describe('TenantScheduler', () => {
+    it('should lend idle capacity to a busy tenant up to the global cap', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ maxInFlight: 4 });
+
+        const busy = await Promise.all([1, 2, 3, 4].map(() => scheduler.acquire('a', 'p')));
+        const queuedA = scheduler.acquire('a', 'p');
+        const queuedB = scheduler.acquire('b', 'p');
+        busy[0]!.release();
+
+        // The freed slot goes to the tenant with nothing in flight
+        const slot = await queuedB;
+        expect(slot.queueDepth).toBe(1);
+        expect(scheduler.stats().tenants).toMatchObject([
+            { tenantId: 'a', inFlight: 3, queued: 1 },
+            { tenantId: 'b', inFlight: 1, queued: 0 },
+        ]);
+
+        busy[1]!.release();
+        await queuedA;
+        expect(scheduler.stats()).toMatchObject({ inFlight: 4, queued: 0, maxInFlight: 4 });
+    });
+
+    it('should share freed capacity by weight', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ maxInFlight: 4, tenants: { gold: { weight: 3 } } });
+        await Promise.all([scheduler.acquire('gold', 'p'), scheduler.acquire('free', 'p')]);
+        const full = await Promise.all([scheduler.acquire('gold', 'p'), scheduler.acquire('gold', 'p')]);
+        const order: string[] = [];
+        const next = ['free', 'gold'].map((tenant) => scheduler.acquire(tenant, 'p').then(() => order.push(tenant)));
+
+        full[0]!.release();
+        full[1]!.release();
+        await Promise.all(next);
+
+        // gold's 2 in flight at weight 3 is a smaller share than free's 1
+        // at weight 1, so gold goes first despite queueing later
+        expect(order).toEqual(['gold', 'free']);
+    });
+
+    it('should cap calls per tenant and provider', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ tenantProviderMaxInFlight: 1 });
+
+        const openai = await scheduler.acquire('a', 'openai');
+        const anthropic = await scheduler.acquire('a', 'anthropic');
+        let second = false;
+        const queued = scheduler.acquire('a', 'openai').then(() => {
+            second = true;
+        });
+        await sleep(0);
+
+        expect(second).toBe(false);
+        anthropic.release();
+        await sleep(0);
+        expect(second).toBe(false);
+        openai.release();
+        await queued;
+        expect(second).toBe(true);
+    });
+
+    it('should time out queued calls and leave on abort', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ tenantMaxInFlight: 1, queueTimeoutMs: 20 });
+        await scheduler.acquire('a', 'p');
+
+        const timedOut = scheduler.acquire('a', 'p');
+        await expect(timedOut).rejects.toBeInstanceOf(ConcurrencyLimitError);
+
+        const controller = new AbortController();
+        const aborted = scheduler.acquire('a', 'p', { signal: controller.signal });
+        controller.abort(new Error('client went away'));
+        await expect(aborted).rejects.toThrow('client went away');
+
+        const expired = scheduler.acquire('a', 'p', { deadline: Date.now() + 5 });
+        await expect(expired).rejects.toMatchObject({ code: 'deadline_exceeded' });
+        expect(scheduler.stats().tenants[0]).toMatchObject({ queued: 0, rejected: 1 });
+    });
+
+    it('should serve classes in priority order, giving low priority its share', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ maxInFlight: 1, lowPriorityShare: 0.5 });
+        let held = await scheduler.acquire('a', 'p');
+        const order: string[] = [];
+        const queued = [
+            ['low', 'l1'], ['normal', 'n1'], ['high', 'h1'], ['high', 'h2'], ['normal', 'n2'], ['low', 'l2'],
+        ].map(([priority, name]) => scheduler.acquire('a', 'p', {}, priority as any).then((slot) => {
+            order.push(name!);
+            held = slot;
+        }));
+
+        for (let i = 0; i < queued.length; i++) {
+            held.release();
+            await sleep(0);
+        }
+        await Promise.all(queued);
+
+        // Low priority earns half a call for each call started while it
+        // waits, and otherwise classes go strictly in order
+        expect(order).toEqual(['h1', 'h2', 'l1', 'n1', 'l2', 'n2']);
+    });
+
+    it('should time out each priority class on its own queue timeout', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ maxInFlight: 1, queueTimeoutMs: 5000, priorityQueueTimeoutMs: { low: 20 } });
+        const held = await scheduler.acquire('a', 'p');
+
+        const high = scheduler.acquire('a', 'p', {}, 'high');
+        await expect(scheduler.acquire('a', 'p', {}, 'low')).rejects.toMatchObject({ retryAfterSeconds: 1 });
+        expect(scheduler.stats().priorities).toMatchObject([
+            { priority: 'high', queued: 1, rejected: 0 },
+            { priority: 'normal', queued: 0, rejected: 0 },
+            { priority: 'low', queued: 0, rejected: 1 },
+        ]);
+        held.release();
+        await high;
+    });
+
+    it('should hold a stream slot until the stream ends', async () => {
+        const scheduler = new TenantScheduler();
+        scheduler.configure({ tenantMaxInFlight: 1 });
+        const provider = withConcurrencyLimit({
+            name: 'mock',
+            apiType: 'openai',
+            complete: vi.fn(),
+            stream: async function* () {
+                yield { type: 'content_delta', contentDelta: 'a' };
+                yield { type: 'done' };
+            },
+        } as any, scheduler, 'acme');
+
+        const stream = provider.stream({} as any);
+        await stream.next();
+        expect(scheduler.stats().inFlight).toBe(1);
+        for await (const _ of stream) { /* drain */ }
+        expect(scheduler.stats().inFlight).toBe(0);
+    });
+});
+
+describe('classifyPriority', () => {
+    const app = { priority: 'low' as const };
+    const headers = new Headers({ 'X-Gateway-Priority': 'high' });
+
+    it('should honor the header only for trusted keys, then key scopes, then the app', () => {
+        expect(classifyPriority(app, { scopes: ['priority:override'] }, headers)).toBe('high');
+        expect(classifyPriority(app, { scopes: [] }, headers)).toBe('low');
+        expect(classifyPriority(app, { scopes: ['priority:normal'] }, headers)).toBe('normal');
+        expect(classifyPriority(undefined, { scopes: ['priority:override'] }, new Headers({ 'X-Gateway-Priority': 'urgent' })))
+            .toBe('normal');
+    });
+});
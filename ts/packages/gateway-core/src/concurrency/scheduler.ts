@@ -0,0 +1,475 @@
+/**
+ * Per-tenant fair queuing for provider calls.
+ *
+ * Caps the provider calls in flight per tenant, optionally per tenant and
+ * provider, and across all tenants. A call that can't start waits in its
+ * tenant's bounded FIFO queue for up to the queue timeout (which may
+ * differ by priority class), then fails with 429. Freed capacity goes to
+ * the highest priority class with a call that can start, except that low
+ * priority calls get a configured share of it while they wait, so they
+ * slow down under contention but never starve. Within a class it goes to
+ * the waiting tenant with the fewest calls in flight for its weight, so a
+ * noisy tenant can't crowd a quiet one out, while capacity idle tenants
+ * aren't using stays available to busy ones up to the global cap.
+ *
+ * @module concurrency/scheduler
+ */
+
+import type { ProviderCallOptions } from '../ports/provider.js';
+import type { RequestPriority } from '../ports/config.js';
+import { APIError, errUpstreamTimeout } from '../domain/errors.js';
+import { percentile, type Percentiles } from '../utils/timings.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default number of calls each tenant may have waiting. */
+export const DEFAULT_QUEUE_SIZE = 100;
+
+/** Default time a call waits for capacity (ms). */
+export const DEFAULT_QUEUE_TIMEOUT_MS = 30_000;
+
+/** Default share of freed capacity low priority calls get while higher classes wait. */
+export const DEFAULT_LOW_PRIORITY_SHARE = 0.1;
+
+/** Priority classes, highest first. */
+export const REQUEST_PRIORITIES: readonly RequestPriority[] = ['high', 'normal', 'low'];
+
+/** Recent queue waits kept per tenant, and per priority class, for percentiles. */
+const WAIT_WINDOW = 1000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Concurrency limits. Unset caps are unlimited.
+ */
+export interface ConcurrencyLimits {
+    /** Provider calls in flight across all tenants. */
+    maxInFlight?: number | undefined;
+
+    /** Provider calls in flight per tenant. */
+    tenantMaxInFlight?: number | undefined;
+
+    /** Provider calls in flight per tenant and provider. */
+    tenantProviderMaxInFlight?: number | undefined;
+
+    /** Calls each tenant may have waiting (default: 100). */
+    queueSize?: number | undefined;
+
+    /** How long a call waits for capacity before failing (ms, default: 30s). */
+    queueTimeoutMs?: number | undefined;
+
+    /** Per priority class overrides of queueTimeoutMs. */
+    priorityQueueTimeoutMs?: Partial<Record<RequestPriority, number>> | undefined;
+
+    /** Share of freed capacity low priority calls get while higher classes wait (default: 0.1). */
+    lowPriorityShare?: number | undefined;
+
+    /** Per-tenant in-flight caps and share weights (default weight: 1). */
+    tenants?: Record<string, TenantConcurrencyLimits> | undefined;
+}
+
+/**
+ * One tenant's concurrency overrides.
+ */
+export interface TenantConcurrencyLimits {
+    /** Provider calls in flight (overrides tenantMaxInFlight). */
+    maxInFlight?: number | undefined;
+
+    /** Share of freed capacity relative to other waiting tenants. */
+    weight?: number | undefined;
+}
+
+/**
+ * Capacity held by one provider call. Release it once the response, or
+ * the stream, has finished.
+ */
+export interface ConcurrencySlot {
+    /** Time spent queued (ms). */
+    waitMs: number;
+
+    /** Calls waiting in the tenant's queue when this one joined it, itself included; 0 when it started at once. */
+    queueDepth: number;
+
+    /** Returns the capacity. Safe to call more than once. */
+    release(): void;
+}
+
+/**
+ * Concurrency counters for one tenant.
+ */
+export interface TenantConcurrencyStats {
+    tenantId: string;
+
+    /** Provider calls in flight. */
+    inFlight: number;
+
+    /** Calls waiting. */
+    queued: number;
+
+    /** Calls started. */
+    admitted: number;
+
+    /** Calls failed because the queue was full or the wait timed out. */
+    rejected: number;
+
+    /** Queue wait over recent started calls (ms). */
+    waitMs: Percentiles;
+}
+
+/**
+ * Concurrency counters for one priority class, across tenants.
+ */
+export interface PriorityConcurrencyStats {
+    priority: RequestPriority;
+
+    /** Calls waiting. */
+    queued: number;
+
+    /** Calls started. */
+    admitted: number;
+
+    /** Calls failed because the queue was full or the wait timed out. */
+    rejected: number;
+
+    /** Queue wait over recent started calls (ms). */
+    waitMs: Percentiles;
+}
+
+/**
+ * Concurrency counters for the admin stats endpoint.
+ */
+export interface ConcurrencyStats {
+    /** Provider calls in flight across all tenants. */
+    inFlight: number;
+
+    /** Calls waiting across all tenants. */
+    queued: number;
+
+    /** Configured global cap, when there is one. */
+    maxInFlight?: number | undefined;
+
+    /** Per tenant, sorted by tenant ID. */
+    tenants: TenantConcurrencyStats[];
+
+    /** Per priority class, highest first. */
+    priorities: PriorityConcurrencyStats[];
+}
+
+/**
+ * A call rejected for lack of capacity (429).
+ */
+export class ConcurrencyLimitError extends APIError {
+    /** Seconds the client should wait before retrying. */
+    readonly retryAfterSeconds: number;
+
+    constructor(message: string, retryAfterSeconds: number) {
+        super('rate_limit', message, { code: 'concurrency_limit_exceeded' });
+        this.retryAfterSeconds = retryAfterSeconds;
+    }
+}
+
+interface Waiter {
+    provider: string;
+    priority: RequestPriority;
+    queueDepth: number;
+    enqueuedAt: number;
+    grant(slot: ConcurrencySlot): void;
+}
+
+interface TenantState {
+    inFlight: number;
+    byProvider: Map<string, number>;
+    queue: Waiter[];
+    admitted: number;
+    rejected: number;
+    waits: number[];
+}
+
+interface PriorityState {
+    admitted: number;
+    rejected: number;
+    waits: number[];
+}
+
+interface Candidate {
+    state: TenantState;
+    index: number;
+    load: number;
+}
+
+// ============================================================================
+// Scheduler
+// ============================================================================
+
+/**
+ * Admits provider calls under per-tenant and global concurrency caps.
+ * Limits can be changed at any time; calls in flight keep their slots.
+ */
+export class TenantScheduler {
+    private limits: ConcurrencyLimits = {};
+    private readonly tenants = new Map<string, TenantState>();
+    private readonly priorities = new Map<RequestPriority, PriorityState>(
+        REQUEST_PRIORITIES.map((priority) => [priority, { admitted: 0, rejected: 0, waits: [] }]),
+    );
+    private inFlight = 0;
+    /** Low priority grants owed; one is due whenever this reaches 1. */
+    private lowCredit = 0;
+    private readonly now: () => number;
+
+    constructor(options: { now?: (() => number) | undefined } = {}) {
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Whether any cap is configured. Calls need not be scheduled otherwise.
+     */
+    get enabled(): boolean {
+        const { maxInFlight, tenantMaxInFlight, tenantProviderMaxInFlight, tenants } = this.limits;
+        return maxInFlight !== undefined
+            || tenantMaxInFlight !== undefined
+            || tenantProviderMaxInFlight !== undefined
+            || Object.values(tenants ?? {}).some((t) => t.maxInFlight !== undefined);
+    }
+
+    /**
+     * Replaces the limits. Raised caps admit waiting calls at once.
+     */
+    configure(limits: ConcurrencyLimits | undefined): void {
+        this.limits = limits ?? {};
+        this.dispatch();
+    }
+
+    /**
+     * Waits for capacity for a tenant's call to a provider. Fails with a
+     * ConcurrencyLimitError when the tenant's queue is full or the wait
+     * times out, and with the abort reason or a deadline error when the
+     * request ends first.
+     */
+    acquire(
+        tenantId: string,
+        provider: string,
+        call: ProviderCallOptions = {},
+        priority: RequestPriority = 'normal',
+    ): Promise<ConcurrencySlot> {
+        const state = this.tenant(tenantId);
+        if (this.canStart(tenantId, state, provider)) {
+            return Promise.resolve(this.start(state, provider, priority, 0, 0));
+        }
+
+        const queueSize = this.limits.queueSize ?? DEFAULT_QUEUE_SIZE;
+        if (state.queue.length >= queueSize) {
+            this.reject(state, priority);
+            return Promise.reject(this.rejection(`Too many provider calls queued for this tenant (${queueSize})`, priority));
+        }
+        if (call.signal?.aborted) {
+            return Promise.reject(call.signal.reason);
+        }
+
+        const timeoutMs = this.queueTimeoutMs(priority);
+        const remaining = call.deadline !== undefined ? call.deadline - this.now() : undefined;
+        const byDeadline = remaining !== undefined && remaining < timeoutMs;
+        return new Promise<ConcurrencySlot>((resolve, reject) => {
+            const leave = (error: unknown): void => {
+                state.queue.splice(state.queue.indexOf(waiter), 1);
+                cleanup();
+                reject(error);
+            };
+            const onAbort = (): void => leave(call.signal!.reason);
+            const timer = setTimeout(() => {
+                if (byDeadline) {
+                    leave(errUpstreamTimeout('Gateway deadline passed while waiting for provider capacity', 'deadline_exceeded'));
+                    return;
+                }
+                this.reject(state, priority);
+                leave(this.rejection(`Timed out after ${timeoutMs}ms waiting for provider capacity`, priority));
+            }, Math.max(byDeadline ? remaining : timeoutMs, 1));
+            const cleanup = (): void => {
+                clearTimeout(timer);
+                call.signal?.removeEventListener('abort', onAbort);
+            };
+            const waiter: Waiter = {
+                provider,
+                priority,
+                queueDepth: state.queue.length + 1,
+                enqueuedAt: this.now(),
+                grant: (slot) => {
+                    cleanup();
+                    resolve(slot);
+                },
+            };
+            state.queue.push(waiter);
+            call.signal?.addEventListener('abort', onAbort, { once: true });
+        });
+    }
+
+    /**
+     * Returns the counters.
+     */
+    stats(): ConcurrencyStats {
+        let queued = 0;
+        const queuedByPriority = new Map<RequestPriority, number>();
+        const tenants: TenantConcurrencyStats[] = [];
+        for (const [tenantId, state] of this.tenants) {
+            queued += state.queue.length;
+            for (const waiter of state.queue) {
+                queuedByPriority.set(waiter.priority, (queuedByPriority.get(waiter.priority) ?? 0) + 1);
+            }
+            tenants.push({
+                tenantId,
+                inFlight: state.inFlight,
+                queued: state.queue.length,
+                admitted: state.admitted,
+                rejected: state.rejected,
+                waitMs: waitPercentiles(state.waits),
+            });
+        }
+        return {
+            inFlight: this.inFlight,
+            queued,
+            maxInFlight: this.limits.maxInFlight,
+            tenants: tenants.sort((a, b) => a.tenantId.localeCompare(b.tenantId)),
+            priorities: REQUEST_PRIORITIES.map((priority) => {
+                const state = this.priorities.get(priority)!;
+                return {
+                    priority,
+                    queued: queuedByPriority.get(priority) ?? 0,
+                    admitted: state.admitted,
+                    rejected: state.rejected,
+                    waitMs: waitPercentiles(state.waits),
+                };
+            }),
+        };
+    }
+
+    private tenant(tenantId: string): TenantState {
+        let state = this.tenants.get(tenantId);
+        if (!state) {
+            state = { inFlight: 0, byProvider: new Map(), queue: [], admitted: 0, rejected: 0, waits: [] };
+            this.tenants.set(tenantId, state);
+        }
+        return state;
+    }
+
+    private canStart(tenantId: string, state: TenantState, provider: string): boolean {
+        const { maxInFlight, tenantMaxInFlight, tenantProviderMaxInFlight, tenants } = this.limits;
+        if (maxInFlight !== undefined && this.inFlight >= maxInFlight) {
+            return false;
+        }
+        const tenantMax = tenants?.[tenantId]?.maxInFlight ?? tenantMaxInFlight;
+        if (tenantMax !== undefined && state.inFlight >= tenantMax) {
+            return false;
+        }
+        return tenantProviderMaxInFlight === undefined
+            || (state.byProvider.get(provider) ?? 0) < tenantProviderMaxInFlight;
+    }
+
+    private start(
+        state: TenantState,
+        provider: string,
+        priority: RequestPriority,
+        waitMs: number,
+        queueDepth: number,
+    ): ConcurrencySlot {
+        this.inFlight++;
+        state.inFlight++;
+        state.byProvider.set(provider, (state.byProvider.get(provider) ?? 0) + 1);
+        const counters = this.priorities.get(priority)!;
+        for (const counted of [state, counters]) {
+            counted.admitted++;
+            counted.waits.push(waitMs);
+            if (counted.waits.length > WAIT_WINDOW) {
+                counted.waits.shift();
+            }
+        }
+
+        let released = false;
+        return {
+            waitMs,
+            queueDepth,
+            release: () => {
+                if (released) return;
+                released = true;
+                this.inFlight--;
+                state.inFlight--;
+                const remaining = state.byProvider.get(provider)! - 1;
+                if (remaining > 0) {
+                    state.byProvider.set(provider, remaining);
+                } else {
+                    state.byProvider.delete(provider);
+                }
+                this.dispatch();
+            },
+        };
+    }
+
+    /**
+     * Starts waiting calls while capacity allows, each time from the
+     * highest priority class with a call that can start, or from low
+     * priority when its share is due. Every call started while a low
+     * priority call could have started earns low priority its share of a
+     * call; each low priority call started spends one.
+     */
+    private dispatch(): void {
+        const share = this.limits.lowPriorityShare ?? DEFAULT_LOW_PRIORITY_SHARE;
+        for (;;) {
+            const low = this.candidate('low');
+            const next = low && this.lowCredit >= 1 ? low : this.candidate('high') ?? this.candidate('normal') ?? low;
+            if (!next) return;
+            if (low) {
+                this.lowCredit += share - (next === low ? 1 : 0);
+            }
+
+            const [waiter] = next.state.queue.splice(next.index, 1);
+            waiter!.grant(this.start(
+                next.state,
+                waiter!.provider,
+                waiter!.priority,
+                this.now() - waiter!.enqueuedAt,
+                waiter!.queueDepth,
+            ));
+        }
+    }
+
+    /**
+     * The next call of a priority class to start: from the tenant with the
+     * fewest calls in flight for its weight (earliest waiter on ties), the
+     * first call of the class in its queue that can start.
+     */
+    private candidate(priority: RequestPriority): Candidate | undefined {
+        let next: Candidate | undefined;
+        for (const [tenantId, state] of this.tenants) {
+            const index = state.queue.findIndex((w) => w.priority === priority && this.canStart(tenantId, state, w.provider));
+            if (index < 0) continue;
+            const load = state.inFlight / (this.limits.tenants?.[tenantId]?.weight ?? 1);
+            if (!next || load < next.load
+                || (load === next.load && state.queue[index]!.enqueuedAt < next.state.queue[next.index]!.enqueuedAt)) {
+                next = { state, index, load };
+            }
+        }
+        return next;
+    }
+
+    private queueTimeoutMs(priority: RequestPriority): number {
+        return this.limits.priorityQueueTimeoutMs?.[priority] ?? this.limits.queueTimeoutMs ?? DEFAULT_QUEUE_TIMEOUT_MS;
+    }
+
+    private reject(state: TenantState, priority: RequestPriority): void {
+        state.rejected++;
+        this.priorities.get(priority)!.rejected++;
+    }
+
+    private rejection(message: string, priority: RequestPriority): ConcurrencyLimitError {
+        return new ConcurrencyLimitError(message, Math.max(1, Math.ceil(this.queueTimeoutMs(priority) / 1000)));
+    }
+}
+
+function waitPercentiles(waits: number[]): Percentiles {
+    const sorted = [...waits].sort((a, b) => a - b);
+    return sorted.length > 0
+        ? { p50: percentile(sorted, 50), p95: percentile(sorted, 95), p99: percentile(sorted, 99) }
+        : { p50: 0, p95: 0, p99: 0 };
+}
@@ -0,0 +1,131 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/** Webhook answers by stage name, taken from the URL path. */
+type Answers = Record<string, object>;
+
+const request = { app: 'chat', model: 'claude-sonnet', messages: [{ role: 'user', content: 'Hi' }] };
+
+describe('Console execute', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(answers: Answers = {}) {
+        const provider = new ScriptedProvider('claude', { text: 'Hello' }, 'anthropic');
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                pipeline: {
+                    stages: [
+                        { name: 'tag', type: 'pre', url: 'http://hooks/tag' },
+                        { name: 'guard', type: 'pre', url: 'http://hooks/guard', order: 1 },
+                        { name: 'audit', type: 'post', url: 'http://hooks/audit' },
+                    ],
+                },
+            })
+            .provider(provider)
+            .webhook((url) => answers[new URL(url).pathname.slice(1)] ?? { action: 'allow' })
+            .start();
+        const gw = gateway.gateway;
+        const admin = new AdminHandler({ console: (request) => gw.consoleExecute(request), consoleRate: { limit: 2, windowMs: 60_000 } });
+        const execute = (body: object) => admin.handle(new Request('http://localhost/api/console/execute', {
+            method: 'POST',
+            body: JSON.stringify(body),
+        }));
+        return { gateway: gw, provider, published: gateway.published, execute };
+    }
+
+    it('should return every representation of a dry run without calling the provider', async () => {
+        const { provider, published, execute } = await setup({ tag: { action: 'modify', request: { temperature: 0.2 } } });
+
+        const response = await execute({ ...request, dry_run: true });
+
+        expect(response.status).toBe(200);
+        const result = await response.json();
+        expect(result).toMatchObject({
+            dry_run: true,
+            raw: { model: 'claude-sonnet', stream: false },
+            canonical: { model: 'claude-sonnet', tenantId: 'console' },
+            pipeline: {
+                stages: [{ stage: 'tag', action: 'modify' }, { stage: 'guard', action: 'continue' }],
+                outcome: 'continue',
+            },
+            routing: { provider: 'claude', model: 'claude-sonnet' },
+            planned: { temperature: 0.2 },
+            provider_request: { model: 'claude-sonnet', temperature: 0.2, messages: [{ role: 'user' }] },
+        });
+        expect(result.canonical.temperature).toBeUndefined();
+        expect(result.response).toBeUndefined();
+        expect(provider.requests).toHaveLength(0);
+        expect(published).toHaveLength(0);
+    });
+
+    it('should execute and return the response chain, flagged and kept out of usage reports', async () => {
+        const { gateway, provider, published, execute } = await setup();
+
+        const result = await (await execute(request)).json();
+
+        expect(provider.requests).toHaveLength(1);
+        expect(result.response).toMatchObject({
+            canonical: { usage: { totalTokens: 15 } },
+            post_pipeline: [{ stage: 'audit', action: 'continue' }],
+            body: { object: 'chat.completion', choices: [{ message: { content: 'Hello' } }] },
+        });
+        expect(published).toHaveLength(1);
+        expect(published[0]).toMatchObject({
+            interactionId: result.interaction_id,
+            data: { origin: 'console', providerName: 'claude' },
+        });
+        const report = await gateway.usageReports.report('console', { start: '2000-01-01', end: '2100-01-01' });
+        expect(report.totals.requests).toBe(0);
+    });
+
+    it('should report a pipeline denial without encoding a provider request', async () => {
+        const { provider, execute } = await setup({ guard: { action: 'deny', reason: 'blocked' } });
+
+        const result = await (await execute(request)).json();
+
+        expect(result.pipeline).toMatchObject({ outcome: 'deny', stages: [{ stage: 'tag' }, { stage: 'guard', action: 'deny' }] });
+        expect(result.error).toBe('blocked');
+        expect(result.provider_request).toBeUndefined();
+        expect(provider.requests).toHaveLength(0);
+    });
+
+    it('should reject invalid requests and rate limit callers', async () => {
+        const { execute } = await setup();
+
+        expect((await execute({ app: 'chat', messages: [] })).status).toBe(400);
+        expect((await execute({ ...request, stream: true })).status).toBe(400);
+        expect((await execute({ ...request, app: 'missing', dry_run: true })).status).toBe(404);
+        await execute({ ...request, dry_run: true });
+        const limited = await execute({ ...request, dry_run: true });
+
+        expect(limited.status).toBe(429);
+        expect(limited.headers.get('Retry-After')).toMatch(/^\d+$/);
+    });
+
+    it('should be operator-only', async () => {
+        const admin = new AdminHandler({
+            console: async () => { throw new Error('unreachable'); },
+            auth: {
+                authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }),
+                getTenant: async () => null,
+            },
+        });
+
+        const response = await admin.handle(new Request('http://localhost/api/console/execute', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k' },
+            body: JSON.stringify(request),
+        }));
+
+        expect(response.status).toBe(403);
+    });
+});
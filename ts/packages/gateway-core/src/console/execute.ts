@@ -0,0 +1,219 @@
+/**
+ * Test-request console.
+ *
+ * Operators send a request to an app from the admin API and see every
+ * representation it passes through: the raw body, the canonical request,
+ * the pipeline's stage outcomes, the routing decision, and the body the
+ * provider would receive. Dry runs stop there; otherwise the provider is
+ * called and the response chain is returned too. Console requests are
+ * flagged in the interaction record and kept out of usage reports and
+ * budgets.
+ *
+ * @module console/execute
+ */
+
+import type { APIType, CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import type { Codec } from '../codecs/types.js';
+import { defaultCodecRegistry } from '../codecs/index.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import type { StageOutcome } from '../middleware/executor.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Tenant console requests run as. */
+export const CONSOLE_TENANT = 'console';
+
+/** Interaction origin recorded for console requests. */
+export const CONSOLE_ORIGIN = 'console';
+
+/** Default console rate per caller: each run may call a provider, so it is kept low. */
+export const DEFAULT_CONSOLE_RATE: ConsoleRate = { limit: 5, windowMs: 60_000 };
+
+/** Frontdoors whose apps the console can send to. */
+const CONSOLE_FRONTDOORS: ReadonlySet<string> = new Set<APIType>(['openai', 'anthropic']);
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Per-caller console rate limit.
+ */
+export interface ConsoleRate {
+    /** Requests allowed per window. */
+    limit: number;
+
+    /** Window length in ms. */
+    windowMs: number;
+}
+
+/**
+ * A console request, parsed from the admin API body.
+ */
+export interface ConsoleRequest {
+    /** App to send the request to. */
+    app: string;
+
+    /** Model, or the app's default when unset. */
+    model?: string | undefined;
+
+    /** Messages, in the app's frontdoor format. */
+    messages: unknown[];
+
+    /** Stop before calling the provider. */
+    dryRun: boolean;
+}
+
+/**
+ * Every representation of a console request, in client JSON.
+ */
+export interface ConsoleResult {
+    /** Interaction ID the request was recorded under. */
+    interaction_id: string;
+
+    /** Whether the provider call was skipped. */
+    dry_run: boolean;
+
+    /** Body as the app's frontdoor received it. */
+    raw: Record<string, unknown>;
+
+    /** Canonical request as decoded, before the pipeline. */
+    canonical: CanonicalRequest;
+
+    /** Pre-request pipeline stage outcomes and how the pipeline ended. */
+    pipeline: {
+        stages: StageOutcome[];
+        outcome: 'continue' | 'respond' | 'deny';
+    };
+
+    /** Provider and model the request was routed to. */
+    routing: {
+        provider: string;
+        model: string;
+        model_rewrite?: string | undefined;
+        pipeline_route?: { stage: string; provider?: string | undefined; model?: string | undefined } | undefined;
+    };
+
+    /** Canonical request as the provider receives it, after the pipeline. */
+    planned: CanonicalRequest;
+
+    /** Body the provider is sent, when its API has a codec. */
+    provider_request?: unknown;
+
+    /** Conversions applied along the way. */
+    transformations: TransformationStep[];
+
+    /** Why the request stopped before the provider: a pipeline denial or a capability it lacks. */
+    error?: string | undefined;
+
+    /** Response chain; absent on dry runs and when the pipeline stopped the request. */
+    response?: {
+        canonical: CanonicalResponse;
+        post_pipeline: StageOutcome[];
+        body: unknown;
+    } | undefined;
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+/**
+ * Parses a console request body. Returns an error message if it is invalid.
+ */
+export function parseConsoleRequest(body: unknown): ConsoleRequest | string {
+    if (!body || typeof body !== 'object' || Array.isArray(body)) {
+        return 'Body must be a JSON object';
+    }
+    const b = body as Record<string, unknown>;
+    if (typeof b.app !== 'string' || b.app === '') {
+        return 'app is required';
+    }
+    if (b.model !== undefined && typeof b.model !== 'string') {
+        return 'model must be a string';
+    }
+    if (!Array.isArray(b.messages) || b.messages.length === 0) {
+        return 'messages must be a non-empty array';
+    }
+    if (b.stream !== undefined && b.stream !== false) {
+        return 'stream is not supported by the console';
+    }
+    if (b.dry_run !== undefined && typeof b.dry_run !== 'boolean') {
+        return 'dry_run must be a boolean';
+    }
+    return { app: b.app, model: b.model, messages: b.messages, dryRun: b.dry_run ?? false };
+}
+
+/**
+ * Returns the codec that decodes requests for an app's frontdoor, or
+ * undefined if the console can't send to it.
+ */
+export function consoleCodec(frontdoor: string): Codec | undefined {
+    return CONSOLE_FRONTDOORS.has(frontdoor) ? defaultCodecRegistry.get(frontdoor as APIType) : undefined;
+}
+
+/**
+ * Encodes a request as a provider of the given API would be sent it, or
+ * returns undefined when that API has no codec.
+ */
+export function encodeProviderRequest(apiType: APIType, request: CanonicalRequest): unknown {
+    const codec = defaultCodecRegistry.get(apiType);
+    return codec && JSON.parse(new TextDecoder().decode(codec.encodeRequest(request)));
+}
+
+/**
+ * Returns a canonical request without its raw body bytes, for JSON output.
+ */
+export function withoutRaw(request: CanonicalRequest): CanonicalRequest {
+    const { rawRequest: _, ...rest } = request;
+    return rest;
+}
+
+/**
+ * Returns a canonical response without its raw body bytes, for JSON output.
+ */
+export function withoutRawResponse(response: CanonicalResponse): CanonicalResponse {
+    const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...rest } = response;
+    return rest;
+}
+
+// ============================================================================
+// Rate Limiting
+// ============================================================================
+
+/**
+ * Fixed-window console rate limiter, per caller.
+ */
+export class ConsoleLimiter {
+    private readonly windows = new Map<string, { start: number; count: number }>();
+
+    constructor(
+        private readonly rate: ConsoleRate = DEFAULT_CONSOLE_RATE,
+        private readonly now: () => number = Date.now,
+    ) { }
+
+    /**
+     * Counts a request against the caller's limit. Returns 0 when it may
+     * run, or the seconds until the window resets.
+     */
+    throttle(caller: string): number {
+        const now = this.now();
+        let window = this.windows.get(caller);
+        if (!window || now - window.start >= this.rate.windowMs) {
+            for (const [key, w] of this.windows) {
+                if (now - w.start >= this.rate.windowMs) {
+                    this.windows.delete(key);
+                }
+            }
+            window = { start: now, count: 0 };
+            this.windows.set(caller, window);
+        }
+        if (window.count >= this.rate.limit) {
+            return Math.max(1, Math.ceil((window.start + this.rate.windowMs - now) / 1000));
+        }
+        window.count++;
+        return 0;
+    }
+}
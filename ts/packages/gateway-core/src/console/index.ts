@@ -0,0 +1,20 @@
+/**
+ * Test-request console exports.
+ *
+ * @module console
+ */
+
+export {
+    CONSOLE_TENANT,
+    CONSOLE_ORIGIN,
+    DEFAULT_CONSOLE_RATE,
+    ConsoleLimiter,
+    parseConsoleRequest,
+    consoleCodec,
+    encodeProviderRequest,
+    withoutRaw,
+    withoutRawResponse,
+    type ConsoleRate,
+    type ConsoleRequest,
+    type ConsoleResult,
+} from './execute.js';
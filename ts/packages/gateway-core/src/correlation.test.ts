@@ -0,0 +1,115 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from './admin/index';
+import {
+    INTERACTION_ID_HEADER,
+    MAX_CORRELATION_VALUE_LENGTH,
+    MemoryMetadataIndex,
+    captureCorrelation,
+    validateCorrelationHeaders,
+} from './correlation/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+let gateway: TestGateway | undefined;
+
+afterEach(async () => {
+    await gateway?.close();
+    gateway = undefined;
+});
+
+async function setup(correlationHeaders: string[] | undefined = ['X-Client-Request-Id']) {
+    gateway = await harness()
+        .app({ name: 'chat', frontdoor: 'openai', path: '/v1', correlationHeaders })
+        .provider(new ScriptedProvider('mock', { text: 'ok', usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 } }))
+        .start();
+    const gw = gateway;
+    const send = (tenant: string, headers: Record<string, string> = {}, body: Record<string, unknown> = {}) =>
+        gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...body }, {
+            Authorization: `Bearer ${tenant}`,
+            ...headers,
+        });
+    return { gw, send };
+}
+
+describe('validateCorrelationHeaders', () => {
+    it('should reject invalid, protected, and duplicate header names', () => {
+        expect(() => validateCorrelationHeaders(['X-Client-Request-Id', 'traceparent'])).not.toThrow();
+        expect(() => validateCorrelationHeaders(['X Client'])).toThrow(/not a valid header name/);
+        expect(() => validateCorrelationHeaders(['Authorization'])).toThrow(/protected/);
+        expect(() => validateCorrelationHeaders(['x-request-id', 'X-Request-Id'])).toThrow(/listed twice/);
+        expect(() => validateCorrelationHeaders(Array.from({ length: 9 }, (_, i) => `x-h${i}`))).toThrow(/at most 8/);
+    });
+
+    it('should fail the config load', async () => {
+        const { gw } = await setup(['Cookie']);
+
+        await expect(gw.gateway.reload()).rejects.toThrow("Invalid config for app 'chat': correlation_headers: 'Cookie' is protected");
+    });
+});
+
+describe('captureCorrelation', () => {
+    it('should key values by lowercase name and cap their length', () => {
+        const headers = new Headers({ 'X-Client-Request-Id': 'r'.repeat(1000), 'X-Empty': ' ' });
+
+        const captured = captureCorrelation(headers, ['X-Client-Request-Id', 'X-Empty', 'X-Absent']);
+
+        expect(captured).toEqual({ 'x-client-request-id': 'r'.repeat(MAX_CORRELATION_VALUE_LENGTH) });
+        expect(captureCorrelation(headers, undefined)).toBeUndefined();
+    });
+});
+
+describe('interaction ID header', () => {
+    it('should be returned on JSON, streamed, and error responses', async () => {
+        const { send } = await setup();
+
+        const json = await send('acme');
+        const stream = await send('acme', {}, { stream: true });
+        const denied = await send('acme', {}, { messages: 'not a list' });
+
+        for (const response of [json, stream, denied]) {
+            expect(response.headers.get(INTERACTION_ID_HEADER)).toMatch(/^[0-9a-f-]{36}$/);
+        }
+        expect(stream.headers.get('Content-Type')).toContain('text/event-stream');
+        expect(new Set([json, stream, denied].map((r) => r.headers.get(INTERACTION_ID_HEADER))).size).toBe(3);
+    });
+});
+
+describe('correlation lookup', () => {
+    it('should find an interaction by the client request ID, scoped to the tenant', async () => {
+        const { gw, send } = await setup();
+        const response = await send('acme', { 'X-Client-Request-Id': 'client-42' });
+        await send('acme', { 'X-Client-Request-Id': 'client-43' });
+        await send('globex', { 'X-Client-Request-Id': 'client-42' });
+        const interactionId = response.headers.get(INTERACTION_ID_HEADER);
+
+        const admin = new AdminHandler({
+            metadataIndex: gw.gateway.metadataIndex,
+            auth: {
+                authenticate: async (token: string) => ({ tenantId: token, scopes: token === 'ops' ? ['admin'] : [], metadata: {} }),
+                getTenant: async () => null,
+            },
+        });
+        const lookup = async (token: string, query: string) => (await admin.handle(new Request(
+            `http://localhost/api/interactions?${query}`,
+            { headers: { Authorization: `Bearer ${token}` } },
+        ))).json();
+
+        const found = await lookup('acme', 'metadata.X-Client-Request-Id=client-42');
+        expect(found.total).toBe(1);
+        expect(found.interactions[0]).toMatchObject({
+            id: interactionId,
+            metadata: { 'x-client-request-id': 'client-42' },
+        });
+
+        expect((await lookup('ops', 'metadata.x-client-request-id=client-42')).total).toBe(2);
+        expect((await lookup('acme', 'metadata.x-client-request-id=missing')).total).toBe(0);
+    });
+
+    it('should evict the oldest interactions past the memory index size', async () => {
+        const index = new MemoryMetadataIndex({ maxEntries: 2 });
+        for (const id of ['a', 'b', 'c']) {
+            await index.indexMetadata({ interactionId: id, tenantId: 't', metadata: { k: 'v' }, createdAt: new Date() });
+        }
+
+        expect((await index.findByMetadata('t', 'k', 'v')).map((r) => r.interactionId)).toEqual(['c', 'b']);
+    });
+});
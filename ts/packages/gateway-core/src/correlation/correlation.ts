@@ -0,0 +1,81 @@
+/**
+ * Client correlation headers.
+ *
+ * Apps can name request headers that carry the client's own correlation
+ * ID (e.g., X-Client-Request-Id). Their values are indexed as interaction
+ * metadata so the admin API can find an interaction by them, and every
+ * response carries the gateway interaction ID back to the client.
+ *
+ * @module correlation/correlation
+ */
+
+import { PROTECTED_HEADERS } from '../utils/headers.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Response header carrying the gateway interaction ID. */
+export const INTERACTION_ID_HEADER = 'X-Gateway-Interaction-Id';
+
+/** Most correlation headers an app may name. */
+export const MAX_CORRELATION_HEADERS = 8;
+
+/** Captured values are cut to this many characters. */
+export const MAX_CORRELATION_VALUE_LENGTH = 256;
+
+/** RFC 9110 field-name token. */
+const HEADER_NAME = /^[!#$%&'*+.^_`|~0-9A-Za-z-]+$/;
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Throws if a correlation header list names an invalid, duplicate, or
+ * credential header, or too many headers.
+ */
+export function validateCorrelationHeaders(names: string[] | undefined): void {
+    if (!names) return;
+    if (names.length > MAX_CORRELATION_HEADERS) {
+        throw new Error(`correlation_headers: at most ${MAX_CORRELATION_HEADERS} headers are allowed`);
+    }
+
+    const seen = new Set<string>();
+    for (const name of names) {
+        if (typeof name !== 'string' || !HEADER_NAME.test(name)) {
+            throw new Error(`correlation_headers: '${name}' is not a valid header name`);
+        }
+        const lower = name.toLowerCase();
+        if (PROTECTED_HEADERS.has(lower)) {
+            throw new Error(`correlation_headers: '${name}' is protected and cannot be captured`);
+        }
+        if (seen.has(lower)) {
+            throw new Error(`correlation_headers: '${name}' is listed twice`);
+        }
+        seen.add(lower);
+    }
+}
+
+// ============================================================================
+// Capture
+// ============================================================================
+
+/**
+ * Returns the named headers' values keyed by lowercase header name, each
+ * cut to MAX_CORRELATION_VALUE_LENGTH. Absent and empty headers are skipped.
+ */
+export function captureCorrelation(
+    headers: Headers,
+    names: string[] | undefined,
+): Record<string, string> | undefined {
+    let captured: Record<string, string> | undefined;
+    for (const name of names ?? []) {
+        const value = headers.get(name)?.trim();
+        if (value) {
+            captured ??= {};
+            captured[name.toLowerCase()] = value.slice(0, MAX_CORRELATION_VALUE_LENGTH);
+        }
+    }
+    return captured;
+}
@@ -0,0 +1,19 @@
+/**
+ * Client correlation exports.
+ *
+ * @module correlation
+ */
+
+export {
+    INTERACTION_ID_HEADER,
+    MAX_CORRELATION_HEADERS,
+    MAX_CORRELATION_VALUE_LENGTH,
+    validateCorrelationHeaders,
+    captureCorrelation,
+} from './correlation.js';
+
+export {
+    MemoryMetadataIndex,
+    isMetadataIndexStore,
+    DEFAULT_METADATA_INDEX_SIZE,
+} from './store.js';
@@ -0,0 +1,69 @@
+/**
+ * In-memory interaction metadata index.
+ *
+ * @module correlation/store
+ */
+
+import type {
+    InteractionMetadataRecord,
+    MetadataIndexStore,
+    StorageProvider,
+} from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Metadata Index
+// ============================================================================
+
+/** Default number of interactions the memory index keeps. */
+export const DEFAULT_METADATA_INDEX_SIZE = 10_000;
+
+/**
+ * Process-local metadata index, holding the most recent interactions.
+ * Used when the configured storage provider does not implement MetadataIndexStore.
+ */
+export class MemoryMetadataIndex implements MetadataIndexStore {
+    private readonly records = new Map<string, InteractionMetadataRecord>();
+    private readonly maxEntries: number;
+
+    constructor(options: { maxEntries?: number | undefined } = {}) {
+        this.maxEntries = options.maxEntries ?? DEFAULT_METADATA_INDEX_SIZE;
+    }
+
+    async indexMetadata(record: InteractionMetadataRecord): Promise<void> {
+        // Later records for an interaction add keys, as SQL stores' rows do
+        const existing = this.records.get(record.interactionId);
+        this.records.set(record.interactionId, { ...record, metadata: { ...existing?.metadata, ...record.metadata } });
+        if (this.records.size > this.maxEntries) {
+            const oldest = this.records.keys().next().value;
+            if (oldest !== undefined) this.records.delete(oldest);
+        }
+    }
+
+    async findByMetadata(tenantId: string, key: string, value: string): Promise<InteractionMetadataRecord[]> {
+        const matches: InteractionMetadataRecord[] = [];
+        for (const record of this.records.values()) {
+            if (record.metadata[key] !== value) continue;
+            if (tenantId !== UNSCOPED_TENANT && record.tenantId !== tenantId) continue;
+            matches.push({ ...record, metadata: { ...record.metadata } });
+        }
+        return matches.reverse();
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements MetadataIndexStore.
+ */
+export function isMetadataIndexStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & MetadataIndexStore {
+    return (
+        storage !== undefined &&
+        typeof storage.indexMetadata === 'function' &&
+        typeof storage.findByMetadata === 'function'
+    );
+}
@@ -0,0 +1,114 @@
+import { describe, it, expect, vi } from 'vitest';
+import { KeyPool, OpenAIProvider } from './providers/index';
+
+const request = { model: 'gpt-4o', messages: [{ role: 'user', content: 'hi' }] } as any;
+
+const completion = {
+    id: 'chatcmpl-1',
+    object: 'chat.completion',
+    created: 0,
+    model: 'gpt-4o',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'ok' }, finish_reason: 'stop' }],
+    usage: { prompt_tokens: 1, completion_tokens: 1, total_tokens: 2 },
+};
+
+/** Upstream that answers per key with the configured status. */
+function upstream(statusByKey: Record<string, number>) {
+    const seen: string[] = [];
+    const fetch = vi.fn(async (_url: string, init: RequestInit) => {
+        const key = (init.headers as Record<string, string>)['Authorization']!.replace('Bearer ', '');
+        seen.push(key);
+        const status = statusByKey[key] ?? 200;
+        return status === 200
+            ? Response.json(completion)
+            : Response.json({ error: { message: 'nope', type: 'error' } }, { status });
+    });
+    return { fetch, seen };
+}
+
+describe('KeyPool', () => {
+    it('should rotate keys round-robin', () => {
+        const pool = new KeyPool([{ key: 'sk-aaaa' }, { key: 'sk-bbbb' }, { key: 'sk-cccc' }]);
+
+        expect([1, 2, 3, 4].map(() => pool.acquire().id)).toEqual(['...aaaa', '...bbbb', '...cccc', '...aaaa']);
+    });
+
+    it('should skip a rate-limited key until its cooldown ends', () => {
+        let now = 0;
+        const pool = new KeyPool([{ key: 'sk-aaaa' }, { key: 'sk-bbbb' }], { cooldownMs: 1000, now: () => now });
+
+        pool.report(pool.acquire(), 429);
+        expect([1, 2, 3].map(() => pool.acquire().id)).toEqual(['...bbbb', '...bbbb', '...bbbb']);
+
+        now = 1000;
+        expect([1, 2].map(() => pool.acquire().id)).toEqual(['...aaaa', '...bbbb']);
+    });
+
+    it('should double the cooldown on consecutive 429s', () => {
+        let now = 0;
+        const pool = new KeyPool([{ key: 'sk-aaaa', label: 'primary' }], { cooldownMs: 1000, now: () => now });
+        const lease = pool.acquire();
+
+        pool.report(lease, 429);
+        now = 1000;
+        pool.report(lease, 429);
+
+        expect(pool.health()[0]).toMatchObject({
+            id: 'primary',
+            rateLimited: 2,
+            coolingDownUntil: new Date(3000).toISOString(),
+        });
+    });
+
+    it('should mark a 401 key unhealthy and alert', () => {
+        const logger = { error: vi.fn(), warn: vi.fn(), info: vi.fn(), debug: vi.fn() };
+        const pool = new KeyPool([{ key: 'sk-aaaa' }, { key: 'sk-bbbb' }], { provider: 'openai', logger });
+
+        pool.report(pool.acquire(), 401);
+
+        expect(logger.error).toHaveBeenCalledWith('provider_key_unhealthy', {
+            provider: 'openai',
+            key: '...aaaa',
+            status: 401,
+        });
+        expect(pool.health().map((k) => k.healthy)).toEqual([false, true]);
+        expect([1, 2].map(() => pool.acquire().id)).toEqual(['...bbbb', '...bbbb']);
+    });
+
+    it('should never expose key material in health', () => {
+        const pool = KeyPool.fromConfig('sk-secret-aaaa', [{ key: 'sk-secret-bbbb' }]);
+
+        expect(JSON.stringify(pool.health())).not.toContain('secret');
+    });
+});
+
+describe('provider key pooling', () => {
+    it('should spread requests across keys and record the key used', async () => {
+        const { fetch, seen } = upstream({});
+        const provider = new OpenAIProvider({
+            name: 'openai',
+            apiKey: 'sk-aaaa',
+            credentials: KeyPool.fromConfig('sk-aaaa', [{ key: 'sk-bbbb' }]),
+            fetch: fetch as any,
+        });
+
+        const first = await provider.complete(request);
+        const second = await provider.complete(request);
+
+        expect(seen).toEqual(['sk-aaaa', 'sk-bbbb']);
+        expect([first.providerKeyId, second.providerKeyId]).toEqual(['...aaaa', '...bbbb']);
+    });
+
+    it('should survive one key being revoked', async () => {
+        const { fetch, seen } = upstream({ 'sk-aaaa': 401 });
+        const pool = KeyPool.fromConfig('sk-aaaa', [{ key: 'sk-bbbb' }]);
+        const provider = new OpenAIProvider({ name: 'openai', apiKey: 'sk-aaaa', credentials: pool, fetch: fetch as any });
+
+        await expect(provider.complete(request)).rejects.toBeDefined();
+        await provider.complete(request);
+        await provider.complete(request);
+
+        expect(seen).toEqual(['sk-aaaa', 'sk-bbbb', 'sk-bbbb']);
+        expect(pool.health()[0]).toMatchObject({ healthy: false, lastStatus: 401 });
+    });
+});
@@ -0,0 +1,105 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { renderDenial } from './frontdoors/index';
+import type { AppConfig, DenyResponseConfig } from './ports/index';
+import { harness, scenario, streamText, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+describe('Denial rendering', () => {
+    const values = { reason: 'pii detected', stage: 'moderate', model: 'gpt-4o' };
+
+    it('should keep the reason from the client unless include_reason is set', () => {
+        expect(renderDenial({ mode: 'static', content: 'Blocked.' }, values)).toBe('Blocked.');
+        expect(renderDenial({ mode: 'static', content: 'Blocked.', includeReason: true }, values)).toBe('Blocked. (pii detected)');
+        expect(renderDenial({ mode: 'static' }, values)).toBe("I can't help with that request.");
+
+        const template = 'Stage {{stage}} stopped {{ model }}: {{reason}} {{unknown}}';
+        expect(renderDenial({ mode: 'template', content: template }, values))
+            .toBe('Stage moderate stopped gpt-4o:  {{unknown}}');
+        expect(renderDenial({ mode: 'template', content: template, includeReason: true }, values))
+            .toBe('Stage moderate stopped gpt-4o: pii detected {{unknown}}');
+    });
+});
+
+describe('Denied responses', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(app: Partial<AppConfig>, stageOnDeny?: DenyResponseConfig) {
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                ...app,
+                pipeline: {
+                    stages: [{ name: 'moderate', type: 'post', url: 'http://hooks/moderate', onDeny: stageOnDeny }],
+                },
+            })
+            .provider(new ScriptedProvider('mock', {
+                chunks: ['Call ', '555-0100'],
+                usage: { promptTokens: 5, completionTokens: 3, totalTokens: 8 },
+            }))
+            .webhook(() => ({ action: 'deny', reason: 'pii detected' }))
+            .start();
+        return gateway;
+    }
+
+    const ask = (body: Record<string, unknown> = {}) =>
+        ({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Who do I call?' }], ...body });
+
+    it('should answer with the configured content and keep the denied response', async () => {
+        const { store } = await setup({ onDeny: { mode: 'static', content: 'That answer was withheld.' } });
+
+        const [turn] = await scenario(gateway!).turn({ path: '/v1/chat/completions', body: ask(), stored: ['response_denied'] }).run();
+        const body = turn!.json;
+        expect(body.choices).toHaveLength(1);
+        expect(body.choices[0].message.content).toBe('That answer was withheld.');
+        expect(body.choices[0].finish_reason).toBe('content_filter');
+        expect(body.usage.total_tokens).toBe(8);
+
+        const denied = store.eventsOf('response_denied')[0];
+        expect(denied?.payload).toMatchObject({
+            stage: 'moderate',
+            reason: 'pii detected',
+            response: { choices: [{ message: { content: 'Call 555-0100' } }] },
+        });
+    });
+
+    it('should replay a denied stream as the configured content', async () => {
+        await setup({ onDeny: { mode: 'template', content: 'Withheld by {{stage}}: {{reason}}', includeReason: true } });
+
+        const [turn] = await scenario(gateway!).turn({
+            path: '/v1/chat/completions',
+            body: ask({ stream: true }),
+            events: [
+                { type: 'start' },
+                { type: 'text', text: 'Withheld by moderate: pii detected' },
+                { type: 'stop', reason: 'content_filter' },
+                { type: 'done' },
+            ],
+        }).run();
+        expect(turn!.body).not.toContain('555-0100');
+    });
+
+    it('should replay a denied Anthropic stream', async () => {
+        await setup({ frontdoor: 'anthropic', onDeny: { mode: 'static', content: 'Withheld.' } });
+
+        const [turn] = await scenario(gateway!).turn({ path: '/v1/messages', body: ask({ stream: true, max_tokens: 100 }) }).run();
+        expect(streamText(turn!.events!)).toBe('Withheld.');
+        expect(turn!.events!.some((e) => e.type === 'stop')).toBe(true);
+    });
+
+    it('should deny with an error without on_deny, or when the stage asks for one', async () => {
+        await setup({});
+        await scenario(gateway!).turn({ path: '/v1/chat/completions', body: ask(), status: 403 }).run();
+        await gateway!.close();
+
+        const strict = await setup({ onDeny: { mode: 'static' } }, { mode: 'error' });
+        const [turn] = await scenario(strict).turn({ path: '/v1/chat/completions', body: ask(), status: 403 }).run();
+        expect(turn!.body).toContain('pii detected');
+        expect(strict.store.eventsOf('response_denied')).toEqual([]);
+    });
+});
@@ -0,0 +1,89 @@
+import { describe, it, expect, vi } from 'vitest';
+import { ModelCatalog } from './catalog';
+import { openAIFrontdoor } from '../frontdoors/index';
+
+const request = (model: string, extra: Record<string, unknown> = {}) => ({
+    tenantId: 't',
+    model,
+    messages: [{ role: 'user', content: 'hi' }],
+    stream: false,
+    sourceAPIType: 'openai',
+    ...extra,
+}) as any;
+
+const tools = [{ type: 'function', function: { name: 'f', parameters: {} } }];
+
+describe('ModelCatalog', () => {
+    it('should resolve dated snapshots to their base entry', () => {
+        const catalog = new ModelCatalog();
+
+        expect(catalog.get('gpt-4o-2024-08-06')?.id).toBe('gpt-4o');
+        expect(catalog.get('gpt-4o-mini-2024-07-18')?.id).toBe('gpt-4o-mini');
+        expect(catalog.get('claude-sonnet-4-20250514')?.id).toBe('claude-sonnet-4');
+        expect(catalog.get('gpt-4oo')).toBeUndefined();
+    });
+
+    it('should override built-in entries field by field', () => {
+        const catalog = new ModelCatalog([{ id: 'gpt-4o', maxOutputTokens: 1000 }]);
+        const info = catalog.get('gpt-4o')!;
+
+        expect(info.maxOutputTokens).toBe(1000);
+        expect(info.contextWindow).toBe(128000);
+        expect(info.supportsTools).toBe(true);
+    });
+
+    it('should reject tools for a no-tools model', () => {
+        const catalog = new ModelCatalog([{ id: 'local-llama', supportsTools: false }]);
+
+        expect(() => catalog.check(request('local-llama', { tools }))).toThrow(/does not support tools/);
+        expect(() => catalog.check(request('local-llama'))).not.toThrow();
+    });
+
+    it('should reject images for a no-vision model', () => {
+        const catalog = new ModelCatalog();
+        const messages = [{
+            role: 'user',
+            content: '',
+            richContent: { parts: [{ type: 'image_url', imageUrl: { url: 'https://x/y.png' } }] },
+        }];
+
+        expect(() => catalog.check(request('o3-mini', { messages }))).toThrow(/image input/);
+    });
+
+    it('should pass unknown models through with a warning', () => {
+        const logger = { warn: vi.fn() };
+
+        new ModelCatalog().check(request('mystery-model', { tools }), logger);
+
+        expect(logger.warn).toHaveBeenCalledWith('model_not_in_catalog', { model: 'mystery-model' });
+    });
+
+    it('should estimate cost from pricing', () => {
+        const cost = new ModelCatalog().estimateCost('gpt-4o', {
+            promptTokens: 1_000_000,
+            completionTokens: 100_000,
+            totalTokens: 1_100_000,
+        });
+
+        expect(cost).toBeCloseTo(3.5);
+    });
+
+    it('should fail fast in the frontdoor before calling the provider', async () => {
+        const provider = { name: 'local', apiType: 'openai', complete: vi.fn(), stream: vi.fn() };
+        const { response } = await openAIFrontdoor.handle({
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                body: JSON.stringify({ model: 'local-llama', messages: [{ role: 'user', content: 'hi' }], tools }),
+            }),
+            provider,
+            auth: { tenantId: 't', scopes: [], metadata: {} },
+            interactionId: 'i',
+            catalog: new ModelCatalog([{ id: 'local-llama', supportsTools: false }]),
+        } as any);
+        const json = await response.json();
+
+        expect(response.status).toBe(400);
+        expect(json.error.param).toBe('tools');
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+});
@@ -0,0 +1,223 @@
+/**
+ * Model catalog - static per-model capability and pricing metadata.
+ *
+ * Built-in defaults cover well-known OpenAI and Anthropic models; config
+ * entries override them field by field or add new models. Dated snapshots
+ * (e.g. "gpt-4o-2024-08-06") resolve to their base entry.
+ *
+ * @module domain/catalog
+ */
+
+import type { APIType, CanonicalRequest, Usage } from './types.js';
+import { errInvalidRequest } from './errors.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Per-million-token pricing in USD.
+ */
+export interface ModelPricing {
+    /** Price per million prompt tokens. */
+    input: number;
+
+    /** Price per million completion tokens. */
+    output: number;
+}
+
+/**
+ * Catalog entry for a model.
+ */
+export interface ModelInfo {
+    /** Model ID as sent by clients. */
+    id: string;
+
+    /** API family that serves the model. */
+    apiType?: APIType | undefined;
+
+    /** Preferred provider name for routing when no rule matches. */
+    provider?: string | undefined;
+
+    /** Context window in tokens. */
+    contextWindow?: number | undefined;
+
+    /** Maximum output tokens per request. */
+    maxOutputTokens?: number | undefined;
+
+    /** Whether the model accepts tool definitions. */
+    supportsTools?: boolean | undefined;
+
+    /** Whether the model accepts image input. */
+    supportsVision?: boolean | undefined;
+
+    /** Token pricing. */
+    pricing?: ModelPricing | undefined;
+}
+
+/**
+ * Minimal logger used for catalog warnings.
+ */
+interface CatalogLogger {
+    warn(message: string, fields?: Record<string, unknown>): void;
+}
+
+// ============================================================================
+// Built-in Defaults
+// ============================================================================
+
+/** Built-in catalog shipped with the gateway. */
+export const BUILTIN_MODELS: readonly ModelInfo[] = [
+    {
+        id: 'gpt-4o', apiType: 'openai', contextWindow: 128000, maxOutputTokens: 16384,
+        supportsTools: true, supportsVision: true, pricing: { input: 2.5, output: 10 },
+    },
+    {
+        id: 'gpt-4o-mini', apiType: 'openai', contextWindow: 128000, maxOutputTokens: 16384,
+        supportsTools: true, supportsVision: true, pricing: { input: 0.15, output: 0.6 },
+    },
+    {
+        id: 'gpt-4.1', apiType: 'openai', contextWindow: 1047576, maxOutputTokens: 32768,
+        supportsTools: true, supportsVision: true, pricing: { input: 2, output: 8 },
+    },
+    {
+        id: 'gpt-4.1-mini', apiType: 'openai', contextWindow: 1047576, maxOutputTokens: 32768,
+        supportsTools: true, supportsVision: true, pricing: { input: 0.4, output: 1.6 },
+    },
+    {
+        id: 'gpt-3.5-turbo', apiType: 'openai', contextWindow: 16385, maxOutputTokens: 4096,
+        supportsTools: true, supportsVision: false, pricing: { input: 0.5, output: 1.5 },
+    },
+    {
+        id: 'o3-mini', apiType: 'openai', contextWindow: 200000, maxOutputTokens: 100000,
+        supportsTools: true, supportsVision: false, pricing: { input: 1.1, output: 4.4 },
+    },
+    {
+        id: 'claude-opus-4', apiType: 'anthropic', contextWindow: 200000, maxOutputTokens: 32000,
+        supportsTools: true, supportsVision: true, pricing: { input: 15, output: 75 },
+    },
+    {
+        id: 'claude-sonnet-4', apiType: 'anthropic', contextWindow: 200000, maxOutputTokens: 64000,
+        supportsTools: true, supportsVision: true, pricing: { input: 3, output: 15 },
+    },
+    {
+        id: 'claude-3-5-sonnet', apiType: 'anthropic', contextWindow: 200000, maxOutputTokens: 8192,
+        supportsTools: true, supportsVision: true, pricing: { input: 3, output: 15 },
+    },
+    {
+        id: 'claude-3-5-haiku', apiType: 'anthropic', contextWindow: 200000, maxOutputTokens: 8192,
+        supportsTools: true, supportsVision: true, pricing: { input: 0.8, output: 4 },
+    },
+    {
+        id: 'claude-3-haiku', apiType: 'anthropic', contextWindow: 200000, maxOutputTokens: 4096,
+        supportsTools: true, supportsVision: true, pricing: { input: 0.25, output: 1.25 },
+    },
+];
+
+// ============================================================================
+// Model Catalog
+// ============================================================================
+
+/**
+ * Resolves model metadata and enforces declared capabilities.
+ */
+export class ModelCatalog {
+    private readonly models = new Map<string, ModelInfo>();
+
+    /**
+     * Creates a catalog from the built-in defaults plus config entries.
+     * Entries with a built-in ID override only the fields they set.
+     */
+    constructor(entries: readonly ModelInfo[] = [], options?: { builtins?: boolean }) {
+        if (options?.builtins !== false) {
+            for (const model of BUILTIN_MODELS) {
+                this.models.set(model.id, model);
+            }
+        }
+        for (const entry of entries) {
+            const base = this.models.get(entry.id);
+            this.models.set(entry.id, base ? mergeModelInfo(base, entry) : entry);
+        }
+    }
+
+    /**
+     * Looks up a model, falling back to the longest cataloged ID that the
+     * model extends with a "-" suffix (dated snapshots).
+     */
+    get(model: string): ModelInfo | undefined {
+        const exact = this.models.get(model);
+        if (exact) return exact;
+
+        let best: ModelInfo | undefined;
+        for (const info of this.models.values()) {
+            if (model.startsWith(`${info.id}-`) && info.id.length > (best?.id.length ?? 0)) {
+                best = info;
+            }
+        }
+        return best;
+    }
+
+    /**
+     * Returns every cataloged model, sorted by ID.
+     */
+    list(): ModelInfo[] {
+        return Array.from(this.models.values()).sort((a, b) => a.id.localeCompare(b.id));
+    }
+
+    /**
+     * Rejects requests that use a capability the model lacks. Unknown models
+     * pass through with a warning. max_tokens over the output cap is clamped
+     * later, once routing has settled the model (see fitMaxTokens).
+     */
+    check(request: CanonicalRequest, logger?: CatalogLogger): void {
+        const info = this.get(request.model);
+        if (!info) {
+            logger?.warn('model_not_in_catalog', { model: request.model });
+            return;
+        }
+
+        if (info.supportsTools === false && (request.tools?.length ?? 0) > 0) {
+            throw errInvalidRequest(`Model '${request.model}' does not support tools`).withParam('tools');
+        }
+
+        if (info.supportsVision === false && hasImageInput(request)) {
+            throw errInvalidRequest(`Model '${request.model}' does not support image input`).withParam('messages');
+        }
+    }
+
+    /**
+     * Estimates the USD cost of a request, or undefined if the model has no pricing.
+     */
+    estimateCost(model: string, usage: Usage): number | undefined {
+        const pricing = this.get(model)?.pricing;
+        if (!pricing) return undefined;
+
+        return (usage.promptTokens * pricing.input + usage.completionTokens * pricing.output) / 1_000_000;
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Overlays the fields set on `override` onto `base`.
+ */
+function mergeModelInfo(base: ModelInfo, override: ModelInfo): ModelInfo {
+    const merged: ModelInfo = { ...base };
+    for (const [key, value] of Object.entries(override)) {
+        if (value !== undefined) {
+            (merged as unknown as Record<string, unknown>)[key] = value;
+        }
+    }
+    return merged;
+}
+
+/**
+ * Reports whether any message carries image content.
+ */
+function hasImageInput(request: CanonicalRequest): boolean {
+    return request.messages.some((m) =>
+        m.richContent?.parts?.some((p) => p.type === 'image' || p.type === 'image_url') ?? false,
+    );
+}
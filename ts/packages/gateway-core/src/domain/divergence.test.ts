@@ -0,0 +1,162 @@
+import { describe, it, expect } from 'vitest';
+import {
+    diffResponses,
+    diffArguments,
+    normalizeWhitespace,
+    unifiedDiff,
+} from './divergence';
+import { detectDivergences } from './shadow';
+
+const response = (extra: Record<string, unknown> = {}) => ({
+    content: 'Hello there.\nHow can I help?',
+    finishReason: 'stop',
+    toolCalls: [],
+    usage: { promptTokens: 10, completionTokens: 8, totalTokens: 18 },
+    ...extra,
+}) as any;
+
+describe('diffResponses', () => {
+    it('should report no changes for identical responses', () => {
+        const diff = diffResponses({ response: response() }, { response: response() });
+
+        expect(diff.finishReason.equal).toBe(true);
+        expect(diff.toolCalls).toEqual([]);
+        expect(diff.text).toEqual({ equal: true, unified: '' });
+        expect(diff.usage.totalTokens.delta).toBe(0);
+    });
+
+    it('should ignore whitespace-only differences in text', () => {
+        const diff = diffResponses(
+            { response: response() },
+            { response: response({ content: '  Hello   there.\n\n\n\nHow can\tI help?  ' }) },
+        );
+
+        expect(diff.text.equal).toBe(false);
+        expect(diff.text.unified).toBe('--- primary\n+++ shadow\n@@ -1,2 +1,3 @@\n Hello there.\n+\n How can I help?');
+    });
+
+    it('should compute usage and latency deltas', () => {
+        const diff = diffResponses(
+            { response: response(), latencyMs: 400 },
+            { response: response({ usage: { promptTokens: 10, completionTokens: 20, totalTokens: 30 } }), latencyMs: 250 },
+        );
+
+        expect(diff.usage.completionTokens).toEqual({ primary: 8, shadow: 20, delta: 12 });
+        expect(diff.latencyMs).toEqual({ primary: 400, shadow: 250, delta: -150 });
+    });
+
+    it('should leave the latency delta unset when one side is unknown', () => {
+        const diff = diffResponses({ response: response() }, { response: response(), latencyMs: 250 });
+
+        expect(diff.latencyMs).toEqual({ primary: null, shadow: 250, delta: null });
+    });
+
+    it('should compare tool call names and arguments by position', () => {
+        const diff = diffResponses(
+            { response: response({ toolCalls: [{ name: 'get_weather', arguments: '{"city":"NYC","units":"f"}' }] }) },
+            { response: response({ toolCalls: [
+                { name: 'get_weather', arguments: '{ "units": "c", "city": "NYC" }' },
+                { name: 'search', arguments: '{}' },
+            ] }) },
+        );
+
+        expect(diff.toolCalls[0]!.name.equal).toBe(true);
+        expect(diff.toolCalls[0]!.argumentChanges).toEqual([
+            { path: '$.units', kind: 'changed', primary: 'f', shadow: 'c' },
+        ]);
+        expect(diff.toolCalls[1]!.name).toEqual({ primary: null, shadow: 'search', equal: false });
+        expect(diff.toolCalls[1]!.argumentChanges).toEqual([{ path: '$', kind: 'added', shadow: {} }]);
+    });
+
+    it('should diff the shadow against an empty primary when the shadow errored', () => {
+        const diff = diffResponses({ response: response() }, { error: 'upstream timeout', latencyMs: 30000 });
+
+        expect(diff.errors).toEqual({ primary: null, shadow: 'upstream timeout' });
+        expect(diff.empty).toEqual({ primary: false, shadow: true });
+        expect(diff.finishReason).toEqual({ primary: 'stop', shadow: null, equal: false });
+        expect(diff.usage.totalTokens).toEqual({ primary: 18, shadow: null, delta: null });
+        expect(diff.text.unified).toBe('--- primary\n+++ shadow\n@@ -1,2 +0,0 @@\n-Hello there.\n-How can I help?');
+    });
+
+    it('should flag an empty output on either side', () => {
+        const diff = diffResponses({ response: response({ content: '   ' }) }, { response: response() });
+
+        expect(diff.empty).toEqual({ primary: true, shadow: false });
+        expect(diff.text.unified).toBe('--- primary\n+++ shadow\n@@ -0,0 +1,2 @@\n+Hello there.\n+How can I help?');
+    });
+});
+
+describe('diffArguments', () => {
+    it('should walk nested objects and arrays', () => {
+        expect(diffArguments('{"a":{"b":[1,2]},"c":true}', '{"a":{"b":[1,3,4]}}')).toEqual([
+            { path: '$.a.b[1]', kind: 'changed', primary: 2, shadow: 3 },
+            { path: '$.a.b[2]', kind: 'added', shadow: 4 },
+            { path: '$.c', kind: 'removed', primary: true },
+        ]);
+    });
+
+    it('should fall back to string comparison for invalid JSON', () => {
+        expect(diffArguments('{"a":1', '{"a":1')).toEqual([]);
+        expect(diffArguments('{"a":1', '{"a":2}')).toEqual([
+            { path: '$', kind: 'changed', primary: '{"a":1', shadow: '{"a":2}' },
+        ]);
+    });
+});
+
+describe('unifiedDiff', () => {
+    it('should split distant changes into separate hunks', () => {
+        const a = Array.from({ length: 12 }, (_, i) => `line ${i + 1}`);
+        const b = [...a];
+        b[0] = 'first';
+        b[11] = 'last';
+
+        expect(unifiedDiff(a.join('\n'), b.join('\n'))).toBe([
+            '--- primary',
+            '+++ shadow',
+            '@@ -1,4 +1,4 @@',
+            '-line 1',
+            '+first',
+            ' line 2',
+            ' line 3',
+            ' line 4',
+            '@@ -9,4 +9,4 @@',
+            ' line 9',
+            ' line 10',
+            ' line 11',
+            '-line 12',
+            '+last',
+        ].join('\n'));
+    });
+
+    it('should normalize line endings and blank runs', () => {
+        expect(normalizeWhitespace('a  b\r\n\r\n\r\n\tc ')).toBe('a b\n\nc');
+    });
+});
+
+describe('detectDivergences tool call arguments', () => {
+    it('should flag argument differences for the same function', () => {
+        const primary = {
+            id: 'r', object: 'chat.completion', created: 0, model: 'gpt-4', sourceAPIType: 'openai',
+            usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            choices: [{
+                index: 0,
+                finishReason: 'tool_calls',
+                message: {
+                    role: 'assistant',
+                    content: '',
+                    toolCalls: [{ id: 'c1', type: 'function', function: { name: 'get_weather', arguments: '{"city":"NYC"}' } }],
+                },
+            }],
+        } as any;
+
+        const divergences = detectDivergences(primary, {
+            id: 's', model: 'claude', content: '', finishReason: 'tool_calls',
+            usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            toolCalls: [{ id: 'c2', name: 'get_weather', arguments: '{"city":"Boston"}' }],
+        });
+
+        expect(divergences).toHaveLength(1);
+        expect(divergences[0]).toMatchObject({ type: 'tool_call_arguments', severity: 'warning' });
+        expect(divergences[0]!.description).toContain('$.city');
+    });
+});
@@ -0,0 +1,387 @@
+/**
+ * Structured comparison of a primary response against a shadow response.
+ *
+ * Shared by the shadow executor's structural checks and the admin diff view.
+ *
+ * @module domain/divergence
+ */
+
+import type { CanonicalResponse } from './types.js';
+import type { ShadowResponse } from './shadow.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** A tool call reduced to the fields that are compared. */
+export interface ComparableToolCall {
+    /** Function name. */
+    name: string;
+
+    /** Function arguments as sent by the model (usually JSON). */
+    arguments: string;
+}
+
+/** A response reduced to the fields that are compared. */
+export interface ComparableResponse {
+    /** Generated text. */
+    content: string;
+
+    /** Finish reason, if known. */
+    finishReason?: string | undefined;
+
+    /** Tool calls made. */
+    toolCalls: ComparableToolCall[];
+
+    /** Token usage, if known. */
+    usage?: {
+        promptTokens: number;
+        completionTokens: number;
+        totalTokens: number;
+    } | undefined;
+}
+
+/** One side of a comparison: a response, an error, or both missing. */
+export interface DiffSide {
+    /** The response, if one was produced. */
+    response?: ComparableResponse | undefined;
+
+    /** Error message, if the side failed. */
+    error?: string | undefined;
+
+    /** Latency in milliseconds, if recorded. */
+    latencyMs?: number | undefined;
+}
+
+/** A scalar compared across both sides. */
+export interface ValueComparison<T> {
+    primary: T;
+    shadow: T;
+    equal: boolean;
+}
+
+/** A numeric value compared across both sides; delta is shadow minus primary. */
+export interface NumericDelta {
+    primary: number | null;
+    shadow: number | null;
+    delta: number | null;
+}
+
+/** Kind of change found between two JSON values. */
+export type JSONChangeKind = 'added' | 'removed' | 'changed';
+
+/** A single change between two JSON values, addressed by path (e.g. `$.items[0].id`). */
+export interface JSONChange {
+    path: string;
+    kind: JSONChangeKind;
+    primary?: unknown;
+    shadow?: unknown;
+}
+
+/** Comparison of the tool calls at one position. */
+export interface ToolCallDiff {
+    /** Position of the tool call in each response. */
+    index: number;
+
+    /** Function names on each side (null when the side has no call here). */
+    name: ValueComparison<string | null>;
+
+    /** Argument changes, compared structurally when both sides are JSON. */
+    argumentChanges: JSONChange[];
+}
+
+/** Structured diff of a primary response against a shadow response. */
+export interface ResponseDiff {
+    /** Error message per side, if that side failed. */
+    errors: { primary: string | null; shadow: string | null };
+
+    /** Whether each side produced neither text nor tool calls. */
+    empty: { primary: boolean; shadow: boolean };
+
+    /** Finish reason comparison. */
+    finishReason: ValueComparison<string | null>;
+
+    /** Per-position tool call comparison. */
+    toolCalls: ToolCallDiff[];
+
+    /** Token usage deltas. */
+    usage: {
+        promptTokens: NumericDelta;
+        completionTokens: NumericDelta;
+        totalTokens: NumericDelta;
+    };
+
+    /** Latency delta in milliseconds. */
+    latencyMs: NumericDelta;
+
+    /** Line-level diff of the whitespace-collapsed text. */
+    text: {
+        equal: boolean;
+        unified: string;
+    };
+}
+
+// ============================================================================
+// Conversion
+// ============================================================================
+
+/**
+ * Reduces the first choice of a canonical response to its comparable fields.
+ */
+export function fromCanonicalResponse(response: CanonicalResponse): ComparableResponse {
+    const choice = response.choices[0];
+    return {
+        content: choice?.message.content ?? '',
+        finishReason: choice?.finishReason ?? undefined,
+        toolCalls: (choice?.message.toolCalls ?? []).map((tc) => ({
+            name: tc.function.name,
+            arguments: tc.function.arguments,
+        })),
+        usage: response.usage,
+    };
+}
+
+/**
+ * Reduces a stored shadow response to its comparable fields.
+ */
+export function fromShadowResponse(response: ShadowResponse): ComparableResponse {
+    return {
+        content: response.content,
+        finishReason: response.finishReason,
+        toolCalls: (response.toolCalls ?? []).map((tc) => ({ name: tc.name, arguments: tc.arguments })),
+        usage: response.usage,
+    };
+}
+
+// ============================================================================
+// Diffing
+// ============================================================================
+
+/**
+ * Diffs two sides of a shadow comparison. Either side may be an error or
+ * missing, in which case it is treated as an empty response.
+ */
+export function diffResponses(primary: DiffSide, shadow: DiffSide): ResponseDiff {
+    const p = primary.response;
+    const s = shadow.response;
+
+    const primaryText = normalizeWhitespace(p?.content ?? '');
+    const shadowText = normalizeWhitespace(s?.content ?? '');
+
+    return {
+        errors: { primary: primary.error ?? null, shadow: shadow.error ?? null },
+        empty: { primary: isEmpty(p), shadow: isEmpty(s) },
+        finishReason: compare(p?.finishReason ?? null, s?.finishReason ?? null),
+        toolCalls: diffToolCalls(p?.toolCalls ?? [], s?.toolCalls ?? []),
+        usage: {
+            promptTokens: delta(p?.usage?.promptTokens, s?.usage?.promptTokens),
+            completionTokens: delta(p?.usage?.completionTokens, s?.usage?.completionTokens),
+            totalTokens: delta(p?.usage?.totalTokens, s?.usage?.totalTokens),
+        },
+        latencyMs: delta(primary.latencyMs, shadow.latencyMs),
+        text: {
+            equal: primaryText === shadowText,
+            unified: unifiedDiff(primaryText, shadowText),
+        },
+    };
+}
+
+/**
+ * Compares tool calls position by position.
+ */
+export function diffToolCalls(primary: ComparableToolCall[], shadow: ComparableToolCall[]): ToolCallDiff[] {
+    const diffs: ToolCallDiff[] = [];
+    for (let i = 0; i < Math.max(primary.length, shadow.length); i++) {
+        const p = primary[i];
+        const s = shadow[i];
+        diffs.push({
+            index: i,
+            name: compare(p?.name ?? null, s?.name ?? null),
+            argumentChanges: diffArguments(p?.arguments, s?.arguments),
+        });
+    }
+    return diffs;
+}
+
+/**
+ * Compares tool call arguments structurally when both sides parse as JSON,
+ * and as opaque strings otherwise.
+ */
+export function diffArguments(primary: string | undefined, shadow: string | undefined): JSONChange[] {
+    if (primary === undefined && shadow === undefined) return [];
+    if (primary === undefined) return [{ path: '$', kind: 'added', shadow: parseOr(shadow!) }];
+    if (shadow === undefined) return [{ path: '$', kind: 'removed', primary: parseOr(primary) }];
+
+    const p = tryParse(primary);
+    const s = tryParse(shadow);
+    if (p.ok && s.ok) {
+        return diffJSON(p.value, s.value);
+    }
+    return primary === shadow ? [] : [{ path: '$', kind: 'changed', primary, shadow }];
+}
+
+/**
+ * Lists the changes between two JSON values. Object keys are visited in
+ * sorted order so the output is stable regardless of key order.
+ */
+export function diffJSON(primary: unknown, shadow: unknown, path = '$'): JSONChange[] {
+    if (Array.isArray(primary) && Array.isArray(shadow)) {
+        const changes: JSONChange[] = [];
+        for (let i = 0; i < Math.max(primary.length, shadow.length); i++) {
+            const at = `${path}[${i}]`;
+            if (i >= primary.length) changes.push({ path: at, kind: 'added', shadow: shadow[i] });
+            else if (i >= shadow.length) changes.push({ path: at, kind: 'removed', primary: primary[i] });
+            else changes.push(...diffJSON(primary[i], shadow[i], at));
+        }
+        return changes;
+    }
+
+    if (isObject(primary) && isObject(shadow)) {
+        const keys = Array.from(new Set([...Object.keys(primary), ...Object.keys(shadow)])).sort();
+        const changes: JSONChange[] = [];
+        for (const key of keys) {
+            const at = `${path}.${key}`;
+            if (!(key in primary)) changes.push({ path: at, kind: 'added', shadow: shadow[key] });
+            else if (!(key in shadow)) changes.push({ path: at, kind: 'removed', primary: primary[key] });
+            else changes.push(...diffJSON(primary[key], shadow[key], at));
+        }
+        return changes;
+    }
+
+    return primary === shadow ? [] : [{ path, kind: 'changed', primary, shadow }];
+}
+
+/**
+ * Collapses runs of horizontal whitespace, trims each line, and squeezes
+ * consecutive blank lines so formatting noise does not show up as a diff.
+ */
+export function normalizeWhitespace(text: string): string {
+    return text
+        .replace(/\r\n?/g, '\n')
+        .split('\n')
+        .map((line) => line.replace(/[ \t\f\v]+/g, ' ').trim())
+        .join('\n')
+        .replace(/\n{3,}/g, '\n\n')
+        .trim();
+}
+
+/**
+ * Produces a line-level unified diff (three lines of context) labelled
+ * `primary` and `shadow`. Returns an empty string when the texts are equal.
+ */
+export function unifiedDiff(primary: string, shadow: string, context = 3): string {
+    if (primary === shadow) return '';
+
+    const a = primary === '' ? [] : primary.split('\n');
+    const b = shadow === '' ? [] : shadow.split('\n');
+    const ops = editScript(a, b);
+
+    const out = ['--- primary', '+++ shadow'];
+    let i = 0;
+    while (i < ops.length) {
+        // Find the next change
+        while (i < ops.length && ops[i]!.op === ' ') i++;
+        if (i >= ops.length) break;
+
+        // Extend the hunk while changes are within 2*context of each other
+        const start = Math.max(0, i - context);
+        let end = i;
+        while (end < ops.length) {
+            if (ops[end]!.op !== ' ') {
+                end++;
+                continue;
+            }
+            let run = end;
+            while (run < ops.length && ops[run]!.op === ' ') run++;
+            if (run >= ops.length || run - end > context * 2) {
+                end = Math.min(run, end + context);
+                break;
+            }
+            end = run;
+        }
+
+        const hunk = ops.slice(start, end);
+        const aLines = hunk.filter((o) => o.op !== '+').length;
+        const bLines = hunk.filter((o) => o.op !== '-').length;
+        const aStart = hunk[0]!.a + (aLines > 0 ? 1 : 0);
+        const bStart = hunk[0]!.b + (bLines > 0 ? 1 : 0);
+        out.push(`@@ -${aStart},${aLines} +${bStart},${bLines} @@`);
+        for (const o of hunk) out.push(`${o.op}${o.line}`);
+        i = end;
+    }
+    return out.join('\n');
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+interface EditOp {
+    op: ' ' | '-' | '+';
+    line: string;
+    /** Number of primary lines before this op. */
+    a: number;
+    /** Number of shadow lines before this op. */
+    b: number;
+}
+
+/**
+ * Computes a minimal line edit script via longest common subsequence.
+ */
+function editScript(a: string[], b: string[]): EditOp[] {
+    const lcs: number[][] = Array.from({ length: a.length + 1 }, () => new Array<number>(b.length + 1).fill(0));
+    for (let i = a.length - 1; i >= 0; i--) {
+        for (let j = b.length - 1; j >= 0; j--) {
+            lcs[i]![j] = a[i] === b[j]
+                ? lcs[i + 1]![j + 1]! + 1
+                : Math.max(lcs[i + 1]![j]!, lcs[i]![j + 1]!);
+        }
+    }
+
+    const ops: EditOp[] = [];
+    let i = 0;
+    let j = 0;
+    while (i < a.length || j < b.length) {
+        if (i < a.length && j < b.length && a[i] === b[j]) {
+            ops.push({ op: ' ', line: a[i]!, a: i++, b: j++ });
+        } else if (i < a.length && (j >= b.length || lcs[i + 1]![j]! >= lcs[i]![j + 1]!)) {
+            ops.push({ op: '-', line: a[i]!, a: i++, b: j });
+        } else {
+            ops.push({ op: '+', line: b[j]!, a: i, b: j++ });
+        }
+    }
+    return ops;
+}
+
+function compare<T>(primary: T, shadow: T): ValueComparison<T> {
+    return { primary, shadow, equal: primary === shadow };
+}
+
+function delta(primary: number | undefined, shadow: number | undefined): NumericDelta {
+    return {
+        primary: primary ?? null,
+        shadow: shadow ?? null,
+        delta: primary !== undefined && shadow !== undefined ? shadow - primary : null,
+    };
+}
+
+function isEmpty(response: ComparableResponse | undefined): boolean {
+    return !response || (response.content.trim() === '' && response.toolCalls.length === 0);
+}
+
+function isObject(value: unknown): value is Record<string, unknown> {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
+
+function tryParse(text: string): { ok: true; value: unknown } | { ok: false } {
+    try {
+        return { ok: true, value: JSON.parse(text) };
+    } catch {
+        return { ok: false };
+    }
+}
+
+function parseOr(text: string): unknown {
+    const parsed = tryParse(text);
+    return parsed.ok ? parsed.value : text;
+}
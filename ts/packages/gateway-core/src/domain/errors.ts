@@ -31,7 +31,35 @@ export type ErrorCode =
     | 'max_tokens_exceeded'
     | 'output_truncated'
     | 'invalid_request_error'
-    | 'server_error';
+    | 'server_error'
+    | 'request_timeout'
+    | 'stream_idle_timeout'
+    | 'deadline_exceeded'
+    | 'budget_exceeded'
+    | 'invalid_json_output'
+    | 'provider_policy_denied'
+    | 'concurrency_limit_exceeded'
+    | 'storage_unavailable'
+    | 'secret_unavailable'
+    | 'request_too_large';
+
+/**
+ * A provider's error response as it came back: status, body, and the
+ * headers worth relaying to a client.
+ */
+export interface UpstreamErrorDetail {
+    /** API the provider speaks. */
+    api: APIType;
+
+    /** HTTP status code. */
+    status: number;
+
+    /** Response body, as text. */
+    body: string;
+
+    /** Relevant response headers (rate limits, retry hints, request IDs), lowercased. */
+    headers: Record<string, string>;
+}
 
 // ============================================================================
 // APIError Class
@@ -57,6 +85,9 @@ export class APIError extends Error {
     /** Which API the error originated from. */
     readonly sourceAPI?: APIType | undefined;
 
+    /** The provider's original error response, when a provider returned one. */
+    readonly upstream?: UpstreamErrorDetail | undefined;
+
     constructor(
         type: ErrorType,
         message: string,
@@ -65,6 +96,7 @@ export class APIError extends Error {
             param?: string | undefined;
             statusCode?: number | undefined;
             sourceAPI?: APIType | undefined;
+            upstream?: UpstreamErrorDetail | undefined;
         },
     ) {
         super(message);
@@ -74,6 +106,7 @@ export class APIError extends Error {
         this.param = options?.param;
         this.statusCode = options?.statusCode ?? getDefaultStatusCode(type);
         this.sourceAPI = options?.sourceAPI;
+        this.upstream = options?.upstream;
 
         // Maintains proper stack trace for where error was thrown (V8 only)
         if (Error.captureStackTrace) {
@@ -90,6 +123,7 @@ export class APIError extends Error {
             param: this.param,
             statusCode: this.statusCode,
             sourceAPI: this.sourceAPI,
+            upstream: this.upstream,
         });
     }
 
@@ -102,6 +136,7 @@ export class APIError extends Error {
             param,
             statusCode: this.statusCode,
             sourceAPI: this.sourceAPI,
+            upstream: this.upstream,
         });
     }
 
@@ -114,6 +149,7 @@ export class APIError extends Error {
             param: this.param,
             statusCode,
             sourceAPI: this.sourceAPI,
+            upstream: this.upstream,
         });
     }
 
@@ -126,6 +162,20 @@ export class APIError extends Error {
             param: this.param,
             statusCode: this.statusCode,
             sourceAPI,
+            upstream: this.upstream,
+        });
+    }
+
+    /**
+     * Creates a new error carrying the provider's original error response.
+     */
+    withUpstream(upstream: UpstreamErrorDetail): APIError {
+        return new APIError(this.type, this.message, {
+            code: this.code,
+            param: this.param,
+            statusCode: this.statusCode,
+            sourceAPI: this.sourceAPI,
+            upstream,
         });
     }
 
@@ -190,8 +240,17 @@ export function errInvalidRequest(message: string): APIError {
 }
 
 /**
- * Creates an authentication error.
+ * Creates an error for a request body over the gateway's size limit.
  */
+export function errRequestTooLarge(maxBytes: number): APIError {
+    return new APIError('invalid_request', `Request body exceeds the limit of ${maxBytes} bytes`, {
+        code: 'request_too_large',
+        statusCode: 413,
+    });
+}
+
+/**
+ * Creates an authentication error.
 export function errAuthentication(message: string): APIError {
     return new APIError('authentication', message);
 }
@@ -210,6 +269,16 @@ export function errNotFound(message: string): APIError {
     return new APIError('not_found', message);
 }
 
+/**
+ * Creates an OpenAI-style model_not_found error.
+ */
+export function errModelNotFound(model: string): APIError {
+    return new APIError('not_found', `The model \`${model}\` does not exist or you do not have access to it.`, {
+        code: 'model_not_found',
+        param: 'model',
+    });
+}
+
 /**
  * Creates a rate limit error.
  */
@@ -219,6 +288,15 @@ export function errRateLimit(message: string): APIError {
     });
 }
 
+/**
+ * Creates a budget exceeded error (a tenant's monthly quota is used up).
+ */
+export function errBudgetExceeded(message: string): APIError {
+    return new APIError('rate_limit', message, {
+        code: 'budget_exceeded',
+    });
+}
+
 /**
  * Creates an overloaded error.
  */
@@ -226,6 +304,28 @@ export function errOverloaded(message: string): APIError {
     return new APIError('overloaded', message);
 }
 
+/**
+ * Creates a storage unavailable error (503): the request needs storage
+ * while the gateway is running degraded without it.
+ */
+export function errStorageUnavailable(message: string): APIError {
+    return new APIError('overloaded', message, {
+        code: 'storage_unavailable',
+        statusCode: 503,
+    });
+}
+
+/**
+ * Creates an error for a secret reference that did not resolve. It names
+ * the reference, never a value.
+ */
+export function errSecretUnavailable(reference: string): APIError {
+    return new APIError('server', `Secret '${reference}' is not set`, {
+        code: 'secret_unavailable',
+        statusCode: 500,
+    });
+}
+
 /**
  * Creates a server error.
  */
@@ -233,6 +333,16 @@ export function errServer(message: string): APIError {
     return new APIError('server', message);
 }
 
+/**
+ * Creates an upstream timeout error (504).
+ */
+export function errUpstreamTimeout(
+    message: string,
+    code: 'request_timeout' | 'stream_idle_timeout' | 'deadline_exceeded',
+): APIError {
+    return new APIError('server', message, { code, statusCode: 504 });
+}
+
 /**
  * Creates a context length exceeded error.
  */
@@ -260,6 +370,27 @@ export function errOutputTruncated(message: string): APIError {
     });
 }
 
+/**
+ * Creates an error for model output that should be JSON but isn't.
+ */
+export function errInvalidJSONOutput(message: string): APIError {
+    return new APIError('server', message, {
+        code: 'invalid_json_output',
+        statusCode: 502,
+    });
+}
+
+/**
+ * Creates an error for a request that resolved to a provider outside its
+ * tenant's allowlist (403).
+ */
+export function errProviderPolicyDenied(provider: string): APIError {
+    return new APIError('permission', `Provider '${provider}' is not allowed for this tenant`, {
+        code: 'provider_policy_denied',
+        statusCode: 403,
+    });
+}
+
 // ============================================================================
 // Error Mapping
 // ============================================================================
@@ -5,7 +5,7 @@
  * @module domain/events
  */
 
-import type { APIType, Usage } from './types.js';
+import type { APIType, CanonicalRequest, CanonicalResponse, Usage } from './types.js';
 import type { APIError } from './errors.js';
 
 // ============================================================================
@@ -22,7 +22,8 @@ export type LifecycleEventType =
     | 'response_sent'
     | 'error'
     | 'shadow_started'
-    | 'shadow_completed';
+    | 'shadow_completed'
+    | 'interaction_completed';
 
 /**
  * A lifecycle event in the request processing pipeline.
@@ -54,7 +55,8 @@ export type LifecycleEventData =
     | ProviderResponseData
     | ResponseSentData
     | ErrorData
-    | ShadowData;
+    | ShadowData
+    | InteractionCompletedData;
 
 /** Data for request_received events. */
 export interface RequestReceivedData {
@@ -140,20 +142,95 @@ export interface ShadowData {
     error?: APIError | undefined;
 }
 
+/**
+ * Data for interaction_completed events, published once the response (or
+ * stream) has been delivered.
+ */
+export interface InteractionCompletedData {
+    /** App that served the request. */
+    appName?: string | undefined;
+
+    /** Frontdoor (client API) name. */
+    frontdoor: string;
+
+    /** Provider name. */
+    providerName: string;
+
+    /** Model as requested, after any pipeline override. */
+    model: string;
+
+    /** Whether the response was streamed. */
+    stream: boolean;
+
+    /** HTTP status code returned to the client. */
+    statusCode: number;
+
+    /** Token usage, when reported. */
+    usage?: Usage | undefined;
+
+    /** Estimated cost in USD, when the model has pricing. */
+    costUsd?: number | undefined;
+
+    /** Finish reason of the first choice (non-streaming only). */
+    finishReason?: string | undefined;
+
+    /** Total duration in milliseconds, including the full stream. */
+    totalDurationMs: number;
+
+    /** Per-phase timings. */
+    timings: InteractionTimings;
+
+    /** Error code of a failed request, when known (e.g. "provider_policy_denied"). */
+    errorType?: string | undefined;
+
+    /** Set to "cancelled" for a non-streaming request whose client went away first (status code 499). */
+    status?: 'cancelled' | undefined;
+
+    /** Set when the request was recorded as a summary, to why (e.g. "sampled_out"); bodies are omitted. */
+    recording?: string | undefined;
+
+    /** Canonical request ("full" payloads only). */
+    request?: CanonicalRequest | undefined;
+
+    /** Canonical response ("full" payloads of non-streaming requests only). */
+    response?: CanonicalResponse | undefined;
+
+    /** Set for message batch results, which online metrics should exclude. */
+    batch?: { id: string; result: string } | undefined;
+
+    /** Set for requests sent from the admin console (e.g. "console"), which usage reports exclude. */
+    origin?: string | undefined;
+
+    /** Set for calls the gateway made on its own behalf (e.g. conversation summaries). */
+    internal?: boolean | undefined;
+}
+
 // ============================================================================
 // Interaction Events (for storage)
 // ============================================================================
 
 /** Type of interaction event. */
 export type InteractionEventType =
+    | 'interaction_started'
     | 'request'
     | 'response'
     | 'stream_start'
+    | 'first_token'
     | 'stream_chunk'
+    | 'stream_transcript'
     | 'stream_end'
+    | 'provider_request'
+    | 'provider_response'
+    | 'passthrough'
     | 'error'
     | 'pipeline_pre'
-    | 'pipeline_post';
+    | 'pipeline_post'
+    | 'tool_execute'
+    | 'thread_resolve'
+    | 'thread_update'
+    | 'tool_argument_field'
+    | 'response_denied'
+    | 'sse_event_truncated';
 
 /**
  * An event in an interaction's lifecycle (for storage/audit).
@@ -175,6 +252,46 @@ export interface InteractionEvent {
     payload?: unknown;
 }
 
+// ============================================================================
+// Interaction Timings
+// ============================================================================
+
+/**
+ * Where an interaction's time went, in milliseconds. Phases that did not
+ * run (no pipeline, a streamed response with no encode step) are omitted.
+ */
+export interface InteractionTimings {
+    /** Request arrival to frontdoor dispatch (body read, auth, routing). */
+    authMs?: number | undefined;
+
+    /** Pre-request pipeline. */
+    prePipelineMs?: number | undefined;
+
+    /** Waiting for the tenant's provider concurrency slots, summed over calls. */
+    queueWaitMs?: number | undefined;
+
+    /** Deepest the tenant's queue was when a call joined it, the call included (a count, not a duration). */
+    queueDepth?: number | undefined;
+
+    /** Provider call to the first stream event carrying content (streaming only). */
+    providerTtfbMs?: number | undefined;
+
+    /** Request arrival to the first stream event carrying content (streaming only). */
+    firstTokenMs?: number | undefined;
+
+    /** Provider call to the end of the response or stream. */
+    providerTotalMs?: number | undefined;
+
+    /** Post-request pipeline. */
+    postPipelineMs?: number | undefined;
+
+    /** Encoding the client response. */
+    encodeMs?: number | undefined;
+
+    /** End to end, including the full stream when streaming. */
+    totalMs?: number | undefined;
+}
+
 // ============================================================================
 // Factory Functions
 // ============================================================================
@@ -11,18 +11,27 @@ export * from './types.js';
 export {
     type ErrorType,
     type ErrorCode,
+    type UpstreamErrorDetail,
     APIError,
     isAPIError,
     errInvalidRequest,
+    errRequestTooLarge,
     errAuthentication,
     errPermission,
     errNotFound,
+    errModelNotFound,
     errRateLimit,
+    errBudgetExceeded,
     errOverloaded,
+    errStorageUnavailable,
+    errSecretUnavailable,
     errServer,
+    errUpstreamTimeout,
     errContextLength,
     errMaxTokens,
     errOutputTruncated,
+    errInvalidJSONOutput,
+    errProviderPolicyDenied,
     toOpenAIError,
     toAnthropicError,
     OPENAI_ERROR_TYPE_MAP,
@@ -32,8 +41,20 @@ export {
 // Events
 export * from './events.js';
 
+// Model catalog
+export { type ModelInfo, type ModelPricing, ModelCatalog, BUILTIN_MODELS } from './catalog.js';
+
 // Responses API
 export * from './responses.js';
 
+// Canonical JSON Schemas
+export {
+    CANONICAL_REQUEST_SCHEMA,
+    CANONICAL_RESPONSE_SCHEMA,
+    validateSchema,
+    type JSONSchema,
+} from './schema.js';
+
 // Shadow mode
 export * from './shadow.js';
+export * from './divergence.js';
@@ -49,6 +49,9 @@ export interface ResponsesAPIRequest {
 
     /** Previous response ID for continuation. */
     previousResponseId?: string | undefined;
+
+    /** End user the request is made for, for abuse monitoring. */
+    user?: string | undefined;
 }
 
 /** Input item for Responses API. */
@@ -57,7 +60,7 @@ export interface ResponsesInputItem {
     type: 'message' | 'item_reference';
 
     /** Role (for message type). */
-    role?: 'user' | 'assistant' | undefined;
+    role?: 'system' | 'developer' | 'user' | 'assistant' | undefined;
 
     /** Content (for message type). */
     content?: string | ResponsesContentPart[] | undefined;
@@ -0,0 +1,399 @@
+/**
+ * JSON Schemas for the canonical request and response.
+ *
+ * Describes the camelCase JSON that pipeline webhooks receive and may send
+ * back as mutations. Each object's property list is typed against its domain
+ * interface, so adding or removing a field in types.ts without updating the
+ * schema fails the build.
+ *
+ * @module domain/schema
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    Choice,
+    ContentPart,
+    FunctionDef,
+    ImageSource,
+    ImageURL,
+    Message,
+    MessageContent,
+    RateLimitInfo,
+    ResponseFormat,
+    ThinkingConfig,
+    ToolCall,
+    ToolCallFunction,
+    ToolDefinition,
+    Usage,
+} from './types.js';
+
+// ============================================================================
+// Schema Builders
+// ============================================================================
+
+/** A JSON Schema document or subschema. */
+export type JSONSchema = Record<string, unknown>;
+
+/** One subschema per field of T, optional fields included. */
+type Properties<T> = { [K in keyof T]-?: JSONSchema };
+
+const JSON_SCHEMA_DIALECT = 'https://json-schema.org/draft/2020-12/schema';
+
+function object<T>(
+    description: string,
+    properties: Properties<T>,
+    required: ReadonlyArray<keyof T & string>,
+): JSONSchema {
+    return {
+        type: 'object',
+        description,
+        properties,
+        required,
+        additionalProperties: false,
+    };
+}
+
+function ref(name: string, description?: string): JSONSchema {
+    return description ? { $ref: `#/$defs/${name}`, description } : { $ref: `#/$defs/${name}` };
+}
+
+const stringField = (description: string): JSONSchema => ({ type: 'string', description });
+const integerField = (description: string): JSONSchema => ({ type: 'integer', minimum: 0, description });
+const numberField = (description: string): JSONSchema => ({ type: 'number', description });
+const stringMap = (description: string): JSONSchema => ({
+    type: 'object',
+    description,
+    additionalProperties: { type: 'string' },
+});
+
+/** Raw bytes kept for pass-through; gateway-internal and ignored on input. */
+const internalBytes = (description: string): JSONSchema => ({ description, readOnly: true });
+
+const API_TYPE: JSONSchema = {
+    type: 'string',
+    enum: ['openai', 'anthropic', 'responses', 'completions', 'cohere'],
+};
+
+// ============================================================================
+// Shared Definitions
+// ============================================================================
+
+const DEFINITIONS: Record<string, JSONSchema> = {
+    Message: object<Message>('A chat message.', {
+        role: { type: 'string', enum: ['system', 'developer', 'user', 'assistant', 'tool'] },
+        content: stringField('Text content.'),
+        name: stringField('Author name.'),
+        richContent: ref('MessageContent', 'Multimodal content; takes precedence over content.'),
+        toolCalls: { type: 'array', items: ref('ToolCall'), description: 'Tool calls (assistant messages).' },
+        toolCallId: stringField('Tool call this message answers (tool messages).'),
+    }, ['role', 'content']),
+
+    MessageContent: object<MessageContent>('Multimodal message content.', {
+        text: stringField('Text content.'),
+        parts: { type: 'array', items: ref('ContentPart') },
+    }, []),
+
+    ContentPart: object<ContentPart>('One part of multimodal content.', {
+        type: {
+            type: 'string',
+            enum: ['text', 'image', 'image_url', 'tool_use', 'tool_result', 'thinking', 'redacted_thinking'],
+        },
+        text: stringField('Text (text parts).'),
+        source: ref('ImageSource', 'Inline image (image parts).'),
+        imageUrl: ref('ImageURL', 'Image reference (image_url parts).'),
+        id: stringField('Tool use ID (tool_use parts).'),
+        name: stringField('Tool name (tool_use parts).'),
+        input: { description: 'Tool input (tool_use parts).' },
+        toolUseId: stringField('Tool use being answered (tool_result parts).'),
+        resultContent: stringField('Tool result (tool_result parts).'),
+        isError: { type: 'boolean', description: 'Whether the tool failed (tool_result parts).' },
+        thinking: stringField('Reasoning text (thinking parts).'),
+        signature: stringField('Provider signature to echo back (thinking parts).'),
+        data: stringField('Encrypted reasoning (redacted_thinking parts).'),
+    }, ['type']),
+
+    ImageSource: object<ImageSource>('A base64-encoded image.', {
+        type: { const: 'base64' },
+        mediaType: stringField('MIME type.'),
+        data: stringField('Base64 data.'),
+    }, ['type', 'mediaType', 'data']),
+
+    ImageURL: object<ImageURL>('An image URL.', {
+        url: stringField('Image URL or data URL.'),
+        detail: { type: 'string', enum: ['auto', 'low', 'high'] },
+    }, ['url']),
+
+    ToolCall: object<ToolCall>('A tool call made by the assistant.', {
+        id: stringField('Tool call ID.'),
+        type: { const: 'function' },
+        function: ref('ToolCallFunction'),
+    }, ['id', 'type', 'function']),
+
+    ToolCallFunction: object<ToolCallFunction>('The function a tool call invokes.', {
+        name: stringField('Function name.'),
+        arguments: stringField('Arguments as a JSON string.'),
+    }, ['name', 'arguments']),
+
+    ToolDefinition: object<ToolDefinition>('A tool the model can call.', {
+        name: stringField('Tool name.'),
+        type: { const: 'function' },
+        function: ref('FunctionDef'),
+    }, ['type', 'function']),
+
+    FunctionDef: object<FunctionDef>('A function definition.', {
+        name: stringField('Function name.'),
+        description: stringField('What the function does.'),
+        parameters: { type: 'object', description: 'JSON Schema for the arguments.' },
+    }, ['name', 'parameters']),
+
+    ToolChoice: {
+        description: 'How the model should choose tools.',
+        oneOf: [
+            { type: 'string', enum: ['auto', 'none', 'required'] },
+            {
+                type: 'object',
+                properties: {
+                    type: { const: 'function' },
+                    function: {
+                        type: 'object',
+                        properties: { name: { type: 'string' } },
+                        required: ['name'],
+                    },
+                },
+                required: ['type', 'function'],
+            },
+        ],
+    },
+
+    ResponseFormat: object<ResponseFormat>('Response format.', {
+        type: { type: 'string', enum: ['text', 'json_object', 'json_schema'] },
+        jsonSchema: { type: 'object', description: 'JSON Schema (json_schema only).' },
+    }, ['type']),
+
+    ThinkingConfig: object<ThinkingConfig>('Extended thinking configuration.', {
+        type: { type: 'string', enum: ['enabled', 'disabled'] },
+        budgetTokens: integerField('Token budget for thinking.'),
+    }, ['type']),
+
+    Usage: object<Usage>('Token usage.', {
+        promptTokens: integerField('Prompt tokens.'),
+        completionTokens: integerField('Completion tokens.'),
+        totalTokens: integerField('Total tokens.'),
+        reasoningTokens: integerField('Reasoning tokens (subset of completionTokens).'),
+    }, ['promptTokens', 'completionTokens', 'totalTokens']),
+
+    Choice: object<Choice>('A completion choice.', {
+        index: integerField('Choice index.'),
+        message: ref('Message'),
+        finishReason: {
+            type: ['string', 'null'],
+            enum: ['stop', 'length', 'tool_calls', 'content_filter', null],
+        },
+        stopSequence: stringField('The stop sequence that ended generation, when reported.'),
+        logprobs: { description: 'Log probabilities, if requested.' },
+    }, ['index', 'message', 'finishReason']),
+
+    RateLimitInfo: object<RateLimitInfo>('Upstream rate limit information.', {
+        requestsLimit: integerField('Request limit.'),
+        requestsRemaining: integerField('Remaining requests.'),
+        requestsReset: stringField('When the request limit resets.'),
+        tokensLimit: integerField('Token limit.'),
+        tokensRemaining: integerField('Remaining tokens.'),
+        tokensReset: stringField('When the token limit resets.'),
+    }, []),
+};
+
+// ============================================================================
+// Canonical Schemas
+// ============================================================================
+
+/**
+ * JSON Schema for CanonicalRequest.
+ */
+export const CANONICAL_REQUEST_SCHEMA: JSONSchema = {
+    $schema: JSON_SCHEMA_DIALECT,
+    title: 'CanonicalRequest',
+    ...object<CanonicalRequest>('Canonical request, as sent to pipeline webhooks.', {
+        tenantId: stringField('Tenant ID; set by the gateway.'),
+        model: stringField('Model identifier.'),
+        messages: { type: 'array', items: ref('Message'), description: 'Conversation messages.' },
+        stream: { type: 'boolean', description: 'Whether to stream the response.' },
+        maxTokens: integerField('Maximum tokens to generate.'),
+        temperature: { ...numberField('Sampling temperature.'), minimum: 0, maximum: 2 },
+        n: { ...integerField('Number of choices.'), minimum: 1 },
+        topP: { ...numberField('Nucleus sampling.'), minimum: 0, maximum: 1 },
+        frequencyPenalty: { ...numberField('Penalty on tokens by how often they have appeared so far.'), minimum: -2, maximum: 2 },
+        presencePenalty: { ...numberField('Penalty on tokens that have appeared at all so far.'), minimum: -2, maximum: 2 },
+        tools: { type: 'array', items: ref('ToolDefinition'), description: 'Tools the model can use.' },
+        toolChoice: ref('ToolChoice'),
+        parallelToolCalls: { type: 'boolean', description: 'Whether the model may call several tools in one turn.' },
+        metadata: stringMap('Arbitrary metadata.'),
+        systemPrompt: stringField('System prompt.'),
+        responseFormat: ref('ResponseFormat'),
+        stop: { type: 'array', items: { type: 'string' }, description: 'Stop sequences.' },
+        instructions: stringField('Instructions (Responses API).'),
+        previousResponseId: stringField('Previous response ID (Responses API).'),
+        thinking: ref('ThinkingConfig'),
+        reasoningEffort: { type: 'string', enum: ['low', 'medium', 'high'] },
+        logprobs: { type: 'boolean', description: 'Return the log probability of each output token.' },
+        topLogprobs: { ...integerField('Most likely alternatives returned per token (requires logprobs).'), minimum: 0, maximum: 20 },
+        userAgent: stringField('Client User-Agent; set by the gateway.'),
+        endUserId: stringField('End user the request is made for, for abuse monitoring.'),
+        store: { type: 'boolean', description: 'Keep the response retrievable from the gateway.' },
+        streamIncludeUsage: { type: 'boolean', description: 'End streams with a usage chunk.' },
+        upstreamHeaders: {
+            type: 'object',
+            description: 'Names of the headers set or stripped upstream; set by the gateway.',
+            properties: { names: { type: 'array', items: { type: 'string' } } },
+            readOnly: true,
+        },
+        sourceAPIType: { ...API_TYPE, description: 'API format the client used.' },
+        rawRequest: internalBytes('Original request body for pass-through.'),
+    }, ['model', 'messages', 'stream', 'sourceAPIType']),
+    $defs: DEFINITIONS,
+};
+
+/**
+ * JSON Schema for CanonicalResponse.
+ */
+export const CANONICAL_RESPONSE_SCHEMA: JSONSchema = {
+    $schema: JSON_SCHEMA_DIALECT,
+    title: 'CanonicalResponse',
+    ...object<CanonicalResponse>('Canonical response, as sent to post-request pipeline webhooks.', {
+        id: stringField('Response ID.'),
+        object: stringField('Object type (e.g., chat.completion).'),
+        created: integerField('Unix timestamp of creation.'),
+        model: stringField('Model that generated the response.'),
+        choices: { type: 'array', items: ref('Choice') },
+        usage: ref('Usage'),
+        sourceAPIType: { ...API_TYPE, description: 'API format of the provider.' },
+        rawResponse: internalBytes('Provider response body as sent.'),
+        rawResponseHeaders: { ...stringMap('Provider response headers kept with the raw body.'), readOnly: true },
+        systemFingerprint: stringField('System fingerprint (OpenAI).'),
+        metadata: stringMap('The request\'s metadata, echoed back.'),
+        rateLimits: ref('RateLimitInfo'),
+        providerKeyId: stringField('Non-secret identifier of the upstream API key.'),
+        providerModel: stringField('Model the provider actually used.'),
+        providerRequestBody: internalBytes('Request body sent upstream.'),
+    }, ['id', 'object', 'created', 'model', 'choices', 'usage', 'sourceAPIType']),
+    $defs: DEFINITIONS,
+};
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Validates a value against one of the canonical schemas and returns the
+ * violations as "path: problem" strings. Supports the keywords the canonical
+ * schemas use; undefined properties count as absent.
+ */
+export function validateSchema(schema: JSONSchema, value: unknown): string[] {
+    return validateWithin(schema, value, schema);
+}
+
+function check(schema: JSONSchema, value: unknown, path: string, root: JSONSchema, errors: string[]): void {
+    const at = path || '(root)';
+
+    const { $ref } = schema;
+    if (typeof $ref === 'string') {
+        const defs = root.$defs as Record<string, JSONSchema>;
+        const target = defs[$ref.replace('#/$defs/', '')];
+        if (target) check(target, value, path, root, errors);
+        return;
+    }
+
+    if (Array.isArray(schema.oneOf)) {
+        const matches = (schema.oneOf as JSONSchema[])
+            .filter((option) => validateWithin(option, value, root).length === 0);
+        if (matches.length !== 1) {
+            errors.push(`${at}: does not match any allowed form`);
+        }
+        return;
+    }
+
+    if ('const' in schema && value !== schema.const) {
+        errors.push(`${at}: must be ${JSON.stringify(schema.const)}`);
+        return;
+    }
+
+    const allowed = schema.enum;
+    if (Array.isArray(allowed) && !allowed.includes(value)) {
+        errors.push(`${at}: must be one of ${allowed.map((v) => JSON.stringify(v)).join(', ')}`);
+        return;
+    }
+
+    if (schema.type !== undefined) {
+        const types = Array.isArray(schema.type) ? schema.type as string[] : [schema.type as string];
+        if (!types.some((type) => hasType(value, type))) {
+            errors.push(`${at}: expected ${types.join(' or ')}, got ${describeType(value)}`);
+            return;
+        }
+    }
+
+    const { minimum, maximum } = schema;
+    if (typeof value === 'number') {
+        if (typeof minimum === 'number' && value < minimum) {
+            errors.push(`${at}: must be at least ${minimum}`);
+        }
+        if (typeof maximum === 'number' && value > maximum) {
+            errors.push(`${at}: must be at most ${maximum}`);
+        }
+    }
+
+    if (Array.isArray(value) && schema.items) {
+        value.forEach((item, i) => check(schema.items as JSONSchema, item, `${path}[${i}]`, root, errors));
+    }
+
+    if (hasType(value, 'object')) {
+        const object = value as Record<string, unknown>;
+        const properties = (schema.properties ?? {}) as Record<string, JSONSchema>;
+        const field = (key: string) => (path ? `${path}.${key}` : key);
+
+        for (const key of (schema.required ?? []) as string[]) {
+            if (object[key] === undefined) {
+                errors.push(`${field(key)}: is required`);
+            }
+        }
+
+        for (const [key, child] of Object.entries(object)) {
+            if (child === undefined) continue;
+            const childSchema = properties[key];
+            if (childSchema) {
+                check(childSchema, child, field(key), root, errors);
+            } else if (schema.additionalProperties === false) {
+                errors.push(`${field(key)}: unknown field`);
+            } else if (typeof schema.additionalProperties === 'object') {
+                check(schema.additionalProperties as JSONSchema, child, field(key), root, errors);
+            }
+        }
+    }
+}
+
+function validateWithin(schema: JSONSchema, value: unknown, root: JSONSchema): string[] {
+    const errors: string[] = [];
+    check(schema, value, '', root, errors);
+    return errors;
+}
+
+function hasType(value: unknown, type: string): boolean {
+    switch (type) {
+        case 'null':
+            return value === null;
+        case 'array':
+            return Array.isArray(value);
+        case 'object':
+            return typeof value === 'object' && value !== null && !Array.isArray(value);
+        case 'integer':
+            return Number.isInteger(value);
+        default:
+            return typeof value === type;
+    }
+}
+
+function describeType(value: unknown): string {
+    if (value === null) return 'null';
+    if (Array.isArray(value)) return 'array';
+    return typeof value;
+}
@@ -6,6 +6,7 @@
 
 import type { APIType, CanonicalRequest, CanonicalResponse } from './types.js';
 import type { APIError } from './errors.js';
+import { fromCanonicalResponse, fromShadowResponse, diffToolCalls } from './divergence.js';
 
 // ============================================================================
 // Shadow Result Types
@@ -187,24 +188,23 @@ export function detectDivergences(
     shadow: ShadowResponse,
 ): Divergence[] {
     const divergences: Divergence[] = [];
+    const p = fromCanonicalResponse(primary);
+    const s = fromShadowResponse(shadow);
 
     // Compare tool call count
-    const primaryToolCalls = primary.choices[0]?.message.toolCalls ?? [];
-    const shadowToolCalls = shadow.toolCalls ?? [];
-
-    if (primaryToolCalls.length !== shadowToolCalls.length) {
+    if (p.toolCalls.length !== s.toolCalls.length) {
         divergences.push({
             type: 'tool_call_count',
-            description: `Primary made ${primaryToolCalls.length} tool calls, shadow made ${shadowToolCalls.length}`,
+            description: `Primary made ${p.toolCalls.length} tool calls, shadow made ${s.toolCalls.length}`,
             severity: 'critical',
-            primaryValue: String(primaryToolCalls.length),
-            shadowValue: String(shadowToolCalls.length),
+            primaryValue: String(p.toolCalls.length),
+            shadowValue: String(s.toolCalls.length),
         });
     }
 
     // Compare tool call names
-    const primaryNames = primaryToolCalls.map((tc) => tc.function.name).sort();
-    const shadowNames = shadowToolCalls.map((tc) => tc.name).sort();
+    const primaryNames = p.toolCalls.map((tc) => tc.name).sort();
+    const shadowNames = s.toolCalls.map((tc) => tc.name).sort();
 
     if (JSON.stringify(primaryNames) !== JSON.stringify(shadowNames)) {
         divergences.push({
@@ -216,9 +216,21 @@ export function detectDivergences(
         });
     }
 
+    // Compare arguments of calls to the same function at the same position
+    for (const diff of diffToolCalls(p.toolCalls, s.toolCalls)) {
+        if (!diff.name.equal || diff.argumentChanges.length === 0) continue;
+        divergences.push({
+            type: 'tool_call_arguments',
+            description: `Different arguments for ${diff.name.primary}: ${diff.argumentChanges.map((c) => c.path).join(', ')}`,
+            severity: 'warning',
+            primaryValue: p.toolCalls[diff.index]?.arguments,
+            shadowValue: s.toolCalls[diff.index]?.arguments,
+        });
+    }
+
     // Compare content length (significant difference threshold: 50%)
-    const primaryContent = primary.choices[0]?.message.content ?? '';
-    const shadowContent = shadow.content;
+    const primaryContent = p.content;
+    const shadowContent = s.content;
     const lengthDiff = Math.abs(primaryContent.length - shadowContent.length);
     const avgLength = (primaryContent.length + shadowContent.length) / 2;
 
@@ -233,8 +245,8 @@ export function detectDivergences(
     }
 
     // Compare finish reason
-    const primaryFinish = primary.choices[0]?.finishReason;
-    const shadowFinish = shadow.finishReason;
+    const primaryFinish = p.finishReason;
+    const shadowFinish = s.finishReason;
 
     if (primaryFinish !== shadowFinish) {
         divergences.push({
@@ -10,14 +10,18 @@
 // ============================================================================
 
 /** Identifies the API format for frontdoors and providers. */
-export type APIType = 'openai' | 'anthropic' | 'responses';
+export type APIType = 'openai' | 'anthropic' | 'responses' | 'completions' | 'cohere';
 
 // ============================================================================
 // Message Types
 // ============================================================================
 
-/** Role of a message participant. */
-export type MessageRole = 'system' | 'user' | 'assistant' | 'tool';
+/**
+ * Role of a message participant. Developer messages are instructions, like
+ * system ones, under OpenAI's newer name; APIs without the role get them as
+ * system instructions.
+ */
+export type MessageRole = 'system' | 'developer' | 'user' | 'assistant' | 'tool';
 
 /**
  * Represents a chat message with support for both simple text
@@ -35,7 +39,8 @@ export interface Message {
 
     /**
      * Rich multimodal content (images, tool calls, etc.).
-     * When set, this takes precedence over content field.
+     * When set, this takes precedence over content field. Tool messages
+     * use it for results that carry images alongside text.
      */
     richContent?: MessageContent | undefined;
 
@@ -61,7 +66,9 @@ export type ContentPartType =
     | 'image'
     | 'image_url'
     | 'tool_use'
-    | 'tool_result';
+    | 'tool_result'
+    | 'thinking'
+    | 'redacted_thinking';
 
 /** A single part of multimodal content. */
 export interface ContentPart {
@@ -94,6 +101,15 @@ export interface ContentPart {
 
     /** Whether the tool result is an error (for type='tool_result'). */
     isError?: boolean | undefined;
+
+    /** Reasoning text (for type='thinking'). */
+    thinking?: string | undefined;
+
+    /** Provider signature that must be echoed back (for type='thinking'). */
+    signature?: string | undefined;
+
+    /** Opaque encrypted reasoning (for type='redacted_thinking'). */
+    data?: string | undefined;
 }
 
 /** Image source for base64-encoded images. */
@@ -187,7 +203,10 @@ export interface FunctionDef {
     parameters: Record<string, unknown>;
 }
 
-/** Tool choice specification. */
+/**
+ * Tool choice specification: let the model decide ("auto"), forbid tools
+ * ("none"), require some tool ("required"), or require one named function.
+ */
 export type ToolChoice =
     | 'auto'
     | 'none'
@@ -198,6 +217,18 @@ export type ToolChoice =
 // Request/Response Types
 // ============================================================================
 
+/** Extended thinking (reasoning) configuration. */
+export interface ThinkingConfig {
+    /** Whether thinking is enabled. */
+    type: 'enabled' | 'disabled';
+
+    /** Token budget for thinking (Anthropic budget_tokens). */
+    budgetTokens?: number | undefined;
+}
+
+/** OpenAI-style reasoning effort. */
+export type ReasoningEffort = 'low' | 'medium' | 'high';
+
 /** Response format specification. */
 export interface ResponseFormat {
     /** Format type. */
@@ -230,15 +261,27 @@ export interface CanonicalRequest {
     /** Sampling temperature (0-2). */
     temperature?: number | undefined;
 
+    /** Number of choices to generate. */
+    n?: number | undefined;
+
     /** Top-p (nucleus) sampling. */
     topP?: number | undefined;
 
+    /** Penalty on tokens by how often they have appeared so far (-2 to 2). */
+    frequencyPenalty?: number | undefined;
+
+    /** Penalty on tokens that have appeared at all so far (-2 to 2). */
+    presencePenalty?: number | undefined;
+
     /** Tools the model can use. */
     tools?: ToolDefinition[] | undefined;
 
     /** How the model should choose tools. */
     toolChoice?: ToolChoice | undefined;
 
+    /** Whether the model may call several tools in one turn (default true). */
+    parallelToolCalls?: boolean | undefined;
+
     /** Arbitrary metadata. */
     metadata?: Record<string, string> | undefined;
 
@@ -257,9 +300,33 @@ export interface CanonicalRequest {
     /** Previous response ID (Responses API - for continuation). */
     previousResponseId?: string | undefined;
 
+    /** Extended thinking configuration (Anthropic style). */
+    thinking?: ThinkingConfig | undefined;
+
+    /** Reasoning effort (OpenAI style). */
+    reasoningEffort?: ReasoningEffort | undefined;
+
+    /** Return the log probability of each output token. */
+    logprobs?: boolean | undefined;
+
+    /** Most likely alternatives returned per token, 0-20 (requires logprobs). */
+    topLogprobs?: number | undefined;
+
     /** User-Agent header from incoming request. */
     userAgent?: string | undefined;
 
+    /** End user the request is made for (OpenAI `user`, Anthropic `metadata.user_id`), for abuse monitoring. */
+    endUserId?: string | undefined;
+
+    /** Keep the response retrievable from the gateway (OpenAI chat completions `store`). */
+    store?: boolean | undefined;
+
+    /** End streams with a usage chunk (OpenAI `stream_options.include_usage`). */
+    streamIncludeUsage?: boolean | undefined;
+
+    /** Extra upstream headers resolved from app and provider header rules. */
+    upstreamHeaders?: UpstreamHeaderSet | undefined;
+
     /** Original API format of the incoming request. */
     sourceAPIType: APIType;
 
@@ -267,6 +334,18 @@ export interface CanonicalRequest {
     rawRequest?: Uint8Array | undefined;
 }
 
+/**
+ * Headers to add to or remove from an upstream request. Values may hold
+ * secrets, so implementations serialize to header names only.
+ */
+export interface UpstreamHeaderSet {
+    /** Names of the headers set or stripped, for recording. */
+    readonly names: string[];
+
+    /** Returns `headers` with the set applied; never overrides protected headers. */
+    apply(headers: Record<string, string>): Record<string, string>;
+}
+
 /** Token usage statistics. */
 export interface Usage {
     /** Number of tokens in the prompt. */
@@ -277,6 +356,9 @@ export interface Usage {
 
     /** Total tokens used. */
     totalTokens: number;
+
+    /** Tokens spent on reasoning/thinking (subset of completionTokens). */
+    reasoningTokens?: number | undefined;
 }
 
 /** A single completion choice. */
@@ -290,8 +372,38 @@ export interface Choice {
     /** Why generation stopped. */
     finishReason: FinishReason | null;
 
+    /** The stop sequence that ended generation, when the provider reports it. */
+    stopSequence?: string | undefined;
+
     /** Log probabilities (if requested). */
-    logprobs?: unknown;
+    logprobs?: Logprobs | null | undefined;
+}
+
+/** Per-token log probabilities of a choice (or of one stream delta). */
+export interface Logprobs {
+    /** Content tokens. */
+    content: TokenLogprob[] | null;
+
+    /** Refusal tokens, when the model refused. */
+    refusal?: TokenLogprob[] | null | undefined;
+}
+
+/** A token's log probability, with the most likely alternatives. */
+export interface TokenLogprob extends TopLogprob {
+    /** Most likely tokens at this position, when requested. */
+    topLogprobs: TopLogprob[];
+}
+
+/** A candidate token's log probability. */
+export interface TopLogprob {
+    /** The token. */
+    token: string;
+
+    /** Log probability of the token. */
+    logprob: number;
+
+    /** UTF-8 bytes of the token, or null when it has none. */
+    bytes: number[] | null;
 }
 
 /** Reason why generation stopped. */
@@ -348,15 +460,24 @@ export interface CanonicalResponse {
     /** API type of the provider that generated this. */
     sourceAPIType: APIType;
 
-    /** Original response body for pass-through mode. */
+    /** The provider's response body as sent, for recording and pass-through. */
     rawResponse?: Uint8Array | undefined;
 
+    /** Provider response headers kept with rawResponse (request IDs, rate limits). */
+    rawResponseHeaders?: Record<string, string> | undefined;
+
     /** System fingerprint (OpenAI specific). */
     systemFingerprint?: string | undefined;
 
+    /** The request's metadata, echoed back (OpenAI chat completions). */
+    metadata?: Record<string, string> | undefined;
+
     /** Rate limit info from upstream. */
     rateLimits?: RateLimitInfo | undefined;
 
+    /** Non-secret identifier of the upstream API key used. */
+    providerKeyId?: string | undefined;
+
     /** Actual model used by provider (for logging when model is rewritten). */
     providerModel?: string | undefined;
 
@@ -387,11 +508,15 @@ export type StreamEventType =
     | 'response.created'
     | 'response.in_progress'
     | 'response.output_item.added'
-    | 'response.output_item.delta'
+    | 'response.content_part.added'
+    | 'response.output_text.delta'
+    | 'response.output_text.done'
+    | 'response.content_part.done'
+    | 'response.function_call_arguments.delta'
+    | 'response.function_call_arguments.done'
     | 'response.output_item.done'
     | 'response.completed'
-    | 'response.failed'
-    | 'response.done';
+    | 'response.failed';
 
 /**
  * Canonical streaming event.
@@ -407,9 +532,18 @@ export interface CanonicalEvent {
     /** Text content delta. */
     contentDelta?: string | undefined;
 
+    /** Thinking content delta. */
+    thinkingDelta?: string | undefined;
+
+    /** Thinking signature delta. */
+    signatureDelta?: string | undefined;
+
     /** Tool call chunk. */
     toolCall?: ToolCallChunk | undefined;
 
+    /** Log probabilities of this delta's tokens (if requested). */
+    logprobs?: Logprobs | null | undefined;
+
     /** Token usage update. */
     usage?: Usage | undefined;
 
@@ -419,12 +553,18 @@ export interface CanonicalEvent {
     /** Content block index. */
     index?: number | undefined;
 
+    /** Choice index (for n > 1). */
+    choiceIndex?: number | undefined;
+
     /** Content block (for block start events). */
     contentBlock?: ContentPart | undefined;
 
     /** Finish reason (for completion events). */
     finishReason?: string | undefined;
 
+    /** Matched stop sequence (for completion events). */
+    stopSequence?: string | undefined;
+
     /** Response ID (for response events). */
     responseId?: string | undefined;
 
@@ -434,7 +574,13 @@ export interface CanonicalEvent {
     /** Actual provider model (for logging). */
     providerModel?: string | undefined;
 
-    /** Raw event data for pass-through mode. */
+    /** Non-secret identifier of the upstream API key (first event only). */
+    providerKeyId?: string | undefined;
+
+    /** Gateway warning surfaced to the client (done events only). */
+    warning?: string | undefined;
+
+    /** The provider's SSE lines this event was decoded from, for recording and pass-through. */
     rawEvent?: Uint8Array | undefined;
 }
 
@@ -499,6 +645,77 @@ export function hasRichContent(message: Message): boolean {
     return message.richContent.parts.some((p) => p.type !== 'text');
 }
 
+/**
+ * Returns the thinking parts of a message, if any.
+ */
+export function getThinkingParts(message: Message): ContentPart[] {
+    return message.richContent?.parts?.filter(
+        (p) => p.type === 'thinking' || p.type === 'redacted_thinking',
+    ) ?? [];
+}
+
+/**
+ * Reports whether a message instructs the model (system or developer role)
+ * rather than taking part in the conversation.
+ */
+export function isInstructionMessage(message: Pick<Message, 'role'>): boolean {
+    return message.role === 'system' || message.role === 'developer';
+}
+
+/**
+ * Returns the image parts of a message, inline or by URL, if any.
+ */
+export function getImageParts(message: Message): ContentPart[] {
+    return message.richContent?.parts?.filter(
+        (p) => p.type === 'image' || p.type === 'image_url',
+    ) ?? [];
+}
+
+/**
+ * Parses an OpenAI-style tool_choice: a mode string, or a specific function
+ * in the chat (`{type, function: {name}}`) or Responses (`{type, name}`)
+ * shape. Returns undefined for anything else.
+ */
+export function parseToolChoice(value: unknown): ToolChoice | undefined {
+    if (value === 'auto' || value === 'none' || value === 'required') {
+        return value;
+    }
+    if (typeof value !== 'object' || value === null) {
+        return undefined;
+    }
+
+    const choice = value as { type?: unknown; name?: unknown; function?: { name?: unknown } };
+    const name = choice.function?.name ?? choice.name;
+    if (choice.type === 'function' && typeof name === 'string' && name) {
+        return { type: 'function', function: { name } };
+    }
+    return undefined;
+}
+
+/**
+ * Maps a thinking budget to an OpenAI reasoning effort.
+ */
+export function budgetToReasoningEffort(budgetTokens: number | undefined): ReasoningEffort {
+    if (budgetTokens === undefined) return 'medium';
+    if (budgetTokens <= 2048) return 'low';
+    if (budgetTokens <= 8192) return 'medium';
+    return 'high';
+}
+
+/**
+ * Maps an OpenAI reasoning effort to a thinking budget.
+ */
+export function reasoningEffortToBudget(effort: ReasoningEffort): number {
+    switch (effort) {
+        case 'low':
+            return 1024;
+        case 'medium':
+            return 4096;
+        case 'high':
+            return 16384;
+    }
+}
+
 /**
  * Deep clones a CanonicalRequest.
  */
@@ -518,6 +735,7 @@ export function cloneRequest(req: CanonicalRequest): CanonicalRequest {
         tools: req.tools ? [...req.tools] : undefined,
         metadata: req.metadata ? { ...req.metadata } : undefined,
         stop: req.stop ? [...req.stop] : undefined,
+        thinking: req.thinking ? { ...req.thinking } : undefined,
         rawRequest: req.rawRequest ? new Uint8Array(req.rawRequest) : undefined,
     };
 }
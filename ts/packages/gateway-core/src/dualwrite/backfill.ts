@@ -0,0 +1,334 @@
+/**
+ * Backfill and consistency checks for dual-write migrations.
+ *
+ * Dual-writing only mirrors new writes; the backfill copies what the
+ * primary held before it started. Interactions are read in batches, oldest
+ * first, each copied with its events, shadow results, and provider
+ * attempts; thread state mappings and tenants follow. Rows the secondary
+ * already has were dual-written, and are newer than the primary's copy
+ * read here, so they are skipped; events and shadow results are matched by
+ * ID. Threads, usage and request stats, and metadata indexes can't be
+ * listed, so they are not copied.
+ *
+ * The consistency check compares a random sample of interactions on both
+ * sides, with their events, to validate a backfill before cutover.
+ *
+ * @module dualwrite/backfill
+ */
+
+import type { InteractionSummary, StorageProvider } from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+import { isAttemptStore } from '../usage/attempts.js';
+import { isTenantStore } from '../tenants/registry.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default number of interactions read per batch. */
+export const DEFAULT_BACKFILL_BATCH_SIZE = 100;
+
+/** Largest batch size accepted. */
+export const MAX_BACKFILL_BATCH_SIZE = 1000;
+
+/** Default number of interactions a consistency check compares. */
+export const DEFAULT_CONSISTENCY_SAMPLE = 100;
+
+/** Largest consistency check sample accepted. */
+export const MAX_CONSISTENCY_SAMPLE = 1000;
+
+/** Thread state mappings read per page. */
+const THREAD_STATE_PAGE_SIZE = 100;
+
+/**
+ * Rows a backfill copied, by kind.
+ */
+export interface DualWriteBackfillCounts {
+    /** Interactions read from the primary. */
+    interactions: number;
+
+    /** Conversations copied. */
+    conversations: number;
+
+    /** Responses copied. */
+    responses: number;
+
+    /** Interaction events copied. */
+    events: number;
+
+    /** Shadow results copied. */
+    shadowResults: number;
+
+    /** Interactions whose provider attempts were copied. */
+    attempts: number;
+
+    /** Thread state mappings copied. */
+    threadState: number;
+
+    /** Tenants copied. */
+    tenants: number;
+
+    /** Rows the secondary already had. */
+    skipped: number;
+
+    /** Interactions that failed to copy. */
+    failed: number;
+}
+
+/**
+ * Backfill options.
+ */
+export interface BackfillOptions {
+    /** Interactions read per batch. */
+    batchSize: number;
+
+    /** Counts to update as rows are copied, for progress reporting. */
+    counts: DualWriteBackfillCounts;
+
+    /** Logger for interactions that fail to copy. */
+    logger?: Logger | undefined;
+}
+
+/**
+ * How a sampled interaction differs between the two sides.
+ */
+export interface ConsistencyMismatch {
+    /** Interaction ID. */
+    id: string;
+
+    /** Interaction type. */
+    type: InteractionSummary['type'];
+
+    /** What differs: the record is missing or different, or its events differ. */
+    problem: 'missing' | 'different' | 'events_differ';
+}
+
+/**
+ * Outcome of a consistency check.
+ */
+export interface ConsistencyReport {
+    /** Interactions compared. */
+    sampled: number;
+
+    /** Interactions identical on both sides. */
+    matched: number;
+
+    /** Interactions that differ. */
+    mismatches: ConsistencyMismatch[];
+}
+
+// ============================================================================
+// Backfill
+// ============================================================================
+
+/**
+ * Counts with nothing copied yet.
+ */
+export function emptyBackfillCounts(): DualWriteBackfillCounts {
+    return {
+        interactions: 0,
+        conversations: 0,
+        responses: 0,
+        events: 0,
+        shadowResults: 0,
+        attempts: 0,
+        threadState: 0,
+        tenants: 0,
+        skipped: 0,
+        failed: 0,
+    };
+}
+
+/**
+ * Copies the primary's rows to the secondary. Safe to run repeatedly
+ * and alongside live traffic.
+ */
+export async function backfillSecondary(
+    primary: StorageProvider,
+    secondary: StorageProvider,
+    options: BackfillOptions,
+): Promise<void> {
+    const { batchSize, counts } = options;
+
+    // Oldest first, so interactions written meanwhile land after the cursor
+    for (let offset = 0; ; offset += batchSize) {
+        const batch = await primary.listInteractions({ limit: batchSize, offset, orderBy: 'createdAt', order: 'asc' });
+        for (const summary of batch) {
+            counts.interactions++;
+            try {
+                await copyInteraction(primary, secondary, summary, counts);
+            } catch (error) {
+                counts.failed++;
+                options.logger?.warn('storage_backfill_interaction_failed', {
+                    interactionId: summary.id,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            }
+        }
+        if (batch.length < batchSize) break;
+    }
+
+    let cursor: string | undefined;
+    for (;;) {
+        const page = await primary.listThreadState({ limit: THREAD_STATE_PAGE_SIZE, cursor });
+        for (const entry of page) {
+            if (await secondary.getThreadState(entry.threadKey) !== null) {
+                counts.skipped++;
+                continue;
+            }
+            await secondary.setThreadState(entry.threadKey, entry.responseId);
+            counts.threadState++;
+        }
+        if (page.length < THREAD_STATE_PAGE_SIZE) break;
+        cursor = page[page.length - 1]!.keyHash;
+    }
+
+    if (isTenantStore(primary) && isTenantStore(secondary)) {
+        for (const tenant of await primary.listTenants()) {
+            if (await secondary.getTenant(tenant.id)) {
+                counts.skipped++;
+                continue;
+            }
+            await secondary.saveTenant(tenant);
+            counts.tenants++;
+        }
+    }
+}
+
+async function copyInteraction(
+    primary: StorageProvider,
+    secondary: StorageProvider,
+    summary: InteractionSummary,
+    counts: DualWriteBackfillCounts,
+): Promise<void> {
+    const { id } = summary;
+    if (summary.type === 'conversation') {
+        const conversation = await primary.getConversation(id, UNSCOPED_TENANT);
+        if (conversation && !await secondary.getConversation(id, UNSCOPED_TENANT)) {
+            await secondary.saveConversation(conversation);
+            counts.conversations++;
+        } else {
+            counts.skipped++;
+        }
+    } else {
+        const response = await primary.getResponse(id, UNSCOPED_TENANT);
+        if (response && !await secondary.getResponse(id, UNSCOPED_TENANT)) {
+            await secondary.saveResponse(response);
+            counts.responses++;
+        } else {
+            counts.skipped++;
+        }
+    }
+
+    const copied = new Set((await secondary.getEvents(id, UNSCOPED_TENANT)).map((e) => e.id));
+    for (const event of await primary.getEvents(id, UNSCOPED_TENANT)) {
+        if (copied.has(event.id)) continue;
+        await secondary.saveEvent(event);
+        counts.events++;
+    }
+
+    const shadows = new Set((await secondary.getShadowResults(id, UNSCOPED_TENANT)).map((r) => r.id));
+    for (const result of await primary.getShadowResults(id, UNSCOPED_TENANT)) {
+        if (shadows.has(result.id)) continue;
+        await secondary.saveShadowResult(result);
+        counts.shadowResults++;
+    }
+
+    if (isAttemptStore(primary) && isAttemptStore(secondary)) {
+        const attempts = await primary.listAttempts(id, UNSCOPED_TENANT);
+        if (attempts.length > 0 && (await secondary.listAttempts(id, UNSCOPED_TENANT)).length === 0) {
+            // Saving replaces the interaction's attempts, so repeats are harmless
+            await secondary.saveAttempts(attempts);
+            counts.attempts++;
+        }
+    }
+}
+
+// ============================================================================
+// Consistency Check
+// ============================================================================
+
+/**
+ * Compares up to `sample` randomly chosen interactions of the primary with
+ * the secondary's: the conversation or response record, and its events.
+ */
+export async function checkConsistency(
+    primary: StorageProvider,
+    secondary: StorageProvider,
+    sample: number,
+): Promise<ConsistencyReport> {
+    const total = await primary.getInteractionCount();
+    const report: ConsistencyReport = { sampled: 0, matched: 0, mismatches: [] };
+
+    for (const offset of sampleOffsets(total, sample)) {
+        const [summary] = await primary.listInteractions({ limit: 1, offset, orderBy: 'createdAt', order: 'asc' });
+        if (!summary) continue;
+        report.sampled++;
+
+        const problem = await compareInteraction(primary, secondary, summary);
+        if (problem) {
+            report.mismatches.push({ id: summary.id, type: summary.type, problem });
+        } else {
+            report.matched++;
+        }
+    }
+    return report;
+}
+
+async function compareInteraction(
+    primary: StorageProvider,
+    secondary: StorageProvider,
+    summary: InteractionSummary,
+): Promise<ConsistencyMismatch['problem'] | undefined> {
+    const get = (store: StorageProvider) => summary.type === 'conversation'
+        ? store.getConversation(summary.id, UNSCOPED_TENANT)
+        : store.getResponse(summary.id, UNSCOPED_TENANT);
+    const [ours, theirs] = await Promise.all([get(primary), get(secondary)]);
+    if (!theirs) {
+        return 'missing';
+    }
+    if (canonicalJSON(ours) !== canonicalJSON(theirs)) {
+        return 'different';
+    }
+
+    const [events, copies] = await Promise.all([
+        primary.getEvents(summary.id, UNSCOPED_TENANT),
+        secondary.getEvents(summary.id, UNSCOPED_TENANT),
+    ]);
+    const ids = (list: typeof events) => list.map((e) => e.id).sort().join(',');
+    return ids(events) === ids(copies) ? undefined : 'events_differ';
+}
+
+/**
+ * Up to `sample` distinct offsets in [0, total), in increasing order.
+ */
+function sampleOffsets(total: number, sample: number): number[] {
+    if (sample >= total) {
+        return Array.from({ length: total }, (_, i) => i);
+    }
+    const offsets = new Set<number>();
+    while (offsets.size < sample) {
+        offsets.add(Math.floor(Math.random() * total));
+    }
+    return [...offsets].sort((a, b) => a - b);
+}
+
+/** JSON with sorted keys and undefined fields dropped, for comparison. */
+function canonicalJSON(value: unknown): string {
+    if (value instanceof Date) {
+        return JSON.stringify(value.toISOString());
+    }
+    if (Array.isArray(value)) {
+        return `[${value.map((item) => canonicalJSON(item ?? null)).join(',')}]`;
+    }
+    if (value !== null && typeof value === 'object') {
+        const record = value as Record<string, unknown>;
+        const fields = Object.keys(record)
+            .filter((key) => record[key] !== undefined)
+            .sort()
+            .map((key) => `${JSON.stringify(key)}:${canonicalJSON(record[key])}`);
+        return `{${fields.join(',')}}`;
+    }
+    return JSON.stringify(value);
+}
@@ -0,0 +1,31 @@
+/**
+ * Dual-write storage migration exports.
+ *
+ * @module dualwrite
+ */
+
+export {
+    DualWriteStorage,
+    dualWriteOf,
+    dualWriteOptions,
+    DEFAULT_DUAL_WRITE_MAX_QUEUE,
+    MAX_BACKFILL_JOBS,
+    type DualWriteReadSide,
+    type DualWriteFailMode,
+    type DualWriteOptions,
+    type DualWriteStats,
+    type BackfillJob,
+    type BackfillJobStatus,
+} from './storage.js';
+
+export {
+    backfillSecondary,
+    checkConsistency,
+    DEFAULT_BACKFILL_BATCH_SIZE,
+    MAX_BACKFILL_BATCH_SIZE,
+    DEFAULT_CONSISTENCY_SAMPLE,
+    MAX_CONSISTENCY_SAMPLE,
+    type DualWriteBackfillCounts,
+    type ConsistencyMismatch,
+    type ConsistencyReport,
+} from './backfill.js';
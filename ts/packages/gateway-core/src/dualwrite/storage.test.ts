@@ -0,0 +1,57 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { DualWriteStorage } from './index';
+import type { AppConfig, StorageConfig } from '../ports/index';
+import type { StorageProvider } from '../ports/storage';
+import { harness, MemoryStore, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+describe('Dual-write config reloads', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    /** A gateway over a dual-write store; change the returned config, then reload. */
+    async function setup() {
+        const storage: StorageConfig = {
+            type: 'dual',
+            primary: { type: 'memory' },
+            secondary: { type: 'memory' },
+            readFrom: 'primary',
+        };
+        const app: AppConfig = { name: 'chat', frontdoor: 'openai', path: '/v1' };
+        const dw = new DualWriteStorage(
+            new MemoryStore() as unknown as StorageProvider,
+            new MemoryStore() as unknown as StorageProvider,
+        );
+        gateway = await harness()
+            .app(app)
+            .provider(new ScriptedProvider('mock', { text: 'Hi' }))
+            .config({ storage })
+            .options({ storage: dw.storage })
+            .start();
+        await gateway.gateway.reload();
+        return { gateway: gateway.gateway, storage, app };
+    }
+
+    it('should switch the read side when the config reloads', async () => {
+        const { gateway, storage } = await setup();
+        expect(gateway.dualWrite?.stats().readFrom).toBe('primary');
+
+        storage.readFrom = 'secondary';
+        await gateway.reload();
+
+        expect(gateway.dualWrite?.stats().readFrom).toBe('secondary');
+    });
+
+    it('should keep the read side when a reload is rejected', async () => {
+        const { gateway, storage, app } = await setup();
+
+        storage.readFrom = 'secondary';
+        app.middleware = [{ name: 'gzip' }];
+
+        await expect(gateway.reload()).rejects.toThrow("Invalid config for app 'chat'");
+        expect(gateway.dualWrite?.stats().readFrom).toBe('primary');
+    });
+});
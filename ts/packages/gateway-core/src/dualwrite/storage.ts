@@ -0,0 +1,421 @@
+/**
+ * Dual-write storage, for moving between backends without downtime.
+ *
+ * During a migration every write goes to the primary store and is then
+ * mirrored to the secondary. With fail mode primary_only mirroring runs in
+ * the background through a bounded queue, and the secondary's failures
+ * are only counted and logged; with require_both the secondary is written
+ * alongside the primary and its failure fails the write. Reads go to the
+ * side readFrom names; reading the secondary first waits for the writes
+ * queued before the read, so callers still read their own writes.
+ *
+ * A cutover runs: start dual-writing, backfill the rows written before
+ * that, compare a sample of rows on both sides, switch readFrom to the
+ * secondary, then make it the only store.
+ *
+ * @module dualwrite/storage
+ */
+
+import type { StorageConfig } from '../ports/config.js';
+import type { StorageProvider } from '../ports/storage.js';
+import { probeStorage } from '../storagehealth/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { randomUUID } from '../utils/crypto.js';
+import {
+    backfillSecondary,
+    checkConsistency,
+    emptyBackfillCounts,
+    DEFAULT_BACKFILL_BATCH_SIZE,
+    type ConsistencyReport,
+    type DualWriteBackfillCounts,
+} from './backfill.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default number of secondary writes waiting at once. */
+export const DEFAULT_DUAL_WRITE_MAX_QUEUE = 10_000;
+
+/** Finished backfill jobs kept for polling; the oldest are forgotten first. */
+export const MAX_BACKFILL_JOBS = 20;
+
+/** Side of a dual-write store that serves reads. */
+export type DualWriteReadSide = 'primary' | 'secondary';
+
+/**
+ * What a failed secondary write does: nothing beyond being counted
+ * (primary_only), or fail the write (require_both).
+ */
+export type DualWriteFailMode = 'primary_only' | 'require_both';
+
+/**
+ * Dual-write options; each can be changed on reload.
+ */
+export interface DualWriteOptions {
+    /** Side that serves reads (default primary). */
+    readFrom?: DualWriteReadSide | undefined;
+
+    /** What a failed secondary write does (default primary_only). */
+    failMode?: DualWriteFailMode | undefined;
+
+    /** Secondary writes waiting at once; writes beyond it are dropped and counted. */
+    maxQueue?: number | undefined;
+}
+
+/**
+ * Dual-write counters, for /admin/api/stats.
+ */
+export interface DualWriteStats {
+    /** Side serving reads. */
+    readFrom: DualWriteReadSide;
+
+    /** What a failed secondary write does. */
+    failMode: DualWriteFailMode;
+
+    /** Secondary writes waiting in the queue. */
+    queued: number;
+
+    /** Queue capacity. */
+    maxQueue: number;
+
+    /** Writes mirrored to the secondary. */
+    mirrored: number;
+
+    /** Secondary writes that failed. */
+    failed: number;
+
+    /** Failed secondary writes, by store method. */
+    failures: Record<string, number>;
+
+    /** Secondary writes dropped because the queue was full. */
+    dropped: number;
+
+    /** Message of the last secondary failure. */
+    lastError?: string | undefined;
+}
+
+/** Lifecycle state of a backfill job. */
+export type BackfillJobStatus = 'running' | 'completed' | 'failed';
+
+/**
+ * A backfill job, as reported by /admin/api/maintenance/backfill/{id}.
+ */
+export interface BackfillJob {
+    /** Job ID. */
+    id: string;
+
+    /** Job state. */
+    status: BackfillJobStatus;
+
+    /** Interactions read per batch. */
+    batchSize: number;
+
+    /** Interactions in the primary when the job started. */
+    total: number;
+
+    /** Rows copied so far. */
+    counts: DualWriteBackfillCounts;
+
+    /** Failure message (once failed). */
+    error?: string | undefined;
+
+    /** When the job was started. */
+    createdAt: Date;
+
+    /** When the job finished. */
+    completedAt?: Date | undefined;
+}
+
+type StoreMethod = (...args: unknown[]) => Promise<unknown>;
+
+/** Read methods, by name; every other method writes. */
+const READ_PREFIX = /^(get|list|find|sum|aggregate|scan)/;
+
+/** Dual-write stores, by the storage they hand out. */
+const dualWriteStores = new WeakMap<StorageProvider, DualWriteStorage>();
+
+// ============================================================================
+// Dual-Write Storage
+// ============================================================================
+
+/**
+ * Writes to two storage providers and reads from one.
+ */
+export class DualWriteStorage {
+    /** The store to hand the gateway. Methods are those the primary implements. */
+    readonly storage: StorageProvider;
+
+    private readFrom: DualWriteReadSide = 'primary';
+    private failMode: DualWriteFailMode = 'primary_only';
+    private maxQueue = DEFAULT_DUAL_WRITE_MAX_QUEUE;
+    private readonly logger: Logger | undefined;
+
+    /** Settles once every secondary write queued so far has. */
+    private queue: Promise<void> = Promise.resolve();
+    private queued = 0;
+    private mirrored = 0;
+    private dropped = 0;
+    private readonly failures = new Map<string, number>();
+    private lastError: string | undefined;
+    private readonly backfills = new Map<string, BackfillJob>();
+
+    constructor(
+        readonly primary: StorageProvider,
+        readonly secondary: StorageProvider,
+        options: DualWriteOptions = {},
+        logger?: Logger,
+    ) {
+        this.logger = logger;
+        this.configure(options);
+        this.storage = this.wrap();
+        dualWriteStores.set(this.storage, this);
+    }
+
+    /**
+     * Applies new options. Fields left out keep their value.
+     */
+    configure(options: DualWriteOptions): void {
+        const readFrom = options.readFrom ?? this.readFrom;
+        if (readFrom !== this.readFrom) {
+            this.logger?.info('dual_write_read_side_changed', { audit: true, from: this.readFrom, to: readFrom });
+        }
+        this.readFrom = readFrom;
+        this.failMode = options.failMode ?? this.failMode;
+        this.maxQueue = options.maxQueue ?? this.maxQueue;
+    }
+
+    /**
+     * Returns the current counters.
+     */
+    stats(): DualWriteStats {
+        return {
+            readFrom: this.readFrom,
+            failMode: this.failMode,
+            queued: this.queued,
+            maxQueue: this.maxQueue,
+            mirrored: this.mirrored,
+            failed: [...this.failures.values()].reduce((sum, n) => sum + n, 0),
+            failures: Object.fromEntries(this.failures),
+            dropped: this.dropped,
+            lastError: this.lastError,
+        };
+    }
+
+    /**
+     * Resolves once every secondary write queued so far has settled.
+     */
+    async flush(): Promise<void> {
+        while (this.queued > 0) {
+            await this.queue;
+        }
+    }
+
+    /**
+     * Starts copying the primary's rows to the secondary in the
+     * background and returns its job, to poll for progress.
+     */
+    async startBackfill(batchSize = DEFAULT_BACKFILL_BATCH_SIZE): Promise<BackfillJob> {
+        const job: BackfillJob = {
+            id: `backfill_${randomUUID().replace(/-/g, '')}`,
+            status: 'running',
+            batchSize,
+            total: await this.primary.getInteractionCount(),
+            counts: emptyBackfillCounts(),
+            createdAt: new Date(),
+        };
+        this.backfills.set(job.id, job);
+        this.prune();
+
+        this.logger?.info('storage_backfill_started', { audit: true, jobId: job.id, total: job.total });
+        void this.runBackfill(job);
+        return snapshot(job);
+    }
+
+    /**
+     * Gets a backfill job by ID.
+     */
+    getBackfill(id: string): BackfillJob | undefined {
+        const job = this.backfills.get(id);
+        return job && snapshot(job);
+    }
+
+    /**
+     * Compares up to `sample` randomly chosen interactions on both sides.
+     */
+    checkConsistency(sample: number): Promise<ConsistencyReport> {
+        return checkConsistency(this.primary, this.secondary, sample);
+    }
+
+    private async runBackfill(job: BackfillJob): Promise<void> {
+        try {
+            await backfillSecondary(this.primary, this.secondary, {
+                batchSize: job.batchSize,
+                counts: job.counts,
+                logger: this.logger,
+            });
+            job.status = 'completed';
+            this.logger?.info('storage_backfill_completed', { audit: true, jobId: job.id, counts: job.counts });
+        } catch (error) {
+            job.status = 'failed';
+            job.error = error instanceof Error ? error.message : String(error);
+            this.logger?.error('storage_backfill_failed', { audit: true, jobId: job.id, error: job.error });
+        }
+        job.completedAt = new Date();
+    }
+
+    private prune(): void {
+        for (const [id, job] of this.backfills) {
+            if (this.backfills.size <= MAX_BACKFILL_JOBS) {
+                return;
+            }
+            if (job.status !== 'running') {
+                this.backfills.delete(id);
+            }
+        }
+    }
+
+    // ---- Store Methods ----
+
+    private wrap(): StorageProvider {
+        const methods = new Map<string, StoreMethod>();
+        const method = (name: string): StoreMethod => {
+            switch (name) {
+                case 'ping':
+                    return () => this.ping();
+                case 'close':
+                    return () => this.close();
+                case 'migrate':
+                    return () => this.migrate();
+                case 'migrationStatus':
+                    return (...args) => invoke(this.primary, name, args);
+                default:
+                    return READ_PREFIX.test(name)
+                        ? (...args) => this.read(name, args)
+                        : (...args) => this.write(name, args);
+            }
+        };
+
+        return new Proxy(this.primary, {
+            get(target, prop) {
+                const value: unknown = Reflect.get(target, prop, target);
+                if (typeof value !== 'function' || typeof prop !== 'string') {
+                    return value;
+                }
+                let wrapped = methods.get(prop);
+                if (!wrapped) {
+                    wrapped = method(prop);
+                    methods.set(prop, wrapped);
+                }
+                return wrapped;
+            },
+        });
+    }
+
+    private async read(name: string, args: unknown[]): Promise<unknown> {
+        if (this.readFrom === 'secondary' && has(this.secondary, name)) {
+            await this.queue;
+            return invoke(this.secondary, name, args);
+        }
+        return invoke(this.primary, name, args);
+    }
+
+    private async write(name: string, args: unknown[]): Promise<unknown> {
+        // Callers may change what they wrote before a queued mirror runs
+        const copy = structuredClone(args);
+        const result = await invoke(this.primary, name, args);
+        if (!has(this.secondary, name)) {
+            return result;
+        }
+
+        if (this.failMode === 'require_both') {
+            // Behind writes queued before the fail mode changed
+            await this.queue;
+            try {
+                await invoke(this.secondary, name, copy);
+                this.mirrored++;
+            } catch (error) {
+                this.failed(name, error);
+                throw error;
+            }
+            return result;
+        }
+
+        if (this.queued >= this.maxQueue) {
+            this.dropped++;
+            this.logger?.warn('storage_secondary_write_dropped', { method: name, queued: this.queued });
+            return result;
+        }
+        this.queued++;
+        this.queue = this.queue.then(async () => {
+            try {
+                await invoke(this.secondary, name, copy);
+                this.mirrored++;
+            } catch (error) {
+                this.failed(name, error);
+            } finally {
+                this.queued--;
+            }
+        });
+        return result;
+    }
+
+    private failed(name: string, error: unknown): void {
+        this.failures.set(name, (this.failures.get(name) ?? 0) + 1);
+        this.lastError = error instanceof Error ? error.message : String(error);
+        this.logger?.warn('storage_secondary_write_failed', { method: name, error: this.lastError });
+    }
+
+    /** Probes the primary, and the secondary when it serves reads. */
+    private async ping(): Promise<void> {
+        await probeStorage(this.primary);
+        if (this.readFrom === 'secondary') {
+            await probeStorage(this.secondary);
+        }
+    }
+
+    /** Migrates both schemas; returns the primary's result. */
+    private async migrate(): Promise<unknown> {
+        const result = await invoke(this.primary, 'migrate', []);
+        if (has(this.secondary, 'migrate')) {
+            await invoke(this.secondary, 'migrate', []);
+        }
+        return result;
+    }
+
+    private async close(): Promise<void> {
+        await this.flush();
+        await this.primary.close?.();
+        await this.secondary.close?.();
+    }
+}
+
+/**
+ * The dual-write store behind a storage provider, if it is one.
+ */
+export function dualWriteOf(storage: StorageProvider | undefined): DualWriteStorage | undefined {
+    return storage && dualWriteStores.get(storage);
+}
+
+/**
+ * Dual-write options from a storage config of type "dual".
+ */
+export function dualWriteOptions(config: StorageConfig): DualWriteOptions {
+    return { readFrom: config.readFrom, failMode: config.failMode, maxQueue: config.maxQueue };
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+function has(store: StorageProvider, name: string): boolean {
+    return typeof Reflect.get(store, name, store) === 'function';
+}
+
+function invoke(store: StorageProvider, name: string, args: unknown[]): Promise<unknown> {
+    return (Reflect.get(store, name, store) as StoreMethod).apply(store, args);
+}
+
+function snapshot(job: BackfillJob): BackfillJob {
+    return { ...job, counts: { ...job.counts } };
+}
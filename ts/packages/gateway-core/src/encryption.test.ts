@@ -0,0 +1,362 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { Gateway } from './gateway';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+import { AdminHandler } from './admin/index';
+import { StorageKeyring, withEncryption, rewrapSensitiveValues } from './encryption/index';
+import { ERASED } from './ports/index';
+import type {
+    GatewayConfig,
+    IdempotencyRecord,
+    InteractionAttemptRecord,
+    ResponseRecord,
+    SensitiveField,
+    SensitiveValue,
+    StorageKeyConfig,
+    StoredMessage,
+} from './ports/index';
+import type { InteractionEvent } from './domain/events';
+import type { ShadowResult } from './domain/shadow';
+import { bytesToBase64 } from './utils/crypto';
+import { messageContentHash } from './messagededup/index';
+
+const key = (fill: number) => bytesToBase64(new Uint8Array(32).fill(fill));
+const K1: StorageKeyConfig = { id: 'k1', key: key(1) };
+const K2: StorageKeyConfig = { id: 'k2', key: key(2) };
+
+async function keyring(...keys: StorageKeyConfig[]): Promise<StorageKeyring> {
+    const ring = new StorageKeyring();
+    await ring.load({ keys });
+    return ring;
+}
+
+/** Stores values exactly as given, like a database would. */
+function rawStore() {
+    const responses = new Map<string, ResponseRecord>();
+    const events: InteractionEvent[] = [];
+    const shadows: ShadowResult[] = [];
+    const idempotency = new Map<string, IdempotencyRecord>();
+    const attempts: InteractionAttemptRecord[] = [];
+    const column = (field: SensitiveField) => field.slice('responses.'.length) as 'request' | 'response' | 'error';
+    return {
+        responses,
+        events,
+        shadows,
+        idempotency,
+        attempts,
+        saveShadowResult: async (r: ShadowResult) => void shadows.push(structuredClone(r)),
+        getShadowResults: async (id: string) => shadows.filter((r) => r.interactionId === id).map((r) => structuredClone(r)),
+        claimIdempotencyKey: async (r: IdempotencyRecord) => {
+            const existing = idempotency.get(r.key);
+            if (existing) return structuredClone(existing);
+            idempotency.set(r.key, structuredClone(r));
+            return null;
+        },
+        getIdempotencyRecord: async (_tenantId: string, key: string) => structuredClone(idempotency.get(key) ?? null),
+        completeIdempotencyKey: async (_tenantId: string, key: string, response: IdempotencyRecord['response']) =>
+            void Object.assign(idempotency.get(key)!, { status: 'completed', response: structuredClone(response) }),
+        releaseIdempotencyKey: async (_tenantId: string, key: string) => void idempotency.delete(key),
+        saveAttempts: async (records: InteractionAttemptRecord[]) => void attempts.push(...structuredClone(records)),
+        listAttempts: async (id: string) => attempts.filter((a) => a.interactionId === id).map((a) => structuredClone(a)),
+        saveResponse: async (r: ResponseRecord) => void responses.set(r.id, structuredClone(r)),
+        getResponse: async (id: string) => structuredClone(responses.get(id) ?? null),
+        listResponses: async () => [...responses.values()].map((r) => structuredClone(r)),
+        saveEvent: async (e: InteractionEvent) => void events.push(structuredClone(e)),
+        getEvents: async (id: string) => events.filter((e) => e.interactionId === id).map((e) => structuredClone(e)),
+        async scanSensitiveValues(field: SensitiveField, after: string | undefined, limit: number): Promise<SensitiveValue[]> {
+            const rows: { id: string; value: unknown }[] = field === 'interaction_events.payload'
+                ? events.map((e) => ({ id: e.id, value: e.payload }))
+                : field.startsWith('responses.')
+                    ? [...responses.values()].map((r) => ({ id: r.id, value: r[column(field)] }))
+                    : [];
+            return rows
+                .filter((r) => r.value != null && (after === undefined || r.id > after))
+                .sort((a, b) => (a.id < b.id ? -1 : 1))
+                .slice(0, limit)
+                .map((r) => ({ field, ...r }));
+        },
+        async updateSensitiveValue(v: SensitiveValue): Promise<void> {
+            if (v.field === 'interaction_events.payload') {
+                events.find((e) => e.id === v.id)!.payload = v.value;
+            } else {
+                responses.get(v.id)![column(v.field)] = v.value;
+            }
+        },
+    };
+}
+
+function record(id: string, overrides: Partial<ResponseRecord> = {}): ResponseRecord {
+    return {
+        id,
+        tenantId: 'tenant-a',
+        model: 'gpt-4o',
+        status: 'completed',
+        request: { input: 'my secret prompt' },
+        response: { output_text: 'the answer' },
+        createdAt: new Date(0),
+        updatedAt: new Date(0),
+        ...overrides,
+    };
+}
+
+describe('StorageKeyring', () => {
+    it('should seal under the newest key and open with the key a value names', async () => {
+        const old = await keyring(K1);
+        const sealed = await old.seal('hello');
+
+        const rotated = await keyring(K2, K1);
+        const resealed = await rotated.seal('hello');
+
+        expect(sealed).toMatch(/^gwenc:k1:/);
+        expect(resealed).toMatch(/^gwenc:k2:/);
+        expect(await rotated.open(sealed)).toBe('hello');
+        expect(await rotated.open(resealed)).toBe('hello');
+        await expect((await keyring(K2)).open(sealed)).rejects.toThrow("Storage encryption key 'k1' is not loaded");
+        await expect(rotated.open(sealed.replace('gwenc:k1:', 'gwenc:k2:'))).rejects.toThrow();
+    });
+
+    it('should reject malformed keys and keep the keys it had', async () => {
+        const ring = await keyring(K1);
+
+        await expect(ring.load({ keys: [{ id: 'short', key: key(1).slice(0, 20) }] })).rejects.toThrow('must be 32 bytes');
+        await expect(ring.load({ keys: [{ id: 'bad:id', key: key(1) }] })).rejects.toThrow('Invalid storage encryption key ID');
+        await expect(ring.load({ keys: [K1, K1] })).rejects.toThrow('Duplicate');
+        await expect(ring.load({ keys: [{ id: 'env', key: '${env:MISSING}' }] })).rejects.toThrow('is not set');
+
+        expect(ring.currentKeyId).toBe('k1');
+        await ring.load({ keys: [{ id: 'env', key: '${env:STORAGE_KEY}' }] }, { STORAGE_KEY: key(3) });
+        expect(await ring.seal('x')).toMatch(/^gwenc:env:/);
+    });
+});
+
+describe('Encrypted storage', () => {
+    it('should store sensitive fields sealed and read them back in plaintext', async () => {
+        const raw = rawStore();
+        const storage = withEncryption(raw as any, await keyring(K1));
+
+        await storage.saveResponse(record('r1'));
+        await storage.saveEvent({ id: 'e1', interactionId: 'r1', type: 'response', timestamp: new Date(0), payload: { text: 'my secret prompt' } });
+
+        const stored = raw.responses.get('r1')!;
+        expect(stored.request).toMatch(/^gwenc:k1:/);
+        expect(stored.response).toMatch(/^gwenc:k1:/);
+        expect(JSON.stringify(stored)).not.toContain('secret');
+        expect(JSON.stringify(raw.events)).not.toContain('secret');
+        expect(stored.model).toBe('gpt-4o');
+
+        expect(await storage.getResponse('r1', 'tenant-a')).toEqual(record('r1'));
+        expect(await storage.listResponses('tenant-a')).toEqual([record('r1')]);
+        expect((await storage.getEvents('r1', 'tenant-a'))[0]!.payload).toEqual({ text: 'my secret prompt' });
+    });
+
+    it('should read legacy plaintext rows and pass other capabilities through', async () => {
+        const raw = rawStore();
+        raw.responses.set('legacy', record('legacy'));
+        const storage = withEncryption(raw as any, await keyring(K1));
+
+        expect(await storage.getResponse('legacy', 'tenant-a')).toEqual(record('legacy'));
+        expect(typeof storage.scanSensitiveValues).toBe('function');
+        expect(storage.saveProbeResult).toBeUndefined();
+    });
+
+    it('should seal shadow output, captured replays, and upstream errors', async () => {
+        const raw = rawStore();
+        const storage = withEncryption(raw as any, await keyring(K1));
+        const shadow: ShadowResult = {
+            id: 's1',
+            interactionId: 'r1',
+            providerName: 'shadow',
+            response: {
+                id: 'resp',
+                model: 'gpt-4o',
+                content: 'secret answer',
+                usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+                toolCalls: [{ id: 'c1', name: 'lookup', arguments: '{"q":"secret"}' }],
+            },
+            error: { type: 'api_error', message: 'secret error' },
+            durationMs: 5,
+            divergences: [
+                { type: 'tool_call_arguments', description: 'differs', severity: 'warning', primaryValue: '{"q":"secret"}', shadowValue: '{}' },
+                { type: 'error_presence', description: 'secret error', severity: 'critical' },
+            ],
+            hasStructuralDivergence: true,
+            createdAt: new Date(0),
+        };
+        const claim: IdempotencyRecord = {
+            tenantId: 'tenant-a',
+            key: 'k',
+            interactionId: 'r1',
+            status: 'in_progress',
+            createdAt: new Date(0),
+            expiresAt: new Date(8.64e15),
+        };
+        const attempt: InteractionAttemptRecord = {
+            interactionId: 'r1',
+            attempt: 1,
+            tenantId: 'tenant-a',
+            provider: 'openai',
+            model: 'gpt-4o',
+            reason: 'primary',
+            durationMs: 5,
+            outcome: 'error',
+            statusCode: 400,
+            upstreamError: '{"error":"secret prompt rejected"}',
+            served: false,
+            createdAt: new Date(0),
+        };
+
+        await storage.saveShadowResult(shadow);
+        await storage.claimIdempotencyKey!(claim);
+        await storage.completeIdempotencyKey!('tenant-a', 'k', { status: 200, headers: {}, body: '{"text":"secret"}' }, claim.expiresAt);
+        await storage.saveAttempts!([attempt]);
+
+        expect(raw.shadows[0]!.response!.content).toMatch(/^gwenc:k1:/);
+        expect(raw.idempotency.get('k')!.response!.body).toMatch(/^gwenc:k1:/);
+        expect(raw.attempts[0]!.upstreamError).toMatch(/^gwenc:k1:/);
+        expect(JSON.stringify([raw.shadows, [...raw.idempotency.values()], raw.attempts])).not.toContain('secret');
+
+        expect(await storage.getShadowResults('r1', 'tenant-a')).toEqual([shadow]);
+        expect((await storage.getIdempotencyRecord!('tenant-a', 'k'))!.response!.body).toBe('{"text":"secret"}');
+        expect((await storage.claimIdempotencyKey!(claim))!.response!.body).toBe('{"text":"secret"}');
+        expect(await storage.listAttempts!('r1', 'tenant-a')).toEqual([attempt]);
+    });
+
+    it('should store plaintext while no key is loaded', async () => {
+        const raw = rawStore();
+        const storage = withEncryption(raw as any, new StorageKeyring());
+
+        await storage.saveResponse(record('r1'));
+
+        expect(raw.responses.get('r1')).toEqual(record('r1'));
+    });
+
+    it('should key message content hashes so they can\'t be matched against guessed text', async () => {
+        const added: StoredMessage[] = [];
+        const raw = {
+            async addMessage(_threadId: string, m: StoredMessage) {
+                added.push(m);
+                return { id: m.id };
+            },
+        };
+        const plain = await messageContentHash('user', 'my secret prompt');
+        const message = (id: string, contentHash = plain): StoredMessage =>
+            ({ id, role: 'user', content: 'my secret prompt', timestamp: new Date(0), contentHash });
+
+        const storage = withEncryption(raw as any, await keyring(K1));
+        await storage.addMessage!('t1', message('m1'));
+        await storage.addMessage!('t1', message('m2'));
+        await storage.addMessage!('t1', message('m3', added[0]!.contentHash));
+
+        expect(added[0]!.contentHash).toMatch(/^gwmac:k1:[0-9a-f]{64}$/);
+        expect(added[0]!.contentHash).not.toContain(plain);
+        // Equal content still compares equal, and a keyed hash isn't keyed twice
+        expect(added[1]!.contentHash).toBe(added[0]!.contentHash);
+        expect(added[2]!.contentHash).toBe(added[0]!.contentHash);
+
+        await withEncryption(raw as any, new StorageKeyring()).addMessage!('t1', message('m4'));
+        expect(added[3]!.contentHash).toBe(plain);
+    });
+});
+
+describe('Gateway stores', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    it('should seal idempotent responses before they reach storage', async () => {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('mock', { text: 'a secret answer' }))
+            .config({ storage: { type: 'memory', encryption: { keys: [K1] } } })
+            .start();
+        const body = { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] };
+
+        const first = await gateway.post('/v1/chat/completions', body, { 'Idempotency-Key': 'once' });
+        const replay = await gateway.post('/v1/chat/completions', body, { 'Idempotency-Key': 'once' });
+
+        const [stored] = [...gateway.store.idempotencyKeys.values()];
+        expect(stored!.response!.body).toMatch(/^gwenc:k1:/);
+        expect(JSON.stringify(stored)).not.toContain('secret');
+        expect((await first.json()).choices[0].message.content).toBe('a secret answer');
+        expect(replay.headers.get('x-gateway-idempotent-replay')).toBe('true');
+        expect((await replay.json()).choices[0].message.content).toBe('a secret answer');
+        expect(gateway.provider('mock').requests).toHaveLength(1);
+    });
+});
+
+describe('rewrapSensitiveValues', () => {
+    it('should reseal old-key and plaintext values under the newest key in batches', async () => {
+        const raw = rawStore();
+        const storage = withEncryption(raw as any, await keyring(K1));
+        for (let i = 0; i < 5; i++) {
+            await storage.saveResponse(record(`r${i}`));
+        }
+        raw.responses.set('legacy', record('legacy'));
+        raw.responses.set('erased', record('erased', { request: ERASED, response: ERASED }));
+
+        const rotated = await keyring(K2, K1);
+        const result = await rewrapSensitiveValues(raw, rotated, { batchSize: 2 });
+
+        expect(result).toMatchObject({ keyId: 'k2', scanned: 14, rewrapped: 12, failed: 0 });
+        expect(result.fields['responses.request']).toBe(6);
+        for (const stored of raw.responses.values()) {
+            if (stored.id === 'erased') {
+                expect(stored.request).toBe(ERASED);
+                continue;
+            }
+            expect(stored.request).toMatch(/^gwenc:k2:/);
+        }
+
+        // With k1 retired, everything still reads
+        const retired = withEncryption(raw as any, await keyring(K2));
+        expect(await retired.getResponse('r3', 'tenant-a')).toEqual(record('r3'));
+        expect(await retired.getResponse('legacy', 'tenant-a')).toEqual(record('legacy'));
+        expect((await rewrapSensitiveValues(raw, rotated)).rewrapped).toBe(0);
+    });
+});
+
+describe('POST /api/maintenance/rewrap', () => {
+    it('should rewrap through the gateway after a key rotation', async () => {
+        const raw = rawStore();
+        let keys = [K1];
+        const gateway = new Gateway({
+            config: {
+                load: async (): Promise<GatewayConfig> => ({
+                    apps: [],
+                    providers: [],
+                    storage: { type: 'memory', encryption: { keys } },
+                }),
+            },
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            storage: raw as any,
+        });
+        const admin = new AdminHandler({ storage: gateway.storage, rewrap: (batchSize) => gateway.rewrapStorage(batchSize) });
+        const rewrap = (query = '') => admin.handle(new Request(`http://localhost/api/maintenance/rewrap${query}`, { method: 'POST' }));
+        await gateway.reload();
+        await gateway.storage!.saveResponse(record('r1'));
+
+        keys = [K2, K1];
+        await gateway.reload();
+        expect((await rewrap('?batch_size=0')).status).toBe(400);
+        const response = await rewrap('?batch_size=10');
+
+        expect(response.status).toBe(200);
+        expect(await response.json()).toMatchObject({ keyId: 'k2', rewrapped: 2 });
+        expect(raw.responses.get('r1')!.request).toMatch(/^gwenc:k2:/);
+
+        keys = [K2];
+        await gateway.reload();
+        expect(await gateway.storage!.getResponse('r1', 'tenant-a')).toEqual(record('r1'));
+        await gateway.close();
+    });
+
+    it('should report 503 without storage encryption', async () => {
+        const admin = new AdminHandler({ rewrap: async () => undefined });
+
+        const response = await admin.handle(new Request('http://localhost/api/maintenance/rewrap', { method: 'POST' }));
+
+        expect(response.status).toBe(503);
+    });
+});
@@ -0,0 +1,18 @@
+/**
+ * Storage encryption at rest exports.
+ *
+ * @module encryption
+ */
+
+export { StorageKeyring, SEALED_PREFIX, HASHED_PREFIX } from './keyring.js';
+
+export { withEncryption, FieldSealer } from './storage.js';
+
+export {
+    rewrapSensitiveValues,
+    isSensitiveValueStore,
+    DEFAULT_REWRAP_BATCH_SIZE,
+    MAX_REWRAP_BATCH_SIZE,
+    type RewrapResult,
+    type RewrapOptions,
+} from './rewrap.js';
@@ -0,0 +1,218 @@
+/**
+ * Storage encryption keys.
+ *
+ * Values are sealed with AES-256-GCM under the newest key and stored as
+ *
+ *     gwenc:<key id>:<base64(iv || ciphertext || tag)>
+ *
+ * so a value names the key that opens it. Older keys stay loaded for
+ * reads until every row has been rewrapped to the newest one.
+ *
+ * Values stored only to be compared are hashed instead, with HMAC-SHA-256
+ * under a key derived from the newest one, as
+ *
+ *     gwmac:<key id>:<hex>
+ *
+ * @module encryption/keyring
+ */
+
+import type { StorageEncryptionConfig } from '../ports/config.js';
+import { arrayToHex, bytesToBase64, base64ToBytes, randomBytes } from '../utils/crypto.js';
+import { expandEnvRefs } from '../utils/headers.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Prefix of every sealed value. */
+export const SEALED_PREFIX = 'gwenc:';
+
+/** Prefix of every keyed hash. */
+export const HASHED_PREFIX = 'gwmac:';
+
+/** HKDF info separating hash keys from encryption keys. */
+const HASH_KEY_INFO = 'polyglot-llm-gateway keyed hash';
+
+/** AES-GCM nonce length in bytes. */
+const IV_BYTES = 12;
+
+/** Required key length in bytes (AES-256). */
+const KEY_BYTES = 32;
+
+const KEY_ID_PATTERN = /^[A-Za-z0-9._-]+$/;
+
+// ============================================================================
+// Keyring
+// ============================================================================
+
+/**
+ * The loaded storage keys. Empty until a config with encryption keys is
+ * loaded; while empty, nothing is sealed.
+ */
+export class StorageKeyring {
+    private keys = new Map<string, CryptoKey>();
+    private hashKeys = new Map<string, CryptoKey>();
+    private current: string | undefined;
+
+    /**
+     * Replaces the loaded keys with the configured ones. Throws, leaving
+     * the current keys in place, if any key is invalid.
+     */
+    async load(
+        config: StorageEncryptionConfig | undefined,
+        env: Record<string, string | undefined> = {},
+    ): Promise<void> {
+        this.adopt(await StorageKeyring.fromConfig(config, env));
+    }
+
+    /**
+     * Builds a separate keyring holding the configured keys, so a config
+     * can be checked before the shared keyring changes. Throws if any key
+     * is invalid.
+     */
+    static async fromConfig(
+        config: StorageEncryptionConfig | undefined,
+        env: Record<string, string | undefined> = {},
+    ): Promise<StorageKeyring> {
+        const keys = new Map<string, CryptoKey>();
+        const hashKeys = new Map<string, CryptoKey>();
+        for (const entry of config?.keys ?? []) {
+            if (!KEY_ID_PATTERN.test(entry.id ?? '')) {
+                throw new Error(`Invalid storage encryption key ID '${entry.id}'`);
+            }
+            if (keys.has(entry.id)) {
+                throw new Error(`Duplicate storage encryption key ID '${entry.id}'`);
+            }
+            const encoded = expandEnvRefs(entry.key ?? '', env);
+            if (!encoded) {
+                throw new Error(`Storage encryption key '${entry.id}' is not set`);
+            }
+            let raw: Uint8Array;
+            try {
+                raw = base64ToBytes(encoded.trim());
+            } catch {
+                throw new Error(`Storage encryption key '${entry.id}' is not valid base64`);
+            }
+            if (raw.length !== KEY_BYTES) {
+                throw new Error(`Storage encryption key '${entry.id}' must be ${KEY_BYTES} bytes, got ${raw.length}`);
+            }
+            keys.set(entry.id, await crypto.subtle.importKey('raw', raw, 'AES-GCM', false, ['encrypt', 'decrypt']));
+            hashKeys.set(entry.id, await deriveHashKey(raw));
+        }
+        const ring = new StorageKeyring();
+        ring.keys = keys;
+        ring.hashKeys = hashKeys;
+        ring.current = config?.keys[0]?.id;
+        return ring;
+    }
+
+    /**
+     * Takes over another keyring's keys.
+     */
+    adopt(other: StorageKeyring): void {
+        this.keys = other.keys;
+        this.hashKeys = other.hashKeys;
+        this.current = other.current;
+    }
+
+    /**
+     * Whether new writes are sealed.
+     */
+    get enabled(): boolean {
+        return this.current !== undefined;
+    }
+
+    /**
+     * ID of the key new writes are sealed with.
+     */
+    get currentKeyId(): string | undefined {
+        return this.current;
+    }
+
+    /**
+     * Returns the ID of the key that sealed a value, or undefined for
+     * plaintext.
+     */
+    keyIdOf(value: unknown): string | undefined {
+        if (typeof value !== 'string' || !value.startsWith(SEALED_PREFIX)) {
+            return undefined;
+        }
+        const end = value.indexOf(':', SEALED_PREFIX.length);
+        return end > SEALED_PREFIX.length ? value.slice(SEALED_PREFIX.length, end) : undefined;
+    }
+
+    /**
+     * Seals text under the newest key.
+     */
+    async seal(plaintext: string): Promise<string> {
+        const keyId = this.current;
+        const key = keyId !== undefined ? this.keys.get(keyId) : undefined;
+        if (keyId === undefined || !key) {
+            throw new Error('No storage encryption key loaded');
+        }
+        const iv = randomBytes(IV_BYTES);
+        const sealed = new Uint8Array(await crypto.subtle.encrypt(
+            { name: 'AES-GCM', iv },
+            key,
+            new TextEncoder().encode(plaintext),
+        ));
+        const out = new Uint8Array(IV_BYTES + sealed.length);
+        out.set(iv);
+        out.set(sealed, IV_BYTES);
+        return `${SEALED_PREFIX}${keyId}:${bytesToBase64(out)}`;
+    }
+
+    /**
+     * Hashes text under the newest key. The same text hashes alike until
+     * the key rotates.
+     */
+    async hash(text: string): Promise<string> {
+        const keyId = this.current;
+        const key = keyId !== undefined ? this.hashKeys.get(keyId) : undefined;
+        if (keyId === undefined || !key) {
+            throw new Error('No storage encryption key loaded');
+        }
+        const mac = await crypto.subtle.sign('HMAC', key, new TextEncoder().encode(text));
+        return `${HASHED_PREFIX}${keyId}:${arrayToHex(new Uint8Array(mac))}`;
+    }
+
+    /**
+     * Opens a sealed value with the key it names.
+     */
+    async open(value: string): Promise<string> {
+        const keyId = this.keyIdOf(value);
+        if (keyId === undefined) {
+            throw new Error('Value is not sealed');
+        }
+        const key = this.keys.get(keyId);
+        if (!key) {
+            throw new Error(`Storage encryption key '${keyId}' is not loaded`);
+        }
+        const bytes = base64ToBytes(value.slice(SEALED_PREFIX.length + keyId.length + 1));
+        const plaintext = await crypto.subtle.decrypt(
+            { name: 'AES-GCM', iv: bytes.subarray(0, IV_BYTES) },
+            key,
+            bytes.subarray(IV_BYTES),
+        );
+        return new TextDecoder().decode(plaintext);
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Derives the HMAC key for keyed hashes, so hashing and encryption never
+ * use the same key.
+ */
+async function deriveHashKey(raw: Uint8Array): Promise<CryptoKey> {
+    const base = await crypto.subtle.importKey('raw', raw, 'HKDF', false, ['deriveKey']);
+    return crypto.subtle.deriveKey(
+        { name: 'HKDF', hash: 'SHA-256', salt: new Uint8Array(0), info: new TextEncoder().encode(HASH_KEY_INFO) },
+        base,
+        { name: 'HMAC', hash: 'SHA-256' },
+        false,
+        ['sign'],
+    );
+}
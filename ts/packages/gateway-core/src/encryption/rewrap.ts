@@ -0,0 +1,159 @@
+/**
+ * Re-encryption of stored sensitive values.
+ *
+ * After a new key is added at the head of the keyring, new writes use it
+ * but existing rows stay sealed under older keys (or in plaintext, if they
+ * predate encryption). Rewrapping walks every sensitive field in ID-ordered
+ * batches and reseals each value under the newest key, after which older
+ * keys can be removed from config.
+ *
+ * @module encryption/rewrap
+ */
+
+import type { SensitiveField, SensitiveValueStore, StorageProvider } from '../ports/storage.js';
+import { ERASED, SENSITIVE_FIELDS } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import type { StorageKeyring } from './keyring.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default number of rows read per batch. */
+export const DEFAULT_REWRAP_BATCH_SIZE = 100;
+
+/** Largest batch size accepted. */
+export const MAX_REWRAP_BATCH_SIZE = 1000;
+
+/** Fields that hold raw text rather than JSON. */
+const TEXT_FIELDS: ReadonlySet<SensitiveField> = new Set(['messages.content']);
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Outcome of one rewrap run.
+ */
+export interface RewrapResult {
+    /** Key every rewrapped value is now sealed with. */
+    keyId: string;
+
+    /** Values read. */
+    scanned: number;
+
+    /** Values resealed under the newest key (older keys and plaintext). */
+    rewrapped: number;
+
+    /** Values that could not be resealed (e.g. sealed with a key no longer loaded). */
+    failed: number;
+
+    /** Values resealed, by field. */
+    fields: Record<SensitiveField, number>;
+}
+
+/**
+ * Rewrap options.
+ */
+export interface RewrapOptions {
+    /** Rows read per batch (default 100). */
+    batchSize?: number | undefined;
+
+    /** Logger for values that fail to reseal. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Rewrap
+// ============================================================================
+
+/**
+ * Reseals every stored sensitive value not already under the keyring's
+ * newest key. Safe to run repeatedly and alongside live traffic.
+ */
+export async function rewrapSensitiveValues(
+    store: SensitiveValueStore,
+    keyring: StorageKeyring,
+    options: RewrapOptions = {},
+): Promise<RewrapResult> {
+    const keyId = keyring.currentKeyId;
+    if (keyId === undefined) {
+        throw new Error('No storage encryption key loaded');
+    }
+    const batchSize = Math.min(options.batchSize ?? DEFAULT_REWRAP_BATCH_SIZE, MAX_REWRAP_BATCH_SIZE);
+    const result: RewrapResult = {
+        keyId,
+        scanned: 0,
+        rewrapped: 0,
+        failed: 0,
+        fields: Object.fromEntries(SENSITIVE_FIELDS.map((f) => [f, 0])) as Record<SensitiveField, number>,
+    };
+
+    for (const field of SENSITIVE_FIELDS) {
+        let after: string | undefined;
+        for (;;) {
+            const batch = await store.scanSensitiveValues(field, after, batchSize);
+            for (const stored of batch) {
+                result.scanned++;
+                try {
+                    const value = await reseal(keyring, field, stored.value);
+                    if (value === undefined) continue;
+                    await store.updateSensitiveValue({ ...stored, value });
+                    result.rewrapped++;
+                    result.fields[field]++;
+                } catch (error) {
+                    result.failed++;
+                    options.logger?.warn('storage_rewrap_failed', {
+                        field,
+                        id: stored.id,
+                        error: error instanceof Error ? error.message : String(error),
+                    });
+                }
+            }
+            if (batch.length < batchSize) break;
+            after = batch[batch.length - 1]!.id;
+        }
+    }
+
+    return result;
+}
+
+/**
+ * Returns a value resealed under the newest key, or undefined when it
+ * needs no change.
+ */
+async function reseal(keyring: StorageKeyring, field: SensitiveField, value: unknown): Promise<string | undefined> {
+    const sealedWith = keyring.keyIdOf(value);
+    if (sealedWith === keyring.currentKeyId) {
+        return undefined;
+    }
+    if (sealedWith !== undefined) {
+        return keyring.seal(await keyring.open(value as string));
+    }
+
+    // Legacy plaintext; erasure tombstones stay readable as such
+    if (value === undefined || value === null || value === ERASED) {
+        return undefined;
+    }
+    if (TEXT_FIELDS.has(field)) {
+        return typeof value === 'string' ? keyring.seal(value) : undefined;
+    }
+    return keyring.seal(JSON.stringify(value));
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements SensitiveValueStore.
+ */
+export function isSensitiveValueStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & SensitiveValueStore {
+    return (
+        storage !== undefined &&
+        typeof storage.scanSensitiveValues === 'function' &&
+        typeof storage.updateSensitiveValue === 'function'
+    );
+}
@@ -0,0 +1,263 @@
+/**
+ * Encrypting storage wrapper.
+ *
+ * Wraps any StorageProvider so sensitive fields are sealed on the way in
+ * and opened on the way out. Stores never see plaintext for those fields,
+ * so no dialect needs its own encryption. Values written before a key was
+ * configured are plaintext and read back unchanged.
+ *
+ * @module encryption/storage
+ */
+
+import type {
+    Conversation,
+    IdempotencyRecord,
+    InteractionAttemptRecord,
+    InteractionRecord,
+    ResponseRecord,
+    StorageProvider,
+    StoredHTTPResponse,
+    StoredMessage,
+    StoredThread,
+} from '../ports/storage.js';
+import type { InteractionEvent } from '../domain/events.js';
+import type { Divergence, ShadowResult } from '../domain/shadow.js';
+import { HASHED_PREFIX, type StorageKeyring } from './keyring.js';
+
+// ============================================================================
+// Field Sealing
+// ============================================================================
+
+/**
+ * Seals and opens individual fields with a keyring.
+ */
+export class FieldSealer {
+    constructor(private readonly keyring: StorageKeyring) { }
+
+    /**
+     * Seals a structured value as JSON. Nothing is sealed while the
+     * keyring is empty.
+     */
+    async sealJSON<T>(value: T): Promise<T> {
+        if (!this.keyring.enabled || value === undefined || value === null || this.keyring.keyIdOf(value)) {
+            return value;
+        }
+        return await this.keyring.seal(JSON.stringify(value)) as unknown as T;
+    }
+
+    /**
+     * Opens a value sealed by sealJSON; plaintext passes through.
+     */
+    async openJSON<T>(value: T): Promise<T> {
+        if (this.keyring.keyIdOf(value) === undefined) {
+            return value;
+        }
+        return JSON.parse(await this.keyring.open(value as string)) as T;
+    }
+
+    /**
+     * Seals text as-is.
+     */
+    async sealText(value: string): Promise<string> {
+        if (!this.keyring.enabled || this.keyring.keyIdOf(value)) {
+            return value;
+        }
+        return this.keyring.seal(value);
+    }
+
+    /**
+     * Replaces a hash with one keyed by the keyring, so it can't be matched
+     * against hashes of guessed text. Nothing is rehashed while the keyring
+     * is empty, or once keyed.
+     */
+    async hashText(value: string): Promise<string> {
+        if (!this.keyring.enabled || value.startsWith(HASHED_PREFIX)) {
+            return value;
+        }
+        return this.keyring.hash(value);
+    }
+
+    /**
+     * Opens text sealed by sealText; plaintext passes through.
+     */
+    async openText(value: string): Promise<string> {
+        return this.keyring.keyIdOf(value) === undefined ? value : this.keyring.open(value);
+    }
+}
+
+// ============================================================================
+// Encrypting Storage
+// ============================================================================
+
+/**
+ * Wraps a storage provider so response bodies, message content, event
+ * payloads, requests kept in interaction records, shadow output, captured
+ * idempotent responses, and upstream error bodies kept with attempts are
+ * encrypted at rest with the keyring's newest key. Message content hashes
+ * are keyed with it too.
+ * Every other method, including optional ones, passes straight through,
+ * so capability checks (isProbeStore and friends) see the inner store.
+ */
+export function withEncryption(storage: StorageProvider, keyring: StorageKeyring): StorageProvider {
+    const fields = new FieldSealer(keyring);
+
+    const sealMessage = async (m: StoredMessage): Promise<StoredMessage> => ({
+        ...m,
+        content: await fields.sealText(m.content),
+        ...(m.contentHash !== undefined && { contentHash: await fields.hashText(m.contentHash) }),
+    });
+    const openMessage = async (m: StoredMessage): Promise<StoredMessage> => ({ ...m, content: await fields.openText(m.content) });
+    const openMessages = (messages: StoredMessage[]) => Promise.all(messages.map(openMessage));
+
+    const sealResponse = async <T extends Partial<ResponseRecord>>(r: T): Promise<T> => ({
+        ...r,
+        ...('request' in r && { request: await fields.sealJSON(r.request) }),
+        ...('response' in r && { response: await fields.sealJSON(r.response) }),
+        ...('error' in r && { error: await fields.sealJSON(r.error) }),
+    });
+    const openResponse = async (r: ResponseRecord): Promise<ResponseRecord> => ({
+        ...r,
+        request: await fields.openJSON(r.request),
+        response: await fields.openJSON(r.response),
+        error: await fields.openJSON(r.error),
+    });
+
+    const openConversation = async (c: Conversation): Promise<Conversation> => ({ ...c, messages: await openMessages(c.messages) });
+    const openThread = async (t: StoredThread): Promise<StoredThread> => ({ ...t, messages: await openMessages(t.messages) });
+    const openEvent = async (e: InteractionEvent): Promise<InteractionEvent> => ({ ...e, payload: await fields.openJSON(e.payload) });
+    const openInteraction = async (r: InteractionRecord): Promise<InteractionRecord> =>
+        r.request === undefined ? r : { ...r, request: await fields.openText(r.request) };
+
+    // Shadow results keep their structure; the same text erasure scrubs is sealed
+    const sealOptional = async (v: string | undefined) => v === undefined ? v : fields.sealText(v);
+    const openOptional = async (v: string | undefined) => v === undefined ? v : fields.openText(v);
+    const sealDivergence = async (d: Divergence): Promise<Divergence> =>
+        d.type === 'error_presence' ? { ...d, description: await fields.sealText(d.description) }
+            : d.type === 'tool_call_arguments' ? { ...d, primaryValue: await sealOptional(d.primaryValue), shadowValue: await sealOptional(d.shadowValue) }
+                : d;
+    const openDivergence = async (d: Divergence): Promise<Divergence> =>
+        d.type === 'error_presence' ? { ...d, description: await fields.openText(d.description) }
+            : d.type === 'tool_call_arguments' ? { ...d, primaryValue: await openOptional(d.primaryValue), shadowValue: await openOptional(d.shadowValue) }
+                : d;
+    const sealShadow = async (r: ShadowResult): Promise<ShadowResult> => ({
+        ...r,
+        response: r.response && {
+            ...r.response,
+            content: await fields.sealText(r.response.content),
+            toolCalls: r.response.toolCalls && await Promise.all(r.response.toolCalls.map(
+                async (call) => ({ ...call, arguments: await fields.sealText(call.arguments) }),
+            )),
+        },
+        error: r.error && { ...r.error, message: await fields.sealText(r.error.message) },
+        divergences: await Promise.all(r.divergences.map(sealDivergence)),
+    });
+    const openShadow = async (r: ShadowResult): Promise<ShadowResult> => ({
+        ...r,
+        response: r.response && {
+            ...r.response,
+            content: await fields.openText(r.response.content),
+            toolCalls: r.response.toolCalls && await Promise.all(r.response.toolCalls.map(
+                async (call) => ({ ...call, arguments: await fields.openText(call.arguments) }),
+            )),
+        },
+        error: r.error && { ...r.error, message: await fields.openText(r.error.message) },
+        divergences: await Promise.all(r.divergences.map(openDivergence)),
+    });
+
+    const sealHTTPResponse = async (r: StoredHTTPResponse): Promise<StoredHTTPResponse> => ({ ...r, body: await fields.sealText(r.body) });
+    const openIdempotency = async (r: IdempotencyRecord | null): Promise<IdempotencyRecord | null> =>
+        r?.response ? { ...r, response: { ...r.response, body: await fields.openText(r.response.body) } } : r;
+
+    const sealAttempt = async (a: InteractionAttemptRecord): Promise<InteractionAttemptRecord> =>
+        a.upstreamError === undefined ? a : { ...a, upstreamError: await fields.sealText(a.upstreamError) };
+    const openAttempt = async (a: InteractionAttemptRecord): Promise<InteractionAttemptRecord> =>
+        a.upstreamError === undefined ? a : { ...a, upstreamError: await fields.openText(a.upstreamError) };
+
+    const overrides: Partial<StorageProvider> = {
+        // Conversations
+        saveConversation: async (c) => storage.saveConversation({ ...c, messages: await Promise.all(c.messages.map(sealMessage)) }),
+        getConversation: async (id, tenantId) => {
+            const c = await storage.getConversation(id, tenantId);
+            return c && openConversation(c);
+        },
+        listConversations: async (tenantId, options) =>
+            Promise.all((await storage.listConversations(tenantId, options)).map(openConversation)),
+
+        // Responses
+        saveResponse: async (r) => storage.saveResponse(await sealResponse(r)),
+        getResponse: async (id, tenantId) => {
+            const r = await storage.getResponse(id, tenantId);
+            return r && openResponse(r);
+        },
+        listResponses: async (tenantId, options) =>
+            Promise.all((await storage.listResponses(tenantId, options)).map(openResponse)),
+        updateResponse: async (id, updates) => storage.updateResponse!(id, await sealResponse(updates)),
+
+        // Events
+        saveEvent: async (e) => storage.saveEvent({ ...e, payload: await fields.sealJSON(e.payload) }),
+        getEvents: async (interactionId, tenantId) =>
+            Promise.all((await storage.getEvents(interactionId, tenantId)).map(openEvent)),
+
+        // Interaction records
+        recordInteraction: async (r) => storage.recordInteraction!(
+            r.request === undefined ? r : { ...r, request: await fields.sealText(r.request) },
+        ),
+        getInteraction: async (id, tenantId) => {
+            const r = await storage.getInteraction!(id, tenantId);
+            return r && openInteraction(r);
+        },
+
+        // Shadow results
+        saveShadowResult: async (r) => storage.saveShadowResult(await sealShadow(r)),
+        getShadowResults: async (interactionId, tenantId) =>
+            Promise.all((await storage.getShadowResults(interactionId, tenantId)).map(openShadow)),
+        getShadowResult: async (id, tenantId) => {
+            const r = await storage.getShadowResult(id, tenantId);
+            return r && openShadow(r);
+        },
+        listDivergentShadowResults: async (options) =>
+            Promise.all((await storage.listDivergentShadowResults(options)).map(openShadow)),
+
+        // Idempotency keys
+        claimIdempotencyKey: async (r) => openIdempotency(await storage.claimIdempotencyKey!(
+            r.response ? { ...r, response: await sealHTTPResponse(r.response) } : r,
+        )),
+        getIdempotencyRecord: async (tenantId, key) => openIdempotency(await storage.getIdempotencyRecord!(tenantId, key)),
+        completeIdempotencyKey: async (tenantId, key, response, expiresAt) =>
+            storage.completeIdempotencyKey!(tenantId, key, await sealHTTPResponse(response), expiresAt),
+
+        // Attempts
+        saveAttempts: async (records) => storage.saveAttempts!(await Promise.all(records.map(sealAttempt))),
+        listAttempts: async (interactionId, tenantId) =>
+            Promise.all((await storage.listAttempts!(interactionId, tenantId)).map(openAttempt)),
+
+        // Threads
+        createThread: async (t) => storage.createThread!({ ...t, messages: await Promise.all(t.messages.map(sealMessage)) }),
+        getThread: async (id, tenantId) => {
+            const t = await storage.getThread!(id, tenantId);
+            return t && openThread(t);
+        },
+        addMessage: async (threadId, m, options) => storage.addMessage!(threadId, await sealMessage(m), options),
+        listMessages: async (threadId, options) => openMessages(await storage.listMessages!(threadId, options)),
+
+        // Thread transfer
+        importThreadBatch: async (batch) => storage.importThreadBatch!({
+            ...batch,
+            conversations: await Promise.all(batch.conversations.map(
+                async (c) => ({ ...c, messages: await Promise.all(c.messages.map(sealMessage)) }),
+            )),
+            responses: await Promise.all(batch.responses.map(sealResponse)),
+        }),
+    };
+
+    return new Proxy(storage, {
+        get(target, prop) {
+            const value: unknown = Reflect.get(target, prop, target);
+            if (typeof value !== 'function') {
+                return value;
+            }
+            const override = typeof prop === 'string' ? (overrides as Record<string, unknown>)[prop] : undefined;
+            return override ?? value.bind(target);
+        },
+    });
+}
@@ -0,0 +1,158 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { passthroughAllows, passthroughPath } from './passthrough/index';
+import type { EndpointPassthroughConfig } from './ports/config';
+
+/** Mocked Anthropic API: model lookups as JSON, /v1/stream as a held-open event stream. */
+function anthropicApi() {
+    const calls: { method: string; url: string; headers: Record<string, string> }[] = [];
+    let release: () => void = () => {};
+    const fetch = vi.fn(async (url: string, init: RequestInit) => {
+        calls.push({ method: init.method ?? 'GET', url, headers: init.headers as Record<string, string> });
+        if (new URL(url).pathname === '/v1/stream') {
+            const encoder = new TextEncoder();
+            return new Response(new ReadableStream({
+                start(controller) {
+                    controller.enqueue(encoder.encode('event: ping\ndata: {}\n\n'));
+                    release = () => {
+                        controller.enqueue(encoder.encode('event: done\ndata: {}\n\n'));
+                        controller.close();
+                    };
+                },
+            }), { headers: { 'Content-Type': 'text/event-stream', 'request-id': 'req_1' } });
+        }
+        return Response.json({ type: 'model', id: 'claude-sonnet-4' }, { headers: { 'request-id': 'req_1' } });
+    });
+    return { fetch, calls, release: () => release() };
+}
+
+function setup(passthrough?: EndpointPassthroughConfig) {
+    const upstream = anthropicApi();
+    const saved: any[] = [];
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [
+                    { name: 'claude', frontdoor: 'anthropic', path: '/anthropic', passthrough },
+                    { name: 'other', frontdoor: 'anthropic', path: '/other', provider: 'openai', passthrough: {} },
+                ],
+                providers: [
+                    { name: 'anthropic', type: 'anthropic', apiKey: 'sk-ant-provider', baseUrl: 'https://api.anthropic.test' },
+                    { name: 'openai', type: 'openai', apiKey: 'sk-openai' },
+                ],
+                routing: { defaultProvider: 'anthropic' },
+            }),
+        },
+        auth: {
+            authenticate: async (token: string) => (token === 'good' ? { tenantId: 'acme', scopes: [], metadata: {} } : null),
+            getTenant: async () => null,
+        },
+        storage: { saveEvent: async (event: any) => void saved.push(event) } as any,
+        httpClientFactory: () => ({ fetch: upstream.fetch as any }),
+    });
+    const send = (method: string, path: string, token = 'good') => gateway.fetch(new Request(`http://gw${path}`, {
+        method,
+        headers: { Authorization: `Bearer ${token}`, 'x-api-key': 'sk-client', 'anthropic-beta': 'models-2025' },
+    }));
+    const events = () => saved.filter((e) => e.type === 'passthrough');
+    return { gateway, upstream, send, events };
+}
+
+describe('passthroughAllows', () => {
+    it('should match the upstream path against the allow and deny lists', () => {
+        const config = { allow: ['/v1/models*', '/v1/files/*'], deny: ['/v1/files/secret*'] };
+
+        expect(passthroughAllows(config, '/v1/models/claude-sonnet-4')).toBe(true);
+        expect(passthroughAllows(config, '/v1/files/file_1')).toBe(true);
+        expect(passthroughAllows(config, '/v1/files/secret_1')).toBe(false);
+        expect(passthroughAllows(config, '/v1/organizations/me')).toBe(false);
+        expect(passthroughAllows({}, '/v1/organizations/me')).toBe(true);
+        expect(passthroughAllows({ enabled: false }, '/v1/models')).toBe(false);
+        expect(passthroughAllows(undefined, '/v1/models')).toBe(false);
+    });
+
+    it('should map request paths under the app to upstream paths', () => {
+        expect(passthroughPath({ path: '/anthropic' }, '/anthropic/v1/models/x')).toBe('/v1/models/x');
+        expect(passthroughPath({ path: '/anthropic/' }, '/anthropic/models/x')).toBe('/v1/models/x');
+        expect(passthroughPath({ path: '/v1' }, '/v1/models/x')).toBe('/v1/models/x');
+    });
+});
+
+describe('Anthropic endpoint passthrough', () => {
+    it('should forward an unserved endpoint with the provider key', async () => {
+        const { gateway, upstream, send, events } = setup({ allow: ['/v1/models/*'] });
+        await gateway.reload();
+
+        const response = await send('GET', '/anthropic/v1/models/claude-sonnet-4?beta=true');
+
+        expect(response.status).toBe(200);
+        expect(await response.json()).toEqual({ type: 'model', id: 'claude-sonnet-4' });
+        expect(response.headers.get('request-id')).toBe('req_1');
+        expect(upstream.calls).toHaveLength(1);
+        expect(upstream.calls[0]!.url).toBe('https://api.anthropic.test/v1/models/claude-sonnet-4?beta=true');
+        expect(upstream.calls[0]!.headers).toMatchObject({ 'x-api-key': 'sk-ant-provider', 'anthropic-beta': 'models-2025' });
+        expect(upstream.calls[0]!.headers).not.toHaveProperty('authorization');
+
+        await vi.waitFor(() => expect(events()).toHaveLength(1));
+        expect(events()[0].payload).toMatchObject({
+            method: 'GET',
+            path: '/v1/models/claude-sonnet-4',
+            status: 200,
+            provider: 'anthropic',
+        });
+        expect(events()[0].payload).not.toHaveProperty('body');
+    });
+
+    it('should still authenticate the tenant', async () => {
+        const { gateway, upstream, send } = setup({});
+        await gateway.reload();
+
+        const response = await send('GET', '/anthropic/v1/models', 'bad');
+
+        expect(response.status).toBe(401);
+        expect(upstream.fetch).not.toHaveBeenCalled();
+    });
+
+    it('should not forward paths that are denied, unlisted, or when passthrough is off', async () => {
+        const listed = setup({ allow: ['/v1/models/*'], deny: ['/v1/models/secret'] });
+        await listed.gateway.reload();
+        expect((await listed.send('GET', '/anthropic/v1/models/secret')).status).toBe(404);
+        expect((await listed.send('GET', '/anthropic/v1/files')).status).toBe(404);
+
+        const off = setup();
+        await off.gateway.reload();
+        expect((await off.send('GET', '/anthropic/v1/models/claude-sonnet-4')).status).toBe(404);
+
+        expect(listed.upstream.fetch).not.toHaveBeenCalled();
+        expect(off.upstream.fetch).not.toHaveBeenCalled();
+    });
+
+    it('should refuse requests routed to a non-Anthropic provider', async () => {
+        const { gateway, upstream, send } = setup();
+        await gateway.reload();
+
+        const response = await send('GET', '/other/v1/models/gpt-4o');
+
+        expect(response.status).toBe(501);
+        expect((await response.json()).error.message).toContain("routes to 'openai'");
+        expect(upstream.fetch).not.toHaveBeenCalled();
+    });
+
+    it('should stream responses through without buffering them', async () => {
+        const { gateway, upstream, send } = setup({});
+        await gateway.reload();
+
+        const response = await send('POST', '/anthropic/v1/stream');
+        const reader = response.body!.getReader();
+        const decoder = new TextDecoder();
+
+        // The first event arrives while the upstream stream is still open
+        expect(decoder.decode((await reader.read()).value)).toBe('event: ping\ndata: {}\n\n');
+        upstream.release();
+        let rest = '';
+        for (let chunk = await reader.read(); !chunk.done; chunk = await reader.read()) {
+            rest += decoder.decode(chunk.value);
+        }
+        expect(rest).toContain('event: done');
+    });
+});
@@ -0,0 +1,94 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+describe('End-user identifiers', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(salt: string | null = 'pepper') {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .app({ name: 'private', frontdoor: 'openai', path: '/private', forwardEndUser: 'hash' })
+            .provider(new ScriptedProvider('mock', { text: 'ok' }))
+            .config(salt !== null ? { endUsers: { salt } } : {})
+            .start();
+        const gw = gateway;
+        const chat = (user: string | undefined, path = '/v1') => gw.post(`${path}/chat/completions`, {
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'Hi' }],
+            user,
+        });
+        const admin = new AdminHandler({
+            auth: {
+                authenticate: async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }),
+                getTenant: async () => null,
+            },
+            usage: gw.gateway.usageReports,
+            endUserHash: (endUserId) => gw.gateway.endUserHash(endUserId),
+        });
+        return { gw, provider: gw.provider('mock'), chat, admin };
+    }
+
+    it('should forward the raw ID and record the same hash for each request', async () => {
+        const { gw, provider, chat } = await setup();
+        await chat('user-42');
+        await chat('user-42');
+        await chat('user-7');
+        await chat(undefined);
+
+        expect(provider.requests[0]).toMatchObject({ endUserId: 'user-42' });
+        const hash = await gw.gateway.endUserHash('user-42');
+        expect(hash).toMatch(/^[0-9a-f]{64}$/);
+
+        const range = gw.gateway.usageReports.parseRange(new URLSearchParams());
+        const report = await gw.gateway.usageReports.report('acme', range, 'end_user');
+        expect(report.group_by).toBe('end_user');
+        expect(report.data).toHaveLength(3);
+        expect(report.data[0]).toMatchObject({ end_user: null, requests: 1 });
+        expect(report.data).toContainEqual(expect.objectContaining({ end_user: hash, requests: 2, total_tokens: 30 }));
+        expect(report.data).toContainEqual(expect.objectContaining({ end_user: await gw.gateway.endUserHash('user-7'), requests: 1 }));
+        expect(JSON.stringify(report)).not.toContain('user-42');
+    });
+
+    it('should forward only the hash for apps that ask for it', async () => {
+        const { gw, provider, chat } = await setup();
+        await chat('user-42', '/private');
+
+        expect(provider.requests[0]!.endUserId).toBe(await gw.gateway.endUserHash('user-42'));
+        expect(provider.requests[0]!.endUserId).not.toBe('user-42');
+    });
+
+    it('should forward but not record IDs without a salt', async () => {
+        const { gw, provider, chat } = await setup(null);
+        await chat('user-42');
+
+        expect(provider.requests[0]).toMatchObject({ endUserId: 'user-42' });
+        expect(await gw.gateway.endUserHash('user-42')).toBeUndefined();
+        const range = gw.gateway.usageReports.parseRange(new URLSearchParams());
+        const report = await gw.gateway.usageReports.report('acme', range, 'end_user');
+        expect(report.data).toEqual([expect.objectContaining({ end_user: null, requests: 1 })]);
+    });
+
+    it("should find a tenant's interactions by end-user ID or hash in the admin API", async () => {
+        const { gw, chat, admin } = await setup();
+        await chat('user-42');
+        await chat('user-42');
+        await chat('user-7');
+        const list = async (endUser: string, token = 'acme') => (await admin.handle(new Request(
+            `http://localhost/api/interactions?end_user=${encodeURIComponent(endUser)}`,
+            { headers: { Authorization: `Bearer ${token}` } },
+        ))).json();
+        const hash = await gw.gateway.endUserHash('user-42');
+
+        const byId = await list('user-42');
+        expect(byId.total).toBe(2);
+        expect(byId.interactions[0]).toMatchObject({ type: 'request', status: 'completed', model: 'gpt-4o', endUser: hash });
+        expect((await list(hash!)).total).toBe(2);
+        expect((await list('user-42', 'globex')).total).toBe(0);
+    });
+});
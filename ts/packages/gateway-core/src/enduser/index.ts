@@ -0,0 +1,11 @@
+/**
+ * End-user identifier exports.
+ *
+ * @module enduser
+ */
+
+export {
+    EndUserHasher,
+    EndUserProvider,
+    withEndUser,
+} from './provider.js';
@@ -0,0 +1,116 @@
+/**
+ * End-user identifiers.
+ *
+ * Clients name the end user a request is made for (OpenAI `user`,
+ * Anthropic `metadata.user_id`) so providers can attribute abuse. The
+ * gateway forwards it in the upstream's native field and records a salted
+ * hash of it with the request's stats, so usage can be split by end user
+ * without storing the identifier. The salt is per deployment: hashes are
+ * stable across requests and restarts, but can't be matched between
+ * deployments. Apps can forward the hash instead of the raw value.
+ *
+ * @module enduser/provider
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { EndUserForwarding } from '../ports/config.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import { sha256WithSalt } from '../utils/crypto.js';
+
+// ============================================================================
+// Hashing
+// ============================================================================
+
+/**
+ * Hashes end-user IDs with the deployment's salt.
+ */
+export class EndUserHasher {
+    constructor(private readonly salt: string) { }
+
+    /**
+     * Returns the hex SHA-256 of the salted ID; equal IDs hash equally.
+     */
+    hash(endUserId: string): Promise<string> {
+        return sha256WithSalt(endUserId, this.salt);
+    }
+}
+
+// ============================================================================
+// End-User Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so each call's end-user ID is hashed for recording and
+ * forwarded raw or as its hash.
+ */
+export class EndUserProvider extends ProviderWrapper {
+    private readonly hasher: EndUserHasher | undefined;
+    private readonly forwarding: EndUserForwarding;
+    private readonly onEndUser: (hash: string) => void;
+
+    constructor(
+        inner: Provider,
+        hasher: EndUserHasher | undefined,
+        forwarding: EndUserForwarding,
+        onEndUser: (hash: string) => void,
+    ) {
+        super(inner);
+        this.hasher = hasher;
+        this.forwarding = forwarding;
+        this.onEndUser = onEndUser;
+    }
+
+    /**
+     * Completes a request with its end-user ID prepared.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        return this.inner.complete(await this.prepare(request), options);
+    }
+
+    /**
+     * Streams a request with its end-user ID prepared.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        yield* this.inner.stream(await this.prepare(request), options);
+    }
+
+    /**
+     * Reports the ID's hash and swaps the hash in when only it may be
+     * forwarded. Without a salt there is no hash, so nothing is forwarded
+     * in its place. The raw body is dropped so passthrough providers
+     * re-encode the request rather than send the original ID.
+     */
+    private async prepare(request: CanonicalRequest): Promise<CanonicalRequest> {
+        if (request.endUserId === undefined) {
+            return request;
+        }
+        const hash = this.hasher && await this.hasher.hash(request.endUserId);
+        if (hash !== undefined) {
+            this.onEndUser(hash);
+        }
+        return this.forwarding === 'hash'
+            ? { ...request, endUserId: hash, rawRequest: undefined }
+            : request;
+    }
+}
+
+/**
+ * Hashes and forwards end-user IDs for an app's provider calls. Returns
+ * the provider unchanged when there is neither a salt nor hash forwarding.
+ */
+export function withEndUser(
+    provider: Provider,
+    hasher: EndUserHasher | undefined,
+    forwarding: EndUserForwarding | undefined,
+    onEndUser: (hash: string) => void,
+): Provider {
+    if (!hasher && forwarding !== 'hash') {
+        return provider;
+    }
+    return new EndUserProvider(provider, hasher, forwarding ?? 'raw', onEndUser);
+}
@@ -0,0 +1,24 @@
+/**
+ * Stream event size limit exports.
+ *
+ * @module eventsize
+ */
+
+export {
+    EVENT_ENVELOPE_BYTES,
+    MIN_EVENT_BYTES,
+    TRUNCATION_MARKER,
+    encodedSize,
+    splitText,
+    truncateText,
+    splitEvent,
+    truncateEvent,
+    type TruncatedFields,
+} from './split.js';
+
+export {
+    EVENT_TRUNCATED_WARNING,
+    EventSizeLimitingProvider,
+    withEventSizeLimit,
+    type TruncatedEvent,
+} from './provider.js';
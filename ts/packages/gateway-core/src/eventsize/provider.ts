@@ -0,0 +1,94 @@
+/**
+ * Outbound stream event size limits.
+ *
+ * Providers occasionally send a single very large delta (a whole tool
+ * argument blob, a long reasoning summary), and some proxies and client
+ * SSE parsers drop a stream at an event over a few tens of KB. For apps
+ * with `max_sse_event_bytes` set, text, thinking and tool argument deltas
+ * are split into as many events as it takes to stay under the limit; the
+ * client reassembles them like any other deltas. Payloads that can't be
+ * split are cut short with a truncation marker and reported, so the
+ * gateway can warn the client and keep the full value in the interaction
+ * event log.
+ *
+ * @module eventsize/provider
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import { EVENT_ENVELOPE_BYTES, splitEvent, truncateEvent } from './split.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Warning code for a stream event cut short to fit the size limit. */
+export const EVENT_TRUNCATED_WARNING = 'sse_event_truncated';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A stream event whose payload was cut short.
+ */
+export interface TruncatedEvent {
+    /** Canonical event type. */
+    type: CanonicalEvent['type'];
+
+    /** Content block index, if any. */
+    index?: number | undefined;
+
+    /** Full values of the fields cut short, by name. */
+    original: Record<string, unknown>;
+}
+
+// ============================================================================
+// Limiting Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so its stream events stay under a size limit.
+ * Complete (non-streaming) responses pass through.
+ */
+export class EventSizeLimitingProvider extends ProviderWrapper {
+    private readonly budget: number;
+    private readonly onTruncated: (event: TruncatedEvent) => void;
+
+    constructor(inner: Provider, maxEventBytes: number, onTruncated: (event: TruncatedEvent) => void) {
+        super(inner);
+        this.budget = maxEventBytes - EVENT_ENVELOPE_BYTES;
+        this.onTruncated = onTruncated;
+    }
+
+    /**
+     * Streams a request, splitting or cutting events over the limit.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        for await (const event of this.inner.stream(request, options)) {
+            for (const piece of splitEvent(event, this.budget)) {
+                const truncated = truncateEvent(piece, this.budget);
+                if (truncated) {
+                    this.onTruncated({ type: piece.type, index: piece.index, original: truncated.original });
+                }
+                yield truncated?.event ?? piece;
+            }
+        }
+    }
+}
+
+/**
+ * Limits a provider's stream events to `maxEventBytes` when given.
+ * Returns the provider unchanged otherwise.
+ */
+export function withEventSizeLimit(
+    provider: Provider,
+    maxEventBytes: number | undefined,
+    onTruncated: (event: TruncatedEvent) => void,
+): Provider {
+    return maxEventBytes ? new EventSizeLimitingProvider(provider, maxEventBytes, onTruncated) : provider;
+}
@@ -0,0 +1,159 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { TRUNCATION_MARKER, encodedSize, splitEvent, splitText } from './index';
+import type { AppConfig } from '../ports/index';
+import type { CanonicalEvent } from '../domain/types';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/** Multi-byte characters, a surrogate pair, and characters JSON escapes. */
+const UNIT = 'héllo "wörld" \\ 😀 \n\u0001 ';
+
+/** About 1 MB of text. */
+const BIG = UNIT.repeat(Math.ceil(1_000_000 / UNIT.length));
+
+describe('Event splitting', () => {
+    it('should split text within the budget at code point boundaries', () => {
+        const pieces = splitText(BIG, 1000);
+
+        expect(pieces.length).toBeGreaterThan(1000);
+        expect(pieces.join('')).toBe(BIG);
+        for (const piece of pieces) {
+            expect(encodedSize(piece)).toBeLessThanOrEqual(1000);
+            // No surrogate pair is broken across pieces
+            expect(piece).not.toMatch(/^[\udc00-\udfff]|[\ud800-\udbff]$/);
+        }
+        expect(splitText('short', 1000)).toEqual(['short']);
+    });
+
+    it('should keep start and end fields on the first and last pieces', () => {
+        const events = splitEvent({
+            type: 'content_delta',
+            role: 'assistant',
+            contentDelta: 'x'.repeat(2500),
+            finishReason: 'stop',
+            usage: { promptTokens: 1, completionTokens: 2, totalTokens: 3 },
+        }, 1000);
+
+        expect(events).toHaveLength(3);
+        expect(events.map((e) => e.role)).toEqual(['assistant', undefined, undefined]);
+        expect(events.map((e) => e.finishReason)).toEqual([undefined, undefined, 'stop']);
+        expect(events[2]!.usage?.totalTokens).toBe(3);
+    });
+
+    it('should move a tool call start event\'s arguments into deltas', () => {
+        const args = JSON.stringify({ blob: 'y'.repeat(3000) });
+        const events = splitEvent({
+            type: 'content_block_start',
+            index: 1,
+            toolCall: { index: 0, id: 'call_1', type: 'function', function: { name: 'save', arguments: args } },
+        }, 1000);
+
+        expect(events[0]).toMatchObject({ type: 'content_block_start', toolCall: { id: 'call_1', function: { name: 'save', arguments: '' } } });
+        expect(events.slice(1).every((e) => e.type === 'content_block_delta' && e.index === 1)).toBe(true);
+        expect(events.slice(1).map((e) => e.toolCall?.function?.arguments).join('')).toBe(args);
+    });
+});
+
+describe('SSE event size limits', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(events: CanonicalEvent[], frontdoor = 'openai', app: Partial<AppConfig> = { maxSseEventBytes: 16384 }) {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor, path: '/v1', ...app })
+            .provider(new ScriptedProvider('mock', { events }))
+            .start();
+        const gw = gateway;
+        const send = async (path: string) => (await gw.post(path, {
+            model: 'gpt-4o', stream: true, max_tokens: 100, messages: [{ role: 'user', content: 'Hi' }],
+        })).text();
+        return { send, store: gw.store };
+    }
+
+    /** Splits an SSE body into its frames. */
+    function frames(body: string): string[] {
+        return body.split('\n\n').filter(Boolean);
+    }
+
+    /** Parsed data of an SSE body's frames, minus [DONE]. */
+    function data(body: string): any[] {
+        return frames(body)
+            .map((frame) => frame.split('\n').find((l) => l.startsWith('data: '))!.slice('data: '.length))
+            .filter((d) => d !== '[DONE]')
+            .map((d) => JSON.parse(d));
+    }
+
+    it('should deliver a 1 MB delta in full across events under the limit', async () => {
+        const { send } = await setup([
+            { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+            { type: 'content_delta', contentDelta: BIG },
+            { type: 'message_stop', finishReason: 'stop' },
+            { type: 'done' },
+        ]);
+
+        const body = await send('/v1/chat/completions');
+        const sizes = frames(body).map((frame) => new TextEncoder().encode(`${frame}\n\n`).length);
+        expect(sizes.length).toBeGreaterThan(60);
+        expect(Math.max(...sizes)).toBeLessThanOrEqual(16384);
+
+        const chunks = data(body);
+        expect(chunks.map((c) => c.choices?.[0]?.delta?.content ?? '').join('')).toBe(BIG);
+        expect(body).not.toContain('sse_event_truncated');
+    });
+
+    it('should split Anthropic text deltas the same way', async () => {
+        const { send } = await setup([
+            { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+            { type: 'content_block_start', index: 0, contentBlock: { type: 'text', text: '' } },
+            { type: 'content_block_delta', index: 0, contentDelta: BIG },
+            { type: 'content_block_stop', index: 0 },
+            { type: 'message_stop', finishReason: 'stop' },
+            { type: 'done' },
+        ], 'anthropic');
+
+        const events = data(await send('/v1/messages'));
+        const deltas = events.filter((e) => e.type === 'content_block_delta');
+        expect(deltas.length).toBeGreaterThan(60);
+        expect(deltas.map((e) => e.delta.text).join('')).toBe(BIG);
+    });
+
+    it('should cut an unsplittable payload short with a warning, keeping it in the event log', async () => {
+        const signature = 's'.repeat(40000);
+        const { send, store } = await setup([
+            { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+            { type: 'content_block_start', index: 0, contentBlock: { type: 'thinking', thinking: '' } as any },
+            { type: 'content_block_delta', index: 0, thinkingDelta: 'Hmm.' },
+            { type: 'content_block_delta', index: 0, signatureDelta: signature },
+            { type: 'content_block_stop', index: 0 },
+            { type: 'message_stop', finishReason: 'stop' },
+            { type: 'done' },
+        ], 'anthropic');
+
+        const body = await send('/v1/messages');
+        const sent = data(body).find((e) => e.delta?.type === 'signature_delta');
+        expect(sent.delta.signature.endsWith(TRUNCATION_MARKER)).toBe(true);
+        expect(encodedSize(sent.delta.signature)).toBeLessThan(16384);
+        expect(body).toContain('sse_event_truncated');
+
+        await vi.waitFor(() => expect(store.eventsOf('sse_event_truncated')).toHaveLength(1));
+        expect(store.eventsOf('sse_event_truncated')[0]!.payload).toMatchObject({
+            type: 'content_block_delta',
+            original: { signatureDelta: signature },
+        });
+    });
+
+    it('should leave streams alone without a limit', async () => {
+        const { send } = await setup([
+            { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+            { type: 'content_delta', contentDelta: BIG },
+            { type: 'message_stop', finishReason: 'stop' },
+            { type: 'done' },
+        ], 'openai', {});
+
+        const contents = data(await send('/v1/chat/completions')).map((c) => c.choices?.[0]?.delta?.content).filter(Boolean);
+        expect(contents).toEqual([BIG]);
+    });
+});
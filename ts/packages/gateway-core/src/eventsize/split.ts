@@ -0,0 +1,200 @@
+/**
+ * Splitting and truncation of oversized stream events.
+ *
+ * Sizes are of the JSON-encoded value in UTF-8, as the codecs write it.
+ * Text is only ever cut between code points, so a piece never ends
+ * partway through a multi-byte UTF-8 sequence, a surrogate pair, or the
+ * JSON escape a character encodes to.
+ *
+ * @module eventsize/split
+ */
+
+import type { CanonicalEvent } from '../domain/types.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/**
+ * Bytes of an outbound event left for the protocol's envelope (event
+ * name, IDs, model, indexes) around the payload being limited.
+ */
+export const EVENT_ENVELOPE_BYTES = 512;
+
+/** Smallest event size limit an app can configure. */
+export const MIN_EVENT_BYTES = 1024;
+
+/** Appended to a field the gateway cut short. */
+export const TRUNCATION_MARKER = '…[truncated by gateway]';
+
+// ============================================================================
+// Sizes
+// ============================================================================
+
+const encoder = new TextEncoder();
+
+/**
+ * Bytes a string takes JSON-encoded, without its quotes.
+ */
+export function encodedSize(text: string): number {
+    return encoder.encode(JSON.stringify(text)).length - 2;
+}
+
+/**
+ * Bytes a code point takes JSON-encoded.
+ */
+function codePointSize(cp: number): number {
+    if (cp === 0x22 || cp === 0x5c || cp === 0x08 || cp === 0x09 || cp === 0x0a || cp === 0x0c || cp === 0x0d) {
+        return 2;
+    }
+    if (cp < 0x20) return 6;
+    if (cp < 0x80) return 1;
+    if (cp < 0x800) return 2;
+    // Lone surrogates are written as \uXXXX escapes
+    if (cp >= 0xd800 && cp <= 0xdfff) return 6;
+    if (cp < 0x10000) return 3;
+    return 4;
+}
+
+// ============================================================================
+// Splitting
+// ============================================================================
+
+/**
+ * Splits text into pieces of at most `budget` encoded bytes each (a
+ * single character larger than the budget gets a piece of its own).
+ */
+export function splitText(text: string, budget: number): string[] {
+    if (encodedSize(text) <= budget) {
+        return [text];
+    }
+    const pieces: string[] = [];
+    let start = 0;
+    let size = 0;
+    for (let i = 0; i < text.length;) {
+        const cp = text.codePointAt(i)!;
+        const cost = codePointSize(cp);
+        if (size + cost > budget && i > start) {
+            pieces.push(text.slice(start, i));
+            start = i;
+            size = 0;
+        }
+        size += cost;
+        i += cp > 0xffff ? 2 : 1;
+    }
+    pieces.push(text.slice(start));
+    return pieces;
+}
+
+/**
+ * Cuts text to at most `budget` encoded bytes, marker included.
+ */
+export function truncateText(text: string, budget: number): string {
+    const [head = ''] = splitText(text, Math.max(budget - encodedSize(TRUNCATION_MARKER), 0));
+    return head + TRUNCATION_MARKER;
+}
+
+/**
+ * Splits an event whose text or tool argument delta is over `budget` into
+ * several events carrying consecutive pieces of it. The first piece keeps
+ * what only belongs once at the start (role, key ID), the last what
+ * belongs at the end (finish reason, usage, logprobs). A tool call's
+ * start event keeps its ID and name, with the arguments following in
+ * delta events. Other events are returned as they are.
+ */
+export function splitEvent(event: CanonicalEvent, budget: number): CanonicalEvent[] {
+    const text = (field: 'contentDelta' | 'thinkingDelta'): CanonicalEvent[] | undefined => {
+        const value = event[field];
+        if (value === undefined || value.length <= budget / 6) return undefined;
+        const pieces = splitText(value, budget);
+        return pieces.length > 1 ? spread(event, pieces.map((piece) => ({ [field]: piece }))) : undefined;
+    };
+    const args = (): CanonicalEvent[] | undefined => {
+        const call = event.toolCall;
+        const value = call?.function?.arguments;
+        if (!call || value === undefined || value.length <= budget / 6) return undefined;
+        const pieces = splitText(value, budget);
+        if (pieces.length <= 1) return undefined;
+        const delta = (piece: string): Partial<CanonicalEvent> => ({
+            toolCall: { index: call.index, function: { arguments: piece } },
+        });
+        if (event.type === 'content_block_start') {
+            // Anthropic-style blocks carry arguments in deltas only
+            return [
+                { ...event, toolCall: { ...call, function: { ...call.function, arguments: '' } } },
+                ...pieces.map((piece) => ({ type: 'content_block_delta' as const, index: event.index, choiceIndex: event.choiceIndex, ...delta(piece) })),
+            ];
+        }
+        return spread(event, pieces.map((piece, i) => i === 0
+            ? { toolCall: { ...call, function: { ...call.function, arguments: piece } } }
+            : delta(piece)));
+    };
+    return text('contentDelta') ?? text('thinkingDelta') ?? args() ?? [event];
+}
+
+/**
+ * Spreads an event over one event per part.
+ */
+function spread(event: CanonicalEvent, parts: Array<Partial<CanonicalEvent>>): CanonicalEvent[] {
+    const { rawEvent, providerKeyId, role, usage, finishReason, stopSequence, logprobs, warning, ...common } = event;
+    const last = parts.length - 1;
+    return parts.map((part, i) => ({
+        ...common,
+        ...(i === 0 && { rawEvent, providerKeyId, role }),
+        ...(i === last && { usage, finishReason, stopSequence, logprobs, warning }),
+        ...part,
+    }));
+}
+
+// ============================================================================
+// Truncation
+// ============================================================================
+
+/**
+ * An event's fields that can't be split, cut to fit, with their full values.
+ */
+export interface TruncatedFields {
+    /** The event as sent. */
+    event: CanonicalEvent;
+
+    /** Full values of the fields cut short, by name (contentBlock fields as contentBlock.<key>). */
+    original: Record<string, unknown>;
+}
+
+/**
+ * Cuts an event's unsplittable payload (a thinking signature, a block
+ * start's content, logprobs) when the event is over `budget`: strings to
+ * fit with a truncation marker, logprobs dropped. Returns undefined when
+ * the event fits.
+ */
+export function truncateEvent(event: CanonicalEvent, budget: number): TruncatedFields | undefined {
+    if (event.signatureDelta === undefined && event.contentBlock === undefined && !event.logprobs) {
+        return undefined;
+    }
+    const { rawEvent: _, error: __, ...payload } = event;
+    if (encoder.encode(JSON.stringify(payload)).length <= budget) {
+        return undefined;
+    }
+
+    const original: Record<string, unknown> = {};
+    const cut = { ...event };
+    if (cut.logprobs) {
+        original.logprobs = cut.logprobs;
+        cut.logprobs = undefined;
+    }
+    if (cut.signatureDelta !== undefined && encodedSize(cut.signatureDelta) > budget) {
+        original.signatureDelta = cut.signatureDelta;
+        cut.signatureDelta = truncateText(cut.signatureDelta, budget);
+    }
+    if (cut.contentBlock) {
+        const block: Record<string, unknown> = { ...cut.contentBlock };
+        for (const [key, value] of Object.entries(block)) {
+            if (typeof value === 'string' && encodedSize(value) > budget) {
+                original[`contentBlock.${key}`] = value;
+                block[key] = truncateText(value, budget);
+            }
+        }
+        cut.contentBlock = block as unknown as CanonicalEvent['contentBlock'];
+    }
+    return Object.keys(original).length > 0 ? { event: cut, original } : undefined;
+}
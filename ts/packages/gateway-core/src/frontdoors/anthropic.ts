@@ -8,7 +8,25 @@ import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
 import { APIError, isAPIError, errInvalidRequest } from '../domain/errors.js';
 import { AnthropicCodec, anthropicCodec } from '../codecs/anthropic.js';
 import { createAnthropicSSEStream, sseResponse, sseHeaders } from '../utils/streaming.js';
-import type { Frontdoor, FrontdoorContext, FrontdoorResponse } from './types.js';
+import { maybeThrottle, describeThrottle } from '../utils/throttle.js';
+import { TimingRecorder, timeStream } from '../utils/timings.js';
+import { runToolLoop } from '../tools/loop.js';
+import { meterStream } from '../budget/meter.js';
+import { validateAnthropicRequest } from '../codecs/validation.js';
+import { RequestBody } from '../ingest/body.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import {
+    mergeMetadata,
+    type Frontdoor,
+    type FrontdoorContext,
+    type FrontdoorResponse,
+    type FrontdoorRoute,
+} from './types.js';
+import { resolveRequestModel } from './models.js';
+import { planExecution } from './plan.js';
+import { screenResponse, screensStreams, collectResponse, responseStream } from './screening.js';
+import { upstreamErrorResponse } from './errors.js';
+import { matchBatchRoute } from '../batches/batches.js';
 
 // ============================================================================
 // Anthropic Frontdoor
@@ -19,6 +37,13 @@ import type { Frontdoor, FrontdoorContext, FrontdoorResponse } from './types.js'
  */
 export class AnthropicFrontdoor implements Frontdoor {
     readonly name = 'anthropic';
+    readonly routes: readonly FrontdoorRoute[] = [
+        { method: 'POST', path: '/v1/messages' },
+        { method: 'POST', path: '/v1/messages/batches' },
+        { method: 'GET', path: '/v1/messages/batches/:id' },
+        { method: 'GET', path: '/v1/messages/batches/:id/results' },
+        { method: 'POST', path: '/v1/messages/batches/:id/cancel' },
+    ];
     private readonly codec: AnthropicCodec;
 
     constructor() {
@@ -39,11 +64,32 @@ export class AnthropicFrontdoor implements Frontdoor {
         const url = new URL(ctx.request.url);
         const path = url.pathname;
 
+        // Route to message batch handlers
+        const batchRoute = matchBatchRoute(path);
+        if (batchRoute) {
+            if (!ctx.batches) {
+                const { body } = this.codec.encodeError(new APIError(
+                    'invalid_request',
+                    'Message batches are not available on this gateway',
+                    { statusCode: 501 },
+                ));
+                return {
+                    response: new Response(body, { status: 501, headers: { 'Content-Type': 'application/json' } }),
+                };
+            }
+            return ctx.batches.handle(ctx, batchRoute);
+        }
+
         // Route to messages handler
         if (path.endsWith('/messages')) {
             return this.handleMessages(ctx);
         }
 
+        // Forward anything else the app lets through
+        if (ctx.passthrough) {
+            return ctx.passthrough.handle(ctx);
+        }
+
         return {
             response: new Response(
                 JSON.stringify({
@@ -59,7 +105,8 @@ export class AnthropicFrontdoor implements Frontdoor {
      * Handles POST /v1/messages
      */
     private async handleMessages(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
-        const { request, provider, auth, app, logger, pipeline } = ctx;
+        const { request, auth, app, logger } = ctx;
+        const timings = ctx.timings ?? new TimingRecorder();
 
         // Validate request method
         if (request.method !== 'POST') {
@@ -68,21 +115,23 @@ export class AnthropicFrontdoor implements Frontdoor {
 
         // Decode request
         let canonicalRequest: CanonicalRequest;
+        let modelSteps: TransformationStep[] = [];
         try {
-            const body = new Uint8Array(await request.arrayBuffer());
+            const body = ctx.body ?? new RequestBody(await request.text());
+            const unknownFields = validateAnthropicRequest(body.json());
+            if (unknownFields.length > 0) {
+                logger?.debug('unmapped_request_fields', { fields: unknownFields });
+            }
             canonicalRequest = this.codec.decodeRequest(body);
             canonicalRequest.tenantId = auth.tenantId;
             canonicalRequest.userAgent = request.headers.get('user-agent') ?? undefined;
+            canonicalRequest.upstreamHeaders = ctx.upstreamHeaders;
 
-            // Apply default model if configured
-            if (!canonicalRequest.model && app?.defaultModel) {
-                canonicalRequest.model = app.defaultModel;
-            }
+            // Apply the app's default model, routing rewrite, and allow-list
+            modelSteps = resolveRequestModel(canonicalRequest, app, ctx.modelRewrite);
 
-            // Validate model
-            if (!canonicalRequest.model) {
-                return this.errorResponse(errInvalidRequest('model is required'), 400);
-            }
+            // Fail fast on capabilities the model lacks
+            ctx.catalog?.check(canonicalRequest, logger);
         } catch (error) {
             if (isAPIError(error)) {
                 return this.errorResponse(error, error.statusCode);
@@ -93,43 +142,24 @@ export class AnthropicFrontdoor implements Frontdoor {
             );
         }
 
-        // Run pre-request middleware pipeline
-        const pipelineMetadata = new Map<string, unknown>();
-        if (pipeline) {
-            const preResult = await pipeline.runPre({
-                request: canonicalRequest,
-                tenantId: auth.tenantId,
-                appName: app?.name,
-                interactionId: ctx.interactionId,
-                metadata: pipelineMetadata,
-            });
-
-            if (!preResult.continue) {
-                // Pipeline denied the request or responded early
-                if (preResult.response) {
-                    // Early response from middleware
-                    const responseBody = this.codec.encodeResponse(preResult.response);
-                    return {
-                        response: new Response(responseBody, {
-                            status: 200,
-                            headers: { 'Content-Type': 'application/json' },
-                        }),
-                        canonicalRequest,
-                        canonicalResponse: preResult.response,
-                    };
-                }
-                // Denied
-                return this.errorResponse(
-                    new APIError('permission', preResult.denyReason ?? 'Request denied by middleware'),
-                    preResult.denyStatusCode ?? 403,
-                );
-            }
-
-            // Use potentially modified request
-            if (preResult.request) {
-                canonicalRequest = preResult.request;
-            }
+        // Run the pre-request pipeline, apply its routing, check capabilities
+        const plan = await planExecution(ctx, canonicalRequest, modelSteps);
+        if (plan.response) {
+            // Early response from middleware
+            return {
+                response: new Response(this.codec.encodeResponse(plan.response), {
+                    status: 200,
+                    headers: { 'Content-Type': 'application/json' },
+                }),
+                canonicalRequest,
+                canonicalResponse: plan.response,
+            };
+        }
+        if (plan.error) {
+            return this.errorResponse(plan.error, plan.error.statusCode);
         }
+        canonicalRequest = plan.request;
+        const { provider, pipelineMetadata } = plan;
 
         // Log request
         logger?.info('messages_request', {
@@ -141,7 +171,26 @@ export class AnthropicFrontdoor implements Frontdoor {
         try {
             if (canonicalRequest.stream) {
                 // Streaming response
-                const generator = provider.stream(canonicalRequest);
+                if (ctx.gatewayTools) {
+                    logger?.debug('gateway_tools_skipped', { reason: 'streaming' });
+                }
+                const model = canonicalRequest.model;
+                // A stream a denial may be rendered for is screened whole,
+                // then replayed
+                let source = timeStream(provider.stream(canonicalRequest), timings);
+                let denial: Record<string, string> | undefined;
+                if (screensStreams(ctx)) {
+                    const screened = await screenResponse(ctx, canonicalRequest, await collectResponse(source, model), pipelineMetadata);
+                    if (screened.error) {
+                        return this.errorResponse(screened.error, screened.error.statusCode);
+                    }
+                    source = responseStream(screened.response);
+                    denial = screened.metadata;
+                }
+                const generator = maybeThrottle(
+                    meterStream(source, (usage) => ctx.onUsage?.(model, usage)),
+                    app?.streamThrottle,
+                );
                 const stream = createAnthropicSSEStream(generator, this.codec, {
                     model: canonicalRequest.model,
                     created: Math.floor(Date.now() / 1000),
@@ -158,38 +207,38 @@ export class AnthropicFrontdoor implements Frontdoor {
                         },
                     }),
                     canonicalRequest,
+                    metadata: mergeMetadata(
+                        app?.streamThrottle ? { stream_throttle: describeThrottle(app.streamThrottle) } : undefined,
+                        denial,
+                    ),
+                    transformations: modelSteps,
                 };
             } else {
                 // Non-streaming response
-                let canonicalResponse = await provider.complete(canonicalRequest);
+                // Gateway tools are executed here, before the client sees the response
+                const tools = ctx.gatewayTools;
+                let canonicalResponse = await timings.time('providerTotalMs', () => tools
+                    ? runToolLoop(provider, canonicalRequest, {
+                        tools,
+                        maxIterations: app?.gatewayTools?.maxIterations,
+                        interactionId: ctx.interactionId,
+                        tenantId: auth.tenantId,
+                        logger,
+                        events: ctx.events,
+                        budget: ctx.callBudget,
+                    })
+                    : provider.complete(canonicalRequest));
 
                 // Run post-request middleware pipeline
-                if (pipeline) {
-                    const postResult = await pipeline.runPost({
-                        request: canonicalRequest,
-                        response: canonicalResponse,
-                        tenantId: auth.tenantId,
-                        appName: app?.name,
-                        interactionId: ctx.interactionId,
-                        metadata: pipelineMetadata,
-                    });
-
-                    if (!postResult.continue) {
-                        if (postResult.response) {
-                            canonicalResponse = postResult.response;
-                        } else if (postResult.denyReason) {
-                            return this.errorResponse(
-                                new APIError('permission', postResult.denyReason ?? 'Response denied by middleware'),
-                                postResult.denyStatusCode ?? 403,
-                            );
-                        }
-                    } else if (postResult.response) {
-                        // Modified response
-                        canonicalResponse = postResult.response;
-                    }
+                const screened = await screenResponse(ctx, canonicalRequest, canonicalResponse, pipelineMetadata);
+                if (screened.error) {
+                    return this.errorResponse(screened.error, screened.error.statusCode);
                 }
+                canonicalResponse = screened.response;
 
+                const encodeStart = timings.now();
                 const responseBody = this.codec.encodeResponse(canonicalResponse);
+                timings.record('encodeMs', encodeStart);
 
                 return {
                     response: new Response(responseBody, {
@@ -198,6 +247,11 @@ export class AnthropicFrontdoor implements Frontdoor {
                     }),
                     canonicalRequest,
                     canonicalResponse,
+                    metadata: mergeMetadata(
+                        canonicalResponse.providerKeyId ? { provider_key: canonicalResponse.providerKeyId } : undefined,
+                        screened.metadata,
+                    ),
+                    transformations: modelSteps,
                 };
             }
         } catch (error) {
@@ -205,6 +259,11 @@ export class AnthropicFrontdoor implements Frontdoor {
                 error: error instanceof Error ? error.message : String(error),
             });
 
+            // A same-API provider's own error response says the most
+            const passthrough = upstreamErrorResponse(error, 'anthropic', app?.errorPassthrough);
+            if (passthrough) {
+                return { response: passthrough, canonicalRequest };
+            }
             if (isAPIError(error)) {
                 return this.errorResponse(error, error.statusCode);
             }
@@ -0,0 +1,164 @@
+/**
+ * Chat completion chunk framing.
+ *
+ * OpenAI SDK stream helpers (openai-python's accumulator, the Vercel AI
+ * SDK) expect a fixed framing: the first chunk of each choice carries
+ * delta.role, later ones only content or tool call deltas, and a separate
+ * last chunk carries finish_reason with an empty delta. Upstream event
+ * patterns differ (Anthropic opens with message_start and closes blocks
+ * with empty stop events; other providers repeat the role or put the
+ * finish reason on the last delta), so the stream is reframed here,
+ * whatever produced it, one chunk per event.
+ *
+ * @module frontdoors/chunks
+ */
+
+import type { CanonicalEvent, Usage } from '../domain/types.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Where a choice is in its framing.
+ *
+ * - `pending`: nothing sent yet; the role goes on its first chunk
+ * - `open`: role sent; content and tool call deltas follow
+ * - `closed`: finish chunk sent
+ */
+type ChoiceState = 'pending' | 'open' | 'closed';
+
+interface Choice {
+    state: ChoiceState;
+    finishReason?: string | undefined;
+    stopSequence?: string | undefined;
+}
+
+// ============================================================================
+// Framing
+// ============================================================================
+
+/**
+ * Reframes a chat completion stream. Deltas pass through without role or
+ * finish reason; events with nothing to show are dropped, keeping their
+ * finish reason and usage; each choice's finish chunk (the stream's usage
+ * on the last) goes out after all its deltas, just before done.
+ */
+export async function* frameChatChunks(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const framer = new ChunkFramer();
+    for await (const event of source) {
+        if (event.type === 'done') {
+            yield* framer.close();
+            yield event;
+            return;
+        }
+        yield* framer.push(event);
+    }
+    yield* framer.close();
+}
+
+/**
+ * Per-choice framing state for one stream.
+ */
+class ChunkFramer {
+    private readonly choices = new Map<number, Choice>();
+    private usage: Usage | undefined;
+
+    /**
+     * Returns the chunks an upstream event becomes: none, its delta, or
+     * the choice's role chunk and then its delta.
+     */
+    push(event: CanonicalEvent): CanonicalEvent[] {
+        if (event.type === 'error') {
+            return [event];
+        }
+        const index = event.choiceIndex ?? 0;
+        const choice = this.choice(index);
+        if (event.usage) {
+            this.usage = event.usage;
+        }
+        if (event.finishReason) {
+            choice.finishReason = event.finishReason;
+            choice.stopSequence = event.stopSequence;
+        }
+        if (!hasDelta(event) || choice.state === 'closed') {
+            return [];
+        }
+
+        const delta: CanonicalEvent = {
+            type: 'content_delta',
+            choiceIndex: event.choiceIndex,
+            contentDelta: event.contentDelta || undefined,
+            toolCall: event.toolCall,
+            logprobs: event.logprobs,
+            model: event.model,
+        };
+        if (choice.state === 'open') {
+            return [delta];
+        }
+        choice.state = 'open';
+        // A tool call opens with the role on its first delta, as OpenAI
+        // sends it; text opens with a role chunk of its own
+        if (delta.toolCall && delta.contentDelta === undefined) {
+            return [{ ...delta, role: 'assistant' }];
+        }
+        return [roleChunk(event.choiceIndex), delta];
+    }
+
+    /**
+     * Returns the finish chunks of every choice not yet closed, in index
+     * order, opening any that sent nothing. A stream with no choices
+     * still gets one, so clients always see a role and a finish.
+     */
+    close(): CanonicalEvent[] {
+        if (this.choices.size === 0) {
+            this.choice(0);
+        }
+        const chunks: CanonicalEvent[] = [];
+        const open = [...this.choices].filter(([, c]) => c.state !== 'closed').sort(([a], [b]) => a - b);
+        for (const [i, [index, choice]] of open.entries()) {
+            const choiceIndex = index === 0 ? undefined : index;
+            if (choice.state === 'pending') {
+                chunks.push(roleChunk(choiceIndex));
+            }
+            choice.state = 'closed';
+            chunks.push({
+                type: 'message_delta',
+                choiceIndex,
+                finishReason: choice.finishReason ?? 'stop',
+                stopSequence: choice.stopSequence,
+                usage: i === open.length - 1 ? this.usage : undefined,
+            });
+        }
+        return chunks;
+    }
+
+    private choice(index: number): Choice {
+        let choice = this.choices.get(index);
+        if (!choice) {
+            choice = { state: 'pending' };
+            this.choices.set(index, choice);
+        }
+        return choice;
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Whether an event carries anything a delta would show.
+ */
+function hasDelta(event: CanonicalEvent): boolean {
+    return !!event.contentDelta || event.toolCall !== undefined || !!event.logprobs;
+}
+
+/**
+ * The chunk opening a choice: the role and empty content.
+ */
+function roleChunk(choiceIndex: number | undefined): CanonicalEvent {
+    return { type: 'content_delta', choiceIndex, role: 'assistant', contentDelta: '' };
+}
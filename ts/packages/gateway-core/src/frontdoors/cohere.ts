@@ -0,0 +1,359 @@
+/**
+ * Cohere frontdoor - handles Cohere v1 chat API-compatible requests.
+ *
+ * Lets clients built on the Cohere SDK use any configured provider. Grounding
+ * documents and connectors are accepted but not passed upstream; the
+ * interaction records that they were dropped.
+ *
+ * @module frontdoors/cohere
+ */
+
+import type { CanonicalEvent, CanonicalRequest, CanonicalResponse, FinishReason } from '../domain/types.js';
+import { APIError, isAPIError, errInvalidRequest } from '../domain/errors.js';
+import { CohereCodec, cohereCodec, decodeCohereRequest, type DecodedCohereRequest } from '../codecs/cohere.js';
+import type { StreamMetadata } from '../codecs/types.js';
+import { sseHeaders, createStreamAccumulator, accumulateEvent, type StreamAccumulator } from '../utils/streaming.js';
+import { randomUUID } from '../utils/crypto.js';
+import { maybeThrottle, describeThrottle } from '../utils/throttle.js';
+import { TimingRecorder, timeStream } from '../utils/timings.js';
+import { runToolLoop } from '../tools/loop.js';
+import { meterStream } from '../budget/meter.js';
+import { validateCohereRequest } from '../codecs/validation.js';
+import { RequestBody } from '../ingest/body.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import {
+    mergeMetadata,
+    type Frontdoor,
+    type FrontdoorContext,
+    type FrontdoorResponse,
+    type FrontdoorRoute,
+} from './types.js';
+import { resolveRequestModel } from './models.js';
+import { planExecution } from './plan.js';
+import { screenResponse, screensStreams, collectResponse, responseStream } from './screening.js';
+
+// ============================================================================
+// Cohere Frontdoor
+// ============================================================================
+
+/**
+ * Cohere chat API-compatible frontdoor.
+ */
+export class CohereFrontdoor implements Frontdoor {
+    readonly name = 'cohere';
+    readonly routes: readonly FrontdoorRoute[] = [{ method: 'POST', path: '/v1/chat' }];
+    private readonly codec: CohereCodec;
+
+    constructor() {
+        this.codec = cohereCodec;
+    }
+
+    /**
+     * Checks if this frontdoor handles the given path.
+     */
+    matches(path: string): boolean {
+        return path === '/v1/chat' || path === '/chat';
+    }
+
+    /**
+     * Handles a request.
+     */
+    async handle(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
+        const url = new URL(ctx.request.url);
+
+        // Route to chat handler
+        if (url.pathname.endsWith('/chat')) {
+            return this.handleChat(ctx);
+        }
+
+        return this.errorResponse(new APIError('not_found', 'Not found'), 404);
+    }
+
+    /**
+     * Handles POST /v1/chat
+     */
+    private async handleChat(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
+        const { request, auth, app, logger } = ctx;
+        const timings = ctx.timings ?? new TimingRecorder();
+
+        // Validate request method
+        if (request.method !== 'POST') {
+            return this.errorResponse(errInvalidRequest('Method not allowed'), 405);
+        }
+
+        // Decode request
+        let canonicalRequest: CanonicalRequest;
+        let steps: TransformationStep[] = [];
+        try {
+            const body = ctx.body ?? new RequestBody(await request.text());
+            const unknownFields = validateCohereRequest(body.json());
+            if (unknownFields.length > 0) {
+                logger?.debug('unmapped_request_fields', { fields: unknownFields });
+            }
+            const decoded = decodeCohereRequest(body);
+            canonicalRequest = decoded.request;
+            canonicalRequest.tenantId = auth.tenantId;
+            canonicalRequest.userAgent = request.headers.get('user-agent') ?? undefined;
+            canonicalRequest.upstreamHeaders = ctx.upstreamHeaders;
+
+            // Apply the app's default model, routing rewrite, and allow-list
+            steps = [
+                describeConversion(decoded, unknownFields),
+                ...resolveRequestModel(canonicalRequest, app, ctx.modelRewrite),
+            ];
+
+            // Fail fast on capabilities the model lacks
+            ctx.catalog?.check(canonicalRequest, logger);
+        } catch (error) {
+            if (isAPIError(error)) {
+                return this.errorResponse(error, error.statusCode);
+            }
+            return this.errorResponse(
+                errInvalidRequest('Failed to parse request body'),
+                400,
+            );
+        }
+
+        // Run the pre-request pipeline, apply its routing, check capabilities
+        const plan = await planExecution(ctx, canonicalRequest, steps);
+        if (plan.response) {
+            // Early response from middleware
+            return {
+                response: new Response(this.codec.encodeResponse(plan.response), {
+                    status: 200,
+                    headers: { 'Content-Type': 'application/json' },
+                }),
+                canonicalRequest,
+                canonicalResponse: plan.response,
+            };
+        }
+        if (plan.error) {
+            return this.errorResponse(plan.error, plan.error.statusCode);
+        }
+        canonicalRequest = plan.request;
+        const { provider, pipelineMetadata } = plan;
+
+        // Log request
+        logger?.info('cohere_chat_request', {
+            model: canonicalRequest.model,
+            stream: canonicalRequest.stream,
+            messageCount: canonicalRequest.messages.length,
+        });
+
+        try {
+            if (canonicalRequest.stream) {
+                // Streaming response
+                if (ctx.gatewayTools) {
+                    logger?.debug('gateway_tools_skipped', { reason: 'streaming' });
+                }
+                const model = canonicalRequest.model;
+                // A stream a denial may be rendered for is screened whole,
+                // then replayed
+                let source = timeStream(provider.stream(canonicalRequest), timings);
+                let denial: Record<string, string> | undefined;
+                if (screensStreams(ctx)) {
+                    const screened = await screenResponse(ctx, canonicalRequest, await collectResponse(source, model), pipelineMetadata);
+                    if (screened.error) {
+                        return this.errorResponse(screened.error, screened.error.statusCode);
+                    }
+                    source = responseStream(screened.response);
+                    denial = screened.metadata;
+                }
+                const generator = maybeThrottle(
+                    meterStream(source, (usage) => ctx.onUsage?.(model, usage)),
+                    app?.streamThrottle,
+                );
+                const stream = createCohereStream(generator, this.codec, {
+                    id: randomUUID(),
+                    model,
+                    created: Math.floor(Date.now() / 1000),
+                });
+
+                return {
+                    response: new Response(stream, {
+                        status: 200,
+                        headers: { ...sseHeaders(), 'Content-Type': 'application/stream+json' },
+                    }),
+                    canonicalRequest,
+                    metadata: mergeMetadata(
+                        app?.streamThrottle ? { stream_throttle: describeThrottle(app.streamThrottle) } : undefined,
+                        denial,
+                    ),
+                    transformations: steps,
+                };
+            }
+
+            // Non-streaming response
+            // Gateway tools are executed here, before the client sees the response
+            const tools = ctx.gatewayTools;
+            let canonicalResponse = await timings.time('providerTotalMs', () => tools
+                ? runToolLoop(provider, canonicalRequest, {
+                    tools,
+                    maxIterations: app?.gatewayTools?.maxIterations,
+                    interactionId: ctx.interactionId,
+                    tenantId: auth.tenantId,
+                    logger,
+                    events: ctx.events,
+                    budget: ctx.callBudget,
+                })
+                : provider.complete(canonicalRequest));
+
+            // Run post-request middleware pipeline
+            const screened = await screenResponse(ctx, canonicalRequest, canonicalResponse, pipelineMetadata);
+            if (screened.error) {
+                return this.errorResponse(screened.error, screened.error.statusCode);
+            }
+            canonicalResponse = screened.response;
+
+            const encodeStart = timings.now();
+            const responseBody = this.codec.encodeResponse(canonicalResponse);
+            timings.record('encodeMs', encodeStart);
+
+            return {
+                response: new Response(responseBody, {
+                    status: 200,
+                    headers: { 'Content-Type': 'application/json' },
+                }),
+                canonicalRequest,
+                canonicalResponse,
+                metadata: mergeMetadata(
+                    canonicalResponse.providerKeyId ? { provider_key: canonicalResponse.providerKeyId } : undefined,
+                    screened.metadata,
+                ),
+                transformations: steps,
+            };
+        } catch (error) {
+            logger?.error('cohere_chat_error', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+
+            if (isAPIError(error)) {
+                return this.errorResponse(error, error.statusCode);
+            }
+            return this.errorResponse(
+                new APIError('server', error instanceof Error ? error.message : 'Internal error'),
+                500,
+            );
+        }
+    }
+
+    /**
+     * Creates an error response.
+     */
+    private errorResponse(error: APIError, status?: number): FrontdoorResponse {
+        const { body } = this.codec.encodeError(error);
+        return {
+            response: new Response(body, {
+                status: status ?? error.statusCode,
+                headers: { 'Content-Type': 'application/json' },
+            }),
+        };
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Records the chat-to-messages conversion, with the Cohere fields that have
+ * no canonical equivalent as warnings.
+ */
+function describeConversion(decoded: DecodedCohereRequest, unknownFields: string[]): TransformationStep {
+    const warnings: string[] = [];
+    if (decoded.documents > 0) {
+        warnings.push(`Field 'documents' is not supported; ${decoded.documents} document(s) were ignored`);
+    }
+    if (decoded.connectors > 0) {
+        warnings.push(`Field 'connectors' is not supported; ${decoded.connectors} connector(s) were ignored`);
+    }
+
+    const unmapped = [
+        ...(decoded.documents > 0 ? ['documents'] : []),
+        ...(decoded.connectors > 0 ? ['connectors'] : []),
+        ...unknownFields,
+    ];
+    return {
+        stage: 'cohere_chat',
+        timestamp: new Date(),
+        codec: 'cohere',
+        description: 'Converted preamble, chat history and message to chat messages',
+        details: {
+            messages: decoded.request.messages.length,
+            unmappedFields: unmapped.length > 0 ? unmapped : undefined,
+        },
+        warnings: warnings.length > 0 ? warnings : undefined,
+    };
+}
+
+/**
+ * Creates a newline-delimited JSON stream of Cohere events: stream-start,
+ * one text-generation per text delta, then a stream-end carrying the whole
+ * reply (or an ERROR finish if the provider stream fails).
+ */
+function createCohereStream(
+    generator: AsyncGenerator<CanonicalEvent, void, void>,
+    codec: CohereCodec,
+    metadata: StreamMetadata,
+): ReadableStream<Uint8Array> {
+    const encoder = new TextEncoder();
+    const acc = createStreamAccumulator();
+
+    return new ReadableStream({
+        async start(controller) {
+            const write = (line: string): void => {
+                if (line) {
+                    controller.enqueue(encoder.encode(`${line}\n`));
+                }
+            };
+
+            write(codec.encodeStreamEvent({ type: 'message_start' }, metadata));
+            try {
+                for await (const event of generator) {
+                    if (event.type === 'done') break;
+                    accumulateEvent(acc, event);
+                    if (event.type !== 'message_start') {
+                        write(codec.encodeStreamEvent(event, metadata));
+                    }
+                }
+                write(codec.encodeStreamEnd(toResponse(acc, metadata)));
+            } catch (error) {
+                write(codec.encodeStreamEnd(
+                    toResponse(acc, metadata),
+                    error instanceof Error ? error : new Error(String(error)),
+                ));
+            } finally {
+                controller.close();
+            }
+        },
+    });
+}
+
+/**
+ * Builds the reply a stream accumulated so far.
+ */
+function toResponse(acc: StreamAccumulator, metadata: StreamMetadata): CanonicalResponse {
+    const toolCalls = [...acc.toolCalls.values()].map((call) => ({
+        id: call.id,
+        type: 'function' as const,
+        function: { name: call.name, arguments: call.arguments },
+    }));
+
+    return {
+        id: metadata.id ?? acc.responseId ?? '',
+        object: 'chat.completion',
+        created: metadata.created ?? Math.floor(Date.now() / 1000),
+        model: acc.model ?? metadata.model ?? '',
+        choices: [{
+            index: 0,
+            message: { role: 'assistant', content: acc.content, toolCalls: toolCalls.length > 0 ? toolCalls : undefined },
+            finishReason: (acc.finishReason ?? 'stop') as FinishReason,
+            stopSequence: acc.stopSequence,
+        }],
+        usage: acc.usage ?? { promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+        sourceAPIType: 'cohere',
+    };
+}
+
+// Export singleton
+export const cohereFrontdoor = new CohereFrontdoor();
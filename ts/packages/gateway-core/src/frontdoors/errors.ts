@@ -0,0 +1,102 @@
+/**
+ * Provider error passthrough.
+ *
+ * When a frontdoor and its provider speak the same API, the provider's
+ * error response (status, body, and relevant headers) is what the client
+ * would have got calling the provider directly, and usually says more
+ * than the translated error. Such responses are returned as sent, minus
+ * redacted headers and body fields (organization and internal request
+ * IDs by default). Errors from a provider with another API keep the
+ * translated error taxonomy.
+ *
+ * @module frontdoors/errors
+ */
+
+import type { APIType } from '../domain/types.js';
+import type { ErrorPassthroughConfig } from '../ports/config.js';
+import { isAPIError } from '../domain/errors.js';
+
+/** Header and body field names always stripped from passed-through errors. */
+export const DEFAULT_ERROR_REDACTIONS = [
+    'openai-organization',
+    'openai-project',
+    'anthropic-organization-id',
+    'request-id',
+    'x-request-id',
+    'request_id',
+    'organization',
+    'organization_id',
+];
+
+/** Organization and request IDs as they appear inside error messages. */
+const REDACTED_ID_PATTERN = /\b(?:org-[A-Za-z0-9]+|req_[A-Za-z0-9]+)\b/g;
+
+/** Replacement for IDs scrubbed from error text. */
+const REDACTED = '[redacted]';
+
+/**
+ * Returns the client response for a provider error that passes through,
+ * or undefined when the error should be translated: passthrough is
+ * disabled, the provider sent no error response, or it speaks another API.
+ */
+export function upstreamErrorResponse(
+    error: unknown,
+    api: APIType,
+    config?: ErrorPassthroughConfig | undefined,
+): Response | undefined {
+    if (!isAPIError(error) || !error.upstream || error.upstream.api !== api || config?.enabled === false) {
+        return undefined;
+    }
+    const redact = new Set([...DEFAULT_ERROR_REDACTIONS, ...(config?.redact ?? [])].map((name) => name.toLowerCase()));
+    const { status, body, headers } = error.upstream;
+
+    const relayed = new Headers();
+    for (const [name, value] of Object.entries(headers)) {
+        if (!redact.has(name)) {
+            relayed.set(name, value);
+        }
+    }
+    const redacted = redactBody(body, redact);
+    relayed.set('Content-Type', redacted.json ? 'application/json' : 'text/plain; charset=utf-8');
+
+    return new Response(redacted.body, { status, headers: relayed });
+}
+
+/**
+ * Strips redacted fields and scrubs IDs from an error body. The body is
+ * re-serialized only when something was removed, so untouched bodies are
+ * returned byte for byte.
+ */
+function redactBody(body: string, redact: Set<string>): { body: string; json: boolean } {
+    let parsed: unknown;
+    try {
+        parsed = JSON.parse(body);
+    } catch {
+        return { body: body.replace(REDACTED_ID_PATTERN, REDACTED), json: false };
+    }
+    let changed = false;
+    const visit = (value: unknown): unknown => {
+        if (typeof value === 'string') {
+            const scrubbed = value.replace(REDACTED_ID_PATTERN, REDACTED);
+            changed ||= scrubbed !== value;
+            return scrubbed;
+        }
+        if (Array.isArray(value)) {
+            return value.map(visit);
+        }
+        if (value && typeof value === 'object') {
+            const out: Record<string, unknown> = {};
+            for (const [key, field] of Object.entries(value)) {
+                if (redact.has(key.toLowerCase())) {
+                    changed = true;
+                    continue;
+                }
+                out[key] = visit(field);
+            }
+            return out;
+        }
+        return value;
+    };
+    const result = visit(parsed);
+    return { body: changed ? JSON.stringify(result) : body, json: true };
+}
@@ -10,6 +10,7 @@ export type {
     FrontdoorContext,
     FrontdoorResponse,
     FrontdoorRegistry,
+    FrontdoorRoute,
 } from './types.js';
 export { createFrontdoorRegistry } from './types.js';
 
@@ -22,6 +23,22 @@ export { anthropicFrontdoor } from './anthropic.js';
 // Responses
 export { responsesFrontdoor } from './responses.js';
 
+// Cohere
+export { cohereFrontdoor } from './cohere.js';
+
+// Provider error passthrough
+export { upstreamErrorResponse, DEFAULT_ERROR_REDACTIONS } from './errors.js';
+
+// Post-request screening and denial responses
+export {
+    screenResponse,
+    denialResponse,
+    renderDenial,
+    DEFAULT_DENY_CONTENT,
+    type ScreenedResponse,
+    type DenialValues,
+} from './screening.js';
+
 /**
  * Creates a default frontdoor registry with all built-in frontdoors.
  */
@@ -29,11 +46,13 @@ import { createFrontdoorRegistry as createRegistry } from './types.js';
 import { openAIFrontdoor } from './openai.js';
 import { anthropicFrontdoor } from './anthropic.js';
 import { responsesFrontdoor } from './responses.js';
+import { cohereFrontdoor } from './cohere.js';
 
 export const defaultFrontdoorRegistry = (() => {
     const registry = createRegistry();
     registry.register(openAIFrontdoor);
     registry.register(anthropicFrontdoor);
     registry.register(responsesFrontdoor);
+    registry.register(cohereFrontdoor);
     return registry;
 })();
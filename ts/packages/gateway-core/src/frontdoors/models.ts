@@ -0,0 +1,329 @@
+/**
+ * Per-app model resolution shared by the frontdoors: the app's default
+ * model, the routing rewrite, the allow-list, the routed provider's
+ * capabilities (including where tool result images and developer messages
+ * can go), and the model's output token cap.
+ *
+ * @module frontdoors/models
+ */
+
+import type { AppConfig } from '../ports/config.js';
+import type { Provider } from '../ports/provider.js';
+import type { APIType, CanonicalRequest, ContentPart, Message } from '../domain/types.js';
+import { getImageParts } from '../domain/types.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import type { ModelCatalog } from '../domain/catalog.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import { invalidField } from '../codecs/validation.js';
+
+// ============================================================================
+// Model Resolution
+// ============================================================================
+
+/**
+ * Checks a model against an app's allow-list. Patterns match exactly, or
+ * by prefix when they end in `*`. No list (or an empty one) allows any model.
+ */
+export function isModelAllowed(model: string, allowed: string[] | undefined): boolean {
+    if (!allowed?.length) {
+        return true;
+    }
+    return allowed.some((pattern) => pattern.endsWith('*')
+        ? model.startsWith(pattern.slice(0, -1))
+        : model === pattern);
+}
+
+/**
+ * Settles the model a request is served with, in place: the app's default
+ * when the client sent none, then the routing rewrite, then the allow-list
+ * check against the result. Returns the steps applied, for interaction
+ * recording.
+ *
+ * Rejections name only the model the client asked for, never the default,
+ * the rewrite target, or the allow-list; so does the rewrite's warning.
+ */
+export function resolveRequestModel(
+    request: { model?: string | undefined },
+    app: AppConfig | undefined,
+    rewrite: string | undefined,
+): TransformationStep[] {
+    const steps: TransformationStep[] = [];
+    const requested = request.model || undefined;
+
+    if (!requested && app?.defaultModel) {
+        request.model = app.defaultModel;
+        steps.push({
+            stage: 'default_model',
+            timestamp: new Date(),
+            description: `Applied app default model '${app.defaultModel}'`,
+            details: { model: app.defaultModel },
+        });
+    }
+
+    if (!request.model) {
+        throw invalidField('model', 'is required');
+    }
+
+    if (rewrite && rewrite !== request.model) {
+        steps.push({
+            stage: 'model_rewrite',
+            timestamp: new Date(),
+            description: `Rewrote model '${request.model}' to '${rewrite}'`,
+            details: { from: request.model, to: rewrite },
+            warnings: [`model '${request.model}' was rerouted by the gateway to another model`],
+        });
+        request.model = rewrite;
+    }
+
+    if (!isModelAllowed(request.model, app?.allowedModels)) {
+        throw errInvalidRequest(requested
+            ? `model '${requested}' is not available for this app`
+            : 'model: is required for this app',
+        ).withParam('model');
+    }
+
+    return steps;
+}
+
+// ============================================================================
+// Provider Capabilities
+// ============================================================================
+
+/**
+ * Checks a request against what its routed provider can produce. Only
+ * OpenAI providers return logprobs: with strict capabilities (the default)
+ * asking another provider for them is a 400, otherwise the fields are
+ * dropped and the step applied is returned, for interaction recording.
+ */
+export function checkProviderCapabilities(
+    request: CanonicalRequest,
+    provider: Pick<Provider, 'name' | 'apiType'>,
+    app: AppConfig | undefined,
+): TransformationStep | undefined {
+    if (!request.logprobs || provider.apiType === 'openai') {
+        return undefined;
+    }
+    if (app?.strictCapabilities !== false) {
+        throw errInvalidRequest(`logprobs not supported by routed provider '${provider.name}'`).withParam('logprobs');
+    }
+    request.logprobs = undefined;
+    request.topLogprobs = undefined;
+    return {
+        stage: 'capabilities',
+        timestamp: new Date(),
+        description: `Dropped logprobs unsupported by provider '${provider.name}'`,
+        details: { provider: provider.name, fields: ['logprobs', 'top_logprobs'] },
+        warnings: ['logprobs not supported by routed provider; omitted from response'],
+    };
+}
+
+/** APIs with a developer role of their own. */
+const DEVELOPER_ROLE_APIS: ReadonlySet<APIType> = new Set(['openai', 'responses']);
+
+/**
+ * Records developer messages sent to a provider without the role. Their
+ * codecs send them as system instructions, which loses their place in the
+ * conversation (Anthropic's system prompt comes before every message).
+ * Returns the step, for interaction recording.
+ */
+export function recordDeveloperMessages(
+    request: CanonicalRequest,
+    provider: Pick<Provider, 'name' | 'apiType'>,
+): TransformationStep | undefined {
+    const count = request.messages.filter((m) => m.role === 'developer').length;
+    if (count === 0 || DEVELOPER_ROLE_APIS.has(provider.apiType)) {
+        return undefined;
+    }
+    return {
+        stage: 'developer_role',
+        timestamp: new Date(),
+        description: `Sent ${count} developer message(s) as system instructions to provider '${provider.name}'`,
+        details: { provider: provider.name, messages: count, unmappedFields: ['messages[].role'] },
+        warnings: ['routed provider has no developer role; sent developer messages as system instructions'],
+    };
+}
+
+/** Transformation stage recording tool result images moved for the provider. */
+const TOOL_RESULT_IMAGES_STAGE = 'tool_result_images';
+
+/** Stands in for each image moved out of a tool result. */
+export const TOOL_RESULT_IMAGE_PLACEHOLDER = '[image: attached in the next user message]';
+
+/**
+ * Moves images out of tool results for OpenAI providers, in place. OpenAI
+ * tool messages carry text only, so each image is replaced by
+ * TOOL_RESULT_IMAGE_PLACEHOLDER and sent in a user message after the run of
+ * tool messages it came from (tool messages must directly follow the call
+ * they answer). Returns the step applied, for interaction recording.
+ */
+export function downgradeToolResultImages(
+    request: CanonicalRequest,
+    provider: Pick<Provider, 'name' | 'apiType'>,
+): TransformationStep | undefined {
+    if (provider.apiType !== 'openai' || !request.messages.some((m) => m.role === 'tool' && getImageParts(m).length > 0)) {
+        return undefined;
+    }
+
+    const messages: Message[] = [];
+    const toolCallIds: string[] = [];
+    let pending: ContentPart[] = [];
+    let moved = 0;
+    const attach = () => {
+        if (pending.length > 0) {
+            messages.push({
+                role: 'user',
+                content: '',
+                richContent: { parts: [{ type: 'text', text: 'Images returned by the tools above:' }, ...pending] },
+            });
+            pending = [];
+        }
+    };
+
+    for (const m of request.messages) {
+        if (m.role !== 'tool') {
+            attach();
+        }
+        const images = m.role === 'tool' ? getImageParts(m) : [];
+        if (images.length === 0) {
+            messages.push(m);
+            continue;
+        }
+        const content = (m.richContent?.parts ?? [])
+            .map((p) => p.type === 'text' ? p.text ?? '' : images.includes(p) ? TOOL_RESULT_IMAGE_PLACEHOLDER : '')
+            .join('');
+        messages.push({ ...m, content, richContent: undefined });
+        pending.push(...images);
+        moved += images.length;
+        if (m.toolCallId) {
+            toolCallIds.push(m.toolCallId);
+        }
+    }
+    attach();
+
+    request.messages = messages;
+    return {
+        stage: TOOL_RESULT_IMAGES_STAGE,
+        timestamp: new Date(),
+        description: `Moved ${moved} tool result image(s) into user messages for provider '${provider.name}'`,
+        details: { provider: provider.name, images: moved, toolCallIds },
+        warnings: ['routed provider does not accept images in tool results; sent them in a following user message'],
+    };
+}
+
+// ============================================================================
+// Output Token Limits
+// ============================================================================
+
+/** Response header carrying the max_tokens a request was clamped to. */
+export const MAX_TOKENS_CLAMPED_HEADER = 'x-gateway-max-tokens-clamped';
+
+/** Share of a model's output cap held back when it becomes the default max_tokens. */
+export const MAX_TOKENS_SAFETY_MARGIN = 0.05;
+
+/** Transformation stage recording a max_tokens change. */
+const MAX_TOKENS_STAGE = 'max_tokens';
+
+/**
+ * Fits a request's max_tokens to its model, in place. A value over the
+ * model's catalog output cap is clamped to it rather than left for the
+ * provider to reject. An omitted value is set by the app's strategy: the
+ * cap less MAX_TOKENS_SAFETY_MARGIN (the default; left unset for models
+ * the catalog doesn't know), a fixed count, or a 400. Returns the step
+ * applied, with the original and effective values, for interaction
+ * recording.
+ */
+export function fitMaxTokens(
+    request: CanonicalRequest,
+    catalog: Pick<ModelCatalog, 'get'> | undefined,
+    app: AppConfig | undefined,
+): TransformationStep | undefined {
+    const cap = catalog?.get(request.model)?.maxOutputTokens;
+    const original = request.maxTokens;
+
+    if (original !== undefined) {
+        if (cap === undefined || original <= cap) {
+            return undefined;
+        }
+        request.maxTokens = cap;
+        return {
+            stage: MAX_TOKENS_STAGE,
+            timestamp: new Date(),
+            description: `Clamped max_tokens ${original} to the ${cap} output token limit of '${request.model}'`,
+            details: { original, effective: cap, clamped: true },
+            warnings: [`max_tokens ${original} exceeds the model's output token limit; clamped to ${cap}`],
+        };
+    }
+
+    const strategy = app?.maxTokensDefault ?? { strategy: 'model_max' };
+    let effective: number;
+    switch (strategy.strategy) {
+        case 'reject':
+            throw errInvalidRequest('max_tokens: is required for this app').withParam('max_tokens');
+        case 'fixed':
+            effective = cap === undefined ? strategy.tokens : Math.min(strategy.tokens, cap);
+            break;
+        case 'model_max':
+            if (cap === undefined) {
+                return undefined;
+            }
+            effective = Math.max(1, Math.floor(cap * (1 - MAX_TOKENS_SAFETY_MARGIN)));
+            break;
+    }
+
+    request.maxTokens = effective;
+    return {
+        stage: MAX_TOKENS_STAGE,
+        timestamp: new Date(),
+        description: strategy.strategy === 'fixed'
+            ? `Applied app default max_tokens ${effective}`
+            : `Defaulted max_tokens to ${effective} from the output token limit of '${request.model}'`,
+        details: { original: null, effective, clamped: false },
+    };
+}
+
+/**
+ * Makes room for an extended thinking budget, in place, once max_tokens is
+ * fitted: Anthropic counts thinking against max_tokens and requires the
+ * budget to be less. A max_tokens at or under the budget is raised by the
+ * budget, so the answer keeps the room the caller asked for, up to the
+ * model's output cap; a budget the cap can't fit is a 400. Returns the step
+ * applied, recorded as a clamp so the response says what was sent.
+ */
+export function fitThinkingBudget(
+    request: CanonicalRequest,
+    catalog: Pick<ModelCatalog, 'get'> | undefined,
+): TransformationStep | undefined {
+    const budget = request.thinking?.type === 'enabled' ? request.thinking.budgetTokens : undefined;
+    if (budget === undefined) {
+        return undefined;
+    }
+    const cap = catalog?.get(request.model)?.maxOutputTokens;
+    if (cap !== undefined && budget >= cap) {
+        throw errInvalidRequest(
+            `thinking.budget_tokens: must be less than the ${cap} output token limit of '${request.model}'`,
+        ).withParam('thinking.budget_tokens');
+    }
+
+    const original = request.maxTokens;
+    if (original === undefined || original > budget) {
+        return undefined;
+    }
+    const effective = cap === undefined ? budget + original : Math.min(budget + original, cap);
+    request.maxTokens = effective;
+    return {
+        stage: MAX_TOKENS_STAGE,
+        timestamp: new Date(),
+        description: `Raised max_tokens ${original} to ${effective} to fit thinking budget_tokens ${budget}`,
+        details: { original, effective, clamped: true },
+        warnings: [`max_tokens ${original} does not exceed thinking budget_tokens ${budget}; raised to ${effective}`],
+    };
+}
+
+/**
+ * The max_tokens a request was clamped to, from its steps, for the
+ * MAX_TOKENS_CLAMPED_HEADER.
+ */
+export function clampedMaxTokens(steps: readonly TransformationStep[]): number | undefined {
+    const step = steps.find((s) => s.stage === MAX_TOKENS_STAGE && s.details?.['clamped'] === true);
+    return step?.details?.['effective'] as number | undefined;
+}
@@ -4,25 +4,84 @@
  * @module frontdoors/openai
  */
 
-import type { CanonicalRequest, CanonicalResponse, CanonicalEvent, ModelList } from '../domain/types.js';
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Choice,
+    FinishReason,
+    ModelList,
+    Usage,
+} from '../domain/types.js';
+import { getMessageContent, getThinkingParts } from '../domain/types.js';
 import { APIError, isAPIError, errInvalidRequest, errNotFound } from '../domain/errors.js';
 import type { Provider } from '../ports/provider.js';
 import type { AuthContext } from '../ports/auth.js';
 import type { AppConfig } from '../ports/config.js';
+import { CHAT_COMPLETION_ID_PREFIX, isChatCompletionRecord } from '../ports/storage.js';
 import { OpenAICodec, openaiCodec } from '../codecs/openai.js';
-import { createSSEStream, sseResponse } from '../utils/streaming.js';
+import type { StreamMetadata } from '../codecs/types.js';
+import { completionsCodec, decodeCompletionsRequest, type DecodedCompletionsRequest } from '../codecs/completions.js';
+import {
+    createSSEStream,
+    sseResponse,
+    createStreamAccumulator,
+    accumulateEvent,
+    type StreamAccumulator,
+} from '../utils/streaming.js';
+import { maybeThrottle, describeThrottle } from '../utils/throttle.js';
+import { TimingRecorder, timeStream } from '../utils/timings.js';
+import { runToolLoop } from '../tools/loop.js';
+import { meterStream } from '../budget/meter.js';
+import { sumUsage } from '../providers/multichoice.js';
 import type { Logger } from '../utils/logging.js';
-import type { Frontdoor, FrontdoorContext, FrontdoorResponse } from './types.js';
+import { randomUUID } from '../utils/crypto.js';
+import { RequestBody } from '../ingest/body.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import {
+    validateOpenAIRequest,
+    validateCompletionsRequest,
+    invalidField,
+} from '../codecs/validation.js';
+import {
+    renderTemplate,
+    applyTemplate,
+    templateMetadata,
+    describeTemplate,
+    type RenderedTemplate,
+} from '../templates/prompt.js';
+import {
+    mergeMetadata,
+    type Frontdoor,
+    type FrontdoorContext,
+    type FrontdoorResponse,
+    type FrontdoorRoute,
+} from './types.js';
+import { resolveRequestModel } from './models.js';
+import { planExecution } from './plan.js';
+import { screenResponse, screensStreams, collectResponse, responseStream } from './screening.js';
+import { upstreamErrorResponse } from './errors.js';
+import { frameChatChunks } from './chunks.js';
 
 // ============================================================================
 // OpenAI Frontdoor
 // ============================================================================
 
+/** Upper bound on prompts fanned out from one legacy completions request. */
+const MAX_FANOUT_PROMPTS = 16;
+
 /**
  * OpenAI API-compatible frontdoor.
  */
 export class OpenAIFrontdoor implements Frontdoor {
     readonly name = 'openai';
+    readonly routes: readonly FrontdoorRoute[] = [
+        { method: 'POST', path: '/v1/chat/completions' },
+        { method: 'GET', path: '/v1/chat/completions/:id' },
+        { method: 'POST', path: '/v1/completions' },
+        { method: 'GET', path: '/v1/models' },
+        { method: 'GET', path: '/v1/models/:model' },
+    ];
     private readonly codec: OpenAICodec;
 
     constructor() {
@@ -35,8 +94,10 @@ export class OpenAIFrontdoor implements Frontdoor {
     matches(path: string): boolean {
         return (
             path.startsWith('/v1/chat/completions') ||
+            path.startsWith('/v1/completions') ||
             path.startsWith('/v1/models') ||
-            path === '/chat/completions'
+            path === '/chat/completions' ||
+            path === '/completions'
         );
     }
 
@@ -48,10 +109,19 @@ export class OpenAIFrontdoor implements Frontdoor {
         const path = url.pathname;
 
         // Route to appropriate handler
+        const stored = path.match(/\/chat\/completions\/([^/]+)$/);
+        if (stored) {
+            return this.handleStoredCompletion(ctx, decodeURIComponent(stored[1]!));
+        }
+
         if (path.endsWith('/chat/completions')) {
             return this.handleChatCompletions(ctx);
         }
 
+        if (path.endsWith('/completions')) {
+            return this.handleCompletions(ctx);
+        }
+
         if (path.endsWith('/models') || path.includes('/models/')) {
             return this.handleModels(ctx, path);
         }
@@ -68,7 +138,8 @@ export class OpenAIFrontdoor implements Frontdoor {
      * Handles POST /v1/chat/completions
      */
     private async handleChatCompletions(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
-        const { request, provider, auth, app, logger, pipeline } = ctx;
+        const { request, auth, app, logger } = ctx;
+        const timings = ctx.timings ?? new TimingRecorder();
 
         // Validate request method
         if (request.method !== 'POST') {
@@ -77,21 +148,32 @@ export class OpenAIFrontdoor implements Frontdoor {
 
         // Decode request
         let canonicalRequest: CanonicalRequest;
+        let steps: TransformationStep[] = [];
+        let template: RenderedTemplate | undefined;
         try {
-            const body = new Uint8Array(await request.arrayBuffer());
+            const body = ctx.body ?? new RequestBody(await request.text());
+            const raw = body.json() as { template?: unknown };
+            const unknownFields = validateOpenAIRequest(raw);
+            if (unknownFields.length > 0) {
+                logger?.debug('unmapped_request_fields', { fields: unknownFields });
+            }
             canonicalRequest = this.codec.decodeRequest(body);
             canonicalRequest.tenantId = auth.tenantId;
             canonicalRequest.userAgent = request.headers.get('user-agent') ?? undefined;
+            canonicalRequest.upstreamHeaders = ctx.upstreamHeaders;
 
-            // Apply default model if configured
-            if (!canonicalRequest.model && app?.defaultModel) {
-                canonicalRequest.model = app.defaultModel;
-            }
+            // Apply the app's default model, routing rewrite, and allow-list
+            steps = resolveRequestModel(canonicalRequest, app, ctx.modelRewrite);
 
-            // Validate model
-            if (!canonicalRequest.model) {
-                return this.errorResponse(errInvalidRequest('model is required'), 400);
+            // Render a gateway-managed prompt template
+            if (raw.template != null) {
+                template = renderTemplate(raw.template, ctx.templates);
+                applyTemplate(canonicalRequest, template);
+                steps.push(describeTemplate(template));
             }
+
+            // Fail fast on capabilities the model lacks
+            ctx.catalog?.check(canonicalRequest, logger);
         } catch (error) {
             if (isAPIError(error)) {
                 return this.errorResponse(error, error.statusCode);
@@ -102,43 +184,25 @@ export class OpenAIFrontdoor implements Frontdoor {
             );
         }
 
-        // Run pre-request middleware pipeline
-        const pipelineMetadata = new Map<string, unknown>();
-        if (pipeline) {
-            const preResult = await pipeline.runPre({
-                request: canonicalRequest,
-                tenantId: auth.tenantId,
-                appName: app?.name,
-                interactionId: ctx.interactionId,
-                metadata: pipelineMetadata,
-            });
-
-            if (!preResult.continue) {
-                // Pipeline denied the request or responded early
-                if (preResult.response) {
-                    // Early response from middleware
-                    const responseBody = this.codec.encodeResponse(preResult.response);
-                    return {
-                        response: new Response(responseBody, {
-                            status: 200,
-                            headers: { 'Content-Type': 'application/json' },
-                        }),
-                        canonicalRequest,
-                        canonicalResponse: preResult.response,
-                    };
-                }
-                // Denied
-                return this.errorResponse(
-                    new APIError('permission', preResult.denyReason ?? 'Request denied by middleware'),
-                    preResult.denyStatusCode ?? 403,
-                );
-            }
-
-            // Use potentially modified request
-            if (preResult.request) {
-                canonicalRequest = preResult.request;
-            }
+        // Run the pre-request pipeline, apply its routing, check capabilities
+        const plan = await planExecution(ctx, canonicalRequest, steps);
+        if (plan.response) {
+            // Early response from middleware
+            const responseBody = this.codec.encodeResponse(plan.response);
+            return {
+                response: new Response(responseBody, {
+                    status: 200,
+                    headers: { 'Content-Type': 'application/json' },
+                }),
+                canonicalRequest,
+                canonicalResponse: plan.response,
+            };
         }
+        if (plan.error) {
+            return this.errorResponse(plan.error, plan.error.statusCode);
+        }
+        canonicalRequest = plan.request;
+        const { provider, pipelineMetadata } = plan;
 
         // Log request
         logger?.info('chat_completion_request', {
@@ -147,52 +211,111 @@ export class OpenAIFrontdoor implements Frontdoor {
             messageCount: canonicalRequest.messages.length,
         });
 
+        // Keep the completion for GET /v1/chat/completions/{id}
+        const store = canonicalRequest.store === true && ctx.storage !== undefined;
+        if (canonicalRequest.store && !ctx.storage) {
+            logger?.debug('chat_completion_store_skipped', { reason: 'no storage' });
+            ctx.warnings?.add('store_unavailable', 'store was requested but this gateway has no storage; the completion was not stored');
+        }
+
         try {
             if (canonicalRequest.stream) {
                 // Streaming response
-                const generator = provider.stream(canonicalRequest);
-                const stream = createSSEStream(generator, this.codec, {
+                if (ctx.gatewayTools) {
+                    logger?.debug('gateway_tools_skipped', { reason: 'streaming' });
+                }
+                const model = canonicalRequest.model;
+                // A stream a denial may be rendered for is screened whole,
+                // then replayed
+                let source = timeStream(provider.stream(canonicalRequest), timings);
+                let denial: Record<string, string> | undefined;
+                if (screensStreams(ctx)) {
+                    const screened = await screenResponse(ctx, canonicalRequest, await collectResponse(source, model), pipelineMetadata);
+                    if (screened.error) {
+                        return this.errorResponse(screened.error, screened.error.statusCode);
+                    }
+                    source = responseStream(screened.response);
+                    denial = screened.metadata;
+                }
+                const metered = meterStream(source, (usage) => ctx.onUsage?.(model, usage));
+                const streamMetadata = {
+                    id: store ? newCompletionId() : undefined,
                     model: canonicalRequest.model,
                     created: Math.floor(Date.now() / 1000),
-                });
+                    includeUsage: canonicalRequest.streamIncludeUsage,
+                };
+                const generator = maybeThrottle(
+                    frameChatChunks(finishStream(
+                        withoutThinking(metered, (dropped) => {
+                            logger?.debug('unmapped_thinking_dropped', { events: dropped });
+                        }),
+                        streamMetadata.includeUsage ?? false,
+                        store
+                            ? (choices, usage) => this.storeCompletion(
+                                ctx,
+                                canonicalRequest,
+                                assembleCompletion(streamMetadata, canonicalRequest, choices, usage),
+                            )
+                            : undefined,
+                    )),
+                    app?.streamThrottle,
+                );
+                const stream = createSSEStream(generator, this.codec, streamMetadata);
 
-                // Note: Cannot run post-middleware on streaming responses easily
-                // The stream is returned directly to the client
                 return {
                     response: sseResponse(stream),
                     canonicalRequest,
+                    metadata: mergeMetadata(
+                        template ? templateMetadata(template) : undefined,
+                        app?.streamThrottle ? { stream_throttle: describeThrottle(app.streamThrottle) } : undefined,
+                        denial,
+                    ),
+                    transformations: steps,
                 };
             } else {
                 // Non-streaming response
-                let canonicalResponse = await provider.complete(canonicalRequest);
+                // Gateway tools are executed here, before the client sees the response
+                const tools = ctx.gatewayTools;
+                let canonicalResponse = await timings.time('providerTotalMs', () => tools
+                    ? runToolLoop(provider, canonicalRequest, {
+                        tools,
+                        maxIterations: app?.gatewayTools?.maxIterations,
+                        interactionId: ctx.interactionId,
+                        tenantId: auth.tenantId,
+                        logger,
+                        events: ctx.events,
+                        budget: ctx.callBudget,
+                    })
+                    : provider.complete(canonicalRequest));
 
                 // Run post-request middleware pipeline
-                if (pipeline) {
-                    const postResult = await pipeline.runPost({
-                        request: canonicalRequest,
-                        response: canonicalResponse,
-                        tenantId: auth.tenantId,
-                        appName: app?.name,
-                        interactionId: ctx.interactionId,
-                        metadata: pipelineMetadata,
-                    });
+                const screened = await screenResponse(ctx, canonicalRequest, canonicalResponse, pipelineMetadata);
+                if (screened.error) {
+                    return this.errorResponse(screened.error, screened.error.statusCode);
+                }
+                canonicalResponse = screened.response;
 
-                    if (!postResult.continue) {
-                        if (postResult.response) {
-                            canonicalResponse = postResult.response;
-                        } else if (postResult.denyReason) {
-                            return this.errorResponse(
-                                new APIError('permission', postResult.denyReason ?? 'Response denied by middleware'),
-                                postResult.denyStatusCode ?? 403,
-                            );
-                        }
-                    } else if (postResult.response) {
-                        // Modified response
-                        canonicalResponse = postResult.response;
-                    }
+                // Chat Completions has no representation for thinking blocks
+                const thinkingBlocks = canonicalResponse.choices
+                    .reduce((n, c) => n + getThinkingParts(c.message).length, 0);
+                if (thinkingBlocks > 0) {
+                    logger?.debug('unmapped_thinking_dropped', { blocks: thinkingBlocks });
+                }
+
+                if (canonicalRequest.metadata) {
+                    canonicalResponse = { ...canonicalResponse, metadata: canonicalRequest.metadata };
+                }
+                if (store && !canonicalResponse.id.startsWith(CHAT_COMPLETION_ID_PREFIX)) {
+                    canonicalResponse = { ...canonicalResponse, id: newCompletionId() };
                 }
 
+                const encodeStart = timings.now();
                 const responseBody = this.codec.encodeResponse(canonicalResponse);
+                timings.record('encodeMs', encodeStart);
+
+                if (store) {
+                    await this.storeCompletion(ctx, canonicalRequest, canonicalResponse);
+                }
 
                 return {
                     response: new Response(responseBody, {
@@ -201,6 +324,12 @@ export class OpenAIFrontdoor implements Frontdoor {
                     }),
                     canonicalRequest,
                     canonicalResponse,
+                    metadata: mergeMetadata(
+                        template ? templateMetadata(template) : undefined,
+                        canonicalResponse.providerKeyId ? { provider_key: canonicalResponse.providerKeyId } : undefined,
+                        screened.metadata,
+                    ),
+                    transformations: steps,
                 };
             }
         } catch (error) {
@@ -208,6 +337,212 @@ export class OpenAIFrontdoor implements Frontdoor {
                 error: error instanceof Error ? error.message : String(error),
             });
 
+            // A same-API provider's own error response says the most
+            const passthrough = upstreamErrorResponse(error, 'openai', app?.errorPassthrough);
+            if (passthrough) {
+                return { response: passthrough, canonicalRequest };
+            }
+            if (isAPIError(error)) {
+                return this.errorResponse(error, error.statusCode);
+            }
+            return this.errorResponse(
+                new APIError('server', error instanceof Error ? error.message : 'Internal error'),
+                500,
+            );
+        }
+    }
+
+    /**
+     * Handles POST /v1/completions (legacy text completions).
+     *
+     * Each prompt becomes a canonical request with a single user message
+     * served through the provider's chat path. Array prompts fan out into one
+     * set of choices per prompt when the app enables fanOutPrompts, and are
+     * rejected with a 400 otherwise.
+     */
+    private async handleCompletions(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
+        const { request, auth, app, logger, pipeline } = ctx;
+        const timings = ctx.timings ?? new TimingRecorder();
+
+        // Validate request method
+        if (request.method !== 'POST') {
+            return this.errorResponse(errInvalidRequest('Method not allowed'), 405);
+        }
+
+        // Decode request
+        let decoded: DecodedCompletionsRequest;
+        let modelSteps: TransformationStep[] = [];
+        try {
+            const body = ctx.body ?? new RequestBody(await request.text());
+            const unknownFields = validateCompletionsRequest(body.json());
+            if (unknownFields.length > 0) {
+                logger?.debug('unmapped_request_fields', { fields: unknownFields });
+            }
+            decoded = decodeCompletionsRequest(body);
+
+            if (decoded.prompts.length > 1 && !app?.fanOutPrompts) {
+                throw invalidField(
+                    'prompt',
+                    'array prompts are not enabled for this app; send one prompt per request',
+                );
+            }
+            if (decoded.prompts.length > MAX_FANOUT_PROMPTS) {
+                throw invalidField('prompt', `must have at most ${MAX_FANOUT_PROMPTS} prompts`);
+            }
+
+            for (const req of decoded.requests) {
+                req.tenantId = auth.tenantId;
+                req.userAgent = request.headers.get('user-agent') ?? undefined;
+                req.upstreamHeaders = ctx.upstreamHeaders;
+
+                // Apply the app's default model, routing rewrite, and allow-list
+                modelSteps = resolveRequestModel(req, app, ctx.modelRewrite);
+            }
+
+            // Fail fast on capabilities the model lacks
+            ctx.catalog?.check(decoded.requests[0]!, logger);
+        } catch (error) {
+            if (isAPIError(error)) {
+                return this.errorResponse(error, error.statusCode);
+            }
+            return this.errorResponse(
+                errInvalidRequest('Failed to parse request body'),
+                400,
+            );
+        }
+
+        const conversion = describeConversion(decoded);
+        const canonicalRequest = decoded.requests[0]!;
+        const metadata = { frontdoor_api: 'completions' };
+        ctx.onDecoded?.(canonicalRequest);
+
+        // Run pre-request middleware pipeline for each prompt
+        const pipelineMetadata = new Map<string, unknown>();
+        const calls: PromptCall[] = decoded.requests.map((req) => ({ request: req, provider: ctx.provider }));
+        if (pipeline) {
+            const denied = await timings.time('prePipelineMs', async () => {
+                for (const call of calls) {
+                    const preResult = await pipeline.runPre({
+                        request: call.request,
+                        tenantId: auth.tenantId,
+                        appName: app?.name,
+                        interactionId: ctx.interactionId,
+                        metadata: pipelineMetadata,
+                    });
+
+                    if (!preResult.continue) {
+                        if (!preResult.response) {
+                            return preResult;
+                        }
+                        // Early response from middleware stands in for this prompt
+                        call.early = preResult.response;
+                        continue;
+                    }
+
+                    if (preResult.request) {
+                        call.request = preResult.request;
+                    }
+
+                    // Apply a route override chosen by a pipeline stage
+                    if (preResult.route) {
+                        const routed = preResult.route.provider ? ctx.resolveProvider?.(preResult.route.provider) : undefined;
+                        call.provider = routed ?? call.provider;
+                        logger?.info('pipeline_route_applied', {
+                            stage: preResult.route.stage,
+                            provider: call.provider.name,
+                            model: call.request.model,
+                        });
+                    }
+                }
+                return undefined;
+            });
+
+            if (denied) {
+                return this.errorResponse(
+                    new APIError('permission', denied.denyReason ?? 'Request denied by middleware'),
+                    denied.denyStatusCode ?? 403,
+                );
+            }
+        }
+
+        // Log request
+        logger?.info('completion_request', {
+            model: canonicalRequest.model,
+            stream: canonicalRequest.stream,
+            prompts: decoded.prompts.length,
+            echo: decoded.echo,
+        });
+
+        try {
+            if (canonicalRequest.stream) {
+                // Streaming response; prompts stream one after another
+                const model = canonicalRequest.model;
+                const metered = meterStream(
+                    timeStream(streamPrompts(calls), timings),
+                    (usage) => ctx.onUsage?.(model, usage),
+                );
+                const generator = maybeThrottle(
+                    decoded.echo ? withEcho(metered, decoded.prompts, canonicalRequest.n ?? 1) : metered,
+                    app?.streamThrottle,
+                );
+                const stream = createSSEStream(generator, completionsCodec, {
+                    id: `cmpl-${ctx.interactionId}`,
+                    model,
+                    created: Math.floor(Date.now() / 1000),
+                });
+
+                return {
+                    response: sseResponse(stream),
+                    canonicalRequest,
+                    metadata: app?.streamThrottle
+                        ? { ...metadata, stream_throttle: describeThrottle(app.streamThrottle) }
+                        : metadata,
+                    transformations: [conversion, ...modelSteps],
+                };
+            }
+
+            // Non-streaming response
+            const responses = await timings.time('providerTotalMs', () => Promise.all(
+                calls.map((call) => call.early ?? call.provider.complete(call.request)),
+            ));
+
+            // Run post-request middleware pipeline for each prompt
+            let denial: Record<string, string> | undefined;
+            for (const [i, call] of calls.entries()) {
+                if (call.early) continue;
+                const screened = await screenResponse(ctx, call.request, responses[i]!, pipelineMetadata);
+                if (screened.error) {
+                    return this.errorResponse(screened.error, screened.error.statusCode);
+                }
+                responses[i] = screened.response;
+                denial = denial ?? screened.metadata;
+            }
+
+            const canonicalResponse = mergePromptResponses(responses, decoded, canonicalRequest.n ?? 1);
+
+            const encodeStart = timings.now();
+            const responseBody = completionsCodec.encodeResponse(canonicalResponse);
+            timings.record('encodeMs', encodeStart);
+
+            return {
+                response: new Response(responseBody, {
+                    status: 200,
+                    headers: { 'Content-Type': 'application/json' },
+                }),
+                canonicalRequest,
+                canonicalResponse,
+                metadata: mergeMetadata(
+                    metadata,
+                    canonicalResponse.providerKeyId ? { provider_key: canonicalResponse.providerKeyId } : undefined,
+                    denial,
+                ),
+                transformations: [conversion, ...modelSteps],
+            };
+        } catch (error) {
+            logger?.error('completion_error', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+
             if (isAPIError(error)) {
                 return this.errorResponse(error, error.statusCode);
             }
@@ -270,7 +605,13 @@ export class OpenAIFrontdoor implements Frontdoor {
             } else if (provider.listModels) {
                 models = await provider.listModels();
             } else {
-                models = { object: 'list', data: [] };
+                // Fall back to the catalog's models for this provider's API
+                models = {
+                    object: 'list',
+                    data: (ctx.catalog?.list() ?? [])
+                        .filter((m) => m.apiType === provider.apiType)
+                        .map((m) => ({ id: m.id, object: 'model' })),
+                };
             }
 
             return {
@@ -294,6 +635,60 @@ export class OpenAIFrontdoor implements Frontdoor {
         }
     }
 
+    /**
+     * Handles GET /v1/chat/completions/{id}: a completion created with
+     * `store`, as it was returned, for the tenant that created it.
+     */
+    private async handleStoredCompletion(ctx: FrontdoorContext, id: string): Promise<FrontdoorResponse> {
+        if (ctx.request.method !== 'GET') {
+            return this.errorResponse(errInvalidRequest('Method not allowed'), 405);
+        }
+
+        const record = await ctx.storage?.getResponse(id, ctx.auth.tenantId);
+        if (!record || !isChatCompletionRecord(record)) {
+            return this.errorResponse(errNotFound(`Chat completion '${id}' not found`), 404);
+        }
+
+        return {
+            response: new Response(JSON.stringify(record.response), {
+                status: 200,
+                headers: { 'Content-Type': 'application/json' },
+            }),
+        };
+    }
+
+    /**
+     * Stores a completion as the client received it. A failed save is
+     * logged rather than failing a completion that already succeeded.
+     */
+    private async storeCompletion(
+        ctx: FrontdoorContext,
+        request: CanonicalRequest,
+        response: CanonicalResponse,
+    ): Promise<void> {
+        const now = new Date();
+        try {
+            await ctx.storage!.saveResponse({
+                id: response.id,
+                tenantId: ctx.auth.tenantId,
+                appName: ctx.app?.name,
+                model: response.model,
+                status: 'completed',
+                request,
+                response: JSON.parse(new TextDecoder().decode(this.codec.encodeResponse(response))) as unknown,
+                usage: response.usage,
+                metadata: request.metadata,
+                createdAt: now,
+                updatedAt: now,
+            });
+        } catch (error) {
+            ctx.logger?.warn('chat_completion_store_failed', {
+                id: response.id,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+
     /**
      * Creates an error response.
      */
@@ -308,5 +703,259 @@ export class OpenAIFrontdoor implements Frontdoor {
     }
 }
 
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Drops thinking events, which have no Chat Completions representation.
+ */
+async function* withoutThinking(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    onDropped: (count: number) => void,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    let dropped = 0;
+    for await (const event of source) {
+        if (
+            event.thinkingDelta !== undefined ||
+            event.signatureDelta !== undefined ||
+            event.contentBlock?.type === 'thinking' ||
+            event.contentBlock?.type === 'redacted_thinking'
+        ) {
+            dropped++;
+            continue;
+        }
+        yield event;
+    }
+    if (dropped > 0) {
+        onDropped(dropped);
+    }
+}
+
+/**
+ * Finishes a chat completions stream. With include_usage, usage is held
+ * back from the content chunks and sent on the done event, and events
+ * that carried nothing else are dropped. `onComplete` gets the streamed
+ * choices, by index, before the done event goes out.
+ */
+async function* finishStream(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    includeUsage: boolean,
+    onComplete?: (choices: Map<number, StreamAccumulator>, usage: Usage | undefined) => Promise<void>,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const choices = new Map<number, StreamAccumulator>();
+    let usage: Usage | undefined;
+    for await (const event of source) {
+        if (event.type === 'done') {
+            await onComplete?.(choices, usage);
+            yield includeUsage && usage ? { ...event, usage } : event;
+            return;
+        }
+
+        if (event.usage) {
+            usage = event.usage;
+        }
+        if (onComplete) {
+            const index = event.choiceIndex ?? 0;
+            const acc = choices.get(index) ?? createStreamAccumulator();
+            accumulateEvent(acc, event);
+            choices.set(index, acc);
+        }
+        if (!includeUsage || !event.usage) {
+            yield event;
+        } else if (!isUsageOnly(event)) {
+            yield { ...event, usage: undefined };
+        }
+    }
+}
+
+/**
+ * Whether an event carries usage and nothing a chunk would show.
+ */
+function isUsageOnly(event: CanonicalEvent): boolean {
+    return event.choiceIndex === undefined &&
+        event.role === undefined &&
+        event.contentDelta === undefined &&
+        event.toolCall === undefined &&
+        event.logprobs === undefined &&
+        event.finishReason === undefined;
+}
+
+/**
+ * Builds the chat.completion a stream delivered, for storing.
+ */
+function assembleCompletion(
+    metadata: StreamMetadata,
+    request: CanonicalRequest,
+    choices: Map<number, StreamAccumulator>,
+    usage: Usage | undefined,
+): CanonicalResponse {
+    const first = choices.values().next().value;
+    return {
+        id: metadata.id ?? first?.responseId ?? '',
+        object: 'chat.completion',
+        created: metadata.created ?? Math.floor(Date.now() / 1000),
+        model: first?.model ?? metadata.model ?? request.model,
+        choices: [...choices]
+            .sort(([a], [b]) => a - b)
+            .map(([index, acc]): Choice => {
+                const toolCalls = [...acc.toolCalls.values()].map((call) => ({
+                    id: call.id,
+                    type: 'function' as const,
+                    function: { name: call.name, arguments: call.arguments },
+                }));
+                return {
+                    index,
+                    message: { role: 'assistant', content: acc.content, toolCalls: toolCalls.length > 0 ? toolCalls : undefined },
+                    finishReason: (acc.finishReason ?? 'stop') as FinishReason,
+                    stopSequence: acc.stopSequence,
+                };
+            }),
+        usage: usage ?? { promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+        metadata: request.metadata,
+        sourceAPIType: 'openai',
+    };
+}
+
+/**
+ * A new chat completion ID, for completions the gateway stores.
+ */
+function newCompletionId(): string {
+    return `${CHAT_COMPLETION_ID_PREFIX}${randomUUID().replace(/-/g, '')}`;
+}
+
+/**
+ * One prompt of a legacy completions request and where it is served.
+ */
+interface PromptCall {
+    request: CanonicalRequest;
+    provider: Provider;
+
+    /** Response a pipeline stage returned in place of the provider call. */
+    early?: CanonicalResponse | undefined;
+}
+
+/**
+ * Records the prompt-to-message conversion, with the legacy fields that
+ * have no chat equivalent as warnings.
+ */
+function describeConversion(decoded: DecodedCompletionsRequest): TransformationStep {
+    const warnings: string[] = [];
+    if (decoded.suffix) {
+        warnings.push("Field 'suffix' is not supported by chat models and was ignored");
+    }
+    if (decoded.logprobs !== undefined) {
+        warnings.push("Field 'logprobs' is not supported by chat providers; logprobs are returned as null");
+    }
+    if (decoded.bestOf !== undefined && decoded.bestOf > (decoded.requests[0]?.n ?? 1)) {
+        warnings.push("Field 'best_of' requires logprobs to rank candidates and was ignored");
+    }
+
+    return {
+        stage: 'completions_prompt',
+        timestamp: new Date(),
+        codec: 'completions',
+        description: decoded.prompts.length > 1
+            ? `Converted ${decoded.prompts.length} prompts to one single-message chat request each`
+            : 'Converted prompt to a single-message chat request',
+        details: { prompts: decoded.prompts.length, echo: decoded.echo },
+        warnings: warnings.length > 0 ? warnings : undefined,
+    };
+}
+
+/**
+ * Merges the per-prompt responses into one: choice indexes are numbered
+ * prompt by prompt (prompt i, choice j is i * n + j) and usage is summed.
+ */
+function mergePromptResponses(
+    responses: CanonicalResponse[],
+    decoded: DecodedCompletionsRequest,
+    n: number,
+): CanonicalResponse {
+    const first = responses[0]!;
+    return {
+        ...first,
+        choices: responses.flatMap((response, i) => response.choices.map((c) => ({
+            index: i * n + c.index,
+            message: {
+                role: 'assistant' as const,
+                content: (decoded.echo ? decoded.prompts[i]! : '') + getMessageContent(c.message),
+            },
+            finishReason: c.finishReason,
+        }))),
+        usage: sumUsage(responses.map((r) => r.usage)),
+        sourceAPIType: 'completions',
+    };
+}
+
+/**
+ * Streams the prompts one after another as text deltas, numbering choices
+ * prompt by prompt. Usage is reported once, summed, at the end.
+ */
+async function* streamPrompts(calls: PromptCall[]): AsyncGenerator<CanonicalEvent, void, void> {
+    const usages: Usage[] = [];
+    for (const [i, call] of calls.entries()) {
+        const n = call.request.n ?? 1;
+        let usage: Usage | undefined;
+        const source = call.early ? responseEvents(call.early) : call.provider.stream(call.request);
+        for await (const event of source) {
+            if (event.type === 'done') break;
+            if (event.usage) {
+                usage = event.usage;
+            }
+
+            // Only text and finish reasons have a legacy representation
+            if (event.contentDelta === undefined && event.finishReason === undefined) {
+                continue;
+            }
+            yield {
+                type: event.type,
+                choiceIndex: i * n + (event.choiceIndex ?? 0),
+                contentDelta: event.contentDelta,
+                finishReason: event.finishReason,
+                model: event.model,
+            };
+        }
+        if (usage) {
+            usages.push(usage);
+        }
+    }
+
+    if (usages.length > 0) {
+        yield { type: 'message_delta', usage: sumUsage(usages) };
+    }
+    yield { type: 'done' };
+}
+
+/**
+ * Prefixes each choice's text with its prompt (legacy `echo`).
+ */
+async function* withEcho(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    prompts: string[],
+    n: number,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const echoed = new Set<number>();
+    for await (const event of source) {
+        const index = event.choiceIndex;
+        if (index !== undefined && !echoed.has(index)) {
+            echoed.add(index);
+            yield { type: 'content_delta', choiceIndex: index, contentDelta: prompts[Math.floor(index / n)] ?? '' };
+        }
+        yield event;
+    }
+}
+
+/**
+ * Replays a complete response as stream events.
+ */
+async function* responseEvents(response: CanonicalResponse): AsyncGenerator<CanonicalEvent, void, void> {
+    for (const choice of response.choices) {
+        yield { type: 'content_delta', choiceIndex: choice.index, contentDelta: getMessageContent(choice.message) };
+        yield { type: 'message_stop', choiceIndex: choice.index, finishReason: choice.finishReason ?? 'stop' };
+    }
+    yield { type: 'message_delta', usage: response.usage };
+}
+
 // Export singleton
 export const openAIFrontdoor = new OpenAIFrontdoor();
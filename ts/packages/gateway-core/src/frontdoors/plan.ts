@@ -0,0 +1,168 @@
+/**
+ * Execution planning shared by the frontdoors and the admin console: the
+ * pre-request pipeline, any route override it chooses, the parameter
+ * policy, the routed provider's capability check, adapting developer
+ * messages and assistant prefill to it, and fitting max_tokens to the
+ * model. Everything between a decoded request and the provider
+ * call.
+ *
+ * @module frontdoors/plan
+ */
+
+import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import { APIError, isAPIError } from '../domain/errors.js';
+import type { Provider } from '../ports/provider.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import type { AppliedRoute, StageOutcome } from '../middleware/executor.js';
+import { TimingRecorder } from '../utils/timings.js';
+import type { FrontdoorContext } from './types.js';
+import {
+    checkProviderCapabilities,
+    downgradeToolResultImages,
+    fitMaxTokens,
+    fitThinkingBudget,
+    recordDeveloperMessages,
+} from './models.js';
+import { applyParameterPolicy, resolveParameterPolicy } from '../parampolicy/policy.js';
+import { instructPrefill, trailingPrefill, withPrefillStripped } from '../prefill/prefill.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A decoded request, ready for its provider.
+ */
+export interface ExecutePlan {
+    /** Request as the provider receives it (pipeline modifications applied). */
+    request: CanonicalRequest;
+
+    /** Provider the request is routed to, after any pipeline route override. */
+    provider: Provider;
+
+    /** Metadata shared by the pre- and post-request pipelines. */
+    pipelineMetadata: Map<string, unknown>;
+
+    /** Outcome of each pre-request stage that ran, in order. */
+    stages: StageOutcome[];
+
+    /** Route override chosen by a pipeline stage, if any. */
+    route?: AppliedRoute | undefined;
+
+    /** Early response from a pipeline stage; the provider is not called. */
+    response?: CanonicalResponse | undefined;
+
+    /** Why the request stops here: a pipeline denial, a strict parameter policy, or a capability the provider lacks. */
+    error?: APIError | undefined;
+}
+
+// ============================================================================
+// Planning
+// ============================================================================
+
+/**
+ * Runs the pre-request pipeline on a decoded request, applies any route
+ * override and the parameter policy for the model, checks the routed
+ * provider's capabilities, adapts developer messages and any assistant
+ * prefill to it, and fits max_tokens to the model's output cap.
+ * Steps applied are appended to steps. Never calls the provider.
+ */
+export async function planExecution(
+    ctx: FrontdoorContext,
+    request: CanonicalRequest,
+    steps: TransformationStep[],
+): Promise<ExecutePlan> {
+    const { auth, app, logger, pipeline } = ctx;
+    const timings = ctx.timings ?? new TimingRecorder();
+    const plan: ExecutePlan = {
+        request,
+        provider: ctx.provider,
+        pipelineMetadata: new Map<string, unknown>(),
+        stages: [],
+    };
+    ctx.onDecoded?.(request);
+
+    if (pipeline) {
+        const preResult = await timings.time('prePipelineMs', () => pipeline.runPre({
+            request,
+            tenantId: auth.tenantId,
+            appName: app?.name,
+            interactionId: ctx.interactionId,
+            metadata: plan.pipelineMetadata,
+        }));
+        plan.stages = preResult.stages ?? [];
+
+        if (!preResult.continue) {
+            // Pipeline denied the request or responded early
+            if (preResult.response) {
+                plan.response = preResult.response;
+            } else {
+                plan.error = new APIError('permission', preResult.denyReason ?? 'Request denied by middleware')
+                    .withStatusCode(preResult.denyStatusCode ?? 403);
+            }
+            return plan;
+        }
+
+        // Use potentially modified request
+        if (preResult.request) {
+            plan.request = preResult.request;
+        }
+
+        // Apply a route override chosen by a pipeline stage
+        if (preResult.route) {
+            const routed = preResult.route.provider ? ctx.resolveProvider?.(preResult.route.provider) : undefined;
+            plan.provider = routed ?? plan.provider;
+            plan.route = preResult.route;
+            logger?.info('pipeline_route_applied', {
+                stage: preResult.route.stage,
+                provider: plan.provider.name,
+                model: plan.request.model,
+            });
+        }
+    }
+
+    // Hold the request to its parameter policy, then check the routed
+    // provider can produce what was asked for, within the model's output cap
+    try {
+        const policy = resolveParameterPolicy(app?.parameterPolicy, ctx.parameterPolicies, plan.request.model);
+        const held = applyParameterPolicy(plan.request, policy);
+        if (held) {
+            steps.push(held);
+        }
+        const step = checkProviderCapabilities(plan.request, plan.provider, app);
+        if (step) {
+            steps.push(step);
+        }
+        const moved = downgradeToolResultImages(plan.request, plan.provider);
+        if (moved) {
+            steps.push(moved);
+        }
+        const developer = recordDeveloperMessages(plan.request, plan.provider);
+        if (developer) {
+            steps.push(developer);
+        }
+        // A partial reply the provider is asked to begin with comes back
+        // repeated; strip it so the client gets only the continuation
+        const prefill = trailingPrefill(plan.request);
+        const instructed = instructPrefill(plan.request, plan.provider, app);
+        if (instructed && prefill !== undefined) {
+            steps.push(instructed);
+            plan.provider = withPrefillStripped(plan.provider, prefill);
+        }
+        const fitted = fitMaxTokens(plan.request, ctx.catalog, app);
+        if (fitted) {
+            steps.push(fitted);
+        }
+        const thinking = fitThinkingBudget(plan.request, ctx.catalog);
+        if (thinking) {
+            steps.push(thinking);
+        }
+    } catch (error) {
+        if (!isAPIError(error)) {
+            throw error;
+        }
+        plan.error = error;
+    }
+
+    return plan;
+}
@@ -4,9 +4,19 @@
  * @module frontdoors/responses
  */
 
-import type { Frontdoor, FrontdoorContext, FrontdoorResponse } from './types.js';
+import type { Frontdoor, FrontdoorContext, FrontdoorResponse, FrontdoorRoute } from './types.js';
+import { resolveRequestModel } from './models.js';
 import type { ResponsesAPIRequest } from '../domain/responses.js';
 import { ResponsesHandler } from '../responses/handler.js';
+import { validateResponsesRequest } from '../codecs/validation.js';
+import { RequestBody } from '../ingest/body.js';
+import { parseMessageId } from '../messagededup/dedup.js';
+import {
+    renderTemplate,
+    applyResponsesTemplate,
+    templateMetadata,
+    describeTemplate,
+} from '../templates/prompt.js';
 import { APIError, errServer, errInvalidRequest, errNotFound, toOpenAIError } from '../domain/errors.js';
 
 // ============================================================================
@@ -19,12 +29,25 @@ import { APIError, errServer, errInvalidRequest, errNotFound, toOpenAIError } fr
 class ResponsesFrontdoor implements Frontdoor {
     readonly name = 'responses';
 
+    /** Served at these paths only, whatever the app's base path. */
+    readonly routes: readonly FrontdoorRoute[] = [
+        { method: 'POST', path: '/v1/responses', absolute: true },
+        { method: 'GET', path: '/v1/responses', absolute: true },
+        { method: 'GET', path: '/v1/responses/:id', absolute: true },
+        { method: 'POST', path: '/v1/responses/:id/cancel', absolute: true },
+        { method: 'POST', path: '/v1/threads', absolute: true },
+        { method: 'GET', path: '/v1/threads/:id', absolute: true },
+        { method: 'POST', path: '/v1/threads/:id/messages', absolute: true },
+        { method: 'GET', path: '/v1/threads/:id/messages', absolute: true },
+        { method: 'POST', path: '/v1/threads/:id/runs', absolute: true },
+    ];
+
     matches(path: string): boolean {
         return path.startsWith('/v1/responses') || path.startsWith('/v1/threads');
     }
 
     async handle(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
-        const { request, provider, auth, logger, app, storage } = ctx;
+        const { request, provider, auth, logger, app, storage, catalog } = ctx;
         const url = new URL(request.url);
         const path = url.pathname;
         const method = request.method;
@@ -38,6 +61,16 @@ class ResponsesFrontdoor implements Frontdoor {
             storage,
             provider,
             logger,
+            replay: ctx.replay,
+            catalog,
+            streamThrottle: app?.streamThrottle,
+            timings: ctx.timings,
+            upstreamHeaders: ctx.upstreamHeaders,
+            onUsage: ctx.onUsage,
+            interactionId: ctx.interactionId,
+            events: ctx.events,
+            compact: ctx.compactConversation,
+            messageDedup: ctx.messageDedup,
         });
 
         try {
@@ -47,32 +80,32 @@ class ResponsesFrontdoor implements Frontdoor {
 
             // POST /v1/responses - Create response
             if (method === 'POST' && path === '/v1/responses') {
-                const body = await request.json() as ResponsesAPIRequest;
-
-                // Validate required fields
-                if (!body.model) {
-                    return this.errorResponse(errInvalidRequest('model is required'));
+                const raw = (ctx.body ?? new RequestBody(await request.text())).json();
+                const unknownFields = validateResponsesRequest(raw);
+                if (unknownFields.length > 0) {
+                    logger?.debug('unmapped_request_fields', { fields: unknownFields });
                 }
-                if (!body.input) {
-                    return this.errorResponse(errInvalidRequest('input is required'));
+                const { template: templateRef, ...body } = raw as ResponsesAPIRequest & { template?: unknown };
+                const transformations = resolveRequestModel(body, app, ctx.modelRewrite);
+
+                // Render a gateway-managed prompt template
+                let metadata: Record<string, string> | undefined;
+                if (templateRef != null) {
+                    const template = renderTemplate(templateRef, ctx.templates);
+                    applyResponsesTemplate(body, template);
+                    transformations.push(describeTemplate(template));
+                    metadata = templateMetadata(template);
                 }
+                handler.checkCapabilities(body, auth.tenantId);
+                ctx.onDecoded?.(body);
 
                 // Check if streaming is requested
                 if (body.stream) {
                     // Streaming response
-                    const sseStream = this.createSSEStream(
-                        handler.handleStream(body, auth.tenantId, app?.name),
-                    );
-
                     return {
-                        response: new Response(sseStream, {
-                            status: 200,
-                            headers: {
-                                'Content-Type': 'text/event-stream',
-                                'Cache-Control': 'no-cache',
-                                'Connection': 'keep-alive',
-                            },
-                        }),
+                        ...this.sseResponse(handler.handleStream(body, auth.tenantId, app?.name)),
+                        metadata,
+                        transformations,
                     };
                 }
 
@@ -84,6 +117,9 @@ class ResponsesFrontdoor implements Frontdoor {
                         status: 200,
                         headers: { 'Content-Type': 'application/json' },
                     }),
+                    recorded: handler.exchange,
+                    metadata,
+                    transformations,
                 };
             }
 
@@ -104,10 +140,31 @@ class ResponsesFrontdoor implements Frontdoor {
                 };
             }
 
+            // GET /v1/responses/:id?stream=true - Resume a stream after Last-Event-ID
+            if (
+                method === 'GET' &&
+                path.match(/^\/v1\/responses\/resp_[a-zA-Z0-9]+$/) &&
+                url.searchParams.get('stream') === 'true'
+            ) {
+                const responseId = path.split('/').pop()!;
+                const lastEventId = parseInt(request.headers.get('Last-Event-ID') ?? '0', 10);
+                const stream = await handler.resumeStream(
+                    responseId,
+                    auth.tenantId,
+                    Number.isFinite(lastEventId) && lastEventId > 0 ? lastEventId : 0,
+                );
+
+                if (!stream) {
+                    return this.errorResponse(errNotFound(`Response '${responseId}' not found`));
+                }
+
+                return this.sseResponse(stream);
+            }
+
             // GET /v1/responses/:id - Get response
             if (method === 'GET' && path.match(/^\/v1\/responses\/resp_[a-zA-Z0-9]+$/)) {
                 const responseId = path.split('/').pop()!;
-                const response = await handler.get(responseId);
+                const response = await handler.get(responseId, auth.tenantId);
 
                 if (!response) {
                     return this.errorResponse(errNotFound(`Response '${responseId}' not found`));
@@ -178,17 +235,19 @@ class ResponsesFrontdoor implements Frontdoor {
             // POST /v1/threads/:id/messages - Create message
             if (method === 'POST' && path.match(/^\/v1\/threads\/thread_[a-zA-Z0-9]+\/messages$/)) {
                 const threadId = path.split('/')[3]!;
-                const body = await request.json() as { role?: 'user' | 'assistant'; content: string };
+                const body = await request.json() as { id?: unknown; role?: 'user' | 'assistant'; content: string };
 
                 if (!body.content) {
                     return this.errorResponse(errInvalidRequest('content is required'));
                 }
 
+                // A client-supplied ID makes retrying the append safe
                 const message = await handler.createMessage(
                     threadId,
                     auth.tenantId,
                     body.role ?? 'user',
                     body.content,
+                    parseMessageId(body.id, 'id'),
                 );
 
                 if (!message) {
@@ -208,6 +267,10 @@ class ResponsesFrontdoor implements Frontdoor {
                 const threadId = path.split('/')[3]!;
                 const messages = await handler.listMessages(threadId, auth.tenantId);
 
+                if (!messages) {
+                    return this.errorResponse(errNotFound(`Thread '${threadId}' not found`));
+                }
+
                 return {
                     response: new Response(
                         JSON.stringify({
@@ -228,9 +291,14 @@ class ResponsesFrontdoor implements Frontdoor {
                 const body = await request.json().catch(() => ({})) as {
                     model?: string;
                     instructions?: string;
+                    message_id?: unknown;
                 };
 
-                const response = await handler.createRun(threadId, auth.tenantId, body);
+                const response = await handler.createRun(threadId, auth.tenantId, {
+                    model: body.model,
+                    instructions: body.instructions,
+                    messageId: parseMessageId(body.message_id, 'message_id'),
+                });
 
                 if (!response) {
                     return this.errorResponse(errNotFound(`Thread '${threadId}' not found`));
@@ -268,6 +336,19 @@ class ResponsesFrontdoor implements Frontdoor {
         };
     }
 
+    private sseResponse(generator: AsyncGenerator<string>): FrontdoorResponse {
+        return {
+            response: new Response(this.createSSEStream(generator), {
+                status: 200,
+                headers: {
+                    'Content-Type': 'text/event-stream',
+                    'Cache-Control': 'no-cache',
+                    'Connection': 'keep-alive',
+                },
+            }),
+        };
+    }
+
     /**
      * Creates a ReadableStream from an async generator of SSE strings.
      */
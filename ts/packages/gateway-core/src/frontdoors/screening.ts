@@ -0,0 +1,288 @@
+/**
+ * Post-request pipeline screening shared by the frontdoors.
+ *
+ * Post stages see the provider's response before the client does and may
+ * replace or deny it. A denial is an HTTP error by default. With on_deny
+ * (per app, or per stage) the client instead gets a well-formed response
+ * carrying the configured content, with finish_reason content_filter, so
+ * a chat product shows a message rather than a failure. The real deny
+ * reason is kept in the interaction's metadata, and the denied response
+ * in a response_denied event; the rendered response keeps the provider's
+ * raw body and usage.
+ *
+ * Streams reach post stages only when a denial would be rendered: the
+ * stream is collected, screened, and the result replayed as a stream.
+ *
+ * @module frontdoors/screening
+ */
+
+import type {
+    CanonicalEvent,
+    CanonicalRequest,
+    CanonicalResponse,
+    FinishReason,
+    Usage,
+} from '../domain/types.js';
+import { getMessageContent } from '../domain/types.js';
+import { APIError } from '../domain/errors.js';
+import { createInteractionEvent } from '../domain/events.js';
+import type { DenyResponseConfig } from '../ports/config.js';
+import { accumulateEvent, createStreamAccumulator, type StreamAccumulator } from '../utils/streaming.js';
+import { TimingRecorder } from '../utils/timings.js';
+import type { FrontdoorContext } from './types.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Content of a rendered denial without configured content. */
+export const DEFAULT_DENY_CONTENT = "I can't help with that request.";
+
+/** `{{variable}}` placeholders in denial templates. */
+const PLACEHOLDER = /\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}/g;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A response after the post-request pipeline.
+ */
+export interface ScreenedResponse {
+    /** Response for the client: the provider's, a stage's replacement, or a rendered denial. */
+    response: CanonicalResponse;
+
+    /** Set when a denial is sent as an HTTP error instead. */
+    error?: APIError | undefined;
+
+    /** Interaction metadata for a rendered denial (deny_reason, denied_by). */
+    metadata?: Record<string, string> | undefined;
+}
+
+/**
+ * Values a denial template can use.
+ */
+export interface DenialValues {
+    /** Deny reason from the stage. */
+    reason: string;
+
+    /** Stage that denied. */
+    stage: string;
+
+    /** Requested model. */
+    model: string;
+}
+
+// ============================================================================
+// Screening
+// ============================================================================
+
+/**
+ * Runs a response through the app's post-request pipeline.
+ */
+export async function screenResponse(
+    ctx: FrontdoorContext,
+    request: CanonicalRequest,
+    response: CanonicalResponse,
+    pipelineMetadata: Map<string, unknown>,
+): Promise<ScreenedResponse> {
+    const { pipeline, app, auth, logger } = ctx;
+    if (!pipeline) {
+        return { response };
+    }
+
+    const timings = ctx.timings ?? new TimingRecorder();
+    const result = await timings.time('postPipelineMs', () => pipeline.runPost({
+        request,
+        response,
+        tenantId: auth.tenantId,
+        appName: app?.name,
+        interactionId: ctx.interactionId,
+        metadata: pipelineMetadata,
+    }));
+    if (result.continue || result.response || !result.denyReason) {
+        // Passed, modified, or replaced by a stage
+        return { response: result.response ?? response };
+    }
+
+    const onDeny = result.deniedBy?.onDeny ?? app?.onDeny;
+    if (!onDeny || onDeny.mode === 'error') {
+        return {
+            response,
+            error: new APIError('permission', result.denyReason).withStatusCode(result.denyStatusCode ?? 403),
+        };
+    }
+
+    const reason = result.denyReason;
+    const stage = result.deniedBy?.name ?? '';
+    logger?.info('response_denied', { stage, reason });
+    if (ctx.events) {
+        const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...denied } = response;
+        await ctx.events.saveEvent(createInteractionEvent('response_denied', ctx.interactionId, {
+            stage,
+            reason,
+            response: denied,
+        })).catch((err: unknown) => {
+            logger?.warn('response_denied_event_failed', {
+                error: err instanceof Error ? err.message : String(err),
+            });
+        });
+    }
+    return {
+        response: denialResponse(onDeny, response, { reason, stage, model: request.model }),
+        metadata: { deny_reason: reason, denied_by: stage },
+    };
+}
+
+/**
+ * Whether the app's streams are screened: only when it has post stages
+ * and a denial would be rendered rather than sent as an error, since
+ * screening holds the stream back until the provider finishes.
+ */
+export function screensStreams(ctx: FrontdoorContext): boolean {
+    const { pipeline, app } = ctx;
+    if (!pipeline?.hasPostStages) {
+        return false;
+    }
+    const rendered = (onDeny: DenyResponseConfig | undefined): boolean => onDeny !== undefined && onDeny.mode !== 'error';
+    return rendered(app?.onDeny) ||
+        (app?.pipeline?.stages ?? []).some((stage) => stage.type === 'post' && rendered(stage.onDeny));
+}
+
+// ============================================================================
+// Denial Rendering
+// ============================================================================
+
+/**
+ * Builds the response sent in place of a denied one: a single choice with
+ * the configured content and finish_reason content_filter. Everything else
+ * (ID, usage, the provider's raw body) is the denied response's.
+ */
+export function denialResponse(
+    onDeny: DenyResponseConfig,
+    denied: CanonicalResponse,
+    values: DenialValues,
+): CanonicalResponse {
+    return {
+        ...denied,
+        choices: [{
+            index: 0,
+            message: { role: 'assistant', content: renderDenial(onDeny, values) },
+            finishReason: 'content_filter',
+        }],
+    };
+}
+
+/**
+ * Renders a denial's content. The reason only reaches the client with
+ * include_reason.
+ */
+export function renderDenial(onDeny: DenyResponseConfig, values: DenialValues): string {
+    const content = onDeny.content ?? DEFAULT_DENY_CONTENT;
+    if (onDeny.mode === 'template') {
+        const known = new Map([
+            ['reason', onDeny.includeReason ? values.reason : ''],
+            ['stage', values.stage],
+            ['model', values.model],
+        ]);
+        return content.replace(PLACEHOLDER, (match, name: string) => known.get(name) ?? match);
+    }
+    return onDeny.includeReason ? `${content} (${values.reason})` : content;
+}
+
+// ============================================================================
+// Stream Collection and Replay
+// ============================================================================
+
+/**
+ * Collects a stream into the response it delivered. Thinking deltas are
+ * not kept. Throws if the stream reports an error.
+ */
+export async function collectResponse(
+    events: AsyncIterable<CanonicalEvent>,
+    model: string,
+): Promise<CanonicalResponse> {
+    const choices = new Map<number, StreamAccumulator>();
+    let usage: Usage | undefined;
+    for await (const event of events) {
+        if (event.type === 'done') {
+            break;
+        }
+        if (event.type === 'error' && event.error) {
+            throw event.error;
+        }
+        if (event.usage) {
+            usage = event.usage;
+        }
+        const index = event.choiceIndex ?? 0;
+        const acc = choices.get(index) ?? createStreamAccumulator();
+        accumulateEvent(acc, event);
+        choices.set(index, acc);
+    }
+
+    const first = choices.get(0);
+    return {
+        id: first?.responseId ?? '',
+        object: 'chat.completion',
+        created: Math.floor(Date.now() / 1000),
+        model: first?.model ?? model,
+        choices: [...choices]
+            .sort(([a], [b]) => a - b)
+            .map(([index, acc]) => ({
+                index,
+                message: {
+                    role: 'assistant' as const,
+                    content: acc.content,
+                    toolCalls: acc.toolCalls.size > 0
+                        ? [...acc.toolCalls.values()].map((call) => ({
+                            id: call.id,
+                            type: 'function' as const,
+                            function: { name: call.name, arguments: call.arguments },
+                        }))
+                        : undefined,
+                },
+                finishReason: (acc.finishReason ?? 'stop') as FinishReason,
+                stopSequence: acc.stopSequence,
+            })),
+        usage: usage ?? { promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+    };
+}
+
+/**
+ * Replays a complete response as a stream: a start event, a block per
+ * choice's text and per tool call, each choice's stop, and done.
+ */
+export async function* responseStream(response: CanonicalResponse): AsyncGenerator<CanonicalEvent, void, void> {
+    yield { type: 'message_start', role: 'assistant', model: response.model, responseId: response.id };
+    for (const [n, choice] of response.choices.entries()) {
+        const choiceIndex = choice.index;
+        let index = 0;
+        const text = getMessageContent(choice.message);
+        if (text) {
+            yield { type: 'content_block_start', choiceIndex, index, contentBlock: { type: 'text', text: '' } };
+            yield { type: 'content_block_delta', choiceIndex, index, contentDelta: text };
+            yield { type: 'content_block_stop', choiceIndex, index };
+            index++;
+        }
+        for (const [i, call] of (choice.message.toolCalls ?? []).entries()) {
+            yield {
+                type: 'content_block_start',
+                choiceIndex,
+                index,
+                toolCall: { index: i, id: call.id, type: 'function', function: { name: call.function.name, arguments: '' } },
+            };
+            yield { type: 'content_block_delta', choiceIndex, index, toolCall: { index: i, function: { arguments: call.function.arguments } } };
+            yield { type: 'content_block_stop', choiceIndex, index };
+            index++;
+        }
+        yield {
+            type: 'message_stop',
+            choiceIndex,
+            finishReason: choice.finishReason ?? 'stop',
+            stopSequence: choice.stopSequence,
+            // Usage once, on the last choice
+            usage: n === response.choices.length - 1 ? response.usage : undefined,
+        };
+    }
+    yield { type: 'done' };
+}
@@ -4,13 +4,27 @@
  * @module frontdoors/types
  */
 
-import type { CanonicalRequest, CanonicalResponse, CanonicalEvent } from '../domain/types.js';
+import type { CanonicalRequest, CanonicalResponse, CanonicalEvent, Message, UpstreamHeaderSet, Usage } from '../domain/types.js';
 import type { Provider } from '../ports/provider.js';
 import type { AuthContext } from '../ports/auth.js';
-import type { AppConfig } from '../ports/config.js';
-import type { StorageProvider } from '../ports/storage.js';
+import type { AppConfig, ModelParameterPolicyConfig } from '../ports/config.js';
+import type { InteractionStore, StorageProvider } from '../ports/storage.js';
 import type { PipelineExecutor } from '../middleware/executor.js';
 import type { Logger } from '../utils/logging.js';
+import type { ModelCatalog } from '../domain/catalog.js';
+import type { TimingRecorder } from '../utils/timings.js';
+import type { WarningCollector } from '../warnings/collector.js';
+import type { GatewayTool } from '../tools/types.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import type { PromptTemplates } from '../templates/prompt.js';
+import type { MessageBatches } from '../batches/batches.js';
+import type { StreamReplayBuffer } from '../responses/replay.js';
+import type { AppMessageDedup } from '../messagededup/dedup.js';
+import type { EndpointPassthrough } from '../passthrough/endpoints.js';
+import type { ProviderSelection } from '../router.js';
+import type { Conversation } from '../summarization/summarizer.js';
+import type { RequestBudget } from '../callbudget/budget.js';
+import type { RequestBody } from '../ingest/body.js';
 
 // ============================================================================
 // Frontdoor Interface
@@ -23,6 +37,9 @@ export interface FrontdoorContext {
     /** The incoming request. */
     request: Request;
 
+    /** The request's body, as the gateway read it (its parse is shared). */
+    body?: RequestBody | undefined;
+
     /** The selected provider to use. */
     provider: Provider;
 
@@ -32,6 +49,12 @@ export interface FrontdoorContext {
     /** App configuration (if matched to an app). */
     app?: AppConfig | undefined;
 
+    /** Model the app's routing rewrites the requested model to, if any. */
+    modelRewrite?: string | undefined;
+
+    /** Prompt templates requests may reference, from the current config. */
+    templates?: PromptTemplates | undefined;
+
     /** Storage provider (optional, needed for Responses API). */
     storage?: StorageProvider | undefined;
 
@@ -41,8 +64,65 @@ export interface FrontdoorContext {
     /** Interaction ID for tracing. */
     interactionId: string;
 
+    /** Interaction event log, applying the request's recording decision. */
+    events?: Pick<InteractionStore, 'saveEvent'> | undefined;
+
+    /**
+     * Called once the request is decoded and its model resolved, before
+     * the provider is called: marks the interaction in_progress.
+     */
+    onDecoded?: ((request: { model: string; stream?: boolean | undefined }) => void) | undefined;
+
     /** Pipeline executor for middleware (optional). */
     pipeline?: PipelineExecutor | undefined;
+
+    /** Model catalog for capability checks (optional). */
+    catalog?: ModelCatalog | undefined;
+
+    /** The gateway's per-model parameter policies, over the app's. */
+    parameterPolicies?: ModelParameterPolicyConfig[] | undefined;
+
+    /** Routes a model as this request's app and tenant would, for batch entries. */
+    routeModel?: ((model: string) => ProviderSelection) | undefined;
+
+    /** Message batch passthrough (anthropic frontdoor). */
+    batches?: MessageBatches | undefined;
+
+    /** The gateway's buffered Responses stream events, for Last-Event-ID resume. */
+    replay?: StreamReplayBuffer | undefined;
+
+    /** Forwards endpoints the frontdoor doesn't serve (anthropic frontdoor, apps with passthrough on). */
+    passthrough?: EndpointPassthrough | undefined;
+
+    /** Looks up a configured provider by name, for pipeline route overrides. */
+    resolveProvider?: ((name: string) => Provider | undefined) | undefined;
+
+    /** Per-phase timing recorder for this request (optional). */
+    timings?: TimingRecorder | undefined;
+
+    /** Upstream headers resolved from app and provider header rules. */
+    upstreamHeaders?: UpstreamHeaderSet | undefined;
+
+    /** Tools the gateway executes for this app (non-streaming requests only). */
+    gatewayTools?: GatewayTool[] | undefined;
+
+    /** The request's outbound call budget, consulted before automatic provider calls. */
+    callBudget?: RequestBudget | undefined;
+
+    /**
+     * Reports usage that isn't in the returned canonicalResponse (streams,
+     * Responses API), once it is known.
+     */
+    onUsage?: ((model: string, usage: Usage) => void) | undefined;
+
+    /** Collects warnings for the client about changes made on its behalf (optional). */
+    warnings?: WarningCollector | undefined;
+
+    /** Compacts long reconstructed conversations (Responses API, apps with thread summaries). */
+    compactConversation?: ((conversation: Conversation) => Promise<Message[]>) | undefined;
+
+    /** The app's duplicate checks for thread appends (Responses API). */
+    messageDedup?: AppMessageDedup | undefined;
 }
 
 /**
@@ -57,6 +137,41 @@ export interface FrontdoorResponse {
 
     /** The canonical response (for non-streaming). */
     canonicalResponse?: CanonicalResponse | undefined;
+
+    /**
+     * Bodies for interaction_completed, from frontdoors that account usage
+     * themselves and so leave canonicalRequest and canonicalResponse unset.
+     */
+    recorded?: { request: CanonicalRequest; response?: CanonicalResponse | undefined } | undefined;
+
+    /** Interaction metadata noted while handling (e.g., effective stream throttle). */
+    metadata?: Record<string, string> | undefined;
+
+    /** Conversions applied by the frontdoor beyond a plain decode, for interaction recording. */
+    transformations?: TransformationStep[] | undefined;
+}
+
+/**
+ * Combines interaction metadata parts, or returns undefined if there are none.
+ */
+export function mergeMetadata(
+    ...parts: Array<Record<string, string> | undefined>
+): Record<string, string> | undefined {
+    const present = parts.filter((p): p is Record<string, string> => p !== undefined);
+    return present.length > 0 ? Object.assign({}, ...present) : undefined;
+}
+
+/**
+ * A method and path a frontdoor serves. The path is matched at the end of
+ * the request path under the app's base path, with any leading /v1
+ * optional; `:name` segments match any one segment.
+ */
+export interface FrontdoorRoute {
+    method: string;
+    path: string;
+
+    /** Served at exactly this path, whatever the app's base path. */
+    absolute?: boolean | undefined;
 }
 
 /**
@@ -66,6 +181,13 @@ export interface Frontdoor {
     /** Frontdoor name. */
     readonly name: string;
 
+    /**
+     * Routes this frontdoor serves. Requests for other paths or methods
+     * under its apps are answered by the gateway; frontdoors without
+     * routes get every request under their apps.
+     */
+    readonly routes?: readonly FrontdoorRoute[] | undefined;
+
     /**
      * Checks if this frontdoor handles the given path.
      */
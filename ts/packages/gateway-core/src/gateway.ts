@@ -8,24 +8,269 @@ import type {
     ConfigProvider,
     GatewayConfig,
     AppConfig,
+    AppMiddlewareConfig,
     ProviderConfig,
+    PipelineStageConfig,
+    EventsConfig,
+    SpillConfig,
+    TokenCountConfig,
+    ConcurrencyConfig,
+    ThreadSummaryConfig,
+    ResponseClassificationConfig,
+    SloConfig,
+    RoutingConfig,
 } from './ports/config.js';
 import { isWatchableConfigProvider } from './ports/config.js';
 import type { AuthProvider, AuthContext } from './ports/auth.js';
 import { extractBearerToken } from './ports/auth.js';
-import type { StorageProvider } from './ports/storage.js';
+import type {
+    StorageProvider,
+    BatchRecord,
+    MetadataIndexStore,
+    UsageStore,
+    UsageStatsStore,
+    RequestStatClassificationFilter,
+    AttemptStore,
+    ThreadStateStore,
+    InteractionRecord,
+} from './ports/storage.js';
 import type { EventPublisher } from './ports/events.js';
-import type { Provider, ProviderRegistry } from './ports/provider.js';
+import type { Provider, ProviderRegistry, ProviderHTTPClient, ProviderCredentials, ProviderCallOptions } from './ports/provider.js';
 import { createProviderRegistry } from './ports/provider.js';
-import type { Frontdoor, FrontdoorRegistry, FrontdoorContext } from './frontdoors/types.js';
-import { createFrontdoorRegistry, openAIFrontdoor, anthropicFrontdoor } from './frontdoors/index.js';
+import type { Frontdoor, FrontdoorRegistry, FrontdoorContext, FrontdoorResponse } from './frontdoors/types.js';
+import {
+    createFrontdoorRegistry,
+    openAIFrontdoor,
+    anthropicFrontdoor,
+    responsesFrontdoor,
+    cohereFrontdoor,
+} from './frontdoors/index.js';
+import { resolveRequestModel, clampedMaxTokens, MAX_TOKENS_CLAMPED_HEADER } from './frontdoors/models.js';
+import { planExecution } from './frontdoors/plan.js';
 import { createOpenAIProvider } from './providers/openai.js';
 import { createAnthropicProvider } from './providers/anthropic.js';
-import { Router, stripAppPrefix } from './router.js';
-import { APIError, errAuthentication, errNotFound, errServer, toOpenAIError } from './domain/errors.js';
+import { withMultiChoice } from './providers/multichoice.js';
+import {
+    withDeadline,
+    clientAborted,
+    CLIENT_CLOSED_STATUS,
+    ClientAborts,
+    DeadlineCancellations,
+    type ClientAbortStats,
+    type DeadlineCancellationStats,
+} from './providers/deadline.js';
+import { ModelListCache, withModelCache, DEFAULT_MODEL_LIST_TTL_MS } from './providers/models.js';
+import { KeyPool, DEFAULT_KEY_COOLDOWN_MS } from './providers/keys.js';
+import { validateProviderVersioning, providerVersioning, versioningMetadata } from './providers/versions.js';
+import { createExecutor, type PipelineExecutor, type StageOutcome } from './middleware/executor.js';
+import { checkStageConditions } from './middleware/conditions.js';
+import { createWebhookStep } from './middleware/steps/webhook.js';
+import { DEFAULT_STAGE_CACHE_TTL_MS, type StageCacheKeyField } from './middleware/cache.js';
+import {
+    Router,
+    stripAppPrefix,
+    selectionOf,
+    routingStep,
+    EXPLAIN_HEADER,
+    ROUTING_HEADER,
+    ROUTING_BODY_KEY,
+    type ProviderSelection,
+    type RoutingDecision,
+    type RoutingTestQuery,
+} from './router.js';
+import { asciiJSON, embedExtension } from './utils/extensions.js';
+import { ModelCatalog } from './domain/catalog.js';
+import type { APIType, CanonicalRequest, CanonicalResponse, Message, Usage } from './domain/types.js';
+import {
+    createInteractionEvent,
+    createLifecycleEvent,
+    type InteractionTimings,
+    type InteractionCompletedData,
+} from './domain/events.js';
+import {
+    APIError,
+    errAuthentication,
+    errBudgetExceeded,
+    errProviderPolicyDenied,
+    errInvalidRequest,
+    errNotFound,
+    errRateLimit,
+    errServer,
+    isAPIError,
+    toOpenAIError,
+} from './domain/errors.js';
 import type { Logger } from './utils/logging.js';
-import { ConsoleLogger, requestLogger } from './utils/logging.js';
-import { randomUUID } from './utils/crypto.js';
+import { ConsoleLogger, requestLogger, type LogLevel } from './utils/logging.js';
+import { LogControl, ControlledLogger } from './logging/control.js';
+import { randomUUID, sha256 } from './utils/crypto.js';
+import { parseDuration } from './utils/duration.js';
+import { TimingRecorder, LatencyStats, type LatencySummary } from './utils/timings.js';
+import { resolveUpstreamHeaders } from './utils/headers.js';
+import { getRequestContext, type HttpMiddleware } from './http/middleware.js';
+import { corsMiddleware, validateCors } from './http/cors.js';
+import { buildAppMiddleware, createHttpMiddlewareRegistry, type HttpMiddlewareRegistry } from './http/registry.js';
+import { createToolRegistry, type GatewayTool, type ToolRegistry } from './tools/types.js';
+import { calculatorTool, createHTTPTool } from './tools/builtin.js';
+import {
+    BudgetAccountant,
+    BUDGET_REMAINING_HEADER,
+    describeExceeded,
+    formatRemaining,
+    type BudgetStatus,
+} from './budget/accountant.js';
+import { MemoryUsageStore, isUsageStore } from './budget/store.js';
+import { createEventSink, type EventSink } from './analytics/sink.js';
+import { createSinkEventPublisher, type SinkEventPublisher, type EventSinkStats } from './analytics/publisher.js';
+import { shapeInteractionData } from './analytics/payload.js';
+import { RequestMirror, type MirrorStats } from './mirror/mirror.js';
+import { TenantRegistry, isTenantStore } from './tenants/registry.js';
+import { isMigratableStore } from './migrations/runner.js';
+import {
+    MessageBatches,
+    AnthropicBatchClient,
+    MemoryBatchStore,
+    isBatchStore,
+    batchRoutingModel,
+    BATCH_PRICE_FACTOR,
+    type MessageBatchResult,
+} from './batches/index.js';
+import { EndpointPassthrough, type PassthroughTarget } from './passthrough/index.js';
+import {
+    INTERACTION_ID_HEADER,
+    MemoryMetadataIndex,
+    isMetadataIndexStore,
+    captureCorrelation,
+    validateCorrelationHeaders,
+} from './correlation/index.js';
+import { UsageReports, USAGE_REPORT_PATH } from './usage/report.js';
+import { MemoryUsageStatsStore, isUsageStatsStore } from './usage/store.js';
+import { MemoryAttemptStore, isAttemptStore } from './usage/attempts.js';
+import { NO_TRAILER_HEADER, appendUsageTrailer, buildUsageTrailer, isEventStream } from './usage/trailer.js';
+import {
+    WarningCollector,
+    WARNINGS_HEADER,
+    appendWarningsEvent,
+    embedWarnings,
+    formatWarningsHeader,
+} from './warnings/collector.js';
+import {
+    FINISH_REASON_METADATA_KEY,
+    TRUNCATED_MESSAGE,
+    TRUNCATED_WARNING,
+    withTruncationWatch,
+} from './warnings/truncation.js';
+import {
+    UsageDiscrepancies,
+    USAGE_DISCREPANCY_METADATA_KEY,
+    resolveUsageCheck,
+    withUsageCheck,
+    type UsageComparison,
+    type UsageDiscrepancyStats,
+} from './usagecheck/check.js';
+import {
+    interleaveToolArgumentEvents,
+    withToolArgumentWatch,
+    type ToolArgumentFieldEvent,
+} from './toolargs/stream.js';
+import {
+    EVENT_TRUNCATED_WARNING,
+    withEventSizeLimit,
+    type TruncatedEvent,
+} from './eventsize/provider.js';
+import {
+    WriteSpill,
+    writeSpillEntry,
+    DEFAULT_SPILL_RETRY_MS,
+    type SpillEntry,
+    type SpillReplayResult,
+    type SpillStats,
+    type SpillStorage,
+    type SpillTargets,
+} from './spill/queue.js';
+import { ProviderProber, type ProbeReport, type ProbeTarget } from './probe/prober.js';
+import { StorageKeyring } from './encryption/keyring.js';
+import { withEncryption } from './encryption/storage.js';
+import { StorageHealth, withStorageHealth, probeStorage, type StorageHealthStats } from './storagehealth/index.js';
+import { dualWriteOf, dualWriteOptions, type DualWriteStorage } from './dualwrite/index.js';
+import {
+    ClassificationWorker,
+    ModerationClassifier,
+    classificationMetadata,
+    languageClassifier,
+    withTextCapture,
+    type ClassificationStats,
+    type ModerationTarget,
+    type ResponseClassifier,
+} from './classification/index.js';
+import { rewrapSensitiveValues, isSensitiveValueStore, type RewrapResult } from './encryption/rewrap.js';
+import {
+    CONSOLE_ORIGIN,
+    CONSOLE_TENANT,
+    consoleCodec,
+    encodeProviderRequest,
+    withoutRaw,
+    withoutRawResponse,
+    type ConsoleRequest,
+    type ConsoleResult,
+} from './console/execute.js';
+import { MemoryProbeStore, isProbeStore } from './probe/store.js';
+import { SloTracker, MemorySloStore, isSloStore, type SloReport } from './slo/index.js';
+import { InteractionLifecycle, isInteractionRecordStore } from './interactions/index.js';
+import { SecretManager, SecretCredentials, MemorySecretStore, isSecretStore } from './secrets/index.js';
+import { BodyBufferPool, RequestBody, readRequestBody, hasTextBody } from './ingest/index.js';
+import { defaultCodecRegistry } from './codecs/index.js';
+import { TokenCounter, tokenCountResult, TOKEN_COUNT_SUFFIX } from './tokens/count.js';
+import { EndUserHasher, withEndUser } from './enduser/provider.js';
+import { ModelMigrationJobs } from './modelmigration/jobs.js';
+import { BpeTokenizer, parseTiktokenRanks } from './tokens/bpe.js';
+import { EXACT_TOKENIZER_FAMILIES, familyPattern, type Tokenizer, type TokenizerFamily } from './tokens/tokenizer.js';
+import {
+    TenantScheduler,
+    DEFAULT_QUEUE_TIMEOUT_MS,
+    type ConcurrencyLimitError,
+    type ConcurrencySlot,
+    type ConcurrencyStats,
+    type TenantConcurrencyLimits,
+} from './concurrency/scheduler.js';
+import { withConcurrencyLimit } from './concurrency/provider.js';
+import { classifyPriority } from './concurrency/priority.js';
+import { RequestCoalescer, type CoalescingStats } from './coalescing/coalescer.js';
+import { withCoalescing } from './coalescing/provider.js';
+import { MessageDedupCounter, type MessageDedupStats } from './messagededup/dedup.js';
+import { ConversationSummarizer, summaryPrompt, type Conversation } from './summarization/summarizer.js';
+import { RouteTable, type RegisteredRoute } from './routes/table.js';
+import { UnmatchedRoutes, unmatchedResponse, type UnmatchedRouteStats } from './routes/unmatched.js';
+import { StreamReplayBuffer } from './responses/replay.js';
+import {
+    ThreadAffinity,
+    threadKeysOf,
+    responseThreadKey,
+    affinityStateKey,
+    threadStateIndexKey,
+    DEFAULT_AFFINITY_TTL_MS,
+    THREAD_STATE_TOUCHED,
+    type ThreadStateTouch,
+} from './affinity/affinity.js';
+import { loadPromptTemplates, type PromptTemplate, type PromptTemplates } from './templates/prompt.js';
+import { compileTransforms, withTransforms, type ResponseTransform } from './transforms/response.js';
+import { withJsonValidation, type JsonValidationOutcome } from './jsonoutput/validation.js';
+import { ADMIN_OPERATOR_SCOPES, type ProviderHealthSummary } from './admin/handler.js';
+import type { TransformationStep } from './recorder/interaction.js';
+import { InteractionSampler, recordingMetadata, type RecordingDecision } from './recorder/sampling.js';
+import { withStreamRecording } from './recorder/stream.js';
+import { AttemptRecorder, withAttemptRecording } from './recorder/attempts.js';
+import { CallBudgetExhaustions, RequestBudget, resolveCallBudget, withCallBudget, type CallBudgetStats } from './callbudget/index.js';
+import { InteractionTails } from './tail/index.js';
+import { checkParameterPolicies, summarizeParameterPolicies, type ParameterPolicySummary } from './parampolicy/index.js';
+import type { IdempotencyStore } from './ports/storage.js';
+import {
+    IdempotencyManager,
+    MemoryIdempotencyStore,
+    isIdempotencyStore,
+    isIdempotentEndpoint,
+    IDEMPOTENCY_KEY_HEADER,
+    DEFAULT_IDEMPOTENCY_TTL_MS,
+} from './idempotency/index.js';
 
 // ============================================================================
 // Gateway Options
@@ -47,9 +292,12 @@ export interface GatewayOptions {
     /** Event publisher. */
     events?: EventPublisher | undefined;
 
-    /** Logger. */
+    /** Logger. Receives every level; filtering is done by Gateway.logControl. */
     logger?: Logger | undefined;
 
+    /** Initial global log level (default: info); changeable through the admin API. */
+    logLevel?: LogLevel | undefined;
+
     /** Custom provider registry. */
     providerRegistry?: ProviderRegistry | undefined;
 
@@ -58,6 +306,129 @@ export interface GatewayOptions {
 
     /** Additional frontdoors to register. */
     frontdoors?: Frontdoor[] | undefined;
+
+    /**
+     * Creates the outbound HTTP client for a provider.
+     * Runtimes with tunable connection pools (e.g., Node.js) supply this;
+     * when omitted or returning undefined, providers use global fetch.
+     */
+    httpClientFactory?: ((config: ProviderConfig) => ProviderHTTPClient | undefined) | undefined;
+
+    /**
+     * Creates the outbound HTTP client for a pipeline webhook stage, so
+     * webhooks get their own proxy and TLS settings instead of a provider's.
+     * When omitted or returning undefined, webhooks use global fetch.
+     */
+    webhookClientFactory?: ((stage: PipelineStageConfig) => ProviderHTTPClient | undefined) | undefined;
+
+    /**
+     * Creates the analytics sink for `events` config. Runtimes with sinks
+     * that need a client library (e.g., Kafka on Node.js) supply this; when
+     * omitted or returning undefined, the built-in webhook sink is used.
+     */
+    eventSinkFactory?: ((config: EventsConfig) => EventSink | undefined) | undefined;
+
+    /**
+     * Creates the spill storage for `storage.spill` config. Only runtimes
+     * with a filesystem (e.g., Node.js) supply this; without it failed
+     * usage writes are logged and dropped.
+     */
+    spillStorageFactory?: ((config: SpillConfig) => SpillStorage | undefined) | undefined;
+
+    /**
+     * Reads a tiktoken rank file named in `tokenCount.encodings`. Only
+     * runtimes with a filesystem (e.g., Node.js) supply this; without it
+     * token counts are approximated.
+     */
+    encodingLoader?: ((path: string) => string | undefined) | undefined;
+
+    /** Variables for ${env:VAR} references in injected header values. */
+    env?: Record<string, string | undefined> | undefined;
+
+    /** Custom gateway tool registry (the calculator is always registered). */
+    toolRegistry?: ToolRegistry | undefined;
+
+    /** Named middleware apps can list (default: the built-ins: cors, ratelimit, bodylog, timeout). */
+    middlewareRegistry?: HttpMiddlewareRegistry | undefined;
+}
+
+/**
+ * Where a request is routed, and the conversation threads it continues.
+ */
+interface RequestRoute {
+    decision: RoutingDecision;
+    selection: ProviderSelection;
+
+    /** Thread keys affinity looked the request up by. */
+    threadKeys: string[];
+
+    /** Why the thread's previous provider could not be kept, if so. */
+    affinityBreak?: string | undefined;
+}
+
+/**
+ * One authenticated request's state, shared by its provider wrappers and
+ * the bookkeeping once it finishes. The optional fields are filled in
+ * as the request runs.
+ */
+interface RequestScope {
+    request: Request;
+    interactionId: string;
+    auth: AuthContext;
+    app: AppConfig | undefined;
+    frontdoor: string;
+    provider: string;
+    requestModel: string | undefined;
+    requestStream: boolean;
+    startedAt: number;
+    call: ProviderCallOptions;
+    log: Logger;
+    warnings: WarningCollector;
+    attempts: AttemptRecorder;
+    classification: ResponseClassificationConfig | undefined;
+
+    /** The interaction's stub, updated once the request is decoded. */
+    interactionRecord: Omit<InteractionRecord, 'status' | 'createdAt' | 'updatedAt'>;
+
+    /** Tool argument fields not yet announced to the client. */
+    toolArgumentFields: ToolArgumentFieldEvent[];
+
+    /** Whether output was cut off at max_tokens. */
+    truncated: boolean;
+
+    /** The frontdoor's result, and the model it sent upstream. */
+    completed?: FrontdoorResponse | undefined;
+    servedModel?: string | undefined;
+
+    /** Usage reported once a stream ends, and its model. */
+    streamedUsage?: Usage | undefined;
+    streamedModel?: string | undefined;
+
+    /** The provider the tenant's allowlist refused. */
+    policyDenied?: string | undefined;
+
+    /** The end user's hash, once forwarded. */
+    endUser?: string | undefined;
+
+    /** Response text captured for classification. */
+    responseText?: string | undefined;
+
+    /** The first usage discrepancy found. */
+    usageDiscrepancy?: UsageComparison | undefined;
+
+    /** Retry-After of a concurrency rejection. */
+    retryAfter?: number | undefined;
+}
+
+/**
+ * Wraps one request's providers (see Gateway.providerChain).
+ */
+interface ProviderChain {
+    /** Wraps a provider in every decorator. */
+    bind(provider: Provider): Provider;
+
+    /** Wraps a provider in the request's limits only, for calls made on its behalf. */
+    limit(provider: Provider): Provider;
 }
 
 // ============================================================================
@@ -76,11 +447,93 @@ export class Gateway {
     private readonly logger: Logger;
     private readonly providerRegistry: ProviderRegistry;
     private readonly frontdoorRegistry: FrontdoorRegistry;
+    private readonly idempotencyStore: IdempotencyStore;
+    private readonly httpClientFactory: GatewayOptions['httpClientFactory'];
+    private readonly webhookClientFactory: GatewayOptions['webhookClientFactory'];
+    private readonly eventSinkFactory: GatewayOptions['eventSinkFactory'];
+    private readonly spillStorageFactory: GatewayOptions['spillStorageFactory'];
+    private readonly encodingLoader: GatewayOptions['encodingLoader'];
+    private readonly spillTargets: SpillTargets;
+    private readonly env: Record<string, string | undefined>;
+    private readonly toolRegistry: ToolRegistry;
+    private readonly budgets: BudgetAccountant;
+    private readonly mirror: RequestMirror;
+    private readonly classifier: ClassificationWorker;
+    private readonly batches: MessageBatches;
+    private readonly passthrough: EndpointPassthrough;
+    private readonly recording: InteractionSampler;
+    private readonly probes: ProviderProber;
+    private readonly slos: SloTracker;
+    private readonly lifecycle: InteractionLifecycle | undefined;
+    private readonly storageKeys = new StorageKeyring();
+    private readonly storageHealth: StorageHealth;
+
+    /** Config-defined and runtime-created tenants. */
+    readonly tenants: TenantRegistry;
+
+    /** Runtime log level and scoped debug overrides. */
+    readonly logControl: LogControl;
+
+    /** Index of interactions by client correlation headers. */
+    readonly metadataIndex: MetadataIndexStore;
+
+    /** Per-request stats behind tenant usage reports. */
+    readonly usageReports: UsageReports;
+
+    /** Provider calls behind each interaction. */
+    readonly attempts: AttemptStore;
+
+    /** Tenant secrets referenced from webhook and provider config. */
+    readonly secrets: SecretManager;
+
+    /** Thread state storage; deletes also clear the thread affinity cache. */
+    readonly threadState: ThreadStateStore | undefined;
+
+    /** Runtime model rewrites and the jobs that reset threads for them. */
+    readonly modelMigrations: ModelMigrationJobs;
+
+    /** Live interaction events, for admin clients following a request. */
+    readonly interactionTails = new InteractionTails();
+
+    /** Buffers request bodies are read into, zeroed between requests. */
+    readonly bodyBuffers = new BodyBufferPool();
+
+    /** The dual-write store behind storage, while migrating between backends. */
+    readonly dualWrite: DualWriteStorage | undefined;
 
     // Initialized on first request or reload
     private config: GatewayConfig | undefined;
     private router: Router | undefined;
+    private routeTable: RouteTable | undefined;
     private providers: Map<string, Provider> = new Map();
+    private idempotency: IdempotencyManager | undefined;
+    private affinity: ThreadAffinity | undefined;
+    private endUsers: EndUserHasher | undefined;
+    private httpClients: Map<string, { key: string; client: ProviderHTTPClient }> = new Map();
+    private pipelines: Map<string, PipelineExecutor> = new Map();
+    private transforms: Map<string, ResponseTransform[]> = new Map();
+    private appCors: Map<string, HttpMiddleware> = new Map();
+    private appMiddleware: Map<string, HttpMiddleware> = new Map();
+    private readonly middlewareRegistry: HttpMiddlewareRegistry;
+    private keyPools: Map<string, { fingerprint: string; pool: KeyPool }> = new Map();
+    private webhookClients: ProviderHTTPClient[] = [];
+    private configTools: Map<string, GatewayTool> = new Map();
+    private eventSink: { key: string; publisher: SinkEventPublisher } | undefined;
+    private spill: { key: string; queue: WriteSpill } | undefined;
+    private tokenCounter: { key: string; counter: TokenCounter } = { key: '', counter: new TokenCounter() };
+    private templates: PromptTemplates = new Map();
+    private readonly latency = new LatencyStats();
+    private readonly deadlineCancellations = new DeadlineCancellations();
+    private readonly callBudgets = new CallBudgetExhaustions();
+    private readonly clientAborts = new ClientAborts();
+    private readonly usageDiscrepancies = new UsageDiscrepancies();
+    private readonly coalescer = new RequestCoalescer();
+    private readonly messageDedup = new MessageDedupCounter();
+    private readonly summaries = new ConversationSummarizer();
+    private readonly scheduler = new TenantScheduler();
+    private readonly replay = new StreamReplayBuffer();
+    private readonly unmatchedRoutes = new UnmatchedRoutes();
+    private readonly modelLists: ModelListCache;
 
     // Hot reload state
     private watchAbortController: AbortController | undefined;
@@ -89,19 +542,108 @@ export class Gateway {
     constructor(options: GatewayOptions) {
         this.configProvider = options.config;
         this.authProvider = options.auth;
-        this.storageProvider = options.storage;
+        const sink = options.logger ?? new ConsoleLogger({ level: 'debug' });
+        this.logControl = new LogControl({ level: options.logLevel, logger: sink });
+        this.logger = new ControlledLogger(sink, this.logControl);
+        // Every store below goes through the health supervisor, and seals
+        // what it keeps of prompts and completions
+        const raw = options.storage;
+        this.dualWrite = dualWriteOf(raw);
+        this.storageHealth = new StorageHealth({
+            probe: async () => raw && probeStorage(raw),
+            logger: this.logger,
+        });
+        const storage = raw && withEncryption(withStorageHealth(raw, this.storageHealth), this.storageKeys);
+        this.storageProvider = storage;
         this.eventPublisher = options.events;
-        this.logger = options.logger ?? new ConsoleLogger();
+        this.idempotencyStore = isIdempotencyStore(storage)
+            ? storage
+            : new MemoryIdempotencyStore();
+        this.httpClientFactory = options.httpClientFactory;
+        this.webhookClientFactory = options.webhookClientFactory;
+        this.eventSinkFactory = options.eventSinkFactory;
+        this.spillStorageFactory = options.spillStorageFactory;
+        this.encodingLoader = options.encodingLoader;
+        this.env = options.env ?? {};
+        const usageStore = isUsageStore(storage) ? storage : new MemoryUsageStore();
+        const statsStore = isUsageStatsStore(storage) ? storage : new MemoryUsageStatsStore();
+        this.spillTargets = { usage: usageStore, requestStats: statsStore };
+        this.budgets = new BudgetAccountant({
+            store: this.spillingUsageStore(usageStore),
+            logger: this.logger,
+        });
+        this.mirror = new RequestMirror({ env: this.env, logger: this.logger });
+        this.classifier = new ClassificationWorker({ logger: this.logger });
+        this.recording = new InteractionSampler({
+            store: this.storageProvider,
+            logger: this.logger,
+            onEvent: (event) => this.interactionTails.publish(event),
+        });
+        this.modelLists = new ModelListCache({ logger: this.logger });
+        this.probes = new ProviderProber({
+            store: isProbeStore(storage) ? storage : new MemoryProbeStore(),
+            estimateCost: (model, usage) => this.router?.catalog.estimateCost(model, usage),
+            env: this.env,
+            logger: this.logger,
+        });
+        this.slos = new SloTracker({
+            store: isSloStore(storage) ? storage : new MemorySloStore(),
+            env: this.env,
+            logger: this.logger,
+        });
+        this.tenants = new TenantRegistry({
+            store: isTenantStore(storage) ? storage : undefined,
+            logger: this.logger,
+        });
+        this.secrets = new SecretManager({
+            store: isSecretStore(storage) ? storage : new MemorySecretStore(),
+            keyring: this.storageKeys,
+            logger: this.logger,
+        });
+        this.metadataIndex = isMetadataIndexStore(storage) ? storage : new MemoryMetadataIndex();
+        this.attempts = isAttemptStore(storage) ? storage : new MemoryAttemptStore();
+        // Requests' interaction records go to the interaction store, sealed
+        // like bodies since they can keep the request
+        this.lifecycle = isInteractionRecordStore(this.storageProvider)
+            ? new InteractionLifecycle({ store: this.storageProvider, logger: this.logger })
+            : undefined;
+        this.threadState = this.storageProvider && this.evictingThreadState(this.storageProvider);
+        this.modelMigrations = new ModelMigrationJobs({
+            threadState: this.threadState,
+            responses: this.storageProvider,
+            forgetModel: (model) => this.affinity?.forgetModel(model),
+            logger: this.logger,
+        });
+        this.usageReports = new UsageReports({
+            store: this.spillingUsageStatsStore(statsStore),
+            attempts: this.attempts,
+            logger: this.logger,
+        });
+        this.batches = new MessageBatches({
+            store: isBatchStore(storage) ? storage : new MemoryBatchStore(),
+            client: (name) => this.batchClientFor(name),
+            onResult: (batch, result) => this.recordBatchResult(batch, result),
+        });
+        this.passthrough = new EndpointPassthrough({
+            target: (name) => this.passthroughTargetFor(name),
+        });
 
         // Setup provider registry
         this.providerRegistry = options.providerRegistry ?? createProviderRegistry();
         this.providerRegistry.register('openai', createOpenAIProvider);
         this.providerRegistry.register('anthropic', createAnthropicProvider);
 
+        // Setup gateway tool registry
+        this.toolRegistry = options.toolRegistry ?? createToolRegistry();
+        this.toolRegistry.register(calculatorTool);
+        this.middlewareRegistry = options.middlewareRegistry ?? createHttpMiddlewareRegistry();
+
         // Setup frontdoor registry
         this.frontdoorRegistry = options.frontdoorRegistry ?? createFrontdoorRegistry();
         this.frontdoorRegistry.register(openAIFrontdoor);
         this.frontdoorRegistry.register(anthropicFrontdoor);
+        this.frontdoorRegistry.register(responsesFrontdoor);
+        this.frontdoorRegistry.register(cohereFrontdoor);
 
         // Register additional frontdoors
         if (options.frontdoors) {
@@ -115,9 +657,50 @@ export class Gateway {
      * Loads or reloads the gateway configuration.
      */
     async reload(): Promise<void> {
-        this.config = await this.configProvider.load();
+        const config = await this.configProvider.load();
+        await this.applyMigrations(config);
+        await this.applyConfig(config);
+
+        this.logger.info('Gateway configuration loaded', {
+            apps: this.config.apps.length,
+            providers: this.providers.size,
+        });
+    }
+
+    /**
+     * Applies a loaded configuration: on reload() and on every change the
+     * config watcher reports.
+     */
+    private async applyConfig(config: GatewayConfig): Promise<void> {
+        // Everything that can reject the config runs before any of it is
+        // applied, so a rejected reload leaves the gateway as it was
+        const keys = await StorageKeyring.fromConfig(config.storage?.encryption, this.env);
+        const secrets = await this.secrets.prepare(keys);
+        const tenants = await this.tenants.prepare(config);
+        const templates = await loadPromptTemplates(config.templates);
+        const transforms = this.createTransforms(config.apps);
+        const middleware = this.createAppMiddleware(config.apps);
+        this.checkCorrelationHeaders(config.apps);
+        checkStageConditions(config.apps);
+        this.checkSharedPaths(config);
+        checkParameterPolicies(config);
+        this.checkProviderVersioning(config.providers);
+
+        this.storageKeys.adopt(keys);
+        await this.secrets.apply(secrets);
+        this.tenants.apply(tenants);
+        this.storageHealth.configure(config.storage?.health);
+        this.configureDualWrite(config.storage);
+        this.transforms = transforms;
+        this.appCors = middleware.cors;
+        this.appMiddleware = middleware.chains;
+        this.config = config;
+        this.modelLists.clear();
         this.router = new Router({
             defaultRouting: this.config.routing,
+            catalog: new ModelCatalog(this.config.models),
+            modelLists: this.modelLists,
+            migratedModels: this.modelMigrations.rules,
         });
 
         // Register apps
@@ -132,6 +715,7 @@ export class Gateway {
                 this.router.addFrontdoor(frontdoor);
             }
         }
+        this.routeTable = this.createRouteTable(this.router, this.config.apps);
 
         // Create providers
         this.providers.clear();
@@ -146,11 +730,21 @@ export class Gateway {
                 });
             }
         }
+        this.pruneHTTPClients(this.config.providers);
+        this.applyProbes(this.config);
+        await this.applySlos(this.config);
+        await this.lifecycle?.configure(this.config.interactionStatus);
+        this.pipelines = this.createPipelines(this.config.apps);
+        this.configTools = this.createGatewayTools(this.config);
+        this.templates = templates;
+        this.applyEventsConfig(this.config.events);
+        this.applySpillConfig(this.config.storage?.spill);
+        this.applyTokenCountConfig(this.config.tokenCount);
+        this.applyConcurrencyConfig(this.config);
 
-        this.logger.info('Gateway configuration loaded', {
-            apps: this.config.apps.length,
-            providers: this.providers.size,
-        });
+        this.idempotency = this.createIdempotencyManager(this.config);
+        this.affinity = this.createAffinity(this.config);
+        this.endUsers = this.createEndUserHasher(this.config);
     }
 
     /**
@@ -180,34 +774,7 @@ export class Gateway {
             try {
                 // Apply the new config directly instead of calling reload()
                 // since we already have the new config
-                this.config = newConfig;
-                this.router = new Router({
-                    defaultRouting: newConfig.routing,
-                });
-
-                for (const app of newConfig.apps) {
-                    this.router.addApp(app);
-                }
-
-                for (const name of this.frontdoorRegistry.list()) {
-                    const frontdoor = this.frontdoorRegistry.get(name);
-                    if (frontdoor) {
-                        this.router.addFrontdoor(frontdoor);
-                    }
-                }
-
-                this.providers.clear();
-                for (const providerConfig of newConfig.providers) {
-                    try {
-                        const provider = this.createProvider(providerConfig);
-                        this.providers.set(providerConfig.name, provider);
-                    } catch (error) {
-                        this.logger.error('Failed to create provider on reload', {
-                            provider: providerConfig.name,
-                            error: error instanceof Error ? error.message : String(error),
-                        });
-                    }
-                }
+                await this.applyConfig(newConfig);
 
                 this.logger.info('Config reload complete', {
                     apps: newConfig.apps.length,
@@ -255,136 +822,2515 @@ export class Gateway {
     }
 
     /**
-     * Handles an HTTP request.
-     * This is the main entry point for the gateway.
+     * Returns per-provider health, including connection pool statistics
+     * when the runtime's HTTP client exposes them.
      */
-    async fetch(request: Request): Promise<Response> {
-        const interactionId = randomUUID();
-        const url = new URL(request.url);
-        const path = url.pathname;
+    providerHealth(): ProviderHealthSummary[] {
+        return (this.config?.providers ?? []).map((config) => ({
+            name: config.name,
+            type: config.type,
+            configured: this.providers.has(config.name),
+            http: this.httpClients.get(config.name)?.client.stats?.(),
+            keys: this.keyPools.get(config.name)?.pool.health(),
+            probe: this.probes.summary(config.name),
+            ...providerVersioning(config),
+        }));
+    }
 
-        // Ensure config is loaded
-        if (!this.config || !this.router) {
-            await this.reload();
-        }
+    /**
+     * Returns a provider's probe status and most recent probe results, or
+     * undefined if the provider has no probe.
+     */
+    async probeReport(provider: string, limit: number): Promise<ProbeReport | undefined> {
+        return this.probes.report(provider, limit);
+    }
 
-        // Quick health check
-        if (path === '/health' || path === '/healthz') {
-            return new Response(JSON.stringify({ status: 'ok' }), {
-                status: 200,
-                headers: { 'Content-Type': 'application/json' },
-            });
-        }
+    /**
+     * Returns each app's SLO compliance and error budget remaining, for
+     * apps with an slo config.
+     */
+    sloReport(): SloReport[] {
+        return this.slos.report();
+    }
 
-        // Authenticate request
-        const authHeader = request.headers.get('Authorization');
-        const token = extractBearerToken(authHeader);
+    /**
+     * Returns latency percentiles per provider and model over recent requests.
+     */
+    latencySummary(): LatencySummary[] {
+        return this.latency.summary();
+    }
 
-        if (!token) {
-            return this.errorResponse(
-                errAuthentication('Missing Authorization header'),
-            );
-        }
+    /**
+     * Returns a tenant's consumption against its configured budget, or
+     * undefined if the tenant has no budget.
+     */
+    async budgetStatus(tenantId: string): Promise<BudgetStatus | undefined> {
+        const budget = this.config?.tenants?.find((t) => t.id === tenantId)?.budget;
+        return budget ? this.budgets.status(tenantId, budget) : undefined;
+    }
 
-        let auth: AuthContext | null;
-        try {
-            auth = await this.authProvider.authenticate(token);
-        } catch (error) {
-            this.logger.error('Authentication error', {
-                error: error instanceof Error ? error.message : String(error),
-            });
-            return this.errorResponse(errAuthentication('Authentication failed'));
-        }
+    /**
+     * Returns the salted hash an end-user ID is recorded under, or
+     * undefined when no salt is configured.
+     */
+    async endUserHash(endUserId: string): Promise<string | undefined> {
+        return this.endUsers?.hash(endUserId);
+    }
 
-        if (!auth) {
-            return this.errorResponse(errAuthentication('Invalid API key'));
-        }
+    /**
+     * Returns analytics sink delivery counters, or undefined when no sink
+     * is configured.
+     */
+    eventStats(): EventSinkStats | undefined {
+        return this.eventSink?.publisher.stats();
+    }
 
-        // Create request-scoped logger
-        const log = requestLogger(this.logger, interactionId, auth.tenantId);
+    /**
+     * Returns write spill counters, or undefined when no spill is
+     * configured.
+     */
+    spillStats(): SpillStats | undefined {
+        return this.spill?.queue.stats();
+    }
 
-        // Match app and frontdoor
-        let app = this.router!.matchApp(path);
-        let frontdoor: Frontdoor | undefined;
+    /**
+     * Replays spilled usage writes now, or returns undefined when no spill
+     * is configured.
+     */
+    async replaySpill(): Promise<SpillReplayResult | undefined> {
+        return this.spill?.queue.replay();
+    }
 
-        if (app) {
-            frontdoor = this.router!.getFrontdoor(app);
-        } else {
-            // Try to match frontdoor directly by path
-            frontdoor = this.router!.matchFrontdoor(path);
+    /**
+     * Storage as the gateway uses it, decrypting sensitive fields when
+     * storage encryption is configured. Hand this, not the raw provider,
+     * to anything that reads stored interactions (e.g. the admin API).
+     */
+    get storage(): StorageProvider | undefined {
+        return this.storageProvider;
+    }
+
+    /**
+     * Reseals stored sensitive values under the newest storage encryption
+     * key, in batches. Returns undefined when encryption is not configured
+     * or storage does not support it.
+     */
+    async rewrapStorage(batchSize?: number): Promise<RewrapResult | undefined> {
+        if (!this.storageKeys.enabled || !isSensitiveValueStore(this.storageProvider)) {
+            return undefined;
         }
+        return rewrapSensitiveValues(this.storageProvider, this.storageKeys, { batchSize, logger: this.logger });
+    }
 
-        if (!frontdoor) {
-            return this.errorResponse(errNotFound('No matching endpoint'));
+    /**
+     * Routes a model as a request on the app and tenant would be routed,
+     * without calling anything, and explains the decision. Thread
+     * affinity, which depends on the request body, is left out.
+     */
+    async explainRouting(query: RoutingTestQuery): Promise<RoutingDecision> {
+        if (!this.config || !this.router) {
+            await this.reload();
+        }
+        const app = query.app !== undefined ? this.config!.apps.find((a) => a.name === query.app) : undefined;
+        if (query.app !== undefined && !app) {
+            throw errNotFound(`App '${query.app}' not found`);
         }
+        const tenantRouting = query.tenant !== undefined ? this.tenants.routing(query.tenant) : undefined;
+        return this.router!.explain(query.model || app?.defaultModel || '', app, undefined, tenantRouting);
+    }
 
-        // Select provider
-        // For now, extract model from request body if POST
-        let requestModel: string | undefined;
-        if (request.method === 'POST') {
-            try {
-                const clonedRequest = request.clone();
-                const body = await clonedRequest.json() as { model?: string };
-                requestModel = body.model;
-            } catch {
-                // Ignore parsing errors, will be caught by frontdoor
-            }
+    /**
+     * Runs a console request through an app: decode, routing, the
+     * pre-request pipeline, and the provider encode, returning every
+     * intermediate representation. Unless it is a dry run the provider is
+     * then called and the post-request pipeline run. Console requests are
+     * flagged in the interaction record and never reach usage reports or
+     * budgets.
+     */
+    async consoleExecute(input: ConsoleRequest): Promise<ConsoleResult> {
+        if (!this.config || !this.router) {
+            await this.reload();
+        }
+        const app = this.config!.apps.find((a) => a.name === input.app);
+        if (!app) {
+            throw errNotFound(`App '${input.app}' not found`);
+        }
+        const codec = consoleCodec(app.frontdoor);
+        if (!codec) {
+            throw errInvalidRequest(`The console does not support '${app.frontdoor}' apps`);
         }
 
-        const selection = this.router!.selectProvider(
-            requestModel ?? app?.defaultModel ?? '',
-            app,
-        );
+        const interactionId = randomUUID();
+        const startedAt = Date.now();
+        const raw: Record<string, unknown> = { model: input.model, messages: input.messages, stream: false };
+        let canonical: CanonicalRequest;
+        try {
+            canonical = codec.decodeRequest(JSON.stringify(raw));
+        } catch (error) {
+            throw isAPIError(error) ? error : errInvalidRequest(`Failed to decode request: ${(error as Error).message}`);
+        }
+        canonical.tenantId = CONSOLE_TENANT;
 
-        const provider = this.providers.get(selection.providerName);
-        if (!provider) {
-            log.error('Provider not found', { provider: selection.providerName });
-            return this.errorResponse(
-                errServer(`Provider '${selection.providerName}' not configured`),
-            );
+        const selection = this.router!.selectProvider(canonical.model || app.defaultModel || '', app);
+        const selected = this.providers.get(selection.providerName);
+        if (!selected) {
+            throw errServer(`Provider '${selection.providerName}' not configured`);
         }
+        const log = requestLogger(this.logger, interactionId, CONSOLE_TENANT).child({ app: app.name, provider: selected.name });
+        log.info('interaction_metadata', { origin: CONSOLE_ORIGIN, dry_run: String(input.dryRun) });
 
-        // Build frontdoor context
+        const steps = resolveRequestModel(canonical, app, selection.model);
+        this.router!.catalog.check(canonical, log);
+        const decoded = withoutRaw(structuredClone(canonical));
+
+        const transforms = this.transforms.get(app.name);
+        const bind = (resolved: Provider): Provider => withTransforms(resolved, transforms, (s) => steps.push(...s));
         const ctx: FrontdoorContext = {
-            request,
-            provider,
-            auth,
+            request: new Request('http://console/'),
+            provider: bind(selected),
+            auth: { tenantId: CONSOLE_TENANT, scopes: [], metadata: { origin: CONSOLE_ORIGIN } },
             app,
+            modelRewrite: selection.model,
             logger: log,
             interactionId,
+            catalog: this.router!.catalog,
+            parameterPolicies: this.config?.parameterPolicies,
+            pipeline: this.pipelines.get(app.name),
+            resolveProvider: (name) => {
+                const resolved = this.providers.get(name);
+                return resolved && bind(resolved);
+            },
         };
+        const plan = await planExecution(ctx, canonical, steps);
 
-        // Handle request
-        try {
-            const result = await frontdoor.handle(ctx);
-
-            // TODO: Publish events, store interaction, trigger shadow mode
+        const result: ConsoleResult = {
+            interaction_id: interactionId,
+            dry_run: input.dryRun,
+            raw,
+            canonical: decoded,
+            pipeline: {
+                stages: plan.stages,
+                outcome: plan.response ? 'respond' : plan.error?.type === 'permission' ? 'deny' : 'continue',
+            },
+            routing: {
+                provider: plan.provider.name,
+                model: plan.request.model,
+                ...(selection.model && { model_rewrite: selection.model }),
+                ...(plan.route && { pipeline_route: plan.route }),
+            },
+            planned: withoutRaw(plan.request),
+            provider_request: plan.response || plan.error ? undefined : encodeProviderRequest(plan.provider.apiType, plan.request),
+            transformations: steps,
+            ...(plan.error && { error: plan.error.message }),
+        };
+        if (input.dryRun || plan.response || plan.error) {
+            return result;
+        }
 
-            return result.response;
-        } catch (error) {
-            log.error('Request handling failed', {
-                error: error instanceof Error ? error.message : String(error),
+        // Execute, then run the response back through the pipeline
+        let response = await plan.provider.complete(plan.request);
+        let postStages: StageOutcome[] = [];
+        if (ctx.pipeline) {
+            const post = await ctx.pipeline.runPost({
+                request: plan.request,
+                response,
+                tenantId: CONSOLE_TENANT,
+                appName: app.name,
+                interactionId,
+                metadata: plan.pipelineMetadata,
             });
+            postStages = post.stages ?? [];
+            response = post.response ?? response;
+        }
+        result.response = {
+            canonical: withoutRawResponse(response),
+            post_pipeline: postStages,
+            body: JSON.parse(new TextDecoder().decode(codec.encodeResponse(response))),
+        };
 
-            if (error instanceof APIError) {
-                return this.errorResponse(error);
-            }
+        this.publishInteraction(CONSOLE_TENANT, interactionId, {
+            appName: app.name,
+            frontdoor: app.frontdoor,
+            providerName: plan.provider.name,
+            model: plan.request.model,
+            stream: false,
+            statusCode: 200,
+            usage: response.usage,
+            costUsd: response.usage && this.router!.catalog.estimateCost(plan.request.model, response.usage),
+            finishReason: response.choices[0]?.finishReason ?? undefined,
+            totalDurationMs: Date.now() - startedAt,
+            timings: { totalMs: Date.now() - startedAt },
+            origin: CONSOLE_ORIGIN,
+        });
+        return result;
+    }
 
-            return this.errorResponse(
-                errServer(error instanceof Error ? error.message : 'Internal error'),
-            );
-        }
+    /**
+     * Returns the loaded prompt templates, with their versions.
+     */
+    promptTemplates(): PromptTemplate[] {
+        return Array.from(this.templates.values());
     }
 
     /**
-     * Creates a provider from configuration.
+     * Returns request mirroring counters per app.
      */
-    private createProvider(config: ProviderConfig): Provider {
-        return this.providerRegistry.create(config.type, {
-            name: config.name,
-            apiKey: config.apiKey,
-            baseUrl: config.baseUrl,
-        });
+    mirrorStats(): MirrorStats[] {
+        return this.mirror.stats();
+    }
+
+    /**
+     * Returns per-provider counts of calls cancelled by a request deadline.
+     */
+    deadlineStats(): DeadlineCancellationStats[] {
+        return this.deadlineCancellations.stats();
+    }
+
+    /**
+     * Returns per-app counts of requests whose call budget refused an
+     * automatic provider call, by the limit reached.
+     */
+    callBudgetStats(): CallBudgetStats[] {
+        return this.callBudgets.stats();
+    }
+
+    /**
+     * Returns the loaded config's parameter policies: each app's and the
+     * per-model ones.
+     */
+    parameterPolicies(): ParameterPolicySummary {
+        return summarizeParameterPolicies(this.config ?? { apps: [] });
+    }
+
+    /**
+     * Returns per-provider, per-model counts of responses whose reported
+     * completion tokens were checked, and of those that disagreed with
+     * the output relayed.
+     */
+    usageDiscrepancyStats(): UsageDiscrepancyStats[] {
+        return this.usageDiscrepancies.stats();
+    }
+
+    /**
+     * Returns per-app counts of non-streaming requests abandoned by their
+     * client, with how long they had run.
+     */
+    clientAbortStats(): ClientAbortStats[] {
+        return this.clientAborts.stats();
+    }
+
+    /**
+     * Returns per-app counts of provider calls made and requests that
+     * joined one in flight.
+     */
+    coalescingStats(): CoalescingStats[] {
+        return this.coalescer.stats();
+    }
+
+    /**
+     * Returns per-app counts of thread appends skipped as duplicates.
+     */
+    messageDedupStats(): MessageDedupStats[] {
+        return this.messageDedup.stats();
+    }
+
+    /**
+     * Returns every method and path served, with the app and frontdoor
+     * that serve it.
+     */
+    routes(): RegisteredRoute[] {
+        return this.routeTable?.list() ?? [];
+    }
+
+    /**
+     * Returns per-prefix counts of requests with no route or a method the
+     * route doesn't accept.
+     */
+    unmatchedRouteStats(): UnmatchedRouteStats[] {
+        return this.unmatchedRoutes.stats();
+    }
+
+    /**
+     * Returns provider calls in flight and queued per tenant, or undefined
+     * when no concurrency limit is configured.
+     */
+    concurrencyStats(): ConcurrencyStats | undefined {
+        return this.scheduler.enabled ? this.scheduler.stats() : undefined;
+    }
+
+    /**
+     * Returns storage health, or undefined when no storage is configured.
+     */
+    storageHealthStats(): StorageHealthStats | undefined {
+        return this.storageProvider && this.storageHealth.stats();
+    }
+
+    /**
+     * Returns response classification counters.
+     */
+    classificationStats(): ClassificationStats {
+        return this.classifier.stats();
+    }
+
+    /**
+     * Shuts the gateway down; call before the process exits. In order:
+     * - stops watching for config changes
+     * - stops provider probes
+     * - finishes queued response classifications
+     * - stops storage recovery probes
+     * - ends interaction tails
+     * - drops buffered Responses stream events
+     * - saves SLO windows
+     * - stops the abandoned-interaction sweep
+     * - delivers queued analytics events
+     * - stops the spill replay loop
+     * - waits for queued dual-write mirroring
+     */
+    async close(): Promise<void> {
+        this.stopWatching();
+        this.probes.close();
+        await this.classifier.drain();
+        this.storageHealth.close();
+        this.interactionTails.clear();
+        this.replay.clear();
+        await this.slos.close();
+        await this.lifecycle?.close();
+        await this.eventSink?.publisher.close();
+        this.eventSink = undefined;
+        await this.spill?.queue.close();
+        this.spill = undefined;
+        await this.dualWrite?.flush();
+    }
+
+    /**
+     * Returns the effective model catalog (built-in defaults plus config).
+     */
+    get modelCatalog(): ModelCatalog {
+        return this.router?.catalog ?? new ModelCatalog(this.config?.models);
+    }
+
+    /**
+     * Handles an HTTP request.
+     * This is the main entry point for the gateway.
+     */
+    async fetch(request: Request): Promise<Response> {
+        // Ensure config is loaded
+        if (!this.config || !this.router) {
+            await this.reload();
+        }
+
+        // An app's CORS wraps only its own routes, and runs before
+        // authentication since preflights carry no key; where apps share a
+        // path, the key is looked up first to find whose CORS applies, and
+        // that lookup is the one the request is authenticated with. The
+        // rest of the app's middleware runs once the key is accepted.
+        const path = new URL(request.url).pathname;
+        let app = this.router!.matchApp(path);
+        let authenticated: Promise<AuthContext | null> | undefined;
+        if (app && this.router!.sharesPath(app)) {
+            const token = extractBearerToken(request.headers.get('Authorization'));
+            authenticated = token ? this.authenticate(token) : undefined;
+            app = this.router!.matchApp(path, await authenticated?.then((auth) => auth?.app, () => undefined));
+        }
+        const cors = app && this.appCors.get(app.name);
+        return cors
+            ? cors((r) => this.serve(r, authenticated))(request)
+            : this.serve(request, authenticated);
+    }
+
+    /**
+     * Looks up a key's principal: runtime and config tenants first, then
+     * the auth provider. Keys of disabled tenants resolve to null.
+     */
+    private async authenticate(token: string): Promise<AuthContext | null> {
+        const auth = await this.tenants.authenticate(token) ?? await this.authProvider.authenticate(token);
+        return auth && this.tenants.isDisabled(auth.tenantId) ? null : auth;
+    }
+
+    /**
+     * Handles an HTTP request as a new interaction, with its key's
+     * principal if fetch() already looked it up.
+     */
+    private async serve(request: Request, authenticated?: Promise<AuthContext | null>): Promise<Response> {
+        const interactionId = randomUUID();
+        const response = await this.handleRequest(request, interactionId, authenticated);
+        // Frontdoor responses already carry it (replays keep the original ID)
+        if (!response.headers.has(INTERACTION_ID_HEADER)) {
+            response.headers.set(INTERACTION_ID_HEADER, interactionId);
+        }
+        return response;
+    }
+
+    /**
+     * Handles an HTTP request under the given interaction ID.
+     */
+    private async handleRequest(
+        request: Request,
+        interactionId: string,
+        authenticated?: Promise<AuthContext | null>,
+    ): Promise<Response> {
+        const startedAt = Date.now();
+        const url = new URL(request.url);
+        const path = url.pathname;
+
+        // Ensure config is loaded
+        if (!this.config || !this.router) {
+            await this.reload();
+        }
+
+        // Quick health check
+        if (path === '/health' || path === '/healthz') {
+            return new Response(JSON.stringify({ status: 'ok' }), {
+                status: 200,
+                headers: { 'Content-Type': 'application/json' },
+            });
+        }
+
+        // Readiness stays 200 while storage is degraded, since completions
+        // still flow; the status says what is missing. It is unauthenticated,
+        // so the storage error is left to the admin stats
+        if (path === '/readyz') {
+            const storage = this.storageHealthStats();
+            const counters = storage && {
+                status: storage.status,
+                consecutiveFailures: storage.consecutiveFailures,
+                failureThreshold: storage.failureThreshold,
+                trips: storage.trips,
+                skippedWrites: storage.skippedWrites,
+                rejectedCalls: storage.rejectedCalls,
+            };
+            return new Response(JSON.stringify({ status: storage?.status === 'degraded' ? 'degraded' : 'ok', storage: counters }), {
+                status: 200,
+                headers: { 'Content-Type': 'application/json' },
+            });
+        }
+
+        // Unknown paths and methods are answered before authentication, so
+        // a typo isn't mistaken for a bad key, and are only counted
+        const unmatched = this.routeTable?.resolve(request.method, path);
+        if (unmatched) {
+            this.unmatchedRoutes.record(unmatched);
+            this.logger.debug('route_unmatched', { method: request.method, path, status: unmatched.status });
+            return unmatchedResponse(request.method, path, unmatched);
+        }
+
+        // Authenticate request
+        const authHeader = request.headers.get('Authorization');
+        const token = extractBearerToken(authHeader);
+
+        if (!token) {
+            return this.errorResponse(
+                errAuthentication('Missing Authorization header'),
+            );
+        }
+
+        let auth: AuthContext | null;
+        try {
+            auth = await (authenticated ?? this.authenticate(token));
+        } catch (error) {
+            this.logger.error('Authentication error', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return this.errorResponse(errAuthentication('Authentication failed'));
+        }
+
+        if (!auth) {
+            return this.errorResponse(errAuthentication('Invalid API key'));
+        }
+
+        // The app's middleware runs inside authentication, so a request
+        // with a bad key never reaches it
+        const accepted = auth;
+        const middleware = this.appMiddleware.get(this.router!.matchApp(path, auth.app)?.name ?? '');
+        return middleware
+            ? middleware((r) => this.handleAuthenticated(r, interactionId, accepted, startedAt))(request)
+            : this.handleAuthenticated(request, interactionId, accepted, startedAt);
+    }
+
+    /**
+     * Handles an authenticated request under the given interaction ID.
+     */
+    private async handleAuthenticated(
+        request: Request,
+        interactionId: string,
+        auth: AuthContext,
+        startedAt: number,
+    ): Promise<Response> {
+        const url = new URL(request.url);
+        const path = url.pathname;
+
+        // Create request-scoped logger
+        let log = requestLogger(this.logger, interactionId, auth.tenantId);
+
+        // Tenant self-serve usage, always for the key's own tenant
+        if (path === USAGE_REPORT_PATH) {
+            return this.handleUsageReport(request, url, auth.tenantId);
+        }
+
+        // Token counts never reach a provider, so they skip budgets and recording
+        const countApp = path.endsWith(TOKEN_COUNT_SUFFIX) ? this.router!.matchApp(path, auth.app) : undefined;
+        if (countApp) {
+            return this.handleTokenCount(request, countApp);
+        }
+
+        // Enforce the tenant's monthly budget (reads don't spend, so only POSTs)
+        const budget = request.method === 'POST' ? await this.budgetStatus(auth.tenantId) : undefined;
+        if (budget?.exceeded) {
+            if (budget.action === 'block') {
+                log.info('budget_blocked', { tokens: budget.tokens, costUsd: budget.costUsd });
+                return this.errorResponse(errBudgetExceeded(describeExceeded(budget)));
+            }
+            if (this.budgets.shouldWarn(auth.tenantId)) {
+                log.warn('budget_exceeded', {
+                    tokens: budget.tokens,
+                    costUsd: budget.costUsd,
+                    monthlyTokens: budget.limits.monthlyTokens,
+                    monthlyCostUsd: budget.limits.monthlyCostUsd,
+                    resetsAt: budget.resetsAt.toISOString(),
+                });
+            }
+        }
+
+        // Match app and frontdoor; where apps share the path, the key's
+        // binding picks one
+        let app = this.router!.matchApp(path, auth.app);
+        let frontdoor: Frontdoor | undefined;
+
+        if (app) {
+            frontdoor = this.router!.getFrontdoor(app);
+        } else {
+            // Try to match frontdoor directly by path
+            frontdoor = this.router!.matchFrontdoor(path);
+        }
+
+        if (!frontdoor) {
+            return this.errorResponse(errNotFound('No matching endpoint'));
+        }
+
+        // Index the client's correlation headers; never awaited
+        const correlation = captureCorrelation(request.headers, app?.correlationHeaders);
+        if (correlation) {
+            log.info('interaction_correlation', correlation);
+            this.metadataIndex.indexMetadata({
+                interactionId,
+                tenantId: auth.tenantId,
+                appName: app?.name,
+                metadata: correlation,
+                createdAt: new Date(startedAt),
+            }).catch((error: unknown) => {
+                log.warn('metadata_index_failed', {
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            });
+        }
+
+        // Select provider
+        // The body is read once, up to the size limit; routing pre-scans it
+        // for the model, and the frontdoor shares its one full parse
+        let requestModel: string | undefined;
+        let requestStream = false;
+        let requestBody: Record<string, unknown> = {};
+        let rawBody = '';
+        let body: RequestBody | undefined;
+        if (request.method === 'POST' && hasTextBody(request)) {
+            try {
+                body = await readRequestBody(request, {
+                    maxBytes: this.config?.server?.maxRequestBytes,
+                    pool: this.bodyBuffers,
+                });
+            } catch (error) {
+                const failure = isAPIError(error)
+                    ? error
+                    : errInvalidRequest(`Failed to read request body: ${error instanceof Error ? error.message : String(error)}`);
+                log.info('request_body_rejected', { status: failure.statusCode, error: failure.message });
+                return this.errorResponse(failure);
+            }
+            rawBody = body.text;
+            const fields = body.routingFields();
+            requestModel = fields.model;
+            requestStream = fields.stream;
+            try {
+                if (requestModel === undefined || this.affinity) {
+                    const parsed = body.json() as { model?: string; requests?: unknown };
+                    requestModel ??= batchRoutingModel(parsed);
+                    requestBody = parsed;
+                }
+            } catch {
+                // Ignore parsing errors, will be caught by frontdoor
+            }
+        }
+
+        const allowedProviders = this.tenants.allowedProviders(auth.tenantId);
+        const tenantRouting = this.tenants.routing(auth.tenantId);
+        let route: RequestRoute;
+        try {
+            route = await this.routeRequest(requestModel || app?.defaultModel || '', app, tenantRouting, requestBody, auth.tenantId, interactionId, log);
+        } catch (error) {
+            if (error instanceof APIError) {
+                return this.errorResponse(error);
+            }
+            throw error;
+        }
+        const { decision, selection, threadKeys, affinityBreak } = route;
+
+        const selected = this.providers.get(selection.providerName);
+        if (!selected) {
+            log.error('Provider not found', { provider: selection.providerName });
+            return this.errorResponse(
+                errServer(`Provider '${selection.providerName}' not configured`),
+            );
+        }
+
+        // Scoped debug logging can target the app and provider from here on
+        log = log.child({ app: app?.name, provider: selected.name });
+
+        const scope: RequestScope = {
+            request,
+            interactionId,
+            auth,
+            app,
+            frontdoor: frontdoor.name,
+            provider: selected.name,
+            requestModel,
+            requestStream,
+            startedAt,
+            // Provider calls end with the request: at the timeout
+            // middleware's deadline, or when the runtime aborts it
+            call: { signal: request.signal, deadline: getRequestContext(request)?.deadline },
+            log,
+            // Warnings are collected from every step recorded, and from
+            // anything else the client should know the gateway changed
+            warnings: new WarningCollector(),
+            // Every provider call is recorded as an attempt; the interaction's
+            // usage is the sum of its attempts
+            attempts: new AttemptRecorder({
+                interactionId,
+                tenantId: auth.tenantId,
+                store: this.attempts,
+                estimateCost: (model, usage) => this.router?.catalog.estimateCost(model, usage),
+                logger: log,
+            }),
+            // Responses are classified once sent, for apps with classification
+            // on; the text is captured from the provider's response or stream
+            classification: app?.classification && app.classification.enabled !== false
+                ? app.classification
+                : undefined,
+            // The interaction's stub is in_progress from its decode until it
+            // finishes; the request itself is kept only when recorded in full
+            interactionRecord: {
+                id: interactionId,
+                tenantId: auth.tenantId,
+                appName: app?.name,
+                frontdoor: frontdoor.name,
+                provider: selected.name,
+                model: requestModel ?? 'unknown',
+                stream: requestStream,
+            },
+            toolArgumentFields: [],
+            truncated: false,
+        };
+        const { warnings, interactionRecord } = scope;
+
+        // Clients can ask how the request was routed: any client of an app
+        // with explain_routing, otherwise admin-scoped keys only
+        const explain = request.headers.get(EXPLAIN_HEADER)?.toLowerCase() === 'true';
+        const explained = explain
+            && (app?.explainRouting === true || auth.scopes.some((s) => ADMIN_OPERATOR_SCOPES.includes(s)));
+        if (explain && !explained) {
+            warnings.add('explain', `${EXPLAIN_HEADER} requires an admin-scoped key for this app`);
+        }
+        this.recordSteps(scope, [routingStep(decision)]);
+
+        // Tool loops, JSON repairs, and retries call the provider again on
+        // their own; the request's budget caps its calls, tokens, and time
+        const callBudget = new RequestBudget(resolveCallBudget(this.config?.callBudget, app?.callBudget), (limit) => {
+            log.info('interaction_metadata', { call_budget: 'exhausted', call_budget_limit: limit });
+            this.callBudgets.record(app?.name ?? '', limit);
+        });
+
+        // Per-phase timings, reported once the response (or stream) completes
+        const timings = new TimingRecorder({
+            startedAt,
+            onFinish: (t) => this.finishInteraction(scope, t),
+        });
+
+        const chain = this.providerChain(scope, timings, callBudget);
+        const provider = chain.bind(selected);
+
+        // The tenant's provider allowlist is checked on the provider the
+        // request finally resolved to (after rewrites, fallbacks, and
+        // affinity) and on any a pipeline stage routes it to
+        const checkProviderPolicy = (name: string): void => {
+            if (allowedProviders && !allowedProviders.includes(name)) {
+                scope.policyDenied = name;
+                log.warn('provider_policy_denied', { provider: name, allowed: allowedProviders.join(', ') });
+                throw errProviderPolicyDenied(name);
+            }
+        };
+        const providerConfig = this.config?.providers.find((p) => p.name === provider.name);
+
+        // Upstream header rules: app first, provider rules take precedence
+        const upstreamHeaders = resolveUpstreamHeaders(
+            request.headers,
+            [app?.headers, providerConfig?.headers],
+            this.env,
+            (header, variable) => log.warn('header_env_unset', { header, variable }),
+        );
+
+        // Decided once; sampled-out requests are recorded without bodies
+        const recordingDecision = this.recording.decide(interactionId, app?.recording, request.headers);
+        this.interactionTails.open(interactionId, auth.tenantId);
+
+        let started = false;
+        const onDecoded = (decoded: { model: string; stream?: boolean | undefined }): void => {
+            if (started) return;
+            started = true;
+            interactionRecord.model = decoded.model;
+            interactionRecord.stream = decoded.stream === true;
+            const { rawRequest: _, ...recordedRequest } = decoded as { rawRequest?: unknown };
+            this.lifecycle?.start({ ...interactionRecord, request: recordingDecision.full ? JSON.stringify(recordedRequest) : undefined });
+            const events = this.storageProvider && this.recording.events;
+            events?.saveEvent(createInteractionEvent('interaction_started', interactionId, { ...interactionRecord })).catch((error: unknown) => {
+                log.warn('interaction_started_event_failed', { error: error instanceof Error ? error.message : String(error) });
+            });
+        };
+
+        // Long reconstructed conversations are summarized for apps with
+        // thread summaries on
+        const summaries = app?.threadSummary && app.threadSummary.enabled !== false
+            ? { app, config: app.threadSummary }
+            : undefined;
+
+        // Build frontdoor context
+        const ctx: FrontdoorContext = {
+            request: body ? body.replay(request) : request,
+            body,
+            provider,
+            auth,
+            app,
+            modelRewrite: selection.model,
+            templates: this.templates,
+            logger: log,
+            interactionId,
+            storage: this.storageProvider,
+            events: this.storageProvider && this.recording.events,
+            onDecoded,
+            catalog: this.router!.catalog,
+            parameterPolicies: this.config?.parameterPolicies,
+            pipeline: app ? this.pipelines.get(app.name) : undefined,
+            routeModel: (model) => this.router!.selectProvider(model, app, undefined, tenantRouting),
+            batches: this.batches,
+            replay: this.replay,
+            passthrough: app?.passthrough && app.passthrough.enabled !== false ? this.passthrough : undefined,
+            resolveProvider: (name) => {
+                const resolved = this.providers.get(name);
+                if (resolved) {
+                    checkProviderPolicy(resolved.name);
+                }
+                return resolved && chain.bind(resolved);
+            },
+            timings,
+            upstreamHeaders,
+            gatewayTools: this.resolveGatewayTools(app),
+            callBudget,
+            onUsage: (model, usage) => {
+                scope.streamedUsage = usage;
+                scope.streamedModel = model;
+                this.budgets.record(
+                    auth.tenantId,
+                    interactionId,
+                    model,
+                    usage,
+                    this.router!.catalog.estimateCost(model, usage),
+                );
+            },
+            warnings,
+            compactConversation: summaries && ((conversation) => {
+                // The summarizer only sees the tenant's threads if the
+                // tenant may use its provider
+                const summarizer = this.providers.get(summaries.config.provider);
+                if (summarizer && allowedProviders && !allowedProviders.includes(summarizer.name)) {
+                    log.warn('conversation_summary_skipped', { provider: summarizer.name, reason: 'provider_policy_denied' });
+                    return Promise.resolve(conversation.request.messages);
+                }
+                return this.compactConversation(
+                    conversation,
+                    summaries.app,
+                    summaries.config,
+                    summarizer && chain.limit(summarizer),
+                    auth.tenantId,
+                    interactionId,
+                    log,
+                );
+            }),
+            messageDedup: app && this.messageDedup.forApp(app.name, app.messageDedup),
+        };
+
+        // Handle request
+        try {
+            const handle = async (): Promise<Response> => {
+                // Mirrored once the request is known to run (idempotent
+                // replays don't)
+                this.mirrorRequest(app, request, rawBody, allowedProviders, log);
+                checkProviderPolicy(provider.name);
+                timings.record('authMs', startedAt);
+                const result = await frontdoor.handle(ctx);
+                scope.servedModel = result.canonicalRequest?.model;
+                scope.completed = result;
+                this.recordSteps(scope, result.transformations ?? []);
+                timings.settle();
+
+                // Cost tracking from catalog pricing
+                const usage = result.canonicalResponse?.usage;
+                if (usage && result.canonicalRequest) {
+                    const costUsd = this.router!.catalog.estimateCost(result.canonicalRequest.model, usage);
+                    log.info('request_usage', {
+                        model: result.canonicalRequest.model,
+                        promptTokens: usage.promptTokens,
+                        completionTokens: usage.completionTokens,
+                        costUsd,
+                    });
+                    this.budgets.record(auth.tenantId, interactionId, result.canonicalRequest.model, usage, costUsd);
+                }
+
+                // Remember the thread's provider, and the new response for
+                // requests that continue from it
+                if (threadKeys.length > 0 && result.response.ok) {
+                    const responseId = result.canonicalResponse?.id;
+                    const keys = responseId ? [...threadKeys, responseThreadKey(responseId)] : threadKeys;
+                    this.affinity?.remember(auth.tenantId, keys, provider.name, result.canonicalRequest?.model);
+                    this.recordThreadState(
+                        'thread_update',
+                        interactionId,
+                        auth.tenantId,
+                        app?.name,
+                        keys.map((threadKey) => ({ threadKey, provider: provider.name })),
+                    );
+                }
+
+                // Version settings are stamped so behavior changes can be
+                // matched to version changes
+                const metadata = {
+                    ...result.metadata,
+                    ...(providerConfig && versioningMetadata(providerVersioning(providerConfig))),
+                    ...(upstreamHeaders && { upstream_headers: upstreamHeaders.names.join(', ') }),
+                    ...(affinityBreak && { affinity_break: affinityBreak }),
+                };
+                if (Object.keys(metadata).length > 0) {
+                    log.info('interaction_metadata', metadata);
+                }
+
+                // Headers go out ahead of the first SSE event on streams
+                const headers = new Headers(result.response.headers);
+                headers.set(INTERACTION_ID_HEADER, interactionId);
+                const remaining = budget ? formatRemaining(budget) : undefined;
+                if (remaining !== undefined) {
+                    headers.set(BUDGET_REMAINING_HEADER, remaining);
+                }
+                if (scope.retryAfter !== undefined && result.response.status === 429) {
+                    headers.set('Retry-After', String(scope.retryAfter));
+                }
+                const clamped = clampedMaxTokens(result.transformations ?? []);
+                if (clamped !== undefined) {
+                    headers.set(MAX_TOKENS_CLAMPED_HEADER, String(clamped));
+                }
+                // Warnings go in a header, unless the response is a
+                // stream: those are only complete once it ends, so they
+                // follow the protocol's terminal event, in the usage
+                // trailer or an event of their own
+                let body: BodyInit | null = result.response.body;
+                const stream = isEventStream(result.response);
+                const json = result.response.ok && headers.get('Content-Type')?.startsWith('application/json') === true;
+                if (!stream && warnings.size > 0) {
+                    headers.set(WARNINGS_HEADER, formatWarningsHeader(warnings.list()));
+                    if (app?.embedWarnings && json) {
+                        body = embedWarnings(await result.response.text(), warnings.list());
+                        headers.delete('Content-Length');
+                    }
+                }
+                if (explained) {
+                    headers.set(ROUTING_HEADER, asciiJSON(decision));
+                    if (!stream && json) {
+                        const text = typeof body === 'string' ? body : await result.response.text();
+                        body = embedExtension(text, ROUTING_BODY_KEY, decision);
+                        headers.delete('Content-Length');
+                    }
+                }
+                if (result.response.body && stream && result.response.ok && app?.toolArgumentEvents) {
+                    body = interleaveToolArgumentEvents(result.response.body, () => scope.toolArgumentFields.splice(0));
+                }
+                if (body instanceof ReadableStream && stream && result.response.ok && !request.headers.has(NO_TRAILER_HEADER)) {
+                    if (app?.usageTrailer) {
+                        body = appendUsageTrailer(body, () => {
+                            const { streamedUsage } = scope;
+                            const model = scope.streamedModel ?? scope.servedModel ?? requestModel ?? 'unknown';
+                            return buildUsageTrailer({
+                                interactionId,
+                                provider: provider.name,
+                                model,
+                                usage: streamedUsage,
+                                costUsd: streamedUsage && this.router!.catalog.estimateCost(model, streamedUsage),
+                                warnings: warnings.list(),
+                            });
+                        });
+                    } else {
+                        body = appendWarningsEvent(body, () => warnings.list());
+                    }
+                }
+                return new Response(body, {
+                    status: result.response.status,
+                    statusText: result.response.statusText,
+                    headers,
+                });
+            };
+
+            return await this.runIdempotently(request, path, auth.tenantId, interactionId, rawBody, requestStream, handle);
+        } catch (error) {
+            log.error('Request handling failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            if (!scope.completed) {
+                this.failInteraction(scope, error);
+            }
+
+            if (error instanceof APIError) {
+                return this.errorResponse(error);
+            }
+
+            return this.errorResponse(
+                errServer(error instanceof Error ? error.message : 'Internal error'),
+            );
+        }
+    }
+
+    /**
+     * Records transformation steps applied to a request, in the log and as
+     * warnings for the client.
+     */
+    private recordSteps(scope: RequestScope, steps: TransformationStep[]): void {
+        scope.warnings.addSteps(steps);
+        for (const step of steps) {
+            scope.log.info('interaction_transformation', {
+                stage: step.stage,
+                description: step.description,
+                details: step.details,
+                warnings: step.warnings,
+            });
+        }
+    }
+
+    /**
+     * Builds the wrappers a request's providers are called through.
+     *
+     * Providers are wrapped in this order, innermost first, and each
+     * wrapper only sees what the ones before it pass on: attempts are
+     * recorded per upstream call; the concurrency slot, deadline, and
+     * call budget are taken once by a call coalesced requests share;
+     * the app's transforms rewrite what comes back before the frontdoor
+     * encodes it; the watchers see the transformed output the client gets;
+     * and the event size limit applies last, to what is written to the
+     * client. Calls the gateway makes on the request's behalf (thread
+     * summaries) take only its limits, being recorded as interactions of
+     * their own.
+     */
+    private providerChain(scope: RequestScope, timings: TimingRecorder, callBudget: RequestBudget): ProviderChain {
+        const { app, auth, interactionId, log, warnings } = scope;
+        const events = this.storageProvider && this.recording.events;
+
+        // Each provider call holds one of the tenant's concurrency slots,
+        // queued in the request's priority class; queueing shows in the
+        // timings, and a rejection's Retry-After on the response
+        const priority = classifyPriority(app, auth, scope.request.headers);
+        if (this.scheduler.enabled) {
+            log.info('interaction_metadata', { priority });
+        }
+        const scheduling = {
+            onStart: (slot: ConcurrencySlot) => timings.recordQueue(slot.waitMs, slot.queueDepth),
+            onReject: (error: ConcurrencyLimitError) => {
+                scope.retryAfter = error.retryAfterSeconds;
+                log.warn('concurrency_limit_rejected', { error: error.message, retryAfter: scope.retryAfter });
+            },
+        };
+        // The end user's hash is recorded with the request's stats
+        const onEndUser = (hash: string): void => {
+            scope.endUser = hash;
+        };
+        // A request that joins an identical one's provider call records no
+        // attempts of its own; its interaction points at the one it joined
+        const coalescing = {
+            app: app?.name ?? '',
+            interactionId,
+            signal: scope.request.signal,
+            onJoin: (leader: string) => log.info('interaction_metadata', { coalesced: 'true', coalesced_with: leader }),
+        };
+        const recordJsonOutcome = (outcome: JsonValidationOutcome): void => {
+            log.info('interaction_metadata', {
+                json_validation: outcome.status,
+                json_repair_attempts: String(outcome.repairAttempts),
+                ...(outcome.detail !== undefined && { json_validation_detail: outcome.detail }),
+            });
+            if (outcome.status === 'repaired') {
+                warnings.add('json_output', `response was not valid JSON; repaired after ${outcome.repairAttempts} retries`);
+            } else if (outcome.status === 'invalid') {
+                warnings.add('json_output', 'response is not valid JSON');
+            } else if (outcome.status === 'closed') {
+                warnings.add('json_output', 'stream ended mid-JSON; closed by the gateway');
+            }
+        };
+        const onText = scope.classification && ((text: string): void => {
+            scope.responseText = text;
+        });
+        // Output cut off at max_tokens is flagged to the client, and
+        // indexed for the admin API once the interaction finishes
+        const onTruncated = (): void => {
+            scope.truncated = true;
+            warnings.add(TRUNCATED_WARNING, TRUNCATED_MESSAGE);
+        };
+        // Streamed tool calls' argument fields are announced to the client
+        // as they complete, for apps that ask, and recorded for debugging
+        const onToolArgument = app?.toolArgumentEvents ? (field: ToolArgumentFieldEvent): void => {
+            scope.toolArgumentFields.push(field);
+            events?.saveEvent(createInteractionEvent('tool_argument_field', interactionId, field)).catch((error: unknown) => {
+                log.warn('tool_argument_event_failed', { error: error instanceof Error ? error.message : String(error) });
+            });
+        } : undefined;
+        // Stream events over the app's size limit are split, or cut short
+        // with a warning and their full payload kept in the event log
+        const onEventTruncated = (event: TruncatedEvent): void => {
+            warnings.add(EVENT_TRUNCATED_WARNING, `a ${event.type} stream event was cut short to fit the size limit (${Object.keys(event.original).join(', ')})`);
+            events?.saveEvent(createInteractionEvent('sse_event_truncated', interactionId, event)).catch((error: unknown) => {
+                log.warn('sse_event_truncated_event_failed', { error: error instanceof Error ? error.message : String(error) });
+            });
+        };
+        // Reported completion tokens are checked against the output relayed;
+        // the first discrepancy is recorded and indexed once the interaction
+        // finishes
+        const onUsageChecked = (comparison: UsageComparison): void => {
+            this.usageDiscrepancies.record(comparison);
+            if (comparison.discrepancy && !scope.usageDiscrepancy) {
+                scope.usageDiscrepancy = comparison;
+                log.warn('usage_discrepancy', { ...comparison });
+            }
+        };
+        const countOutput = (text: string, model: string, apiType: APIType): number =>
+            this.tokenCounter.counter.countText(text, model, apiType);
+
+        const limits: Array<(provider: Provider) => Provider> = [
+            (p) => withConcurrencyLimit(p, this.scheduler, auth.tenantId, scheduling, priority),
+            (p) => withDeadline(p, scope.call, this.deadlineCancellations),
+            (p) => withCallBudget(p, callBudget),
+        ];
+        const decorators: Array<(provider: Provider) => Provider> = [
+            (p) => withEndUser(p, this.endUsers, app?.forwardEndUser, onEndUser),
+            (p) => withAttemptRecording(p, scope.attempts),
+            ...limits,
+            (p) => withCoalescing(p, this.coalescer, app?.coalesce, coalescing),
+            (p) => withTransforms(p, app && this.transforms.get(app.name), (steps) => this.recordSteps(scope, steps)),
+            (p) => withJsonValidation(p, app?.validateJsonOutput, recordJsonOutcome),
+            (p) => withStreamRecording(p, {
+                events,
+                interactionId,
+                granularity: app?.eventGranularity,
+                rawResponseMaxBytes: app?.recording?.rawResponseMaxBytes,
+                logger: log,
+            }),
+            (p) => withTextCapture(p, onText),
+            (p) => withToolArgumentWatch(p, onToolArgument),
+            (p) => withTruncationWatch(p, onTruncated),
+            (p) => withUsageCheck(p, resolveUsageCheck(this.config?.usageCheck), countOutput, onUsageChecked),
+            (p) => withEventSizeLimit(p, app?.maxSseEventBytes, onEventTruncated),
+        ];
+        const wrap = (provider: Provider, wrappers: Array<(provider: Provider) => Provider>): Provider =>
+            wrappers.reduce((wrapped, decorate) => decorate(wrapped), provider);
+        return {
+            bind: (provider) => wrap(provider, decorators),
+            limit: (provider) => wrap(provider, limits),
+        };
+    }
+
+    /**
+     * Settles a request once its response (or stream) completes: logs its
+     * timings and warnings, records its latency, attempts, recording,
+     * lifecycle, SLOs, and stats, and publishes it.
+     */
+    private finishInteraction(scope: RequestScope, t: InteractionTimings): void {
+        const { app, auth, interactionId, log, warnings, attempts, completed, requestModel } = scope;
+        const model = scope.servedModel ?? requestModel ?? 'unknown';
+        log.info('interaction_timings', { ...t });
+        if (warnings.size > 0) {
+            log.info('interaction_metadata', { gateway_warnings: JSON.stringify(warnings.list()) });
+        }
+        if (scope.truncated) {
+            this.indexTruncation(interactionId, auth.tenantId, app?.name, model, log);
+        }
+        if (scope.usageDiscrepancy) {
+            this.indexUsageDiscrepancy(interactionId, auth.tenantId, app?.name, scope.usageDiscrepancy, log);
+        }
+        if (!completed?.metadata?.batch_id) {
+            this.latency.record(scope.provider, model, t);
+        }
+        if (!completed) {
+            return;
+        }
+
+        // A non-streaming request whose client went away is still
+        // recorded, as cancelled, with what is known
+        const cancelled = !scope.requestStream && clientAborted(scope.request.signal, scope.call.deadline);
+        if (cancelled) {
+            this.clientAborts.record(app?.name ?? '', t.totalMs ?? 0);
+            log.warn('client_aborted', { elapsedMs: t.totalMs });
+            log.info('interaction_metadata', { status: 'cancelled' });
+        }
+        const status = completed.response.status;
+        const usage = attempts.usage() ?? completed.canonicalResponse?.usage ?? scope.streamedUsage;
+        attempts.settle(status < 400);
+        const recorded = this.recording.settle(interactionId, {
+            error: cancelled || status >= 400,
+            durationMs: t.totalMs ?? 0,
+        });
+        if (app?.recording) {
+            log.info('interaction_metadata', recordingMetadata(recorded));
+        }
+        const ended = cancelled ? 'cancelled' : status >= 400 ? 'failed' : 'completed';
+        this.interactionTails.close(interactionId, ended);
+        void this.lifecycle?.finish(interactionId, {
+            status: ended,
+            statusCode: status,
+            errorType: scope.policyDenied && 'provider_policy_denied',
+        }, scope.interactionRecord);
+        // Streams are held to their time to first token, the wait an
+        // interactive client sees; client errors count for neither objective
+        if (app && !cancelled && !completed.metadata?.batch_id && (status < 400 || status >= 500)) {
+            void this.slos.record(app.name, {
+                latencyMs: (scope.requestStream ? t.firstTokenMs : undefined) ?? t.totalMs ?? 0,
+                error: status >= 500,
+            });
+        }
+        this.publishCompleted(auth.tenantId, interactionId, {
+            app,
+            frontdoor: scope.frontdoor,
+            provider: scope.provider,
+            result: completed,
+            usage,
+            timings: t,
+            recording: recorded,
+            errorType: scope.policyDenied && 'provider_policy_denied',
+            cancelled,
+            requestModel,
+        });
+        if (completed.metadata?.batch_id) {
+            return;
+        }
+
+        const outcome = {
+            usage,
+            latencyMs: t.totalMs,
+            error: status >= 400,
+            endUser: scope.endUser,
+            createdAt: new Date(),
+        };
+        // Classified stats are recorded once classification finishes, with
+        // its tags
+        const classifiers = scope.classification && !outcome.error && scope.responseText
+            ? this.classifiersFor(scope.classification)
+            : [];
+        if (classifiers.length === 0) {
+            this.recordRequestStat(auth.tenantId, interactionId, model, outcome);
+            return;
+        }
+        this.classifier.enqueue({
+            text: scope.responseText!,
+            classifiers,
+            logger: log,
+            done: (result) => {
+                const tags = classificationMetadata(result);
+                if (Object.keys(tags).length > 0) {
+                    log.info('interaction_metadata', tags);
+                }
+                this.recordRequestStat(auth.tenantId, interactionId, model, { ...outcome, ...result });
+            },
+        });
+    }
+
+    /**
+     * Settles a request that failed before its frontdoor returned.
+     */
+    private failInteraction(scope: RequestScope, error: unknown): void {
+        const { app, auth, interactionId, log, startedAt, requestModel } = scope;
+        const durationMs = Date.now() - startedAt;
+        this.recordRequestStat(auth.tenantId, interactionId, requestModel ?? 'unknown', {
+            usage: scope.attempts.usage(),
+            latencyMs: durationMs,
+            error: true,
+            endUser: scope.endUser,
+        });
+        scope.attempts.settle(false);
+        const recorded = this.recording.settle(interactionId, { error: true, durationMs });
+        if (app?.recording) {
+            log.info('interaction_metadata', recordingMetadata(recorded));
+        }
+        this.interactionTails.close(interactionId, 'failed');
+        void this.lifecycle?.finish(interactionId, {
+            status: 'failed',
+            statusCode: error instanceof APIError ? error.statusCode : 500,
+            errorType: scope.policyDenied ? 'provider_policy_denied' : error instanceof APIError ? error.type : 'server',
+        }, scope.interactionRecord);
+        if (app && !(error instanceof APIError && error.statusCode < 500)) {
+            void this.slos.record(app.name, { latencyMs: durationMs, error: true });
+        }
+        if (scope.policyDenied && error instanceof APIError) {
+            this.publishFailed(auth.tenantId, interactionId, {
+                app,
+                frontdoor: scope.frontdoor,
+                provider: scope.policyDenied,
+                model: requestModel ?? '',
+                stream: scope.requestStream,
+                error,
+                durationMs,
+            });
+        }
+    }
+
+    /**
+     * Routes a request's model, keeping a threaded conversation on the
+     * provider that served its previous turn while that provider is
+     * healthy and still routable. Records the thread state looked up.
+     *
+     * @throws APIError model_not_found as the router does
+     */
+    private async routeRequest(
+        model: string,
+        app: AppConfig | undefined,
+        tenantRouting: RoutingConfig | undefined,
+        requestBody: Record<string, unknown>,
+        tenantId: string,
+        interactionId: string,
+        log: Logger,
+    ): Promise<RequestRoute> {
+        let decision = this.router!.explain(model, app, undefined, tenantRouting);
+        let selection: ProviderSelection = selectionOf(decision);
+
+        const threadKeyPath = this.config?.providers
+            .find((p) => p.name === selection.providerName)?.responsesThreadKeyPath;
+        const threadKeys = this.affinity ? threadKeysOf(requestBody, threadKeyPath) : [];
+        const resolved: ThreadStateTouch[] = [];
+        let affinityBreak: string | undefined;
+        for (const threadKey of threadKeys) {
+            const sticky = await this.affinity!.lookup(tenantId, threadKey);
+            if (!sticky) {
+                resolved.push({ threadKey, outcome: 'miss' });
+                continue;
+            }
+            if (sticky !== selection.providerName) {
+                if (!this.providers.has(sticky) || !this.router!.allows(sticky, model, app, tenantRouting)) {
+                    affinityBreak = 'not_routable';
+                } else if (!this.providerHealthy(sticky)) {
+                    affinityBreak = 'unhealthy';
+                } else {
+                    selection = { providerName: sticky };
+                    decision = {
+                        ...decision,
+                        migratedTo: undefined,
+                        rewrite: undefined,
+                        rewriteResponseModel: undefined,
+                        provider: sticky,
+                        model,
+                    };
+                }
+            }
+            decision = {
+                ...decision,
+                affinity: { provider: sticky, outcome: affinityBreak ? 'broken' : 'hit', reason: affinityBreak },
+            };
+            resolved.push({ threadKey, provider: sticky, outcome: affinityBreak ? 'broken' : 'hit' });
+            if (affinityBreak) {
+                log.info('thread_affinity_broken', {
+                    provider: sticky,
+                    selected: selection.providerName,
+                    reason: affinityBreak,
+                });
+            }
+            break;
+        }
+        this.recordThreadState('thread_resolve', interactionId, tenantId, app?.name, resolved);
+        return { decision, selection, threadKeys, affinityBreak };
+    }
+
+    /**
+     * Mirrors a sample of the app's traffic; never awaited. The mirror's
+     * providers are out of reach of a tenant's provider allowlist, so
+     * restricted tenants are never mirrored.
+     */
+    private mirrorRequest(
+        app: AppConfig | undefined,
+        request: Request,
+        rawBody: string,
+        allowedProviders: string[] | undefined,
+        log: Logger,
+    ): void {
+        if (!app?.mirror || !rawBody) {
+            return;
+        }
+        if (allowedProviders) {
+            log.debug('mirror_skipped', { reason: 'provider_policy' });
+        } else {
+            void this.mirror.mirror(app.name, app.mirror, request, rawBody);
+        }
+    }
+
+    /**
+     * Runs a request's handling once per idempotency key, for endpoints
+     * that take one; retries with the key get the first run's response.
+     */
+    private runIdempotently(
+        request: Request,
+        path: string,
+        tenantId: string,
+        interactionId: string,
+        rawBody: string,
+        stream: boolean,
+        handle: () => Promise<Response>,
+    ): Promise<Response> {
+        const key = request.headers.get(IDEMPOTENCY_KEY_HEADER);
+        if (!this.idempotency || key === null || !isIdempotentEndpoint(request.method, path)) {
+            return handle();
+        }
+        return this.idempotency.execute({ tenantId, key, interactionId, body: rawBody, stream }, handle);
+    }
+
+    /**
+     * Creates a provider from configuration.
+     */
+    private createProvider(config: ProviderConfig): Provider {
+        if (config.http?.insecureSkipVerify) {
+            this.logger.warn('tls_verification_disabled', {
+                provider: config.name,
+                warning: 'insecure_skip_verify is set; upstream certificates are NOT verified',
+            });
+        }
+
+        const provider = this.providerRegistry.create(config.type, {
+            name: config.name,
+            apiKey: config.apiKey,
+            credentials: this.credentialsFor(config),
+            baseUrl: config.baseUrl,
+            fetch: this.httpClientFor(config)?.fetch,
+            requestTimeoutMs: parseDuration(config.requestTimeout, 0),
+            streamIdleTimeoutMs: parseDuration(config.streamIdleTimeout, 0),
+            deadlineHeader: config.deadline?.header,
+            deadlineTokensPerSecond: config.deadline?.tokensPerSecond,
+            apiVersion: config.apiVersion,
+            betaFeatures: config.betaFeatures,
+            organization: config.organization,
+            project: config.project,
+        });
+
+        // OpenAI supports n > 1 natively; other APIs fan out or reject it
+        const served = provider.apiType === 'openai'
+            ? provider
+            : withMultiChoice(provider, { fanOut: config.fanOutChoices ?? false });
+        return withModelCache(served, this.modelLists, parseDuration(config.modelListTtl, DEFAULT_MODEL_LIST_TTL_MS));
+    }
+
+    /**
+     * Returns a Message Batches client for an Anthropic provider.
+     */
+    private batchClientFor(name: string): AnthropicBatchClient | undefined {
+        const config = this.config?.providers.find((p) => p.name === name);
+        if (!config || this.providers.get(name)?.apiType !== 'anthropic') {
+            return undefined;
+        }
+        return new AnthropicBatchClient({
+            baseUrl: config.baseUrl,
+            // A secret key is resolved when the client is made, on every batch call
+            credentials: config.apiKeySecret
+                ? KeyPool.fromConfig(this.secrets.resolve(config.apiKeySecret))
+                : this.keyPoolFor(config),
+            fetch: this.httpClientFor(config)?.fetch,
+            apiVersion: config.apiVersion,
+            betaFeatures: config.betaFeatures,
+        });
+    }
+
+    /**
+     * Indexes an interaction whose output stopped at max_tokens by its
+     * finish reason and model, for the admin API's finish_reason filter.
+     * Never awaited.
+     */
+    private indexTruncation(
+        interactionId: string,
+        tenantId: string,
+        appName: string | undefined,
+        model: string,
+        log: Logger,
+    ): void {
+        log.info('interaction_metadata', { [FINISH_REASON_METADATA_KEY]: 'length' });
+        this.metadataIndex.indexMetadata({
+            interactionId,
+            tenantId,
+            appName,
+            metadata: { [FINISH_REASON_METADATA_KEY]: 'length', model },
+            createdAt: new Date(),
+        }).catch((error: unknown) => {
+            log.warn('metadata_index_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    /**
+     * Records a usage discrepancy on an interaction (both counts and their
+     * ratio) and indexes it by provider and model, for the admin API's
+     * has_usage_discrepancy filter. Never awaited.
+     */
+    private indexUsageDiscrepancy(
+        interactionId: string,
+        tenantId: string,
+        appName: string | undefined,
+        comparison: UsageComparison,
+        log: Logger,
+    ): void {
+        const metadata = {
+            [USAGE_DISCREPANCY_METADATA_KEY]: 'true',
+            usage_reported_tokens: String(comparison.reportedTokens),
+            usage_counted_tokens: String(comparison.countedTokens),
+            ...(comparison.ratio !== null && { usage_ratio: String(comparison.ratio) }),
+        };
+        log.info('interaction_metadata', metadata);
+        this.metadataIndex.indexMetadata({
+            interactionId,
+            tenantId,
+            appName,
+            metadata: { ...metadata, provider: comparison.provider, model: comparison.model },
+            createdAt: new Date(),
+        }).catch((error: unknown) => {
+            log.warn('metadata_index_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    /**
+     * Returns the classifiers an app's classification config asks for.
+     */
+    private classifiersFor(config: ResponseClassificationConfig): ResponseClassifier[] {
+        const classifiers: ResponseClassifier[] = [];
+        if (config.language !== false) {
+            classifiers.push(languageClassifier);
+        }
+        if (config.safety) {
+            classifiers.push(new ModerationClassifier({
+                provider: config.safety.provider,
+                target: this.moderationTargetFor(config.safety.provider),
+                model: config.safety.model,
+            }));
+        }
+        return classifiers;
+    }
+
+    /**
+     * Returns where an OpenAI provider's moderation requests go.
+     */
+    private moderationTargetFor(name: string): ModerationTarget | undefined {
+        const config = this.config?.providers.find((p) => p.name === name);
+        if (!config || this.providers.get(name)?.apiType !== 'openai') {
+            return undefined;
+        }
+        return {
+            baseUrl: config.baseUrl,
+            credentials: this.credentialsFor(config),
+            fetch: this.httpClientFor(config)?.fetch,
+        };
+    }
+
+    /**
+     * Returns where an Anthropic provider's passthrough requests go.
+     */
+    private passthroughTargetFor(name: string): PassthroughTarget | undefined {
+        const config = this.config?.providers.find((p) => p.name === name);
+        if (!config || this.providers.get(name)?.apiType !== 'anthropic') {
+            return undefined;
+        }
+        return {
+            baseUrl: config.baseUrl,
+            credentials: this.credentialsFor(config),
+            fetch: this.httpClientFor(config)?.fetch,
+            apiVersion: config.apiVersion,
+            betaFeatures: config.betaFeatures,
+        };
+    }
+
+    /**
+     * Returns the HTTP client for a provider, reusing the existing pool
+     * across reloads when the provider's endpoint and HTTP settings are unchanged.
+     */
+    private httpClientFor(config: ProviderConfig): ProviderHTTPClient | undefined {
+        if (!this.httpClientFactory) {
+            return undefined;
+        }
+
+        const key = JSON.stringify([config.baseUrl ?? '', config.http ?? {}]);
+        const existing = this.httpClients.get(config.name);
+        if (existing?.key === key) {
+            return existing.client;
+        }
+
+        existing?.client.close?.();
+        this.httpClients.delete(config.name);
+
+        const client = this.httpClientFactory(config);
+        if (client) {
+            this.httpClients.set(config.name, { key, client });
+        }
+        return client;
+    }
+
+    /**
+     * Returns where a provider's API keys come from: its secret, looked up
+     * on every request, or its key pool.
+     */
+    private credentialsFor(config: ProviderConfig): ProviderCredentials {
+        return config.apiKeySecret
+            ? new SecretCredentials(this.secrets, config.apiKeySecret)
+            : this.keyPoolFor(config);
+    }
+
+    /**
+     * Returns the provider's key pool, keeping rate-limit and health state
+     * across reloads while its keys and cooldown are unchanged.
+     */
+    private keyPoolFor(config: ProviderConfig): KeyPool {
+        const fingerprint = JSON.stringify([config.apiKey, config.apiKeys ?? [], config.keyCooldown ?? '']);
+        const existing = this.keyPools.get(config.name);
+        if (existing?.fingerprint === fingerprint) {
+            return existing.pool;
+        }
+
+        const pool = KeyPool.fromConfig(config.apiKey, config.apiKeys, {
+            provider: config.name,
+            cooldownMs: parseDuration(config.keyCooldown, DEFAULT_KEY_COOLDOWN_MS),
+            logger: this.logger,
+        });
+        this.keyPools.set(config.name, { fingerprint, pool });
+        return pool;
+    }
+
+    /**
+     * Closes HTTP clients and drops key pools for providers no longer in
+     * the configuration.
+     */
+    private pruneHTTPClients(providers: ProviderConfig[]): void {
+        const names = new Set(providers.map((p) => p.name));
+        for (const [name, entry] of this.httpClients) {
+            if (!names.has(name)) {
+                entry.client.close?.();
+                this.httpClients.delete(name);
+            }
+        }
+        for (const name of this.keyPools.keys()) {
+            if (!names.has(name)) {
+                this.keyPools.delete(name);
+            }
+        }
+    }
+
+    /**
+     * Applies a dual storage config's read side, fail mode, and queue
+     * size to the dual-write store, so a reload can switch reads over.
+     */
+    private configureDualWrite(config: GatewayConfig['storage']): void {
+        if (this.dualWrite && config?.type === 'dual') {
+            this.dualWrite.configure(dualWriteOptions(config));
+        }
+    }
+
+    /**
+     * Applies pending storage schema migrations when storage.autoMigrate
+     * is set. A failure aborts the load, so the gateway never serves
+     * against a schema it doesn't expect.
+     */
+    private async applyMigrations(config: GatewayConfig): Promise<void> {
+        if (!config.storage?.autoMigrate || !isMigratableStore(this.storageProvider)) {
+            return;
+        }
+
+        try {
+            const result = await this.storageProvider.migrate();
+            if (result.applied.length > 0 || result.baselined.length > 0) {
+                this.logger.info('storage_migrated', { ...result });
+            }
+        } catch (error) {
+            this.logger.error('storage_migration_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            throw error;
+        }
+    }
+
+    /**
+     * Creates the idempotency manager for a configuration.
+     * Returns undefined when Idempotency-Key handling is disabled.
+     */
+    private createIdempotencyManager(config: GatewayConfig): IdempotencyManager | undefined {
+        if (config.idempotency?.enabled === false) {
+            return undefined;
+        }
+
+        return new IdempotencyManager({
+            store: this.idempotencyStore,
+            ttlMs: parseDuration(config.idempotency?.ttl, DEFAULT_IDEMPOTENCY_TTL_MS),
+            logger: this.logger,
+        });
+    }
+
+    /**
+     * Creates the end-user ID hasher for a configuration.
+     * Returns undefined when no salt is configured.
+     */
+    private createEndUserHasher(config: GatewayConfig): EndUserHasher | undefined {
+        const salt = config.endUsers?.salt;
+        return salt ? new EndUserHasher(salt) : undefined;
+    }
+
+    /**
+     * Creates the thread affinity cache for a configuration.
+     * Returns undefined when thread affinity is not configured.
+     */
+    private createAffinity(config: GatewayConfig): ThreadAffinity | undefined {
+        const affinity = config.routing?.affinity;
+        if (!affinity || affinity.enabled === false) {
+            return undefined;
+        }
+
+        return new ThreadAffinity({
+            ttlMs: parseDuration(affinity.ttl, DEFAULT_AFFINITY_TTL_MS),
+            maxEntries: affinity.maxEntries,
+            store: this.storageProvider,
+            logger: this.logger,
+        });
+    }
+
+    /**
+     * Records an interaction's thread state lookups or writes as events,
+     * indexed by key hash so the admin API can list the interactions that
+     * touched a mapping. They carry no content, so they are saved whatever
+     * the app's recording mode. Never awaited.
+     */
+    private recordThreadState(
+        type: 'thread_resolve' | 'thread_update',
+        interactionId: string,
+        tenantId: string,
+        appName: string | undefined,
+        touches: ThreadStateTouch[],
+    ): void {
+        const storage = this.storageProvider;
+        if (!storage || touches.length === 0) return;
+
+        const record = async () => {
+            const metadata: Record<string, string> = {};
+            for (const { threadKey, ...touch } of touches) {
+                const keyHash = await sha256(affinityStateKey(tenantId, threadKey));
+                metadata[threadStateIndexKey(keyHash)] = THREAD_STATE_TOUCHED;
+                await storage.saveEvent(createInteractionEvent(type, interactionId, { keyHash, ...touch }));
+            }
+            await this.metadataIndex.indexMetadata({ interactionId, tenantId, appName, metadata, createdAt: new Date() });
+        };
+        record().catch((error: unknown) => {
+            this.logger.warn('thread_state_event_failed', {
+                interactionId,
+                type,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    /**
+     * Wraps thread state storage so deleted and purged mappings are also
+     * dropped from the thread affinity cache.
+     */
+    private evictingThreadState(storage: ThreadStateStore): ThreadStateStore {
+        return {
+            setThreadState: (threadKey, responseId) => storage.setThreadState(threadKey, responseId),
+            getThreadState: (threadKey) => storage.getThreadState(threadKey),
+            listThreadState: (options) => storage.listThreadState(options),
+            findThreadState: (keyHash) => storage.findThreadState(keyHash),
+            deleteThreadState: async (threadKey) => {
+                this.affinity?.forget(threadKey);
+                return storage.deleteThreadState(threadKey);
+            },
+            purgeThreadState: async (before) => {
+                this.affinity?.forgetBefore(before);
+                return storage.purgeThreadState(before);
+            },
+        };
+    }
+
+    /**
+     * Whether a provider has a usable key (not revoked or cooling down)
+     * and is not failing its synthetic probes.
+     */
+    private providerHealthy(name: string): boolean {
+        if (this.probes.degraded(name)) {
+            return false;
+        }
+        const keys = this.keyPools.get(name)?.pool.health();
+        return !keys || keys.some((k) => k.healthy && !k.coolingDownUntil);
+    }
+
+    /**
+     * Points the prober at the providers with a probe config. Probes use
+     * the provider as created, so key pool health sees their failures too.
+     */
+    private applyProbes(config: GatewayConfig): void {
+        const targets = new Map<string, ProbeTarget>();
+        for (const providerConfig of config.providers) {
+            const provider = this.providers.get(providerConfig.name);
+            if (providerConfig.probe && provider) {
+                targets.set(providerConfig.name, { provider, config: providerConfig.probe });
+            }
+        }
+        this.probes.configure(targets);
+    }
+
+    /**
+     * Points the SLO tracker at the apps with an slo config. Resolves once
+     * saved windows are restored.
+     */
+    private async applySlos(config: GatewayConfig): Promise<void> {
+        const slos = new Map<string, SloConfig>();
+        for (const app of config.apps) {
+            if (app.slo) {
+                slos.set(app.name, app.slo);
+            }
+        }
+        await this.slos.configure(slos);
+    }
+
+    /**
+     * Creates, replaces, or removes the analytics sink when the events
+     * config changes. A replaced sink is flushed in the background.
+     */
+    private applyEventsConfig(config: EventsConfig | undefined): void {
+        const key = config ? JSON.stringify(config) : '';
+        if (this.eventSink?.key === key || (!this.eventSink && !config)) {
+            return;
+        }
+
+        const previous = this.eventSink?.publisher;
+        this.eventSink = undefined;
+        previous?.close().catch((error: unknown) => {
+            this.logger.warn('event_sink_close_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+
+        if (!config) return;
+        try {
+            const sink = this.eventSinkFactory?.(config) ?? createEventSink(config);
+            this.eventSink = { key, publisher: createSinkEventPublisher(config, sink, this.logger) };
+            this.logger.info('event_sink_configured', { sink: sink.name, payload: config.payload ?? 'summary' });
+        } catch (error) {
+            this.logger.error('event_sink_failed', {
+                sink: config.sink,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+
+    /**
+     * Creates, replaces, or removes the write spill when the spill config
+     * changes. Records already spilled stay on disk and are replayed by
+     * the next spill over the same directory.
+     */
+    private applySpillConfig(config: SpillConfig | undefined): void {
+        const key = config ? JSON.stringify(config) : '';
+        if (this.spill?.key === key || (!this.spill && !config)) {
+            return;
+        }
+
+        const previous = this.spill?.queue;
+        this.spill = undefined;
+        previous?.close().catch((error: unknown) => {
+            this.logger.warn('spill_close_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+
+        if (!config) return;
+        try {
+            const storage = this.spillStorageFactory?.(config);
+            if (!storage) {
+                this.logger.warn('spill_unsupported', { dir: config.dir });
+                return;
+            }
+            const queue = new WriteSpill({
+                storage,
+                targets: this.spillTargets,
+                retryDelayMs: parseDuration(config.replayInterval, DEFAULT_SPILL_RETRY_MS),
+                logger: this.logger,
+            });
+            queue.start();
+            this.spill = { key, queue };
+            this.logger.info('spill_configured', { dir: config.dir });
+        } catch (error) {
+            this.logger.error('spill_failed', {
+                dir: config.dir,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+
+    /**
+     * Rebuilds the token counter when the configured encodings change.
+     * Families whose rank file can't be read fall back to the
+     * approximation.
+     */
+    private applyTokenCountConfig(config: TokenCountConfig | undefined): void {
+        const encodings = config?.encodings ?? {};
+        const key = JSON.stringify(encodings);
+        if (this.tokenCounter.key === key) {
+            return;
+        }
+
+        const exact: Partial<Record<TokenizerFamily, Tokenizer>> = {};
+        for (const family of EXACT_TOKENIZER_FAMILIES) {
+            const path = encodings[family as keyof typeof encodings];
+            if (!path) continue;
+            try {
+                const text = this.encodingLoader?.(path);
+                if (text === undefined) {
+                    this.logger.warn('token_encoding_unsupported', { family, path });
+                    continue;
+                }
+                exact[family] = new BpeTokenizer(parseTiktokenRanks(text), familyPattern(family));
+                this.logger.info('token_encoding_loaded', { family, path });
+            } catch (error) {
+                this.logger.error('token_encoding_failed', {
+                    family,
+                    path,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            }
+        }
+        this.tokenCounter = { key, counter: new TokenCounter(exact) };
+    }
+
+    /**
+     * Applies the concurrency caps and tenant overrides. The scheduler
+     * outlives reloads, so calls in flight keep their slots.
+     */
+    private applyConcurrencyConfig(config: GatewayConfig): void {
+        const concurrency: ConcurrencyConfig = config.concurrency ?? {};
+        const tenants: Record<string, TenantConcurrencyLimits> = {};
+        for (const tenant of config.tenants ?? []) {
+            if (tenant.concurrency) {
+                tenants[tenant.id] = tenant.concurrency;
+            }
+        }
+        const queueTimeoutMs = parseDuration(concurrency.queueTimeout, DEFAULT_QUEUE_TIMEOUT_MS);
+        this.scheduler.configure({
+            maxInFlight: concurrency.maxInFlight,
+            tenantMaxInFlight: concurrency.tenantMaxInFlight,
+            tenantProviderMaxInFlight: concurrency.tenantProviderMaxInFlight,
+            queueSize: concurrency.queueSize,
+            queueTimeoutMs,
+            priorityQueueTimeoutMs: Object.fromEntries(
+                Object.entries(concurrency.priorityQueueTimeouts ?? {})
+                    .map(([priority, timeout]) => [priority, parseDuration(timeout, queueTimeoutMs)]),
+            ),
+            lowPriorityShare: concurrency.lowPriorityShare,
+            tenants,
+        });
+    }
+
+    /**
+     * Wraps the usage store so failed writes go to the spill, when one is
+     * configured.
+     */
+    private spillingUsageStore(store: UsageStore): UsageStore {
+        return {
+            recordUsage: (record) => this.writeOrSpill({ kind: 'usage', record }),
+            sumUsage: (tenantId, since, options) => store.sumUsage(tenantId, since, options),
+        };
+    }
+
+    /**
+     * Wraps the usage stats store so failed writes go to the spill, when
+     * one is configured.
+     */
+    private spillingUsageStatsStore(store: UsageStatsStore): UsageStatsStore {
+        return {
+            recordRequestStat: (record) => this.writeOrSpill({ kind: 'request_stat', record }),
+            aggregateUsageStats: (tenantId, from, to, options) => store.aggregateUsageStats(tenantId, from, to, options),
+            ...(store.findRequestStatsByEndUser && {
+                findRequestStatsByEndUser: (tenantId: string, endUser: string) => store.findRequestStatsByEndUser!(tenantId, endUser),
+            }),
+            ...(store.findRequestStatsByClassification && {
+                findRequestStatsByClassification: (tenantId: string, filter: RequestStatClassificationFilter) =>
+                    store.findRequestStatsByClassification!(tenantId, filter),
+            }),
+        };
+    }
+
+    /**
+     * Writes a usage record or request stat, through the spill when one is
+     * configured.
+     */
+    private writeOrSpill(entry: SpillEntry): Promise<void> {
+        return this.spill ? this.spill.queue.write(entry) : writeSpillEntry(this.spillTargets, entry);
+    }
+
+    /**
+     * Publishes an interaction_completed event to the configured sink, or
+     * to the event publisher passed to the constructor. Never throws or
+     * waits on delivery.
+     */
+    private publishCompleted(
+        tenantId: string,
+        interactionId: string,
+        params: {
+            app: AppConfig | undefined;
+            frontdoor: string;
+            provider: string;
+            result: FrontdoorResponse;
+            usage: Usage | undefined;
+            timings: InteractionTimings;
+            recording: RecordingDecision;
+            errorType?: string | undefined;
+            cancelled?: boolean | undefined;
+            /** Model the client asked for, for responses without a canonical request. */
+            requestModel?: string | undefined;
+        },
+    ): void {
+        const { result, usage, recording, cancelled } = params;
+        const request = result.canonicalRequest ?? result.recorded?.request;
+        const response = result.canonicalResponse ?? result.recorded?.response;
+        this.publishInteraction(tenantId, interactionId, {
+            appName: params.app?.name,
+            frontdoor: params.frontdoor,
+            providerName: params.provider,
+            model: request?.model ?? params.requestModel ?? '',
+            stream: request?.stream ?? false,
+            statusCode: cancelled ? CLIENT_CLOSED_STATUS : result.response.status,
+            usage,
+            costUsd: usage && request ? this.router?.catalog.estimateCost(request.model, usage) : undefined,
+            finishReason: response?.choices[0]?.finishReason ?? undefined,
+            totalDurationMs: params.timings.totalMs ?? 0,
+            timings: params.timings,
+            errorType: cancelled ? 'client_cancelled' : params.errorType,
+            ...(cancelled && { status: 'cancelled' as const }),
+            ...(recording.full ? { request, response } : { recording: recording.reason }),
+        });
+    }
+
+    /**
+     * Publishes an interaction_completed event for a request that failed
+     * before its frontdoor produced a response.
+     */
+    private publishFailed(
+        tenantId: string,
+        interactionId: string,
+        params: {
+            app: AppConfig | undefined;
+            frontdoor: string;
+            provider: string;
+            model: string;
+            stream: boolean;
+            error: APIError;
+            durationMs: number;
+        },
+    ): void {
+        this.publishInteraction(tenantId, interactionId, {
+            appName: params.app?.name,
+            frontdoor: params.frontdoor,
+            providerName: params.provider,
+            model: params.model,
+            stream: params.stream,
+            statusCode: params.error.statusCode,
+            errorType: params.error.code ?? params.error.type,
+            totalDurationMs: params.durationMs,
+            timings: { totalMs: params.durationMs },
+        });
+    }
+
+    /**
+     * Records a message batch result line as an interaction: usage and
+     * batch-priced cost against the tenant's budget, and an
+     * interaction_completed event flagged as batch. Latency isn't recorded.
+     */
+    private recordBatchResult(batch: BatchRecord, result: MessageBatchResult): void {
+        const interactionId = `${batch.id}/${result.customId}`;
+        const response = result.response;
+        const usage = response?.usage;
+        const model = response?.model ?? '';
+        const listCost = usage && this.router?.catalog.estimateCost(model, usage);
+        const costUsd = listCost === undefined ? undefined : listCost * BATCH_PRICE_FACTOR;
+        if (usage) {
+            this.budgets.record(batch.tenantId, interactionId, model, usage, costUsd);
+        }
+        this.recordRequestStat(batch.tenantId, interactionId, model, { usage, error: result.type !== 'succeeded' });
+
+        this.logger.info('batch_result', {
+            batchId: batch.id,
+            customId: result.customId,
+            result: result.type,
+            tenantId: batch.tenantId,
+            costUsd,
+        });
+        this.publishInteraction(batch.tenantId, interactionId, {
+            appName: batch.appName,
+            frontdoor: 'anthropic',
+            providerName: batch.provider,
+            model,
+            stream: false,
+            statusCode: result.type === 'succeeded' ? 200 : 500,
+            usage,
+            costUsd,
+            finishReason: response?.choices[0]?.finishReason ?? undefined,
+            totalDurationMs: 0,
+            timings: {},
+            response,
+            batch: { id: batch.id, result: result.type },
+        });
+    }
+
+    /**
+     * Compacts a long reconstructed conversation with the app's thread
+     * summaries, noting on the request's interaction what was done.
+     */
+    private async compactConversation(
+        conversation: Conversation,
+        app: AppConfig,
+        config: ThreadSummaryConfig,
+        provider: Provider | undefined,
+        tenantId: string,
+        interactionId: string,
+        log: Logger,
+    ): Promise<Message[]> {
+        const result = await this.summaries.compact(conversation, {
+            thresholdTokens: config.thresholdTokens,
+            keepRecent: config.keepRecent,
+            tokens: this.tokenCounter.counter,
+            summarize: (messages, previous) => this.summarizeTurns(messages, previous, app, config, provider, tenantId, interactionId),
+        });
+        if (result.summarized !== undefined) {
+            log.info('interaction_metadata', {
+                conversation_summary: result.cached ? 'cached' : 'created',
+                summarized_messages: String(result.summarized),
+            });
+        } else if (result.truncated !== undefined) {
+            log.warn('conversation_summary_failed', { error: result.error, truncated: result.truncated });
+            log.info('interaction_metadata', { conversation_truncated: String(result.truncated) });
+        }
+        return result.messages;
+    }
+
+    /**
+     * Writes a conversation summary with the app's summarizer model, on
+     * the provider bound to the request's limits. The call is an
+     * interaction of its own, flagged internal, and counts in the tenant's
+     * usage reports only when the app says so.
+     */
+    private async summarizeTurns(
+        messages: Message[],
+        previous: string | undefined,
+        app: AppConfig,
+        config: ThreadSummaryConfig,
+        provider: Provider | undefined,
+        tenantId: string,
+        parentId: string,
+    ): Promise<string> {
+        if (!provider) {
+            throw errServer(`Provider '${config.provider}' not configured`);
+        }
+        const interactionId = randomUUID();
+        const startedAt = Date.now();
+        const log = requestLogger(this.logger, interactionId, tenantId).child({ app: app.name, provider: provider.name });
+        log.info('interaction_metadata', { internal: 'true', summarizes_for: parentId });
+
+        let response: CanonicalResponse | undefined;
+        let failure: unknown;
+        try {
+            response = await provider.complete({
+                tenantId,
+                model: config.model,
+                messages: summaryPrompt(messages, previous),
+                stream: false,
+                temperature: 0,
+            });
+        } catch (error) {
+            failure = error;
+        }
+        const totalMs = Date.now() - startedAt;
+        this.publishInteraction(tenantId, interactionId, {
+            appName: app.name,
+            frontdoor: app.frontdoor,
+            providerName: provider.name,
+            model: config.model,
+            stream: false,
+            statusCode: failure === undefined ? 200 : isAPIError(failure) ? failure.statusCode : 500,
+            usage: response?.usage,
+            costUsd: response?.usage && this.router?.catalog.estimateCost(config.model, response.usage),
+            finishReason: response?.choices[0]?.finishReason ?? undefined,
+            totalDurationMs: totalMs,
+            timings: { totalMs },
+            internal: true,
+        });
+        if (config.countUsage) {
+            this.recordRequestStat(tenantId, interactionId, config.model, {
+                usage: response?.usage,
+                latencyMs: totalMs,
+                error: failure !== undefined,
+            });
+        }
+        if (failure !== undefined) {
+            throw failure;
+        }
+
+        const summary = response?.choices[0]?.message.content.trim();
+        if (!summary) {
+            throw errServer('Summarizer returned an empty summary');
+        }
+        return summary;
+    }
+
+    /**
+     * Records a request's outcome for the tenant's usage reports.
+     */
+    private recordRequestStat(
+        tenantId: string,
+        interactionId: string,
+        model: string,
+        outcome: {
+            usage?: Usage | undefined;
+            latencyMs?: number | undefined;
+            error: boolean;
+            endUser?: string | undefined;
+            createdAt?: Date | undefined;
+            language?: string | undefined;
+            safetyFlags?: string[] | undefined;
+        },
+    ): void {
+        this.usageReports.record({
+            tenantId,
+            interactionId,
+            model,
+            promptTokens: outcome.usage?.promptTokens ?? 0,
+            completionTokens: outcome.usage?.completionTokens ?? 0,
+            totalTokens: outcome.usage?.totalTokens ?? 0,
+            latencyMs: outcome.latencyMs,
+            error: outcome.error,
+            createdAt: outcome.createdAt ?? new Date(),
+            endUser: outcome.endUser,
+            language: outcome.language,
+            safetyFlags: outcome.safetyFlags?.length ? outcome.safetyFlags : undefined,
+        });
+    }
+
+    /**
+     * Serves GET /v1/usage. Query parameters only pick the date range and
+     * grouping; the tenant always comes from the API key. Rate limited per
+     * tenant.
+     */
+    private async handleUsageReport(request: Request, url: URL, tenantId: string): Promise<Response> {
+        if (request.method !== 'GET') {
+            return this.errorResponse(errInvalidRequest(`${request.method} is not supported on ${USAGE_REPORT_PATH}`).withStatusCode(405));
+        }
+        try {
+            const range = this.usageReports.parseRange(url.searchParams);
+            const groupBy = this.usageReports.parseGrouping(url.searchParams);
+            const retryAfter = this.usageReports.throttle(tenantId);
+            if (retryAfter > 0) {
+                const response = this.errorResponse(errRateLimit('Too many usage report requests; try again later'));
+                response.headers.set('Retry-After', String(retryAfter));
+                return response;
+            }
+            return Response.json(await this.usageReports.report(tenantId, range, groupBy));
+        } catch (error) {
+            if (error instanceof APIError) {
+                return this.errorResponse(error);
+            }
+            this.logger.error('usage_report_failed', {
+                tenantId,
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return this.errorResponse(errServer('Usage report failed'));
+        }
+    }
+
+    /**
+     * Serves POST <app path>/v1/token_count: decodes the body as the app's
+     * frontdoor would and counts its input tokens against the model's
+     * catalog limits. The model defaults to the app's default model.
+     */
+    private async handleTokenCount(request: Request, app: AppConfig): Promise<Response> {
+        if (request.method !== 'POST') {
+            return this.errorResponse(errInvalidRequest(`${request.method} is not supported on ${TOKEN_COUNT_SUFFIX}`).withStatusCode(405));
+        }
+        const apiType = app.frontdoor === 'anthropic' ? 'anthropic' : 'openai';
+        let canonical: CanonicalRequest;
+        try {
+            const body = await readRequestBody(request, {
+                maxBytes: this.config?.server?.maxRequestBytes,
+                pool: this.bodyBuffers,
+            });
+            canonical = defaultCodecRegistry.get(apiType)!.decodeRequest(body);
+        } catch (error) {
+            return this.errorResponse(isAPIError(error) ? error : errInvalidRequest(`Failed to decode request: ${(error as Error).message}`));
+        }
+        canonical.model ||= app.defaultModel ?? '';
+        if (!canonical.model) {
+            return this.errorResponse(errInvalidRequest('model is required'));
+        }
+
+        const info = this.router!.catalog.get(canonical.model);
+        const count = this.tokenCounter.counter.countRequest(canonical, info?.apiType);
+        return Response.json(tokenCountResult(canonical.model, count, info, canonical.maxTokens));
+    }
+
+    /**
+     * Publishes interaction data, shaped per the events config.
+     */
+    private publishInteraction(tenantId: string, interactionId: string, data: InteractionCompletedData): void {
+        const publisher = this.eventSink?.publisher ?? this.eventPublisher;
+        if (!publisher) return;
+
+        const shaped = shapeInteractionData(data, this.config?.events);
+        const event = createLifecycleEvent('interaction_completed', interactionId, tenantId, shaped);
+        publisher.publish(event).catch((error: unknown) => {
+            this.logger.warn('event_publish_failed', {
+                interactionId,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    /**
+     * Fails the load if any app's correlation header list is invalid.
+     */
+    private checkCorrelationHeaders(apps: AppConfig[]): void {
+        for (const app of apps) {
+            try {
+                validateCorrelationHeaders(app.correlationHeaders);
+            } catch (error) {
+                throw new Error(`Invalid config for app '${app.name}': ${(error as Error).message}`);
+            }
+        }
+    }
+
+    /**
+     * Fails the load if apps sharing a path use different frontdoors, or a
+     * key is bound to an app that doesn't exist.
+     */
+    private checkSharedPaths(config: GatewayConfig): void {
+        const byPath = new Map<string, AppConfig>();
+        for (const app of config.apps) {
+            const path = app.path.replace(/\/$/, '');
+            const first = byPath.get(path);
+            if (!first) {
+                byPath.set(path, app);
+            } else if (first.frontdoor !== app.frontdoor) {
+                throw new Error(
+                    `Invalid config for app '${app.name}': shares path '${app.path}' with app '${first.name}' `
+                    + `but uses frontdoor '${app.frontdoor}', not '${first.frontdoor}'`,
+                );
+            }
+        }
+        const names = new Set(config.apps.map((a) => a.name));
+        for (const tenant of config.tenants ?? []) {
+            for (const key of tenant.apiKeys ?? []) {
+                if (key.app !== undefined && !names.has(key.app)) {
+                    throw new Error(`Invalid config for tenant '${tenant.id}': an API key is bound to unknown app '${key.app}'`);
+                }
+            }
+        }
+    }
+
+    /**
+     * Fails the load if any provider's version pinning is invalid.
+     */
+    private checkProviderVersioning(providers: ProviderConfig[]): void {
+        for (const provider of providers) {
+            try {
+                validateProviderVersioning(provider);
+            } catch (error) {
+                throw new Error(`Invalid config for provider '${provider.name}': ${(error as Error).message}`);
+            }
+        }
+    }
+
+    /**
+     * Compiles each app's response transforms. An invalid transform fails
+     * the load before any of the new config is applied.
+     */
+    private createTransforms(apps: AppConfig[]): Map<string, ResponseTransform[]> {
+        const transforms = new Map<string, ResponseTransform[]>();
+        for (const app of apps) {
+            if (!app.transforms?.length) continue;
+            try {
+                transforms.set(app.name, compileTransforms(app.transforms));
+            } catch (error) {
+                throw new Error(`Invalid config for app '${app.name}': ${(error as Error).message}`);
+            }
+        }
+        return transforms;
+    }
+
+    /**
+     * Builds the route table: the gateway's own endpoints, each app's
+     * frontdoor routes and token count endpoint, and the frontdoors'
+     * routes outside any app.
+     */
+    private createRouteTable(router: Router, apps: AppConfig[]): RouteTable {
+        return new RouteTable({
+            apps,
+            frontdoors: this.frontdoorRegistry.list().flatMap((name) => this.frontdoorRegistry.get(name) ?? []),
+            matchApp: (path) => router.matchApp(path),
+            gatewayRoutes: [
+                { method: 'GET', path: '/health' },
+                { method: 'GET', path: '/healthz' },
+                { method: 'GET', path: '/readyz' },
+                { method: 'GET', path: USAGE_REPORT_PATH },
+            ],
+            appRoutes: [{ method: 'POST', path: `/v1${TOKEN_COUNT_SUFFIX}` }],
+        });
+    }
+
+    /**
+     * Builds each app's middleware: its CORS (the cors config or a cors
+     * entry), which runs before authentication, and the rest of its
+     * middleware list in order, which runs after. Throws if any app's CORS
+     * config is invalid or its list names unregistered middleware or has
+     * invalid parameters.
+     */
+    private createAppMiddleware(apps: AppConfig[]): { cors: Map<string, HttpMiddleware>; chains: Map<string, HttpMiddleware> } {
+        const cors = new Map<string, HttpMiddleware>();
+        const chains = new Map<string, HttpMiddleware>();
+        const context = { logger: this.logger };
+        for (const app of apps) {
+            const entries = app.middleware ?? [];
+            const isCors = (entry: AppMiddlewareConfig): boolean => entry.name === 'cors';
+            try {
+                if (app.cors) {
+                    if (entries.some(isCors)) {
+                        throw new Error('cors is configured twice: use either cors or a cors middleware entry');
+                    }
+                    validateCors(app.cors);
+                    cors.set(app.name, corsMiddleware(app.cors));
+                } else if (entries.some(isCors)) {
+                    cors.set(app.name, buildAppMiddleware(entries, this.middlewareRegistry, context, isCors));
+                }
+                if (!entries.every(isCors)) {
+                    chains.set(app.name, buildAppMiddleware(entries, this.middlewareRegistry, context, (entry) => !isCors(entry)));
+                }
+            } catch (error) {
+                throw new Error(`Invalid config for app '${app.name}': ${(error as Error).message}`);
+            }
+        }
+        return { cors, chains };
+    }
+
+    /**
+     * Builds each app's middleware pipeline from its configured webhook
+     * stages. Webhook HTTP clients are rebuilt on every load.
+     */
+    private createPipelines(apps: AppConfig[]): Map<string, PipelineExecutor> {
+        for (const client of this.webhookClients) {
+            client.close?.();
+        }
+        this.webhookClients = [];
+
+        const pipelines = new Map<string, PipelineExecutor>();
+        for (const app of apps) {
+            const stages = app.pipeline?.stages ?? [];
+            if (stages.length === 0) continue;
+
+            pipelines.set(app.name, createExecutor(stages.map((stage) => {
+                if (stage.insecureSkipVerify) {
+                    this.logger.warn('tls_verification_disabled', {
+                        app: app.name,
+                        stage: stage.name,
+                        warning: 'insecure_skip_verify is set; webhook certificates are NOT verified',
+                    });
+                }
+
+                const client = this.webhookClientFactory?.(stage);
+                if (client) {
+                    this.webhookClients.push(client);
+                }
+
+                const timeoutMs = parseDuration(stage.timeout, 5000);
+                const signingSecret = stage.signingSecret;
+                return {
+                    name: stage.name,
+                    type: stage.type,
+                    step: createWebhookStep({
+                        type: 'webhook',
+                        url: stage.url,
+                        headers: stage.headers,
+                        timeoutMs,
+                        retries: stage.retries,
+                        signingKey: signingSecret && (() => this.secrets.resolve(signingSecret)),
+                        fetch: client?.fetch,
+                    }),
+                    timeoutMs,
+                    onError: stage.onError,
+                    onDeny: stage.onDeny,
+                    order: stage.order,
+                    when: stage.when,
+                    cache: stage.cache && {
+                        ttlMs: parseDuration(stage.cache.ttl, DEFAULT_STAGE_CACHE_TTL_MS),
+                        key: stage.cache.key as StageCacheKeyField[] | undefined,
+                        cacheDenies: stage.cache.cacheDenies,
+                        maxEntries: stage.cache.maxEntries,
+                    },
+                };
+            }), {
+                logger: this.logger,
+                hasProvider: (name) => this.providers.has(name),
+                events: this.storageProvider && this.recording.events,
+                countTokens: (request) => this.tokenCounter.counter.countRequest(request).inputTokens,
+            }));
+        }
+        return pipelines;
+    }
+
+    /**
+     * Builds the HTTP-backed tools from the top-level tools list and warns
+     * about apps that reference tools that don't exist.
+     */
+    private createGatewayTools(config: GatewayConfig): Map<string, GatewayTool> {
+        const tools = new Map<string, GatewayTool>();
+        for (const tool of config.tools ?? []) {
+            tools.set(tool.name, createHTTPTool({
+                name: tool.name,
+                description: tool.description,
+                url: tool.url,
+                parameters: tool.parameters,
+                headers: tool.headers,
+                timeoutMs: parseDuration(tool.timeout, 10000),
+            }));
+        }
+
+        for (const app of config.apps) {
+            for (const name of app.gatewayTools?.tools ?? []) {
+                if (!tools.has(name) && !this.toolRegistry.get(name)) {
+                    this.logger.warn('gateway_tool_unknown', { app: app.name, tool: name });
+                }
+            }
+        }
+        return tools;
+    }
+
+    /**
+     * Resolves the gateway tools an app exposes; configured tools shadow
+     * registered ones with the same name.
+     */
+    private resolveGatewayTools(app: AppConfig | undefined): GatewayTool[] | undefined {
+        const tools = (app?.gatewayTools?.tools ?? [])
+            .map((name) => this.configTools.get(name) ?? this.toolRegistry.get(name))
+            .filter((tool): tool is GatewayTool => tool !== undefined);
+        return tools.length > 0 ? tools : undefined;
     }
 
     /**
@@ -0,0 +1,128 @@
+import { describe, it, expect, vi } from 'vitest';
+import { resolveUpstreamHeaders, validateHeaderRules, expandEnvRefs } from './utils/headers';
+import { OpenAIProvider } from './providers/index';
+
+const client = (headers: Record<string, string> = {}) => new Headers(headers);
+
+describe('resolveUpstreamHeaders', () => {
+    it('should return undefined when no rules are configured', () => {
+        expect(resolveUpstreamHeaders(client(), [undefined, undefined], {})).toBeUndefined();
+    });
+
+    it('should pass through listed client headers only', () => {
+        const headers = resolveUpstreamHeaders(
+            client({ 'anthropic-beta': 'tools-2024', 'x-other': 'no' }),
+            [{ passthrough: ['Anthropic-Beta', 'x-trace-id'] }],
+            {},
+        );
+
+        expect(headers!.apply({})).toEqual({ 'anthropic-beta': 'tools-2024' });
+    });
+
+    it('should let inject beat passthrough and provider rules beat app rules', () => {
+        const headers = resolveUpstreamHeaders(
+            client({ 'x-team': 'from-client' }),
+            [
+                { passthrough: ['x-team'], inject: { 'X-Env': 'app' } },
+                { inject: { 'x-team': 'from-config', 'x-env': 'provider' } },
+            ],
+            {},
+        );
+
+        expect(headers!.apply({})).toEqual({ 'x-team': 'from-config', 'x-env': 'provider' });
+    });
+
+    it('should let strip beat inject and remove base headers', () => {
+        const headers = resolveUpstreamHeaders(
+            client(),
+            [{ inject: { 'x-debug': '1' } }, { strip: ['X-Debug', 'User-Agent'] }],
+            {},
+        );
+
+        expect(headers!.apply({ 'User-Agent': 'sdk/1.0', 'Content-Type': 'application/json' })).toEqual({
+            'Content-Type': 'application/json',
+        });
+        expect(headers!.names).toEqual(['-x-debug', '-user-agent']);
+    });
+
+    it('should never touch protected headers', () => {
+        const headers = resolveUpstreamHeaders(
+            client({ authorization: 'Bearer client-key', cookie: 'a=b' }),
+            [{ passthrough: ['authorization', 'cookie'], inject: { 'X-Api-Key': 'x' }, strip: ['Authorization'] }],
+            {},
+        );
+
+        expect(headers).toBeUndefined();
+    });
+
+    it('should expand env references and skip unset ones', () => {
+        const onUnset = vi.fn();
+        const headers = resolveUpstreamHeaders(
+            client(),
+            [{ inject: { 'OpenAI-Organization': '${env:OPENAI_ORG}', 'OpenAI-Project': '${env:OPENAI_PROJECT}' } }],
+            { OPENAI_ORG: 'org-123' },
+            onUnset,
+        );
+
+        expect(headers!.apply({})).toEqual({ 'openai-organization': 'org-123' });
+        expect(onUnset).toHaveBeenCalledWith('openai-project', 'OPENAI_PROJECT');
+    });
+
+    it('should serialize header names without values', () => {
+        const headers = resolveUpstreamHeaders(client(), [{ inject: { 'x-secret': 'hunter2' } }], {});
+
+        expect(JSON.stringify({ upstreamHeaders: headers })).toBe('{"upstreamHeaders":{"headers":["x-secret"]}}');
+    });
+});
+
+describe('validateHeaderRules', () => {
+    it('should reject rules that reference protected headers', () => {
+        expect(() => validateHeaderRules({ inject: { Authorization: 'Bearer x' } })).toThrow(
+            "header 'Authorization' is protected",
+        );
+        expect(() => validateHeaderRules({ strip: ['transfer-encoding'] })).toThrow('protected');
+        expect(() => validateHeaderRules({ passthrough: ['anthropic-beta'], strip: ['user-agent'] })).not.toThrow();
+    });
+});
+
+describe('expandEnvRefs', () => {
+    it('should expand references inside larger values', () => {
+        expect(expandEnvRefs('team=${env:TEAM};v=2', { TEAM: 'core' })).toBe('team=core;v=2');
+        expect(expandEnvRefs('plain', {})).toBe('plain');
+    });
+});
+
+describe('provider header rules', () => {
+    it('should send injected headers without overriding credentials', async () => {
+        let sent: Record<string, string> = {};
+        const fetch = vi.fn(async (_url: string, init: RequestInit) => {
+            sent = init.headers as Record<string, string>;
+            return Response.json({
+                id: 'chatcmpl-1',
+                object: 'chat.completion',
+                created: 0,
+                model: 'gpt-4o',
+                choices: [{ index: 0, message: { role: 'assistant', content: 'ok' }, finish_reason: 'stop' }],
+                usage: { prompt_tokens: 1, completion_tokens: 1, total_tokens: 2 },
+            });
+        });
+        const provider = new OpenAIProvider({ name: 'openai', apiKey: 'sk-real', fetch: fetch as any });
+
+        await provider.complete({
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'hi' }],
+            userAgent: 'client/1.0',
+            upstreamHeaders: resolveUpstreamHeaders(
+                client({ authorization: 'Bearer sk-client' }),
+                [{ passthrough: ['authorization'], inject: { 'OpenAI-Organization': 'org-1' }, strip: ['user-agent'] }],
+                {},
+            ),
+        } as any);
+
+        expect(sent).toEqual({
+            'Authorization': 'Bearer sk-real',
+            'Content-Type': 'application/json',
+            'openai-organization': 'org-1',
+        });
+    });
+});
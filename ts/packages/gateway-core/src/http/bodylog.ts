@@ -0,0 +1,81 @@
+/**
+ * Request and response body logging.
+ *
+ * Logs each request's body and, for non-streaming responses, the
+ * response's, truncated to a byte budget, for debugging an app's
+ * clients. Bodies are logged at info: listing the middleware is the
+ * opt-in. Bodies may carry prompts and keys, so keep it off public apps.
+ *
+ * @module http/bodylog
+ */
+
+import type { Logger } from '../utils/logging.js';
+import type { HttpMiddleware } from './middleware.js';
+import { getRequestId } from './middleware.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default bytes of each body logged. */
+export const DEFAULT_BODY_LOG_MAX_BYTES = 4096;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Options for the body logging middleware.
+ */
+export interface BodyLoggingOptions {
+    /** Logger bodies are written to. */
+    logger: Logger;
+
+    /** Bytes of each body logged (default 4096). */
+    maxBytes?: number | undefined;
+}
+
+// ============================================================================
+// Middleware
+// ============================================================================
+
+/**
+ * Creates middleware logging request and response bodies. Streamed
+ * (text/event-stream) response bodies are not logged.
+ */
+export function bodyLoggingMiddleware(options: BodyLoggingOptions): HttpMiddleware {
+    const { logger } = options;
+    const maxBytes = options.maxBytes ?? DEFAULT_BODY_LOG_MAX_BYTES;
+
+    return (handler) => async (request) => {
+        const path = new URL(request.url).pathname;
+        const requestId = getRequestId(request) || undefined;
+        if (request.body) {
+            logger.info('request_body', {
+                requestId,
+                method: request.method,
+                path,
+                body: truncate(await request.clone().text(), maxBytes),
+            });
+        }
+
+        const response = await handler(request);
+        const contentType = response.headers.get('content-type') ?? '';
+        if (response.body && !contentType.includes('text/event-stream')) {
+            // Read from a copy without holding up the response
+            void response.clone().text().then(
+                (body) => logger.info('response_body', { requestId, path, status: response.status, body: truncate(body, maxBytes) }),
+                () => undefined,
+            );
+        }
+        return response;
+    };
+}
+
+function truncate(text: string, maxBytes: number): string {
+    const bytes = new TextEncoder().encode(text);
+    if (bytes.length <= maxBytes) {
+        return text;
+    }
+    return `${new TextDecoder().decode(bytes.slice(0, maxBytes)).replace(/\uFFFD$/, '')}…[${bytes.length - maxBytes} bytes truncated]`;
+}
@@ -0,0 +1,123 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import type { CorsConfig } from '../ports/index';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const ORIGIN = 'https://app.example.com';
+
+const chat = { messages: [{ role: 'user', content: 'Hi' }] };
+
+describe('App CORS', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(cors: CorsConfig = {
+        allowedOrigins: [ORIGIN],
+        exposeHeaders: ['X-Request-Id', 'X-Gateway-Interaction-Id'],
+        maxAge: 600,
+    }) {
+        const authenticate = vi.fn(async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }));
+        await gateway?.close();
+        gateway = await harness()
+            .app({ name: 'browser', frontdoor: 'openai', path: '/v1/chat/completions', cors })
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses', cors })
+            .app({ name: 'server', frontdoor: 'anthropic', path: '/v1/messages' })
+            .provider(new ScriptedProvider('mock', { text: 'Hi' }))
+            .options({ auth: { authenticate, getTenant: async () => null } })
+            .start();
+        const gw = gateway;
+        const preflight = (path: string, origin = ORIGIN) => gw.request(path, {
+            method: 'OPTIONS',
+            headers: {
+                Origin: origin,
+                'Access-Control-Request-Method': 'POST',
+                'Access-Control-Request-Headers': 'authorization, content-type',
+            },
+        });
+        const send = (path: string, body: object, origin = ORIGIN) =>
+            gw.post(path, { model: 'gpt-4o', ...body }, { Origin: origin });
+        return { gateway: gw, authenticate, preflight, send };
+    }
+
+    it('should answer preflights without authenticating or recording an interaction', async () => {
+        const { authenticate, preflight } = await setup();
+
+        const response = await preflight('/v1/chat/completions');
+
+        expect(response.status).toBe(204);
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        expect(response.headers.get('Access-Control-Allow-Methods')).toContain('POST');
+        expect(response.headers.get('Access-Control-Allow-Headers')).toBe('Authorization, Content-Type');
+        expect(response.headers.get('Access-Control-Max-Age')).toBe('600');
+        expect(response.headers.get('Vary')).toBe('Origin');
+        expect(response.headers.get('Access-Control-Allow-Credentials')).toBeNull();
+        expect(response.headers.has('X-Gateway-Interaction-Id')).toBe(false);
+        expect(authenticate).not.toHaveBeenCalled();
+    });
+
+    it('should echo the origin and exposed headers on actual requests', async () => {
+        const { send } = await setup();
+
+        const response = await send('/v1/chat/completions', chat);
+
+        expect(response.status).toBe(200);
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        expect(response.headers.get('Access-Control-Expose-Headers')).toBe('X-Request-Id, X-Gateway-Interaction-Id');
+        expect(response.headers.get('X-Gateway-Interaction-Id')).toBeTruthy();
+    });
+
+    it('should refuse preflights from other origins and omit headers on their requests', async () => {
+        const { authenticate, preflight, send } = await setup();
+
+        const refused = await preflight('/v1/chat/completions', 'https://evil.example');
+        const response = await send('/v1/chat/completions', chat, 'https://evil.example');
+
+        expect(refused.status).toBe(403);
+        expect(refused.headers.get('Access-Control-Allow-Origin')).toBeNull();
+        expect(authenticate).toHaveBeenCalledOnce();
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBeNull();
+    });
+
+    it('should only apply to the configuring app', async () => {
+        const { authenticate, preflight, send } = await setup();
+
+        const response = await send('/v1/messages', { max_tokens: 64, ...chat });
+        const unanswered = await preflight('/v1/messages');
+
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBeNull();
+        expect(unanswered.status).toBe(405);
+        expect(unanswered.headers.get('Access-Control-Allow-Origin')).toBeNull();
+        expect(authenticate).toHaveBeenCalledOnce();
+    });
+
+    it('should cover streaming on the Responses route', async () => {
+        const { preflight, send } = await setup();
+
+        const allowed = await preflight('/v1/responses');
+        const response = await send('/v1/responses', { input: 'Hi', stream: true });
+
+        expect(allowed.status).toBe(204);
+        expect(response.headers.get('Content-Type')).toContain('text/event-stream');
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        expect(await response.text()).toContain('response.completed');
+    });
+
+    it('should send * for any-origin apps without credentials', async () => {
+        const { preflight } = await setup({ allowedOrigins: ['*'] });
+
+        const response = await preflight('/v1/chat/completions', 'https://anywhere.example');
+
+        expect(response.headers.get('Access-Control-Allow-Origin')).toBe('*');
+        expect(response.headers.get('Vary')).toBeNull();
+    });
+
+    it('should reject wildcard origins with credentials and malformed origins at load', async () => {
+        await expect((await setup({ allowedOrigins: ['*'], allowCredentials: true })).gateway.gateway.reload())
+            .rejects.toThrow("Invalid config for app 'browser': cors.allowed_origins: '*' cannot be combined with allow_credentials");
+        await expect((await setup({ allowedOrigins: ['https://app.example.com/path'] })).gateway.gateway.reload())
+            .rejects.toThrow('is not an origin');
+    });
+});
@@ -0,0 +1,141 @@
+/**
+ * Per-app CORS middleware.
+ *
+ * Browser clients calling an app directly get CORS headers for the
+ * origins the app allows. Preflights (OPTIONS with an
+ * Access-Control-Request-Method header) are answered here, before
+ * authentication, so they never count as interactions; browsers send
+ * them for every SSE request because of the Authorization header.
+ *
+ * @module http/cors
+ */
+
+import type { CorsConfig } from '../ports/config.js';
+import type { HttpMiddleware } from './middleware.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Request headers allowed when an app lists none. */
+export const DEFAULT_CORS_ALLOWED_HEADERS = ['Authorization', 'Content-Type'];
+
+/** Methods the gateway's data-plane routes accept. */
+const ALLOWED_METHODS = 'GET, POST, DELETE, OPTIONS';
+
+/** RFC 9110 field-name token. */
+const HEADER_NAME = /^[!#$%&'*+.^_`|~0-9A-Za-z-]+$/;
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Throws if a CORS config names an invalid origin or header, or allows
+ * credentials from any origin.
+ */
+export function validateCors(cors: CorsConfig | undefined): void {
+    if (!cors) return;
+    if (!Array.isArray(cors.allowedOrigins) || cors.allowedOrigins.length === 0) {
+        throw new Error('cors.allowed_origins: at least one origin is required');
+    }
+    for (const origin of cors.allowedOrigins) {
+        if (origin === '*') {
+            if (cors.allowCredentials) {
+                throw new Error("cors.allowed_origins: '*' cannot be combined with allow_credentials");
+            }
+            continue;
+        }
+        if (typeof origin !== 'string' || !isOrigin(origin)) {
+            throw new Error(`cors.allowed_origins: '${origin}' is not an origin (scheme://host[:port])`);
+        }
+    }
+    for (const [field, names] of [['allowed_headers', cors.allowedHeaders], ['expose_headers', cors.exposeHeaders]] as const) {
+        for (const name of names ?? []) {
+            if (typeof name !== 'string' || !HEADER_NAME.test(name)) {
+                throw new Error(`cors.${field}: '${name}' is not a valid header name`);
+            }
+        }
+    }
+    if (cors.maxAge !== undefined && (!Number.isInteger(cors.maxAge) || cors.maxAge < 0)) {
+        throw new Error('cors.max_age must be a non-negative number of seconds');
+    }
+}
+
+function isOrigin(value: string): boolean {
+    try {
+        const url = new URL(value);
+        return (url.protocol === 'http:' || url.protocol === 'https:') && url.origin === value;
+    } catch {
+        return false;
+    }
+}
+
+// ============================================================================
+// Middleware
+// ============================================================================
+
+/**
+ * Creates middleware applying an app's CORS config. Preflights from
+ * allowed origins get a 204 and from other origins a 403, without
+ * reaching the handler. Other requests are handled as usual; responses
+ * to allowed origins carry CORS headers, and others carry none, so the
+ * browser blocks them.
+ */
+export function corsMiddleware(cors: CorsConfig): HttpMiddleware {
+    const anyOrigin = cors.allowedOrigins.includes('*');
+    const origins = new Set(cors.allowedOrigins);
+    const allowedHeaders = (cors.allowedHeaders ?? DEFAULT_CORS_ALLOWED_HEADERS).join(', ');
+    const exposeHeaders = cors.exposeHeaders?.join(', ');
+
+    const allowOrigin = (origin: string, headers: Headers): void => {
+        if (anyOrigin && !cors.allowCredentials) {
+            headers.set('Access-Control-Allow-Origin', '*');
+        } else {
+            headers.set('Access-Control-Allow-Origin', origin);
+            headers.append('Vary', 'Origin');
+        }
+        if (cors.allowCredentials) {
+            headers.set('Access-Control-Allow-Credentials', 'true');
+        }
+    };
+
+    return (handler) => async (request) => {
+        const origin = request.headers.get('Origin');
+        if (!origin) {
+            return handler(request);
+        }
+        const allowed = anyOrigin || origins.has(origin);
+
+        // Preflight
+        if (request.method === 'OPTIONS' && request.headers.has('Access-Control-Request-Method')) {
+            if (!allowed) {
+                return new Response(null, { status: 403 });
+            }
+            const headers = new Headers({
+                'Access-Control-Allow-Methods': ALLOWED_METHODS,
+                'Access-Control-Allow-Headers': allowedHeaders,
+            });
+            allowOrigin(origin, headers);
+            if (cors.maxAge !== undefined) {
+                headers.set('Access-Control-Max-Age', String(cors.maxAge));
+            }
+            return new Response(null, { status: 204, headers });
+        }
+
+        const response = await handler(request);
+        if (!allowed) {
+            return response;
+        }
+        const headers = new Headers(response.headers);
+        allowOrigin(origin, headers);
+        if (exposeHeaders) {
+            headers.set('Access-Control-Expose-Headers', exposeHeaders);
+        }
+        return new Response(response.body, {
+            status: response.status,
+            statusText: response.statusText,
+            headers,
+        });
+    };
+}
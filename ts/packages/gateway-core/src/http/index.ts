@@ -32,3 +32,29 @@ export {
     composeMiddleware,
     createStandardMiddleware,
 } from './middleware.js';
+
+export {
+    corsMiddleware,
+    validateCors,
+    DEFAULT_CORS_ALLOWED_HEADERS,
+} from './cors.js';
+
+export {
+    clientRateLimitMiddleware,
+    type ClientRateLimitOptions,
+    type RateLimitKey,
+} from './ratelimit.js';
+
+export {
+    bodyLoggingMiddleware,
+    DEFAULT_BODY_LOG_MAX_BYTES,
+    type BodyLoggingOptions,
+} from './bodylog.js';
+
+export {
+    createHttpMiddlewareRegistry,
+    buildAppMiddleware,
+    type HttpMiddlewareRegistry,
+    type HttpMiddlewareFactory,
+    type HttpMiddlewareContext,
+} from './registry.js';
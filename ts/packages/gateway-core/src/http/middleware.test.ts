@@ -0,0 +1,150 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import type { AppConfig } from '../ports/index';
+import { createHttpMiddlewareRegistry, type HttpMiddlewareRegistry } from './index';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const ORIGIN = 'https://demo.example.com';
+
+const chat = { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] };
+
+const demo: AppConfig = {
+    name: 'demo',
+    frontdoor: 'openai',
+    path: '/demo',
+    middleware: [
+        { name: 'cors', params: { allowed_origins: [ORIGIN] } },
+        { name: 'ratelimit', params: { requests: 2, window: '1m' } },
+    ],
+};
+
+const internal: AppConfig = {
+    name: 'internal',
+    frontdoor: 'openai',
+    path: '/internal',
+    middleware: [{ name: 'bodylog' }, { name: 'timeout', params: { duration: '5s' } }],
+};
+
+describe('Per-app middleware', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(apps: AppConfig[], middlewareRegistry?: HttpMiddlewareRegistry) {
+        const logger = spyLogger();
+        const authenticate = vi.fn(async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }));
+        await gateway?.close();
+        const builder = harness().provider(new ScriptedProvider('mock', { text: 'Hi' }));
+        for (const app of apps) {
+            builder.app(app);
+        }
+        gateway = await builder
+            .options({ logger, middlewareRegistry, auth: { authenticate, getTenant: async () => null } })
+            .start();
+        const gw = gateway;
+        const send = (path: string, headers: Record<string, string> = {}) =>
+            gw.post(path, chat, { Origin: ORIGIN, ...headers });
+        return { gateway: gw, logger, authenticate, send };
+    }
+
+    it('should give two apps on the same gateway their own chains', async () => {
+        const { send, logger, authenticate } = await setup([demo, internal]);
+
+        const first = await send('/demo/chat/completions');
+        expect(first.status).toBe(200);
+        expect(first.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        expect((await send('/demo/chat/completions')).status).toBe(200);
+        const limited = await send('/demo/chat/completions');
+        expect(limited.status).toBe(429);
+        expect(limited.headers.get('Retry-After')).toMatch(/^\d+$/);
+        expect(limited.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        expect(authenticate).toHaveBeenCalledTimes(3);
+        expect(logged(logger.info, 'request_body')).toEqual([]);
+
+        for (let i = 0; i < 3; i++) {
+            const response = await send('/internal/chat/completions');
+            expect(response.status).toBe(200);
+            expect(response.headers.get('Access-Control-Allow-Origin')).toBeNull();
+        }
+        expect(logged(logger.info, 'request_body')).toContainEqual(expect.objectContaining({
+            path: '/internal/chat/completions',
+            body: expect.stringContaining('"model":"gpt-4o"'),
+        }));
+    });
+
+    it('should count clients separately and run entries outermost first', async () => {
+        const order: string[] = [];
+        const registry = createHttpMiddlewareRegistry();
+        for (const name of ['outer', 'inner']) {
+            registry.register(name, () => (handler) => async (request) => {
+                order.push(name);
+                return handler(request);
+            });
+        }
+        const { send } = await setup([{
+            name: 'demo',
+            frontdoor: 'openai',
+            path: '/demo',
+            middleware: [{ name: 'outer' }, { name: 'inner' }, { name: 'ratelimit', params: { requests: 1 } }],
+        }], registry);
+
+        expect((await send('/demo/chat/completions')).status).toBe(200);
+        expect((await send('/demo/chat/completions')).status).toBe(429);
+        expect((await send('/demo/chat/completions', { Authorization: 'Bearer other' })).status).toBe(200);
+        expect(order.slice(0, 2)).toEqual(['outer', 'inner']);
+    });
+
+    it('should run the chain only once the key is accepted, with CORS before it', async () => {
+        const ran: string[] = [];
+        const registry = createHttpMiddlewareRegistry();
+        registry.register('trace', () => (handler) => async (request) => {
+            ran.push(new URL(request.url).pathname);
+            return handler(request);
+        });
+        const { gateway: gw, send, authenticate } = await setup([{
+            ...demo,
+            middleware: [{ name: 'cors', params: { allowed_origins: [ORIGIN] } }, { name: 'trace' }],
+        }], registry);
+
+        authenticate.mockResolvedValueOnce(null as any);
+        const rejected = await send('/demo/chat/completions');
+        expect(rejected.status).toBe(401);
+        expect(rejected.headers.get('Access-Control-Allow-Origin')).toBe(ORIGIN);
+        const preflight = await gw.request('/demo/chat/completions', {
+            method: 'OPTIONS',
+            headers: { Origin: ORIGIN, 'Access-Control-Request-Method': 'POST' },
+        });
+        expect(preflight.status).toBe(204);
+        expect(ran).toEqual([]);
+
+        expect((await send('/demo/chat/completions')).status).toBe(200);
+        expect(ran).toEqual(['/demo/chat/completions']);
+    });
+
+    it('should key ip limits on the hop before the trusted proxies', async () => {
+        const { send } = await setup([{
+            ...demo,
+            middleware: [{ name: 'ratelimit', params: { requests: 1, key: 'ip', trusted_proxies: 1 } }],
+        }]);
+        const from = (client: string, forged: string) => ({ 'X-Forwarded-For': `${forged}, ${client}, 10.0.0.1` });
+
+        expect((await send('/demo/chat/completions', from('198.51.100.7', '1.1.1.1'))).status).toBe(200);
+        expect((await send('/demo/chat/completions', from('198.51.100.7', '2.2.2.2'))).status).toBe(429);
+        expect((await send('/demo/chat/completions', from('198.51.100.8', '1.1.1.1'))).status).toBe(200);
+    });
+
+    it('should reject unknown middleware and invalid parameters at load', async () => {
+        const reload = async (app: AppConfig) => (await setup([app])).gateway.gateway.reload();
+
+        await expect(reload({ ...demo, middleware: [{ name: 'gzip' }] }))
+            .rejects.toThrow("Invalid config for app 'demo': middleware[0]: unknown middleware 'gzip' (registered: bodylog, cors, ratelimit, timeout)");
+        await expect(reload({ ...internal, middleware: [{ name: 'timeout', params: { duration: 'soon' } }] }))
+            .rejects.toThrow("middleware[0] (timeout): duration must be a duration like '30s', got 'soon'");
+        await expect(reload({ ...demo, cors: { allowedOrigins: [ORIGIN] } }))
+            .rejects.toThrow('cors is configured twice');
+        await expect(reload({ ...demo, middleware: [{ name: 'ratelimit', params: { requests: 1, trusted_proxies: -1 } }] }))
+            .rejects.toThrow('middleware[0] (ratelimit): trusted_proxies must be a non-negative integer, got -1');
+    });
+});
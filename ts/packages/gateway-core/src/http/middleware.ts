@@ -29,6 +29,9 @@ export interface RequestContext {
 
     /** Rate limit info to write as response headers. */
     rateLimits?: HttpRateLimitInfo | undefined;
+
+    /** When the timeout middleware gives up on the request (epoch ms). */
+    deadline?: number | undefined;
 }
 
 /**
@@ -178,7 +181,7 @@ export interface LoggingMiddlewareOptions {
  * Logs HTTP requests with structured logging.
  */
 export function loggingMiddleware(options: LoggingMiddlewareOptions): HttpMiddleware {
-    const { logger, skipPaths = ['/health', '/healthz', '/ready'] } = options;
+    const { logger, skipPaths = ['/health', '/healthz', '/ready', '/readyz'] } = options;
 
     return (handler) => async (request) => {
         const url = new URL(request.url);
@@ -238,27 +241,29 @@ export function loggingMiddleware(options: LoggingMiddlewareOptions): HttpMiddle
 
 /**
  * Enforces request timeouts.
- * Uses AbortController to cancel long-running requests.
+ * Uses AbortController to cancel long-running requests, and records the
+ * deadline in the request context so provider calls can be cut off with it.
+ * The client's own abort still goes through.
  */
 export function timeoutMiddleware(timeoutMs: number): HttpMiddleware {
     return (handler) => async (request) => {
         const controller = new AbortController();
         const timeoutId = setTimeout(() => controller.abort(), timeoutMs);
+        const onClientAbort = (): void => controller.abort(request.signal.reason);
+        if (request.signal.aborted) {
+            onClientAbort();
+        } else {
+            request.signal.addEventListener('abort', onClientAbort, { once: true });
+        }
 
         try {
             // Create a new request with the abort signal
-            const newRequest = new Request(request.url, {
-                method: request.method,
-                headers: request.headers,
-                body: request.body,
-                signal: controller.signal,
-            });
+            const newRequest = new Request(request, { signal: controller.signal });
 
             // Copy context to new request
-            const ctx = getRequestContext(request);
-            if (ctx) {
-                requestContexts.set(newRequest, ctx);
-            }
+            const ctx = ensureRequestContext(request);
+            ctx.deadline = Date.now() + timeoutMs;
+            requestContexts.set(newRequest, ctx);
 
             return await handler(newRequest);
         } catch (error) {
@@ -279,6 +284,7 @@ export function timeoutMiddleware(timeoutMs: number): HttpMiddleware {
             throw error;
         } finally {
             clearTimeout(timeoutId);
+            request.signal.removeEventListener('abort', onClientAbort);
         }
     };
 }
@@ -0,0 +1,116 @@
+/**
+ * Per-client request rate limiting.
+ *
+ * Counts each client's requests in fixed windows and answers those over
+ * the limit with a 429 and a Retry-After, without reaching the handler.
+ * Clients are told apart by API key (Authorization or x-api-key) or by
+ * address; counters are per process and start over when the app's config
+ * is reloaded.
+ *
+ * The address is a hop from X-Forwarded-For counted from the right, since
+ * only hops appended by proxies the gateway trusts are reliable: with no
+ * trusted proxies it is the right-most entry, which the runtime (the Node
+ * server, or Cloudflare's edge) appends from the connection itself.
+ *
+ * @module http/ratelimit
+ */
+
+import type { HttpMiddleware } from './middleware.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** How clients are told apart. */
+export type RateLimitKey = 'api_key' | 'ip';
+
+/**
+ * Options for the client rate limit middleware.
+ */
+export interface ClientRateLimitOptions {
+    /** Requests allowed per client per window. */
+    requests: number;
+
+    /** Window length in ms. */
+    windowMs: number;
+
+    /** How clients are told apart (default: api_key, falling back to ip). */
+    key?: RateLimitKey | undefined;
+
+    /** Proxies in front of the gateway whose hops are skipped (default: 0). */
+    trustedProxies?: number | undefined;
+
+    /** Clock (default: Date.now). */
+    now?: (() => number) | undefined;
+}
+
+// ============================================================================
+// Middleware
+// ============================================================================
+
+/**
+ * Creates middleware limiting each client to `requests` per window.
+ */
+export function clientRateLimitMiddleware(options: ClientRateLimitOptions): HttpMiddleware {
+    const now = options.now ?? Date.now;
+    const counts = new Map<string, number>();
+    let windowStart = now();
+
+    return (handler) => async (request) => {
+        const t = now();
+        if (t - windowStart >= options.windowMs) {
+            // Every client's window starts over together, which keeps the
+            // map to one window's clients
+            windowStart = t - ((t - windowStart) % options.windowMs);
+            counts.clear();
+        }
+
+        const client = clientKey(request, options.key ?? 'api_key', options.trustedProxies ?? 0);
+        const count = (counts.get(client) ?? 0) + 1;
+        counts.set(client, count);
+        if (count <= options.requests) {
+            return handler(request);
+        }
+
+        const retryAfter = Math.max(1, Math.ceil((windowStart + options.windowMs - t) / 1000));
+        return new Response(
+            JSON.stringify({
+                error: {
+                    type: 'rate_limit_error',
+                    message: `Rate limit exceeded: ${options.requests} requests per ${options.windowMs / 1000}s`,
+                },
+            }),
+            {
+                status: 429,
+                headers: { 'Content-Type': 'application/json', 'Retry-After': String(retryAfter) },
+            },
+        );
+    };
+}
+
+/**
+ * Identifies a request's client: its API key, or its address when keyed
+ * by ip or when it sends no key.
+ */
+function clientKey(request: Request, key: RateLimitKey, trustedProxies: number): string {
+    if (key === 'api_key') {
+        const apiKey = request.headers.get('authorization') ?? request.headers.get('x-api-key');
+        if (apiKey) {
+            return `key:${apiKey}`;
+        }
+    }
+    return `ip:${clientAddress(request, trustedProxies) ?? 'unknown'}`;
+}
+
+/**
+ * Returns the X-Forwarded-For hop just before the trusted proxies'. Hops
+ * further left were sent by the client and are never used, so a forged
+ * header cannot change the address.
+ */
+function clientAddress(request: Request, trustedProxies: number): string | undefined {
+    const hops = (request.headers.get('x-forwarded-for') ?? '')
+        .split(',')
+        .map((hop) => hop.trim())
+        .filter((hop) => hop !== '');
+    return hops.length > 0 ? hops[Math.max(0, hops.length - 1 - trustedProxies)] : undefined;
+}
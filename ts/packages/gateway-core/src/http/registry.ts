@@ -0,0 +1,184 @@
+/**
+ * Named HTTP middleware for per-app chains.
+ *
+ * Apps list the middleware wrapping their routes by name, with
+ * parameters; the names resolve to factories in a registry that starts
+ * with the built-ins (cors, ratelimit, bodylog, timeout) and can take
+ * more. A chain runs outermost first, inside the gateway's own request
+ * handling and after authentication, so only accepted keys reach it. cors
+ * is the exception: it runs before authentication, since preflights carry
+ * no key.
+ *
+ * @module http/registry
+ */
+
+import type { AppMiddlewareConfig, CorsConfig } from '../ports/config.js';
+import type { Logger } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+import { composeMiddleware, timeoutMiddleware, type HttpMiddleware } from './middleware.js';
+import { corsMiddleware, validateCors } from './cors.js';
+import { clientRateLimitMiddleware, type RateLimitKey } from './ratelimit.js';
+import { bodyLoggingMiddleware } from './bodylog.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * What a middleware factory gets besides its parameters.
+ */
+export interface HttpMiddlewareContext {
+    /** Gateway logger. */
+    logger: Logger;
+}
+
+/**
+ * Builds a middleware from its config parameters. Throws if they are
+ * invalid.
+ */
+export type HttpMiddlewareFactory = (params: Record<string, unknown>, context: HttpMiddlewareContext) => HttpMiddleware;
+
+/**
+ * Registry of named middleware factories.
+ */
+export interface HttpMiddlewareRegistry {
+    /**
+     * Registers a factory, replacing any with the same name.
+     */
+    register(name: string, factory: HttpMiddlewareFactory): void;
+
+    /**
+     * Gets a factory by name.
+     */
+    get(name: string): HttpMiddlewareFactory | undefined;
+
+    /**
+     * Lists registered names.
+     */
+    list(): string[];
+}
+
+// ============================================================================
+// Registry
+// ============================================================================
+
+/**
+ * Creates a middleware registry holding the built-ins.
+ */
+export function createHttpMiddlewareRegistry(): HttpMiddlewareRegistry {
+    const factories = new Map<string, HttpMiddlewareFactory>(Object.entries(BUILTIN_MIDDLEWARE));
+
+    return {
+        register(name: string, factory: HttpMiddlewareFactory): void {
+            factories.set(name, factory);
+        },
+
+        get(name: string): HttpMiddlewareFactory | undefined {
+            return factories.get(name);
+        },
+
+        list(): string[] {
+            return Array.from(factories.keys());
+        },
+    };
+}
+
+/**
+ * Builds an app's middleware chain from the entries `select` accepts
+ * (default: all), first entry outermost. Throws if an entry names
+ * unregistered middleware or its parameters are invalid.
+ */
+export function buildAppMiddleware(
+    entries: AppMiddlewareConfig[],
+    registry: HttpMiddlewareRegistry,
+    context: HttpMiddlewareContext,
+    select: (entry: AppMiddlewareConfig) => boolean = () => true,
+): HttpMiddleware {
+    const middlewares = entries.flatMap((entry, i) => {
+        if (!select(entry)) return [];
+        const factory = registry.get(entry.name);
+        if (!factory) {
+            throw new Error(
+                `middleware[${i}]: unknown middleware '${entry.name}' (registered: ${registry.list().sort().join(', ')})`,
+            );
+        }
+        try {
+            return factory(entry.params ?? {}, context);
+        } catch (error) {
+            throw new Error(`middleware[${i}] (${entry.name}): ${(error as Error).message}`);
+        }
+    });
+    return composeMiddleware(...middlewares);
+}
+
+// ============================================================================
+// Built-in Middleware
+// ============================================================================
+
+const BUILTIN_MIDDLEWARE: Record<string, HttpMiddlewareFactory> = {
+    cors: (params) => {
+        const cors: CorsConfig = {
+            allowedOrigins: param(params, 'allowed_origins') as string[],
+            allowedHeaders: param(params, 'allowed_headers') as string[] | undefined,
+            exposeHeaders: param(params, 'expose_headers') as string[] | undefined,
+            maxAge: param(params, 'max_age') as number | undefined,
+            allowCredentials: param(params, 'allow_credentials') as boolean | undefined,
+        };
+        validateCors(cors);
+        return corsMiddleware(cors);
+    },
+
+    ratelimit: (params) => {
+        const requests = param(params, 'requests');
+        if (!(typeof requests === 'number' && Number.isInteger(requests) && requests > 0)) {
+            throw new Error(`requests must be a positive integer, got ${String(requests)}`);
+        }
+        const key = param(params, 'key');
+        if (key !== undefined && key !== 'api_key' && key !== 'ip') {
+            throw new Error(`key must be 'api_key' or 'ip', got '${String(key)}'`);
+        }
+        const trustedProxies = param(params, 'trusted_proxies');
+        if (trustedProxies !== undefined && !(typeof trustedProxies === 'number' && Number.isInteger(trustedProxies) && trustedProxies >= 0)) {
+            throw new Error(`trusted_proxies must be a non-negative integer, got ${String(trustedProxies)}`);
+        }
+        return clientRateLimitMiddleware({
+            requests,
+            windowMs: duration(params, 'window', 60_000),
+            key: key as RateLimitKey | undefined,
+            trustedProxies: trustedProxies as number | undefined,
+        });
+    },
+
+    bodylog: (params, { logger }) => {
+        const maxBytes = param(params, 'max_bytes');
+        if (maxBytes !== undefined && !(typeof maxBytes === 'number' && Number.isInteger(maxBytes) && maxBytes > 0)) {
+            throw new Error(`max_bytes must be a positive integer, got ${String(maxBytes)}`);
+        }
+        return bodyLoggingMiddleware({ logger, maxBytes: maxBytes as number | undefined });
+    },
+
+    timeout: (params) => timeoutMiddleware(duration(params, 'duration')),
+};
+
+/**
+ * Reads a parameter by its snake_case name or its camelCase form.
+ */
+function param(params: Record<string, unknown>, name: string): unknown {
+    return params[name] ?? params[name.replace(/_([a-z])/g, (_, c: string) => c.toUpperCase())];
+}
+
+/**
+ * Reads a duration parameter ("500ms", "30s", "5m"); required unless it
+ * has a fallback.
+ */
+function duration(params: Record<string, unknown>, name: string, fallbackMs?: number): number {
+    const value = param(params, name);
+    if (value === undefined && fallbackMs !== undefined) {
+        return fallbackMs;
+    }
+    const ms = typeof value === 'string' ? parseDuration(value, NaN) : NaN;
+    if (!(ms > 0)) {
+        throw new Error(`${name} must be a duration like '30s', got ${value === undefined ? 'none' : `'${String(value)}'`}`);
+    }
+    return ms;
+}
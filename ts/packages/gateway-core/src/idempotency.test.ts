@@ -0,0 +1,192 @@
+import { describe, it, expect, vi } from 'vitest';
+import {
+    IdempotencyManager,
+    MemoryIdempotencyStore,
+    IDEMPOTENT_REPLAY_HEADER,
+    isIdempotentEndpoint,
+} from './idempotency/index';
+import { APIError } from './domain/errors';
+
+function jsonResponse(body: unknown, status = 200): Response {
+    return new Response(JSON.stringify(body), {
+        status,
+        headers: { 'Content-Type': 'application/json' },
+    });
+}
+
+describe('IdempotencyManager', () => {
+    const baseRequest = {
+        tenantId: 'tenant-a',
+        key: 'key-1',
+        interactionId: 'int-1',
+        body: '{"model":"gpt-4"}',
+        stream: false,
+    };
+
+    it('should replay the stored response on retry', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        const run = vi.fn().mockImplementation(async () => jsonResponse({ id: 'chatcmpl-1' }));
+
+        const first = await manager.execute(baseRequest, run);
+        const second = await manager.execute({ ...baseRequest, interactionId: 'int-2' }, run);
+
+        expect(run).toHaveBeenCalledTimes(1);
+        expect(first.headers.get(IDEMPOTENT_REPLAY_HEADER)).toBeNull();
+        expect(second.headers.get(IDEMPOTENT_REPLAY_HEADER)).toBe('true');
+        expect(await second.json()).toEqual({ id: 'chatcmpl-1' });
+    });
+
+    it('should scope keys by tenant', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        const run = vi.fn().mockImplementation(async () => jsonResponse({ ok: true }));
+
+        await manager.execute(baseRequest, run);
+        await manager.execute({ ...baseRequest, tenantId: 'tenant-b' }, run);
+
+        expect(run).toHaveBeenCalledTimes(2);
+    });
+
+    it('should re-execute after a failed original', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        const run = vi.fn()
+            .mockImplementationOnce(async () => jsonResponse({ error: 'boom' }, 502))
+            .mockImplementationOnce(async () => jsonResponse({ id: 'ok' }));
+
+        const first = await manager.execute(baseRequest, run);
+        const second = await manager.execute(baseRequest, run);
+
+        expect(first.status).toBe(502);
+        expect(second.status).toBe(200);
+        expect(run).toHaveBeenCalledTimes(2);
+    });
+
+    it('should re-execute after a client error', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        const run = vi.fn()
+            .mockImplementationOnce(async () => jsonResponse({ error: 'bad request' }, 400))
+            .mockImplementationOnce(async () => jsonResponse({ id: 'ok' }));
+
+        const first = await manager.execute(baseRequest, run);
+        const second = await manager.execute(baseRequest, run);
+
+        expect(first.status).toBe(400);
+        expect(second.status).toBe(200);
+        expect(second.headers.get(IDEMPOTENT_REPLAY_HEADER)).toBeNull();
+        expect(run).toHaveBeenCalledTimes(2);
+    });
+
+    it('should reject a reused key with a different body', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        await manager.execute(baseRequest, async () => jsonResponse({}));
+
+        await expect(
+            manager.execute({ ...baseRequest, body: '{"model":"gpt-4o"}' }, async () => jsonResponse({})),
+        ).rejects.toMatchObject({ statusCode: 422 });
+    });
+
+    it('should wait for an in-flight non-streaming original', async () => {
+        const manager = new IdempotencyManager({
+            store: new MemoryIdempotencyStore(),
+            pollIntervalMs: 5,
+        });
+        let release!: () => void;
+        const gate = new Promise<void>((resolve) => {
+            release = resolve;
+        });
+        const run = vi.fn().mockImplementation(async () => {
+            await gate;
+            return jsonResponse({ id: 'slow' });
+        });
+
+        const original = manager.execute(baseRequest, run);
+        const duplicate = manager.execute(baseRequest, run);
+        release();
+
+        const responses = await Promise.all([original, duplicate]);
+        const replayed = responses.filter((r) => r.headers.get(IDEMPOTENT_REPLAY_HEADER) === 'true');
+        expect(run).toHaveBeenCalledTimes(1);
+        expect(replayed).toHaveLength(1);
+    });
+
+    it('should return 409 for an in-flight streaming duplicate', async () => {
+        const manager = new IdempotencyManager({ store: new MemoryIdempotencyStore() });
+        let release!: () => void;
+        const gate = new Promise<void>((resolve) => {
+            release = resolve;
+        });
+
+        const original = manager.execute(baseRequest, async () => {
+            await gate;
+            return jsonResponse({});
+        });
+        await new Promise((resolve) => setTimeout(resolve, 10));
+
+        const error = await manager
+            .execute({ ...baseRequest, stream: true }, async () => jsonResponse({}))
+            .catch((e) => e);
+
+        release();
+        await original;
+
+        expect(error).toBeInstanceOf(APIError);
+        expect(error.statusCode).toBe(409);
+    });
+    it('should hold in-progress claims on a renewed lease and keep completed ones for the TTL', async () => {
+        const store = new MemoryIdempotencyStore();
+        const manager = new IdempotencyManager({ store, leaseMs: 30, ttlMs: 60_000 });
+        let release!: () => void;
+        const gate = new Promise<void>((resolve) => {
+            release = resolve;
+        });
+
+        const original = manager.execute(baseRequest, async () => {
+            await gate;
+            return jsonResponse({});
+        });
+        await new Promise((resolve) => setTimeout(resolve, 5));
+        const claimed = await store.getIdempotencyRecord('tenant-a', 'key-1');
+        expect(claimed!.expiresAt.getTime()).toBeLessThanOrEqual(Date.now() + 30);
+
+        // Still held well past the first lease
+        await new Promise((resolve) => setTimeout(resolve, 80));
+        expect(await store.getIdempotencyRecord('tenant-a', 'key-1')).toMatchObject({ status: 'in_progress' });
+
+        release();
+        await original;
+        const completed = await store.getIdempotencyRecord('tenant-a', 'key-1');
+        expect(completed!.status).toBe('completed');
+        expect(completed!.expiresAt.getTime()).toBeGreaterThan(Date.now() + 50_000);
+    });
+});
+
+describe('MemoryIdempotencyStore', () => {
+    it('should only release the claim made by the given interaction', async () => {
+        const store = new MemoryIdempotencyStore();
+        const now = new Date();
+        await store.claimIdempotencyKey({
+            tenantId: 'tenant-a',
+            key: 'key-1',
+            interactionId: 'int-2',
+            status: 'in_progress',
+            createdAt: now,
+            expiresAt: new Date(now.getTime() + 60_000),
+        });
+
+        // A stale original releasing after its claim was replaced
+        await store.releaseIdempotencyKey('tenant-a', 'key-1', 'int-1');
+        expect((await store.getIdempotencyRecord('tenant-a', 'key-1'))?.interactionId).toBe('int-2');
+
+        await store.releaseIdempotencyKey('tenant-a', 'key-1', 'int-2');
+        expect(await store.getIdempotencyRecord('tenant-a', 'key-1')).toBeNull();
+    });
+});
+
+describe('isIdempotentEndpoint', () => {
+    it('should match chat and responses POST endpoints', () => {
+        expect(isIdempotentEndpoint('POST', '/v1/chat/completions')).toBe(true);
+        expect(isIdempotentEndpoint('POST', '/anthropic/v1/messages')).toBe(true);
+        expect(isIdempotentEndpoint('POST', '/openai/v1/responses')).toBe(true);
+        expect(isIdempotentEndpoint('GET', '/v1/responses')).toBe(false);
+        expect(isIdempotentEndpoint('POST', '/v1/threads/t1/messages')).toBe(false);
+    });
+});
@@ -0,0 +1,16 @@
+/**
+ * Idempotency module exports.
+ *
+ * @module idempotency
+ */
+
+export {
+    IdempotencyManager,
+    type IdempotencyManagerOptions,
+    type IdempotentRequest,
+    isIdempotentEndpoint,
+    IDEMPOTENCY_KEY_HEADER,
+    IDEMPOTENT_REPLAY_HEADER,
+    DEFAULT_IDEMPOTENCY_TTL_MS,
+} from './manager.js';
+export { MemoryIdempotencyStore, isIdempotencyStore } from './store.js';
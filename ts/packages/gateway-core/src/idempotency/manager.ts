@@ -0,0 +1,353 @@
+/**
+ * Idempotency-Key handling for POST endpoints.
+ *
+ * A retry carrying the same (tenant, key) pair replays the stored client
+ * response instead of calling the provider again. Duplicates that arrive
+ * while the original is still running either wait for it (non-streaming)
+ * or are rejected with 409 (streaming).
+ *
+ * @module idempotency/manager
+ */
+
+import type { IdempotencyRecord, IdempotencyStore, StoredHTTPResponse } from '../ports/storage.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import type { Logger } from '../utils/logging.js';
+import { NullLogger } from '../utils/logging.js';
+import { sha256 } from '../utils/crypto.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Request header carrying the client's idempotency key. */
+export const IDEMPOTENCY_KEY_HEADER = 'Idempotency-Key';
+
+/** Response header set on replayed responses. */
+export const IDEMPOTENT_REPLAY_HEADER = 'x-gateway-idempotent-replay';
+
+/** Default time a key is remembered. */
+export const DEFAULT_IDEMPOTENCY_TTL_MS = 24 * 60 * 60 * 1000;
+
+const MAX_KEY_LENGTH = 255;
+
+/** Endpoints that honor Idempotency-Key. */
+const IDEMPOTENT_PATH_PATTERNS = [
+    /\/chat\/completions$/,
+    /\/v1\/messages$/,
+    /\/responses$/,
+];
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Options for the idempotency manager.
+ */
+export interface IdempotencyManagerOptions {
+    /** Backing store. */
+    store: IdempotencyStore;
+
+    /** How long completed responses are remembered (ms). */
+    ttlMs?: number | undefined;
+
+    /**
+     * How long an in-progress claim holds the key without renewal (ms);
+     * the original renews it while it runs. Defaults to the wait timeout.
+     */
+    leaseMs?: number | undefined;
+
+    /** Maximum time a duplicate waits for the original (ms). */
+    waitTimeoutMs?: number | undefined;
+
+    /** Interval for polling the store while waiting (ms). */
+    pollIntervalMs?: number | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+}
+
+/**
+ * A request subject to idempotency handling.
+ */
+export interface IdempotentRequest {
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** Client-supplied key. */
+    key: string;
+
+    /** Interaction ID assigned to this attempt. */
+    interactionId: string;
+
+    /** Raw request body. */
+    body: string;
+
+    /** Whether the client asked for a streaming response. */
+    stream: boolean;
+}
+
+// ============================================================================
+// Idempotency Manager
+// ============================================================================
+
+/**
+ * Coordinates idempotent execution of requests.
+ */
+export class IdempotencyManager {
+    private readonly store: IdempotencyStore;
+    private readonly ttlMs: number;
+    private readonly leaseMs: number;
+    private readonly waitTimeoutMs: number;
+    private readonly pollIntervalMs: number;
+    private readonly logger: Logger;
+
+    /** Originals running in this process, for fast wake-up of waiters. */
+    private readonly inflight = new Map<string, Promise<void>>();
+
+    constructor(options: IdempotencyManagerOptions) {
+        this.store = options.store;
+        this.ttlMs = options.ttlMs ?? DEFAULT_IDEMPOTENCY_TTL_MS;
+        this.waitTimeoutMs = options.waitTimeoutMs ?? 120_000;
+        this.leaseMs = options.leaseMs ?? this.waitTimeoutMs;
+        this.pollIntervalMs = options.pollIntervalMs ?? 250;
+        this.logger = options.logger ?? new NullLogger();
+    }
+
+    /**
+     * Runs the request once per key, replaying the stored response on retries.
+     */
+    async execute(req: IdempotentRequest, run: () => Promise<Response>): Promise<Response> {
+        if (req.key.length === 0 || req.key.length > MAX_KEY_LENGTH) {
+            throw errInvalidRequest(
+                `${IDEMPOTENCY_KEY_HEADER} must be between 1 and ${MAX_KEY_LENGTH} characters`,
+            );
+        }
+
+        const requestHash = await sha256(req.body);
+        const deadline = Date.now() + this.waitTimeoutMs;
+
+        for (;;) {
+            const now = new Date();
+            const existing = await this.store.claimIdempotencyKey({
+                tenantId: req.tenantId,
+                key: req.key,
+                interactionId: req.interactionId,
+                requestHash,
+                status: 'in_progress',
+                createdAt: now,
+                // A crashed original frees the key once its lease lapses
+                expiresAt: new Date(now.getTime() + this.leaseMs),
+            });
+
+            if (!existing) {
+                return this.runOriginal(req, run);
+            }
+
+            if (existing.requestHash && existing.requestHash !== requestHash) {
+                throw errInvalidRequest(
+                    `${IDEMPOTENCY_KEY_HEADER} was already used with a different request body`,
+                ).withStatusCode(422);
+            }
+
+            if (existing.status === 'completed' && existing.response) {
+                this.logger.debug('Replaying idempotent response', {
+                    idempotencyKey: req.key,
+                    originalInteractionId: existing.interactionId,
+                });
+                return replayResponse(existing);
+            }
+
+            if (req.stream) {
+                throw errInvalidRequest(
+                    `A request with this ${IDEMPOTENCY_KEY_HEADER} is already in progress`,
+                ).withStatusCode(409);
+            }
+
+            const settled = await this.waitForOriginal(req.tenantId, req.key, deadline);
+            if (!settled) {
+                throw errInvalidRequest(
+                    `Timed out waiting for the original request with this ${IDEMPOTENCY_KEY_HEADER}`,
+                ).withStatusCode(409);
+            }
+            // Loop: either replay the completed record or claim a released key.
+        }
+    }
+
+    /**
+     * Runs the original request and captures its client response.
+     * Failed originals release the key so a retry can re-execute.
+     */
+    private async runOriginal(req: IdempotentRequest, run: () => Promise<Response>): Promise<Response> {
+        const id = inflightKey(req.tenantId, req.key);
+        let settle!: () => void;
+        this.inflight.set(id, new Promise<void>((resolve) => {
+            settle = resolve;
+        }));
+        const renewal = setInterval(() => void this.renew(req), this.leaseMs / 3);
+        // Don't hold the process open for lease renewal
+        (renewal as { unref?: () => void }).unref?.();
+        const finish = (): void => {
+            clearInterval(renewal);
+            this.inflight.delete(id);
+            settle();
+        };
+
+        let response: Response;
+        try {
+            response = await run();
+        } catch (error) {
+            await this.release(req);
+            finish();
+            throw error;
+        }
+
+        if (!isReplayable(response.status)) {
+            await this.release(req);
+            finish();
+            return response;
+        }
+
+        const headers = headersToRecord(response.headers);
+
+        if (!response.body || !req.stream) {
+            let body: string;
+            try {
+                body = await response.text();
+            } catch (error) {
+                await this.release(req);
+                finish();
+                throw error;
+            }
+            await this.complete(req, { status: response.status, headers, body });
+            finish();
+            return new Response(body, {
+                status: response.status,
+                statusText: response.statusText,
+                headers: response.headers,
+            });
+        }
+
+        // Streaming: hand one branch to the client and capture the other.
+        const [clientBody, captureBody] = response.body.tee();
+        new Response(captureBody)
+            .text()
+            .then((body) => this.complete(req, { status: response.status, headers, body }))
+            .catch(() => this.release(req))
+            .finally(finish);
+
+        return new Response(clientBody, {
+            status: response.status,
+            statusText: response.statusText,
+            headers: response.headers,
+        });
+    }
+
+    /**
+     * Waits until the original completes or releases the key.
+     * Returns false if the deadline passes first.
+     */
+    private async waitForOriginal(tenantId: string, key: string, deadline: number): Promise<boolean> {
+        while (Date.now() < deadline) {
+            const local = this.inflight.get(inflightKey(tenantId, key));
+            await Promise.race([local ?? Promise.resolve(), sleep(this.pollIntervalMs)]);
+
+            const record = await this.store.getIdempotencyRecord(tenantId, key);
+            if (!record || record.status === 'completed') {
+                return true;
+            }
+        }
+        return false;
+    }
+
+    private async renew(req: IdempotentRequest): Promise<void> {
+        try {
+            await this.store.renewIdempotencyKey(
+                req.tenantId,
+                req.key,
+                req.interactionId,
+                new Date(Date.now() + this.leaseMs),
+            );
+        } catch (error) {
+            this.logger.warn('Failed to renew idempotency key', {
+                idempotencyKey: req.key,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+
+    private async complete(req: IdempotentRequest, response: StoredHTTPResponse): Promise<void> {
+        try {
+            await this.store.completeIdempotencyKey(
+                req.tenantId,
+                req.key,
+                response,
+                new Date(Date.now() + this.ttlMs),
+            );
+        } catch (error) {
+            this.logger.error('Failed to store idempotent response', {
+                idempotencyKey: req.key,
+                error: error instanceof Error ? error.message : String(error),
+            });
+            await this.release(req);
+        }
+    }
+
+    private async release(req: IdempotentRequest): Promise<void> {
+        try {
+            await this.store.releaseIdempotencyKey(req.tenantId, req.key, req.interactionId);
+        } catch (error) {
+            this.logger.error('Failed to release idempotency key', {
+                idempotencyKey: req.key,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Whether the given path accepts Idempotency-Key.
+ */
+export function isIdempotentEndpoint(method: string, path: string): boolean {
+    return method === 'POST' && IDEMPOTENT_PATH_PATTERNS.some((pattern) => pattern.test(path));
+}
+
+/**
+ * Builds a response from a completed idempotency record.
+ */
+function replayResponse(record: IdempotencyRecord): Response {
+    const stored = record.response!;
+    const headers = new Headers(stored.headers);
+    headers.set(IDEMPOTENT_REPLAY_HEADER, 'true');
+    return new Response(stored.body, { status: stored.status, headers });
+}
+
+/**
+ * Only successes are stored; any failure releases the key so a retry
+ * runs fresh.
+ */
+function isReplayable(status: number): boolean {
+    return status >= 200 && status < 300;
+}
+
+function headersToRecord(headers: Headers): Record<string, string> {
+    const result: Record<string, string> = {};
+    headers.forEach((value, name) => {
+        if (name !== 'content-length') {
+            result[name] = value;
+        }
+    });
+    return result;
+}
+
+function inflightKey(tenantId: string, key: string): string {
+    return `${tenantId}\u0000${key}`;
+}
+
+function sleep(ms: number): Promise<void> {
+    return new Promise((resolve) => setTimeout(resolve, ms));
+}
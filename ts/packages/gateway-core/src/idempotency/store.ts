@@ -0,0 +1,113 @@
+/**
+ * In-memory idempotency store.
+ *
+ * @module idempotency/store
+ */
+
+import type {
+    IdempotencyRecord,
+    IdempotencyStore,
+    StorageProvider,
+    StoredHTTPResponse,
+} from '../ports/storage.js';
+
+// ============================================================================
+// Memory Idempotency Store
+// ============================================================================
+
+/**
+ * Process-local idempotency store.
+ * Used when the configured storage provider does not implement IdempotencyStore.
+ */
+export class MemoryIdempotencyStore implements IdempotencyStore {
+    private readonly records = new Map<string, IdempotencyRecord>();
+
+    async claimIdempotencyKey(record: IdempotencyRecord): Promise<IdempotencyRecord | null> {
+        this.prune();
+
+        const id = recordKey(record.tenantId, record.key);
+        const existing = this.records.get(id);
+        if (existing) {
+            return existing;
+        }
+
+        this.records.set(id, record);
+        return null;
+    }
+
+    async getIdempotencyRecord(tenantId: string, key: string): Promise<IdempotencyRecord | null> {
+        const record = this.records.get(recordKey(tenantId, key));
+        if (!record) return null;
+
+        if (record.expiresAt.getTime() <= Date.now()) {
+            this.records.delete(recordKey(tenantId, key));
+            return null;
+        }
+
+        return record;
+    }
+
+    async renewIdempotencyKey(tenantId: string, key: string, interactionId: string, expiresAt: Date): Promise<void> {
+        const record = this.records.get(recordKey(tenantId, key));
+        if (record?.interactionId === interactionId && record.status === 'in_progress') {
+            record.expiresAt = expiresAt;
+        }
+    }
+
+    async completeIdempotencyKey(
+        tenantId: string,
+        key: string,
+        response: StoredHTTPResponse,
+        expiresAt: Date,
+    ): Promise<void> {
+        const record = this.records.get(recordKey(tenantId, key));
+        if (!record) return;
+
+        record.status = 'completed';
+        record.response = response;
+        record.expiresAt = expiresAt;
+    }
+
+    async releaseIdempotencyKey(tenantId: string, key: string, interactionId: string): Promise<void> {
+        const id = recordKey(tenantId, key);
+        if (this.records.get(id)?.interactionId === interactionId) {
+            this.records.delete(id);
+        }
+    }
+
+    /**
+     * Drops expired records.
+     */
+    private prune(): void {
+        const now = Date.now();
+        for (const [id, record] of this.records) {
+            if (record.expiresAt.getTime() <= now) {
+                this.records.delete(id);
+            }
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements IdempotencyStore.
+ */
+export function isIdempotencyStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & IdempotencyStore {
+    return (
+        storage !== undefined &&
+        typeof storage.claimIdempotencyKey === 'function' &&
+        typeof storage.getIdempotencyRecord === 'function' &&
+        typeof storage.renewIdempotencyKey === 'function' &&
+        typeof storage.completeIdempotencyKey === 'function' &&
+        typeof storage.releaseIdempotencyKey === 'function'
+    );
+}
+
+function recordKey(tenantId: string, key: string): string {
+    return `${tenantId}\u0000${key}`;
+}
@@ -8,7 +8,23 @@
 export { Gateway, type GatewayOptions } from './gateway.js';
 
 // Router
-export { Router, type Route, type ProviderSelection, stripAppPrefix, joinPath } from './router.js';
+export {
+    Router,
+    type Route,
+    type ProviderSelection,
+    type RoutingDecision,
+    type RoutingMatch,
+    type RoutingMatchType,
+    type AffinityFactor,
+    type RoutingTestQuery,
+    stripAppPrefix,
+    joinPath,
+    selectionOf,
+    routingStep,
+    EXPLAIN_HEADER,
+    ROUTING_HEADER,
+    ROUTING_BODY_KEY,
+} from './router.js';
 
 // Domain types
 export * from './domain/index.js';
@@ -46,5 +62,143 @@ export * from './responses/index.js';
 // Shadow Mode
 export * from './shadow/index.js';
 
+// Idempotency
+export * from './idempotency/index.js';
+
+// Gateway Tools
+export * from './tools/index.js';
+
+// Tenant Budgets
+export * from './budget/index.js';
+
+// Analytics Event Sinks
+export * from './analytics/index.js';
+
+// Request Mirroring
+export * from './mirror/index.js';
+
+// Prompt Templates
+export * from './templates/index.js';
+
+// Response Transforms
+export * from './transforms/index.js';
+
+// JSON Output Validation
+export * from './jsonoutput/index.js';
+
+// Privacy Erasure
+export * from './privacy/index.js';
+
+// Tenant Registry
+export * from './tenants/index.js';
+
+// Thread Affinity
+export * from './affinity/index.js';
+
+// Model Migrations
+export * from './modelmigration/index.js';
+
+// Storage Migrations
+export * from './migrations/index.js';
+
+// Runtime Logging Control
+export * from './logging/index.js';
+
+// Message Batches
+export * from './batches/index.js';
+
+// Client Correlation
+export * from './correlation/index.js';
+
+// Tenant Usage Reports
+export * from './usage/index.js';
+
+// Usage Write Spill
+export * from './spill/index.js';
+
+// Storage Health
+export * from './storagehealth/index.js';
+
+// Dual-Write Storage Migration
+export * from './dualwrite/index.js';
+
+// Response Classification
+export * from './classification/index.js';
+
+// Synthetic Provider Probes
+export * from './probe/index.js';
+
+// Storage Encryption
+export * from './encryption/index.js';
+
+// Test-Request Console
+export * from './console/index.js';
+
+// Token Counting
+export * from './tokens/index.js';
+
+// Tenant Concurrency
+export * from './concurrency/index.js';
+
+// Request Coalescing
+export * from './coalescing/index.js';
+
+// Conversation Summaries
+export * from './summarization/index.js';
+
+// Endpoint Passthrough
+export * from './passthrough/index.js';
+
+// Route Table
+export * from './routes/index.js';
+
+// Thread Transfer
+export * from './transfer/index.js';
+
+// End-User Identifiers
+export * from './enduser/index.js';
+
+// Gateway Warnings
+export * from './warnings/index.js';
+
+// Tool Argument Streaming
+export * from './toolargs/index.js';
+
+// Stream Event Size Limits
+export * from './eventsize/index.js';
+
+// Request Call Budgets
+export * from './callbudget/index.js';
+
+// Live Interaction Tails
+export * from './tail/index.js';
+
+// Sampling Parameter Policies
+export * from './parampolicy/index.js';
+
+// Provider Usage Checks
+export * from './usagecheck/index.js';
+
+// Assistant Prefill
+export * from './prefill/index.js';
+
+// Latency SLOs
+export * from './slo/index.js';
+
+// Tenant Secrets
+export * from './secrets/index.js';
+
+// Request Body Ingest
+export * from './ingest/index.js';
+
+// Interaction Status Lifecycle
+export * from './interactions/index.js';
+
+// OpenAPI Documents
+export * from './openapi/index.js';
+
+// Thread Message Dedup
+export * from './messagededup/index.js';
+
 // Utilities
 export * from './utils/index.js';
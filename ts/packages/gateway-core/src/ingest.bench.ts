@@ -0,0 +1,49 @@
+import { bench, describe } from 'vitest';
+import { readRequestBody, BodyBufferPool } from './ingest/index';
+import { openaiCodec } from './codecs/openai';
+import { parseJSONBody } from './codecs/validation';
+
+// A 5MB context-stuffed chat request, as RAG clients send them. The legacy
+// path is the one the gateway took before: a cloned read and full parse
+// for routing, then another read, a parse for validation, and a third in
+// the codec. Profile a run (node --heap-prof) to compare allocations as
+// well as time.
+const chunk = 'Retrieved passage: the quick brown fox jumps over the lazy dog. '.repeat(1024);
+const body = JSON.stringify({
+    model: 'gpt-4o',
+    messages: [
+        { role: 'system', content: 'Answer from the passages.' },
+        ...Array.from({ length: 80 }, () => ({ role: 'user', content: chunk })),
+    ],
+});
+
+const request = () => new Request('http://localhost/v1/chat/completions', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body,
+});
+
+const pool = new BodyBufferPool();
+
+describe(`5MB chat request (${(body.length / 1024 / 1024).toFixed(1)} MiB)`, () => {
+    bench('legacy: clone, parse for routing, reparse to decode', async () => {
+        const incoming = request();
+        const raw = await incoming.clone().text();
+        JSON.parse(raw);
+        const text = await incoming.text();
+        parseJSONBody(text);
+        openaiCodec.decodeRequest(text);
+    });
+
+    bench('ingest: pooled read, pre-scan, one parse', async () => {
+        const read = await readRequestBody(request(), { pool });
+        read.routingFields();
+        read.json();
+        openaiCodec.decodeRequest(read);
+    });
+
+    bench('ingest: pre-scan only (refused before decode)', async () => {
+        const read = await readRequestBody(request(), { pool });
+        read.routingFields();
+    });
+});
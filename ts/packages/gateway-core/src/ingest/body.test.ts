@@ -0,0 +1,183 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { readRequestBody, RequestBody, BodyBufferPool, BODY_BUFFER_SIZES } from './index';
+import { openaiCodec } from '../codecs/openai';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const encoder = new TextEncoder();
+
+/** A body streamed in chunks, counting how many were pulled. */
+function chunked(chunks: string[]) {
+    const state = { pulled: 0, cancelled: false };
+    const stream = new ReadableStream<Uint8Array>({
+        pull(controller) {
+            const next = chunks[state.pulled++];
+            if (next === undefined) {
+                controller.close();
+            } else {
+                controller.enqueue(encoder.encode(next));
+            }
+        },
+        cancel() {
+            state.cancelled = true;
+        },
+    });
+    return { stream, state };
+}
+
+function post(body: BodyInit, headers: Record<string, string> = {}): Request {
+    return new Request('http://localhost/v1/chat/completions', { method: 'POST', headers, body, duplex: 'half' } as RequestInit);
+}
+
+describe('readRequestBody', () => {
+    it('should refuse a body whose Content-Length is over the limit without reading it', async () => {
+        const { stream, state } = chunked(['{"model":"gpt-4o"}']);
+
+        await expect(readRequestBody(post(stream, { 'Content-Length': '2048' }), { maxBytes: 1024 }))
+            .rejects.toMatchObject({ statusCode: 413, code: 'request_too_large' });
+        expect(state.pulled).toBe(0);
+        expect(state.cancelled).toBe(true);
+    });
+
+    it('should stop reading a body once it passes the limit', async () => {
+        const { stream, state } = chunked(Array.from({ length: 100 }, () => 'x'.repeat(1024)));
+        const pool = new BodyBufferPool();
+
+        await expect(readRequestBody(post(stream), { maxBytes: 4096, pool })).rejects.toMatchObject({ statusCode: 413 });
+        expect(state.pulled).toBeLessThan(10);
+        expect(state.cancelled).toBe(true);
+        expect(pool.stats().outstanding).toBe(0);
+    });
+
+    it('should read bodies larger than the first buffer across chunks', async () => {
+        const text = JSON.stringify({ model: 'gpt-4o', content: 'é'.repeat(200_000) });
+        const { stream } = chunked(text.match(/.{1,7000}/gs)!);
+
+        const body = await readRequestBody(post(stream));
+
+        expect(body.text).toBe(text);
+        expect(body.size).toBe(encoder.encode(text).length);
+    });
+
+    it('should not take a buffer the size of the declared Content-Length before the bytes arrive', async () => {
+        const { stream } = chunked(['{"model":"gpt-4o"}']);
+        const pool = new BodyBufferPool();
+
+        const body = await readRequestBody(post(stream, { 'Content-Length': String(16 * 1024 * 1024) }), { pool });
+
+        expect(body.text).toBe('{"model":"gpt-4o"}');
+        expect(pool.stats().idle).toEqual({ [BODY_BUFFER_SIZES[0]]: 1 });
+    });
+
+    it('should read an empty body', async () => {
+        const body = await readRequestBody(new Request('http://localhost/', { method: 'POST' }));
+
+        expect(body.text).toBe('');
+    });
+});
+
+describe('BodyBufferPool', () => {
+    it('should return every buffer zeroed, whether the read succeeds or fails', async () => {
+        const pool = new BodyBufferPool();
+        const secret = JSON.stringify({ model: 'gpt-4o', content: 'tenant-a-secret '.repeat(1000) });
+
+        await readRequestBody(post(secret), { pool });
+        await readRequestBody(post('x'.repeat(10_000)), { pool, maxBytes: 1000 }).catch(() => undefined);
+
+        expect(pool.stats().outstanding).toBe(0);
+        const reused = pool.acquire(1);
+        expect(reused.length).toBe(BODY_BUFFER_SIZES[0]);
+        expect(reused.every((byte) => byte === 0)).toBe(true);
+    });
+
+    it('should not leak bytes between concurrent requests', async () => {
+        const pool = new BodyBufferPool({ maxIdle: 2 });
+        const bodies = Array.from({ length: 50 }, (_, i) => JSON.stringify({ model: `m-${i}`, content: String(i).repeat(5000 + i * 97) }));
+
+        const read = await Promise.all(bodies.map((text) => {
+            const { stream } = chunked(text.match(/.{1,4096}/gs)!);
+            return readRequestBody(post(stream), { pool });
+        }));
+
+        expect(read.map((body) => body.text)).toEqual(bodies);
+        expect(pool.stats().outstanding).toBe(0);
+        expect(pool.stats().idle[BODY_BUFFER_SIZES[0]]).toBeLessThanOrEqual(2);
+    });
+
+    it('should ignore buffers it did not hand out', () => {
+        const pool = new BodyBufferPool();
+
+        pool.release(new Uint8Array(BODY_BUFFER_SIZES[0]), 0);
+
+        expect(pool.stats().idle).toEqual({});
+    });
+});
+
+describe('RequestBody', () => {
+    it('should share one parse between validation and codec decode', () => {
+        const body = new RequestBody(JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] }));
+        const parse = vi.spyOn(JSON, 'parse');
+
+        body.json();
+        const canonical = openaiCodec.decodeRequest(body);
+
+        expect(parse).toHaveBeenCalledTimes(1);
+        expect(canonical.model).toBe('gpt-4o');
+        parse.mockRestore();
+    });
+
+    it('should fail json() with a 400 on invalid JSON', () => {
+        expect(() => new RequestBody('{"model":').json()).toThrow(expect.objectContaining({ statusCode: 400 }));
+    });
+
+    it('should replay its text as a request body', async () => {
+        const body = new RequestBody('{"model":"gpt-4o"}');
+
+        const replayed = body.replay(new Request('http://localhost/v1/x', { method: 'POST', headers: { 'X-Test': '1' } }));
+
+        expect(await replayed.text()).toBe('{"model":"gpt-4o"}');
+        expect(replayed.headers.get('X-Test')).toBe('1');
+    });
+});
+
+describe('Gateway request bodies', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(maxRequestBytes?: number) {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('mock', (request) => ({ text: String(request.messages[0]!.content.length) })))
+            .config({ server: { maxRequestBytes } })
+            .start();
+        return gateway;
+    }
+
+    const chat = (gw: TestGateway, content: string) =>
+        gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content }] });
+
+    it('should serve a large body read once and release its buffers', async () => {
+        const gw = await setup();
+        const content = 'passage '.repeat(700_000);
+
+        const response = await chat(gw, content);
+
+        expect(response.status).toBe(200);
+        expect((await response.json()).choices[0].message.content).toBe(String(content.length));
+        expect(gw.gateway.bodyBuffers.stats().outstanding).toBe(0);
+    });
+
+    it('should refuse a body over server.maxRequestBytes with a 413', async () => {
+        const gw = await setup(1024);
+
+        const response = await chat(gw, 'x'.repeat(2048));
+
+        expect(response.status).toBe(413);
+        expect((await response.json()).error.code).toBe('request_too_large');
+        expect(gw.provider('mock').requests).toHaveLength(0);
+        expect(gw.gateway.bodyBuffers.stats().outstanding).toBe(0);
+    });
+});
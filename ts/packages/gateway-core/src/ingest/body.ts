@@ -0,0 +1,192 @@
+/**
+ * Request body ingest: one bounded read, one decoded copy.
+ *
+ * The gateway reads a request's body once, into a pooled buffer, stopping
+ * as soon as it passes the size limit. The text decoded from it is shared
+ * by everything that needs the body: routing's pre-scan of the model,
+ * mirroring, idempotency, the frontdoor's validation and codec decode
+ * (which share one parse), and anything that reads the request again.
+ *
+ * @module ingest/body
+ */
+
+import { errRequestTooLarge } from '../domain/errors.js';
+import { parseJSONBody } from '../codecs/validation.js';
+import { BodyBufferPool } from './pool.js';
+import { scanTopLevelFields } from './scan.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Largest request body read (default), in bytes. */
+export const DEFAULT_MAX_REQUEST_BYTES = 32 * 1024 * 1024;
+
+/**
+ * Buffer a read starts with. Bodies grow into larger ones as their bytes
+ * arrive, never on the strength of the declared Content-Length alone.
+ */
+const INITIAL_BUFFER_BYTES = 64 * 1024;
+
+/** Pool shared by reads that don't bring their own. */
+const defaultPool = new BodyBufferPool();
+
+// ============================================================================
+// Request Body
+// ============================================================================
+
+/**
+ * Fields routing reads from a body before it is decoded.
+ */
+export interface RoutingFields {
+    /** The requested model, if a string. */
+    model?: string | undefined;
+
+    /** Whether the client asked for a stream. */
+    stream: boolean;
+}
+
+/**
+ * A request body read once. Its JSON is parsed at most once, on first use.
+ */
+export class RequestBody {
+    /** The body's text. */
+    readonly text: string;
+
+    /** The body's size, in bytes. */
+    readonly size: number;
+
+    private parsed?: { value: unknown } | { error: unknown };
+
+    constructor(text: string, size?: number) {
+        this.text = text;
+        this.size = size ?? new TextEncoder().encode(text).length;
+    }
+
+    /**
+     * Returns the parsed body, failing with a 400 on syntax errors.
+     */
+    json(): unknown {
+        if (!this.parsed) {
+            try {
+                this.parsed = { value: parseJSONBody(this.text) };
+            } catch (error) {
+                this.parsed = { error };
+            }
+        }
+        if ('error' in this.parsed) {
+            throw this.parsed.error;
+        }
+        return this.parsed.value;
+    }
+
+    /**
+     * Returns the model and stream flag without parsing the rest of the
+     * body, or from the parse if one has already been made.
+     */
+    routingFields(): RoutingFields {
+        const fields = this.parsed && 'value' in this.parsed && isRecord(this.parsed.value)
+            ? this.parsed.value
+            : scanTopLevelFields(this.text, ['model', 'stream']);
+        return {
+            model: typeof fields.model === 'string' ? fields.model : undefined,
+            stream: fields.stream === true,
+        };
+    }
+
+    /**
+     * Returns a request like the one the body was read from, whose body
+     * reads this one's text again. The text is only encoded if read.
+     */
+    replay(request: Request): Request {
+        const text = this.text;
+        const body = new ReadableStream<Uint8Array>({
+            pull(controller) {
+                controller.enqueue(new TextEncoder().encode(text));
+                controller.close();
+            },
+        });
+        return new Request(request, { body, duplex: 'half' } as RequestInit);
+    }
+}
+
+// ============================================================================
+// Reading
+// ============================================================================
+
+/**
+ * Request body read options.
+ */
+export interface ReadRequestBodyOptions {
+    /** Largest body read, in bytes (default 32 MiB). */
+    maxBytes?: number | undefined;
+
+    /** Buffers to read into (default: a pool shared by the process). */
+    pool?: BodyBufferPool | undefined;
+}
+
+/**
+ * Reports whether a request's body is text the gateway decodes: JSON, any
+ * text type, or untyped. Other bodies (file uploads passed through) are
+ * left for whatever handles them to read as they are.
+ */
+export function hasTextBody(request: Request): boolean {
+    const type = request.headers.get('Content-Type')?.toLowerCase();
+    return !type || type.includes('json') || type.startsWith('text/');
+}
+
+/**
+ * Reads a request's body, failing with a 413 once it passes the limit: up
+ * front when Content-Length says it will, otherwise as soon as the bytes
+ * read do, without reading the rest. A declared Content-Length only caps
+ * how far the buffer grows; memory is taken as bytes arrive. The buffers
+ * read into are zeroed and returned to the pool before this returns,
+ * whatever the outcome.
+ */
+export async function readRequestBody(request: Request, options: ReadRequestBodyOptions = {}): Promise<RequestBody> {
+    const maxBytes = options.maxBytes ?? DEFAULT_MAX_REQUEST_BYTES;
+    const pool = options.pool ?? defaultPool;
+
+    const declared = Number(request.headers.get('Content-Length') ?? NaN);
+    if (declared > maxBytes) {
+        await request.body?.cancel().catch(() => undefined);
+        throw errRequestTooLarge(maxBytes);
+    }
+    if (!request.body) {
+        return new RequestBody('', 0);
+    }
+
+    const expected = declared > 0 ? declared : maxBytes;
+    const reader = request.body.getReader();
+    let buffer = pool.acquire(Math.min(expected, INITIAL_BUFFER_BYTES));
+    let length = 0;
+    try {
+        for (;;) {
+            const { done, value } = await reader.read();
+            if (done) {
+                break;
+            }
+            if (length + value.length > maxBytes) {
+                await reader.cancel().catch(() => undefined);
+                throw errRequestTooLarge(maxBytes);
+            }
+            if (length + value.length > buffer.length) {
+                const needed = length + value.length;
+                const grown = pool.acquire(Math.min(Math.max(buffer.length * 2, needed), Math.max(expected, needed)));
+                grown.set(buffer.subarray(0, length));
+                pool.release(buffer, length);
+                buffer = grown;
+            }
+            buffer.set(value, length);
+            length += value.length;
+        }
+        return new RequestBody(new TextDecoder().decode(buffer.subarray(0, length)), length);
+    } finally {
+        reader.releaseLock();
+        pool.release(buffer, length);
+    }
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -0,0 +1,23 @@
+/**
+ * Request body ingest exports.
+ *
+ * @module ingest
+ */
+
+export {
+    RequestBody,
+    readRequestBody,
+    hasTextBody,
+    DEFAULT_MAX_REQUEST_BYTES,
+    type RoutingFields,
+    type ReadRequestBodyOptions,
+} from './body.js';
+
+export {
+    BodyBufferPool,
+    BODY_BUFFER_SIZES,
+    type BodyBufferPoolOptions,
+    type BodyBufferPoolStats,
+} from './pool.js';
+
+export { scanTopLevelFields } from './scan.js';
@@ -0,0 +1,96 @@
+/**
+ * Pooled buffers for reading request bodies.
+ *
+ * @module ingest/pool
+ */
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/**
+ * Pooled buffer sizes. A read takes the smallest that fits; bodies larger
+ * than the last get a buffer of their own, which is never pooled.
+ */
+export const BODY_BUFFER_SIZES = [64 * 1024, 256 * 1024, 1024 * 1024, 4 * 1024 * 1024, 16 * 1024 * 1024] as const;
+
+/** Idle buffers kept per size (default). */
+const DEFAULT_MAX_IDLE = 4;
+
+// ============================================================================
+// Buffer Pool
+// ============================================================================
+
+/**
+ * Body buffer pool options.
+ */
+export interface BodyBufferPoolOptions {
+    /** Idle buffers kept per size (default 4). */
+    maxIdle?: number | undefined;
+}
+
+/**
+ * Body buffer pool statistics.
+ */
+export interface BodyBufferPoolStats {
+    /** Buffers handed out and not yet released. */
+    outstanding: number;
+
+    /** Idle buffers, by size. */
+    idle: Record<number, number>;
+}
+
+/**
+ * Reusable buffers for request bodies, by size. Buffers are zeroed as they
+ * are released, so no request's bytes are visible to the next one to take
+ * the buffer.
+ */
+export class BodyBufferPool {
+    private readonly maxIdle: number;
+    private readonly free = new Map<number, Uint8Array[]>();
+    private readonly leased = new Set<Uint8Array>();
+
+    constructor(options: BodyBufferPoolOptions = {}) {
+        this.maxIdle = options.maxIdle ?? DEFAULT_MAX_IDLE;
+    }
+
+    /**
+     * Takes a buffer of at least `minBytes`.
+     */
+    acquire(minBytes: number): Uint8Array {
+        const size = BODY_BUFFER_SIZES.find((s) => s >= minBytes);
+        const buffer = (size !== undefined && this.free.get(size)?.pop()) || new Uint8Array(size ?? minBytes);
+        this.leased.add(buffer);
+        return buffer;
+    }
+
+    /**
+     * Returns a buffer, zeroing the first `used` bytes (the rest were never
+     * written since it was last zeroed).
+     */
+    release(buffer: Uint8Array, used: number): void {
+        if (!this.leased.delete(buffer)) {
+            return;
+        }
+        buffer.fill(0, 0, used);
+        if (!(BODY_BUFFER_SIZES as readonly number[]).includes(buffer.length)) {
+            return;
+        }
+        const free = this.free.get(buffer.length) ?? [];
+        if (free.length < this.maxIdle) {
+            free.push(buffer);
+            this.free.set(buffer.length, free);
+        }
+    }
+
+    /**
+     * Returns how many buffers are out and idle.
+     */
+    stats(): BodyBufferPoolStats {
+        const idle: Record<number, number> = {};
+        for (const [size, free] of this.free) {
+            idle[size] = free.length;
+        }
+        return { outstanding: this.leased.size, idle };
+    }
+}
@@ -0,0 +1,25 @@
+import { describe, it, expect } from 'vitest';
+import { scanTopLevelFields } from './index';
+
+describe('scanTopLevelFields', () => {
+    it('should find fields after large nested values', () => {
+        const text = JSON.stringify({
+            messages: [{ role: 'user', content: 'a "quoted" {brace} [bracket] \\ "model": "nope"' }],
+            tools: [{ nested: { model: 'inner', deep: [[1, 2], { x: null }] } }],
+            stream: true,
+            model: 'gpt-4o',
+        });
+
+        expect(scanTopLevelFields(text, ['model', 'stream'])).toEqual({ model: 'gpt-4o', stream: true });
+    });
+
+    it('should decode escaped keys and values', () => {
+        expect(scanTopLevelFields('{ "mod\\u0065l" : "gpt-\\"4o\\"" }', ['model'])).toEqual({ model: 'gpt-"4o"' });
+    });
+
+    it('should stop at what it found when the body is not an object', () => {
+        expect(scanTopLevelFields('[1,2]', ['model'])).toEqual({});
+        expect(scanTopLevelFields('{"stream":false,"model":', ['model', 'stream'])).toEqual({ stream: false });
+        expect(scanTopLevelFields('', ['model'])).toEqual({});
+    });
+});
@@ -0,0 +1,143 @@
+/**
+ * Top-level field pre-scan for JSON request bodies.
+ *
+ * Routing needs a request's model (and whether it streams) before anything
+ * else; for a body of several megabytes of messages, parsing all of it to
+ * read one string is most of the cost of rejecting it. The scanner walks
+ * the top-level object's keys, skipping over values it wasn't asked for
+ * without building them, and stops once it has every field asked for.
+ *
+ * @module ingest/scan
+ */
+
+// ============================================================================
+// Scanner
+// ============================================================================
+
+/**
+ * Returns the requested top-level fields of a JSON object, parsing only
+ * their values. Fields that aren't present are left out; a body that isn't
+ * a JSON object yields what was found before the scanner lost its place
+ * (full decoding reports the error).
+ */
+export function scanTopLevelFields(text: string, fields: readonly string[]): Record<string, unknown> {
+    const found: Record<string, unknown> = {};
+    const wanted = new Set(fields);
+    let i = skipWhitespace(text, 0);
+    if (text[i] !== '{') {
+        return found;
+    }
+    i++;
+
+    while (wanted.size > 0) {
+        i = skipWhitespace(text, i);
+        if (text[i] !== '"') {
+            return found;
+        }
+        const keyEnd = skipString(text, i);
+        if (keyEnd < 0) {
+            return found;
+        }
+        const key = readKey(text, i, keyEnd);
+
+        i = skipWhitespace(text, keyEnd);
+        if (text[i] !== ':') {
+            return found;
+        }
+        i = skipWhitespace(text, i + 1);
+        const valueEnd = skipValue(text, i);
+        if (valueEnd < 0) {
+            return found;
+        }
+        if (key !== undefined && wanted.delete(key)) {
+            try {
+                found[key] = JSON.parse(text.slice(i, valueEnd));
+            } catch {
+                return found;
+            }
+        }
+
+        i = skipWhitespace(text, valueEnd);
+        if (text[i] !== ',') {
+            return found;
+        }
+        i++;
+    }
+    return found;
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+function skipWhitespace(text: string, i: number): number {
+    while (i < text.length) {
+        const c = text.charCodeAt(i);
+        if (c !== 0x20 && c !== 0x0a && c !== 0x0d && c !== 0x09) break;
+        i++;
+    }
+    return i;
+}
+
+/**
+ * Returns the index just past the string starting at `i` (a quote), or -1.
+ */
+function skipString(text: string, i: number): number {
+    for (let j = i + 1; j < text.length; j++) {
+        const c = text.charCodeAt(j);
+        if (c === 0x5c) {
+            j++;
+        } else if (c === 0x22) {
+            return j + 1;
+        }
+    }
+    return -1;
+}
+
+/**
+ * Returns a key's value; keys with escapes are decoded by JSON.parse.
+ */
+function readKey(text: string, start: number, end: number): string | undefined {
+    const raw = text.slice(start + 1, end - 1);
+    if (!raw.includes('\\')) {
+        return raw;
+    }
+    try {
+        return JSON.parse(text.slice(start, end)) as string;
+    } catch {
+        return undefined;
+    }
+}
+
+/**
+ * Returns the index just past the value starting at `i`, or -1. Nested
+ * objects and arrays are skipped by depth, minding strings.
+ */
+function skipValue(text: string, i: number): number {
+    const first = text[i];
+    if (first === '"') {
+        return skipString(text, i);
+    }
+    if (first !== '{' && first !== '[') {
+        // Number, true, false, or null: runs to the next delimiter
+        let j = i;
+        while (j < text.length && !',}] \n\r\t'.includes(text[j]!)) j++;
+        return j > i ? j : -1;
+    }
+
+    let depth = 0;
+    for (let j = i; j < text.length; j++) {
+        const c = text[j];
+        if (c === '"') {
+            j = skipString(text, j);
+            if (j < 0) return -1;
+            j--;
+        } else if (c === '{' || c === '[') {
+            depth++;
+        } else if (c === '}' || c === ']') {
+            depth--;
+            if (depth === 0) return j + 1;
+        }
+    }
+    return -1;
+}
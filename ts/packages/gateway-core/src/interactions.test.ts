@@ -0,0 +1,162 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { INTERACTION_ID_HEADER } from './correlation/index';
+import { APIError } from './domain/errors';
+import type { CanonicalRequest, CanonicalResponse } from './domain/types';
+import { InteractionLifecycle } from './interactions/index';
+import type { InteractionRecord } from './ports/index';
+import { harness, parseSSE, ScriptedProvider, type Reply, type TestGateway } from './__tests__/harness/index';
+
+/** A scripted provider that doesn't answer until released, like one a gateway dies waiting on. */
+class HangingProvider extends ScriptedProvider {
+    private release!: () => void;
+    private readonly gate = new Promise<void>((resolve) => {
+        this.release = resolve;
+    });
+
+    constructor(name: string, reply: Reply) {
+        super(name, reply);
+    }
+
+    open(): void {
+        this.release();
+    }
+
+    override async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+        await this.gate;
+        return super.complete(request);
+    }
+}
+
+/** A scripted provider whose upstream is down. */
+class FailingProvider extends ScriptedProvider {
+    override async complete(): Promise<CanonicalResponse> {
+        throw new APIError('server', 'upstream unavailable').withStatusCode(502);
+    }
+}
+
+const chat = { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] };
+
+function record(id: string, status: InteractionRecord['status'], createdAt: number): InteractionRecord {
+    return {
+        id,
+        tenantId: 'acme',
+        frontdoor: 'openai',
+        provider: 'mock',
+        model: 'gpt-4o',
+        stream: false,
+        status,
+        createdAt: new Date(createdAt),
+        updatedAt: new Date(createdAt),
+    };
+}
+
+describe('Interaction status lifecycle', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(provider: ScriptedProvider) {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(provider)
+            .start();
+        const admin = new AdminHandler({
+            storage: gateway.store as any,
+            tails: gateway.gateway.interactionTails,
+        });
+        return { gw: gateway, admin };
+    }
+
+    function inProgress(gw: TestGateway): InteractionRecord[] {
+        return [...gw.store.interactions.values()].filter((r) => r.status === 'in_progress');
+    }
+
+    it('should record a request in_progress before the provider answers', async () => {
+        const provider = new HangingProvider('mock', { text: 'Hello' });
+        const { gw } = await setup(provider);
+
+        const pending = gw.post('/v1/chat/completions', chat);
+        await vi.waitFor(async () => expect(inProgress(gw)).toHaveLength(1));
+
+        const [started] = inProgress(gw);
+        expect(started).toMatchObject({ tenantId: 'acme', appName: 'chat', frontdoor: 'openai', provider: 'mock', model: 'gpt-4o', stream: false });
+        expect(JSON.parse(started!.request!).messages[0].content).toBe('Hi');
+        expect(gw.store.eventsFor(started!.id)[0]!.type).toBe('interaction_started');
+
+        provider.open();
+        const response = await pending;
+        expect(response.status).toBe(200);
+        expect(response.headers.get(INTERACTION_ID_HEADER)).toBe(started!.id);
+        await vi.waitFor(async () => expect(await gw.store.getInteraction(started!.id, 'acme'))
+            .toMatchObject({ status: 'completed', statusCode: 200 }));
+    });
+
+    it('should leave a request lost with its gateway in_progress until a later sweep abandons it', async () => {
+        const provider = new HangingProvider('mock', { text: 'Hello' });
+        const { gw } = await setup(provider);
+
+        const pending = gw.post('/v1/chat/completions', chat);
+        await vi.waitFor(async () => expect(inProgress(gw)).toHaveLength(1));
+        const [lost] = inProgress(gw);
+
+        // The next gateway over the same store sweeps at startup
+        const restarted = new InteractionLifecycle({
+            store: gw.store,
+            clock: () => Date.now() + 16 * 60_000,
+        });
+        await restarted.configure({ abandonAfter: '15m' });
+        await restarted.close();
+
+        expect(inProgress(gw)).toEqual([]);
+        expect(await gw.store.getInteraction(lost!.id, 'acme')).toMatchObject({ status: 'abandoned' });
+
+        provider.open();
+        await pending;
+    });
+
+    it('should record a failed request with its status code', async () => {
+        const { gw } = await setup(new FailingProvider('mock', {}));
+
+        const response = await gw.post('/v1/chat/completions', chat);
+        const id = gw.store.eventsOf('interaction_started')[0]!.interactionId;
+
+        expect(response.status).toBe(502);
+        await vi.waitFor(async () => expect(await gw.store.getInteraction(id, 'acme'))
+            .toMatchObject({ status: 'failed', statusCode: 502 }));
+    });
+
+    it('should list interactions by status from the admin API', async () => {
+        const provider = new HangingProvider('mock', { text: 'Hello' });
+        const { gw, admin } = await setup(provider);
+
+        const pending = gw.post('/v1/chat/completions', chat);
+        await vi.waitFor(async () => expect(inProgress(gw)).toHaveLength(1));
+
+        const listed = await admin.handle(new Request('http://localhost/api/interactions?status=in_progress'));
+        const body = await listed.json();
+        expect(body.total).toBe(1);
+        expect(body.interactions[0]).toMatchObject({ type: 'request', status: 'in_progress', model: 'gpt-4o', provider: 'mock' });
+
+        const invalid = await admin.handle(new Request('http://localhost/api/interactions?status=running'));
+        expect(invalid.status).toBe(400);
+
+        provider.open();
+        await pending;
+        await vi.waitFor(async () => expect(inProgress(gw)).toEqual([]));
+    });
+
+    it('should end the tail of an abandoned interaction with its recorded status', async () => {
+        const { gw, admin } = await setup(new ScriptedProvider('mock', { text: 'Hello' }));
+        await gw.store.recordInteraction(record('int-lost', 'abandoned', Date.now()));
+
+        const response = await admin.handle(new Request('http://localhost/api/interactions/int-lost/tail?wait=0s'));
+        const frames = parseSSE(await response.text());
+
+        expect(frames.at(-1)).toMatchObject({ event: 'end' });
+        expect(JSON.parse(frames.at(-1)!.data).status).toBe('abandoned');
+    });
+});
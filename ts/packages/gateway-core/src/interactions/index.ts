@@ -0,0 +1,16 @@
+/**
+ * Interaction status exports.
+ *
+ * @module interactions
+ */
+
+export {
+    InteractionLifecycle,
+    DEFAULT_INTERACTION_ABANDON_AFTER,
+    DEFAULT_INTERACTION_SWEEP_INTERVAL,
+    INTERACTION_LIFECYCLE_STATUSES,
+    isInteractionRecordStore,
+    type InteractionLifecycleOptions,
+    type InteractionOutcome,
+    type InteractionRecordStore,
+} from './lifecycle.js';
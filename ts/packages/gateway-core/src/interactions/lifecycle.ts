@@ -0,0 +1,231 @@
+/**
+ * Interaction status lifecycle.
+ *
+ * Every request's interaction record is saved to the interaction store as
+ * an in_progress stub as soon as its frontdoor has decoded it, before the
+ * provider is called, so a request lost with the gateway serving it (a
+ * crash, a deploy) still shows up. The record is updated to completed,
+ * failed, or cancelled when the response or stream finishes. Records
+ * still in_progress after abandonAfter are swept to abandoned: once at
+ * startup, then every sweepInterval.
+ *
+ * Record writes never hold up the request; failures are logged.
+ *
+ * @module interactions/lifecycle
+ */
+
+import type { InteractionStatusConfig } from '../ports/config.js';
+import type {
+    InteractionLifecycleStatus,
+    InteractionRecord,
+    InteractionStore,
+    StorageProvider,
+} from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default age at which an in_progress interaction is abandoned. */
+export const DEFAULT_INTERACTION_ABANDON_AFTER = '15m';
+
+/** Default time between sweeps for abandoned interactions. */
+export const DEFAULT_INTERACTION_SWEEP_INTERVAL = '1m';
+
+const DEFAULT_ABANDON_AFTER_MS = parseDuration(DEFAULT_INTERACTION_ABANDON_AFTER, 0);
+const DEFAULT_SWEEP_INTERVAL_MS = parseDuration(DEFAULT_INTERACTION_SWEEP_INTERVAL, 0);
+
+/** Lifecycle statuses, for validating filters. */
+export const INTERACTION_LIFECYCLE_STATUSES: readonly InteractionLifecycleStatus[] = [
+    'in_progress',
+    'completed',
+    'failed',
+    'cancelled',
+    'abandoned',
+];
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * An interaction store that keeps requests' interaction records.
+ */
+export type InteractionRecordStore = Required<
+    Pick<InteractionStore, 'recordInteraction' | 'updateInteraction' | 'getInteraction' | 'abandonInteractions'>
+>;
+
+/**
+ * Interaction lifecycle options.
+ */
+export interface InteractionLifecycleOptions {
+    /** Interaction store the records are kept in. */
+    store: InteractionRecordStore;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests (default: Date.now). */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * How an interaction ended.
+ */
+export interface InteractionOutcome {
+    /** Final status. */
+    status: Exclude<InteractionLifecycleStatus, 'in_progress' | 'abandoned'>;
+
+    /** HTTP status sent. */
+    statusCode?: number | undefined;
+
+    /** Error type, for failed interactions. */
+    errorType?: string | undefined;
+}
+
+// ============================================================================
+// Interaction Lifecycle
+// ============================================================================
+
+/**
+ * Writes requests' interaction records and sweeps abandoned ones.
+ */
+export class InteractionLifecycle {
+    private readonly store: InteractionRecordStore;
+    private readonly logger?: Logger | undefined;
+    private readonly clock: () => number;
+    private abandonAfterMs = DEFAULT_ABANDON_AFTER_MS;
+    private sweepIntervalMs = DEFAULT_SWEEP_INTERVAL_MS;
+    private timer?: ReturnType<typeof setInterval> | undefined;
+
+    /** Start writes not yet settled, by interaction ID. */
+    private readonly starting = new Map<string, Promise<void>>();
+
+    constructor(options: InteractionLifecycleOptions) {
+        this.store = options.store;
+        this.logger = options.logger;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /**
+     * Applies the sweep settings, after a config load. The first call
+     * sweeps at once and starts the schedule; later ones reschedule it if
+     * the interval changed.
+     */
+    async configure(config: InteractionStatusConfig | undefined): Promise<void> {
+        const sweepIntervalMs = parseDuration(config?.sweepInterval, DEFAULT_SWEEP_INTERVAL_MS);
+        this.abandonAfterMs = parseDuration(config?.abandonAfter, DEFAULT_ABANDON_AFTER_MS);
+
+        if (this.timer && sweepIntervalMs === this.sweepIntervalMs) {
+            return;
+        }
+        const first = !this.timer;
+        if (this.timer) {
+            clearInterval(this.timer);
+        }
+        this.sweepIntervalMs = sweepIntervalMs;
+        this.timer = setInterval(() => void this.sweep(), this.sweepIntervalMs);
+        (this.timer as { unref?: () => void }).unref?.();
+        if (first) {
+            await this.sweep();
+        }
+    }
+
+    /**
+     * Saves an interaction's in_progress stub. Returns without waiting for
+     * the write.
+     */
+    start(record: Omit<InteractionRecord, 'status' | 'createdAt' | 'updatedAt'>): void {
+        const now = new Date(this.clock());
+        const write = this.store
+            .recordInteraction({ ...record, status: 'in_progress', createdAt: now, updatedAt: now })
+            .catch((error) => this.warn('interaction_status_save_failed', record.id, error))
+            .finally(() => {
+                if (this.starting.get(record.id) === write) {
+                    this.starting.delete(record.id);
+                }
+            });
+        this.starting.set(record.id, write);
+    }
+
+    /**
+     * Records how an interaction ended. One that was never started (it
+     * failed before its frontdoor decoded it) is saved from the fallback
+     * record given, if any.
+     */
+    async finish(
+        id: string,
+        outcome: InteractionOutcome,
+        fallback?: Omit<InteractionRecord, 'status' | 'createdAt' | 'updatedAt'>,
+    ): Promise<void> {
+        const now = new Date(this.clock());
+        try {
+            await this.starting.get(id);
+            const updated = await this.store.updateInteraction(id, { ...outcome, updatedAt: now });
+            if (!updated && fallback) {
+                await this.store.recordInteraction({ ...fallback, ...outcome, createdAt: now, updatedAt: now });
+            }
+        } catch (error) {
+            this.warn('interaction_status_save_failed', id, error);
+        }
+    }
+
+    /**
+     * Marks interactions in_progress for longer than abandonAfter as
+     * abandoned. Returns how many were.
+     */
+    async sweep(): Promise<number> {
+        const now = this.clock();
+        try {
+            const abandoned = await this.store.abandonInteractions(new Date(now - this.abandonAfterMs), new Date(now));
+            if (abandoned > 0) {
+                this.logger?.warn('interactions_abandoned', { count: abandoned, abandonAfterMs: this.abandonAfterMs });
+            }
+            return abandoned;
+        } catch (error) {
+            this.logger?.warn('interaction_sweep_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return 0;
+        }
+    }
+
+    /**
+     * Stops the sweep schedule and waits for start writes in flight.
+     */
+    async close(): Promise<void> {
+        if (this.timer) {
+            clearInterval(this.timer);
+            this.timer = undefined;
+        }
+        await Promise.all(this.starting.values());
+    }
+
+    private warn(event: string, id: string, error: unknown): void {
+        this.logger?.warn(event, {
+            interactionId: id,
+            error: error instanceof Error ? error.message : String(error),
+        });
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider keeps interaction records.
+ */
+export function isInteractionRecordStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & InteractionRecordStore {
+    return (
+        storage !== undefined &&
+        typeof storage.recordInteraction === 'function' &&
+        typeof storage.updateInteraction === 'function' &&
+        typeof storage.getInteraction === 'function' &&
+        typeof storage.abandonInteractions === 'function'
+    );
+}
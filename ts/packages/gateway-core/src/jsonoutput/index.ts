@@ -0,0 +1,16 @@
+/**
+ * JSON output validation exports.
+ *
+ * @module jsonoutput
+ */
+
+export {
+    isJsonMode,
+    checkJsonOutput,
+    balanceJsonStream,
+    withJsonValidation,
+    JsonBalance,
+    JsonValidatingProvider,
+    type JsonValidationStatus,
+    type JsonValidationOutcome,
+} from './validation.js';
@@ -0,0 +1,163 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { JsonBalance, balanceJsonStream, type JsonValidationOutcome } from './index';
+import type { JsonOutputValidationConfig } from '../ports/index';
+import { harness, ScriptedProvider, type Reply, type TestGateway } from '../__tests__/harness/index';
+
+const usage = { promptTokens: 1, completionTokens: 1, totalTokens: 2 };
+
+const schemaFormat = {
+    type: 'json_schema',
+    json_schema: {
+        name: 'answer',
+        schema: { type: 'object', properties: { answer: { type: 'string' } }, required: ['answer'] },
+    },
+};
+
+const truncated: Reply = {
+    events: [
+        { type: 'message_start', role: 'assistant' },
+        { type: 'content_delta', contentDelta: '{"items": [1, 2' },
+        { type: 'content_delta', contentDelta: ', {"name": "thr' },
+        { type: 'content_delta', contentDelta: '', finishReason: 'length' },
+        { type: 'done' },
+    ],
+};
+
+describe('JsonBalance', () => {
+    it.each([
+        ['{"a": 1', '}'],
+        ['{"a": [true, fa', 'lse]}'],
+        ['{"a": "x\\', '\\"}'],
+        ['{"a', '":null}'],
+        ['{"a":', 'null}'],
+        ['[1, 2.', '0]'],
+        ['{"a": {}, "b": []}', ''],
+    ])('should close %s', (text, closing) => {
+        const balance = new JsonBalance();
+        for (const ch of text) {
+            balance.push(ch);
+        }
+
+        expect(balance.closing()).toBe(closing);
+        expect(() => JSON.parse(text + closing)).not.toThrow();
+    });
+});
+
+describe('JSON output validation', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(replies: Reply[], validateJsonOutput: JsonOutputValidationConfig | undefined = {}) {
+        const provider = new ScriptedProvider('mock', replies.length > 0 ? replies : truncated);
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', validateJsonOutput })
+            .provider(provider)
+            .start();
+        const gw = gateway;
+        const send = (body: Record<string, unknown>) =>
+            gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...body });
+        return { provider, send };
+    }
+
+    const text = (...replies: string[]): Reply[] => replies.map((reply) => ({ text: reply, usage }));
+
+    it('should pass valid output through', async () => {
+        const { provider, send } = await setup(text('{"answer": "yes"}'));
+
+        const response = await send({ response_format: schemaFormat });
+
+        expect(response.status).toBe(200);
+        expect((await response.json()).choices[0].message.content).toBe('{"answer": "yes"}');
+        expect(provider.requests).toHaveLength(1);
+    });
+
+    it('should leave requests without JSON mode alone', async () => {
+        const { send } = await setup(text('not json'));
+
+        expect((await send({})).status).toBe(200);
+    });
+
+    it('should return a 502 for invalid output by default', async () => {
+        const { provider, send } = await setup(text('Sure! {"answer": "yes"}'));
+
+        const response = await send({ response_format: { type: 'json_object' } });
+
+        expect(response.status).toBe(502);
+        const body = await response.json();
+        expect(body.error.code).toBe('invalid_json_output');
+        expect(body.error.message).toContain('not valid JSON');
+        expect(provider.requests).toHaveLength(1);
+    });
+
+    it('should reject output that parses but misses the schema', async () => {
+        const { send } = await setup(text('{"reply": "yes"}'));
+
+        const response = await send({ response_format: schemaFormat });
+
+        expect(response.status).toBe(502);
+        expect((await response.json()).error.message).toContain('does not match the schema');
+    });
+
+    it('should repair invalid output with one retry', async () => {
+        const { provider, send } = await setup(text('{"answer": "yes"', '{"answer": "yes"}'), { onInvalid: 'repair' });
+
+        const response = await send({ response_format: schemaFormat });
+
+        expect(response.status).toBe(200);
+        const body = await response.json();
+        expect(body.choices[0].message.content).toBe('{"answer": "yes"}');
+        expect(body.usage.total_tokens).toBe(4);
+
+        const retry = provider.requests[1]!;
+        expect(retry.messages.at(-2)).toMatchObject({ role: 'assistant', content: '{"answer": "yes"' });
+        expect(retry.messages.at(-1)!.content).toContain('not valid JSON');
+    });
+
+    it('should fail when the repair is still invalid', async () => {
+        const { provider, send } = await setup(text('nope', 'still nope'), { onInvalid: 'repair' });
+
+        const response = await send({ response_format: { type: 'json_object' } });
+
+        expect(response.status).toBe(502);
+        expect((await response.json()).error.message).toContain('after a repair attempt');
+        expect(provider.requests).toHaveLength(2);
+    });
+
+    it('should close a truncated stream and warn on the final chunk', async () => {
+        const { send } = await setup([]);
+
+        const response = await send({ stream: true, response_format: { type: 'json_object' } });
+        const chunks = (await response.text())
+            .split('\n\n')
+            .filter((line) => line.startsWith('data: {'))
+            .map((line) => JSON.parse(line.slice(6)));
+
+        const content = chunks.map((chunk) => chunk.choices[0]?.delta.content ?? '').join('');
+        expect(JSON.parse(content)).toEqual({ items: [1, 2, { name: 'thr' }] });
+        expect(chunks.at(-1).warning).toContain(`'"}]}'`);
+    });
+});
+
+describe('balanceJsonStream', () => {
+    it('should report balanced streams as valid', async () => {
+        const outcomes: JsonValidationOutcome[] = [];
+        async function* source() {
+            yield { type: 'content_delta' as const, contentDelta: '{"a": ' };
+            yield { type: 'content_delta' as const, contentDelta: '1}', finishReason: 'stop' };
+            yield { type: 'done' as const };
+        }
+
+        const events = [];
+        for await (const event of balanceJsonStream(source(), (outcome) => outcomes.push(outcome))) {
+            events.push(event);
+        }
+
+        expect(events).toHaveLength(3);
+        expect(events.at(-1)!.warning).toBeUndefined();
+        expect(outcomes).toEqual([{ status: 'valid', repairAttempts: 0, stream: true, detail: undefined }]);
+    });
+});
@@ -0,0 +1,472 @@
+/**
+ * JSON-mode output validation.
+ *
+ * With an app's `validate_json_output` set, responses to requests that ask
+ * for JSON (response_format json_object or json_schema) are checked before
+ * the frontdoor encodes them. Non-streamed output must parse, and match the
+ * schema when one was given; otherwise the request fails with a 502
+ * invalid_json_output error, or is retried once with an instruction to fix
+ * the JSON. Streamed output can't be taken back, so the stream's brace and
+ * bracket balance is tracked as it goes; a stream that ends inside an
+ * object, array, or string gets a closing delta and a warning on its done
+ * event.
+ *
+ * @module jsonoutput/validation
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Usage,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { JsonOutputInvalidAction, JsonOutputValidationConfig } from '../ports/config.js';
+import { errInvalidJSONOutput } from '../domain/errors.js';
+import { validateSchema, type JSONSchema } from '../domain/schema.js';
+import { isCallBudgetExceeded } from '../callbudget/budget.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Validation result for one response: valid as returned, valid after a
+ * repair retry, still invalid, or (streams) closed by the gateway.
+ */
+export type JsonValidationStatus = 'valid' | 'repaired' | 'invalid' | 'closed';
+
+/**
+ * Outcome reported for each validated response.
+ */
+export interface JsonValidationOutcome {
+    /** Validation result. */
+    status: JsonValidationStatus;
+
+    /** Number of repair retries made. */
+    repairAttempts: number;
+
+    /** Whether the response was streamed. */
+    stream: boolean;
+
+    /** Why the output is invalid, or the text appended to close a stream. */
+    detail?: string | undefined;
+}
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/** Violations quoted in an error message. */
+const MAX_REPORTED_VIOLATIONS = 3;
+
+/**
+ * Reports whether a request asks for JSON output.
+ */
+export function isJsonMode(request: CanonicalRequest): boolean {
+    const type = request.responseFormat?.type;
+    return type === 'json_object' || type === 'json_schema';
+}
+
+/**
+ * Returns the schema a json_schema request's output must match. The
+ * format's jsonSchema is OpenAI's envelope ({ name, schema, strict }).
+ */
+function requestSchema(request: CanonicalRequest): JSONSchema | undefined {
+    const schema = request.responseFormat?.jsonSchema?.['schema'];
+    return typeof schema === 'object' && schema !== null ? schema as JSONSchema : undefined;
+}
+
+/**
+ * Checks that text is JSON matching the schema, if any. Returns the
+ * problem, or undefined when the text is valid.
+ */
+export function checkJsonOutput(text: string, schema?: JSONSchema): string | undefined {
+    let value: unknown;
+    try {
+        value = JSON.parse(text);
+    } catch (error) {
+        return `not valid JSON: ${error instanceof Error ? error.message : String(error)}`;
+    }
+    if (!schema) {
+        return undefined;
+    }
+    const violations = validateSchema(schema, value);
+    if (violations.length === 0) {
+        return undefined;
+    }
+    return `does not match the schema: ${violations.slice(0, MAX_REPORTED_VIOLATIONS).join('; ')}`;
+}
+
+/**
+ * Checks each choice's text, skipping choices that called tools. Returns
+ * the first problem found and the text it was found in.
+ */
+function checkResponse(
+    response: CanonicalResponse,
+    schema: JSONSchema | undefined,
+): { problem: string; text: string } | undefined {
+    for (const choice of response.choices) {
+        if (choice.message.toolCalls?.length) {
+            continue;
+        }
+        const text = typeof choice.message.content === 'string' ? choice.message.content : '';
+        const problem = checkJsonOutput(text, schema);
+        if (problem) {
+            return { problem, text };
+        }
+    }
+    return undefined;
+}
+
+/**
+ * Builds the repair retry: the original conversation, the invalid reply,
+ * and an instruction to send it again as valid JSON.
+ */
+function repairRequest(request: CanonicalRequest, text: string, problem: string): CanonicalRequest {
+    return {
+        ...request,
+        messages: [
+            ...request.messages,
+            { role: 'assistant', content: text },
+            {
+                role: 'user',
+                content: `Your previous reply is ${problem}. Reply again with only the corrected JSON and no other text.`,
+            },
+        ],
+    };
+}
+
+/**
+ * Sums the usage of the original call and its repair retry.
+ */
+function addUsage(a: Usage | undefined, b: Usage | undefined): Usage | undefined {
+    if (!a || !b) {
+        return b ?? a;
+    }
+    return {
+        promptTokens: a.promptTokens + b.promptTokens,
+        completionTokens: a.completionTokens + b.completionTokens,
+        totalTokens: a.totalTokens + b.totalTokens,
+        reasoningTokens: a.reasoningTokens !== undefined || b.reasoningTokens !== undefined
+            ? (a.reasoningTokens ?? 0) + (b.reasoningTokens ?? 0)
+            : undefined,
+    };
+}
+
+// ============================================================================
+// Stream Balance
+// ============================================================================
+
+/** What an open container expects next. */
+type Expect = 'key' | 'colon' | 'value' | 'comma';
+
+/** An open object or array. */
+interface Container {
+    close: '}' | ']';
+    expect: Expect;
+    empty: boolean;
+}
+
+/** Completions for a truncated bare literal. */
+const LITERALS = ['true', 'false', 'null'];
+
+/**
+ * Tracks JSON nesting across streamed text and works out the text that
+ * would close it. Text outside the JSON (e.g. a code fence) is skipped
+ * over, not validated.
+ */
+export class JsonBalance {
+    private readonly stack: Container[] = [];
+    private inString = false;
+    private escaped = false;
+    private stringIsKey = false;
+    private literal = '';
+
+    /**
+     * Feeds the next piece of text.
+     */
+    push(text: string): void {
+        for (const ch of text) {
+            this.step(ch);
+        }
+    }
+
+    /**
+     * Returns the text that closes every open string and container, or ''
+     * when the JSON so far is balanced.
+     */
+    closing(): string {
+        let out = '';
+        const top = this.stack.at(-1);
+        let pending = top?.expect;
+
+        if (this.inString) {
+            out += (this.escaped ? '\\' : '') + '"';
+            out += this.stringIsKey ? ':null' : '';
+            pending = 'comma';
+        } else if (this.literal) {
+            out += completeLiteral(this.literal);
+            pending = 'comma';
+        }
+
+        if (top) {
+            if (pending === 'colon') {
+                out += ':null';
+            } else if (pending === 'value' && !top.empty) {
+                out += 'null';
+            } else if (pending === 'key' && !top.empty) {
+                out += '"":null';
+            }
+        }
+        for (let i = this.stack.length - 1; i >= 0; i--) {
+            out += this.stack[i]!.close;
+        }
+        return out;
+    }
+
+    private step(ch: string): void {
+        if (this.inString) {
+            if (this.escaped) {
+                this.escaped = false;
+            } else if (ch === '\\') {
+                this.escaped = true;
+            } else if (ch === '"') {
+                this.inString = false;
+                this.endValue(this.stringIsKey ? 'colon' : 'comma');
+            }
+            return;
+        }
+
+        if (/[A-Za-z0-9.+-]/.test(ch)) {
+            if (!this.literal) {
+                this.startValue();
+            }
+            this.literal += ch;
+            return;
+        }
+        if (this.literal) {
+            this.literal = '';
+            this.endValue('comma');
+        }
+
+        const top = this.stack.at(-1);
+        switch (ch) {
+            case '"':
+                this.stringIsKey = top?.close === '}' && top.expect === 'key';
+                this.startValue();
+                this.inString = true;
+                break;
+            case '{':
+                this.startValue();
+                this.stack.push({ close: '}', expect: 'key', empty: true });
+                break;
+            case '[':
+                this.startValue();
+                this.stack.push({ close: ']', expect: 'value', empty: true });
+                break;
+            case '}':
+            case ']':
+                if (top?.close === ch) {
+                    this.stack.pop();
+                    this.endValue('comma');
+                }
+                break;
+            case ':':
+                if (top) top.expect = 'value';
+                break;
+            case ',':
+                if (top) top.expect = top.close === '}' ? 'key' : 'value';
+                break;
+        }
+    }
+
+    private startValue(): void {
+        const top = this.stack.at(-1);
+        if (top) top.empty = false;
+    }
+
+    private endValue(next: Expect): void {
+        const top = this.stack.at(-1);
+        if (top) top.expect = next;
+    }
+}
+
+/**
+ * Completes a truncated literal or number.
+ */
+function completeLiteral(literal: string): string {
+    const word = LITERALS.find((w) => w.startsWith(literal));
+    if (word) {
+        return word.slice(literal.length);
+    }
+    return /[-+.eE]$/.test(literal) ? '0' : '';
+}
+
+/**
+ * Closes any choice whose streamed JSON ends unbalanced. The closing delta
+ * goes out before the choice's finish (or the block's stop), and the done
+ * event carries a warning naming what was appended.
+ */
+export async function* balanceJsonStream(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    onOutcome: (outcome: JsonValidationOutcome) => void,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const choices = new Map<number, { balance: JsonBalance; last: CanonicalEvent; text: string; closed: boolean }>();
+    const appended: string[] = [];
+
+    function* close(index: number | undefined): Generator<CanonicalEvent> {
+        for (const [choiceIndex, choice] of choices) {
+            if (choice.closed || (index !== undefined && choiceIndex !== index)) {
+                continue;
+            }
+            choice.closed = true;
+            const closing = choice.balance.closing();
+            if (closing) {
+                choice.text += closing;
+                appended.push(closing);
+                yield {
+                    type: choice.last.type,
+                    contentDelta: closing,
+                    index: choice.last.index,
+                    choiceIndex: choice.last.choiceIndex,
+                };
+            }
+        }
+    }
+
+    const warning = () => appended.length > 0
+        ? `JSON output ended unbalanced; the gateway appended ${appended.map((text) => `'${text}'`).join(', ')} to close it`
+        : undefined;
+
+    for await (const event of source) {
+        const index = event.choiceIndex ?? 0;
+        if (event.contentDelta) {
+            let choice = choices.get(index);
+            if (!choice) {
+                choice = { balance: new JsonBalance(), last: event, text: '', closed: false };
+                choices.set(index, choice);
+            }
+            choice.last = event;
+            choice.balance.push(event.contentDelta);
+            choice.text += event.contentDelta;
+            if (event.finishReason === undefined || choice.balance.closing() === '') {
+                yield event;
+                continue;
+            }
+            // The finishing chunk carries text; close after it
+            yield { ...event, finishReason: undefined };
+            yield* close(index);
+            yield { ...event, contentDelta: undefined };
+            continue;
+        }
+
+        if (event.type === 'content_block_stop' || event.type === 'content_done' || event.finishReason !== undefined) {
+            yield* close(index);
+        } else if (event.type === 'message_stop' || event.type === 'done') {
+            yield* close(undefined);
+        }
+
+        const text = event.type === 'done' ? warning() : undefined;
+        yield text ? { ...event, warning: text } : event;
+    }
+    yield* close(undefined);
+
+    const problem = [...choices.values()].map((choice) => checkJsonOutput(choice.text)).find(Boolean);
+    onOutcome({
+        status: problem ? 'invalid' : appended.length > 0 ? 'closed' : 'valid',
+        repairAttempts: 0,
+        stream: true,
+        detail: problem ?? (appended.join(', ') || undefined),
+    });
+}
+
+// ============================================================================
+// Validating Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so JSON-mode responses are validated.
+ */
+export class JsonValidatingProvider extends ProviderWrapper {
+    private readonly onInvalid: JsonOutputInvalidAction;
+    private readonly onOutcome: (outcome: JsonValidationOutcome) => void;
+
+    constructor(
+        inner: Provider,
+        config: JsonOutputValidationConfig,
+        onOutcome: (outcome: JsonValidationOutcome) => void,
+    ) {
+        super(inner);
+        this.onInvalid = config.onInvalid ?? 'error';
+        this.onOutcome = onOutcome;
+    }
+
+    /**
+     * Completes a request, validating JSON-mode output and repairing it
+     * once when configured to.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, options);
+        if (!isJsonMode(request)) {
+            return response;
+        }
+        const schema = requestSchema(request);
+        const invalid = checkResponse(response, schema);
+        if (!invalid) {
+            this.onOutcome({ status: 'valid', repairAttempts: 0, stream: false });
+            return response;
+        }
+
+        if (this.onInvalid !== 'repair') {
+            this.onOutcome({ status: 'invalid', repairAttempts: 0, stream: false, detail: invalid.problem });
+            throw errInvalidJSONOutput(`Model output is ${invalid.problem}`);
+        }
+
+        let retry: CanonicalResponse;
+        try {
+            retry = await this.inner.complete(
+                repairRequest(request, invalid.text, invalid.problem),
+                { ...options, attempt: 'retry' },
+            );
+        } catch (error) {
+            // The request's call budget refused the repair; the invalid
+            // output is no usable result, so the refusal is the answer
+            if (isCallBudgetExceeded(error)) {
+                this.onOutcome({ status: 'invalid', repairAttempts: 0, stream: false, detail: invalid.problem });
+            }
+            throw error;
+        }
+        const stillInvalid = checkResponse(retry, schema);
+        if (stillInvalid) {
+            this.onOutcome({ status: 'invalid', repairAttempts: 1, stream: false, detail: stillInvalid.problem });
+            throw errInvalidJSONOutput(`Model output is ${stillInvalid.problem} after a repair attempt`);
+        }
+        this.onOutcome({ status: 'repaired', repairAttempts: 1, stream: false });
+        return { ...retry, usage: addUsage(response.usage, retry.usage) };
+    }
+
+    /**
+     * Streams a request, closing JSON-mode output that ends unbalanced.
+     */
+    stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const source = this.inner.stream(request, options);
+        return isJsonMode(request) ? balanceJsonStream(source, this.onOutcome) : source;
+    }
+}
+
+/**
+ * Validates a provider's JSON-mode responses. Returns the provider
+ * unchanged when validation isn't configured.
+ */
+export function withJsonValidation(
+    provider: Provider,
+    config: JsonOutputValidationConfig | undefined,
+    onOutcome: (outcome: JsonValidationOutcome) => void,
+): Provider {
+    if (!config) {
+        return provider;
+    }
+    return new JsonValidatingProvider(provider, config, onOutcome);
+}
@@ -0,0 +1,28 @@
+import { bench, describe } from 'vitest';
+import { NullLogger } from './utils/logging';
+import { LogControl, ControlledLogger } from './logging/index';
+
+// A filtered-out debug entry is the hot path: request loggers emit them on
+// every request and almost all are dropped.
+const sink = new NullLogger();
+const fields = { step: 'decode' };
+
+const idle = new ControlledLogger(sink, new LogControl()).child({ tenantId: 'globex', provider: 'openai' });
+
+const scopedControl = new LogControl();
+scopedControl.set({ scopes: [{ tenant: 'acme' }, { provider: 'anthropic' }], ttlMs: 60 * 60 * 1000 });
+const scoped = new ControlledLogger(sink, scopedControl).child({ tenantId: 'globex', provider: 'openai' });
+
+describe('dropped debug entry', () => {
+    bench('uncontrolled logger', () => {
+        sink.debug('decoded', fields);
+    });
+
+    bench('no overrides', () => {
+        idle.debug('decoded', fields);
+    });
+
+    bench('non-matching overrides', () => {
+        scoped.debug('decoded', fields);
+    });
+});
@@ -0,0 +1,103 @@
+import { describe, it, expect, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { LogControl, ControlledLogger } from './logging/index';
+
+const logger = () => {
+    const l = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    l.child.mockReturnValue(l);
+    return l;
+};
+
+describe('LogControl', () => {
+    it('should change the global level and revert it after the TTL', () => {
+        let now = 0;
+        const audit = logger();
+        const control = new LogControl({ logger: audit as any, now: () => now });
+
+        expect(control.enabled('debug', {})).toBe(false);
+        control.set({ level: 'debug', ttlMs: 1000 });
+
+        expect(control.enabled('debug', {})).toBe(true);
+        expect(audit.info).toHaveBeenCalledWith('logging_changed', expect.objectContaining({ audit: true, level: 'debug' }));
+
+        now = 1000;
+        expect(control.enabled('debug', {})).toBe(false);
+        expect(control.state()).toEqual({ level: 'info', baseLevel: 'info' });
+        expect(audit.info).toHaveBeenCalledWith('logging_level_expired', { audit: true, level: 'debug', baseLevel: 'info' });
+    });
+
+    it('should log matching scopes at debug regardless of the global level', () => {
+        let now = 0;
+        const control = new LogControl({ now: () => now });
+        control.set({ scopes: [{ tenant: 'acme' }, { provider: 'openai', app: 'chat' }], ttlMs: 1000 });
+
+        expect(control.enabled('debug', { tenantId: 'acme' })).toBe(true);
+        expect(control.enabled('debug', { tenantId: 'globex', provider: 'openai', app: 'chat' })).toBe(true);
+        expect(control.enabled('debug', { tenantId: 'globex', provider: 'openai' })).toBe(false);
+        expect(control.enabled('info', { tenantId: 'globex' })).toBe(true);
+        expect(control.state().override).toEqual({
+            level: 'debug',
+            scopes: [{ tenant: 'acme' }, { provider: 'openai', app: 'chat' }],
+            expiresAt: new Date(1000).toISOString(),
+        });
+
+        now = 1000;
+        expect(control.enabled('debug', { tenantId: 'acme' })).toBe(false);
+        expect(control.state().override).toBeUndefined();
+    });
+
+    it('should replace an earlier change and reset to the base level', () => {
+        const control = new LogControl({ level: 'warn' });
+        control.set({ level: 'debug' });
+        control.set({ scopes: [{ tenant: 'acme' }] });
+
+        expect(control.state().level).toBe('warn');
+        expect(control.reset()).toEqual({ level: 'warn', baseLevel: 'warn' });
+    });
+});
+
+describe('ControlledLogger', () => {
+    it('should filter entries by the context accumulated through child loggers', () => {
+        const sink = logger();
+        const control = new LogControl();
+        const root = new ControlledLogger(sink as any, control);
+        const request = root.child({ tenantId: 'acme' }).child({ provider: 'openai' });
+        control.set({ scopes: [{ tenant: 'acme', provider: 'openai' }] });
+
+        root.debug('global');
+        request.debug('scoped', { step: 1 });
+
+        expect(sink.debug).toHaveBeenCalledTimes(1);
+        expect(sink.debug).toHaveBeenCalledWith('scoped', { step: 1 });
+        expect(sink.child).toHaveBeenCalledWith({ tenantId: 'acme' });
+    });
+});
+
+describe('Admin /api/logging', () => {
+    const send = (admin: AdminHandler, method: string, body?: unknown) => admin.handle(new Request('http://localhost/api/logging', {
+        method,
+        ...(body !== undefined && { body: JSON.stringify(body) }),
+    }));
+
+    it('should apply, report, and reset logging changes', async () => {
+        const admin = new AdminHandler({ logging: new LogControl() });
+
+        const put = await send(admin, 'PUT', { level: 'debug', scopes: [{ tenant: 'acme' }, { provider: 'openai' }], ttl: '15m' });
+        expect(put.status).toBe(200);
+
+        const state = await (await send(admin, 'GET')).json();
+        expect(state.level).toBe('info');
+        expect(state.override.scopes).toEqual([{ tenant: 'acme' }, { provider: 'openai' }]);
+
+        expect(await (await send(admin, 'DELETE')).json()).toEqual({ level: 'info', baseLevel: 'info' });
+    });
+
+    it('should reject invalid changes', async () => {
+        const admin = new AdminHandler({ logging: new LogControl() });
+
+        expect((await send(admin, 'PUT', { level: 'verbose' })).status).toBe(400);
+        expect((await send(admin, 'PUT', { level: 'debug', ttl: 'soon' })).status).toBe(400);
+        expect((await send(admin, 'PUT', { scopes: [{}] })).status).toBe(400);
+        expect((await send(new AdminHandler(), 'GET')).status).toBe(503);
+    });
+});
@@ -0,0 +1,267 @@
+/**
+ * Runtime log level control and scoped debug logging.
+ *
+ * LogControl holds the global log level, which the admin API can change
+ * without a restart, and scoped overrides that let requests for one tenant,
+ * provider, or app log at a more verbose level than everything else. Every
+ * change expires after its TTL and is audit-logged. ControlledLogger applies
+ * the control in front of any Logger; with no overrides active, filtering an
+ * entry is one comparison and allocates nothing.
+ *
+ * @module logging/control
+ */
+
+import { LOG_LEVELS, type Logger, type LogLevel } from '../utils/logging.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default lifetime of a logging change. */
+export const DEFAULT_LOG_OVERRIDE_TTL_MS = 15 * 60 * 1000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Requests a scoped override applies to. Every field set must match the
+ * logger's context (tenantId, provider, app).
+ */
+export interface LogScope {
+    /** Tenant ID. */
+    tenant?: string | undefined;
+
+    /** Provider name. */
+    provider?: string | undefined;
+
+    /** App name. */
+    app?: string | undefined;
+}
+
+/**
+ * A logging change, as accepted by PUT /admin/api/logging.
+ */
+export interface LoggingChange {
+    /** Global level, or with scopes, the level for matching requests (default: debug). */
+    level?: LogLevel | undefined;
+
+    /** Scopes logged at the level regardless of the global level. */
+    scopes?: LogScope[] | undefined;
+
+    /** How long the change lasts (default: 15 minutes). */
+    ttlMs?: number | undefined;
+}
+
+/**
+ * Current logging state, as reported by GET /admin/api/logging.
+ */
+export interface LoggingState {
+    /** Effective global level. */
+    level: LogLevel;
+
+    /** Level the global level reverts to. */
+    baseLevel: LogLevel;
+
+    /** When a changed global level reverts. */
+    levelExpiresAt?: string | undefined;
+
+    /** Active scoped override. */
+    override?: {
+        level: LogLevel;
+        scopes: LogScope[];
+        expiresAt: string;
+    } | undefined;
+}
+
+/**
+ * LogControl options.
+ */
+export interface LogControlOptions {
+    /** Global level (default: info). */
+    level?: LogLevel | undefined;
+
+    /** Audit log for changes; should not itself be controlled. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests. */
+    now?: (() => number) | undefined;
+}
+
+// ============================================================================
+// Log Control
+// ============================================================================
+
+/**
+ * Dynamic global log level plus expiring scoped overrides.
+ */
+export class LogControl {
+    private readonly baseLevel: LogLevel;
+    private readonly logger: Logger | undefined;
+    private readonly now: () => number;
+
+    private level: LogLevel;
+    private threshold: number;
+    private levelExpiresAt = Infinity;
+
+    private scopes: LogScope[] = [];
+    private scopeLevel: LogLevel = 'debug';
+    private scopesExpireAt = Infinity;
+
+    /** Earliest expiry, so the hot path checks one number. */
+    private nextExpiry = Infinity;
+
+    constructor(options: LogControlOptions = {}) {
+        this.baseLevel = options.level ?? 'info';
+        this.level = this.baseLevel;
+        this.threshold = LOG_LEVELS[this.level];
+        this.logger = options.logger;
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Whether an entry at a level should be logged for a logger context.
+     */
+    enabled(level: LogLevel, context: Record<string, unknown>): boolean {
+        if (this.nextExpiry !== Infinity && this.now() >= this.nextExpiry) {
+            this.expire();
+        }
+        const value = LOG_LEVELS[level];
+        if (value >= this.threshold) {
+            return true;
+        }
+        if (this.scopes.length === 0 || value < LOG_LEVELS[this.scopeLevel]) {
+            return false;
+        }
+        for (const scope of this.scopes) {
+            if (matchesScope(scope, context)) {
+                return true;
+            }
+        }
+        return false;
+    }
+
+    /**
+     * Applies a change, replacing any earlier one. Without scopes the level
+     * becomes the global level; with scopes, matching requests log at the
+     * level and the global level reverts to its base.
+     */
+    set(change: LoggingChange): LoggingState {
+        const expiresAt = this.now() + (change.ttlMs ?? DEFAULT_LOG_OVERRIDE_TTL_MS);
+
+        if (change.scopes && change.scopes.length > 0) {
+            this.setLevel(this.baseLevel, Infinity);
+            this.scopes = change.scopes.map((s) => ({ ...s }));
+            this.scopeLevel = change.level ?? 'debug';
+            this.scopesExpireAt = expiresAt;
+        } else {
+            const level = change.level ?? this.baseLevel;
+            this.setLevel(level, level === this.baseLevel ? Infinity : expiresAt);
+            this.clearScopes();
+        }
+        this.nextExpiry = Math.min(this.levelExpiresAt, this.scopesExpireAt);
+
+        const state = this.state();
+        this.logger?.info('logging_changed', { audit: true, ...state });
+        return state;
+    }
+
+    /**
+     * Reverts to the base level and drops scoped overrides.
+     */
+    reset(): LoggingState {
+        return this.set({});
+    }
+
+    /**
+     * Returns the current state, after applying any expiry.
+     */
+    state(): LoggingState {
+        if (this.now() >= this.nextExpiry) {
+            this.expire();
+        }
+        return {
+            level: this.level,
+            baseLevel: this.baseLevel,
+            levelExpiresAt: this.levelExpiresAt === Infinity ? undefined : new Date(this.levelExpiresAt).toISOString(),
+            override: this.scopes.length > 0
+                ? {
+                    level: this.scopeLevel,
+                    scopes: this.scopes.map((s) => ({ ...s })),
+                    expiresAt: new Date(this.scopesExpireAt).toISOString(),
+                }
+                : undefined,
+        };
+    }
+
+    private expire(): void {
+        const now = this.now();
+        if (now >= this.levelExpiresAt) {
+            this.logger?.info('logging_level_expired', { audit: true, level: this.level, baseLevel: this.baseLevel });
+            this.setLevel(this.baseLevel, Infinity);
+        }
+        if (now >= this.scopesExpireAt) {
+            this.logger?.info('logging_override_expired', { audit: true, scopes: this.scopes });
+            this.clearScopes();
+        }
+        this.nextExpiry = Math.min(this.levelExpiresAt, this.scopesExpireAt);
+    }
+
+    private setLevel(level: LogLevel, expiresAt: number): void {
+        this.level = level;
+        this.threshold = LOG_LEVELS[level];
+        this.levelExpiresAt = expiresAt;
+    }
+
+    private clearScopes(): void {
+        this.scopes = [];
+        this.scopeLevel = 'debug';
+        this.scopesExpireAt = Infinity;
+    }
+}
+
+// ============================================================================
+// Controlled Logger
+// ============================================================================
+
+/**
+ * Logger that filters entries through a LogControl before passing them to
+ * the wrapped logger, which should accept every level.
+ */
+export class ControlledLogger implements Logger {
+    constructor(
+        private readonly sink: Logger,
+        private readonly control: LogControl,
+        private readonly context: Record<string, unknown> = {},
+    ) { }
+
+    debug(message: string, fields?: Record<string, unknown>): void {
+        if (this.control.enabled('debug', this.context)) this.sink.debug(message, fields);
+    }
+
+    info(message: string, fields?: Record<string, unknown>): void {
+        if (this.control.enabled('info', this.context)) this.sink.info(message, fields);
+    }
+
+    warn(message: string, fields?: Record<string, unknown>): void {
+        if (this.control.enabled('warn', this.context)) this.sink.warn(message, fields);
+    }
+
+    error(message: string, fields?: Record<string, unknown>): void {
+        if (this.control.enabled('error', this.context)) this.sink.error(message, fields);
+    }
+
+    child(fields: Record<string, unknown>): Logger {
+        return new ControlledLogger(this.sink.child(fields), this.control, { ...this.context, ...fields });
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+function matchesScope(scope: LogScope, context: Record<string, unknown>): boolean {
+    return (scope.tenant === undefined || scope.tenant === context.tenantId)
+        && (scope.provider === undefined || scope.provider === context.provider)
+        && (scope.app === undefined || scope.app === context.app);
+}
@@ -0,0 +1,15 @@
+/**
+ * Runtime logging control exports.
+ *
+ * @module logging
+ */
+
+export {
+    LogControl,
+    ControlledLogger,
+    DEFAULT_LOG_OVERRIDE_TTL_MS,
+    type LogScope,
+    type LoggingChange,
+    type LoggingState,
+    type LogControlOptions,
+} from './control.js';
@@ -0,0 +1,113 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openaiCodec } from './codecs/index';
+import { openAIFrontdoor } from './frontdoors/index';
+
+/** A chat completion recorded from OpenAI with logprobs: true, top_logprobs: 2. */
+const recordedResponse = '{"id":"chatcmpl-B9MHDbslfkBeAs8l4bebGdFOJ6PeG","object":"chat.completion","created":1741570283,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!","refusal":null},"logprobs":{"content":[{"token":"Hello","logprob":-0.31725305,"bytes":[72,101,108,108,111],"top_logprobs":[{"token":"Hello","logprob":-0.31725305,"bytes":[72,101,108,108,111]},{"token":"Hi","logprob":-1.3190403,"bytes":[72,105]}]},{"token":"!","logprob":-0.02380986,"bytes":[33],"top_logprobs":[{"token":"!","logprob":-0.02380986,"bytes":[33]},{"token":" there","logprob":-3.787621,"bytes":[32,116,104,101,114,101]}]}],"refusal":null},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11},"system_fingerprint":"fp_6b68a8204b"}';
+
+/** Stream chunks recorded from the same request. */
+const recordedChunks = [
+    '{"id":"chatcmpl-B9MHE","object":"chat.completion.chunk","created":1741570284,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":{"content":[{"token":"Hello","logprob":-0.31725305,"bytes":[72,101,108,108,111],"top_logprobs":[{"token":"Hello","logprob":-0.31725305,"bytes":[72,101,108,108,111]},{"token":"Hi","logprob":-1.3190403,"bytes":[72,105]}]}],"refusal":null},"finish_reason":null}]}',
+    '{"id":"chatcmpl-B9MHE","object":"chat.completion.chunk","created":1741570284,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"content":"!"},"logprobs":{"content":[{"token":"!","logprob":-0.02380986,"bytes":[33],"top_logprobs":[{"token":"!","logprob":-0.02380986,"bytes":[33]},{"token":" there","logprob":-3.787621,"bytes":[32,116,104,101,114,101]}]}],"refusal":null},"finish_reason":null}]}',
+];
+
+/** The logprobs JSON exactly as it appears in a recorded body. */
+function logprobsText(body: string): string {
+    const start = body.indexOf('"logprobs":') + '"logprobs":'.length;
+    return body.slice(start, body.indexOf(',"finish_reason"', start));
+}
+
+const decoder = new TextDecoder();
+
+describe('OpenAI logprobs passthrough', () => {
+    it('should re-encode response logprobs byte-for-byte', () => {
+        const response = openaiCodec.decodeResponse(recordedResponse);
+
+        const encoded = decoder.decode(openaiCodec.encodeResponse(response));
+
+        expect(response.choices[0]!.logprobs?.content).toHaveLength(2);
+        expect(logprobsText(encoded)).toBe(logprobsText(recordedResponse));
+    });
+
+    it('should forward each stream chunk\'s logprobs with that chunk', () => {
+        const encoded = recordedChunks.map((chunk) => {
+            const event = openaiCodec.decodeStreamChunk(chunk)!;
+            return openaiCodec.encodeStreamEvent(event, { id: 'chatcmpl-B9MHE', model: 'gpt-4o-2024-08-06', created: 1741570284 });
+        });
+
+        expect(encoded.map(logprobsText)).toEqual(recordedChunks.map(logprobsText));
+    });
+
+    it('should send logprobs and top_logprobs upstream', () => {
+        const request = openaiCodec.decodeRequest(JSON.stringify({
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'Hi' }],
+            logprobs: true,
+            top_logprobs: 2,
+        }));
+
+        const upstream = JSON.parse(decoder.decode(openaiCodec.encodeRequest(request)));
+
+        expect(upstream).toMatchObject({ logprobs: true, top_logprobs: 2 });
+    });
+});
+
+describe('Logprobs on a provider that cannot produce them', () => {
+    function context(app?: { strictCapabilities?: boolean }) {
+        const provider = {
+            name: 'claude',
+            apiType: 'anthropic',
+            complete: vi.fn(async (req: any) => ({
+                id: 'msg-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'anthropic',
+                choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'Hello!' } }],
+                usage: { promptTokens: 3, completionTokens: 2, totalTokens: 5 },
+            })),
+            stream: vi.fn(),
+        };
+        const ctx = {
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    model: 'claude-sonnet-4',
+                    messages: [{ role: 'user', content: 'Hi' }],
+                    logprobs: true,
+                    top_logprobs: 2,
+                }),
+            }),
+            provider,
+            app: app && { name: 'chat', frontdoor: 'openai', path: '/v1', ...app },
+            auth: { tenantId: 'tenant-a', scopes: ['*'], metadata: {} },
+            interactionId: 'int-1',
+            storage: {},
+        } as any;
+        return { ctx, provider };
+    }
+
+    it('should reject the request by default', async () => {
+        const { ctx, provider } = context();
+
+        const { response } = await openAIFrontdoor.handle(ctx);
+
+        expect(response.status).toBe(400);
+        expect((await response.json()).error).toMatchObject({
+            type: 'invalid_request_error',
+            message: "logprobs not supported by routed provider 'claude'",
+            param: 'logprobs',
+        });
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+
+    it('should drop logprobs and record a warning without strict capabilities', async () => {
+        const { ctx, provider } = context({ strictCapabilities: false });
+
+        const result = await openAIFrontdoor.handle(ctx);
+
+        expect(result.response.status).toBe(200);
+        expect(provider.complete.mock.calls[0]![0]).toMatchObject({ logprobs: undefined, topLogprobs: undefined });
+        expect(result.transformations).toContainEqual(expect.objectContaining({
+            stage: 'capabilities',
+            warnings: ['logprobs not supported by routed provider; omitted from response'],
+        }));
+    });
+});
@@ -0,0 +1,195 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { fitMaxTokens, MAX_TOKENS_SAFETY_MARGIN } from './frontdoors/models';
+import { ModelCatalog } from './domain/catalog';
+
+const openaiBody = {
+    id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'gpt-4o',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'Hi' }, finish_reason: 'stop' }],
+    usage: { prompt_tokens: 1, completion_tokens: 1, total_tokens: 2 },
+};
+
+const anthropicBody = {
+    id: 'msg_1', type: 'message', role: 'assistant', model: 'claude-sonnet-4',
+    content: [{ type: 'text', text: 'Hi' }], stop_reason: 'end_turn', stop_sequence: null,
+    usage: { input_tokens: 1, output_tokens: 1 },
+};
+
+function setup() {
+    const upstream: Array<{ url: string; body: any }> = [];
+    const fetch = vi.fn(async (url: string, init: RequestInit) => {
+        upstream.push({ url, body: JSON.parse(new TextDecoder().decode(init.body as Uint8Array)) });
+        const body = url.includes('anthropic') ? anthropicBody : openaiBody;
+        return new Response(JSON.stringify(body), { status: 200, headers: { 'Content-Type': 'application/json' } });
+    });
+    const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    logger.child.mockReturnValue(logger);
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [
+                    { name: 'chat', frontdoor: 'openai', path: '/v1' },
+                    { name: 'fixed', frontdoor: 'openai', path: '/fixed', maxTokensDefault: { strategy: 'fixed', tokens: 1000 } },
+                    { name: 'strict', frontdoor: 'openai', path: '/strict', maxTokensDefault: { strategy: 'reject' } },
+                    { name: 'claude', frontdoor: 'anthropic', path: '/anthropic' },
+                ],
+                providers: [
+                    { name: 'openai', type: 'openai', apiKey: 'sk-test' },
+                    { name: 'anthropic', type: 'anthropic', apiKey: 'sk-ant', baseUrl: 'https://api.anthropic.com' },
+                ],
+                routing: {
+                    rules: [{ modelPrefix: 'claude', provider: 'anthropic' }],
+                    defaultProvider: 'openai',
+                },
+            }),
+        },
+        auth: { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null },
+        httpClientFactory: () => ({ fetch: fetch as any }),
+        logger: logger as any,
+    });
+    const call = (path: string, body: Record<string, unknown>) => gateway.fetch(new Request(`http://localhost${path}`, {
+        method: 'POST',
+        headers: { Authorization: 'Bearer key', 'Content-Type': 'application/json' },
+        body: JSON.stringify({ messages: [{ role: 'user', content: 'Hi' }], ...body }),
+    }));
+    const recorded = () => logger.info.mock.calls
+        .filter(([message, fields]) => message === 'interaction_transformation' && fields.stage === 'max_tokens')
+        .map(([, fields]) => fields.details);
+    return { call, upstream, recorded };
+}
+
+/** The catalog default for a model's output cap. */
+const modelMax = (cap: number) => Math.floor(cap * (1 - MAX_TOKENS_SAFETY_MARGIN));
+
+describe('max_tokens defaulting', () => {
+    it('should default an omitted max_tokens from the routed model\'s output cap', async () => {
+        const { call, upstream, recorded } = setup();
+
+        const openai = await call('/v1/chat/completions', { model: 'gpt-4o' });
+        const anthropic = await call('/v1/chat/completions', { model: 'claude-sonnet-4' });
+
+        expect(openai.status).toBe(200);
+        expect(anthropic.status).toBe(200);
+        expect(upstream[0]!.body.max_completion_tokens).toBe(modelMax(16384));
+        expect(upstream[1]!.body.max_tokens).toBe(modelMax(64000));
+        expect(openai.headers.has('x-gateway-max-tokens-clamped')).toBe(false);
+        expect(recorded()).toEqual([
+            { original: null, effective: modelMax(16384), clamped: false },
+            { original: null, effective: modelMax(64000), clamped: false },
+        ]);
+    });
+
+    it('should leave max_tokens unset for models the catalog does not know', async () => {
+        const { call, upstream, recorded } = setup();
+
+        await call('/v1/chat/completions', { model: 'gpt-next' });
+        await call('/v1/chat/completions', { model: 'claude-next' });
+
+        expect(upstream[0]!.body).not.toHaveProperty('max_completion_tokens');
+        expect(upstream[1]!.body.max_tokens).toBe(4096);
+        expect(recorded()).toEqual([]);
+    });
+
+    it('should apply a fixed default, within the model\'s cap', async () => {
+        const { call, upstream } = setup();
+
+        await call('/fixed/chat/completions', { model: 'gpt-4o' });
+        await call('/fixed/chat/completions', { model: 'claude-3-haiku' });
+
+        expect(upstream[0]!.body.max_completion_tokens).toBe(1000);
+        expect(upstream[1]!.body.max_tokens).toBe(1000);
+        expect(fitMaxTokens({ model: 'gpt-3.5-turbo' } as any, new ModelCatalog(), {
+            name: 'a', frontdoor: 'openai', path: '/', maxTokensDefault: { strategy: 'fixed', tokens: 10000 },
+        })).toMatchObject({ details: { effective: 4096 } });
+    });
+
+    it('should reject an omitted max_tokens when the app requires it', async () => {
+        const { call, upstream } = setup();
+
+        const missing = await call('/strict/chat/completions', { model: 'claude-sonnet-4' });
+        const sent = await call('/strict/chat/completions', { model: 'claude-sonnet-4', max_tokens: 100 });
+
+        expect(missing.status).toBe(400);
+        expect((await missing.json()).error).toMatchObject({
+            message: 'max_tokens: is required for this app',
+            param: 'max_tokens',
+        });
+        expect(sent.status).toBe(200);
+        expect(upstream).toHaveLength(1);
+        expect(upstream[0]!.body.max_tokens).toBe(100);
+    });
+});
+
+describe('max_tokens clamping', () => {
+    it('should clamp a value over the model\'s cap instead of letting the provider reject it', async () => {
+        const { call, upstream, recorded } = setup();
+
+        const openai = await call('/v1/chat/completions', { model: 'gpt-4o', max_tokens: 100000 });
+        const anthropic = await call('/anthropic/v1/messages', { model: 'claude-3-5-haiku', max_tokens: 100000 });
+
+        expect(openai.status).toBe(200);
+        expect(openai.headers.get('x-gateway-max-tokens-clamped')).toBe('16384');
+        expect(upstream[0]!.body.max_completion_tokens).toBe(16384);
+        expect(anthropic.status).toBe(200);
+        expect(anthropic.headers.get('x-gateway-max-tokens-clamped')).toBe('8192');
+        expect(upstream[1]!.body.max_tokens).toBe(8192);
+        expect(recorded()).toEqual([
+            { original: 100000, effective: 16384, clamped: true },
+            { original: 100000, effective: 8192, clamped: true },
+        ]);
+    });
+
+    it('should pass values within the cap through untouched', async () => {
+        const { call, upstream, recorded } = setup();
+
+        const response = await call('/anthropic/v1/messages', { model: 'claude-3-5-haiku', max_tokens: 8192 });
+
+        expect(response.headers.has('x-gateway-max-tokens-clamped')).toBe(false);
+        expect(upstream[0]!.body.max_tokens).toBe(8192);
+        expect(recorded()).toEqual([]);
+    });
+});
+
+describe('max_tokens with a thinking budget', () => {
+    const thinking = (budget: number) => ({ type: 'enabled', budget_tokens: budget });
+
+    it('should raise max_tokens by the budget, within the model\'s cap, and say so', async () => {
+        const { call, upstream, recorded } = setup();
+
+        const raised = await call('/anthropic/v1/messages', { model: 'claude-sonnet-4', max_tokens: 1000, thinking: thinking(4096) });
+        const capped = await call('/anthropic/v1/messages', { model: 'claude-3-5-haiku', max_tokens: 6000, thinking: thinking(7000) });
+
+        expect(raised.status).toBe(200);
+        expect(upstream[0]!.body).toMatchObject({ max_tokens: 5096, thinking: thinking(4096) });
+        expect(raised.headers.get('x-gateway-max-tokens-clamped')).toBe('5096');
+        expect(raised.headers.get('x-gateway-warnings')).toContain('max_tokens 1000 does not exceed thinking budget_tokens 4096');
+        expect(capped.status).toBe(200);
+        expect(upstream[1]!.body.max_tokens).toBe(8192);
+        expect(capped.headers.get('x-gateway-max-tokens-clamped')).toBe('8192');
+        expect(recorded()).toEqual([
+            { original: 1000, effective: 5096, clamped: true },
+            { original: 6000, effective: 8192, clamped: true },
+        ]);
+    });
+
+    it('should reject a budget the model\'s cap cannot fit', async () => {
+        const { call, upstream } = setup();
+
+        const response = await call('/anthropic/v1/messages', { model: 'claude-3-5-haiku', max_tokens: 1000, thinking: thinking(10000) });
+
+        expect(response.status).toBe(400);
+        expect(JSON.stringify(await response.json())).toContain(
+            "thinking.budget_tokens: must be less than the 8192 output token limit of 'claude-3-5-haiku'",
+        );
+        expect(upstream).toHaveLength(0);
+    });
+
+    it('should leave a max_tokens above the budget alone', async () => {
+        const { call, upstream, recorded } = setup();
+
+        await call('/anthropic/v1/messages', { model: 'claude-sonnet-4', max_tokens: 16000, thinking: thinking(4096) });
+
+        expect(upstream[0]!.body.max_tokens).toBe(16000);
+        expect(recorded()).toEqual([]);
+    });
+});
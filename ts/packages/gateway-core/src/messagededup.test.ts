@@ -0,0 +1,132 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { findDuplicateMessage, messageContentHash, MessageDedupCounter } from './messagededup/index';
+import type { StoredMessage } from './ports/storage';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const at = (seconds: number) => new Date(Date.UTC(2024, 0, 1, 0, 0, seconds));
+
+async function message(id: string, role: string, content: string, seconds: number): Promise<StoredMessage> {
+    return { id, role, content, timestamp: at(seconds), contentHash: await messageContentHash(role, content) };
+}
+
+describe('findDuplicateMessage', () => {
+    it('should match by ID whatever the window', async () => {
+        const messages = [await message('m1', 'user', 'hi', 0), await message('m2', 'assistant', 'Hello', 1)];
+
+        const found = findDuplicateMessage(messages, await message('m1', 'user', 'other', 500));
+
+        expect(found).toEqual({ message: messages[0], reason: 'id' });
+    });
+
+    it.each([
+        ['within the last messages', { messages: 2 }, 500, true],
+        ['within the age', { seconds: 60 }, 30, true],
+        ['outside both', { messages: 1, seconds: 60 }, 500, false],
+        ['without a window', undefined, 2, false],
+    ])('should match identical content %s', async (_name, window, seconds, expected) => {
+        const messages = [
+            await message('m1', 'user', 'hi', 0),
+            await message('m2', 'assistant', 'Hello', 1),
+            await message('m3', 'user', 'again', 2),
+        ];
+
+        const found = findDuplicateMessage(messages, await message('m4', 'assistant', 'Hello', seconds), window);
+
+        expect(found).toEqual(expected ? { message: messages[1], reason: 'content' } : undefined);
+    });
+
+    it('should not match the same content in another role', async () => {
+        const messages = [await message('m1', 'user', 'Hello', 0)];
+
+        expect(findDuplicateMessage(messages, await message('m2', 'assistant', 'Hello', 1), { messages: 2 })).toBeUndefined();
+    });
+});
+
+describe('MessageDedupCounter', () => {
+    it('should leave dedup off for apps without config and count per app', () => {
+        const counter = new MessageDedupCounter();
+
+        expect(counter.forApp('a', undefined).window).toBeUndefined();
+        expect(counter.forApp('a', { enabled: false }).window).toBeUndefined();
+        expect(counter.forApp('b', {}).window).toEqual({ messages: 2, seconds: 60 });
+
+        counter.forApp('b', {}).onDuplicate('content');
+        counter.record('a', 'id');
+        counter.record('b', 'id');
+
+        expect(counter.stats()).toEqual([
+            { app: 'a', byId: 1, byContent: 0 },
+            { app: 'b', byId: 1, byContent: 1 },
+        ]);
+    });
+});
+
+describe('Thread message dedup', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(messageDedup = {}) {
+        gateway = await harness()
+            .app({ name: 'agents', frontdoor: 'responses', path: '/v1', messageDedup })
+            .provider(new ScriptedProvider('mock', { text: 'Hello there' }))
+            .start();
+        const gw = gateway;
+        const { id } = await (await gw.post('/v1/threads', {})).json();
+        const list = async () => (await (await gw.request(`/v1/threads/${id}/messages`)).json()).data as any[];
+        return { gw, id: id as string, list };
+    }
+
+    it('should append a retried run\'s reply once', async () => {
+        const { gw, id, list } = await setup();
+        await gw.post(`/v1/threads/${id}/messages`, { content: 'Hi' });
+
+        const first = await gw.post(`/v1/threads/${id}/runs`, {});
+        const retry = await gw.post(`/v1/threads/${id}/runs`, {});
+
+        expect(first.status).toBe(200);
+        expect(retry.status).toBe(200);
+        expect((await list()).map((m) => [m.role, m.content[0].text.value])).toEqual([
+            ['user', 'Hi'],
+            ['assistant', 'Hello there'],
+        ]);
+        expect(gw.gateway.messageDedupStats()).toEqual([{ app: 'agents', byId: 0, byContent: 1 }]);
+    });
+
+    it('should append identical replies for apps without dedup', async () => {
+        const { gw, id, list } = await setup({ enabled: false });
+        await gw.post(`/v1/threads/${id}/messages`, { content: 'Hi' });
+
+        await gw.post(`/v1/threads/${id}/runs`, {});
+        await gw.post(`/v1/threads/${id}/runs`, {});
+
+        expect((await list()).filter((m) => m.role === 'assistant')).toHaveLength(2);
+        expect(gw.gateway.messageDedupStats()).toEqual([]);
+    });
+
+    it('should make retries with a client message ID no-ops', async () => {
+        const { gw, id, list } = await setup({ enabled: false });
+
+        const first = await (await gw.post(`/v1/threads/${id}/messages`, { id: 'client-1', content: 'Hi' })).json();
+        const retry = await (await gw.post(`/v1/threads/${id}/messages`, { id: 'client-1', content: 'Hi' })).json();
+        await gw.post(`/v1/threads/${id}/runs`, { message_id: 'reply-1' });
+        await gw.post(`/v1/threads/${id}/runs`, { message_id: 'reply-1' });
+
+        expect(first.id).toBe('client-1');
+        expect(retry.id).toBe('client-1');
+        expect((await list()).map((m) => m.id)).toEqual(['client-1', 'reply-1']);
+        expect(gw.gateway.messageDedupStats()).toEqual([{ app: 'agents', byId: 3, byContent: 0 }]);
+    });
+
+    it('should reject malformed message IDs', async () => {
+        const { gw, id } = await setup();
+
+        const response = await gw.post(`/v1/threads/${id}/messages`, { id: 'has spaces', content: 'Hi' });
+
+        expect(response.status).toBe(400);
+        expect((await response.json()).error.message).toContain('id must be');
+    });
+});
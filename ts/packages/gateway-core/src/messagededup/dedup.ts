@@ -0,0 +1,178 @@
+/**
+ * Idempotent message appends for threads.
+ *
+ * A client that retries POST /v1/threads/:id/runs after a timeout gets the
+ * run's assistant reply appended twice, and the copy is sent back as
+ * context on every later run. Appends are made idempotent two ways: a
+ * message whose client-supplied ID the thread already has isn't appended
+ * again, and for apps with message dedup on, neither is an assistant
+ * message whose role and content match one in the thread's recent window.
+ * Both return the existing message's ID. Stores compare content hashes,
+ * which SQL stores index.
+ *
+ * @module messagededup/dedup
+ */
+
+import type { MessageDedupConfig } from '../ports/config.js';
+import type { DuplicateMessageReason, MessageDedupWindow, StoredMessage } from '../ports/storage.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import { sha256 } from '../utils/crypto.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default number of recent messages an assistant message is checked against. */
+export const DEFAULT_DEDUP_WINDOW_MESSAGES = 2;
+
+/** Default age of messages an assistant message is checked against, in seconds. */
+export const DEFAULT_DEDUP_WINDOW_SECONDS = 60;
+
+/** Client-supplied message IDs: letters, digits, `_` and `-`. */
+const MESSAGE_ID_PATTERN = /^[A-Za-z0-9_-]{1,128}$/;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Appends skipped as duplicates for one app.
+ */
+export interface MessageDedupStats {
+    /** App name. */
+    app: string;
+
+    /** Messages whose client-supplied ID the thread already had. */
+    byId: number;
+
+    /** Assistant messages that repeated one in the window. */
+    byContent: number;
+}
+
+/**
+ * An app's duplicate checks, for its Responses API handler.
+ */
+export interface AppMessageDedup {
+    /** Window assistant messages are checked in; unset when the app has dedup off. */
+    window?: MessageDedupWindow | undefined;
+
+    /** Counts an append skipped as a duplicate. */
+    onDuplicate(reason: DuplicateMessageReason): void;
+}
+
+// ============================================================================
+// Checks
+// ============================================================================
+
+/**
+ * Hashes a message's role and content, as stores compare them.
+ */
+export function messageContentHash(role: string, content: string): Promise<string> {
+    return sha256(`${role}\n${content}`);
+}
+
+/**
+ * Returns the window an app's config asks for, or undefined when dedup is off.
+ */
+export function dedupWindow(config: MessageDedupConfig | undefined): MessageDedupWindow | undefined {
+    if (!config || config.enabled === false) {
+        return undefined;
+    }
+    return {
+        messages: config.windowMessages ?? DEFAULT_DEDUP_WINDOW_MESSAGES,
+        seconds: config.windowSeconds ?? DEFAULT_DEDUP_WINDOW_SECONDS,
+    };
+}
+
+/**
+ * Checks a client-supplied message ID. Returns undefined when none was
+ * given; throws a 400 when it is malformed.
+ */
+export function parseMessageId(id: unknown, field: string): string | undefined {
+    if (id === undefined || id === null) {
+        return undefined;
+    }
+    if (typeof id !== 'string' || !MESSAGE_ID_PATTERN.test(id)) {
+        throw errInvalidRequest(`${field} must be 1 to 128 letters, digits, '_' or '-'`);
+    }
+    return id;
+}
+
+/**
+ * Finds the message an append duplicates among a thread's messages,
+ * oldest first: one with its ID, or else one with its content hash among
+ * the window's most recent messages or within its age. For stores that
+ * keep a thread's messages in memory.
+ */
+export function findDuplicateMessage(
+    messages: readonly StoredMessage[],
+    message: StoredMessage,
+    window?: MessageDedupWindow,
+): { message: StoredMessage; reason: DuplicateMessageReason } | undefined {
+    const sameId = messages.find((m) => m.id === message.id);
+    if (sameId) {
+        return { message: sameId, reason: 'id' };
+    }
+    if (!window || !message.contentHash) {
+        return undefined;
+    }
+
+    const recent = messages.length - (window.messages ?? 0);
+    const since = window.seconds === undefined ? Infinity : message.timestamp.getTime() - window.seconds * 1000;
+    for (let i = messages.length - 1; i >= 0; i--) {
+        const m = messages[i]!;
+        // Messages are in append order, so everything before is outside the window too
+        if (i < recent && m.timestamp.getTime() < since) {
+            break;
+        }
+        if (m.contentHash === message.contentHash) {
+            return { message: m, reason: 'content' };
+        }
+    }
+    return undefined;
+}
+
+// ============================================================================
+// Counters
+// ============================================================================
+
+/**
+ * Counts appends skipped as duplicates, per app.
+ */
+export class MessageDedupCounter {
+    private readonly counts = new Map<string, { byId: number; byContent: number }>();
+
+    /**
+     * Returns an app's checks: its configured window, counted here.
+     */
+    forApp(app: string, config: MessageDedupConfig | undefined): AppMessageDedup {
+        return {
+            window: dedupWindow(config),
+            onDuplicate: (reason) => this.record(app, reason),
+        };
+    }
+
+    /**
+     * Counts a skipped append.
+     */
+    record(app: string, reason: DuplicateMessageReason): void {
+        let count = this.counts.get(app);
+        if (!count) {
+            count = { byId: 0, byContent: 0 };
+            this.counts.set(app, count);
+        }
+        if (reason === 'id') {
+            count.byId++;
+        } else {
+            count.byContent++;
+        }
+    }
+
+    /**
+     * Returns the counts, by app name.
+     */
+    stats(): MessageDedupStats[] {
+        return Array.from(this.counts, ([app, { byId, byContent }]) => ({ app, byId, byContent }))
+            .sort((a, b) => a.app.localeCompare(b.app));
+    }
+}
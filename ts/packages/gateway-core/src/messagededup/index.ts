@@ -0,0 +1,17 @@
+/**
+ * Message dedup exports.
+ *
+ * @module messagededup
+ */
+
+export {
+    MessageDedupCounter,
+    messageContentHash,
+    dedupWindow,
+    parseMessageId,
+    findDuplicateMessage,
+    DEFAULT_DEDUP_WINDOW_MESSAGES,
+    DEFAULT_DEDUP_WINDOW_SECONDS,
+    type MessageDedupStats,
+    type AppMessageDedup,
+} from './dedup.js';
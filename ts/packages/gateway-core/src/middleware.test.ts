@@ -6,6 +6,8 @@ import {
     modifyResult,
     denyResult,
     respondResult,
+    routeResult,
+    createWebhookStep,
 } from './middleware/index';
 import type { PipelineContext, StageConfig, StepResult } from './middleware/types';
 import type { CanonicalRequest, CanonicalResponse } from './domain/types';
@@ -192,6 +194,311 @@ describe('PipelineExecutor', () => {
             expect(result.continue).toBe(true);
         });
     });
+
+    describe('route action', () => {
+        const logger = () => ({ debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn() });
+        const known = new Set(['openai', 'anthropic']);
+
+        it('should apply a valid route and record it', async () => {
+            executor = new PipelineExecutor({ hasProvider: (name) => known.has(name) });
+            executor.addPreStage({
+                name: 'entitlement',
+                type: 'pre',
+                step: async () => routeResult({ provider: 'anthropic', model: 'claude-sonnet-4' }),
+            });
+
+            const result = await executor.runPre(ctx);
+
+            expect(result.continue).toBe(true);
+            expect(result.route).toEqual({ stage: 'entitlement', provider: 'anthropic', model: 'claude-sonnet-4' });
+            expect(result.request?.model).toBe('claude-sonnet-4');
+            expect(result.transformations).toHaveLength(1);
+            expect(result.transformations![0]).toMatchObject({
+                stage: 'entitlement',
+                details: { provider: 'anthropic', model: 'claude-sonnet-4' },
+            });
+        });
+
+        it('should fail closed on an unknown provider', async () => {
+            executor = new PipelineExecutor({ hasProvider: (name) => known.has(name) });
+            const next = vi.fn().mockResolvedValue(continueResult());
+            executor.addPreStage({
+                name: 'entitlement',
+                type: 'pre',
+                step: async () => routeResult({ provider: 'mystery' }),
+                order: 1,
+            });
+            executor.addPreStage({ name: 'after', type: 'pre', step: next, order: 2 });
+
+            const result = await executor.runPre(ctx);
+
+            expect(result.continue).toBe(false);
+            expect(result.denyReason).toContain("unknown provider 'mystery'");
+            expect(next).not.toHaveBeenCalled();
+        });
+
+        it('should ignore an unknown provider when the stage fails open', async () => {
+            executor = new PipelineExecutor({ hasProvider: (name) => known.has(name) });
+            executor.addPreStage({
+                name: 'entitlement',
+                type: 'pre',
+                onError: 'allow',
+                step: async () => routeResult({ provider: 'mystery' }),
+            });
+
+            const result = await executor.runPre(ctx);
+
+            expect(result.continue).toBe(true);
+            expect(result.route).toBeUndefined();
+        });
+
+        it('should resolve conflicting routes last-writer-wins with a warning', async () => {
+            const log = logger();
+            executor = new PipelineExecutor({ logger: log, hasProvider: (name) => known.has(name) });
+            executor.addPreStage({
+                name: 'first',
+                type: 'pre',
+                step: async () => routeResult({ provider: 'openai', model: 'gpt-4o' }),
+                order: 1,
+            });
+            executor.addPreStage({
+                name: 'second',
+                type: 'pre',
+                step: async () => routeResult({ provider: 'anthropic' }),
+                order: 2,
+            });
+
+            const result = await executor.runPre(ctx);
+
+            expect(result.route).toEqual({ stage: 'second', provider: 'anthropic', model: 'gpt-4o' });
+            expect(result.transformations?.map((t) => t.stage)).toEqual(['first', 'second']);
+            expect(log.warn).toHaveBeenCalledWith('pipeline_route_overridden', {
+                stage: 'second',
+                previousStage: 'first',
+            });
+        });
+    });
+});
+
+describe('mutation validation', () => {
+    const request: CanonicalRequest = {
+        tenantId: 't',
+        model: 'gpt-4',
+        messages: [
+            { role: 'user', content: 'Weather?' },
+            {
+                role: 'assistant',
+                content: '',
+                toolCalls: [{ id: 'call_1', type: 'function', function: { name: 'weather', arguments: '{}' } }],
+            },
+            { role: 'tool', content: 'Sunny', toolCallId: 'call_1' },
+        ],
+        stream: false,
+        sourceAPIType: 'openai',
+    };
+
+    const response: CanonicalResponse = {
+        id: 'resp-1',
+        object: 'chat.completion',
+        created: 1700000000,
+        model: 'gpt-4',
+        choices: [{ index: 0, message: { role: 'assistant', content: 'Sunny' }, finishReason: 'stop' }],
+        usage: { promptTokens: 5, completionTokens: 1, totalTokens: 6 },
+        sourceAPIType: 'openai',
+    };
+
+    /** A webhook stage that always answers with the given body. */
+    function webhookStage(body: unknown, options: Partial<StageConfig> = {}): StageConfig {
+        const fetch = vi.fn(async () => new Response(JSON.stringify(body)));
+        return {
+            name: 'hook',
+            type: 'pre',
+            step: createWebhookStep({ type: 'webhook', url: 'http://hook.test', fetch: fetch as any }),
+            ...options,
+        };
+    }
+
+    function context(): PipelineContext {
+        return { request, response, tenantId: 't', interactionId: 'int-1', metadata: new Map() };
+    }
+
+    it.each([
+        ['messages as a string', { request: { messages: 'Hello' } }, 'request.messages: expected array, got string'],
+        ['an unknown field', { request: { prompt: 'Hello' } }, 'request.prompt: unknown field'],
+        ['an invalid role', { request: { messages: [{ role: 'robot', content: 'Hi' }] } }, 'request.messages[0].role'],
+        ['a wrongly typed field', { request: { maxTokens: '100' } }, 'request.maxTokens: expected integer'],
+        ['empty messages', { request: { messages: [] } }, 'request.messages: must not be empty'],
+        [
+            'a dangling tool result',
+            { request: { messages: [request.messages[0], request.messages[2]] } },
+            "request.messages[1].toolCallId: no tool call 'call_1'",
+        ],
+        [
+            'a tool call stripped of its result',
+            { request: { messages: request.messages.slice(0, 2) } },
+            "result of tool call 'call_1' was removed",
+        ],
+        ['choices as an object', { response: { choices: { text: 'Hi' } } }, 'response.choices: expected array'],
+    ])('should reject %s', async (_, mutation, error) => {
+        const executor = new PipelineExecutor();
+        executor.addPreStage(webhookStage({ action: 'modify', ...mutation }));
+
+        const result = await executor.runPre(context());
+
+        expect(result.continue).toBe(false);
+        expect(result.denyStatusCode).toBe(500);
+        expect(result.denyReason).toContain(error);
+    });
+
+    it('should accept a valid mutation', async () => {
+        const executor = new PipelineExecutor();
+        executor.addPreStage(webhookStage({
+            action: 'modify',
+            request: { model: 'gpt-4o', messages: request.messages.slice(0, 1), temperature: 0.2 },
+        }));
+
+        const result = await executor.runPre(context());
+
+        expect(result.continue).toBe(true);
+        expect(result.request).toMatchObject({ model: 'gpt-4o', temperature: 0.2 });
+        expect(result.request?.messages).toHaveLength(1);
+    });
+
+    it('should keep the original and record the rejection when failing open', async () => {
+        const events = { saveEvent: vi.fn().mockResolvedValue(undefined) };
+        const executor = new PipelineExecutor({ events });
+        executor.addPostStage(webhookStage(
+            { action: 'modify', request: { messages: 'Hello' }, response: { usage: null } },
+            { onError: 'allow' },
+        ));
+
+        const result = await executor.runPost(context());
+
+        expect(result.continue).toBe(true);
+        expect(result.request).toBe(request);
+        expect(result.response).toBe(response);
+        expect(events.saveEvent).toHaveBeenCalledWith(expect.objectContaining({
+            type: 'pipeline_post',
+            interactionId: 'int-1',
+            payload: {
+                stage: 'hook',
+                action: 'mutation_rejected',
+                errors: [
+                    'request.messages: expected array, got string',
+                    'response.usage: expected object, got null',
+                ],
+            },
+        }));
+    });
+});
+
+describe('stage result caching', () => {
+    function context(overrides: Partial<CanonicalRequest> = {}, interactionId = 'int-1'): PipelineContext {
+        return {
+            request: {
+                tenantId: 't',
+                model: 'gpt-4o',
+                messages: [{ role: 'user', content: 'Is this allowed?' }],
+                stream: false,
+                sourceAPIType: 'openai',
+                ...overrides,
+            },
+            tenantId: 't',
+            interactionId,
+            metadata: new Map(),
+        };
+    }
+
+    function policyStage(body: unknown, cache: StageConfig['cache'] = { ttlMs: 60_000 }) {
+        const fetch = vi.fn(async () => new Response(JSON.stringify(body)));
+        const stage: StageConfig = {
+            name: 'policy',
+            type: 'pre',
+            step: createWebhookStep({ type: 'webhook', url: 'http://policy.test', fetch: fetch as any }),
+            cache,
+        };
+        return { fetch, stage };
+    }
+
+    it('should hit for identical prompts and miss for a changed one', async () => {
+        const events = { saveEvent: vi.fn().mockResolvedValue(undefined) };
+        const executor = new PipelineExecutor({ events });
+        const { fetch, stage } = policyStage({ action: 'allow' });
+        executor.addPreStage(stage);
+
+        await executor.runPre(context({ metadata: { request_id: 'a' } }, 'int-1'));
+        await executor.runPre(context({ metadata: { request_id: 'b' }, temperature: 0.5 }, 'int-2'));
+        expect(fetch).toHaveBeenCalledTimes(1);
+
+        await executor.runPre(context({ messages: [{ role: 'user', content: 'Something else' }] }, 'int-3'));
+        expect(fetch).toHaveBeenCalledTimes(2);
+
+        expect(events.saveEvent.mock.calls.map(([e]) => [e.interactionId, e.payload.cached])).toEqual([
+            ['int-1', false],
+            ['int-2', true],
+            ['int-3', false],
+        ]);
+    });
+
+    it('should key on the configured fields only', async () => {
+        const executor = new PipelineExecutor();
+        const { fetch, stage } = policyStage({ action: 'allow' }, { ttlMs: 60_000, key: ['tenant', 'messages_hash'] });
+        executor.addPreStage(stage);
+
+        await executor.runPre(context({ model: 'gpt-4o' }));
+        await executor.runPre(context({ model: 'claude-sonnet-4' }));
+        expect(fetch).toHaveBeenCalledTimes(1);
+
+        await executor.runPre(context({ systemPrompt: 'Be terse.' }));
+        expect(fetch).toHaveBeenCalledTimes(2);
+    });
+
+    it('should replay mutations onto the request at hand', async () => {
+        const executor = new PipelineExecutor();
+        const { fetch, stage } = policyStage({ action: 'modify', request: { systemPrompt: 'Follow policy 7.' } });
+        executor.addPreStage(stage);
+
+        await executor.runPre(context({ maxTokens: 10 }));
+        const replayed = await executor.runPre(context({ maxTokens: 99 }));
+
+        expect(fetch).toHaveBeenCalledTimes(1);
+        expect(replayed.request).toMatchObject({ systemPrompt: 'Follow policy 7.', maxTokens: 99 });
+    });
+
+    it('should only cache denials when asked to', async () => {
+        const plain = new PipelineExecutor();
+        const uncached = policyStage({ action: 'deny', reason: 'no' });
+        plain.addPreStage(uncached.stage);
+        await plain.runPre(context());
+        expect((await plain.runPre(context())).denyStatusCode).toBe(403);
+        expect(uncached.fetch).toHaveBeenCalledTimes(2);
+
+        const opted = new PipelineExecutor();
+        const cached = policyStage({ action: 'deny', reason: 'no' }, { ttlMs: 60_000, cacheDenies: true });
+        opted.addPreStage(cached.stage);
+        await opted.runPre(context());
+        const result = await opted.runPre(context());
+        expect(result).toMatchObject({ continue: false, denyReason: 'no', denyStatusCode: 403 });
+        expect(cached.fetch).toHaveBeenCalledTimes(1);
+    });
+
+    it('should not cache failures and should expire entries', async () => {
+        let now = 0;
+        const executor = new PipelineExecutor({ now: () => now });
+        const step = vi.fn()
+            .mockRejectedValueOnce(new Error('webhook down'))
+            .mockResolvedValue(continueResult());
+        executor.addPreStage({ name: 'policy', type: 'pre', step, onError: 'allow', cache: { ttlMs: 1000 } });
+
+        await executor.runPre(context());
+        await executor.runPre(context());
+        await executor.runPre(context());
+        expect(step).toHaveBeenCalledTimes(2);
+
+        now = 1000;
+        await executor.runPre(context());
+        expect(step).toHaveBeenCalledTimes(3);
+    });
 });
 
 describe('createExecutor', () => {
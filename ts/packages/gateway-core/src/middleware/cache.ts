@@ -0,0 +1,198 @@
+/**
+ * Result caching for deterministic pipeline stages.
+ *
+ * A stage whose decision depends only on a few request fields (e.g. a
+ * policy webhook over tenant, model, and prompt) can have its results
+ * cached under a key built from just those fields, so volatile ones such
+ * as the interaction ID or timestamps never affect a hit. Allow decisions
+ * and mutations are cached; denials only when the stage opts in. There is
+ * no shared response cache to reuse, so each stage keeps a small LRU.
+ *
+ * @module middleware/cache
+ */
+
+import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import { sha256 } from '../utils/crypto.js';
+import type { PipelineContext, StepResult } from './types.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default time a stage result stays cached. */
+export const DEFAULT_STAGE_CACHE_TTL_MS = 5 * 60 * 1000;
+
+/** Default entries kept per stage. */
+export const DEFAULT_STAGE_CACHE_ENTRIES = 1000;
+
+/** Default key: the fields a policy decision usually depends on. */
+export const DEFAULT_STAGE_CACHE_KEY: StageCacheKeyField[] = ['tenant', 'model', 'messages_hash'];
+
+/**
+ * Request fields a stage cache key can be built from. messages_hash
+ * covers the messages and system prompt; tools_hash the tool definitions.
+ */
+export type StageCacheKeyField = 'tenant' | 'app' | 'model' | 'messages_hash' | 'tools_hash';
+
+/** Every StageCacheKeyField, for config validation. */
+export const STAGE_CACHE_KEY_FIELDS: readonly StageCacheKeyField[] = ['tenant', 'app', 'model', 'messages_hash', 'tools_hash'];
+
+/**
+ * A stage's caching policy.
+ */
+export interface StageCachePolicy {
+    /** How long a result stays cached, in milliseconds. */
+    ttlMs: number;
+
+    /** Fields the key is built from (default DEFAULT_STAGE_CACHE_KEY). */
+    key?: StageCacheKeyField[] | undefined;
+
+    /** Cache deny decisions too. */
+    cacheDenies?: boolean | undefined;
+
+    /** Entries kept before the least recently used is evicted. */
+    maxEntries?: number | undefined;
+}
+
+/**
+ * A cached decision. Mutations are stored as the top-level fields the
+ * stage changed, so a hit keeps the fields outside the key (temperature,
+ * max tokens, ...) from the request at hand.
+ */
+export type CachedStageResult =
+    | { action: 'continue' }
+    | {
+        action: 'modify';
+        request?: Partial<CanonicalRequest> | undefined;
+        response?: Partial<CanonicalResponse> | undefined;
+    }
+    | { action: 'deny'; reason: string; statusCode?: number | undefined };
+
+// ============================================================================
+// Stage Cache
+// ============================================================================
+
+/**
+ * One stage's result cache.
+ */
+export class StageCache {
+    private readonly entries = new Map<string, { result: CachedStageResult; expiresAt: number }>();
+    private readonly fields: StageCacheKeyField[];
+    private readonly maxEntries: number;
+
+    constructor(
+        private readonly policy: StageCachePolicy,
+        private readonly now: () => number = Date.now,
+    ) {
+        this.fields = policy.key?.length ? policy.key : DEFAULT_STAGE_CACHE_KEY;
+        this.maxEntries = policy.maxEntries ?? DEFAULT_STAGE_CACHE_ENTRIES;
+    }
+
+    /**
+     * Builds the cache key for a request from the policy's fields only.
+     */
+    async key(ctx: PipelineContext): Promise<string> {
+        const parts = await Promise.all(this.fields.map(async (field) => {
+            switch (field) {
+                case 'tenant':
+                    return ctx.tenantId;
+                case 'app':
+                    return ctx.appName ?? '';
+                case 'model':
+                    return ctx.request.model;
+                case 'messages_hash':
+                    return sha256(JSON.stringify([ctx.request.systemPrompt ?? null, ctx.request.messages]));
+                case 'tools_hash':
+                    return sha256(JSON.stringify(ctx.request.tools ?? []));
+            }
+        }));
+        return JSON.stringify(parts);
+    }
+
+    /**
+     * Replays a cached result against the current context, or returns
+     * undefined on a miss.
+     */
+    get(key: string, ctx: PipelineContext): StepResult | undefined {
+        const entry = this.entries.get(key);
+        if (!entry) {
+            return undefined;
+        }
+        if (entry.expiresAt <= this.now()) {
+            this.entries.delete(key);
+            return undefined;
+        }
+
+        // Re-insert to mark as most recently used
+        this.entries.delete(key);
+        this.entries.set(key, entry);
+
+        const { result } = entry;
+        if (result.action !== 'modify') {
+            return result.action === 'deny' ? { ...result, statusCode: result.statusCode ?? 403 } : result;
+        }
+        return {
+            action: 'modify',
+            ...(result.request && { request: { ...ctx.request, ...result.request } }),
+            ...(result.response && ctx.response && { response: { ...ctx.response, ...result.response } }),
+        };
+    }
+
+    /**
+     * Caches a stage result if the policy allows it. Returns whether it
+     * was cached.
+     */
+    set(key: string, ctx: PipelineContext, result: StepResult): boolean {
+        let cached: CachedStageResult;
+        switch (result.action) {
+            case 'continue':
+                cached = result;
+                break;
+            case 'modify':
+                cached = {
+                    action: 'modify',
+                    request: result.request && changedFields(ctx.request, result.request),
+                    response: result.response && ctx.response && changedFields(ctx.response, result.response),
+                };
+                break;
+            case 'deny':
+                if (!this.policy.cacheDenies) {
+                    return false;
+                }
+                cached = result;
+                break;
+            default:
+                return false;
+        }
+
+        this.entries.delete(key);
+        this.entries.set(key, { result: cached, expiresAt: this.now() + this.policy.ttlMs });
+        if (this.entries.size > this.maxEntries) {
+            const oldest = this.entries.keys().next().value;
+            if (oldest !== undefined) {
+                this.entries.delete(oldest);
+            }
+        }
+        return true;
+    }
+
+    /**
+     * Number of cached entries (expired ones included until next read).
+     */
+    get size(): number {
+        return this.entries.size;
+    }
+}
+
+/**
+ * Top-level fields of `after` that differ from `before`.
+ */
+function changedFields<T extends object>(before: T, after: T): Partial<T> {
+    const changed: Partial<T> = {};
+    for (const field of Object.keys(after) as (keyof T)[]) {
+        if (JSON.stringify(after[field]) !== JSON.stringify(before[field])) {
+            changed[field] = after[field];
+        }
+    }
+    return changed;
+}
@@ -0,0 +1,260 @@
+/**
+ * Pipeline stage conditions.
+ *
+ * A stage with a `when` clause runs only for requests that match it, so a
+ * compliance webhook that only cares about tool use, or a scrubber for one
+ * app, adds no latency to the rest. Conditions check the canonical request
+ * (and, for post stages, the response) before the stage's webhook is
+ * called; a stage that doesn't match is skipped, and the condition that
+ * failed is recorded.
+ *
+ * @module middleware/conditions
+ */
+
+import type { CanonicalRequest } from '../domain/types.js';
+import type { AppConfig, StageConditionConfig } from '../ports/config.js';
+import type { PipelineContext } from './types.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * What conditions are evaluated with besides the pipeline context.
+ */
+export interface ConditionEnvironment {
+    /** Estimates a request's input tokens. */
+    countTokens: (request: CanonicalRequest) => number;
+
+    /** Random number in [0, 1), for sample rates. */
+    random: () => number;
+}
+
+/** Condition fields as written in config, for messages. */
+const FIELD_NAMES: Record<keyof StageConditionConfig, string> = {
+    all: 'all',
+    any: 'any',
+    model: 'model',
+    modelPrefix: 'model_prefix',
+    modelPattern: 'model_pattern',
+    tenant: 'tenant',
+    app: 'app',
+    stream: 'stream',
+    hasTools: 'has_tools',
+    minMessages: 'min_messages',
+    maxMessages: 'max_messages',
+    minTokens: 'min_tokens',
+    maxTokens: 'max_tokens',
+    metadata: 'metadata',
+    sampleRate: 'sample_rate',
+    finishReason: 'finish_reason',
+    minContentLength: 'min_content_length',
+    maxContentLength: 'max_content_length',
+};
+
+/** Fields that check the response, which pre stages don't have yet. */
+const RESPONSE_FIELDS: readonly (keyof StageConditionConfig)[] = ['finishReason', 'minContentLength', 'maxContentLength'];
+
+/** Compiled model patterns, by source. */
+const patterns = new Map<string, RegExp>();
+
+// ============================================================================
+// Evaluation
+// ============================================================================
+
+/**
+ * Returns the condition a request doesn't meet, as written in config
+ * (e.g. `has_tools: true`), or undefined when the stage should run.
+ * The sample rate is checked last, so it samples matching requests.
+ */
+export function unmetCondition(
+    when: StageConditionConfig,
+    ctx: PipelineContext,
+    env: ConditionEnvironment,
+): string | undefined {
+    const { request, response } = ctx;
+
+    if (when.model !== undefined && request.model !== when.model) {
+        return `model: '${when.model}'`;
+    }
+    if (when.modelPrefix !== undefined && !request.model.startsWith(when.modelPrefix)) {
+        return `model_prefix: '${when.modelPrefix}'`;
+    }
+    if (when.modelPattern !== undefined && !pattern(when.modelPattern).test(request.model)) {
+        return `model_pattern: '${when.modelPattern}'`;
+    }
+    if (when.tenant && !when.tenant.includes(ctx.tenantId)) {
+        return `tenant: ${list(when.tenant)}`;
+    }
+    if (when.app && (ctx.appName === undefined || !when.app.includes(ctx.appName))) {
+        return `app: ${list(when.app)}`;
+    }
+    if (when.stream !== undefined && request.stream !== when.stream) {
+        return `stream: ${when.stream}`;
+    }
+    if (when.hasTools !== undefined && ((request.tools?.length ?? 0) > 0) !== when.hasTools) {
+        return `has_tools: ${when.hasTools}`;
+    }
+
+    const messages = request.messages.length;
+    if (when.minMessages !== undefined && messages < when.minMessages) {
+        return `min_messages: ${when.minMessages}`;
+    }
+    if (when.maxMessages !== undefined && messages > when.maxMessages) {
+        return `max_messages: ${when.maxMessages}`;
+    }
+    if (when.minTokens !== undefined || when.maxTokens !== undefined) {
+        const tokens = env.countTokens(request);
+        if (when.minTokens !== undefined && tokens < when.minTokens) {
+            return `min_tokens: ${when.minTokens}`;
+        }
+        if (when.maxTokens !== undefined && tokens > when.maxTokens) {
+            return `max_tokens: ${when.maxTokens}`;
+        }
+    }
+    for (const [key, value] of Object.entries(when.metadata ?? {})) {
+        if (request.metadata?.[key] !== value) {
+            return `metadata.${key}: '${value}'`;
+        }
+    }
+
+    const choice = response?.choices[0];
+    if (when.finishReason && !(choice?.finishReason && when.finishReason.includes(choice.finishReason))) {
+        return `finish_reason: ${list(when.finishReason)}`;
+    }
+    const length = choice?.message.content.length ?? 0;
+    if (when.minContentLength !== undefined && length < when.minContentLength) {
+        return `min_content_length: ${when.minContentLength}`;
+    }
+    if (when.maxContentLength !== undefined && length > when.maxContentLength) {
+        return `max_content_length: ${when.maxContentLength}`;
+    }
+
+    for (const condition of when.all ?? []) {
+        const unmet = unmetCondition(condition, ctx, env);
+        if (unmet) {
+            return unmet;
+        }
+    }
+    if (when.any) {
+        const unmet: string[] = [];
+        for (const condition of when.any) {
+            const reason = unmetCondition(condition, ctx, env);
+            if (!reason) break;
+            unmet.push(reason);
+        }
+        if (unmet.length === when.any.length) {
+            return `any: none of (${unmet.join('; ')})`;
+        }
+    }
+
+    if (when.sampleRate !== undefined && env.random() >= when.sampleRate) {
+        return `sample_rate: ${when.sampleRate}`;
+    }
+    return undefined;
+}
+
+function pattern(source: string): RegExp {
+    let compiled = patterns.get(source);
+    if (!compiled) {
+        compiled = new RegExp(source);
+        patterns.set(source, compiled);
+    }
+    return compiled;
+}
+
+function list(values: string[]): string {
+    return values.length === 1 ? `'${values[0]}'` : `[${values.map((v) => `'${v}'`).join(', ')}]`;
+}
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Throws if a stage's condition is malformed: a bad pattern, count, or
+ * rate, an empty list, or a response field on a pre stage.
+ */
+export function validateStageCondition(when: StageConditionConfig, stageType: 'pre' | 'post', path = 'when'): void {
+    if (typeof when !== 'object' || when === null || Array.isArray(when)) {
+        throw new Error(`${path} must be a condition object`);
+    }
+    const field = (key: keyof StageConditionConfig) => `${path}.${FIELD_NAMES[key]}`;
+
+    for (const key of ['model', 'modelPrefix'] as const) {
+        if (when[key] !== undefined && (typeof when[key] !== 'string' || when[key] === '')) {
+            throw new Error(`${field(key)} must be a non-empty string`);
+        }
+    }
+    if (when.modelPattern !== undefined) {
+        try {
+            pattern(when.modelPattern);
+        } catch {
+            throw new Error(`${field('modelPattern')}: '${String(when.modelPattern)}' is not a valid regular expression`);
+        }
+    }
+    for (const key of ['tenant', 'app', 'finishReason'] as const) {
+        const values = when[key];
+        if (values !== undefined && (!Array.isArray(values) || values.length === 0 || values.some((v) => typeof v !== 'string' || v === ''))) {
+            throw new Error(`${field(key)} must be a name or a non-empty list of names`);
+        }
+    }
+    for (const key of ['stream', 'hasTools'] as const) {
+        if (when[key] !== undefined && typeof when[key] !== 'boolean') {
+            throw new Error(`${field(key)} must be true or false`);
+        }
+    }
+    for (const [min, max] of [
+        ['minMessages', 'maxMessages'],
+        ['minTokens', 'maxTokens'],
+        ['minContentLength', 'maxContentLength'],
+    ] as const) {
+        for (const key of [min, max]) {
+            const value = when[key];
+            if (value !== undefined && !(typeof value === 'number' && Number.isInteger(value) && value >= 0)) {
+                throw new Error(`${field(key)} must be a non-negative integer, got ${String(value)}`);
+            }
+        }
+        if (when[min] !== undefined && when[max] !== undefined && when[min] > when[max]) {
+            throw new Error(`${field(min)} is more than ${FIELD_NAMES[max]}`);
+        }
+    }
+    if (when.metadata !== undefined
+        && (typeof when.metadata !== 'object' || when.metadata === null
+            || Object.values(when.metadata).some((v) => typeof v !== 'string'))) {
+        throw new Error(`${field('metadata')} must map keys to string values`);
+    }
+    if (when.sampleRate !== undefined && !(typeof when.sampleRate === 'number' && when.sampleRate >= 0 && when.sampleRate <= 1)) {
+        throw new Error(`${field('sampleRate')} must be between 0 and 1, got ${String(when.sampleRate)}`);
+    }
+    if (stageType === 'pre') {
+        const key = RESPONSE_FIELDS.find((k) => when[k] !== undefined);
+        if (key) {
+            throw new Error(`${field(key)} checks the response, so only post stages can use it`);
+        }
+    }
+    for (const key of ['all', 'any'] as const) {
+        const conditions = when[key];
+        if (conditions === undefined) continue;
+        if (!Array.isArray(conditions) || conditions.length === 0) {
+            throw new Error(`${field(key)} must be a non-empty list of conditions`);
+        }
+        conditions.forEach((condition, i) => validateStageCondition(condition, stageType, `${field(key)}[${i}]`));
+    }
+}
+
+/**
+ * Fails the load if any app's pipeline stage has a malformed condition.
+ */
+export function checkStageConditions(apps: AppConfig[]): void {
+    for (const app of apps) {
+        for (const stage of app.pipeline?.stages ?? []) {
+            if (stage.when === undefined) continue;
+            try {
+                validateStageCondition(stage.when, stage.type);
+            } catch (error) {
+                throw new Error(`Invalid config for app '${app.name}': pipeline stage '${stage.name}' ${(error as Error).message}`);
+            }
+        }
+    }
+}
@@ -5,13 +5,21 @@
  */
 
 import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import { TokenCounter } from '../tokens/count.js';
 import { APIError, errServer } from '../domain/errors.js';
+import { createInteractionEvent } from '../domain/events.js';
+import type { InteractionStore } from '../ports/storage.js';
 import type { Logger } from '../utils/logging.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import { validateRequestMutation, validateResponseMutation } from './validation.js';
+import { StageCache } from './cache.js';
+import { unmetCondition, type ConditionEnvironment } from './conditions.js';
 import type {
     PipelineContext,
     StageConfig,
     StepResult,
     MiddlewareStep,
+    RouteOverride,
 } from './types.js';
 
 // ============================================================================
@@ -30,6 +38,46 @@ export interface ExecutorOptions {
 
     /** Default error handling mode. */
     defaultOnError?: 'allow' | 'deny' | undefined;
+
+    /**
+     * Checks that a provider named by a route action exists for the tenant.
+     * When unset, route actions are applied without validation.
+     */
+    hasProvider?: ((name: string, tenantId: string) => boolean) | undefined;
+
+    /** Where rejected mutations and cached stage decisions are recorded (optional). */
+    events?: Pick<InteractionStore, 'saveEvent'> | undefined;
+
+    /** Clock for stage cache expiry, in milliseconds (default Date.now). */
+    now?: (() => number) | undefined;
+
+    /** Estimates a request's input tokens, for token conditions (default: approximate counts). */
+    countTokens?: ((request: CanonicalRequest) => number) | undefined;
+
+    /** Random source for sample rate conditions (default Math.random). */
+    random?: (() => number) | undefined;
+}
+
+/**
+ * Route override applied by the pipeline, with the stage that chose it.
+ */
+export interface AppliedRoute extends RouteOverride {
+    /** Stage that returned the route action. */
+    stage: string;
+}
+
+/**
+ * What one stage did, in run order.
+ */
+export interface StageOutcome {
+    /** Stage name. */
+    stage: string;
+
+    /** Action the stage returned, 'mutation_rejected' for an invalid modify, or 'skipped' when its condition didn't match. */
+    action: StepResult['action'] | 'mutation_rejected' | 'skipped';
+
+    /** Condition a skipped stage's request didn't meet. */
+    condition?: string | undefined;
 }
 
 /**
@@ -50,6 +98,18 @@ export interface ExecutionResult {
 
     /** Deny status code. */
     denyStatusCode?: number | undefined;
+
+    /** Stage that denied (if denied). */
+    deniedBy?: StageConfig | undefined;
+
+    /** Route override from a route action (last writer wins). */
+    route?: AppliedRoute | undefined;
+
+    /** Transformations applied by stages, for interaction recording. */
+    transformations?: TransformationStep[] | undefined;
+
+    /** Outcome of each stage, in order. */
+    stages?: StageOutcome[] | undefined;
 }
 
 /**
@@ -61,18 +121,31 @@ export class PipelineExecutor {
     private readonly logger?: Logger;
     private readonly defaultTimeoutMs: number;
     private readonly defaultOnError: 'allow' | 'deny';
+    private readonly hasProvider?: (name: string, tenantId: string) => boolean;
+    private readonly events?: Pick<InteractionStore, 'saveEvent'>;
+    private readonly now: () => number;
+    private readonly caches = new Map<StageConfig, StageCache>();
+    private readonly conditions: ConditionEnvironment;
 
     constructor(options?: ExecutorOptions) {
         this.logger = options?.logger;
         this.defaultTimeoutMs = options?.defaultTimeoutMs ?? 30000;
         this.defaultOnError = options?.defaultOnError ?? 'deny';
+        this.hasProvider = options?.hasProvider;
+        this.events = options?.events;
+        this.now = options?.now ?? Date.now;
+        const tokens = new TokenCounter();
+        this.conditions = {
+            countTokens: options?.countTokens ?? ((request) => tokens.countRequest(request).inputTokens),
+            random: options?.random ?? Math.random,
+        };
     }
 
     /**
      * Adds a pre-request stage.
      */
     addPreStage(stage: StageConfig): void {
-        this.preStages.push({ ...stage, type: 'pre' });
+        this.preStages.push(this.withCache({ ...stage, type: 'pre' }));
         this.sortStages();
     }
 
@@ -80,10 +153,17 @@ export class PipelineExecutor {
      * Adds a post-request stage.
      */
     addPostStage(stage: StageConfig): void {
-        this.postStages.push({ ...stage, type: 'post' });
+        this.postStages.push(this.withCache({ ...stage, type: 'post' }));
         this.sortStages();
     }
 
+    private withCache(stage: StageConfig): StageConfig {
+        if (stage.cache) {
+            this.caches.set(stage, new StageCache(stage.cache, this.now));
+        }
+        return stage;
+    }
+
     /**
      * Runs the pre-request pipeline.
      */
@@ -98,6 +178,13 @@ export class PipelineExecutor {
         return this.runStages(this.postStages, ctx);
     }
 
+    /**
+     * Whether any post-request stage is configured.
+     */
+    get hasPostStages(): boolean {
+        return this.postStages.length > 0;
+    }
+
     /**
      * Runs a list of stages.
      */
@@ -105,15 +192,50 @@ export class PipelineExecutor {
         stages: StageConfig[],
         ctx: PipelineContext,
     ): Promise<ExecutionResult> {
+        let route: AppliedRoute | undefined;
+        const transformations: TransformationStep[] = [];
+        const outcomes: StageOutcome[] = [];
+
         for (const stage of stages) {
+            const unmet = stage.when && unmetCondition(stage.when, ctx, this.conditions);
+            if (unmet) {
+                outcomes.push({ stage: stage.name, action: 'skipped', condition: unmet });
+                await this.recordEvent(stage, ctx, { stage: stage.name, action: 'skipped', skipped: true, condition: unmet });
+                continue;
+            }
+
             const result = await this.runStage(stage, ctx);
+            outcomes.push({ stage: stage.name, action: result.action });
 
             switch (result.action) {
                 case 'continue':
                     // Continue to next stage
                     break;
 
-                case 'modify':
+                case 'modify': {
+                    // Reject mutations that don't describe a sendable request or response
+                    const errors = [
+                        ...(result.request ? validateRequestMutation(ctx.request, result.request) : [])
+                            .map((e) => `request.${e}`),
+                        ...(result.response ? validateResponseMutation(result.response) : [])
+                            .map((e) => `response.${e}`),
+                    ];
+                    if (errors.length > 0) {
+                        outcomes[outcomes.length - 1]!.action = 'mutation_rejected';
+                        await this.recordRejection(stage, ctx, errors);
+                        if ((stage.onError ?? this.defaultOnError) === 'deny') {
+                            return {
+                                continue: false,
+                                denyReason: `Stage '${stage.name}' returned an invalid mutation: ${errors.join('; ')}`,
+                                denyStatusCode: 500,
+                                deniedBy: stage,
+                                stages: outcomes,
+                            };
+                        }
+                        // Fail open with the un-mutated request and response
+                        break;
+                    }
+
                     // Update context with modifications
                     if (result.request) {
                         ctx.request = result.request;
@@ -122,19 +244,57 @@ export class PipelineExecutor {
                         ctx.response = result.response;
                     }
                     break;
+                }
 
                 case 'deny':
                     return {
                         continue: false,
                         denyReason: result.reason,
                         denyStatusCode: result.statusCode ?? 403,
+                        deniedBy: stage,
+                        stages: outcomes,
                     };
 
                 case 'respond':
                     return {
                         continue: false,
                         response: result.response,
+                        stages: outcomes,
+                    };
+
+                case 'route': {
+                    const { provider, model } = result.route;
+                    if (provider && this.hasProvider && !this.hasProvider(provider, ctx.tenantId)) {
+                        const reason = `Stage '${stage.name}' routed to unknown provider '${provider}'`;
+                        this.logger?.error('pipeline_route_rejected', { stage: stage.name, provider });
+                        if ((stage.onError ?? this.defaultOnError) === 'deny') {
+                            return { continue: false, denyReason: reason, denyStatusCode: 500, deniedBy: stage, stages: outcomes };
+                        }
+                        break;
+                    }
+
+                    if (route) {
+                        this.logger?.warn('pipeline_route_overridden', {
+                            stage: stage.name,
+                            previousStage: route.stage,
+                        });
+                    }
+                    route = {
+                        stage: stage.name,
+                        provider: provider ?? route?.provider,
+                        model: model ?? route?.model,
                     };
+                    if (model) {
+                        ctx.request = { ...ctx.request, model };
+                    }
+                    transformations.push({
+                        stage: stage.name,
+                        timestamp: new Date(),
+                        description: 'pipeline route override',
+                        details: { provider, model },
+                    });
+                    break;
+                }
             }
         }
 
@@ -142,36 +302,40 @@ export class PipelineExecutor {
             continue: true,
             request: ctx.request,
             response: ctx.response,
+            route,
+            transformations: transformations.length > 0 ? transformations : undefined,
+            stages: outcomes,
         };
     }
 
     /**
-     * Runs a single stage with timeout and error handling.
+     * Runs a single stage with caching, timeout, and error handling.
+     * Failures fall back to onError (except an unresolved secret, which
+     * always denies) and are never cached.
      */
     private async runStage(
         stage: StageConfig,
         ctx: PipelineContext,
     ): Promise<StepResult> {
-        const timeoutMs = stage.timeoutMs ?? this.defaultTimeoutMs;
         const onError = stage.onError ?? this.defaultOnError;
+        const cache = this.caches.get(stage);
 
         try {
-            // Create abort controller for timeout
-            const controller = new AbortController();
-            const timeoutId = setTimeout(() => controller.abort(), timeoutMs);
+            if (!cache) {
+                return await this.invokeStage(stage, ctx);
+            }
 
-            // Run the step with timeout
-            const resultPromise = stage.step({ ...ctx, signal: controller.signal });
-            const result = await Promise.race([
-                resultPromise,
-                new Promise<StepResult>((_, reject) => {
-                    controller.signal.addEventListener('abort', () => {
-                        reject(new Error(`Stage '${stage.name}' timed out after ${timeoutMs}ms`));
-                    });
-                }),
-            ]);
+            const key = await cache.key(ctx);
+            const hit = cache.get(key, ctx);
+            if (hit) {
+                await this.recordEvent(stage, ctx, { stage: stage.name, action: hit.action, cached: true });
+                return hit;
+            }
 
-            clearTimeout(timeoutId);
+            const result = await this.invokeStage(stage, ctx);
+            if (cache.set(key, ctx, result)) {
+                await this.recordEvent(stage, ctx, { stage: stage.name, action: result.action, cached: false });
+            }
             return result;
         } catch (error) {
             const message = error instanceof Error ? error.message : String(error);
@@ -180,7 +344,8 @@ export class PipelineExecutor {
                 error: message,
             });
 
-            if (onError === 'allow') {
+            // A secret that doesn't resolve fails closed, whatever onError says
+            if (onError === 'allow' && !(error instanceof APIError && error.code === 'secret_unavailable')) {
                 return { action: 'continue' };
             }
 
@@ -192,6 +357,58 @@ export class PipelineExecutor {
         }
     }
 
+    /**
+     * Runs a stage's step with its timeout.
+     */
+    private async invokeStage(stage: StageConfig, ctx: PipelineContext): Promise<StepResult> {
+        const timeoutMs = stage.timeoutMs ?? this.defaultTimeoutMs;
+
+        // Create abort controller for timeout
+        const controller = new AbortController();
+        const timeoutId = setTimeout(() => controller.abort(), timeoutMs);
+
+        try {
+            // Run the step with timeout
+            return await Promise.race([
+                stage.step({ ...ctx, signal: controller.signal }),
+                new Promise<StepResult>((_, reject) => {
+                    controller.signal.addEventListener('abort', () => {
+                        reject(new Error(`Stage '${stage.name}' timed out after ${timeoutMs}ms`));
+                    });
+                }),
+            ]);
+        } finally {
+            clearTimeout(timeoutId);
+        }
+    }
+
+    /**
+     * Logs a rejected mutation and records it as an interaction event.
+     */
+    private async recordRejection(stage: StageConfig, ctx: PipelineContext, errors: string[]): Promise<void> {
+        this.logger?.error('pipeline_mutation_rejected', { stage: stage.name, errors });
+        await this.recordEvent(stage, ctx, { stage: stage.name, action: 'mutation_rejected', errors });
+    }
+
+    /**
+     * Records a stage outcome (a cached or rejected result, or a skip) as
+     * an interaction event.
+     */
+    private async recordEvent(stage: StageConfig, ctx: PipelineContext, payload: Record<string, unknown>): Promise<void> {
+        if (!this.events) return;
+
+        const event = createInteractionEvent(
+            stage.type === 'post' ? 'pipeline_post' : 'pipeline_pre',
+            ctx.interactionId,
+            payload,
+        );
+        await this.events.saveEvent(event).catch((err: unknown) => {
+            this.logger?.warn('pipeline_event_save_failed', {
+                error: err instanceof Error ? err.message : String(err),
+            });
+        });
+    }
+
     /**
      * Sorts stages by order.
      */
@@ -9,6 +9,7 @@ export type {
     PipelineContext,
     StepResult,
     MiddlewareStep,
+    RouteOverride,
     StageConfig,
     TransformStepConfig,
     RateLimitStepConfig,
@@ -24,6 +25,7 @@ export {
     modifyResult,
     denyResult,
     respondResult,
+    routeResult,
 } from './types.js';
 
 // Executor
@@ -32,11 +34,37 @@ export {
     createExecutor,
     type ExecutorOptions,
     type ExecutionResult,
+    type AppliedRoute,
+    type StageOutcome,
 } from './executor.js';
 
+// Stage result caching
+export {
+    StageCache,
+    DEFAULT_STAGE_CACHE_TTL_MS,
+    DEFAULT_STAGE_CACHE_ENTRIES,
+    DEFAULT_STAGE_CACHE_KEY,
+    STAGE_CACHE_KEY_FIELDS,
+    type StageCachePolicy,
+    type StageCacheKeyField,
+    type CachedStageResult,
+} from './cache.js';
+
+// Stage conditions
+export {
+    unmetCondition,
+    validateStageCondition,
+    checkStageConditions,
+    type ConditionEnvironment,
+} from './conditions.js';
+
+// Mutation validation
+export { validateRequestMutation, validateResponseMutation } from './validation.js';
+
 // Built-in steps
 export {
     createWebhookStep,
+    WEBHOOK_SIGNATURE_HEADER,
     createTransformStep,
     createContentFilterStep,
     createLogStep,
@@ -4,7 +4,7 @@
  * @module middleware/steps
  */
 
-export { createWebhookStep } from './webhook.js';
+export { createWebhookStep, WEBHOOK_SIGNATURE_HEADER } from './webhook.js';
 export { createTransformStep } from './transform.js';
 export { createContentFilterStep } from './filter.js';
 export { createLogStep } from './log.js';
@@ -4,15 +4,22 @@
  * @module middleware/steps/webhook
  */
 
+import type { CanonicalResponse } from '../../domain/types.js';
 import type { PipelineContext, StepResult, WebhookStepConfig } from '../types.js';
-import { continueResult, denyResult, modifyResult } from '../types.js';
+import { continueResult, denyResult, modifyResult, routeResult } from '../types.js';
+
+/**
+ * Header carrying the hex HMAC-SHA256 of the request body, for webhooks
+ * configured with a signing secret.
+ */
+export const WEBHOOK_SIGNATURE_HEADER = 'X-Gateway-Signature';
 
 /**
  * Webhook response format.
  */
 interface WebhookResponse {
     /** Action to take. */
-    action?: 'allow' | 'deny' | 'modify' | undefined;
+    action?: 'allow' | 'deny' | 'modify' | 'route' | undefined;
 
     /** Deny reason. */
     reason?: string | undefined;
@@ -22,6 +29,9 @@ interface WebhookResponse {
 
     /** Modified response fields. */
     response?: Record<string, unknown> | undefined;
+
+    /** Provider/model override (route action). */
+    route?: { provider?: string; model?: string } | undefined;
 }
 
 /**
@@ -29,16 +39,19 @@ interface WebhookResponse {
  */
 export function createWebhookStep(config: WebhookStepConfig): (ctx: PipelineContext) => Promise<StepResult> {
     const { url, method = 'POST', headers = {}, timeoutMs = 5000, retries = 0 } = config;
+    const fetchFn = config.fetch ?? globalThis.fetch.bind(globalThis);
 
     return async (ctx: PipelineContext): Promise<StepResult> => {
-        // Build payload
+        // Build payload; the provider's raw bytes stay in the gateway
         const payload = {
             request: ctx.request,
-            response: ctx.response,
+            response: ctx.response && withoutRaw(ctx.response),
             tenantId: ctx.tenantId,
             appName: ctx.appName,
             interactionId: ctx.interactionId,
         };
+        const body = JSON.stringify(payload);
+        const signature = config.signingKey && await sign(config.signingKey(), body);
 
         // Retry loop
         let lastError: Error | undefined;
@@ -47,13 +60,14 @@ export function createWebhookStep(config: WebhookStepConfig): (ctx: PipelineCont
                 const controller = new AbortController();
                 const timeoutId = setTimeout(() => controller.abort(), timeoutMs);
 
-                const response = await fetch(url, {
+                const response = await fetchFn(url, {
                     method,
                     headers: {
                         'Content-Type': 'application/json',
                         ...headers,
+                        ...(signature !== undefined && { [WEBHOOK_SIGNATURE_HEADER]: signature }),
                     },
-                    body: JSON.stringify(payload),
+                    body,
                     signal: controller.signal,
                 });
 
@@ -87,6 +101,11 @@ export function createWebhookStep(config: WebhookStepConfig): (ctx: PipelineCont
                         }
                         return continueResult();
 
+                    case 'route':
+                        return result.route
+                            ? routeResult({ provider: result.route.provider, model: result.route.model })
+                            : continueResult();
+
                     case 'allow':
                     default:
                         return continueResult();
@@ -109,3 +128,27 @@ export function createWebhookStep(config: WebhookStepConfig): (ctx: PipelineCont
         throw lastError ?? new Error('Webhook failed');
     };
 }
+
+/**
+ * Returns the hex HMAC-SHA256 of a body under a key.
+ */
+async function sign(key: string, body: string): Promise<string> {
+    const encoder = new TextEncoder();
+    const hmacKey = await crypto.subtle.importKey(
+        'raw',
+        encoder.encode(key),
+        { name: 'HMAC', hash: 'SHA-256' },
+        false,
+        ['sign'],
+    );
+    const mac = await crypto.subtle.sign('HMAC', hmacKey, encoder.encode(body));
+    return Array.from(new Uint8Array(mac), (b) => b.toString(16).padStart(2, '0')).join('');
+}
+
+/**
+ * A response without the provider's raw body and headers.
+ */
+function withoutRaw(response: CanonicalResponse): CanonicalResponse {
+    const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...rest } = response;
+    return rest;
+}
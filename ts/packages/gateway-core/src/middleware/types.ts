@@ -5,6 +5,8 @@
  */
 
 import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import type { DenyResponseConfig, StageConditionConfig } from '../ports/config.js';
+import type { StageCachePolicy } from './cache.js';
 
 // ============================================================================
 // Pipeline Context
@@ -47,7 +49,19 @@ export type StepResult =
     | { action: 'continue' }
     | { action: 'modify'; request?: CanonicalRequest; response?: CanonicalResponse }
     | { action: 'deny'; reason: string; statusCode?: number }
-    | { action: 'respond'; response: CanonicalResponse };
+    | { action: 'respond'; response: CanonicalResponse }
+    | { action: 'route'; route: RouteOverride };
+
+/**
+ * Provider and/or model chosen by a routing stage.
+ */
+export interface RouteOverride {
+    /** Provider to serve the request. */
+    provider?: string | undefined;
+
+    /** Model to request. */
+    model?: string | undefined;
+}
 
 /**
  * A middleware step function.
@@ -75,6 +89,15 @@ export interface StageConfig {
 
     /** Execution order (lower = earlier). */
     order?: number | undefined;
+
+    /** Result caching, for stages that are deterministic over the key fields. */
+    cache?: StageCachePolicy | undefined;
+
+    /** What the client gets when this post stage denies a response (default: the app's). */
+    onDeny?: DenyResponseConfig | undefined;
+
+    /** Run only for requests matching this condition (default: every request). */
+    when?: StageConditionConfig | undefined;
 }
 
 // ============================================================================
@@ -135,6 +158,10 @@ export interface WebhookStepConfig {
     timeoutMs?: number | undefined;
     /** Retry count. */
     retries?: number | undefined;
+    /** Returns the key to sign the body with; looked up on every call, and a throw fails the step. */
+    signingKey?: (() => string) | undefined;
+    /** Fetch implementation (defaults to global fetch). */
+    fetch?: typeof fetch | undefined;
 }
 
 /**
@@ -208,3 +235,10 @@ export function denyResult(reason: string, statusCode?: number): StepResult {
 export function respondResult(response: CanonicalResponse): StepResult {
     return { action: 'respond', response };
 }
+
+/**
+ * Creates a route result.
+ */
+export function routeResult(route: RouteOverride): StepResult {
+    return { action: 'route', route };
+}
@@ -0,0 +1,71 @@
+/**
+ * Mutation validation - checks what a stage's modify action returns before
+ * the pipeline accepts it.
+ *
+ * @module middleware/validation
+ */
+
+import type { CanonicalRequest, CanonicalResponse } from '../domain/types.js';
+import {
+    CANONICAL_REQUEST_SCHEMA,
+    CANONICAL_RESPONSE_SCHEMA,
+    validateSchema,
+} from '../domain/schema.js';
+
+/**
+ * Validates a mutated request against the canonical schema and checks that
+ * it can still be sent: it has messages, and every tool call from the
+ * original is either kept with its result or removed together with it.
+ * Returns the violations; empty when valid.
+ */
+export function validateRequestMutation(original: CanonicalRequest, mutated: CanonicalRequest): string[] {
+    const errors = validateSchema(CANONICAL_REQUEST_SCHEMA, mutated);
+    if (errors.length > 0) {
+        // Semantic checks assume the structure is sound
+        return errors;
+    }
+
+    if (!mutated.model) {
+        errors.push('model: must not be empty');
+    }
+    if (mutated.messages.length === 0) {
+        errors.push('messages: must not be empty');
+    }
+
+    const calls = toolCallIds(mutated);
+    const results = toolResultIds(mutated);
+    const answered = toolResultIds(original);
+
+    mutated.messages.forEach((m, i) => {
+        if (m.role === 'tool' && m.toolCallId && !calls.has(m.toolCallId)) {
+            errors.push(`messages[${i}].toolCallId: no tool call '${m.toolCallId}'`);
+        }
+    });
+    for (const id of calls) {
+        if (answered.has(id) && !results.has(id)) {
+            errors.push(`messages: result of tool call '${id}' was removed but the call was kept`);
+        }
+    }
+
+    return errors;
+}
+
+/**
+ * Validates a mutated response against the canonical schema. Returns the
+ * violations; empty when valid.
+ */
+export function validateResponseMutation(mutated: CanonicalResponse): string[] {
+    const errors = validateSchema(CANONICAL_RESPONSE_SCHEMA, mutated);
+    if (errors.length === 0 && mutated.choices.length === 0) {
+        errors.push('choices: must not be empty');
+    }
+    return errors;
+}
+
+function toolCallIds(request: CanonicalRequest): Set<string> {
+    return new Set(request.messages.flatMap((m) => m.toolCalls?.map((tc) => tc.id) ?? []));
+}
+
+function toolResultIds(request: CanonicalRequest): Set<string> {
+    return new Set(request.messages.flatMap((m) => (m.role === 'tool' && m.toolCallId ? [m.toolCallId] : [])));
+}
@@ -0,0 +1,176 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { MigrationRunner, STORAGE_MIGRATIONS, type Migration, type MigrationDatabase } from './migrations/index';
+
+/**
+ * A minimal SQLite stand-in: tracks tables, columns, and rows, and applies
+ * each exec batch atomically like D1.
+ */
+function fakeSqlite() {
+    let tables = new Map<string, { columns: string[]; rows: Record<string, unknown>[] }>();
+
+    function apply(sql: string, params: unknown[]) {
+        const create = /^CREATE TABLE IF NOT EXISTS (\w+) \(([\s\S]*)\)$/.exec(sql);
+        if (create) {
+            if (!tables.has(create[1]!)) {
+                const columns = create[2]!.split('\n')
+                    .map((line) => line.trim().split(' ')[0]!)
+                    .filter((name) => name && !['PRIMARY', 'FOREIGN'].includes(name));
+                tables.set(create[1]!, { columns, rows: [] });
+            }
+            return;
+        }
+        const alter = /^ALTER TABLE (\w+) ADD COLUMN (\w+)/.exec(sql);
+        if (alter) {
+            const table = tables.get(alter[1]!);
+            if (!table) throw new Error(`no such table: ${alter[1]}`);
+            if (table.columns.includes(alter[2]!)) throw new Error(`duplicate column name: ${alter[2]}`);
+            table.columns.push(alter[2]!);
+            return;
+        }
+        const insert = /^INSERT INTO (\w+) \(([^)]*)\)/.exec(sql);
+        if (insert) {
+            const table = tables.get(insert[1]!);
+            if (!table) throw new Error(`no such table: ${insert[1]}`);
+            const names = insert[2]!.split(', ');
+            table.rows.push(Object.fromEntries(names.map((name, i) => [name, params[i]])));
+            return;
+        }
+        if (sql.startsWith('CREATE INDEX') || sql.startsWith('CREATE UNIQUE INDEX')) return;
+        if (sql.startsWith('DELETE FROM')) return;
+        throw new Error(`unsupported: ${sql}`);
+    }
+
+    const db: MigrationDatabase = {
+        dialect: 'sqlite',
+        async exec(statements) {
+            const snapshot = new Map([...tables].map(([name, t]) => [name, { columns: [...t.columns], rows: [...t.rows] }]));
+            try {
+                for (const statement of statements) {
+                    apply(statement.sql, statement.params ?? []);
+                }
+            } catch (error) {
+                tables = snapshot;
+                throw error;
+            }
+        },
+        async query(sql, params = []) {
+            if (sql.includes('pragma_table_info')) {
+                return tables.get(String(params[0]))?.columns.includes(String(params[1])) ? [{ found: 1 }] : [];
+            }
+            const select = /FROM (\w+)$/.exec(sql);
+            return tables.get(select![1]!)?.rows ?? [];
+        },
+    };
+    return { db, tables: () => tables };
+}
+
+/** Creates tables the way the pre-migration schema.sql did. */
+async function legacySchema(db: MigrationDatabase, versions: number[]) {
+    for (const migration of STORAGE_MIGRATIONS.filter((m) => versions.includes(m.version))) {
+        await db.exec(migration.up.sqlite!.map((sql) => ({ sql })));
+    }
+}
+
+describe('MigrationRunner', () => {
+    it('should create the schema on an empty database and record every version', async () => {
+        const { db, tables } = fakeSqlite();
+        const runner = new MigrationRunner(db, STORAGE_MIGRATIONS);
+
+        const result = await runner.migrate();
+
+        expect(result).toEqual({ applied: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20], baselined: [], version: 20 });
+        expect(tables().get('responses')!.columns).toContain('timings');
+        expect((await runner.status()).every((m) => m.appliedAt instanceof Date)).toBe(true);
+        expect(await runner.migrate()).toEqual({ applied: [], baselined: [], version: 20 });
+    });
+
+    it('should upgrade a database created before migrations without losing data', async () => {
+        const { db, tables } = fakeSqlite();
+        await legacySchema(db, [1, 2]);
+        await db.exec([{
+            sql: 'INSERT INTO responses (id, tenant_id, model, status) VALUES (?, ?, ?, ?)',
+            params: ['resp_1', 'acme', 'gpt-4o', 'completed'],
+        }]);
+
+        const result = await new MigrationRunner(db, STORAGE_MIGRATIONS).migrate();
+
+        expect(result.version).toBe(20);
+        expect(tables().get('responses')!.columns).toContain('timings');
+        expect(tables().get('responses')!.rows).toEqual([
+            { id: 'resp_1', tenant_id: 'acme', model: 'gpt-4o', status: 'completed' },
+        ]);
+        expect(tables().has('tenants')).toBe(true);
+    });
+
+    it('should record a legacy column migration without running it', async () => {
+        const { db } = fakeSqlite();
+        await legacySchema(db, [1, 2, 3]);
+
+        const result = await new MigrationRunner(db, STORAGE_MIGRATIONS).migrate();
+
+        expect(result).toEqual({ applied: [1, 2, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20], baselined: [3], version: 20 });
+    });
+
+    it('should roll back a failed migration and stop there', async () => {
+        const { db, tables } = fakeSqlite();
+        const migrations: Migration[] = [
+            { version: 1, name: 'one', up: { sqlite: ['CREATE TABLE IF NOT EXISTS one (\n  id TEXT\n)'] } },
+            { version: 2, name: 'two', up: { sqlite: ['CREATE TABLE IF NOT EXISTS two (\n  id TEXT\n)', 'DROP TABLE one'] } },
+            { version: 3, name: 'three', up: { sqlite: ['CREATE TABLE IF NOT EXISTS three (\n  id TEXT\n)'] } },
+        ];
+        const runner = new MigrationRunner(db, migrations);
+
+        await expect(runner.migrate()).rejects.toThrow('unsupported: DROP TABLE one');
+
+        expect(tables().has('two')).toBe(false);
+        expect(tables().has('three')).toBe(false);
+        expect((await runner.status()).map((m) => [m.version, m.appliedAt !== undefined])).toEqual([
+            [1, true], [2, false], [3, false],
+        ]);
+    });
+
+    it('should refuse a database migrated by a newer gateway', async () => {
+        const { db } = fakeSqlite();
+        await new MigrationRunner(db, STORAGE_MIGRATIONS).migrate();
+
+        await expect(new MigrationRunner(db, STORAGE_MIGRATIONS.slice(0, 3)).migrate())
+            .rejects.toThrow('Database schema version 17 is newer than this gateway supports (3)');
+    });
+
+    it('should reject duplicate versions', () => {
+        const { db } = fakeSqlite();
+        const migration = STORAGE_MIGRATIONS[0]!;
+
+        expect(() => new MigrationRunner(db, [migration, migration])).toThrow('Duplicate migration version 1');
+    });
+});
+
+describe('Gateway storage.autoMigrate', () => {
+    function gatewayWith(autoMigrate: boolean, migrate = vi.fn(async () => ({ applied: [1], baselined: [], version: 1 }))) {
+        const storage = { migrate, migrationStatus: vi.fn(async () => []) };
+        const gateway = new Gateway({
+            config: { load: async () => ({ apps: [], providers: [], storage: { type: 'd1', autoMigrate } }) },
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            storage: storage as any,
+        });
+        return { gateway, migrate };
+    }
+
+    it('should apply migrations on load only when enabled', async () => {
+        const enabled = gatewayWith(true);
+        const disabled = gatewayWith(false);
+
+        await enabled.gateway.reload();
+        await disabled.gateway.reload();
+
+        expect(enabled.migrate).toHaveBeenCalledTimes(1);
+        expect(disabled.migrate).not.toHaveBeenCalled();
+    });
+
+    it('should fail the load when a migration fails', async () => {
+        const { gateway } = gatewayWith(true, vi.fn().mockRejectedValue(new Error('locked')));
+
+        await expect(gateway.reload()).rejects.toThrow('locked');
+    });
+});
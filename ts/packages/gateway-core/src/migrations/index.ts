@@ -0,0 +1,17 @@
+/**
+ * Storage migration exports.
+ *
+ * @module migrations
+ */
+
+export {
+    MigrationRunner,
+    isMigratableStore,
+    sqliteColumnExists,
+    SCHEMA_MIGRATIONS_TABLE,
+    type Migration,
+    type MigrationDatabase,
+    type MigrationStatement,
+    type SQLDialect,
+} from './runner.js';
+export { STORAGE_MIGRATIONS } from './schema.js';
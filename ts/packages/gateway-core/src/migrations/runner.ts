@@ -0,0 +1,191 @@
+/**
+ * Versioned schema migrations for SQL storage providers.
+ *
+ * Each migration has a version, a name, and up statements per SQL dialect.
+ * The runner records applied versions in a schema_migrations table and
+ * applies pending ones in order, each in a transaction where the database
+ * supports it. Databases created before migrations existed have no
+ * schema_migrations table; a migration can detect that its change is
+ * already present and is then recorded without being run.
+ *
+ * @module migrations/runner
+ */
+
+import type { MigrationResult, MigrationStatus, MigratableStore, StorageProvider } from '../ports/storage.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** SQL dialects migrations are written for. */
+export type SQLDialect = 'sqlite';
+
+/** Name of the table recording applied migrations. */
+export const SCHEMA_MIGRATIONS_TABLE = 'schema_migrations';
+
+/**
+ * A SQL statement with bound parameters.
+ */
+export interface MigrationStatement {
+    /** SQL text, with ? placeholders. */
+    sql: string;
+
+    /** Bound parameters. */
+    params?: unknown[] | undefined;
+}
+
+/**
+ * Database access used by the runner, implemented by each SQL store.
+ */
+export interface MigrationDatabase {
+    /** Dialect of the database. */
+    readonly dialect: SQLDialect;
+
+    /**
+     * Runs statements in order. Atomic (all or none) when the database
+     * supports transactions.
+     */
+    exec(statements: MigrationStatement[]): Promise<void>;
+
+    /**
+     * Runs a query and returns its rows.
+     */
+    query(sql: string, params?: unknown[]): Promise<Record<string, unknown>[]>;
+}
+
+/**
+ * A schema migration.
+ */
+export interface Migration {
+    /** Version; migrations apply in ascending order. */
+    version: number;
+
+    /** Short description, recorded with the version. */
+    name: string;
+
+    /** Up statements per dialect. */
+    up: Partial<Record<SQLDialect, string[]>>;
+
+    /**
+     * Reports whether a database created before migrations existed already
+     * has this change, so it is recorded without being run. Only consulted
+     * for versions missing from schema_migrations.
+     */
+    present?(db: MigrationDatabase): Promise<boolean>;
+}
+
+// ============================================================================
+// Migration Runner
+// ============================================================================
+
+/**
+ * Applies migrations to a database and reports their status.
+ */
+export class MigrationRunner {
+    private readonly migrations: Migration[];
+
+    constructor(
+        private readonly db: MigrationDatabase,
+        migrations: Migration[],
+    ) {
+        this.migrations = [...migrations].sort((a, b) => a.version - b.version);
+        for (let i = 1; i < this.migrations.length; i++) {
+            if (this.migrations[i]!.version === this.migrations[i - 1]!.version) {
+                throw new Error(`Duplicate migration version ${this.migrations[i]!.version}`);
+            }
+        }
+    }
+
+    /**
+     * Lists every known migration, with when it was applied.
+     */
+    async status(): Promise<MigrationStatus[]> {
+        await this.ensureTable();
+        const applied = await this.applied();
+        return this.migrations.map((m) => ({
+            version: m.version,
+            name: m.name,
+            appliedAt: applied.get(m.version),
+        }));
+    }
+
+    /**
+     * Applies pending migrations in order. Stops at the first failure,
+     * leaving earlier migrations applied.
+     *
+     * @throws Error when the database has a version newer than any known
+     * migration (it was migrated by a newer gateway)
+     */
+    async migrate(): Promise<MigrationResult> {
+        await this.ensureTable();
+        const applied = await this.applied();
+        const latest = this.migrations[this.migrations.length - 1]?.version ?? 0;
+        const newest = Math.max(0, ...applied.keys());
+        if (newest > latest) {
+            throw new Error(`Database schema version ${newest} is newer than this gateway supports (${latest})`);
+        }
+
+        const result: MigrationResult = { applied: [], baselined: [], version: newest };
+        for (const migration of this.migrations) {
+            if (applied.has(migration.version)) {
+                continue;
+            }
+
+            const record: MigrationStatement = {
+                sql: `INSERT INTO ${SCHEMA_MIGRATIONS_TABLE} (version, name, applied_at) VALUES (?, ?, ?)`,
+                params: [migration.version, migration.name, new Date().toISOString()],
+            };
+            if (await migration.present?.(this.db)) {
+                await this.db.exec([record]);
+                result.baselined.push(migration.version);
+            } else {
+                const up = migration.up[this.db.dialect];
+                if (!up) {
+                    throw new Error(`Migration ${migration.version} (${migration.name}) has no ${this.db.dialect} statements`);
+                }
+                await this.db.exec([...up.map((sql) => ({ sql })), record]);
+                result.applied.push(migration.version);
+            }
+            result.version = migration.version;
+        }
+        return result;
+    }
+
+    private async ensureTable(): Promise<void> {
+        await this.db.exec([{
+            sql: `CREATE TABLE IF NOT EXISTS ${SCHEMA_MIGRATIONS_TABLE} (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_at TEXT NOT NULL
+)`,
+        }]);
+    }
+
+    private async applied(): Promise<Map<number, Date>> {
+        const rows = await this.db.query(`SELECT version, applied_at FROM ${SCHEMA_MIGRATIONS_TABLE}`);
+        return new Map(rows.map((row) => [Number(row.version), new Date(String(row.applied_at))]));
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Reports whether a SQLite table has a column.
+ */
+export async function sqliteColumnExists(db: MigrationDatabase, table: string, column: string): Promise<boolean> {
+    const rows = await db.query('SELECT 1 AS found FROM pragma_table_info(?) WHERE name = ?', [table, column]);
+    return rows.length > 0;
+}
+
+/**
+ * Type guard to check if a storage provider implements MigratableStore.
+ */
+export function isMigratableStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & MigratableStore {
+    return storage !== undefined
+        && typeof storage.migrate === 'function'
+        && typeof storage.migrationStatus === 'function';
+}
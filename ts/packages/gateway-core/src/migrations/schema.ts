@@ -0,0 +1,407 @@
+/**
+ * Storage schema migrations.
+ *
+ * The schema of SQL storage providers, as ordered migrations. Never edit a
+ * released migration; add a new one. apps/gateway-cloudflare/schema.sql is
+ * the schema after the latest migration, for reference and fresh installs.
+ *
+ * @module migrations/schema
+ */
+
+import { sqliteColumnExists, type Migration } from './runner.js';
+
+// ============================================================================
+// Storage Migrations
+// ============================================================================
+
+/**
+ * Migrations for the storage schema, oldest first.
+ */
+export const STORAGE_MIGRATIONS: Migration[] = [
+    {
+        version: 1,
+        name: 'initial_schema',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS conversations (
+  id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  model TEXT,
+  metadata TEXT DEFAULT '{}',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_conversations_tenant ON conversations(tenant_id)',
+                'CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at)',
+                `CREATE TABLE IF NOT EXISTS messages (
+  id TEXT PRIMARY KEY,
+  conversation_id TEXT NOT NULL,
+  role TEXT NOT NULL,
+  content TEXT NOT NULL,
+  usage TEXT,
+  timestamp TEXT NOT NULL,
+  FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id)',
+                `CREATE TABLE IF NOT EXISTS responses (
+  id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  thread_key TEXT,
+  previous_response_id TEXT,
+  model TEXT NOT NULL,
+  status TEXT NOT NULL,
+  request TEXT,
+  response TEXT,
+  error TEXT,
+  usage TEXT,
+  metadata TEXT DEFAULT '{}',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_responses_tenant ON responses(tenant_id)',
+                'CREATE INDEX IF NOT EXISTS idx_responses_thread ON responses(thread_key)',
+                'CREATE INDEX IF NOT EXISTS idx_responses_updated ON responses(updated_at)',
+                `CREATE TABLE IF NOT EXISTS interaction_events (
+  id TEXT PRIMARY KEY,
+  interaction_id TEXT NOT NULL,
+  type TEXT NOT NULL,
+  payload TEXT,
+  timestamp TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_events_interaction ON interaction_events(interaction_id)',
+                `CREATE TABLE IF NOT EXISTS shadow_results (
+  id TEXT PRIMARY KEY,
+  interaction_id TEXT NOT NULL,
+  provider_name TEXT NOT NULL,
+  request TEXT,
+  response TEXT,
+  error TEXT,
+  duration_ms INTEGER NOT NULL,
+  divergences TEXT NOT NULL DEFAULT '[]',
+  has_structural_divergence INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_shadow_interaction ON shadow_results(interaction_id)',
+                'CREATE INDEX IF NOT EXISTS idx_shadow_divergence ON shadow_results(has_structural_divergence)',
+                `CREATE TABLE IF NOT EXISTS thread_state (
+  thread_key TEXT PRIMARY KEY,
+  response_id TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+)`,
+            ],
+        },
+    },
+    {
+        version: 2,
+        name: 'idempotency_keys',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS idempotency_keys (
+  tenant_id TEXT NOT NULL,
+  idempotency_key TEXT NOT NULL,
+  interaction_id TEXT NOT NULL,
+  request_hash TEXT,
+  status TEXT NOT NULL,
+  response TEXT,
+  created_at TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, idempotency_key)
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_idempotency_expires ON idempotency_keys(expires_at)',
+            ],
+        },
+    },
+    {
+        version: 3,
+        name: 'response_timings',
+        up: {
+            sqlite: ['ALTER TABLE responses ADD COLUMN timings TEXT'],
+        },
+        present: (db) => sqliteColumnExists(db, 'responses', 'timings'),
+    },
+    {
+        version: 4,
+        name: 'usage_records',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS usage_records (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant_id TEXT NOT NULL,
+  interaction_id TEXT NOT NULL,
+  model TEXT NOT NULL,
+  total_tokens INTEGER NOT NULL,
+  cost_usd REAL NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_usage_tenant_created ON usage_records(tenant_id, created_at)',
+            ],
+        },
+    },
+    {
+        version: 5,
+        name: 'tenants',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS tenants (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  api_keys TEXT NOT NULL,
+  routing TEXT,
+  disabled INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+)`,
+            ],
+        },
+    },
+    {
+        version: 6,
+        name: 'message_batches',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS message_batches (
+  id TEXT PRIMARY KEY,
+  provider_batch_id TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  key_id TEXT,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  request_count INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  results_recorded_at TEXT
+)`,
+            ],
+        },
+    },
+    {
+        version: 7,
+        name: 'metadata_index',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS metadata_index (
+  interaction_id TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  key TEXT NOT NULL,
+  value TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (interaction_id, key)
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_metadata_index_lookup ON metadata_index(key, value, tenant_id)',
+            ],
+        },
+    },
+    {
+        version: 8,
+        name: 'request_stats',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS request_stats (
+  interaction_id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  model TEXT NOT NULL,
+  prompt_tokens INTEGER NOT NULL,
+  completion_tokens INTEGER NOT NULL,
+  total_tokens INTEGER NOT NULL,
+  latency_ms INTEGER,
+  error INTEGER NOT NULL,
+  created_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_created ON request_stats(tenant_id, created_at)',
+            ],
+        },
+    },
+    {
+        version: 9,
+        name: 'usage_records_unique_interaction',
+        up: {
+            sqlite: [
+                'DELETE FROM usage_records WHERE id NOT IN (SELECT MIN(id) FROM usage_records GROUP BY interaction_id)',
+                'CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_interaction ON usage_records(interaction_id)',
+            ],
+        },
+    },
+    {
+        version: 10,
+        name: 'probe_results',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS probe_results (
+  id TEXT PRIMARY KEY,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  success INTEGER NOT NULL,
+  status_code INTEGER,
+  error TEXT,
+  latency_ms INTEGER NOT NULL,
+  usage TEXT,
+  cost_usd REAL,
+  created_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_probe_results_provider_created ON probe_results(provider, created_at)',
+            ],
+        },
+    },
+    {
+        version: 11,
+        name: 'interaction_attempts',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS interaction_attempts (
+  interaction_id TEXT NOT NULL,
+  attempt INTEGER NOT NULL,
+  tenant_id TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  prompt_tokens INTEGER,
+  completion_tokens INTEGER,
+  total_tokens INTEGER,
+  cost_usd REAL,
+  duration_ms INTEGER NOT NULL,
+  outcome TEXT NOT NULL,
+  error TEXT,
+  served INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (interaction_id, attempt)
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_interaction_attempts_tenant_created ON interaction_attempts(tenant_id, created_at)',
+            ],
+        },
+    },
+    {
+        version: 12,
+        name: 'thread_state_key_hash',
+        up: {
+            // Rows written before this version are hashed when the admin API next lists them
+            sqlite: [
+                'ALTER TABLE thread_state ADD COLUMN key_hash TEXT',
+                'CREATE INDEX IF NOT EXISTS idx_thread_state_key_hash ON thread_state(key_hash)',
+                'CREATE INDEX IF NOT EXISTS idx_thread_state_updated ON thread_state(updated_at)',
+            ],
+        },
+        present: (db) => sqliteColumnExists(db, 'thread_state', 'key_hash'),
+    },
+    {
+        version: 13,
+        name: 'thread_imports',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS thread_imports (
+  checksum TEXT PRIMARY KEY,
+  conversations INTEGER NOT NULL,
+  responses INTEGER NOT NULL,
+  thread_state INTEGER NOT NULL,
+  remapped TEXT NOT NULL,
+  imported_at TEXT NOT NULL
+)`,
+            ],
+        },
+    },
+    {
+        version: 14,
+        name: 'request_stats_end_user',
+        up: {
+            sqlite: [
+                'ALTER TABLE request_stats ADD COLUMN end_user TEXT',
+                'CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_end_user ON request_stats(tenant_id, end_user, created_at)',
+            ],
+        },
+        present: (db) => sqliteColumnExists(db, 'request_stats', 'end_user'),
+    },
+    {
+        version: 15,
+        name: 'interaction_attempts_upstream_error',
+        up: {
+            sqlite: [
+                'ALTER TABLE interaction_attempts ADD COLUMN status_code INTEGER',
+                'ALTER TABLE interaction_attempts ADD COLUMN upstream_error TEXT',
+            ],
+        },
+        present: (db) => sqliteColumnExists(db, 'interaction_attempts', 'upstream_error'),
+    },
+    {
+        version: 16,
+        name: 'request_stats_classification',
+        up: {
+            sqlite: [
+                'ALTER TABLE request_stats ADD COLUMN language TEXT',
+                'ALTER TABLE request_stats ADD COLUMN safety_flags TEXT',
+                'CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_language ON request_stats(tenant_id, language, created_at)',
+                'CREATE INDEX IF NOT EXISTS idx_request_stats_tenant_flagged ON request_stats(tenant_id, created_at) WHERE safety_flags IS NOT NULL',
+            ],
+        },
+        present: (db) => sqliteColumnExists(db, 'request_stats', 'safety_flags'),
+    },
+    {
+        version: 17,
+        name: 'slo_snapshots',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS slo_snapshots (
+  app TEXT PRIMARY KEY,
+  config_key TEXT NOT NULL,
+  minutes TEXT NOT NULL,
+  hours TEXT NOT NULL,
+  last_alert_at TEXT,
+  saved_at TEXT NOT NULL
+)`,
+            ],
+        },
+    },
+    {
+        version: 18,
+        name: 'tenant_secrets',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS tenant_secrets (
+  tenant_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  value TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (tenant_id, name)
+)`,
+            ],
+        },
+    },
+    {
+        version: 19,
+        name: 'interactions',
+        up: {
+            sqlite: [
+                `CREATE TABLE IF NOT EXISTS interactions (
+  id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  app_name TEXT,
+  frontdoor TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  stream INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL,
+  request TEXT,
+  status_code INTEGER,
+  error_type TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+)`,
+                'CREATE INDEX IF NOT EXISTS idx_interactions_status_created ON interactions(status, created_at)',
+                'CREATE INDEX IF NOT EXISTS idx_interactions_tenant_status ON interactions(tenant_id, status, created_at)',
+            ],
+        },
+    },
+    {
+        version: 20,
+        name: 'messages_content_hash',
+        up: {
+            sqlite: [
+                'ALTER TABLE messages ADD COLUMN content_hash TEXT',
+                'CREATE INDEX IF NOT EXISTS idx_messages_conversation_hash ON messages(conversation_id, content_hash)',
+            ],
+        },
+        present: (db) => sqliteColumnExists(db, 'messages', 'content_hash'),
+    },
+];
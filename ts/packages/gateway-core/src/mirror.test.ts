@@ -0,0 +1,133 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { RequestMirror, MIRROR_HEADER } from './mirror/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const body = JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] });
+
+function clientRequest(headers: Record<string, string> = {}) {
+    return new Request('http://gateway.local/openai/v1/chat/completions?trace=1', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', Authorization: 'Bearer client-key', ...headers },
+        body,
+    });
+}
+
+describe('RequestMirror', () => {
+    it('should re-POST the body with the mirror credential instead of the client one', async () => {
+        const fetch = vi.fn(async () => new Response('{}', { status: 200 }));
+        const mirror = new RequestMirror({ fetch, env: { STAGING_KEY: 'staging-key' } });
+
+        await mirror.mirror('app', {
+            url: 'https://staging-gw.internal/',
+            apiKey: '${env:STAGING_KEY}',
+            headers: { 'X-Mirror-Source': 'prod' },
+        }, clientRequest(), body);
+
+        const [url, init] = fetch.mock.calls[0] as any;
+        expect(url).toBe('https://staging-gw.internal/openai/v1/chat/completions?trace=1');
+        expect(init.method).toBe('POST');
+        expect(init.body).toBe(body);
+        expect(init.headers).toEqual({
+            'Content-Type': 'application/json',
+            'X-Mirror-Source': 'prod',
+            Authorization: 'Bearer staging-key',
+            [MIRROR_HEADER]: 'true',
+        });
+    });
+
+    it('should mirror only the sampled share of requests', async () => {
+        const fetch = vi.fn(async () => new Response(null, { status: 200 }));
+        const samples = [0.01, 0.5, 0.04];
+        const mirror = new RequestMirror({ fetch, random: () => samples.shift()! });
+        const config = { url: 'https://staging-gw.internal', sampleRate: 0.05 };
+
+        const sent = [0, 1, 2].map(() => mirror.mirror('app', config, clientRequest(), body));
+        await Promise.all(sent);
+
+        expect(sent.map((p) => p !== undefined)).toEqual([true, false, true]);
+        expect(fetch).toHaveBeenCalledTimes(2);
+    });
+
+    it('should count responses by status and failures without throwing', async () => {
+        const fetch = vi.fn()
+            .mockResolvedValueOnce(new Response(null, { status: 200 }))
+            .mockResolvedValueOnce(new Response('bad', { status: 502 }))
+            .mockRejectedValueOnce(new Error('connect ECONNREFUSED'));
+        const mirror = new RequestMirror({ fetch });
+        const config = { url: 'https://staging-gw.internal' };
+
+        for (let i = 0; i < 3; i++) {
+            await mirror.mirror('app', config, clientRequest(), body);
+        }
+
+        expect(mirror.stats()).toEqual([{
+            app: 'app',
+            sent: 3,
+            succeeded: 1,
+            failed: 2,
+            dropped: 0,
+            inFlight: 0,
+            statusCodes: { '200': 1, '502': 1, error: 1 },
+        }]);
+    });
+
+    it('should drop requests beyond the in-flight bound', async () => {
+        let release!: () => void;
+        const gate = new Promise<void>((resolve) => {
+            release = resolve;
+        });
+        const fetch = vi.fn(async () => {
+            await gate;
+            return new Response(null, { status: 200 });
+        });
+        const mirror = new RequestMirror({ fetch });
+        const config = { url: 'https://staging-gw.internal', maxInFlight: 1 };
+
+        const first = mirror.mirror('app', config, clientRequest(), body);
+        expect(mirror.mirror('app', config, clientRequest(), body)).toBeUndefined();
+        expect(mirror.stats()[0]).toMatchObject({ sent: 1, dropped: 1, inFlight: 1 });
+
+        release();
+        await first;
+        expect(mirror.stats()[0]).toMatchObject({ succeeded: 1, inFlight: 0 });
+    });
+
+    it('should not mirror requests that were themselves mirrored', () => {
+        const fetch = vi.fn();
+        const mirror = new RequestMirror({ fetch });
+
+        const sent = mirror.mirror('app', { url: 'https://staging-gw.internal' }, clientRequest({ [MIRROR_HEADER]: 'true' }), body);
+
+        expect(sent).toBeUndefined();
+        expect(fetch).not.toHaveBeenCalled();
+    });
+});
+
+describe('Gateway traffic mirroring', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        vi.unstubAllGlobals();
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    it('should mirror an idempotent request once, not its replays', async () => {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', mirror: { url: 'https://staging-gw.internal' } })
+            .provider(new ScriptedProvider('mock', { text: 'Hi' }))
+            .start();
+        const fetch = vi.fn(async () => new Response('{}'));
+        vi.stubGlobal('fetch', fetch);
+        const send = () => gateway!.post('/v1/chat/completions', JSON.parse(body), { 'Idempotency-Key': 'retry-1' });
+
+        const original = await send();
+        const replay = await send();
+
+        expect(original.status).toBe(200);
+        expect(replay.status).toBe(200);
+        expect(await replay.text()).toBe(await original.text());
+        expect(gateway.provider('mock').requests).toHaveLength(1);
+        await vi.waitFor(() => expect(fetch).toHaveBeenCalledTimes(1));
+    });
+});
@@ -0,0 +1,14 @@
+/**
+ * Request mirroring exports.
+ *
+ * @module mirror
+ */
+
+export {
+    RequestMirror,
+    MIRROR_HEADER,
+    DEFAULT_MIRROR_MAX_IN_FLIGHT,
+    DEFAULT_MIRROR_TIMEOUT_MS,
+    type MirrorStats,
+    type RequestMirrorOptions,
+} from './mirror.js';
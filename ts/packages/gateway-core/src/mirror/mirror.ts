@@ -0,0 +1,200 @@
+/**
+ * Request mirroring to another gateway deployment (e.g., staging).
+ *
+ * A sampled share of an app's requests is re-POSTed, body unchanged, to
+ * the app's mirror URL. Mirroring is fire-and-forget: the client path never
+ * waits on it, each app has a bounded number of mirrored requests in
+ * flight (beyond it requests are dropped and counted), and each mirrored
+ * request has a timeout. Only the response status is looked at.
+ *
+ * Client credentials are never forwarded; the mirror gets its own.
+ *
+ * @module mirror/mirror
+ */
+
+import type { MirrorConfig } from '../ports/config.js';
+import type { Logger } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+import { expandEnvRefs } from '../utils/headers.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Header marking a mirrored request, so the receiving gateway can tag it. */
+export const MIRROR_HEADER = 'X-Gateway-Mirror';
+
+/** Default mirrored requests in flight per app. */
+export const DEFAULT_MIRROR_MAX_IN_FLIGHT = 8;
+
+/** Default mirrored request timeout. */
+export const DEFAULT_MIRROR_TIMEOUT_MS = 10_000;
+
+/**
+ * Request mirror options.
+ */
+export interface RequestMirrorOptions {
+    /** Variables for ${env:VAR} references in the mirror credential and headers. */
+    env?: Record<string, string | undefined> | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Fetch implementation (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+
+    /** Sampling source in [0, 1), for tests. */
+    random?: (() => number) | undefined;
+}
+
+/**
+ * Mirror counters for one app, as reported by /admin/api/stats.
+ */
+export interface MirrorStats {
+    /** App name. */
+    app: string;
+
+    /** Mirrored requests sent. */
+    sent: number;
+
+    /** Mirrored requests answered with a 2xx. */
+    succeeded: number;
+
+    /** Mirrored requests answered with another status, or that errored or timed out. */
+    failed: number;
+
+    /** Sampled requests skipped because too many were in flight. */
+    dropped: number;
+
+    /** Mirrored requests awaiting a response. */
+    inFlight: number;
+
+    /** Responses by status code; "error" counts requests with no response. */
+    statusCodes: Record<string, number>;
+}
+
+// ============================================================================
+// Request Mirror
+// ============================================================================
+
+/**
+ * Mirrors sampled requests to each app's configured mirror.
+ */
+export class RequestMirror {
+    private readonly env: Record<string, string | undefined>;
+    private readonly logger?: Logger | undefined;
+    private readonly fetch: typeof fetch;
+    private readonly random: () => number;
+    private readonly apps = new Map<string, MirrorStats>();
+
+    constructor(options: RequestMirrorOptions = {}) {
+        this.env = options.env ?? {};
+        this.logger = options.logger;
+        this.fetch = options.fetch ?? ((input, init) => fetch(input, init));
+        this.random = options.random ?? Math.random;
+    }
+
+    /**
+     * Mirrors a request if it is sampled. Returns the in-flight mirror
+     * request (for tests and graceful shutdown); callers don't await it.
+     */
+    mirror(app: string, config: MirrorConfig, request: Request, body: string): Promise<void> | undefined {
+        // Never re-mirror mirrored traffic
+        if (request.headers.has(MIRROR_HEADER)) {
+            return undefined;
+        }
+        if (this.random() >= (config.sampleRate ?? 1)) {
+            return undefined;
+        }
+
+        const stats = this.statsFor(app);
+        if (stats.inFlight >= (config.maxInFlight ?? DEFAULT_MIRROR_MAX_IN_FLIGHT)) {
+            stats.dropped++;
+            return undefined;
+        }
+
+        const headers = this.headers(config, request);
+        if (!headers) {
+            stats.dropped++;
+            return undefined;
+        }
+
+        const url = new URL(request.url);
+        const target = config.url.replace(/\/+$/, '') + url.pathname + url.search;
+
+        stats.sent++;
+        stats.inFlight++;
+        return this.fetch(target, {
+            method: 'POST',
+            headers,
+            body,
+            signal: AbortSignal.timeout(parseDuration(config.timeout, DEFAULT_MIRROR_TIMEOUT_MS)),
+        }).then(
+            async (response) => {
+                await response.body?.cancel();
+                this.count(stats, String(response.status), response.ok);
+            },
+            (error: unknown) => {
+                this.count(stats, 'error', false);
+                this.logger?.debug('mirror_request_failed', {
+                    app,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            },
+        ).finally(() => {
+            stats.inFlight--;
+        });
+    }
+
+    /**
+     * Returns counters for every app that has mirrored a request.
+     */
+    stats(): MirrorStats[] {
+        return Array.from(this.apps.values(), (s) => ({ ...s, statusCodes: { ...s.statusCodes } }));
+    }
+
+    private statsFor(app: string): MirrorStats {
+        let stats = this.apps.get(app);
+        if (!stats) {
+            stats = { app, sent: 0, succeeded: 0, failed: 0, dropped: 0, inFlight: 0, statusCodes: {} };
+            this.apps.set(app, stats);
+        }
+        return stats;
+    }
+
+    private count(stats: MirrorStats, status: string, ok: boolean): void {
+        stats.statusCodes[status] = (stats.statusCodes[status] ?? 0) + 1;
+        if (ok) {
+            stats.succeeded++;
+        } else {
+            stats.failed++;
+        }
+    }
+
+    /**
+     * Builds the mirror's headers: the content type, the configured
+     * headers, the mirror credential, and the mirror marker. Returns
+     * undefined if a referenced env variable is unset.
+     */
+    private headers(config: MirrorConfig, request: Request): Record<string, string> | undefined {
+        const headers: Record<string, string> = {
+            'Content-Type': request.headers.get('content-type') ?? 'application/json',
+        };
+
+        const onUnset = (variable: string) => this.logger?.warn('mirror_env_unset', { variable });
+        for (const [name, template] of Object.entries(config.headers ?? {})) {
+            const value = expandEnvRefs(template, this.env, onUnset);
+            if (value === undefined) return undefined;
+            headers[name] = value;
+        }
+
+        if (config.apiKey) {
+            const key = expandEnvRefs(config.apiKey, this.env, onUnset);
+            if (key === undefined) return undefined;
+            headers['Authorization'] = `Bearer ${key}`;
+        }
+
+        headers[MIRROR_HEADER] = 'true';
+        return headers;
+    }
+}
@@ -0,0 +1,139 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { ModelListCache, withModelCache } from './providers/index';
+import { createProviderRegistry } from './ports/index';
+
+/** A logger that drops everything, children included. */
+function silentLogger() {
+    const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    logger.child.mockReturnValue(logger);
+    return logger;
+}
+
+const list = (...ids: string[]) => ({ object: 'list', data: ids.map((id) => ({ id })) });
+
+function deferred<T>() {
+    let resolve!: (value: T) => void;
+    let reject!: (error: unknown) => void;
+    const promise = new Promise<T>((res, rej) => { resolve = res; reject = rej; });
+    return { promise, resolve, reject };
+}
+
+describe('ModelListCache', () => {
+    it('should serve a stale list immediately and refresh it in the background', async () => {
+        let now = 0;
+        const cache = new ModelListCache({ now: () => now });
+        const refresh = deferred<ReturnType<typeof list>>();
+        const fetch = vi.fn()
+            .mockResolvedValueOnce(list('gpt-4o'))
+            .mockReturnValueOnce(refresh.promise);
+
+        await cache.get('openai', 1000, fetch);
+        now = 5000;
+
+        expect(await cache.get('openai', 1000, fetch)).toEqual(list('gpt-4o'));
+        expect(await cache.get('openai', 1000, fetch)).toEqual(list('gpt-4o'));
+        expect(fetch).toHaveBeenCalledTimes(2);
+
+        refresh.resolve(list('gpt-4o', 'gpt-5'));
+        await refresh.promise;
+        expect(await cache.get('openai', 1000, fetch)).toEqual(list('gpt-4o', 'gpt-5'));
+    });
+
+    it('should keep the stale list when a refresh fails', async () => {
+        let now = 0;
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+        const cache = new ModelListCache({ now: () => now, logger: logger as any });
+        const fetch = vi.fn()
+            .mockResolvedValueOnce(list('gpt-4o'))
+            .mockRejectedValueOnce(new Error('429 Too Many Requests'));
+
+        await cache.get('openai', 1000, fetch);
+        now = 2000;
+
+        expect(await cache.get('openai', 1000, fetch)).toEqual(list('gpt-4o'));
+        await vi.waitFor(() => expect(logger.warn).toHaveBeenCalledWith('model_list_refresh_failed', {
+            provider: 'openai',
+            error: '429 Too Many Requests',
+            ageMs: 2000,
+        }));
+        expect(cache.peek('openai')).toEqual(list('gpt-4o'));
+    });
+
+    it('should fail only when there is no cached copy, sharing one fetch between callers', async () => {
+        const cache = new ModelListCache();
+        const first = deferred<ReturnType<typeof list>>();
+        const fetch = vi.fn().mockReturnValueOnce(first.promise);
+
+        const calls = [cache.get('openai', 1000, fetch), cache.get('openai', 1000, fetch)];
+        first.reject(new Error('upstream down'));
+
+        await Promise.all(calls.map((call) => expect(call).rejects.toThrow('upstream down')));
+        expect(fetch).toHaveBeenCalledTimes(1);
+        expect(cache.peek('openai')).toBeUndefined();
+    });
+
+    it('should only cache providers that list models', () => {
+        const cache = new ModelListCache();
+        const provider = { name: 'p', apiType: 'openai' as const, complete: vi.fn(), stream: vi.fn() };
+
+        expect(withModelCache(provider, cache, 1000)).toBe(provider);
+    });
+});
+
+describe('Gateway model list caching', () => {
+    function setup() {
+        const provider = {
+            name: 'openai',
+            apiType: 'openai' as const,
+            complete: vi.fn(),
+            stream: vi.fn(),
+            listModels: vi.fn(async () => list('gpt-4o')),
+        };
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', () => provider);
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'default', frontdoor: 'openai', path: '/v1' }],
+                    providers: [{ name: 'openai', type: 'mock', apiKey: '' }],
+                    routing: { defaultProvider: 'openai' },
+                }),
+            },
+            auth: { authenticate: async () => ({ tenantId: 't', scopes: [], metadata: {} }), getTenant: async () => null },
+            providerRegistry,
+            logger: silentLogger() as any,
+        });
+        const send = (path: string, body?: unknown) => gateway.fetch(new Request(`http://localhost${path}`, {
+            method: body ? 'POST' : 'GET',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            ...(body !== undefined && { body: JSON.stringify(body) }),
+        }));
+        return { gateway, provider, send };
+    }
+
+    it('should serve /v1/models from the cache until the config is reloaded', async () => {
+        const { gateway, provider, send } = setup();
+        await gateway.reload();
+
+        expect((await send('/v1/models')).status).toBe(200);
+        expect(await (await send('/v1/models')).json()).toMatchObject({ data: [{ id: 'gpt-4o' }] });
+        expect(provider.listModels).toHaveBeenCalledTimes(1);
+
+        await gateway.reload();
+        await send('/v1/models');
+        expect(provider.listModels).toHaveBeenCalledTimes(2);
+    });
+
+    it('should answer an unknown model with a 404 model_not_found once lists are cached', async () => {
+        const { gateway, provider, send } = setup();
+        await gateway.reload();
+        await send('/v1/models');
+
+        const response = await send('/v1/chat/completions', { model: 'gpt-9', messages: [{ role: 'user', content: 'Hi' }] });
+
+        expect(response.status).toBe(404);
+        expect(((await response.json()) as any).error).toMatchObject({ code: 'model_not_found', param: 'model' });
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+});
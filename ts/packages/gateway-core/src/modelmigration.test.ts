@@ -0,0 +1,153 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { AdminHandler } from './admin/index';
+import { createProviderRegistry, type ThreadStateEntry } from './ports/index';
+import { sha256 } from './utils/crypto';
+
+function memoryThreadState() {
+    const states = new Map<string, ThreadStateEntry>();
+    return {
+        states,
+        setThreadState: async (threadKey: string, responseId: string) => {
+            states.set(threadKey, { threadKey, keyHash: await sha256(threadKey), responseId, updatedAt: new Date() });
+        },
+        getThreadState: async (threadKey: string) => states.get(threadKey)?.responseId ?? null,
+        listThreadState: async (options: { limit?: number; cursor?: string } = {}) => [...states.values()]
+            .filter((e) => !options.cursor || e.keyHash > options.cursor)
+            .sort((a, b) => a.keyHash.localeCompare(b.keyHash))
+            .slice(0, options.limit ?? 50),
+        findThreadState: async (keyHash: string) => [...states.values()].find((e) => e.keyHash === keyHash) ?? null,
+        deleteThreadState: async (threadKey: string) => states.delete(threadKey),
+        purgeThreadState: async () => 0,
+    };
+}
+
+function setup() {
+    const providers = Object.fromEntries(['legacy', 'primary'].map((name) => [name, {
+        name,
+        apiType: 'openai' as const,
+        complete: vi.fn(async (request: { model: string }) => ({
+            id: `chatcmpl-${name}`, object: 'chat.completion', created: 0, model: request.model, sourceAPIType: 'openai' as const,
+            choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content: name } }],
+            usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+        })),
+        stream: vi.fn(),
+    }]));
+    const providerRegistry = createProviderRegistry();
+    providerRegistry.register('mock', (config) => providers[config.name]! as any);
+    const storage = memoryThreadState();
+    const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    logger.child.mockReturnValue(logger);
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [{ name: 'chat', frontdoor: 'openai', path: '/v1' }],
+                providers: ['legacy', 'primary'].map((name) => ({
+                    name, type: 'mock', apiKey: `sk-${name}`, responsesThreadKeyPath: 'metadata.user_id',
+                })),
+                routing: {
+                    rules: [
+                        { modelPrefix: 'gpt-4-turbo', provider: 'legacy' },
+                        { modelPrefix: 'gpt', provider: 'primary' },
+                        { modelPrefix: 'gpt', provider: 'legacy' },
+                    ],
+                    defaultProvider: 'primary',
+                    affinity: { ttl: '1h' },
+                },
+            }),
+        },
+        auth: { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null },
+        storage: storage as any,
+        providerRegistry,
+        logger: logger as any,
+    });
+    const admin = new AdminHandler({
+        storage: storage as any,
+        modelMigrations: gateway.modelMigrations,
+        logger: logger as any,
+    });
+    const send = (userId: string) => gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+        method: 'POST',
+        headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+        body: JSON.stringify({ model: 'gpt-4-turbo', messages: [{ role: 'user', content: 'Hi' }], metadata: { user_id: userId } }),
+    }));
+    const call = (path: string, body?: unknown, handler = admin) => handler.handle(new Request(`http://localhost${path}`, {
+        method: body === undefined ? 'GET' : 'POST',
+        headers: { Authorization: 'Bearer k' },
+        ...(body !== undefined && { body: JSON.stringify(body) }),
+    }));
+    const migrate = async (mode: string) => {
+        const response = await call('/api/maintenance/migrate-model', { from_model: 'gpt-4-turbo', to_model: 'gpt-4o', mode });
+        expect(response.status).toBe(202);
+        const { id } = await response.json();
+        let job: any;
+        await vi.waitFor(async () => {
+            job = await (await call(`/api/maintenance/migrate-model/${id}`)).json();
+            expect(job.status).toBe('completed');
+        });
+        return job;
+    };
+    const servedBy = (name: string) => providers[name]!.complete.mock.calls.map(([request]) => request.model);
+    return { gateway, storage, logger, send, call, migrate, servedBy };
+}
+
+/** Waits for thread state written in the background. */
+const settled = () => new Promise((resolve) => setTimeout(resolve, 10));
+
+/** Stored mappings last served by a model. */
+const servedWith = (states: Map<string, ThreadStateEntry>, model: string) => [...states.values()]
+    .filter((entry) => JSON.parse(entry.responseId).model === model);
+
+describe('Model migration', () => {
+    it('should reroute new requests and leave in-progress threads pinned', async () => {
+        const { gateway, storage, send, migrate, servedBy } = setup();
+        await gateway.reload();
+        await send('u1');
+        await settled();
+
+        const job = await migrate('reroute_only');
+        await send('u1');
+        await send('u2');
+
+        expect(job).toMatchObject({ fromModel: 'gpt-4-turbo', toModel: 'gpt-4o', counts: { scanned: 0, pins: 0, threads: 0 } });
+        expect(servedBy('legacy')).toEqual(['gpt-4-turbo', 'gpt-4-turbo']);
+        expect(servedBy('primary')).toEqual(['gpt-4o']);
+        expect(gateway.modelMigrations.rules.get('gpt-4-turbo')).toBe('gpt-4o');
+    });
+
+    it('should reset threads last served by the old model so their next turn moves', async () => {
+        const { gateway, storage, logger, send, migrate, servedBy } = setup();
+        await gateway.reload();
+        await send('u1');
+        await settled();
+        const pinned = servedWith(storage.states, 'gpt-4-turbo').length;
+        await storage.setThreadState('affinity:acme:thread:u9', JSON.stringify({ provider: 'primary', updatedAt: Date.now(), model: 'gpt-4o' }));
+
+        const job = await migrate('reset_threads');
+        await send('u1');
+
+        expect(pinned).toBeGreaterThan(0);
+        expect(job.counts).toEqual({ scanned: pinned + 1, pins: pinned, threads: 0 });
+        expect(storage.states.has('affinity:acme:thread:u9')).toBe(true);
+        expect(servedWith(storage.states, 'gpt-4-turbo')).toEqual([]);
+        expect(servedBy('legacy')).toEqual(['gpt-4-turbo']);
+        expect(servedBy('primary')).toEqual(['gpt-4o']);
+        expect(logger.info).toHaveBeenCalledWith('model_migration_completed', expect.objectContaining({
+            audit: true, jobId: job.id, mode: 'reset_threads',
+        }));
+    });
+
+    it('should validate the request and allow only operators', async () => {
+        const { gateway, call } = setup();
+        const auth = { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null };
+        const scoped = new AdminHandler({ storage: {} as any, auth: auth as any, modelMigrations: gateway.modelMigrations });
+
+        expect((await call('/api/maintenance/migrate-model', { from_model: 'a', to_model: 'a' })).status).toBe(400);
+        expect((await call('/api/maintenance/migrate-model', { from_model: 'a', to_model: 'b', mode: 'drain' })).status).toBe(400);
+        expect((await call('/api/maintenance/migrate-model', { from_model: 'a' })).status).toBe(400);
+        expect((await call('/api/maintenance/migrate-model', { from_model: 'a', to_model: 'b' }, scoped)).status).toBe(403);
+        expect((await call('/api/maintenance/migrate-model', { from_model: 'a', to_model: 'b' }, new AdminHandler({ storage: {} as any }))).status).toBe(503);
+        expect(gateway.modelMigrations.rules.size).toBe(0);
+        expect((await call('/api/maintenance/migrate-model/migrate_missing')).status).toBe(404);
+    });
+});
@@ -0,0 +1,16 @@
+/**
+ * Model migration exports.
+ *
+ * @module modelmigration
+ */
+
+export {
+    ModelMigrationJobs,
+    MAX_MODEL_MIGRATION_JOBS,
+    type ModelMigrationMode,
+    type ModelMigrationJobStatus,
+    type ModelMigrationRequest,
+    type ModelMigrationCounts,
+    type ModelMigrationJob,
+    type ModelMigrationJobsOptions,
+} from './jobs.js';
@@ -0,0 +1,266 @@
+/**
+ * Model migrations: moving traffic off a deprecated model at runtime.
+ *
+ * A migration adds a rewrite from one model to another ahead of all other
+ * routing, so new requests for the old model are served by the new one.
+ * Threads already under way stay pinned to the provider and model that
+ * served their last turn, by thread affinity and Responses thread state.
+ * In reset_threads mode those mappings are cleared too, wherever the last
+ * turn was served by the old model, so each thread's next turn starts a
+ * fresh provider thread on the new model (its history is still replayed
+ * from stored responses). Rewrites live in memory until the next restart;
+ * the config's rewrites should be updated to keep them. Migrations run as
+ * background jobs the admin API can poll, and are logged for audit.
+ *
+ * @module modelmigration/jobs
+ */
+
+import type { ResponseStore, ThreadStateEntry, ThreadStateStore } from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+import { parseAffinityState, parseAffinityStateKey } from '../affinity/affinity.js';
+import type { Logger } from '../utils/logging.js';
+import { randomUUID } from '../utils/crypto.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Finished jobs kept for polling; the oldest are forgotten first. */
+export const MAX_MODEL_MIGRATION_JOBS = 100;
+
+/** Thread state mappings read per page while resetting threads. */
+const SCAN_PAGE_SIZE = 100;
+
+/** Thread key prefix of a response's affinity pin. */
+const RESPONSE_KEY_PREFIX = 'response:';
+
+/**
+ * What a migration does beyond adding the rewrite: nothing, or also
+ * clear the thread mappings last served by the old model.
+ */
+export type ModelMigrationMode = 'reroute_only' | 'reset_threads';
+
+/** Lifecycle state of a migration job. */
+export type ModelMigrationJobStatus = 'running' | 'completed' | 'failed';
+
+/**
+ * A requested migration.
+ */
+export interface ModelMigrationRequest {
+    /** Model being retired. */
+    fromModel: string;
+
+    /** Model serving its traffic from now on. */
+    toModel: string;
+
+    /** Whether in-flight threads are reset. */
+    mode: ModelMigrationMode;
+}
+
+/**
+ * Thread mappings a migration looked at and cleared.
+ */
+export interface ModelMigrationCounts {
+    /** Thread state mappings read. */
+    scanned: number;
+
+    /** Thread affinity pins cleared. */
+    pins: number;
+
+    /** Responses thread mappings cleared. */
+    threads: number;
+}
+
+/**
+ * A migration job, as reported by /admin/api/maintenance/migrate-model/{id}.
+ */
+export interface ModelMigrationJob extends ModelMigrationRequest {
+    /** Job ID. */
+    id: string;
+
+    /** Job state. */
+    status: ModelMigrationJobStatus;
+
+    /** Mappings scanned and cleared (once completed). */
+    counts?: ModelMigrationCounts | undefined;
+
+    /** Failure message (once failed). */
+    error?: string | undefined;
+
+    /** When the job was started. */
+    createdAt: Date;
+
+    /** When the job finished. */
+    completedAt?: Date | undefined;
+}
+
+/**
+ * Model migration options.
+ */
+export interface ModelMigrationJobsOptions {
+    /** Thread state to reset; deleting through it must also drop cached pins. */
+    threadState?: ThreadStateStore | undefined;
+
+    /** Stored responses, to tell which model served a mapping's last turn. */
+    responses?: Pick<ResponseStore, 'getResponse'> | undefined;
+
+    /** Drops in-memory thread pins last served by a model. */
+    forgetModel?: ((model: string) => void) | undefined;
+
+    /** Logger for the audit trail. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Model Migrations
+// ============================================================================
+
+/**
+ * Holds the runtime model rewrites and runs migrations in the background.
+ */
+export class ModelMigrationJobs {
+    private readonly options: ModelMigrationJobsOptions;
+    private readonly jobs = new Map<string, ModelMigrationJob>();
+    private readonly rewrites = new Map<string, string>();
+
+    constructor(options: ModelMigrationJobsOptions = {}) {
+        this.options = options;
+    }
+
+    /**
+     * Runtime rewrites, old model to new. Never chained: no target is
+     * also a source.
+     */
+    get rules(): ReadonlyMap<string, string> {
+        return this.rewrites;
+    }
+
+    /**
+     * Adds the migration's rewrite and returns its job without waiting
+     * for the thread reset.
+     */
+    start(request: ModelMigrationRequest): ModelMigrationJob {
+        this.addRewrite(request.fromModel, request.toModel);
+        const job: ModelMigrationJob = {
+            id: `migrate_${randomUUID().replace(/-/g, '')}`,
+            status: 'running',
+            ...request,
+            createdAt: new Date(),
+        };
+        this.jobs.set(job.id, job);
+        this.prune();
+
+        this.options.logger?.info('model_migration_started', { audit: true, ...summarize(job) });
+        void this.run(job);
+        return { ...job };
+    }
+
+    /**
+     * Gets a job by ID.
+     */
+    get(id: string): ModelMigrationJob | undefined {
+        const job = this.jobs.get(id);
+        return job && { ...job };
+    }
+
+    /**
+     * Points `from` at `to`. Rewrites into `from` follow it, and a
+     * rewrite out of `to` (e.g. the migration being rolled back) is
+     * dropped, so rewrites never chain or loop.
+     */
+    private addRewrite(from: string, to: string): void {
+        this.rewrites.delete(to);
+        for (const [source, target] of this.rewrites) {
+            if (target === from) {
+                this.rewrites.set(source, to);
+            }
+        }
+        this.rewrites.set(from, to);
+    }
+
+    private async run(job: ModelMigrationJob): Promise<void> {
+        try {
+            job.counts = job.mode === 'reset_threads'
+                ? await this.resetThreads(job.fromModel)
+                : { scanned: 0, pins: 0, threads: 0 };
+            job.status = 'completed';
+            this.options.logger?.info('model_migration_completed', { audit: true, ...summarize(job), counts: job.counts });
+        } catch (error) {
+            job.status = 'failed';
+            job.error = error instanceof Error ? error.message : String(error);
+            this.options.logger?.error('model_migration_failed', { audit: true, ...summarize(job), error: job.error });
+        }
+        job.completedAt = new Date();
+    }
+
+    /**
+     * Deletes every thread state mapping whose last turn was served by
+     * `model`. Pages by key hash, which deleting doesn't disturb.
+     */
+    private async resetThreads(model: string): Promise<ModelMigrationCounts> {
+        const counts: ModelMigrationCounts = { scanned: 0, pins: 0, threads: 0 };
+        this.options.forgetModel?.(model);
+        const store = this.options.threadState;
+        if (!store) {
+            return counts;
+        }
+
+        let cursor: string | undefined;
+        for (;;) {
+            const page = await store.listThreadState({ limit: SCAN_PAGE_SIZE, cursor });
+            for (const entry of page) {
+                counts.scanned++;
+                if (await this.servedModel(entry) !== model) {
+                    continue;
+                }
+                if (await store.deleteThreadState(entry.threadKey)) {
+                    if (parseAffinityState(entry.responseId)) {
+                        counts.pins++;
+                    } else {
+                        counts.threads++;
+                    }
+                }
+            }
+            if (page.length < SCAN_PAGE_SIZE) {
+                return counts;
+            }
+            cursor = page[page.length - 1]!.keyHash;
+        }
+    }
+
+    /**
+     * The model that served a mapping's last turn: recorded on affinity
+     * pins, or the stored response a mapping (or a response's pin
+     * written before pins recorded it) points at.
+     */
+    private async servedModel(entry: ThreadStateEntry): Promise<string | undefined> {
+        const pin = parseAffinityState(entry.responseId);
+        if (!pin) {
+            return (await this.options.responses?.getResponse(entry.responseId, UNSCOPED_TENANT))?.model;
+        }
+        if (pin.model !== undefined) {
+            return pin.model;
+        }
+        const key = parseAffinityStateKey(entry.threadKey);
+        if (!key?.threadKey.startsWith(RESPONSE_KEY_PREFIX)) {
+            return undefined;
+        }
+        const responseId = key.threadKey.slice(RESPONSE_KEY_PREFIX.length);
+        return (await this.options.responses?.getResponse(responseId, key.tenantId))?.model;
+    }
+
+    private prune(): void {
+        for (const [id, job] of this.jobs) {
+            if (this.jobs.size <= MAX_MODEL_MIGRATION_JOBS) {
+                return;
+            }
+            if (job.status !== 'running') {
+                this.jobs.delete(id);
+            }
+        }
+    }
+}
+
+function summarize(job: ModelMigrationJob): Record<string, string> {
+    return { jobId: job.id, fromModel: job.fromModel, toModel: job.toModel, mode: job.mode };
+}
@@ -0,0 +1,165 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openAIFrontdoor, anthropicFrontdoor, responsesFrontdoor } from './frontdoors/index';
+import { isModelAllowed, resolveRequestModel } from './frontdoors/models';
+import { validateResponsesRequest } from './codecs/validation';
+
+const usage = { promptTokens: 3, completionTokens: 2, totalTokens: 5 };
+
+function mockProvider() {
+    return {
+        name: 'mock',
+        apiType: 'openai',
+        complete: vi.fn(async (req: any) => ({
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'openai',
+            usage,
+            choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'Hi' } }],
+        })),
+        stream: vi.fn(),
+    };
+}
+
+function memoryStorage() {
+    const responses = new Map<string, any>();
+    return {
+        async saveResponse(record: any) {
+            responses.set(record.id, record);
+        },
+        async getResponse(id: string) {
+            return responses.get(id) ?? null;
+        },
+    } as any;
+}
+
+function handle(frontdoor: any, path: string, body: unknown, app: unknown, modelRewrite?: string) {
+    const provider = mockProvider();
+    const result = frontdoor.handle({
+        request: new Request(`http://localhost${path}`, { method: 'POST', body: JSON.stringify(body) }),
+        provider,
+        app,
+        modelRewrite,
+        storage: memoryStorage(),
+        auth: { tenantId: 't', scopes: [], metadata: {} },
+        interactionId: 'int-1',
+    } as any);
+    return { provider, result };
+}
+
+const app = (overrides: Record<string, unknown> = {}) => ({
+    name: 'app',
+    frontdoor: 'openai',
+    path: '/',
+    defaultModel: 'gpt-4o-mini',
+    allowedModels: ['gpt-4o-mini', 'claude-*'],
+    ...overrides,
+});
+
+describe('isModelAllowed', () => {
+    it('should match exact names and trailing-star prefixes', () => {
+        const allowed = ['gpt-4o', 'claude-*'];
+
+        expect(isModelAllowed('gpt-4o', allowed)).toBe(true);
+        expect(isModelAllowed('gpt-4o-mini', allowed)).toBe(false);
+        expect(isModelAllowed('claude-sonnet-4', allowed)).toBe(true);
+        expect(isModelAllowed('anything', undefined)).toBe(true);
+        expect(isModelAllowed('anything', [])).toBe(true);
+    });
+});
+
+describe('resolveRequestModel', () => {
+    it('should apply the default model only when none was sent', () => {
+        const request: { model?: string } = {};
+        const steps = resolveRequestModel(request, app(), undefined);
+
+        expect(request.model).toBe('gpt-4o-mini');
+        expect(steps).toEqual([expect.objectContaining({ stage: 'default_model', details: { model: 'gpt-4o-mini' } })]);
+
+        const explicit = { model: 'claude-haiku' };
+        expect(resolveRequestModel(explicit, app(), undefined)).toEqual([]);
+        expect(explicit.model).toBe('claude-haiku');
+    });
+
+    it('should rewrite after applying the default, and check the rewritten model', () => {
+        const request: { model?: string } = {};
+        const steps = resolveRequestModel(request, app(), 'claude-sonnet-4');
+
+        expect(request.model).toBe('claude-sonnet-4');
+        expect(steps.map((s) => s.stage)).toEqual(['default_model', 'model_rewrite']);
+        expect(steps[1]!.details).toEqual({ from: 'gpt-4o-mini', to: 'claude-sonnet-4' });
+
+        expect(() => resolveRequestModel({ model: 'claude-haiku' }, app(), 'gpt-4o'))
+            .toThrow("model 'claude-haiku' is not available for this app");
+    });
+
+    it('should require a model when there is no default', () => {
+        expect(() => resolveRequestModel({}, undefined, undefined)).toThrow('model: is required');
+        expect(() => resolveRequestModel({ model: '' }, app({ defaultModel: undefined }), undefined))
+            .toThrow('model: is required');
+    });
+});
+
+describe('frontdoor model enforcement', () => {
+    it('should apply and record the default model on chat completions', async () => {
+        const { provider, result } = handle(openAIFrontdoor, '/v1/chat/completions', {
+            messages: [{ role: 'user', content: 'Hi' }],
+        }, app());
+        const { response, transformations } = await result;
+
+        expect(response.status).toBe(200);
+        expect(provider.complete.mock.calls[0]![0].model).toBe('gpt-4o-mini');
+        expect(transformations).toEqual([expect.objectContaining({ stage: 'default_model' })]);
+    });
+
+    it('should reject a disallowed model without listing the allowed ones', async () => {
+        const { provider, result } = handle(anthropicFrontdoor, '/v1/messages', {
+            model: 'gpt-4', max_tokens: 10, messages: [{ role: 'user', content: 'Hi' }],
+        }, app());
+        const { response } = await result;
+        const body = await response.json() as any;
+
+        expect(response.status).toBe(400);
+        expect(body.error.message).toBe("model 'gpt-4' is not available for this app");
+        expect(JSON.stringify(body)).not.toContain('gpt-4o-mini');
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+
+    it('should not leak a disallowed rewrite target', async () => {
+        const { result } = handle(openAIFrontdoor, '/v1/chat/completions', {
+            model: 'gpt-4o-mini', messages: [{ role: 'user', content: 'Hi' }],
+        }, app(), 'internal-model-v2');
+        const { response } = await result;
+        const text = await response.text();
+
+        expect(response.status).toBe(400);
+        expect(text).toContain("model 'gpt-4o-mini' is not available");
+        expect(text).not.toContain('internal-model-v2');
+    });
+
+    it('should apply rewrites to legacy completions', async () => {
+        const { provider, result } = handle(openAIFrontdoor, '/v1/completions', {
+            model: 'gpt-4o-mini', prompt: 'Once',
+        }, app(), 'claude-haiku');
+        const { response, transformations } = await result;
+
+        expect(response.status).toBe(200);
+        expect(provider.complete.mock.calls[0]![0].model).toBe('claude-haiku');
+        expect(transformations?.map((s: any) => s.stage)).toEqual(['completions_prompt', 'model_rewrite']);
+    });
+
+    it('should apply the default model and allow-list to the Responses API', async () => {
+        expect(validateResponsesRequest({ input: 'Hi' })).toEqual([]);
+
+        const defaulted = handle(responsesFrontdoor, '/v1/responses', { input: 'Hi' }, app());
+        const { response, transformations } = await defaulted.result;
+        expect(response.status).toBe(200);
+        expect(((await response.json()) as any).model).toBe('gpt-4o-mini');
+        expect(transformations).toEqual([expect.objectContaining({ stage: 'default_model' })]);
+
+        const rejected = handle(responsesFrontdoor, '/v1/responses', { model: 'o3', input: 'Hi' }, app());
+        expect((await rejected.result).response.status).toBe(400);
+        expect(defaulted.provider.complete).toHaveBeenCalledTimes(1);
+        expect(rejected.provider.complete).not.toHaveBeenCalled();
+
+        const missing = handle(responsesFrontdoor, '/v1/responses', { input: 'Hi' }, undefined);
+        expect((await missing.result).response.status).toBe(400);
+    });
+});
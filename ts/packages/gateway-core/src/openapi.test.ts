@@ -0,0 +1,252 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { existsSync, readFileSync, writeFileSync } from 'node:fs';
+import { fileURLToPath } from 'node:url';
+import { AdminHandler, adminRouteKey, matchAdminRoute } from './admin/index';
+import {
+    ADMIN_OPERATIONS,
+    breakingChanges,
+    buildAdminDocument,
+    buildDataPlaneDocument,
+    missingOperations,
+    type OpenAPIDocument,
+} from './openapi/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+/** Committed contract; run with UPDATE_GOLDEN=1 to rewrite it after a deliberate change. */
+const SNAPSHOT = fileURLToPath(new URL('./__tests__/golden/openapi.json', import.meta.url));
+
+/** Both documents as one, the admin API's paths under its mount point. */
+function contract(): OpenAPIDocument {
+    const dataPlane = buildDataPlaneDocument();
+    const admin = buildAdminDocument();
+    const mount = admin.servers![0]!.url;
+    return {
+        openapi: dataPlane.openapi,
+        info: dataPlane.info,
+        paths: {
+            ...dataPlane.paths,
+            ...Object.fromEntries(Object.entries(admin.paths).map(([path, item]) => [`${mount}${path}`, item])),
+        },
+        components: {
+            schemas: { ...dataPlane.components.schemas, ...admin.components.schemas },
+            securitySchemes: { ...dataPlane.components.securitySchemes, ...admin.components.securitySchemes },
+        },
+    };
+}
+
+/** One line per path and per schema, so changes diff as the entries they touch. */
+function serialize(doc: OpenAPIDocument): string {
+    const entries = (record: object, indent: string) => Object.entries(record)
+        .map(([key, value]) => `${indent}${JSON.stringify(key)}: ${JSON.stringify(value)}`)
+        .join(',\n');
+    return [
+        '{',
+        `    "openapi": ${JSON.stringify(doc.openapi)},`,
+        `    "info": ${JSON.stringify(doc.info)},`,
+        '    "paths": {',
+        entries(doc.paths, ' '.repeat(8)),
+        '    },',
+        '    "components": {',
+        '        "schemas": {',
+        entries(doc.components.schemas, ' '.repeat(12)),
+        '        },',
+        `        "securitySchemes": ${JSON.stringify(doc.components.securitySchemes)}`,
+        '    }',
+        '}',
+        '',
+    ].join('\n');
+}
+
+/** A request path for an operation, with each parameter filled in. */
+function samplePath(path: string): string {
+    return path.replace(/:(\w+)/g, (_, name: string) => name === 'hash' ? '0'.repeat(64) : `sample-${name}`);
+}
+
+describe('OpenAPI documents', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic' })
+            .app({ name: 'command', frontdoor: 'cohere', path: '/cohere' })
+            .app({ name: 'agents', frontdoor: 'responses', path: '/responses' })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .start();
+        const admin = new AdminHandler({
+            storage: gateway.store as any,
+            routes: () => gateway!.gateway.routes(),
+        });
+        return { gw: gateway, admin };
+    }
+
+    it('should document every route the data plane registers', async () => {
+        const { gw } = await setup();
+        const routes = gw.gateway.routes();
+
+        expect(missingOperations(routes)).toEqual([]);
+
+        const doc = buildDataPlaneDocument(routes);
+        const documented = Object.entries(doc.paths)
+            .flatMap(([path, methods]) => Object.keys(methods).map((method) => `${method.toUpperCase()} ${path}`));
+        expect(documented.sort()).toEqual(routes
+            .map((r) => `${r.method} ${r.path.replace(/:(\w+)/g, '{$1}')}`)
+            .sort());
+    });
+
+    it('should route every admin operation to itself', () => {
+        for (const operation of ADMIN_OPERATIONS) {
+            const route = matchAdminRoute(operation.method, samplePath(operation.path));
+
+            expect(route?.operation, adminRouteKey(operation)).toBe(operation);
+        }
+        expect(matchAdminRoute('GET', '/api/tenants/acme%2Fwest/secrets/db')?.params).toEqual({ id: 'acme/west', name: 'db' });
+        expect(matchAdminRoute('POST', '/api/thread-state/purge')?.key).toBe('POST /api/thread-state/purge');
+        expect(matchAdminRoute('PUT', '/api/stats')).toBeUndefined();
+    });
+
+    it('should refuse a path parameter that is not valid percent-encoding with a 400', async () => {
+        const { admin } = await setup();
+
+        expect(() => matchAdminRoute('GET', '/api/tenants/%E0%A4%A/secrets/db')).toThrow(expect.objectContaining({ statusCode: 400 }));
+        const response = await admin.handle(new Request('http://localhost/api/tenants/%E0%A4%A/secrets/db'));
+
+        expect(response.status).toBe(400);
+        expect(await response.json()).toEqual({ error: 'Malformed percent-encoding in path' });
+    });
+
+    it('should answer every admin operation from its endpoint', async () => {
+        const { admin } = await setup();
+
+        for (const operation of ADMIN_OPERATIONS) {
+            const response = await admin.handle(new Request(`http://localhost${samplePath(operation.path)}`, {
+                method: operation.method,
+            }));
+            const body = response.headers.get('Content-Type')?.includes('json') ? await response.json() : {};
+
+            expect(body.error, adminRouteKey(operation)).not.toBe('Not Found');
+        }
+    });
+
+    it('should give every operation its auth requirement and error statuses', () => {
+        for (const doc of [buildDataPlaneDocument(), buildAdminDocument()]) {
+            for (const [path, methods] of Object.entries(doc.paths)) {
+                for (const [method, operation] of Object.entries(methods)) {
+                    const where = `${method} ${path}`;
+                    expect(operation['x-auth'], where).toBeDefined();
+                    const statuses = Object.keys(operation['responses'] as object);
+                    expect(statuses.some((s) => s.startsWith('2')), where).toBe(true);
+                    if (operation['x-auth'] !== 'public') {
+                        expect(statuses, where).toContain('401');
+                    }
+                }
+            }
+        }
+    });
+
+    it('should resolve every schema reference', () => {
+        for (const doc of [buildDataPlaneDocument(), buildAdminDocument()]) {
+            const refs = JSON.stringify(doc).matchAll(/"#\/components\/schemas\/(\w+)"/g);
+            for (const [, name] of refs) {
+                expect(doc.components.schemas, name).toHaveProperty(name!);
+            }
+        }
+    });
+
+    it('should make no breaking changes to the committed contract', () => {
+        const doc = contract();
+        if (process.env.UPDATE_GOLDEN === '1') {
+            writeFileSync(SNAPSHOT, serialize(doc));
+            return;
+        }
+        if (!existsSync(SNAPSHOT)) {
+            throw new Error('missing golden/openapi.json; run with UPDATE_GOLDEN=1 to write it');
+        }
+        const committed = JSON.parse(readFileSync(SNAPSHOT, 'utf8')) as OpenAPIDocument;
+        expect(breakingChanges(committed, doc)).toEqual([]);
+    });
+
+    it('should serve both documents from the admin API', async () => {
+        const { admin } = await setup();
+
+        const dataPlane = await admin.handle(new Request('http://localhost/api/openapi/data-plane.json'));
+        expect(dataPlane.status).toBe(200);
+        const doc = await dataPlane.json();
+        expect(doc.openapi).toBe('3.1.0');
+        expect(doc.paths['/anthropic/v1/messages'].post.tags).toEqual(['claude']);
+        expect(doc.paths['/cohere/v1/chat'].post.responses['200'].content).toHaveProperty('application/stream+json');
+        expect(doc.paths['/health'].get.security).toEqual([]);
+
+        const adminDoc = await (await admin.handle(new Request('http://localhost/api/openapi/admin.json'))).json();
+        expect(adminDoc.servers).toEqual([expect.objectContaining({ url: '/admin' })]);
+        expect(adminDoc.paths['/api/tenants/{id}/secrets/{name}'].put['x-auth']).toBe('own_tenant');
+    });
+});
+
+describe('breakingChanges', () => {
+    function doc(paths: OpenAPIDocument['paths'], schemas: OpenAPIDocument['components']['schemas'] = {}): OpenAPIDocument {
+        return { openapi: '3.1.0', info: { title: 'Test', version: '1' }, paths, components: { schemas, securitySchemes: {} } };
+    }
+
+    function json(schema: Record<string, unknown>) {
+        return { content: { 'application/json': { schema } } };
+    }
+
+    const thing = {
+        type: 'object',
+        properties: { id: { type: 'string' }, count: { type: 'integer' } },
+        required: ['id', 'count'],
+    };
+
+    it('should accept additions', () => {
+        const before = doc({ '/things': { get: { 'x-auth': 'api_key', responses: { 200: json({ $ref: '#/components/schemas/Thing' }) } } } }, { Thing: thing });
+        const after = doc({
+            '/things': {
+                get: { 'x-auth': 'api_key', responses: { 200: json({ $ref: '#/components/schemas/Thing' }), 404: { description: 'Not found.' } } },
+                post: { 'x-auth': 'api_key', responses: {} },
+            },
+        }, { Thing: { ...thing, properties: { ...thing.properties, name: { type: 'string' } } } });
+
+        expect(breakingChanges(before, after)).toEqual([]);
+    });
+
+    it('should report removed operations, statuses, and response properties', () => {
+        const before = doc({
+            '/things': { get: { responses: { 200: json(thing), 404: { description: 'Not found.' } } } },
+            '/other': { get: { responses: {} } },
+        });
+        const after = doc({
+            '/things': { get: { responses: { 200: json({ type: 'object', properties: { id: { type: 'number' } } }) } } },
+        });
+
+        expect(breakingChanges(before, after)).toEqual([
+            'GET /things 200: property id no longer required',
+            'GET /things 200.id: type changed from string to number',
+            'GET /things 200: property count removed',
+            'GET /things: 404 response removed',
+            'GET /other: removed',
+        ]);
+    });
+
+    it('should report newly required request properties and stricter auth', () => {
+        const before = doc({ '/things': { post: { 'x-auth': 'tenant', requestBody: json({ type: 'object', properties: {} }), responses: {} } } });
+        const after = doc({ '/things': { post: { 'x-auth': 'operator', requestBody: json({ type: 'object', required: ['name'] }), responses: {} } } });
+
+        expect(breakingChanges(before, after)).toEqual([
+            'POST /things: auth changed from tenant to operator',
+            'POST /things request: property name now required',
+        ]);
+    });
+
+    it('should terminate on recursive schemas', () => {
+        const node = { type: 'object', properties: { children: { type: 'array', items: { $ref: '#/components/schemas/Node' } } } };
+        const before = doc({ '/tree': { get: { responses: { 200: json({ $ref: '#/components/schemas/Node' }) } } } }, { Node: node });
+
+        expect(breakingChanges(before, before)).toEqual([]);
+    });
+});
@@ -0,0 +1,180 @@
+/**
+ * Breaking change detection between two OpenAPI documents.
+ *
+ * A change breaks clients built against the earlier document when it
+ * removes an operation or a status code, makes an operation's auth
+ * stricter, removes or retypes a response property (or makes a required
+ * one optional), or requires a request property the earlier document did
+ * not. Additions are not breaking: objects are open, so clients ignore
+ * properties they don't know.
+ *
+ * @module openapi/compat
+ */
+
+import type { JSONSchema } from '../domain/schema.js';
+import type { OpenAPIDocument } from './document.js';
+import type { OperationAuth } from './operations.js';
+
+/** How much each auth requirement asks of a caller. */
+const AUTH_RANK: Record<OperationAuth, number> = {
+    public: 0,
+    api_key: 1,
+    tenant: 1,
+    own_tenant: 2,
+    operator: 3,
+};
+
+/**
+ * Returns the changes from `before` to `after` that would break existing
+ * clients, one description each; empty when compatible.
+ */
+export function breakingChanges(before: OpenAPIDocument, after: OpenAPIDocument): string[] {
+    const changes: string[] = [];
+    const compare = new SchemaComparison(before, after, changes);
+
+    for (const [path, methods] of Object.entries(before.paths)) {
+        for (const [method, operation] of Object.entries(methods)) {
+            const where = `${method.toUpperCase()} ${path}`;
+            const next = after.paths[path]?.[method];
+            if (!next) {
+                changes.push(`${where}: removed`);
+                continue;
+            }
+
+            const auth = operation['x-auth'] as OperationAuth | undefined;
+            const nextAuth = next['x-auth'] as OperationAuth | undefined;
+            if (auth && nextAuth && AUTH_RANK[nextAuth] > AUTH_RANK[auth]) {
+                changes.push(`${where}: auth changed from ${auth} to ${nextAuth}`);
+            }
+
+            const requestSchema = bodySchema(operation['requestBody']);
+            const nextRequestSchema = bodySchema(next['requestBody']);
+            if (nextRequestSchema && !requestSchema) {
+                changes.push(`${where}: request body added`);
+            } else if (requestSchema && nextRequestSchema) {
+                compare.request(`${where} request`, requestSchema, nextRequestSchema);
+            }
+
+            const responses = (operation['responses'] ?? {}) as Record<string, JSONSchema>;
+            const nextResponses = (next['responses'] ?? {}) as Record<string, JSONSchema>;
+            for (const [status, response] of Object.entries(responses)) {
+                const nextResponse = nextResponses[status];
+                if (!nextResponse) {
+                    changes.push(`${where}: ${status} response removed`);
+                    continue;
+                }
+                const schema = bodySchema(response);
+                const nextSchema = bodySchema(nextResponse);
+                if (schema && nextSchema) {
+                    compare.response(`${where} ${status}`, schema, nextSchema);
+                }
+            }
+        }
+    }
+    return changes;
+}
+
+/**
+ * Compares schemas property by property, following each document's
+ * references. Each pair of referenced schemas is compared once per
+ * direction, so recursive schemas terminate.
+ */
+class SchemaComparison {
+    private readonly seen = new Set<string>();
+
+    constructor(
+        private readonly before: OpenAPIDocument,
+        private readonly after: OpenAPIDocument,
+        private readonly changes: string[],
+    ) {}
+
+    /** Clients send `before`'s shape; `after` must still accept it. */
+    request(where: string, before: JSONSchema, after: JSONSchema): void {
+        this.compare(where, before, after, 'request');
+    }
+
+    /** Clients read `before`'s shape; `after` must still provide it. */
+    response(where: string, before: JSONSchema, after: JSONSchema): void {
+        this.compare(where, before, after, 'response');
+    }
+
+    private compare(where: string, before: JSONSchema, after: JSONSchema, direction: 'request' | 'response'): void {
+        const key = `${direction}:${before['$ref'] ?? ''}:${after['$ref'] ?? ''}`;
+        if (before['$ref'] !== undefined && after['$ref'] !== undefined) {
+            if (this.seen.has(key)) return;
+            this.seen.add(key);
+        }
+        before = resolve(this.before, before);
+        after = resolve(this.after, after);
+
+        const types = typeSet(before);
+        const nextTypes = typeSet(after);
+        if (types && nextTypes) {
+            // Responses may not start sending new types; requests may not stop accepting old ones
+            const narrowed = direction === 'response'
+                ? [...nextTypes].some((t) => !types.has(t))
+                : [...types].some((t) => !nextTypes.has(t));
+            if (narrowed) {
+                this.changes.push(`${where}: type changed from ${[...types].join('|')} to ${[...nextTypes].join('|')}`);
+                return;
+            }
+        }
+
+        const properties = (before['properties'] ?? {}) as Record<string, JSONSchema>;
+        const nextProperties = (after['properties'] ?? {}) as Record<string, JSONSchema>;
+        const required = new Set((before['required'] ?? []) as string[]);
+        const nextRequired = new Set((after['required'] ?? []) as string[]);
+
+        if (direction === 'response') {
+            for (const [name, schema] of Object.entries(properties)) {
+                const nextSchema = nextProperties[name];
+                if (!nextSchema) {
+                    this.changes.push(`${where}: property ${name} removed`);
+                    continue;
+                }
+                if (required.has(name) && !nextRequired.has(name)) {
+                    this.changes.push(`${where}: property ${name} no longer required`);
+                }
+                this.compare(`${where}.${name}`, schema, nextSchema, direction);
+            }
+        } else {
+            for (const name of nextRequired) {
+                if (!required.has(name)) {
+                    this.changes.push(`${where}: property ${name} now required`);
+                }
+            }
+            for (const [name, schema] of Object.entries(properties)) {
+                const nextSchema = nextProperties[name];
+                if (nextSchema) {
+                    this.compare(`${where}.${name}`, schema, nextSchema, direction);
+                }
+            }
+        }
+
+        const items = before['items'] as JSONSchema | undefined;
+        const nextItems = after['items'] as JSONSchema | undefined;
+        if (items && nextItems) {
+            this.compare(`${where}[]`, items, nextItems, direction);
+        }
+    }
+}
+
+/** The JSON body schema of a request body or response, if it has one. */
+function bodySchema(body: unknown): JSONSchema | undefined {
+    const content = (body as JSONSchema | undefined)?.['content'] as Record<string, JSONSchema> | undefined;
+    return content?.['application/json']?.['schema'] as JSONSchema | undefined;
+}
+
+/** Follows a component reference; anything else is returned as is. */
+function resolve(doc: OpenAPIDocument, schema: JSONSchema): JSONSchema {
+    const $ref = schema['$ref'];
+    if (typeof $ref !== 'string') return schema;
+    return doc.components.schemas[$ref.slice($ref.lastIndexOf('/') + 1)] ?? schema;
+}
+
+function typeSet(schema: JSONSchema): Set<string> | undefined {
+    const type = schema['type'];
+    if (typeof type === 'string') return new Set([type]);
+    if (Array.isArray(type)) return new Set(type as string[]);
+    return undefined;
+}
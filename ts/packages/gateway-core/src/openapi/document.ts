@@ -0,0 +1,254 @@
+/**
+ * OpenAPI documents for the gateway's own HTTP surface.
+ *
+ * The data plane document lists every route the gateway serves, as the
+ * route table registered them when given the table (so app base paths are
+ * the deployment's own), or each operation's declared path otherwise. The
+ * admin document lists the admin API under its mount point. Both include
+ * only the component schemas their operations reach.
+ *
+ * @module openapi/document
+ */
+
+import type { JSONSchema } from '../domain/schema.js';
+import type { RegisteredRoute } from '../routes/table.js';
+import { ADMIN_OPERATIONS, DATA_PLANE_OPERATIONS, type OpenAPIOperation } from './operations.js';
+import { OPENAPI_SCHEMAS, ref } from './schemas.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * An OpenAPI 3.1 document.
+ */
+export interface OpenAPIDocument {
+    openapi: string;
+    info: { title: string; version: string; description?: string };
+    servers?: Array<{ url: string; description?: string }>;
+
+    /** Operations by path, then lowercase method. */
+    paths: Record<string, Record<string, JSONSchema>>;
+
+    components: {
+        schemas: Record<string, JSONSchema>;
+        securitySchemes: Record<string, JSONSchema>;
+    };
+}
+
+/** OpenAPI version the documents follow. */
+export const OPENAPI_VERSION = '3.1.0';
+
+/** Version of the documented API, bumped on breaking changes. */
+export const API_VERSION = '1';
+
+/** Path the admin API is mounted under. */
+const ADMIN_SERVER = '/admin';
+
+/** Media type of bodies unless an operation says otherwise. */
+const JSON_CONTENT_TYPE = 'application/json';
+
+// ============================================================================
+// Documents
+// ============================================================================
+
+/**
+ * The data plane document. Given the route table's routes, documents each
+ * under the path it is served at; routes with no operation are left out
+ * (see {@link missingOperations}).
+ */
+export function buildDataPlaneDocument(routes?: readonly RegisteredRoute[]): OpenAPIDocument {
+    const entries = routes
+        ? routes.flatMap((route) => {
+            const operation = findOperation(route);
+            return operation ? [{ path: route.path, app: route.app, operation }] : [];
+        })
+        : DATA_PLANE_OPERATIONS.map((operation) => ({ path: operation.path, app: undefined, operation }));
+
+    const paths: OpenAPIDocument['paths'] = {};
+    for (const { path, app, operation } of entries) {
+        const item = paths[openAPIPath(path)] ??= {};
+        item[operation.method.toLowerCase()] = {
+            ...describeOperation(operation, dataPlaneErrorSchema(operation.frontdoor)),
+            tags: [app ?? operation.frontdoor ?? 'gateway'],
+        };
+    }
+
+    return document(paths, {
+        title: 'Polyglot LLM Gateway',
+        description: 'The frontdoor APIs the gateway serves under each app, and its own endpoints.',
+    }, {
+        apiKey: {
+            type: 'http',
+            scheme: 'bearer',
+            description: 'A gateway API key, as Authorization: Bearer <key>.',
+        },
+    });
+}
+
+/**
+ * The admin API document.
+ */
+export function buildAdminDocument(): OpenAPIDocument {
+    const paths: OpenAPIDocument['paths'] = {};
+    for (const operation of ADMIN_OPERATIONS) {
+        const item = paths[openAPIPath(operation.path)] ??= {};
+        item[operation.method.toLowerCase()] = describeOperation(operation, () => ref('AdminError'));
+    }
+
+    const doc = document(paths, {
+        title: 'Polyglot LLM Gateway Admin API',
+        description: 'The control plane API. Tokens with an operator scope see all tenants; other tokens only see their own tenant\'s data.',
+    }, {
+        adminToken: {
+            type: 'http',
+            scheme: 'bearer',
+            description: 'An admin token, as Authorization: Bearer <token>. Required on every endpoint but /api/health when the admin API has an auth provider.',
+        },
+    });
+    doc.servers = [{ url: ADMIN_SERVER, description: 'Mounted on the data plane listener, or served from its own.' }];
+    return doc;
+}
+
+/**
+ * Registered routes the data plane document has no operation for.
+ */
+export function missingOperations(routes: readonly RegisteredRoute[]): RegisteredRoute[] {
+    return routes.filter((route) => !findOperation(route));
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * The operation a registered route serves: the longest declared path it
+ * ends with, among the operations of its frontdoor (or the gateway's own,
+ * for routes outside any frontdoor or served under every app).
+ */
+function findOperation(route: RegisteredRoute): OpenAPIOperation | undefined {
+    let found: OpenAPIOperation | undefined;
+    for (const operation of DATA_PLANE_OPERATIONS) {
+        if (operation.method !== route.method || !route.path.endsWith(operation.path)) {
+            continue;
+        }
+        const served = operation.perApp
+            ? route.app !== undefined
+            : operation.frontdoor === route.frontdoor && (operation.frontdoor !== undefined || route.app === undefined);
+        if (!served) {
+            continue;
+        }
+        if (!found || operation.path.length > found.path.length) {
+            found = operation;
+        }
+    }
+    return found;
+}
+
+/**
+ * Errors on the data plane are in the frontdoor's format, except those the
+ * gateway answers before a frontdoor sees the request (authentication and
+ * tenant limits), which are always in OpenAI's.
+ */
+function dataPlaneErrorSchema(frontdoor: string | undefined): (status: number) => JSONSchema {
+    const own = frontdoor === 'anthropic' ? 'AnthropicError' : frontdoor === 'cohere' ? 'CohereError' : undefined;
+    return (status) => {
+        if (!own || status === 401) {
+            return ref('OpenAIError');
+        }
+        return { oneOf: [ref(own), ref('OpenAIError')] };
+    };
+}
+
+/**
+ * An operation object. Error statuses without a schema of their own get
+ * `errorSchema`'s.
+ */
+function describeOperation(operation: OpenAPIOperation, errorSchema: (status: number) => JSONSchema): JSONSchema {
+    const parameters: JSONSchema[] = [];
+    for (const name of pathParameters(operation.path)) {
+        parameters.push({ name, in: 'path', required: true, schema: { type: 'string' } });
+    }
+    for (const [name, description] of Object.entries(operation.query ?? {})) {
+        parameters.push({ name, in: 'query', required: false, description, schema: { type: 'string' } });
+    }
+    for (const [name, description] of Object.entries(operation.headers ?? {})) {
+        parameters.push({ name, in: 'header', required: false, description, schema: { type: 'string' } });
+    }
+
+    const responses: Record<string, JSONSchema> = {};
+    for (const [code, response] of Object.entries(operation.responses)) {
+        const status = Number(code);
+        const schema = response.schema ? ref(response.schema) : status >= 400 ? errorSchema(status) : undefined;
+        const content: Record<string, JSONSchema> = {
+            [response.contentType ?? JSON_CONTENT_TYPE]: schema ? { schema } : {},
+        };
+        if (response.streamContentType) {
+            content[response.streamContentType] = {};
+        }
+        responses[code] = { description: response.description, content };
+    }
+
+    const described: JSONSchema = {
+        summary: operation.summary,
+        'x-auth': operation.auth,
+        security: operation.auth === 'public' ? [] : [{ [operation.auth === 'api_key' ? 'apiKey' : 'adminToken']: [] }],
+    };
+    if (parameters.length > 0) {
+        described['parameters'] = parameters;
+    }
+    if (operation.requestBody) {
+        described['requestBody'] = {
+            required: true,
+            content: { [JSON_CONTENT_TYPE]: { schema: ref(operation.requestBody) } },
+        };
+    }
+    described['responses'] = responses;
+    return described;
+}
+
+function document(
+    paths: OpenAPIDocument['paths'],
+    info: { title: string; description: string },
+    securitySchemes: Record<string, JSONSchema>,
+): OpenAPIDocument {
+    return {
+        openapi: OPENAPI_VERSION,
+        info: { title: info.title, version: API_VERSION, description: info.description },
+        paths,
+        components: { schemas: reachableSchemas(paths), securitySchemes },
+    };
+}
+
+/** The component schemas referenced from paths, directly or through other schemas. */
+function reachableSchemas(paths: OpenAPIDocument['paths']): Record<string, JSONSchema> {
+    const names = new Set<string>();
+    const pending: unknown[] = [paths];
+    while (pending.length > 0) {
+        const value = pending.pop();
+        if (Array.isArray(value)) {
+            pending.push(...value);
+        } else if (value && typeof value === 'object') {
+            const $ref = (value as JSONSchema)['$ref'];
+            if (typeof $ref === 'string') {
+                const name = $ref.slice($ref.lastIndexOf('/') + 1);
+                const schema = OPENAPI_SCHEMAS[name];
+                if (!names.has(name) && schema) {
+                    names.add(name);
+                    pending.push(schema);
+                }
+            }
+            pending.push(...Object.values(value));
+        }
+    }
+    return Object.fromEntries([...names].sort().map((name) => [name, OPENAPI_SCHEMAS[name]!]));
+}
+
+/** `:name` segments as OpenAPI `{name}` templates. */
+function openAPIPath(path: string): string {
+    return path.replace(/:([A-Za-z_][A-Za-z0-9_]*)/g, '{$1}');
+}
+
+function pathParameters(path: string): string[] {
+    return [...path.matchAll(/:([A-Za-z_][A-Za-z0-9_]*)/g)].map((match) => match[1]!);
+}
@@ -0,0 +1,26 @@
+/**
+ * OpenAPI exports.
+ *
+ * @module openapi
+ */
+
+export {
+    buildDataPlaneDocument,
+    buildAdminDocument,
+    missingOperations,
+    OPENAPI_VERSION,
+    API_VERSION,
+    type OpenAPIDocument,
+} from './document.js';
+
+export {
+    DATA_PLANE_OPERATIONS,
+    ADMIN_OPERATIONS,
+    type OpenAPIOperation,
+    type OperationAuth,
+    type OperationResponse,
+} from './operations.js';
+
+export { OPENAPI_SCHEMAS } from './schemas.js';
+
+export { breakingChanges } from './compat.js';
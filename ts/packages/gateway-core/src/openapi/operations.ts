@@ -0,0 +1,549 @@
+/**
+ * OpenAPI operation registry.
+ *
+ * Every method and path the gateway serves, with its auth requirement,
+ * request and response bodies, and the status codes it answers with.
+ * Data plane operations are keyed by the path their frontdoor declares
+ * (or the gateway's own path), so they match the route table's routes
+ * under any app base path; admin operations by their /api path, which is
+ * also how the admin handler routes requests to its endpoints.
+ *
+ * @module openapi/operations
+ */
+
+import { USAGE_REPORT_PATH } from '../usage/report.js';
+import { TOKEN_COUNT_SUFFIX } from '../tokens/count.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Who may call an operation.
+ *
+ * - public: anyone, without credentials
+ * - api_key: a data plane API key (Authorization: Bearer)
+ * - operator: an admin token with an operator scope; tenant tokens get 403
+ * - tenant: any admin token; tenant tokens only see their own tenant's data
+ * - own_tenant: an operator token, or a tenant token for the tenant in the
+ *   path; other tenants' are not found (404)
+ */
+export type OperationAuth = 'public' | 'api_key' | 'operator' | 'tenant' | 'own_tenant';
+
+/**
+ * One status code an operation answers with.
+ */
+export interface OperationResponse {
+    description: string;
+
+    /** Component schema of the body. Error statuses get their API's error schema when unset. */
+    schema?: string | undefined;
+
+    /** Body media type (default: application/json). */
+    contentType?: string | undefined;
+
+    /** Media type sent instead when the request asks to stream. */
+    streamContentType?: string | undefined;
+}
+
+/**
+ * A method and path, as documented.
+ */
+export interface OpenAPIOperation {
+    method: string;
+
+    /** Path; `:name` segments are parameters. */
+    path: string;
+
+    /** Frontdoor that serves it; unset for the gateway's own endpoints and the admin API. */
+    frontdoor?: string | undefined;
+
+    /** Whether the gateway serves it under every app (as with token counts). */
+    perApp?: boolean | undefined;
+
+    summary: string;
+    auth: OperationAuth;
+
+    /** Query parameters, by name, with their descriptions. */
+    query?: Record<string, string> | undefined;
+
+    /** Request headers read, by name, with their descriptions. */
+    headers?: Record<string, string> | undefined;
+
+    /** Component schema of the request body. */
+    requestBody?: string | undefined;
+
+    /** Responses by status code. */
+    responses: Record<number, OperationResponse>;
+}
+
+// ============================================================================
+// Status Codes
+// ============================================================================
+
+const STATUS: Record<number, string> = {
+    400: 'Invalid request.',
+    401: 'Missing or invalid credentials.',
+    403: 'Not allowed for these credentials.',
+    404: 'Not found.',
+    413: 'Request body too large.',
+    429: 'Rate, concurrency, or budget limit reached.',
+    500: 'Internal error.',
+    501: 'Not available on this gateway.',
+    502: 'Provider error, or output that failed validation.',
+    503: 'Provider overloaded, or a dependency unavailable.',
+    504: 'Provider or request deadline timed out.',
+};
+
+/** Error responses with their default descriptions, optionally overridden. */
+function errors(...codes: Array<number | readonly [number, string]>): Record<number, OperationResponse> {
+    return Object.fromEntries(codes.map((code) => typeof code === 'number'
+        ? [code, { description: STATUS[code]! }]
+        : [code[0], { description: code[1] }]));
+}
+
+function ok(schema: string, description = 'OK.', options: Partial<OperationResponse> = {}): Record<number, OperationResponse> {
+    return { 200: { description, schema, ...options } };
+}
+
+/** Statuses of an operation that calls a provider. */
+const PROVIDER_ERRORS = [400, 401, 403, 404, 413, 429, 500, 502, 503, 504] as const;
+
+// ============================================================================
+// Data Plane
+// ============================================================================
+
+/**
+ * Operations the data plane serves: the gateway's own endpoints, those
+ * served under every app, and each frontdoor's declared routes.
+ */
+export const DATA_PLANE_OPERATIONS: readonly OpenAPIOperation[] = [
+    // Gateway
+    {
+        method: 'GET', path: '/health', summary: 'Liveness', auth: 'public',
+        responses: ok('Health'),
+    },
+    {
+        method: 'GET', path: '/healthz', summary: 'Liveness', auth: 'public',
+        responses: ok('Health'),
+    },
+    {
+        method: 'GET', path: '/readyz', summary: 'Readiness, with storage health', auth: 'public',
+        responses: ok('Readiness'),
+    },
+    {
+        method: 'GET', path: USAGE_REPORT_PATH, summary: 'The key\'s tenant\'s usage by model and day', auth: 'api_key',
+        query: {
+            start_date: 'First day (YYYY-MM-DD).',
+            end_date: 'Last day (YYYY-MM-DD; default: today).',
+            group_by: 'model, reason, end_user, or language.',
+        },
+        responses: { ...ok('UsageReport'), ...errors(400, 401, [429, 'Too many usage report requests.'], 500) },
+    },
+    {
+        method: 'POST', path: `/v1${TOKEN_COUNT_SUFFIX}`, perApp: true, summary: 'Count a request\'s input tokens', auth: 'api_key',
+        requestBody: 'TokenCountRequest',
+        responses: { ...ok('TokenCount'), ...errors(400, 401, 413) },
+    },
+
+    // OpenAI
+    {
+        method: 'POST', path: '/v1/chat/completions', frontdoor: 'openai', summary: 'Create a chat completion', auth: 'api_key',
+        requestBody: 'ChatCompletionRequest',
+        responses: { ...ok('ChatCompletion', 'The completion, or a stream of chunks.', { streamContentType: 'text/event-stream' }), ...errors(...PROVIDER_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/v1/chat/completions/:id', frontdoor: 'openai', summary: 'Get a stored chat completion', auth: 'api_key',
+        responses: { ...ok('ChatCompletion'), ...errors(401, 404) },
+    },
+    {
+        method: 'POST', path: '/v1/completions', frontdoor: 'openai', summary: 'Create a legacy completion', auth: 'api_key',
+        requestBody: 'CompletionRequest',
+        responses: { ...ok('Completion', 'The completion, or a stream of chunks.', { streamContentType: 'text/event-stream' }), ...errors(...PROVIDER_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/v1/models', frontdoor: 'openai', summary: 'List models', auth: 'api_key',
+        responses: { ...ok('ModelList'), ...errors(401, 500, 502) },
+    },
+    {
+        method: 'GET', path: '/v1/models/:model', frontdoor: 'openai', summary: 'Get a model', auth: 'api_key',
+        responses: { ...ok('Model'), ...errors(401, 404, 500, 502) },
+    },
+
+    // Anthropic
+    {
+        method: 'POST', path: '/v1/messages', frontdoor: 'anthropic', summary: 'Create a message', auth: 'api_key',
+        requestBody: 'MessagesRequest',
+        responses: { ...ok('MessagesResponse', 'The message, or a stream of events.', { streamContentType: 'text/event-stream' }), ...errors(...PROVIDER_ERRORS) },
+    },
+    {
+        method: 'POST', path: '/v1/messages/batches', frontdoor: 'anthropic', summary: 'Create a message batch', auth: 'api_key',
+        requestBody: 'MessageBatchRequest',
+        responses: {
+            ...ok('MessageBatch'),
+            ...errors(400, 401, 404, 413, 429, 500, [501, 'Batches unavailable, or the requests route to a non-Anthropic provider.'], 502, 503),
+        },
+    },
+    {
+        method: 'GET', path: '/v1/messages/batches/:id', frontdoor: 'anthropic', summary: 'Get a message batch', auth: 'api_key',
+        responses: { ...ok('MessageBatch'), ...errors(401, 404, 500, 501, 502) },
+    },
+    {
+        method: 'GET', path: '/v1/messages/batches/:id/results', frontdoor: 'anthropic', summary: 'Stream a message batch\'s results', auth: 'api_key',
+        responses: {
+            200: { description: 'One result per line.', contentType: 'application/x-jsonl' },
+            ...errors(401, 404, 500, 501, 502),
+        },
+    },
+    {
+        method: 'POST', path: '/v1/messages/batches/:id/cancel', frontdoor: 'anthropic', summary: 'Cancel a message batch', auth: 'api_key',
+        responses: { ...ok('MessageBatch'), ...errors(401, 404, 500, 501, 502) },
+    },
+
+    // Cohere
+    {
+        method: 'POST', path: '/v1/chat', frontdoor: 'cohere', summary: 'Chat', auth: 'api_key',
+        requestBody: 'CohereChatRequest',
+        responses: { ...ok('CohereChatResponse', 'The response, or a stream of events.', { streamContentType: 'application/stream+json' }), ...errors(...PROVIDER_ERRORS) },
+    },
+
+    // Responses
+    {
+        method: 'POST', path: '/v1/responses', frontdoor: 'responses', summary: 'Create a response', auth: 'api_key',
+        requestBody: 'ResponseRequest',
+        responses: { ...ok('Response', 'The response, or a stream of events.', { streamContentType: 'text/event-stream' }), ...errors(...PROVIDER_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/v1/responses', frontdoor: 'responses', summary: 'List responses', auth: 'api_key',
+        query: { limit: 'Page size (default: 20).' },
+        responses: { ...ok('ResponseList'), ...errors(401, 500) },
+    },
+    {
+        method: 'GET', path: '/v1/responses/:id', frontdoor: 'responses', summary: 'Get a response, or resume its stream', auth: 'api_key',
+        query: { stream: 'true to replay the stream after Last-Event-ID.' },
+        headers: { 'Last-Event-ID': 'Last stream event received (with stream=true).' },
+        responses: { ...ok('Response', 'The response, or its stream events.', { streamContentType: 'text/event-stream' }), ...errors(401, 404, 500) },
+    },
+    {
+        method: 'POST', path: '/v1/responses/:id/cancel', frontdoor: 'responses', summary: 'Cancel a response', auth: 'api_key',
+        responses: { ...ok('Response'), ...errors(401, 404, 500) },
+    },
+    {
+        method: 'POST', path: '/v1/threads', frontdoor: 'responses', summary: 'Create a thread', auth: 'api_key',
+        requestBody: 'ThreadRequest',
+        responses: { ...ok('Thread'), ...errors(401, 500) },
+    },
+    {
+        method: 'GET', path: '/v1/threads/:id', frontdoor: 'responses', summary: 'Get a thread', auth: 'api_key',
+        responses: { ...ok('Thread'), ...errors(401, 404, 500) },
+    },
+    {
+        method: 'POST', path: '/v1/threads/:id/messages', frontdoor: 'responses', summary: 'Add a message to a thread', auth: 'api_key',
+        requestBody: 'ThreadMessageRequest',
+        responses: { ...ok('ThreadMessage'), ...errors(400, 401, 404, [409, 'Message ID used by another thread.'], 500) },
+    },
+    {
+        method: 'GET', path: '/v1/threads/:id/messages', frontdoor: 'responses', summary: 'List a thread\'s messages', auth: 'api_key',
+        responses: { ...ok('ThreadMessageList'), ...errors(401, 404, 500) },
+    },
+    {
+        method: 'POST', path: '/v1/threads/:id/runs', frontdoor: 'responses', summary: 'Run a thread through the model', auth: 'api_key',
+        requestBody: 'RunRequest',
+        responses: { ...ok('Response'), ...errors(...PROVIDER_ERRORS, [409, 'Message ID used by another thread.']) },
+    },
+];
+
+// ============================================================================
+// Admin API
+// ============================================================================
+
+const LIST_QUERY = { limit: 'Page size (default: 50).', offset: 'Rows skipped.' };
+
+/** Statuses every authenticated admin operation may answer with. */
+const ADMIN_ERRORS = [401, 500] as const;
+
+/** Operator-only operations also refuse tenant tokens. */
+const OPERATOR_ERRORS = [401, [403, 'Operator scope required.'], 500] as const;
+
+const UNAVAILABLE = [503, 'Not configured on this gateway.'] as const;
+
+/**
+ * Operations the admin API serves, under its mount point. The admin
+ * handler dispatches on this table, so an operation without an endpoint
+ * (or the reverse) does not compile.
+ */
+export const ADMIN_OPERATIONS = [
+    {
+        method: 'GET', path: '/api/health', summary: 'Liveness', auth: 'public',
+        responses: ok('AdminHealth'),
+    },
+    {
+        method: 'GET', path: '/api/stats', summary: 'System statistics', auth: 'operator',
+        responses: { ...ok('Stats'), ...errors(...OPERATOR_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/api/overview', summary: 'Configuration overview', auth: 'operator',
+        responses: { ...ok('Overview'), ...errors(...OPERATOR_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/api/providers/health', summary: 'Provider status and connection pools', auth: 'operator',
+        responses: { ...ok('ProviderHealthList'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/providers/:name/probes', summary: 'Synthetic probe results', auth: 'operator',
+        query: { limit: 'Results returned (default: 50).' },
+        responses: { ...ok('ProbeReport'), ...errors(...OPERATOR_ERRORS, [404, 'Provider has no probe.'], UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/slo', summary: 'Per-app SLO compliance', auth: 'operator',
+        responses: { ...ok('SloReportList'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/models', summary: 'Effective model catalog', auth: 'tenant',
+        responses: { ...ok('ModelCatalog'), ...errors(...ADMIN_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/routes', summary: 'Every method and path the data plane serves', auth: 'operator',
+        responses: { ...ok('RouteList'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/routing/test', summary: 'How a model would be routed', auth: 'tenant',
+        query: { model: 'Model.', app: 'App.', tenant: 'Tenant (operators only; tenant tokens test their own).' },
+        responses: { ...ok('RoutingTest'), ...errors(400, ...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/templates', summary: 'Prompt templates with their versions', auth: 'tenant',
+        responses: { ...ok('TemplateList'), ...errors(...ADMIN_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/schema/canonical-request', summary: 'Canonical request JSON Schema', auth: 'tenant',
+        responses: { ...ok('JSONSchemaDocument'), ...errors(...ADMIN_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/api/schema/canonical-response', summary: 'Canonical response JSON Schema', auth: 'tenant',
+        responses: { ...ok('JSONSchemaDocument'), ...errors(...ADMIN_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/api/openapi/data-plane.json', summary: 'OpenAPI document for the data plane', auth: 'tenant',
+        responses: { ...ok('OpenAPIDocument'), ...errors(...ADMIN_ERRORS) },
+    },
+    {
+        method: 'GET', path: '/api/openapi/admin.json', summary: 'OpenAPI document for the admin API', auth: 'tenant',
+        responses: { ...ok('OpenAPIDocument'), ...errors(...ADMIN_ERRORS) },
+    },
+    {
+        method: 'POST', path: '/api/privacy/erase', summary: 'Start erasing an end user\'s interactions', auth: 'operator',
+        requestBody: 'ErasureRequest',
+        responses: { 202: { description: 'Erasure started.', schema: 'ErasureJob' }, ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/privacy/jobs/:id', summary: 'Erasure job status', auth: 'operator',
+        responses: { ...ok('ErasureJob'), ...errors(...OPERATOR_ERRORS, 404) },
+    },
+    {
+        method: 'GET', path: '/api/logging', summary: 'Log level and debug overrides', auth: 'operator',
+        responses: { ...ok('LogState'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'PUT', path: '/api/logging', summary: 'Change the log level or add a debug override', auth: 'operator',
+        requestBody: 'LogChange',
+        responses: { ...ok('LogState'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'DELETE', path: '/api/logging', summary: 'Reset logging to its configuration', auth: 'operator',
+        responses: { ...ok('LogState'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/maintenance/replay-spill', summary: 'Replay spilled usage writes', auth: 'operator',
+        responses: { ...ok('SpillReplay'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/maintenance/rewrap', summary: 'Re-encrypt stored data under the newest key', auth: 'operator',
+        query: { batch_size: 'Rows per batch.' },
+        responses: { ...ok('RewrapResult'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/maintenance/migrate-model', summary: 'Start migrating a model to another', auth: 'operator',
+        requestBody: 'ModelMigrationRequest',
+        responses: { 202: { description: 'Migration started.', schema: 'ModelMigrationJob' }, ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/maintenance/migrate-model/:id', summary: 'Model migration job status', auth: 'operator',
+        responses: { ...ok('ModelMigrationJob'), ...errors(...OPERATOR_ERRORS, 404) },
+    },
+    {
+        method: 'POST', path: '/api/maintenance/backfill', summary: 'Start copying the primary store to the secondary', auth: 'operator',
+        query: { batch_size: 'Rows per batch.' },
+        responses: { 202: { description: 'Backfill started.', schema: 'BackfillJob' }, ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/maintenance/backfill/:id', summary: 'Backfill job status', auth: 'operator',
+        responses: { ...ok('BackfillJob'), ...errors(...OPERATOR_ERRORS, 404) },
+    },
+    {
+        method: 'GET', path: '/api/maintenance/consistency', summary: 'Compare a sample of interactions on both dual-write stores', auth: 'operator',
+        query: { sample: 'Interactions compared.' },
+        responses: { ...ok('ConsistencyReport'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/thread-state', summary: 'Thread state mappings', auth: 'operator',
+        query: { limit: 'Page size (default: 50).', cursor: 'Cursor from the previous page.' },
+        responses: { ...ok('ThreadStateList'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/thread-state/purge', summary: 'Remove mappings not updated recently', auth: 'operator',
+        query: { older_than: 'Age, as a duration (e.g. 30d).' },
+        responses: { ...ok('ThreadStatePurge'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/thread-state/:hash', summary: 'A mapping with the interactions that touched it', auth: 'operator',
+        responses: { ...ok('ThreadStateDetail'), ...errors(...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'DELETE', path: '/api/thread-state/:hash', summary: 'Remove a mapping', auth: 'operator',
+        responses: { ...ok('ThreadStateDeleted'), ...errors(...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/export/threads', summary: 'Export tenants\' conversations as a bundle', auth: 'operator',
+        requestBody: 'ThreadExportRequest',
+        responses: { ...ok('ThreadBundle'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/import/threads', summary: 'Import a bundle from another deployment', auth: 'operator',
+        requestBody: 'ThreadBundle',
+        query: { batch_size: 'Records per batch.' },
+        responses: { ...ok('ThreadImportResult'), ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/console/execute', summary: 'Run a test request through an app', auth: 'operator',
+        requestBody: 'ConsoleRequest',
+        responses: {
+            ...ok('ConsoleResult'),
+            ...errors(400, ...OPERATOR_ERRORS, [429, 'Too many console requests.'], UNAVAILABLE),
+        },
+    },
+    {
+        method: 'GET', path: '/api/tenants', summary: 'List tenants', auth: 'operator',
+        responses: { ...ok('TenantList'), ...errors(...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/tenants', summary: 'Create a tenant with its initial API key', auth: 'operator',
+        requestBody: 'TenantCreate',
+        responses: { 201: { description: 'Created.', schema: 'TenantCreated' }, ...errors(400, ...OPERATOR_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/tenants/:id', summary: 'Get a tenant', auth: 'own_tenant',
+        responses: { ...ok('Tenant'), ...errors(...ADMIN_ERRORS, 404) },
+    },
+    {
+        method: 'PATCH', path: '/api/tenants/:id', summary: 'Update a tenant', auth: 'operator',
+        requestBody: 'TenantUpdate',
+        responses: { ...ok('Tenant'), ...errors(400, ...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'DELETE', path: '/api/tenants/:id', summary: 'Disable a tenant', auth: 'operator',
+        responses: { ...ok('Tenant'), ...errors(...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/tenants/:id/enable', summary: 'Enable a tenant', auth: 'operator',
+        responses: { ...ok('Tenant'), ...errors(...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'POST', path: '/api/tenants/:id/disable', summary: 'Disable a tenant', auth: 'operator',
+        responses: { ...ok('Tenant'), ...errors(...OPERATOR_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/tenants/:id/budget', summary: 'Monthly budget consumption', auth: 'own_tenant',
+        responses: { ...ok('BudgetStatus'), ...errors(...ADMIN_ERRORS, [404, 'Not found, or no budget configured.'], UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/tenants/:id/secrets', summary: 'A tenant\'s secrets\' metadata', auth: 'own_tenant',
+        responses: { ...ok('SecretList'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/tenants/:id/secrets/:name', summary: 'A secret\'s metadata', auth: 'own_tenant',
+        responses: { ...ok('Secret'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'PUT', path: '/api/tenants/:id/secrets/:name', summary: 'Set or rotate a secret', auth: 'own_tenant',
+        requestBody: 'SecretValue',
+        responses: { ...ok('Secret'), ...errors(400, ...ADMIN_ERRORS, 404, [503, 'Storage encryption keys not configured.']) },
+    },
+    {
+        method: 'DELETE', path: '/api/tenants/:id/secrets/:name', summary: 'Remove a secret', auth: 'own_tenant',
+        responses: { ...ok('SecretDeleted'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/tenants/:id/usage', summary: 'Usage report by model and day', auth: 'own_tenant',
+        query: {
+            start_date: 'First day (YYYY-MM-DD).',
+            end_date: 'Last day (YYYY-MM-DD; default: today).',
+            group_by: 'model, reason, end_user, or language.',
+        },
+        responses: { ...ok('UsageReport'), ...errors(400, ...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/interactions', summary: 'List or find interactions', auth: 'tenant',
+        query: {
+            ...LIST_QUERY,
+            status: 'Lifecycle status: in_progress, completed, failed, cancelled, or abandoned.',
+            end_user: 'End-user ID or hash.',
+            has_usage_discrepancy: 'true, for those whose reported tokens disagreed with the output (with provider and model).',
+            finish_reason: 'Finish reason (with app and model).',
+            language: 'Response language.',
+            safety: 'Safety category, or * for any.',
+            provider: 'Provider (with has_usage_discrepancy).',
+            app: 'App (with finish_reason).',
+            model: 'Model (with finish_reason or has_usage_discrepancy).',
+            'metadata.{key}': 'Correlation metadata value.',
+        },
+        responses: { ...ok('InteractionList'), ...errors(400, ...ADMIN_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/interactions/:id', summary: 'Get an interaction with its provider attempts', auth: 'tenant',
+        responses: { ...ok('InteractionDetail'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/interactions/:id/events', summary: 'An interaction\'s events', auth: 'tenant',
+        query: { expand: 'chunks, to restore one stream_chunk event per stream event.' },
+        responses: { ...ok('InteractionEvents'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/interactions/:id/tail', summary: 'Follow an interaction\'s events', auth: 'tenant',
+        query: { wait: 'How long to wait for an interaction not started yet (e.g. 30s).' },
+        responses: {
+            200: { description: 'Saved events, live ones, then an end event with the final status.', contentType: 'text/event-stream' },
+            ...errors(400, ...ADMIN_ERRORS, 404, UNAVAILABLE),
+        },
+    },
+    {
+        method: 'GET', path: '/api/interactions/:id/provider-request', summary: 'Request bodies sent upstream', auth: 'tenant',
+        responses: { ...ok('ProviderRequests'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/interactions/:id/shadows/:shadowId/diff', summary: 'Primary vs shadow diff', auth: 'tenant',
+        responses: { ...ok('ShadowDiff'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/threads', summary: 'List threads', auth: 'tenant',
+        query: LIST_QUERY,
+        responses: { ...ok('AdminThreadList'), ...errors(...ADMIN_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/threads/:id', summary: 'Get a thread', auth: 'tenant',
+        responses: { ...ok('AdminThread'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/responses', summary: 'List responses', auth: 'tenant',
+        query: LIST_QUERY,
+        responses: { ...ok('AdminResponseList'), ...errors(...ADMIN_ERRORS, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/responses/:id', summary: 'Get a response', auth: 'tenant',
+        responses: { ...ok('AdminResponse'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+    {
+        method: 'GET', path: '/api/shadows/:id', summary: 'Get a shadow result', auth: 'tenant',
+        responses: { ...ok('ShadowResult'), ...errors(...ADMIN_ERRORS, 404, UNAVAILABLE) },
+    },
+] as const satisfies readonly OpenAPIOperation[];
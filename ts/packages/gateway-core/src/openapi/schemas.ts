@@ -0,0 +1,1068 @@
+/**
+ * OpenAPI component schemas for the gateway's own HTTP surface.
+ *
+ * Describes request and response bodies as the gateway sends and accepts
+ * them: the frontdoors' wire formats, the Responses and thread endpoints
+ * (whose bodies are camelCase, as implemented), the gateway's own
+ * endpoints, and the admin API. Each object's property list is typed
+ * against the interface the code serializes, so adding or removing a field
+ * without updating its schema fails the build. Objects are left open to
+ * unlisted properties, so adding one is not a breaking change.
+ *
+ * @module openapi/schemas
+ */
+
+import type { JSONSchema } from '../domain/schema.js';
+import type { Model, ModelList } from '../domain/types.js';
+import type {
+    IncompleteDetails,
+    ResponsesAPIRequest,
+    ResponsesAPIResponse,
+    ResponsesContentPart,
+    ResponsesError,
+    ResponsesInputItem,
+    ResponsesOutputContent,
+    ResponsesOutputItem,
+    ResponsesUsage,
+    Thread,
+    ThreadMessage,
+    ThreadMessageContent,
+} from '../domain/responses.js';
+import type {
+    OpenAIChoice,
+    OpenAIContentPart,
+    OpenAIMessage,
+    OpenAIRequest,
+    OpenAIRequestMessage,
+    OpenAIResponse,
+    OpenAITool,
+    OpenAIToolCall,
+    OpenAIUsage,
+} from '../codecs/openai.js';
+import type {
+    AnthropicContentBlock,
+    AnthropicImageSource,
+    AnthropicMessage,
+    AnthropicRequest,
+    AnthropicResponse,
+    AnthropicResponseContent,
+    AnthropicThinkingConfig,
+    AnthropicTool,
+    AnthropicToolChoice,
+    AnthropicUsage,
+} from '../codecs/anthropic.js';
+import type {
+    CompletionsChoice,
+    CompletionsRequest,
+    CompletionsResponse,
+    CompletionsUsage,
+} from '../codecs/completions.js';
+import type { CohereMessage, CohereRequest, CohereResponse, CohereToolCall } from '../codecs/cohere.js';
+import type { TokenCountResult } from '../tokens/count.js';
+import type { UsageReport, UsageReportBucket, UsageReportTotals } from '../usage/report.js';
+import type { RegisteredRoute } from '../routes/table.js';
+import type { TenantInfo } from '../tenants/registry.js';
+import type { SecretInfo } from '../secrets/manager.js';
+import type {
+    AdminInteractionSummary,
+    AdminInteractionsListResponse,
+    AdminThreadState,
+    AdminThreadStateDetail,
+    AdminThreadStateListResponse,
+    AdminThreadStateTouch,
+    OverviewResponse,
+    ProviderHealthSummary,
+    StatsResponse,
+} from '../admin/handler.js';
+
+// ============================================================================
+// Schema Builders
+// ============================================================================
+
+/** One subschema per field of T, optional fields included. */
+type Properties<T> = { [K in keyof T]-?: JSONSchema };
+
+/** Request fields the frontdoors accept beyond the provider's format. */
+interface TemplateField {
+    /** Gateway-managed prompt template reference. */
+    template?: unknown;
+}
+
+function object<T>(
+    description: string,
+    properties: Properties<T>,
+    required: ReadonlyArray<keyof T & string>,
+): JSONSchema {
+    return { type: 'object', description, properties, required };
+}
+
+/** A reference to another component schema. */
+export function ref(name: string, description?: string): JSONSchema {
+    const $ref = `#/components/schemas/${name}`;
+    return description ? { $ref, description } : { $ref };
+}
+
+const stringField = (description: string): JSONSchema => ({ type: 'string', description });
+const integerField = (description: string): JSONSchema => ({ type: 'integer', minimum: 0, description });
+const numberField = (description: string): JSONSchema => ({ type: 'number', description });
+const booleanField = (description: string): JSONSchema => ({ type: 'boolean', description });
+const nullable = (schema: JSONSchema): JSONSchema => ({ ...schema, type: [schema.type, 'null'] });
+const arrayOf = (items: JSONSchema, description?: string): JSONSchema =>
+    description ? { type: 'array', items, description } : { type: 'array', items };
+const stringMap = (description: string): JSONSchema => ({
+    type: 'object',
+    description,
+    additionalProperties: { type: 'string' },
+});
+const anyObject = (description: string): JSONSchema => ({ type: 'object', description });
+const stopField: JSONSchema = {
+    description: 'Stop sequence or sequences.',
+    oneOf: [{ type: 'string' }, { type: 'array', items: { type: 'string' } }],
+};
+
+// ============================================================================
+// OpenAI
+// ============================================================================
+
+const OPENAI_SCHEMAS: Record<string, JSONSchema> = {
+    ChatCompletionRequest: object<OpenAIRequest & TemplateField>('OpenAI chat completion request.', {
+        model: stringField('Model, or an alias the app maps; defaults to the app\'s default model.'),
+        messages: arrayOf(ref('ChatRequestMessage'), 'Conversation messages.'),
+        stream: booleanField('Stream the response as server-sent events.'),
+        max_tokens: integerField('Maximum tokens to generate.'),
+        max_completion_tokens: integerField('Maximum tokens to generate, reasoning included.'),
+        temperature: numberField('Sampling temperature.'),
+        top_p: numberField('Nucleus sampling.'),
+        frequency_penalty: numberField('Frequency penalty.'),
+        presence_penalty: numberField('Presence penalty.'),
+        n: integerField('Number of choices.'),
+        stop: stopField,
+        tools: arrayOf(ref('ChatTool'), 'Tools the model can call.'),
+        tool_choice: { description: 'auto, none, required, or a function to call.' },
+        parallel_tool_calls: booleanField('Whether the model may call several tools in one turn.'),
+        response_format: anyObject('Response format: text, json_object, or json_schema.'),
+        user: stringField('End user the request is made for.'),
+        reasoning_effort: { type: 'string', enum: ['low', 'medium', 'high'] },
+        logprobs: booleanField('Return log probabilities of output tokens.'),
+        top_logprobs: integerField('Alternatives returned per token.'),
+        store: booleanField('Keep the completion retrievable with GET /v1/chat/completions/{id}.'),
+        metadata: stringMap('Metadata recorded with the interaction.'),
+        stream_options: anyObject('Streaming options (include_usage).'),
+        template: { description: 'Gateway prompt template to render: a name, or {name, version, variables}.' },
+    }, ['messages']),
+
+    ChatRequestMessage: object<OpenAIRequestMessage>('A chat message in a request.', {
+        role: { type: 'string', enum: ['system', 'developer', 'user', 'assistant', 'tool'] },
+        content: {
+            description: 'Text, a list of content parts, or null.',
+            oneOf: [{ type: 'string' }, arrayOf(ref('ChatContentPart')), { type: 'null' }],
+        },
+        name: stringField('Author name.'),
+        tool_calls: arrayOf(ref('ChatToolCall'), 'Tool calls (assistant messages).'),
+        tool_call_id: stringField('Tool call this message answers (tool messages).'),
+    }, ['role', 'content']),
+
+    ChatContentPart: object<OpenAIContentPart>('A content part.', {
+        type: { type: 'string', enum: ['text', 'image_url'] },
+        text: stringField('Text (text parts).'),
+        image_url: anyObject('Image URL and detail (image_url parts).'),
+    }, ['type']),
+
+    ChatTool: object<OpenAITool>('A function the model can call.', {
+        type: { const: 'function' },
+        function: anyObject('Name, description, and JSON Schema parameters.'),
+    }, ['type', 'function']),
+
+    ChatToolCall: object<OpenAIToolCall>('A tool call made by the assistant.', {
+        id: stringField('Tool call ID.'),
+        type: { const: 'function' },
+        function: anyObject('Function name and JSON-encoded arguments.'),
+    }, ['id', 'type', 'function']),
+
+    ChatCompletion: object<OpenAIResponse>('OpenAI chat completion.', {
+        id: stringField('Completion ID.'),
+        object: stringField('Object type (chat.completion).'),
+        created: integerField('Unix timestamp of creation.'),
+        model: stringField('Model that generated the completion.'),
+        choices: arrayOf(ref('ChatChoice')),
+        usage: ref('ChatUsage'),
+        system_fingerprint: stringField('System fingerprint.'),
+        metadata: stringMap('The request\'s metadata, echoed back.'),
+    }, ['id', 'object', 'created', 'model', 'choices', 'usage']),
+
+    ChatChoice: object<OpenAIChoice>('A completion choice.', {
+        index: integerField('Choice index.'),
+        message: ref('ChatMessage'),
+        finish_reason: nullable({ type: 'string' }),
+        stop_reason: { description: 'Matched stop sequence, when the provider reports one.' },
+        logprobs: { description: 'Log probabilities, if requested.' },
+    }, ['index', 'message', 'finish_reason']),
+
+    ChatMessage: object<OpenAIMessage>('A chat message in a response.', {
+        role: stringField('Author role.'),
+        content: nullable({ type: 'string' }),
+        name: stringField('Author name.'),
+        tool_calls: arrayOf(ref('ChatToolCall')),
+        tool_call_id: stringField('Tool call this message answers.'),
+    }, ['role', 'content']),
+
+    ChatUsage: object<OpenAIUsage>('Token usage.', {
+        prompt_tokens: integerField('Prompt tokens.'),
+        completion_tokens: integerField('Completion tokens.'),
+        total_tokens: integerField('Total tokens.'),
+        completion_tokens_details: anyObject('Completion token breakdown (reasoning_tokens).'),
+    }, ['prompt_tokens', 'completion_tokens', 'total_tokens']),
+
+    CompletionRequest: object<CompletionsRequest>('OpenAI legacy completion request.', {
+        model: stringField('Model.'),
+        prompt: { description: 'Prompt text, prompts, or token arrays.' },
+        suffix: stringField('Text after the completion.'),
+        max_tokens: integerField('Maximum tokens to generate.'),
+        temperature: numberField('Sampling temperature.'),
+        top_p: numberField('Nucleus sampling.'),
+        frequency_penalty: numberField('Frequency penalty.'),
+        presence_penalty: numberField('Presence penalty.'),
+        n: integerField('Completions per prompt.'),
+        stream: booleanField('Stream the response as server-sent events.'),
+        logprobs: nullable({ type: 'integer' }),
+        echo: booleanField('Echo the prompt before the completion.'),
+        stop: stopField,
+        best_of: integerField('Candidates generated per completion.'),
+        user: stringField('End user the request is made for.'),
+    }, []),
+
+    Completion: object<CompletionsResponse>('OpenAI legacy completion.', {
+        id: stringField('Completion ID.'),
+        object: { const: 'text_completion' },
+        created: integerField('Unix timestamp of creation.'),
+        model: stringField('Model that generated the completion.'),
+        choices: arrayOf(ref('CompletionChoice')),
+        usage: ref('CompletionUsage'),
+        system_fingerprint: stringField('System fingerprint.'),
+        warning: stringField('Deprecation warning.'),
+    }, ['id', 'object', 'created', 'model', 'choices']),
+
+    CompletionChoice: object<CompletionsChoice>('A completion choice.', {
+        text: stringField('Generated text.'),
+        index: integerField('Choice index.'),
+        logprobs: { type: 'null' },
+        finish_reason: nullable({ type: 'string' }),
+    }, ['text', 'index', 'logprobs', 'finish_reason']),
+
+    CompletionUsage: object<CompletionsUsage>('Token usage.', {
+        prompt_tokens: integerField('Prompt tokens.'),
+        completion_tokens: integerField('Completion tokens.'),
+        total_tokens: integerField('Total tokens.'),
+    }, ['prompt_tokens', 'completion_tokens', 'total_tokens']),
+
+    Model: object<Model>('A model.', {
+        id: stringField('Model ID.'),
+        object: stringField('Object type (model).'),
+        ownedBy: stringField('Model owner.'),
+        created: integerField('Unix timestamp of creation.'),
+    }, ['id']),
+
+    ModelList: object<ModelList>('Models the app serves.', {
+        object: { const: 'list' },
+        data: arrayOf(ref('Model')),
+    }, ['object', 'data']),
+
+    OpenAIError: {
+        type: 'object',
+        description: 'Error, in OpenAI format.',
+        properties: {
+            error: {
+                type: 'object',
+                properties: {
+                    type: stringField('Error type.'),
+                    code: nullable({ type: 'string' }),
+                    message: stringField('Error message.'),
+                    param: nullable({ type: 'string' }),
+                },
+                required: ['type', 'code', 'message', 'param'],
+            },
+        },
+        required: ['error'],
+    },
+};
+
+// ============================================================================
+// Anthropic
+// ============================================================================
+
+const ANTHROPIC_SCHEMAS: Record<string, JSONSchema> = {
+    MessagesRequest: object<AnthropicRequest>('Anthropic messages request.', {
+        model: stringField('Model.'),
+        messages: arrayOf(ref('AnthropicMessage'), 'Conversation messages.'),
+        max_tokens: integerField('Maximum tokens to generate.'),
+        system: {
+            description: 'System prompt: text or text blocks.',
+            oneOf: [{ type: 'string' }, arrayOf(anyObject('A text block.'))],
+        },
+        stream: booleanField('Stream the response as server-sent events.'),
+        temperature: numberField('Sampling temperature.'),
+        top_p: numberField('Nucleus sampling.'),
+        stop_sequences: arrayOf({ type: 'string' }, 'Stop sequences.'),
+        tools: arrayOf(ref('AnthropicTool'), 'Tools the model can call.'),
+        tool_choice: ref('AnthropicToolChoice'),
+        metadata: anyObject('Request metadata (user_id).'),
+        thinking: ref('AnthropicThinking'),
+    }, ['model', 'messages', 'max_tokens']),
+
+    AnthropicMessage: object<AnthropicMessage>('A message.', {
+        role: { type: 'string', enum: ['user', 'assistant'] },
+        content: {
+            description: 'Text or content blocks.',
+            oneOf: [{ type: 'string' }, arrayOf(ref('AnthropicContentBlock'))],
+        },
+    }, ['role', 'content']),
+
+    AnthropicContentBlock: object<AnthropicContentBlock>('A content block.', {
+        type: { type: 'string', enum: ['text', 'image', 'tool_use', 'tool_result', 'thinking', 'redacted_thinking'] },
+        text: stringField('Text (text blocks).'),
+        thinking: stringField('Reasoning (thinking blocks).'),
+        signature: stringField('Signature to echo back (thinking blocks).'),
+        data: stringField('Encrypted reasoning (redacted_thinking blocks).'),
+        id: stringField('Tool use ID (tool_use blocks).'),
+        name: stringField('Tool name (tool_use blocks).'),
+        input: { description: 'Tool input (tool_use blocks).' },
+        tool_use_id: stringField('Tool use answered (tool_result blocks).'),
+        content: { description: 'Tool result: text or content blocks (tool_result blocks).' },
+        is_error: booleanField('Whether the tool failed (tool_result blocks).'),
+        source: ref('AnthropicImageSource'),
+    }, ['type']),
+
+    AnthropicImageSource: object<AnthropicImageSource>('An image: inline base64 data or a URL.', {
+        type: { type: 'string', enum: ['base64', 'url'] },
+        media_type: stringField('MIME type (base64).'),
+        data: stringField('Base64 data.'),
+        url: stringField('Image URL.'),
+    }, ['type']),
+
+    AnthropicTool: object<AnthropicTool>('A tool the model can call.', {
+        name: stringField('Tool name.'),
+        description: stringField('What the tool does.'),
+        input_schema: anyObject('JSON Schema for the input.'),
+    }, ['name', 'input_schema']),
+
+    AnthropicToolChoice: object<AnthropicToolChoice>('How the model should choose tools.', {
+        type: { type: 'string', enum: ['auto', 'any', 'tool', 'none'] },
+        name: stringField('Tool to call (type tool).'),
+        disable_parallel_tool_use: booleanField('Call at most one tool.'),
+    }, ['type']),
+
+    AnthropicThinking: object<AnthropicThinkingConfig>('Extended thinking configuration.', {
+        type: { type: 'string', enum: ['enabled', 'disabled'] },
+        budget_tokens: integerField('Token budget for thinking.'),
+    }, ['type']),
+
+    MessagesResponse: object<AnthropicResponse>('Anthropic message.', {
+        id: stringField('Message ID.'),
+        type: { const: 'message' },
+        role: { const: 'assistant' },
+        model: stringField('Model that generated the message.'),
+        content: arrayOf(ref('AnthropicResponseContent')),
+        stop_reason: nullable({ type: 'string' }),
+        stop_sequence: nullable({ type: 'string' }),
+        usage: ref('AnthropicUsage'),
+    }, ['id', 'type', 'role', 'model', 'content', 'stop_reason', 'stop_sequence', 'usage']),
+
+    AnthropicResponseContent: object<AnthropicResponseContent>('A response content block.', {
+        type: { type: 'string', enum: ['text', 'tool_use', 'thinking', 'redacted_thinking'] },
+        text: stringField('Text (text blocks).'),
+        thinking: stringField('Reasoning (thinking blocks).'),
+        signature: stringField('Signature to echo back (thinking blocks).'),
+        data: stringField('Encrypted reasoning (redacted_thinking blocks).'),
+        id: stringField('Tool use ID (tool_use blocks).'),
+        name: stringField('Tool name (tool_use blocks).'),
+        input: { description: 'Tool input (tool_use blocks).' },
+    }, ['type']),
+
+    AnthropicUsage: object<AnthropicUsage>('Token usage.', {
+        input_tokens: integerField('Input tokens.'),
+        output_tokens: integerField('Output tokens.'),
+    }, ['input_tokens', 'output_tokens']),
+
+    MessageBatchRequest: {
+        type: 'object',
+        description: 'Message batch submission. Every request must route to the same Anthropic provider.',
+        properties: {
+            requests: arrayOf({
+                type: 'object',
+                properties: {
+                    custom_id: stringField('The client\'s ID for the request.'),
+                    params: ref('MessagesRequest'),
+                },
+                required: ['custom_id', 'params'],
+            }),
+        },
+        required: ['requests'],
+    },
+
+    MessageBatch: {
+        type: 'object',
+        description: 'A message batch, as the provider reports it, under the gateway\'s batch ID.',
+        properties: {
+            id: stringField('Gateway batch ID.'),
+            type: { const: 'message_batch' },
+            processing_status: stringField('in_progress, canceling, or ended.'),
+            request_counts: anyObject('Requests processing, succeeded, errored, canceled, and expired.'),
+            results_url: nullable(stringField('Gateway URL of the results, once the batch has ended.')),
+        },
+        required: ['id'],
+    },
+
+    AnthropicError: {
+        type: 'object',
+        description: 'Error, in Anthropic format.',
+        properties: {
+            type: { const: 'error' },
+            error: {
+                type: 'object',
+                properties: {
+                    type: stringField('Error type.'),
+                    message: stringField('Error message.'),
+                },
+                required: ['type', 'message'],
+            },
+        },
+        required: ['type', 'error'],
+    },
+};
+
+// ============================================================================
+// Cohere
+// ============================================================================
+
+const COHERE_SCHEMAS: Record<string, JSONSchema> = {
+    CohereChatRequest: object<CohereRequest>('Cohere chat request.', {
+        message: stringField('User message.'),
+        model: stringField('Model.'),
+        preamble: stringField('System prompt.'),
+        chat_history: arrayOf(ref('CohereMessage'), 'Earlier turns.'),
+        stream: booleanField('Stream the response as newline-delimited events.'),
+        max_tokens: integerField('Maximum tokens to generate.'),
+        temperature: numberField('Sampling temperature.'),
+        p: numberField('Nucleus sampling.'),
+        stop_sequences: arrayOf({ type: 'string' }, 'Stop sequences.'),
+        documents: arrayOf({}, 'Accepted but not forwarded.'),
+        connectors: arrayOf({}, 'Accepted but not forwarded.'),
+    }, ['message']),
+
+    CohereMessage: object<CohereMessage>('A chat history entry.', {
+        role: { type: 'string', enum: ['USER', 'CHATBOT', 'SYSTEM', 'TOOL'] },
+        message: stringField('Message text.'),
+    }, ['role']),
+
+    CohereChatResponse: object<CohereResponse>('Cohere chat response.', {
+        response_id: stringField('Response ID.'),
+        text: stringField('Generated text.'),
+        generation_id: stringField('Generation ID.'),
+        finish_reason: stringField('Why generation stopped.'),
+        tool_calls: arrayOf(ref('CohereToolCall')),
+        meta: anyObject('Billed units.'),
+    }, ['response_id', 'text', 'generation_id', 'finish_reason']),
+
+    CohereToolCall: object<CohereToolCall>('A tool call.', {
+        name: stringField('Tool name.'),
+        parameters: anyObject('Tool arguments.'),
+    }, ['name', 'parameters']),
+
+    CohereError: {
+        type: 'object',
+        description: 'Error, in Cohere format.',
+        properties: { message: stringField('Error message.') },
+        required: ['message'],
+    },
+};
+
+// ============================================================================
+// Responses and Threads
+// ============================================================================
+
+const RESPONSES_SCHEMAS: Record<string, JSONSchema> = {
+    ResponseRequest: object<ResponsesAPIRequest & TemplateField>('Responses API request.', {
+        model: stringField('Model.'),
+        input: {
+            description: 'Input text or items.',
+            oneOf: [{ type: 'string' }, arrayOf(ref('ResponseInputItem'))],
+        },
+        instructions: stringField('Instructions for the model.'),
+        tools: arrayOf(anyObject('A function tool.'), 'Tools the model can call.'),
+        toolChoice: { type: 'string', enum: ['auto', 'none', 'required'] },
+        metadata: stringMap('Metadata for the response.'),
+        maxOutputTokens: integerField('Maximum tokens to generate.'),
+        temperature: numberField('Sampling temperature.'),
+        topP: numberField('Nucleus sampling.'),
+        stream: booleanField('Stream the response as server-sent events.'),
+        store: booleanField('Whether to store the response.'),
+        previousResponseId: stringField('Response this one continues.'),
+        user: stringField('End user the request is made for.'),
+        template: { description: 'Gateway prompt template to render: a name, or {name, version, variables}.' },
+    }, ['model', 'input']),
+
+    ResponseInputItem: object<ResponsesInputItem>('An input item.', {
+        type: { type: 'string', enum: ['message', 'item_reference'] },
+        role: { type: 'string', enum: ['system', 'developer', 'user', 'assistant'] },
+        content: {
+            description: 'Text or content parts (message items).',
+            oneOf: [{ type: 'string' }, arrayOf(ref('ResponseContentPart'))],
+        },
+        id: stringField('Referenced item ID (item_reference items).'),
+    }, ['type']),
+
+    ResponseContentPart: object<ResponsesContentPart>('An input content part.', {
+        type: { type: 'string', enum: ['input_text', 'input_image', 'input_audio'] },
+        text: stringField('Text.'),
+        imageUrl: stringField('Image URL.'),
+        imageData: stringField('Base64 image data.'),
+        audioData: stringField('Base64 audio data.'),
+    }, ['type']),
+
+    Response: object<ResponsesAPIResponse>('A response.', {
+        id: stringField('Response ID.'),
+        object: { const: 'response' },
+        createdAt: integerField('Unix timestamp of creation.'),
+        status: { type: 'string', enum: ['in_progress', 'completed', 'incomplete', 'cancelled', 'failed'] },
+        model: stringField('Model used.'),
+        output: arrayOf(ref('ResponseOutputItem')),
+        usage: ref('ResponseUsage'),
+        metadata: stringMap('Metadata.'),
+        error: ref('ResponseError'),
+        incompleteDetails: ref('ResponseIncompleteDetails'),
+    }, ['id', 'object', 'createdAt', 'status', 'model', 'output']),
+
+    ResponseOutputItem: object<ResponsesOutputItem>('An output item.', {
+        type: { type: 'string', enum: ['message', 'function_call', 'function_call_output'] },
+        id: stringField('Item ID.'),
+        status: { type: 'string', enum: ['in_progress', 'completed', 'incomplete'] },
+        role: { const: 'assistant' },
+        content: arrayOf(ref('ResponseOutputContent')),
+        name: stringField('Function name (function_call items).'),
+        callId: stringField('Call ID (function_call items).'),
+        arguments: stringField('JSON-encoded arguments (function_call items).'),
+        output: stringField('Function output (function_call_output items).'),
+    }, ['type', 'id']),
+
+    ResponseOutputContent: object<ResponsesOutputContent>('Output content.', {
+        type: { type: 'string', enum: ['output_text', 'refusal'] },
+        text: stringField('Text.'),
+        refusal: stringField('Refusal message.'),
+    }, ['type']),
+
+    ResponseUsage: object<ResponsesUsage>('Token usage.', {
+        inputTokens: integerField('Input tokens.'),
+        outputTokens: integerField('Output tokens.'),
+        totalTokens: integerField('Total tokens.'),
+        inputTokensDetails: anyObject('Input token breakdown (cachedTokens).'),
+        outputTokensDetails: anyObject('Output token breakdown (reasoningTokens).'),
+    }, ['inputTokens', 'outputTokens', 'totalTokens']),
+
+    ResponseError: object<ResponsesError>('Why a response failed.', {
+        type: stringField('Error type.'),
+        code: stringField('Error code.'),
+        message: stringField('Error message.'),
+    }, ['type', 'message']),
+
+    ResponseIncompleteDetails: object<IncompleteDetails>('Why a response is incomplete.', {
+        reason: { type: 'string', enum: ['max_output_tokens', 'content_filter'] },
+    }, ['reason']),
+
+    ResponseList: {
+        type: 'object',
+        description: 'The tenant\'s responses, newest first.',
+        properties: {
+            object: { const: 'list' },
+            data: arrayOf(ref('Response')),
+            has_more: booleanField('Whether there are more.'),
+        },
+        required: ['object', 'data', 'has_more'],
+    },
+
+    ThreadRequest: {
+        type: 'object',
+        description: 'Thread creation request.',
+        properties: { metadata: stringMap('Thread metadata.') },
+    },
+
+    Thread: object<Thread>('A conversation thread.', {
+        id: stringField('Thread ID.'),
+        object: { const: 'thread' },
+        createdAt: integerField('Unix timestamp of creation.'),
+        metadata: stringMap('Metadata.'),
+    }, ['id', 'object', 'createdAt']),
+
+    ThreadMessageRequest: {
+        type: 'object',
+        description: 'Message creation request.',
+        properties: {
+            id: stringField('Message ID, making retries safe: a message the thread already has isn\'t added again.'),
+            role: { type: 'string', enum: ['user', 'assistant'], default: 'user' },
+            content: stringField('Message text.'),
+        },
+        required: ['content'],
+    },
+
+    ThreadMessage: object<ThreadMessage>('A message in a thread.', {
+        id: stringField('Message ID.'),
+        object: { const: 'thread.message' },
+        threadId: stringField('Thread ID.'),
+        createdAt: integerField('Unix timestamp of creation.'),
+        role: { type: 'string', enum: ['user', 'assistant'] },
+        content: arrayOf(ref('ThreadMessageContent')),
+        metadata: stringMap('Metadata.'),
+    }, ['id', 'object', 'threadId', 'createdAt', 'role', 'content']),
+
+    ThreadMessageContent: object<ThreadMessageContent>('Message content.', {
+        type: { const: 'text' },
+        text: {
+            type: 'object',
+            properties: { value: { type: 'string' } },
+            required: ['value'],
+        },
+    }, ['type', 'text']),
+
+    ThreadMessageList: {
+        type: 'object',
+        description: 'A thread\'s messages, oldest first.',
+        properties: {
+            object: { const: 'list' },
+            data: arrayOf(ref('ThreadMessage')),
+        },
+        required: ['object', 'data'],
+    },
+
+    RunRequest: {
+        type: 'object',
+        description: 'Run creation request.',
+        properties: {
+            model: stringField('Model; defaults to the app\'s default model.'),
+            instructions: stringField('Instructions for the model.'),
+            message_id: stringField('ID for the reply added to the thread, making retries safe.'),
+        },
+    },
+};
+
+// ============================================================================
+// Gateway Endpoints
+// ============================================================================
+
+const GATEWAY_SCHEMAS: Record<string, JSONSchema> = {
+    Health: {
+        type: 'object',
+        description: 'Liveness.',
+        properties: { status: { const: 'ok' } },
+        required: ['status'],
+    },
+
+    Readiness: {
+        type: 'object',
+        description: 'Readiness. Stays 200 while storage is degraded; the status says so.',
+        properties: {
+            status: { type: 'string', enum: ['ok', 'degraded'] },
+            storage: anyObject('Storage health: status and counters.'),
+        },
+        required: ['status'],
+    },
+
+    TokenCountRequest: {
+        description: 'A request in the app frontdoor\'s format (Anthropic apps take a messages request, all others a chat completion request).',
+        oneOf: [ref('ChatCompletionRequest'), ref('MessagesRequest')],
+    },
+
+    TokenCount: object<TokenCountResult>('A request\'s input token count, against the model\'s limits.', {
+        object: { const: 'token_count' },
+        model: stringField('Model counted for.'),
+        input_tokens: integerField('Input tokens.'),
+        exact: booleanField('Whether the count is exact rather than estimated.'),
+        tokenizer: { type: 'string', enum: ['o200k', 'cl100k', 'claude'] },
+        context_window: nullable(integerField('Model context window, when known.')),
+        max_output_tokens: nullable(integerField('Model output limit, when known.')),
+        max_tokens: nullable(integerField('max_tokens requested.')),
+        fits: nullable(booleanField('Whether the input and max_tokens fit the context window, when known.')),
+    }, ['object', 'model', 'input_tokens', 'exact', 'tokenizer', 'context_window', 'max_output_tokens', 'max_tokens', 'fits']),
+
+    UsageReport: object<UsageReport>('The key\'s tenant\'s usage by model and day.', {
+        object: { const: 'usage.report' },
+        schema_version: integerField('Report format version.'),
+        start_date: stringField('First day (YYYY-MM-DD).'),
+        end_date: stringField('Last day (YYYY-MM-DD).'),
+        group_by: { type: 'string', enum: ['model', 'reason', 'end_user', 'language'] },
+        data: arrayOf(ref('UsageBucket')),
+        totals: ref('UsageTotals'),
+    }, ['object', 'schema_version', 'start_date', 'end_date', 'group_by', 'data', 'totals']),
+
+    UsageBucket: object<UsageReportBucket>('One model\'s usage on one day.', {
+        object: { const: 'usage.bucket' },
+        date: stringField('Day (YYYY-MM-DD).'),
+        model: stringField('Model.'),
+        reason: stringField('Attempt reason (group_by=reason).'),
+        end_user: nullable(stringField('End-user hash (group_by=end_user).')),
+        language: nullable(stringField('Response language (group_by=language).')),
+        safety_flagged: integerField('Safety-flagged responses (group_by=language).'),
+        p95_latency_ms: nullable(numberField('p95 latency.')),
+        requests: integerField('Requests (attempts, grouped by reason).'),
+        errors: integerField('Failed requests.'),
+        prompt_tokens: integerField('Prompt tokens.'),
+        completion_tokens: integerField('Completion tokens.'),
+        total_tokens: integerField('Total tokens.'),
+        cost_usd: numberField('Cost (grouped by reason).'),
+    }, ['object', 'date', 'model', 'p95_latency_ms', 'requests', 'errors', 'prompt_tokens', 'completion_tokens', 'total_tokens']),
+
+    UsageTotals: object<UsageReportTotals>('Usage totals.', {
+        requests: integerField('Requests.'),
+        errors: integerField('Failed requests.'),
+        prompt_tokens: integerField('Prompt tokens.'),
+        completion_tokens: integerField('Completion tokens.'),
+        total_tokens: integerField('Total tokens.'),
+        cost_usd: numberField('Cost (grouped by reason).'),
+    }, ['requests', 'errors', 'prompt_tokens', 'completion_tokens', 'total_tokens']),
+};
+
+// ============================================================================
+// Admin API
+// ============================================================================
+
+const ADMIN_SCHEMAS: Record<string, JSONSchema> = {
+    AdminError: {
+        type: 'object',
+        description: 'Admin API error.',
+        properties: { error: stringField('Error message.') },
+        required: ['error'],
+    },
+
+    AdminHealth: {
+        type: 'object',
+        description: 'Admin API liveness.',
+        properties: { status: { const: 'ok' } },
+        required: ['status'],
+    },
+
+    Stats: object<StatsResponse>('System statistics.', {
+        uptime: stringField('Uptime, formatted.'),
+        uptimeMs: integerField('Uptime.'),
+        runtime: stringField('Runtime name.'),
+        memory: anyObject('Memory usage, where the runtime reports it.'),
+        latency: arrayOf(anyObject('Percentiles for a provider, model, and phase.')),
+        events: anyObject('Analytics sink counters.'),
+        mirrors: arrayOf(anyObject('Mirroring counters for an app.')),
+        deadlines: arrayOf(anyObject('Deadline cancellations for a provider.')),
+        callBudgets: arrayOf(anyObject('Call budget refusals for an app and limit.')),
+        clientAborts: arrayOf(anyObject('Client aborts for an app.')),
+        usageDiscrepancies: arrayOf(anyObject('Usage checks for a provider and model.')),
+        coalescing: arrayOf(anyObject('Coalescing counters for an app.')),
+        messageDedup: arrayOf(anyObject('Duplicate thread appends skipped for an app.')),
+        spill: anyObject('Spilled usage writes.'),
+        storage: anyObject('Storage health.'),
+        dualWrite: anyObject('Dual-write counters.'),
+        classification: anyObject('Response classification counters.'),
+        concurrency: anyObject('Provider calls in flight and queued per tenant.'),
+        unmatchedRoutes: arrayOf(anyObject('Unmatched requests for a path prefix.')),
+    }, ['uptime', 'uptimeMs', 'runtime']),
+
+    Overview: object<OverviewResponse>('Configuration overview.', {
+        mode: { type: 'string', enum: ['single-tenant', 'multi-tenant'] },
+        storage: anyObject('Storage type.'),
+        apps: arrayOf(anyObject('An app: name, frontdoor, path, provider, and default model.')),
+        providers: arrayOf(anyObject('A provider: name, type, and base URL.')),
+        frontdoors: arrayOf(anyObject('A frontdoor and its path.')),
+        routing: anyObject('Default provider and routing rules.'),
+        parameterPolicies: anyObject('Sampling parameter policies per app and model pattern.'),
+    }, ['mode', 'storage', 'apps', 'providers', 'frontdoors', 'routing']),
+
+    ProviderHealth: object<ProviderHealthSummary>('A provider\'s status.', {
+        name: stringField('Provider name.'),
+        type: stringField('Provider type.'),
+        configured: booleanField('Whether the provider is configured.'),
+        http: anyObject('Connection pool stats.'),
+        keys: arrayOf(anyObject('An API key\'s health, by non-secret ID.')),
+        probe: anyObject('Synthetic probe summary.'),
+        apiVersion: stringField('API version sent.'),
+        betaFeatures: arrayOf({ type: 'string' }),
+        organization: stringField('Organization sent.'),
+        project: stringField('Project sent.'),
+    }, ['name', 'type', 'configured']),
+
+    Route: object<RegisteredRoute>('A method and path the gateway serves.', {
+        method: stringField('HTTP method.'),
+        path: stringField('Path; :name segments are parameters.'),
+        app: stringField('Owning app.'),
+        frontdoor: stringField('Frontdoor that serves it; unset for the gateway\'s own endpoints.'),
+    }, ['method', 'path']),
+
+    InteractionSummary: object<AdminInteractionSummary>('An interaction, for list display.', {
+        id: stringField('Interaction ID.'),
+        type: stringField('conversation, response, or request.'),
+        status: stringField('Status.'),
+        model: stringField('Model.'),
+        provider: stringField('Provider.'),
+        durationMs: integerField('Duration.'),
+        metadata: stringMap('Correlation metadata.'),
+        endUser: stringField('End-user hash.'),
+        language: stringField('Response language.'),
+        safetyFlags: arrayOf({ type: 'string' }),
+        createdAt: integerField('Creation time (ms).'),
+        updatedAt: integerField('Last update (ms).'),
+    }, ['id', 'type', 'createdAt', 'updatedAt']),
+
+    InteractionList: object<AdminInteractionsListResponse>('Interactions, newest first.', {
+        interactions: arrayOf(ref('InteractionSummary')),
+        total: integerField('Matches.'),
+        breakdown: arrayOf(anyObject('Matches per app and model, or per provider and model.')),
+    }, ['interactions', 'total']),
+
+    ThreadState: object<AdminThreadState>('A thread state mapping, by key hash.', {
+        keyHash: stringField('SHA-256 of the thread key.'),
+        kind: { type: 'string', enum: ['affinity', 'response'] },
+        provider: stringField('Pinned provider (affinity mappings).'),
+        responseId: stringField('Current response ID (response mappings).'),
+        updatedAt: integerField('Last update (ms).'),
+    }, ['keyHash', 'kind', 'updatedAt']),
+
+    ThreadStateList: object<AdminThreadStateListResponse>('Thread state mappings.', {
+        entries: arrayOf(ref('ThreadState')),
+        nextCursor: nullable(stringField('Cursor for the next page.')),
+    }, ['entries', 'nextCursor']),
+
+    ThreadStateDetail: object<AdminThreadStateDetail>('A thread state mapping with the interactions that touched it.', {
+        keyHash: stringField('SHA-256 of the thread key.'),
+        kind: { type: 'string', enum: ['affinity', 'response'] },
+        provider: stringField('Pinned provider (affinity mappings).'),
+        responseId: stringField('Current response ID (response mappings).'),
+        updatedAt: integerField('Last update (ms).'),
+        chain: arrayOf(ref('ThreadStateTouch')),
+    }, ['keyHash', 'kind', 'updatedAt', 'chain']),
+
+    ThreadStateTouch: object<AdminThreadStateTouch>('A thread state lookup or write.', {
+        interactionId: stringField('Interaction ID.'),
+        tenantId: stringField('Tenant ID.'),
+        type: { type: 'string', enum: ['thread_resolve', 'thread_update'] },
+        provider: stringField('Provider.'),
+        outcome: stringField('Outcome.'),
+        timestamp: integerField('When (ms).'),
+    }, ['interactionId', 'tenantId', 'type', 'timestamp']),
+
+    ProviderHealthList: {
+        type: 'object',
+        description: 'Every provider\'s status.',
+        properties: { providers: arrayOf(ref('ProviderHealth')) },
+        required: ['providers'],
+    },
+
+    ProbeReport: anyObject('A provider\'s recent probe results and rolling success rate.'),
+
+    SloReportList: {
+        type: 'object',
+        description: 'SLO compliance per app.',
+        properties: { apps: arrayOf(anyObject('An app\'s objectives, error budget remaining, and burn rates.')) },
+        required: ['apps'],
+    },
+
+    ModelCatalog: {
+        type: 'object',
+        description: 'The effective model catalog.',
+        properties: { models: arrayOf(anyObject('A model\'s API, limits, capabilities, and pricing.')) },
+        required: ['models'],
+    },
+
+    RouteList: {
+        type: 'object',
+        description: 'Every method and path the data plane serves, sorted by path and method.',
+        properties: { routes: arrayOf(ref('Route')) },
+        required: ['routes'],
+    },
+
+    RoutingTest: {
+        type: 'object',
+        description: 'How a model would be routed, without calling anything.',
+        properties: { decision: anyObject('Provider, model rewrites, and the rules considered.') },
+        required: ['decision'],
+    },
+
+    TemplateList: {
+        type: 'object',
+        description: 'Prompt templates.',
+        properties: { templates: arrayOf(anyObject('A template with its versions.')) },
+        required: ['templates'],
+    },
+
+    ErasureRequest: anyObject('Whose interactions to erase: a tenant, a metadata entry, a thread key, or interaction IDs.'),
+    ErasureJob: anyObject('An erasure job: its status and scrubbed row counts.'),
+    LogState: anyObject('Log level and scoped debug overrides.'),
+    LogChange: anyObject('A log level, or a scoped debug override with its expiry.'),
+    SpillReplay: anyObject('Spilled writes replayed and failed.'),
+    RewrapResult: anyObject('Rows re-encrypted per table.'),
+    ModelMigrationRequest: anyObject('Model to retire, model to serve its traffic, and whether threads are reset.'),
+    ModelMigrationJob: anyObject('A model migration job: its status and cleared mapping counts.'),
+    BackfillJob: anyObject('A backfill job: its status and copied row counts.'),
+    ConsistencyReport: anyObject('Interactions compared on both dual-write stores, and those that differ.'),
+
+    ThreadStatePurge: {
+        type: 'object',
+        description: 'Mappings removed.',
+        properties: {
+            purged: integerField('Mappings removed.'),
+            before: integerField('Cutoff (ms).'),
+        },
+        required: ['purged', 'before'],
+    },
+
+    ThreadStateDeleted: {
+        type: 'object',
+        description: 'A removed mapping.',
+        properties: {
+            deleted: { const: true },
+            keyHash: stringField('SHA-256 of the thread key.'),
+        },
+        required: ['deleted', 'keyHash'],
+    },
+
+    ThreadExportRequest: anyObject('Tenants to export.'),
+    ThreadBundle: anyObject('A portable bundle of conversations, responses, and thread state, with its checksum.'),
+    ThreadImportResult: anyObject('Records imported, skipped, and remapped, and when.'),
+    ConsoleRequest: anyObject('App, model, messages in the app\'s frontdoor format, and dry_run.'),
+    ConsoleResult: anyObject('The request as each stage saw it, and the response.'),
+
+    TenantList: {
+        type: 'object',
+        description: 'Every tenant.',
+        properties: { tenants: arrayOf(ref('Tenant')) },
+        required: ['tenants'],
+    },
+
+    Tenant: object<TenantInfo>('A tenant.', {
+        id: stringField('Tenant ID.'),
+        name: stringField('Tenant name.'),
+        source: { type: 'string', enum: ['config', 'store'] },
+        disabled: booleanField('Whether the tenant\'s keys are rejected.'),
+        apiKeys: integerField('Number of API keys.'),
+        routing: anyObject('Tenant routing, when it has its own.'),
+        allowedProviders: arrayOf({ type: 'string' }, 'Providers the tenant\'s requests may be sent to.'),
+        createdAt: { type: 'string', format: 'date-time' },
+        updatedAt: { type: 'string', format: 'date-time' },
+    }, ['id', 'name', 'source', 'disabled', 'apiKeys']),
+
+    TenantCreate: anyObject('Tenant ID (default: generated), name, routing, and allowed providers.'),
+    TenantUpdate: anyObject('Name, and routing (null reverts to the global routing).'),
+
+    TenantCreated: {
+        type: 'object',
+        description: 'A new tenant, with its initial API key (only returned here).',
+        properties: {
+            tenant: ref('Tenant'),
+            apiKey: stringField('API key.'),
+        },
+        required: ['tenant', 'apiKey'],
+    },
+
+    BudgetStatus: anyObject('Budget limits, consumption this month, and when it resets.'),
+
+    SecretList: {
+        type: 'object',
+        description: 'A tenant\'s secrets\' metadata.',
+        properties: { secrets: arrayOf(ref('Secret')) },
+        required: ['secrets'],
+    },
+
+    Secret: object<SecretInfo>('A secret\'s metadata; values are never returned.', {
+        tenantId: stringField('Owning tenant.'),
+        name: stringField('Secret name.'),
+        reference: stringField('How config refers to it: <tenant>/<name>.'),
+        version: integerField('Incremented on every rotation.'),
+        keyId: stringField('Storage key the value is sealed with.'),
+        createdAt: { type: 'string', format: 'date-time' },
+        updatedAt: { type: 'string', format: 'date-time' },
+    }, ['tenantId', 'name', 'reference', 'version', 'createdAt', 'updatedAt']),
+
+    SecretValue: {
+        type: 'object',
+        description: 'A secret value to set.',
+        properties: { value: stringField('Secret value.') },
+        required: ['value'],
+    },
+
+    SecretDeleted: {
+        type: 'object',
+        description: 'A removed secret.',
+        properties: {
+            deleted: { const: true },
+            reference: stringField('<tenant>/<name>.'),
+        },
+        required: ['deleted', 'reference'],
+    },
+
+    InteractionDetail: anyObject('An interaction: its type, status, model, timings, and provider attempts.'),
+
+    InteractionEvents: {
+        type: 'object',
+        description: 'An interaction\'s events, oldest first.',
+        properties: { events: arrayOf(anyObject('An interaction event.')) },
+        required: ['events'],
+    },
+
+    ProviderRequests: anyObject('Request bodies sent upstream, with their SHA-256.'),
+    ShadowDiff: anyObject('Differences between the primary and shadow responses.'),
+    ShadowResult: anyObject('A shadow request\'s result.'),
+
+    AdminThreadList: {
+        type: 'object',
+        description: 'Threads, newest first.',
+        properties: {
+            threads: arrayOf(anyObject('A thread summary.')),
+            total: integerField('Threads returned.'),
+        },
+        required: ['threads', 'total'],
+    },
+
+    AdminThread: anyObject('A thread: its timestamps and metadata.'),
+
+    AdminResponseList: {
+        type: 'object',
+        description: 'Responses, newest first.',
+        properties: {
+            responses: arrayOf(ref('AdminResponse')),
+            total: integerField('Responses returned.'),
+        },
+        required: ['responses', 'total'],
+    },
+
+    AdminResponse: {
+        type: 'object',
+        description: 'A stored response.',
+        properties: {
+            id: stringField('Response ID.'),
+            status: stringField('Status.'),
+            model: stringField('Model.'),
+            createdAt: integerField('Creation time (ms).'),
+            updatedAt: integerField('Last update (ms).'),
+        },
+        required: ['id', 'status', 'model', 'createdAt', 'updatedAt'],
+    },
+
+    JSONSchemaDocument: anyObject('A JSON Schema (2020-12) document.'),
+
+    OpenAPIDocument: {
+        type: 'object',
+        description: 'An OpenAPI 3.1 document.',
+        properties: {
+            openapi: stringField('OpenAPI version.'),
+            info: anyObject('Title and version.'),
+            paths: anyObject('Operations by path and method.'),
+            components: anyObject('Schemas and security schemes.'),
+        },
+        required: ['openapi', 'info', 'paths'],
+    },
+};
+
+/**
+ * Every component schema, by name. Documents include those their
+ * operations reach.
+ */
+export const OPENAPI_SCHEMAS: Readonly<Record<string, JSONSchema>> = {
+    ...OPENAI_SCHEMAS,
+    ...ANTHROPIC_SCHEMAS,
+    ...COHERE_SCHEMAS,
+    ...RESPONSES_SCHEMAS,
+    ...GATEWAY_SCHEMAS,
+    ...ADMIN_SCHEMAS,
+};
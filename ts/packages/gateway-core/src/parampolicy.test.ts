@@ -0,0 +1,261 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { Gateway } from './gateway';
+import { createProviderRegistry } from './ports/index';
+import { AdminHandler } from './admin/index';
+import type { CanonicalRequest } from './domain/types';
+import type { AppConfig, GatewayConfig, ModelParameterPolicyConfig, ParameterPolicyConfig } from './ports/config';
+import { applyParameterPolicy, resolveParameterPolicy, type ResolvedParameterPolicy } from './parampolicy/index';
+import { WARNINGS_HEADER } from './warnings/collector';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+function request(fields: Partial<CanonicalRequest> = {}): CanonicalRequest {
+    return { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...fields } as CanonicalRequest;
+}
+
+function policy(fields: Partial<ResolvedParameterPolicy>): ResolvedParameterPolicy {
+    return { strict: false, rules: {}, exclusive: [], ...fields };
+}
+
+describe('applyParameterPolicy', () => {
+    const cases: Array<{
+        name: string;
+        policy: ResolvedParameterPolicy;
+        request: Partial<CanonicalRequest>;
+        effective: Partial<CanonicalRequest>;
+        reasons: string[];
+        warnings: number;
+    }> = [
+        {
+            name: 'clamps values above max and below min',
+            policy: policy({ rules: { temperature: { max: 1 }, top_p: { min: 0.5 } } }),
+            request: { temperature: 1.7, topP: 0.1 },
+            effective: { temperature: 1, topP: 0.5 },
+            reasons: ['temperature clamped', 'top_p clamped'],
+            warnings: 2,
+        },
+        {
+            name: 'leaves values within range alone',
+            policy: policy({ rules: { temperature: { min: 0, max: 1 } } }),
+            request: { temperature: 0.3 },
+            effective: { temperature: 0.3 },
+            reasons: [],
+            warnings: 0,
+        },
+        {
+            name: 'omits forbidden parameters',
+            policy: policy({ rules: { presence_penalty: { forbidden: true } } }),
+            request: { presencePenalty: 0.5, frequencyPenalty: 0.5 },
+            effective: { presencePenalty: undefined, frequencyPenalty: 0.5 },
+            reasons: ['presence_penalty forbidden'],
+            warnings: 1,
+        },
+        {
+            name: 'forces values, whether or not the client set them',
+            policy: policy({ rules: { temperature: { force: 1 }, max_tokens: { force: 256 } } }),
+            request: { temperature: 0.2 },
+            effective: { temperature: 1, maxTokens: 256 },
+            reasons: ['temperature forced', 'max_tokens forced'],
+            // Only replacing a value the client sent is worth a warning
+            warnings: 1,
+        },
+        {
+            name: 'keeps the first parameter of an exclusive group',
+            policy: policy({ exclusive: [['temperature', 'top_p']] }),
+            request: { temperature: 0.5, topP: 0.9 },
+            effective: { temperature: 0.5, topP: undefined },
+            reasons: ['top_p exclusive'],
+            warnings: 1,
+        },
+        {
+            name: 'keeps the forced parameter of an exclusive group',
+            policy: policy({ rules: { top_p: { force: 1 } }, exclusive: [['temperature', 'top_p']] }),
+            request: { temperature: 0.5 },
+            effective: { temperature: undefined, topP: 1 },
+            reasons: ['top_p forced', 'temperature exclusive'],
+            warnings: 1,
+        },
+    ];
+
+    it.each(cases)('$name', ({ policy, request: fields, effective, reasons, warnings }) => {
+        const req = request(fields);
+
+        const step = applyParameterPolicy(req, policy);
+
+        expect(req).toMatchObject(effective);
+        for (const [field, value] of Object.entries(effective)) {
+            expect(req[field as keyof CanonicalRequest]).toBe(value);
+        }
+        expect(step?.description.replace('Applied parameter policy: ', '').split(', ') ?? []).toEqual(reasons);
+        expect(step?.warnings?.length ?? 0).toBe(warnings);
+    });
+
+    it('should record the original and effective value of each change', () => {
+        const step = applyParameterPolicy(request({ temperature: 1.7 }), policy({ rules: { temperature: { max: 1 } } }));
+
+        expect(step).toMatchObject({
+            stage: 'parameter_policy',
+            details: { changes: [{ parameter: 'temperature', original: 1.7, effective: 1, reason: 'clamped' }] },
+            warnings: ['temperature 1.7 is outside the allowed range; clamped to 1'],
+        });
+    });
+
+    it('should reject violations of a strict policy, naming the parameter', () => {
+        const strict = (rules: ResolvedParameterPolicy['rules'], exclusive: ResolvedParameterPolicy['exclusive'] = []) =>
+            policy({ strict: true, rules, exclusive });
+
+        expect(() => applyParameterPolicy(request({ temperature: 1.7 }), strict({ temperature: { max: 1 } })))
+            .toThrow(expect.objectContaining({ statusCode: 400, param: 'temperature' }));
+        expect(() => applyParameterPolicy(request({ topP: 0.5 }), strict({ top_p: { forbidden: true } })))
+            .toThrow('is not allowed');
+        expect(() => applyParameterPolicy(request({ temperature: 0.5, topP: 0.5 }), strict({}, [['temperature', 'top_p']])))
+            .toThrow('cannot be combined with temperature');
+
+        // Forced values are not violations
+        const req = request({ temperature: 0.2 });
+        expect(applyParameterPolicy(req, strict({ temperature: { force: 1 } }))?.details).toEqual({
+            changes: [{ parameter: 'temperature', original: 0.2, effective: 1, reason: 'forced' }],
+        });
+    });
+});
+
+describe('resolveParameterPolicy', () => {
+    const app: ParameterPolicyConfig = { temperature: { max: 1.5 }, topP: { forbidden: true } };
+    const models: ModelParameterPolicyConfig[] = [
+        { models: ['claude-3-5-sonnet-latest'], temperature: { max: 0.5 } },
+        { models: ['claude-*'], temperature: { max: 1 }, strict: true },
+        { models: ['claude-3-*'], temperature: { max: 0.8 }, exclusive: [['temperature', 'top_p']] },
+        { models: ['gpt-*'], maxTokens: { max: 100 } },
+    ];
+
+    it('should let the most specific model policy win, parameter by parameter', () => {
+        expect(resolveParameterPolicy(app, models, 'claude-3-5-sonnet-latest')).toEqual({
+            strict: true,
+            rules: { temperature: { max: 0.5 }, top_p: { forbidden: true } },
+            exclusive: [['temperature', 'top_p']],
+        });
+        expect(resolveParameterPolicy(app, models, 'claude-3-haiku')?.rules.temperature).toEqual({ max: 0.8 });
+        expect(resolveParameterPolicy(app, models, 'claude-opus-4')?.rules.temperature).toEqual({ max: 1 });
+        expect(resolveParameterPolicy(app, models, 'gpt-4o')).toEqual({
+            strict: false,
+            rules: { temperature: { max: 1.5 }, top_p: { forbidden: true }, max_tokens: { max: 100 } },
+            exclusive: [],
+        });
+    });
+
+    it('should resolve nothing when no policy applies', () => {
+        expect(resolveParameterPolicy(undefined, models, 'llama-3')).toBeUndefined();
+        expect(resolveParameterPolicy(undefined, undefined, 'gpt-4o')).toBeUndefined();
+    });
+});
+
+describe('Parameter policy config', () => {
+    async function load(apps: AppConfig[], parameterPolicies?: GatewayConfig['parameterPolicies']): Promise<Gateway> {
+        const gateway = new Gateway({
+            config: { load: async () => ({ apps, parameterPolicies, providers: [{ name: 'mock', type: 'mock' }], routing: { defaultProvider: 'mock' } }) },
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            providerRegistry: createProviderRegistry(),
+        });
+        await gateway.reload();
+        return gateway;
+    }
+
+    it('should reject rules that cannot be met', async () => {
+        await expect(load([
+            { name: 'chat', frontdoor: 'openai', path: '/v1', parameterPolicy: { temperature: { min: 1.5, max: 1 } } },
+        ])).rejects.toThrow("Invalid config for app 'chat': parameter policy temperature.min (1.5) is greater than temperature.max (1)");
+        await expect(load([], [{ models: ['o1*'], temperature: { force: 1, forbidden: true } }]))
+            .rejects.toThrow('Invalid config for parameter policy 1: temperature is both forbidden and forced');
+        await expect(load([], [{ models: [], temperature: { max: 1 } }]))
+            .rejects.toThrow('Invalid config for parameter policy 1: must name the models it applies to');
+        await expect(load([], [{ models: ['gpt-*'], exclusive: [['temperature', 'seed' as any]] }]))
+            .rejects.toThrow("exclusive names unknown parameter 'seed'");
+    });
+});
+
+describe('Parameter policies', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const provider = new ScriptedProvider('mock', { text: 'Hi' });
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', parameterPolicy: { temperature: { max: 0.7 } } })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic' })
+            .provider(provider)
+            .config({
+                parameterPolicies: [
+                    { models: ['claude-*'], temperature: { max: 1 }, exclusive: [['temperature', 'top_p']] },
+                    { models: ['o1*'], strict: true, temperature: { force: 1 }, topP: { forbidden: true } },
+                ],
+            })
+            .start();
+        return { gw: gateway, provider };
+    }
+
+    it('should clamp what is sent upstream and warn the client', async () => {
+        const { gw, provider } = await setup();
+
+        const response = await gw.post('/v1/chat/completions', {
+            model: 'claude-sonnet-4',
+            temperature: 1.8,
+            top_p: 0.9,
+            messages: [{ role: 'user', content: 'Hi' }],
+        });
+
+        expect(response.status).toBe(200);
+        expect(provider.lastRequest).toMatchObject({ temperature: 1 });
+        expect(provider.lastRequest?.topP).toBeUndefined();
+        expect(JSON.parse(response.headers.get(WARNINGS_HEADER)!)).toEqual([
+            { code: 'parameter_policy', message: 'temperature 1.8 is outside the allowed range; clamped to 1' },
+            { code: 'parameter_policy', message: 'top_p cannot be combined with temperature; omitted' },
+        ]);
+    });
+
+    it('should apply the app\'s policy to models no model policy matches', async () => {
+        const { gw, provider } = await setup();
+
+        await gw.post('/v1/chat/completions', { model: 'gpt-4o', temperature: 0.9, messages: [{ role: 'user', content: 'Hi' }] });
+        expect(provider.lastRequest?.temperature).toBe(0.7);
+
+        await gw.post('/anthropic/v1/messages', {
+            model: 'gpt-4o', max_tokens: 100, temperature: 0.9, messages: [{ role: 'user', content: 'Hi' }],
+        });
+        expect(provider.lastRequest?.temperature).toBe(0.9);
+    });
+
+    it('should reject a strict policy\'s violations before calling the provider', async () => {
+        const { gw, provider } = await setup();
+
+        const response = await gw.post('/v1/chat/completions', { model: 'o1-mini', top_p: 0.5, messages: [{ role: 'user', content: 'Hi' }] });
+
+        expect(response.status).toBe(400);
+        expect((await response.json()).error).toMatchObject({ param: 'top_p', message: expect.stringContaining('is not allowed') });
+        expect(provider.requests).toHaveLength(0);
+
+        await gw.post('/v1/chat/completions', { model: 'o1-mini', temperature: 0.2, messages: [{ role: 'user', content: 'Hi' }] });
+        expect(provider.lastRequest?.temperature).toBe(1);
+    });
+
+    it('should list the policies in force in the admin overview', async () => {
+        const { gw } = await setup();
+        await gw.gateway.reload();
+        const admin = new AdminHandler({
+            storage: gw.store as any,
+            parameterPolicies: () => gw.gateway.parameterPolicies(),
+        });
+
+        const overview = await (await admin.handle(new Request('http://localhost/api/overview'))).json();
+
+        expect(overview.parameterPolicies).toEqual({
+            apps: { chat: { temperature: { max: 0.7 } } },
+            models: [
+                { models: ['claude-*'], temperature: { max: 1 }, exclusive: [['temperature', 'top_p']] },
+                { models: ['o1*'], strict: true, temperature: { force: 1 }, topP: { forbidden: true } },
+            ],
+        });
+    });
+});
@@ -0,0 +1,16 @@
+/**
+ * Sampling parameter policy exports.
+ *
+ * @module parampolicy
+ */
+
+export {
+    resolveParameterPolicy,
+    applyParameterPolicy,
+    validateParameterPolicy,
+    checkParameterPolicies,
+    summarizeParameterPolicies,
+    type ResolvedParameterPolicy,
+    type ParameterPolicyChange,
+    type ParameterPolicySummary,
+} from './policy.js';
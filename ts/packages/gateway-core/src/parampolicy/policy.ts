@@ -0,0 +1,300 @@
+/**
+ * Sampling parameter policies.
+ *
+ * An app's parameterPolicy and the gateway's per-model parameterPolicies
+ * keep the sampling parameters of requests within what the platform
+ * allows: ranges, forbidden parameters, forced values, and parameters that
+ * may not be combined. A request's policy is its app's, overridden
+ * parameter by parameter by the model policies matching its model, the
+ * most specific pattern last. It is applied once the request's model and
+ * provider are settled, before max_tokens is fitted to the model's cap.
+ *
+ * @module parampolicy/policy
+ */
+
+import type { CanonicalRequest } from '../domain/types.js';
+import type {
+    GatewayConfig,
+    ModelParameterPolicyConfig,
+    ParameterPolicyConfig,
+    ParameterRule,
+    PolicyParameter,
+} from '../ports/config.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import { invalidField } from '../codecs/validation.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Request and config field of each governed parameter. */
+const PARAMETER_FIELDS = {
+    temperature: 'temperature',
+    top_p: 'topP',
+    frequency_penalty: 'frequencyPenalty',
+    presence_penalty: 'presencePenalty',
+    max_tokens: 'maxTokens',
+} as const satisfies Record<PolicyParameter, keyof CanonicalRequest & keyof ParameterPolicyConfig>;
+
+const PARAMETERS = Object.keys(PARAMETER_FIELDS) as PolicyParameter[];
+
+/**
+ * The policy a request is held to: its app's and its model's, combined.
+ */
+export interface ResolvedParameterPolicy {
+    /** Violations are rejected rather than clamped. */
+    strict: boolean;
+
+    /** Rule of each governed parameter. */
+    rules: Partial<Record<PolicyParameter, ParameterRule>>;
+
+    /** Groups of parameters a request may set at most one of. */
+    exclusive: PolicyParameter[][];
+}
+
+/**
+ * A change a policy made to a request, as recorded on its step.
+ */
+export interface ParameterPolicyChange {
+    /** Parameter, by its wire name. */
+    parameter: PolicyParameter;
+
+    /** Value the client sent (null when it sent none). */
+    original: number | null;
+
+    /** Value sent upstream (null when omitted). */
+    effective: number | null;
+
+    /** Why: forced, forbidden, clamped to a bound, or excluded by another parameter. */
+    reason: 'forced' | 'forbidden' | 'clamped' | 'exclusive';
+}
+
+/**
+ * The configured policies, for the admin overview.
+ */
+export interface ParameterPolicySummary {
+    /** Each app's policy, by app name. */
+    apps: Record<string, ParameterPolicyConfig>;
+
+    /** Model policies, in config order. */
+    models: ModelParameterPolicyConfig[];
+}
+
+/** Transformation stage recording a policy's changes. */
+const PARAMETER_POLICY_STAGE = 'parameter_policy';
+
+// ============================================================================
+// Resolution
+// ============================================================================
+
+/**
+ * Combines an app's policy with the model policies matching a model: each
+ * matching policy, least specific first, replaces the rules of the
+ * parameters it sets (and strictness, when it sets it); exclusive groups
+ * add up. Returns undefined when no policy applies.
+ */
+export function resolveParameterPolicy(
+    app: ParameterPolicyConfig | undefined,
+    models: ModelParameterPolicyConfig[] | undefined,
+    model: string,
+): ResolvedParameterPolicy | undefined {
+    const matching = (models ?? [])
+        .map((policy) => ({ policy, specificity: Math.max(...policy.models.map((p) => specificity(p, model))) }))
+        .filter((m) => m.specificity >= 0)
+        .sort((a, b) => a.specificity - b.specificity)
+        .map((m) => m.policy);
+    const policies = app ? [app, ...matching] : matching;
+    if (policies.length === 0) {
+        return undefined;
+    }
+
+    const resolved: ResolvedParameterPolicy = { strict: false, rules: {}, exclusive: [] };
+    for (const policy of policies) {
+        resolved.strict = policy.strict ?? resolved.strict;
+        for (const parameter of PARAMETERS) {
+            resolved.rules[parameter] = policy[PARAMETER_FIELDS[parameter]] ?? resolved.rules[parameter];
+        }
+        resolved.exclusive.push(...(policy.exclusive ?? []));
+    }
+    return resolved;
+}
+
+/**
+ * How specifically a model pattern matches a model: the exact name beats
+ * any prefix, and longer prefixes beat shorter ones. -1 when it doesn't.
+ */
+function specificity(pattern: string, model: string): number {
+    if (pattern.endsWith('*')) {
+        const prefix = pattern.slice(0, -1);
+        return model.startsWith(prefix) ? prefix.length : -1;
+    }
+    return pattern === model ? Infinity : -1;
+}
+
+// ============================================================================
+// Application
+// ============================================================================
+
+/**
+ * Holds a request to a policy, in place: forced values replace the
+ * client's, then forbidden parameters are omitted and out-of-range values
+ * clamped to the nearest bound, then only the first parameter set of each
+ * exclusive group is kept (a forced one first). Each change is reported as
+ * a warning. A strict policy rejects the request with a 400 instead of
+ * changing anything but forced values. Returns the step applied, with
+ * every change, for interaction recording.
+ */
+export function applyParameterPolicy(
+    request: CanonicalRequest,
+    policy: ResolvedParameterPolicy | undefined,
+): TransformationStep | undefined {
+    if (!policy) {
+        return undefined;
+    }
+    const changes: ParameterPolicyChange[] = [];
+    const warnings: string[] = [];
+    const violation = (parameter: PolicyParameter, message: string): void => {
+        if (policy.strict) {
+            throw invalidField(parameter, message);
+        }
+    };
+    const set = (parameter: PolicyParameter, effective: number | undefined, reason: ParameterPolicyChange['reason']): void => {
+        const field = PARAMETER_FIELDS[parameter];
+        changes.push({ parameter, original: request[field] ?? null, effective: effective ?? null, reason });
+        request[field] = effective;
+    };
+
+    for (const parameter of PARAMETERS) {
+        const rule = policy.rules[parameter];
+        const original = request[PARAMETER_FIELDS[parameter]];
+        if (rule?.force !== undefined) {
+            if (original !== rule.force) {
+                if (original !== undefined) {
+                    warnings.push(`${parameter} ${original} was replaced by the gateway with ${rule.force}`);
+                }
+                set(parameter, rule.force, 'forced');
+            }
+            continue;
+        }
+        if (!rule || original === undefined) {
+            continue;
+        }
+        if (rule.forbidden) {
+            violation(parameter, 'is not allowed');
+            warnings.push(`${parameter} is not allowed; omitted`);
+            set(parameter, undefined, 'forbidden');
+            continue;
+        }
+        const effective = Math.min(Math.max(original, rule.min ?? -Infinity), rule.max ?? Infinity);
+        if (effective !== original) {
+            violation(parameter, effective < original ? `must be at most ${rule.max}` : `must be at least ${rule.min}`);
+            warnings.push(`${parameter} ${original} is outside the allowed range; clamped to ${effective}`);
+            set(parameter, effective, 'clamped');
+        }
+    }
+
+    for (const group of policy.exclusive) {
+        const present = group.filter((p) => request[PARAMETER_FIELDS[p]] !== undefined);
+        if (present.length < 2) {
+            continue;
+        }
+        const kept = present.find((p) => policy.rules[p]?.force !== undefined) ?? present[0]!;
+        for (const parameter of present.filter((p) => p !== kept)) {
+            violation(parameter, `cannot be combined with ${kept}`);
+            warnings.push(`${parameter} cannot be combined with ${kept}; omitted`);
+            set(parameter, undefined, 'exclusive');
+        }
+    }
+
+    if (changes.length === 0) {
+        return undefined;
+    }
+    return {
+        stage: PARAMETER_POLICY_STAGE,
+        timestamp: new Date(),
+        description: `Applied parameter policy: ${changes.map((c) => `${c.parameter} ${c.reason}`).join(', ')}`,
+        details: { changes },
+        warnings: warnings.length > 0 ? warnings : undefined,
+    };
+}
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Throws if a policy's rules can't be met: a non-numeric bound or forced
+ * value, min above max, a forced value outside its bounds or also
+ * forbidden, or an exclusive group with an unknown parameter, fewer than
+ * two, or two forced values.
+ */
+export function validateParameterPolicy(policy: ParameterPolicyConfig): void {
+    for (const parameter of PARAMETERS) {
+        const rule = policy[PARAMETER_FIELDS[parameter]];
+        if (!rule) continue;
+        for (const field of ['min', 'max', 'force'] as const) {
+            const value = rule[field];
+            if (value !== undefined && !(typeof value === 'number' && Number.isFinite(value))) {
+                throw new Error(`${parameter}.${field} must be a number, got ${String(value)}`);
+            }
+        }
+        if (rule.min !== undefined && rule.max !== undefined && rule.min > rule.max) {
+            throw new Error(`${parameter}.min (${rule.min}) is greater than ${parameter}.max (${rule.max})`);
+        }
+        if (rule.force !== undefined) {
+            if (rule.forbidden) {
+                throw new Error(`${parameter} is both forbidden and forced`);
+            }
+            if (rule.force < (rule.min ?? -Infinity) || rule.force > (rule.max ?? Infinity)) {
+                throw new Error(`${parameter}.force (${rule.force}) is outside its min and max`);
+            }
+        }
+    }
+    for (const group of policy.exclusive ?? []) {
+        if (!Array.isArray(group) || group.length < 2) {
+            throw new Error('exclusive groups must name at least two parameters');
+        }
+        const unknown = group.find((p) => !PARAMETERS.includes(p));
+        if (unknown !== undefined) {
+            throw new Error(`exclusive names unknown parameter '${String(unknown)}'; expected one of ${PARAMETERS.join(', ')}`);
+        }
+        const forced = group.filter((p) => policy[PARAMETER_FIELDS[p]]?.force !== undefined);
+        if (forced.length > 1) {
+            throw new Error(`exclusive group [${group.join(', ')}] forces both ${forced[0]} and ${forced[1]}`);
+        }
+    }
+}
+
+/**
+ * Fails the load if any app's or model's parameter policy is invalid.
+ */
+export function checkParameterPolicies(config: Pick<GatewayConfig, 'apps' | 'parameterPolicies'>): void {
+    for (const app of config.apps) {
+        if (!app.parameterPolicy) continue;
+        try {
+            validateParameterPolicy(app.parameterPolicy);
+        } catch (error) {
+            throw new Error(`Invalid config for app '${app.name}': parameter policy ${(error as Error).message}`);
+        }
+    }
+    for (const [i, policy] of (config.parameterPolicies ?? []).entries()) {
+        try {
+            if (!Array.isArray(policy.models) || policy.models.length === 0) {
+                throw new Error('must name the models it applies to');
+            }
+            validateParameterPolicy(policy);
+        } catch (error) {
+            throw new Error(`Invalid config for parameter policy ${i + 1}: ${(error as Error).message}`);
+        }
+    }
+}
+
+/**
+ * The configured policies, for the admin overview.
+ */
+export function summarizeParameterPolicies(config: Pick<GatewayConfig, 'apps' | 'parameterPolicies'>): ParameterPolicySummary {
+    return {
+        apps: Object.fromEntries(config.apps.flatMap((app) => app.parameterPolicy ? [[app.name, app.parameterPolicy]] : [])),
+        models: config.parameterPolicies ?? [],
+    };
+}
@@ -0,0 +1,125 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { upstreamErrorResponse } from './frontdoors/index';
+import { APIError } from './domain/errors';
+
+const upstreamBody = JSON.stringify({
+    error: {
+        message: 'max_tokens is too large: 100000. This model supports at most 4096 completion tokens (organization org-abc123).',
+        type: 'invalid_request_error',
+        param: 'max_tokens',
+        code: null,
+        doc_url: 'https://platform.example.com/docs/max-tokens',
+    },
+});
+
+function setup() {
+    const fetch = vi.fn(async () => new Response(upstreamBody, {
+        status: 400,
+        headers: {
+            'Content-Type': 'application/json',
+            'x-request-id': 'req_0123456789',
+            'openai-organization': 'org-abc123',
+            'x-ratelimit-remaining-requests': '99',
+        },
+    }));
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [
+                    { name: 'chat', frontdoor: 'openai', path: '/v1' },
+                    { name: 'strict', frontdoor: 'openai', path: '/strict', errorPassthrough: { enabled: false } },
+                    { name: 'custom', frontdoor: 'openai', path: '/custom', errorPassthrough: { redact: ['doc_url'] } },
+                    { name: 'claude', frontdoor: 'anthropic', path: '/anthropic' },
+                ],
+                providers: [{ name: 'openai', type: 'openai', apiKey: 'sk-test' }],
+                routing: { defaultProvider: 'openai' },
+            }),
+        },
+        auth: {
+            authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }),
+            getTenant: async () => null,
+        },
+        httpClientFactory: () => ({ fetch: fetch as any }),
+    });
+    const call = (path: string, body: Record<string, unknown>) => gateway.fetch(new Request(`http://localhost${path}`, {
+        method: 'POST',
+        headers: { Authorization: 'Bearer key', 'Content-Type': 'application/json' },
+        body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...body }),
+    }));
+    return { gateway, call };
+}
+
+/** Waits for attempts saved when the interaction ends. */
+const settled = () => new Promise((resolve) => setTimeout(resolve, 10));
+
+describe('Provider error passthrough', () => {
+    it('should return a same-API provider error as sent, minus redacted IDs', async () => {
+        const { call } = setup();
+
+        const response = await call('/v1/chat/completions', { max_tokens: 100000 });
+
+        expect(response.status).toBe(400);
+        expect(response.headers.get('x-ratelimit-remaining-requests')).toBe('99');
+        expect(response.headers.has('x-request-id')).toBe(false);
+        expect(response.headers.has('openai-organization')).toBe(false);
+        const body = await response.json();
+        expect(body.error).toMatchObject({
+            param: 'max_tokens',
+            doc_url: 'https://platform.example.com/docs/max-tokens',
+        });
+        expect(body.error.message).toBe(
+            'max_tokens is too large: 100000. This model supports at most 4096 completion tokens (organization [redacted]).',
+        );
+    });
+
+    it('should translate errors when disabled or across APIs', async () => {
+        const { call } = setup();
+
+        const strict = await call('/strict/chat/completions', {});
+        expect(strict.status).toBe(400);
+        expect(strict.headers.has('x-ratelimit-remaining-requests')).toBe(false);
+        expect((await strict.json()).error).not.toHaveProperty('doc_url');
+
+        const anthropic = await call('/anthropic/v1/messages', { max_tokens: 100000 });
+        expect(anthropic.status).toBe(400);
+        expect(await anthropic.json()).toMatchObject({
+            type: 'error',
+            error: { type: 'invalid_request_error' },
+        });
+    });
+
+    it('should strip configured fields too', async () => {
+        const { call } = setup();
+
+        const body = await (await call('/custom/chat/completions', {})).json();
+
+        expect(body.error).not.toHaveProperty('doc_url');
+        expect(body.error.param).toBe('max_tokens');
+    });
+
+    it('should keep the original upstream body on the attempt record', async () => {
+        const { gateway, call } = setup();
+
+        const response = await call('/strict/chat/completions', {});
+        const id = response.headers.get('X-Gateway-Interaction-Id')!;
+        await settled();
+
+        const [attempt] = await gateway.attempts.listAttempts(id, 'acme');
+        expect(attempt).toMatchObject({ outcome: 'error', statusCode: 400, upstreamError: upstreamBody });
+    });
+});
+
+describe('upstreamErrorResponse', () => {
+    it('should pass untouched bodies through byte for byte', async () => {
+        const body = '{ "error": { "message": "bad",  "type": "invalid_request_error" } }';
+        const error = new APIError('invalid_request', 'bad').withUpstream({ api: 'openai', status: 422, body, headers: {} });
+
+        const response = upstreamErrorResponse(error, 'openai');
+
+        expect(response!.status).toBe(422);
+        expect(await response!.text()).toBe(body);
+        expect(upstreamErrorResponse(error, 'anthropic')).toBeUndefined();
+        expect(upstreamErrorResponse(new APIError('invalid_request', 'bad'), 'openai')).toBeUndefined();
+    });
+});
@@ -0,0 +1,207 @@
+/**
+ * Anthropic endpoint passthrough.
+ *
+ * Anthropic-native clients call endpoints beyond /v1/messages (model
+ * lookups, token counting behind beta headers, endpoints the gateway has
+ * not modeled). For anthropic apps with passthrough on, requests under the
+ * app's base path that no route serves are forwarded as they are to the
+ * Anthropic provider the request routes to, with the provider's key in
+ * place of the client's. Tenant auth still applies, the app's allow and
+ * deny lists pick which paths may be forwarded, and a request routed to a
+ * provider that isn't Anthropic's is refused. Response bodies are streamed
+ * through without buffering; only the method, path, status and latency
+ * are recorded.
+ *
+ * @module passthrough/endpoints
+ */
+
+import type { AppConfig, EndpointPassthroughConfig } from '../ports/config.js';
+import type { ProviderCredentials } from '../ports/provider.js';
+import type { FrontdoorContext, FrontdoorResponse } from '../frontdoors/types.js';
+import { APIError, errNotFound } from '../domain/errors.js';
+import { createInteractionEvent } from '../domain/events.js';
+import { anthropicCodec } from '../codecs/anthropic.js';
+import { anthropicVersionHeaders } from '../providers/versions.js';
+import { PROTECTED_HEADERS } from '../utils/headers.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+const DEFAULT_BASE_URL = 'https://api.anthropic.com';
+
+/** Response headers that describe the upstream connection or encoding, not the body passed on. */
+const UPSTREAM_ONLY_HEADERS: ReadonlySet<string> = new Set([...PROTECTED_HEADERS, 'content-encoding']);
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Where an Anthropic provider's passthrough requests go.
+ */
+export interface PassthroughTarget {
+    /** Provider base URL (default: https://api.anthropic.com). */
+    baseUrl?: string | undefined;
+
+    /** Provider key source. */
+    credentials: ProviderCredentials;
+
+    /** Fetch implementation (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+
+    /** The provider's pinned anthropic-version, sent when the client sends none. */
+    apiVersion?: string | undefined;
+
+    /** The provider's anthropic-beta features, sent when the client sends none. */
+    betaFeatures?: string[] | undefined;
+}
+
+/**
+ * EndpointPassthrough options.
+ */
+export interface EndpointPassthroughOptions {
+    /** Returns a provider's target, or undefined if it isn't an Anthropic provider. */
+    target: (provider: string) => PassthroughTarget | undefined;
+}
+
+// ============================================================================
+// Paths
+// ============================================================================
+
+/**
+ * Returns the upstream path of a request under an app: the path past the
+ * app's base path, under /v1.
+ */
+export function passthroughPath(app: Pick<AppConfig, 'path'>, path: string): string {
+    const base = app.path.replace(/\/$/, '');
+    const rest = path.startsWith(`${base}/`) ? path.slice(base.length) : path;
+    return rest.startsWith('/v1/') ? rest : `/v1${rest}`;
+}
+
+/**
+ * Whether an app forwards an upstream path: passthrough is on, the path
+ * matches an allow pattern (any, when none are set), and no deny pattern.
+ * Patterns are paths where `*` matches any run of characters.
+ */
+export function passthroughAllows(config: EndpointPassthroughConfig | undefined, path: string): boolean {
+    if (!config || config.enabled === false) {
+        return false;
+    }
+    if (config.deny?.some((pattern) => globMatches(pattern, path))) {
+        return false;
+    }
+    return !config.allow?.length || config.allow.some((pattern) => globMatches(pattern, path));
+}
+
+function globMatches(pattern: string, path: string): boolean {
+    const source = pattern.split('*').map((part) => part.replace(/[.*+?^${}()|[\]\\]/g, '\\$&')).join('.*');
+    return new RegExp(`^${source}$`).test(path);
+}
+
+// ============================================================================
+// Endpoint Passthrough
+// ============================================================================
+
+/**
+ * Forwards an anthropic app's unmodeled endpoints to its Anthropic provider.
+ */
+export class EndpointPassthrough {
+    private readonly target: EndpointPassthroughOptions['target'];
+
+    constructor(options: EndpointPassthroughOptions) {
+        this.target = options.target;
+    }
+
+    /**
+     * Forwards a request the frontdoor has no handler for, or answers 404
+     * when the app doesn't forward its path.
+     */
+    async handle(ctx: FrontdoorContext): Promise<FrontdoorResponse> {
+        const { request, app, provider } = ctx;
+        const url = new URL(request.url);
+        const path = app ? passthroughPath(app, url.pathname) : url.pathname;
+        if (!app || !passthroughAllows(app.passthrough, path)) {
+            return errorResponse(errNotFound('Not found'));
+        }
+        const target = this.target(provider.name);
+        if (!target) {
+            return errorResponse(new APIError(
+                'invalid_request',
+                `Passthrough is only supported on Anthropic providers; this request routes to '${provider.name}'`,
+                { statusCode: 501 },
+            ));
+        }
+
+        // The client's credentials are replaced with the provider's key
+        const lease = target.credentials.acquire();
+        const headers: Record<string, string> = anthropicVersionHeaders(target.apiVersion, target.betaFeatures);
+        request.headers.forEach((value, name) => {
+            if (!PROTECTED_HEADERS.has(name) && name !== 'accept-encoding') {
+                headers[name] = value;
+            }
+        });
+        headers['x-api-key'] = lease.key;
+
+        const startedAt = Date.now();
+        let upstream: Response;
+        try {
+            upstream = await (target.fetch ?? globalThis.fetch.bind(globalThis))(
+                `${(target.baseUrl ?? DEFAULT_BASE_URL).replace(/\/$/, '')}${path}${url.search}`,
+                {
+                    method: request.method,
+                    headers: ctx.upstreamHeaders?.apply(headers) ?? headers,
+                    body: request.method === 'GET' || request.method === 'HEAD' ? undefined : await request.arrayBuffer(),
+                    signal: request.signal,
+                },
+            );
+        } catch (error) {
+            const message = error instanceof Error ? error.message : String(error);
+            ctx.logger?.warn('passthrough_failed', { path, error: message });
+            this.record(ctx, { method: request.method, path, status: 502, latencyMs: Date.now() - startedAt });
+            return errorResponse(new APIError('server', `Upstream request failed: ${message}`, { statusCode: 502 }));
+        }
+        target.credentials.report(lease, upstream.status);
+        this.record(ctx, { method: request.method, path, status: upstream.status, latencyMs: Date.now() - startedAt });
+
+        const responseHeaders = new Headers();
+        upstream.headers.forEach((value, name) => {
+            if (!UPSTREAM_ONLY_HEADERS.has(name)) {
+                responseHeaders.set(name, value);
+            }
+        });
+        return {
+            response: new Response(upstream.body, {
+                status: upstream.status,
+                statusText: upstream.statusText,
+                headers: responseHeaders,
+            }),
+            metadata: { passthrough_path: path, provider_key: lease.id },
+        };
+    }
+
+    /**
+     * Saves the forwarded call as an interaction event, without bodies.
+     */
+    private record(
+        ctx: FrontdoorContext,
+        call: { method: string; path: string; status: number; latencyMs: number },
+    ): void {
+        ctx.events?.saveEvent(createInteractionEvent('passthrough', ctx.interactionId, { ...call, provider: ctx.provider.name }))
+            .catch((error: unknown) => {
+                ctx.logger?.warn('passthrough_event_save_failed', {
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            });
+    }
+}
+
+function errorResponse(error: APIError): FrontdoorResponse {
+    const { body } = anthropicCodec.encodeError(error);
+    return {
+        response: new Response(body, {
+            status: error.statusCode,
+            headers: { 'Content-Type': 'application/json' },
+        }),
+    };
+}
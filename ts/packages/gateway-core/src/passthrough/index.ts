@@ -0,0 +1,13 @@
+/**
+ * Endpoint passthrough exports.
+ *
+ * @module passthrough
+ */
+
+export {
+    EndpointPassthrough,
+    passthroughPath,
+    passthroughAllows,
+    type PassthroughTarget,
+    type EndpointPassthroughOptions,
+} from './endpoints.js';
@@ -0,0 +1,139 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { Gateway } from './gateway';
+import { AdminHandler } from './admin/index';
+import { ShadowExecutor } from './shadow/index';
+import { hashAPIKey, createProviderRegistry } from './ports/index';
+import type { AppConfig } from './ports/index';
+import type { LifecycleEvent } from './domain/events';
+import type { CanonicalRequest, CanonicalResponse } from './domain/types';
+
+function mockProvider(name: string) {
+    return {
+        name,
+        apiType: 'openai' as const,
+        complete: vi.fn(async (req: any): Promise<CanonicalResponse> => ({
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'openai',
+            usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: name } }],
+        })),
+        stream: vi.fn(),
+    };
+}
+
+async function setup(app: Partial<AppConfig> = {}) {
+    const providers = { 'anthropic-eu': mockProvider('anthropic-eu'), openai: mockProvider('openai') };
+    const providerRegistry = createProviderRegistry();
+    providerRegistry.register('mock', (c) => providers[c.name as keyof typeof providers] as any);
+    const published: LifecycleEvent[] = [];
+    const logger: any = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: () => logger };
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [{ name: 'chat', frontdoor: 'openai', path: '/v1', ...app }],
+                providers: [
+                    { name: 'anthropic-eu', type: 'mock', apiKey: '' },
+                    { name: 'openai', type: 'mock', apiKey: '' },
+                ],
+                routing: { defaultProvider: 'anthropic-eu' },
+                tenants: [{
+                    id: 'acme',
+                    name: 'Acme',
+                    apiKeys: [{ keyHash: await hashAPIKey('acme-key') }],
+                    allowedProviders: ['anthropic-eu'],
+                }],
+            }),
+        },
+        auth: { authenticate: async () => null, getTenant: async () => null },
+        events: { publish: async (e: LifecycleEvent) => void published.push(e) } as any,
+        providerRegistry,
+        logger,
+    });
+    const chat = (model: string) => gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+        method: 'POST',
+        headers: { Authorization: 'Bearer acme-key', 'Content-Type': 'application/json' },
+        body: JSON.stringify({ model, messages: [{ role: 'user', content: 'Hi' }] }),
+    }));
+    return { gateway, providers, published, logger, chat };
+}
+
+describe('Tenant provider allowlist', () => {
+    afterEach(() => {
+        vi.unstubAllGlobals();
+    });
+
+    it('should serve requests that resolve to an allowed provider', async () => {
+        const { providers, chat } = await setup();
+
+        const response = await chat('claude-3-5-sonnet');
+
+        expect(response.status).toBe(200);
+        expect(providers['anthropic-eu'].complete).toHaveBeenCalledTimes(1);
+    });
+
+    it('should deny a request whose fallback provider is not allowed', async () => {
+        const { providers, published, logger, chat } = await setup({
+            modelRouting: { fallback: { provider: 'openai', model: 'gpt-4o' } },
+        });
+
+        const response = await chat('some-model');
+
+        expect(response.status).toBe(403);
+        expect((await response.json()).error).toMatchObject({ code: 'provider_policy_denied' });
+        expect(providers.openai.complete).not.toHaveBeenCalled();
+        expect(logger.warn).toHaveBeenCalledWith('provider_policy_denied', { provider: 'openai', allowed: 'anthropic-eu' });
+        await vi.waitFor(() => expect(published).toHaveLength(1));
+        expect(published[0]!.data).toMatchObject({
+            providerName: 'openai',
+            statusCode: 403,
+            errorType: 'provider_policy_denied',
+        });
+    });
+
+    it('should never mirror a restricted tenant\'s requests', async () => {
+        const fetch = vi.fn(async () => new Response('{}'));
+        vi.stubGlobal('fetch', fetch);
+        const { chat } = await setup({ mirror: { url: 'https://staging.example' } });
+
+        expect((await chat('claude-3-5-sonnet')).status).toBe(200);
+        expect(fetch).not.toHaveBeenCalled();
+    });
+
+    it('should show the allowlist in the tenant summary', async () => {
+        const { gateway } = await setup();
+        await gateway.reload();
+
+        const response = await new AdminHandler({ tenants: gateway.tenants }).handle(new Request('http://localhost/api/tenants/acme'));
+
+        expect((await response.json()).allowedProviders).toEqual(['anthropic-eu']);
+    });
+});
+
+describe('Shadow provider allowlist', () => {
+    const request: CanonicalRequest = { tenantId: 'acme', model: 'claude-3-5-sonnet', messages: [{ role: 'user', content: 'Hi' }] };
+
+    it('should record a denied shadow without calling its provider', async () => {
+        const providers = { 'anthropic-eu': mockProvider('anthropic-eu'), openai: mockProvider('openai') };
+        const create = vi.fn((_type: string, c: { name: string }) => providers[c.name as keyof typeof providers] as any);
+        const saveShadowResult = vi.fn(async () => undefined);
+        const executor = new ShadowExecutor({
+            providerRegistry: { create } as any,
+            storage: { saveShadowResult } as any,
+            allowedProviders: (tenantId) => (tenantId === 'acme' ? ['anthropic-eu'] : undefined),
+        });
+        const primary = await providers['anthropic-eu'].complete(request);
+
+        const results = await executor.executeAll('int-1', request, primary, {
+            enabled: true,
+            providers: [{ name: 'openai' }, { name: 'anthropic-eu' }],
+        });
+
+        expect(results[0]).toMatchObject({
+            providerName: 'openai',
+            error: { type: 'provider_policy_denied', statusCode: 403 },
+        });
+        expect(results[1]!.error).toBeUndefined();
+        expect(create.mock.calls.map(([, c]) => c.name)).toEqual(['anthropic-eu']);
+        expect(providers.openai.complete).not.toHaveBeenCalled();
+        expect(saveShadowResult).toHaveBeenCalledTimes(2);
+    });
+});
@@ -25,6 +25,9 @@ export interface AuthContext {
 
     /** Additional metadata. */
     metadata: Record<string, string>;
+
+    /** App the key is bound to, chosen among apps sharing a request's path. */
+    app?: string | undefined;
 }
 
 /**
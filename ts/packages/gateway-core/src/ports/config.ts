@@ -5,6 +5,7 @@
  */
 
 import type { ShadowConfig } from '../domain/shadow.js';
+import type { ModelInfo } from '../domain/catalog.js';
 
 // ============================================================================
 // Configuration Types
@@ -31,18 +32,241 @@ export interface GatewayConfig {
 
     /** Global routing configuration. */
     routing?: RoutingConfig | undefined;
+
+    /** Idempotency-Key handling. */
+    idempotency?: IdempotencyConfig | undefined;
+
+    /** Model catalog entries (override or extend the built-in defaults). */
+    models?: ModelInfo[] | undefined;
+
+    /** Separate listener for the admin control plane (unset = mounted on the main listener). */
+    admin?: AdminListenerConfig | undefined;
+
+    /** HTTP-backed tools the gateway can execute on the model's behalf. */
+    tools?: GatewayToolConfig[] | undefined;
+
+    /** External sink that receives every completed interaction. */
+    events?: EventsConfig | undefined;
+
+    /** Prompt templates requests can reference by name (chat and Responses frontdoors). */
+    templates?: PromptTemplateConfig[] | undefined;
+
+    /** Exact tokenizers for the token count endpoint. */
+    tokenCount?: TokenCountConfig | undefined;
+
+    /** Caps on provider calls in flight, with fair queuing between tenants. */
+    concurrency?: ConcurrencyConfig | undefined;
+
+    /** Hashing of the end-user IDs clients send, for usage reports and forwarding. */
+    endUsers?: EndUserConfig | undefined;
+
+    /** Default caps on the provider calls one client request can make (apps can override). */
+    callBudget?: CallBudgetConfig | undefined;
+
+    /** Sampling parameter policies by model, over each app's parameterPolicy. */
+    parameterPolicies?: ModelParameterPolicyConfig[] | undefined;
+
+    /** Checks of the completion tokens providers report against the output relayed. */
+    usageCheck?: UsageCheckConfig | undefined;
+
+    /** Sweeping of interactions left in_progress by a gateway that went away. */
+    interactionStatus?: InteractionStatusConfig | undefined;
+}
+
+/**
+ * Interaction status. Every request's interaction record is saved
+ * in_progress once its frontdoor decodes it and updated when it finishes;
+ * records still in_progress after abandonAfter are marked abandoned, at
+ * startup and every sweepInterval.
+ */
+export interface InteractionStatusConfig {
+    /** Age at which an in_progress interaction is abandoned (default "15m"); keep it above the longest request. */
+    abandonAfter?: string | undefined;
+
+    /** Time between sweeps (default "1m"). */
+    sweepInterval?: string | undefined;
+}
+
+/**
+ * Verification of provider-reported completion tokens. At the end of each
+ * response the output relayed is counted with the model's tokenizer; a
+ * count that differs from the reported one by more than the threshold is
+ * recorded as a usage discrepancy. Clients see no difference.
+ */
+export interface UsageCheckConfig {
+    /** Check responses (default: true). */
+    enabled?: boolean | undefined;
+
+    /** Relative difference, of the larger count, over which counts disagree (default: 0.5). */
+    threshold?: number | undefined;
+
+    /** Responses where both counts are below this are not compared; short outputs estimate poorly (default: 20). */
+    minTokens?: number | undefined;
+}
+
+/** Request parameters a parameter policy governs, by their wire names. */
+export type PolicyParameter = 'temperature' | 'top_p' | 'frequency_penalty' | 'presence_penalty' | 'max_tokens';
+
+/**
+ * What one parameter may be. Only parameters the client sends are
+ * checked; a forced value is sent whether or not the client sent one.
+ */
+export interface ParameterRule {
+    /** Lowest value allowed. */
+    min?: number | undefined;
+
+    /** Highest value allowed. */
+    max?: number | undefined;
+
+    /** The parameter may not be sent. */
+    forbidden?: boolean | undefined;
+
+    /** Value sent instead of the client's. */
+    force?: number | undefined;
+}
+
+/**
+ * Sampling parameter hygiene for the requests of an app or model.
+ * Violations are clamped (an out-of-range value to the nearest bound, a
+ * forbidden or excluded parameter omitted) with a gateway warning, or
+ * rejected with a 400 when strict.
+ */
+export interface ParameterPolicyConfig {
+    /** Reject violating requests instead of clamping them (default: false). */
+    strict?: boolean | undefined;
+
+    /** Sampling temperature. */
+    temperature?: ParameterRule | undefined;
+
+    /** Nucleus sampling. */
+    topP?: ParameterRule | undefined;
+
+    /** Frequency penalty. */
+    frequencyPenalty?: ParameterRule | undefined;
+
+    /** Presence penalty. */
+    presencePenalty?: ParameterRule | undefined;
+
+    /** Output token limit, before it is fitted to the model's cap. */
+    maxTokens?: ParameterRule | undefined;
+
+    /** Groups of parameters a request may set at most one of (e.g. [[temperature, top_p]]); the first set is kept. */
+    exclusive?: PolicyParameter[][] | undefined;
+}
+
+/**
+ * A parameter policy for some models. Where several match a model, the
+ * most specific pattern's rules win, parameter by parameter.
+ */
+export interface ModelParameterPolicyConfig extends ParameterPolicyConfig {
+    /** Models it applies to: exact names, or prefixes ending in `*`. */
+    models: string[];
+}
+
+/**
+ * Caps on the provider calls one client request makes, counting those the
+ * gateway makes on its own (tool loops, JSON repairs, retries). Once one
+ * is reached, no further automatic calls are made and the request gets
+ * the best result so far. The client's own call is always made.
+ */
+export interface CallBudgetConfig {
+    /** Provider calls, the client's own included (default: 10). */
+    maxCalls?: number | undefined;
+
+    /** Upstream tokens (prompt and completion) across the calls (default: unlimited). */
+    maxTokens?: number | undefined;
+
+    /** Time from the request's start after which no automatic call is started (e.g. "60s", default: unlimited). */
+    maxTime?: string | undefined;
+}
+
+/**
+ * End-user ID hashing. Without a salt, end-user IDs are forwarded but
+ * not recorded, and apps that forward only the hash forward nothing.
+ */
+export interface EndUserConfig {
+    /** Per-deployment secret mixed into every end-user hash. */
+    salt?: string | undefined;
+}
+
+/**
+ * Provider call concurrency. Calls over a cap wait in their tenant's
+ * queue; freed capacity goes to the highest priority class waiting (low
+ * priority gets a small share meanwhile), and within it to the waiting
+ * tenant with the fewest calls in flight for its weight. Unset caps are
+ * unlimited.
+ */
+export interface ConcurrencyConfig {
+    /** Provider calls in flight across all tenants. */
+    maxInFlight?: number | undefined;
+
+    /** Provider calls in flight per tenant (tenants can override). */
+    tenantMaxInFlight?: number | undefined;
+
+    /** Provider calls in flight per tenant and provider. */
+    tenantProviderMaxInFlight?: number | undefined;
+
+    /** Calls each tenant may have waiting (default: 100); more fail with 429 at once. */
+    queueSize?: number | undefined;
+
+    /** How long a call waits before failing with 429 (e.g. "10s", default: "30s"). */
+    queueTimeout?: string | undefined;
+
+    /** Per priority class overrides of queueTimeout (e.g. low: "5s"). */
+    priorityQueueTimeouts?: Partial<Record<RequestPriority, string>> | undefined;
+
+    /** Share of freed capacity low priority calls get while higher classes wait, in [0, 1] (default: 0.1). */
+    lowPriorityShare?: number | undefined;
+}
+
+/** Priority class of a request's provider calls. */
+export type RequestPriority = 'high' | 'normal' | 'low';
+
+/** A tenant's concurrency overrides. */
+export interface TenantConcurrencyConfig {
+    /** Provider calls in flight (overrides concurrency.tenantMaxInFlight). */
+    maxInFlight?: number | undefined;
+
+    /** Share of freed capacity relative to other waiting tenants (default: 1). */
+    weight?: number | undefined;
+}
+
+/**
+ * Token count configuration. Without an encoding file, a family's counts
+ * are approximated and marked inexact.
+ */
+export interface TokenCountConfig {
+    /** tiktoken rank files (e.g. o200k_base.tiktoken) by family (runtimes with a filesystem only). */
+    encodings?: {
+        o200k?: string | undefined;
+        cl100k?: string | undefined;
+    } | undefined;
 }
 
 /** Server configuration. */
 export interface ServerConfig {
     /** Port to listen on. */
     port?: number | undefined;
+
+    /** Largest request body accepted, in bytes (default 32 MiB); larger bodies are refused with a 413. */
+    maxRequestBytes?: number | undefined;
+}
+
+/**
+ * Admin control plane listener configuration.
+ */
+export interface AdminListenerConfig {
+    /** Port for the admin listener. */
+    port: number;
+
+    /** Address to bind (default "127.0.0.1"). */
+    bind?: string | undefined;
 }
 
 /** Storage configuration. */
 export interface StorageConfig {
-    /** Storage type. */
-    type: 'sqlite' | 'postgres' | 'mysql' | 'memory' | 'd1' | 'none';
+    /** Storage type; "dual" writes to a primary and a secondary store while migrating between them. */
+    type: 'sqlite' | 'postgres' | 'mysql' | 'memory' | 'd1' | 'none' | 'dual';
 
     /** SQLite configuration. */
     sqlite?: {
@@ -54,6 +278,165 @@ export interface StorageConfig {
         driver: string;
         dsn: string;
     } | undefined;
+
+    /** Apply pending schema migrations when the gateway loads its config. */
+    autoMigrate?: boolean | undefined;
+
+    /** Durable spill for usage writes that fail (runtimes with a filesystem only). */
+    spill?: SpillConfig | undefined;
+
+    /** Encrypt prompt and completion text before it is stored. */
+    encryption?: StorageEncryptionConfig | undefined;
+
+    /** When failing storage trips degraded mode, and how recovery is probed. */
+    health?: StorageHealthConfig | undefined;
+
+    /** Store every write goes to first (type "dual"). */
+    primary?: StorageConfig | undefined;
+
+    /** Store writes are mirrored to (type "dual"). */
+    secondary?: StorageConfig | undefined;
+
+    /** Side that serves reads (type "dual", default "primary"). */
+    readFrom?: 'primary' | 'secondary' | undefined;
+
+    /**
+     * What a failed secondary write does (type "dual"): "primary_only"
+     * (default) mirrors writes in the background and only counts failures;
+     * "require_both" writes both sides and fails the write with either.
+     */
+    failMode?: 'primary_only' | 'require_both' | undefined;
+
+    /** Secondary writes waiting at once in primary_only mode (type "dual", default 10000). */
+    maxQueue?: number | undefined;
+}
+
+/**
+ * Storage health supervision. After enough consecutive failed writes the
+ * gateway runs degraded: recording is skipped, endpoints that need
+ * storage answer 503, and completions keep flowing until a recovery
+ * probe succeeds.
+ */
+export interface StorageHealthConfig {
+    /** Consecutive failed writes that trip degraded mode (default 5). */
+    failureThreshold?: number | undefined;
+
+    /** Delay between recovery probes while degraded (default "5s"). */
+    probeInterval?: string | undefined;
+}
+
+/**
+ * Storage encryption at rest. Sensitive fields (response request/response
+ * bodies, message content, event payloads) are sealed with AES-256-GCM
+ * and tagged with the ID of the key that sealed them.
+ */
+export interface StorageEncryptionConfig {
+    /** Keys, newest first. New writes use the first; reads use the key named in each value. */
+    keys: StorageKeyConfig[];
+}
+
+/** One storage encryption key. */
+export interface StorageKeyConfig {
+    /** Key ID stored with each value; letters, digits, '.', '_' and '-'. */
+    id: string;
+
+    /** Base64-encoded 256-bit key; supports ${env:VAR}. */
+    key: string;
+}
+
+/**
+ * Write spill configuration. Usage records and request stats that fail to
+ * write are appended to files in `dir` and replayed to storage in the
+ * background.
+ */
+export interface SpillConfig {
+    /** Directory for spill files; created if missing. */
+    dir: string;
+
+    /** Cap on the total size of spill files in bytes (default 64 MiB); records beyond it are dead-lettered to the error log. */
+    maxBytes?: number | undefined;
+
+    /** Size at which a spill file is rotated, in bytes (default 4 MiB). */
+    segmentBytes?: number | undefined;
+
+    /** Delay before retrying a failed replay (default "5s"); doubles per failure, up to 5 minutes. */
+    replayInterval?: string | undefined;
+}
+
+/** Idempotency configuration. */
+export interface IdempotencyConfig {
+    /** Honor Idempotency-Key headers (default: true). */
+    enabled?: boolean | undefined;
+
+    /** How long a key is remembered (e.g., "24h"). */
+    ttl?: string | undefined;
+}
+
+/**
+ * Analytics event sink configuration. Completed interactions are queued in
+ * memory and delivered in batches; delivery never blocks a request.
+ */
+export interface EventsConfig {
+    /** Sink type. */
+    sink: 'webhook' | 'kafka';
+
+    /** Event contents: "summary" (default) or "full" (adds canonical request and response). */
+    payload?: 'summary' | 'full' | undefined;
+
+    /** Replace message text and tool arguments in full payloads with "[redacted]". */
+    redactContent?: boolean | undefined;
+
+    /** Events held while the sink catches up; newer events are dropped beyond it (default 10000). */
+    queueSize?: number | undefined;
+
+    /** Events per delivery (default 100). */
+    batchSize?: number | undefined;
+
+    /** Longest an event waits for a full batch (default "1s"). */
+    flushInterval?: string | undefined;
+
+    /** Retries per batch before it is dead-lettered to the log (default 3). */
+    retries?: number | undefined;
+
+    /** Webhook sink settings (sink: webhook). */
+    webhook?: EventsWebhookConfig | undefined;
+
+    /** Kafka sink settings (sink: kafka). */
+    kafka?: EventsKafkaConfig | undefined;
+}
+
+/** Webhook event sink: each batch is POSTed as {"events": [...]}. */
+export interface EventsWebhookConfig {
+    /** Endpoint URL. */
+    url: string;
+
+    /** Additional request headers (e.g., Authorization). */
+    headers?: Record<string, string> | undefined;
+
+    /** Request timeout (default "10s"). */
+    timeout?: string | undefined;
+}
+
+/** Kafka event sink: each event is one message keyed by interaction ID. */
+export interface EventsKafkaConfig {
+    /** Bootstrap brokers (host:port). */
+    brokers: string[];
+
+    /** Topic to produce to. */
+    topic: string;
+
+    /** Client ID (default "polyglot-llm-gateway"). */
+    clientId?: string | undefined;
+
+    /** Connect over TLS. */
+    tls?: boolean | undefined;
+
+    /** SASL authentication. */
+    sasl?: {
+        mechanism: 'plain' | 'scram-sha-256' | 'scram-sha-512';
+        username: string;
+        password: string;
+    } | undefined;
 }
 
 /** Tenant configuration. */
@@ -72,6 +455,31 @@ export interface TenantConfig {
 
     /** Tenant-specific routing. */
     routing?: RoutingConfig | undefined;
+
+    /** Monthly usage budget. */
+    budget?: BudgetConfig | undefined;
+
+    /**
+     * Providers this tenant's requests may be sent to (default: any). Checked
+     * on the provider a request finally resolves to, after rewrites,
+     * fallbacks and overrides, and on shadow executions; mirroring is skipped.
+     */
+    allowedProviders?: string[] | undefined;
+
+    /** Concurrency cap and fair-share weight. */
+    concurrency?: TenantConcurrencyConfig | undefined;
+}
+
+/** Hard monthly usage limits for a tenant (calendar month, UTC). */
+export interface BudgetConfig {
+    /** Token limit per month. */
+    monthlyTokens?: number | undefined;
+
+    /** Estimated cost limit per month, in USD. */
+    monthlyCostUsd?: number | undefined;
+
+    /** Reject requests once exceeded, or only warn (default: block). */
+    action?: 'block' | 'warn' | undefined;
 }
 
 /** API key configuration. */
@@ -81,6 +489,9 @@ export interface APIKeyConfig {
 
     /** Description of the key. */
     description?: string | undefined;
+
+    /** App serving the key's requests where apps share a path (default: the path's first app). */
+    app?: string | undefined;
 }
 
 /** App configuration. */
@@ -91,15 +502,22 @@ export interface AppConfig {
     /** Frontdoor type. */
     frontdoor: string;
 
-    /** Base path for this app. */
+    /**
+     * Base path for this app. Apps with the same frontdoor may share one;
+     * each key's app binding picks among them, and the first listed serves
+     * keys bound to none of them.
+     */
     path: string;
 
     /** Force specific provider. */
     provider?: string | undefined;
 
-    /** Default model. */
+    /** Default model, applied when a request names none. */
     defaultModel?: string | undefined;
 
+    /** Models clients may use, checked after rewrites; a trailing `*` matches by prefix (default: any). */
+    allowedModels?: string[] | undefined;
+
     /** Model routing configuration. */
     modelRouting?: ModelRoutingConfig | undefined;
 
@@ -117,6 +535,459 @@ export interface AppConfig {
 
     /** Pipeline configuration. */
     pipeline?: PipelineConfig | undefined;
+
+    /** What the client gets when a post-request stage denies a response (default: an HTTP error). */
+    onDeny?: DenyResponseConfig | undefined;
+
+    /** Caps how fast streamed content is relayed to clients. */
+    streamThrottle?: StreamThrottleConfig | undefined;
+
+    /** Upstream header rules; provider rules take precedence over these. */
+    headers?: HeaderRulesConfig | undefined;
+
+    /** Tools executed by the gateway rather than the client (non-streaming only). */
+    gatewayTools?: GatewayToolsConfig | undefined;
+
+    /** Reject requests for output the routed provider can't produce, e.g. logprobs (default: true; false drops them). */
+    strictCapabilities?: boolean | undefined;
+
+    /** max_tokens for requests that omit it (default: the model's catalog output cap, less a margin). */
+    maxTokensDefault?: MaxTokensDefault | undefined;
+
+    /** How a trailing assistant message reaches providers that don't continue one (default: instruction). */
+    prefill?: PrefillStrategy | undefined;
+
+    /** Latency and availability objectives, with burn-rate alerts (default: none). */
+    slo?: SloConfig | undefined;
+
+    /** Append a gateway.usage SSE event after each stream's terminal event (default: false). */
+    usageTrailer?: boolean | undefined;
+
+    /** Also embed gateway warnings in JSON response bodies, under x_gateway_warnings (default: false). */
+    embedWarnings?: boolean | undefined;
+
+    /** Announce each streamed tool call's top-level argument fields as they complete, in gateway.tool_argument.field_complete SSE events (default: false). */
+    toolArgumentEvents?: boolean | undefined;
+
+    /** Largest outbound SSE event, in bytes: longer deltas are split, other payloads cut short with a warning (default: unlimited; at least 1024). */
+    maxSseEventBytes?: number | undefined;
+
+    /** Let any client ask for its routing decision with X-Gateway-Explain (default: admin-scoped keys only). */
+    explainRouting?: boolean | undefined;
+
+    /** Fan out array prompts on /v1/completions into one call per prompt (default: reject with 400). */
+    fanOutPrompts?: boolean | undefined;
+
+    /** Re-POSTs a sample of requests to another gateway (e.g., staging), fire-and-forget. */
+    mirror?: MirrorConfig | undefined;
+
+    /** Rewrites applied to response text, in order, before it is encoded for the client. */
+    transforms?: ResponseTransformConfig[] | undefined;
+
+    /** Request headers (e.g., X-Client-Request-Id) indexed as interaction metadata for admin lookups. */
+    correlationHeaders?: string[] | undefined;
+
+    /** Checks JSON-mode output (response_format json_object/json_schema) parses and matches its schema. */
+    validateJsonOutput?: JsonOutputValidationConfig | undefined;
+
+    /** Which interactions keep full payloads (default: all). */
+    recording?: RecordingConfig | undefined;
+
+    /** How streamed responses are stored as interaction events (default: compacted). */
+    eventGranularity?: EventGranularity | undefined;
+
+    /** Cross-origin access for browser clients calling this app's routes directly. */
+    cors?: CorsConfig | undefined;
+
+    /** HTTP middleware wrapping this app's routes, outermost first, by registered name (e.g. ratelimit, bodylog). */
+    middleware?: AppMiddlewareConfig[] | undefined;
+
+    /** What is forwarded upstream as the end-user ID: the client's value or only its salted hash (default: raw). */
+    forwardEndUser?: EndUserForwarding | undefined;
+
+    /** Return a same-API provider's error responses to clients as sent, after redaction (default: enabled). */
+    errorPassthrough?: ErrorPassthroughConfig | undefined;
+
+    /** Priority class of the app's requests (default: normal). */
+    priority?: RequestPriority | undefined;
+
+    /** Let identical concurrent non-streaming requests share one provider call (default: off). */
+    coalesce?: CoalescingConfig | undefined;
+
+    /** Summarize the oldest turns of long reconstructed conversations (default: off). */
+    threadSummary?: ThreadSummaryConfig | undefined;
+
+    /** Skip assistant messages appended to a thread that repeat a recent one, as a retried run does (default: off). */
+    messageDedup?: MessageDedupConfig | undefined;
+
+    /** Forward endpoints the anthropic frontdoor doesn't serve to the Anthropic provider (default: off). */
+    passthrough?: EndpointPassthroughConfig | undefined;
+
+    /** Tag responses with their language and safety flags after they are sent (default: off). */
+    classification?: ResponseClassificationConfig | undefined;
+
+    /** Caps on the provider calls one request makes, over the gateway's callBudget field by field. */
+    callBudget?: CallBudgetConfig | undefined;
+
+    /** Sampling parameter policy; the gateway's parameterPolicies for the model override it, parameter by parameter. */
+    parameterPolicy?: ParameterPolicyConfig | undefined;
+}
+
+/**
+ * One entry in an app's middleware chain: a middleware registered under
+ * `name`, built with `params`. Built in: cors, ratelimit, bodylog and
+ * timeout.
+ */
+export interface AppMiddlewareConfig {
+    /** Registered middleware name. */
+    name: string;
+
+    /** Parameters for the middleware's factory, as written in config. */
+    params?: Record<string, unknown> | undefined;
+}
+
+/**
+ * An app's service level objectives, tracked over a rolling window. A
+ * request is slow when it takes longer than latencyP95Ms (streams count
+ * the time to their first token), and failed when it ends in a server
+ * error; the error budget is the share of requests each may be.
+ */
+export interface SloConfig {
+    /** Latency objective: 95% of requests within this many ms. */
+    latencyP95Ms?: number | undefined;
+
+    /** Availability objective: percent of requests that don't fail (e.g., 99.5). */
+    availability?: number | undefined;
+
+    /** Rolling window compliance is measured over (default: 28d). */
+    window?: string | undefined;
+
+    /** Webhook alert when the error budget burns fast. */
+    alert?: SloAlertConfig | undefined;
+}
+
+/**
+ * SLO burn-rate alert webhook. It fires when an objective's budget burns
+ * at fastBurnRate or more over both the short and the long window.
+ */
+export interface SloAlertConfig extends AlertWebhookConfig {
+    /** Burn rate (multiple of the sustainable rate) that trips the alert (default: 14.4). */
+    fastBurnRate?: number | undefined;
+
+    /** Short burn window (default: 5m). */
+    shortWindow?: string | undefined;
+
+    /** Long burn window (default: 1h). */
+    longWindow?: string | undefined;
+}
+
+/**
+ * How a partial assistant reply ending a request is sent to a provider
+ * that would take it for a finished turn: as a closing instruction to begin
+ * the reply with it, as-is (for servers that continue it), or not at all
+ * (the request is rejected).
+ */
+export type PrefillStrategy = 'instruction' | 'passthrough' | 'reject';
+
+/**
+ * How max_tokens is set when a request omits it: from the routed model's
+ * catalog output cap, to a fixed count, or not at all (the request is
+ * rejected).
+ */
+export type MaxTokensDefault =
+    | { strategy: 'model_max' }
+    | { strategy: 'fixed'; tokens: number }
+    | { strategy: 'reject' };
+
+/** End-user ID forwarded upstream: the client's value, or its salted hash. */
+export type EndUserForwarding = 'raw' | 'hash';
+
+/**
+ * Error passthrough for an app. When the provider speaks the app's API,
+ * its error status, body, and relevant headers reach the client as sent;
+ * across APIs errors are always translated.
+ */
+export interface ErrorPassthroughConfig {
+    /** Pass same-API provider errors through (default: true). */
+    enabled?: boolean | undefined;
+
+    /** Header and body field names stripped before passthrough, in addition to the built-in list. */
+    redact?: string[] | undefined;
+}
+
+/**
+ * Request coalescing for an app. A non-streaming request identical to one
+ * in flight (same tenant and canonical request) joins its provider call
+ * and gets the same response or error.
+ */
+export interface CoalescingConfig {
+    /** Coalesce requests (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** How long after a call starts identical requests may join it, in ms (default 2000). */
+    windowMs?: number | undefined;
+
+    /** Also coalesce requests with a temperature above 0 (default: false). */
+    includeSampled?: boolean | undefined;
+}
+
+/**
+ * Duplicate assistant messages for an app's threads. An assistant message
+ * whose role and content match one among the thread's last
+ * windowMessages, or added within windowSeconds, isn't appended again;
+ * the existing message's ID is returned instead.
+ */
+export interface MessageDedupConfig {
+    /** Skip duplicates (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** Most recent messages checked (default 2). */
+    windowMessages?: number | undefined;
+
+    /** Age of messages checked, in seconds (default 60). */
+    windowSeconds?: number | undefined;
+}
+
+/**
+ * Conversation summaries for an app. When a conversation rebuilt from
+ * previous_response_id or a thread passes the threshold, its oldest turns
+ * are sent as one summary written by the configured model.
+ */
+export interface ThreadSummaryConfig {
+    /** Summarize long conversations (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** Provider that writes summaries. */
+    provider: string;
+
+    /** Model that writes summaries. */
+    model: string;
+
+    /** Input tokens above which a conversation is summarized. */
+    thresholdTokens: number;
+
+    /** Most recent messages always sent as they are (default 6). */
+    keepRecent?: number | undefined;
+
+    /** Count summarizer calls in the tenant's usage reports (default: false). */
+    countUsage?: boolean | undefined;
+}
+
+/**
+ * Endpoint passthrough for an anthropic app. Patterns match the upstream
+ * path (e.g. /v1/models/*); `*` matches any run of characters.
+ */
+export interface EndpointPassthroughConfig {
+    /** Forward unserved endpoints (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** Paths that may be forwarded (default: all). */
+    allow?: string[] | undefined;
+
+    /** Paths never forwarded; checked before allow. */
+    deny?: string[] | undefined;
+}
+
+/**
+ * Response classification for an app. Once a response has been sent, its
+ * text is classified in the background and the results are stored with
+ * the request's stats.
+ */
+export interface ResponseClassificationConfig {
+    /** Classify responses (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** Detect the response language with the built-in detector (default: true). */
+    language?: boolean | undefined;
+
+    /** Flag unsafe responses with a moderation API (default: off). */
+    safety?: SafetyClassifierConfig | undefined;
+}
+
+/**
+ * Moderation-API safety classifier.
+ */
+export interface SafetyClassifierConfig {
+    /** OpenAI provider whose moderation endpoint is called. */
+    provider: string;
+
+    /** Moderation model (default: omni-moderation-latest). */
+    model?: string | undefined;
+}
+
+/**
+ * Streamed response events: "chunk" saves one interaction event per stream
+ * event (for debugging); "compacted" saves one transcript event plus the
+ * stream_start, first_token and stream_end milestones.
+ */
+export type EventGranularity = 'chunk' | 'compacted';
+
+/** Which interactions keep full payloads: all, a random share, or failures only. */
+export type RecordingMode = 'full' | 'sampled' | 'errors_only';
+
+/** Interaction recording for an app; other requests are recorded as a summary without bodies. */
+export interface RecordingConfig {
+    /** Recording mode (default: full). */
+    mode?: RecordingMode | undefined;
+
+    /** Share of requests recorded in full in sampled mode, in [0, 1] (default 0.01). */
+    sampleRate?: number | undefined;
+
+    /** Conditions that record a request in full whatever the mode. */
+    alwaysFullOn?: RecordingTriggers | undefined;
+
+    /** Cap on a saved raw provider response, in bytes (default 256 KiB; 0 saves none). */
+    rawResponseMaxBytes?: number | undefined;
+}
+
+/** Escalation triggers for interaction recording. */
+export interface RecordingTriggers {
+    /** Record failed requests in full. */
+    error?: boolean | undefined;
+
+    /** Record requests that take at least this many milliseconds in full. */
+    slowMs?: number | undefined;
+
+    /** Record requests carrying this header in full (e.g., x-debug-record). */
+    header?: string | undefined;
+}
+
+/** What to do with non-streamed JSON-mode output that fails validation. */
+export type JsonOutputInvalidAction = 'error' | 'repair';
+
+/** JSON output validation settings. */
+export interface JsonOutputValidationConfig {
+    /** Return a 502 invalid_json_output error, or retry once asking the model to fix it (default: error). */
+    onInvalid?: JsonOutputInvalidAction | undefined;
+}
+
+/** Built-in response transform types. */
+export type ResponseTransformType = 'regex_replace' | 'truncate_chars' | 'strip_markdown' | 'json_extract';
+
+/** A deterministic rewrite of response text. */
+export interface ResponseTransformConfig {
+    /** Transform type. */
+    type: ResponseTransformType;
+
+    /** Pattern to replace (regex_replace); matched within one line when streaming. */
+    pattern?: string | undefined;
+
+    /** Replacement text; supports $1-style group references (regex_replace, default: ''). */
+    replacement?: string | undefined;
+
+    /** Regex flags; `g` is always added (regex_replace). */
+    flags?: string | undefined;
+
+    /** Maximum characters kept (truncate_chars). */
+    maxChars?: number | undefined;
+
+    /** Dotted path into the JSON text, e.g. `result.items.0` (json_extract; forces buffered streaming). */
+    path?: string | undefined;
+}
+
+/** Cross-origin (CORS) settings for an app's routes. */
+export interface CorsConfig {
+    /** Origins allowed to call the app (e.g. https://app.example.com), or '*' for any. */
+    allowedOrigins: string[];
+
+    /** Request headers a browser may send (default: Authorization, Content-Type). */
+    allowedHeaders?: string[] | undefined;
+
+    /** Response headers scripts may read (e.g. X-Request-Id, X-Gateway-Interaction-Id). */
+    exposeHeaders?: string[] | undefined;
+
+    /** Seconds browsers may cache a preflight result. */
+    maxAge?: number | undefined;
+
+    /** Allow cookies and HTTP auth on cross-origin requests; not allowed with '*' origins (default: false). */
+    allowCredentials?: boolean | undefined;
+}
+
+/** Request mirroring for an app; client credentials are never forwarded. */
+export interface MirrorConfig {
+    /** Base URL of the receiving gateway; the request path is appended. */
+    url: string;
+
+    /** Share of requests mirrored, 0 to 1 (default: 1). */
+    sampleRate?: number | undefined;
+
+    /** Credential sent to the mirror as a bearer token; supports ${env:VAR}. */
+    apiKey?: string | undefined;
+
+    /** Extra headers; values support ${env:VAR}. */
+    headers?: Record<string, string> | undefined;
+
+    /** Mirrored requests in flight before new ones are dropped (default: 8). */
+    maxInFlight?: number | undefined;
+
+    /** Timeout duration (default: 10s). */
+    timeout?: string | undefined;
+}
+
+/** Gateway-executed tools exposed to an app's models. */
+export interface GatewayToolsConfig {
+    /** Names of registered tools (built-in or from the top-level tools list). */
+    tools: string[];
+
+    /** Maximum tool rounds before the model must answer (default: 5). */
+    maxIterations?: number | undefined;
+}
+
+/** A gateway-managed prompt template. */
+export interface PromptTemplateConfig {
+    /** Template name requests refer to. */
+    name: string;
+
+    /** Template text with {{variable}} placeholders. */
+    text: string;
+
+    /** Variables every request must supply. */
+    required?: string[] | undefined;
+
+    /** Render into the system prompt (default) or a prepended user message. */
+    placement?: 'system' | 'user' | undefined;
+}
+
+/** An HTTP-backed gateway tool; the arguments are POSTed as JSON. */
+export interface GatewayToolConfig {
+    /** Tool name the model calls. */
+    name: string;
+
+    /** Description shown to the model. */
+    description?: string | undefined;
+
+    /** Endpoint that executes the tool; its response body is the result. */
+    url: string;
+
+    /** JSON Schema for the arguments (default: a single required `query` string). */
+    parameters?: Record<string, unknown> | undefined;
+
+    /** Extra headers. */
+    headers?: Record<string, string> | undefined;
+
+    /** Timeout duration (default: 10s). */
+    timeout?: string | undefined;
+}
+
+/** Rules for the headers sent upstream, per app or per provider. */
+export interface HeaderRulesConfig {
+    /** Client request headers forwarded upstream as-is. */
+    passthrough?: string[] | undefined;
+
+    /** Headers set on every upstream request; values support ${env:VAR}. */
+    inject?: Record<string, string> | undefined;
+
+    /** Headers removed from the upstream request (including defaults such as User-Agent). */
+    strip?: string[] | undefined;
+}
+
+/** Output pacing for streamed responses. */
+export interface StreamThrottleConfig {
+    /** Sustained rate in (estimated) tokens per second. */
+    tokensPerSecond: number;
+
+    /** Tokens that may be sent without pacing (default: tokensPerSecond). */
+    burst?: number | undefined;
 }
 
 /** Pipeline configuration. */
@@ -148,11 +1019,132 @@ export interface PipelineStageConfig {
     /** Suppress response if denied. */
     squelch?: boolean | undefined;
 
+    /** What the client gets when this post stage denies a response (default: the app's on_deny). */
+    onDeny?: DenyResponseConfig | undefined;
+
     /** Extra headers. */
     headers?: Record<string, string> | undefined;
 
     /** Execution order. */
     order?: number | undefined;
+
+    /** HTTP proxy for this webhook only; webhooks never inherit a provider's proxy. */
+    proxyUrl?: string | undefined;
+
+    /** PEM bundle of extra CAs trusted for this webhook. */
+    caBundlePath?: string | undefined;
+
+    /** Disable TLS certificate verification (logged loudly at startup). */
+    insecureSkipVerify?: boolean | undefined;
+
+    /** Result caching for webhooks that are deterministic over the key fields. */
+    cache?: PipelineStageCacheConfig | undefined;
+
+    /** Tenant secret the webhook body is signed with (HMAC-SHA256, in X-Gateway-Signature). */
+    signingSecret?: SecretRef | undefined;
+
+    /** Run the stage only for requests matching this condition (default: every request). */
+    when?: StageConditionConfig | undefined;
+}
+
+/**
+ * Which requests a pipeline stage runs for. Every field set must match;
+ * `all` and `any` combine nested conditions. The finish reason and
+ * content length fields check the response, so only post stages can use
+ * them.
+ */
+export interface StageConditionConfig {
+    /** Every one of these conditions matches. */
+    all?: StageConditionConfig[] | undefined;
+
+    /** At least one of these conditions matches. */
+    any?: StageConditionConfig[] | undefined;
+
+    /** Model, exactly. */
+    model?: string | undefined;
+
+    /** Model prefix (e.g., "gpt-4"). */
+    modelPrefix?: string | undefined;
+
+    /** Regular expression the model matches. */
+    modelPattern?: string | undefined;
+
+    /** Tenant IDs, one of which makes the request. */
+    tenant?: string[] | undefined;
+
+    /** App names, one of which serves the request. */
+    app?: string[] | undefined;
+
+    /** Whether the request streams. */
+    stream?: boolean | undefined;
+
+    /** Whether the request defines tools. */
+    hasTools?: boolean | undefined;
+
+    /** Fewest messages in the request. */
+    minMessages?: number | undefined;
+
+    /** Most messages in the request. */
+    maxMessages?: number | undefined;
+
+    /** Fewest estimated input tokens. */
+    minTokens?: number | undefined;
+
+    /** Most estimated input tokens. */
+    maxTokens?: number | undefined;
+
+    /** Request metadata keys and the values they must have. */
+    metadata?: Record<string, string> | undefined;
+
+    /** Fraction of otherwise matching requests the stage runs for, 0 to 1. */
+    sampleRate?: number | undefined;
+
+    /** Finish reasons, one of which the response has (post stages). */
+    finishReason?: string[] | undefined;
+
+    /** Fewest characters in the response text (post stages). */
+    minContentLength?: number | undefined;
+
+    /** Most characters in the response text (post stages). */
+    maxContentLength?: number | undefined;
+}
+
+/**
+ * A reference to a tenant secret set through the admin API, as
+ * `{secret: "<tenant>/<name>"}`, in place of a value in config. It is
+ * resolved each time the value is used; one that doesn't resolve fails
+ * the use, naming the reference.
+ */
+export interface SecretRef {
+    /** Tenant ID and secret name, as "<tenant>/<name>". */
+    secret: string;
+}
+
+/** What a client gets in place of a response a post-request stage denied. */
+export interface DenyResponseConfig {
+    /** static: the content as-is; template: content with {{reason}}, {{stage}}, {{model}} filled in; error: an HTTP error, as without on_deny. */
+    mode: 'static' | 'template' | 'error';
+
+    /** Assistant message sent instead (default: "I can't help with that request."). */
+    content?: string | undefined;
+
+    /** Show the client the deny reason: appended to static content, and as {{reason}} in templates (default: false). */
+    includeReason?: boolean | undefined;
+}
+
+/** Pipeline stage result caching. */
+export interface PipelineStageCacheConfig {
+    /** How long a result stays cached (e.g., "5m"). */
+    ttl?: string | undefined;
+
+    /** Key fields: tenant, app, model, messages_hash, tools_hash (default tenant, model, messages_hash). */
+    key?: string[] | undefined;
+
+    /** Cache deny decisions too (default: only allows and mutations). */
+    cacheDenies?: boolean | undefined;
+
+    /** Entries kept per stage. */
+    maxEntries?: number | undefined;
 }
 
 /** Provider configuration. */
@@ -166,6 +1158,15 @@ export interface ProviderConfig {
     /** API key. */
     apiKey: string;
 
+    /** Tenant secret holding the API key, in place of apiKey; resolved on every request. */
+    apiKeySecret?: SecretRef | undefined;
+
+    /** Additional API keys pooled with apiKey and rotated per request. */
+    apiKeys?: ProviderKeyConfig[] | undefined;
+
+    /** Base cooldown for a key after a 429 (e.g., "30s"); doubles on repeats. */
+    keyCooldown?: string | undefined;
+
     /** Custom base URL. */
     baseUrl?: string | undefined;
 
@@ -183,6 +1184,150 @@ export interface ProviderConfig {
 
     /** Persist thread state. */
     responsesThreadPersistence?: boolean | undefined;
+
+    /** Fan out n > 1 as parallel requests when the API lacks native support. */
+    fanOutChoices?: boolean | undefined;
+
+    /** Outbound HTTP client tuning. */
+    http?: ProviderHTTPConfig | undefined;
+
+    /** Per-attempt bound on Complete calls and time to first stream event (e.g., "30s"). */
+    requestTimeout?: string | undefined;
+
+    /** Aborts a stream when no event arrives for this long mid-flight (e.g., "20s"). */
+    streamIdleTimeout?: string | undefined;
+
+    /** How long a fetched model list is served before a background refresh (default: 5m). */
+    modelListTtl?: string | undefined;
+
+    /** How the request deadline is passed to the upstream. */
+    deadline?: ProviderDeadlineConfig | undefined;
+
+    /** Upstream header rules. */
+    headers?: HeaderRulesConfig | undefined;
+
+    /** Pinned API version: anthropic-version, or the api-version query parameter for OpenAI (Azure). */
+    apiVersion?: string | undefined;
+
+    /** anthropic-beta features to enable (anthropic only). */
+    betaFeatures?: string[] | undefined;
+
+    /** Accept beta features missing from the gateway's known list. */
+    allowUnknown?: boolean | undefined;
+
+    /** OpenAI-Organization header (openai only). */
+    organization?: string | undefined;
+
+    /** OpenAI-Project header (openai only). */
+    project?: string | undefined;
+
+    /** Synthetic probe sent on an interval, so degradation shows up without traffic. */
+    probe?: ProviderProbeConfig | undefined;
+}
+
+/**
+ * Synthetic probe for a provider. Probes call the provider directly: they
+ * are never attributed to a tenant or counted in usage and budgets.
+ */
+export interface ProviderProbeConfig {
+    /** Time between probes (default: 60s). */
+    interval?: string | undefined;
+
+    /** Model probed. */
+    model: string;
+
+    /** User message sent (default: "ping"). */
+    prompt?: string | undefined;
+
+    /** Max tokens requested (default: 5). */
+    maxTokens?: number | undefined;
+
+    /** Per-probe timeout (default: 10s). */
+    timeout?: string | undefined;
+
+    /** Probe results the rolling success rate and latency p95 cover (default: 20). */
+    window?: number | undefined;
+
+    /** Rolling success rate below which the provider counts as degraded (default: 0.5). */
+    minSuccessRate?: number | undefined;
+
+    /** Webhook alert on a low success rate or slow p95. */
+    alert?: ProbeAlertConfig | undefined;
+}
+
+/**
+ * Alert webhook, shared by provider probes and app SLOs.
+ */
+export interface AlertWebhookConfig {
+    /** URL alerts are POSTed to. */
+    webhook: string;
+
+    /** Minimum time between alerts for one provider or app (default: 15m for probes, 1h for SLOs). */
+    cooldown?: string | undefined;
+
+    /** Extra request headers; values may reference ${env:VAR}. */
+    headers?: Record<string, string> | undefined;
+}
+
+/**
+ * Probe alert webhook.
+ */
+export interface ProbeAlertConfig extends AlertWebhookConfig {
+    /** Alert when rolling latency p95 exceeds this (e.g., "5s"). */
+    maxP95?: string | undefined;
+}
+
+/**
+ * Request deadline propagation for an OpenAI-compatible provider.
+ */
+export interface ProviderDeadlineConfig {
+    /** Send the time left as X-Request-Timeout-Ms (default true). */
+    header?: boolean | undefined;
+
+    /** Estimated generation rate; max tokens is clamped to what fits before the deadline. */
+    tokensPerSecond?: number | undefined;
+}
+
+/** A pooled provider API key. */
+export interface ProviderKeyConfig {
+    /** The API key. */
+    key: string;
+
+    /** Non-secret label for logs and metadata (default: last 4 characters). */
+    label?: string | undefined;
+}
+
+/** Outbound HTTP client configuration for a provider. */
+export interface ProviderHTTPConfig {
+    /** Maximum idle connections kept across all hosts. */
+    maxIdleConns?: number | undefined;
+
+    /** Maximum idle connections kept per host. */
+    maxIdleConnsPerHost?: number | undefined;
+
+    /** Maximum concurrent connections per host (0 = unlimited). */
+    maxConnsPerHost?: number | undefined;
+
+    /** How long an idle connection is kept (e.g., "90s"). */
+    idleConnTimeout?: string | undefined;
+
+    /** TCP connect timeout (e.g., "10s"). */
+    dialTimeout?: string | undefined;
+
+    /** TLS handshake timeout (e.g., "10s"). */
+    tlsHandshakeTimeout?: string | undefined;
+
+    /** Time to wait for response headers after the request is written (e.g., "60s"). */
+    responseHeaderTimeout?: string | undefined;
+
+    /** Upstream HTTP proxy for this provider only (e.g., "http://proxy:3128"). */
+    proxyUrl?: string | undefined;
+
+    /** PEM bundle of extra CAs trusted for this provider, added to the default roots. */
+    caBundlePath?: string | undefined;
+
+    /** Disable TLS certificate verification (logged loudly at startup). */
+    insecureSkipVerify?: boolean | undefined;
 }
 
 /** Routing configuration. */
@@ -192,6 +1337,21 @@ export interface RoutingConfig {
 
     /** Default provider. */
     defaultProvider?: string | undefined;
+
+    /** Keeps threaded conversations on one provider (global routing only). */
+    affinity?: AffinityConfig | undefined;
+}
+
+/** Thread affinity configuration. */
+export interface AffinityConfig {
+    /** Whether thread affinity is enabled (default: true when configured). */
+    enabled?: boolean | undefined;
+
+    /** How long a thread stays on its provider after its last turn (e.g. "1h"). */
+    ttl?: string | undefined;
+
+    /** Maximum threads remembered in memory (default: 10000). */
+    maxEntries?: number | undefined;
 }
 
 /** Routing rule. */
@@ -11,14 +11,74 @@ export type {
     ConfigChangeCallback,
     GatewayConfig,
     ServerConfig,
+    AdminListenerConfig,
     StorageConfig,
+    SpillConfig,
+    StorageEncryptionConfig,
+    StorageHealthConfig,
+    StorageKeyConfig,
+    IdempotencyConfig,
+    EventsConfig,
+    EventsWebhookConfig,
+    EventsKafkaConfig,
     TenantConfig,
+    BudgetConfig,
     APIKeyConfig,
     AppConfig,
     PipelineConfig,
     PipelineStageConfig,
+    PipelineStageCacheConfig,
+    StageConditionConfig,
+    DenyResponseConfig,
+    StreamThrottleConfig,
+    HeaderRulesConfig,
+    GatewayToolsConfig,
+    GatewayToolConfig,
+    MirrorConfig,
+    CorsConfig,
+    AppMiddlewareConfig,
+    ResponseTransformConfig,
+    ResponseTransformType,
+    JsonOutputValidationConfig,
+    RecordingConfig,
+    RecordingMode,
+    RecordingTriggers,
+    EventGranularity,
+    JsonOutputInvalidAction,
+    PromptTemplateConfig,
     ProviderConfig,
+    ProviderHTTPConfig,
+    ProviderDeadlineConfig,
+    ProviderKeyConfig,
+    ProviderProbeConfig,
+    ProbeAlertConfig,
+    AlertWebhookConfig,
+    SecretRef,
     RoutingConfig,
+    AffinityConfig,
+    ConcurrencyConfig,
+    TenantConcurrencyConfig,
+    RequestPriority,
+    EndUserConfig,
+    EndUserForwarding,
+    CallBudgetConfig,
+    PolicyParameter,
+    ParameterRule,
+    ParameterPolicyConfig,
+    ModelParameterPolicyConfig,
+    UsageCheckConfig,
+    InteractionStatusConfig,
+    MaxTokensDefault,
+    PrefillStrategy,
+    SloConfig,
+    SloAlertConfig,
+    ErrorPassthroughConfig,
+    CoalescingConfig,
+    MessageDedupConfig,
+    ThreadSummaryConfig,
+    EndpointPassthroughConfig,
+    ResponseClassificationConfig,
+    SafetyClassifierConfig,
     RoutingRule,
     ModelRoutingConfig,
     ModelRewriteRule,
@@ -38,15 +98,67 @@ export type {
     InteractionStore,
     ShadowStore,
     ThreadStateStore,
+    ThreadStateEntry,
+    ThreadStateListOptions,
+    ThreadStore,
+    MessageDedupWindow,
+    AddMessageOptions,
+    AddMessageResult,
+    DuplicateMessageReason,
+    IdempotencyStore,
+    UsageStore,
+    UsageRecord,
+    UsageStatsStore,
+    RequestStatRecord,
+    RequestStatClassificationFilter,
+    UsageStatsRow,
+    UsageTotals,
+    UsageSumOptions,
+    ErasureStore,
+    ErasureSelector,
+    ErasureCounts,
+    TenantStore,
+    StoredTenant,
+    ThreadTransferStore,
+    ThreadImportBatch,
+    ThreadImportRecord,
+    BatchStore,
+    BatchRecord,
+    MetadataIndexStore,
+    InteractionMetadataRecord,
+    ProbeStore,
+    ProbeResultRecord,
+    SloStore,
+    SloSnapshotRecord,
+    SloBucketRecord,
+    SecretStore,
+    SecretRecord,
+    AttemptStore,
+    InteractionAttemptRecord,
+    AttemptUsageRow,
+    InteractionRecord,
+    InteractionUpdate,
+    InteractionLifecycleStatus,
+    SensitiveValueStore,
+    SensitiveValue,
+    SensitiveField,
+    MigratableStore,
+    MigrationResult,
+    MigrationStatus,
     Conversation,
     StoredMessage,
+    StoredThread,
     ResponseRecord,
     InteractionSummary,
     InteractionType,
     ListOptions,
     InteractionListOptions,
     DivergenceListOptions,
+    IdempotencyRecord,
+    IdempotencyStatus,
+    StoredHTTPResponse,
 } from './storage.js';
+export { UNSCOPED_TENANT, ERASED, SENSITIVE_FIELDS, CHAT_COMPLETION_ID_PREFIX, isChatCompletionRecord } from './storage.js';
 
 // Events
 export type { EventPublisher } from './events.js';
@@ -57,6 +169,12 @@ export type {
     Provider,
     ProviderFactory,
     ProviderFactoryConfig,
+    ProviderCallOptions,
+    AttemptReason,
     ProviderRegistry,
+    ProviderHTTPClient,
+    HTTPPoolStats,
+    ProviderCredentials,
+    CredentialLease,
 } from './provider.js';
 export { createProviderRegistry } from './provider.js';
@@ -29,12 +29,12 @@ export interface Provider {
     /**
      * Completes a non-streaming request.
      */
-    complete(request: CanonicalRequest): Promise<CanonicalResponse>;
+    complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse>;
 
     /**
      * Streams a request, yielding events.
      */
-    stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent, void, void>;
+    stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void>;
 
     /**
      * Lists available models.
@@ -42,6 +42,28 @@ export interface Provider {
     listModels?(): Promise<ModelList>;
 }
 
+/**
+ * Why a provider call was made, for per-attempt usage attribution.
+ */
+export type AttemptReason = 'primary' | 'retry' | 'failover' | 'hedge' | 'tool_loop_iteration';
+
+/**
+ * Per-call options carrying the client request's lifetime to the upstream.
+ */
+export interface ProviderCallOptions {
+    /** Aborted when the client request is abandoned; tears down the upstream call. */
+    signal?: AbortSignal | undefined;
+
+    /** Gateway deadline for the request (epoch ms); the call is cancelled when it passes. */
+    deadline?: number | undefined;
+
+    /** Why the call is made (default: primary). Set by call sites that call again. */
+    attempt?: AttemptReason | undefined;
+
+    /** Receives the exact body of each upstream request, just before it is sent. */
+    onRequestBody?: ((body: Uint8Array) => void) | undefined;
+}
+
 // ============================================================================
 // Provider Factory Types
 // ============================================================================
@@ -64,13 +86,105 @@ export interface ProviderFactoryConfig {
     /** Custom base URL. */
     baseUrl?: string | undefined;
 
+    /** Credential pool; when set, keys are drawn from it instead of apiKey. */
+    credentials?: ProviderCredentials | undefined;
+
     /** HTTP client override (for testing). */
     fetch?: typeof globalThis.fetch | undefined;
 
+    /** Bound on Complete calls and time to first stream event in ms (0 = none). */
+    requestTimeoutMs?: number | undefined;
+
+    /** Bound on the gap between stream events in ms (0 = none). */
+    streamIdleTimeoutMs?: number | undefined;
+
+    /** Send the remaining deadline upstream as X-Request-Timeout-Ms (OpenAI-compatible only; default true). */
+    deadlineHeader?: boolean | undefined;
+
+    /**
+     * Estimated generation rate; when set, max tokens is clamped to what
+     * fits in the remaining deadline (OpenAI-compatible only).
+     */
+    deadlineTokensPerSecond?: number | undefined;
+
+    /** Pinned API version (anthropic-version, or OpenAI's api-version query parameter). */
+    apiVersion?: string | undefined;
+
+    /** anthropic-beta features (Anthropic only). */
+    betaFeatures?: string[] | undefined;
+
+    /** OpenAI-Organization header (OpenAI only). */
+    organization?: string | undefined;
+
+    /** OpenAI-Project header (OpenAI only). */
+    project?: string | undefined;
+
     /** Additional options. */
     options?: Record<string, unknown> | undefined;
 }
 
+// ============================================================================
+// Credential Types
+// ============================================================================
+
+/**
+ * An API key checked out for one upstream request.
+ */
+export interface CredentialLease {
+    /** Non-secret identifier (configured label or last 4 characters). */
+    id: string;
+
+    /** The API key. */
+    key: string;
+}
+
+/**
+ * Source of API keys for a provider. Providers report each upstream status
+ * so the source can steer away from rate-limited or revoked keys.
+ */
+export interface ProviderCredentials {
+    /** Picks the key for the next request. */
+    acquire(): CredentialLease;
+
+    /** Records the HTTP status the upstream returned for a lease. */
+    report(lease: CredentialLease, status: number): void;
+}
+
+// ============================================================================
+// HTTP Client Types
+// ============================================================================
+
+/**
+ * Connection pool statistics for a provider's HTTP client.
+ */
+export interface HTTPPoolStats {
+    /** Requests that opened a new connection. */
+    newConnections: number;
+
+    /** Requests that reused a pooled connection. */
+    reusedConnections: number;
+
+    /** Requests currently in flight. */
+    activeRequests: number;
+
+    /** Connections currently idle in the pool. */
+    idleConnections: number;
+}
+
+/**
+ * A runtime-specific HTTP client for outbound provider calls.
+ */
+export interface ProviderHTTPClient {
+    /** Fetch implementation used by the provider. */
+    fetch: typeof globalThis.fetch;
+
+    /** Returns current pool statistics, if the runtime exposes them. */
+    stats?(): HTTPPoolStats;
+
+    /** Releases pooled connections. */
+    close?(): void;
+}
+
 // ============================================================================
 // Provider Registry
 // ============================================================================
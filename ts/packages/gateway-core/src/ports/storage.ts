@@ -6,7 +6,9 @@
 
 import type { Message, Usage } from '../domain/types.js';
 import type { ShadowResult } from '../domain/shadow.js';
-import type { InteractionEvent } from '../domain/events.js';
+import type { InteractionEvent, InteractionTimings } from '../domain/events.js';
+import type { RoutingConfig } from './config.js';
+import type { AttemptReason } from './provider.js';
 
 // ============================================================================
 // Conversation Types
@@ -59,6 +61,13 @@ export interface StoredMessage {
 
     /** Timestamp. */
     timestamp: Date;
+
+    /**
+     * SHA-256 of role and content (see messageContentHash), for duplicate
+     * checks on append. Keyed with the storage keyring when storage is
+     * encrypted.
+     */
+    contentHash?: string | undefined;
 }
 
 // ============================================================================
@@ -66,7 +75,8 @@ export interface StoredMessage {
 // ============================================================================
 
 /**
- * A stored response record (for Responses API).
+ * A stored response record (for Responses API, and chat completions
+ * created with `store`).
  */
 export interface ResponseRecord {
     /** Response ID. */
@@ -105,6 +115,9 @@ export interface ResponseRecord {
     /** Metadata. */
     metadata?: Record<string, string> | undefined;
 
+    /** Per-phase latency breakdown. */
+    timings?: InteractionTimings | undefined;
+
     /** Creation timestamp. */
     createdAt: Date;
 
@@ -112,12 +125,97 @@ export interface ResponseRecord {
     updatedAt: Date;
 }
 
+/** ID prefix of chat completions, which mark stored ones apart from Responses API responses. */
+export const CHAT_COMPLETION_ID_PREFIX = 'chatcmpl-';
+
+/**
+ * Whether a stored response is a chat completion (created with `store`)
+ * rather than a Responses API response. Its `response` is the
+ * chat.completion object as the client received it.
+ */
+export function isChatCompletionRecord(record: ResponseRecord): boolean {
+    return record.id.startsWith(CHAT_COMPLETION_ID_PREFIX);
+}
+
 // ============================================================================
 // Interaction Types (Unified)
 // ============================================================================
 
-/** Type of interaction. */
-export type InteractionType = 'conversation' | 'response';
+/**
+ * Type of interaction: a stored conversation or response, or the record
+ * of a request written from its start (see InteractionRecord).
+ */
+export type InteractionType = 'conversation' | 'response' | 'request';
+
+/**
+ * Where a request is in its lifecycle. It is in_progress from the
+ * frontdoor's decode until its response (or stream) finishes; one still
+ * in_progress long after it started was lost with the gateway serving it,
+ * and is swept to abandoned.
+ */
+export type InteractionLifecycleStatus = 'in_progress' | 'completed' | 'failed' | 'cancelled' | 'abandoned';
+
+/**
+ * A request's interaction record: saved in_progress as its stub when the
+ * request starts, with the request side only, and updated when it ends.
+ */
+export interface InteractionRecord {
+    /** Interaction ID, as minted for the request. */
+    id: string;
+
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** App name. */
+    appName?: string | undefined;
+
+    /** Frontdoor name. */
+    frontdoor: string;
+
+    /** Provider the request was routed to. */
+    provider: string;
+
+    /** Model requested, after the app's rewrites. */
+    model: string;
+
+    /** Whether the client asked for a stream. */
+    stream: boolean;
+
+    /** Lifecycle status. */
+    status: InteractionLifecycleStatus;
+
+    /** The decoded request as JSON, for requests recorded in full. */
+    request?: string | undefined;
+
+    /** HTTP status sent, once finished. */
+    statusCode?: number | undefined;
+
+    /** Error type, for failed interactions. */
+    errorType?: string | undefined;
+
+    /** When the request started. */
+    createdAt: Date;
+
+    /** Last status change. */
+    updatedAt: Date;
+}
+
+/**
+ * How a request's interaction record changes when it ends.
+ */
+export interface InteractionUpdate {
+    /** New status. */
+    status: InteractionLifecycleStatus;
+
+    /** HTTP status sent. */
+    statusCode?: number | undefined;
+
+    /** Error type, for failed interactions. */
+    errorType?: string | undefined;
+
+    /** Time of the change. */
+    updatedAt: Date;
+}
 
 /**
  * Unified interaction summary for listing.
@@ -141,9 +239,12 @@ export interface InteractionSummary {
     /** Message count (for conversations). */
     messageCount?: number | undefined;
 
-    /** Status (for responses). */
+    /** Status (for responses and requests). */
     status?: string | undefined;
 
+    /** Provider (for requests). */
+    provider?: string | undefined;
+
     /** Creation timestamp. */
     createdAt: Date;
 
@@ -165,10 +266,10 @@ export interface ListOptions {
     /** Offset for pagination. */
     offset?: number | undefined;
 
-    /** Order by field. */
+    /** Timestamp to order by: "createdAt" or "updatedAt" (default). */
     orderBy?: string | undefined;
 
-    /** Sort direction. */
+    /** Sort direction (default "desc"); ties are broken by ID. */
     order?: 'asc' | 'desc' | undefined;
 }
 
@@ -176,11 +277,18 @@ export interface ListOptions {
  * Options for listing interactions.
  */
 export interface InteractionListOptions extends ListOptions {
-    /** Filter by type. */
+    /**
+     * Filter by type. Requests are listed only when asked for, since one
+     * that stored a conversation or response would otherwise be listed
+     * twice.
+     */
     type?: InteractionType | undefined;
 
     /** Filter by tenant. */
     tenantId?: string | undefined;
+
+    /** Filter by status. */
+    status?: string | undefined;
 }
 
 /**
@@ -194,6 +302,17 @@ export interface DivergenceListOptions extends ListOptions {
     structuralOnly?: boolean | undefined;
 }
 
+// ============================================================================
+// Tenant Scoping
+// ============================================================================
+
+/**
+ * Tenant ID that disables tenant filtering on single-item getters.
+ * Only for internal callers (recorders, operator tooling) that are not
+ * acting on behalf of a tenant.
+ */
+export const UNSCOPED_TENANT = '';
+
 // ============================================================================
 // Conversation Store Interface
 // ============================================================================
@@ -208,9 +327,9 @@ export interface ConversationStore {
     saveConversation(conversation: Conversation): Promise<void>;
 
     /**
-     * Gets a conversation by ID.
+     * Gets a conversation by ID, or null if it belongs to another tenant.
      */
-    getConversation(id: string): Promise<Conversation | null>;
+    getConversation(id: string, tenantId: string): Promise<Conversation | null>;
 
     /**
      * Lists conversations for a tenant.
@@ -240,9 +359,9 @@ export interface ResponseStore {
     saveResponse(response: ResponseRecord): Promise<void>;
 
     /**
-     * Gets a response by ID.
+     * Gets a response by ID, or null if it belongs to another tenant.
      */
-    getResponse(id: string): Promise<ResponseRecord | null>;
+    getResponse(id: string, tenantId: string): Promise<ResponseRecord | null>;
 
     /**
      * Lists responses for a tenant.
@@ -292,9 +411,32 @@ export interface InteractionStore {
     saveEvent(event: InteractionEvent): Promise<void>;
 
     /**
-     * Gets events for an interaction.
+     * Gets events for an interaction owned by the tenant.
+     */
+    getEvents(interactionId: string, tenantId: string): Promise<InteractionEvent[]>;
+
+    /**
+     * Saves a request's interaction record, replacing any with its ID.
+     */
+    recordInteraction?(record: InteractionRecord): Promise<void>;
+
+    /**
+     * Updates a request's interaction record. Returns false when there is
+     * none with the ID.
+     */
+    updateInteraction?(id: string, update: InteractionUpdate): Promise<boolean>;
+
+    /**
+     * Gets a request's interaction record, or null if it belongs to
+     * another tenant.
+     */
+    getInteraction?(id: string, tenantId: string): Promise<InteractionRecord | null>;
+
+    /**
+     * Marks interaction records in_progress since before the cutoff
+     * abandoned. Returns how many were.
      */
-    getEvents(interactionId: string): Promise<InteractionEvent[]>;
+    abandonInteractions?(before: Date, updatedAt: Date): Promise<number>;
 }
 
 // ============================================================================
@@ -311,17 +453,18 @@ export interface ShadowStore {
     saveShadowResult(result: ShadowResult): Promise<void>;
 
     /**
-     * Gets shadow results for an interaction.
+     * Gets shadow results for an interaction owned by the tenant.
      */
-    getShadowResults(interactionId: string): Promise<ShadowResult[]>;
+    getShadowResults(interactionId: string, tenantId: string): Promise<ShadowResult[]>;
 
     /**
-     * Gets a shadow result by ID.
+     * Gets a shadow result by ID, or null if its interaction belongs to
+     * another tenant.
      */
-    getShadowResult(id: string): Promise<ShadowResult | null>;
+    getShadowResult(id: string, tenantId: string): Promise<ShadowResult | null>;
 
     /**
-     * Lists divergent shadow results.
+     * Lists shadow results with at least one divergence, newest first.
      */
     listDivergentShadowResults(
         options?: DivergenceListOptions,
@@ -332,6 +475,35 @@ export interface ShadowStore {
 // Thread State Store Interface
 // ============================================================================
 
+/**
+ * A thread state mapping. Keys can carry end-user identifiers, so they are
+ * addressed by hash outside the gateway.
+ */
+export interface ThreadStateEntry {
+    /** Thread key. */
+    threadKey: string;
+
+    /** SHA-256 of the thread key, hex. */
+    keyHash: string;
+
+    /** Mapped value (a response ID, or a JSON thread affinity entry). */
+    responseId: string;
+
+    /** Last write. */
+    updatedAt: Date;
+}
+
+/**
+ * Options for listing thread state.
+ */
+export interface ThreadStateListOptions {
+    /** Maximum entries (default 50). */
+    limit?: number | undefined;
+
+    /** Key hash to list after, from the previous page. */
+    cursor?: string | undefined;
+}
+
 /**
  * Storage for thread state (Responses API continuation).
  */
@@ -345,6 +517,26 @@ export interface ThreadStateStore {
      * Gets the thread state (latest response ID for thread).
      */
     getThreadState(threadKey: string): Promise<string | null>;
+
+    /**
+     * Lists mappings ordered by key hash.
+     */
+    listThreadState(options?: ThreadStateListOptions): Promise<ThreadStateEntry[]>;
+
+    /**
+     * Finds a mapping by key hash.
+     */
+    findThreadState(keyHash: string): Promise<ThreadStateEntry | null>;
+
+    /**
+     * Deletes a mapping. Returns whether it existed.
+     */
+    deleteThreadState(threadKey: string): Promise<boolean>;
+
+    /**
+     * Deletes mappings last written before a time. Returns how many.
+     */
+    purgeThreadState(before: Date): Promise<number>;
 }
 
 // ============================================================================
@@ -374,6 +566,43 @@ export interface StoredThread {
     updatedAt: Date;
 }
 
+/**
+ * Recent messages an appended message is checked against: the last
+ * `messages` of the thread, or those added within `seconds` before it.
+ */
+export interface MessageDedupWindow {
+    /** Most recent messages checked. */
+    messages?: number | undefined;
+
+    /** Age of messages checked, in seconds. */
+    seconds?: number | undefined;
+}
+
+/**
+ * Options for appending a message to a thread.
+ */
+export interface AddMessageOptions {
+    /**
+     * Skip the append when a message with the same contentHash is in this
+     * window (requires message.contentHash).
+     */
+    dedupWindow?: MessageDedupWindow | undefined;
+}
+
+/** Why an append was skipped: the thread has the message's ID, or its content within the window. */
+export type DuplicateMessageReason = 'id' | 'content';
+
+/**
+ * Outcome of appending a message.
+ */
+export interface AddMessageResult {
+    /** ID of the message appended, or of the existing message it duplicates. */
+    id: string;
+
+    /** Set when nothing was appended. */
+    duplicate?: DuplicateMessageReason | undefined;
+}
+
 /**
  * Storage for threads (OpenAI Assistants-style API).
  */
@@ -384,14 +613,18 @@ export interface ThreadStore {
     createThread(thread: StoredThread): Promise<void>;
 
     /**
-     * Gets a thread by ID.
+     * Gets a thread by ID, or null if it belongs to another tenant.
      */
-    getThread(id: string): Promise<StoredThread | null>;
+    getThread(id: string, tenantId: string): Promise<StoredThread | null>;
 
     /**
-     * Adds a message to a thread.
+     * Adds a message to a thread, unless the thread already has a message
+     * with its ID or (with a dedup window) its content hash; retries are
+     * then no-ops that return the existing message's ID. Returns null when
+     * the thread doesn't exist, or when the store keys messages globally
+     * and another thread's message has the ID.
      */
-    addMessage(threadId: string, message: StoredMessage): Promise<void>;
+    addMessage(threadId: string, message: StoredMessage, options?: AddMessageOptions): Promise<AddMessageResult | null>;
 
     /**
      * Lists messages in a thread.
@@ -405,19 +638,968 @@ export interface ThreadStore {
 }
 
 // ============================================================================
-// Combined Storage Provider Interface
+// Idempotency Store Interface
 // ============================================================================
 
+/** Lifecycle state of an idempotency key. */
+export type IdempotencyStatus = 'in_progress' | 'completed';
+
 /**
- * Full storage provider combining all storage interfaces.
+ * A client response captured for idempotent replay.
  */
-export interface StorageProvider
-    extends ConversationStore,
-    ResponseStore,
-    InteractionStore,
-    ShadowStore,
-    ThreadStateStore,
-    Partial<ThreadStore> {
+export interface StoredHTTPResponse {
+    /** HTTP status code. */
+    status: number;
+
+    /** Response headers. */
+    headers: Record<string, string>;
+
+    /** Response body. */
+    body: string;
+}
+
+/**
+ * Mapping from a tenant-scoped idempotency key to an interaction.
+ */
+export interface IdempotencyRecord {
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** Client-supplied Idempotency-Key. */
+    key: string;
+
+    /** Interaction ID of the original request. */
+    interactionId: string;
+
+    /** SHA-256 hash of the original request body. */
+    requestHash?: string | undefined;
+
+    /** Whether the original request is still running. */
+    status: IdempotencyStatus;
+
+    /** Captured client response (once completed). */
+    response?: StoredHTTPResponse | undefined;
+
+    /** Creation timestamp. */
+    createdAt: Date;
+
+    /** Expiry timestamp. */
+    expiresAt: Date;
+}
+
+/**
+ * Storage for idempotency keys.
+ */
+export interface IdempotencyStore {
+    /**
+     * Atomically claims a key. Returns null if the record was stored,
+     * or the existing unexpired record if the key is already claimed.
+     */
+    claimIdempotencyKey(record: IdempotencyRecord): Promise<IdempotencyRecord | null>;
+
+    /**
+     * Gets the unexpired record for a key.
+     */
+    getIdempotencyRecord(tenantId: string, key: string): Promise<IdempotencyRecord | null>;
+
+    /**
+     * Extends an in-progress claim made by `interactionId` while it runs.
+     */
+    renewIdempotencyKey(tenantId: string, key: string, interactionId: string, expiresAt: Date): Promise<void>;
+
+    /**
+     * Marks a claimed key as completed with the captured response, kept
+     * until `expiresAt`.
+     */
+    completeIdempotencyKey(
+        tenantId: string,
+        key: string,
+        response: StoredHTTPResponse,
+        expiresAt: Date,
+    ): Promise<void>;
+
+    /**
+     * Releases a claimed key so a retry can re-execute (failed originals).
+     * Only the claim made by `interactionId` is released, not one that has
+     * since replaced it.
+     */
+    releaseIdempotencyKey(tenantId: string, key: string, interactionId: string): Promise<void>;
+}
+
+// ============================================================================
+// Usage Store Interface
+// ============================================================================
+
+/**
+ * Billable usage of one completed request.
+ */
+export interface UsageRecord {
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** Interaction ID. */
+    interactionId: string;
+
+    /** Model used. */
+    model: string;
+
+    /** Total tokens (prompt + completion). */
+    totalTokens: number;
+
+    /** Estimated cost in USD (0 when the model has no pricing). */
+    costUsd: number;
+
+    /** When the request completed. */
+    createdAt: Date;
+}
+
+/** Usage summed over a period. */
+export interface UsageTotals {
+    /** Total tokens. */
+    tokens: number;
+
+    /** Estimated cost in USD. */
+    costUsd: number;
+
+    /** Number of requests. */
+    requests: number;
+}
+
+/** Narrows a usage sum. */
+export interface UsageSumOptions {
+    /** Only usage recorded before this time. */
+    before?: Date | undefined;
+
+    /** Interactions left out of the sum. */
+    excluding?: readonly string[] | undefined;
+}
+
+/**
+ * Storage for per-request usage, summed for tenant budgets.
+ */
+export interface UsageStore {
+    /**
+     * Records a request's usage. Recording an interaction ID that is
+     * already recorded is a no-op, so failed writes can be retried.
+     */
+    recordUsage(record: UsageRecord): Promise<void>;
+
+    /**
+     * Sums a tenant's usage recorded at or after `since`.
+     */
+    sumUsage(tenantId: string, since: Date, options?: UsageSumOptions): Promise<UsageTotals>;
+}
+
+// ============================================================================
+// Usage Stats Store Interface
+// ============================================================================
+
+/**
+ * Outcome of one request, for usage reports. Unlike UsageRecord, failed
+ * requests are recorded too.
+ */
+export interface RequestStatRecord {
+    /** Tenant ID. */
+    tenantId: string;
+
+    /** Interaction ID. */
+    interactionId: string;
+
+    /** Model used. */
+    model: string;
+
+    /** Prompt tokens (0 when unreported). */
+    promptTokens: number;
+
+    /** Completion tokens (0 when unreported). */
+    completionTokens: number;
+
+    /** Total tokens (0 when unreported). */
+    totalTokens: number;
+
+    /** End-to-end latency in ms (unset for batch results). */
+    latencyMs?: number | undefined;
+
+    /** Whether the request failed. */
+    error: boolean;
+
+    /** When the request completed. */
+    createdAt: Date;
+
+    /** Salted hash of the end-user ID the client sent (unset when it sent none). */
+    endUser?: string | undefined;
+
+    /** Detected response language (ISO 639-1), when the app classifies responses. */
+    language?: string | undefined;
+
+    /** Safety categories the response was flagged for (unset when none or unclassified). */
+    safetyFlags?: string[] | undefined;
+}
+
+/**
+ * Classification filter for request stats. Every criterion given must
+ * match.
+ */
+export interface RequestStatClassificationFilter {
+    /** Detected response language (ISO 639-1). */
+    language?: string | undefined;
+
+    /** Safety category the response was flagged for; '*' matches any flag. */
+    safety?: string | undefined;
+}
+
+/**
+ * One tenant's request stats for one model on one UTC day (and for one
+ * end user or response language, when grouped by them).
+ */
+export interface UsageStatsRow {
+    /** UTC day (YYYY-MM-DD). */
+    day: string;
+
+    /** Model. */
+    model: string;
+
+    /** End-user hash, when grouped by end user (unset for requests without one). */
+    endUser?: string | undefined;
+
+    /** Response language, when grouped by language (unset for unclassified requests). */
+    language?: string | undefined;
+
+    /** Number of requests. */
+    requests: number;
+
+    /** Number of failed requests. */
+    errors: number;
+
+    /** Prompt tokens. */
+    promptTokens: number;
+
+    /** Completion tokens. */
+    completionTokens: number;
+
+    /** Total tokens. */
+    totalTokens: number;
+
+    /** 95th percentile latency in ms (nearest rank), if any request reported one. */
+    p95LatencyMs?: number | undefined;
+
+    /** Requests whose response was flagged by the safety classifier, when grouped by language. */
+    safetyFlagged?: number | undefined;
+}
+
+/**
+ * Storage for per-request stats, aggregated for usage reports.
+ */
+export interface UsageStatsStore {
+    /**
+     * Records a request's outcome. Recording an interaction ID again
+     * replaces its earlier record, so failed writes can be retried.
+     */
+    recordRequestStat(record: RequestStatRecord): Promise<void>;
+
+    /**
+     * Aggregates one tenant's stats recorded in [from, to), by day and
+     * model (and end user, with byEndUser, or response language with
+     * byLanguage, which also counts safety-flagged requests), ordered by
+     * day, model, then end user or language. Always scoped to the tenant.
+     */
+    aggregateUsageStats(
+        tenantId: string,
+        from: Date,
+        to: Date,
+        options?: { byEndUser?: boolean | undefined; byLanguage?: boolean | undefined },
+    ): Promise<UsageStatsRow[]>;
+
+    /**
+     * Finds the tenant's request stats for an end-user hash, newest first.
+     * UNSCOPED_TENANT searches every tenant.
+     */
+    findRequestStatsByEndUser?(tenantId: string, endUser: string): Promise<RequestStatRecord[]>;
+
+    /**
+     * Finds the tenant's request stats matching a classification filter,
+     * newest first. UNSCOPED_TENANT searches every tenant.
+     */
+    findRequestStatsByClassification?(
+        tenantId: string,
+        filter: RequestStatClassificationFilter,
+    ): Promise<RequestStatRecord[]>;
+}
+
+// ============================================================================
+// Erasure Store Interface
+// ============================================================================
+
+/**
+ * Tombstone written in place of erased text and payloads.
+ */
+export const ERASED = '[erased]';
+
+/**
+ * Which interactions to erase. Every criterion given must match; at
+ * least one of metadata, threadKey, or interactionIds is required.
+ */
+export interface ErasureSelector {
+    /** Only erase this tenant's interactions. */
+    tenantId?: string | undefined;
+
+    /** Metadata entry identifying the end user (e.g. user_id). */
+    metadata?: { key: string; value: string } | undefined;
+
+    /** Responses API thread key. */
+    threadKey?: string | undefined;
+
+    /** Conversation, response, or thread IDs. */
+    interactionIds?: string[] | undefined;
+}
+
+/**
+ * Rows scrubbed by an erasure, by kind.
+ */
+export interface ErasureCounts {
+    conversations: number;
+    messages: number;
+    responses: number;
+    events: number;
+    shadowResults: number;
+    threads: number;
+    idempotencyKeys: number;
+    interactions: number;
+}
+
+/**
+ * Storage that can erase stored interactions (right to be forgotten).
+ */
+export interface ErasureStore {
+    /**
+     * Scrubs the content of matching interactions and everything recorded
+     * about them: message text, request and response bodies, event
+     * payloads, shadow outputs, captured client responses, requests kept
+     * in interaction records, and metadata are replaced with ERASED or
+     * dropped. Rows, IDs, models, status,
+     * usage, timings, and timestamps are kept for billing.
+     */
+    eraseInteractions(selector: ErasureSelector): Promise<ErasureCounts>;
+}
+
+// ============================================================================
+// Batch Store Interface
+// ============================================================================
+
+/**
+ * A message batch submitted through the gateway.
+ */
+export interface BatchRecord {
+    /** Gateway batch ID, as returned to the client. */
+    id: string;
+
+    /** Batch ID at the provider. */
+    providerBatchId: string;
+
+    /** Provider the batch was submitted to. */
+    provider: string;
+
+    /** Provider key that submitted it; follow-up calls use the same key. */
+    keyId?: string | undefined;
+
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** App the batch was submitted through. */
+    appName?: string | undefined;
+
+    /** Number of requests in the batch. */
+    requestCount: number;
+
+    /** Submission time. */
+    createdAt: Date;
+
+    /** When results were first fetched and recorded as interactions. */
+    resultsRecordedAt?: Date | undefined;
+}
+
+/**
+ * Storage for message batch records.
+ */
+export interface BatchStore {
+    /**
+     * Creates or replaces a batch record.
+     */
+    saveBatch(record: BatchRecord): Promise<void>;
+
+    /**
+     * Gets a batch owned by the tenant, or null.
+     */
+    getBatch(id: string, tenantId: string): Promise<BatchRecord | null>;
+}
+
+// ============================================================================
+// Metadata Index Interface
+// ============================================================================
+
+/**
+ * Correlation metadata captured from an interaction's request headers.
+ */
+export interface InteractionMetadataRecord {
+    /** Gateway interaction ID. */
+    interactionId: string;
+
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** App that served the request. */
+    appName?: string | undefined;
+
+    /** Captured values by key (lowercase header name). */
+    metadata: Record<string, string>;
+
+    /** Request time. */
+    createdAt: Date;
+}
+
+/**
+ * Index of interactions by correlation metadata, so a client's own
+ * request ID finds the gateway interaction in one indexed lookup.
+ */
+export interface MetadataIndexStore {
+    /**
+     * Indexes an interaction's metadata. Indexing an interaction again
+     * adds its new keys.
+     */
+    indexMetadata(record: InteractionMetadataRecord): Promise<void>;
+
+    /**
+     * Finds the tenant's interactions with a metadata value, newest first.
+     * UNSCOPED_TENANT searches every tenant.
+     */
+    findByMetadata(tenantId: string, key: string, value: string): Promise<InteractionMetadataRecord[]>;
+}
+
+// ============================================================================
+// Probe Store Interface
+// ============================================================================
+
+/**
+ * Result of one synthetic provider probe. Kept apart from interactions.
+ */
+export interface ProbeResultRecord {
+    /** Result ID. */
+    id: string;
+
+    /** Provider probed. */
+    provider: string;
+
+    /** Model probed. */
+    model: string;
+
+    /** Whether the provider answered. */
+    success: boolean;
+
+    /** HTTP status of a failed probe, when the provider returned one. */
+    statusCode?: number | undefined;
+
+    /** Failure message. */
+    error?: string | undefined;
+
+    /** Time to the provider's answer or failure (ms). */
+    latencyMs: number;
+
+    /** Tokens used. */
+    usage?: Usage | undefined;
+
+    /** Estimated cost (USD), when the model has pricing. */
+    costUsd?: number | undefined;
+
+    /** Probe time. */
+    createdAt: Date;
+}
+
+/**
+ * Storage for synthetic probe results.
+ */
+export interface ProbeStore {
+    /**
+     * Saves a probe result.
+     */
+    saveProbeResult(result: ProbeResultRecord): Promise<void>;
+
+    /**
+     * Lists a provider's most recent probe results, newest first.
+     */
+    listProbeResults(provider: string, limit: number): Promise<ProbeResultRecord[]>;
+}
+
+// ============================================================================
+// SLO Store Interface
+// ============================================================================
+
+/**
+ * Requests counted in one time slice of an app's SLO window.
+ */
+export interface SloBucketRecord {
+    /** Slice start (epoch ms). */
+    start: number;
+
+    /** Requests counted. */
+    total: number;
+
+    /** Requests that failed with a server error. */
+    errors: number;
+
+    /** Requests slower than the latency objective. */
+    slow: number;
+
+    /** Request counts per latency histogram bucket. */
+    latency: number[];
+}
+
+/**
+ * Snapshot of an app's SLO window, so a restart doesn't reset it.
+ */
+export interface SloSnapshotRecord {
+    /** App name. */
+    app: string;
+
+    /** The app's SLO objectives when the snapshot was taken (JSON); changed objectives start afresh. */
+    configKey: string;
+
+    /** Per-minute slices covering the burn windows, oldest first. */
+    minutes: SloBucketRecord[];
+
+    /** Per-hour slices covering the compliance window, oldest first. */
+    hours: SloBucketRecord[];
+
+    /** Time of the last burn-rate alert. */
+    lastAlertAt?: Date | undefined;
+
+    /** Snapshot time. */
+    savedAt: Date;
+}
+
+/**
+ * Storage for SLO window snapshots, one per app.
+ */
+export interface SloStore {
+    /**
+     * Saves an app's snapshot, replacing the previous one.
+     */
+    saveSloSnapshot(snapshot: SloSnapshotRecord): Promise<void>;
+
+    /**
+     * Gets an app's latest snapshot.
+     */
+    getSloSnapshot(app: string): Promise<SloSnapshotRecord | null>;
+}
+
+// ============================================================================
+// Secret Store Interface
+// ============================================================================
+
+/**
+ * A named secret owned by a tenant, sealed with the storage encryption key.
+ */
+export interface SecretRecord {
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** Secret name, unique within the tenant. */
+    name: string;
+
+    /** The value, sealed (gwenc:...); stores never see plaintext. */
+    value: string;
+
+    /** Incremented on every rotation, starting at 1. */
+    version: number;
+
+    /** Creation time. */
+    createdAt: Date;
+
+    /** Last rotation time. */
+    updatedAt: Date;
+}
+
+/**
+ * Storage for tenant secrets.
+ */
+export interface SecretStore {
+    /**
+     * Creates or replaces a secret.
+     */
+    saveSecret(secret: SecretRecord): Promise<void>;
+
+    /**
+     * Gets a tenant's secret by name, or null.
+     */
+    getSecret(tenantId: string, name: string): Promise<SecretRecord | null>;
+
+    /**
+     * Lists a tenant's secrets by name, or every tenant's without one.
+     */
+    listSecrets(tenantId?: string): Promise<SecretRecord[]>;
+
+    /**
+     * Deletes a secret. Returns whether it existed.
+     */
+    deleteSecret(tenantId: string, name: string): Promise<boolean>;
+}
+
+// ============================================================================
+// Attempt Store Interface
+// ============================================================================
+
+/**
+ * One provider call made for an interaction. An interaction's usage is
+ * the sum of its attempts' usage.
+ */
+export interface InteractionAttemptRecord {
+    /** Interaction ID. */
+    interactionId: string;
+
+    /** Call order within the interaction, from 1. */
+    attempt: number;
+
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** Provider called. */
+    provider: string;
+
+    /** Model requested. */
+    model: string;
+
+    /** Why the call was made. */
+    reason: AttemptReason;
+
+    /** Tokens used, when the provider reported them. */
+    usage?: Usage | undefined;
+
+    /** Estimated cost (USD), when the model has pricing. */
+    costUsd?: number | undefined;
+
+    /** Call duration (ms). */
+    durationMs: number;
+
+    /** Whether the call returned a response. */
+    outcome: 'success' | 'error';
+
+    /** Failure message. */
+    error?: string | undefined;
+
+    /** HTTP status of the provider's error response. */
+    statusCode?: number | undefined;
+
+    /** The provider's error response body as sent, kept whatever the client was shown. */
+    upstreamError?: string | undefined;
+
+    /** Whether this attempt's response was the one served to the client. */
+    served: boolean;
+
+    /** Call start time. */
+    createdAt: Date;
+}
+
+/**
+ * One tenant's attempts for one model and reason on one UTC day.
+ */
+export interface AttemptUsageRow {
+    /** UTC day (YYYY-MM-DD). */
+    day: string;
+
+    /** Model. */
+    model: string;
+
+    /** Why the calls were made. */
+    reason: AttemptReason;
+
+    /** Number of attempts. */
+    attempts: number;
+
+    /** Number of failed attempts. */
+    errors: number;
+
+    /** Prompt tokens. */
+    promptTokens: number;
+
+    /** Completion tokens. */
+    completionTokens: number;
+
+    /** Total tokens. */
+    totalTokens: number;
+
+    /** Estimated cost (USD) of the attempts with pricing. */
+    costUsd: number;
+}
+
+/**
+ * Storage for the provider calls behind each interaction.
+ */
+export interface AttemptStore {
+    /**
+     * Saves an interaction's attempts, replacing any saved before for the
+     * same interaction, so failed writes can be retried.
+     */
+    saveAttempts(records: InteractionAttemptRecord[]): Promise<void>;
+
+    /**
+     * Lists an interaction's attempts in call order. Nothing is returned
+     * for another tenant's interaction.
+     */
+    listAttempts(interactionId: string, tenantId: string): Promise<InteractionAttemptRecord[]>;
+
+    /**
+     * Aggregates one tenant's attempts started in [from, to), by day,
+     * model, and reason, ordered by day, model, then reason.
+     */
+    aggregateAttempts(tenantId: string, from: Date, to: Date): Promise<AttemptUsageRow[]>;
+}
+
+// ============================================================================
+// Sensitive Value Store Interface
+// ============================================================================
+
+/**
+ * Stored fields that hold prompt or completion text, as table.column.
+ */
+export type SensitiveField =
+    | 'responses.request'
+    | 'responses.response'
+    | 'responses.error'
+    | 'messages.content'
+    | 'interaction_events.payload'
+    | 'interactions.request';
+
+/** Every sensitive field, in the order maintenance visits them. */
+export const SENSITIVE_FIELDS: readonly SensitiveField[] = [
+    'responses.request',
+    'responses.response',
+    'responses.error',
+    'messages.content',
+    'interaction_events.payload',
+    'interactions.request',
+];
+
+/**
+ * One stored sensitive value, exactly as stored (sealed or plaintext).
+ */
+export interface SensitiveValue {
+    /** Field the value belongs to. */
+    field: SensitiveField;
+
+    /** ID of the row holding it. */
+    id: string;
+
+    /** Stored value. */
+    value: unknown;
+}
+
+/**
+ * Raw access to sensitive fields, so they can be re-encrypted in place.
+ * Values are read and written as stored, never decrypted.
+ */
+export interface SensitiveValueStore {
+    /**
+     * Lists up to `limit` values of a field from rows with IDs after
+     * `after`, in ID order. Rows with no value are skipped.
+     */
+    scanSensitiveValues(field: SensitiveField, after: string | undefined, limit: number): Promise<SensitiveValue[]>;
+
+    /**
+     * Overwrites one stored value.
+     */
+    updateSensitiveValue(value: SensitiveValue): Promise<void>;
+}
+
+// ============================================================================
+// Tenant Store Interface
+// ============================================================================
+
+/**
+ * A tenant created through the admin API.
+ */
+export interface StoredTenant {
+    /** Tenant ID. */
+    id: string;
+
+    /** Tenant name. */
+    name: string;
+
+    /** SHA-256 hashes of the tenant's API keys. */
+    apiKeys: { keyHash: string; description?: string | undefined }[];
+
+    /** Routing over the global providers (default: the global routing). */
+    routing?: RoutingConfig | undefined;
+
+    /** Disabled tenants' keys are rejected; tenants are never hard-deleted. */
+    disabled: boolean;
+
+    /** Creation time. */
+    createdAt: Date;
+
+    /** Last update time. */
+    updatedAt: Date;
+}
+
+/**
+ * Storage for tenants created at runtime.
+ */
+export interface TenantStore {
+    /**
+     * Creates or replaces a tenant.
+     */
+    saveTenant(tenant: StoredTenant): Promise<void>;
+
+    /**
+     * Gets a tenant by ID, or null.
+     */
+    getTenant(id: string): Promise<StoredTenant | null>;
+
+    /**
+     * Lists every stored tenant, disabled ones included.
+     */
+    listTenants(): Promise<StoredTenant[]>;
+}
+
+// ============================================================================
+// Thread Transfer Interface
+// ============================================================================
+
+/**
+ * Records written together by one step of a thread bundle import.
+ */
+export interface ThreadImportBatch {
+    conversations: Conversation[];
+    responses: ResponseRecord[];
+    threadState: Pick<ThreadStateEntry, 'threadKey' | 'responseId' | 'updatedAt'>[];
+}
+
+/**
+ * A thread bundle recorded in the import ledger.
+ */
+export interface ThreadImportRecord {
+    /** Bundle checksum (SHA-256 of its canonical content, hex). */
+    checksum: string;
+
+    /** Conversations written. */
+    conversations: number;
+
+    /** Responses written. */
+    responses: number;
+
+    /** Thread state mappings written. */
+    threadState: number;
+
+    /** Record IDs that were taken in this store, mapped to the IDs used instead. */
+    remapped: Record<string, string>;
+
+    /** When the import finished. */
+    importedAt: Date;
+}
+
+/**
+ * Storage that can import thread bundles from another deployment.
+ */
+export interface ThreadTransferStore {
+    /**
+     * Writes a batch in one transaction, replacing records with the same
+     * IDs and keys. Thread state keeps the given update times.
+     */
+    importThreadBatch(batch: ThreadImportBatch): Promise<void>;
+
+    /**
+     * Gets the ledger entry for a bundle checksum, or null.
+     */
+    getThreadImport(checksum: string): Promise<ThreadImportRecord | null>;
+
+    /**
+     * Records a bundle as imported.
+     */
+    recordThreadImport(record: ThreadImportRecord): Promise<void>;
+}
+
+// ============================================================================
+// Migratable Store Interface
+// ============================================================================
+
+/**
+ * A schema migration and when it was applied.
+ */
+export interface MigrationStatus {
+    /** Migration version. */
+    version: number;
+
+    /** Migration name. */
+    name: string;
+
+    /** When it was applied (unset = pending). */
+    appliedAt?: Date | undefined;
+}
+
+/**
+ * Outcome of applying pending migrations.
+ */
+export interface MigrationResult {
+    /** Versions that were run. */
+    applied: number[];
+
+    /** Versions already present in a pre-migration database, recorded without running. */
+    baselined: number[];
+
+    /** Schema version afterwards. */
+    version: number;
+}
+
+/**
+ * Storage with a versioned SQL schema.
+ */
+export interface MigratableStore {
+    /**
+     * Applies pending schema migrations.
+     */
+    migrate(): Promise<MigrationResult>;
+
+    /**
+     * Lists known migrations and which are applied.
+     */
+    migrationStatus(): Promise<MigrationStatus[]>;
+}
+
+// ============================================================================
+// Combined Storage Provider Interface
+// ============================================================================
+
+/**
+ * Full storage provider combining all storage interfaces.
+ *
+ * Single-item getters take the caller's tenant ID and return nothing for
+ * records owned by another tenant. Pass UNSCOPED_TENANT to skip the check.
+ */
+export interface StorageProvider
+    extends ConversationStore,
+    ResponseStore,
+    InteractionStore,
+    ShadowStore,
+    ThreadStateStore,
+    Partial<ThreadStore>,
+    Partial<IdempotencyStore>,
+    Partial<UsageStore>,
+    Partial<UsageStatsStore>,
+    Partial<ErasureStore>,
+    Partial<TenantStore>,
+    Partial<ThreadTransferStore>,
+    Partial<BatchStore>,
+    Partial<MetadataIndexStore>,
+    Partial<ProbeStore>,
+    Partial<SloStore>,
+    Partial<SecretStore>,
+    Partial<AttemptStore>,
+    Partial<SensitiveValueStore>,
+    Partial<MigratableStore> {
+    /**
+     * Checks that the store is reachable and writable. Rejects when it
+     * isn't. Used to probe recovery while storage is degraded.
+     */
+    ping?(): Promise<void>;
+
     /**
      * Closes the storage connection.
      */
@@ -0,0 +1,174 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AnthropicCodec } from './codecs/anthropic';
+import { OpenAICodec } from './codecs/openai';
+import type { APIType, CanonicalRequest } from './domain/types';
+import { PREFILL_INSTRUCTION } from './prefill/index';
+import type { PrefillStrategy } from './ports/config';
+import { WARNINGS_HEADER } from './warnings/collector';
+import { harness, ScriptedProvider, streamEvents, streamText, type Reply, type TestGateway } from './__tests__/harness/index';
+
+const prefillRequest = {
+    model: 'test-model',
+    max_tokens: 256,
+    messages: [
+        { role: 'user', content: 'List three colors as JSON.' },
+        { role: 'assistant', content: '{"colors": [' },
+    ],
+};
+
+/** The body a provider of the given API is sent for a request. */
+function wire(apiType: 'openai' | 'anthropic', request: CanonicalRequest | undefined): any {
+    const codec = apiType === 'openai' ? new OpenAICodec() : new AnthropicCodec();
+    return JSON.parse(new TextDecoder().decode(codec.encodeRequest(request!)));
+}
+
+function warnings(response: Response): unknown {
+    const header = response.headers.get(WARNINGS_HEADER);
+    return header ? JSON.parse(header) : null;
+}
+
+describe('Developer messages and prefill', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(apiType: APIType, reply: Reply = { text: 'Hello' }, prefill?: PrefillStrategy) {
+        const provider = new ScriptedProvider('upstream', reply, apiType);
+        gateway = await harness()
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic', prefill })
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', prefill })
+            .provider(provider)
+            .start();
+        return { gw: gateway, provider };
+    }
+
+    const developerRequest = {
+        model: 'test-model',
+        messages: [
+            { role: 'developer', content: 'Answer in French.' },
+            { role: 'user', content: 'Capital of Italy?' },
+        ],
+    };
+
+    describe('developer messages', () => {
+        it('should keep the developer role for OpenAI', async () => {
+            const { gw, provider } = await setup('openai');
+
+            const response = await gw.post('/v1/chat/completions', developerRequest);
+
+            expect(response.status).toBe(200);
+            expect(warnings(response)).toBeNull();
+            expect(wire('openai', provider.lastRequest).messages).toEqual(developerRequest.messages);
+        });
+
+        it('should send them to Anthropic as system text, and say so', async () => {
+            const { gw, provider } = await setup('anthropic');
+
+            const response = await gw.post('/v1/chat/completions', developerRequest);
+
+            expect(response.status).toBe(200);
+            const sent = wire('anthropic', provider.lastRequest);
+            expect(sent.system).toEqual([{ type: 'text', text: 'Answer in French.' }]);
+            expect(sent.messages).toEqual([{ role: 'user', content: [{ type: 'text', text: 'Capital of Italy?' }] }]);
+            expect(warnings(response)).toEqual([{
+                code: 'developer_role',
+                message: 'routed provider has no developer role; sent developer messages as system instructions',
+            }]);
+        });
+
+        it('should keep an Anthropic client\'s system prompt a system message for OpenAI', async () => {
+            const { gw, provider } = await setup('openai');
+
+            await gw.post('/anthropic/v1/messages', {
+                model: 'test-model',
+                max_tokens: 256,
+                system: 'Answer in French.',
+                messages: [{ role: 'user', content: 'Capital of Italy?' }],
+            });
+
+            expect(wire('openai', provider.lastRequest).messages[0]).toEqual({ role: 'system', content: 'Answer in French.' });
+        });
+    });
+
+    describe('prefill', () => {
+        it('should hand Anthropic the partial reply to continue', async () => {
+            const { gw, provider } = await setup('anthropic', { text: '"red", "green", "blue"]}' });
+
+            const response = await gw.post('/anthropic/v1/messages', prefillRequest);
+
+            expect(warnings(response)).toBeNull();
+            expect(wire('anthropic', provider.lastRequest).messages[1]).toEqual({
+                role: 'assistant',
+                content: [{ type: 'text', text: '{"colors": [' }],
+            });
+            expect((await response.json()).content).toEqual([{ type: 'text', text: '"red", "green", "blue"]}' }]);
+        });
+
+        it('should hand Anthropic an OpenAI client\'s trailing assistant message to continue', async () => {
+            const { gw, provider } = await setup('anthropic', { text: '"red"]}' });
+
+            const response = await gw.post('/v1/chat/completions', prefillRequest);
+
+            expect(wire('anthropic', provider.lastRequest).messages.at(-1).role).toBe('assistant');
+            expect((await response.json()).choices[0].message.content).toBe('"red"]}');
+        });
+
+        it('should ask OpenAI to begin with the partial reply, and return only the continuation', async () => {
+            const { gw, provider } = await setup('openai', { text: '{"colors": ["red", "green", "blue"]}' });
+
+            const response = await gw.post('/anthropic/v1/messages', prefillRequest);
+
+            expect(wire('openai', provider.lastRequest).messages).toEqual([
+                { role: 'user', content: 'List three colors as JSON.' },
+                { role: 'system', content: `${PREFILL_INSTRUCTION}\n\n{"colors": [` },
+            ]);
+            expect((await response.json()).content).toEqual([{ type: 'text', text: '"red", "green", "blue"]}' }]);
+            expect(warnings(response)).toEqual([{
+                code: 'prefill',
+                message: 'routed provider does not continue partial assistant messages; asked it to begin its reply with the text instead',
+            }]);
+        });
+
+        it('should strip the partial reply across stream chunks', async () => {
+            const { gw } = await setup('openai', { chunks: ['{"col', 'ors": ["r', 'ed"', ']}'] });
+
+            const response = await gw.post('/anthropic/v1/messages', { ...prefillRequest, stream: true });
+
+            expect(streamText(streamEvents(await response.text(), 'anthropic'))).toBe('"red"]}');
+        });
+
+        it('should return a reply that does not repeat the partial reply as it was', async () => {
+            const { gw } = await setup('openai', { chunks: ['{"col', 'ours": []}'] });
+
+            const stream = await gw.post('/anthropic/v1/messages', { ...prefillRequest, stream: true });
+            const whole = await gw.post('/anthropic/v1/messages', prefillRequest);
+
+            expect(streamText(streamEvents(await stream.text(), 'anthropic'))).toBe('{"colours": []}');
+            expect((await whole.json()).content[0].text).toBe('{"colours": []}');
+        });
+
+        it('should send the partial reply as-is with the passthrough strategy', async () => {
+            const { gw, provider } = await setup('openai', { text: '"red"]}' }, 'passthrough');
+
+            const response = await gw.post('/anthropic/v1/messages', prefillRequest);
+
+            expect(warnings(response)).toBeNull();
+            expect(wire('openai', provider.lastRequest).messages.at(-1)).toEqual({ role: 'assistant', content: '{"colors": [' });
+            expect((await response.json()).content[0].text).toBe('"red"]}');
+        });
+
+        it('should reject the partial reply with the reject strategy', async () => {
+            const { gw, provider } = await setup('openai', { text: '"red"]}' }, 'reject');
+
+            const response = await gw.post('/anthropic/v1/messages', prefillRequest);
+
+            expect(response.status).toBe(400);
+            expect((await response.json()).error.message)
+                .toBe("routed provider 'upstream' cannot continue a partial assistant message");
+            expect(provider.requests).toHaveLength(0);
+        });
+    });
+});
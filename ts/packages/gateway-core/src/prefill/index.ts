@@ -0,0 +1,13 @@
+/**
+ * Assistant prefill exports.
+ *
+ * @module prefill
+ */
+
+export {
+    PrefillStrippingProvider,
+    withPrefillStripped,
+    instructPrefill,
+    trailingPrefill,
+    PREFILL_INSTRUCTION,
+} from './prefill.js';
@@ -0,0 +1,181 @@
+/**
+ * Assistant prefill across providers.
+ *
+ * A request ending in an assistant message asks the model to continue that
+ * partial reply (Anthropic's prefill), and the response carries only the
+ * continuation. Anthropic continues it natively, and legacy completions
+ * continue the flattened prompt; OpenAI-style chat APIs would take it for a
+ * finished turn. For those the app's strategy applies: the partial reply is
+ * turned into a closing instruction to begin with it (the default), sent
+ * as-is for servers that continue it themselves, or rejected. When it
+ * becomes an instruction the model's reply starts with the partial reply,
+ * which is stripped, so the client gets only the continuation either way.
+ *
+ * @module prefill/prefill
+ */
+
+import type {
+    APIType,
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import type { AppConfig, PrefillStrategy } from '../ports/config.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** APIs that continue a trailing assistant message themselves. */
+const NATIVE_PREFILL_APIS: ReadonlySet<APIType> = new Set(['anthropic', 'completions']);
+
+/** Introduces the partial reply in the instruction that replaces it. */
+export const PREFILL_INSTRUCTION = 'Begin your reply with exactly the following text, then continue it:';
+
+/** Transformation stage recording a prefill turned into an instruction. */
+const PREFILL_STAGE = 'prefill';
+
+// ============================================================================
+// Request
+// ============================================================================
+
+/**
+ * Returns the partial reply a request asks the model to continue: the text
+ * of a trailing assistant message without tool calls, if any.
+ */
+export function trailingPrefill(request: Pick<CanonicalRequest, 'messages'>): string | undefined {
+    const last = request.messages[request.messages.length - 1];
+    if (last?.role !== 'assistant' || last.toolCalls?.length || !last.content) {
+        return undefined;
+    }
+    return last.content;
+}
+
+/**
+ * Applies the app's prefill strategy for providers that don't continue a
+ * trailing assistant message, in place. With the instruction strategy the
+ * message is replaced by a closing system instruction to begin the reply
+ * with its text; with reject the request is a 400. Returns the step
+ * applied, for interaction recording; the caller strips the repeated text
+ * from the response with withPrefillStripped.
+ */
+export function instructPrefill(
+    request: CanonicalRequest,
+    provider: Pick<Provider, 'name' | 'apiType'>,
+    app: Pick<AppConfig, 'prefill'> | undefined,
+): TransformationStep | undefined {
+    const prefill = trailingPrefill(request);
+    const strategy: PrefillStrategy = app?.prefill ?? 'instruction';
+    if (prefill === undefined || NATIVE_PREFILL_APIS.has(provider.apiType) || strategy === 'passthrough') {
+        return undefined;
+    }
+    if (strategy === 'reject') {
+        throw errInvalidRequest(`routed provider '${provider.name}' cannot continue a partial assistant message`)
+            .withParam('messages');
+    }
+
+    request.messages = [
+        ...request.messages.slice(0, -1),
+        { role: 'system', content: `${PREFILL_INSTRUCTION}\n\n${prefill}` },
+    ];
+    return {
+        stage: PREFILL_STAGE,
+        timestamp: new Date(),
+        description: `Turned the trailing assistant message into an instruction for provider '${provider.name}'`,
+        details: { provider: provider.name, characters: prefill.length, unmappedFields: ['messages[].role'] },
+        warnings: ['routed provider does not continue partial assistant messages; asked it to begin its reply with the text instead'],
+    };
+}
+
+// ============================================================================
+// Stripping Provider
+// ============================================================================
+
+/**
+ * Wraps a provider whose replies begin with the prefill, removing it so
+ * only the continuation is returned. Replies that don't repeat it whole are
+ * returned unchanged.
+ */
+export class PrefillStrippingProvider extends ProviderWrapper {
+    private readonly prefill: string;
+
+    constructor(inner: Provider, prefill: string) {
+        super(inner);
+        this.prefill = prefill;
+    }
+
+    /**
+     * Completes a request, stripping the prefill from each choice.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, options);
+        for (const choice of response.choices) {
+            if (choice.message.content.startsWith(this.prefill)) {
+                choice.message.content = choice.message.content.slice(this.prefill.length);
+            }
+        }
+        return response;
+    }
+
+    /**
+     * Streams a request, holding each choice's text deltas until they have
+     * either repeated the prefill (and are dropped, but for any text past
+     * it) or departed from it (and are sent as they were).
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const held = new Map<number, { events: CanonicalEvent[]; text: string }>();
+        const settled = new Set<number>();
+
+        for await (const event of this.inner.stream(request, options)) {
+            const choice = event.choiceIndex ?? 0;
+            if (event.contentDelta === undefined || settled.has(choice)) {
+                if (event.contentDelta === undefined) {
+                    // Anything but text ends the reply's opening
+                    for (const [index, pending] of held) {
+                        settled.add(index);
+                        yield* pending.events;
+                    }
+                    held.clear();
+                }
+                yield event;
+                continue;
+            }
+
+            const pending = held.get(choice) ?? { events: [], text: '' };
+            held.set(choice, pending);
+            pending.events.push(event);
+            pending.text += event.contentDelta;
+            if (pending.text.length >= this.prefill.length) {
+                settled.add(choice);
+                held.delete(choice);
+                if (pending.text.startsWith(this.prefill)) {
+                    const rest = pending.text.slice(this.prefill.length);
+                    if (rest) {
+                        yield { ...event, contentDelta: rest };
+                    }
+                } else {
+                    yield* pending.events;
+                }
+            } else if (!this.prefill.startsWith(pending.text)) {
+                settled.add(choice);
+                held.delete(choice);
+                yield* pending.events;
+            }
+        }
+
+        for (const pending of held.values()) {
+            yield* pending.events;
+        }
+    }
+}
+
+/**
+ * Strips the prefill from the provider's replies.
+ */
+export function withPrefillStripped(provider: Provider, prefill: string): Provider {
+    return new PrefillStrippingProvider(provider, prefill);
+}
@@ -0,0 +1,128 @@
+import { describe, it, expect, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { emptyErasureCounts, matchesErasure, scrubShadowResult } from './privacy/index';
+import { ERASED } from './ports/index';
+
+const flush = () => new Promise((resolve) => setTimeout(resolve, 0));
+
+function logger() {
+    const log = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    log.child.mockReturnValue(log);
+    return log;
+}
+
+function erase(admin: AdminHandler, body: unknown) {
+    return admin.handle(new Request('http://localhost/api/privacy/erase', {
+        method: 'POST',
+        body: JSON.stringify(body),
+    }));
+}
+
+describe('matchesErasure', () => {
+    const record = { id: 'c1', tenantId: 'tenant-a', metadata: { user_id: 'u-42' } };
+
+    it('should require every given criterion to match', () => {
+        const metadata = { key: 'user_id', value: 'u-42' };
+
+        expect(matchesErasure(record, { tenantId: 'tenant-a', metadata })).toBe(true);
+        expect(matchesErasure(record, { tenantId: 'tenant-b', metadata })).toBe(false);
+        expect(matchesErasure(record, { metadata, interactionIds: ['c2'] })).toBe(false);
+        expect(matchesErasure(record, { threadKey: 'th-1' })).toBe(false);
+        expect(matchesErasure(record, { interactionIds: ['c1'] })).toBe(true);
+    });
+
+    it('should match nothing with a tenant alone', () => {
+        expect(matchesErasure(record, { tenantId: 'tenant-a' })).toBe(false);
+    });
+});
+
+describe('scrubShadowResult', () => {
+    it('should erase output and error text but keep usage and divergence types', () => {
+        const scrubbed = scrubShadowResult({
+            id: 's1', interactionId: 'c1', providerName: 'shadow', durationMs: 5, hasStructuralDivergence: true,
+            createdAt: new Date(),
+            response: {
+                id: 'x', model: 'm', content: 'secret',
+                usage: { promptTokens: 1, completionTokens: 2, totalTokens: 3 },
+                toolCalls: [{ id: 't', name: 'lookup', arguments: '{"ssn":"123"}' }],
+            },
+            error: { type: 'api_error', message: 'echoed secret' },
+            divergences: [{ type: 'error_presence', description: 'Shadow failed with error: echoed secret', severity: 'critical' }],
+        });
+
+        expect(JSON.stringify(scrubbed)).not.toContain('secret');
+        expect(JSON.stringify(scrubbed)).not.toContain('ssn');
+        expect(scrubbed.response?.usage.totalTokens).toBe(3);
+        expect(scrubbed.response?.toolCalls?.[0]?.name).toBe('lookup');
+        expect(scrubbed.divergences[0]?.type).toBe('error_presence');
+        expect(scrubbed.error?.message).toBe(ERASED);
+    });
+});
+
+describe('POST /api/privacy/erase', () => {
+    it('should run the erasure in the background and report counts by job ID', async () => {
+        const counts = { ...emptyErasureCounts(), conversations: 2, messages: 6 };
+        const eraseInteractions = vi.fn(async () => counts);
+        const log = logger();
+        const admin = new AdminHandler({ storage: { eraseInteractions } as any, logger: log as any });
+
+        const response = await erase(admin, { tenant_id: 'tenant-a', metadata: { key: 'user_id', value: 'u-42' } });
+        const job = await response.json() as any;
+
+        expect(response.status).toBe(202);
+        expect(job.status).toBe('running');
+        expect(eraseInteractions).toHaveBeenCalledWith({ tenantId: 'tenant-a', metadata: { key: 'user_id', value: 'u-42' } });
+
+        await flush();
+        const polled = await admin.handle(new Request(`http://localhost/api/privacy/jobs/${job.id}`));
+        expect(await polled.json()).toMatchObject({ id: job.id, status: 'completed', counts });
+
+        const audit = log.info.mock.calls.map(([event, fields]) => [event, fields.jobId]);
+        expect(audit).toEqual([['privacy_erasure_started', job.id], ['privacy_erasure_completed', job.id]]);
+        expect(JSON.stringify(log.info.mock.calls)).not.toContain('u-42');
+    });
+
+    it('should report failed erasures on the job', async () => {
+        const admin = new AdminHandler({
+            storage: { eraseInteractions: async () => { throw new Error('database is locked'); } } as any,
+        });
+
+        const job = await (await erase(admin, { thread_key: 'th-1' })).json() as any;
+        await flush();
+        const polled = await admin.handle(new Request(`http://localhost/api/privacy/jobs/${job.id}`));
+
+        expect(await polled.json()).toMatchObject({ status: 'failed', error: 'database is locked' });
+    });
+
+    it.each([
+        ['no selector', { tenant_id: 'tenant-a' }, 'One of metadata, thread_key, or interaction_ids is required'],
+        ['metadata without a tenant', { metadata: { key: 'user_id', value: 'u-42' } }, 'metadata requires tenant_id'],
+        ['a metadata key outside the allowed characters', { tenant_id: 't', metadata: { key: "a') OR 1=1", value: 'x' } },
+            'metadata must be {"key": ..., "value": ...} with a non-empty key and value'],
+        ['empty interaction IDs', { interaction_ids: [] }, 'interaction_ids must be a non-empty array of strings'],
+    ])('should reject %s', async (_name, body, message) => {
+        const eraseInteractions = vi.fn();
+        const admin = new AdminHandler({ storage: { eraseInteractions } as any });
+
+        const response = await erase(admin, body);
+
+        expect(response.status).toBe(400);
+        expect(await response.json()).toEqual({ error: message });
+        expect(eraseInteractions).not.toHaveBeenCalled();
+    });
+
+    it('should be operator-only and need an erasure-capable store', async () => {
+        const auth = { authenticate: async () => ({ tenantId: 'tenant-a', scopes: [], metadata: {} }), getTenant: async () => null };
+        const scoped = new AdminHandler({ storage: { eraseInteractions: vi.fn() } as any, auth: auth as any });
+        const request = new Request('http://localhost/api/privacy/erase', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k' },
+            body: JSON.stringify({ interaction_ids: ['c1'] }),
+        });
+        expect((await scoped.handle(request)).status).toBe(403);
+
+        const unsupported = new AdminHandler({ storage: {} as any });
+        expect((await erase(unsupported, { interaction_ids: ['c1'] })).status).toBe(503);
+        expect((await unsupported.handle(new Request('http://localhost/api/privacy/jobs/nope'))).status).toBe(404);
+    });
+});
@@ -0,0 +1,263 @@
+/**
+ * Erasure of stored interactions (right to be forgotten).
+ *
+ * An erasure selects interactions by tenant plus a metadata entry, a
+ * Responses API thread key, or explicit IDs, and scrubs their content in
+ * storage while keeping the rows, usage, and timings billing depends on.
+ * Erasures run as background jobs the admin API can poll. Each job is
+ * logged for audit with the selector's shape but never its values, so the
+ * log does not re-record the identifier being forgotten.
+ *
+ * @module privacy/erasure
+ */
+
+import type {
+    StorageProvider,
+    ErasureStore,
+    ErasureSelector,
+    ErasureCounts,
+    StoredMessage,
+    StoredHTTPResponse,
+} from '../ports/storage.js';
+import { ERASED } from '../ports/storage.js';
+import type { ShadowResult } from '../domain/shadow.js';
+import type { Logger } from '../utils/logging.js';
+import { randomUUID } from '../utils/crypto.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Finished jobs kept for polling; the oldest are forgotten first. */
+export const MAX_ERASURE_JOBS = 1000;
+
+/** Lifecycle state of an erasure job. */
+export type ErasureJobStatus = 'running' | 'completed' | 'failed';
+
+/**
+ * An erasure job, as reported by /admin/api/privacy/jobs/{id}.
+ */
+export interface ErasureJob {
+    /** Job ID. */
+    id: string;
+
+    /** Job state. */
+    status: ErasureJobStatus;
+
+    /** The selector, with its values left out. */
+    selector: ErasureSelectorSummary;
+
+    /** Rows scrubbed (once completed). */
+    counts?: ErasureCounts | undefined;
+
+    /** Failure message (once failed). */
+    error?: string | undefined;
+
+    /** When the job was started. */
+    createdAt: Date;
+
+    /** When the job finished. */
+    completedAt?: Date | undefined;
+}
+
+/**
+ * What an erasure selected on, without the identifying values.
+ */
+export interface ErasureSelectorSummary {
+    tenantId?: string | undefined;
+    metadataKey?: string | undefined;
+    threadKey: boolean;
+    interactionIds: number;
+}
+
+/**
+ * Erasure job options.
+ */
+export interface ErasureJobsOptions {
+    /** Store to erase from. */
+    store: ErasureStore;
+
+    /** Logger for the audit trail. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Erasure Jobs
+// ============================================================================
+
+/**
+ * Runs erasures in the background and tracks them for polling.
+ */
+export class ErasureJobs {
+    private readonly store: ErasureStore;
+    private readonly logger?: Logger | undefined;
+    private readonly jobs = new Map<string, ErasureJob>();
+
+    constructor(options: ErasureJobsOptions) {
+        this.store = options.store;
+        this.logger = options.logger;
+    }
+
+    /**
+     * Starts an erasure and returns its job without waiting for it.
+     */
+    start(selector: ErasureSelector): ErasureJob {
+        const job: ErasureJob = {
+            id: `erase_${randomUUID().replace(/-/g, '')}`,
+            status: 'running',
+            selector: summarizeSelector(selector),
+            createdAt: new Date(),
+        };
+        this.jobs.set(job.id, job);
+        this.prune();
+
+        this.logger?.info('privacy_erasure_started', { audit: true, jobId: job.id, selector: job.selector });
+        void this.run(job, selector);
+        return { ...job };
+    }
+
+    /**
+     * Gets a job by ID.
+     */
+    get(id: string): ErasureJob | undefined {
+        const job = this.jobs.get(id);
+        return job && { ...job };
+    }
+
+    private async run(job: ErasureJob, selector: ErasureSelector): Promise<void> {
+        try {
+            job.counts = await this.store.eraseInteractions(selector);
+            job.status = 'completed';
+            this.logger?.info('privacy_erasure_completed', {
+                audit: true,
+                jobId: job.id,
+                selector: job.selector,
+                counts: job.counts,
+            });
+        } catch (error) {
+            job.status = 'failed';
+            job.error = error instanceof Error ? error.message : String(error);
+            this.logger?.error('privacy_erasure_failed', {
+                audit: true,
+                jobId: job.id,
+                selector: job.selector,
+                error: job.error,
+            });
+        }
+        job.completedAt = new Date();
+    }
+
+    private prune(): void {
+        for (const [id, job] of this.jobs) {
+            if (this.jobs.size <= MAX_ERASURE_JOBS) {
+                return;
+            }
+            if (job.status !== 'running') {
+                this.jobs.delete(id);
+            }
+        }
+    }
+}
+
+// ============================================================================
+// Helpers for Store Implementations
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements ErasureStore.
+ */
+export function isErasureStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & ErasureStore {
+    return storage !== undefined && typeof storage.eraseInteractions === 'function';
+}
+
+/**
+ * Zeroed erasure counts.
+ */
+export function emptyErasureCounts(): ErasureCounts {
+    return {
+        conversations: 0,
+        messages: 0,
+        responses: 0,
+        events: 0,
+        shadowResults: 0,
+        threads: 0,
+        idempotencyKeys: 0,
+        interactions: 0,
+    };
+}
+
+/**
+ * Whether a stored conversation, response, thread, or interaction record
+ * matches a selector.
+ * Records without a thread key never match a thread key selector, and a
+ * selector with nothing but a tenant matches nothing.
+ */
+export function matchesErasure(
+    record: {
+        id: string;
+        tenantId: string;
+        metadata?: Record<string, string> | undefined;
+        threadKey?: string | undefined;
+    },
+    selector: ErasureSelector,
+): boolean {
+    if (!selector.metadata && !selector.threadKey && !selector.interactionIds) {
+        return false;
+    }
+    if (selector.tenantId && record.tenantId !== selector.tenantId) {
+        return false;
+    }
+    if (selector.metadata && record.metadata?.[selector.metadata.key] !== selector.metadata.value) {
+        return false;
+    }
+    if (selector.threadKey && record.threadKey !== selector.threadKey) {
+        return false;
+    }
+    if (selector.interactionIds && !selector.interactionIds.includes(record.id)) {
+        return false;
+    }
+    return true;
+}
+
+/**
+ * A message with its content, and the hash of it, erased.
+ */
+export function scrubMessage(message: StoredMessage): StoredMessage {
+    return { ...message, content: ERASED, contentHash: undefined };
+}
+
+/**
+ * A shadow result with its output and error text erased. Usage, duration,
+ * and divergence types are kept; the error divergence's description
+ * quotes the error message, so it is erased too.
+ */
+export function scrubShadowResult(result: ShadowResult): ShadowResult {
+    return {
+        ...result,
+        response: result.response && {
+            ...result.response,
+            content: ERASED,
+            toolCalls: result.response.toolCalls?.map((call) => ({ ...call, arguments: ERASED })),
+        },
+        error: result.error && { ...result.error, message: ERASED },
+        divergences: result.divergences.map((d) => d.type === 'error_presence' ? { ...d, description: ERASED } : d),
+    };
+}
+
+/**
+ * A captured client response with its body erased.
+ */
+export function scrubHTTPResponse(response: StoredHTTPResponse): StoredHTTPResponse {
+    return { ...response, body: ERASED };
+}
+
+function summarizeSelector(selector: ErasureSelector): ErasureSelectorSummary {
+    return {
+        tenantId: selector.tenantId || undefined,
+        metadataKey: selector.metadata?.key,
+        threadKey: selector.threadKey !== undefined,
+        interactionIds: selector.interactionIds?.length ?? 0,
+    };
+}
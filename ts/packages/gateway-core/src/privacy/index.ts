@@ -0,0 +1,20 @@
+/**
+ * Privacy erasure exports.
+ *
+ * @module privacy
+ */
+
+export {
+    ErasureJobs,
+    MAX_ERASURE_JOBS,
+    isErasureStore,
+    emptyErasureCounts,
+    matchesErasure,
+    scrubMessage,
+    scrubShadowResult,
+    scrubHTTPResponse,
+    type ErasureJob,
+    type ErasureJobStatus,
+    type ErasureJobsOptions,
+    type ErasureSelectorSummary,
+} from './erasure.js';
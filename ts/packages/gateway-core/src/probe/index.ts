@@ -0,0 +1,28 @@
+/**
+ * Synthetic provider probe exports.
+ *
+ * @module probe
+ */
+
+export {
+    ProviderProber,
+    DEFAULT_PROBE_INTERVAL_MS,
+    DEFAULT_PROBE_TIMEOUT_MS,
+    DEFAULT_PROBE_PROMPT,
+    DEFAULT_PROBE_MAX_TOKENS,
+    DEFAULT_PROBE_WINDOW,
+    DEFAULT_PROBE_MIN_SUCCESS_RATE,
+    DEFAULT_PROBE_ALERT_COOLDOWN_MS,
+    MIN_PROBE_SAMPLES,
+    type ProbeTarget,
+    type ProviderProberOptions,
+    type ProbeSummary,
+    type ProbeReport,
+    type ProbeAlert,
+} from './prober.js';
+
+export {
+    MemoryProbeStore,
+    isProbeStore,
+    DEFAULT_PROBE_RESULTS_SIZE,
+} from './store.js';
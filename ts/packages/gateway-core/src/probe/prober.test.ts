@@ -0,0 +1,173 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { ProviderProber, MemoryProbeStore } from './index';
+import type { ProviderProbeConfig } from '../ports/index';
+import { APIError } from '../domain/errors';
+import type { CanonicalResponse } from '../domain/types';
+import { harness, spyLogger, ScriptedProvider } from '../__tests__/harness/index';
+
+function answer(model: string): CanonicalResponse {
+    return {
+        id: 'chatcmpl-probe', object: 'chat.completion', created: 0, model, sourceAPIType: 'openai',
+        usage: { promptTokens: 8, completionTokens: 1, totalTokens: 9 },
+        choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'pong' } }],
+    };
+}
+
+function setup(config: Partial<ProviderProbeConfig>, outcomes: ('ok' | 'fail' | 'slow')[]) {
+    let now = Date.UTC(2025, 0, 1);
+    const provider = {
+        name: 'openai',
+        apiType: 'openai' as const,
+        complete: vi.fn(async (req: any) => {
+            const outcome = outcomes.shift() ?? 'ok';
+            now += outcome === 'slow' ? 6000 : 200;
+            if (outcome === 'fail') {
+                throw new APIError('server', 'upstream unavailable', { statusCode: 503 });
+            }
+            return answer(req.model);
+        }),
+        stream: vi.fn(),
+    };
+    const fetch = vi.fn(async (_url: string | URL | Request, _init?: RequestInit) => new Response(null, { status: 204 }));
+    const store = new MemoryProbeStore();
+    const prober = new ProviderProber({
+        store,
+        estimateCost: (_model, usage) => usage.totalTokens * 0.000001,
+        env: { ALERT_TOKEN: 's3cret' },
+        fetch: fetch as typeof globalThis.fetch,
+        clock: () => now,
+    });
+    prober.configure(new Map([['openai', { provider, config: { model: 'gpt-4o-mini', ...config } }]]));
+    return {
+        prober,
+        provider,
+        fetch,
+        store,
+        advance: (ms: number) => {
+            now += ms;
+        },
+    };
+}
+
+describe('ProviderProber', () => {
+    afterEach(() => {
+        vi.useRealTimers();
+    });
+
+    it('should record results and mark a provider degraded below its success rate', async () => {
+        vi.useFakeTimers();
+        const { prober, provider } = setup({}, ['ok', 'fail', 'fail']);
+
+        await prober.probe('openai');
+        expect(prober.degraded('openai')).toBe(false);
+        await prober.probe('openai');
+        await prober.probe('openai');
+
+        expect(provider.complete.mock.calls[0]![0]).toMatchObject({
+            tenantId: '',
+            model: 'gpt-4o-mini',
+            messages: [{ role: 'user', content: 'ping' }],
+            maxTokens: 5,
+        });
+        expect(prober.degraded('openai')).toBe(true);
+        const report = await prober.report('openai', 10);
+        expect(report).toMatchObject({ provider: 'openai', samples: 3, degraded: true, lastError: 'upstream unavailable' });
+        expect(report!.successRate).toBeCloseTo(1 / 3);
+        expect(report!.results.map((r) => [r.success, r.statusCode])).toEqual([[false, 503], [false, 503], [true, undefined]]);
+        expect(report!.results[2]).toMatchObject({ latencyMs: 200, usage: { totalTokens: 9 } });
+        expect(report!.results[2]!.costUsd).toBeCloseTo(0.000009);
+        expect(prober.degraded('anthropic')).toBe(false);
+        expect(await prober.report('anthropic', 10)).toBeUndefined();
+    });
+
+    it('should alert on a low success rate at most once per cooldown', async () => {
+        vi.useFakeTimers();
+        const { prober, fetch, advance } = setup({
+            alert: { webhook: 'https://alerts.example/hook', cooldown: '10m', headers: { Authorization: 'Bearer ${env:ALERT_TOKEN}' } },
+        }, ['fail', 'fail', 'fail', 'fail', 'fail', 'fail']);
+
+        for (let i = 0; i < 5; i++) {
+            await prober.probe('openai');
+        }
+        expect(fetch).toHaveBeenCalledTimes(1);
+        const [url, init] = fetch.mock.calls[0]!;
+        expect(url).toBe('https://alerts.example/hook');
+        expect((init!.headers as Record<string, string>).Authorization).toBe('Bearer s3cret');
+        expect(JSON.parse(init!.body as string)).toMatchObject({
+            type: 'provider_probe_alert',
+            provider: 'openai',
+            reason: 'success_rate',
+            threshold: 0.5,
+            successRate: 0,
+            samples: 3,
+        });
+
+        advance(10 * 60_000);
+        await prober.probe('openai');
+        expect(fetch).toHaveBeenCalledTimes(2);
+    });
+
+    it('should alert when latency p95 exceeds its bound', async () => {
+        vi.useFakeTimers();
+        const { prober, fetch } = setup({ alert: { webhook: 'https://alerts.example/hook', maxP95: '5s' } }, ['ok', 'ok', 'slow']);
+
+        for (let i = 0; i < 3; i++) {
+            await prober.probe('openai');
+        }
+
+        expect(prober.degraded('openai')).toBe(false);
+        expect(JSON.parse(fetch.mock.calls[0]![1]!.body as string)).toMatchObject({
+            reason: 'latency_p95',
+            threshold: 5000,
+            p95Ms: 6000,
+            successRate: 1,
+        });
+    });
+});
+
+describe('Gateway provider probes', () => {
+    it('should probe on load, report results, and never count probes as usage', async () => {
+        const provider = new ScriptedProvider('mock', { text: 'pong', usage: { promptTokens: 8, completionTokens: 1, totalTokens: 9 } });
+        const probeStore = new MemoryProbeStore();
+        const storage = {
+            saveProbeResult: (r: any) => probeStore.saveProbeResult(r),
+            listProbeResults: (p: string, limit: number) => probeStore.listProbeResults(p, limit),
+            recordUsage: vi.fn(async () => undefined),
+            sumUsage: vi.fn(async () => ({ tokens: 0, costUsd: 0, requests: 0 })),
+            recordRequestStat: vi.fn(async () => undefined),
+            aggregateUsageStats: vi.fn(async () => []),
+        };
+        const publish = vi.fn(async () => undefined);
+        const gw = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(provider)
+            .config({
+                providers: [{ name: 'openai', type: 'mock', apiKey: '', probe: { model: 'gpt-4o-mini', interval: '1h' } }],
+                routing: { defaultProvider: 'openai' },
+            })
+            .options({ storage: storage as any, events: { publish } as any, logger: spyLogger() })
+            .start();
+        const gateway = gw.gateway;
+        await gateway.reload();
+
+        await vi.waitFor(() => expect(gateway.providerHealth()[0]!.probe).toMatchObject({ samples: 1, successRate: 1 }));
+        const admin = new AdminHandler({ probes: (name, limit) => gateway.probeReport(name, limit) });
+        const response = await admin.handle(new Request('http://localhost/api/providers/openai/probes?limit=5'));
+        const missing = await admin.handle(new Request('http://localhost/api/providers/anthropic/probes'));
+        await gw.close();
+
+        expect(response.status).toBe(200);
+        expect(await response.json()).toMatchObject({
+            provider: 'openai',
+            model: 'gpt-4o-mini',
+            degraded: false,
+            results: [{ provider: 'openai', success: true, usage: { totalTokens: 9 } }],
+        });
+        expect(missing.status).toBe(404);
+        expect(provider.requests).toHaveLength(1);
+        expect(storage.recordUsage).not.toHaveBeenCalled();
+        expect(storage.recordRequestStat).not.toHaveBeenCalled();
+        expect(publish).not.toHaveBeenCalled();
+    });
+});
@@ -0,0 +1,433 @@
+/**
+ * Synthetic provider probes.
+ *
+ * Each provider with a probe config gets a tiny request on an interval, so
+ * a degraded provider is noticed during low traffic rather than from
+ * client complaints. Results are saved apart from interactions, and a
+ * rolling window of them gives each provider a success rate and latency
+ * p95. A provider whose success rate falls below its threshold counts as
+ * degraded, and an optional webhook is alerted (with a cooldown) when the
+ * success rate or p95 crosses its bound.
+ *
+ * Probes call the provider directly, not through an app: they are never
+ * attributed to a tenant and never counted in usage or budgets.
+ *
+ * @module probe/prober
+ */
+
+import type { CanonicalRequest, Usage } from '../domain/types.js';
+import { APIError } from '../domain/errors.js';
+import type { ProviderProbeConfig } from '../ports/config.js';
+import type { Provider } from '../ports/provider.js';
+import type { ProbeResultRecord, ProbeStore } from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { randomUUID } from '../utils/crypto.js';
+import { parseDuration } from '../utils/duration.js';
+import { postAlert } from '../utils/alerts.js';
+import { percentile } from '../utils/timings.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default time between probes. */
+export const DEFAULT_PROBE_INTERVAL_MS = 60_000;
+
+/** Default per-probe timeout. */
+export const DEFAULT_PROBE_TIMEOUT_MS = 10_000;
+
+/** Default probe message. */
+export const DEFAULT_PROBE_PROMPT = 'ping';
+
+/** Default probe max tokens. */
+export const DEFAULT_PROBE_MAX_TOKENS = 5;
+
+/** Default number of results in the rolling window. */
+export const DEFAULT_PROBE_WINDOW = 20;
+
+/** Default rolling success rate below which a provider is degraded. */
+export const DEFAULT_PROBE_MIN_SUCCESS_RATE = 0.5;
+
+/** Default minimum time between alerts for a provider. */
+export const DEFAULT_PROBE_ALERT_COOLDOWN_MS = 15 * 60_000;
+
+/** Results needed before the rolling window can mark a provider degraded or alert. */
+export const MIN_PROBE_SAMPLES = 3;
+
+/**
+ * A provider to probe.
+ */
+export interface ProbeTarget {
+    /** The provider, as the gateway serves it. */
+    provider: Provider;
+
+    /** Its probe config. */
+    config: ProviderProbeConfig;
+}
+
+/**
+ * Provider prober options.
+ */
+export interface ProviderProberOptions {
+    /** Where probe results are saved. */
+    store: ProbeStore;
+
+    /** Estimates a probe's cost (USD), or undefined without pricing. */
+    estimateCost?: ((model: string, usage: Usage) => number | undefined) | undefined;
+
+    /** Variables for ${env:VAR} references in alert webhook headers. */
+    env?: Record<string, string | undefined> | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Fetch implementation for alert webhooks (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+
+    /** Clock, for tests. */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * A provider's probe status, as reported on the admin provider health
+ * endpoint.
+ */
+export interface ProbeSummary {
+    /** Model probed. */
+    model: string;
+
+    /** Results in the rolling window. */
+    samples: number;
+
+    /** Share of the rolling window that succeeded (0-1). */
+    successRate?: number | undefined;
+
+    /** Latency p95 over the rolling window (ms). */
+    p95Ms?: number | undefined;
+
+    /** Whether the success rate is below the provider's threshold. */
+    degraded: boolean;
+
+    /** Time of the latest probe. */
+    lastProbeAt?: Date | undefined;
+
+    /** Failure message of the latest probe, if it failed. */
+    lastError?: string | undefined;
+}
+
+/**
+ * A provider's recent probe results, from GET /admin/api/providers/:name/probes.
+ */
+export interface ProbeReport extends ProbeSummary {
+    /** Provider name. */
+    provider: string;
+
+    /** Saved results, newest first. */
+    results: ProbeResultRecord[];
+}
+
+/**
+ * Body of a probe alert webhook.
+ */
+export interface ProbeAlert {
+    type: 'provider_probe_alert';
+    provider: string;
+    model: string;
+
+    /** Which bound was crossed. */
+    reason: 'success_rate' | 'latency_p95';
+
+    /** The bound: a success rate, or a latency in ms. */
+    threshold: number;
+
+    successRate: number;
+    p95Ms: number;
+    samples: number;
+    timestamp: string;
+}
+
+/** Probe state for one provider. */
+interface ProbeState {
+    name: string;
+    key: string;
+    provider: Provider;
+    config: ProviderProbeConfig;
+    recent: ProbeResultRecord[];
+    timer?: ReturnType<typeof setTimeout> | undefined;
+    running?: Promise<ProbeResultRecord> | undefined;
+    lastAlertAt?: number | undefined;
+    stopped: boolean;
+}
+
+// ============================================================================
+// Provider Prober
+// ============================================================================
+
+/**
+ * Probes configured providers on their intervals.
+ */
+export class ProviderProber {
+    private readonly store: ProbeStore;
+    private readonly estimateCost: ProviderProberOptions['estimateCost'];
+    private readonly env: Record<string, string | undefined>;
+    private readonly logger?: Logger | undefined;
+    private readonly fetch: typeof fetch;
+    private readonly clock: () => number;
+    private readonly probes = new Map<string, ProbeState>();
+
+    constructor(options: ProviderProberOptions) {
+        this.store = options.store;
+        this.estimateCost = options.estimateCost;
+        this.env = options.env ?? {};
+        this.logger = options.logger;
+        this.fetch = options.fetch ?? ((input, init) => fetch(input, init));
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /**
+     * Sets the providers to probe, after a config load. A provider whose
+     * probe config is unchanged keeps its schedule and rolling window;
+     * a new or changed one is probed right away.
+     */
+    configure(targets: Map<string, ProbeTarget>): void {
+        for (const [name, state] of this.probes) {
+            const target = targets.get(name);
+            if (!target || JSON.stringify(target.config) !== state.key) {
+                this.stop(state);
+                this.probes.delete(name);
+            }
+        }
+
+        for (const [name, target] of targets) {
+            const existing = this.probes.get(name);
+            if (existing) {
+                existing.provider = target.provider;
+                continue;
+            }
+            const state: ProbeState = {
+                name,
+                key: JSON.stringify(target.config),
+                provider: target.provider,
+                config: target.config,
+                recent: [],
+                stopped: false,
+            };
+            this.probes.set(name, state);
+            this.schedule(state, 0);
+        }
+    }
+
+    /**
+     * Probes a provider now, or returns undefined if it has no probe.
+     * Joins a probe already in flight.
+     */
+    probe(name: string): Promise<ProbeResultRecord> | undefined {
+        const state = this.probes.get(name);
+        return state && this.run(state);
+    }
+
+    /**
+     * Whether a provider's probes show it degraded.
+     */
+    degraded(name: string): boolean {
+        const state = this.probes.get(name);
+        return state ? this.summarize(state).degraded : false;
+    }
+
+    /**
+     * Returns a provider's probe status, or undefined if it has no probe.
+     */
+    summary(name: string): ProbeSummary | undefined {
+        const state = this.probes.get(name);
+        return state && this.summarize(state);
+    }
+
+    /**
+     * Returns a provider's status and its most recent saved results, or
+     * undefined if it has no probe.
+     */
+    async report(name: string, limit: number): Promise<ProbeReport | undefined> {
+        const state = this.probes.get(name);
+        if (!state) {
+            return undefined;
+        }
+        const results = await this.store.listProbeResults(name, limit);
+        return { provider: name, ...this.summarize(state), results };
+    }
+
+    /**
+     * Stops every probe schedule.
+     */
+    close(): void {
+        for (const state of this.probes.values()) {
+            this.stop(state);
+        }
+        this.probes.clear();
+    }
+
+    // ---- Probing ----
+
+    private run(state: ProbeState): Promise<ProbeResultRecord> {
+        if (!state.running) {
+            state.running = this.send(state).finally(() => {
+                state.running = undefined;
+            });
+        }
+        return state.running;
+    }
+
+    private async send(state: ProbeState): Promise<ProbeResultRecord> {
+        const { config } = state;
+        const request: CanonicalRequest = {
+            tenantId: UNSCOPED_TENANT,
+            model: config.model,
+            messages: [{ role: 'user', content: config.prompt ?? DEFAULT_PROBE_PROMPT }],
+            maxTokens: config.maxTokens ?? DEFAULT_PROBE_MAX_TOKENS,
+        };
+        const startedAt = this.clock();
+        const base = { id: randomUUID(), provider: state.name, model: config.model, createdAt: new Date(startedAt) };
+
+        let result: ProbeResultRecord;
+        try {
+            const response = await state.provider.complete(request, {
+                signal: AbortSignal.timeout(parseDuration(config.timeout, DEFAULT_PROBE_TIMEOUT_MS)),
+            });
+            const usage = response.usage;
+            result = {
+                ...base,
+                success: true,
+                latencyMs: this.clock() - startedAt,
+                usage,
+                costUsd: usage && this.estimateCost?.(config.model, usage),
+            };
+            this.logger?.debug('probe_succeeded', { provider: state.name, model: config.model, latencyMs: result.latencyMs });
+        } catch (error) {
+            result = {
+                ...base,
+                success: false,
+                statusCode: error instanceof APIError ? error.statusCode : undefined,
+                error: error instanceof Error ? error.message : String(error),
+                latencyMs: this.clock() - startedAt,
+            };
+            this.logger?.warn('probe_failed', {
+                provider: state.name,
+                model: config.model,
+                statusCode: result.statusCode,
+                error: result.error,
+                latencyMs: result.latencyMs,
+            });
+        }
+
+        state.recent.push(result);
+        if (state.recent.length > (config.window ?? DEFAULT_PROBE_WINDOW)) {
+            state.recent.shift();
+        }
+        this.store.saveProbeResult(result).catch((error: unknown) => {
+            this.logger?.warn('probe_result_save_failed', {
+                provider: state.name,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+        await this.checkAlert(state);
+        return result;
+    }
+
+    private schedule(state: ProbeState, delayMs: number): void {
+        if (state.stopped) return;
+        state.timer = setTimeout(() => {
+            state.timer = undefined;
+            void this.run(state).finally(() => {
+                this.schedule(state, parseDuration(state.config.interval, DEFAULT_PROBE_INTERVAL_MS));
+            });
+        }, delayMs);
+        (state.timer as { unref?: () => void }).unref?.();
+    }
+
+    private stop(state: ProbeState): void {
+        state.stopped = true;
+        if (state.timer) {
+            clearTimeout(state.timer);
+            state.timer = undefined;
+        }
+    }
+
+    // ---- Rolling Window ----
+
+    private summarize(state: ProbeState): ProbeSummary {
+        const { recent, config } = state;
+        const last = recent[recent.length - 1];
+        const summary: ProbeSummary = {
+            model: config.model,
+            samples: recent.length,
+            degraded: false,
+            lastProbeAt: last?.createdAt,
+            lastError: last?.error,
+        };
+        if (recent.length === 0) {
+            return summary;
+        }
+        summary.successRate = recent.filter((r) => r.success).length / recent.length;
+        summary.p95Ms = percentile(recent.map((r) => r.latencyMs).sort((a, b) => a - b), 95);
+        summary.degraded = recent.length >= MIN_PROBE_SAMPLES
+            && summary.successRate < (config.minSuccessRate ?? DEFAULT_PROBE_MIN_SUCCESS_RATE);
+        return summary;
+    }
+
+    // ---- Alerts ----
+
+    /**
+     * Alerts the provider's webhook if the rolling window crosses a bound
+     * and the cooldown since the last alert has passed.
+     */
+    private async checkAlert(state: ProbeState): Promise<void> {
+        const alert = state.config.alert;
+        if (!alert) return;
+
+        const summary = this.summarize(state);
+        if (summary.samples < MIN_PROBE_SAMPLES) return;
+        const successRate = summary.successRate!;
+        const p95Ms = summary.p95Ms!;
+
+        let reason: ProbeAlert['reason'];
+        let threshold: number;
+        const maxP95Ms = alert.maxP95 ? parseDuration(alert.maxP95, 0) : undefined;
+        if (summary.degraded) {
+            reason = 'success_rate';
+            threshold = state.config.minSuccessRate ?? DEFAULT_PROBE_MIN_SUCCESS_RATE;
+        } else if (maxP95Ms !== undefined && p95Ms > maxP95Ms) {
+            reason = 'latency_p95';
+            threshold = maxP95Ms;
+        } else {
+            return;
+        }
+
+        const now = this.clock();
+        const cooldownMs = parseDuration(alert.cooldown, DEFAULT_PROBE_ALERT_COOLDOWN_MS);
+        if (state.lastAlertAt !== undefined && now - state.lastAlertAt < cooldownMs) {
+            return;
+        }
+        state.lastAlertAt = now;
+
+        const body: ProbeAlert = {
+            type: 'provider_probe_alert',
+            provider: state.name,
+            model: state.config.model,
+            reason,
+            threshold,
+            successRate,
+            p95Ms,
+            samples: summary.samples,
+            timestamp: new Date(now).toISOString(),
+        };
+        this.logger?.warn('probe_alert', { provider: state.name, reason, successRate, p95Ms });
+
+        await postAlert(
+            { fetch: this.fetch, env: this.env, logger: this.logger },
+            alert,
+            body,
+            'probe_alert_failed',
+            { provider: state.name },
+        );
+    }
+}
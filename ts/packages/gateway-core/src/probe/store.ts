@@ -0,0 +1,61 @@
+/**
+ * In-memory probe result store.
+ *
+ * @module probe/store
+ */
+
+import type { ProbeResultRecord, ProbeStore, StorageProvider } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Probe Store
+// ============================================================================
+
+/** Default number of results the memory store keeps per provider. */
+export const DEFAULT_PROBE_RESULTS_SIZE = 500;
+
+/**
+ * Process-local probe results, the most recent per provider.
+ * Used when the configured storage provider does not implement ProbeStore.
+ */
+export class MemoryProbeStore implements ProbeStore {
+    private readonly results = new Map<string, ProbeResultRecord[]>();
+    private readonly maxEntries: number;
+
+    constructor(options: { maxEntries?: number | undefined } = {}) {
+        this.maxEntries = options.maxEntries ?? DEFAULT_PROBE_RESULTS_SIZE;
+    }
+
+    async saveProbeResult(result: ProbeResultRecord): Promise<void> {
+        let list = this.results.get(result.provider);
+        if (!list) {
+            list = [];
+            this.results.set(result.provider, list);
+        }
+        list.push({ ...result });
+        if (list.length > this.maxEntries) {
+            list.shift();
+        }
+    }
+
+    async listProbeResults(provider: string, limit: number): Promise<ProbeResultRecord[]> {
+        const list = this.results.get(provider) ?? [];
+        return list.slice(-limit).reverse().map((r) => ({ ...r }));
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements ProbeStore.
+ */
+export function isProbeStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & ProbeStore {
+    return (
+        storage !== undefined &&
+        typeof storage.saveProbeResult === 'function' &&
+        typeof storage.listProbeResults === 'function'
+    );
+}
@@ -0,0 +1,131 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { AdminHandler } from './admin/index';
+import type { InteractionEvent } from './domain/events';
+
+const sse = (events: unknown[]) => events.map((e) => `data: ${JSON.stringify(e)}\n\n`).join('');
+
+/** What each provider answers: a completion, a stream, or an error. */
+const upstream = {
+    openai: {
+        json: {
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'gpt-4o',
+            choices: [{ index: 0, finish_reason: 'stop', message: { role: 'assistant', content: 'Hello' } }],
+            usage: { prompt_tokens: 5, completion_tokens: 1, total_tokens: 6 },
+        },
+        sse: sse([
+            { id: 'chatcmpl-1', object: 'chat.completion.chunk', created: 0, model: 'gpt-4o', choices: [{ index: 0, delta: { role: 'assistant', content: 'Hello' }, finish_reason: 'stop' }] },
+        ]) + 'data: [DONE]\n\n',
+        error: { error: { message: 'bad request', type: 'invalid_request_error' } },
+    },
+    anthropic: {
+        json: {
+            id: 'msg_1', type: 'message', role: 'assistant', model: 'claude-sonnet-4',
+            content: [{ type: 'text', text: 'Hello' }], stop_reason: 'end_turn', stop_sequence: null,
+            usage: { input_tokens: 5, output_tokens: 1 },
+        },
+        sse: [
+            { type: 'message_start', message: { id: 'msg_1', type: 'message', role: 'assistant', model: 'claude-sonnet-4', content: [], usage: { input_tokens: 5, output_tokens: 0 } } },
+            { type: 'content_block_start', index: 0, content_block: { type: 'text', text: '' } },
+            { type: 'content_block_delta', index: 0, delta: { type: 'text_delta', text: 'Hello' } },
+            { type: 'content_block_stop', index: 0 },
+            { type: 'message_delta', delta: { stop_reason: 'end_turn' }, usage: { output_tokens: 1 } },
+            { type: 'message_stop' },
+        ].map((e) => `event: ${e.type}\ndata: ${JSON.stringify(e)}\n\n`).join(''),
+        error: { type: 'error', error: { type: 'invalid_request_error', message: 'bad request' } },
+    },
+};
+
+type ProviderName = keyof typeof upstream;
+
+function setup(provider: ProviderName, fail: boolean) {
+    const sent: Uint8Array[] = [];
+    const fetch = vi.fn(async (_url: string, init: RequestInit) => {
+        sent.push(init.body as Uint8Array);
+        const stream = JSON.parse(new TextDecoder().decode(init.body as Uint8Array)).stream === true;
+        if (fail) {
+            return Response.json(upstream[provider].error, { status: 400 });
+        }
+        return stream
+            ? new Response(upstream[provider].sse, { headers: { 'Content-Type': 'text/event-stream' } })
+            : Response.json(upstream[provider].json);
+    });
+    const events: InteractionEvent[] = [];
+    const storage = {
+        saveEvent: async (event: InteractionEvent) => void events.push(event),
+        getEvents: async (id: string) => events.filter((e) => e.interactionId === id),
+        getConversation: async () => null,
+        getResponse: async (id: string) => ({ id }),
+    };
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [{ name: 'chat', frontdoor: 'openai', path: '/v1' }],
+                providers: [
+                    { name: 'openai', type: 'openai', apiKey: 'sk-openai' },
+                    { name: 'anthropic', type: 'anthropic', apiKey: 'sk-ant' },
+                ],
+                routing: { rules: [{ modelPrefix: 'claude', provider: 'anthropic' }, { modelPrefix: 'gpt', provider: 'openai' }] },
+            }),
+        },
+        auth: { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null },
+        storage: storage as any,
+        httpClientFactory: () => ({ fetch: fetch as any }),
+    });
+    const admin = new AdminHandler({ storage: storage as any });
+    const providerRequest = async (id: string) =>
+        admin.handle(new Request(`http://localhost/api/interactions/${id}/provider-request`));
+    return { gateway, sent, providerRequest };
+}
+
+const sha256 = async (bytes: Uint8Array) => [...new Uint8Array(await crypto.subtle.digest('SHA-256', bytes))]
+    .map((b) => b.toString(16).padStart(2, '0'))
+    .join('');
+
+const matrix = (['openai', 'anthropic'] as const).flatMap((provider) =>
+    [false, true].flatMap((stream) => [false, true].map((fail) => [provider, stream, fail] as const)));
+
+describe('Provider request recording', () => {
+    it.each(matrix)('should record what was sent to %s (stream: %s, error: %s)', async (provider, stream, fail) => {
+        const { gateway, sent, providerRequest } = setup(provider, fail);
+        await gateway.reload();
+
+        const response = await gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                model: provider === 'openai' ? 'gpt-4o' : 'claude-sonnet-4',
+                messages: [{ role: 'user', content: 'Hi' }],
+                max_tokens: 16,
+                stream,
+            }),
+        }));
+        await response.text();
+        const id = response.headers.get('X-Gateway-Interaction-Id')!;
+
+        expect(sent).toHaveLength(1);
+        let recorded!: Response;
+        await vi.waitFor(async () => {
+            recorded = await providerRequest(id);
+            expect(recorded.status).toBe(200);
+        });
+        const body = await recorded.json();
+        expect(body).toMatchObject({
+            id,
+            body: new TextDecoder().decode(sent[0]),
+            bytes: sent[0]!.length,
+            sha256: await sha256(sent[0]!),
+            stream,
+            provider,
+        });
+        expect(body.requests).toHaveLength(1);
+    });
+
+    it('should answer 404 when nothing was sent upstream', async () => {
+        const { providerRequest } = setup('openai', false);
+
+        const response = await providerRequest('int-unknown');
+
+        expect(response.status).toBe(404);
+    });
+});
@@ -12,8 +12,17 @@ import type {
     APIType,
 } from '../domain/types.js';
 import { APIError, errServer } from '../domain/errors.js';
-import type { Provider, ProviderFactoryConfig } from '../ports/provider.js';
+import type {
+    Provider,
+    ProviderFactoryConfig,
+    ProviderCredentials,
+    ProviderCallOptions,
+} from '../ports/provider.js';
 import { AnthropicCodec } from '../codecs/anthropic.js';
+import { withUpstreamResponse, relevantResponseHeaders } from './errors.js';
+import { UpstreamDeadline, type UpstreamTimeouts } from './timeout.js';
+import { KeyPool } from './keys.js';
+import { anthropicVersionHeaders } from './versions.js';
 
 // ============================================================================
 // Constants
@@ -21,7 +30,6 @@ import { AnthropicCodec } from '../codecs/anthropic.js';
 
 const DEFAULT_BASE_URL = 'https://api.anthropic.com';
 const MESSAGES_PATH = '/v1/messages';
-const API_VERSION = '2023-06-01';
 
 // ============================================================================
 // Anthropic Provider
@@ -34,40 +42,67 @@ export class AnthropicProvider implements Provider {
     readonly name: string;
     readonly apiType: APIType = 'anthropic';
 
-    private readonly apiKey: string;
+    private readonly credentials: ProviderCredentials;
     private readonly baseUrl: string;
     private readonly codec: AnthropicCodec;
     private readonly fetchFn: typeof fetch;
+    private readonly timeouts: UpstreamTimeouts;
+    private readonly versionHeaders: Record<string, string>;
 
     constructor(config: ProviderFactoryConfig) {
         this.name = config.name;
-        this.apiKey = config.apiKey;
+        this.credentials = config.credentials ?? KeyPool.fromConfig(config.apiKey);
         this.baseUrl = (config.baseUrl ?? DEFAULT_BASE_URL).replace(/\/$/, '');
         this.codec = new AnthropicCodec();
         this.fetchFn = config.fetch ?? globalThis.fetch.bind(globalThis);
+        this.timeouts = {
+            requestTimeoutMs: config.requestTimeoutMs,
+            streamIdleTimeoutMs: config.streamIdleTimeoutMs,
+        };
+        this.versionHeaders = anthropicVersionHeaders(config.apiVersion, config.betaFeatures);
     }
 
     /**
-     * Makes a non-streaming completion request.
+     * Makes a non-streaming completion request, bounded by the request
+     * timeout and the caller's deadline.
      */
-    async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        return new UpstreamDeadline(this.timeouts, options).run((signal) => this.completeOnce(request, signal, options));
+    }
+
+    /**
+     * Performs the completion request, aborting on `signal`.
+     */
+    private async completeOnce(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        options: ProviderCallOptions | undefined,
+    ): Promise<CanonicalResponse> {
         const body = this.codec.encodeRequest({ ...request, stream: false });
+        options?.onRequestBody?.(body);
+        const lease = this.credentials.acquire();
 
         const response = await this.fetchFn(`${this.baseUrl}${MESSAGES_PATH}`, {
             method: 'POST',
-            headers: this.getHeaders(request),
+            headers: this.getHeaders(request, lease.key),
             body,
+            signal,
         });
+        this.credentials.report(lease, response.status);
 
         const responseBody = await response.arrayBuffer();
         const responseBytes = new Uint8Array(responseBody);
 
         if (!response.ok) {
-            throw this.codec.decodeError(responseBytes, response.status);
+            throw withUpstreamResponse(this.codec.decodeError(responseBytes, response.status), 'anthropic', response, responseBytes);
         }
 
         const canonicalResponse = this.codec.decodeResponse(responseBytes);
         canonicalResponse.sourceAPIType = 'anthropic';
+        canonicalResponse.providerKeyId = lease.id;
+        canonicalResponse.providerRequestBody = body;
+        canonicalResponse.rawResponse = responseBytes;
+        canonicalResponse.rawResponseHeaders = relevantResponseHeaders(response.headers);
 
         // Extract rate limits from headers
         canonicalResponse.rateLimits = this.extractRateLimits(response.headers);
@@ -76,20 +111,38 @@ export class AnthropicProvider implements Provider {
     }
 
     /**
-     * Makes a streaming completion request.
+     * Makes a streaming completion request, bounded by the request timeout
+     * until the first event, by the stream idle timeout after it, and by
+     * the caller's deadline throughout.
      */
-    async *stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent, void, void> {
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const deadline = new UpstreamDeadline(this.timeouts, options);
+        yield* deadline.guard(this.streamOnce(request, deadline.signal, options));
+    }
+
+    /**
+     * Performs the streaming request, aborting on `signal`.
+     */
+    private async *streamOnce(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        options: ProviderCallOptions | undefined,
+    ): AsyncGenerator<CanonicalEvent, void, void> {
         const body = this.codec.encodeRequest({ ...request, stream: true });
+        options?.onRequestBody?.(body);
+        const lease = this.credentials.acquire();
 
         const response = await this.fetchFn(`${this.baseUrl}${MESSAGES_PATH}`, {
             method: 'POST',
-            headers: this.getHeaders(request),
+            headers: this.getHeaders(request, lease.key),
             body,
+            signal,
         });
+        this.credentials.report(lease, response.status);
 
         if (!response.ok) {
-            const responseBody = await response.arrayBuffer();
-            throw this.codec.decodeError(new Uint8Array(responseBody), response.status);
+            const responseBytes = new Uint8Array(await response.arrayBuffer());
+            throw withUpstreamResponse(this.codec.decodeError(responseBytes, response.status), 'anthropic', response, responseBytes);
         }
 
         if (!response.body) {
@@ -99,8 +152,14 @@ export class AnthropicProvider implements Provider {
         // Parse SSE stream
         const reader = response.body.getReader();
         const decoder = new TextDecoder();
+        const encoder = new TextEncoder();
         let buffer = '';
+        let keyId: string | undefined = lease.id;
         let currentEventType = '';
+        // SSE lines read since the last event, kept with the next one
+        let raw = '';
+        // Content block index -> tool call index
+        const toolIndexes = new Map<number, number>();
 
         try {
             while (true) {
@@ -114,6 +173,7 @@ export class AnthropicProvider implements Provider {
                 buffer = lines.pop() ?? ''; // Keep incomplete line in buffer
 
                 for (const line of lines) {
+                    raw += `${line}\n`;
                     const trimmed = line.trim();
 
                     // Skip empty lines
@@ -135,6 +195,22 @@ export class AnthropicProvider implements Provider {
                         // Decode the chunk
                         const event = this.codec.decodeStreamChunk(data);
                         if (event) {
+                            // Number tool calls among themselves, as OpenAI clients expect
+                            if (event.toolCall) {
+                                const block = event.toolCall.index;
+                                if (!toolIndexes.has(block)) {
+                                    toolIndexes.set(block, toolIndexes.size);
+                                }
+                                event.toolCall.index = toolIndexes.get(block)!;
+                            }
+
+                            // Tag the first event with the key that served it
+                            if (keyId) {
+                                event.providerKeyId = keyId;
+                                keyId = undefined;
+                            }
+                            event.rawEvent = encoder.encode(raw);
+                            raw = '';
                             yield event;
 
                             // Check for message_stop
@@ -172,10 +248,10 @@ export class AnthropicProvider implements Provider {
     /**
      * Gets request headers.
      */
-    private getHeaders(request: CanonicalRequest): Record<string, string> {
+    private getHeaders(request: CanonicalRequest, apiKey: string): Record<string, string> {
         const headers: Record<string, string> = {
-            'x-api-key': this.apiKey,
-            'anthropic-version': API_VERSION,
+            'x-api-key': apiKey,
+            ...this.versionHeaders,
             'Content-Type': 'application/json',
         };
 
@@ -183,7 +259,7 @@ export class AnthropicProvider implements Provider {
             headers['User-Agent'] = request.userAgent;
         }
 
-        return headers;
+        return request.upstreamHeaders?.apply(headers) ?? headers;
     }
 
     /**
@@ -0,0 +1,196 @@
+/**
+ * Request deadline propagation to providers.
+ *
+ * Binds a client request's gateway deadline and abort signal to every
+ * provider call made on its behalf, so a request the gateway has given up
+ * on is cancelled upstream instead of being computed to completion, and
+ * counts the calls cancelled that way. Non-streaming requests whose client
+ * went away are counted too, with how long the client had waited.
+ *
+ * @module providers/deadline
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import { APIError } from '../domain/errors.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from './passthrough.js';
+import { percentile, type Percentiles } from '../utils/timings.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Status recorded for a request its client closed (nginx's "client closed request"). */
+export const CLIENT_CLOSED_STATUS = 499;
+
+/** Recent abort times kept per app, for percentiles. */
+const ABORT_WINDOW = 1000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Provider calls cancelled by a request deadline, per provider.
+ */
+export interface DeadlineCancellationStats {
+    /** Provider name. */
+    provider: string;
+
+    /** Calls cancelled because the gateway deadline passed or the request was abandoned. */
+    cancelled: number;
+}
+
+/**
+ * Non-streaming requests abandoned by their client, per app.
+ */
+export interface ClientAbortStats {
+    /** App name ('' for requests outside any app). */
+    app: string;
+
+    /** Requests whose client disconnected before the response was sent. */
+    aborted: number;
+
+    /** Time from the request's start to the abort, over recent aborts. */
+    elapsedMs: Percentiles;
+}
+
+// ============================================================================
+// Cancellation Counters
+// ============================================================================
+
+/**
+ * Counts provider calls cancelled by a request deadline.
+ */
+export class DeadlineCancellations {
+    private readonly counts = new Map<string, number>();
+
+    /**
+     * Records one cancelled call.
+     */
+    record(provider: string): void {
+        this.counts.set(provider, (this.counts.get(provider) ?? 0) + 1);
+    }
+
+    /**
+     * Returns the counters, sorted by provider name.
+     */
+    stats(): DeadlineCancellationStats[] {
+        return Array.from(this.counts, ([provider, cancelled]) => ({ provider, cancelled }))
+            .sort((a, b) => a.provider.localeCompare(b.provider));
+    }
+}
+
+/**
+ * Counts non-streaming requests abandoned by their client, with the time
+ * each had been running.
+ */
+export class ClientAborts {
+    private readonly apps = new Map<string, { aborted: number; elapsed: number[] }>();
+
+    /**
+     * Records one abandoned request.
+     */
+    record(app: string, elapsedMs: number): void {
+        let state = this.apps.get(app);
+        if (!state) {
+            state = { aborted: 0, elapsed: [] };
+            this.apps.set(app, state);
+        }
+        state.aborted++;
+        state.elapsed.push(elapsedMs);
+        if (state.elapsed.length > ABORT_WINDOW) {
+            state.elapsed.shift();
+        }
+    }
+
+    /**
+     * Returns the counters, sorted by app name.
+     */
+    stats(): ClientAbortStats[] {
+        return Array.from(this.apps, ([app, { aborted, elapsed }]) => {
+            const sorted = [...elapsed].sort((a, b) => a - b);
+            return {
+                app,
+                aborted,
+                elapsedMs: { p50: percentile(sorted, 50), p95: percentile(sorted, 95), p99: percentile(sorted, 99) },
+            };
+        }).sort((a, b) => a.app.localeCompare(b.app));
+    }
+}
+
+/**
+ * Whether a request was abandoned by its client: its signal aborted
+ * before the gateway deadline (if any) passed.
+ */
+export function clientAborted(signal: AbortSignal, deadline: number | undefined, now = Date.now()): boolean {
+    return signal.aborted && !(deadline !== undefined && now >= deadline);
+}
+
+// ============================================================================
+// Deadline-Bound Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so every call carries one request's deadline and signal.
+ */
+export class DeadlineBoundProvider extends ProviderWrapper {
+    private readonly call: ProviderCallOptions;
+    private readonly cancellations: DeadlineCancellations;
+
+    constructor(inner: Provider, call: ProviderCallOptions, cancellations: DeadlineCancellations) {
+        super(inner);
+        this.call = call;
+        this.cancellations = cancellations;
+    }
+
+    /**
+     * Completes a request under the bound deadline.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        try {
+            return await this.inner.complete(request, { ...this.call, ...options });
+        } catch (error) {
+            this.recordIfCancelled(error);
+            throw error;
+        }
+    }
+
+    /**
+     * Streams a request under the bound deadline.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        try {
+            yield* this.inner.stream(request, { ...this.call, ...options });
+        } catch (error) {
+            this.recordIfCancelled(error);
+            throw error;
+        }
+    }
+
+    private recordIfCancelled(error: unknown): void {
+        const expired = error instanceof APIError && error.code === 'deadline_exceeded';
+        if (expired || this.call.signal?.aborted) {
+            this.cancellations.record(this.name);
+        }
+    }
+}
+
+/**
+ * Binds a request's deadline and signal to a provider. Returns the
+ * provider unchanged when there is neither.
+ */
+export function withDeadline(
+    provider: Provider,
+    call: ProviderCallOptions,
+    cancellations: DeadlineCancellations,
+): Provider {
+    if (call.deadline === undefined && !call.signal) {
+        return provider;
+    }
+    return new DeadlineBoundProvider(provider, call, cancellations);
+}
@@ -0,0 +1,51 @@
+/**
+ * Upstream error capture.
+ *
+ * Provider clients decode an error response into the canonical taxonomy
+ * and keep the response itself on the error, so a frontdoor speaking the
+ * same API can return it verbatim and the interaction's attempts record
+ * what the provider actually said. Successful responses keep the same
+ * subset of headers with their raw body.
+ *
+ * @module providers/errors
+ */
+
+import type { APIType } from '../domain/types.js';
+import { isAPIError } from '../domain/errors.js';
+
+/** Headers kept from a provider's response. */
+const RELEVANT_HEADERS = ['retry-after', 'retry-after-ms', 'x-should-retry', 'request-id', 'x-request-id'];
+
+/** Header prefixes kept from a provider's response. */
+const RELEVANT_HEADER_PREFIXES = ['x-ratelimit-', 'anthropic-ratelimit-', 'openai-', 'anthropic-'];
+
+/**
+ * Attaches a provider's error response to its decoded error. Errors that
+ * aren't APIErrors are returned unchanged.
+ */
+export function withUpstreamResponse(error: Error, api: APIType, response: Response, body: Uint8Array): Error {
+    if (!isAPIError(error)) {
+        return error;
+    }
+    return error.withUpstream({
+        api,
+        status: response.status,
+        body: new TextDecoder().decode(body),
+        headers: relevantResponseHeaders(response.headers),
+    });
+}
+
+/**
+ * The headers worth keeping from a provider response (request IDs, rate
+ * limits, retry hints), by lowercase name.
+ */
+export function relevantResponseHeaders(headers: Headers): Record<string, string> {
+    const kept: Record<string, string> = {};
+    headers.forEach((value, name) => {
+        const lower = name.toLowerCase();
+        if (RELEVANT_HEADERS.includes(lower) || RELEVANT_HEADER_PREFIXES.some((prefix) => lower.startsWith(prefix))) {
+            kept[lower] = value;
+        }
+    });
+    return kept;
+}
@@ -5,7 +5,7 @@
  */
 
 // OpenAI
-export { OpenAIProvider, createOpenAIProvider } from './openai.js';
+export { OpenAIProvider, createOpenAIProvider, REQUEST_TIMEOUT_HEADER } from './openai.js';
 
 // Anthropic
 export { AnthropicProvider, createAnthropicProvider } from './anthropic.js';
@@ -14,6 +14,40 @@ export { AnthropicProvider, createAnthropicProvider } from './anthropic.js';
 export { PassthroughProvider, withPassthrough } from './passthrough.js';
 export type { PassthroughOptions, PassthroughableProvider } from './passthrough.js';
 
+// Multi-choice (n > 1)
+export { MultiChoiceProvider, withMultiChoice, MAX_FANOUT_CHOICES } from './multichoice.js';
+export type { MultiChoiceOptions } from './multichoice.js';
+
+// Per-attempt upstream timeouts
+export { UpstreamDeadline } from './timeout.js';
+export type { UpstreamTimeouts } from './timeout.js';
+
+// Request deadline propagation
+export { DeadlineBoundProvider, DeadlineCancellations, ClientAborts, clientAborted, withDeadline, CLIENT_CLOSED_STATUS } from './deadline.js';
+export type { DeadlineCancellationStats, ClientAbortStats } from './deadline.js';
+
+// Stale-while-revalidate model lists
+export { ModelListCache, ModelCachingProvider, withModelCache, DEFAULT_MODEL_LIST_TTL_MS } from './models.js';
+export type { ModelListCacheOptions } from './models.js';
+
+// API version pinning
+export {
+    DEFAULT_ANTHROPIC_VERSION,
+    KNOWN_ANTHROPIC_BETAS,
+    validateProviderVersioning,
+    providerVersioning,
+    versioningMetadata,
+    anthropicVersionHeaders,
+} from './versions.js';
+export type { ProviderVersioning } from './versions.js';
+
+// Upstream error capture
+export { withUpstreamResponse, relevantResponseHeaders } from './errors.js';
+
+// Multi-key credential pooling
+export { KeyPool, keyId, DEFAULT_KEY_COOLDOWN_MS } from './keys.js';
+export type { KeyPoolOptions, ProviderKeyHealth } from './keys.js';
+
 // Default registry with built-in providers
 import { createProviderRegistry } from '../ports/provider.js';
 import { createOpenAIProvider } from './openai.js';
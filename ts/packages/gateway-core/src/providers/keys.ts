@@ -0,0 +1,223 @@
+/**
+ * Multi-key credential pooling for providers.
+ *
+ * Keys are rotated round-robin per request. A key that returns 429 sits out
+ * a cooldown that doubles with each consecutive 429, so load shifts to the
+ * keys with headroom; a 401 marks the key unhealthy until it succeeds again.
+ * Only non-secret key identifiers ever leave the pool.
+ *
+ * @module providers/keys
+ */
+
+import type { ProviderKeyConfig } from '../ports/config.js';
+import type { CredentialLease, ProviderCredentials } from '../ports/provider.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default cooldown after a 429. */
+export const DEFAULT_KEY_COOLDOWN_MS = 30_000;
+
+/** Cap on cooldown doubling (base * 2^5). */
+const MAX_COOLDOWN_DOUBLINGS = 5;
+
+/**
+ * Options for a key pool.
+ */
+export interface KeyPoolOptions {
+    /** Provider name for log fields. */
+    provider?: string | undefined;
+
+    /** Base cooldown after a 429 in ms. */
+    cooldownMs?: number | undefined;
+
+    /** Logger for rate-limit and revocation alerts. */
+    logger?: Logger | undefined;
+
+    /** Time source (for testing). */
+    now?: (() => number) | undefined;
+}
+
+/**
+ * Health of one pooled key, as reported on the admin provider health endpoint.
+ */
+export interface ProviderKeyHealth {
+    /** Non-secret identifier. */
+    id: string;
+
+    /** False after a 401 until the key succeeds again. */
+    healthy: boolean;
+
+    /** When the current 429 cooldown ends, if cooling down. */
+    coolingDownUntil?: string | undefined;
+
+    /** Total 429s seen. */
+    rateLimited: number;
+
+    /** Most recent upstream status. */
+    lastStatus?: number | undefined;
+}
+
+interface PooledKey {
+    id: string;
+    key: string;
+    healthy: boolean;
+    cooldownUntil: number;
+    consecutiveRateLimits: number;
+    rateLimited: number;
+    lastStatus?: number;
+}
+
+// ============================================================================
+// Key Pool
+// ============================================================================
+
+/**
+ * Round-robin key pool that deprioritizes rate-limited and revoked keys.
+ */
+export class KeyPool implements ProviderCredentials {
+    private readonly keys: PooledKey[];
+    private readonly provider: string | undefined;
+    private readonly cooldownMs: number;
+    private readonly logger: Logger | undefined;
+    private readonly now: () => number;
+    private cursor = 0;
+
+    constructor(keys: ProviderKeyConfig[], options: KeyPoolOptions = {}) {
+        this.keys = dedupe(keys).map((k) => ({
+            id: k.label ?? keyId(k.key),
+            key: k.key,
+            healthy: true,
+            cooldownUntil: 0,
+            consecutiveRateLimits: 0,
+            rateLimited: 0,
+        }));
+        this.provider = options.provider;
+        this.cooldownMs = options.cooldownMs ?? DEFAULT_KEY_COOLDOWN_MS;
+        this.logger = options.logger;
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Builds a pool from a provider's single key plus its key list.
+     */
+    static fromConfig(apiKey: string, apiKeys: ProviderKeyConfig[] = [], options?: KeyPoolOptions): KeyPool {
+        return new KeyPool(apiKey ? [{ key: apiKey }, ...apiKeys] : apiKeys, options);
+    }
+
+    /** Number of distinct keys. */
+    get size(): number {
+        return this.keys.length;
+    }
+
+    /**
+     * Picks the next healthy key that is not cooling down. When every key is
+     * cooling down, picks the one whose cooldown ends first; when every key
+     * is unhealthy, keeps rotating so a restored key is noticed.
+     */
+    acquire(): CredentialLease {
+        if (this.keys.length === 0) {
+            return { id: 'none', key: '' };
+        }
+
+        const now = this.now();
+        let chosen: number | undefined;
+        let soonest: number | undefined;
+        for (let i = 0; i < this.keys.length; i++) {
+            const index = (this.cursor + i) % this.keys.length;
+            const key = this.keys[index]!;
+            if (!key.healthy) continue;
+            if (key.cooldownUntil <= now) {
+                chosen = index;
+                break;
+            }
+            if (soonest === undefined || key.cooldownUntil < this.keys[soonest]!.cooldownUntil) {
+                soonest = index;
+            }
+        }
+
+        const index = chosen ?? soonest ?? this.cursor % this.keys.length;
+        this.cursor = index + 1;
+        const key = this.keys[index]!;
+        return { id: key.id, key: key.key };
+    }
+
+    /**
+     * Returns the lease for a key by its ID, for calls that must reuse the
+     * key that made an earlier one (e.g. fetching a batch it submitted).
+     */
+    lease(id: string): CredentialLease | undefined {
+        const key = this.keys.find((k) => k.id === id);
+        return key && { id: key.id, key: key.key };
+    }
+
+    /**
+     * Records an upstream status for a lease.
+     */
+    report(lease: CredentialLease, status: number): void {
+        const key = this.keys.find((k) => k.key === lease.key);
+        if (!key) return;
+        key.lastStatus = status;
+
+        if (status === 429) {
+            key.rateLimited++;
+            key.consecutiveRateLimits++;
+            const cooldownMs = this.cooldownMs * 2 ** Math.min(key.consecutiveRateLimits - 1, MAX_COOLDOWN_DOUBLINGS);
+            key.cooldownUntil = this.now() + cooldownMs;
+            this.logger?.warn('provider_key_rate_limited', { provider: this.provider, key: key.id, cooldownMs });
+            return;
+        }
+
+        if (status === 401) {
+            if (key.healthy) {
+                this.logger?.error('provider_key_unhealthy', { provider: this.provider, key: key.id, status });
+            }
+            key.healthy = false;
+            return;
+        }
+
+        if (status < 400) {
+            if (!key.healthy) {
+                this.logger?.info('provider_key_recovered', { provider: this.provider, key: key.id });
+            }
+            key.healthy = true;
+            key.consecutiveRateLimits = 0;
+        }
+    }
+
+    /**
+     * Returns per-key health without exposing key material.
+     */
+    health(): ProviderKeyHealth[] {
+        const now = this.now();
+        return this.keys.map((k) => ({
+            id: k.id,
+            healthy: k.healthy,
+            coolingDownUntil: k.cooldownUntil > now ? new Date(k.cooldownUntil).toISOString() : undefined,
+            rateLimited: k.rateLimited,
+            lastStatus: k.lastStatus,
+        }));
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Non-secret identifier for a key: its last 4 characters.
+ */
+export function keyId(key: string): string {
+    return `...${key.slice(-4)}`;
+}
+
+function dedupe(keys: ProviderKeyConfig[]): ProviderKeyConfig[] {
+    const seen = new Set<string>();
+    return keys.filter((k) => {
+        if (!k.key || seen.has(k.key)) return false;
+        seen.add(k.key);
+        return true;
+    });
+}
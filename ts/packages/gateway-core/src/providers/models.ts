@@ -0,0 +1,171 @@
+/**
+ * Stale-while-revalidate caching of provider model lists.
+ *
+ * A provider's models endpoint can be slow or rate-limited, and clients
+ * probe /v1/models on startup. Once a list has been fetched it is served
+ * from memory: a copy older than the TTL is still returned immediately
+ * while one background refresh replaces it, and a failed refresh keeps the
+ * stale copy. Only a provider with no cached copy at all waits on (and can
+ * fail with) the upstream call. The router also consults the cached lists
+ * to reject models no provider serves.
+ *
+ * @module providers/models
+ */
+
+import type { ModelList } from '../domain/types.js';
+import type { Provider } from '../ports/provider.js';
+import { ProviderWrapper } from './passthrough.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default time a fetched model list is served before it is refreshed. */
+export const DEFAULT_MODEL_LIST_TTL_MS = 5 * 60 * 1000;
+
+/**
+ * Model list cache options.
+ */
+export interface ModelListCacheOptions {
+    /** Logger for background refresh failures. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests. */
+    now?: (() => number) | undefined;
+}
+
+// ============================================================================
+// Model List Cache
+// ============================================================================
+
+/**
+ * Model lists by provider name.
+ */
+export class ModelListCache {
+    private readonly entries = new Map<string, { list: ModelList; fetchedAt: number }>();
+    private readonly inFlight = new Map<string, Promise<ModelList>>();
+    private readonly logger: Logger | undefined;
+    private readonly now: () => number;
+    private generation = 0;
+
+    constructor(options: ModelListCacheOptions = {}) {
+        this.logger = options.logger;
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Returns a provider's model list, fetching it only when there is no
+     * cached copy. A copy older than `ttlMs` is refreshed in the background.
+     */
+    async get(name: string, ttlMs: number, fetch: () => Promise<ModelList>): Promise<ModelList> {
+        const cached = this.entries.get(name);
+        if (!cached) {
+            return this.fetch(name, fetch);
+        }
+
+        if (this.now() - cached.fetchedAt >= ttlMs && !this.inFlight.has(name)) {
+            this.fetch(name, fetch).catch((error: unknown) => {
+                this.logger?.warn('model_list_refresh_failed', {
+                    provider: name,
+                    error: error instanceof Error ? error.message : String(error),
+                    ageMs: this.now() - cached.fetchedAt,
+                });
+            });
+        }
+        return cached.list;
+    }
+
+    /**
+     * Returns a provider's cached model list, if it has one.
+     */
+    peek(name: string): ModelList | undefined {
+        return this.entries.get(name)?.list;
+    }
+
+    /**
+     * Returns the first provider whose cached list has a model.
+     */
+    owner(model: string): string | undefined {
+        for (const [name, { list }] of this.entries) {
+            if (list.data.some((m) => m.id === model)) {
+                return name;
+            }
+        }
+        return undefined;
+    }
+
+    /**
+     * Drops every cached list (on config reload); fetches already in
+     * flight finish without repopulating the cache.
+     */
+    clear(): void {
+        this.entries.clear();
+        this.inFlight.clear();
+        this.generation++;
+    }
+
+    private fetch(name: string, fetch: () => Promise<ModelList>): Promise<ModelList> {
+        const pending = this.inFlight.get(name);
+        if (pending) {
+            return pending;
+        }
+
+        const generation = this.generation;
+        const request = fetch()
+            .then((list) => {
+                if (generation === this.generation) {
+                    this.entries.set(name, { list, fetchedAt: this.now() });
+                }
+                return list;
+            })
+            .finally(() => {
+                if (this.inFlight.get(name) === request) {
+                    this.inFlight.delete(name);
+                }
+            });
+        this.inFlight.set(name, request);
+        return request;
+    }
+}
+
+// ============================================================================
+// Model-Caching Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so its model list is served from a cache.
+ */
+export class ModelCachingProvider extends ProviderWrapper {
+    protected declare readonly inner: Provider & Required<Pick<Provider, 'listModels'>>;
+    private readonly cache: ModelListCache;
+    private readonly ttlMs: number;
+
+    constructor(
+        inner: Provider & Required<Pick<Provider, 'listModels'>>,
+        cache: ModelListCache,
+        ttlMs: number,
+    ) {
+        super(inner);
+        this.cache = cache;
+        this.ttlMs = ttlMs;
+    }
+
+    /**
+     * Lists available models from the cache.
+     */
+    listModels(): Promise<ModelList> {
+        return this.cache.get(this.name, this.ttlMs, () => this.inner.listModels());
+    }
+}
+
+/**
+ * Serves a provider's model list from a cache. Returns the provider
+ * unchanged when it can't list models.
+ */
+export function withModelCache(provider: Provider, cache: ModelListCache, ttlMs: number): Provider {
+    if (!provider.listModels) {
+        return provider;
+    }
+    return new ModelCachingProvider(provider as Provider & Required<Pick<Provider, 'listModels'>>, cache, ttlMs);
+}
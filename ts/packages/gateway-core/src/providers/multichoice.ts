@@ -0,0 +1,191 @@
+/**
+ * Multi-choice provider - handles `n > 1` for providers without native support.
+ *
+ * Anthropic (and most non-OpenAI APIs) return a single completion per request.
+ * This wrapper either rejects `n > 1` with a 400, or fans the request out into
+ * N parallel provider calls and merges the results into one response with N
+ * choices (and N interleaved streams keyed by choice index).
+ *
+ * @module providers/multichoice
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Choice,
+    Usage,
+} from '../domain/types.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from './passthrough.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Upper bound on fan-out requests per client request. */
+export const MAX_FANOUT_CHOICES = 8;
+
+/**
+ * Options for the multi-choice provider.
+ */
+export interface MultiChoiceOptions {
+    /** Issue N parallel requests instead of rejecting n > 1. */
+    fanOut: boolean;
+}
+
+// ============================================================================
+// Multi-Choice Provider
+// ============================================================================
+
+/**
+ * Wraps a single-choice provider to support (or explicitly reject) n > 1.
+ */
+export class MultiChoiceProvider extends ProviderWrapper {
+    private readonly fanOut: boolean;
+
+    constructor(inner: Provider, options: MultiChoiceOptions) {
+        super(inner);
+        this.fanOut = options.fanOut;
+    }
+
+    /**
+     * Completes a request, fanning out when n > 1.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const n = this.choiceCount(request);
+        if (n === 1) {
+            return this.inner.complete(request, options);
+        }
+
+        const single = { ...request, n: undefined };
+        const responses = await Promise.all(
+            Array.from({ length: n }, () => this.inner.complete(single, options)),
+        );
+
+        const first = responses[0]!;
+        const choices = responses.map((r, i): Choice => ({
+            ...r.choices[0]!,
+            index: i,
+        }));
+
+        return {
+            ...first,
+            choices,
+            usage: sumUsage(responses.map((r) => r.usage)),
+        };
+    }
+
+    /**
+     * Streams a request, interleaving N streams by choice index when n > 1.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const n = this.choiceCount(request);
+        if (n === 1) {
+            yield* this.inner.stream(request, options);
+            return;
+        }
+
+        const single = { ...request, n: undefined };
+        const streams = Array.from({ length: n }, () => this.inner.stream(single, options));
+        const usages: Usage[] = [];
+
+        for await (const event of mergeChoiceStreams(streams)) {
+            if (event.type === 'done') continue;
+
+            // Usage is reported once, aggregated, at the end
+            const { usage, ...rest } = event;
+            if (usage) usages.push(usage);
+            yield rest;
+        }
+
+        yield { type: 'message_delta', usage: sumUsage(usages) };
+        yield { type: 'done' };
+    }
+
+    /**
+     * Validates and returns the requested number of choices.
+     */
+    private choiceCount(request: CanonicalRequest): number {
+        const n = request.n ?? 1;
+        if (n <= 1) return 1;
+
+        if (!this.fanOut) {
+            throw errInvalidRequest(
+                `Provider '${this.name}' does not support n > 1; ` +
+                'enable fan_out_choices on the provider to issue parallel requests',
+            ).withParam('n');
+        }
+
+        if (n > MAX_FANOUT_CHOICES) {
+            throw errInvalidRequest(
+                `n must be at most ${MAX_FANOUT_CHOICES} for provider '${this.name}'`,
+            ).withParam('n');
+        }
+
+        return n;
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Interleaves events from several streams, tagging each with its choice index.
+ */
+async function* mergeChoiceStreams(
+    streams: AsyncGenerator<CanonicalEvent, void, void>[],
+): AsyncGenerator<CanonicalEvent, void, void> {
+    type Next = { index: number; result: IteratorResult<CanonicalEvent, void> };
+    const pending = new Map<number, Promise<Next>>();
+    const pull = (index: number): Promise<Next> =>
+        streams[index]!.next().then((result) => ({ index, result }));
+
+    streams.forEach((_, i) => pending.set(i, pull(i)));
+
+    try {
+        while (pending.size > 0) {
+            const { index, result } = await Promise.race(pending.values());
+            if (result.done) {
+                pending.delete(index);
+                continue;
+            }
+            pending.set(index, pull(index));
+            yield { ...result.value, choiceIndex: index };
+        }
+    } finally {
+        await Promise.allSettled(streams.map((s) => s.return(undefined)));
+    }
+}
+
+/**
+ * Sums usage across fan-out calls.
+ */
+export function sumUsage(usages: Usage[]): Usage {
+    const total: Usage = { promptTokens: 0, completionTokens: 0, totalTokens: 0 };
+    for (const u of usages) {
+        total.promptTokens += u.promptTokens;
+        total.completionTokens += u.completionTokens;
+        total.totalTokens += u.totalTokens;
+        if (u.reasoningTokens !== undefined) {
+            total.reasoningTokens = (total.reasoningTokens ?? 0) + u.reasoningTokens;
+        }
+    }
+    return total;
+}
+
+// ============================================================================
+// Factory Function
+// ============================================================================
+
+/**
+ * Wraps a provider with n > 1 handling.
+ */
+export function withMultiChoice(
+    provider: Provider,
+    options: MultiChoiceOptions,
+): MultiChoiceProvider {
+    return new MultiChoiceProvider(provider, options);
+}
@@ -12,8 +12,16 @@ import type {
     APIType,
 } from '../domain/types.js';
 import { APIError, errServer } from '../domain/errors.js';
-import type { Provider, ProviderFactoryConfig } from '../ports/provider.js';
+import type {
+    Provider,
+    ProviderFactoryConfig,
+    ProviderCredentials,
+    ProviderCallOptions,
+} from '../ports/provider.js';
 import { OpenAICodec } from '../codecs/openai.js';
+import { withUpstreamResponse, relevantResponseHeaders } from './errors.js';
+import { UpstreamDeadline, type UpstreamTimeouts } from './timeout.js';
+import { KeyPool } from './keys.js';
 
 // ============================================================================
 // Constants
@@ -23,6 +31,9 @@ const DEFAULT_BASE_URL = 'https://api.openai.com';
 const MODELS_PATH = '/v1/models';
 const CHAT_PATH = '/v1/chat/completions';
 
+/** Tells OpenAI-compatible servers how long the gateway will wait. */
+export const REQUEST_TIMEOUT_HEADER = 'X-Request-Timeout-Ms';
+
 // ============================================================================
 // OpenAI Provider
 // ============================================================================
@@ -34,40 +45,78 @@ export class OpenAIProvider implements Provider {
     readonly name: string;
     readonly apiType: APIType = 'openai';
 
-    private readonly apiKey: string;
+    private readonly credentials: ProviderCredentials;
     private readonly baseUrl: string;
     private readonly codec: OpenAICodec;
     private readonly fetchFn: typeof fetch;
+    private readonly timeouts: UpstreamTimeouts;
+    private readonly deadlineHeader: boolean;
+    private readonly deadlineTokensPerSecond: number;
+    private readonly apiVersion: string | undefined;
+    private readonly accountHeaders: Record<string, string>;
 
     constructor(config: ProviderFactoryConfig) {
         this.name = config.name;
-        this.apiKey = config.apiKey;
+        this.credentials = config.credentials ?? KeyPool.fromConfig(config.apiKey);
         this.baseUrl = (config.baseUrl ?? DEFAULT_BASE_URL).replace(/\/$/, '');
         this.codec = new OpenAICodec();
         this.fetchFn = config.fetch ?? globalThis.fetch.bind(globalThis);
+        this.timeouts = {
+            requestTimeoutMs: config.requestTimeoutMs,
+            streamIdleTimeoutMs: config.streamIdleTimeoutMs,
+        };
+        this.deadlineHeader = config.deadlineHeader ?? true;
+        this.deadlineTokensPerSecond = config.deadlineTokensPerSecond ?? 0;
+        this.apiVersion = config.apiVersion;
+        this.accountHeaders = {
+            ...(config.organization && { 'OpenAI-Organization': config.organization }),
+            ...(config.project && { 'OpenAI-Project': config.project }),
+        };
     }
 
     /**
-     * Makes a non-streaming completion request.
+     * Makes a non-streaming completion request, bounded by the request
+     * timeout and the caller's deadline.
      */
-    async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
-        const body = this.codec.encodeRequest({ ...request, stream: false });
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const deadline = new UpstreamDeadline(this.timeouts, options);
+        return deadline.run((signal) => this.completeOnce(request, signal, deadline.remainingMs(), options));
+    }
 
-        const response = await this.fetchFn(`${this.baseUrl}${CHAT_PATH}`, {
+    /**
+     * Performs the completion request, aborting on `signal`.
+     */
+    private async completeOnce(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        remainingMs: number | undefined,
+        options: ProviderCallOptions | undefined,
+    ): Promise<CanonicalResponse> {
+        const body = this.codec.encodeRequest({ ...this.fitDeadline(request, remainingMs), stream: false });
+        options?.onRequestBody?.(body);
+        const lease = this.credentials.acquire();
+
+        const response = await this.fetchFn(this.url(CHAT_PATH), {
             method: 'POST',
-            headers: this.getHeaders(request),
+            headers: this.getHeaders(request, lease.key, remainingMs),
             body,
+            signal,
         });
+        this.credentials.report(lease, response.status);
 
         const responseBody = await response.arrayBuffer();
         const responseBytes = new Uint8Array(responseBody);
 
         if (!response.ok) {
-            throw this.codec.decodeError(responseBytes, response.status);
+            throw withUpstreamResponse(this.codec.decodeError(responseBytes, response.status), 'openai', response, responseBytes);
         }
 
         const canonicalResponse = this.codec.decodeResponse(responseBytes);
         canonicalResponse.sourceAPIType = 'openai';
+        canonicalResponse.providerKeyId = lease.id;
+        canonicalResponse.providerRequestBody = body;
+        canonicalResponse.rawResponse = responseBytes;
+        canonicalResponse.rawResponseHeaders = relevantResponseHeaders(response.headers);
 
         // Extract rate limits from headers
         canonicalResponse.rateLimits = this.extractRateLimits(response.headers);
@@ -76,20 +125,39 @@ export class OpenAIProvider implements Provider {
     }
 
     /**
-     * Makes a streaming completion request.
+     * Makes a streaming completion request, bounded by the request timeout
+     * until the first event, by the stream idle timeout after it, and by
+     * the caller's deadline throughout.
      */
-    async *stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent, void, void> {
-        const body = this.codec.encodeRequest({ ...request, stream: true });
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const deadline = new UpstreamDeadline(this.timeouts, options);
+        yield* deadline.guard(this.streamOnce(request, deadline.signal, deadline.remainingMs(), options));
+    }
 
-        const response = await this.fetchFn(`${this.baseUrl}${CHAT_PATH}`, {
+    /**
+     * Performs the streaming request, aborting on `signal`.
+     */
+    private async *streamOnce(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        remainingMs: number | undefined,
+        options: ProviderCallOptions | undefined,
+    ): AsyncGenerator<CanonicalEvent, void, void> {
+        const body = this.codec.encodeRequest({ ...this.fitDeadline(request, remainingMs), stream: true });
+        options?.onRequestBody?.(body);
+        const lease = this.credentials.acquire();
+
+        const response = await this.fetchFn(this.url(CHAT_PATH), {
             method: 'POST',
-            headers: this.getHeaders(request),
+            headers: this.getHeaders(request, lease.key, remainingMs),
             body,
+            signal,
         });
+        this.credentials.report(lease, response.status);
 
         if (!response.ok) {
-            const responseBody = await response.arrayBuffer();
-            throw this.codec.decodeError(new Uint8Array(responseBody), response.status);
+            const responseBytes = new Uint8Array(await response.arrayBuffer());
+            throw withUpstreamResponse(this.codec.decodeError(responseBytes, response.status), 'openai', response, responseBytes);
         }
 
         if (!response.body) {
@@ -99,7 +167,11 @@ export class OpenAIProvider implements Provider {
         // Parse SSE stream
         const reader = response.body.getReader();
         const decoder = new TextDecoder();
+        const encoder = new TextEncoder();
         let buffer = '';
+        let keyId: string | undefined = lease.id;
+        // SSE lines read since the last event, kept with the next one
+        let raw = '';
 
         try {
             while (true) {
@@ -113,6 +185,7 @@ export class OpenAIProvider implements Provider {
                 buffer = lines.pop() ?? ''; // Keep incomplete line in buffer
 
                 for (const line of lines) {
+                    raw += `${line}\n`;
                     const trimmed = line.trim();
 
                     // Skip empty lines and comments
@@ -124,13 +197,20 @@ export class OpenAIProvider implements Provider {
 
                         // Check for [DONE] marker
                         if (data === '[DONE]') {
-                            yield { type: 'done' };
+                            yield { type: 'done', rawEvent: encoder.encode(raw) };
                             return;
                         }
 
                         // Decode the chunk
                         const event = this.codec.decodeStreamChunk(data);
                         if (event) {
+                            // Tag the first event with the key that served it
+                            if (keyId) {
+                                event.providerKeyId = keyId;
+                                keyId = undefined;
+                            }
+                            event.rawEvent = encoder.encode(raw);
+                            raw = '';
                             yield event;
                         }
                     }
@@ -145,16 +225,19 @@ export class OpenAIProvider implements Provider {
      * Lists available models.
      */
     async listModels(): Promise<ModelList> {
-        const response = await this.fetchFn(`${this.baseUrl}${MODELS_PATH}`, {
+        const lease = this.credentials.acquire();
+        const response = await this.fetchFn(this.url(MODELS_PATH), {
             method: 'GET',
             headers: {
-                'Authorization': `Bearer ${this.apiKey}`,
+                'Authorization': `Bearer ${lease.key}`,
+                ...this.accountHeaders,
             },
         });
+        this.credentials.report(lease, response.status);
 
         if (!response.ok) {
-            const responseBody = await response.arrayBuffer();
-            throw this.codec.decodeError(new Uint8Array(responseBody), response.status);
+            const responseBytes = new Uint8Array(await response.arrayBuffer());
+            throw withUpstreamResponse(this.codec.decodeError(responseBytes, response.status), 'openai', response, responseBytes);
         }
 
         const data = await response.json() as {
@@ -178,20 +261,52 @@ export class OpenAIProvider implements Provider {
         };
     }
 
+    /**
+     * Clamps max tokens to what the estimated generation rate can produce
+     * before the caller's deadline, when configured.
+     */
+    private fitDeadline(request: CanonicalRequest, remainingMs: number | undefined): CanonicalRequest {
+        if (remainingMs === undefined || this.deadlineTokensPerSecond <= 0) {
+            return request;
+        }
+        const affordable = Math.max(1, Math.floor((remainingMs / 1000) * this.deadlineTokensPerSecond));
+        if (request.maxTokens !== undefined && request.maxTokens <= affordable) {
+            return request;
+        }
+        return { ...request, maxTokens: affordable };
+    }
+
+    /**
+     * Builds an endpoint URL, with the pinned api-version if any.
+     */
+    private url(path: string): string {
+        const url = `${this.baseUrl}${path}`;
+        return this.apiVersion ? `${url}?api-version=${encodeURIComponent(this.apiVersion)}` : url;
+    }
+
     /**
      * Gets request headers.
      */
-    private getHeaders(request: CanonicalRequest): Record<string, string> {
+    private getHeaders(
+        request: CanonicalRequest,
+        apiKey: string,
+        remainingMs?: number,
+    ): Record<string, string> {
         const headers: Record<string, string> = {
-            'Authorization': `Bearer ${this.apiKey}`,
+            'Authorization': `Bearer ${apiKey}`,
             'Content-Type': 'application/json',
+            ...this.accountHeaders,
         };
 
         if (request.userAgent) {
             headers['User-Agent'] = request.userAgent;
         }
 
-        return headers;
+        if (remainingMs !== undefined && this.deadlineHeader) {
+            headers[REQUEST_TIMEOUT_HEADER] = String(remainingMs);
+        }
+
+        return request.upstreamHeaders?.apply(headers) ?? headers;
     }
 
     /**
@@ -18,7 +18,8 @@ import type {
     Message,
     ModelList,
 } from '../domain/types.js';
-import type { Provider } from '../ports/provider.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { UpstreamDeadline } from './timeout.js';
 
 // ============================================================================
 // Types
@@ -58,6 +59,50 @@ export interface PassthroughableProvider extends Provider {
     streamRaw?(request: CanonicalRequest): AsyncGenerator<CanonicalEvent>;
 }
 
+// ============================================================================
+// Provider Wrapper
+// ============================================================================
+
+/**
+ * Base for providers that wrap another. Every call passes through to the
+ * inner provider; subclasses override the calls they change.
+ */
+export class ProviderWrapper implements Provider {
+    readonly name: string;
+    readonly apiType: APIType;
+    protected readonly inner: Provider;
+
+    constructor(inner: Provider) {
+        this.inner = inner;
+        this.name = inner.name;
+        this.apiType = inner.apiType;
+    }
+
+    /**
+     * Completes a request (passes through to inner provider).
+     */
+    complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        return this.inner.complete(request, options);
+    }
+
+    /**
+     * Streams a request (passes through to inner provider).
+     */
+    stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        return this.inner.stream(request, options);
+    }
+
+    /**
+     * Lists available models (passes through to inner provider).
+     */
+    async listModels(): Promise<ModelList> {
+        if (this.inner.listModels) {
+            return this.inner.listModels();
+        }
+        return { object: 'list', data: [] };
+    }
+}
+
 // ============================================================================
 // Passthrough Provider
 // ============================================================================
@@ -69,18 +114,13 @@ export interface PassthroughableProvider extends Provider {
  * is available, this provider sends the request directly without canonical
  * conversion, improving latency.
  */
-export class PassthroughProvider implements Provider {
-    readonly name: string;
-    readonly apiType: APIType;
-    private readonly inner: Provider;
+export class PassthroughProvider extends ProviderWrapper {
     private readonly apiKey: string;
     private readonly baseURL: string;
     private readonly headers?: Record<string, string>;
 
     constructor(inner: Provider, options: PassthroughOptions) {
-        this.inner = inner;
-        this.name = inner.name;
-        this.apiType = inner.apiType;
+        super(inner);
         this.apiKey = options.apiKey;
         this.baseURL = options.baseURL ?? this.getDefaultBaseURL(inner.apiType);
         this.headers = options.headers;
@@ -96,39 +136,32 @@ export class PassthroughProvider implements Provider {
     /**
      * Completes a request, using passthrough when possible.
      */
-    async complete(request: CanonicalRequest): Promise<CanonicalResponse> {
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
         // Check if we can use passthrough
         if (this.supportsPassthrough(request.sourceAPIType) && request.rawRequest?.length) {
-            const [rawResponse, parsedResponse] = await this.completeRaw(request);
+            const [rawResponse, parsedResponse] = await new UpstreamDeadline({}, options)
+                .run((signal) => this.completeRaw(request, signal, options));
             parsedResponse.rawResponse = rawResponse;
+            parsedResponse.providerRequestBody = request.rawRequest;
             return parsedResponse;
         }
 
         // Fall back to canonical conversion
-        return this.inner.complete(request);
+        return super.complete(request, options);
     }
 
     /**
      * Streams a request, using passthrough when possible.
      */
-    stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent> {
+    stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent> {
         // Check if we can use passthrough
         if (this.supportsPassthrough(request.sourceAPIType) && request.rawRequest?.length) {
-            return this.streamRaw(request);
+            const deadline = new UpstreamDeadline({}, options);
+            return deadline.guard(this.streamRaw(request, deadline.signal, options));
         }
 
         // Fall back to canonical conversion
-        return this.inner.stream(request);
-    }
-
-    /**
-     * Lists available models (passes through to inner provider).
-     */
-    async listModels(): Promise<ModelList> {
-        if (this.inner.listModels) {
-            return this.inner.listModels();
-        }
-        return { object: 'list', data: [] };
+        return super.stream(request, options);
     }
 
     // ---- Private Methods ----
@@ -136,13 +169,19 @@ export class PassthroughProvider implements Provider {
     /**
      * Completes a raw request.
      */
-    private async completeRaw(request: CanonicalRequest): Promise<[Uint8Array, CanonicalResponse]> {
+    private async completeRaw(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        options: ProviderCallOptions | undefined,
+    ): Promise<[Uint8Array, CanonicalResponse]> {
         const { endpoint, headers } = this.getRequestConfig(this.apiType);
+        options?.onRequestBody?.(request.rawRequest!);
 
         const response = await fetch(endpoint, {
             method: 'POST',
             headers,
             body: request.rawRequest,
+            signal,
         });
 
         const rawResponse = new Uint8Array(await response.arrayBuffer());
@@ -163,16 +202,22 @@ export class PassthroughProvider implements Provider {
     /**
      * Streams a raw request.
      */
-    private async *streamRaw(request: CanonicalRequest): AsyncGenerator<CanonicalEvent> {
+    private async *streamRaw(
+        request: CanonicalRequest,
+        signal: AbortSignal,
+        options: ProviderCallOptions | undefined,
+    ): AsyncGenerator<CanonicalEvent> {
         const { endpoint, headers } = this.getRequestConfig(this.apiType);
 
         // Ensure streaming is enabled in the raw request
         const rawRequest = this.ensureStreamingEnabled(request.rawRequest!);
+        options?.onRequestBody?.(rawRequest);
 
         const response = await fetch(endpoint, {
             method: 'POST',
             headers,
             body: rawRequest,
+            signal,
         });
 
         if (!response.ok) {
@@ -325,6 +370,7 @@ export class PassthroughProvider implements Provider {
                 index: 0,
                 message,
                 finishReason: this.mapAnthropicStopReason(obj.stop_reason as string),
+                stopSequence: typeof obj.stop_sequence === 'string' ? obj.stop_sequence : undefined,
             }],
             usage: {
                 promptTokens: usage.input_tokens,
@@ -0,0 +1,149 @@
+/**
+ * Per-attempt upstream timeouts for providers.
+ *
+ * Distinct from the client-facing timeout middleware: these bound a single
+ * provider call so a hung upstream fails fast enough to leave time for
+ * failover or a clean error to the client. An attempt also ends when the
+ * client request's gateway deadline passes or its signal aborts, so the
+ * upstream stops computing a response nobody will read.
+ *
+ * @module providers/timeout
+ */
+
+import { errUpstreamTimeout } from '../domain/errors.js';
+import type { ProviderCallOptions } from '../ports/provider.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Timeouts applied to one provider attempt. Zero or unset disables a bound.
+ */
+export interface UpstreamTimeouts {
+    /** Bound on Complete calls and time to the first stream event (ms). */
+    requestTimeoutMs?: number | undefined;
+
+    /** Bound on the gap between stream events once the first has arrived (ms). */
+    streamIdleTimeoutMs?: number | undefined;
+}
+
+// ============================================================================
+// Upstream Deadline
+// ============================================================================
+
+/**
+ * Owns the abort signal for one provider attempt and enforces its timeouts
+ * and the caller's deadline. Pass `signal` to fetch so a timeout also tears
+ * down the connection.
+ */
+export class UpstreamDeadline {
+    private readonly controller = new AbortController();
+    private readonly requestTimeoutMs: number;
+    private readonly streamIdleTimeoutMs: number;
+    private readonly deadline: number | undefined;
+
+    constructor(timeouts: UpstreamTimeouts = {}, call: ProviderCallOptions = {}) {
+        this.requestTimeoutMs = timeouts.requestTimeoutMs ?? 0;
+        this.streamIdleTimeoutMs = timeouts.streamIdleTimeoutMs ?? 0;
+        this.deadline = call.deadline;
+
+        const parent = call.signal;
+        if (parent?.aborted) {
+            this.controller.abort(parent.reason);
+        } else {
+            parent?.addEventListener('abort', () => this.controller.abort(parent.reason), { once: true });
+        }
+    }
+
+    /** Signal aborted when a timeout fires or the caller's signal aborts. */
+    get signal(): AbortSignal {
+        return this.controller.signal;
+    }
+
+    /**
+     * Milliseconds left before the caller's deadline (never negative), or
+     * undefined without one.
+     */
+    remainingMs(): number | undefined {
+        return this.deadline === undefined ? undefined : Math.max(0, this.deadline - Date.now());
+    }
+
+    /**
+     * Runs a non-streaming call under the request timeout.
+     */
+    async run<T>(call: (signal: AbortSignal) => Promise<T>): Promise<T> {
+        return this.race(
+            call(this.signal),
+            this.requestTimeoutMs,
+            () => errUpstreamTimeout(
+                `Provider did not respond within ${this.requestTimeoutMs}ms`,
+                'request_timeout',
+            ),
+        );
+    }
+
+    /**
+     * Relays a provider stream, bounding the wait for the first event by the
+     * request timeout and each later gap by the stream idle timeout.
+     */
+    async *guard<T>(source: AsyncGenerator<T, void, void>): AsyncGenerator<T, void, void> {
+        let received = 0;
+        try {
+            while (true) {
+                const result = received === 0
+                    ? await this.race(source.next(), this.requestTimeoutMs, () => errUpstreamTimeout(
+                        `Provider sent no stream events within ${this.requestTimeoutMs}ms`,
+                        'request_timeout',
+                    ))
+                    : await this.race(source.next(), this.streamIdleTimeoutMs, () => errUpstreamTimeout(
+                        `Provider stream idle for ${this.streamIdleTimeoutMs}ms after ${received} events`,
+                        'stream_idle_timeout',
+                    ));
+                if (result.done) return;
+                received++;
+                yield result.value;
+            }
+        } finally {
+            if (this.signal.aborted) {
+                // The source is parked on an aborted read; let it unwind on its own
+                source.return(undefined).catch(() => undefined);
+            } else {
+                await source.return(undefined);
+            }
+        }
+    }
+
+    /**
+     * Races a pending upstream operation against a timer, aborting the
+     * attempt when the timer wins. The caller's deadline takes over when it
+     * is sooner than the timeout.
+     */
+    private race<T>(pending: Promise<T>, timeoutMs: number, onTimeout: () => Error): Promise<T> {
+        const remaining = this.remainingMs();
+        if (remaining !== undefined && (timeoutMs <= 0 || remaining < timeoutMs)) {
+            timeoutMs = Math.max(remaining, 1);
+            onTimeout = () => errUpstreamTimeout(
+                'Gateway deadline passed before the provider finished',
+                'deadline_exceeded',
+            );
+        }
+        if (timeoutMs <= 0) {
+            return pending;
+        }
+
+        let timer: ReturnType<typeof setTimeout> | undefined;
+        const timeout = new Promise<never>((_, reject) => {
+            timer = setTimeout(() => {
+                const error = onTimeout();
+                this.controller.abort(error);
+                reject(error);
+            }, timeoutMs);
+        });
+
+        // The losing upstream promise rejects with an abort error; ignore it
+        pending.catch(() => undefined);
+
+        return Promise.race([pending, timeout]).finally(() => clearTimeout(timer));
+    }
+}
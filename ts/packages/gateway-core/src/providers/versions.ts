@@ -0,0 +1,152 @@
+/**
+ * Provider API version pinning.
+ *
+ * Each provider instance can pin the API version it speaks and, for
+ * Anthropic, opt into beta features: `api_version` sets anthropic-version
+ * and `beta_features` sets anthropic-beta. OpenAI providers can set an
+ * organization and project (OpenAI-Organization, OpenAI-Project) and an
+ * `api_version`, sent as the api-version query parameter that Azure
+ * OpenAI requires. The effective values are reported on the admin
+ * overview and stamped on every interaction's metadata, so behavior
+ * changes can be lined up against version changes.
+ *
+ * @module providers/versions
+ */
+
+import type { ProviderConfig } from '../ports/config.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** anthropic-version sent when a provider doesn't pin one. */
+export const DEFAULT_ANTHROPIC_VERSION = '2023-06-01';
+
+/**
+ * anthropic-beta values the gateway knows about. Others are rejected as
+ * likely typos unless the provider sets allow_unknown.
+ */
+export const KNOWN_ANTHROPIC_BETAS: readonly string[] = [
+    'message-batches-2024-09-24',
+    'prompt-caching-2024-07-31',
+    'computer-use-2024-10-22',
+    'computer-use-2025-01-24',
+    'pdfs-2024-09-25',
+    'token-counting-2024-11-01',
+    'token-efficient-tools-2025-02-19',
+    'output-128k-2025-02-19',
+    'files-api-2025-04-14',
+    'mcp-client-2025-04-04',
+    'interleaved-thinking-2025-05-14',
+    'fine-grained-tool-streaming-2025-05-14',
+    'code-execution-2025-05-22',
+    'extended-cache-ttl-2025-04-11',
+    'context-management-2025-06-27',
+    'context-1m-2025-08-07',
+];
+
+/** Anthropic API versions are dates. */
+const ANTHROPIC_VERSION_PATTERN = /^\d{4}-\d{2}-\d{2}$/;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A provider's effective API version settings.
+ */
+export interface ProviderVersioning {
+    /** anthropic-version, or the OpenAI api-version query parameter. */
+    apiVersion?: string | undefined;
+
+    /** anthropic-beta features, in the order sent. */
+    betaFeatures?: string[] | undefined;
+
+    /** OpenAI-Organization. */
+    organization?: string | undefined;
+
+    /** OpenAI-Project. */
+    project?: string | undefined;
+}
+
+// ============================================================================
+// Validation
+// ============================================================================
+
+/**
+ * Throws if a provider's version settings don't fit its type, or name a
+ * beta feature outside the known list (unless allowUnknown is set).
+ */
+export function validateProviderVersioning(config: ProviderConfig): void {
+    const anthropic = config.type === 'anthropic';
+
+    if (config.betaFeatures?.length && !anthropic) {
+        throw new Error('beta_features: only supported for anthropic providers');
+    }
+    if ((config.organization || config.project) && anthropic) {
+        throw new Error('organization/project: only supported for OpenAI-compatible providers');
+    }
+    if (anthropic && config.apiVersion !== undefined && !ANTHROPIC_VERSION_PATTERN.test(config.apiVersion)) {
+        throw new Error(`api_version: '${config.apiVersion}' is not an anthropic-version date (e.g. ${DEFAULT_ANTHROPIC_VERSION})`);
+    }
+
+    const seen = new Set<string>();
+    for (const feature of config.betaFeatures ?? []) {
+        if (seen.has(feature)) {
+            throw new Error(`beta_features: '${feature}' is listed twice`);
+        }
+        seen.add(feature);
+        if (!config.allowUnknown && !KNOWN_ANTHROPIC_BETAS.includes(feature)) {
+            throw new Error(`beta_features: unknown feature '${feature}' (set allow_unknown: true to send it anyway)`);
+        }
+    }
+}
+
+// ============================================================================
+// Effective Values
+// ============================================================================
+
+/**
+ * Returns the version settings a provider sends, with defaults applied.
+ */
+export function providerVersioning(config: ProviderConfig): ProviderVersioning {
+    if (config.type === 'anthropic') {
+        return {
+            apiVersion: config.apiVersion ?? DEFAULT_ANTHROPIC_VERSION,
+            betaFeatures: config.betaFeatures?.length ? [...config.betaFeatures] : undefined,
+        };
+    }
+    return {
+        apiVersion: config.apiVersion,
+        organization: config.organization,
+        project: config.project,
+    };
+}
+
+/**
+ * Formats version settings as interaction metadata entries.
+ */
+export function versioningMetadata(versioning: ProviderVersioning): Record<string, string> {
+    const metadata: Record<string, string> = {};
+    if (versioning.apiVersion) metadata.provider_api_version = versioning.apiVersion;
+    if (versioning.betaFeatures?.length) metadata.provider_beta_features = versioning.betaFeatures.join(',');
+    if (versioning.organization) metadata.provider_organization = versioning.organization;
+    if (versioning.project) metadata.provider_project = versioning.project;
+    return metadata;
+}
+
+/**
+ * Builds the anthropic-version and anthropic-beta headers.
+ */
+export function anthropicVersionHeaders(
+    apiVersion: string | undefined,
+    betaFeatures: readonly string[] | undefined,
+): Record<string, string> {
+    const headers: Record<string, string> = {
+        'anthropic-version': apiVersion ?? DEFAULT_ANTHROPIC_VERSION,
+    };
+    if (betaFeatures?.length) {
+        headers['anthropic-beta'] = betaFeatures.join(',');
+    }
+    return headers;
+}
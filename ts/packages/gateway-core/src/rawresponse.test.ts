@@ -0,0 +1,143 @@
+import { describe, it, expect, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { OpenAIProvider, AnthropicProvider } from './providers/index';
+import { withStreamRecording, RAW_TRUNCATION_MARKER } from './recorder/index';
+import type { InteractionEvent } from './domain/events';
+import type { CanonicalRequest } from './domain/types';
+import type { Provider } from './ports/index';
+
+const request: CanonicalRequest = {
+    tenantId: 'tenant-a',
+    model: 'gpt-4o',
+    messages: [{ role: 'user', content: 'Hi' }],
+};
+
+const completion = JSON.stringify({
+    id: 'chatcmpl-1',
+    object: 'chat.completion',
+    created: 1700000000,
+    model: 'gpt-4o',
+    choices: [{ index: 0, finish_reason: 'stop', message: { role: 'assistant', content: 'Hello' } }],
+    usage: { prompt_tokens: 5, completion_tokens: 1, total_tokens: 6 },
+    system_fingerprint: 'fp_1',
+});
+
+const sse = [
+    'data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}',
+    '',
+    'data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}',
+    '',
+    'data: [DONE]',
+    '',
+].join('\n') + '\n';
+
+function setup(provider: Provider, rawResponseMaxBytes?: number) {
+    const saved: InteractionEvent[] = [];
+    const recorded = withStreamRecording(provider, {
+        events: { saveEvent: async (event) => void saved.push(event) },
+        interactionId: 'int-1',
+        rawResponseMaxBytes,
+    });
+    const raw = () => saved.filter((e) => e.type === 'provider_response').map((e) => e.payload as any);
+    return { provider: recorded, saved, raw };
+}
+
+function openai(body: string, headers: Record<string, string> = {}): OpenAIProvider {
+    const fetch = vi.fn(async () => new Response(body, {
+        status: 200,
+        headers: { 'Content-Type': 'application/json', 'x-request-id': 'req_123', 'set-cookie': 'a=b', ...headers },
+    }));
+    return new OpenAIProvider({ name: 'openai', apiKey: 'sk-test', fetch: fetch as any });
+}
+
+describe('Raw provider responses', () => {
+    it('should save the body the provider sent with its request ID headers', async () => {
+        const { provider, raw } = setup(openai(completion));
+
+        const response = await provider.complete(request);
+
+        expect(response.choices[0]!.message.content).toBe('Hello');
+        expect(raw()).toEqual([{ body: completion, stream: false, headers: { 'x-request-id': 'req_123' }, bytes: completion.length }]);
+    });
+
+    it('should save a stream\'s SSE transcript as sent', async () => {
+        const { provider, saved, raw } = setup(openai(sse, { 'Content-Type': 'text/event-stream' }));
+
+        const text: string[] = [];
+        for await (const event of provider.stream({ ...request, stream: true })) {
+            if (event.contentDelta) text.push(event.contentDelta);
+        }
+
+        expect(text.join('')).toBe('Hello');
+        expect(raw()).toEqual([{ body: sse.trimEnd() + '\n', stream: true, bytes: sse.trimEnd().length + 1 }]);
+        expect(saved[saved.length - 1]!.type).toBe('stream_end');
+    });
+
+    it('should save the Anthropic SSE transcript', async () => {
+        const transcript = [
+            'event: message_start',
+            'data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4","content":[],"usage":{"input_tokens":5,"output_tokens":0}}}',
+            '',
+            'event: content_block_delta',
+            'data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}',
+            '',
+            'event: message_stop',
+            'data: {"type":"message_stop"}',
+            '',
+        ].join('\n') + '\n';
+        const fetch = vi.fn(async () => new Response(transcript, { status: 200, headers: { 'Content-Type': 'text/event-stream' } }));
+        const { provider, raw } = setup(new AnthropicProvider({ name: 'anthropic', apiKey: 'sk-ant', fetch: fetch as any }));
+
+        for await (const _ of provider.stream({ ...request, model: 'claude-sonnet-4', stream: true })) {
+            // drain
+        }
+
+        expect(raw()[0].body).toBe(transcript.trimEnd() + '\n');
+    });
+
+    it('should keep a prefix and mark the truncation past the cap', async () => {
+        const { provider, raw } = setup(openai(completion), 32);
+
+        await provider.complete(request);
+
+        expect(raw()[0]).toMatchObject({
+            body: completion.slice(0, 32) + RAW_TRUNCATION_MARKER,
+            bytes: completion.length,
+            truncated: true,
+        });
+    });
+
+    it('should save nothing when the cap is 0', async () => {
+        const { provider, raw } = setup(openai(completion), 0);
+
+        await provider.complete(request);
+
+        expect(raw()).toEqual([]);
+    });
+
+    it('should attach the raw body and headers to the canonical response', async () => {
+        const response = await openai(completion).complete(request);
+
+        expect(new TextDecoder().decode(response.rawResponse)).toBe(completion);
+        expect(response.rawResponseHeaders).toEqual({ 'x-request-id': 'req_123' });
+    });
+
+    it('should serve the raw body from the admin events endpoint', async () => {
+        const { provider, saved } = setup(openai(completion));
+        await provider.complete(request);
+        const admin = new AdminHandler({
+            storage: {
+                getConversation: async () => null,
+                getResponse: async () => ({ id: 'int-1' }),
+                getEvents: async () => saved,
+            } as any,
+        });
+
+        const response = await admin.handle(new Request('http://localhost/api/interactions/int-1/events'));
+        const { events } = await response.json();
+
+        expect(events).toHaveLength(1);
+        expect(events[0].type).toBe('provider_response');
+        expect(JSON.parse(events[0].payload.body)).toEqual(JSON.parse(completion));
+    });
+});
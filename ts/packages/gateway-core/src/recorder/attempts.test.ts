@@ -0,0 +1,155 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { errServer } from '../domain/errors';
+import { MemoryAttemptStore } from '../usage/index';
+import { harness, ScriptedProvider, type Reply, type TestGateway } from '../__tests__/harness/index';
+
+const usage = { promptTokens: 10, completionTokens: 5, totalTokens: 15 };
+
+/** gpt-4o list price for one call's usage. */
+const callCost = (10 * 2.5 + 5 * 10) / 1_000_000;
+
+const calculatorCall = { id: 'c1', name: 'calculator', arguments: '{"expression":"6 * 7"}' };
+
+describe('Interaction attempts', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(...replies: Reply[]) {
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                gatewayTools: { tools: ['calculator'] },
+                validateJsonOutput: { onInvalid: 'repair' },
+            })
+            .provider(new ScriptedProvider('mock', replies.length > 0 ? replies : { text: 'It is 42.', usage }))
+            .start();
+        const gw = gateway;
+        const chat = (body: Record<string, unknown> = {}) =>
+            gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'what is 6 * 7?' }], ...body });
+        const report = (query: string) => gw.request(`/v1/usage${query}`);
+        return { gateway: gw.gateway, chat, report };
+    }
+
+    it('should record each tool loop call and sum them into the interaction usage', async () => {
+        const { gateway, chat, report } = await setup(
+            { toolCalls: [calculatorCall], usage },
+            { text: 'It is 42.', usage },
+        );
+
+        const response = await chat();
+        const id = response.headers.get('X-Gateway-Interaction-Id')!;
+        const attempts = await gateway.attempts.listAttempts(id, 'acme');
+
+        expect(attempts).toMatchObject([
+            { attempt: 1, provider: 'mock', model: 'gpt-4o', reason: 'primary', outcome: 'success', served: false, usage },
+            { attempt: 2, reason: 'tool_loop_iteration', outcome: 'success', served: true, usage },
+        ]);
+        expect(attempts[0]!.costUsd).toBeCloseTo(callCost, 9);
+        expect(await gateway.attempts.listAttempts(id, 'globex')).toEqual([]);
+        const byModel = await (await report('')).json();
+        expect(byModel.totals).toMatchObject({ requests: 1, total_tokens: 30 });
+    });
+
+    it('should flag a JSON repair call as a retry', async () => {
+        const { gateway, chat } = await setup(
+            { text: '{"answer": "yes"', usage },
+            { text: '{"answer": "yes"}', usage },
+        );
+
+        const response = await chat({ response_format: { type: 'json_object' } });
+        const attempts = await gateway.attempts.listAttempts(response.headers.get('X-Gateway-Interaction-Id')!, 'acme');
+
+        expect(response.status).toBe(200);
+        expect(attempts.map((a) => [a.reason, a.served])).toEqual([['primary', false], ['retry', true]]);
+    });
+
+    it('should record a stream that reached its done event as a success', async () => {
+        const { gateway, chat } = await setup();
+
+        const response = await chat({ stream: true });
+        await response.text();
+        const id = response.headers.get('X-Gateway-Interaction-Id')!;
+
+        await vi.waitFor(async () => expect(await gateway.attempts.listAttempts(id, 'acme')).toMatchObject([
+            { attempt: 1, reason: 'primary', outcome: 'success', served: true, usage },
+        ]));
+    });
+
+    it('should record failed calls without a served attempt', async () => {
+        const { gateway, chat, report } = await setup({ error: errServer('upstream exploded') });
+
+        const response = await chat();
+        const attempts = await gateway.attempts.listAttempts(response.headers.get('X-Gateway-Interaction-Id')!, 'acme');
+        const body = await (await report('?group_by=reason')).json();
+
+        expect(response.status).toBe(500);
+        expect(attempts).toMatchObject([{ reason: 'primary', outcome: 'error', error: 'upstream exploded', served: false }]);
+        expect(body.data).toMatchObject([{ reason: 'primary', requests: 1, errors: 1, total_tokens: 0, cost_usd: 0 }]);
+    });
+
+    it('should group usage reports by attempt reason with cost', async () => {
+        const { chat, report } = await setup(
+            { toolCalls: [calculatorCall], usage },
+            { text: 'It is 42.', usage },
+        );
+        await chat();
+
+        const response = await report('?group_by=reason');
+        const body = await response.json();
+
+        expect(response.status).toBe(200);
+        expect(body.group_by).toBe('reason');
+        expect(body.data).toMatchObject([
+            { object: 'usage.bucket', model: 'gpt-4o', reason: 'primary', requests: 1, errors: 0, total_tokens: 15 },
+            { model: 'gpt-4o', reason: 'tool_loop_iteration', requests: 1, total_tokens: 15 },
+        ]);
+        expect(body.data[0].cost_usd).toBeCloseTo(callCost, 9);
+        expect(body.totals.cost_usd).toBeCloseTo(2 * callCost, 9);
+        expect((await report('?group_by=provider')).status).toBe(400);
+    });
+
+    it('should list attempts on the admin interaction detail', async () => {
+        const attempts = new MemoryAttemptStore();
+        await attempts.saveAttempts([
+            {
+                interactionId: 'resp_1', attempt: 1, tenantId: 'acme', provider: 'openai', model: 'gpt-4o',
+                reason: 'primary', durationMs: 30, outcome: 'error', error: 'timeout', served: false,
+                createdAt: new Date(1000),
+            },
+            {
+                interactionId: 'resp_1', attempt: 2, tenantId: 'acme', provider: 'openai', model: 'gpt-4o',
+                reason: 'retry', usage, costUsd: callCost, durationMs: 40, outcome: 'success', served: true,
+                createdAt: new Date(2000),
+            },
+        ]);
+        const admin = new AdminHandler({
+            storage: {
+                getConversation: async () => null,
+                getResponse: async () => ({
+                    id: 'resp_1', status: 'completed', model: 'gpt-4o', createdAt: new Date(0), updatedAt: new Date(0),
+                }),
+            } as any,
+            attempts,
+        });
+
+        const body = await (await admin.handle(new Request('http://localhost/api/interactions/resp_1'))).json();
+
+        expect(body.attempts).toEqual([
+            {
+                attempt: 1, provider: 'openai', model: 'gpt-4o', reason: 'primary', durationMs: 30,
+                outcome: 'error', error: 'timeout', served: false, createdAt: 1000,
+            },
+            {
+                attempt: 2, provider: 'openai', model: 'gpt-4o', reason: 'retry', usage, costUsd: callCost,
+                durationMs: 40, outcome: 'success', served: true, createdAt: 2000,
+            },
+        ]);
+    });
+});
@@ -0,0 +1,212 @@
+/**
+ * Per-attempt usage attribution.
+ *
+ * Failover, hedging, JSON repairs, and gateway tool loops can make several
+ * provider calls for one client request. Each call is recorded as an
+ * attempt with its own provider, model, usage, cost, duration, outcome,
+ * and reason, so an interaction's usage can be reconciled call by call
+ * against provider invoices. Call sites that call again say why through
+ * ProviderCallOptions.attempt; anything else is a primary call. Attempts
+ * are saved together once the interaction ends, with the one whose
+ * response was served flagged.
+ *
+ * @module recorder/attempts
+ */
+
+import type {
+    CanonicalEvent,
+    CanonicalRequest,
+    CanonicalResponse,
+    Usage,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { AttemptStore, InteractionAttemptRecord } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { isAPIError } from '../domain/errors.js';
+
+// ============================================================================
+// Recorder
+// ============================================================================
+
+/**
+ * Attempt recorder options.
+ */
+export interface AttemptRecorderOptions {
+    /** Interaction ID. */
+    interactionId: string;
+
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** Where attempts are saved when the interaction ends (optional). */
+    store?: Pick<AttemptStore, 'saveAttempts'> | undefined;
+
+    /** Estimates an attempt's cost from catalog pricing. */
+    estimateCost?: ((model: string, usage: Usage) => number | undefined) | undefined;
+
+    /** Logger for failed writes. */
+    logger?: Logger | undefined;
+}
+
+/**
+ * Collects one interaction's provider attempts.
+ */
+export class AttemptRecorder {
+    private readonly options: AttemptRecorderOptions;
+    private readonly records: InteractionAttemptRecord[] = [];
+    private settled = false;
+
+    constructor(options: AttemptRecorderOptions) {
+        this.options = options;
+    }
+
+    /**
+     * Records one finished provider call.
+     */
+    record(attempt: {
+        provider: string;
+        request: CanonicalRequest;
+        options?: ProviderCallOptions | undefined;
+        startedAt: number;
+        usage?: Usage | undefined;
+        error?: unknown;
+    }): void {
+        const { request, usage } = attempt;
+        const upstream = isAPIError(attempt.error) ? attempt.error.upstream : undefined;
+        this.records.push({
+            interactionId: this.options.interactionId,
+            attempt: this.records.length + 1,
+            tenantId: this.options.tenantId,
+            provider: attempt.provider,
+            model: request.model,
+            reason: attempt.options?.attempt ?? 'primary',
+            usage,
+            costUsd: usage && this.options.estimateCost?.(request.model, usage),
+            durationMs: Date.now() - attempt.startedAt,
+            outcome: attempt.error === undefined ? 'success' : 'error',
+            error: attempt.error === undefined ? undefined : errorMessage(attempt.error),
+            statusCode: upstream?.status,
+            upstreamError: upstream?.body,
+            served: false,
+            createdAt: new Date(attempt.startedAt),
+        });
+    }
+
+    /**
+     * Attempts recorded so far, in call order.
+     */
+    attempts(): readonly InteractionAttemptRecord[] {
+        return this.records;
+    }
+
+    /**
+     * Sum of the attempts' usage, or undefined when none reported any.
+     */
+    usage(): Usage | undefined {
+        const reported = this.records.filter((r) => r.usage);
+        if (reported.length === 0) {
+            return undefined;
+        }
+        const total: Usage = { promptTokens: 0, completionTokens: 0, totalTokens: 0 };
+        for (const { usage } of reported) {
+            total.promptTokens += usage!.promptTokens;
+            total.completionTokens += usage!.completionTokens;
+            total.totalTokens += usage!.totalTokens;
+        }
+        return total;
+    }
+
+    /**
+     * Saves the attempts once the interaction ends. When it succeeded, the
+     * last successful attempt is flagged as served. Later calls are
+     * ignored. Never throws or waits on the write.
+     */
+    settle(succeeded: boolean): void {
+        if (this.settled || this.records.length === 0) {
+            return;
+        }
+        this.settled = true;
+        if (succeeded) {
+            const served = this.records.filter((r) => r.outcome === 'success').at(-1);
+            if (served) {
+                served.served = true;
+            }
+        }
+        this.options.store?.saveAttempts([...this.records]).catch((error: unknown) => {
+            this.options.logger?.error('attempt_record_failed', {
+                tenantId: this.options.tenantId,
+                interactionId: this.options.interactionId,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+}
+
+// ============================================================================
+// Recording Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so every call is recorded as an attempt.
+ */
+export class AttemptRecordingProvider extends ProviderWrapper {
+    private readonly recorder: AttemptRecorder;
+
+    constructor(inner: Provider, recorder: AttemptRecorder) {
+        super(inner);
+        this.recorder = recorder;
+    }
+
+    /**
+     * Completes a request, recording the call.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const startedAt = Date.now();
+        try {
+            const response = await this.inner.complete(request, options);
+            this.recorder.record({ provider: this.name, request, options, startedAt, usage: response.usage });
+            return response;
+        } catch (error) {
+            this.recorder.record({ provider: this.name, request, options, startedAt, error });
+            throw error;
+        }
+    }
+
+    /**
+     * Streams a request, recording the call however the stream ends. A
+     * stream the consumer stops early counts as failed.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const startedAt = Date.now();
+        let usage: Usage | undefined;
+        let error: unknown = 'Stream closed before completion';
+        try {
+            for await (const event of this.inner.stream(request, options)) {
+                usage = event.usage ?? usage;
+                // Consumers stop reading at the done event
+                if (event.type === 'done') {
+                    error = undefined;
+                }
+                yield event;
+            }
+            error = undefined;
+        } catch (failure) {
+            error = failure;
+            throw failure;
+        } finally {
+            this.recorder.record({ provider: this.name, request, options, startedAt, usage, error });
+        }
+    }
+}
+
+/**
+ * Records a provider's calls with an interaction's attempt recorder.
+ */
+export function withAttemptRecording(provider: Provider, recorder: AttemptRecorder): Provider {
+    return new AttemptRecordingProvider(provider, recorder);
+}
+
+function errorMessage(error: unknown): string {
+    return error instanceof Error ? error.message : String(error);
+}
@@ -21,3 +21,42 @@ export {
     // Helpers
     extractRelevantHeaders,
 } from './interaction.js';
+
+export {
+    InteractionSampler,
+    recordingMetadata,
+    DEFAULT_RECORDING_SAMPLE_RATE,
+    MAX_HELD_EVENTS,
+    MAX_PENDING_RECORDINGS,
+    type InteractionSamplerOptions,
+    type RecordingDecision,
+    type RecordingOutcome,
+    type RecordingReason,
+} from './sampling.js';
+
+export {
+    StreamRecordingProvider,
+    withStreamRecording,
+    expandTranscripts,
+    MAX_TRANSCRIPT_CHUNKS,
+    type StreamRecordingOptions,
+    type RecordedChunk,
+    type StreamChunkPayload,
+    type StreamTranscriptPayload,
+} from './stream.js';
+
+export {
+    AttemptRecorder,
+    AttemptRecordingProvider,
+    withAttemptRecording,
+    type AttemptRecorderOptions,
+} from './attempts.js';
+
+export {
+    RawCapture,
+    DEFAULT_RAW_RESPONSE_MAX_BYTES,
+    RAW_TRUNCATION_MARKER,
+    providerRequestPayload,
+    type ProviderResponsePayload,
+    type ProviderRequestPayload,
+} from './raw.js';
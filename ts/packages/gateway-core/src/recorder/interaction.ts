@@ -13,6 +13,7 @@
  */
 
 import type { CanonicalRequest, CanonicalResponse, APIType } from '../domain/types.js';
+import type { InteractionTimings } from '../domain/events.js';
 import type { StorageProvider } from '../ports/storage.js';
 import type { Logger } from '../utils/logging.js';
 import { randomUUID } from '../utils/crypto.js';
@@ -60,6 +61,9 @@ export interface RecordInteractionParams {
     /** Frontdoor API type. */
     frontdoor: APIType;
 
+    /** Conversions the frontdoor applied after decoding (e.g., legacy prompt to message). */
+    transformations?: TransformationStep[] | undefined;
+
     /** Provider name. */
     provider: string;
 
@@ -75,6 +79,9 @@ export interface RecordInteractionParams {
     /** Request duration in milliseconds. */
     durationMs?: number | undefined;
 
+    /** Per-phase latency breakdown. */
+    timings?: InteractionTimings | undefined;
+
     /** Response finish reason. */
     finishReason?: string | undefined;
 
@@ -125,6 +132,9 @@ export interface Interaction {
     /** Duration in milliseconds. */
     durationMs?: number | undefined;
 
+    /** Per-phase latency breakdown. */
+    timings?: InteractionTimings | undefined;
+
     /** Previous interaction ID. */
     previousInteractionId?: string | undefined;
 
@@ -325,6 +335,7 @@ export class InteractionRecorder {
 
         // Update response data
         interaction.durationMs = params.durationMs;
+        interaction.timings = params.timings;
         interaction.updatedAt = now;
 
         if (params.canonicalResponse) {
@@ -373,6 +384,7 @@ export class InteractionRecorder {
             requestedModel: params.canonicalRequest?.model,
             servedModel: params.canonicalResponse?.model,
             durationMs: params.durationMs,
+            timings: params.timings,
             previousInteractionId: params.previousInteractionId,
             threadKey: params.threadKey,
             requestHeaders: params.requestHeaders,
@@ -394,7 +406,7 @@ export class InteractionRecorder {
                     ? JSON.stringify(params.canonicalRequest)
                     : undefined,
                 unmappedFields: params.unmappedRequest,
-                providerRequest: params.providerRequestBody,
+                providerRequest: params.providerRequestBody ?? params.canonicalResponse?.providerRequestBody,
             };
         }
 
@@ -409,10 +421,12 @@ export class InteractionRecorder {
             return undefined;
         }
 
+        // Raw bodies are kept as bytes, not serialized into the canonical JSON
+        const { rawResponse, rawResponseHeaders: _, providerRequestBody: __, ...canonical } = params.canonicalResponse ?? {};
         return {
-            raw: params.rawResponse,
+            raw: params.rawResponse ?? rawResponse,
             canonicalJson: params.canonicalResponse
-                ? JSON.stringify(params.canonicalResponse)
+                ? JSON.stringify(canonical)
                 : undefined,
             unmappedFields: params.unmappedResponse,
             clientResponse: params.clientResponse,
@@ -447,6 +461,11 @@ export class InteractionRecorder {
             });
         }
 
+        // Frontdoor conversions follow the decode
+        if (params.transformations) {
+            steps.push(...params.transformations);
+        }
+
         // Step 2: Model mapping
         if (
             params.canonicalResponse?.model &&
@@ -466,7 +485,7 @@ export class InteractionRecorder {
         }
 
         // Step 3: Encode for provider
-        if (params.providerRequestBody) {
+        if (params.providerRequestBody ?? params.canonicalResponse?.providerRequestBody) {
             steps.push({
                 stage: 'encode_provider_request',
                 timestamp,
@@ -513,6 +532,7 @@ export class InteractionRecorder {
             provider: interaction.provider,
             model: interaction.servedModel ?? interaction.requestedModel,
             durationMs: interaction.durationMs,
+            timings: interaction.timings,
         });
     }
 }
@@ -0,0 +1,145 @@
+/**
+ * Raw provider requests and responses, for debugging codecs and settling
+ * disputes with providers.
+ *
+ * What a provider actually sent is saved as a provider_response
+ * interaction event, next to what the gateway made of it: the body of a
+ * non-streaming response with the headers worth keeping (request IDs,
+ * rate limits), or the SSE transcript of a stream. Bodies are capped
+ * (recording.raw_response_max_bytes); a longer one keeps its prefix,
+ * followed by a truncation marker.
+ *
+ * What the gateway sent is saved as a provider_request event per upstream
+ * call: the exact body, never capped, with its SHA-256.
+ *
+ * @module recorder/raw
+ */
+
+import type { AttemptReason } from '../ports/provider.js';
+import { sha256Bytes } from '../utils/crypto.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default cap on a saved raw response body, in bytes. */
+export const DEFAULT_RAW_RESPONSE_MAX_BYTES = 256 * 1024;
+
+/** Appended to a raw body cut at the cap. */
+export const RAW_TRUNCATION_MARKER = '\n[truncated by gateway]';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Payload of a provider_response event. */
+export interface ProviderResponsePayload {
+    /** Response body, or the stream's SSE transcript, as sent. */
+    body: string;
+
+    /** Whether the response was streamed. */
+    stream: boolean;
+
+    /** Response headers kept (non-streaming responses). */
+    headers?: Record<string, string> | undefined;
+
+    /** Size of the whole body in bytes, including any part not kept. */
+    bytes: number;
+
+    /** Set when the body was cut at the cap. */
+    truncated?: boolean | undefined;
+}
+
+/** Payload of a provider_request event. */
+export interface ProviderRequestPayload {
+    /** Request body as sent. */
+    body: string;
+
+    /** Whether the request was for a stream. */
+    stream: boolean;
+
+    /** Size of the body in bytes. */
+    bytes: number;
+
+    /** Hex SHA-256 of the body's bytes. */
+    sha256: string;
+
+    /** Provider called. */
+    provider: string;
+
+    /** Why the call was made (default: primary). */
+    attempt?: AttemptReason | undefined;
+}
+
+// ============================================================================
+// Capture
+// ============================================================================
+
+/**
+ * Accumulates a raw body up to a byte cap, counting what it doesn't keep.
+ */
+export class RawCapture {
+    private readonly maxBytes: number;
+    private readonly parts: Uint8Array[] = [];
+    private kept = 0;
+    private total = 0;
+
+    constructor(maxBytes: number) {
+        this.maxBytes = maxBytes;
+    }
+
+    /**
+     * Adds the next piece of the body.
+     */
+    append(bytes: Uint8Array): void {
+        this.total += bytes.length;
+        const room = this.maxBytes - this.kept;
+        if (room <= 0) return;
+        const part = bytes.length <= room ? bytes : bytes.subarray(0, room);
+        this.parts.push(part);
+        this.kept += part.length;
+    }
+
+    /** Bytes seen so far. */
+    get size(): number {
+        return this.total;
+    }
+
+    /**
+     * The provider_response payload for what was captured. A character
+     * split by the cap decodes as U+FFFD.
+     */
+    payload(stream: boolean, headers?: Record<string, string> | undefined): ProviderResponsePayload {
+        const joined = new Uint8Array(this.kept);
+        let offset = 0;
+        for (const part of this.parts) {
+            joined.set(part, offset);
+            offset += part.length;
+        }
+        const truncated = this.total > this.kept;
+        return {
+            body: new TextDecoder().decode(joined) + (truncated ? RAW_TRUNCATION_MARKER : ''),
+            stream,
+            headers,
+            bytes: this.total,
+            truncated: truncated || undefined,
+        };
+    }
+}
+
+/**
+ * The provider_request payload for a request body.
+ */
+export async function providerRequestPayload(
+    body: Uint8Array,
+    call: { stream: boolean; provider: string; attempt?: AttemptReason | undefined },
+): Promise<ProviderRequestPayload> {
+    return {
+        body: new TextDecoder().decode(body),
+        stream: call.stream,
+        bytes: body.length,
+        sha256: await sha256Bytes(body),
+        provider: call.provider,
+        attempt: call.attempt,
+    };
+}
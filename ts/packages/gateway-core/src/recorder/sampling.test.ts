@@ -0,0 +1,155 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import type { AppConfig } from '../ports/index';
+import { InteractionSampler, MAX_HELD_EVENTS, recordingMetadata } from './index';
+import { createInteractionEvent, type LifecycleEvent } from '../domain/events';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const headers = (init?: Record<string, string>) => new Headers(init);
+const event = (interactionId: string) => createInteractionEvent('response', interactionId, { ok: true });
+
+describe('InteractionSampler', () => {
+    it('should record every request in full without a recording config', async () => {
+        const saveEvent = vi.fn(async () => undefined);
+        const sampler = new InteractionSampler({ store: { saveEvent } });
+
+        expect(sampler.decide('int-1', undefined, headers())).toEqual({ full: true, reason: 'mode' });
+        await sampler.events.saveEvent(event('int-1'));
+
+        expect(saveEvent).toHaveBeenCalledTimes(1);
+        expect(sampler.settle('int-1', { error: false, durationMs: 10 })).toEqual({ full: true, reason: 'mode' });
+    });
+
+    it('should sample at the configured rate and drop sampled-out events', async () => {
+        const saveEvent = vi.fn(async () => undefined);
+        const draws = [0.004, 0.5];
+        const sampler = new InteractionSampler({ store: { saveEvent }, random: () => draws.shift()! });
+        const config = { mode: 'sampled' as const, sampleRate: 0.01 };
+
+        expect(sampler.decide('kept', config, headers())).toEqual({ full: true, reason: 'sampled' });
+        expect(sampler.decide('dropped', config, headers())).toEqual({ full: false, reason: 'sampled_out' });
+        await sampler.events.saveEvent(event('kept'));
+        await sampler.events.saveEvent(event('dropped'));
+
+        expect(saveEvent.mock.calls.map(([e]: any) => e.interactionId)).toEqual(['kept']);
+        expect(sampler.settle('dropped', { error: true, durationMs: 60_000 })).toEqual({ full: false, reason: 'sampled_out' });
+    });
+
+    it('should escalate on the debug header before any event is logged', async () => {
+        const saveEvent = vi.fn(async () => undefined);
+        const sampler = new InteractionSampler({ store: { saveEvent }, random: () => 0.99 });
+
+        const decision = sampler.decide('int-1', {
+            mode: 'sampled',
+            sampleRate: 0.01,
+            alwaysFullOn: { header: 'x-debug-record' },
+        }, headers({ 'X-Debug-Record': '1' }));
+        await sampler.events.saveEvent(event('int-1'));
+
+        expect(decision).toEqual({ full: true, reason: 'header' });
+        expect(saveEvent).toHaveBeenCalledTimes(1);
+    });
+
+    it('should hold events until an error or slow trigger decides the request', async () => {
+        const saveEvent = vi.fn(async () => undefined);
+        const sampler = new InteractionSampler({ store: { saveEvent }, random: () => 0.99 });
+        const config = { mode: 'sampled' as const, alwaysFullOn: { error: true, slowMs: 5000 } };
+
+        sampler.decide('failed', config, headers());
+        sampler.decide('slow', config, headers());
+        sampler.decide('fine', config, headers());
+        for (const id of ['failed', 'slow', 'fine']) {
+            await sampler.events.saveEvent(event(id));
+        }
+        expect(saveEvent).not.toHaveBeenCalled();
+
+        expect(sampler.settle('failed', { error: true, durationMs: 20 })).toEqual({ full: true, reason: 'error' });
+        expect(sampler.settle('slow', { error: false, durationMs: 5000 })).toEqual({ full: true, reason: 'slow' });
+        expect(sampler.settle('fine', { error: false, durationMs: 20 })).toEqual({ full: false, reason: 'sampled_out' });
+
+        await vi.waitFor(() => expect(saveEvent).toHaveBeenCalledTimes(2));
+        expect(saveEvent.mock.calls.map(([e]: any) => e.interactionId)).toEqual(['failed', 'slow']);
+    });
+
+    it('should record only failures in errors_only mode and bound what it holds', async () => {
+        const saveEvent = vi.fn(async () => undefined);
+        const sampler = new InteractionSampler({ store: { saveEvent } });
+
+        expect(sampler.decide('int-1', { mode: 'errors_only' }, headers())).toEqual({ full: false, reason: 'not_error' });
+        for (let i = 0; i < MAX_HELD_EVENTS + 5; i++) {
+            await sampler.events.saveEvent(event('int-1'));
+        }
+        expect(sampler.settle('int-1', { error: true, durationMs: 1 })).toEqual({ full: true, reason: 'error' });
+
+        await vi.waitFor(() => expect(saveEvent).toHaveBeenCalledTimes(MAX_HELD_EVENTS));
+    });
+
+    it('should describe the decision as interaction metadata', () => {
+        expect(recordingMetadata({ full: false, reason: 'sampled_out' })).toEqual({
+            recording: 'summary',
+            recording_reason: 'sampled_out',
+        });
+    });
+});
+
+describe('Gateway interaction sampling', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(recording: AppConfig['recording']) {
+        const published: LifecycleEvent[] = [];
+        const logger = spyLogger();
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1/chat', recording })
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses', recording })
+            .provider(new ScriptedProvider('mock', { text: 'secret answer' }))
+            .config({ events: { sink: 'webhook', payload: 'full', batchSize: 1, flushInterval: '1ms' } })
+            .options({
+                eventSinkFactory: () => ({ name: 'memory', send: async (events: LifecycleEvent[]) => void published.push(...events) }),
+                logger,
+            })
+            .start();
+        const gw = gateway;
+        const send = (path: string, body: object, extra: Record<string, string> = {}) => gw.post(path, body, extra);
+        return { published, saved: gw.store.events, metadata: () => logged(logger.info, 'interaction_metadata'), send };
+    }
+
+    it('should publish a summary without bodies and skip events for sampled-out requests', async () => {
+        const { published, saved, metadata, send } = await setup({ mode: 'sampled', sampleRate: 0 });
+
+        const response = await send('/v1/responses', { model: 'gpt-4o', input: 'Hi' });
+        await response.text();
+
+        await vi.waitFor(() => expect(published).toHaveLength(1));
+        expect(published[0]!.data).toMatchObject({
+            model: 'gpt-4o',
+            statusCode: 200,
+            usage: { totalTokens: 15 },
+            recording: 'sampled_out',
+        });
+        expect(published[0]!.data).not.toHaveProperty('request');
+        expect(published[0]!.data).not.toHaveProperty('response');
+        expect(saved).toEqual([]);
+        expect(metadata()).toContainEqual({ recording: 'summary', recording_reason: 'sampled_out' });
+    });
+
+    it('should record a request in full when it carries the debug header', async () => {
+        const { published, saved, metadata, send } = await setup({
+            mode: 'sampled',
+            sampleRate: 0,
+            alwaysFullOn: { header: 'x-debug-record' },
+        });
+
+        const response = await send('/v1/responses', { model: 'gpt-4o', input: 'Hi' }, { 'X-Debug-Record': '1' });
+        await response.text();
+
+        await vi.waitFor(() => expect(published).toHaveLength(1));
+        expect(published[0]!.data).not.toHaveProperty('recording');
+        expect(published[0]!.data).toMatchObject({ request: { model: 'gpt-4o' }, response: { choices: [{ message: { content: 'secret answer' } }] } });
+        expect(saved).toEqual([expect.objectContaining({ type: 'response', interactionId: published[0]!.interactionId })]);
+        expect(metadata()).toContainEqual({ recording: 'full', recording_reason: 'header' });
+    });
+});
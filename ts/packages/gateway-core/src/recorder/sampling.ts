@@ -0,0 +1,237 @@
+/**
+ * Interaction recording decisions: which requests keep their payloads.
+ *
+ * Each request gets one decision when it is routed, from its app's
+ * `recording` config: "full" records every request, "sampled" a random
+ * share, "errors_only" only failed requests. Escalation triggers record a
+ * request in full regardless of mode: a request header (known up front),
+ * an error or a slow response (known when the request finishes).
+ *
+ * Requests recorded as a summary still publish their interaction_completed
+ * event with IDs, models, usage, status and timings (what billing needs),
+ * but without request or response bodies, and their interaction events are
+ * not saved. Events logged while an error or slow trigger could still
+ * escalate the request are held (bounded) and saved only if it does.
+ *
+ * @module recorder/sampling
+ */
+
+import type { InteractionEvent } from '../domain/events.js';
+import type { RecordingConfig } from '../ports/config.js';
+import type { InteractionStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default share of requests recorded in full by "sampled" mode. */
+export const DEFAULT_RECORDING_SAMPLE_RATE = 0.01;
+
+/** Interaction events held per request while its recording is undecided. */
+export const MAX_HELD_EVENTS = 100;
+
+/** Requests tracked at once; the oldest are dropped beyond it. */
+export const MAX_PENDING_RECORDINGS = 10_000;
+
+/**
+ * Why a request was recorded the way it was: "mode" (full mode or no
+ * config), "sampled"/"sampled_out" (sampled mode), "not_error"
+ * (errors_only mode, request succeeded), or the trigger that escalated it.
+ */
+export type RecordingReason = 'mode' | 'sampled' | 'sampled_out' | 'not_error' | 'header' | 'error' | 'slow';
+
+/**
+ * A request's recording decision.
+ */
+export interface RecordingDecision {
+    /** Whether bodies and interaction events are recorded. */
+    full: boolean;
+
+    /** Why. */
+    reason: RecordingReason;
+}
+
+/**
+ * How a request finished, for the error and slow triggers.
+ */
+export interface RecordingOutcome {
+    /** The request failed (threw, or answered with a 4xx/5xx). */
+    error: boolean;
+
+    /** Total duration in milliseconds. */
+    durationMs: number;
+}
+
+/**
+ * Interaction sampler options.
+ */
+export interface InteractionSamplerOptions {
+    /** Where interaction events are saved. */
+    store?: Pick<InteractionStore, 'saveEvent'> | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /**
+     * Called with each event as it is saved, before the store write (e.g.
+     * InteractionTails.publish). Must not block; errors are logged.
+     */
+    onEvent?: ((event: InteractionEvent) => void) | undefined;
+
+    /** Sampling source in [0, 1), for tests. */
+    random?: (() => number) | undefined;
+}
+
+interface PendingRecording {
+    config: RecordingConfig;
+    decision: RecordingDecision;
+    /** An error or slow trigger could still escalate the request. */
+    open: boolean;
+    held: InteractionEvent[];
+}
+
+/** Decision for requests whose app has no recording config. */
+const RECORD_FULL: RecordingDecision = { full: true, reason: 'mode' };
+
+// ============================================================================
+// Interaction Sampler
+// ============================================================================
+
+/**
+ * Makes and applies per-request recording decisions.
+ */
+export class InteractionSampler {
+    /** Interaction event store that applies each request's decision. */
+    readonly events: Pick<InteractionStore, 'saveEvent'>;
+
+    private readonly store?: Pick<InteractionStore, 'saveEvent'> | undefined;
+    private readonly logger?: Logger | undefined;
+    private readonly onEvent?: ((event: InteractionEvent) => void) | undefined;
+    private readonly random: () => number;
+    private readonly pending = new Map<string, PendingRecording>();
+
+    constructor(options: InteractionSamplerOptions = {}) {
+        this.store = options.store;
+        this.logger = options.logger;
+        this.onEvent = options.onEvent;
+        this.random = options.random ?? Math.random;
+        this.events = { saveEvent: (event) => this.saveEvent(event) };
+    }
+
+    /**
+     * Decides how a request is recorded. Called once, when it is routed.
+     */
+    decide(interactionId: string, config: RecordingConfig | undefined, headers: Headers): RecordingDecision {
+        if (!config) {
+            return RECORD_FULL;
+        }
+
+        const triggers = config.alwaysFullOn;
+        let decision: RecordingDecision;
+        if (triggers?.header && headers.has(triggers.header)) {
+            decision = { full: true, reason: 'header' };
+        } else if (config.mode === 'sampled') {
+            decision = this.random() < (config.sampleRate ?? DEFAULT_RECORDING_SAMPLE_RATE)
+                ? { full: true, reason: 'sampled' }
+                : { full: false, reason: 'sampled_out' };
+        } else if (config.mode === 'errors_only') {
+            decision = { full: false, reason: 'not_error' };
+        } else {
+            decision = RECORD_FULL;
+        }
+
+        if (this.pending.size >= MAX_PENDING_RECORDINGS) {
+            this.pending.delete(this.pending.keys().next().value!);
+        }
+        this.pending.set(interactionId, {
+            config,
+            decision,
+            open: !decision.full && (config.mode === 'errors_only' || triggers?.error === true || triggers?.slowMs !== undefined),
+            held: [],
+        });
+        return decision;
+    }
+
+    /**
+     * Applies the error and slow triggers once a request finishes, saves
+     * any held events if it is now recorded in full, and returns the final
+     * decision. Later calls for the same request return the default.
+     */
+    settle(interactionId: string, outcome: RecordingOutcome): RecordingDecision {
+        const pending = this.pending.get(interactionId);
+        if (!pending) {
+            return RECORD_FULL;
+        }
+        this.pending.delete(interactionId);
+
+        let { decision } = pending;
+        if (pending.open) {
+            const triggers = pending.config.alwaysFullOn;
+            if (outcome.error && (pending.config.mode === 'errors_only' || triggers?.error)) {
+                decision = { full: true, reason: 'error' };
+            } else if (triggers?.slowMs !== undefined && outcome.durationMs >= triggers.slowMs) {
+                decision = { full: true, reason: 'slow' };
+            }
+        }
+
+        if (decision.full) {
+            for (const event of pending.held) {
+                void this.flush(event);
+            }
+        }
+        return decision;
+    }
+
+    // ---- Private Methods ----
+
+    private async saveEvent(event: InteractionEvent): Promise<void> {
+        const pending = this.pending.get(event.interactionId);
+        if (!pending || pending.decision.full) {
+            this.publish(event);
+            // Callers handle their own save failures
+            return this.store?.saveEvent(event);
+        }
+        if (pending.open && pending.held.length < MAX_HELD_EVENTS) {
+            pending.held.push(event);
+        }
+    }
+
+    private async flush(event: InteractionEvent): Promise<void> {
+        this.publish(event);
+        await this.store?.saveEvent(event).catch((error: unknown) => {
+            this.logger?.warn('interaction_event_save_failed', {
+                interactionId: event.interactionId,
+                type: event.type,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    private publish(event: InteractionEvent): void {
+        try {
+            this.onEvent?.(event);
+        } catch (error) {
+            this.logger?.warn('interaction_event_publish_failed', {
+                interactionId: event.interactionId,
+                type: event.type,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Interaction metadata explaining a recording decision, so a missing body
+ * can be told apart from a lost one.
+ */
+export function recordingMetadata(decision: RecordingDecision): Record<string, string> {
+    return {
+        recording: decision.full ? 'full' : 'summary',
+        recording_reason: decision.reason,
+    };
+}
@@ -0,0 +1,256 @@
+/**
+ * Interaction events for streamed responses.
+ *
+ * A streamed response can carry hundreds of events, so by default they are
+ * not saved one row each: they are held in memory (bounded) and saved at
+ * stream end as a single stream_transcript event, next to the stream_start,
+ * first_token and stream_end milestones. The "chunk" granularity saves one
+ * stream_chunk event per stream event instead, for debugging.
+ *
+ * The provider's raw response is saved too, as a provider_response event:
+ * the body of each non-streaming call, and the SSE transcript of streams.
+ * Each upstream call's exact request body is saved as a provider_request
+ * event, whether the call succeeds or fails.
+ *
+ * @module recorder/stream
+ */
+
+import type { InteractionEvent, InteractionEventType } from '../domain/events.js';
+import { createInteractionEvent } from '../domain/events.js';
+import type {
+    CanonicalEvent,
+    CanonicalRequest,
+    CanonicalResponse,
+    Usage,
+} from '../domain/types.js';
+import type { EventGranularity } from '../ports/config.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { InteractionStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { RawCapture, DEFAULT_RAW_RESPONSE_MAX_BYTES, providerRequestPayload } from './raw.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Stream events kept in one transcript; later events are counted, not kept. */
+export const MAX_TRANSCRIPT_CHUNKS = 5000;
+
+/**
+ * A stream event as saved: the canonical event without raw bytes, with
+ * errors reduced to their message.
+ */
+export type RecordedChunk = Omit<CanonicalEvent, 'rawEvent' | 'error'> & { error?: string | undefined };
+
+/** Payload of a stream_chunk event. */
+export interface StreamChunkPayload {
+    /** Position in the stream, from 0. */
+    seq: number;
+
+    /** The stream event. */
+    event: RecordedChunk;
+}
+
+/** Payload of a stream_transcript event. */
+export interface StreamTranscriptPayload {
+    /** Stream events, in order. */
+    chunks: RecordedChunk[];
+
+    /** Events past MAX_TRANSCRIPT_CHUNKS that were not kept. */
+    dropped?: number | undefined;
+}
+
+/**
+ * Stream recording options.
+ */
+export interface StreamRecordingOptions {
+    /** Where interaction events are saved. */
+    events: Pick<InteractionStore, 'saveEvent'>;
+
+    /** Interaction the events belong to. */
+    interactionId: string;
+
+    /** Event granularity (default: compacted). */
+    granularity?: EventGranularity | undefined;
+
+    /** Cap on a saved raw response body in bytes; 0 saves none (default: 256 KiB). */
+    rawResponseMaxBytes?: number | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Recording Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so its streams, and its raw requests and responses, are
+ * recorded as interaction events.
+ */
+export class StreamRecordingProvider extends ProviderWrapper {
+    private readonly options: StreamRecordingOptions;
+
+    constructor(inner: Provider, options: StreamRecordingOptions) {
+        super(inner);
+        this.options = options;
+    }
+
+    /**
+     * Completes a request, recording the bodies sent and the provider's raw
+     * response.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, this.recordingRequests(false, options));
+        const raw = this.rawCapture();
+        if (raw && response.rawResponse) {
+            raw.append(response.rawResponse);
+            this.save('provider_response', raw.payload(false, response.rawResponseHeaders));
+        }
+        return response;
+    }
+
+    /**
+     * Streams a request, recording its events. The transcript and
+     * stream_end are saved however the stream ends, including when the
+     * consumer stops early.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const compacted = this.options.granularity !== 'chunk';
+        const startedAt = Date.now();
+        const chunks: RecordedChunk[] = [];
+        let seq = 0;
+        let firstTokenMs: number | undefined;
+        let usage: Usage | undefined;
+        let finishReason: string | undefined;
+        let failure: string | undefined;
+        const raw = this.rawCapture();
+
+        this.save('stream_start', { provider: this.name, model: request.model });
+        try {
+            for await (const event of this.inner.stream(request, this.recordingRequests(true, options))) {
+                if (firstTokenMs === undefined && (event.contentDelta || event.thinkingDelta || event.toolCall)) {
+                    firstTokenMs = Date.now() - startedAt;
+                    this.save('first_token', { afterMs: firstTokenMs });
+                }
+                usage = event.usage ?? usage;
+                finishReason = event.finishReason ?? finishReason;
+                if (raw && event.rawEvent) {
+                    raw.append(event.rawEvent);
+                }
+
+                const chunk = recordChunk(event);
+                if (!compacted) {
+                    const payload: StreamChunkPayload = { seq, event: chunk };
+                    this.save('stream_chunk', payload);
+                } else if (chunks.length < MAX_TRANSCRIPT_CHUNKS) {
+                    chunks.push(chunk);
+                }
+                seq++;
+                yield event;
+            }
+        } catch (error) {
+            failure = error instanceof Error ? error.message : String(error);
+            throw error;
+        } finally {
+            if (compacted) {
+                const dropped = seq - chunks.length;
+                const payload: StreamTranscriptPayload = { chunks, dropped: dropped > 0 ? dropped : undefined };
+                this.save('stream_transcript', payload);
+            }
+            if (raw && raw.size > 0) {
+                this.save('provider_response', raw.payload(true));
+            }
+            this.save('stream_end', {
+                chunks: seq,
+                durationMs: Date.now() - startedAt,
+                firstTokenMs,
+                usage,
+                finishReason,
+                error: failure,
+            });
+        }
+    }
+
+    /**
+     * Adds a request body observer to call options that saves each body
+     * sent as a provider_request event.
+     */
+    private recordingRequests(stream: boolean, options: ProviderCallOptions | undefined): ProviderCallOptions {
+        const observe = options?.onRequestBody;
+        return {
+            ...options,
+            onRequestBody: (body) => {
+                observe?.(body);
+                void providerRequestPayload(body, { stream, provider: this.name, attempt: options?.attempt })
+                    .then((payload) => this.save('provider_request', payload));
+            },
+        };
+    }
+
+    /** A capture for one raw response, unless raw responses aren't saved. */
+    private rawCapture(): RawCapture | undefined {
+        const maxBytes = this.options.rawResponseMaxBytes ?? DEFAULT_RAW_RESPONSE_MAX_BYTES;
+        return maxBytes > 0 ? new RawCapture(maxBytes) : undefined;
+    }
+
+    private save(type: InteractionEventType, payload: unknown): void {
+        const event = createInteractionEvent(type, this.options.interactionId, payload);
+        this.options.events.saveEvent(event).catch((error: unknown) => {
+            this.options.logger?.warn('stream_event_save_failed', {
+                interactionId: event.interactionId,
+                type,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+}
+
+/**
+ * Records a provider's streams as interaction events. Returns the
+ * provider unchanged when there is no event store.
+ */
+export function withStreamRecording(
+    provider: Provider,
+    options: Omit<StreamRecordingOptions, 'events'> & { events?: StreamRecordingOptions['events'] | undefined },
+): Provider {
+    const { events } = options;
+    if (!events) {
+        return provider;
+    }
+    return new StreamRecordingProvider(provider, { ...options, events });
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Expands each stream_transcript event into the stream_chunk events it
+ * replaced, as the "chunk" granularity would have saved them. Other events
+ * pass through.
+ */
+export function expandTranscripts(events: InteractionEvent[]): InteractionEvent[] {
+    return events.flatMap((event) => {
+        if (event.type !== 'stream_transcript') {
+            return [event];
+        }
+        const { chunks = [] } = (event.payload ?? {}) as Partial<StreamTranscriptPayload>;
+        return chunks.map((chunk, seq): InteractionEvent => {
+            const payload: StreamChunkPayload = { seq, event: chunk };
+            return {
+                id: `${event.id}.${seq}`,
+                interactionId: event.interactionId,
+                type: 'stream_chunk',
+                timestamp: event.timestamp,
+                payload,
+            };
+        });
+    });
+}
+
+function recordChunk(event: CanonicalEvent): RecordedChunk {
+    const { rawEvent: _, error, ...rest } = event;
+    return error ? { ...rest, error: error.message } : rest;
+}
@@ -0,0 +1,357 @@
+import { describe, it, expect } from 'vitest';
+import { ResponsesHandler, StreamReplayBuffer, RESPONSES_DONE_FRAME, REPLAY_EVENTS_DROPPED } from './responses/index';
+
+function parseFrames(frames: string[]): { id?: number; event: string; data: any }[] {
+    return frames.filter((frame) => frame !== RESPONSES_DONE_FRAME).map((frame) => {
+        const id = /^id: (\d+)$/m.exec(frame)?.[1];
+        return {
+            id: id !== undefined ? Number(id) : undefined,
+            event: /^event: (.+)$/m.exec(frame)![1]!,
+            data: JSON.parse(/^data: (.+)$/m.exec(frame)![1]!),
+        };
+    });
+}
+
+function memoryStorage() {
+    const responses = new Map<string, any>();
+    return {
+        responses,
+        async saveResponse(record: any) {
+            responses.set(record.id, record);
+        },
+        async getResponse(id: string, tenantId: string) {
+            const record = responses.get(id);
+            return record && (tenantId === '' || record.tenantId === tenantId) ? record : null;
+        },
+    } as any;
+}
+
+/** Provider whose stream pauses after the first delta until released. */
+function gatedProvider() {
+    let release!: () => void;
+    const gate = new Promise<void>((resolve) => {
+        release = resolve;
+    });
+    const provider = {
+        name: 'mock',
+        apiType: 'openai',
+        async complete() {
+            throw new Error('not used');
+        },
+        async *stream() {
+            yield { type: 'content_block_delta', contentDelta: 'Hello' };
+            await gate;
+            yield { type: 'content_block_delta', contentDelta: ', world' };
+            yield {
+                type: 'message_delta',
+                usage: { promptTokens: 3, completionTokens: 2, totalTokens: 5 },
+            };
+            yield { type: 'done' };
+        },
+    } as any;
+    return { provider, release };
+}
+
+describe('ResponsesHandler stream resume', () => {
+    it('should replay missed events after a mid-stream disconnect and follow live', async () => {
+        const storage = memoryStorage();
+        const replay = new StreamReplayBuffer();
+        const { provider, release } = gatedProvider();
+        const handler = new ResponsesHandler({ storage, provider, replay });
+
+        // Read until the first delta, then drop the connection
+        const first: string[] = [];
+        const stream = handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a');
+        for await (const frame of stream) {
+            first.push(frame);
+            if (frame.includes('response.output_text.delta')) break;
+        }
+
+        const seen = parseFrames(first);
+        expect(seen.map((e) => e.id)).toEqual([1, 2, 3, 4, 5]);
+        const responseId = seen[0]!.data.response.id;
+        const lastEventId = seen[seen.length - 1]!.id!;
+
+        // Upstream is still producing; resume and then let it finish
+        const resumed = await handler.resumeStream(responseId, 'tenant-a', lastEventId);
+        expect(resumed).not.toBeNull();
+        release();
+
+        const rest: string[] = [];
+        for await (const frame of resumed!) {
+            rest.push(frame);
+        }
+
+        const events = parseFrames(rest);
+        expect(events[0]!.id).toBe(lastEventId + 1);
+        expect(events.map((e) => e.event)).toEqual([
+            'response.output_text.delta',
+            'response.output_text.done',
+            'response.content_part.done',
+            'response.output_item.done',
+            'response.completed',
+        ]);
+        expect(events[0]!.data.delta).toBe(', world');
+        expect(rest[rest.length - 1]).toBe(RESPONSES_DONE_FRAME);
+    });
+
+    it('should return the completed response once the buffer has expired', async () => {
+        const storage = memoryStorage();
+        const replay = new StreamReplayBuffer({ gracePeriodMs: 0 });
+        const { provider, release } = gatedProvider();
+        const handler = new ResponsesHandler({ storage, provider, replay });
+        release();
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+        }
+        const responseId = parseFrames(frames)[0]!.data.response.id;
+        await new Promise((resolve) => setTimeout(resolve, 5));
+        expect(replay.size).toBe(0);
+
+        const resumed = await handler.resumeStream(responseId, 'tenant-a', 2);
+        const events: string[] = [];
+        for await (const frame of resumed!) {
+            events.push(frame);
+        }
+
+        const [completed] = parseFrames(events);
+        expect(events).toEqual([expect.stringContaining('event: response.completed'), RESPONSES_DONE_FRAME]);
+        expect(completed!.event).toBe('response.completed');
+        expect(completed!.data.response.output[0].content[0].text).toBe('Hello, world');
+        expect(completed!.data.response.usage.total_tokens).toBe(5);
+    });
+
+    it('should not resume another tenant\'s response', async () => {
+        const storage = memoryStorage();
+        const { provider, release } = gatedProvider();
+        const handler = new ResponsesHandler({ storage, provider, replay: new StreamReplayBuffer() });
+
+        const stream = handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a');
+        const { value } = await stream.next();
+        const responseId = parseFrames([value as string])[0]!.data.response.id;
+
+        expect(await handler.resumeStream(responseId, 'tenant-b', 0)).toBeNull();
+        release();
+        await stream.return(undefined);
+    });
+
+    it('should hold the upstream for a slow client instead of dropping events', async () => {
+        const deltas = Array.from({ length: 20 }, (_, i) => `${i} `);
+        const handler = new ResponsesHandler({
+            storage: memoryStorage(),
+            provider: scriptedProvider(deltas),
+            replay: new StreamReplayBuffer({ maxEvents: 3 }),
+        });
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+            await new Promise((resolve) => setTimeout(resolve, 1));
+        }
+
+        const events = parseFrames(frames);
+        expect(events.map((e) => e.id)).toEqual(Array.from({ length: 28 }, (_, i) => i + 1));
+        expect(events.some((e) => e.event === 'error')).toBe(false);
+        expect(events[events.length - 1]!.data.response.output[0].content[0].text).toBe(deltas.join(''));
+        expect(frames[frames.length - 1]).toBe(RESPONSES_DONE_FRAME);
+    });
+});
+
+/** Provider that streams the given deltas, or fails after them when `fail` is set. */
+function scriptedProvider(deltas: string[], fail?: Error) {
+    return {
+        name: 'mock',
+        apiType: 'openai',
+        async complete() {
+            return {
+                id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'gpt-4o', sourceAPIType: 'openai',
+                choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: deltas.join('') } }],
+                usage: { promptTokens: 3, completionTokens: 2, totalTokens: 5 },
+            };
+        },
+        async *stream() {
+            for (const delta of deltas) {
+                yield { type: 'content_block_delta', contentDelta: delta };
+            }
+            if (fail) throw fail;
+            yield { type: 'message_delta', usage: { promptTokens: 3, completionTokens: 2, totalTokens: 5 } };
+            yield { type: 'done' };
+        },
+    } as any;
+}
+
+/** Replaces generated IDs and timestamps so streams compare byte for byte. */
+function normalize(frames: string[]): string {
+    return frames.join('')
+        .replace(/resp_[0-9a-f]+/g, 'resp_1')
+        .replace(/msg_[0-9a-f]+/g, 'msg_1')
+        .replace(/"created_at":\d+/g, '"created_at":0');
+}
+
+describe('ResponsesHandler SSE sequence', () => {
+    it('should stream the spec event sequence ending in a done sentinel', async () => {
+        const handler = new ResponsesHandler({ storage: memoryStorage(), provider: scriptedProvider(['Hel', 'lo']) });
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+        }
+
+        const message = '{"id":"msg_1","type":"message","status":"completed","role":"assistant","content":[{"type":"output_text","text":"Hello","annotations":[]}]}';
+        const part = '"item_id":"msg_1","output_index":0,"content_index":0';
+        expect(normalize(frames)).toBe([
+            'id: 1\nevent: response.created\ndata: {"type":"response.created","response":{"id":"resp_1","object":"response","created_at":0,"status":"in_progress","model":"gpt-4o","output":[]}}\n\n',
+            'id: 2\nevent: response.in_progress\ndata: {"type":"response.in_progress","response":{"id":"resp_1","object":"response","created_at":0,"status":"in_progress","model":"gpt-4o","output":[]}}\n\n',
+            'id: 3\nevent: response.output_item.added\ndata: {"type":"response.output_item.added","output_index":0,"item":{"id":"msg_1","type":"message","status":"in_progress","role":"assistant","content":[]}}\n\n',
+            `id: 4\nevent: response.content_part.added\ndata: {"type":"response.content_part.added",${part},"part":{"type":"output_text","text":"","annotations":[]}}\n\n`,
+            `id: 5\nevent: response.output_text.delta\ndata: {"type":"response.output_text.delta",${part},"delta":"Hel"}\n\n`,
+            `id: 6\nevent: response.output_text.delta\ndata: {"type":"response.output_text.delta",${part},"delta":"lo"}\n\n`,
+            `id: 7\nevent: response.output_text.done\ndata: {"type":"response.output_text.done",${part},"text":"Hello"}\n\n`,
+            `id: 8\nevent: response.content_part.done\ndata: {"type":"response.content_part.done",${part},"part":{"type":"output_text","text":"Hello","annotations":[]}}\n\n`,
+            `id: 9\nevent: response.output_item.done\ndata: {"type":"response.output_item.done","output_index":0,"item":${message}}\n\n`,
+            `id: 10\nevent: response.completed\ndata: {"type":"response.completed","response":{"id":"resp_1","object":"response","created_at":0,"status":"completed","model":"gpt-4o","output":[${message}],"usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}}\n\n`,
+            'data: [DONE]\n\n',
+        ].join(''));
+    });
+
+    it('should end a failed stream with response.failed and no done sentinel', async () => {
+        const handler = new ResponsesHandler({
+            storage: memoryStorage(),
+            provider: scriptedProvider(['Hel'], new Error('upstream reset')),
+        });
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+        }
+
+        expect(parseFrames(frames).map((e) => e.event)).toEqual([
+            'response.created',
+            'response.in_progress',
+            'response.output_item.added',
+            'response.content_part.added',
+            'response.output_text.delta',
+            'response.failed',
+        ]);
+        expect(frames).not.toContain(RESPONSES_DONE_FRAME);
+        expect(parseFrames(frames).pop()!.data.response).toMatchObject({
+            status: 'failed',
+            error: { type: 'server_error', message: 'upstream reset' },
+        });
+    });
+
+    it('should save the response record and the interaction event for streamed and plain requests', async () => {
+        const storage = memoryStorage();
+        storage.events = [] as any[];
+        storage.saveEvent = async (event: any) => {
+            storage.events.push(event);
+        };
+        const handler = new ResponsesHandler({
+            storage,
+            provider: scriptedProvider(['Hel', 'lo']),
+            interactionId: 'int-1',
+        });
+
+        const plain = await handler.handle({ model: 'gpt-4o', input: 'hi' }, 'tenant-a');
+        for await (const _ of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            // drain
+        }
+
+        expect(storage.responses.size).toBe(2);
+        expect(storage.responses.get(plain.id)).toMatchObject({ status: 'completed', tenantId: 'tenant-a' });
+        expect(storage.events).toEqual([
+            expect.objectContaining({ type: 'response', interactionId: 'int-1', payload: expect.objectContaining({ responseId: plain.id }) }),
+            expect.objectContaining({ type: 'response', interactionId: 'int-1', payload: expect.objectContaining({ status: 'completed' }) }),
+        ]);
+    });
+
+    it('should save the response record alone when the store keeps no interaction events', async () => {
+        const storage = memoryStorage();
+        const handler = new ResponsesHandler({ storage, provider: scriptedProvider(['ok']), interactionId: 'int-1' });
+
+        const response = await handler.handle({ model: 'gpt-4o', input: 'hi' }, 'tenant-a');
+
+        expect(storage.responses.get(response.id)).toMatchObject({ status: 'completed' });
+    });
+});
+
+describe('StreamReplayBuffer', () => {
+    it('should refuse to resume past dropped events', () => {
+        const buffer = new StreamReplayBuffer({ maxEvents: 2 });
+        buffer.open('resp_1', 't');
+        for (let i = 0; i < 5; i++) {
+            buffer.append('resp_1', 'e', { i });
+        }
+
+        expect(buffer.subscribe('resp_1', 't', 1)).toBeUndefined();
+        expect(buffer.subscribe('resp_1', 't', 3)).toBeDefined();
+    });
+
+    it('should end a follow with an error once the buffer overflows past its cursor', async () => {
+        const buffer = new StreamReplayBuffer({ maxEvents: 2 });
+        buffer.open('resp_1', 't');
+        const follow = buffer.subscribe('resp_1', 't', 0)!;
+        buffer.append('resp_1', 'e', { i: 1 });
+
+        expect((await follow.next()).value).toMatchObject({ id: 1 });
+
+        // The reader stalls while events 2-3 are pushed out
+        for (let i = 2; i <= 5; i++) {
+            buffer.append('resp_1', 'e', { i });
+        }
+        buffer.close('resp_1');
+
+        const gap = (await follow.next()).value!;
+        expect(gap).toMatchObject({ id: 0, event: 'error' });
+        expect(JSON.parse(gap.data)).toMatchObject({ type: 'error', code: REPLAY_EVENTS_DROPPED });
+        expect((await follow.next()).done).toBe(true);
+    });
+
+    it('should deliver every event to a reader that keeps up', async () => {
+        const buffer = new StreamReplayBuffer({ maxEvents: 2 });
+        buffer.open('resp_1', 't');
+        const follow = buffer.subscribe('resp_1', 't', 0)!;
+        const ids: number[] = [];
+        const reading = (async () => {
+            for await (const event of follow) ids.push(event.id);
+        })();
+
+        for (let i = 1; i <= 5; i++) {
+            buffer.append('resp_1', 'e', { i });
+            await new Promise((resolve) => setImmediate(resolve));
+        }
+        buffer.close('resp_1');
+        await reading;
+
+        expect(ids).toEqual([1, 2, 3, 4, 5]);
+    });
+
+    it('should hold the producer until a primary reader catches up', async () => {
+        const buffer = new StreamReplayBuffer({ maxEvents: 2 });
+        buffer.open('resp_1', 't');
+        const follow = buffer.subscribe('resp_1', 't', 0, { primary: true })!;
+        buffer.append('resp_1', 'e', { i: 1 });
+        buffer.append('resp_1', 'e', { i: 2 });
+
+        let ready = false;
+        const waiting = buffer.ready('resp_1').then(() => {
+            ready = true;
+        });
+        await new Promise((resolve) => setImmediate(resolve));
+        expect(ready).toBe(false);
+
+        // Taking event 2 means event 1 was read
+        expect((await follow.next()).value).toMatchObject({ id: 1 });
+        expect((await follow.next()).value).toMatchObject({ id: 2 });
+        await waiting;
+        expect(ready).toBe(true);
+
+        // A disconnected reader no longer holds the producer
+        buffer.append('resp_1', 'e', { i: 3 });
+        buffer.append('resp_1', 'e', { i: 4 });
+        await follow.return(undefined);
+        await buffer.ready('resp_1');
+    });
+});
@@ -4,8 +4,17 @@
  * @module responses/handler
  */
 
-import type { CanonicalRequest, CanonicalResponse, Message, ToolDefinition } from '../domain/types.js';
 import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    FinishReason,
+    Message,
+    ToolDefinition,
+    UpstreamHeaderSet,
+    Usage,
+} from '../domain/types.js';
+import type {
+    IncompleteDetails,
     ResponsesAPIRequest,
     ResponsesAPIResponse,
     ResponsesInputItem,
@@ -16,11 +25,20 @@ import type {
     ThreadMessage,
 } from '../domain/responses.js';
 import { responsesInputToMessages } from '../domain/responses.js';
-import type { StorageProvider, ResponseRecord } from '../ports/storage.js';
+import type { InteractionStore, StorageProvider, ResponseRecord, StoredMessage } from '../ports/storage.js';
+import { isChatCompletionRecord } from '../ports/storage.js';
+import type { StreamThrottleConfig } from '../ports/config.js';
 import type { Provider } from '../ports/provider.js';
 import type { Logger } from '../utils/logging.js';
-import { errNotFound, errInvalidRequest } from '../domain/errors.js';
+import { errNotFound, errInvalidRequest, isAPIError } from '../domain/errors.js';
+import { createInteractionEvent } from '../domain/events.js';
 import { randomUUID } from '../utils/crypto.js';
+import { maybeThrottle, describeThrottle } from '../utils/throttle.js';
+import { TimingRecorder, timeStream } from '../utils/timings.js';
+import type { ModelCatalog } from '../domain/catalog.js';
+import type { Conversation } from '../summarization/summarizer.js';
+import { messageContentHash, type AppMessageDedup } from '../messagededup/dedup.js';
+import { StreamReplayBuffer, formatReplayEvent, type ReplayEvent } from './replay.js';
 
 // ============================================================================
 // Handler Options
@@ -38,8 +56,49 @@ export interface ResponsesHandlerOptions {
 
     /** Logger. */
     logger?: Logger | undefined;
+
+    /** Shared replay buffer for resumable streams. */
+    replay?: StreamReplayBuffer | undefined;
+
+    /** Model catalog for capability checks. */
+    catalog?: ModelCatalog | undefined;
+
+    /** Output pacing for streamed responses. */
+    streamThrottle?: StreamThrottleConfig | undefined;
+
+    /** Per-phase timing recorder for the current request. */
+    timings?: TimingRecorder | undefined;
+
+    /** Upstream headers resolved from app and provider header rules. */
+    upstreamHeaders?: UpstreamHeaderSet | undefined;
+
+    /** Reports each completed response's usage (for budget accounting). */
+    onUsage?: ((model: string, usage: Usage) => void) | undefined;
+
+    /** Gateway interaction ID, recorded with each response when the store keeps interaction events. */
+    interactionId?: string | undefined;
+
+    /** Interaction event log (default: the storage provider, when it keeps interaction events). */
+    events?: Pick<InteractionStore, 'saveEvent'> | undefined;
+
+    /** Returns the messages to send for a long reconstructed conversation (thread summaries). */
+    compact?: ((conversation: Conversation) => Promise<Message[]>) | undefined;
+
+    /** The app's duplicate checks for thread appends (message dedup). */
+    messageDedup?: AppMessageDedup | undefined;
+}
+
+/**
+ * A thread being run: its ID and its stored message IDs, in order.
+ */
+export interface ThreadHistory {
+    id: string;
+    messageIds: string[];
 }
 
+/** Sentinel frame sent after `response.completed` or `response.incomplete`. */
+export const RESPONSES_DONE_FRAME = 'data: [DONE]\n\n';
+
 // ============================================================================
 // Responses Handler
 // ============================================================================
@@ -51,20 +110,54 @@ export class ResponsesHandler {
     private readonly storage: StorageProvider;
     private readonly provider: Provider;
     private readonly logger?: Logger;
+    private readonly replay: StreamReplayBuffer;
+    private readonly catalog?: ModelCatalog;
+    private readonly streamThrottle?: StreamThrottleConfig;
+    private readonly timings: TimingRecorder;
+    private readonly upstreamHeaders?: UpstreamHeaderSet;
+    private readonly onUsage?: ((model: string, usage: Usage) => void) | undefined;
+    private readonly interactionId?: string | undefined;
+    private readonly events?: Pick<InteractionStore, 'saveEvent'> | undefined;
+    private readonly compact?: ((conversation: Conversation) => Promise<Message[]>) | undefined;
+    private readonly messageDedup?: AppMessageDedup | undefined;
+
+    /** The canonical request and response of the last non-streaming request handled. */
+    exchange?: { request: CanonicalRequest; response: CanonicalResponse } | undefined;
 
     constructor(options: ResponsesHandlerOptions) {
         this.storage = options.storage;
         this.provider = options.provider;
         this.logger = options.logger;
+        // Without a shared buffer, streams still get IDs but aren't resumable
+        this.replay = options.replay ?? new StreamReplayBuffer({ gracePeriodMs: 0 });
+        this.catalog = options.catalog;
+        this.streamThrottle = options.streamThrottle;
+        this.timings = options.timings ?? new TimingRecorder();
+        this.upstreamHeaders = options.upstreamHeaders;
+        this.onUsage = options.onUsage;
+        this.interactionId = options.interactionId;
+        this.events = options.events ?? (typeof this.storage.saveEvent === 'function' ? this.storage : undefined);
+        this.compact = options.compact;
+        this.messageDedup = options.messageDedup;
     }
 
     /**
-     * Handles a Responses API request.
+     * Rejects requests that use a capability the model lacks, before any
+     * upstream call or stream is started.
+     */
+    checkCapabilities(request: ResponsesAPIRequest, tenantId: string): void {
+        this.catalog?.check(this.toCanonicalRequest(request, tenantId, []), this.logger);
+    }
+
+    /**
+     * Handles a Responses API request. `thread` is set when the request
+     * runs a stored thread.
      */
     async handle(
         request: ResponsesAPIRequest,
         tenantId: string,
         appName?: string,
+        thread?: ThreadHistory,
     ): Promise<ResponsesAPIResponse> {
         const responseId = `resp_${randomUUID().replace(/-/g, '')}`;
         const now = new Date();
@@ -72,7 +165,7 @@ export class ResponsesHandler {
         // Resolve previous response if provided
         let previousMessages: Message[] = [];
         if (request.previousResponseId) {
-            previousMessages = await this.resolvePreviousResponse(request.previousResponseId);
+            previousMessages = await this.resolvePreviousResponse(request.previousResponseId, tenantId);
         }
 
         // Convert request to canonical format
@@ -83,17 +176,24 @@ export class ResponsesHandler {
         );
 
         // Make completion request
-        const canonicalResponse = await this.provider.complete(canonicalRequest);
+        const sent = previousMessages.length > 0 || thread
+            ? await this.compacted(canonicalRequest, tenantId, thread)
+            : canonicalRequest;
+        const canonicalResponse = await this.timings.time('providerTotalMs', () => this.provider.complete(sent));
+        this.onUsage?.(canonicalRequest.model, canonicalResponse.usage);
 
         // Build response items from completion
         const outputItems = this.buildOutputItems(canonicalResponse);
 
+        // Output cut off at max_output_tokens (or filtered) is incomplete
+        const incomplete = incompleteReason(canonicalResponse.choices[0]?.finishReason);
+
         // Create response record
         const response: ResponsesAPIResponse = {
             id: responseId,
             object: 'response',
             createdAt: Math.floor(now.getTime() / 1000),
-            status: 'completed',
+            status: incomplete ? 'incomplete' : 'completed',
             model: canonicalResponse.model,
             output: outputItems,
             usage: {
@@ -102,34 +202,41 @@ export class ResponsesHandler {
                 totalTokens: canonicalResponse.usage.totalTokens,
             },
             metadata: request.metadata,
+            incompleteDetails: incomplete && { reason: incomplete },
         };
 
-        // Store for threading
+        // Store for threading; the raw bodies are recorded as
+        // provider_request and provider_response interaction events instead
+        const { rawResponse: _, rawResponseHeaders: __, providerRequestBody: ___, ...stored } = canonicalResponse;
         const record: ResponseRecord = {
             id: responseId,
             tenantId,
             appName,
             previousResponseId: request.previousResponseId,
             model: request.model,
-            status: 'completed',
+            status: response.status,
             request: canonicalRequest,
-            response: canonicalResponse,
+            response: stored,
             usage: canonicalResponse.usage,
-            metadata: request.metadata ?? {},
+            metadata: canonicalResponse.providerKeyId
+                ? { ...request.metadata, provider_key: canonicalResponse.providerKeyId }
+                : request.metadata ?? {},
+            timings: { ...this.timings.timings },
             createdAt: now,
             updatedAt: now,
         };
 
-        await this.storage.saveResponse(record);
+        await this.persist(record);
+        this.exchange = { request: canonicalRequest, response: stored };
 
         return response;
     }
 
     /**
-     * Gets a response by ID.
+     * Gets a response by ID, scoped to the tenant.
      */
-    async get(responseId: string): Promise<ResponsesAPIResponse | null> {
-        const record = await this.storage.getResponse(responseId);
+    async get(responseId: string, tenantId: string): Promise<ResponsesAPIResponse | null> {
+        const record = await this.findResponse(responseId, tenantId);
         if (!record) return null;
 
         return this.recordToResponse(record);
@@ -143,188 +250,398 @@ export class ResponsesHandler {
         options?: { limit?: number; offset?: number },
     ): Promise<ResponsesAPIResponse[]> {
         const records = await this.storage.listResponses(tenantId, options);
-        return records.map((r) => this.recordToResponse(r));
+        return records.filter((r) => !isChatCompletionRecord(r)).map((r) => this.recordToResponse(r));
     }
 
     /**
      * Handles a streaming Responses API request.
-     * Yields SSE events in the Responses API format.
+     * Yields SSE events in the Responses API format, each with an `id:` so the
+     * client can resume after a disconnect (see resumeStream).
      */
-    async *handleStream(
+    handleStream(
         request: ResponsesAPIRequest,
         tenantId: string,
         appName?: string,
     ): AsyncGenerator<string> {
-        const responseId = `resp_${randomUUID().replace(/-/g, '')}`;
-        const now = new Date();
+        // Keep the request's timings open until the upstream stream is done
+        const release = this.timings.defer();
+        const produce = (responseId: string) => this.produceStream(responseId, request, tenantId, appName).finally(release);
+        const replay = this.replay;
+
+        return (async function* () {
+            const responseId = `resp_${randomUUID().replace(/-/g, '')}`;
+            replay.open(responseId, tenantId);
+            const events = replay.subscribe(responseId, tenantId, 0, { primary: true })!;
+
+            // Produce in the background so the upstream keeps running (and
+            // buffering) if this client disconnects before the stream ends.
+            // While it is connected, the producer waits for it to read.
+            void produce(responseId);
+
+            yield* formatStream(events);
+        })();
+    }
 
-        // Resolve previous response if provided
-        let previousMessages: Message[] = [];
-        if (request.previousResponseId) {
-            previousMessages = await this.resolvePreviousResponse(request.previousResponseId);
+    /**
+     * Resumes a stream after `lastEventId`: replays missed events and then
+     * follows the live stream if the upstream is still producing.
+     * Streams that are no longer buffered are answered with the stored
+     * response as a single terminal event. Returns null if the response
+     * does not exist for the tenant.
+     */
+    async resumeStream(
+        responseId: string,
+        tenantId: string,
+        lastEventId: number,
+    ): Promise<AsyncGenerator<string> | null> {
+        const live = this.replay.subscribe(responseId, tenantId, lastEventId);
+        if (live) {
+            return formatStream(live);
         }
 
-        // Convert request to canonical format with streaming enabled
-        const canonicalRequest = this.toCanonicalRequest(
-            request,
-            tenantId,
-            previousMessages,
-        );
-        canonicalRequest.stream = true;
+        // Buffered but the missed events were dropped: wait for the final state
+        if (this.replay.has(responseId, tenantId)) {
+            await this.replay.waitForClose(responseId);
+        }
 
-        // Emit response.created event
-        yield this.formatSSE('response.created', {
-            type: 'response.created',
-            response: {
-                id: responseId,
-                object: 'response',
-                created_at: Math.floor(now.getTime() / 1000),
-                status: 'in_progress',
-                model: request.model,
-                output: [],
-            },
-        });
+        const record = await this.findResponse(responseId, tenantId);
+        if (!record) {
+            return null;
+        }
 
-        // Emit response.in_progress event
-        yield this.formatSSE('response.in_progress', {
-            type: 'response.in_progress',
-            response: {
-                id: responseId,
-                status: 'in_progress',
-            },
-        });
+        const type = `response.${record.status === 'failed' || record.status === 'incomplete' ? record.status : 'completed'}`;
+        const terminal = this.formatSSE(type, { type, response: this.toStreamResponse(record) });
+        return (async function* () {
+            yield terminal;
+            if (type !== 'response.failed') {
+                yield RESPONSES_DONE_FRAME;
+            }
+        })();
+    }
 
-        // Generate output item ID
-        const outputItemId = `item_${randomUUID().replace(/-/g, '')}`;
-        let outputItemIndex = 0;
+    /**
+     * Runs a streaming request against the provider, appending every event
+     * to the replay buffer. Events follow the Responses API sequence:
+     * response.created, response.in_progress, output item and content part
+     * events around the text deltas, then response.completed (or
+     * response.incomplete when the output was cut off, or response.failed).
+     */
+    private async produceStream(
+        responseId: string,
+        request: ResponsesAPIRequest,
+        tenantId: string,
+        appName?: string,
+    ): Promise<void> {
+        // Waits for the client that started the stream before each event, so
+        // a slow reader slows the upstream instead of losing events
+        const emit = async (eventType: string, data: Record<string, unknown>): Promise<void> => {
+            await this.replay.ready(responseId);
+            this.replay.append(responseId, eventType, { type: eventType, ...data });
+        };
+        const now = new Date();
+        const createdAt = Math.floor(now.getTime() / 1000);
+        const outputItemId = `msg_${randomUUID().replace(/-/g, '')}`;
+        const part = { item_id: outputItemId, output_index: 0, content_index: 0 };
+        let canonicalRequest: CanonicalRequest | undefined;
+        let fullContent = '';
+        let usage: Usage = { promptTokens: 0, completionTokens: 0, totalTokens: 0 };
+        let providerKeyId: string | undefined;
+        let finishReason: FinishReason | undefined;
+        // Upstream tool call index -> function_call output item
+        const calls = new Map<number, StreamedFunctionCall>();
 
-        // Emit output_item.added
-        yield this.formatSSE('response.output_item.added', {
-            type: 'response.output_item.added',
-            output_index: 0,
-            item: {
-                id: outputItemId,
-                type: 'message',
-                status: 'in_progress',
-                role: 'assistant',
-                content: [],
-            },
+        const streamed = (finishReason: FinishReason | null): CanonicalResponse => ({
+            id: responseId,
+            object: 'response',
+            created: createdAt,
+            model: request.model,
+            choices: [{
+                index: 0,
+                message: {
+                    role: 'assistant',
+                    content: fullContent,
+                    toolCalls: calls.size > 0
+                        ? [...calls.values()].map((call) => ({
+                            id: call.callId,
+                            type: 'function' as const,
+                            function: { name: call.name, arguments: call.arguments },
+                        }))
+                        : undefined,
+                },
+                finishReason,
+            }],
+            usage,
+            sourceAPIType: this.provider.apiType,
         });
 
-        // Stream from provider
-        let fullContent = '';
-        let usage = { promptTokens: 0, completionTokens: 0, totalTokens: 0 };
-
         try {
-            for await (const event of this.provider.stream(canonicalRequest)) {
+            // Resolve previous response if provided
+            let previousMessages: Message[] = [];
+            if (request.previousResponseId) {
+                previousMessages = await this.resolvePreviousResponse(request.previousResponseId, tenantId);
+            }
+
+            // Convert request to canonical format with streaming enabled
+            canonicalRequest = this.toCanonicalRequest(
+                request,
+                tenantId,
+                previousMessages,
+            );
+            canonicalRequest.stream = true;
+            const sent = previousMessages.length > 0
+                ? await this.compacted(canonicalRequest, tenantId)
+                : canonicalRequest;
+
+            await emit('response.created', {
+                response: {
+                    id: responseId,
+                    object: 'response',
+                    created_at: createdAt,
+                    status: 'in_progress',
+                    model: request.model,
+                    output: [],
+                },
+            });
+            await emit('response.in_progress', {
+                response: {
+                    id: responseId,
+                    object: 'response',
+                    created_at: createdAt,
+                    status: 'in_progress',
+                    model: request.model,
+                    output: [],
+                },
+            });
+            await emit('response.output_item.added', {
+                output_index: 0,
+                item: {
+                    id: outputItemId,
+                    type: 'message',
+                    status: 'in_progress',
+                    role: 'assistant',
+                    content: [],
+                },
+            });
+            await emit('response.content_part.added', {
+                ...part,
+                part: { type: 'output_text', text: '', annotations: [] },
+            });
+
+            // Stream from provider
+            const upstream = timeStream(this.provider.stream(sent), this.timings);
+            for await (const event of maybeThrottle(upstream, this.streamThrottle)) {
                 if (event.contentDelta) {
                     fullContent += event.contentDelta;
+                    await emit('response.output_text.delta', { ...part, delta: event.contentDelta });
+                }
 
-                    // Emit content delta
-                    yield this.formatSSE('response.output_item.delta', {
-                        type: 'response.output_item.delta',
-                        output_index: 0,
-                        item_id: outputItemId,
-                        delta: {
-                            type: 'text',
-                            text: event.contentDelta,
-                        },
-                    });
+                // Function call arguments are forwarded as they arrive
+                if (event.toolCall) {
+                    const tc = event.toolCall;
+                    let call = calls.get(tc.index);
+                    if (!call) {
+                        call = {
+                            id: `fc_${randomUUID().replace(/-/g, '')}`,
+                            outputIndex: calls.size + 1,
+                            callId: tc.id ?? '',
+                            name: tc.function?.name ?? '',
+                            arguments: '',
+                        };
+                        calls.set(tc.index, call);
+                        await emit('response.output_item.added', {
+                            output_index: call.outputIndex,
+                            item: functionCallItem(call, 'in_progress'),
+                        });
+                    }
+                    const delta = tc.function?.arguments;
+                    if (delta) {
+                        call.arguments += delta;
+                        await emit('response.function_call_arguments.delta', {
+                            item_id: call.id,
+                            output_index: call.outputIndex,
+                            delta,
+                        });
+                    }
                 }
 
                 if (event.usage) {
                     usage = event.usage;
                 }
 
+                if (event.providerKeyId) {
+                    providerKeyId = event.providerKeyId;
+                }
+
+                if (event.finishReason) {
+                    finishReason = event.finishReason as FinishReason;
+                }
+
                 if (event.type === 'done') {
                     break;
                 }
             }
 
-            // Emit output_item.done
-            yield this.formatSSE('response.output_item.done', {
-                type: 'response.output_item.done',
-                output_index: 0,
-                item: {
-                    id: outputItemId,
-                    type: 'message',
-                    status: 'completed',
-                    role: 'assistant',
-                    content: [{
-                        type: 'output_text',
-                        text: fullContent,
-                    }],
-                },
+            // Output cut off at max_output_tokens (or filtered) is incomplete
+            const incomplete = incompleteReason(finishReason);
+            const text = { type: 'output_text', text: fullContent, annotations: [] };
+            const item = {
+                id: outputItemId,
+                type: 'message',
+                status: incomplete ? 'incomplete' : 'completed',
+                role: 'assistant',
+                content: [text],
+            };
+            await emit('response.output_text.done', { ...part, text: fullContent });
+            await emit('response.content_part.done', { ...part, part: text });
+            await emit('response.output_item.done', { output_index: 0, item });
+            const functionCalls: Record<string, unknown>[] = [];
+            for (const call of calls.values()) {
+                const done = functionCallItem(call, 'completed');
+                await emit('response.function_call_arguments.done', {
+                    item_id: call.id,
+                    output_index: call.outputIndex,
+                    arguments: call.arguments,
+                });
+                await emit('response.output_item.done', { output_index: call.outputIndex, item: done });
+                functionCalls.push(done);
+            }
+
+            // Store before completing so a client that follows up with
+            // previous_response_id finds this response
+            await this.persist({
+                id: responseId,
+                tenantId,
+                appName,
+                previousResponseId: request.previousResponseId,
+                model: request.model,
+                status: incomplete ? 'incomplete' : 'completed',
+                request: canonicalRequest,
+                response: streamed(finishReason ?? (calls.size > 0 ? 'tool_calls' : 'stop')),
+                usage,
+                metadata: this.streamMetadata(request, providerKeyId),
+                timings: { ...this.timings.timings },
+                createdAt: now,
+                updatedAt: new Date(),
             });
 
-            // Emit response.completed
-            yield this.formatSSE('response.completed', {
-                type: 'response.completed',
+            await emit(incomplete ? 'response.incomplete' : 'response.completed', {
                 response: {
                     id: responseId,
                     object: 'response',
-                    created_at: Math.floor(now.getTime() / 1000),
-                    status: 'completed',
+                    created_at: createdAt,
+                    status: incomplete ? 'incomplete' : 'completed',
                     model: request.model,
-                    output: [{
-                        id: outputItemId,
-                        type: 'message',
-                        status: 'completed',
-                        role: 'assistant',
-                        content: [{
-                            type: 'output_text',
-                            text: fullContent,
-                        }],
-                    }],
+                    output: [item, ...functionCalls],
                     usage: {
                         input_tokens: usage.promptTokens,
                         output_tokens: usage.completionTokens,
                         total_tokens: usage.totalTokens,
                     },
+                    ...(incomplete && { incomplete_details: { reason: incomplete } }),
                 },
             });
+        } catch (error) {
+            const failure = {
+                type: 'server_error',
+                code: isAPIError(error) ? error.code : undefined,
+                message: error instanceof Error ? error.message : 'Unknown error',
+            };
 
-            // Emit done marker
-            yield this.formatSSE('response.done', {
-                type: 'response.done',
+            await emit('response.failed', {
+                response: {
+                    id: responseId,
+                    object: 'response',
+                    created_at: createdAt,
+                    status: 'failed',
+                    model: request.model,
+                    error: failure,
+                },
+            });
+
+            this.logger?.warn('response_stream_failed', {
+                responseId,
+                code: failure.code,
+                partialChars: fullContent.length,
             });
 
-            // Store for threading
-            const record: ResponseRecord = {
+            // Keep whatever arrived before the failure
+            await this.persist({
                 id: responseId,
                 tenantId,
                 appName,
                 previousResponseId: request.previousResponseId,
                 model: request.model,
-                status: 'completed',
+                status: 'failed',
                 request: canonicalRequest,
-                response: {
-                    id: responseId,
-                    model: request.model,
-                    content: fullContent,
-                },
+                response: streamed(null),
+                error: failure,
                 usage,
-                metadata: request.metadata ?? {},
+                metadata: this.streamMetadata(request, providerKeyId),
+                timings: { ...this.timings.timings },
                 createdAt: now,
                 updatedAt: new Date(),
-            };
+            }).catch((saveError) => {
+                this.logger?.error('response_save_failed', {
+                    responseId,
+                    error: saveError instanceof Error ? saveError.message : String(saveError),
+                });
+            });
+        } finally {
+            // Partial streams are billed for what was generated
+            if (usage.totalTokens > 0) {
+                this.onUsage?.(request.model, usage);
+            }
+            this.replay.close(responseId);
+        }
+    }
 
-            await this.storage.saveResponse(record);
+    /**
+     * Gets a stored Responses API response, scoped to the tenant. Stored
+     * chat completions share the store but aren't responses.
+     */
+    private async findResponse(responseId: string, tenantId: string): Promise<ResponseRecord | null> {
+        const record = await this.storage.getResponse(responseId, tenantId);
+        return record && !isChatCompletionRecord(record) ? record : null;
+    }
 
-        } catch (error) {
-            // Emit error event
-            yield this.formatSSE('response.failed', {
-                type: 'response.failed',
-                response: {
-                    id: responseId,
-                    status: 'failed',
-                    error: {
-                        type: 'server_error',
-                        message: error instanceof Error ? error.message : 'Unknown error',
-                    },
-                },
+    /**
+     * Saves a response record for retrieval and threading, and records it
+     * against the gateway interaction when the store keeps interaction events.
+     */
+    private async persist(record: ResponseRecord): Promise<void> {
+        await this.storage.saveResponse(record);
+
+        if (!this.interactionId || !this.events) {
+            return;
+        }
+        const event = createInteractionEvent(record.status === 'failed' ? 'error' : 'response', this.interactionId, {
+            responseId: record.id,
+            status: record.status,
+            model: record.model,
+            usage: record.usage,
+            error: record.error,
+        });
+        await this.events.saveEvent(event).catch((err: unknown) => {
+            this.logger?.warn('response_event_save_failed', {
+                responseId: record.id,
+                error: err instanceof Error ? err.message : String(err),
             });
+        });
+    }
+
+    /**
+     * Metadata stored with a streamed response, noting any output throttle
+     * and the upstream key that served it.
+     */
+    private streamMetadata(request: ResponsesAPIRequest, providerKeyId: string | undefined): Record<string, string> {
+        const metadata: Record<string, string> = { ...request.metadata };
+        if (this.streamThrottle) {
+            metadata['stream_throttle'] = describeThrottle(this.streamThrottle);
+        }
+        if (providerKeyId) {
+            metadata['provider_key'] = providerKeyId;
         }
+        return metadata;
     }
 
     /**
@@ -338,8 +655,8 @@ export class ResponsesHandler {
     /**
      * Resolves a previous response to get its messages.
      */
-    private async resolvePreviousResponse(responseId: string): Promise<Message[]> {
-        const record = await this.storage.getResponse(responseId);
+    private async resolvePreviousResponse(responseId: string, tenantId: string): Promise<Message[]> {
+        const record = await this.findResponse(responseId, tenantId);
         if (!record) {
             throw errNotFound(`Previous response '${responseId}' not found`);
         }
@@ -361,6 +678,22 @@ export class ResponsesHandler {
         return messages;
     }
 
+    /**
+     * Compacts a reconstructed conversation for sending. The stored request
+     * keeps the whole conversation, so later turns rebuild it in full.
+     */
+    private async compacted(request: CanonicalRequest, tenantId: string, thread?: ThreadHistory): Promise<CanonicalRequest> {
+        if (!this.compact) {
+            return request;
+        }
+        const messages = await this.compact({
+            thread: `${tenantId}/${thread?.id ?? 'responses'}`,
+            request,
+            messageIds: thread?.messageIds,
+        });
+        return messages === request.messages ? request : { ...request, messages };
+    }
+
     /**
      * Converts a Responses API request to canonical format.
      */
@@ -395,6 +728,8 @@ export class ResponsesHandler {
             temperature: request.temperature,
             topP: request.topP,
             metadata: request.metadata,
+            endUserId: request.user,
+            upstreamHeaders: this.upstreamHeaders,
             sourceAPIType: 'responses',
         };
     }
@@ -418,7 +753,7 @@ export class ResponsesHandler {
                     id: `item_${randomUUID().replace(/-/g, '').slice(0, 12)}`,
                     role: 'assistant',
                     content,
-                    status: 'completed',
+                    status: incompleteReason(choice.finishReason) ? 'incomplete' : 'completed',
                 });
             }
 
@@ -440,12 +775,37 @@ export class ResponsesHandler {
         return items;
     }
 
+    /**
+     * Converts a stored record to the wire shape used in stream events.
+     */
+    private toStreamResponse(record: ResponseRecord): Record<string, unknown> {
+        const response = this.recordToResponse(record);
+        return {
+            id: response.id,
+            object: 'response',
+            created_at: response.createdAt,
+            status: response.status,
+            model: response.model,
+            output: response.output,
+            usage: response.usage
+                ? {
+                    input_tokens: response.usage.inputTokens,
+                    output_tokens: response.usage.outputTokens,
+                    total_tokens: response.usage.totalTokens,
+                }
+                : undefined,
+            error: response.error,
+            incomplete_details: response.incompleteDetails,
+        };
+    }
+
     /**
      * Converts a stored record to a Responses API response.
      */
     private recordToResponse(record: ResponseRecord): ResponsesAPIResponse {
         const resp = record.response as CanonicalResponse | undefined;
-        const output = resp ? this.buildOutputItems(resp) : [];
+        // Records saved by older streaming code lack choices
+        const output = resp?.choices ? this.buildOutputItems(resp) : [];
 
         const usage: ResponsesUsage | undefined = record.usage
             ? {
@@ -455,11 +815,15 @@ export class ResponsesHandler {
             }
             : undefined;
 
+        const incomplete = record.status === 'incomplete'
+            ? incompleteReason(resp?.choices?.[0]?.finishReason) ?? 'max_output_tokens'
+            : undefined;
+
         return {
             id: record.id,
             object: 'response',
             createdAt: Math.floor(record.createdAt.getTime() / 1000),
-            status: record.status as 'completed' | 'failed',
+            status: record.status as 'completed' | 'incomplete' | 'failed',
             model: record.model,
             output,
             usage,
@@ -467,6 +831,7 @@ export class ResponsesHandler {
             error: record.error
                 ? { type: 'server_error', message: String(record.error) }
                 : undefined,
+            incompleteDetails: incomplete && { reason: incomplete },
         };
     }
 
@@ -514,8 +879,8 @@ export class ResponsesHandler {
             return null;
         }
 
-        const thread = await this.storage.getThread(threadId);
-        if (!thread || thread.tenantId !== tenantId) {
+        const thread = await this.storage.getThread(threadId, tenantId);
+        if (!thread) {
             return null;
         }
 
@@ -528,37 +893,35 @@ export class ResponsesHandler {
     }
 
     /**
-     * Creates a message in a thread.
+     * Creates a message in a thread. Given a message ID the thread already
+     * has, returns that message's ID without appending again.
      */
     async createMessage(
         threadId: string,
         tenantId: string,
         role: 'user' | 'assistant',
         content: string,
+        messageId?: string,
     ): Promise<ThreadMessage | null> {
         if (!this.storage.getThread || !this.storage.addMessage) {
             return null;
         }
 
-        const thread = await this.storage.getThread(threadId);
-        if (!thread || thread.tenantId !== tenantId) {
+        const thread = await this.storage.getThread(threadId, tenantId);
+        if (!thread) {
             return null;
         }
 
-        const messageId = `msg_${randomUUID().replace(/-/g, '')}`;
         const now = new Date();
-
-        const storedMessage: import('../ports/storage.js').StoredMessage = {
-            id: messageId,
+        const id = await this.appendMessage(threadId, {
+            id: messageId ?? `msg_${randomUUID().replace(/-/g, '')}`,
             role,
             content,
             timestamp: now,
-        };
-
-        await this.storage.addMessage(threadId, storedMessage);
+        });
 
         return {
-            id: messageId,
+            id,
             object: 'thread.message',
             threadId,
             createdAt: Math.floor(now.getTime() / 1000),
@@ -568,19 +931,19 @@ export class ResponsesHandler {
     }
 
     /**
-     * Lists messages in a thread.
+     * Lists messages in a thread, or null if the thread is not found.
      */
     async listMessages(
         threadId: string,
         tenantId: string,
-    ): Promise<ThreadMessage[]> {
+    ): Promise<ThreadMessage[] | null> {
         if (!this.storage.getThread || !this.storage.listMessages) {
             return [];
         }
 
-        const thread = await this.storage.getThread(threadId);
-        if (!thread || thread.tenantId !== tenantId) {
-            return [];
+        const thread = await this.storage.getThread(threadId, tenantId);
+        if (!thread) {
+            return null;
         }
 
         const messages = await this.storage.listMessages(threadId);
@@ -595,19 +958,21 @@ export class ResponsesHandler {
     }
 
     /**
-     * Creates a run (executes the thread through the provider).
+     * Creates a run (executes the thread through the provider). The reply
+     * is appended to the thread as `messageId` when given, so a retried
+     * run doesn't append it twice.
      */
     async createRun(
         threadId: string,
         tenantId: string,
-        options: { model?: string; instructions?: string } = {},
+        options: { model?: string | undefined; instructions?: string | undefined; messageId?: string | undefined } = {},
     ): Promise<ResponsesAPIResponse | null> {
         if (!this.storage.getThread || !this.storage.listMessages) {
             return null;
         }
 
-        const thread = await this.storage.getThread(threadId);
-        if (!thread || thread.tenantId !== tenantId) {
+        const thread = await this.storage.getThread(threadId, tenantId);
+        if (!thread) {
             return null;
         }
 
@@ -629,10 +994,13 @@ export class ResponsesHandler {
         };
 
         // Execute as a normal response
-        const response = await this.handle(request, tenantId);
+        const response = await this.handle(request, tenantId, undefined, {
+            id: threadId,
+            messageIds: messages.map((m) => m.id),
+        });
 
-        // Add assistant message to thread if successful
-        if (response.status === 'completed' && this.storage.addMessage) {
+        // Add assistant message to thread if successful, even if cut off
+        if ((response.status === 'completed' || response.status === 'incomplete') && this.storage.addMessage) {
             const content = response.output
                 .filter((o) => o.type === 'message')
                 .flatMap((o) => o.content ?? [])
@@ -641,8 +1009,8 @@ export class ResponsesHandler {
                 .join('');
 
             if (content) {
-                await this.storage.addMessage(threadId, {
-                    id: `msg_${randomUUID().replace(/-/g, '')}`,
+                await this.appendMessage(threadId, {
+                    id: options.messageId ?? `msg_${randomUUID().replace(/-/g, '')}`,
                     role: 'assistant',
                     content,
                     timestamp: new Date(),
@@ -653,6 +1021,30 @@ export class ResponsesHandler {
         return response;
     }
 
+    /**
+     * Appends a message to a thread unless it duplicates one there (see
+     * messagededup), and returns the ID it is stored under.
+     */
+    private async appendMessage(threadId: string, message: StoredMessage): Promise<string> {
+        message.contentHash = await messageContentHash(message.role, message.content);
+        // Only replies are checked by content; users may well say the same thing twice
+        const dedupWindow = message.role === 'assistant' ? this.messageDedup?.window : undefined;
+
+        const result = await this.storage.addMessage!(threadId, message, { dedupWindow });
+        if (!result) {
+            throw errInvalidRequest(`Message ID '${message.id}' is used by another thread`).withStatusCode(409);
+        }
+        if (result.duplicate) {
+            this.messageDedup?.onDuplicate(result.duplicate);
+            this.logger?.debug('thread_message_duplicate', {
+                threadId,
+                messageId: result.id,
+                reason: result.duplicate,
+            });
+        }
+        return result.id;
+    }
+
     /**
      * Cancels a response.
      */
@@ -660,8 +1052,8 @@ export class ResponsesHandler {
         responseId: string,
         tenantId: string,
     ): Promise<ResponsesAPIResponse | null> {
-        const record = await this.storage.getResponse(responseId);
-        if (!record || record.tenantId !== tenantId) {
+        const record = await this.findResponse(responseId, tenantId);
+        if (!record) {
             return null;
         }
 
@@ -684,3 +1076,64 @@ export class ResponsesHandler {
         return this.recordToResponse(record);
     }
 }
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/** A function call being assembled from a stream. */
+interface StreamedFunctionCall {
+    /** Output item ID. */
+    id: string;
+
+    /** Position in the response output (the message is at 0). */
+    outputIndex: number;
+
+    /** Upstream tool call ID. */
+    callId: string;
+
+    /** Function name. */
+    name: string;
+
+    /** Arguments received so far. */
+    arguments: string;
+}
+
+/**
+ * Formats a streamed function call as a Responses API output item.
+ */
+function functionCallItem(call: StreamedFunctionCall, status: 'in_progress' | 'completed'): Record<string, unknown> {
+    return {
+        id: call.id,
+        type: 'function_call',
+        status,
+        call_id: call.callId,
+        name: call.name,
+        arguments: status === 'completed' ? call.arguments : '',
+    };
+}
+
+/**
+ * Why a response stopped short of finishing, per its finish reason: it
+ * hit the output token cap, or was filtered. Undefined otherwise.
+ */
+function incompleteReason(finishReason: string | null | undefined): IncompleteDetails['reason'] | undefined {
+    if (finishReason === 'length') return 'max_output_tokens';
+    if (finishReason === 'content_filter') return 'content_filter';
+    return undefined;
+}
+
+/**
+ * Formats replayed events as SSE frames, ending with the done sentinel
+ * once the response completes (cut off or not).
+ */
+async function* formatStream(events: AsyncIterable<ReplayEvent>): AsyncGenerator<string> {
+    let completed = false;
+    for await (const event of events) {
+        completed = event.event === 'response.completed' || event.event === 'response.incomplete';
+        yield formatReplayEvent(event);
+    }
+    if (completed) {
+        yield RESPONSES_DONE_FRAME;
+    }
+}
@@ -4,4 +4,15 @@
  * @module responses
  */
 
-export { ResponsesHandler, type ResponsesHandlerOptions } from './handler.js';
+export { ResponsesHandler, RESPONSES_DONE_FRAME, type ResponsesHandlerOptions } from './handler.js';
+export {
+    StreamReplayBuffer,
+    formatReplayEvent,
+    DEFAULT_REPLAY_MAX_EVENTS,
+    DEFAULT_REPLAY_GRACE_MS,
+    DEFAULT_REPLAY_MAX_STREAMS,
+    REPLAY_EVENTS_DROPPED,
+    type ReplayBufferOptions,
+    type ReplayEvent,
+    type ReplaySubscribeOptions,
+} from './replay.js';
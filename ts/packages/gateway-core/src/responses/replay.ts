@@ -0,0 +1,350 @@
+/**
+ * Replay buffer for resumable Responses API streams.
+ *
+ * Every SSE event emitted on a Responses stream gets a monotonically
+ * increasing `id:`. Events are buffered per response while the stream is
+ * active and for a grace period after it ends, so a client that lost its
+ * connection can reconnect with Last-Event-ID, receive the events it missed,
+ * and continue following the live stream. The client that started the
+ * stream paces its producer (see ready), so only resumed readers can fall
+ * behind dropped events.
+ *
+ * @module responses/replay
+ */
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default number of events retained per response. */
+export const DEFAULT_REPLAY_MAX_EVENTS = 2048;
+
+/** Default time a finished stream stays resumable (ms). */
+export const DEFAULT_REPLAY_GRACE_MS = 60_000;
+
+/** Default number of streams buffered at once. */
+export const DEFAULT_REPLAY_MAX_STREAMS = 1024;
+
+/** Error code sent when a reader fell behind events the buffer dropped. */
+export const REPLAY_EVENTS_DROPPED = 'stream_events_dropped';
+
+/**
+ * Options for the replay buffer.
+ */
+export interface ReplayBufferOptions {
+    /** Events retained per response; older events are dropped first. */
+    maxEvents?: number | undefined;
+
+    /** How long a finished stream remains resumable (ms). */
+    gracePeriodMs?: number | undefined;
+
+    /** Maximum concurrently buffered streams; the oldest is evicted first. */
+    maxStreams?: number | undefined;
+}
+
+/**
+ * A buffered SSE event.
+ */
+export interface ReplayEvent {
+    /** SSE event ID (1-based, per response). */
+    id: number;
+
+    /** SSE event name. */
+    event: string;
+
+    /** Serialized JSON payload. */
+    data: string;
+}
+
+/**
+ * Options for subscribing to a stream.
+ */
+export interface ReplaySubscribeOptions {
+    /** The client that started the stream; its producer waits for it (see ready). */
+    primary?: boolean | undefined;
+}
+
+interface ReplayStream {
+    tenantId: string;
+    events: ReplayEvent[];
+    nextId: number;
+    closed: boolean;
+    /** Last event ID the primary reader took, while it is attached. */
+    primary?: { cursor: number } | undefined;
+    /** Wakes subscribers waiting for new events. */
+    notify: () => void;
+    changed: Promise<void>;
+    expiry?: ReturnType<typeof setTimeout> | undefined;
+}
+
+// ============================================================================
+// Stream Replay Buffer
+// ============================================================================
+
+/**
+ * Bounded in-memory buffer of Responses stream events keyed by response ID.
+ */
+export class StreamReplayBuffer {
+    private readonly maxEvents: number;
+    private readonly gracePeriodMs: number;
+    private readonly maxStreams: number;
+    private readonly streams = new Map<string, ReplayStream>();
+
+    constructor(options: ReplayBufferOptions = {}) {
+        this.maxEvents = options.maxEvents ?? DEFAULT_REPLAY_MAX_EVENTS;
+        this.gracePeriodMs = options.gracePeriodMs ?? DEFAULT_REPLAY_GRACE_MS;
+        this.maxStreams = options.maxStreams ?? DEFAULT_REPLAY_MAX_STREAMS;
+    }
+
+    /**
+     * Starts buffering a stream.
+     */
+    open(responseId: string, tenantId: string): void {
+        this.evict(responseId);
+        while (this.streams.size >= this.maxStreams) {
+            const oldest = this.streams.keys().next().value;
+            if (oldest === undefined) break;
+            this.evict(oldest);
+        }
+
+        const stream: ReplayStream = {
+            tenantId,
+            events: [],
+            nextId: 1,
+            closed: false,
+            notify: () => undefined,
+            changed: Promise.resolve(),
+        };
+        resetSignal(stream);
+        this.streams.set(responseId, stream);
+    }
+
+    /**
+     * Appends an event and returns it with its assigned ID.
+     * Events for unknown or closed streams are assigned IDs but not buffered.
+     */
+    append(responseId: string, event: string, data: unknown): ReplayEvent {
+        const stream = this.streams.get(responseId);
+        const entry: ReplayEvent = {
+            id: stream ? stream.nextId++ : 0,
+            event,
+            data: JSON.stringify(data),
+        };
+        if (!stream || stream.closed) {
+            return entry;
+        }
+
+        stream.events.push(entry);
+        if (stream.events.length > this.maxEvents) {
+            stream.events.shift();
+        }
+        signal(stream);
+        return entry;
+    }
+
+    /**
+     * Marks a stream finished. It stays resumable for the grace period.
+     */
+    close(responseId: string): void {
+        const stream = this.streams.get(responseId);
+        if (!stream || stream.closed) return;
+
+        stream.closed = true;
+        signal(stream);
+        stream.expiry = setTimeout(() => this.evict(responseId), this.gracePeriodMs);
+        // Don't hold the process open for buffer cleanup
+        (stream.expiry as { unref?: () => void }).unref?.();
+    }
+
+    /**
+     * Whether a stream is buffered for the tenant.
+     */
+    has(responseId: string, tenantId: string): boolean {
+        return this.streams.get(responseId)?.tenantId === tenantId;
+    }
+
+    /**
+     * Whether the upstream for a buffered stream is still producing.
+     */
+    isActive(responseId: string): boolean {
+        const stream = this.streams.get(responseId);
+        return stream !== undefined && !stream.closed;
+    }
+
+    /**
+     * Subscribes to events after `lastEventId`, replaying buffered events and
+     * then following the live stream until it closes. A subscriber that
+     * falls behind events dropped while it follows gets an `error` event
+     * (code stream_events_dropped) and no more; a primary subscriber holds
+     * the producer back instead (see ready).
+     * Returns undefined when the stream is not buffered for the tenant or the
+     * requested events have already been dropped.
+     */
+    subscribe(
+        responseId: string,
+        tenantId: string,
+        lastEventId: number,
+        options: ReplaySubscribeOptions = {},
+    ): AsyncGenerator<ReplayEvent, void, void> | undefined {
+        const stream = this.streams.get(responseId);
+        if (!stream || stream.tenantId !== tenantId) {
+            return undefined;
+        }
+
+        const oldest = stream.events[0]?.id ?? stream.nextId;
+        if (lastEventId + 1 < oldest) {
+            return undefined;
+        }
+
+        if (!options.primary) {
+            return follow(stream, lastEventId);
+        }
+        const reader = { cursor: lastEventId };
+        stream.primary = reader;
+        return followPrimary(stream, reader);
+    }
+
+    /**
+     * Resolves once the stream can take another event without dropping one
+     * its primary reader hasn't taken yet. Resolves at once when no primary
+     * reader is attached (it finished or disconnected), so the producer keeps
+     * buffering for resumes.
+     */
+    async ready(responseId: string): Promise<void> {
+        for (;;) {
+            const stream = this.streams.get(responseId);
+            if (!stream || stream.closed || !stream.primary) return;
+            if (stream.nextId - 1 - stream.primary.cursor < this.maxEvents) return;
+            await stream.changed;
+        }
+    }
+
+    /**
+     * Resolves once the stream closes or is evicted.
+     */
+    async waitForClose(responseId: string): Promise<void> {
+        for (;;) {
+            const stream = this.streams.get(responseId);
+            if (!stream || stream.closed) return;
+            await stream.changed;
+        }
+    }
+
+    /**
+     * Number of buffered streams.
+     */
+    get size(): number {
+        return this.streams.size;
+    }
+
+    /**
+     * Drops all buffered streams.
+     */
+    clear(): void {
+        for (const id of [...this.streams.keys()]) {
+            this.evict(id);
+        }
+    }
+
+    private evict(responseId: string): void {
+        const stream = this.streams.get(responseId);
+        if (!stream) return;
+
+        clearTimeout(stream.expiry);
+        this.streams.delete(responseId);
+        // Release any subscribers still waiting on this stream
+        stream.closed = true;
+        signal(stream);
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+async function* follow(
+    stream: ReplayStream,
+    lastEventId: number,
+): AsyncGenerator<ReplayEvent, void, void> {
+    let cursor = lastEventId;
+    for (;;) {
+        const changed = stream.changed;
+        // Buffered IDs are consecutive, so the next event's index follows
+        // from the oldest one still kept
+        for (;;) {
+            const oldest = stream.events[0]?.id;
+            if (oldest === undefined) break;
+            if (oldest > cursor + 1) {
+                yield droppedEvent(cursor, oldest);
+                return;
+            }
+            const event = stream.events[cursor + 1 - oldest];
+            if (!event) break;
+            cursor = event.id;
+            yield event;
+        }
+        if (stream.closed) return;
+        await changed;
+    }
+}
+
+/**
+ * Follows the stream for its primary reader, recording how far it has read
+ * and detaching once it stops.
+ */
+async function* followPrimary(
+    stream: ReplayStream,
+    reader: { cursor: number },
+): AsyncGenerator<ReplayEvent, void, void> {
+    try {
+        for await (const event of follow(stream, reader.cursor)) {
+            yield event;
+            // The reader came back for more, so it has taken this event
+            if (event.id > 0) {
+                reader.cursor = event.id;
+                signal(stream);
+            }
+        }
+    } finally {
+        if (stream.primary === reader) {
+            stream.primary = undefined;
+            signal(stream);
+        }
+    }
+}
+
+/**
+ * The event ending a follow whose next events were dropped. It has no ID,
+ * so a client can't resume past it.
+ */
+function droppedEvent(cursor: number, oldest: number): ReplayEvent {
+    return {
+        id: 0,
+        event: 'error',
+        data: JSON.stringify({
+            type: 'error',
+            code: REPLAY_EVENTS_DROPPED,
+            message: `Stream events ${cursor + 1}-${oldest - 1} were dropped before they could be sent`,
+        }),
+    };
+}
+
+function signal(stream: ReplayStream): void {
+    const notify = stream.notify;
+    resetSignal(stream);
+    notify();
+}
+
+function resetSignal(stream: ReplayStream): void {
+    stream.changed = new Promise<void>((resolve) => {
+        stream.notify = resolve;
+    });
+}
+
+/**
+ * Formats a buffered event as an SSE frame.
+ */
+export function formatReplayEvent(event: ReplayEvent): string {
+    const id = event.id > 0 ? `id: ${event.id}\n` : '';
+    return `${id}event: ${event.event}\ndata: ${event.data}\n\n`;
+}
@@ -1,7 +1,11 @@
-import { describe, it, expect } from 'vitest';
-import { Router } from './router';
-import type { AppConfig } from './ports/config';
+import { describe, it, expect, afterEach } from 'vitest';
+import { Router, selectionOf, type RoutingDecision } from './router';
+import { AdminHandler } from './admin/index';
+import { ModelCatalog } from './domain/catalog';
+import { ModelListCache } from './providers/models';
+import type { AppConfig, RoutingConfig } from './ports/config';
 import type { Frontdoor, FrontdoorContext, FrontdoorResponse } from './frontdoors/types';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
 
 // Mock frontdoor for testing
 class MockFrontdoor implements Frontdoor {
@@ -46,6 +50,20 @@ describe('Router', () => {
             expect(router.matchApp('/api/v2/chat')?.name).toBe('short');
         });
 
+        it('should pick among apps sharing a path by the key\'s binding', () => {
+            const router = new Router();
+
+            router.addApp({ name: 'internal', frontdoor: 'openai', path: '/v1' });
+            router.addApp({ name: 'partner', frontdoor: 'openai', path: '/v1/' });
+            router.addApp({ name: 'batch', frontdoor: 'openai', path: '/v1/batch' });
+
+            expect(router.matchApp('/v1/chat/completions')?.name).toBe('internal');
+            expect(router.matchApp('/v1/chat/completions', 'partner')?.name).toBe('partner');
+            expect(router.matchApp('/v1/chat/completions', 'unknown')?.name).toBe('internal');
+            // A longer path still wins over the binding
+            expect(router.matchApp('/v1/batch/jobs', 'partner')?.name).toBe('batch');
+        });
+
         it('should return undefined for unmatched paths', () => {
             const router = new Router();
             router.addApp({ name: 'test', frontdoor: 'openai', path: '/v1' });
@@ -157,4 +175,231 @@ describe('Router', () => {
             expect(selection.rewriteResponseModel).toBe(true);
         });
     });
+
+    describe('selectProvider with cached model lists', () => {
+        async function routerWithLists() {
+            const modelLists = new ModelListCache();
+            await modelLists.get('openai', 60_000, async () => ({ object: 'list', data: [{ id: 'gpt-4o' }] }));
+            await modelLists.get('local', 60_000, async () => ({ object: 'list', data: [{ id: 'llama-3' }] }));
+            return new Router({ defaultRouting: { defaultProvider: 'openai' }, modelLists });
+        }
+
+        it('should send unrouted models to the provider that lists them', async () => {
+            const router = await routerWithLists();
+
+            expect(router.selectProvider('gpt-4o').providerName).toBe('openai');
+            expect(router.selectProvider('llama-3').providerName).toBe('local');
+        });
+
+        it('should reject a model no provider lists with model_not_found', async () => {
+            const router = await routerWithLists();
+
+            expect(() => router.selectProvider('gpt-9')).toThrow(expect.objectContaining({
+                statusCode: 404,
+                code: 'model_not_found',
+                message: 'The model `gpt-9` does not exist or you do not have access to it.',
+            }));
+        });
+
+        it('should keep routing rules, app models, and uncached defaults ahead of the check', async () => {
+            const router = await routerWithLists();
+            const app: AppConfig = { name: 'a', frontdoor: 'openai', path: '/v1', models: [{ id: 'private-ft' }] };
+            const uncached = new Router({ defaultRouting: { defaultProvider: 'other' }, modelLists: new ModelListCache() });
+
+            expect(router.selectProvider('private-ft', app).providerName).toBe('openai');
+            expect(router.selectProvider('').providerName).toBe('openai');
+            expect(uncached.selectProvider('gpt-9').providerName).toBe('other');
+        });
+    });
+});
+
+const apps: Record<string, AppConfig> = {
+    chat: {
+        name: 'chat',
+        frontdoor: 'openai',
+        path: '/v1',
+        modelRouting: {
+            prefixProviders: { 'claude-': 'anthropic', 'gemini-': 'google' },
+            rewrites: [
+                { modelExact: 'fast', model: 'gpt-4o-mini', provider: 'openai' },
+                { modelPrefix: 'legacy-', model: 'gpt-4o', provider: 'azure', rewriteResponseModel: true },
+            ],
+        },
+    },
+    pinned: { name: 'pinned', frontdoor: 'openai', path: '/pinned', provider: 'anthropic' },
+    catchall: {
+        name: 'catchall',
+        frontdoor: 'openai',
+        path: '/catchall',
+        modelRouting: { fallback: { provider: 'local', model: 'llama-3' } },
+    },
+};
+
+const globalRouting: RoutingConfig = {
+    rules: [
+        { modelPrefix: 'mistral-', provider: 'mistral' },
+        { modelExact: 'o1', provider: 'reasoning' },
+    ],
+    defaultProvider: 'openai',
+};
+
+const tenants: Record<string, RoutingConfig> = {
+    acme: { rules: [{ modelPrefix: 'gpt-', provider: 'azure' }], defaultProvider: 'azure' },
+};
+
+async function fixtureRouter(): Promise<Router> {
+    const modelLists = new ModelListCache();
+    await modelLists.get('openai', 60_000, async () => ({ object: 'list', data: [{ id: 'gpt-4o' }] }));
+    await modelLists.get('local', 60_000, async () => ({ object: 'list', data: [{ id: 'llama-3' }] }));
+    return new Router({
+        defaultRouting: globalRouting,
+        catalog: new ModelCatalog([{ id: 'house-model', provider: 'internal' }], { builtins: false }),
+        modelLists,
+        migratedModels: new Map([['gpt-4', 'gpt-4o'], ['fast-old', 'fast']]),
+    });
+}
+
+type Case = [name: string, app: string | undefined, tenant: string | undefined, model: string, expected: Partial<RoutingDecision>];
+
+const cases: Case[] = [
+    ['app prefix provider', 'chat', undefined, 'claude-sonnet-4',
+        { provider: 'anthropic', model: 'claude-sonnet-4', match: { type: 'prefix_provider', index: 0, pattern: 'claude-' } }],
+    ['second prefix provider', 'chat', undefined, 'gemini-pro',
+        { provider: 'google', model: 'gemini-pro', match: { type: 'prefix_provider', index: 1, pattern: 'gemini-' } }],
+    ['exact rewrite', 'chat', undefined, 'fast',
+        { provider: 'openai', model: 'gpt-4o-mini', rewrite: 'gpt-4o-mini', match: { type: 'rewrite', index: 0, pattern: 'fast' } }],
+    ['prefix rewrite', 'chat', undefined, 'legacy-davinci',
+        { provider: 'azure', model: 'gpt-4o', rewrite: 'gpt-4o', rewriteResponseModel: true, match: { type: 'rewrite', index: 1, pattern: 'legacy-' } }],
+    ['app routing falls through to global rules', 'chat', undefined, 'mistral-large',
+        { provider: 'mistral', model: 'mistral-large', match: { type: 'rule', index: 0, pattern: 'mistral-', source: 'global' } }],
+    ['forced app provider beats everything', 'pinned', 'acme', 'gpt-4o',
+        { provider: 'anthropic', model: 'gpt-4o', match: { type: 'app_provider' } }],
+    ['app fallback', 'catchall', undefined, 'anything',
+        { provider: 'local', model: 'llama-3', rewrite: 'llama-3', match: { type: 'model_fallback' } }],
+    ['exact global rule', undefined, undefined, 'o1',
+        { provider: 'reasoning', model: 'o1', match: { type: 'rule', index: 1, pattern: 'o1', source: 'global' } }],
+    ['tenant rule', undefined, 'acme', 'gpt-4o',
+        { provider: 'azure', model: 'gpt-4o', match: { type: 'rule', index: 0, pattern: 'gpt-', source: 'tenant' } }],
+    ['tenant rules replace global rules', undefined, 'acme', 'mistral-large',
+        { provider: 'azure', model: 'mistral-large', match: { type: 'default', source: 'tenant' } }],
+    ['catalog', undefined, undefined, 'house-model',
+        { provider: 'internal', model: 'house-model', match: { type: 'catalog' } }],
+    ['default provider', undefined, undefined, 'gpt-4o',
+        { provider: 'openai', model: 'gpt-4o', match: { type: 'default', source: 'global' } }],
+    ['provider whose model list has it', undefined, undefined, 'llama-3',
+        { provider: 'local', model: 'llama-3', match: { type: 'model_list' } }],
+    ['migration ahead of routing', undefined, undefined, 'gpt-4',
+        { requestedModel: 'gpt-4', migratedTo: 'gpt-4o', provider: 'openai', model: 'gpt-4o', match: { type: 'default', source: 'global' } }],
+    ['migration then rewrite', 'chat', undefined, 'fast-old',
+        { requestedModel: 'fast-old', migratedTo: 'fast', provider: 'openai', model: 'gpt-4o-mini', rewrite: 'gpt-4o-mini' }],
+];
+
+describe('Routing explanations', () => {
+    it.each(cases)('%s', async (_, app, tenant, model, expected) => {
+        const r = await fixtureRouter();
+
+        const decision = r.explain(model, app ? apps[app] : undefined, undefined, tenant ? tenants[tenant] : undefined);
+
+        expect(decision).toMatchObject({ requestedModel: expected.requestedModel ?? model, ...expected });
+        expect(r.selectProvider(model, app ? apps[app] : undefined, undefined, tenant ? tenants[tenant] : undefined))
+            .toEqual(selectionOf(decision));
+    });
+
+    it('should explain a model nothing serves as model_not_found', async () => {
+        const r = await fixtureRouter();
+
+        expect(() => r.explain('gpt-9')).toThrow(/gpt-9/);
+    });
+});
+
+const explained = {
+    requestedModel: 'claude-sonnet-4',
+    match: { type: 'prefix_provider', index: 0, pattern: 'claude-' },
+    provider: 'anthropic',
+    model: 'claude-sonnet-4',
+};
+
+describe('X-Gateway-Explain', () => {
+    const gateways: TestGateway[] = [];
+
+    afterEach(async () => {
+        await Promise.all(gateways.splice(0).map((gw) => gw.close()));
+    });
+
+    async function setup(explainRouting?: boolean) {
+        const logger = spyLogger();
+        const gw = await harness()
+            .app({ ...apps.chat!, explainRouting })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .config({
+                providers: [{ name: 'anthropic', type: 'mock', apiKey: 'sk-mock' }],
+                routing: { defaultProvider: 'anthropic' },
+            })
+            .options({
+                auth: {
+                    authenticate: async (token: string) => ({ tenantId: 'acme', scopes: token === 'admin' ? ['admin'] : ['chat'], metadata: {} }),
+                    getTenant: async () => null,
+                },
+                logger,
+            })
+            .start();
+        gateways.push(gw);
+        const send = (token: string, headers: Record<string, string> = {}) => gw.post(
+            '/v1/chat/completions',
+            { model: 'claude-sonnet-4', messages: [{ role: 'user', content: 'Hi' }] },
+            { Authorization: `Bearer ${token}`, ...headers },
+        );
+        const steps = () => logged(logger.info, 'interaction_transformation').filter((fields) => fields.stage === 'routing');
+        return { gateway: gw.gateway, send, steps };
+    }
+
+    it('should record every decision on the interaction', async () => {
+        const { send, steps } = await setup();
+
+        const response = await send('user');
+
+        expect(response.headers.has('X-Gateway-Routing')).toBe(false);
+        expect(await response.json()).not.toHaveProperty('x_gateway_routing');
+        expect(steps()).toEqual([expect.objectContaining({
+            description: "Routed model 'claude-sonnet-4' to provider 'anthropic' as 'claude-sonnet-4' by prefix provider 0",
+            details: explained,
+        })]);
+    });
+
+    it('should return the decision to admin-scoped keys that ask', async () => {
+        const { send } = await setup();
+
+        const response = await send('admin', { 'X-Gateway-Explain': 'true' });
+
+        expect(JSON.parse(response.headers.get('X-Gateway-Routing')!)).toEqual(explained);
+        expect((await response.json()).x_gateway_routing).toEqual(explained);
+    });
+
+    it('should refuse other keys unless the app allows explanations', async () => {
+        const denied = await (await setup()).send('user', { 'X-Gateway-Explain': 'true' });
+
+        expect(denied.headers.has('X-Gateway-Routing')).toBe(false);
+        expect(JSON.parse(denied.headers.get('X-Gateway-Warnings')!)).toEqual([
+            { code: 'explain', message: 'X-Gateway-Explain requires an admin-scoped key for this app' },
+        ]);
+
+        const allowed = await (await setup(true)).send('user', { 'X-Gateway-Explain': 'true' });
+        expect(JSON.parse(allowed.headers.get('X-Gateway-Routing')!)).toEqual(explained);
+    });
+
+    it('should dry-run routing from the admin API', async () => {
+        const { gateway } = await setup();
+        const admin = new AdminHandler({ routing: (query) => gateway.explainRouting(query) });
+        const test = async (query: string) => admin.handle(new Request(`http://localhost/api/routing/test?${query}`));
+
+        const response = await test('model=legacy-davinci&app=chat&tenant=acme');
+
+        expect(response.status).toBe(200);
+        expect((await response.json()).decision).toMatchObject({
+            provider: 'azure',
+            model: 'gpt-4o',
+            match: { type: 'rewrite', index: 1, pattern: 'legacy-' },
+        });
+        expect((await test('model=gpt-4o&app=missing')).status).toBe(404);
+    });
 });
@@ -1,12 +1,35 @@
 /**
  * Request router for the gateway.
  *
+ * Every provider selection can be explained: Router.explain returns a
+ * RoutingDecision naming what matched (which rule, of which kind, from
+ * which config), any model migration or rewrite, and the provider and
+ * model chosen. The gateway adds thread affinity, records the decision on
+ * the interaction, and returns it to clients that ask.
+ *
  * @module router
  */
 
 import type { AppConfig, RoutingConfig, RoutingRule, ModelRoutingConfig } from './ports/config.js';
 import type { Provider, ProviderFactoryConfig } from './ports/provider.js';
 import type { Frontdoor } from './frontdoors/types.js';
+import type { TransformationStep } from './recorder/interaction.js';
+import { ModelCatalog } from './domain/catalog.js';
+import { errModelNotFound } from './domain/errors.js';
+import type { ModelListCache } from './providers/models.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Request header asking for the routing decision (`true`). */
+export const EXPLAIN_HEADER = 'X-Gateway-Explain';
+
+/** Response header carrying the routing decision. */
+export const ROUTING_HEADER = 'X-Gateway-Routing';
+
+/** Vendor-extension key the routing decision is embedded under in JSON bodies. */
+export const ROUTING_BODY_KEY = 'x_gateway_routing';
 
 // ============================================================================
 // Route Types
@@ -46,6 +69,97 @@ export interface ProviderSelection {
     rewriteResponseModel?: boolean | undefined;
 }
 
+/**
+ * What selected a provider, in the order the router checks: the app's
+ * forced provider, its model routing (prefix providers, rewrites, then
+ * fallback), the tenant's or global routing rules, the model catalog, and
+ * the default provider (or a provider whose cached model list has the
+ * model, when the default's doesn't).
+ */
+export type RoutingMatchType =
+    | 'app_provider'
+    | 'prefix_provider'
+    | 'rewrite'
+    | 'model_fallback'
+    | 'rule'
+    | 'catalog'
+    | 'default'
+    | 'model_list';
+
+/**
+ * The routing entry that matched.
+ */
+export interface RoutingMatch {
+    /** Kind of entry. */
+    type: RoutingMatchType;
+
+    /** Position of the entry in its list (prefix providers, rewrites, rules). */
+    index?: number | undefined;
+
+    /** Model name or prefix the entry matched on. */
+    pattern?: string | undefined;
+
+    /** Where rules and the default provider came from. */
+    source?: 'tenant' | 'global' | undefined;
+}
+
+/**
+ * Thread affinity's effect on a decision.
+ */
+export interface AffinityFactor {
+    /** Provider that served the thread's previous turn. */
+    provider: string;
+
+    /** Whether the request stayed on it. */
+    outcome: 'hit' | 'broken';
+
+    /** Why it didn't (not_routable, unhealthy). */
+    reason?: string | undefined;
+}
+
+/**
+ * An explained provider selection.
+ */
+export interface RoutingDecision {
+    /** Model the request asked for. */
+    requestedModel: string;
+
+    /** Model it was migrated to, ahead of routing. */
+    migratedTo?: string | undefined;
+
+    /** What selected the provider. */
+    match: RoutingMatch;
+
+    /** Model an app rewrite or fallback replaced the request's with. */
+    rewrite?: string | undefined;
+
+    /** Whether responses report the requested model instead of the rewrite. */
+    rewriteResponseModel?: boolean | undefined;
+
+    /** Thread affinity, when the request continues a thread (set by the gateway). */
+    affinity?: AffinityFactor | undefined;
+
+    /** Provider chosen. */
+    provider: string;
+
+    /** Model sent to it. */
+    model: string;
+}
+
+/**
+ * A dry-run routing query (GET /admin/api/routing/test).
+ */
+export interface RoutingTestQuery {
+    /** Model requested (default: the app's default model). */
+    model?: string | undefined;
+
+    /** Tenant whose routing applies, if it has its own. */
+    tenant?: string | undefined;
+
+    /** App the request would come in on. */
+    app?: string | undefined;
+}
+
 // ============================================================================
 // Router
 // ============================================================================
@@ -57,9 +171,22 @@ export class Router {
     private readonly apps: Map<string, AppConfig> = new Map();
     private readonly frontdoors: Map<string, Frontdoor> = new Map();
     private readonly defaultRouting: RoutingConfig | undefined;
-
-    constructor(options?: { defaultRouting?: RoutingConfig | undefined }) {
+    private readonly modelLists: ModelListCache | undefined;
+    private readonly migratedModels: ReadonlyMap<string, string> | undefined;
+
+    /** Model catalog consulted when no routing rule matches. */
+    readonly catalog: ModelCatalog;
+
+    constructor(options?: {
+        defaultRouting?: RoutingConfig | undefined;
+        catalog?: ModelCatalog | undefined;
+        modelLists?: ModelListCache | undefined;
+        migratedModels?: ReadonlyMap<string, string> | undefined;
+    }) {
         this.defaultRouting = options?.defaultRouting;
+        this.catalog = options?.catalog ?? new ModelCatalog();
+        this.modelLists = options?.modelLists;
+        this.migratedModels = options?.migratedModels;
     }
 
     /**
@@ -77,16 +204,18 @@ export class Router {
     }
 
     /**
-     * Matches a request path to an app.
+     * Matches a request path to an app. Among apps sharing the path, the
+     * one named by the key's binding wins, else the first added.
      */
-    matchApp(path: string): AppConfig | undefined {
+    matchApp(path: string, boundApp?: string): AppConfig | undefined {
         // Find app with longest matching path prefix
         let bestMatch: AppConfig | undefined;
         let bestMatchLength = 0;
 
         for (const app of this.apps.values()) {
             const appPath = app.path.replace(/\/$/, ''); // Remove trailing slash
-            if (path.startsWith(appPath) && appPath.length > bestMatchLength) {
+            if (!path.startsWith(appPath)) continue;
+            if (appPath.length > bestMatchLength || (appPath.length === bestMatchLength && app.name === boundApp)) {
                 bestMatch = app;
                 bestMatchLength = appPath.length;
             }
@@ -95,6 +224,14 @@ export class Router {
         return bestMatch;
     }
 
+    /**
+     * Whether another app has the same path as this one.
+     */
+    sharesPath(app: AppConfig): boolean {
+        const path = app.path.replace(/\/$/, '');
+        return [...this.apps.values()].some((other) => other !== app && other.path.replace(/\/$/, '') === path);
+    }
+
     /**
      * Gets the frontdoor for an app or path.
      */
@@ -115,42 +252,152 @@ export class Router {
     }
 
     /**
-     * Selects a provider based on model and routing configuration.
+     * Selects a provider based on model and routing configuration. A
+     * tenant's own routing, when given, replaces the global routing. A
+     * migrated model is routed as the model it migrated to, ahead of all
+     * other routing.
+     *
+     * @throws APIError model_not_found when the model falls through to the
+     * default provider and the cached model lists show no provider serves it
      */
     selectProvider(
         model: string,
         app?: AppConfig,
         defaultProvider?: string,
+        tenantRouting?: RoutingConfig,
     ): ProviderSelection {
+        return selectionOf(this.explain(model, app, defaultProvider, tenantRouting));
+    }
+
+    /**
+     * Selects a provider as selectProvider does, explaining the choice.
+     *
+     * @throws APIError model_not_found as selectProvider does
+     */
+    explain(
+        model: string,
+        app?: AppConfig,
+        defaultProvider?: string,
+        tenantRouting?: RoutingConfig,
+    ): RoutingDecision {
+        const migrated = this.migratedModels?.get(model);
+        if (migrated !== undefined) {
+            const decision = this.explain(migrated, app, defaultProvider, tenantRouting);
+            return { ...decision, requestedModel: model, migratedTo: decision.migratedTo ?? migrated };
+        }
+
+        const routing = tenantRouting ?? this.defaultRouting;
+        const source = tenantRouting ? 'tenant' : 'global';
+        const decide = (provider: string, match: RoutingMatch): RoutingDecision =>
+            ({ requestedModel: model, match, provider, model });
+
         // 1. Check app-level forced provider
         if (app?.provider) {
-            return { providerName: app.provider };
+            return decide(app.provider, { type: 'app_provider' });
         }
 
         // 2. Check app-level model routing
         if (app?.modelRouting) {
-            const selection = this.matchModelRouting(model, app.modelRouting);
-            if (selection) {
-                return selection;
+            const decision = this.matchModelRouting(model, app.modelRouting);
+            if (decision) {
+                return decision;
             }
         }
 
-        // 3. Check global routing rules
-        if (this.defaultRouting?.rules) {
-            for (const rule of this.defaultRouting.rules) {
+        // 3. Check tenant or global routing rules
+        if (routing?.rules) {
+            for (const [index, rule] of routing.rules.entries()) {
                 if (this.matchesRoutingRule(model, rule)) {
-                    return { providerName: rule.provider };
+                    return decide(rule.provider, {
+                        type: 'rule',
+                        index,
+                        pattern: rule.modelExact ?? rule.modelPrefix,
+                        source,
+                    });
                 }
             }
         }
 
-        // 4. Use default provider
+        // 4. Use the catalog's preferred provider for the model
+        const cataloged = this.catalog.get(model)?.provider;
+        if (cataloged) {
+            return decide(cataloged, { type: 'catalog' });
+        }
+
+        // 5. Use default provider
         const provider =
             defaultProvider ??
+            routing?.defaultProvider ??
             this.defaultRouting?.defaultProvider ??
             'openai';
 
-        return { providerName: provider };
+        const checked = this.checkUnrouted(model, provider, app);
+        return decide(checked, checked === provider ? { type: 'default', source } : { type: 'model_list' });
+    }
+
+    /**
+     * Checks whether routing allows a model to be served by a provider it
+     * didn't select (thread affinity). A forced app provider or a matching
+     * app model route allows only its own provider; otherwise the provider
+     * must be the target of any matching rule, the catalog entry, or the
+     * default, and its cached model list, when known, must have the model.
+     */
+    allows(
+        providerName: string,
+        model: string,
+        app?: AppConfig,
+        tenantRouting?: RoutingConfig,
+    ): boolean {
+        if (app?.provider) {
+            return app.provider === providerName;
+        }
+        const appRoute = app?.modelRouting && this.matchModelRouting(model, app.modelRouting);
+        if (appRoute) {
+            return appRoute.provider === providerName;
+        }
+
+        const routing = tenantRouting ?? this.defaultRouting;
+        const targets = new Set<string>();
+        for (const rule of routing?.rules ?? []) {
+            if (this.matchesRoutingRule(model, rule)) {
+                targets.add(rule.provider);
+            }
+        }
+        const cataloged = this.catalog.get(model)?.provider;
+        if (cataloged) {
+            targets.add(cataloged);
+        }
+        targets.add(routing?.defaultProvider ?? this.defaultRouting?.defaultProvider ?? 'openai');
+        if (!targets.has(providerName)) {
+            return false;
+        }
+
+        const known = this.modelLists?.peek(providerName);
+        return !model || !known?.data.length || known.data.some((m) => m.id === model);
+    }
+
+    /**
+     * Checks a model that fell through to the default provider against
+     * the cached model lists: if the default's list is known and lacks the
+     * model, a provider that lists it takes it, and otherwise the model
+     * doesn't exist. Without a cached list the default is used as-is.
+     */
+    private checkUnrouted(model: string, fallback: string, app?: AppConfig): string {
+        const known = this.modelLists?.peek(fallback);
+        if (
+            !model
+            || !known?.data.length
+            || known.data.some((m) => m.id === model)
+            || app?.models?.some((m) => m.id === model)
+        ) {
+            return fallback;
+        }
+
+        const owner = this.modelLists?.owner(model);
+        if (owner) {
+            return owner;
+        }
+        throw errModelNotFound(model);
     }
 
     /**
@@ -159,31 +406,35 @@ export class Router {
     private matchModelRouting(
         model: string,
         routing: ModelRoutingConfig,
-    ): ProviderSelection | undefined {
+    ): RoutingDecision | undefined {
         // Check prefix providers
         if (routing.prefixProviders) {
-            for (const [prefix, provider] of Object.entries(routing.prefixProviders)) {
+            for (const [index, [prefix, provider]] of Object.entries(routing.prefixProviders).entries()) {
                 if (model.startsWith(prefix)) {
-                    return { providerName: provider };
+                    return {
+                        requestedModel: model,
+                        match: { type: 'prefix_provider', index, pattern: prefix },
+                        provider,
+                        model,
+                    };
                 }
             }
         }
 
         // Check rewrites
         if (routing.rewrites) {
-            for (const rewrite of routing.rewrites) {
-                if (rewrite.modelExact && model === rewrite.modelExact) {
-                    return {
-                        providerName: rewrite.provider ?? 'openai',
-                        model: rewrite.model,
-                        rewriteResponseModel: rewrite.rewriteResponseModel,
-                    };
-                }
-                if (rewrite.modelPrefix && model.startsWith(rewrite.modelPrefix)) {
+            for (const [index, rewrite] of routing.rewrites.entries()) {
+                const pattern = rewrite.modelExact && model === rewrite.modelExact
+                    ? rewrite.modelExact
+                    : rewrite.modelPrefix && model.startsWith(rewrite.modelPrefix) ? rewrite.modelPrefix : undefined;
+                if (pattern !== undefined) {
                     return {
-                        providerName: rewrite.provider ?? 'openai',
-                        model: rewrite.model,
+                        requestedModel: model,
+                        match: { type: 'rewrite', index, pattern },
+                        rewrite: rewrite.model,
                         rewriteResponseModel: rewrite.rewriteResponseModel,
+                        provider: rewrite.provider ?? 'openai',
+                        model: rewrite.model ?? model,
                     };
                 }
             }
@@ -192,9 +443,12 @@ export class Router {
         // Check fallback
         if (routing.fallback) {
             return {
-                providerName: routing.fallback.provider ?? 'openai',
-                model: routing.fallback.model,
+                requestedModel: model,
+                match: { type: 'model_fallback' },
+                rewrite: routing.fallback.model,
                 rewriteResponseModel: routing.fallback.rewriteResponseModel,
+                provider: routing.fallback.provider ?? 'openai',
+                model: routing.fallback.model ?? model,
             };
         }
 
@@ -215,6 +469,41 @@ export class Router {
     }
 }
 
+// ============================================================================
+// Routing Decisions
+// ============================================================================
+
+/**
+ * The provider selection a decision amounts to.
+ */
+export function selectionOf(decision: RoutingDecision): ProviderSelection {
+    return {
+        providerName: decision.provider,
+        model: decision.rewrite ?? decision.migratedTo,
+        rewriteResponseModel: decision.rewriteResponseModel,
+    };
+}
+
+/**
+ * The transformation step recording a decision on its interaction.
+ */
+export function routingStep(decision: RoutingDecision): TransformationStep {
+    const { match } = decision;
+    const kind = match.type.replace('_', ' ');
+    const entry = match.index !== undefined ? `${kind} ${match.index}` : kind;
+    const factors = [
+        decision.migratedTo !== undefined && `migrated to '${decision.migratedTo}'`,
+        decision.affinity && `thread affinity ${decision.affinity.outcome}`,
+    ].filter(Boolean);
+    return {
+        stage: 'routing',
+        timestamp: new Date(),
+        description: `Routed model '${decision.requestedModel}' to provider '${decision.provider}' as '${decision.model}' by ${entry}`
+            + (factors.length > 0 ? ` (${factors.join(', ')})` : ''),
+        details: { ...decision },
+    };
+}
+
 // ============================================================================
 // Path Utilities
 // ============================================================================
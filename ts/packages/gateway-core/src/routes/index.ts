@@ -0,0 +1,19 @@
+/**
+ * Route table exports.
+ *
+ * @module routes
+ */
+
+export {
+    RouteTable,
+    levenshtein,
+    type RegisteredRoute,
+    type RouteTableOptions,
+    type UnmatchedRoute,
+} from './table.js';
+
+export {
+    UnmatchedRoutes,
+    unmatchedResponse,
+    type UnmatchedRouteStats,
+} from './unmatched.js';
@@ -0,0 +1,150 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { openAIFrontdoor, anthropicFrontdoor } from '../frontdoors/index';
+import { Router } from '../router';
+import { RouteTable, levenshtein } from './index';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+describe('Unmatched routes', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const provider = new ScriptedProvider('mock', { text: 'Hi' });
+        const authenticate = vi.fn(async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }));
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic' })
+            .provider(provider)
+            .options({ auth: { authenticate, getTenant: async () => null } })
+            .start();
+        const gw = gateway;
+        const send = (method: string, path: string) => method === 'POST'
+            ? gw.post(path, { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] })
+            : gw.request(path, { method });
+        return { gateway: gw.gateway, provider, authenticate, send };
+    }
+
+    it('should answer a typo with a 404 naming the nearest route, before authentication', async () => {
+        const { gateway, provider, authenticate, send } = await setup();
+        await gateway.reload();
+
+        const response = await send('POST', '/v1/chat/completion');
+
+        expect(response.status).toBe(404);
+        expect((await response.json()).error).toMatchObject({
+            type: 'not_found',
+            message: 'No route for POST /v1/chat/completion; did you mean POST /v1/chat/completions?',
+        });
+        expect(authenticate).not.toHaveBeenCalled();
+        expect(provider.requests).toHaveLength(0);
+    });
+
+    it('should answer in the format of the nearest route\'s frontdoor', async () => {
+        const { gateway, send } = await setup();
+        await gateway.reload();
+
+        const response = await send('POST', '/anthropc/v1/messages');
+
+        expect(response.status).toBe(404);
+        expect(await response.json()).toEqual({
+            type: 'error',
+            error: {
+                type: 'not_found_error',
+                message: 'No route for POST /anthropc/v1/messages; did you mean POST /anthropic/v1/messages?',
+            },
+        });
+    });
+
+    it('should answer a wrong method with a 405 and the allowed methods', async () => {
+        const { gateway, authenticate, send } = await setup();
+        await gateway.reload();
+
+        const response = await send('GET', '/anthropic/v1/messages');
+
+        expect(response.status).toBe(405);
+        expect(response.headers.get('Allow')).toBe('POST');
+        expect((await response.json()).error).toMatchObject({ type: 'invalid_request_error' });
+        expect(authenticate).not.toHaveBeenCalled();
+    });
+
+    it('should pass every declared route through', async () => {
+        const { gateway, send } = await setup();
+        await gateway.reload();
+
+        // Versionless paths under an app match its routes too
+        await send('POST', '/anthropic/messages');
+        await send('POST', '/anthropic/v1/messages/batches/gwbatch_1/cancel');
+        await send('GET', '/v1/models/gpt-4o');
+        await send('POST', '/v1/token_count');
+        await send('GET', '/v1/usage');
+
+        expect(gateway.unmatchedRouteStats()).toEqual([]);
+    });
+
+    it('should count unmatched requests per path prefix', async () => {
+        const { gateway, send } = await setup();
+        await gateway.reload();
+
+        await send('POST', '/v1/chat/completion');
+        await send('POST', '/v1/embeddings');
+        await send('GET', '/anthropic/v1/messages');
+        await send('GET', '/favicon.ico');
+
+        expect(gateway.unmatchedRouteStats()).toEqual([
+            { prefix: '/anthropic', notFound: 0, methodNotAllowed: 1 },
+            { prefix: '/favicon.ico', notFound: 1, methodNotAllowed: 0 },
+            { prefix: '/v1', notFound: 2, methodNotAllowed: 0 },
+        ]);
+    });
+
+    it('should list routes through the admin API', async () => {
+        const { gateway } = await setup();
+        await gateway.reload();
+        const admin = new AdminHandler({ routes: () => gateway.routes() });
+
+        const { routes } = await (await admin.handle(new Request('http://localhost/api/routes'))).json();
+
+        expect(routes).toContainEqual({ method: 'POST', path: '/v1/chat/completions', app: 'chat', frontdoor: 'openai' });
+        expect(routes).toContainEqual({ method: 'GET', path: '/v1/models/:model', app: 'chat', frontdoor: 'openai' });
+        expect(routes).toContainEqual({ method: 'POST', path: '/anthropic/v1/messages', app: 'claude', frontdoor: 'anthropic' });
+        expect(routes).toContainEqual({ method: 'POST', path: '/anthropic/v1/token_count', app: 'claude', frontdoor: 'anthropic' });
+        expect(routes).toContainEqual({ method: 'GET', path: '/v1/usage' });
+        // Frontdoor routes outside any app are all under the app at /v1
+        expect(routes.filter((r: any) => !r.app && r.frontdoor)).toEqual([]);
+    });
+});
+
+describe('RouteTable', () => {
+    const table = (apps: any[], frontdoors: any[]) => {
+        const router = new Router();
+        apps.forEach((app) => router.addApp(app));
+        return new RouteTable({ apps, frontdoors, matchApp: (path) => router.matchApp(path) });
+    };
+
+    it('should overlap an app mounted at an endpoint with its routes', () => {
+        const routes = table([{ name: 'c', frontdoor: 'openai', path: '/v1/chat/completions' }], [openAIFrontdoor]);
+
+        expect(routes.resolve('POST', '/v1/chat/completions')).toBeUndefined();
+        expect(routes.list()).toContainEqual({ method: 'POST', path: '/v1/chat/completions', app: 'c', frontdoor: 'openai' });
+    });
+
+    it('should leave apps whose frontdoor declares no routes to the frontdoor', () => {
+        const custom = { name: 'custom', matches: (path: string) => path.startsWith('/custom'), handle: vi.fn() };
+        const routes = table([{ name: 'x', frontdoor: 'custom', path: '/x' }], [anthropicFrontdoor, custom]);
+
+        expect(routes.resolve('POST', '/x/anything')).toBeUndefined();
+        expect(routes.resolve('POST', '/custom/anything')).toBeUndefined();
+        expect(routes.resolve('POST', '/other')).toMatchObject({ status: 404, prefix: '/other' });
+    });
+
+    it('should measure edit distance', () => {
+        expect(levenshtein('/v1/chat/completion', '/v1/chat/completions')).toBe(1);
+        expect(levenshtein('kitten', 'sitting')).toBe(3);
+        expect(levenshtein('', 'abc')).toBe(3);
+    });
+});
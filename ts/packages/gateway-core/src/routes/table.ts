@@ -0,0 +1,310 @@
+/**
+ * Route table.
+ *
+ * Lists the methods and paths the gateway serves, built from each
+ * frontdoor's declared routes under every app's base path plus the
+ * gateway's own endpoints, and checks requests against it. A request with
+ * no route gets the nearest registered one as a hint; one with a route
+ * but the wrong method gets the methods it allows. Apps whose frontdoor
+ * declares no routes are left to the frontdoor, as are paths with no
+ * route under apps that forward unserved endpoints to their provider.
+ *
+ * @module routes/table
+ */
+
+import type { AppConfig } from '../ports/config.js';
+import type { Frontdoor, FrontdoorRoute } from '../frontdoors/types.js';
+import { passthroughAllows, passthroughPath } from '../passthrough/endpoints.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Request path length compared when looking for the nearest route. */
+const MAX_HINT_PATH = 256;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A method and path the gateway serves.
+ */
+export interface RegisteredRoute {
+    method: string;
+
+    /** Path as clients request it; `:name` segments are parameters. */
+    path: string;
+
+    /** Owning app; unset for routes served outside any app. */
+    app?: string | undefined;
+
+    /** Frontdoor that serves it; unset for the gateway's own endpoints. */
+    frontdoor?: string | undefined;
+}
+
+/**
+ * Why a request has no route.
+ */
+export interface UnmatchedRoute {
+    /** 404 for an unknown path, 405 for a known path and another method. */
+    status: 404 | 405;
+
+    /** Methods the path accepts (405 only). */
+    allow: string[];
+
+    /** Frontdoor whose error format the client most likely expects. */
+    frontdoor: string;
+
+    /** The matched app's base path, or the first path segment. */
+    prefix: string;
+
+    /** Nearest registered route (404 only). */
+    hint?: RegisteredRoute | undefined;
+}
+
+/**
+ * Route table options.
+ */
+export interface RouteTableOptions {
+    /** Configured apps. */
+    apps: AppConfig[];
+
+    /** Registered frontdoors. */
+    frontdoors: Frontdoor[];
+
+    /** Finds the app a path belongs to, as the router does. */
+    matchApp: (path: string) => AppConfig | undefined;
+
+    /** Endpoints the gateway serves itself, ahead of any app. */
+    gatewayRoutes?: FrontdoorRoute[] | undefined;
+
+    /** Endpoints the gateway serves under every app. */
+    appRoutes?: FrontdoorRoute[] | undefined;
+}
+
+interface Entry {
+    route: RegisteredRoute;
+
+    /** Matches the route's path, less any optional /v1, at the end of a request path. */
+    suffix: RegExp;
+
+    absolute: boolean;
+}
+
+// ============================================================================
+// Route Table
+// ============================================================================
+
+/**
+ * Routes for one config. Rebuilt on reload.
+ */
+export class RouteTable {
+    private readonly matchApp: (path: string) => AppConfig | undefined;
+    private readonly gateway: Entry[];
+
+    /** Per app name; undefined when the app's frontdoor declares no routes. */
+    private readonly apps = new Map<string, Entry[] | undefined>();
+
+    /** Frontdoor routes served outside any app. */
+    private readonly root: Entry[] = [];
+
+    /** Frontdoors that declare no routes, left to match paths themselves. */
+    private readonly opaque: Frontdoor[] = [];
+
+    private readonly routes: RegisteredRoute[];
+
+    constructor(options: RouteTableOptions) {
+        this.matchApp = options.matchApp;
+        this.gateway = (options.gatewayRoutes ?? []).map((route) => compile({ ...route, absolute: true }, route.path));
+
+        const frontdoors = new Map(options.frontdoors.map((f) => [f.name, f]));
+        for (const app of options.apps) {
+            const frontdoor = frontdoors.get(app.frontdoor);
+            if (!frontdoor?.routes) {
+                this.apps.set(app.name, undefined);
+                continue;
+            }
+            const base = basePath(app.path);
+            const entries: Entry[] = [];
+            for (const route of [...frontdoor.routes, ...(options.appRoutes ?? [])]) {
+                // Absolute routes are only under the app if its base path is a prefix
+                if (route.absolute && !route.path.startsWith(base)) continue;
+                const path = route.absolute ? route.path : joinPath(base, route.path);
+                entries.push(compile(route, path, app.name, frontdoor.name));
+            }
+            this.apps.set(app.name, entries);
+        }
+
+        for (const frontdoor of options.frontdoors) {
+            if (!frontdoor.routes) {
+                this.opaque.push(frontdoor);
+                continue;
+            }
+            for (const route of frontdoor.routes) {
+                this.root.push(compile(route, route.path, undefined, frontdoor.name));
+            }
+        }
+
+        // Root routes under an app's base path are served by the app instead
+        this.routes = [
+            ...this.gateway,
+            ...[...this.apps.values()].flatMap((entries) => entries ?? []),
+            ...this.root.filter((e) => !this.matchApp(e.route.path)),
+        ]
+            .map((e) => e.route)
+            .sort((a, b) => a.path.localeCompare(b.path) || a.method.localeCompare(b.method));
+    }
+
+    /**
+     * Returns every registered route, sorted by path and method.
+     */
+    list(): RegisteredRoute[] {
+        return this.routes.map((route) => ({ ...route }));
+    }
+
+    /**
+     * Checks a request against the table. Returns undefined when it has a
+     * route, when the frontdoor it would reach declares no routes, or when
+     * its app forwards the path to the provider.
+     */
+    resolve(method: string, path: string): UnmatchedRoute | undefined {
+        const gateway = this.gateway.filter((e) => matches(e, '', path));
+        if (gateway.length > 0) {
+            return this.check(method, path, gateway, '/');
+        }
+
+        const app = this.matchApp(path);
+        if (app) {
+            const entries = this.apps.get(app.name);
+            if (!entries) return undefined;
+            const base = basePath(app.path);
+            const found = entries.filter((e) => matches(e, base, path));
+            if (found.length === 0 && passthroughAllows(app.passthrough, passthroughPath(app, path))) {
+                return undefined;
+            }
+            return this.check(method, path, found, base || '/', app.frontdoor);
+        }
+
+        const found = this.root.filter((e) => matches(e, '', path));
+        if (found.length === 0 && this.opaque.some((f) => f.matches(path))) {
+            return undefined;
+        }
+        return this.check(method, path, found, `/${path.split('/')[1] ?? ''}`);
+    }
+
+    private check(
+        method: string,
+        path: string,
+        found: Entry[],
+        prefix: string,
+        frontdoor?: string,
+    ): UnmatchedRoute | undefined {
+        if (found.some((e) => e.route.method === method)) {
+            return undefined;
+        }
+        if (found.length > 0) {
+            return {
+                status: 405,
+                allow: [...new Set(found.map((e) => e.route.method))].sort(),
+                frontdoor: frontdoor ?? found[0]!.route.frontdoor ?? 'openai',
+                prefix,
+            };
+        }
+        const hint = this.nearest(method, path);
+        return {
+            status: 404,
+            allow: [],
+            frontdoor: frontdoor ?? hint?.frontdoor ?? 'openai',
+            prefix,
+            hint,
+        };
+    }
+
+    /**
+     * Finds the registered route whose path is the fewest edits away,
+     * preferring the request's method on ties.
+     */
+    private nearest(method: string, path: string): RegisteredRoute | undefined {
+        const target = path.slice(0, MAX_HINT_PATH);
+        let best: { route: RegisteredRoute; distance: number } | undefined;
+        for (const route of this.routes) {
+            const distance = levenshtein(target, route.path);
+            if (!best || distance < best.distance
+                || (distance === best.distance && route.method === method && best.route.method !== method)) {
+                best = { route, distance };
+            }
+        }
+        return best && { ...best.route };
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+function compile(route: FrontdoorRoute, path: string, app?: string, frontdoor?: string): Entry {
+    const absolute = route.absolute === true;
+    const matched = absolute ? route.path : route.path.replace(/^\/v1(?=\/)/, '');
+    const pattern = matched
+        .split('/')
+        .map((segment) => segment.startsWith(':') ? '[^/]+' : segment.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'))
+        .join('/');
+    return {
+        route: { method: route.method, path, app, frontdoor },
+        suffix: new RegExp(`${pattern}$`),
+        absolute,
+    };
+}
+
+/**
+ * Whether a request path under an app's base path reaches an entry: the
+ * route's path must end the request path, and what comes before it must
+ * be the base path (or part of it), optionally followed by /v1. Absolute
+ * routes must be the whole path.
+ */
+function matches(entry: Entry, base: string, path: string): boolean {
+    const match = entry.suffix.exec(path);
+    if (!match) return false;
+    const before = path.slice(0, match.index);
+    if (entry.absolute) {
+        return before === '';
+    }
+    return base.startsWith(before) || before === `${base}/v1`;
+}
+
+function basePath(path: string): string {
+    return path.replace(/\/$/, '');
+}
+
+/**
+ * Joins a base path and a route path, overlapping the base's trailing
+ * segments with the route's leading ones (an app mounted at /v1, or at
+ * the endpoint itself).
+ */
+function joinPath(base: string, path: string): string {
+    for (let i = 0; i < base.length; i++) {
+        if (base[i] !== '/') continue;
+        const tail = base.slice(i);
+        if (path === tail || path.startsWith(`${tail}/`)) {
+            return base.slice(0, i) + path;
+        }
+    }
+    return base + path;
+}
+
+/**
+ * Returns the edit distance between two strings.
+ */
+export function levenshtein(a: string, b: string): number {
+    let previous = Array.from({ length: b.length + 1 }, (_, j) => j);
+    for (let i = 1; i <= a.length; i++) {
+        const current = [i];
+        for (let j = 1; j <= b.length; j++) {
+            const cost = a[i - 1] === b[j - 1] ? 0 : 1;
+            current.push(Math.min(previous[j]! + 1, current[j - 1]! + 1, previous[j - 1]! + cost));
+        }
+        previous = current;
+    }
+    return previous[b.length]!;
+}
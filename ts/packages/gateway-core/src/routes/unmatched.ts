@@ -0,0 +1,101 @@
+/**
+ * Responses and counters for requests without a route.
+ *
+ * Unmatched requests are answered in the error format of the frontdoor
+ * the client most likely meant, and only counted: they are never
+ * authenticated or recorded.
+ *
+ * @module routes/unmatched
+ */
+
+import type { APIType } from '../domain/types.js';
+import { errInvalidRequest, errNotFound } from '../domain/errors.js';
+import { defaultCodecRegistry, openaiCodec } from '../codecs/index.js';
+import type { UnmatchedRoute } from './table.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Distinct path prefixes counted before the rest are counted together. */
+const MAX_PREFIXES = 100;
+
+/** Label for requests past the prefix limit. */
+const OTHER_PREFIX = '(other)';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Unmatched request counters for one path prefix.
+ */
+export interface UnmatchedRouteStats {
+    /** The matched app's base path, or the first path segment. */
+    prefix: string;
+
+    /** Requests for a path with no route. */
+    notFound: number;
+
+    /** Requests for a known path with a method it doesn't accept. */
+    methodNotAllowed: number;
+}
+
+// ============================================================================
+// Counters
+// ============================================================================
+
+/**
+ * Counts unmatched requests per path prefix.
+ */
+export class UnmatchedRoutes {
+    private readonly counts = new Map<string, UnmatchedRouteStats>();
+
+    /**
+     * Records one unmatched request.
+     */
+    record(unmatched: UnmatchedRoute): void {
+        const prefix = this.counts.has(unmatched.prefix) || this.counts.size < MAX_PREFIXES
+            ? unmatched.prefix
+            : OTHER_PREFIX;
+        let stats = this.counts.get(prefix);
+        if (!stats) {
+            stats = { prefix, notFound: 0, methodNotAllowed: 0 };
+            this.counts.set(prefix, stats);
+        }
+        if (unmatched.status === 405) {
+            stats.methodNotAllowed++;
+        } else {
+            stats.notFound++;
+        }
+    }
+
+    /**
+     * Returns the counters, sorted by prefix.
+     */
+    stats(): UnmatchedRouteStats[] {
+        return Array.from(this.counts.values(), (s) => ({ ...s }))
+            .sort((a, b) => a.prefix.localeCompare(b.prefix));
+    }
+}
+
+// ============================================================================
+// Responses
+// ============================================================================
+
+/**
+ * Builds the 404 or 405 response for an unmatched request, naming the
+ * nearest route or the allowed methods.
+ */
+export function unmatchedResponse(method: string, path: string, unmatched: UnmatchedRoute): Response {
+    const { hint } = unmatched;
+    const error = unmatched.status === 405
+        ? errInvalidRequest(`${method} is not supported on ${path}; use ${unmatched.allow.join(' or ')}`).withStatusCode(405)
+        : errNotFound(`No route for ${method} ${path}${hint ? `; did you mean ${hint.method} ${hint.path}?` : ''}`);
+    const codec = defaultCodecRegistry.get(unmatched.frontdoor as APIType) ?? openaiCodec;
+    const headers: Record<string, string> = { 'Content-Type': 'application/json' };
+    if (unmatched.status === 405) {
+        headers['Allow'] = unmatched.allow.join(', ');
+    }
+    return new Response(codec.encodeError(error).body, { status: unmatched.status, headers });
+}
@@ -0,0 +1,33 @@
+/**
+ * Provider credentials held in a tenant secret.
+ *
+ * @module secrets/credentials
+ */
+
+import type { SecretRef } from '../ports/config.js';
+import type { CredentialLease, ProviderCredentials } from '../ports/provider.js';
+import type { SecretManager } from './manager.js';
+
+// ============================================================================
+// Secret Credentials
+// ============================================================================
+
+/**
+ * A provider's API key, looked up in a secret on every request so a
+ * rotation applies to the next one. A reference that doesn't resolve fails
+ * the request with a secret_unavailable error naming it.
+ */
+export class SecretCredentials implements ProviderCredentials {
+    constructor(
+        private readonly secrets: SecretManager,
+        private readonly ref: SecretRef,
+    ) { }
+
+    acquire(): CredentialLease {
+        return { id: this.ref.secret, key: this.secrets.resolve(this.ref) };
+    }
+
+    report(_lease: CredentialLease, _status: number): void {
+        // A single key has nothing to steer away to
+    }
+}
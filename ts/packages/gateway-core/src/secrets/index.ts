@@ -0,0 +1,21 @@
+/**
+ * Tenant secret exports.
+ *
+ * @module secrets
+ */
+
+export {
+    SecretManager,
+    SECRET_NAME_PATTERN,
+    isSecretReference,
+    type LoadedSecrets,
+    type SecretInfo,
+    type SecretManagerOptions,
+} from './manager.js';
+
+export {
+    MemorySecretStore,
+    isSecretStore,
+} from './store.js';
+
+export { SecretCredentials } from './credentials.js';
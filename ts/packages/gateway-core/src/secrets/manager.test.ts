@@ -0,0 +1,239 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { SecretManager, MemorySecretStore } from './index';
+import { StorageKeyring } from '../encryption/index';
+import { WEBHOOK_SIGNATURE_HEADER } from '../middleware/index';
+import type { PipelineStageConfig, StorageKeyConfig } from '../ports/index';
+import { bytesToBase64 } from '../utils/crypto';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const K1: StorageKeyConfig = { id: 'k1', key: bytesToBase64(new Uint8Array(32).fill(1)) };
+const K2: StorageKeyConfig = { id: 'k2', key: bytesToBase64(new Uint8Array(32).fill(2)) };
+const VALUE = 'hunter2-very-secret';
+
+function capturingLogger() {
+    const lines: string[] = [];
+    const logger: any = {
+        child: () => logger,
+        ...Object.fromEntries(['debug', 'info', 'warn', 'error'].map((level) => [
+            level,
+            vi.fn((message: string, fields?: Record<string, unknown>) => void lines.push(`${message} ${JSON.stringify(fields ?? {})}`)),
+        ])),
+    };
+    return { logger, lines };
+}
+
+async function manager(...keys: StorageKeyConfig[]) {
+    const keyring = new StorageKeyring();
+    await keyring.load({ keys });
+    const store = new MemorySecretStore();
+    const { logger, lines } = capturingLogger();
+    return { secrets: new SecretManager({ store, keyring, logger }), store, keyring, logger, lines };
+}
+
+async function hmac(key: string, body: string): Promise<string> {
+    const encoder = new TextEncoder();
+    const k = await crypto.subtle.importKey('raw', encoder.encode(key), { name: 'HMAC', hash: 'SHA-256' }, false, ['sign']);
+    const mac = await crypto.subtle.sign('HMAC', k, encoder.encode(body));
+    return Array.from(new Uint8Array(mac), (b) => b.toString(16).padStart(2, '0')).join('');
+}
+
+describe('SecretManager', () => {
+    it('should store values sealed and bump the version on rotation', async () => {
+        const { secrets, store, logger } = await manager(K1);
+
+        const created = await secrets.set('acme', 'webhook-hmac', VALUE);
+        const rotated = await secrets.set('acme', 'webhook-hmac', 'second');
+
+        expect(created).toMatchObject({ reference: 'acme/webhook-hmac', version: 1, keyId: 'k1' });
+        expect(rotated.version).toBe(2);
+        expect(rotated.createdAt).toEqual(created.createdAt);
+        expect((await store.getSecret('acme', 'webhook-hmac'))!.value).toMatch(/^gwenc:k1:/);
+        expect(secrets.resolve({ secret: 'acme/webhook-hmac' })).toBe('second');
+        expect(logger.info).toHaveBeenCalledWith('secret_created', { audit: true, tenantId: 'acme', name: 'webhook-hmac', version: 1 });
+        expect(logger.info).toHaveBeenCalledWith('secret_rotated', { audit: true, tenantId: 'acme', name: 'webhook-hmac', version: 2 });
+    });
+
+    it('should fail resolution with an error naming the reference', async () => {
+        const { secrets } = await manager(K1);
+        await secrets.set('acme', 'key', VALUE);
+        await secrets.delete('acme', 'key');
+
+        expect(() => secrets.resolve({ secret: 'acme/key' })).toThrow(
+            expect.objectContaining({ code: 'secret_unavailable', message: "Secret 'acme/key' is not set" }),
+        );
+    });
+
+    it('should reload stored secrets and reseal them under the newest key', async () => {
+        const { secrets, store, keyring, lines } = await manager(K1);
+        await secrets.set('acme', 'key', VALUE);
+
+        await keyring.load({ keys: [K2, K1] });
+        const reloaded = new SecretManager({ store, keyring });
+        await reloaded.load();
+
+        expect(reloaded.resolve({ secret: 'acme/key' })).toBe(VALUE);
+        expect((await store.getSecret('acme', 'key'))!.value).toMatch(/^gwenc:k2:/);
+        expect(lines.join('\n')).not.toContain(VALUE);
+    });
+
+    it('should refuse to store secrets without encryption keys', async () => {
+        const { secrets } = await manager();
+
+        expect(secrets.writable).toBe(false);
+        await expect(secrets.set('acme', 'key', VALUE)).rejects.toMatchObject({ statusCode: 503 });
+    });
+
+    it('should reject names that cannot be referenced', async () => {
+        const { secrets } = await manager(K1);
+
+        await expect(secrets.set('acme', 'a/b', VALUE)).rejects.toMatchObject({ statusCode: 400 });
+    });
+});
+
+describe('Secrets admin API', () => {
+    async function setup(tenantId?: string) {
+        const { secrets, lines } = await manager(K1);
+        const auth = tenantId === undefined
+            ? undefined
+            : { authenticate: async () => ({ tenantId, scopes: [], metadata: {} }), getTenant: async () => null };
+        const admin = new AdminHandler({ secrets, auth });
+        const call = (method: string, path: string, body?: unknown) => admin.handle(new Request(`http://localhost${path}`, {
+            method,
+            headers: { Authorization: 'Bearer token' },
+            body: body === undefined ? undefined : JSON.stringify(body),
+        }));
+        return { secrets, lines, call };
+    }
+
+    it('should set, rotate, list, and delete secrets without returning values', async () => {
+        const { call, lines } = await setup();
+
+        const created = await call('PUT', '/api/tenants/acme/secrets/webhook-hmac', { value: VALUE });
+        const rotated = await call('PUT', '/api/tenants/acme/secrets/webhook-hmac', { value: `${VALUE}-2` });
+        const got = await call('GET', '/api/tenants/acme/secrets/webhook-hmac');
+        const listed = await call('GET', '/api/tenants/acme/secrets');
+        const bodies = await Promise.all([created, rotated, got, listed].map((r) => r.text()));
+
+        expect([created.status, rotated.status, got.status, listed.status]).toEqual([200, 200, 200, 200]);
+        expect(JSON.parse(bodies[2]!)).toMatchObject({ reference: 'acme/webhook-hmac', version: 2, keyId: 'k1' });
+        expect(JSON.parse(bodies[3]!).secrets).toHaveLength(1);
+        for (const body of bodies) {
+            expect(body).not.toContain(VALUE);
+            expect(body).not.toContain('gwenc:');
+        }
+
+        expect((await call('DELETE', '/api/tenants/acme/secrets/webhook-hmac')).status).toBe(200);
+        expect((await call('GET', '/api/tenants/acme/secrets/webhook-hmac')).status).toBe(404);
+        expect((await call('DELETE', '/api/tenants/acme/secrets/webhook-hmac')).status).toBe(404);
+        expect(lines.filter((line) => line.includes('"audit":true'))).toHaveLength(3);
+        expect(lines.join('\n')).not.toContain(VALUE);
+    });
+
+    it('should reject a missing value', async () => {
+        const { call } = await setup();
+
+        expect((await call('PUT', '/api/tenants/acme/secrets/key', {})).status).toBe(400);
+    });
+
+    it('should let a tenant manage only its own secrets', async () => {
+        const { call } = await setup('acme');
+
+        expect((await call('PUT', '/api/tenants/acme/secrets/key', { value: VALUE })).status).toBe(200);
+        expect((await call('PUT', '/api/tenants/beta/secrets/key', { value: VALUE })).status).toBe(404);
+        expect((await call('GET', '/api/tenants/beta/secrets')).status).toBe(404);
+    });
+
+    it('should answer 503 without encryption keys', async () => {
+        const admin = new AdminHandler({ secrets: (await manager()).secrets });
+
+        const response = await admin.handle(new Request('http://localhost/api/tenants/acme/secrets/key', {
+            method: 'PUT',
+            body: JSON.stringify({ value: VALUE }),
+        }));
+
+        expect(response.status).toBe(503);
+    });
+});
+
+describe('Secret references', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(stage: Partial<PipelineStageConfig> = {}) {
+        const provider: ScriptedProvider = new ScriptedProvider('mock', () => ({
+            text: `key=${provider.config!.credentials!.acquire().key}`,
+        }));
+        const { logger, lines } = capturingLogger();
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                pipeline: {
+                    stages: [{
+                        name: 'policy',
+                        type: 'pre',
+                        url: 'http://hook.test',
+                        onError: 'allow',
+                        signingSecret: { secret: 'acme/webhook-hmac' },
+                        ...stage,
+                    }],
+                },
+            })
+            .provider(provider)
+            .config({
+                providers: [{ name: 'mock', type: 'mock', apiKey: '', apiKeySecret: { secret: 'acme/provider-key' } }],
+                storage: { type: 'memory', encryption: { keys: [K1] } },
+            })
+            .webhook(() => ({ action: 'allow' }))
+            .options({ logger })
+            .start();
+        await gateway.gateway.reload();
+        const gw = gateway;
+        const chat = () => gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] });
+        return { gateway: gw.gateway, hooks: gw.webhookCalls, lines, chat };
+    }
+
+    it('should sign webhooks and authenticate providers with the current secret values', async () => {
+        const { gateway, hooks, lines, chat } = await setup();
+        await gateway.secrets.set('acme', 'webhook-hmac', VALUE);
+        await gateway.secrets.set('acme', 'provider-key', 'sk-first');
+
+        const first = await chat();
+        await gateway.secrets.set('acme', 'provider-key', 'sk-second');
+        const second = await chat();
+
+        expect((await first.json()).choices[0].message.content).toBe('key=sk-first');
+        expect((await second.json()).choices[0].message.content).toBe('key=sk-second');
+        const [hook] = hooks;
+        expect(hook?.headers.get(WEBHOOK_SIGNATURE_HEADER)).toBe(await hmac(VALUE, hook!.body));
+        expect(lines.join('\n')).not.toContain(VALUE);
+        expect(lines.join('\n')).not.toContain('sk-first');
+    });
+
+    it('should fail a webhook stage closed when its secret is missing, whatever onError says', async () => {
+        const { gateway, hooks, chat } = await setup();
+        await gateway.secrets.set('acme', 'provider-key', 'sk-first');
+
+        const response = await chat();
+
+        expect(response.status).toBe(500);
+        expect(JSON.stringify(await response.json())).toContain('acme/webhook-hmac');
+        expect(hooks).toHaveLength(0);
+    });
+
+    it('should fail a request whose provider key secret is missing, naming the reference', async () => {
+        const { chat } = await setup({ signingSecret: undefined });
+
+        const response = await chat();
+        const body = await response.json();
+
+        expect(response.status).toBe(500);
+        expect(body.error).toMatchObject({ code: 'secret_unavailable', message: "Secret 'acme/provider-key' is not set" });
+    });
+});
@@ -0,0 +1,292 @@
+/**
+ * Tenant secrets.
+ *
+ * Tenants keep the secrets their pipeline webhooks and provider keys need
+ * out of the main config: a secret is set through the admin API, sealed
+ * with the storage encryption keyring, and referenced from config as
+ * `{secret: "<tenant>/<name>"}`. Values are write-only; the API and logs
+ * only ever show metadata. Opened values are held in memory and looked up
+ * each time a reference is used, so a set or rotation takes effect on the
+ * next request (other instances see it at their next config load). Every
+ * mutation is audit-logged.
+ *
+ * @module secrets/manager
+ */
+
+import type { SecretRef } from '../ports/config.js';
+import type { SecretRecord, SecretStore } from '../ports/storage.js';
+import { errInvalidRequest, errNotFound, errSecretUnavailable, APIError } from '../domain/errors.js';
+import type { StorageKeyring } from '../encryption/keyring.js';
+import type { Logger } from '../utils/logging.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Characters allowed in a secret name (it appears in admin API paths). */
+export const SECRET_NAME_PATTERN = /^[A-Za-z0-9_.-]+$/;
+
+/** A reference: "<tenant>/<name>". */
+const REFERENCE_PATTERN = /^([A-Za-z0-9_.-]+)\/([A-Za-z0-9_.-]+)$/;
+
+/**
+ * A secret as the admin API reports it. The value is never included.
+ */
+export interface SecretInfo {
+    /** Owning tenant. */
+    tenantId: string;
+
+    /** Secret name. */
+    name: string;
+
+    /** How config refers to it: "<tenant>/<name>". */
+    reference: string;
+
+    /** Incremented on every rotation, starting at 1. */
+    version: number;
+
+    /** ID of the storage key the value is sealed with. */
+    keyId?: string | undefined;
+
+    /** Creation time. */
+    createdAt: Date;
+
+    /** Last rotation time. */
+    updatedAt: Date;
+}
+
+/**
+ * Stored secrets opened with a config's keys, ready to apply.
+ */
+export interface LoadedSecrets {
+    /** Opened values by reference. */
+    values: Map<string, string>;
+
+    /** Secrets sealed under a key other than the newest. */
+    stale: { record: SecretRecord; value: string }[];
+}
+
+/**
+ * Secret manager options.
+ */
+export interface SecretManagerOptions {
+    /** Where sealed secrets are kept. */
+    store: SecretStore;
+
+    /** Storage encryption keys secrets are sealed with (typically the gateway's). */
+    keyring: StorageKeyring;
+
+    /** Logger for the audit trail. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Secret Manager
+// ============================================================================
+
+/**
+ * Sets, rotates, and resolves tenant secrets.
+ */
+export class SecretManager {
+    private readonly store: SecretStore;
+    private readonly keyring: StorageKeyring;
+    private readonly logger: Logger | undefined;
+    private values = new Map<string, string>();
+
+    constructor(options: SecretManagerOptions) {
+        this.store = options.store;
+        this.keyring = options.keyring;
+        this.logger = options.logger;
+    }
+
+    /**
+     * Whether secrets can be set: values are only ever stored sealed.
+     */
+    get writable(): boolean {
+        return this.keyring.enabled;
+    }
+
+    /**
+     * Opens every stored secret, after the keyring is loaded. Secrets
+     * sealed under an older key are resealed under the newest; one that
+     * can't be opened is logged and left unresolved. If the store can't be
+     * read, the secrets already loaded are kept.
+     */
+    async load(): Promise<void> {
+        await this.apply(await this.prepare());
+    }
+
+    /**
+     * Opens every stored secret with a keyring (default: the shared one)
+     * without changing what resolves, so a config's keys can be tried
+     * before they are applied. Returns undefined if the store can't be read.
+     */
+    async prepare(keyring: StorageKeyring = this.keyring): Promise<LoadedSecrets | undefined> {
+        let records: SecretRecord[];
+        try {
+            records = await this.store.listSecrets();
+        } catch (error) {
+            this.logger?.error('secret_load_failed', {
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return undefined;
+        }
+
+        const loaded: LoadedSecrets = { values: new Map(), stale: [] };
+        for (const record of records) {
+            const reference = referenceOf(record.tenantId, record.name);
+            try {
+                const value = await keyring.open(record.value);
+                loaded.values.set(reference, value);
+                if (keyring.enabled && keyring.keyIdOf(record.value) !== keyring.currentKeyId) {
+                    loaded.stale.push({ record, value });
+                }
+            } catch (error) {
+                this.logger?.error('secret_open_failed', {
+                    reference,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            }
+        }
+        return loaded;
+    }
+
+    /**
+     * Resolves secrets opened by prepare(), once the shared keyring holds
+     * the keys they were opened with, and reseals stale ones under its
+     * newest key. Without secrets (the store couldn't be read) the ones
+     * already loaded are kept.
+     */
+    async apply(loaded: LoadedSecrets | undefined): Promise<void> {
+        if (!loaded) return;
+        this.values = loaded.values;
+        for (const { record, value } of loaded.stale) {
+            const reference = referenceOf(record.tenantId, record.name);
+            try {
+                await this.store.saveSecret({ ...record, value: await this.keyring.seal(value) });
+                this.logger?.info('secret_resealed', { audit: true, reference, keyId: this.keyring.currentKeyId });
+            } catch (error) {
+                this.logger?.error('secret_reseal_failed', {
+                    reference,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            }
+        }
+    }
+
+    /**
+     * Sets a tenant's secret, rotating it if it exists.
+     */
+    async set(tenantId: string, name: string, value: string): Promise<SecretInfo> {
+        const reference = checkReference(tenantId, name);
+        if (!value) {
+            throw errInvalidRequest('value must be a non-empty string');
+        }
+        if (!this.writable) {
+            throw new APIError('server', 'Secrets require storage encryption keys (storage.encryption.keys)', {
+                statusCode: 503,
+            });
+        }
+
+        const existing = await this.store.getSecret(tenantId, name);
+        const now = new Date();
+        const record: SecretRecord = {
+            tenantId,
+            name,
+            value: await this.keyring.seal(value),
+            version: (existing?.version ?? 0) + 1,
+            createdAt: existing?.createdAt ?? now,
+            updatedAt: now,
+        };
+        await this.store.saveSecret(record);
+        this.values.set(reference, value);
+        this.logger?.info(existing ? 'secret_rotated' : 'secret_created', {
+            audit: true,
+            tenantId,
+            name,
+            version: record.version,
+        });
+        return this.info(record);
+    }
+
+    /**
+     * Deletes a tenant's secret; references to it stop resolving.
+     */
+    async delete(tenantId: string, name: string): Promise<void> {
+        const reference = checkReference(tenantId, name);
+        if (!await this.store.deleteSecret(tenantId, name)) {
+            throw errNotFound(`Secret '${reference}' not found`);
+        }
+        this.values.delete(reference);
+        this.logger?.info('secret_deleted', { audit: true, tenantId, name });
+    }
+
+    /**
+     * Returns a tenant's secret's metadata.
+     */
+    async get(tenantId: string, name: string): Promise<SecretInfo> {
+        const reference = checkReference(tenantId, name);
+        const record = await this.store.getSecret(tenantId, name);
+        if (!record) {
+            throw errNotFound(`Secret '${reference}' not found`);
+        }
+        return this.info(record);
+    }
+
+    /**
+     * Lists a tenant's secrets' metadata, by name.
+     */
+    async list(tenantId: string): Promise<SecretInfo[]> {
+        const records = await this.store.listSecrets(tenantId);
+        return records
+            .sort((a, b) => a.name.localeCompare(b.name))
+            .map((record) => this.info(record));
+    }
+
+    /**
+     * Returns the value a reference names. Throws a secret_unavailable
+     * error, naming the reference, when it doesn't resolve.
+     */
+    resolve(ref: SecretRef): string {
+        const value = this.values.get(ref.secret);
+        if (value === undefined) {
+            throw errSecretUnavailable(ref.secret);
+        }
+        return value;
+    }
+
+    private info(record: SecretRecord): SecretInfo {
+        return {
+            tenantId: record.tenantId,
+            name: record.name,
+            reference: referenceOf(record.tenantId, record.name),
+            version: record.version,
+            keyId: this.keyring.keyIdOf(record.value),
+            createdAt: record.createdAt,
+            updatedAt: record.updatedAt,
+        };
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Whether a string is a well-formed secret reference ("<tenant>/<name>").
+ */
+export function isSecretReference(reference: string): boolean {
+    return REFERENCE_PATTERN.test(reference);
+}
+
+function referenceOf(tenantId: string, name: string): string {
+    return `${tenantId}/${name}`;
+}
+
+function checkReference(tenantId: string, name: string): string {
+    const reference = referenceOf(tenantId, name);
+    if (!SECRET_NAME_PATTERN.test(name) || !isSecretReference(reference)) {
+        throw errInvalidRequest(`Invalid secret name '${name}': use letters, digits, '_', '.', and '-'`);
+    }
+    return reference;
+}
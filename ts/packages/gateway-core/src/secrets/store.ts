@@ -0,0 +1,57 @@
+/**
+ * In-memory secret store.
+ *
+ * @module secrets/store
+ */
+
+import type { SecretRecord, SecretStore, StorageProvider } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Secret Store
+// ============================================================================
+
+/**
+ * Process-local secrets, still sealed. Used when the configured storage
+ * provider does not implement SecretStore; secrets are lost on restart.
+ */
+export class MemorySecretStore implements SecretStore {
+    private readonly secrets = new Map<string, SecretRecord>();
+
+    async saveSecret(secret: SecretRecord): Promise<void> {
+        this.secrets.set(`${secret.tenantId}/${secret.name}`, { ...secret });
+    }
+
+    async getSecret(tenantId: string, name: string): Promise<SecretRecord | null> {
+        const secret = this.secrets.get(`${tenantId}/${name}`);
+        return secret ? { ...secret } : null;
+    }
+
+    async listSecrets(tenantId?: string): Promise<SecretRecord[]> {
+        return [...this.secrets.values()]
+            .filter((secret) => tenantId === undefined || secret.tenantId === tenantId)
+            .map((secret) => ({ ...secret }));
+    }
+
+    async deleteSecret(tenantId: string, name: string): Promise<boolean> {
+        return this.secrets.delete(`${tenantId}/${name}`);
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements SecretStore.
+ */
+export function isSecretStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & SecretStore {
+    return (
+        storage !== undefined &&
+        typeof storage.saveSecret === 'function' &&
+        typeof storage.getSecret === 'function' &&
+        typeof storage.listSecrets === 'function' &&
+        typeof storage.deleteSecret === 'function'
+    );
+}
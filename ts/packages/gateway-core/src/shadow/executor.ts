@@ -38,6 +38,9 @@ export interface ShadowExecutorOptions {
 
     /** Default timeout in ms. */
     defaultTimeoutMs?: number | undefined;
+
+    /** The providers a tenant's requests may be sent to (undefined: any). */
+    allowedProviders?: ((tenantId: string) => string[] | undefined) | undefined;
 }
 
 // ============================================================================
@@ -52,16 +55,20 @@ export class ShadowExecutor {
     private readonly storage?: StorageProvider;
     private readonly logger?: Logger;
     private readonly defaultTimeoutMs: number;
+    private readonly allowedProviders?: ((tenantId: string) => string[] | undefined) | undefined;
 
     constructor(options: ShadowExecutorOptions) {
         this.providerRegistry = options.providerRegistry;
         this.storage = options.storage;
         this.logger = options.logger;
         this.defaultTimeoutMs = options.defaultTimeoutMs ?? 30000;
+        this.allowedProviders = options.allowedProviders;
     }
 
     /**
-     * Executes a shadow request for a single provider.
+     * Executes a shadow request for a single provider. A provider outside
+     * the tenant's allowlist is never called; the shadow is recorded as
+     * failed with a provider_policy_denied error.
      */
     async executeOne(
         interactionId: string,
@@ -73,6 +80,26 @@ export class ShadowExecutor {
         const startTime = Date.now();
         const providerName = providerConfig.name;
 
+        const allowed = this.allowedProviders?.(request.tenantId);
+        if (allowed && !allowed.includes(providerName)) {
+            this.logger?.warn('provider_policy_denied', {
+                interactionId,
+                shadowProvider: providerName,
+                allowed: allowed.join(', '),
+            });
+            const result = createShadowResult(interactionId, providerName, 0, {
+                error: {
+                    type: 'provider_policy_denied',
+                    message: `Provider '${providerName}' is not allowed for this tenant`,
+                    statusCode: 403,
+                },
+            });
+            if (this.storage) {
+                await this.storage.saveShadowResult(result);
+            }
+            return result;
+        }
+
         // Get shadow provider
         const shadowProvider = this.providerRegistry.create(providerName, {
             name: providerName,
@@ -0,0 +1,139 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { createProviderRegistry, hashAPIKey } from './ports/index';
+import type { AppConfig, GatewayConfig } from './ports/index';
+import { harness, scenario, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const chat = { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] };
+
+describe('Apps sharing a path', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        gateway = await harness()
+            .app({
+                name: 'internal',
+                frontdoor: 'openai',
+                path: '/v1',
+                modelRouting: { rewrites: [{ modelExact: 'gpt-4o', provider: 'internal-llm', model: 'internal-large' }] },
+            })
+            .app({
+                name: 'partner',
+                frontdoor: 'openai',
+                path: '/v1',
+                allowedModels: ['gpt-4o', 'partner-*'],
+                modelRouting: { rewrites: [{ modelExact: 'gpt-4o', provider: 'partner-llm', model: 'partner-small' }] },
+                pipeline: { stages: [{ name: 'partner-policy', type: 'pre', url: 'http://hooks/partner' }] },
+                middleware: [{ name: 'ratelimit', params: { requests: 2, window: '1m' } }],
+            })
+            .provider(new ScriptedProvider('internal-llm', { text: 'Internal' }))
+            .provider(new ScriptedProvider('partner-llm', { text: 'Partner' }))
+            .config({
+                tenants: [
+                    {
+                        id: 'acme',
+                        name: 'Acme',
+                        apiKeys: [
+                            { keyHash: await hashAPIKey('sk-internal') },
+                            { keyHash: await hashAPIKey('sk-partner'), app: 'partner' },
+                        ],
+                    },
+                ],
+            })
+            .start();
+        return gateway;
+    }
+
+    const as = (key: string) => ({ Authorization: `Bearer ${key}` });
+
+    it('should serve each key from its bound app, and unbound keys from the first', async () => {
+        const gw = await setup();
+
+        const [internal, partner] = await scenario(gw)
+            .turn({
+                path: '/v1/chat/completions',
+                body: chat,
+                headers: as('sk-internal'),
+                provider: 'internal-llm',
+                sent: (request) => expect(request.model).toBe('internal-large'),
+            })
+            .turn({
+                path: '/v1/chat/completions',
+                body: chat,
+                headers: as('sk-partner'),
+                provider: 'partner-llm',
+                sent: (request) => expect(request.model).toBe('partner-small'),
+            })
+            .run();
+
+        expect(internal!.json.choices[0].message.content).toBe('Internal');
+        expect(partner!.json.choices[0].message.content).toBe('Partner');
+
+        // Only the partner app's pipeline ran, and only for its key
+        expect(gw.webhookCalls.map((c) => c.url)).toEqual(['http://hooks/partner']);
+
+        // Each interaction is recorded under the app that served it
+        await vi.waitFor(() => expect(gw.published.filter((e) => e.type === 'interaction_completed')).toHaveLength(2));
+        const apps = new Map(gw.published.map((e) => [e.interactionId, (e.data as { appName?: string }).appName]));
+        expect(apps.get(internal!.interactionId)).toBe('internal');
+        expect(apps.get(partner!.interactionId)).toBe('partner');
+    });
+
+    it('should apply the bound app\'s model allow-list', async () => {
+        const gw = await setup();
+
+        await scenario(gw)
+            .turn({ path: '/v1/chat/completions', body: { ...chat, model: 'gpt-4o-mini' }, headers: as('sk-internal'), provider: 'internal-llm' })
+            .turn({ path: '/v1/chat/completions', body: { ...chat, model: 'gpt-4o-mini' }, headers: as('sk-partner'), status: 400 })
+            .run();
+    });
+
+    it('should rate limit only the keys of the app with the limit', async () => {
+        const gw = await setup();
+        const send = (key: string) => gw.post('/v1/chat/completions', chat, as(key)).then((r) => r.status);
+
+        expect([await send('sk-partner'), await send('sk-partner'), await send('sk-partner')]).toEqual([200, 200, 429]);
+        expect([await send('sk-internal'), await send('sk-internal'), await send('sk-internal')]).toEqual([200, 200, 200]);
+    });
+
+    it('should look a key up once per request', async () => {
+        const gw = await setup();
+        const authenticate = vi.spyOn(gw.gateway.tenants, 'authenticate');
+
+        expect((await gw.post('/v1/chat/completions', chat, as('sk-partner'))).status).toBe(200);
+        expect(authenticate).toHaveBeenCalledTimes(1);
+        expect((await gw.post('/v1/chat/completions', chat, as('sk-unknown'))).status).toBe(200);
+        expect(authenticate).toHaveBeenCalledTimes(2);
+    });
+});
+
+describe('Shared path validation', () => {
+    async function load(apps: AppConfig[], tenants: GatewayConfig['tenants'] = []): Promise<Gateway> {
+        const gateway = new Gateway({
+            config: { load: async () => ({ apps, tenants, providers: [{ name: 'mock', type: 'mock' }], routing: { defaultProvider: 'mock' } }) },
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            providerRegistry: createProviderRegistry(),
+        });
+        await gateway.reload();
+        return gateway;
+    }
+
+    it('should require apps sharing a path to use the same frontdoor', async () => {
+        await expect(load([
+            { name: 'internal', frontdoor: 'openai', path: '/v1' },
+            { name: 'partner', frontdoor: 'anthropic', path: '/v1/' },
+        ])).rejects.toThrow("Invalid config for app 'partner': shares path '/v1/' with app 'internal' but uses frontdoor 'anthropic', not 'openai'");
+    });
+
+    it('should reject keys bound to an unknown app', async () => {
+        await expect(load(
+            [{ name: 'internal', frontdoor: 'openai', path: '/v1' }],
+            [{ id: 'acme', name: 'Acme', apiKeys: [{ keyHash: 'abc', app: 'partner' }] }],
+        )).rejects.toThrow("Invalid config for tenant 'acme': an API key is bound to unknown app 'partner'");
+    });
+});
@@ -0,0 +1,227 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { SloTracker, MemorySloStore } from './slo/index';
+import type { SloConfig } from './ports/index';
+import { APIError } from './domain/errors';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const ALERT = { webhook: 'https://alerts.example/hook', headers: { Authorization: 'Bearer ${env:ALERT_TOKEN}' } };
+
+function setup(config: SloConfig, store = new MemorySloStore()) {
+    let now = Date.UTC(2025, 0, 1);
+    const fetch = vi.fn(async (_url: string | URL | Request, _init?: RequestInit) => new Response(null, { status: 204 }));
+    const tracker = new SloTracker({
+        store,
+        env: { ALERT_TOKEN: 's3cret' },
+        fetch: fetch as typeof globalThis.fetch,
+        clock: () => now,
+    });
+    const configure = () => tracker.configure(new Map([['chat', config]]));
+    const record = async (count: number, sample: { latencyMs?: number; error?: boolean } = {}) => {
+        for (let i = 0; i < count; i++) {
+            await tracker.record('chat', { latencyMs: sample.latencyMs ?? 200, error: sample.error ?? false });
+        }
+    };
+    return {
+        tracker,
+        fetch,
+        store,
+        configure,
+        record,
+        advance: (ms: number) => {
+            now += ms;
+        },
+    };
+}
+
+describe('SloTracker', () => {
+    let tracker: SloTracker | undefined;
+
+    afterEach(async () => {
+        await tracker?.close();
+        tracker = undefined;
+    });
+
+    it('should alert on a fast burn at most once per cooldown', async () => {
+        const slo = setup({ availability: 99.5, alert: { ...ALERT, cooldown: '30m' } });
+        tracker = slo.tracker;
+        await slo.configure();
+
+        // An hour of healthy traffic, then an outage
+        for (let minute = 0; minute < 55; minute++) {
+            await slo.record(2);
+            slo.advance(60_000);
+        }
+        expect(slo.fetch).not.toHaveBeenCalled();
+        for (let minute = 0; minute < 5; minute++) {
+            await slo.record(10, { error: true });
+            slo.advance(60_000);
+        }
+
+        expect(slo.fetch).toHaveBeenCalledTimes(1);
+        const [url, init] = slo.fetch.mock.calls[0]!;
+        expect(url).toBe('https://alerts.example/hook');
+        expect((init!.headers as Record<string, string>).Authorization).toBe('Bearer s3cret');
+        const alert = JSON.parse(init!.body as string);
+        expect(alert).toMatchObject({ type: 'app_slo_burn_alert', app: 'chat', threshold: 14.4 });
+        expect(alert.objectives).toEqual([{
+            objective: 'availability',
+            burnRate: { short: expect.any(Number), long: expect.any(Number) },
+            errorBudgetRemaining: expect.any(Number),
+        }]);
+        expect(alert.objectives[0].burnRate.short).toBeGreaterThanOrEqual(14.4);
+        expect(alert.objectives[0].burnRate.long).toBeGreaterThanOrEqual(14.4);
+
+        // Still burning: silent until the cooldown has passed
+        for (let minute = 0; minute < 25; minute++) {
+            await slo.record(10, { error: true });
+            slo.advance(60_000);
+        }
+        expect(slo.fetch).toHaveBeenCalledTimes(1);
+        await slo.record(10, { error: true });
+        expect(slo.fetch).toHaveBeenCalledTimes(2);
+        expect(slo.tracker.report()[0]!.lastAlertAt).toBeInstanceOf(Date);
+    });
+
+    it('should not alert on a short spike the long window absorbs', async () => {
+        const slo = setup({ availability: 99.5, alert: ALERT });
+        tracker = slo.tracker;
+        await slo.configure();
+
+        for (let minute = 0; minute < 60; minute++) {
+            await slo.record(100);
+            slo.advance(60_000);
+        }
+        await slo.record(100, { error: true });
+
+        const report = slo.tracker.report()[0]!;
+        expect(report.availability!.burnRate.short).toBeGreaterThanOrEqual(14.4);
+        expect(report.availability!.burnRate.long).toBeLessThan(14.4);
+        expect(slo.fetch).not.toHaveBeenCalled();
+    });
+
+    it('should report compliance and error budget remaining per objective', async () => {
+        const slo = setup({ latencyP95Ms: 1000, availability: 99 });
+        tracker = slo.tracker;
+        await slo.configure();
+
+        await slo.record(890, { latencyMs: 400 });
+        await slo.record(100, { latencyMs: 2000 });
+        await slo.record(5, { latencyMs: 400, error: true });
+        await slo.record(5, { latencyMs: 20_000, error: true });
+
+        const [report] = slo.tracker.report();
+        expect(report).toMatchObject({ app: 'chat', window: '28d', requests: 1000, p95Ms: 2000 });
+        expect(report!.latency).toMatchObject({
+            objective: 1000,
+            target: 0.95,
+            bad: 105,
+            compliance: 0.895,
+            met: false,
+            errorBudgetRemaining: -1.1,
+        });
+        expect(report!.availability).toMatchObject({
+            objective: 99,
+            target: 0.99,
+            bad: 10,
+            compliance: 0.99,
+            met: true,
+            errorBudgetRemaining: 0,
+            burnRate: { short: 1, long: 1 },
+        });
+    });
+
+    it('should drop requests older than the window', async () => {
+        const slo = setup({ availability: 99.5, window: '1d' });
+        tracker = slo.tracker;
+        await slo.configure();
+
+        await slo.record(10, { error: true });
+        slo.advance(25 * 60 * 60_000);
+        await slo.record(10);
+
+        expect(slo.tracker.report()[0]).toMatchObject({
+            requests: 10,
+            availability: { bad: 0, compliance: 1, errorBudgetRemaining: 1, burnRate: { short: 0, long: 0 } },
+        });
+    });
+
+    it('should pick up a saved window after a restart, unless the objectives changed', async () => {
+        const store = new MemorySloStore();
+        const before = setup({ availability: 99.5 }, store);
+        await before.configure();
+        await before.record(20);
+        await before.record(2, { error: true });
+        await before.tracker.close();
+
+        const after = setup({ availability: 99.5, alert: ALERT }, store);
+        tracker = after.tracker;
+        await after.configure();
+        expect(after.tracker.report()[0]).toMatchObject({ requests: 22, availability: { bad: 2 } });
+
+        const changed = setup({ availability: 99.9 }, store);
+        await changed.configure();
+        expect(changed.tracker.report()[0]).toMatchObject({ requests: 0, availability: { compliance: null, met: true } });
+        await changed.tracker.close();
+    });
+
+    it('should ignore apps without an SLO', async () => {
+        const slo = setup({ availability: 99.5 });
+        tracker = slo.tracker;
+        await slo.configure();
+
+        await slo.tracker.record('other', { latencyMs: 100, error: true });
+
+        expect(slo.tracker.report().map((r) => r.app)).toEqual(['chat']);
+    });
+});
+
+describe('Gateway SLOs', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    it('should count requests and server errors, but not client errors', async () => {
+        const provider = new ScriptedProvider('mock', (request) => {
+            if (request.messages[0]?.content === 'fail') {
+                throw new APIError('server', 'upstream unavailable', { statusCode: 503 });
+            }
+            return { text: 'Hello' };
+        });
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', slo: { latencyP95Ms: 3000, availability: 99.5 } })
+            .app({ name: 'other', frontdoor: 'anthropic', path: '/anthropic' })
+            .provider(provider)
+            .start();
+        const gw = gateway;
+        const admin = new AdminHandler({ slo: () => gw.gateway.sloReport() });
+        const ask = (content: string, stream = false) =>
+            gw.post('/v1/chat/completions', { model: 'gpt-4o', stream, messages: [{ role: 'user', content }] });
+
+        await ask('Hello');
+        await (await ask('Hello', true)).text();
+        await ask('fail');
+        await gw.post('/v1/chat/completions', { model: 'gpt-4o' });
+
+        await vi.waitFor(() => expect(gw.gateway.sloReport()[0]?.requests).toBe(3));
+        const response = await admin.handle(new Request('http://localhost/api/slo'));
+        expect(response.status).toBe(200);
+        const { apps } = await response.json();
+        expect(apps).toHaveLength(1);
+        expect(apps[0]).toMatchObject({
+            app: 'chat',
+            requests: 3,
+            latency: { objective: 3000, bad: 0, met: true },
+            availability: { objective: 99.5, bad: 1, met: false },
+        });
+    });
+
+    it('should answer 503 without an SLO source', async () => {
+        const response = await new AdminHandler({}).handle(new Request('http://localhost/api/slo'));
+
+        expect(response.status).toBe(503);
+    });
+});
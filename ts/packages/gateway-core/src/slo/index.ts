@@ -0,0 +1,28 @@
+/**
+ * Per-app SLO exports.
+ *
+ * @module slo
+ */
+
+export {
+    SloTracker,
+    DEFAULT_SLO_WINDOW,
+    DEFAULT_SLO_FAST_BURN_RATE,
+    DEFAULT_SLO_SHORT_WINDOW_MS,
+    DEFAULT_SLO_LONG_WINDOW_MS,
+    DEFAULT_SLO_ALERT_COOLDOWN_MS,
+    DEFAULT_SLO_SNAPSHOT_INTERVAL_MS,
+    MIN_SLO_ALERT_REQUESTS,
+    SLO_LATENCY_BOUNDS_MS,
+    type SloTrackerOptions,
+    type SloSample,
+    type SloBurnRates,
+    type SloObjectiveReport,
+    type SloReport,
+    type SloAlert,
+} from './tracker.js';
+
+export {
+    MemorySloStore,
+    isSloStore,
+} from './store.js';
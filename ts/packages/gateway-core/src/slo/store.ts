@@ -0,0 +1,45 @@
+/**
+ * In-memory SLO snapshot store.
+ *
+ * @module slo/store
+ */
+
+import type { SloSnapshotRecord, SloStore, StorageProvider } from '../ports/storage.js';
+
+// ============================================================================
+// Memory SLO Store
+// ============================================================================
+
+/**
+ * Process-local SLO snapshots, the latest per app.
+ * Used when the configured storage provider does not implement SloStore.
+ */
+export class MemorySloStore implements SloStore {
+    private readonly snapshots = new Map<string, SloSnapshotRecord>();
+
+    async saveSloSnapshot(snapshot: SloSnapshotRecord): Promise<void> {
+        this.snapshots.set(snapshot.app, structuredClone(snapshot));
+    }
+
+    async getSloSnapshot(app: string): Promise<SloSnapshotRecord | null> {
+        const snapshot = this.snapshots.get(app);
+        return snapshot ? structuredClone(snapshot) : null;
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements SloStore.
+ */
+export function isSloStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & SloStore {
+    return (
+        storage !== undefined &&
+        typeof storage.saveSloSnapshot === 'function' &&
+        typeof storage.getSloSnapshot === 'function'
+    );
+}
@@ -0,0 +1,553 @@
+/**
+ * Per-app service level objectives.
+ *
+ * Apps with an slo config have their requests counted in rolling time
+ * slices: per minute for the burn windows, per hour for the compliance
+ * window (28 days by default). A request is slow when it takes longer than
+ * the latency objective (streams count the time to their first token) and
+ * failed when it ends in a server error; client errors and requests the
+ * client abandoned count for neither. Each objective has an error budget,
+ * the share of requests allowed to be bad (5% for a p95 latency, 0.5% for
+ * 99.5% availability), and a burn rate, how many times faster than the
+ * window allows the budget is being spent. When an objective burns at the
+ * fast-burn rate over both the short and the long window, the app's alert
+ * webhook is called (with a cooldown). The slices are snapshotted to the
+ * store periodically, so a restart doesn't reset the window.
+ *
+ * @module slo/tracker
+ */
+
+import type { SloConfig } from '../ports/config.js';
+import type { SloBucketRecord, SloSnapshotRecord, SloStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { postAlert } from '../utils/alerts.js';
+import { parseDuration } from '../utils/duration.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default compliance window. */
+export const DEFAULT_SLO_WINDOW = '28d';
+
+/** Default burn rate over both windows that trips an alert. */
+export const DEFAULT_SLO_FAST_BURN_RATE = 14.4;
+
+/** Default short burn window. */
+export const DEFAULT_SLO_SHORT_WINDOW_MS = 5 * 60_000;
+
+/** Default long burn window. */
+export const DEFAULT_SLO_LONG_WINDOW_MS = 60 * 60_000;
+
+/** Default minimum time between alerts for an app. */
+export const DEFAULT_SLO_ALERT_COOLDOWN_MS = 60 * 60_000;
+
+/** Default time between snapshots of changed windows. */
+export const DEFAULT_SLO_SNAPSHOT_INTERVAL_MS = 60_000;
+
+/** Requests needed in the short window before an alert can fire. */
+export const MIN_SLO_ALERT_REQUESTS = 10;
+
+/** Upper bounds of the latency histogram buckets (ms); a last bucket holds slower requests. */
+export const SLO_LATENCY_BOUNDS_MS = [
+    100, 250, 500, 750, 1000, 1500, 2000, 2500, 3000, 4000, 5000, 7500, 10_000, 15_000, 30_000, 60_000,
+];
+
+/** Share of requests a p95 latency objective allows to be slow. */
+const LATENCY_BUDGET = 0.05;
+
+const MINUTE_MS = 60_000;
+const HOUR_MS = 60 * MINUTE_MS;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * SLO tracker options.
+ */
+export interface SloTrackerOptions {
+    /** Where window snapshots are saved. */
+    store: SloStore;
+
+    /** Variables for ${env:VAR} references in alert webhook headers. */
+    env?: Record<string, string | undefined> | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Fetch implementation for alert webhooks (default: global fetch). */
+    fetch?: typeof fetch | undefined;
+
+    /** Clock, for tests. */
+    clock?: (() => number) | undefined;
+
+    /** Time between snapshots (default: 1m). */
+    snapshotIntervalMs?: number | undefined;
+}
+
+/**
+ * One finished request, as an SLO counts it.
+ */
+export interface SloSample {
+    /** End-to-end latency, or the time to the first token for streams (ms). */
+    latencyMs: number;
+
+    /** Whether the request failed with a server error. */
+    error: boolean;
+}
+
+/** Burn rates over the short and long windows. */
+export interface SloBurnRates {
+    short: number;
+    long: number;
+}
+
+/**
+ * Compliance with one objective over the window.
+ */
+export interface SloObjectiveReport {
+    /** The objective: a latency in ms, or an availability percentage. */
+    objective: number;
+
+    /** Share of requests that must be good (0-1). */
+    target: number;
+
+    /** Requests in the window that were slow, or failed. */
+    bad: number;
+
+    /** Share of requests in the window that were good, or null with none. */
+    compliance: number | null;
+
+    /** Whether compliance meets the target. */
+    met: boolean;
+
+    /** Share of the window's error budget left (negative once overspent). */
+    errorBudgetRemaining: number;
+
+    /** How many times faster than sustainable the budget is burning. */
+    burnRate: SloBurnRates;
+}
+
+/**
+ * An app's SLO compliance, from GET /admin/api/slo.
+ */
+export interface SloReport {
+    /** App name. */
+    app: string;
+
+    /** Compliance window. */
+    window: string;
+
+    /** Requests counted in the window. */
+    requests: number;
+
+    /** Latency p95 over the window, as its histogram bucket's upper bound (ms; requests over 60s read as 60000). */
+    p95Ms?: number | undefined;
+
+    /** The latency objective, when set. */
+    latency?: SloObjectiveReport | undefined;
+
+    /** The availability objective, when set. */
+    availability?: SloObjectiveReport | undefined;
+
+    /** Time of the last burn-rate alert. */
+    lastAlertAt?: Date | undefined;
+}
+
+/**
+ * Body of an SLO burn-rate alert webhook.
+ */
+export interface SloAlert {
+    type: 'app_slo_burn_alert';
+    app: string;
+
+    /** Objectives burning at or over the threshold on both windows. */
+    objectives: Array<{
+        objective: 'latency' | 'availability';
+        burnRate: SloBurnRates;
+        errorBudgetRemaining: number;
+    }>;
+
+    /** The fast-burn threshold. */
+    threshold: number;
+
+    timestamp: string;
+}
+
+/** Tracking state for one app. */
+interface SloState {
+    app: string;
+    key: string;
+    config: SloConfig;
+    windowMs: number;
+    minutes: SloBucketRecord[];
+    hours: SloBucketRecord[];
+    lastAlertAt?: number | undefined;
+    dirty: boolean;
+}
+
+// ============================================================================
+// SLO Tracker
+// ============================================================================
+
+/**
+ * Tracks apps' SLOs and alerts on fast burns.
+ */
+export class SloTracker {
+    private readonly store: SloStore;
+    private readonly env: Record<string, string | undefined>;
+    private readonly logger?: Logger | undefined;
+    private readonly fetch: typeof fetch;
+    private readonly clock: () => number;
+    private readonly snapshotIntervalMs: number;
+    private readonly states = new Map<string, SloState>();
+    private timer?: ReturnType<typeof setInterval> | undefined;
+
+    constructor(options: SloTrackerOptions) {
+        this.store = options.store;
+        this.env = options.env ?? {};
+        this.logger = options.logger;
+        this.fetch = options.fetch ?? ((input, init) => fetch(input, init));
+        this.clock = options.clock ?? Date.now;
+        this.snapshotIntervalMs = options.snapshotIntervalMs ?? DEFAULT_SLO_SNAPSHOT_INTERVAL_MS;
+    }
+
+    /**
+     * Sets the apps tracked, after a config load. An app whose objectives
+     * are unchanged keeps its window (alert settings may change freely); a
+     * new or changed one starts from its saved snapshot, if that was taken
+     * under the same objectives. Resolves once snapshots are restored.
+     */
+    async configure(slos: Map<string, SloConfig>): Promise<void> {
+        for (const [app, state] of this.states) {
+            const config = slos.get(app);
+            if (!config || objectivesKey(config) !== state.key) {
+                this.states.delete(app);
+            } else {
+                state.config = config;
+            }
+        }
+
+        const restores: Promise<void>[] = [];
+        for (const [app, config] of slos) {
+            if (this.states.has(app)) {
+                continue;
+            }
+            const state: SloState = {
+                app,
+                key: objectivesKey(config),
+                config,
+                windowMs: parseDuration(config.window, parseDuration(DEFAULT_SLO_WINDOW, 0)),
+                minutes: [],
+                hours: [],
+                dirty: false,
+            };
+            this.states.set(app, state);
+            restores.push(this.restore(state));
+        }
+
+        if (this.states.size > 0 && !this.timer) {
+            this.timer = setInterval(() => void this.persist(), this.snapshotIntervalMs);
+            (this.timer as { unref?: () => void }).unref?.();
+        }
+        await Promise.all(restores);
+    }
+
+    /**
+     * Counts a finished request for an app, alerting its webhook if an
+     * objective is now burning fast. Apps without an SLO are ignored.
+     */
+    async record(app: string, sample: SloSample): Promise<void> {
+        const state = this.states.get(app);
+        if (!state) return;
+
+        const now = this.clock();
+        const slow = state.config.latencyP95Ms !== undefined && sample.latencyMs > state.config.latencyP95Ms;
+        add(state.minutes, MINUTE_MS, now, sample, slow);
+        add(state.hours, HOUR_MS, now, sample, slow);
+        this.prune(state, now);
+        state.dirty = true;
+        await this.checkAlert(state, now);
+    }
+
+    /**
+     * Returns each tracked app's compliance, sorted by app.
+     */
+    report(): SloReport[] {
+        const now = this.clock();
+        return [...this.states.values()]
+            .sort((a, b) => a.app.localeCompare(b.app))
+            .map((state) => this.summarize(state, now));
+    }
+
+    /**
+     * Saves a snapshot of every window changed since the last one.
+     */
+    async persist(): Promise<void> {
+        const saves = [...this.states.values()].filter((state) => state.dirty).map(async (state) => {
+            state.dirty = false;
+            const snapshot: SloSnapshotRecord = {
+                app: state.app,
+                configKey: state.key,
+                minutes: state.minutes.map(copyBucket),
+                hours: state.hours.map(copyBucket),
+                lastAlertAt: state.lastAlertAt !== undefined ? new Date(state.lastAlertAt) : undefined,
+                savedAt: new Date(this.clock()),
+            };
+            try {
+                await this.store.saveSloSnapshot(snapshot);
+            } catch (error) {
+                state.dirty = true;
+                this.logger?.warn('slo_snapshot_save_failed', {
+                    app: state.app,
+                    error: error instanceof Error ? error.message : String(error),
+                });
+            }
+        });
+        await Promise.all(saves);
+    }
+
+    /**
+     * Stops the snapshot schedule and saves a last snapshot.
+     */
+    async close(): Promise<void> {
+        if (this.timer) {
+            clearInterval(this.timer);
+            this.timer = undefined;
+        }
+        await this.persist();
+    }
+
+    // ---- Windows ----
+
+    /**
+     * Merges an app's saved snapshot into its window, if it was taken
+     * under the same config.
+     */
+    private async restore(state: SloState): Promise<void> {
+        let snapshot: SloSnapshotRecord | null;
+        try {
+            snapshot = await this.store.getSloSnapshot(state.app);
+        } catch (error) {
+            this.logger?.warn('slo_snapshot_load_failed', {
+                app: state.app,
+                error: error instanceof Error ? error.message : String(error),
+            });
+            return;
+        }
+        if (!snapshot || snapshot.configKey !== state.key || this.states.get(state.app) !== state) {
+            return;
+        }
+        state.minutes = merge(state.minutes, snapshot.minutes);
+        state.hours = merge(state.hours, snapshot.hours);
+        const lastAlertAt = snapshot.lastAlertAt?.getTime();
+        if (lastAlertAt !== undefined && (state.lastAlertAt === undefined || lastAlertAt > state.lastAlertAt)) {
+            state.lastAlertAt = lastAlertAt;
+        }
+        this.prune(state, this.clock());
+        this.logger?.debug('slo_snapshot_restored', { app: state.app, savedAt: snapshot.savedAt });
+    }
+
+    private prune(state: SloState, now: number): void {
+        const { shortMs, longMs } = burnWindows(state.config);
+        dropBefore(state.minutes, MINUTE_MS, now - Math.max(shortMs, longMs));
+        dropBefore(state.hours, HOUR_MS, now - state.windowMs);
+    }
+
+    private summarize(state: SloState, now: number): SloReport {
+        const window = sum(state.hours, HOUR_MS, now - state.windowMs);
+        const { shortMs, longMs } = burnWindows(state.config);
+        const short = sum(state.minutes, MINUTE_MS, now - shortMs);
+        const long = sum(state.minutes, MINUTE_MS, now - longMs);
+
+        const report: SloReport = {
+            app: state.app,
+            window: state.config.window ?? DEFAULT_SLO_WINDOW,
+            requests: window.total,
+            p95Ms: histogramP95(window.latency, window.total),
+            lastAlertAt: state.lastAlertAt !== undefined ? new Date(state.lastAlertAt) : undefined,
+        };
+        if (state.config.latencyP95Ms !== undefined) {
+            report.latency = objectiveReport(state.config.latencyP95Ms, LATENCY_BUDGET, window.slow, window.total, {
+                short: burnRate(short.slow, short.total, LATENCY_BUDGET),
+                long: burnRate(long.slow, long.total, LATENCY_BUDGET),
+            });
+        }
+        if (state.config.availability !== undefined) {
+            const budget = 1 - state.config.availability / 100;
+            report.availability = objectiveReport(state.config.availability, budget, window.errors, window.total, {
+                short: burnRate(short.errors, short.total, budget),
+                long: burnRate(long.errors, long.total, budget),
+            });
+        }
+        return report;
+    }
+
+    // ---- Alerts ----
+
+    /**
+     * Alerts the app's webhook if an objective burns at the fast-burn rate
+     * over both windows and the cooldown since the last alert has passed.
+     */
+    private async checkAlert(state: SloState, now: number): Promise<void> {
+        const alert = state.config.alert;
+        if (!alert) return;
+
+        const { shortMs } = burnWindows(state.config);
+        if (sum(state.minutes, MINUTE_MS, now - shortMs).total < MIN_SLO_ALERT_REQUESTS) return;
+
+        const threshold = alert.fastBurnRate ?? DEFAULT_SLO_FAST_BURN_RATE;
+        const report = this.summarize(state, now);
+        const objectives: SloAlert['objectives'] = [];
+        for (const objective of ['latency', 'availability'] as const) {
+            const result = report[objective];
+            if (result && result.burnRate.short >= threshold && result.burnRate.long >= threshold) {
+                objectives.push({ objective, burnRate: result.burnRate, errorBudgetRemaining: result.errorBudgetRemaining });
+            }
+        }
+        if (objectives.length === 0) return;
+
+        const cooldownMs = parseDuration(alert.cooldown, DEFAULT_SLO_ALERT_COOLDOWN_MS);
+        if (state.lastAlertAt !== undefined && now - state.lastAlertAt < cooldownMs) {
+            return;
+        }
+        state.lastAlertAt = now;
+        state.dirty = true;
+
+        const body: SloAlert = {
+            type: 'app_slo_burn_alert',
+            app: state.app,
+            objectives,
+            threshold,
+            timestamp: new Date(now).toISOString(),
+        };
+        this.logger?.warn('slo_burn_alert', { app: state.app, objectives: objectives.map((o) => o.objective) });
+        await postAlert(
+            { fetch: this.fetch, env: this.env, logger: this.logger },
+            alert,
+            body,
+            'slo_alert_failed',
+            { app: state.app },
+        );
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/** Identifies an SLO's objectives; windows counted under others are discarded. */
+function objectivesKey(config: SloConfig): string {
+    return JSON.stringify({
+        latencyP95Ms: config.latencyP95Ms,
+        availability: config.availability,
+        window: config.window ?? DEFAULT_SLO_WINDOW,
+    });
+}
+
+function burnWindows(config: SloConfig): { shortMs: number; longMs: number } {
+    return {
+        shortMs: parseDuration(config.alert?.shortWindow, DEFAULT_SLO_SHORT_WINDOW_MS),
+        longMs: parseDuration(config.alert?.longWindow, DEFAULT_SLO_LONG_WINDOW_MS),
+    };
+}
+
+function emptyBucket(start: number): SloBucketRecord {
+    return { start, total: 0, errors: 0, slow: 0, latency: new Array<number>(SLO_LATENCY_BOUNDS_MS.length + 1).fill(0) };
+}
+
+function copyBucket(bucket: SloBucketRecord): SloBucketRecord {
+    return { ...bucket, latency: [...bucket.latency] };
+}
+
+/** Counts a sample in the slice of `sliceMs` holding `now`. */
+function add(buckets: SloBucketRecord[], sliceMs: number, now: number, sample: SloSample, slow: boolean): void {
+    const start = now - (now % sliceMs);
+    let bucket = buckets[buckets.length - 1];
+    if (!bucket || bucket.start < start) {
+        bucket = emptyBucket(start);
+        buckets.push(bucket);
+    }
+    bucket.total++;
+    if (sample.error) bucket.errors++;
+    if (slow) bucket.slow++;
+    const index = SLO_LATENCY_BOUNDS_MS.findIndex((bound) => sample.latencyMs <= bound);
+    bucket.latency[index === -1 ? SLO_LATENCY_BOUNDS_MS.length : index]!++;
+}
+
+/** Drops slices that end at or before `since`. */
+function dropBefore(buckets: SloBucketRecord[], sliceMs: number, since: number): void {
+    while (buckets.length > 0 && buckets[0]!.start + sliceMs <= since) {
+        buckets.shift();
+    }
+}
+
+/** Sums the slices that end after `since`. */
+function sum(buckets: SloBucketRecord[], sliceMs: number, since: number): SloBucketRecord {
+    const total = emptyBucket(since);
+    for (const bucket of buckets) {
+        if (bucket.start + sliceMs <= since) continue;
+        total.total += bucket.total;
+        total.errors += bucket.errors;
+        total.slow += bucket.slow;
+        bucket.latency.forEach((count, i) => {
+            total.latency[i] = (total.latency[i] ?? 0) + count;
+        });
+    }
+    return total;
+}
+
+/** Merges saved slices into live ones, adding the counts of slices both have. */
+function merge(live: SloBucketRecord[], saved: SloBucketRecord[]): SloBucketRecord[] {
+    const byStart = new Map(live.map((bucket) => [bucket.start, bucket]));
+    for (const bucket of saved) {
+        const existing = byStart.get(bucket.start);
+        if (!existing) {
+            byStart.set(bucket.start, copyBucket(bucket));
+            continue;
+        }
+        existing.total += bucket.total;
+        existing.errors += bucket.errors;
+        existing.slow += bucket.slow;
+        bucket.latency.forEach((count, i) => {
+            existing.latency[i] = (existing.latency[i] ?? 0) + count;
+        });
+    }
+    return [...byStart.values()].sort((a, b) => a.start - b.start);
+}
+
+function histogramP95(latency: number[], total: number): number | undefined {
+    if (total === 0) return undefined;
+    const rank = Math.ceil(0.95 * total);
+    let seen = 0;
+    for (let i = 0; i < latency.length; i++) {
+        seen += latency[i]!;
+        if (seen >= rank) {
+            return SLO_LATENCY_BOUNDS_MS[Math.min(i, SLO_LATENCY_BOUNDS_MS.length - 1)];
+        }
+    }
+    return undefined;
+}
+
+function burnRate(bad: number, total: number, budget: number): number {
+    return total === 0 ? 0 : round((bad / total) / budget);
+}
+
+function objectiveReport(objective: number, budget: number, bad: number, total: number, rates: SloBurnRates): SloObjectiveReport {
+    const target = round(1 - budget);
+    const compliance = total === 0 ? null : round((total - bad) / total);
+    return {
+        objective,
+        target,
+        bad,
+        compliance,
+        met: compliance === null || compliance >= target,
+        errorBudgetRemaining: total === 0 ? 1 : round(1 - bad / (budget * total)),
+        burnRate: rates,
+    };
+}
+
+function round(value: number): number {
+    return Math.round(value * 10_000) / 10_000;
+}
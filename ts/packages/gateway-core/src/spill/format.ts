@@ -0,0 +1,112 @@
+/**
+ * Spill file record framing.
+ *
+ * A spill file is a sequence of frames: a 4-byte big-endian payload
+ * length, a 4-byte CRC-32 of the payload, then the payload. A crash or a
+ * full disk can leave the last frame cut short; decoding stops at the
+ * first frame that is incomplete or fails its checksum and reports the
+ * file as damaged, so every frame before it is still recovered.
+ *
+ * @module spill/format
+ */
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Bytes before each payload (length + checksum). */
+export const SPILL_FRAME_HEADER_BYTES = 8;
+
+/** Largest payload a frame may declare; larger lengths mean a damaged header. */
+export const MAX_SPILL_RECORD_BYTES = 16 * 1024 * 1024;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Frames recovered from a spill file.
+ */
+export interface DecodedSpillFrames {
+    /** Payloads of the intact frames, in file order. */
+    payloads: Uint8Array[];
+
+    /** Bytes covered by the intact frames. */
+    validBytes: number;
+
+    /** Whether bytes after the intact frames were discarded. */
+    damaged: boolean;
+}
+
+// ============================================================================
+// Encoding
+// ============================================================================
+
+/**
+ * Frames a payload for appending to a spill file.
+ */
+export function encodeSpillFrame(payload: Uint8Array): Uint8Array {
+    if (payload.length > MAX_SPILL_RECORD_BYTES) {
+        throw new Error(`Spill record of ${payload.length} bytes exceeds ${MAX_SPILL_RECORD_BYTES}`);
+    }
+    const frame = new Uint8Array(SPILL_FRAME_HEADER_BYTES + payload.length);
+    const view = new DataView(frame.buffer);
+    view.setUint32(0, payload.length);
+    view.setUint32(4, crc32(payload));
+    frame.set(payload, SPILL_FRAME_HEADER_BYTES);
+    return frame;
+}
+
+/**
+ * Decodes the frames of a spill file, stopping at the first incomplete or
+ * corrupt one.
+ */
+export function decodeSpillFrames(bytes: Uint8Array): DecodedSpillFrames {
+    const view = new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+    const payloads: Uint8Array[] = [];
+    let offset = 0;
+
+    while (offset + SPILL_FRAME_HEADER_BYTES <= bytes.length) {
+        const length = view.getUint32(offset);
+        const end = offset + SPILL_FRAME_HEADER_BYTES + length;
+        if (length > MAX_SPILL_RECORD_BYTES || end > bytes.length) {
+            break;
+        }
+        const payload = bytes.subarray(offset + SPILL_FRAME_HEADER_BYTES, end);
+        if (crc32(payload) !== view.getUint32(offset + 4)) {
+            break;
+        }
+        payloads.push(payload);
+        offset = end;
+    }
+
+    return { payloads, validBytes: offset, damaged: offset < bytes.length };
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+let crcTable: Uint32Array | undefined;
+
+/**
+ * CRC-32 (IEEE 802.3), as used by zip and gzip.
+ */
+export function crc32(bytes: Uint8Array): number {
+    if (!crcTable) {
+        crcTable = new Uint32Array(256);
+        for (let n = 0; n < 256; n++) {
+            let c = n;
+            for (let k = 0; k < 8; k++) {
+                c = c & 1 ? 0xedb88320 ^ (c >>> 1) : c >>> 1;
+            }
+            crcTable[n] = c >>> 0;
+        }
+    }
+
+    let crc = 0xffffffff;
+    for (const byte of bytes) {
+        crc = crcTable[(crc ^ byte) & 0xff]! ^ (crc >>> 8);
+    }
+    return (crc ^ 0xffffffff) >>> 0;
+}
@@ -0,0 +1,28 @@
+/**
+ * Write spill exports.
+ *
+ * @module spill
+ */
+
+export {
+    WriteSpill,
+    SpillFullError,
+    writeSpillEntry,
+    DEFAULT_SPILL_RETRY_MS,
+    MAX_SPILL_RETRY_MS,
+    type SpillEntry,
+    type SpillTargets,
+    type SpillStorage,
+    type SpillStats,
+    type SpillReplayResult,
+    type WriteSpillOptions,
+} from './queue.js';
+
+export {
+    encodeSpillFrame,
+    decodeSpillFrames,
+    crc32,
+    SPILL_FRAME_HEADER_BYTES,
+    MAX_SPILL_RECORD_BYTES,
+    type DecodedSpillFrames,
+} from './format.js';
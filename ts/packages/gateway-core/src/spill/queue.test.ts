@@ -0,0 +1,268 @@
+import { describe, it, expect, vi } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import type { UsageRecord } from '../ports/index';
+import { MemoryUsageStore } from '../budget/store';
+import {
+    WriteSpill,
+    SpillFullError,
+    encodeSpillFrame,
+    decodeSpillFrames,
+    SPILL_FRAME_HEADER_BYTES,
+    type SpillStorage,
+} from './index';
+import { harness, ScriptedProvider } from '../__tests__/harness/index';
+
+const bytes = (text: string) => new TextEncoder().encode(text);
+const text = (payload: Uint8Array) => new TextDecoder().decode(payload);
+
+function concat(...parts: Uint8Array[]): Uint8Array {
+    const out = new Uint8Array(parts.reduce((n, p) => n + p.length, 0));
+    let offset = 0;
+    for (const part of parts) {
+        out.set(part, offset);
+        offset += part.length;
+    }
+    return out;
+}
+
+/** In-memory SpillStorage with the same segment semantics as the file one. */
+function memorySpill(maxBytes = Infinity) {
+    const segments = new Map<string, Uint8Array>();
+    let active = new Uint8Array();
+    let sequence = 0;
+    const size = () => active.length + [...segments.values()].reduce((n, s) => n + s.length, 0);
+    const storage: SpillStorage = {
+        async append(frame) {
+            if (size() + frame.length > maxBytes) throw new SpillFullError();
+            active = concat(active, frame);
+        },
+        async rotate() {
+            if (active.length === 0) return;
+            segments.set(`segment-${String(sequence++).padStart(6, '0')}`, active);
+            active = new Uint8Array();
+        },
+        async segments() {
+            return [...segments.keys()].sort();
+        },
+        async read(segment) {
+            return segments.get(segment)!;
+        },
+        async remove(segment) {
+            segments.delete(segment);
+        },
+    };
+    return { storage, segments };
+}
+
+/** A usage store whose writes fail while `down` is set. */
+function flakyUsageStore() {
+    const store = new MemoryUsageStore();
+    const state = { down: true };
+    const recordUsage = vi.fn(async (record: UsageRecord) => {
+        if (state.down) throw new Error('SQLITE_BUSY: database is locked');
+        await store.recordUsage(record);
+    });
+    return { state, recordUsage, store };
+}
+
+const usage = (interactionId: string): UsageRecord => ({
+    tenantId: 'acme',
+    interactionId,
+    model: 'gpt-4o',
+    totalTokens: 15,
+    costUsd: 0.01,
+    createdAt: new Date('2025-01-02T08:00:00Z'),
+});
+
+describe('spill frames', () => {
+    it('should round-trip payloads', () => {
+        const file = concat(encodeSpillFrame(bytes('one')), encodeSpillFrame(bytes('')), encodeSpillFrame(bytes('three')));
+
+        const decoded = decodeSpillFrames(file);
+
+        expect(decoded.payloads.map(text)).toEqual(['one', '', 'three']);
+        expect(decoded).toMatchObject({ validBytes: file.length, damaged: false });
+    });
+
+    it('should recover every whole frame before a partial write at any cut point', () => {
+        const first = encodeSpillFrame(bytes('{"kind":"usage"}'));
+        const second = encodeSpillFrame(bytes('{"kind":"request_stat"}'));
+        const file = concat(first, second);
+
+        for (let cut = first.length + 1; cut < file.length; cut++) {
+            const decoded = decodeSpillFrames(file.subarray(0, cut));
+            expect(decoded.payloads.map(text)).toEqual(['{"kind":"usage"}']);
+            expect(decoded).toMatchObject({ validBytes: first.length, damaged: true });
+        }
+        expect(decodeSpillFrames(file.subarray(0, 3))).toEqual({ payloads: [], validBytes: 0, damaged: true });
+    });
+
+    it('should stop at a frame that fails its checksum', () => {
+        const first = encodeSpillFrame(bytes('intact'));
+        const second = encodeSpillFrame(bytes('flipped'));
+        const third = encodeSpillFrame(bytes('after'));
+        const flipped = SPILL_FRAME_HEADER_BYTES + 2;
+        second[flipped] = second[flipped]! ^ 0x01;
+
+        const decoded = decodeSpillFrames(concat(first, second, third));
+
+        expect(decoded.payloads.map(text)).toEqual(['intact']);
+        expect(decoded.damaged).toBe(true);
+    });
+
+    it('should treat an impossible length as damage rather than allocating it', () => {
+        const header = new Uint8Array(SPILL_FRAME_HEADER_BYTES);
+        new DataView(header.buffer).setUint32(0, 0xffffffff);
+
+        expect(decodeSpillFrames(concat(encodeSpillFrame(bytes('ok')), header))).toMatchObject({ damaged: true });
+    });
+});
+
+describe('WriteSpill', () => {
+    it('should spill failed writes and replay them exactly once when storage recovers', async () => {
+        const { storage } = memorySpill();
+        const usageStore = flakyUsageStore();
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+        const spill = new WriteSpill({
+            storage,
+            targets: { usage: usageStore, requestStats: { recordRequestStat: vi.fn() } },
+            logger,
+            clock: () => Date.parse('2025-01-02T08:00:01Z'),
+        });
+
+        await spill.write({ kind: 'usage', record: usage('int_1') });
+        await spill.write({ kind: 'usage', record: usage('int_2') });
+        expect(spill.stats()).toMatchObject({ depth: 2, spilled: 2, oldestSpilledAt: new Date('2025-01-02T08:00:01Z') });
+        expect(logger.warn).toHaveBeenCalledWith('write_spilled', expect.objectContaining({ interactionId: 'int_1' }));
+
+        expect(await spill.replay()).toMatchObject({ replayed: 0, remaining: 2, error: 'SQLITE_BUSY: database is locked' });
+        expect(spill.stats()).toMatchObject({ depth: 2, replayFailures: 1 });
+
+        usageStore.state.down = false;
+        expect(await spill.replay()).toEqual({ replayed: 2, remaining: 0, error: undefined });
+        expect(await spill.replay()).toEqual({ replayed: 0, remaining: 0, error: undefined });
+
+        const replayed = usageStore.recordUsage.mock.calls.slice(-2).map(([record]) => record);
+        expect(replayed).toEqual([usage('int_1'), usage('int_2')]);
+        expect(await usageStore.store.sumUsage('acme', new Date(0))).toEqual({ tokens: 30, costUsd: 0.02, requests: 2 });
+        expect(spill.stats()).toMatchObject({ depth: 0, oldestSpilledAt: undefined, replayed: 2 });
+        await spill.close();
+    });
+
+    it('should store a record once when a crash repeats its replay', async () => {
+        const { storage, segments } = memorySpill();
+        const usageStore = flakyUsageStore();
+        const first = new WriteSpill({ storage, targets: { usage: usageStore, requestStats: { recordRequestStat: vi.fn() } } });
+        await first.write({ kind: 'usage', record: usage('int_1') });
+        await storage.rotate();
+        const segment = [...segments.values()][0]!;
+
+        // The record reached the store, but the process died before the segment was removed
+        usageStore.state.down = false;
+        await first.replay();
+        segments.set('segment-000000', segment);
+
+        const restarted = new WriteSpill({ storage, targets: { usage: usageStore, requestStats: { recordRequestStat: vi.fn() } } });
+        expect(await restarted.replay()).toMatchObject({ replayed: 1, remaining: 0 });
+        expect(await usageStore.store.sumUsage('acme', new Date(0))).toMatchObject({ requests: 1, tokens: 15 });
+    });
+
+    it('should replay what it can from a segment cut short by a crash', async () => {
+        const { storage, segments } = memorySpill();
+        const usageStore = flakyUsageStore();
+        usageStore.state.down = false;
+        const frame = (id: string) => encodeSpillFrame(bytes(JSON.stringify({
+            kind: 'usage',
+            spilledAt: '2025-01-02T08:00:01.000Z',
+            record: { ...usage(id), createdAt: '2025-01-02T08:00:00.000Z' },
+        })));
+        const partial = frame('int_2');
+        segments.set('segment-000000', concat(frame('int_1'), partial.subarray(0, partial.length - 5)));
+
+        const spill = new WriteSpill({ storage, targets: { usage: usageStore, requestStats: { recordRequestStat: vi.fn() } } });
+
+        expect(await spill.replay()).toMatchObject({ replayed: 1, remaining: 0 });
+        expect(usageStore.recordUsage).toHaveBeenCalledWith(usage('int_1'));
+        expect(spill.stats().damagedSegments).toBe(1);
+        expect(segments.size).toBe(0);
+    });
+
+    it('should dead-letter a record to the error log when the spill is full', async () => {
+        const { storage } = memorySpill(0);
+        const logger = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+        const spill = new WriteSpill({
+            storage,
+            targets: { usage: flakyUsageStore(), requestStats: { recordRequestStat: vi.fn() } },
+            logger,
+        });
+
+        await expect(spill.write({ kind: 'usage', record: usage('int_1') })).rejects.toBeInstanceOf(SpillFullError);
+
+        expect(logger.error).toHaveBeenCalledWith('spill_dead_letter', expect.objectContaining({
+            interactionId: 'int_1',
+            record: expect.objectContaining({ totalTokens: 15, costUsd: 0.01 }),
+        }));
+        expect(spill.stats()).toMatchObject({ deadLettered: 1, depth: 0 });
+    });
+});
+
+describe('Gateway write spill', () => {
+    it('should spill failed usage writes and report them on the admin API', async () => {
+        const { storage: spillStorage } = memorySpill();
+        const usageStore = flakyUsageStore();
+        const statsDown = { down: true };
+        const storage = {
+            recordUsage: usageStore.recordUsage,
+            sumUsage: (tenantId: string, since: Date) => usageStore.store.sumUsage(tenantId, since),
+            recordRequestStat: async () => {
+                if (statsDown.down) throw new Error('disk full');
+            },
+            aggregateUsageStats: async () => [],
+        };
+        const spillStorageFactory = vi.fn(() => spillStorage);
+        const auth = {
+            authenticate: async (token: string) => ({ tenantId: token, scopes: ['admin'], metadata: {} }),
+            getTenant: async () => null,
+        };
+        const gw = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('mock', { text: 'ok' }))
+            .config({ storage: { type: 'memory', spill: { dir: '/var/spill', replayInterval: '1h' } } })
+            .options({ auth, storage: storage as any, spillStorageFactory })
+            .start();
+        const gateway = gw.gateway;
+        await gateway.reload();
+        const admin = new AdminHandler({
+            auth,
+            spill: () => gateway.spillStats(),
+            replaySpill: () => gateway.replaySpill(),
+        });
+        const adminRequest = (method: string, path: string) => admin.handle(new Request(`http://localhost${path}`, {
+            method,
+            headers: { Authorization: 'Bearer ops' },
+        }));
+
+        await gw.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] });
+        await vi.waitFor(() => expect(gateway.spillStats()?.spilled).toBe(2));
+
+        expect(spillStorageFactory).toHaveBeenCalledWith(expect.objectContaining({ dir: '/var/spill' }));
+        const stats = await (await adminRequest('GET', '/api/stats')).json();
+        expect(stats.spill).toMatchObject({ depth: 2, oldestSpilledAt: expect.any(String) });
+
+        usageStore.state.down = false;
+        statsDown.down = false;
+        const replay = await adminRequest('POST', '/api/maintenance/replay-spill');
+        expect(replay.status).toBe(200);
+        expect(await replay.json()).toEqual({ replayed: 2, remaining: 0 });
+        expect(await usageStore.store.sumUsage('acme', new Date(0))).toMatchObject({ requests: 1, tokens: 15 });
+        await gw.close();
+    });
+
+    it('should answer 503 for a replay when no spill is configured', async () => {
+        const admin = new AdminHandler({ replaySpill: async () => undefined });
+
+        const response = await admin.handle(new Request('http://localhost/api/maintenance/replay-spill', { method: 'POST' }));
+
+        expect(response.status).toBe(503);
+    });
+});
@@ -0,0 +1,465 @@
+/**
+ * Durable spill for usage writes that fail.
+ *
+ * Usage records and request stats are what billing reconciles against, so
+ * a write that fails (storage locked, disk full, network blip) is not just
+ * logged: the record is appended to a spill file and a background loop
+ * replays it to storage, backing off exponentially while storage keeps
+ * failing. Stores insert idempotently by interaction ID, so a record that
+ * is replayed again after a crash, or that had actually been written
+ * before its error, is still stored once. When the spill itself is full
+ * or unwritable, the record is dead-lettered to the error log.
+ *
+ * @module spill/queue
+ */
+
+import type { RequestStatRecord, UsageRecord, UsageStatsStore, UsageStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { decodeSpillFrames, encodeSpillFrame } from './format.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default delay before retrying a failed replay (doubles per failure). */
+export const DEFAULT_SPILL_RETRY_MS = 5000;
+
+/** Longest delay between replay attempts. */
+export const MAX_SPILL_RETRY_MS = 300_000;
+
+/**
+ * A write that can be spilled.
+ */
+export type SpillEntry =
+    | { kind: 'usage'; record: UsageRecord }
+    | { kind: 'request_stat'; record: RequestStatRecord };
+
+/**
+ * The stores spilled records are written (and replayed) to.
+ */
+export interface SpillTargets {
+    usage: Pick<UsageStore, 'recordUsage'>;
+    requestStats: Pick<UsageStatsStore, 'recordRequestStat'>;
+}
+
+/**
+ * Durable, append-only storage for spill frames, split into segments.
+ * Runtimes with a filesystem supply this (e.g., files in a directory on
+ * Node.js). Calls are never concurrent.
+ */
+export interface SpillStorage {
+    /**
+     * Appends a frame to the active segment. Throws SpillFullError when
+     * the frame would exceed the size cap.
+     */
+    append(frame: Uint8Array): Promise<void>;
+
+    /**
+     * Closes the active segment so it can be replayed. No-op when empty.
+     */
+    rotate(): Promise<void>;
+
+    /**
+     * Lists closed segments, oldest first.
+     */
+    segments(): Promise<string[]>;
+
+    /**
+     * Reads a closed segment.
+     */
+    read(segment: string): Promise<Uint8Array>;
+
+    /**
+     * Deletes a replayed segment.
+     */
+    remove(segment: string): Promise<void>;
+}
+
+/**
+ * Thrown by SpillStorage.append when the spill has reached its size cap.
+ */
+export class SpillFullError extends Error {
+    constructor(message = 'Spill is full') {
+        super(message);
+        this.name = 'SpillFullError';
+    }
+}
+
+/**
+ * Spill counters, as reported by /admin/api/stats.
+ */
+export interface SpillStats {
+    /** Records waiting to be replayed. */
+    depth: number;
+
+    /** When the oldest waiting record was spilled. */
+    oldestSpilledAt?: Date | undefined;
+
+    /** Records spilled. */
+    spilled: number;
+
+    /** Records replayed to storage. */
+    replayed: number;
+
+    /** Replay attempts that stopped on a storage error. */
+    replayFailures: number;
+
+    /** Error from the last failed replay attempt. */
+    lastReplayError?: string | undefined;
+
+    /** Records dead-lettered to the error log because they couldn't be spilled. */
+    deadLettered: number;
+
+    /** Segments with a cut-short or corrupt tail, e.g. from a crash mid-write. */
+    damagedSegments: number;
+}
+
+/**
+ * Outcome of one replay pass.
+ */
+export interface SpillReplayResult {
+    /** Records written to storage. */
+    replayed: number;
+
+    /** Records still waiting. */
+    remaining: number;
+
+    /** Storage error that stopped the pass. */
+    error?: string | undefined;
+}
+
+/**
+ * Write spill options.
+ */
+export interface WriteSpillOptions {
+    /** Where spilled records are kept. */
+    storage: SpillStorage;
+
+    /** Stores records are replayed to. */
+    targets: SpillTargets;
+
+    /** Delay before the first retry (ms); doubles on each failed replay. */
+    retryDelayMs?: number | undefined;
+
+    /** Longest delay between replay attempts (ms). */
+    maxRetryDelayMs?: number | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Clock, for tests. */
+    clock?: (() => number) | undefined;
+}
+
+/** A spill entry as framed on disk. */
+interface SpilledEntry {
+    kind: SpillEntry['kind'];
+    spilledAt: string;
+    record: Record<string, unknown>;
+}
+
+// ============================================================================
+// Write Spill
+// ============================================================================
+
+/**
+ * Writes usage records to their stores, spilling the ones that fail and
+ * replaying them in the background.
+ */
+export class WriteSpill {
+    private readonly storage: SpillStorage;
+    private readonly targets: SpillTargets;
+    private readonly retryDelayMs: number;
+    private readonly maxRetryDelayMs: number;
+    private readonly logger?: Logger | undefined;
+    private readonly clock: () => number;
+
+    /** Serializes storage calls, so appends never interleave with a rotation. */
+    private io: Promise<unknown> = Promise.resolve();
+    private replaying: Promise<SpillReplayResult> | undefined;
+    private timer: ReturnType<typeof setTimeout> | undefined;
+    private closed = false;
+    private consecutiveFailures = 0;
+
+    /** Records already replayed from a segment that couldn't be finished. */
+    private readonly progress = new Map<string, number>();
+    private readonly damaged = new Set<string>();
+    private damagedSegments = 0;
+
+    // Waiting records: those in closed segments as of the last pass, plus
+    // those appended since
+    private closedDepth = 0;
+    private closedOldest: Date | undefined;
+    private activeDepth = 0;
+    private activeOldest: Date | undefined;
+
+    private spilled = 0;
+    private replayed = 0;
+    private replayFailures = 0;
+    private lastReplayError: string | undefined;
+    private deadLettered = 0;
+
+    constructor(options: WriteSpillOptions) {
+        this.storage = options.storage;
+        this.targets = options.targets;
+        this.retryDelayMs = options.retryDelayMs ?? DEFAULT_SPILL_RETRY_MS;
+        this.maxRetryDelayMs = options.maxRetryDelayMs ?? MAX_SPILL_RETRY_MS;
+        this.logger = options.logger;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /**
+     * Writes a record to its store, spilling it if the write fails. Only
+     * rejects if the record could be neither written nor spilled, after
+     * dead-lettering it.
+     */
+    async write(entry: SpillEntry): Promise<void> {
+        try {
+            await writeSpillEntry(this.targets, entry);
+        } catch (error) {
+            await this.spill(entry, error);
+        }
+    }
+
+    /**
+     * Starts the replay loop, first replaying anything left from a
+     * previous run.
+     */
+    start(): void {
+        this.schedule(0);
+    }
+
+    /**
+     * Replays spilled records now. Joins a replay already in progress.
+     */
+    replay(): Promise<SpillReplayResult> {
+        if (!this.replaying) {
+            this.clearTimer();
+            this.replaying = this.runReplay().finally(() => {
+                this.replaying = undefined;
+            });
+        }
+        return this.replaying;
+    }
+
+    /**
+     * Stops the replay loop and waits for pending appends. Spilled records
+     * stay on disk for the next run.
+     */
+    async close(): Promise<void> {
+        this.closed = true;
+        this.clearTimer();
+        await this.io;
+    }
+
+    /**
+     * Returns spill counters.
+     */
+    stats(): SpillStats {
+        return {
+            depth: this.closedDepth + this.activeDepth,
+            oldestSpilledAt: this.closedOldest ?? this.activeOldest,
+            spilled: this.spilled,
+            replayed: this.replayed,
+            replayFailures: this.replayFailures,
+            lastReplayError: this.lastReplayError,
+            deadLettered: this.deadLettered,
+            damagedSegments: this.damagedSegments,
+        };
+    }
+
+    // ---- Spilling ----
+
+    private async spill(entry: SpillEntry, cause: unknown): Promise<void> {
+        const spilledAt = new Date(this.clock());
+        const spilled: SpilledEntry = { kind: entry.kind, spilledAt: spilledAt.toISOString(), record: { ...entry.record } };
+
+        try {
+            const frame = encodeSpillFrame(new TextEncoder().encode(JSON.stringify(spilled)));
+            await this.enqueue(async () => {
+                await this.storage.append(frame);
+                this.activeDepth++;
+                this.activeOldest ??= spilledAt;
+            });
+        } catch (error) {
+            this.deadLettered++;
+            this.logger?.error('spill_dead_letter', {
+                kind: entry.kind,
+                interactionId: entry.record.interactionId,
+                cause: errorMessage(cause),
+                error: errorMessage(error),
+                record: spilled.record,
+            });
+            throw error;
+        }
+
+        this.spilled++;
+        this.logger?.warn('write_spilled', {
+            kind: entry.kind,
+            interactionId: entry.record.interactionId,
+            error: errorMessage(cause),
+        });
+        if (!this.timer && !this.replaying) {
+            this.schedule(this.retryDelay());
+        }
+    }
+
+    // ---- Replay ----
+
+    private async runReplay(): Promise<SpillReplayResult> {
+        // Close the active segment, so appends during the pass go to a new one
+        const segments = await this.enqueue(async () => {
+            await this.storage.rotate();
+            this.activeDepth = 0;
+            this.activeOldest = undefined;
+            return this.storage.segments();
+        });
+
+        let replayed = 0;
+        let remaining = 0;
+        let oldest: Date | undefined;
+        let failure: unknown;
+        let failed = false;
+
+        for (const segment of segments) {
+            const { payloads, damaged } = decodeSpillFrames(await this.storage.read(segment));
+            if (damaged) {
+                this.markDamaged(segment);
+                this.logger?.warn('spill_segment_damaged', { segment, recovered: payloads.length });
+            }
+
+            let next = this.progress.get(segment) ?? 0;
+            for (; next < payloads.length && !failed; next++) {
+                const entry = parseSpilledEntry(payloads[next]!);
+                if (!entry) {
+                    this.markDamaged(segment);
+                    this.logger?.warn('spill_record_unreadable', { segment, index: next });
+                    continue;
+                }
+                try {
+                    await writeSpillEntry(this.targets, entry.entry);
+                    replayed++;
+                } catch (error) {
+                    failure = error;
+                    failed = true;
+                    break;
+                }
+            }
+
+            if (next >= payloads.length) {
+                await this.storage.remove(segment);
+                this.progress.delete(segment);
+                this.damaged.delete(segment);
+            } else {
+                this.progress.set(segment, next);
+                remaining += payloads.length - next;
+                oldest ??= parseSpilledEntry(payloads[next]!)?.spilledAt;
+            }
+        }
+
+        this.replayed += replayed;
+        this.closedDepth = remaining;
+        this.closedOldest = oldest;
+
+        if (failed) {
+            this.replayFailures++;
+            this.consecutiveFailures++;
+            this.lastReplayError = errorMessage(failure);
+            this.logger?.warn('spill_replay_failed', { replayed, remaining, error: this.lastReplayError });
+            this.schedule(this.retryDelay());
+        } else {
+            this.consecutiveFailures = 0;
+            if (replayed > 0) {
+                this.logger?.info('spill_replayed', { replayed });
+            }
+            if (this.activeDepth > 0) {
+                // Spilled while this pass ran
+                this.schedule(this.retryDelay());
+            }
+        }
+
+        return { replayed, remaining: remaining + this.activeDepth, error: failed ? this.lastReplayError : undefined };
+    }
+
+    // ---- Helpers ----
+
+    /**
+     * Counts a damaged segment once, however many passes read it.
+     */
+    private markDamaged(segment: string): void {
+        if (!this.damaged.has(segment)) {
+            this.damaged.add(segment);
+            this.damagedSegments++;
+        }
+    }
+
+    private enqueue<T>(task: () => Promise<T>): Promise<T> {
+        const result = this.io.then(task);
+        this.io = result.catch(() => undefined);
+        return result;
+    }
+
+    private retryDelay(): number {
+        const delay = this.retryDelayMs * 2 ** Math.max(0, this.consecutiveFailures - 1);
+        return Math.min(delay, this.maxRetryDelayMs);
+    }
+
+    private schedule(delayMs: number): void {
+        if (this.closed) return;
+        this.clearTimer();
+        this.timer = setTimeout(() => {
+            this.timer = undefined;
+            this.replay().catch((error: unknown) => {
+                // Spill storage itself failed; try again later
+                this.logger?.error('spill_replay_error', { error: errorMessage(error) });
+                this.consecutiveFailures++;
+                this.schedule(this.retryDelay());
+            });
+        }, delayMs);
+        (this.timer as { unref?: () => void }).unref?.();
+    }
+
+    private clearTimer(): void {
+        if (this.timer) {
+            clearTimeout(this.timer);
+            this.timer = undefined;
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Writes an entry to its store.
+ */
+export function writeSpillEntry(targets: SpillTargets, entry: SpillEntry): Promise<void> {
+    return entry.kind === 'usage'
+        ? targets.usage.recordUsage(entry.record)
+        : targets.requestStats.recordRequestStat(entry.record);
+}
+
+/**
+ * Parses a spilled payload, reviving its dates. Returns undefined for a
+ * payload that isn't a spill entry.
+ */
+function parseSpilledEntry(payload: Uint8Array): { entry: SpillEntry; spilledAt: Date } | undefined {
+    try {
+        const spilled = JSON.parse(new TextDecoder().decode(payload)) as SpilledEntry;
+        if ((spilled.kind !== 'usage' && spilled.kind !== 'request_stat') || typeof spilled.record !== 'object') {
+            return undefined;
+        }
+        const record = { ...spilled.record, createdAt: new Date(spilled.record.createdAt as string) };
+        return {
+            entry: { kind: spilled.kind, record } as SpillEntry,
+            spilledAt: new Date(spilled.spilledAt),
+        };
+    } catch {
+        return undefined;
+    }
+}
+
+function errorMessage(error: unknown): string {
+    return error instanceof Error ? error.message : String(error);
+}
@@ -0,0 +1,245 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import {
+    PipelineExecutor,
+    checkStageConditions,
+    continueResult,
+    unmetCondition,
+    validateStageCondition,
+    type ConditionEnvironment,
+} from './middleware/index';
+import type { PipelineContext } from './middleware/types';
+import type { CanonicalRequest, CanonicalResponse } from './domain/types';
+import type { AppConfig, StageConditionConfig } from './ports/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const request: CanonicalRequest = {
+    tenantId: 'acme',
+    model: 'gpt-4o-mini',
+    messages: [
+        { role: 'user', content: 'What is the weather?' },
+        { role: 'assistant', content: 'Where?' },
+        { role: 'user', content: 'Paris' },
+    ],
+    stream: true,
+    tools: [{ type: 'function', function: { name: 'weather', parameters: { type: 'object' } } }],
+    metadata: { team: 'search' },
+    sourceAPIType: 'openai',
+};
+
+const response: CanonicalResponse = {
+    id: 'resp-1',
+    object: 'chat.completion',
+    created: 1700000000,
+    model: 'gpt-4o-mini',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'Sunny, 21C' }, finishReason: 'stop' }],
+    usage: { promptTokens: 20, completionTokens: 4, totalTokens: 24 },
+    sourceAPIType: 'openai',
+};
+
+function context(overrides: Partial<PipelineContext> = {}): PipelineContext {
+    return { request, response, tenantId: 'acme', appName: 'chat', interactionId: 'int-1', metadata: new Map(), ...overrides };
+}
+
+/** 100 tokens a request, and a sample draw of 0.5. */
+const env: ConditionEnvironment = { countTokens: () => 100, random: () => 0.5 };
+
+describe('unmetCondition', () => {
+    it.each<[string, StageConditionConfig, string | undefined]>([
+        ['an exact model', { model: 'gpt-4o-mini' }, undefined],
+        ['another exact model', { model: 'gpt-4o' }, "model: 'gpt-4o'"],
+        ['a model prefix', { modelPrefix: 'gpt-4' }, undefined],
+        ['another model prefix', { modelPrefix: 'claude' }, "model_prefix: 'claude'"],
+        ['a model pattern', { modelPattern: '^gpt-4o(-mini)?$' }, undefined],
+        ['another model pattern', { modelPattern: 'turbo$' }, "model_pattern: 'turbo$'"],
+        ['the tenant', { tenant: ['globex', 'acme'] }, undefined],
+        ['another tenant', { tenant: ['globex'] }, "tenant: 'globex'"],
+        ['the app', { app: ['chat'] }, undefined],
+        ['other apps', { app: ['batch', 'admin'] }, "app: ['batch', 'admin']"],
+        ['streaming', { stream: true }, undefined],
+        ['not streaming', { stream: false }, 'stream: false'],
+        ['tools', { hasTools: true }, undefined],
+        ['no tools', { hasTools: false }, 'has_tools: false'],
+        ['enough messages', { minMessages: 3, maxMessages: 3 }, undefined],
+        ['too few messages', { minMessages: 4 }, 'min_messages: 4'],
+        ['too many messages', { maxMessages: 2 }, 'max_messages: 2'],
+        ['a token range', { minTokens: 50, maxTokens: 100 }, undefined],
+        ['too few tokens', { minTokens: 101 }, 'min_tokens: 101'],
+        ['too many tokens', { maxTokens: 99 }, 'max_tokens: 99'],
+        ['a metadata value', { metadata: { team: 'search' } }, undefined],
+        ['another metadata value', { metadata: { team: 'ads' } }, "metadata.team: 'ads'"],
+        ['a missing metadata key', { metadata: { env: 'prod' } }, "metadata.env: 'prod'"],
+        ['a sample draw under the rate', { sampleRate: 0.6 }, undefined],
+        ['a sample draw over the rate', { sampleRate: 0.4 }, 'sample_rate: 0.4'],
+        ['the finish reason', { finishReason: ['stop', 'length'] }, undefined],
+        ['another finish reason', { finishReason: ['tool_calls'] }, "finish_reason: 'tool_calls'"],
+        ['a content length range', { minContentLength: 10, maxContentLength: 10 }, undefined],
+        ['too short content', { minContentLength: 11 }, 'min_content_length: 11'],
+        ['every field of one condition', { modelPrefix: 'gpt', hasTools: true, stream: false }, 'stream: false'],
+        ['all conditions', { all: [{ hasTools: true }, { app: ['chat'] }] }, undefined],
+        ['all but one condition', { all: [{ hasTools: true }, { app: ['batch'] }] }, "app: 'batch'"],
+        ['any condition', { any: [{ app: ['batch'] }, { hasTools: true }] }, undefined],
+        ['no condition of any', { any: [{ app: ['batch'] }, { stream: false }] }, "any: none of (app: 'batch'; stream: false)"],
+        ['nested conditions', { any: [{ all: [{ hasTools: true }, { minMessages: 2 }] }, { model: 'x' }] }, undefined],
+    ])('should evaluate %s', (_name, when, unmet) => {
+        expect(unmetCondition(when, context(), env)).toBe(unmet);
+    });
+
+    it('should only count tokens and draw samples when asked to', () => {
+        const countTokens = vi.fn(() => 100);
+        const random = vi.fn(() => 0.5);
+
+        unmetCondition({ hasTools: true }, context(), { countTokens, random });
+        unmetCondition({ hasTools: false, sampleRate: 0.9 }, context(), { countTokens, random });
+
+        expect(countTokens).not.toHaveBeenCalled();
+        expect(random).not.toHaveBeenCalled();
+    });
+
+    it('should treat requests without a response or app as not matching them', () => {
+        const ctx = context({ response: undefined, appName: undefined });
+
+        expect(unmetCondition({ finishReason: ['stop'] }, ctx, env)).toBe("finish_reason: 'stop'");
+        expect(unmetCondition({ minContentLength: 1 }, ctx, env)).toBe('min_content_length: 1');
+        expect(unmetCondition({ app: ['chat'] }, ctx, env)).toBe("app: 'chat'");
+    });
+});
+
+describe('validateStageCondition', () => {
+    it.each<[string, unknown, 'pre' | 'post', string]>([
+        ['a list', [], 'pre', 'when must be a condition object'],
+        ['an invalid pattern', { modelPattern: '(' }, 'pre', "when.model_pattern: '(' is not a valid regular expression"],
+        ['an empty tenant list', { tenant: [] }, 'pre', 'when.tenant must be a name or a non-empty list of names'],
+        ['a negative count', { minMessages: -1 }, 'pre', 'when.min_messages must be a non-negative integer, got -1'],
+        ['a fractional count', { maxTokens: 1.5 }, 'pre', 'when.max_tokens must be a non-negative integer, got 1.5'],
+        ['an inverted range', { minTokens: 10, maxTokens: 5 }, 'pre', 'when.min_tokens is more than max_tokens'],
+        ['a rate over 1', { sampleRate: 2 }, 'pre', 'when.sample_rate must be between 0 and 1, got 2'],
+        ['a non-boolean flag', { hasTools: 'yes' }, 'pre', 'when.has_tools must be true or false'],
+        ['non-string metadata', { metadata: { n: 1 } }, 'pre', 'when.metadata must map keys to string values'],
+        ['a response field on a pre stage', { finishReason: ['stop'] }, 'pre', 'when.finish_reason checks the response, so only post stages can use it'],
+        ['an empty any', { any: [] }, 'post', 'when.any must be a non-empty list of conditions'],
+        ['a nested error', { all: [{ stream: true }, { any: [{ sampleRate: -1 }] }] }, 'post', 'when.all[1].any[0].sample_rate must be between 0 and 1, got -1'],
+    ])('should reject %s', (_name, when, type, message) => {
+        expect(() => validateStageCondition(when as StageConditionConfig, type)).toThrow(message);
+    });
+
+    it('should accept well-formed conditions', () => {
+        expect(() => validateStageCondition({
+            any: [{ hasTools: true }, { app: ['pii'], modelPattern: '^gpt-' }],
+            minContentLength: 0,
+            finishReason: ['stop'],
+            sampleRate: 1,
+        }, 'post')).not.toThrow();
+    });
+
+    it('should name the app and stage of a malformed condition', () => {
+        const apps = [{
+            name: 'chat',
+            frontdoor: 'openai',
+            path: '/v1',
+            pipeline: { stages: [{ name: 'audit', type: 'pre', url: 'http://hooks/audit', when: { maxContentLength: 100 } }] },
+        }] as AppConfig[];
+
+        expect(() => checkStageConditions(apps)).toThrow(
+            "Invalid config for app 'chat': pipeline stage 'audit' when.max_content_length checks the response, so only post stages can use it",
+        );
+    });
+});
+
+describe('PipelineExecutor stage conditions', () => {
+    it('should skip stages whose condition fails and record the condition', async () => {
+        const events = { saveEvent: vi.fn().mockResolvedValue(undefined) };
+        const executor = new PipelineExecutor({ events, countTokens: () => 100, random: () => 0.5 });
+        const compliance = vi.fn().mockResolvedValue(continueResult());
+        const scrubber = vi.fn().mockResolvedValue(continueResult());
+        const audit = vi.fn().mockResolvedValue(continueResult());
+        executor.addPreStage({ name: 'compliance', type: 'pre', step: compliance, when: { hasTools: true } });
+        executor.addPreStage({ name: 'scrubber', type: 'pre', step: scrubber, when: { app: ['pii'] }, order: 1 });
+        executor.addPostStage({ name: 'audit', type: 'post', step: audit, when: { finishReason: ['length'] } });
+
+        const pre = await executor.runPre(context());
+        const post = await executor.runPost(context());
+
+        expect(compliance).toHaveBeenCalledTimes(1);
+        expect(scrubber).not.toHaveBeenCalled();
+        expect(audit).not.toHaveBeenCalled();
+        expect(pre.continue).toBe(true);
+        expect(pre.stages).toEqual([
+            { stage: 'compliance', action: 'continue' },
+            { stage: 'scrubber', action: 'skipped', condition: "app: 'pii'" },
+        ]);
+        expect(post.stages).toEqual([{ stage: 'audit', action: 'skipped', condition: "finish_reason: 'length'" }]);
+        expect(events.saveEvent.mock.calls.map(([event]) => [event.type, event.payload])).toEqual([
+            ['pipeline_pre', { stage: 'scrubber', action: 'skipped', skipped: true, condition: "app: 'pii'" }],
+            ['pipeline_post', { stage: 'audit', action: 'skipped', skipped: true, condition: "finish_reason: 'length'" }],
+        ]);
+    });
+
+    it('should evaluate later stages against earlier stages\' changes', async () => {
+        const executor = new PipelineExecutor();
+        const downstream = vi.fn().mockResolvedValue(continueResult());
+        executor.addPreStage({
+            name: 'strip-tools',
+            type: 'pre',
+            step: async (ctx) => ({ action: 'modify', request: { ...ctx.request, tools: undefined } }),
+        });
+        executor.addPreStage({ name: 'tools-only', type: 'pre', step: downstream, when: { hasTools: true }, order: 1 });
+
+        const result = await executor.runPre(context());
+
+        expect(downstream).not.toHaveBeenCalled();
+        expect(result.stages?.[1]).toEqual({ stage: 'tools-only', action: 'skipped', condition: 'has_tools: true' });
+    });
+});
+
+describe('Stage conditions', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    it('should call a stage\'s webhook only for matching requests', async () => {
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                pipeline: {
+                    stages: [{ name: 'compliance', type: 'pre', url: 'http://hooks/compliance', when: { hasTools: true } }],
+                },
+            })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .start();
+        const ask = (body: Record<string, unknown> = {}) =>
+            gateway!.post('/v1/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }], ...body });
+
+        expect((await ask()).status).toBe(200);
+        expect(gateway.webhookCalls).toHaveLength(0);
+        expect(gateway.store.eventsOf('pipeline_pre').map((e) => e.payload)).toEqual([
+            { stage: 'compliance', action: 'skipped', skipped: true, condition: 'has_tools: true' },
+        ]);
+
+        const tools = [{ type: 'function', function: { name: 'weather', parameters: { type: 'object' } } }];
+        expect((await ask({ tools })).status).toBe(200);
+        expect(gateway.webhookCalls.map((c) => c.url)).toEqual(['http://hooks/compliance']);
+    });
+
+    it('should refuse to load a malformed condition', async () => {
+        gateway = await harness()
+            .app({
+                name: 'chat',
+                frontdoor: 'openai',
+                path: '/v1',
+                pipeline: {
+                    stages: [{ name: 'compliance', type: 'pre', url: 'http://hooks/compliance', when: { sampleRate: 5 } }],
+                },
+            })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .start();
+
+        await expect(gateway.gateway.reload()).rejects.toThrow(
+            "Invalid config for app 'chat': pipeline stage 'compliance' when.sample_rate must be between 0 and 1, got 5",
+        );
+    });
+});
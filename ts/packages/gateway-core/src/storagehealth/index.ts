@@ -0,0 +1,15 @@
+/**
+ * Storage health exports.
+ *
+ * @module storagehealth
+ */
+
+export {
+    StorageHealth,
+    DEFAULT_STORAGE_FAILURE_THRESHOLD,
+    DEFAULT_STORAGE_PROBE_MS,
+    type StorageHealthOptions,
+    type StorageHealthStats,
+} from './supervisor.js';
+
+export { withStorageHealth, probeStorage } from './storage.js';
@@ -0,0 +1,121 @@
+/**
+ * Health-supervised storage wrapper.
+ *
+ * Wraps any StorageProvider so every write reports its outcome to a
+ * StorageHealth supervisor. While storage is degraded, writes that only
+ * record what happened (conversations, responses, events, attempts) are
+ * skipped, usage writes fail fast so the write spill takes them, and
+ * every other call is refused with storage_unavailable (503), so the
+ * endpoints that truly need storage say so while completions, whose
+ * storage reads fail open, keep flowing.
+ *
+ * @module storagehealth/storage
+ */
+
+import type { StorageProvider } from '../ports/storage.js';
+import { errStorageUnavailable } from '../domain/errors.js';
+import type { StorageHealth } from './supervisor.js';
+
+// ============================================================================
+// Method Classes
+// ============================================================================
+
+/** Writes that only record what happened; skipped while degraded. */
+const RECORDING_WRITES: ReadonlySet<string> = new Set([
+    'saveConversation',
+    'saveResponse',
+    'updateResponse',
+    'saveEvent',
+    'saveShadowResult',
+    'setThreadState',
+    'indexMetadata',
+    'saveAttempts',
+    'saveProbeResult',
+    'saveSloSnapshot',
+]);
+
+/** Writes the write spill keeps; they fail fast while degraded so it does. */
+const SPILLED_WRITES: ReadonlySet<string> = new Set(['recordUsage', 'recordRequestStat']);
+
+/** Calls that manage the store itself; never counted or refused. */
+const UNSUPERVISED: ReadonlySet<string> = new Set(['ping', 'close', 'migrate', 'migrationStatus']);
+
+/** Read methods, by name; every other method writes. */
+const READ_PREFIX = /^(get|list|find|sum|aggregate|scan)/;
+
+/** Conversation looked up by the fallback probe; never exists. */
+const STORAGE_PROBE_ID = 'storage-health-probe';
+
+// ============================================================================
+// Supervised Storage
+// ============================================================================
+
+/**
+ * Wraps a storage provider so its writes are supervised by `health`.
+ * Methods are looked up on the inner store, so capability checks
+ * (isProbeStore and friends) see what it implements.
+ */
+export function withStorageHealth(storage: StorageProvider, health: StorageHealth): StorageProvider {
+    // Methods are looked up on each call, so ones replaced on the inner
+    // store after wrapping are still the ones called
+    const call = (name: string, args: unknown[]): Promise<unknown> =>
+        (Reflect.get(storage, name, storage) as (...args: unknown[]) => Promise<unknown>).apply(storage, args);
+
+    const supervised = (name: string) => {
+        const write = !READ_PREFIX.test(name);
+        return async (...args: unknown[]): Promise<unknown> => {
+            if (health.degraded) {
+                if (RECORDING_WRITES.has(name)) {
+                    health.skipped();
+                    return undefined;
+                }
+                health.rejected();
+                throw errStorageUnavailable(SPILLED_WRITES.has(name)
+                    ? 'Storage is degraded; the write was not attempted'
+                    : 'Storage is temporarily unavailable');
+            }
+            if (!write) {
+                return call(name, args);
+            }
+            try {
+                const result = await call(name, args);
+                health.succeeded();
+                return result;
+            } catch (error) {
+                health.failed(error);
+                throw error;
+            }
+        };
+    };
+
+    const wrapped = new Map<string, unknown>();
+    return new Proxy(storage, {
+        get(target, prop) {
+            const value: unknown = Reflect.get(target, prop, target);
+            if (typeof value !== 'function') {
+                return value;
+            }
+            if (typeof prop !== 'string' || UNSUPERVISED.has(prop)) {
+                return value.bind(target);
+            }
+            let method = wrapped.get(prop);
+            if (!method) {
+                method = supervised(prop);
+                wrapped.set(prop, method);
+            }
+            return method;
+        },
+    });
+}
+
+/**
+ * Checks that a store answers: its ping, or else a lookup of a
+ * conversation that doesn't exist.
+ */
+export async function probeStorage(storage: StorageProvider): Promise<void> {
+    if (storage.ping) {
+        await storage.ping();
+        return;
+    }
+    await storage.getConversation(STORAGE_PROBE_ID, '');
+}
@@ -0,0 +1,181 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { StorageHealth, withStorageHealth } from './index';
+import { APIError } from '../domain/errors';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/**
+ * In-memory store over a "database file" that can be deleted: once it is
+ * gone every call fails the way SQLite does, until it is put back.
+ */
+function fileStore() {
+    const file = { deleted: false };
+    const check = () => {
+        if (file.deleted) throw new Error('SQLITE_IOERR: disk I/O error');
+    };
+    const responses = new Map<string, any>();
+    const threads = new Map<string, any>();
+    const store = {
+        saveResponse: vi.fn(async (r: any) => {
+            check();
+            responses.set(r.id, structuredClone(r));
+        }),
+        getResponse: vi.fn(async (id: string, tenantId: string) => {
+            check();
+            return responses.get(id)?.tenantId === tenantId ? structuredClone(responses.get(id)) : null;
+        }),
+        getConversation: vi.fn(async () => {
+            check();
+            return null;
+        }),
+        saveEvent: vi.fn(async () => check()),
+        recordUsage: vi.fn(async () => check()),
+        sumUsage: vi.fn(async () => {
+            check();
+            return { tokens: 0, costUsd: 0, requests: 0 };
+        }),
+        createThread: vi.fn(async (t: any) => {
+            check();
+            threads.set(t.id, t);
+        }),
+        getThread: vi.fn(async (id: string, tenantId: string) => {
+            check();
+            return threads.get(id)?.tenantId === tenantId ? threads.get(id) : null;
+        }),
+    };
+    return { file, store };
+}
+
+describe('withStorageHealth', () => {
+    it('should trip after consecutive failed writes, then skip, spill, and refuse', async () => {
+        const { file, store } = fileStore();
+        const health = new StorageHealth({ probe: async () => store.getConversation() });
+        health.configure({ failureThreshold: 2, probeInterval: '1h' });
+        const storage = withStorageHealth(store as any, health);
+
+        file.deleted = true;
+        await expect(storage.saveEvent({} as any)).rejects.toThrow('SQLITE_IOERR');
+        // Reads don't count toward the threshold
+        await expect(storage.getResponse('resp_1', 'acme')).rejects.toThrow('SQLITE_IOERR');
+        expect(health.degraded).toBe(false);
+        await expect(storage.saveEvent({} as any)).rejects.toThrow('SQLITE_IOERR');
+        expect(health.degraded).toBe(true);
+
+        store.saveEvent.mockClear();
+        await expect(storage.saveEvent({} as any)).resolves.toBeUndefined();
+        expect(store.saveEvent).not.toHaveBeenCalled();
+        const refused = await storage.getResponse('resp_1', 'acme').catch((error: unknown) => error);
+        expect(refused).toBeInstanceOf(APIError);
+        expect(refused).toMatchObject({ code: 'storage_unavailable', statusCode: 503 });
+        await expect(storage.recordUsage!({} as any)).rejects.toMatchObject({ code: 'storage_unavailable' });
+        expect(store.recordUsage).not.toHaveBeenCalled();
+        expect(health.stats()).toMatchObject({ status: 'degraded', trips: 1, skippedWrites: 1, rejectedCalls: 2 });
+
+        // Probes fail until the file is back
+        expect(await health.probe()).toBe(false);
+        file.deleted = false;
+        expect(await health.probe()).toBe(true);
+        expect(health.stats()).toMatchObject({ status: 'ok', consecutiveFailures: 0 });
+        await storage.saveEvent({} as any);
+        expect(store.saveEvent).toHaveBeenCalledTimes(1);
+        health.close();
+    });
+
+    it('should reset the count on a successful write and leave capabilities as they are', async () => {
+        const { file, store } = fileStore();
+        const health = new StorageHealth({ probe: async () => {} });
+        health.configure({ failureThreshold: 2 });
+        const storage = withStorageHealth(store as any, health);
+
+        file.deleted = true;
+        await storage.saveEvent({} as any).catch(() => {});
+        file.deleted = false;
+        await storage.saveEvent({} as any);
+        file.deleted = true;
+        await storage.saveEvent({} as any).catch(() => {});
+
+        expect(health.degraded).toBe(false);
+        expect(typeof storage.createThread).toBe('function');
+        expect(storage.addMessage).toBeUndefined();
+        health.close();
+    });
+});
+
+describe('Degraded storage', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const { file, store } = fileStore();
+        const logger = spyLogger();
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/openai' })
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses' })
+            .provider(new ScriptedProvider('mock', { text: 'ok' }))
+            .config({
+                storage: { type: 'sqlite', sqlite: { path: '/tmp/gateway.db' }, health: { failureThreshold: 3, probeInterval: '10ms' } },
+            })
+            .options({ storage: store as any, logger })
+            .start();
+        const gw = gateway;
+        const admin = new AdminHandler({ storageHealth: () => gw.gateway.storageHealthStats() });
+        const send = (method: string, path: string, body?: object) =>
+            method === 'POST' ? gw.post(path, body) : gw.request(path, { method });
+        const complete = () => send('POST', '/openai/chat/completions', { model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] });
+        const stats = async () => (await (await admin.handle(new Request('http://localhost/api/stats'))).json()).storage;
+        return { file, gateway: gw.gateway, logger, send, complete, stats };
+    }
+
+    it('should keep completions flowing while the storage file is gone, then recover', async () => {
+        const { file, gateway, logger, send, complete, stats } = await setup();
+        const created = await (await send('POST', '/v1/responses', { model: 'gpt-4o', input: 'Hi' })).json();
+        const thread = await (await send('POST', '/v1/threads', {})).json();
+        expect((await send('GET', `/v1/responses/${created.id}`)).status).toBe(200);
+        expect(await stats()).toMatchObject({ status: 'ok' });
+
+        // The database file is deleted mid-run
+        file.deleted = true;
+        for (let i = 0; i < 3; i++) {
+            expect((await complete()).status).toBe(200);
+        }
+        await vi.waitFor(() => expect(gateway.storageHealthStats()?.status).toBe('degraded'));
+        expect(logged(logger.error, 'storage_degraded')).toHaveLength(1);
+
+        // Endpoints that need storage say so; completions don't notice
+        for (const path of [`/v1/responses/${created.id}`, `/v1/threads/${thread.id}`]) {
+            const response = await send('GET', path);
+            expect(response.status).toBe(503);
+            expect((await response.json()).error.code).toBe('storage_unavailable');
+        }
+        expect((await complete()).status).toBe(200);
+        const ready = await send('GET', '/readyz');
+        expect(ready.status).toBe(200);
+        const readiness = await ready.json();
+        expect(readiness).toMatchObject({ status: 'degraded', storage: { status: 'degraded', trips: 1 } });
+        expect(readiness.storage).not.toHaveProperty('lastError');
+        expect(await stats()).toMatchObject({ status: 'degraded', degradedSince: expect.any(String), lastError: expect.any(String) });
+
+        // The file comes back; a probe restores normal mode
+        file.deleted = false;
+        await vi.waitFor(() => expect(gateway.storageHealthStats()?.status).toBe('ok'));
+        expect(logged(logger.info, 'storage_recovered')).toHaveLength(1);
+        expect((await send('GET', `/v1/responses/${created.id}`)).status).toBe(200);
+        expect(await (await send('GET', '/readyz')).json()).toMatchObject({ status: 'ok' });
+    });
+
+    it('should report no storage on /readyz when none is configured', async () => {
+        gateway = await harness()
+            .provider(new ScriptedProvider('mock', { text: 'ok' }))
+            .options({ storage: undefined })
+            .start();
+
+        const ready = await gateway.request('/readyz');
+
+        expect(ready.status).toBe(200);
+        expect(await ready.json()).toEqual({ status: 'ok' });
+    });
+});
@@ -0,0 +1,253 @@
+/**
+ * Storage health supervision.
+ *
+ * A store that is gone (a deleted or locked SQLite file, an unreachable
+ * database) fails every call, and without supervision each request pays
+ * for it: recordings fail one by one, and endpoints that read storage
+ * answer with whatever the driver threw. The supervisor counts
+ * consecutive failed writes; past a threshold it trips degraded mode, in
+ * which the storage wrapper skips recording writes and refuses the calls
+ * that need storage with an explicit 503. While degraded it probes the
+ * store on an interval and returns to normal mode on the first probe
+ * that succeeds.
+ *
+ * @module storagehealth/supervisor
+ */
+
+import type { StorageHealthConfig } from '../ports/config.js';
+import type { Logger } from '../utils/logging.js';
+import { parseDuration } from '../utils/duration.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default consecutive failed writes that trip degraded mode. */
+export const DEFAULT_STORAGE_FAILURE_THRESHOLD = 5;
+
+/** Default delay between recovery probes while degraded. */
+export const DEFAULT_STORAGE_PROBE_MS = 5000;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * StorageHealth options.
+ */
+export interface StorageHealthOptions {
+    /** Checks the store; rejects while it is unavailable. */
+    probe: () => Promise<unknown>;
+
+    /** Logger for degraded-mode entry and exit. */
+    logger?: Logger | undefined;
+
+    /** Clock (default: Date.now). */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * Storage health as reported by the admin stats. /readyz, which is
+ * unauthenticated, shows only the status and counters.
+ */
+export interface StorageHealthStats {
+    /** 'degraded' while recording is skipped and storage-backed endpoints answer 503. */
+    status: 'ok' | 'degraded';
+
+    /** Writes that have failed since the last one that succeeded. */
+    consecutiveFailures: number;
+
+    /** Consecutive failed writes that trip degraded mode. */
+    failureThreshold: number;
+
+    /** When the current degraded period started. */
+    degradedSince?: Date | undefined;
+
+    /** Error from the last failed write or probe. */
+    lastError?: string | undefined;
+
+    /** When a recovery probe last ran. */
+    lastProbeAt?: Date | undefined;
+
+    /** Times degraded mode was entered. */
+    trips: number;
+
+    /** Recording writes skipped while degraded. */
+    skippedWrites: number;
+
+    /** Calls refused with storage_unavailable while degraded. */
+    rejectedCalls: number;
+}
+
+// ============================================================================
+// Storage Health
+// ============================================================================
+
+/**
+ * Tracks storage write failures, trips degraded mode, and probes for
+ * recovery.
+ */
+export class StorageHealth {
+    private readonly probeStore: StorageHealthOptions['probe'];
+    private readonly logger?: Logger | undefined;
+    private readonly clock: () => number;
+    private failureThreshold = DEFAULT_STORAGE_FAILURE_THRESHOLD;
+    private probeIntervalMs = DEFAULT_STORAGE_PROBE_MS;
+
+    private timer: ReturnType<typeof setTimeout> | undefined;
+    private probing: Promise<boolean> | undefined;
+    private closed = false;
+
+    private consecutiveFailures = 0;
+    private degradedSince: number | undefined;
+    private lastError: string | undefined;
+    private lastProbeAt: number | undefined;
+    private trips = 0;
+    private skippedWrites = 0;
+    private rejectedCalls = 0;
+
+    constructor(options: StorageHealthOptions) {
+        this.probeStore = options.probe;
+        this.logger = options.logger;
+        this.clock = options.clock ?? Date.now;
+    }
+
+    /** Whether storage is degraded. */
+    get degraded(): boolean {
+        return this.degradedSince !== undefined;
+    }
+
+    /**
+     * Applies the configured threshold and probe interval.
+     */
+    configure(config: StorageHealthConfig | undefined): void {
+        const probeIntervalMs = parseDuration(config?.probeInterval, DEFAULT_STORAGE_PROBE_MS);
+        this.failureThreshold = Math.max(1, config?.failureThreshold ?? DEFAULT_STORAGE_FAILURE_THRESHOLD);
+        this.probeIntervalMs = probeIntervalMs;
+    }
+
+    /**
+     * Records a write that succeeded.
+     */
+    succeeded(): void {
+        this.consecutiveFailures = 0;
+    }
+
+    /**
+     * Records a write that failed, tripping degraded mode at the
+     * threshold.
+     */
+    failed(error: unknown): void {
+        this.consecutiveFailures++;
+        this.lastError = errorMessage(error);
+        if (this.degraded || this.consecutiveFailures < this.failureThreshold) {
+            return;
+        }
+        this.degradedSince = this.clock();
+        this.trips++;
+        this.logger?.error('storage_degraded', {
+            consecutiveFailures: this.consecutiveFailures,
+            error: this.lastError,
+        });
+        this.schedule();
+    }
+
+    /**
+     * Counts a recording write skipped while degraded.
+     */
+    skipped(): void {
+        this.skippedWrites++;
+    }
+
+    /**
+     * Counts a call refused while degraded.
+     */
+    rejected(): void {
+        this.rejectedCalls++;
+    }
+
+    /**
+     * Probes the store now, returning to normal mode if it answers.
+     * Returns whether storage is healthy. Joins a probe in progress.
+     */
+    probe(): Promise<boolean> {
+        if (!this.probing) {
+            this.clearTimer();
+            this.probing = this.runProbe().finally(() => {
+                this.probing = undefined;
+            });
+        }
+        return this.probing;
+    }
+
+    /**
+     * Returns the current health.
+     */
+    stats(): StorageHealthStats {
+        return {
+            status: this.degraded ? 'degraded' : 'ok',
+            consecutiveFailures: this.consecutiveFailures,
+            failureThreshold: this.failureThreshold,
+            degradedSince: this.degradedSince === undefined ? undefined : new Date(this.degradedSince),
+            lastError: this.lastError,
+            lastProbeAt: this.lastProbeAt === undefined ? undefined : new Date(this.lastProbeAt),
+            trips: this.trips,
+            skippedWrites: this.skippedWrites,
+            rejectedCalls: this.rejectedCalls,
+        };
+    }
+
+    /**
+     * Stops probing.
+     */
+    close(): void {
+        this.closed = true;
+        this.clearTimer();
+    }
+
+    // ---- Helpers ----
+
+    private async runProbe(): Promise<boolean> {
+        this.lastProbeAt = this.clock();
+        try {
+            await this.probeStore();
+        } catch (error) {
+            this.lastError = errorMessage(error);
+            if (this.degraded) {
+                this.schedule();
+            }
+            return false;
+        }
+        if (this.degradedSince !== undefined) {
+            this.logger?.info('storage_recovered', {
+                degradedMs: this.clock() - this.degradedSince,
+                skippedWrites: this.skippedWrites,
+                rejectedCalls: this.rejectedCalls,
+            });
+        }
+        this.degradedSince = undefined;
+        this.consecutiveFailures = 0;
+        return true;
+    }
+
+    private schedule(): void {
+        if (this.closed) return;
+        this.clearTimer();
+        this.timer = setTimeout(() => {
+            this.timer = undefined;
+            void this.probe();
+        }, this.probeIntervalMs);
+        (this.timer as { unref?: () => void }).unref?.();
+    }
+
+    private clearTimer(): void {
+        if (this.timer) {
+            clearTimeout(this.timer);
+            this.timer = undefined;
+        }
+    }
+}
+
+function errorMessage(error: unknown): string {
+    return error instanceof Error ? error.message : String(error);
+}
@@ -0,0 +1,179 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openaiCodec } from './codecs/index';
+import { openAIFrontdoor } from './frontdoors/index';
+import { ResponsesHandler } from './responses/handler';
+
+/** A chat completion recorded from OpenAI. */
+const recordedResponse = '{"id":"chatcmpl-AxQ3nN5mHlyVDBdwSFLcZL0Ltpyq6","object":"chat.completion","created":1738689415,"model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"message":{"role":"assistant","content":"Hello! How can I help you today?","refusal":null},"logprobs":null,"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":10,"total_tokens":19,"prompt_tokens_details":{"cached_tokens":0,"audio_tokens":0},"completion_tokens_details":{"reasoning_tokens":0,"audio_tokens":0,"accepted_prediction_tokens":0,"rejected_prediction_tokens":0}},"service_tier":"default","system_fingerprint":"fp_72ed7ab54c"}';
+
+/** Stream chunks recorded from the same request with stream_options: { include_usage: true }. */
+const recordedChunks = [
+    '{"id":"chatcmpl-AxQ4Ab7kSRmR2pjeYrWGvqKzl0YzN","object":"chat.completion.chunk","created":1738689456,"model":"gpt-4o-mini-2024-07-18","service_tier":"default","system_fingerprint":"fp_72ed7ab54c","choices":[{"index":0,"delta":{"role":"assistant","content":"","refusal":null},"logprobs":null,"finish_reason":null}],"usage":null}',
+    '{"id":"chatcmpl-AxQ4Ab7kSRmR2pjeYrWGvqKzl0YzN","object":"chat.completion.chunk","created":1738689456,"model":"gpt-4o-mini-2024-07-18","service_tier":"default","system_fingerprint":"fp_72ed7ab54c","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}],"usage":null}',
+    '{"id":"chatcmpl-AxQ4Ab7kSRmR2pjeYrWGvqKzl0YzN","object":"chat.completion.chunk","created":1738689456,"model":"gpt-4o-mini-2024-07-18","service_tier":"default","system_fingerprint":"fp_72ed7ab54c","choices":[{"index":0,"delta":{"content":"!"},"logprobs":null,"finish_reason":null}],"usage":null}',
+    '{"id":"chatcmpl-AxQ4Ab7kSRmR2pjeYrWGvqKzl0YzN","object":"chat.completion.chunk","created":1738689456,"model":"gpt-4o-mini-2024-07-18","service_tier":"default","system_fingerprint":"fp_72ed7ab54c","choices":[{"index":0,"delta":{},"logprobs":null,"finish_reason":"stop"}],"usage":null}',
+    '{"id":"chatcmpl-AxQ4Ab7kSRmR2pjeYrWGvqKzl0YzN","object":"chat.completion.chunk","created":1738689456,"model":"gpt-4o-mini-2024-07-18","service_tier":"default","system_fingerprint":"fp_72ed7ab54c","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11,"prompt_tokens_details":{"cached_tokens":0,"audio_tokens":0},"completion_tokens_details":{"reasoning_tokens":0,"audio_tokens":0,"accepted_prediction_tokens":0,"rejected_prediction_tokens":0}}}',
+];
+
+const decoder = new TextDecoder();
+
+function memoryStorage() {
+    const responses = new Map<string, any>();
+    return {
+        responses,
+        async saveResponse(record: any) {
+            responses.set(record.id, record);
+        },
+        async getResponse(id: string, tenantId: string) {
+            const record = responses.get(id);
+            return record && (tenantId === '' || record.tenantId === tenantId) ? record : null;
+        },
+    } as any;
+}
+
+/** Provider that replays the recorded response and chunks. */
+function recordedProvider() {
+    return {
+        name: 'openai',
+        apiType: 'openai',
+        complete: vi.fn(async () => openaiCodec.decodeResponse(recordedResponse)),
+        stream: vi.fn(async function* () {
+            for (const chunk of recordedChunks) {
+                yield openaiCodec.decodeStreamChunk(chunk)!;
+            }
+            yield { type: 'done' as const };
+        }),
+    };
+}
+
+function context(storage: any, provider: any, init: { method?: string; path?: string; body?: unknown; tenantId?: string }) {
+    return {
+        request: new Request(`http://localhost${init.path ?? '/v1/chat/completions'}`, {
+            method: init.method ?? 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: init.body === undefined ? undefined : JSON.stringify(init.body),
+        }),
+        provider,
+        auth: { tenantId: init.tenantId ?? 'tenant-a', scopes: ['*'], metadata: {} },
+        interactionId: 'int-1',
+        storage,
+    } as any;
+}
+
+/** The JSON data lines of an SSE body, without [DONE]. */
+async function chunks(response: Response): Promise<any[]> {
+    const text = await response.text();
+    expect(text.trimEnd().endsWith('data: [DONE]')).toBe(true);
+    return text.split('\n')
+        .filter((line) => line.startsWith('data: ') && line !== 'data: [DONE]')
+        .map((line) => JSON.parse(line.slice(6)));
+}
+
+const messages = [{ role: 'user', content: 'Hi' }];
+
+describe('Chat completions store and metadata', () => {
+    it('should echo metadata and serve the stored completion to its tenant only', async () => {
+        const storage = memoryStorage();
+        const provider = recordedProvider();
+
+        const { response } = await openAIFrontdoor.handle(context(storage, provider, {
+            body: { model: 'gpt-4o-mini', messages, store: true, metadata: { team: 'search' } },
+        }));
+        const created = await response.json();
+
+        expect(created).toMatchObject({
+            id: 'chatcmpl-AxQ3nN5mHlyVDBdwSFLcZL0Ltpyq6',
+            object: 'chat.completion',
+            metadata: { team: 'search' },
+            choices: [{ message: { content: 'Hello! How can I help you today?' } }],
+        });
+
+        const stored = await openAIFrontdoor.handle(context(storage, provider, {
+            method: 'GET', path: `/v1/chat/completions/${created.id}`,
+        }));
+        expect(stored.response.status).toBe(200);
+        expect(await stored.response.json()).toEqual(created);
+
+        const other = await openAIFrontdoor.handle(context(storage, provider, {
+            method: 'GET', path: `/v1/chat/completions/${created.id}`, tenantId: 'tenant-b',
+        }));
+        expect(other.response.status).toBe(404);
+        expect((await other.response.json()).error).toMatchObject({ type: 'not_found' });
+
+        // Stored completions are not Responses API responses
+        expect(await new ResponsesHandler({ storage, provider: provider as any }).get(created.id, 'tenant-a')).toBeNull();
+    });
+
+    it('should not store without store, nor send store or metadata upstream', async () => {
+        const storage = memoryStorage();
+
+        const { response } = await openAIFrontdoor.handle(context(storage, recordedProvider(), {
+            body: { model: 'gpt-4o-mini', messages, metadata: { team: 'search' } },
+        }));
+
+        expect((await response.json()).metadata).toEqual({ team: 'search' });
+        expect(storage.responses.size).toBe(0);
+
+        const request = openaiCodec.decodeRequest(JSON.stringify({
+            model: 'gpt-4o-mini', messages, stream: true, store: true, metadata: { team: 'search' }, stream_options: { include_usage: true },
+        }));
+        const upstream = JSON.parse(decoder.decode(openaiCodec.encodeRequest(request)));
+        expect(upstream).toMatchObject({ stream: true, stream_options: { include_usage: true } });
+        expect(upstream).not.toHaveProperty('store');
+        expect(upstream).not.toHaveProperty('metadata');
+    });
+});
+
+describe('Chat completions stream_options', () => {
+    it('should end the stream with a usage chunk when include_usage is set', async () => {
+        const { response } = await openAIFrontdoor.handle(context(memoryStorage(), recordedProvider(), {
+            body: { model: 'gpt-4o-mini', messages, stream: true, stream_options: { include_usage: true } },
+        }));
+
+        const received = await chunks(response);
+        const last = received[received.length - 1];
+
+        // As OpenAI sends them: usage null on every chunk but the last, which has no choices
+        expect(received.slice(0, -1).every((chunk) => chunk.usage === null && chunk.choices.length === 1)).toBe(true);
+        expect(last).toMatchObject({
+            object: 'chat.completion.chunk',
+            choices: [],
+            usage: { prompt_tokens: 9, completion_tokens: 2, total_tokens: 11 },
+        });
+    });
+
+    it('should leave chunks as they were without include_usage', async () => {
+        const { response } = await openAIFrontdoor.handle(context(memoryStorage(), recordedProvider(), {
+            body: { model: 'gpt-4o-mini', messages, stream: true },
+        }));
+
+        const received = await chunks(response);
+
+        expect(received.every((chunk) => chunk.usage !== null && chunk.choices.length === 1)).toBe(true);
+    });
+
+    it('should store a streamed completion once the stream completes', async () => {
+        const storage = memoryStorage();
+        const provider = recordedProvider();
+
+        const { response } = await openAIFrontdoor.handle(context(storage, provider, {
+            body: { model: 'gpt-4o-mini', messages, stream: true, store: true, metadata: { team: 'search' } },
+        }));
+        const received = await chunks(response);
+        const id = received[0].id;
+
+        expect(id).toMatch(/^chatcmpl-/);
+        expect(received.every((chunk) => chunk.id === id)).toBe(true);
+
+        const stored = await openAIFrontdoor.handle(context(storage, provider, {
+            method: 'GET', path: `/v1/chat/completions/${id}`,
+        }));
+        expect(await stored.response.json()).toMatchObject({
+            id,
+            object: 'chat.completion',
+            metadata: { team: 'search' },
+            choices: [{ index: 0, message: { role: 'assistant', content: 'Hello!' }, finish_reason: 'stop' }],
+            usage: { prompt_tokens: 9, completion_tokens: 2, total_tokens: 11 },
+        });
+    });
+});
@@ -0,0 +1,133 @@
+import { describe, it, expect, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { MAX_TRANSCRIPT_CHUNKS, withStreamRecording, expandTranscripts } from './recorder/index';
+import type { InteractionEvent } from './domain/events';
+import type { CanonicalEvent, CanonicalRequest } from './domain/types';
+import type { EventGranularity } from './ports/index';
+
+const request: CanonicalRequest = {
+    tenantId: 'tenant-a',
+    model: 'gpt-4o',
+    messages: [{ role: 'user', content: 'Write an essay' }],
+    stream: true,
+};
+
+/** A stream the size of a ~2k-token response: 500 content deltas. */
+function events(deltas = 500): CanonicalEvent[] {
+    return [
+        { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+        ...Array.from({ length: deltas }, (_, i): CanonicalEvent => ({ type: 'content_block_delta', index: 0, contentDelta: `tok${i} ` })),
+        { type: 'message_delta', finishReason: 'stop', usage: { promptTokens: 20, completionTokens: 2000, totalTokens: 2020 } },
+        { type: 'done' },
+    ];
+}
+
+function setup(granularity: EventGranularity | undefined, stream = events()) {
+    const saved: InteractionEvent[] = [];
+    const provider = withStreamRecording({
+        name: 'mock',
+        apiType: 'openai',
+        complete: vi.fn(),
+        stream: async function* () {
+            yield* stream;
+        },
+    }, {
+        events: { saveEvent: async (event) => void saved.push(event) },
+        interactionId: 'int-1',
+        granularity,
+    });
+    return { provider, saved };
+}
+
+async function drain(source: AsyncGenerator<CanonicalEvent, void, void>): Promise<CanonicalEvent[]> {
+    const seen: CanonicalEvent[] = [];
+    for await (const event of source) seen.push(event);
+    return seen;
+}
+
+describe('Stream event recording', () => {
+    it('should store one row per stream event with chunk granularity', async () => {
+        const { provider, saved } = setup('chunk');
+
+        await drain(provider.stream(request));
+
+        expect(saved).toHaveLength(503 + 3);
+        expect(saved.filter((e) => e.type === 'stream_chunk')).toHaveLength(503);
+        expect(saved[0]!.type).toBe('stream_start');
+        expect(saved[saved.length - 1]!.type).toBe('stream_end');
+    });
+
+    it('should compact the same stream into a transcript and three milestones', async () => {
+        const { provider, saved } = setup(undefined);
+
+        const seen = await drain(provider.stream(request));
+
+        expect(seen).toHaveLength(503);
+        expect(saved.map((e) => e.type)).toEqual(['stream_start', 'first_token', 'stream_transcript', 'stream_end']);
+        expect((saved[2]!.payload as any).chunks).toHaveLength(503);
+        expect(saved[3]!.payload).toMatchObject({
+            chunks: 503,
+            finishReason: 'stop',
+            usage: { totalTokens: 2020 },
+        });
+    });
+
+    it('should expand a transcript into the rows chunk granularity would have stored', async () => {
+        const chunked = setup('chunk');
+        const compacted = setup('compacted');
+        await drain(chunked.provider.stream(request));
+        await drain(compacted.provider.stream(request));
+
+        const expanded = expandTranscripts(compacted.saved);
+
+        const shape = (list: InteractionEvent[]) => list.map((e) => [e.type, e.payload]).filter(([type]) => type === 'stream_chunk');
+        expect(shape(expanded)).toEqual(shape(chunked.saved));
+        expect(expanded).toHaveLength(chunked.saved.length);
+    });
+
+    it('should bound the transcript and count what it dropped', async () => {
+        const { provider, saved } = setup('compacted', events(MAX_TRANSCRIPT_CHUNKS + 10));
+
+        await drain(provider.stream(request));
+
+        const transcript = saved.find((e) => e.type === 'stream_transcript')!.payload as any;
+        expect(transcript.chunks).toHaveLength(MAX_TRANSCRIPT_CHUNKS);
+        expect(transcript.dropped).toBe(13);
+    });
+
+    it('should save the transcript when the consumer stops early', async () => {
+        const { provider, saved } = setup('compacted');
+
+        const stream = provider.stream(request);
+        await stream.next();
+        await stream.next();
+        await stream.return(undefined);
+
+        expect(saved.map((e) => e.type)).toEqual(['stream_start', 'first_token', 'stream_transcript', 'stream_end']);
+        expect((saved[2]!.payload as any).chunks).toHaveLength(2);
+    });
+});
+
+describe('Admin interaction events', () => {
+    it('should expand compacted transcripts only when asked', async () => {
+        const { provider, saved } = setup('compacted', events(3));
+        await drain(provider.stream(request));
+        const admin = new AdminHandler({
+            storage: {
+                getConversation: async () => null,
+                getResponse: async () => ({ id: 'int-1' }),
+                getEvents: async () => saved,
+            } as any,
+        });
+
+        const list = async (query: string) =>
+            (await (await admin.handle(new Request(`http://localhost/api/interactions/int-1/events${query}`))).json()).events;
+
+        expect((await list('')).map((e: any) => e.type)).toEqual(['stream_start', 'first_token', 'stream_transcript', 'stream_end']);
+        const expanded = await list('?expand=chunks');
+        expect(expanded.map((e: any) => e.type)).toEqual([
+            'stream_start', 'first_token', ...Array(6).fill('stream_chunk'), 'stream_end',
+        ]);
+        expect(expanded[3].payload).toEqual({ seq: 1, event: { type: 'content_block_delta', index: 0, contentDelta: 'tok0 ' } });
+    });
+});
@@ -0,0 +1,206 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { createProviderRegistry } from './ports/index';
+import { ConversationSummarizer, SUMMARY_MESSAGE_PREFIX } from './summarization/index';
+import { errOverloaded } from './domain/errors';
+import type { CanonicalRequest, Message } from './domain/types';
+import type { LifecycleEvent } from './domain/events';
+import type { TokenCounter } from './tokens/index';
+
+/** Counts 100 tokens per message. */
+const tokens = { countRequest: (r: CanonicalRequest) => ({ inputTokens: r.messages.length * 100 }) } as unknown as TokenCounter;
+
+function turns(count: number): Message[] {
+    return Array.from({ length: count }, (_, i) => ({
+        role: i % 2 === 0 ? 'user' as const : 'assistant' as const,
+        content: `message ${i}`,
+    }));
+}
+
+const conversation = (messages: Message[]) => ({
+    thread: 'acme/responses',
+    request: { tenantId: 'acme', model: 'gpt-4o', messages, stream: false },
+});
+
+describe('ConversationSummarizer', () => {
+    it('should reuse a cached summary across consecutive turns until the recent turns no longer fit', async () => {
+        const summarizer = new ConversationSummarizer();
+        const summarize = vi.fn(async (messages: Message[], previous: string | undefined) =>
+            `${previous ?? ''}[${messages.map((m) => m.content).join(', ')}]`);
+        const options = { thresholdTokens: 950, keepRecent: 2, tokens, summarize };
+
+        const calls: number[] = [];
+        const results = [];
+        for (const length of [10, 12, 14, 16, 18, 20]) {
+            results.push(await summarizer.compact(conversation(turns(length)), options));
+            calls.push(summarize.mock.calls.length);
+        }
+
+        expect(calls).toEqual([1, 1, 1, 1, 2, 2]);
+        expect(results.map((r) => r.cached)).toEqual([false, true, true, true, false, true]);
+        expect(results[1]!.messages).toEqual([
+            { role: 'system', content: `${SUMMARY_MESSAGE_PREFIX}[message 0, message 1, message 2, message 3, message 4, message 5, message 6, message 7]` },
+            ...turns(12).slice(8),
+        ]);
+        // The second summary folds the first into itself
+        expect(summarize.mock.calls[1]![1]).toBe(results[0]!.messages[0]!.content.slice(SUMMARY_MESSAGE_PREFIX.length));
+        expect(summarize.mock.calls[1]![0]).toEqual(turns(16).slice(8));
+    });
+
+    it('should leave conversations under the threshold and leading system messages alone', async () => {
+        const summarizer = new ConversationSummarizer();
+        const summarize = vi.fn(async () => 'summary');
+        const messages: Message[] = [{ role: 'system', content: 'Be brief.' }, ...turns(9)];
+
+        const short = await summarizer.compact(conversation(turns(3)), { thresholdTokens: 950, tokens, summarize });
+        const long = await summarizer.compact(conversation(messages), { thresholdTokens: 950, keepRecent: 2, tokens, summarize });
+
+        expect(short.messages).toEqual(turns(3));
+        expect(long.messages).toEqual([
+            { role: 'system', content: 'Be brief.' },
+            { role: 'system', content: `${SUMMARY_MESSAGE_PREFIX}summary` },
+            ...messages.slice(8),
+        ]);
+        expect(summarize).toHaveBeenCalledTimes(1);
+    });
+
+    it('should drop the oldest turns when the summarizer fails', async () => {
+        const summarizer = new ConversationSummarizer();
+        const summarize = vi.fn(async () => {
+            throw new Error('summarizer down');
+        });
+
+        const result = await summarizer.compact(conversation(turns(12)), { thresholdTokens: 950, keepRecent: 2, tokens, summarize });
+
+        expect(result).toEqual({ messages: turns(12).slice(3), truncated: 3, error: 'summarizer down' });
+        expect(summarizer.size).toBe(0);
+    });
+});
+
+function setup(failSummaries = false, allowedProviders?: string[]) {
+    const chat = {
+        name: 'chat',
+        apiType: 'openai' as const,
+        complete: vi.fn(async (request: CanonicalRequest) => ({
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: request.model, sourceAPIType: 'openai' as const,
+            choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content: 'Hello' } }],
+            usage: { promptTokens: 100, completionTokens: 1, totalTokens: 101 },
+        })),
+        stream: vi.fn(),
+    };
+    const cheap = {
+        name: 'cheap',
+        apiType: 'openai' as const,
+        complete: vi.fn(async (request: CanonicalRequest) => {
+            if (failSummaries) {
+                throw errOverloaded('Provider overloaded');
+            }
+            return {
+                id: 'chatcmpl-2', object: 'chat.completion', created: 0, model: request.model, sourceAPIType: 'openai' as const,
+                choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content: 'The user said hi.' } }],
+                usage: { promptTokens: 50, completionTokens: 5, totalTokens: 55 },
+            };
+        }),
+        stream: vi.fn(),
+    };
+    const providerRegistry = createProviderRegistry();
+    providerRegistry.register('chat', () => chat as any);
+    providerRegistry.register('cheap', () => cheap as any);
+    const responses = new Map<string, any>();
+    const storage = {
+        saveResponse: async (r: any) => void responses.set(r.id, structuredClone(r)),
+        getResponse: async (id: string, tenantId: string) =>
+            responses.get(id)?.tenantId === tenantId ? structuredClone(responses.get(id)) : null,
+        saveEvent: async () => {},
+    };
+    const published: LifecycleEvent[] = [];
+    const gateway = new Gateway({
+        config: {
+            load: async () => ({
+                apps: [{
+                    name: 'resp',
+                    frontdoor: 'responses',
+                    path: '/v1/responses',
+                    threadSummary: { provider: 'cheap', model: 'gpt-4o-mini', thresholdTokens: 400, keepRecent: 2 },
+                }],
+                providers: [{ name: 'chat', type: 'chat', apiKey: 'sk-mock' }, { name: 'cheap', type: 'cheap', apiKey: 'sk-mock' }],
+                routing: { defaultProvider: 'chat' },
+                tenants: allowedProviders && [{ id: 'acme', name: 'Acme', apiKeys: [], allowedProviders }],
+            }),
+        },
+        auth: { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null },
+        storage: storage as any,
+        providerRegistry,
+        events: { publish: async (event: LifecycleEvent) => void published.push(event), close: async () => { } },
+    });
+
+    // Each turn is a ~100-token input answered with "Hello"
+    let previousResponseId: string | undefined;
+    const turn = async () => {
+        const response = await gateway.fetch(new Request('http://localhost/v1/responses', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer k', 'Content-Type': 'application/json' },
+            body: JSON.stringify({ model: 'gpt-4o', input: 'word '.repeat(100), previousResponseId }),
+        }));
+        expect(response.status).toBe(200);
+        previousResponseId = (await response.json()).id;
+        return chat.complete.mock.calls.at(-1)![0].messages as Message[];
+    };
+    const internal = () => published.filter((e) => (e.data as any)?.internal === true);
+    return { cheap, responses, turn, internal };
+}
+
+describe('Thread summaries', () => {
+    it('should summarize a long previous_response_id chain once across consecutive turns', async () => {
+        const { cheap, responses, turn, internal } = setup();
+
+        const sent: Message[][] = [];
+        for (let i = 0; i < 6; i++) {
+            sent.push(await turn());
+        }
+
+        expect(sent.slice(0, 3).map((m) => m.length)).toEqual([1, 3, 5]);
+        expect(cheap.complete).toHaveBeenCalledTimes(1);
+        for (const messages of sent.slice(3)) {
+            expect(messages[0]).toEqual({ role: 'system', content: `${SUMMARY_MESSAGE_PREFIX}The user said hi.` });
+        }
+        // Stored responses keep the whole conversation for later turns
+        const last = [...responses.values()].at(-1);
+        expect(last.request.messages).toHaveLength(11);
+
+        await vi.waitFor(() => expect(internal()).toHaveLength(1));
+        expect(internal()[0]).toMatchObject({
+            tenantId: 'acme',
+            data: { providerName: 'cheap', model: 'gpt-4o-mini', statusCode: 200, internal: true },
+        });
+    });
+
+    it('should fall back to dropping the oldest turns when the summarizer fails', async () => {
+        const { cheap, turn, internal } = setup(true);
+
+        for (let i = 0; i < 3; i++) {
+            await turn();
+        }
+        const messages = await turn();
+
+        expect(cheap.complete).toHaveBeenCalledTimes(1);
+        expect(messages.some((m) => m.content.startsWith(SUMMARY_MESSAGE_PREFIX))).toBe(false);
+        expect(messages.length).toBeLessThan(7);
+        expect(messages.at(-1)!.role).toBe('user');
+        await vi.waitFor(() => expect(internal()[0]?.data).toMatchObject({ statusCode: 503, internal: true }));
+    });
+
+    it('should not summarize with a provider the tenant may not use', async () => {
+        const { cheap, turn, internal } = setup(false, ['chat']);
+
+        const sent: Message[][] = [];
+        for (let i = 0; i < 4; i++) {
+            sent.push(await turn());
+        }
+
+        expect(cheap.complete).not.toHaveBeenCalled();
+        expect(sent.map((m) => m.length)).toEqual([1, 3, 5, 7]);
+        expect(sent.flat().some((m) => m.content.startsWith(SUMMARY_MESSAGE_PREFIX))).toBe(false);
+        expect(internal()).toEqual([]);
+    });
+});
@@ -0,0 +1,17 @@
+/**
+ * Conversation summary exports.
+ *
+ * @module summarization
+ */
+
+export {
+    ConversationSummarizer,
+    summaryPrompt,
+    DEFAULT_SUMMARY_KEEP_RECENT,
+    DEFAULT_SUMMARY_CACHE_ENTRIES,
+    SUMMARY_MESSAGE_PREFIX,
+    type Conversation,
+    type SummarizeTurns,
+    type CompactionOptions,
+    type CompactedConversation,
+} from './summarizer.js';
@@ -0,0 +1,300 @@
+/**
+ * Conversation summaries for long threads.
+ *
+ * A conversation rebuilt from previous_response_id or a thread's messages
+ * grows every turn until it no longer fits the model. For apps with
+ * thread summaries on, once it passes the token threshold the oldest
+ * turns are replaced by one system message summarizing them, written by a
+ * configured (cheap) model, and only the most recent turns are sent as
+ * they are. Summaries are cached by thread and the last message they
+ * cover, so consecutive turns reuse one until the turns after it pass the
+ * threshold again; a new summary then folds the old one into itself. When
+ * the summarizer fails, the oldest turns are dropped instead.
+ *
+ * @module summarization/summarizer
+ */
+
+import type { CanonicalRequest, Message } from '../domain/types.js';
+import { isInstructionMessage } from '../domain/types.js';
+import type { TokenCounter } from '../tokens/count.js';
+import { sha256 } from '../utils/crypto.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default number of most recent messages always sent as they are. */
+export const DEFAULT_SUMMARY_KEEP_RECENT = 6;
+
+/** Default number of summaries kept in the cache. */
+export const DEFAULT_SUMMARY_CACHE_ENTRIES = 1000;
+
+/** Starts the system message carrying a summary. */
+export const SUMMARY_MESSAGE_PREFIX = 'Conversation summary (earlier turns):\n';
+
+/** Instructions given to the summarizer model. */
+const SUMMARIZER_INSTRUCTIONS = [
+    'Summarize the conversation below for an assistant that will continue it without seeing it.',
+    'Keep every fact, name, number, decision, preference and open question that may matter later.',
+    'Write plain prose without a preamble.',
+].join(' ');
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A reconstructed conversation about to be sent.
+ */
+export interface Conversation {
+    /** Identifies the conversation within its tenant (a thread ID, or a response chain). */
+    thread: string;
+
+    /** The request carrying the full conversation. */
+    request: CanonicalRequest;
+
+    /**
+     * Stored message IDs, one per message. Without them a message is
+     * identified by a digest of the conversation up to and including it.
+     */
+    messageIds?: string[] | undefined;
+}
+
+/**
+ * Summarizes messages, folding in the summary of the turns before them.
+ */
+export type SummarizeTurns = (messages: Message[], previous: string | undefined) => Promise<string>;
+
+/**
+ * How a conversation is compacted.
+ */
+export interface CompactionOptions {
+    /** Input tokens above which the conversation is compacted. */
+    thresholdTokens: number;
+
+    /** Most recent messages always sent as they are (default 6). */
+    keepRecent?: number | undefined;
+
+    /** Counts the conversation's tokens. */
+    tokens: TokenCounter;
+
+    /** Writes a summary (the configured summarizer model). */
+    summarize: SummarizeTurns;
+}
+
+/**
+ * A conversation as it will be sent.
+ */
+export interface CompactedConversation {
+    /** Messages to send. */
+    messages: Message[];
+
+    /** Messages the summary replaced, when one was used. */
+    summarized?: number | undefined;
+
+    /** Whether the summary came from the cache. */
+    cached?: boolean | undefined;
+
+    /** Messages dropped because the summarizer failed. */
+    truncated?: number | undefined;
+
+    /** Why the summarizer failed. */
+    error?: string | undefined;
+}
+
+/** A cached summary. */
+interface CachedSummary {
+    /** Summary text. */
+    summary: string;
+
+    /** Index of the first message after the ones it covers. */
+    through: number;
+}
+
+// ============================================================================
+// Summarizer
+// ============================================================================
+
+/**
+ * Compacts long conversations, caching summaries across turns.
+ */
+export class ConversationSummarizer {
+    private readonly maxEntries: number;
+    private readonly cache = new Map<string, string>();
+
+    constructor(options: { maxEntries?: number | undefined } = {}) {
+        this.maxEntries = options.maxEntries ?? DEFAULT_SUMMARY_CACHE_ENTRIES;
+    }
+
+    /** Number of summaries cached. */
+    get size(): number {
+        return this.cache.size;
+    }
+
+    /**
+     * Returns the messages to send for a conversation: unchanged while it
+     * fits the threshold, otherwise its leading system messages, a summary
+     * of the turns that follow them, and the most recent turns.
+     */
+    async compact(conversation: Conversation, options: CompactionOptions): Promise<CompactedConversation> {
+        const { request } = conversation;
+        const messages = request.messages;
+        const count = (candidate: Message[]): number =>
+            options.tokens.countRequest({ ...request, messages: candidate }).inputTokens;
+        if (count(messages) <= options.thresholdTokens) {
+            return { messages };
+        }
+
+        // Leading system messages are kept as they are; the recent turns
+        // never start with a tool result cut off from its call
+        const pinned = leadingSystemMessages(messages);
+        let end = Math.max(pinned, messages.length - (options.keepRecent ?? DEFAULT_SUMMARY_KEEP_RECENT));
+        while (end > pinned && messages[end]?.role === 'tool') {
+            end--;
+        }
+        if (end === pinned) {
+            return { messages };
+        }
+
+        const ids = conversation.messageIds?.length === messages.length
+            ? conversation.messageIds
+            : await prefixDigests(messages);
+        const withSummary = (summary: string, through: number): Message[] => [
+            ...messages.slice(0, pinned),
+            { role: 'system', content: SUMMARY_MESSAGE_PREFIX + summary },
+            ...messages.slice(through),
+        ];
+
+        // The latest summary cached for this conversation serves until the
+        // turns after it no longer fit
+        const cached = this.latest(conversation.thread, ids, pinned, end);
+        if (cached) {
+            const compacted = withSummary(cached.summary, cached.through);
+            if (cached.through === end || count(compacted) <= options.thresholdTokens) {
+                return { messages: compacted, summarized: cached.through - pinned, cached: true };
+            }
+        }
+
+        let summary: string;
+        try {
+            summary = await options.summarize(messages.slice(cached?.through ?? pinned, end), cached?.summary);
+        } catch (error) {
+            return {
+                ...truncate(messages, pinned, options.thresholdTokens, count),
+                error: error instanceof Error ? error.message : String(error),
+            };
+        }
+        this.remember(conversation.thread, ids[end - 1]!, summary);
+        return { messages: withSummary(summary, end), summarized: end - pinned, cached: false };
+    }
+
+    /**
+     * Finds the cached summary covering the most messages up to `end`.
+     */
+    private latest(thread: string, ids: string[], pinned: number, end: number): CachedSummary | undefined {
+        for (let through = end; through > pinned; through--) {
+            const summary = this.cache.get(cacheKey(thread, ids[through - 1]!));
+            if (summary !== undefined) {
+                // Refresh its place in the eviction order
+                this.remember(thread, ids[through - 1]!, summary);
+                return { summary, through };
+            }
+        }
+        return undefined;
+    }
+
+    /**
+     * Caches a summary, evicting the least recently used past the limit.
+     */
+    private remember(thread: string, lastId: string, summary: string): void {
+        const key = cacheKey(thread, lastId);
+        this.cache.delete(key);
+        this.cache.set(key, summary);
+        while (this.cache.size > this.maxEntries) {
+            this.cache.delete(this.cache.keys().next().value!);
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Builds the summarizer's messages: its instructions, then the turns to
+ * summarize (after the earlier summary, when there is one) as a transcript.
+ */
+export function summaryPrompt(messages: Message[], previous: string | undefined): Message[] {
+    const sections: string[] = [];
+    if (previous !== undefined) {
+        sections.push(`Summary of the conversation so far:\n${previous}`);
+    }
+    sections.push(`Conversation:\n${messages.map(transcriptLine).join('\n')}`);
+    return [
+        { role: 'system', content: SUMMARIZER_INSTRUCTIONS },
+        { role: 'user', content: sections.join('\n\n') },
+    ];
+}
+
+/** Cache key of a summary: the conversation and the last message it covers. */
+function cacheKey(thread: string, lastId: string): string {
+    return `${thread}\0${lastId}`;
+}
+
+/** Number of system and developer messages at the start of a conversation. */
+function leadingSystemMessages(messages: Message[]): number {
+    let count = 0;
+    while (messages[count] && isInstructionMessage(messages[count]!)) {
+        count++;
+    }
+    return count;
+}
+
+/**
+ * Identifies each message by a digest of the conversation up to and
+ * including it, so the same prefix of the same conversation always has
+ * the same IDs.
+ */
+async function prefixDigests(messages: Message[]): Promise<string[]> {
+    const ids: string[] = [];
+    let previous = '';
+    for (const message of messages) {
+        previous = await sha256(previous + JSON.stringify(message));
+        ids.push(previous);
+    }
+    return ids;
+}
+
+/**
+ * Keeps the most recent messages that fit the threshold (at least the
+ * last one) after the leading system messages, never starting with a
+ * tool result.
+ */
+function truncate(
+    messages: Message[],
+    pinned: number,
+    thresholdTokens: number,
+    count: (messages: Message[]) => number,
+): CompactedConversation {
+    const head = messages.slice(0, pinned);
+    let start = messages.length - 1;
+    while (start > pinned && count([...head, ...messages.slice(start - 1)]) <= thresholdTokens) {
+        start--;
+    }
+    while (start < messages.length - 1 && messages[start]?.role === 'tool') {
+        start++;
+    }
+    return { messages: [...head, ...messages.slice(start)], truncated: start - pinned };
+}
+
+/** Renders a message as one transcript line. */
+function transcriptLine(message: Message): string {
+    const parts = message.richContent?.parts;
+    let text = parts
+        ? parts.map((part) => part.text ?? part.resultContent ?? '').filter(Boolean).join('\n')
+        : message.content;
+    for (const call of message.toolCalls ?? []) {
+        text += `${text ? '\n' : ''}[called ${call.function.name}(${call.function.arguments})]`;
+    }
+    return `${message.role}: ${text}`;
+}
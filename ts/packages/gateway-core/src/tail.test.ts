@@ -0,0 +1,200 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { INTERACTION_ID_HEADER } from './correlation/index';
+import { createInteractionEvent } from './domain/events';
+import type { CanonicalEvent, CanonicalRequest } from './domain/types';
+import { InteractionTails, type TailMessage } from './tail/index';
+import { harness, parseSSE, ScriptedProvider, type Reply, type SSEFrame, type TestGateway } from './__tests__/harness/index';
+
+/** A scripted provider whose stream pauses after its first delta until released. */
+class GatedProvider extends ScriptedProvider {
+    private release!: () => void;
+    private readonly gate = new Promise<void>((resolve) => {
+        this.release = resolve;
+    });
+
+    constructor(name: string, reply: Reply) {
+        super(name, reply);
+    }
+
+    open(): void {
+        this.release();
+    }
+
+    override async *stream(request: CanonicalRequest): AsyncGenerator<CanonicalEvent, void, void> {
+        let deltas = 0;
+        for await (const event of super.stream(request)) {
+            if (event.type === 'content_block_delta' && deltas++ > 0) {
+                await this.gate;
+            }
+            yield event;
+        }
+    }
+}
+
+/** Admin tokens: "ops" is an operator, others are scoped to their tenant. */
+const auth = {
+    authenticate: async (token: string) => ({ tenantId: token, scopes: token === 'ops' ? ['admin'] : [], metadata: {} }),
+    getTenant: async () => null,
+};
+
+/**
+ * An admin client following an interaction, reading frames as they arrive.
+ */
+class TailClient {
+    readonly frames: SSEFrame[] = [];
+    private readonly reader: ReadableStreamDefaultReader<Uint8Array>;
+    private readonly decoder = new TextDecoder();
+    private buffer = '';
+
+    constructor(readonly response: Response) {
+        this.reader = response.body!.getReader();
+    }
+
+    /** Reads until `count` frames have arrived, or the tail ends. */
+    async read(count = Infinity): Promise<SSEFrame[]> {
+        while (this.frames.length < count) {
+            const { value, done } = await this.reader.read();
+            if (done) break;
+            this.buffer += this.decoder.decode(value, { stream: true });
+            const end = this.buffer.lastIndexOf('\n\n');
+            if (end >= 0) {
+                this.frames.push(...parseSSE(this.buffer.slice(0, end + 2)));
+                this.buffer = this.buffer.slice(end + 2);
+            }
+        }
+        return this.frames;
+    }
+}
+
+function tail(admin: AdminHandler, id: string, token: string, wait?: string): Promise<Response> {
+    const query = wait === undefined ? '' : `?wait=${wait}`;
+    return admin.handle(new Request(`http://localhost/api/interactions/${id}/tail${query}`, {
+        headers: { Authorization: `Bearer ${token}` },
+    }));
+}
+
+/** Interaction event types of the frames, then the end frame's status. */
+function sequence(frames: SSEFrame[]): string[] {
+    return frames.map((f) => f.event === 'end' ? `end:${JSON.parse(f.data).status}` : JSON.parse(f.data).type);
+}
+
+describe('Interaction tail', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const provider = new GatedProvider('mock', { chunks: ['Hel', 'lo'] });
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', eventGranularity: 'chunk' })
+            .provider(provider)
+            .start();
+        const admin = new AdminHandler({ storage: gateway.store as any, tails: gateway.gateway.interactionTails, auth });
+        return { gw: gateway, provider, admin };
+    }
+
+    it('should replay logged events, then follow live ones, then end with the final status', async () => {
+        const { gw, provider, admin } = await setup();
+
+        const response = await gw.post('/v1/chat/completions', {
+            model: 'gpt-4o',
+            stream: true,
+            messages: [{ role: 'user', content: 'Hi' }],
+        });
+        const id = response.headers.get(INTERACTION_ID_HEADER)!;
+
+        const client = new TailClient(await tail(admin, id, 'ops'));
+        expect(client.response.headers.get('Content-Type')).toBe('text/event-stream');
+
+        // Paused mid-stream: what was logged so far is replayed
+        const before = gw.store.eventsFor(id).map((e) => e.type);
+        expect(before).toContain('first_token');
+        expect(before).not.toContain('stream_end');
+        expect(sequence(await client.read(before.length))).toEqual(before);
+
+        provider.open();
+        expect(await response.text()).toContain('Hel');
+        const frames = await client.read();
+
+        // Every event, once, in the order logged, then the terminal message
+        const logged = gw.store.eventsFor(id);
+        expect(sequence(frames)).toEqual([...logged.map((e) => e.type), 'end:completed']);
+        expect(frames.slice(0, -1).map((f) => JSON.parse(f.data).id)).toEqual(logged.map((e) => e.id));
+        expect(logged.length).toBeGreaterThan(before.length);
+        expect(frames.every((f) => f.event === 'interaction_event' || f.event === 'end')).toBe(true);
+    });
+
+    it('should wait for an interaction that has not started yet', async () => {
+        const { gw, admin } = await setup();
+        const tails = gw.gateway.interactionTails;
+
+        const following = tail(admin, 'later', 'acme', '5s');
+        await new Promise((resolve) => setTimeout(resolve, 20));
+        tails.open('later', 'acme');
+        tails.publish(createInteractionEvent('stream_start', 'later', {}));
+        tails.close('later', 'failed');
+
+        expect(sequence(await new TailClient(await following).read())).toEqual(['stream_start', 'end:failed']);
+        expect((await tail(admin, 'never', 'acme', '20ms')).status).toBe(404);
+    });
+
+    it('should not let a tenant follow another tenant\'s interaction', async () => {
+        const { gw, provider, admin } = await setup();
+        provider.open();
+
+        const response = await gw.post('/v1/chat/completions', {
+            model: 'gpt-4o',
+            stream: true,
+            messages: [{ role: 'user', content: 'Hi' }],
+        });
+        const id = response.headers.get(INTERACTION_ID_HEADER)!;
+        await response.text();
+
+        expect((await tail(admin, id, 'globex', '20ms')).status).toBe(404);
+        const own = await new TailClient(await tail(admin, id, 'acme')).read();
+        expect(sequence(own).at(-1)).toBe('end:completed');
+    });
+
+    it('should reject a malformed wait', async () => {
+        const { admin } = await setup();
+
+        expect((await tail(admin, 'x', 'ops', 'soon')).status).toBe(400);
+    });
+});
+
+describe('InteractionTails', () => {
+    async function drain(messages: AsyncGenerator<TailMessage, void, void>): Promise<string[]> {
+        const seen: string[] = [];
+        for await (const message of messages) {
+            seen.push(message.type === 'end' ? `end:${message.status}` : String(message.event.payload));
+        }
+        return seen;
+    }
+
+    it('should keep only the newest events for a subscriber that falls behind', async () => {
+        const tails = new InteractionTails({ maxEvents: 2 });
+        tails.open('i', 't');
+        const messages = tails.subscribe('i', 't')!;
+
+        for (const n of [1, 2, 3]) {
+            tails.publish(createInteractionEvent('stream_chunk', 'i', n));
+        }
+        tails.close('i', 'completed');
+
+        expect(await drain(messages)).toEqual(['2', '3', 'end:completed']);
+    });
+
+    it('should end subscriptions whose interaction is evicted', async () => {
+        const tails = new InteractionTails({ maxChannels: 1 });
+        tails.open('a', 't');
+        const messages = tails.subscribe('a', 't')!;
+        tails.open('b', 't');
+
+        expect(await drain(messages)).toEqual(['end:unknown']);
+        expect(tails.subscribe('b', 'other')).toBeUndefined();
+    });
+});
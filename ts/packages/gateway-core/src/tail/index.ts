@@ -0,0 +1,15 @@
+/**
+ * Live interaction tail exports.
+ *
+ * @module tail
+ */
+
+export {
+    InteractionTails,
+    DEFAULT_TAIL_MAX_EVENTS,
+    DEFAULT_TAIL_GRACE_MS,
+    DEFAULT_TAIL_MAX_CHANNELS,
+    type InteractionTailsOptions,
+    type TailMessage,
+    type TailStatus,
+} from './tails.js';
@@ -0,0 +1,259 @@
+/**
+ * Live interaction tails.
+ *
+ * Every routed request gets a channel, keyed by interaction ID, that
+ * collects its interaction events as they are saved (decode, pipeline
+ * stages, provider chunks, encode) until the request reaches its final
+ * status, and for a short grace period after. An admin client following
+ * an interaction subscribes to its channel: buffered events first, then
+ * live ones, then the final status.
+ *
+ * Publishing only appends to the channel's bounded buffer and wakes
+ * subscribers; it never waits on them, so a slow tail client never slows
+ * the request it follows.
+ *
+ * @module tail/tails
+ */
+
+import type { InteractionEvent } from '../domain/events.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Default number of events buffered per interaction. */
+export const DEFAULT_TAIL_MAX_EVENTS = 1000;
+
+/** Default time a finished interaction stays followable (ms). */
+export const DEFAULT_TAIL_GRACE_MS = 10_000;
+
+/** Default number of interactions followed at once. */
+export const DEFAULT_TAIL_MAX_CHANNELS = 10_000;
+
+/**
+ * How a followed interaction ended. "unknown" means its channel was
+ * evicted before it finished.
+ */
+export type TailStatus = 'completed' | 'failed' | 'cancelled' | 'unknown';
+
+/**
+ * A message delivered to a tail subscriber: an interaction event, or the
+ * interaction's final status, always last.
+ */
+export type TailMessage =
+    | { type: 'event'; event: InteractionEvent }
+    | { type: 'end'; status: TailStatus };
+
+/**
+ * Options for the interaction tails.
+ */
+export interface InteractionTailsOptions {
+    /** Events buffered per interaction; older events are dropped first. */
+    maxEvents?: number | undefined;
+
+    /** How long a finished interaction stays followable (ms). */
+    gracePeriodMs?: number | undefined;
+
+    /** Maximum interactions followed at once; the oldest is evicted first. */
+    maxChannels?: number | undefined;
+}
+
+interface TailChannel {
+    tenantId: string;
+    events: InteractionEvent[];
+    /** Events dropped from the front of the buffer. */
+    dropped: number;
+    status?: TailStatus | undefined;
+    /** Wakes subscribers waiting for new events. */
+    notify: () => void;
+    changed: Promise<void>;
+    expiry?: ReturnType<typeof setTimeout> | undefined;
+}
+
+// ============================================================================
+// Interaction Tails
+// ============================================================================
+
+/**
+ * Bounded in-memory channels of interaction events keyed by interaction ID.
+ */
+export class InteractionTails {
+    private readonly maxEvents: number;
+    private readonly gracePeriodMs: number;
+    private readonly maxChannels: number;
+    private readonly channels = new Map<string, TailChannel>();
+    private readonly waiters = new Map<string, Set<() => void>>();
+
+    constructor(options: InteractionTailsOptions = {}) {
+        this.maxEvents = options.maxEvents ?? DEFAULT_TAIL_MAX_EVENTS;
+        this.gracePeriodMs = options.gracePeriodMs ?? DEFAULT_TAIL_GRACE_MS;
+        this.maxChannels = options.maxChannels ?? DEFAULT_TAIL_MAX_CHANNELS;
+    }
+
+    /**
+     * Opens an interaction's channel. Called once, when it is routed.
+     */
+    open(interactionId: string, tenantId: string): void {
+        this.evict(interactionId);
+        while (this.channels.size >= this.maxChannels) {
+            const oldest = this.channels.keys().next().value;
+            if (oldest === undefined) break;
+            this.evict(oldest);
+        }
+
+        const channel: TailChannel = {
+            tenantId,
+            events: [],
+            dropped: 0,
+            notify: () => undefined,
+            changed: Promise.resolve(),
+        };
+        resetSignal(channel);
+        this.channels.set(interactionId, channel);
+
+        const waiting = this.waiters.get(interactionId);
+        this.waiters.delete(interactionId);
+        for (const wake of waiting ?? []) {
+            wake();
+        }
+    }
+
+    /**
+     * Appends a saved event to its interaction's channel. Events of
+     * interactions without an open channel are ignored.
+     */
+    publish(event: InteractionEvent): void {
+        const channel = this.channels.get(event.interactionId);
+        if (!channel || channel.status) {
+            return;
+        }
+        channel.events.push(event);
+        if (channel.events.length > this.maxEvents) {
+            channel.events.shift();
+            channel.dropped++;
+        }
+        signal(channel);
+    }
+
+    /**
+     * Records an interaction's final status, ending its subscriptions.
+     * It stays followable for the grace period.
+     */
+    close(interactionId: string, status: Exclude<TailStatus, 'unknown'>): void {
+        const channel = this.channels.get(interactionId);
+        if (!channel || channel.status) return;
+
+        channel.status = status;
+        signal(channel);
+        channel.expiry = setTimeout(() => this.evict(interactionId), this.gracePeriodMs);
+        // Don't hold the process open for channel cleanup
+        (channel.expiry as { unref?: () => void }).unref?.();
+    }
+
+    /**
+     * Follows an interaction: its buffered events, live events as they are
+     * saved, and its final status. Returns undefined when it has no
+     * channel, or belongs to another tenant (the unscoped tenant follows
+     * any).
+     */
+    subscribe(interactionId: string, tenantId: string): AsyncGenerator<TailMessage, void, void> | undefined {
+        const channel = this.channels.get(interactionId);
+        if (!channel || (tenantId !== UNSCOPED_TENANT && channel.tenantId !== tenantId)) {
+            return undefined;
+        }
+        return follow(channel);
+    }
+
+    /**
+     * Resolves true once the interaction's channel opens (immediately if it
+     * is open), or false after the timeout or when the signal aborts.
+     */
+    waitFor(interactionId: string, timeoutMs: number, abort?: AbortSignal): Promise<boolean> {
+        if (this.channels.has(interactionId)) {
+            return Promise.resolve(true);
+        }
+        return new Promise((resolve) => {
+            let waiting = this.waiters.get(interactionId);
+            if (!waiting) {
+                waiting = new Set();
+                this.waiters.set(interactionId, waiting);
+            }
+            const done = (opened: boolean): void => {
+                clearTimeout(timer);
+                abort?.removeEventListener('abort', aborted);
+                waiting.delete(wake);
+                if (waiting.size === 0 && this.waiters.get(interactionId) === waiting) {
+                    this.waiters.delete(interactionId);
+                }
+                resolve(opened);
+            };
+            const wake = (): void => done(true);
+            const aborted = (): void => done(false);
+            const timer = setTimeout(() => done(false), timeoutMs);
+            waiting.add(wake);
+            abort?.addEventListener('abort', aborted, { once: true });
+        });
+    }
+
+    /**
+     * Number of followable interactions.
+     */
+    get size(): number {
+        return this.channels.size;
+    }
+
+    /**
+     * Drops all channels, ending their subscriptions.
+     */
+    clear(): void {
+        for (const id of [...this.channels.keys()]) {
+            this.evict(id);
+        }
+    }
+
+    private evict(interactionId: string): void {
+        const channel = this.channels.get(interactionId);
+        if (!channel) return;
+
+        clearTimeout(channel.expiry);
+        this.channels.delete(interactionId);
+        // Release any subscribers still waiting on this channel
+        channel.status ??= 'unknown';
+        signal(channel);
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+async function* follow(channel: TailChannel): AsyncGenerator<TailMessage, void, void> {
+    // Position in the channel's events since it opened, dropped ones included
+    let cursor = channel.dropped;
+    for (;;) {
+        const changed = channel.changed;
+        while (cursor < channel.dropped + channel.events.length) {
+            cursor = Math.max(cursor, channel.dropped);
+            yield { type: 'event', event: channel.events[cursor - channel.dropped]! };
+            cursor++;
+        }
+        if (channel.status) {
+            yield { type: 'end', status: channel.status };
+            return;
+        }
+        await changed;
+    }
+}
+
+function signal(channel: TailChannel): void {
+    const notify = channel.notify;
+    resetSignal(channel);
+    notify();
+}
+
+function resetSignal(channel: TailChannel): void {
+    channel.changed = new Promise<void>((resolve) => {
+        channel.notify = resolve;
+    });
+}
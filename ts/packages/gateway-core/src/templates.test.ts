@@ -0,0 +1,160 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openAIFrontdoor, responsesFrontdoor } from './frontdoors/index';
+import { AdminHandler } from './admin/index';
+import {
+    loadPromptTemplates,
+    renderTemplate,
+    applyTemplate,
+    applyResponsesTemplate,
+    type PromptTemplates,
+} from './templates/index';
+
+const usage = { promptTokens: 3, completionTokens: 2, totalTokens: 5 };
+
+function mockProvider() {
+    return {
+        name: 'mock',
+        apiType: 'openai',
+        complete: vi.fn(async (req: any) => ({
+            id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model, sourceAPIType: 'openai',
+            usage,
+            choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'Hi' } }],
+        })),
+        stream: vi.fn(),
+    };
+}
+
+function handle(frontdoor: any, path: string, body: unknown, templates: PromptTemplates) {
+    const provider = mockProvider();
+    const result = frontdoor.handle({
+        request: new Request(`http://localhost${path}`, { method: 'POST', body: JSON.stringify(body) }),
+        provider,
+        templates,
+        storage: { async saveResponse() { } },
+        auth: { tenantId: 't', scopes: [], metadata: {} },
+        interactionId: 'int-1',
+    } as any);
+    return { provider, result };
+}
+
+const templates = () => loadPromptTemplates([
+    {
+        name: 'support_agent_v2',
+        text: 'You support {{ product }} customers. Reply in {{language}}.',
+        required: ['product'],
+    },
+    { name: 'preamble', text: 'Context: {{topic}}', placement: 'user' },
+]);
+
+describe('prompt templates', () => {
+    it('should version templates by a hash of their text', async () => {
+        const loaded = await templates();
+        const edited = await loadPromptTemplates([{ name: 'support_agent_v2', text: 'Something else' }]);
+
+        const version = loaded.get('support_agent_v2')!.version;
+        expect(version).toMatch(/^[0-9a-f]{12}$/);
+        expect((await templates()).get('support_agent_v2')!.version).toBe(version);
+        expect(edited.get('support_agent_v2')!.version).not.toBe(version);
+        expect(loaded.get('support_agent_v2')!.placement).toBe('system');
+    });
+
+    it('should render variables and leave unset optional ones empty', async () => {
+        const rendered = renderTemplate({ name: 'support_agent_v2', variables: { product: 'Acme' } }, await templates());
+
+        expect(rendered.text).toBe('You support Acme customers. Reply in .');
+    });
+
+    it('should reject unknown templates and missing required variables', async () => {
+        const loaded = await templates();
+
+        expect(() => renderTemplate({ name: 'nope' }, loaded)).toThrow("template.name: unknown template 'nope'");
+        expect(() => renderTemplate({ name: 'support_agent_v2', variables: { language: 'en' } }, loaded))
+            .toThrow('template.variables.product: is required');
+        expect(() => renderTemplate({ name: 'support_agent_v2' }, undefined)).toThrow('unknown template');
+    });
+
+    it('should place rendered text ahead of the system prompt or as the first user message', async () => {
+        const loaded = await templates();
+        const request = { model: 'm', messages: [{ role: 'user', content: 'Hi' }], systemPrompt: 'Be brief.' } as any;
+
+        applyTemplate(request, renderTemplate({ name: 'support_agent_v2', variables: { product: 'Acme', language: 'en' } }, loaded));
+        expect(request.systemPrompt).toBe('You support Acme customers. Reply in en.\n\nBe brief.');
+
+        applyTemplate(request, renderTemplate({ name: 'preamble', variables: { topic: 'billing' } }, loaded));
+        expect(request.messages.map((m: any) => m.content)).toEqual(['Context: billing', 'Hi']);
+
+        const responses = { model: 'm', input: 'Hi' } as any;
+        applyResponsesTemplate(responses, renderTemplate({ name: 'preamble', variables: { topic: 'x' } }, loaded));
+        expect(responses.input).toEqual([
+            { type: 'message', role: 'user', content: 'Context: x' },
+            { type: 'message', role: 'user', content: 'Hi' },
+        ]);
+    });
+});
+
+describe('frontdoor templates', () => {
+    it('should render into the chat system prompt and record name and version', async () => {
+        const loaded = await templates();
+        const { provider, result } = handle(openAIFrontdoor, '/v1/chat/completions', {
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'My order is late' }],
+            template: { name: 'support_agent_v2', variables: { product: 'Acme', language: 'English' } },
+        }, loaded);
+        const { response, metadata, transformations } = await result;
+
+        expect(response.status).toBe(200);
+        expect(provider.complete.mock.calls[0]![0].systemPrompt).toBe('You support Acme customers. Reply in English.');
+        expect(metadata).toEqual({
+            template: 'support_agent_v2',
+            template_version: loaded.get('support_agent_v2')!.version,
+        });
+        expect(transformations).toEqual([expect.objectContaining({ stage: 'prompt_template' })]);
+    });
+
+    it('should reject a missing required variable with a 400 naming it', async () => {
+        const { provider, result } = handle(openAIFrontdoor, '/v1/chat/completions', {
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'Hi' }],
+            template: { name: 'support_agent_v2', variables: {} },
+        }, await templates());
+        const { response } = await result;
+
+        expect(response.status).toBe(400);
+        expect(((await response.json()) as any).error.message).toBe('template.variables.product: is required');
+        expect(provider.complete).not.toHaveBeenCalled();
+    });
+
+    it('should render into Responses API instructions', async () => {
+        const loaded = await templates();
+        const { provider, result } = handle(responsesFrontdoor, '/v1/responses', {
+            model: 'gpt-4o',
+            input: 'Hi',
+            instructions: 'Be brief.',
+            template: { name: 'support_agent_v2', variables: { product: 'Acme', language: 'en' } },
+        }, loaded);
+        const { response, metadata } = await result;
+
+        expect(response.status).toBe(200);
+        expect(provider.complete.mock.calls[0]![0].instructions).toBe('You support Acme customers. Reply in en.\n\nBe brief.');
+        expect(metadata).toMatchObject({ template: 'support_agent_v2' });
+
+        const unknown = handle(responsesFrontdoor, '/v1/responses', { model: 'gpt-4o', input: 'Hi', template: { name: 'nope' } }, loaded);
+        expect((await unknown.result).response.status).toBe(400);
+    });
+});
+
+describe('GET /api/templates', () => {
+    it('should list templates with their versions', async () => {
+        const loaded = await templates();
+        const admin = new AdminHandler({ templates: () => Array.from(loaded.values()) });
+
+        const response = await admin.handle(new Request('http://localhost/api/templates'));
+        const body = await response.json() as any;
+
+        expect(response.status).toBe(200);
+        expect(body.templates.map((t: any) => [t.name, t.version])).toEqual([
+            ['support_agent_v2', loaded.get('support_agent_v2')!.version],
+            ['preamble', loaded.get('preamble')!.version],
+        ]);
+    });
+});
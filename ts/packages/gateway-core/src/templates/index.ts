@@ -0,0 +1,17 @@
+/**
+ * Prompt template exports.
+ *
+ * @module templates
+ */
+
+export {
+    loadPromptTemplates,
+    renderTemplate,
+    applyTemplate,
+    applyResponsesTemplate,
+    templateMetadata,
+    describeTemplate,
+    type PromptTemplate,
+    type PromptTemplates,
+    type RenderedTemplate,
+} from './prompt.js';
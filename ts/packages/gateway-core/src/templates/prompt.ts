@@ -0,0 +1,160 @@
+/**
+ * Gateway-managed prompt templates.
+ *
+ * Templates come from the `templates` config section and are referenced by
+ * requests as `{"template": {"name": ..., "variables": {...}}}`. The
+ * rendered text becomes the system prompt or a prepended user message,
+ * per the template's placement. Each template's version is a hash of its
+ * text, so interactions can be correlated with the exact wording served.
+ *
+ * @module templates/prompt
+ */
+
+import type { PromptTemplateConfig } from '../ports/config.js';
+import type { CanonicalRequest } from '../domain/types.js';
+import type { ResponsesAPIRequest } from '../domain/responses.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+import { invalidField } from '../codecs/validation.js';
+import { sha256 } from '../utils/crypto.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Hex digits of the text hash kept as the template version. */
+const VERSION_LENGTH = 12;
+
+/** `{{variable}}` placeholders; whitespace inside the braces is allowed. */
+const PLACEHOLDER = /\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}/g;
+
+/**
+ * A loaded template, with its version.
+ */
+export interface PromptTemplate {
+    /** Template name. */
+    name: string;
+
+    /** Template text with {{variable}} placeholders. */
+    text: string;
+
+    /** Variables every request must supply. */
+    required: string[];
+
+    /** Where the rendered text goes. */
+    placement: 'system' | 'user';
+
+    /** Leading hex digits of the SHA-256 of the text. */
+    version: string;
+}
+
+/**
+ * Templates by name, as loaded from config.
+ */
+export type PromptTemplates = ReadonlyMap<string, PromptTemplate>;
+
+/**
+ * A request's template, rendered.
+ */
+export interface RenderedTemplate {
+    template: PromptTemplate;
+    text: string;
+}
+
+// ============================================================================
+// Loading and Rendering
+// ============================================================================
+
+/**
+ * Loads configured templates, computing each one's version.
+ */
+export async function loadPromptTemplates(configs: PromptTemplateConfig[] | undefined): Promise<PromptTemplates> {
+    const templates = new Map<string, PromptTemplate>();
+    for (const config of configs ?? []) {
+        templates.set(config.name, {
+            name: config.name,
+            text: config.text,
+            required: config.required ?? [],
+            placement: config.placement ?? 'system',
+            version: (await sha256(config.text)).slice(0, VERSION_LENGTH),
+        });
+    }
+    return templates;
+}
+
+/**
+ * Renders a request's template reference (already shape-checked by the
+ * frontdoor's validator). Unknown templates and missing required
+ * variables fail with a 400; placeholders without a value render empty.
+ */
+export function renderTemplate(ref: unknown, templates: PromptTemplates | undefined): RenderedTemplate {
+    const { name, variables = {} } = ref as { name: string; variables?: Record<string, unknown> };
+    const template = templates?.get(name);
+    if (!template) {
+        throw invalidField('template.name', `unknown template '${name}'`);
+    }
+
+    for (const variable of template.required) {
+        if (variables[variable] === undefined || variables[variable] === null) {
+            throw invalidField(`template.variables.${variable}`, 'is required');
+        }
+    }
+
+    const text = template.text.replace(PLACEHOLDER, (_, variable: string) => {
+        const value = variables[variable];
+        return value === undefined || value === null ? '' : String(value);
+    });
+    return { template, text };
+}
+
+/**
+ * Places rendered text in a canonical request: ahead of any system
+ * prompt, or as a new first user message.
+ */
+export function applyTemplate(request: CanonicalRequest, rendered: RenderedTemplate): void {
+    if (rendered.template.placement === 'user') {
+        request.messages.unshift({ role: 'user', content: rendered.text });
+    } else {
+        request.systemPrompt = joinPrompt(rendered.text, request.systemPrompt);
+    }
+}
+
+/**
+ * Places rendered text in a Responses API request: ahead of any
+ * instructions, or as a new first input message.
+ */
+export function applyResponsesTemplate(request: ResponsesAPIRequest, rendered: RenderedTemplate): void {
+    if (rendered.template.placement === 'user') {
+        const input = typeof request.input === 'string'
+            ? [{ type: 'message' as const, role: 'user' as const, content: request.input }]
+            : request.input ?? [];
+        request.input = [{ type: 'message', role: 'user', content: rendered.text }, ...input];
+    } else {
+        request.instructions = joinPrompt(rendered.text, request.instructions);
+    }
+}
+
+/**
+ * Interaction metadata identifying the template served.
+ */
+export function templateMetadata(rendered: RenderedTemplate): Record<string, string> {
+    return { template: rendered.template.name, template_version: rendered.template.version };
+}
+
+/**
+ * Records the rendering, for interaction recording.
+ */
+export function describeTemplate(rendered: RenderedTemplate): TransformationStep {
+    const { name, version, placement } = rendered.template;
+    return {
+        stage: 'prompt_template',
+        timestamp: new Date(),
+        description: placement === 'user'
+            ? `Rendered template '${name}' as the first user message`
+            : `Rendered template '${name}' into the system prompt`,
+        details: { name, version, placement },
+    };
+}
+
+function joinPrompt(rendered: string, existing: string | undefined): string {
+    return existing ? `${rendered}\n\n${existing}` : rendered;
+}
@@ -0,0 +1,179 @@
+import { describe, it, expect, vi } from 'vitest';
+import { responsesFrontdoor } from './frontdoors/index';
+import { AdminHandler } from './admin/index';
+
+const now = new Date();
+
+/** Storage seeded with one of everything for tenant-a and tenant-b. */
+function seededStorage() {
+    const conversations = new Map<string, any>();
+    const responses = new Map<string, any>();
+    const threads = new Map<string, any>();
+    const events = new Map<string, any[]>();
+    const shadows = new Map<string, any>();
+
+    for (const tenant of ['a', 'b']) {
+        const tenantId = `tenant-${tenant}`;
+        conversations.set(`conv_${tenant}`, {
+            id: `conv_${tenant}`, tenantId, model: 'gpt-4o', messages: [], createdAt: now, updatedAt: now,
+        });
+        responses.set(`resp_${tenant}`, {
+            id: `resp_${tenant}`, tenantId, model: 'gpt-4o', status: 'in_progress', createdAt: now, updatedAt: now,
+        });
+        threads.set(`thread_${tenant}`, {
+            id: `thread_${tenant}`, tenantId, messages: [], createdAt: now, updatedAt: now,
+        });
+        events.set(`resp_${tenant}`, [
+            { id: `evt_${tenant}`, interactionId: `resp_${tenant}`, type: 'request', timestamp: now },
+        ]);
+        shadows.set(`shadow_${tenant}`, {
+            id: `shadow_${tenant}`, interactionId: `resp_${tenant}`, providerName: 'shadow',
+            divergences: [], hasStructuralDivergence: false, durationMs: 1, createdAt: now,
+        });
+    }
+
+    const visible = (owner: string | undefined, tenantId: string) =>
+        owner !== undefined && (tenantId === '' || owner === tenantId);
+    const owner = (interactionId: string) =>
+        conversations.get(interactionId)?.tenantId ?? responses.get(interactionId)?.tenantId;
+    const scoped = <T extends { tenantId: string }>(item: T | undefined, tenantId: string) =>
+        item && visible(item.tenantId, tenantId) ? item : null;
+    const byTenant = <T extends { tenantId: string }>(items: Map<string, T>, tenantId: string) =>
+        Array.from(items.values()).filter((i) => i.tenantId === tenantId);
+
+    return {
+        saveResponse: vi.fn(),
+        saveConversation: vi.fn(),
+        async getConversation(id: string, tenantId: string) {
+            return scoped(conversations.get(id), tenantId);
+        },
+        async getResponse(id: string, tenantId: string) {
+            return scoped(responses.get(id), tenantId);
+        },
+        async getThread(id: string, tenantId: string) {
+            return scoped(threads.get(id), tenantId);
+        },
+        async listConversations(tenantId: string) {
+            return byTenant(conversations, tenantId);
+        },
+        async listResponses(tenantId: string) {
+            return byTenant(responses, tenantId);
+        },
+        async listInteractions(options: any) {
+            return byTenant(responses, options.tenantId).map((r) => ({ ...r, type: 'response' }));
+        },
+        async getInteractionCount(options: any) {
+            return byTenant(responses, options.tenantId).length;
+        },
+        async getEvents(interactionId: string, tenantId: string) {
+            return visible(owner(interactionId), tenantId) ? events.get(interactionId) ?? [] : [];
+        },
+        async getShadowResult(id: string, tenantId: string) {
+            const shadow = shadows.get(id);
+            return shadow && visible(owner(shadow.interactionId), tenantId) ? shadow : null;
+        },
+        addMessage: vi.fn(),
+        listMessages: vi.fn(async () => []),
+    } as any;
+}
+
+const provider = {
+    name: 'mock',
+    apiType: 'openai',
+    complete: vi.fn(),
+    stream: vi.fn(),
+} as any;
+
+describe('tenant isolation', () => {
+    describe('responses frontdoor', () => {
+        const storage = seededStorage();
+
+        function request(method: string, path: string, body?: unknown) {
+            return responsesFrontdoor.handle({
+                request: new Request(`http://localhost${path}`, {
+                    method,
+                    headers: { 'Content-Type': 'application/json' },
+                    body: body === undefined ? undefined : JSON.stringify(body),
+                }),
+                provider,
+                auth: { tenantId: 'tenant-b', scopes: ['*'], metadata: {} },
+                interactionId: 'int-1',
+                storage,
+            } as any);
+        }
+
+        const cases: [string, string, unknown?][] = [
+            ['GET', '/v1/responses/resp_a'],
+            ['GET', '/v1/responses/resp_a?stream=true'],
+            ['POST', '/v1/responses/resp_a/cancel'],
+            ['POST', '/v1/responses', { model: 'gpt-4o', input: 'hi', previousResponseId: 'resp_a' }],
+            ['GET', '/v1/threads/thread_a'],
+            ['GET', '/v1/threads/thread_a/messages'],
+            ['POST', '/v1/threads/thread_a/messages', { content: 'hi' }],
+            ['POST', '/v1/threads/thread_a/runs', {}],
+        ];
+
+        it.each(cases)('%s %s returns 404 for another tenant', async (method, path, body) => {
+            const { response } = await request(method, path, body);
+
+            expect(response.status).toBe(404);
+            expect(provider.complete).not.toHaveBeenCalled();
+            expect(storage.addMessage).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('admin handler', () => {
+        const auth = {
+            async authenticate(token: string) {
+                if (token === 'key-b') return { tenantId: 'tenant-b', scopes: ['chat'], metadata: {} };
+                if (token === 'key-ops') return { tenantId: 'ops', scopes: ['admin'], metadata: {} };
+                return null;
+            },
+            async getTenant() {
+                return null;
+            },
+        };
+        const handler = new AdminHandler({ storage: seededStorage(), auth, providerHealth: () => [] });
+
+        function get(path: string, token?: string) {
+            return handler.handle(new Request(`http://localhost${path}`, {
+                headers: token ? { Authorization: `Bearer ${token}` } : {},
+            }));
+        }
+
+        it('requires a valid token', async () => {
+            expect((await get('/api/responses')).status).toBe(401);
+            expect((await get('/api/responses', 'bogus')).status).toBe(401);
+            expect((await get('/api/health')).status).toBe(200);
+        });
+
+        it.each(['/api/stats', '/api/overview', '/api/providers/health'])(
+            '%s is forbidden to tenant tokens',
+            async (path) => {
+                expect((await get(path, 'key-b')).status).toBe(403);
+                expect((await get(path, 'key-ops')).status).toBe(200);
+            },
+        );
+
+        it.each([
+            '/api/responses/resp_a',
+            '/api/threads/conv_a',
+            '/api/interactions/resp_a',
+            '/api/interactions/resp_a/events',
+            '/api/shadows/shadow_a',
+        ])('%s returns 404 for another tenant', async (path) => {
+            expect((await get(path, 'key-b')).status).toBe(404);
+            expect((await get(path.replace(/_a\b/, '_b'), 'key-b')).status).toBe(200);
+            expect((await get(path, 'key-ops')).status).toBe(200);
+        });
+
+        it('lists only the caller\'s tenant', async () => {
+            const responses = await (await get('/api/responses', 'key-b')).json();
+            const interactions = await (await get('/api/interactions', 'key-b')).json();
+
+            expect(responses.responses.map((r: any) => r.id)).toEqual(['resp_b']);
+            expect(interactions.interactions.map((i: any) => i.id)).toEqual(['resp_b']);
+            expect(interactions.total).toBe(1);
+        });
+    });
+});
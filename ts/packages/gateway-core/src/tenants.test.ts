@@ -0,0 +1,291 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { AdminHandler } from './admin/index';
+import { TenantRegistry } from './tenants/index';
+import { hashAPIKey, createProviderRegistry } from './ports/index';
+import type { GatewayConfig, StoredTenant, TenantStore } from './ports/index';
+
+function memoryStore(seed: StoredTenant[] = []) {
+    const tenants = new Map(seed.map((t) => [t.id, t]));
+    return {
+        saveTenant: vi.fn(async (tenant: StoredTenant) => { tenants.set(tenant.id, tenant); }),
+        async getTenant(id: string) { return tenants.get(id) ?? null; },
+        async listTenants() { return Array.from(tenants.values()); },
+    } satisfies TenantStore;
+}
+
+const logger = () => {
+    const log = { debug: vi.fn(), info: vi.fn(), warn: vi.fn(), error: vi.fn(), child: vi.fn() };
+    log.child.mockReturnValue(log);
+    return log;
+};
+
+async function config(): Promise<Pick<GatewayConfig, 'tenants' | 'providers'>> {
+    return {
+        providers: [
+            { name: 'primary', type: 'mock', apiKey: '' },
+            { name: 'cheap', type: 'mock', apiKey: '' },
+        ],
+        tenants: [{ id: 'acme', name: 'Acme', apiKeys: [{ keyHash: await hashAPIKey('acme-key') }] }],
+    };
+}
+
+function stored(id: string, overrides: Partial<StoredTenant> = {}): StoredTenant {
+    return {
+        id,
+        name: id,
+        apiKeys: [],
+        disabled: false,
+        createdAt: new Date(),
+        updatedAt: new Date(),
+        ...overrides,
+    };
+}
+
+function send(admin: AdminHandler, method: string, path: string, body?: unknown) {
+    return admin.handle(new Request(`http://localhost${path}`, {
+        method,
+        ...(body !== undefined && { body: JSON.stringify(body) }),
+    }));
+}
+
+describe('TenantRegistry', () => {
+    it('should authenticate config and stored tenants by key hash', async () => {
+        const store = memoryStore([stored('beta', { apiKeys: [{ keyHash: await hashAPIKey('beta-key') }] })]);
+        const registry = new TenantRegistry({ store });
+        await registry.load(await config());
+
+        expect(await registry.authenticate('acme-key')).toEqual({ tenantId: 'acme', scopes: [], metadata: {} });
+        expect((await registry.authenticate('beta-key'))?.tenantId).toBe('beta');
+        expect(await registry.authenticate('nope')).toBeNull();
+        expect(registry.list().map((t) => [t.id, t.source])).toEqual([['acme', 'config'], ['beta', 'store']]);
+    });
+
+    it('should let a config tenant win an ID collision with a stored one', async () => {
+        const log = logger();
+        const store = memoryStore([stored('acme', {
+            name: 'Impostor',
+            apiKeys: [{ keyHash: await hashAPIKey('impostor-key') }],
+            routing: { defaultProvider: 'cheap' },
+        })]);
+        const registry = new TenantRegistry({ store, logger: log as any });
+        await registry.load(await config());
+
+        expect(registry.get('acme')).toMatchObject({ name: 'Acme', source: 'config' });
+        expect(registry.list()).toHaveLength(1);
+        expect(await registry.authenticate('impostor-key')).toBeNull();
+        expect(registry.routing('acme')).toBeUndefined();
+        expect(log.warn).toHaveBeenCalledWith('tenant_id_collision', expect.objectContaining({ tenantId: 'acme' }));
+
+        await expect(registry.create({ id: 'acme', name: 'Again' })).rejects.toMatchObject({ statusCode: 409 });
+    });
+
+    it('should create tenants whose key works immediately and reject it once disabled', async () => {
+        const store = memoryStore();
+        const registry = new TenantRegistry({ store });
+        await registry.load(await config());
+
+        const { tenant, apiKey } = await registry.create({
+            name: 'Signup Co',
+            routing: { rules: [{ modelPrefix: 'gpt', provider: 'cheap' }] },
+        });
+
+        expect(tenant).toMatchObject({ source: 'store', disabled: false, apiKeys: 1 });
+        expect(apiKey).toMatch(/^pgw_[0-9a-f]{32}$/);
+        expect(JSON.stringify(store.saveTenant.mock.calls)).not.toContain(apiKey);
+        expect((await registry.authenticate(apiKey))?.tenantId).toBe(tenant.id);
+        expect(registry.routing(tenant.id)?.rules?.[0]?.provider).toBe('cheap');
+
+        await registry.setDisabled(tenant.id, true);
+        expect(await registry.authenticate(apiKey)).toBeNull();
+        expect(registry.isDisabled(tenant.id)).toBe(true);
+
+        await registry.setDisabled(tenant.id, false);
+        expect((await registry.authenticate(apiKey))?.tenantId).toBe(tenant.id);
+    });
+
+    it('should reject routing to unknown providers and changes to config tenants', async () => {
+        const registry = new TenantRegistry({ store: memoryStore() });
+        await registry.load(await config());
+
+        await expect(registry.create({ name: 'X', routing: { defaultProvider: 'tenant-only' } }))
+            .rejects.toThrow("routing references unknown provider 'tenant-only'");
+        await expect(registry.update('acme', { name: 'Renamed' })).rejects.toMatchObject({ statusCode: 409 });
+        await expect(registry.setDisabled('acme', true)).rejects.toMatchObject({ statusCode: 409 });
+        await expect(registry.update('missing', { name: 'X' })).rejects.toMatchObject({ statusCode: 404 });
+    });
+});
+
+describe('/api/tenants', () => {
+    async function setup() {
+        const store = memoryStore();
+        const tenants = new TenantRegistry({ store });
+        await tenants.load(await config());
+        return { store, tenants, admin: new AdminHandler({ tenants }) };
+    }
+
+    it('should create, update, disable, and re-enable a tenant', async () => {
+        const { tenants, admin } = await setup();
+
+        const created = await send(admin, 'POST', '/api/tenants', {
+            id: 'signup-co',
+            name: 'Signup Co',
+            routing: { rules: [{ model_prefix: 'gpt', provider: 'cheap' }], default_provider: 'primary' },
+        });
+        const body = await created.json() as any;
+        expect(created.status).toBe(201);
+        expect(body.tenant).toMatchObject({ id: 'signup-co', source: 'store', disabled: false });
+        expect(tenants.routing('signup-co')).toEqual({
+            rules: [{ modelPrefix: 'gpt', provider: 'cheap' }],
+            defaultProvider: 'primary',
+        });
+
+        const updated = await send(admin, 'PATCH', '/api/tenants/signup-co', { routing: { default_provider: 'cheap' } });
+        expect(await updated.json()).toMatchObject({ routing: { rules: [], defaultProvider: 'cheap' } });
+
+        const deleted = await send(admin, 'DELETE', '/api/tenants/signup-co');
+        expect(await deleted.json()).toMatchObject({ disabled: true });
+        expect(await tenants.authenticate(body.apiKey)).toBeNull();
+
+        await send(admin, 'POST', '/api/tenants/signup-co/enable');
+        expect((await (await send(admin, 'GET', '/api/tenants/signup-co')).json() as any).disabled).toBe(false);
+
+        const list = await (await send(admin, 'GET', '/api/tenants')).json() as any;
+        expect(list.tenants.map((t: any) => t.id)).toEqual(['acme', 'signup-co']);
+        expect(JSON.stringify(list)).not.toContain('keyHash');
+    });
+
+    it.each([
+        ['a missing name', { routing: { default_provider: 'primary' } }, 400, 'name is required'],
+        ['a rule without a model match', { name: 'X', routing: { rules: [{ provider: 'cheap' }] } }, 400,
+            'routing.rules[0] needs model_prefix or model_exact'],
+        ['an unknown provider', { name: 'X', routing: { default_provider: 'nope' } }, 400,
+            "routing references unknown provider 'nope'"],
+        ['an ID with a slash', { id: 'a/b', name: 'X' }, 400, 'id may only contain letters, digits, ".", "_", and "-"'],
+        ['a config tenant ID', { id: 'acme', name: 'X' }, 409, "Tenant 'acme' already exists"],
+    ])('should reject %s', async (_name, request, status, message) => {
+        const { store, admin } = await setup();
+
+        const response = await send(admin, 'POST', '/api/tenants', request);
+
+        expect(response.status).toBe(status);
+        expect(await response.json()).toEqual({ error: message });
+        expect(store.saveTenant).not.toHaveBeenCalled();
+    });
+
+    it('should keep config tenants read-only', async () => {
+        const { admin } = await setup();
+
+        const response = await send(admin, 'PATCH', '/api/tenants/acme', { name: 'Renamed' });
+
+        expect(response.status).toBe(409);
+        expect(await response.json()).toEqual({ error: "Tenant 'acme' is defined in config and is read-only" });
+    });
+
+    it('should be operator-only and need tenant storage to write', async () => {
+        const auth = { authenticate: async () => ({ tenantId: 'acme', scopes: [], metadata: {} }), getTenant: async () => null };
+        const { tenants } = await setup();
+        const scoped = new AdminHandler({ tenants, auth: auth as any });
+        const asTenant = (method: string, path: string) => scoped.handle(new Request(`http://localhost${path}`, {
+            method,
+            headers: { Authorization: 'Bearer k' },
+            ...(method === 'POST' && { body: '{"name":"X"}' }),
+        }));
+
+        expect((await asTenant('POST', '/api/tenants')).status).toBe(403);
+        expect((await asTenant('GET', '/api/tenants')).status).toBe(403);
+        expect((await asTenant('GET', '/api/tenants/acme')).status).toBe(200);
+
+        const readOnly = new TenantRegistry();
+        await readOnly.load(await config());
+        expect((await send(new AdminHandler({ tenants: readOnly }), 'POST', '/api/tenants', { name: 'X' })).status).toBe(503);
+    });
+});
+
+describe('Gateway with runtime tenants', () => {
+    function mockProvider(name: string) {
+        return {
+            name,
+            apiType: 'openai' as const,
+            complete: vi.fn(async (req: any) => ({
+                id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: req.model,
+                usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+                choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: name } }],
+            })),
+            stream: vi.fn(),
+        };
+    }
+
+    it('should authenticate and route a new tenant without a restart', async () => {
+        const providers = { primary: mockProvider('primary'), cheap: mockProvider('cheap') };
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', (c) => providers[c.name as keyof typeof providers] as any);
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    ...(await config()),
+                    apps: [{ name: 'default', frontdoor: 'openai', path: '/v1' }],
+                    routing: { defaultProvider: 'primary' },
+                }),
+            } as any,
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            storage: memoryStore() as any,
+            providerRegistry,
+            logger: logger() as any,
+        });
+        await gateway.reload();
+        const chat = (key: string) => gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+            method: 'POST',
+            headers: { Authorization: `Bearer ${key}`, 'Content-Type': 'application/json' },
+            body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] }),
+        }));
+
+        const { tenant, apiKey } = await gateway.tenants.create({ name: 'New', routing: { defaultProvider: 'cheap' } });
+
+        const routed = await chat(apiKey);
+        expect(routed.status).toBe(200);
+        expect(providers.cheap.complete).toHaveBeenCalledTimes(1);
+        expect((await chat('acme-key')).status).toBe(200);
+        expect(providers.primary.complete).toHaveBeenCalledTimes(1);
+
+        await gateway.tenants.setDisabled(tenant.id, true);
+        expect((await chat(apiKey)).status).toBe(401);
+    });
+
+    it('should keep the previous router when a reload has an invalid tenant', async () => {
+        const providers = { primary: mockProvider('primary'), cheap: mockProvider('cheap') };
+        const providerRegistry = createProviderRegistry();
+        providerRegistry.register('mock', (c) => providers[c.name as keyof typeof providers] as any);
+        let next: Partial<GatewayConfig> = { routing: { defaultProvider: 'primary' } };
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    ...(await config()),
+                    apps: [{ name: 'default', frontdoor: 'openai', path: '/v1' }],
+                    ...next,
+                }),
+            } as any,
+            auth: { authenticate: async () => null, getTenant: async () => null },
+            storage: memoryStore() as any,
+            providerRegistry,
+            logger: logger() as any,
+        });
+        await gateway.reload();
+
+        next = {
+            routing: { defaultProvider: 'cheap' },
+            tenants: [{ id: 'acme', name: 'Acme', apiKeys: [{ keyHash: await hashAPIKey('acme-key') }], allowedProviders: ['ghost'] }],
+        };
+        await expect(gateway.reload()).rejects.toThrow("Invalid config for tenant 'acme': allowedProviders: unknown provider 'ghost'");
+
+        const response = await gateway.fetch(new Request('http://localhost/v1/chat/completions', {
+            method: 'POST',
+            headers: { Authorization: 'Bearer acme-key', 'Content-Type': 'application/json' },
+            body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'Hi' }] }),
+        }));
+        expect(response.status).toBe(200);
+        expect(providers.primary.complete).toHaveBeenCalledTimes(1);
+        expect(providers.cheap.complete).not.toHaveBeenCalled();
+        expect(gateway.tenants.allowedProviders('acme')).toBeUndefined();
+    });
+});
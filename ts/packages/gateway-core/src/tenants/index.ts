@@ -0,0 +1,18 @@
+/**
+ * Tenant registry exports.
+ *
+ * @module tenants
+ */
+
+export {
+    TenantRegistry,
+    isTenantStore,
+    TENANT_ID_PATTERN,
+    TENANT_API_KEY_PREFIX,
+    type TenantInfo,
+    type TenantSource,
+    type CreateTenantInput,
+    type UpdateTenantInput,
+    type TenantRegistryOptions,
+    type LoadedTenants,
+} from './registry.js';
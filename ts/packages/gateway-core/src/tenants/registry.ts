@@ -0,0 +1,420 @@
+/**
+ * Tenant registry: config-defined tenants merged with ones created at runtime.
+ *
+ * Config tenants are read-only through the admin API. Tenants created
+ * through it are persisted in a TenantStore and route over the global
+ * providers. Both kinds authenticate by API key hash. Every mutation
+ * updates the in-memory view it is read from, so auth and routing see a
+ * new, changed, or disabled tenant on the next request without a restart.
+ *
+ * @module tenants/registry
+ */
+
+import type { AuthContext } from '../ports/auth.js';
+import { hashAPIKey } from '../ports/auth.js';
+import type { GatewayConfig, RoutingConfig, TenantConfig } from '../ports/config.js';
+import type { StorageProvider, TenantStore, StoredTenant } from '../ports/storage.js';
+import { APIError, errInvalidRequest, errNotFound } from '../domain/errors.js';
+import type { Logger } from '../utils/logging.js';
+import { randomUUID } from '../utils/crypto.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** Characters allowed in a tenant ID (it appears in admin API paths). */
+export const TENANT_ID_PATTERN = /^[A-Za-z0-9_.-]+$/;
+
+/** Prefix of API keys issued to tenants created through the admin API. */
+export const TENANT_API_KEY_PREFIX = 'pgw_';
+
+/** Where a tenant is defined. */
+export type TenantSource = 'config' | 'store';
+
+/**
+ * A tenant as reported by /admin/api/tenants. Key hashes are never shown.
+ */
+export interface TenantInfo {
+    /** Tenant ID. */
+    id: string;
+
+    /** Tenant name. */
+    name: string;
+
+    /** Where the tenant is defined; config tenants are read-only. */
+    source: TenantSource;
+
+    /** Whether the tenant's keys are rejected. */
+    disabled: boolean;
+
+    /** Number of API keys. */
+    apiKeys: number;
+
+    /** Tenant routing, when it has its own. */
+    routing?: RoutingConfig | undefined;
+
+    /** Providers the tenant's requests may be sent to, when restricted. */
+    allowedProviders?: string[] | undefined;
+
+    /** Creation time (stored tenants). */
+    createdAt?: Date | undefined;
+
+    /** Last update time (stored tenants). */
+    updatedAt?: Date | undefined;
+}
+
+/**
+ * A tenant to create.
+ */
+export interface CreateTenantInput {
+    /** Tenant ID (default: generated). */
+    id?: string | undefined;
+
+    /** Tenant name. */
+    name: string;
+
+    /** Routing over the global providers (default: the global routing). */
+    routing?: RoutingConfig | undefined;
+}
+
+/**
+ * Changes to a stored tenant. A null routing reverts to the global routing.
+ */
+export interface UpdateTenantInput {
+    name?: string | undefined;
+    routing?: RoutingConfig | null | undefined;
+}
+
+/**
+ * Tenants read for a config, checked and ready to apply.
+ */
+export interface LoadedTenants {
+    configTenants: Map<string, TenantConfig>;
+    storedTenants: Map<string, StoredTenant>;
+    providers: Set<string>;
+}
+
+/**
+ * Tenant registry options.
+ */
+export interface TenantRegistryOptions {
+    /** Store for tenants created at runtime; without one the API is read-only. */
+    store?: TenantStore | undefined;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+}
+
+// ============================================================================
+// Tenant Registry
+// ============================================================================
+
+/**
+ * Merged view of config-defined and stored tenants.
+ */
+export class TenantRegistry {
+    private readonly store: TenantStore | undefined;
+    private readonly logger: Logger | undefined;
+    private configTenants = new Map<string, TenantConfig>();
+    private storedTenants = new Map<string, StoredTenant>();
+    private providers = new Set<string>();
+    private keys = new Map<string, { tenantId: string; app?: string | undefined }>();
+
+    constructor(options: TenantRegistryOptions = {}) {
+        this.store = options.store;
+        this.logger = options.logger;
+    }
+
+    /** Whether tenants can be created and changed. */
+    get writable(): boolean {
+        return this.store !== undefined;
+    }
+
+    /**
+     * Loads config tenants and re-reads the store. A stored tenant whose ID
+     * is also defined in config is ignored; the config one wins.
+     */
+    async load(config: Pick<GatewayConfig, 'tenants' | 'providers'>): Promise<void> {
+        this.apply(await this.prepare(config));
+    }
+
+    /**
+     * Checks config tenants and re-reads the store without changing what
+     * the registry serves. Throws if a config tenant is invalid or the
+     * store can't be read.
+     */
+    async prepare(config: Pick<GatewayConfig, 'tenants' | 'providers'>): Promise<LoadedTenants> {
+        const providers = new Set(config.providers.map((p) => p.name));
+        const configTenants = new Map<string, TenantConfig>();
+        for (const tenant of config.tenants ?? []) {
+            checkConfigTenant(tenant, configTenants, providers);
+            configTenants.set(tenant.id, tenant);
+        }
+
+        const storedTenants = new Map<string, StoredTenant>();
+        for (const tenant of await this.store?.listTenants() ?? []) {
+            if (configTenants.has(tenant.id)) {
+                this.logger?.warn('tenant_id_collision', {
+                    tenantId: tenant.id,
+                    warning: 'stored tenant ignored; a config tenant has the same ID',
+                });
+                continue;
+            }
+            storedTenants.set(tenant.id, tenant);
+        }
+        return { configTenants, storedTenants, providers };
+    }
+
+    /**
+     * Serves tenants read by prepare().
+     */
+    apply(loaded: LoadedTenants): void {
+        this.configTenants = loaded.configTenants;
+        this.storedTenants = loaded.storedTenants;
+        this.providers = loaded.providers;
+        this.reindex();
+    }
+
+    /**
+     * Authenticates an API key against enabled tenants' key hashes.
+     */
+    async authenticate(token: string): Promise<AuthContext | null> {
+        if (this.keys.size === 0) {
+            return null;
+        }
+        const key = this.keys.get(await hashAPIKey(token));
+        return key ? { tenantId: key.tenantId, scopes: [], metadata: {}, ...(key.app && { app: key.app }) } : null;
+    }
+
+    /**
+     * Whether a tenant has been disabled. Unknown tenants are not.
+     */
+    isDisabled(tenantId: string): boolean {
+        return this.storedTenants.get(tenantId)?.disabled === true;
+    }
+
+    /**
+     * The tenant's own routing, if it has any.
+     */
+    routing(tenantId: string): RoutingConfig | undefined {
+        return this.configTenants.get(tenantId)?.routing ?? this.storedTenants.get(tenantId)?.routing;
+    }
+
+    /**
+     * The providers the tenant's requests may go to, or undefined for any.
+     */
+    allowedProviders(tenantId: string): string[] | undefined {
+        return this.configTenants.get(tenantId)?.allowedProviders;
+    }
+
+    /**
+     * Lists every tenant, config ones first.
+     */
+    list(): TenantInfo[] {
+        return [
+            ...Array.from(this.configTenants.values(), configInfo),
+            ...Array.from(this.storedTenants.values(), storedInfo),
+        ];
+    }
+
+    /**
+     * Gets a tenant by ID.
+     */
+    get(id: string): TenantInfo | undefined {
+        const configured = this.configTenants.get(id);
+        if (configured) {
+            return configInfo(configured);
+        }
+        const stored = this.storedTenants.get(id);
+        return stored && storedInfo(stored);
+    }
+
+    /**
+     * Creates a tenant with one API key. The key is only ever returned here.
+     */
+    async create(input: CreateTenantInput): Promise<{ tenant: TenantInfo; apiKey: string }> {
+        const store = this.requireStore();
+        const id = input.id ?? `tnt_${randomUUID().replace(/-/g, '').slice(0, 16)}`;
+        if (!TENANT_ID_PATTERN.test(id)) {
+            throw errInvalidRequest('id may only contain letters, digits, ".", "_", and "-"');
+        }
+        if (this.configTenants.has(id) || this.storedTenants.has(id) || await store.getTenant(id)) {
+            throw conflict(`Tenant '${id}' already exists`);
+        }
+        this.checkRouting(input.routing);
+
+        const apiKey = `${TENANT_API_KEY_PREFIX}${randomUUID().replace(/-/g, '')}`;
+        const now = new Date();
+        const tenant: StoredTenant = {
+            id,
+            name: input.name,
+            apiKeys: [{ keyHash: await hashAPIKey(apiKey), description: 'initial key' }],
+            routing: input.routing,
+            disabled: false,
+            createdAt: now,
+            updatedAt: now,
+        };
+        await this.save(store, tenant);
+        this.logger?.info('tenant_created', { audit: true, tenantId: id });
+        return { tenant: storedInfo(tenant), apiKey };
+    }
+
+    /**
+     * Renames a stored tenant or changes its routing.
+     */
+    async update(id: string, input: UpdateTenantInput): Promise<TenantInfo> {
+        const store = this.requireStore();
+        const existing = this.mutable(id);
+        if (input.routing) {
+            this.checkRouting(input.routing);
+        }
+
+        const tenant: StoredTenant = {
+            ...existing,
+            name: input.name ?? existing.name,
+            routing: input.routing === undefined ? existing.routing : input.routing ?? undefined,
+            updatedAt: new Date(),
+        };
+        await this.save(store, tenant);
+        this.logger?.info('tenant_updated', { audit: true, tenantId: id });
+        return storedInfo(tenant);
+    }
+
+    /**
+     * Disables or re-enables a stored tenant. Disabling rejects its keys
+     * from the next request on; tenants are never hard-deleted.
+     */
+    async setDisabled(id: string, disabled: boolean): Promise<TenantInfo> {
+        const store = this.requireStore();
+        const tenant: StoredTenant = { ...this.mutable(id), disabled, updatedAt: new Date() };
+        await this.save(store, tenant);
+        this.logger?.info(disabled ? 'tenant_disabled' : 'tenant_enabled', { audit: true, tenantId: id });
+        return storedInfo(tenant);
+    }
+
+    private requireStore(): TenantStore {
+        if (!this.store) {
+            throw new APIError('server', 'Tenant storage not available', { statusCode: 503 });
+        }
+        return this.store;
+    }
+
+    private mutable(id: string): StoredTenant {
+        if (this.configTenants.has(id)) {
+            throw conflict(`Tenant '${id}' is defined in config and is read-only`);
+        }
+        const tenant = this.storedTenants.get(id);
+        if (!tenant) {
+            throw errNotFound(`Tenant '${id}' not found`);
+        }
+        return tenant;
+    }
+
+    private checkRouting(routing: RoutingConfig | undefined): void {
+        const referenced = [
+            ...(routing?.rules ?? []).map((r) => r.provider),
+            ...(routing?.defaultProvider ? [routing.defaultProvider] : []),
+        ];
+        const unknown = referenced.find((name) => !this.providers.has(name));
+        if (unknown !== undefined) {
+            throw errInvalidRequest(`routing references unknown provider '${unknown}'`);
+        }
+    }
+
+    private async save(store: TenantStore, tenant: StoredTenant): Promise<void> {
+        await store.saveTenant(tenant);
+        this.storedTenants.set(tenant.id, tenant);
+        this.reindex();
+    }
+
+    /**
+     * Rebuilds the key index. Config tenants are indexed last so they win
+     * a key hash collision.
+     */
+    private reindex(): void {
+        const keys = new Map<string, { tenantId: string; app?: string | undefined }>();
+        for (const tenant of this.storedTenants.values()) {
+            if (!tenant.disabled) {
+                for (const key of tenant.apiKeys) {
+                    keys.set(key.keyHash, { tenantId: tenant.id });
+                }
+            }
+        }
+        for (const tenant of this.configTenants.values()) {
+            for (const key of tenant.apiKeys ?? []) {
+                keys.set(key.keyHash, { tenantId: tenant.id, app: key.app });
+            }
+        }
+        this.keys = keys;
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements TenantStore.
+ */
+export function isTenantStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & TenantStore {
+    return storage !== undefined
+        && typeof storage.saveTenant === 'function'
+        && typeof storage.getTenant === 'function'
+        && typeof storage.listTenants === 'function';
+}
+
+/**
+ * Rejects a config tenant that is missing its ID, repeats one, has a key
+ * without a hash, or allows a provider that isn't configured.
+ */
+function checkConfigTenant(tenant: TenantConfig, seen: Map<string, TenantConfig>, providers: Set<string>): void {
+    if (!tenant.id) {
+        throw new Error(`Invalid config for tenant '${tenant.name ?? ''}': id is required`);
+    }
+    const invalid = (message: string) => new Error(`Invalid config for tenant '${tenant.id}': ${message}`);
+    if (seen.has(tenant.id)) {
+        throw invalid('another tenant has the same ID');
+    }
+    for (const [i, key] of (tenant.apiKeys ?? []).entries()) {
+        if (!key.keyHash) {
+            throw invalid(`apiKeys[${i}]: keyHash is required`);
+        }
+    }
+    const own = new Set((tenant.providers ?? []).map((p) => p.name));
+    for (const name of tenant.allowedProviders ?? []) {
+        if (!providers.has(name) && !own.has(name)) {
+            throw invalid(`allowedProviders: unknown provider '${name}'`);
+        }
+    }
+}
+
+function configInfo(tenant: TenantConfig): TenantInfo {
+    return {
+        id: tenant.id,
+        name: tenant.name,
+        source: 'config',
+        disabled: false,
+        apiKeys: tenant.apiKeys?.length ?? 0,
+        routing: tenant.routing,
+        allowedProviders: tenant.allowedProviders,
+    };
+}
+
+function storedInfo(tenant: StoredTenant): TenantInfo {
+    return {
+        id: tenant.id,
+        name: tenant.name,
+        source: 'store',
+        disabled: tenant.disabled,
+        apiKeys: tenant.apiKeys.length,
+        routing: tenant.routing,
+        createdAt: tenant.createdAt,
+        updatedAt: tenant.updatedAt,
+    };
+}
+
+function conflict(message: string): APIError {
+    return new APIError('invalid_request', message, { statusCode: 409 });
+}
@@ -0,0 +1,108 @@
+import { describe, it, expect } from 'vitest';
+import { throttleStream, maybeThrottle, type ThrottleClock } from './utils/throttle';
+import { openAIFrontdoor } from './frontdoors/index';
+
+/** Clock whose sleeps advance time instantly. */
+function fakeClock(): ThrottleClock & { time: number } {
+    const clock = {
+        time: 0,
+        now: () => clock.time,
+        sleep: async (ms: number) => {
+            clock.time += ms;
+        },
+    };
+    return clock;
+}
+
+/** Provider stream that produces `count` one-token deltas with no delay. */
+async function* instant(count: number, opts: { fail?: boolean; pulled?: { n: number } } = {}): AsyncGenerator<any, void, void> {
+    for (let i = 0; i < count; i++) {
+        if (opts.pulled) opts.pulled.n++;
+        yield { type: 'content_delta', contentDelta: 'abcd' };
+    }
+    if (opts.fail) throw new Error('upstream reset');
+    yield { type: 'done' };
+}
+
+describe('throttleStream', () => {
+    it('should deliver an instant 500-token response over the expected duration', async () => {
+        const clock = fakeClock();
+        let received = 0;
+
+        for await (const event of throttleStream(instant(500), { tokensPerSecond: 40, burst: 80 }, clock)) {
+            if (event.contentDelta) received++;
+        }
+
+        // 80 tokens go out immediately, the remaining 420 at 40/s
+        expect(received).toBe(500);
+        expect(clock.time).toBeCloseTo(10_500, -1);
+    });
+
+    it('should not delay the done event', async () => {
+        const clock = fakeClock();
+        const times: Array<[string, number]> = [];
+
+        for await (const event of throttleStream(instant(100), { tokensPerSecond: 10 }, clock)) {
+            times.push([event.type, clock.time]);
+        }
+
+        const [doneType, doneAt] = times[times.length - 1]!;
+        expect(doneType).toBe('done');
+        expect(doneAt).toBe(times[times.length - 2]![1]);
+    });
+
+    it('should surface errors without waiting', async () => {
+        const clock = fakeClock();
+        let lastContentAt = 0;
+        let error: any;
+
+        try {
+            for await (const event of throttleStream(instant(50, { fail: true }), { tokensPerSecond: 10 }, clock)) {
+                if (event.contentDelta) lastContentAt = clock.time;
+            }
+        } catch (e) {
+            error = e;
+        }
+
+        expect(error.message).toBe('upstream reset');
+        expect(clock.time).toBe(lastContentAt);
+    });
+
+    it('should apply backpressure instead of reading ahead', async () => {
+        const pulled = { n: 0 };
+        let yielded = 0;
+
+        for await (const event of throttleStream(instant(200, { pulled }), { tokensPerSecond: 20, burst: 5 }, fakeClock())) {
+            if (event.contentDelta) yielded++;
+            expect(pulled.n).toBe(yielded);
+        }
+    });
+
+    it('should pass the stream through untouched when unset', () => {
+        const source = instant(1);
+
+        expect(maybeThrottle(source, undefined)).toBe(source);
+    });
+});
+
+describe('frontdoor stream throttle', () => {
+    it('should note the effective throttle in interaction metadata', async () => {
+        const provider = { name: 'mock', apiType: 'openai', stream: () => instant(3) };
+        const result = await openAIFrontdoor.handle({
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'hi' }], stream: true }),
+            }),
+            provider,
+            app: { name: 'demo', frontdoor: 'openai', path: '/v1', streamThrottle: { tokensPerSecond: 1000, burst: 80 } },
+            auth: { tenantId: 't', scopes: [], metadata: {} },
+            interactionId: 'i',
+        } as any);
+
+        const text = await result.response.text();
+
+        expect(result.metadata).toEqual({ stream_throttle: '1000 tokens/s, burst 80' });
+        expect(text.match(/abcd/g)).toHaveLength(3);
+        expect(text).toContain('data: [DONE]');
+    });
+});
@@ -0,0 +1,281 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { createServer, type Server, type ServerResponse } from 'node:http';
+import type { AddressInfo } from 'node:net';
+import { OpenAIProvider, AnthropicProvider, DeadlineCancellations, withDeadline } from './providers/index';
+import { ResponsesHandler, StreamReplayBuffer } from './responses/index';
+import { openAIFrontdoor } from './frontdoors/index';
+
+type Mode = 'stall-headers' | 'stall-first-event' | 'stall-mid-stream' | 'respond';
+
+const openAIChunk = (content: string) => `data: ${JSON.stringify({
+    id: 'chatcmpl-1',
+    object: 'chat.completion.chunk',
+    created: 0,
+    model: 'gpt-4o',
+    choices: [{ index: 0, delta: { content }, finish_reason: null }],
+    usage: { prompt_tokens: 3, completion_tokens: 1, total_tokens: 4 },
+})}\n\n`;
+
+const anthropicDelta = (text: string) =>
+    `event: content_block_delta\ndata: ${JSON.stringify({
+        type: 'content_block_delta',
+        index: 0,
+        delta: { type: 'text_delta', text },
+    })}\n\n`;
+
+const chatCompletion = JSON.stringify({
+    id: 'chatcmpl-1',
+    object: 'chat.completion',
+    created: 0,
+    model: 'gpt-4o',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'Hello' }, finish_reason: 'stop' }],
+    usage: { prompt_tokens: 3, completion_tokens: 1, total_tokens: 4 },
+});
+
+/** Mock upstream that accepts requests and then stalls according to `mode`. */
+let mode: Mode = 'stall-headers';
+let server: Server;
+let baseUrl: string;
+const open = new Set<ServerResponse>();
+
+/** The last request the upstream received, and when its connection closed. */
+let received: { headers: Record<string, unknown>; body: any } | undefined;
+let closedAt = 0;
+
+beforeAll(async () => {
+    server = createServer((req, res) => {
+        open.add(res);
+        res.on('close', () => {
+            open.delete(res);
+            closedAt = Date.now();
+        });
+        let body = '';
+        req.on('data', (chunk) => { body += chunk; });
+        req.on('end', () => {
+            received = { headers: req.headers, body: JSON.parse(body || '{}') };
+            if (mode === 'respond') {
+                res.writeHead(200, { 'Content-Type': 'application/json' }).end(chatCompletion);
+            }
+        });
+        if (mode === 'stall-headers' || mode === 'respond') return;
+
+        res.writeHead(200, { 'Content-Type': 'text/event-stream' });
+        res.flushHeaders();
+        if (mode === 'stall-mid-stream') {
+            res.write(req.url?.includes('/messages') ? anthropicDelta('Hel') : openAIChunk('Hel'));
+        }
+    });
+    await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+    baseUrl = `http://127.0.0.1:${(server.address() as AddressInfo).port}`;
+});
+
+afterAll(async () => {
+    for (const res of open) res.destroy();
+    await new Promise((resolve) => server.close(resolve));
+});
+
+const request = { model: 'gpt-4o', messages: [{ role: 'user', content: 'hi' }], maxTokens: 10 } as any;
+
+function openai(requestTimeoutMs: number, streamIdleTimeoutMs = 0) {
+    return new OpenAIProvider({ name: 'openai', apiKey: 'k', baseUrl, requestTimeoutMs, streamIdleTimeoutMs });
+}
+
+async function drain(stream: AsyncGenerator<any>): Promise<{ events: any[]; error?: any }> {
+    const events: any[] = [];
+    try {
+        for await (const event of stream) events.push(event);
+        return { events };
+    } catch (error) {
+        return { events, error };
+    }
+}
+
+describe('provider upstream timeouts', () => {
+    it('bounds a non-streaming call by request_timeout', async () => {
+        mode = 'stall-headers';
+        const started = Date.now();
+
+        await expect(openai(50).complete(request)).rejects.toMatchObject({
+            code: 'request_timeout',
+            statusCode: 504,
+        });
+        expect(Date.now() - started).toBeLessThan(1000);
+    });
+
+    it('bounds the time to the first stream event by request_timeout', async () => {
+        mode = 'stall-first-event';
+
+        const { events, error } = await drain(openai(50, 5000).stream({ ...request, stream: true }));
+
+        expect(events).toHaveLength(0);
+        expect(error.code).toBe('request_timeout');
+    });
+
+    it('aborts a stream that goes idle mid-flight', async () => {
+        mode = 'stall-mid-stream';
+
+        const { events, error } = await drain(openai(5000, 50).stream({ ...request, stream: true }));
+
+        expect(events.map((e) => e.contentDelta)).toEqual(['Hel']);
+        expect(error.code).toBe('stream_idle_timeout');
+    });
+
+    it('applies the idle timeout to the Anthropic provider', async () => {
+        mode = 'stall-mid-stream';
+        const provider = new AnthropicProvider({ name: 'anthropic', apiKey: 'k', baseUrl, streamIdleTimeoutMs: 50 });
+
+        const { events, error } = await drain(provider.stream({ ...request, stream: true }));
+
+        expect(events.some((e) => e.contentDelta === 'Hel')).toBe(true);
+        expect(error.code).toBe('stream_idle_timeout');
+    });
+});
+
+describe('frontdoor handling of idle timeouts', () => {
+    it('ends a chat completions stream with an error event', async () => {
+        mode = 'stall-mid-stream';
+        const { response } = await openAIFrontdoor.handle({
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'hi' }], stream: true }),
+            }),
+            provider: openai(5000, 50),
+            auth: { tenantId: 'tenant-a', scopes: ['*'], metadata: {} },
+            interactionId: 'int-1',
+        } as any);
+
+        const text = await response.text();
+        const frames = text.trim().split('\n\n');
+        const last = JSON.parse(frames[frames.length - 1]!.replace(/^data: /, ''));
+
+        // The role chunk, the delta before the stall, and the error
+        expect(frames.length).toBe(3);
+        expect(JSON.parse(frames[0]!.replace(/^data: /, '')).choices[0].delta).toEqual({ role: 'assistant', content: '' });
+        expect(last.error.code).toBe('stream_idle_timeout');
+    });
+
+    it('records a failed Responses stream with partial content and usage', async () => {
+        mode = 'stall-mid-stream';
+        const saved = new Map<string, any>();
+        const storage = {
+            async saveResponse(record: any) {
+                saved.set(record.id, record);
+            },
+        } as any;
+        const handler = new ResponsesHandler({
+            storage,
+            provider: openai(5000, 50),
+            replay: new StreamReplayBuffer(),
+        });
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'gpt-4o', input: 'hi', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+        }
+        await new Promise((resolve) => setTimeout(resolve, 5));
+
+        expect(frames[frames.length - 1]).toContain('event: response.failed');
+        expect(frames[frames.length - 1]).toContain('stream_idle_timeout');
+
+        const [record] = saved.values();
+        expect(record.status).toBe('failed');
+        expect(record.response.choices[0].message.content).toBe('Hel');
+        expect(record.usage.totalTokens).toBe(4);
+    });
+});
+
+describe('request deadline propagation', () => {
+    /** Waits for the mock upstream to see every connection closed. */
+    async function upstreamClosed(): Promise<number> {
+        for (let i = 0; i < 100 && open.size > 0; i++) {
+            await new Promise((resolve) => setTimeout(resolve, 5));
+        }
+        expect(open.size).toBe(0);
+        return closedAt;
+    }
+
+    it('closes the upstream connection within 100ms of the gateway deadline', async () => {
+        mode = 'stall-headers';
+        const deadline = Date.now() + 100;
+
+        await expect(openai(0).complete(request, { deadline })).rejects.toMatchObject({
+            code: 'deadline_exceeded',
+            statusCode: 504,
+        });
+
+        expect(await upstreamClosed() - deadline).toBeLessThan(100);
+    });
+
+    it('cuts off a stream mid-flight at the deadline', async () => {
+        mode = 'stall-mid-stream';
+        const deadline = Date.now() + 100;
+
+        const { events, error } = await drain(openai(0).stream({ ...request, stream: true }, { deadline }));
+
+        expect(events.map((e) => e.contentDelta)).toEqual(['Hel']);
+        expect(error.code).toBe('deadline_exceeded');
+        expect(await upstreamClosed() - deadline).toBeLessThan(100);
+    });
+
+    it('takes the deadline over a longer request_timeout', async () => {
+        mode = 'stall-headers';
+        const provider = new AnthropicProvider({ name: 'anthropic', apiKey: 'k', baseUrl, requestTimeoutMs: 5000 });
+        const deadline = Date.now() + 50;
+
+        await expect(provider.complete(request, { deadline })).rejects.toMatchObject({ code: 'deadline_exceeded' });
+        expect(await upstreamClosed() - deadline).toBeLessThan(100);
+    });
+
+    it('tears down the upstream call when the client request is aborted', async () => {
+        mode = 'stall-headers';
+        const controller = new AbortController();
+        const pending = openai(0).complete(request, { signal: controller.signal });
+        await new Promise((resolve) => setTimeout(resolve, 20));
+
+        const abortedAt = Date.now();
+        controller.abort();
+
+        await expect(pending).rejects.toBeDefined();
+        expect(await upstreamClosed() - abortedAt).toBeLessThan(100);
+    });
+
+    it('sends the time left as X-Request-Timeout-Ms', async () => {
+        mode = 'respond';
+
+        await openai(0).complete(request, { deadline: Date.now() + 2000 });
+
+        const timeoutMs = Number(received?.headers['x-request-timeout-ms']);
+        expect(timeoutMs).toBeGreaterThan(1500);
+        expect(timeoutMs).toBeLessThanOrEqual(2000);
+    });
+
+    it('clamps max tokens to what fits before the deadline when configured', async () => {
+        mode = 'respond';
+        const provider = new OpenAIProvider({
+            name: 'openai', apiKey: 'k', baseUrl, deadlineHeader: false, deadlineTokensPerSecond: 10,
+        });
+
+        await provider.complete({ ...request, maxTokens: 1000 }, { deadline: Date.now() + 2000 });
+        expect(received?.body.max_completion_tokens).toBeGreaterThan(10);
+        expect(received?.body.max_completion_tokens).toBeLessThanOrEqual(20);
+        expect(received?.headers['x-request-timeout-ms']).toBeUndefined();
+
+        await provider.complete({ ...request, maxTokens: 5 }, { deadline: Date.now() + 2000 });
+        expect(received?.body.max_completion_tokens).toBe(5);
+    });
+
+    it('counts provider calls cancelled by the deadline', async () => {
+        const cancellations = new DeadlineCancellations();
+
+        mode = 'stall-headers';
+        const expiring = withDeadline(openai(0), { deadline: Date.now() + 50 }, cancellations);
+        await expect(expiring.complete(request)).rejects.toMatchObject({ code: 'deadline_exceeded' });
+
+        mode = 'stall-first-event';
+        const timedOut = withDeadline(openai(50), { deadline: Date.now() + 5000 }, cancellations);
+        await drain(timedOut.stream({ ...request, stream: true }));
+
+        expect(cancellations.stats()).toEqual([{ provider: 'openai', cancelled: 1 }]);
+        await upstreamClosed();
+    });
+});
@@ -0,0 +1,140 @@
+import { describe, it, expect } from 'vitest';
+import { TimingRecorder, timeStream, LatencyStats, percentile } from './utils/timings';
+import { openAIFrontdoor } from './frontdoors/index';
+
+/** Manually advanced clock. */
+function fakeClock() {
+    const clock = { time: 1000, now: () => clock.time };
+    return clock;
+}
+
+/** Stream that advances the clock before each event. */
+async function* timed(clock: { time: number }, steps: Array<[number, any]>): AsyncGenerator<any, void, void> {
+    for (const [ms, event] of steps) {
+        clock.time += ms;
+        yield event;
+    }
+}
+
+describe('TimingRecorder', () => {
+    it('should finish on settle with the end-to-end total', () => {
+        const clock = fakeClock();
+        let finished: any;
+        const timings = new TimingRecorder({ startedAt: 900, clock: clock.now, onFinish: (t) => { finished = t; } });
+
+        clock.time = 1005;
+        timings.record('authMs', 900);
+        timings.settle();
+
+        expect(finished).toEqual({ authMs: 105, totalMs: 105 });
+    });
+
+    it('should record a phase even when it throws', async () => {
+        const clock = fakeClock();
+        const timings = new TimingRecorder({ clock: clock.now });
+
+        await expect(timings.time('prePipelineMs', async () => {
+            clock.time += 40;
+            throw new Error('webhook down');
+        })).rejects.toThrow('webhook down');
+
+        expect(timings.timings.prePipelineMs).toBe(40);
+    });
+});
+
+describe('timeStream', () => {
+    it('should measure TTFB at the first event with content and hold the recorder open', async () => {
+        const clock = fakeClock();
+        const finished: any[] = [];
+        const timings = new TimingRecorder({ clock: clock.now, onFinish: (t) => finished.push({ ...t }) });
+
+        const stream = timeStream(timed(clock, [
+            [50, { type: 'content_delta', role: 'assistant' }],
+            [70, { type: 'content_delta', contentDelta: 'Hel' }],
+            [30, { type: 'content_delta', contentDelta: 'lo' }],
+            [10, { type: 'done' }],
+        ]), timings);
+
+        // The handler returns before the stream is consumed
+        timings.settle();
+        expect(finished).toHaveLength(0);
+
+        for await (const _ of stream) { /* drain */ }
+
+        expect(finished).toEqual([{ providerTtfbMs: 120, firstTokenMs: 120, providerTotalMs: 160, totalMs: 160 }]);
+    });
+
+    it('should record the provider total when the consumer stops early', async () => {
+        const clock = fakeClock();
+        const timings = new TimingRecorder({ clock: clock.now });
+
+        for await (const _ of timeStream(timed(clock, [
+            [20, { type: 'content_delta', contentDelta: 'a' }],
+            [20, { type: 'content_delta', contentDelta: 'b' }],
+        ]), timings)) {
+            break;
+        }
+
+        expect(timings.timings).toMatchObject({ providerTtfbMs: 20, providerTotalMs: 20 });
+    });
+});
+
+describe('LatencyStats', () => {
+    it('should compute nearest-rank percentiles per provider and model', () => {
+        const stats = new LatencyStats();
+        for (let i = 1; i <= 100; i++) {
+            stats.record('openai', 'gpt-4o', { providerTotalMs: i, totalMs: i + 5 });
+        }
+        stats.record('anthropic', 'claude-sonnet-4', { providerTtfbMs: 300, totalMs: 900 });
+
+        const [anthropic, openai] = stats.summary();
+
+        expect(anthropic).toMatchObject({ provider: 'anthropic', count: 1 });
+        expect(anthropic!.phases.providerTtfbMs).toEqual({ p50: 300, p95: 300, p99: 300 });
+        expect(openai!.phases.providerTotalMs).toEqual({ p50: 50, p95: 95, p99: 99 });
+        expect(openai!.phases.totalMs).toEqual({ p50: 55, p95: 100, p99: 104 });
+        expect(openai!.phases.prePipelineMs).toBeUndefined();
+    });
+
+    it('should keep only the most recent window of samples', () => {
+        const stats = new LatencyStats(2);
+        stats.record('p', 'm', { totalMs: 1000 });
+        stats.record('p', 'm', { totalMs: 10 });
+        stats.record('p', 'm', { totalMs: 20 });
+
+        expect(stats.summary()[0]).toMatchObject({ count: 2, phases: { totalMs: { p50: 10, p99: 20 } } });
+    });
+
+    it('should handle single-element percentiles', () => {
+        expect(percentile([7], 1)).toBe(7);
+        expect(percentile([7], 99)).toBe(7);
+    });
+});
+
+describe('frontdoor timings', () => {
+    it('should time the provider call and encoding for non-streaming requests', async () => {
+        const timings = new TimingRecorder();
+        await openAIFrontdoor.handle({
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: 'hi' }] }),
+            }),
+            provider: {
+                name: 'mock',
+                apiType: 'openai',
+                complete: async () => ({
+                    id: 'r', object: 'chat.completion', created: 0, model: 'gpt-4o', sourceAPIType: 'openai',
+                    usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+                    choices: [{ index: 0, finishReason: 'stop', message: { role: 'assistant', content: 'hello' } }],
+                }),
+            },
+            auth: { tenantId: 't', scopes: [], metadata: {} },
+            interactionId: 'i',
+            timings,
+        } as any);
+
+        expect(timings.timings.providerTotalMs).toBeGreaterThanOrEqual(0);
+        expect(timings.timings.encodeMs).toBeGreaterThanOrEqual(0);
+        expect(timings.timings.providerTtfbMs).toBeUndefined();
+    });
+});
@@ -0,0 +1,115 @@
+/**
+ * Exact byte-pair encoding, compatible with tiktoken.
+ *
+ * Loads a tiktoken rank file (one "<base64 token> <rank>" line per token,
+ * as in o200k_base.tiktoken) and counts tokens the way tiktoken encodes
+ * ordinary text: split with the family's pattern, then merge each piece's
+ * UTF-8 bytes by lowest pair rank. Rank files are large, so they are read
+ * by the runtime only when configured.
+ *
+ * @module tokens/bpe
+ */
+
+import type { Tokenizer } from './tokenizer.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/**
+ * Longest piece merged as a whole. Merging is quadratic in piece length,
+ * so longer pieces (e.g. an unbroken base64 blob) are merged in chunks of
+ * this size, which can overcount by a token per chunk.
+ */
+export const MAX_PIECE_BYTES = 4096;
+
+// ============================================================================
+// Rank Files
+// ============================================================================
+
+/**
+ * BPE ranks keyed by token bytes, one char per byte (0-255).
+ */
+export type BpeRanks = Map<string, number>;
+
+/**
+ * Parses a tiktoken rank file. Throws on malformed lines.
+ */
+export function parseTiktokenRanks(text: string): BpeRanks {
+    const ranks: BpeRanks = new Map();
+    for (const [index, line] of text.split('\n').entries()) {
+        if (line.trim() === '') continue;
+        const [token, rank] = line.trim().split(/\s+/);
+        const value = Number(rank);
+        if (!token || !Number.isInteger(value)) {
+            throw new Error(`Malformed rank file line ${index + 1}`);
+        }
+        ranks.set(atob(token), value);
+    }
+    return ranks;
+}
+
+// ============================================================================
+// Tokenizer
+// ============================================================================
+
+/**
+ * Exact tokenizer over a BPE rank table.
+ */
+export class BpeTokenizer implements Tokenizer {
+    readonly exact = true;
+    private readonly ranks: BpeRanks;
+    private readonly pattern: RegExp;
+    private readonly encoder = new TextEncoder();
+
+    constructor(ranks: BpeRanks, pattern: RegExp) {
+        this.ranks = ranks;
+        this.pattern = pattern;
+    }
+
+    count(text: string): number {
+        let tokens = 0;
+        for (const [piece] of text.matchAll(this.pattern)) {
+            const bytes = byteString(this.encoder.encode(piece));
+            if (this.ranks.has(bytes)) {
+                tokens++;
+                continue;
+            }
+            for (let start = 0; start < bytes.length; start += MAX_PIECE_BYTES) {
+                tokens += this.mergedLength(bytes.slice(start, start + MAX_PIECE_BYTES));
+            }
+        }
+        return tokens;
+    }
+
+    /**
+     * Merges a piece's bytes, lowest-ranked adjacent pair first, until no
+     * pair is in the table, and returns the number of tokens left.
+     */
+    private mergedLength(bytes: string): number {
+        const bounds = Array.from({ length: bytes.length + 1 }, (_, i) => i);
+        for (;;) {
+            let best = Infinity;
+            let at = -1;
+            for (let i = 0; i < bounds.length - 2; i++) {
+                const rank = this.ranks.get(bytes.slice(bounds[i], bounds[i + 2]));
+                if (rank !== undefined && rank < best) {
+                    best = rank;
+                    at = i;
+                }
+            }
+            if (at < 0) {
+                return bounds.length - 1;
+            }
+            bounds.splice(at + 1, 1);
+        }
+    }
+}
+
+function byteString(bytes: Uint8Array): string {
+    let text = '';
+    for (const byte of bytes) {
+        text += String.fromCharCode(byte);
+    }
+    return text;
+}
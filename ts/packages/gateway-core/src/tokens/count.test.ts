@@ -0,0 +1,178 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import {
+    ApproximateTokenizer,
+    BpeTokenizer,
+    CL100K_PATTERN,
+    TokenCounter,
+    parseTiktokenRanks,
+    tokenizerFamily,
+    type TokenizerFamily,
+} from './index';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/** Counts recorded from tiktoken's cl100k_base and o200k_base encodings. */
+const RECORDED: [TokenizerFamily, string, number][] = [
+    ['cl100k', 'hello world', 2],
+    ['cl100k', 'tiktoken is great!', 6],
+    ['cl100k', 'antidisestablishmentarianism', 6],
+    ['cl100k', '2 + 2 = 4', 7],
+    ['cl100k', 'お誕生日おめでとう', 9],
+    ['o200k', 'hello world', 2],
+    ['o200k', '2 + 2 = 4', 7],
+];
+
+/** A tiktoken rank file: single bytes, then merges in rank order. */
+function rankFile(tokens: string[]): string {
+    return tokens.map((token, rank) => `${btoa(token)} ${rank}`).join('\n');
+}
+
+const TINY_RANKS = rankFile(['a', 'b', 'c', ' ', 'bc', 'ab', 'ca']);
+
+describe('Tokenizers', () => {
+    it('should estimate within a tolerance band of recorded exact counts', () => {
+        for (const [family, text, exact] of RECORDED) {
+            const estimate = new ApproximateTokenizer(family).count(text);
+            const tolerance = Math.max(2, Math.ceil(exact * 0.25));
+            expect(Math.abs(estimate - exact), `${family} "${text}"`).toBeLessThanOrEqual(tolerance);
+        }
+    });
+
+    it('should pick the tokenizer family by model', () => {
+        expect(tokenizerFamily('gpt-4o-mini')).toBe('o200k');
+        expect(tokenizerFamily('o3-mini')).toBe('o200k');
+        expect(tokenizerFamily('gpt-4-turbo')).toBe('cl100k');
+        expect(tokenizerFamily('gpt-3.5-turbo')).toBe('cl100k');
+        expect(tokenizerFamily('claude-sonnet-4')).toBe('claude');
+        expect(tokenizerFamily('my-proxy-model', 'anthropic')).toBe('claude');
+    });
+
+    it('should merge byte pairs by lowest rank', () => {
+        const tokenizer = new BpeTokenizer(parseTiktokenRanks(TINY_RANKS), CL100K_PATTERN);
+
+        // "abca" merges "bc" (rank 4) first; merging "ab" (rank 5) first
+        // would have left "ab" + "ca"
+        expect(tokenizer.count('bc')).toBe(1);
+        expect(tokenizer.count(' ab')).toBe(2);
+        expect(tokenizer.count('abca')).toBe(3);
+        expect(tokenizer.exact).toBe(true);
+    });
+
+    it('should reject malformed rank files', () => {
+        expect(() => parseTiktokenRanks('YQ== 0\nYg==')).toThrow('Malformed rank file line 2');
+    });
+
+    it('should add message and tool overhead to request counts', () => {
+        const counter = new TokenCounter();
+        const plain = counter.countRequest({
+            tenantId: 'acme', model: 'gpt-4o', messages: [{ role: 'user', content: 'hello world' }],
+        });
+        const withTool = counter.countRequest({
+            tenantId: 'acme',
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'hello world' }],
+            tools: [{ type: 'function', function: { name: 'lookup', parameters: { type: 'object' } } }],
+        });
+
+        expect(plain).toEqual({ family: 'o200k', inputTokens: 9, exact: false });
+        expect(withTool.inputTokens).toBeGreaterThan(plain.inputTokens + 8);
+    });
+});
+
+describe('Token count endpoint', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(options: { encodings?: Record<string, string>; loader?: (path: string) => string | undefined } = {}) {
+        const provider = new ScriptedProvider('mock', { text: 'Hi' });
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/openai' })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic', defaultModel: 'claude-sonnet-4' })
+            .provider(provider)
+            .config({ tokenCount: options.encodings && { encodings: options.encodings } })
+            .options({ encodingLoader: options.loader })
+            .start();
+        const gw = gateway;
+        const count = (path: string, body: Record<string, unknown>, method = 'POST') => method === 'POST'
+            ? gw.post(path, body)
+            : gw.request(path, { method });
+        return { provider, count };
+    }
+
+    it('should count an OpenAI-style body against the model context window', async () => {
+        const { provider, count } = await setup();
+
+        const response = await count('/openai/v1/token_count', {
+            model: 'gpt-4o',
+            max_tokens: 1000,
+            messages: [{ role: 'user', content: 'hello world' }],
+        });
+
+        expect(response.status).toBe(200);
+        expect(await response.json()).toEqual({
+            object: 'token_count',
+            model: 'gpt-4o',
+            input_tokens: 9,
+            exact: false,
+            tokenizer: 'o200k',
+            context_window: 128000,
+            max_output_tokens: 16384,
+            max_tokens: 1000,
+            fits: true,
+        });
+        expect(provider.requests).toHaveLength(0);
+    });
+
+    it('should count an Anthropic-style body with the app default model', async () => {
+        const { count } = await setup();
+
+        const body = await (await count('/anthropic/v1/token_count', {
+            max_tokens: 100000,
+            system: 'You are terse.',
+            messages: [{ role: 'user', content: [{ type: 'text', text: 'hello world' }] }],
+        })).json();
+
+        expect(body).toMatchObject({ model: 'claude-sonnet-4', tokenizer: 'claude', exact: false, context_window: 200000 });
+        expect(body.input_tokens).toBeGreaterThan(9);
+        expect(body.fits).toBe(false);
+    });
+
+    it('should leave the verdict open for uncataloged models', async () => {
+        const { count } = await setup();
+
+        const body = await (await count('/openai/v1/token_count', {
+            model: 'local-llama',
+            messages: [{ role: 'user', content: 'hi' }],
+        })).json();
+
+        expect(body).toMatchObject({ context_window: null, max_tokens: null, fits: null });
+    });
+
+    it('should count exactly with a configured encoding', async () => {
+        const loader = vi.fn(() => TINY_RANKS);
+        const { count } = await setup({ encodings: { o200k: '/data/o200k_base.tiktoken' }, loader });
+
+        const exact = await (await count('/openai/v1/token_count', {
+            model: 'gpt-4o',
+            messages: [{ role: 'user', content: 'abc' }],
+        })).json();
+        const estimated = await (await count('/openai/v1/token_count', {
+            model: 'gpt-4-turbo',
+            messages: [{ role: 'user', content: 'abc' }],
+        })).json();
+
+        expect(loader).toHaveBeenCalledWith('/data/o200k_base.tiktoken');
+        expect(exact).toMatchObject({ tokenizer: 'o200k', exact: true });
+        expect(estimated).toMatchObject({ tokenizer: 'cl100k', exact: false });
+    });
+
+    it('should reject other methods and missing models', async () => {
+        const { count } = await setup();
+
+        expect((await count('/openai/v1/token_count', {}, 'GET')).status).toBe(405);
+        expect((await count('/openai/v1/token_count', { messages: [] })).status).toBe(400);
+    });
+});
@@ -0,0 +1,204 @@
+/**
+ * Request token counts.
+ *
+ * Serves POST /v1/token_count under every app's path (any path in an app
+ * ending in /token_count). Clients send the body they would send to the
+ * app (OpenAI-style, or Anthropic-style on anthropic apps) and get the
+ * estimated input tokens, the model's context window from the
+ * catalog, and whether the input plus max_tokens fits. Nothing is sent to
+ * a provider. Counts are exact only when the model family has an exact
+ * tokenizer and the request has no images or tools, whose provider-side
+ * formatting is undocumented.
+ *
+ * @module tokens/count
+ */
+
+import type { APIType, CanonicalRequest, Message } from '../domain/types.js';
+import type { ModelInfo } from '../domain/catalog.js';
+import {
+    ApproximateTokenizer,
+    tokenizerFamily,
+    type Tokenizer,
+    type TokenizerFamily,
+} from './tokenizer.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Token count path suffix, under each app's base URL (e.g. /v1/token_count). */
+export const TOKEN_COUNT_SUFFIX = '/token_count';
+
+/** Tokens each message adds for its role and separators. */
+export const MESSAGE_OVERHEAD_TOKENS = 3;
+
+/** Tokens that prime the assistant's reply. */
+export const REPLY_PRIMING_TOKENS = 3;
+
+/** Tokens a tool definition adds beyond its JSON. */
+export const TOOL_OVERHEAD_TOKENS = 8;
+
+/** Estimated tokens per image input (a high-detail OpenAI image; Claude's scale with size). */
+const IMAGE_TOKENS: Record<TokenizerFamily, number> = { o200k: 765, cl100k: 765, claude: 1600 };
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A request's input token count.
+ */
+export interface TokenCount {
+    /** Tokenizer family used. */
+    family: TokenizerFamily;
+
+    /** Input tokens. */
+    inputTokens: number;
+
+    /** Whether the count is exact rather than estimated. */
+    exact: boolean;
+}
+
+/**
+ * Token count response body.
+ */
+export interface TokenCountResult {
+    object: 'token_count';
+    model: string;
+    input_tokens: number;
+    exact: boolean;
+    tokenizer: TokenizerFamily;
+    context_window: number | null;
+    max_output_tokens: number | null;
+    max_tokens: number | null;
+    fits: boolean | null;
+}
+
+// ============================================================================
+// Counter
+// ============================================================================
+
+/**
+ * Counts request tokens with each family's exact tokenizer when one is
+ * given, and the approximation otherwise.
+ */
+export class TokenCounter {
+    private readonly tokenizers = new Map<TokenizerFamily, Tokenizer>();
+
+    constructor(exact: Partial<Record<TokenizerFamily, Tokenizer>> = {}) {
+        for (const family of ['o200k', 'cl100k', 'claude'] as const) {
+            this.tokenizers.set(family, exact[family] ?? new ApproximateTokenizer(family));
+        }
+    }
+
+    /**
+     * Counts a request's input tokens: system prompt, messages, and tools.
+     */
+    countRequest(request: CanonicalRequest, apiType?: APIType | undefined): TokenCount {
+        const family = tokenizerFamily(request.model, apiType);
+        const tokenizer = this.tokenizers.get(family)!;
+        let tokens = REPLY_PRIMING_TOKENS;
+        let images = 0;
+
+        const system = request.instructions ?? request.systemPrompt;
+        if (system) {
+            tokens += MESSAGE_OVERHEAD_TOKENS + tokenizer.count(system);
+        }
+        for (const message of request.messages) {
+            tokens += MESSAGE_OVERHEAD_TOKENS + tokenizer.count(message.role);
+            if (message.name) {
+                tokens += 1 + tokenizer.count(message.name);
+            }
+            const { text, imageCount } = messageText(message);
+            tokens += tokenizer.count(text);
+            images += imageCount;
+        }
+        tokens += images * IMAGE_TOKENS[family];
+        for (const tool of request.tools ?? []) {
+            const { name, description, parameters } = tool.function;
+            tokens += TOOL_OVERHEAD_TOKENS + tokenizer.count(JSON.stringify({ name, description, parameters }));
+        }
+
+        return {
+            family,
+            inputTokens: tokens,
+            exact: tokenizer.exact && images === 0 && (request.tools?.length ?? 0) === 0,
+        };
+    }
+
+    /**
+     * Counts the tokens in a text as a model's tokenizer would.
+     */
+    countText(text: string, model: string, apiType?: APIType | undefined): number {
+        return this.tokenizers.get(tokenizerFamily(model, apiType))!.count(text);
+    }
+}
+
+/**
+ * Shapes a count as a response, with the model's limits and whether the
+ * input plus max_tokens fits them. fits is null for uncataloged models.
+ */
+export function tokenCountResult(model: string, count: TokenCount, info: ModelInfo | undefined, maxTokens: number | undefined): TokenCountResult {
+    const contextWindow = info?.contextWindow;
+    const maxOutput = info?.maxOutputTokens;
+    let fits: boolean | null = null;
+    if (contextWindow !== undefined) {
+        fits = count.inputTokens + (maxTokens ?? 0) <= contextWindow
+            && (maxTokens === undefined || maxOutput === undefined || maxTokens <= maxOutput);
+    }
+    return {
+        object: 'token_count',
+        model,
+        input_tokens: count.inputTokens,
+        exact: count.exact,
+        tokenizer: count.family,
+        context_window: contextWindow ?? null,
+        max_output_tokens: maxOutput ?? null,
+        max_tokens: maxTokens ?? null,
+        fits,
+    };
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Concatenates the text a message carries (content, tool calls and
+ * results, reasoning) and counts its images.
+ */
+function messageText(message: Message): { text: string; imageCount: number } {
+    const texts: string[] = [];
+    let imageCount = 0;
+    const parts = message.richContent?.parts;
+    if (parts) {
+        for (const part of parts) {
+            switch (part.type) {
+                case 'text':
+                    texts.push(part.text ?? '');
+                    break;
+                case 'image':
+                case 'image_url':
+                    imageCount++;
+                    break;
+                case 'tool_use':
+                    texts.push(part.name ?? '', JSON.stringify(part.input ?? {}));
+                    break;
+                case 'tool_result':
+                    texts.push(part.resultContent ?? '');
+                    break;
+                case 'thinking':
+                    texts.push(part.thinking ?? '');
+                    break;
+                case 'redacted_thinking':
+                    break;
+            }
+        }
+    } else {
+        texts.push(message.richContent?.text ?? message.content);
+    }
+    for (const call of message.toolCalls ?? []) {
+        texts.push(call.function.name, call.function.arguments);
+    }
+    return { text: texts.join('\n'), imageCount };
+}
@@ -0,0 +1,34 @@
+/**
+ * Token counting exports.
+ *
+ * @module tokens
+ */
+
+export {
+    ApproximateTokenizer,
+    tokenizerFamily,
+    familyPattern,
+    CL100K_PATTERN,
+    O200K_PATTERN,
+    EXACT_TOKENIZER_FAMILIES,
+    type Tokenizer,
+    type TokenizerFamily,
+} from './tokenizer.js';
+
+export {
+    BpeTokenizer,
+    parseTiktokenRanks,
+    MAX_PIECE_BYTES,
+    type BpeRanks,
+} from './bpe.js';
+
+export {
+    TokenCounter,
+    tokenCountResult,
+    TOKEN_COUNT_SUFFIX,
+    MESSAGE_OVERHEAD_TOKENS,
+    REPLY_PRIMING_TOKENS,
+    TOOL_OVERHEAD_TOKENS,
+    type TokenCount,
+    type TokenCountResult,
+} from './count.js';
@@ -0,0 +1,175 @@
+/**
+ * Tokenizers for request size estimates.
+ *
+ * Each model family has its own vocabulary, so counts are made with the
+ * family's tokenizer. Exact tokenizers are optional (they need the
+ * family's BPE rank file); without one, the built-in approximation splits
+ * text the way the family's tokenizer pre-splits it and estimates the
+ * tokens in each piece.
+ *
+ * @module tokens/tokenizer
+ */
+
+import type { APIType } from '../domain/types.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Tokenizer vocabularies. o200k covers GPT-4o and later OpenAI models,
+ * cl100k GPT-4 and GPT-3.5, and claude Anthropic models (approximation only).
+ */
+export type TokenizerFamily = 'o200k' | 'cl100k' | 'claude';
+
+/** Families that can be given an exact tiktoken-compatible tokenizer. */
+export const EXACT_TOKENIZER_FAMILIES: readonly TokenizerFamily[] = ['o200k', 'cl100k'];
+
+/**
+ * Counts the tokens in a text.
+ */
+export interface Tokenizer {
+    /** Whether counts are exact rather than estimated. */
+    readonly exact: boolean;
+
+    /** Counts the tokens in a text. */
+    count(text: string): number;
+}
+
+// ============================================================================
+// Pre-tokenization
+// ============================================================================
+
+/** Contraction suffixes, case-insensitive. */
+const CONTRACTION = "'(?:[sS]|[tT]|[rR][eE]|[vV][eE]|[mM]|[lL][lL]|[dD])";
+
+/** cl100k_base pre-tokenizer pattern. */
+export const CL100K_PATTERN = new RegExp(
+    `${CONTRACTION}|[^\\r\\n\\p{L}\\p{N}]?\\p{L}+|\\p{N}{1,3}| ?[^\\s\\p{L}\\p{N}]+[\\r\\n]*|\\s*[\\r\\n]+|\\s+(?!\\S)|\\s+`,
+    'gu',
+);
+
+/** o200k_base pre-tokenizer pattern. */
+export const O200K_PATTERN = new RegExp(
+    [
+        `[^\\r\\n\\p{L}\\p{N}]?[\\p{Lu}\\p{Lt}\\p{Lm}\\p{Lo}\\p{M}]*[\\p{Ll}\\p{Lm}\\p{Lo}\\p{M}]+(?:${CONTRACTION})?`,
+        `[^\\r\\n\\p{L}\\p{N}]?[\\p{Lu}\\p{Lt}\\p{Lm}\\p{Lo}\\p{M}]+[\\p{Ll}\\p{Lm}\\p{Lo}\\p{M}]*(?:${CONTRACTION})?`,
+        '\\p{N}{1,3}',
+        ' ?[^\\s\\p{L}\\p{N}]+[\\r\\n/]*',
+        '\\s*[\\r\\n]+',
+        '\\s+(?!\\S)',
+        '\\s+',
+    ].join('|'),
+    'gu',
+);
+
+/**
+ * Returns the pre-tokenizer pattern of a family. Claude's tokenizer is
+ * not public; cl100k's splits are the closer match.
+ */
+export function familyPattern(family: TokenizerFamily): RegExp {
+    return family === 'o200k' ? O200K_PATTERN : CL100K_PATTERN;
+}
+
+// ============================================================================
+// Approximation
+// ============================================================================
+
+/**
+ * Per-family estimator tuning.
+ */
+interface EstimatorProfile {
+    /** Longest ASCII word (leading space excluded) assumed to be one token. */
+    wordChars: number;
+
+    /** Characters per token in longer ASCII words. */
+    charsPerToken: number;
+
+    /** Tokens per CJK character. */
+    cjkTokens: number;
+
+    /** Characters per token in other non-ASCII text. */
+    otherCharsPerToken: number;
+}
+
+const PROFILES: Record<TokenizerFamily, EstimatorProfile> = {
+    o200k: { wordChars: 7, charsPerToken: 4.2, cjkTokens: 0.8, otherCharsPerToken: 3 },
+    cl100k: { wordChars: 6, charsPerToken: 4, cjkTokens: 1, otherCharsPerToken: 2.5 },
+    claude: { wordChars: 6, charsPerToken: 3.5, cjkTokens: 1.2, otherCharsPerToken: 2.2 },
+};
+
+const ASCII = /^[\x00-\x7f]*$/;
+const ASCII_WORD = /^[A-Za-z]/;
+const DIGITS = /^[0-9]/;
+const CJK = /[\p{Script=Han}\p{Script=Hiragana}\p{Script=Katakana}\p{Script=Hangul}]/u;
+
+/**
+ * Estimates counts from the family's pre-tokenizer splits: short words,
+ * number groups, and whitespace runs are one token each; longer words,
+ * punctuation runs, and non-ASCII text are estimated by length.
+ */
+export class ApproximateTokenizer implements Tokenizer {
+    readonly exact = false;
+    private readonly pattern: RegExp;
+    private readonly profile: EstimatorProfile;
+
+    constructor(family: TokenizerFamily) {
+        this.pattern = familyPattern(family);
+        this.profile = PROFILES[family];
+    }
+
+    count(text: string): number {
+        let tokens = 0;
+        for (const [piece] of text.matchAll(this.pattern)) {
+            tokens += this.estimatePiece(piece);
+        }
+        return tokens;
+    }
+
+    private estimatePiece(piece: string): number {
+        const { wordChars, charsPerToken, cjkTokens, otherCharsPerToken } = this.profile;
+        const core = piece.trimStart();
+        if (core.length === 0 || DIGITS.test(core)) {
+            return 1;
+        }
+        if (ASCII.test(core)) {
+            if (ASCII_WORD.test(core)) {
+                return core.length <= wordChars ? 1 : Math.ceil(core.length / charsPerToken);
+            }
+            return Math.ceil(core.length / 3);
+        }
+
+        let cjk = 0;
+        let other = 0;
+        for (const char of core) {
+            if (CJK.test(char)) {
+                cjk++;
+            } else {
+                other++;
+            }
+        }
+        return Math.max(1, Math.ceil(cjk * cjkTokens + other / otherCharsPerToken));
+    }
+}
+
+// ============================================================================
+// Family Selection
+// ============================================================================
+
+/**
+ * Picks the tokenizer family for a model, from its name and, failing
+ * that, the API family that serves it.
+ */
+export function tokenizerFamily(model: string, apiType?: APIType | undefined): TokenizerFamily {
+    const id = model.toLowerCase();
+    if (id.startsWith('claude') || apiType === 'anthropic') {
+        return 'claude';
+    }
+    if (/^(gpt-4o|chatgpt-4o|gpt-4\.1|gpt-4\.5|gpt-5|o[1-9])/.test(id)) {
+        return 'o200k';
+    }
+    if (/^(gpt-4|gpt-3\.5|text-embedding)/.test(id)) {
+        return 'cl100k';
+    }
+    return 'o200k';
+}
@@ -0,0 +1,124 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { ToolArgumentParser, type ToolArgumentField } from './toolargs/index';
+import type { CanonicalEvent } from './domain/types';
+import { collapse, harness, parseSSE, scenario, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+/** Arguments with escapes, \u escapes, a surrogate pair, nesting, and every scalar kind. */
+const ARGS = '{"query": "say \\"hi\\" \\\\ caf\\u00e9 😀", "limit": 10, "filters": {"lang": "en", "tags": ["a", "}"]}, "exact": false , "cursor": null}';
+
+/** Feeds text in the given pieces, collecting every field reported. */
+function parse(pieces: string[]): { fields: ToolArgumentField[]; parser: ToolArgumentParser } {
+    const parser = new ToolArgumentParser();
+    return { fields: pieces.flatMap((piece) => parser.push(piece)), parser };
+}
+
+describe('Tool argument parser', () => {
+    it('should report each top-level field once, however the text is split', () => {
+        const expected = Object.entries(JSON.parse(ARGS)).map(([path, value]) => ({ path, value }));
+
+        // Every split into three pieces: through escapes, \u sequences,
+        // and the emoji's surrogate pair
+        for (let i = 0; i <= ARGS.length; i++) {
+            for (let j = i; j <= ARGS.length; j++) {
+                const { fields, parser } = parse([ARGS.slice(0, i), ARGS.slice(i, j), ARGS.slice(j)]);
+                expect(fields).toEqual(expected);
+                expect(parser.failed).toBe(false);
+            }
+        }
+        expect(parse(ARGS.split('')).fields).toEqual(expected);
+    });
+
+    it('should report a field as soon as its value closes', () => {
+        const parser = new ToolArgumentParser();
+
+        expect(parser.push('{"query": "cats", "limit": 1')).toEqual([{ path: 'query', value: 'cats' }]);
+        expect(parser.push('0')).toEqual([]);
+        expect(parser.push(', "nested": {"a": {"b": 1}')).toEqual([{ path: 'limit', value: 10 }]);
+        expect(parser.push('}')).toEqual([{ path: 'nested', value: { a: { b: 1 } } }]);
+    });
+
+    it('should stop reporting at malformed JSON without throwing', () => {
+        const broken = parse(['{"a": 1, "b": tru', 'x, "c": 2}']);
+        expect(broken.fields).toEqual([{ path: 'a', value: 1 }]);
+        expect(broken.parser.failed).toBe(true);
+
+        expect(parse(['[1, 2]']).fields).toEqual([]);
+        expect(parse(['{"a" 1}']).parser.failed).toBe(true);
+        expect(parse(['{"a": "\\x"}']).fields).toEqual([]);
+        expect(parse(['{"a": 1}', ' trailing']).parser.failed).toBe(true);
+    });
+});
+
+describe('Tool argument field events', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(toolArgumentEvents: boolean) {
+        const chunk = (args: string, first = false): CanonicalEvent => ({
+            type: 'content_delta',
+            toolCall: first
+                ? { index: 0, id: 'call_1', type: 'function', function: { name: 'search', arguments: args } }
+                : { index: 0, function: { arguments: args } },
+        });
+        gateway = await harness()
+            .app({ name: 'agents', frontdoor: 'openai', path: '/v1', toolArgumentEvents })
+            .provider(new ScriptedProvider('mock', {
+                events: [
+                    { type: 'message_start', role: 'assistant', model: 'gpt-4o' },
+                    chunk('', true),
+                    chunk('{"query": "ca'),
+                    chunk('ts", "limit"'),
+                    chunk(': 5, "opts": {"x": [1]}'),
+                    chunk('}'),
+                    { type: 'message_stop', finishReason: 'tool_calls' },
+                    { type: 'done' },
+                ],
+            }))
+            .start();
+        const [turn] = await scenario(gateway).turn({
+            path: '/v1/chat/completions',
+            body: { model: 'gpt-4o', stream: true, messages: [{ role: 'user', content: 'Find cats' }] },
+        }).run();
+        return { turn: turn!, store: gateway.store };
+    }
+
+    it('should announce fields after the deltas completing them, leaving native chunks unchanged', async () => {
+        const { turn, store } = await setup(true);
+
+        const fields = turn.events!.flatMap((e) => (e.type === 'gateway' ? [e.data] : []));
+        expect(fields).toEqual([
+            { call_id: 'call_1', path: 'query', value: 'cats' },
+            { call_id: 'call_1', path: 'limit', value: 5 },
+            { call_id: 'call_1', path: 'opts', value: { x: [1] } },
+        ]);
+        expect(collapse(turn.events!.filter((e) => e.type !== 'gateway'))).toEqual([
+            { type: 'start' },
+            { type: 'tool_call', id: 'call_1', name: 'search' },
+            { type: 'tool_args', text: '{"query": "cats", "limit": 5, "opts": {"x": [1]}}' },
+            { type: 'stop', reason: 'tool_calls' },
+            { type: 'done' },
+        ]);
+
+        // Each announcement follows the chunk that completed its field
+        const all = parseSSE(turn.body);
+        const at = (text: string) => all.findIndex((f) => f.data.includes(text));
+        expect(all.filter((f) => f.event === 'gateway.tool_argument.field_complete')).toHaveLength(3);
+        expect(at('"path":"query"')).toBeGreaterThan(at('ts\\", \\"limit\\"'));
+        expect(at('"path":"limit"')).toBeGreaterThan(at(': 5, '));
+        expect(at('"path":"opts"')).toBeGreaterThan(at('"arguments":"}"'));
+
+        await vi.waitFor(() => expect(store.eventsOf('tool_argument_field')).toHaveLength(3));
+        expect(store.eventsOf('tool_argument_field')[0]!.payload).toEqual({ call_id: 'call_1', path: 'query', value: 'cats' });
+    });
+
+    it('should send no announcements for apps without tool_argument_events', async () => {
+        const { turn, store } = await setup(false);
+
+        expect(turn.body).not.toContain('gateway.tool_argument');
+        expect(store.eventsOf('tool_argument_field')).toEqual([]);
+    });
+});
@@ -0,0 +1,15 @@
+/**
+ * Tool argument streaming exports.
+ *
+ * @module toolargs
+ */
+
+export { ToolArgumentParser, type ToolArgumentField } from './parser.js';
+
+export {
+    TOOL_ARGUMENT_FIELD_EVENT,
+    ToolArgumentWatchingProvider,
+    withToolArgumentWatch,
+    interleaveToolArgumentEvents,
+    type ToolArgumentFieldEvent,
+} from './stream.js';
@@ -0,0 +1,217 @@
+/**
+ * Incremental parsing of streamed tool-call arguments.
+ *
+ * Tool-call arguments arrive as JSON text split at arbitrary points,
+ * including inside escapes and string values. The parser is fed each
+ * delta and reports each top-level field of the arguments object as soon
+ * as its value is complete; nested values are reported whole with their
+ * field. Once the text stops being a JSON object the parser fails and
+ * reports nothing more, whatever follows.
+ *
+ * @module toolargs/parser
+ */
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A completed top-level field of a tool call's arguments.
+ */
+export interface ToolArgumentField {
+    /** Field name. */
+    path: string;
+
+    /** Parsed field value. */
+    value: unknown;
+}
+
+type ParserState =
+    | 'start'         // before the opening brace
+    | 'key'           // expecting a key or a closing brace
+    | 'key_string'    // inside a key
+    | 'colon'         // after a key
+    | 'value'         // expecting a value
+    | 'value_text'    // inside a value
+    | 'after_value'   // expecting a comma or a closing brace
+    | 'done'          // object closed
+    | 'failed';       // not a JSON object
+
+const WHITESPACE = new Set([' ', '\t', '\n', '\r']);
+
+// ============================================================================
+// Parser
+// ============================================================================
+
+/**
+ * Parses one tool call's arguments, delta by delta.
+ */
+export class ToolArgumentParser {
+    private state: ParserState = 'start';
+    private key = '';
+    private raw = '';
+    private depth = 0;
+    private inString = false;
+    private escaped = false;
+
+    /** Whether the arguments turned out not to be a JSON object. */
+    get failed(): boolean {
+        return this.state === 'failed';
+    }
+
+    /**
+     * Feeds the next delta, returning the fields it completed.
+     */
+    push(delta: string): ToolArgumentField[] {
+        const fields: ToolArgumentField[] = [];
+        for (const c of delta) {
+            if (this.state === 'failed') {
+                break;
+            }
+            const field = this.step(c);
+            if (field) {
+                fields.push(field);
+            }
+        }
+        return fields;
+    }
+
+    private step(c: string): ToolArgumentField | undefined {
+        switch (this.state) {
+            case 'start':
+                if (c === '{') this.state = 'key';
+                else if (!WHITESPACE.has(c)) this.state = 'failed';
+                return undefined;
+
+            case 'key':
+                if (c === '"') {
+                    this.state = 'key_string';
+                    this.raw = '';
+                } else if (c === '}') {
+                    this.state = 'done';
+                } else if (!WHITESPACE.has(c)) {
+                    this.state = 'failed';
+                }
+                return undefined;
+
+            case 'key_string':
+                if (this.escaped) {
+                    this.escaped = false;
+                } else if (c === '\\') {
+                    this.escaped = true;
+                } else if (c === '"') {
+                    const key = decode(`"${this.raw}"`);
+                    if (typeof key !== 'string') {
+                        this.state = 'failed';
+                        return undefined;
+                    }
+                    this.key = key;
+                    this.state = 'colon';
+                    return undefined;
+                }
+                this.raw += c;
+                return undefined;
+
+            case 'colon':
+                if (c === ':') this.state = 'value';
+                else if (!WHITESPACE.has(c)) this.state = 'failed';
+                return undefined;
+
+            case 'value':
+                if (WHITESPACE.has(c)) {
+                    return undefined;
+                }
+                if (c === ',' || c === '}' || c === ']' || c === ':') {
+                    this.state = 'failed';
+                    return undefined;
+                }
+                this.state = 'value_text';
+                this.raw = '';
+                this.depth = 0;
+                this.inString = false;
+                this.escaped = false;
+                return this.valueChar(c);
+
+            case 'value_text':
+                return this.valueChar(c);
+
+            case 'after_value':
+                if (c === ',') this.state = 'key';
+                else if (c === '}') this.state = 'done';
+                else if (!WHITESPACE.has(c)) this.state = 'failed';
+                return undefined;
+
+            case 'done':
+                if (!WHITESPACE.has(c)) this.state = 'failed';
+                return undefined;
+
+            default:
+                return undefined;
+        }
+    }
+
+    /**
+     * Adds a character to the current value. Strings, objects, and arrays
+     * end on their closing character; numbers and literals only on the
+     * comma or brace after them.
+     */
+    private valueChar(c: string): ToolArgumentField | undefined {
+        if (this.inString) {
+            this.raw += c;
+            if (this.escaped) {
+                this.escaped = false;
+            } else if (c === '\\') {
+                this.escaped = true;
+            } else if (c === '"') {
+                this.inString = false;
+                if (this.depth === 0) {
+                    return this.complete('after_value');
+                }
+            }
+            return undefined;
+        }
+
+        if (this.depth === 0 && this.raw !== '' && (c === ',' || c === '}' || WHITESPACE.has(c))) {
+            const field = this.complete('after_value');
+            if (field && !WHITESPACE.has(c)) {
+                this.step(c);
+            }
+            return field;
+        }
+
+        this.raw += c;
+        if (c === '"') {
+            this.inString = true;
+        } else if (c === '{' || c === '[') {
+            this.depth++;
+        } else if (c === '}' || c === ']') {
+            this.depth--;
+            if (this.depth < 0) {
+                this.state = 'failed';
+            } else if (this.depth === 0) {
+                return this.complete('after_value');
+            }
+        }
+        return undefined;
+    }
+
+    private complete(next: ParserState): ToolArgumentField | undefined {
+        const value = decode(this.raw);
+        if (value === INVALID) {
+            this.state = 'failed';
+            return undefined;
+        }
+        this.state = next;
+        return { path: this.key, value };
+    }
+}
+
+const INVALID = Symbol('invalid');
+
+function decode(text: string): unknown {
+    try {
+        return JSON.parse(text) as unknown;
+    } catch {
+        return INVALID;
+    }
+}
@@ -0,0 +1,146 @@
+/**
+ * Tool argument field events on streams.
+ *
+ * For apps with `tool_argument_events` on, a streamed tool call's
+ * argument deltas are parsed as they arrive, and each top-level field is
+ * announced once complete, so an agent can act on it (start a search once
+ * "query" closes) before the call ends. The announcements are extra
+ * `gateway.tool_argument.field_complete` SSE events between the
+ * protocol's own, which pass through unchanged.
+ *
+ * @module toolargs/stream
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import { ToolArgumentParser } from './parser.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** SSE event announcing a completed top-level tool argument field. */
+export const TOOL_ARGUMENT_FIELD_EVENT = 'gateway.tool_argument.field_complete';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A completed top-level field of a streamed tool call's arguments.
+ */
+export interface ToolArgumentFieldEvent {
+    /** Tool call ID, once the stream has given one. */
+    call_id: string | null;
+
+    /** Field name. */
+    path: string;
+
+    /** Parsed field value. */
+    value: unknown;
+}
+
+// ============================================================================
+// Watching Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so completed tool argument fields of its streams are
+ * reported. Complete (non-streaming) responses pass through.
+ */
+export class ToolArgumentWatchingProvider extends ProviderWrapper {
+    private readonly onField: (field: ToolArgumentFieldEvent) => void;
+
+    constructor(inner: Provider, onField: (field: ToolArgumentFieldEvent) => void) {
+        super(inner);
+        this.onField = onField;
+    }
+
+    /**
+     * Streams a request, parsing each tool call's argument deltas. A
+     * field is reported once the event completing it has been taken, so
+     * its announcement can't reach the client ahead of that event.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        const calls = new Map<string, { id: string | null; parser: ToolArgumentParser }>();
+        let completed: ToolArgumentFieldEvent[] = [];
+        const report = (): void => {
+            for (const field of completed) {
+                this.onField(field);
+            }
+            completed = [];
+        };
+        for await (const event of this.inner.stream(request, options)) {
+            report();
+            const chunk = event.toolCall;
+            if (chunk) {
+                const key = `${event.choiceIndex ?? 0}:${chunk.index}`;
+                let call = calls.get(key);
+                if (!call) {
+                    call = { id: null, parser: new ToolArgumentParser() };
+                    calls.set(key, call);
+                }
+                call.id = chunk.id ?? call.id;
+                const delta = chunk.function?.arguments;
+                if (delta) {
+                    for (const field of call.parser.push(delta)) {
+                        completed.push({ call_id: call.id, ...field });
+                    }
+                }
+            }
+            yield event;
+        }
+        report();
+    }
+}
+
+/**
+ * Reports a provider's completed tool argument fields to `onField` when
+ * given. Returns the provider unchanged otherwise.
+ */
+export function withToolArgumentWatch(
+    provider: Provider,
+    onField: ((field: ToolArgumentFieldEvent) => void) | undefined,
+): Provider {
+    return onField ? new ToolArgumentWatchingProvider(provider, onField) : provider;
+}
+
+// ============================================================================
+// SSE Injection
+// ============================================================================
+
+/**
+ * Passes an SSE body through, sending the fields reported so far as
+ * gateway.tool_argument.field_complete events after each complete frame
+ * of the body, and any left once it closes.
+ */
+export function interleaveToolArgumentEvents(
+    body: ReadableStream<Uint8Array>,
+    take: () => ToolArgumentFieldEvent[],
+): ReadableStream<Uint8Array> {
+    const encoder = new TextEncoder();
+    const decoder = new TextDecoder();
+    // Events only go between frames: a chunk may end partway through one
+    let tail = '';
+    const send = (controller: TransformStreamDefaultController<Uint8Array>): void => {
+        for (const field of take()) {
+            controller.enqueue(encoder.encode(`event: ${TOOL_ARGUMENT_FIELD_EVENT}\ndata: ${JSON.stringify(field)}\n\n`));
+        }
+    };
+    return body.pipeThrough(new TransformStream<Uint8Array, Uint8Array>({
+        transform(chunk, controller) {
+            controller.enqueue(chunk);
+            tail = (tail + decoder.decode(chunk, { stream: true })).slice(-2);
+            if (tail === '\n\n') {
+                send(controller);
+            }
+        },
+        flush(controller) {
+            send(controller);
+        },
+    }));
+}
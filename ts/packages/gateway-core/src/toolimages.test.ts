@@ -0,0 +1,150 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AnthropicCodec } from './codecs/anthropic';
+import { OpenAICodec } from './codecs/openai';
+import type { APIType, CanonicalRequest } from './domain/types';
+import { TOOL_RESULT_IMAGE_PLACEHOLDER } from './frontdoors/models';
+import { WARNINGS_HEADER } from './warnings/collector';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+/** A 1x1 transparent PNG. */
+const PNG = 'iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==';
+
+const screenshot = {
+    type: 'tool_result',
+    tool_use_id: 'toolu_1',
+    content: [
+        { type: 'text', text: 'Screenshot:' },
+        { type: 'image', source: { type: 'base64', media_type: 'image/png', data: PNG } },
+    ],
+};
+
+const anthropicRequest = {
+    model: 'test-model',
+    max_tokens: 256,
+    messages: [
+        { role: 'user', content: 'What does the page look like?' },
+        { role: 'assistant', content: [{ type: 'tool_use', id: 'toolu_1', name: 'screenshot', input: {} }] },
+        { role: 'user', content: [screenshot] },
+    ],
+};
+
+/** The body a provider of the given API is sent for a request. */
+function wire(apiType: 'openai' | 'anthropic', request: CanonicalRequest | undefined): any {
+    const codec = apiType === 'openai' ? new OpenAICodec() : new AnthropicCodec();
+    return JSON.parse(new TextDecoder().decode(codec.encodeRequest(request!)));
+}
+
+describe('Tool result images', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(apiType: APIType) {
+        const provider = new ScriptedProvider('upstream', { text: 'A login form' }, apiType);
+        gateway = await harness()
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/anthropic' })
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(provider)
+            .start();
+        return { gw: gateway, provider };
+    }
+
+    it('should hand Anthropic the image inside the tool result', async () => {
+        const { gw, provider } = await setup('anthropic');
+
+        const response = await gw.post('/anthropic/v1/messages', anthropicRequest);
+
+        expect(response.status).toBe(200);
+        expect(response.headers.get(WARNINGS_HEADER)).toBeNull();
+        expect(wire('anthropic', provider.lastRequest).messages[2]).toEqual({ role: 'user', content: [screenshot] });
+    });
+
+    it('should move the image to a user message for OpenAI, and say so', async () => {
+        const { gw, provider } = await setup('openai');
+
+        const response = await gw.post('/anthropic/v1/messages', anthropicRequest);
+
+        expect(response.status).toBe(200);
+        expect(wire('openai', provider.lastRequest).messages.slice(1)).toEqual([
+            {
+                role: 'assistant',
+                content: '',
+                tool_calls: [{ id: 'toolu_1', type: 'function', function: { name: 'screenshot', arguments: '{}' } }],
+            },
+            { role: 'tool', content: `Screenshot:${TOOL_RESULT_IMAGE_PLACEHOLDER}`, tool_call_id: 'toolu_1' },
+            {
+                role: 'user',
+                content: [
+                    { type: 'text', text: 'Images returned by the tools above:' },
+                    { type: 'image_url', image_url: { url: `data:image/png;base64,${PNG}` } },
+                ],
+            },
+        ]);
+        expect(JSON.parse(response.headers.get(WARNINGS_HEADER)!)).toEqual([{
+            code: 'tool_result_images',
+            message: 'routed provider does not accept images in tool results; sent them in a following user message',
+        }]);
+    });
+
+    it('should keep the user message after a run of tool results', async () => {
+        const { gw, provider } = await setup('openai');
+
+        await gw.post('/anthropic/v1/messages', {
+            ...anthropicRequest,
+            messages: [
+                anthropicRequest.messages[0],
+                {
+                    role: 'assistant',
+                    content: [
+                        { type: 'tool_use', id: 'toolu_1', name: 'screenshot', input: {} },
+                        { type: 'tool_use', id: 'toolu_2', name: 'title', input: {} },
+                    ],
+                },
+                {
+                    role: 'user',
+                    content: [
+                        screenshot,
+                        { type: 'tool_result', tool_use_id: 'toolu_2', content: 'Sign in' },
+                        { type: 'text', text: 'Describe it' },
+                    ],
+                },
+            ],
+        });
+
+        const roles = wire('openai', provider.lastRequest).messages.map((m: any) => m.role);
+        expect(roles).toEqual(['user', 'assistant', 'tool', 'tool', 'user', 'user']);
+    });
+
+    it('should hand Anthropic an OpenAI client\'s tool result and image as one turn', async () => {
+        const { gw, provider } = await setup('anthropic');
+
+        const response = await gw.post('/v1/chat/completions', {
+            model: 'test-model',
+            messages: [
+                { role: 'user', content: 'What does the page look like?' },
+                {
+                    role: 'assistant',
+                    content: null,
+                    tool_calls: [{ id: 'toolu_1', type: 'function', function: { name: 'screenshot', arguments: '{}' } }],
+                },
+                { role: 'tool', tool_call_id: 'toolu_1', content: 'Screenshot attached' },
+                {
+                    role: 'user',
+                    content: [{ type: 'image_url', image_url: { url: `data:image/png;base64,${PNG}` } }],
+                },
+            ],
+        });
+
+        expect(response.status).toBe(200);
+        expect(wire('anthropic', provider.lastRequest).messages[2]).toEqual({
+            role: 'user',
+            content: [
+                { type: 'tool_result', tool_use_id: 'toolu_1', content: 'Screenshot attached' },
+                { type: 'image', source: { type: 'base64', media_type: 'image/png', data: PNG } },
+            ],
+        });
+    });
+});
@@ -0,0 +1,180 @@
+import { describe, it, expect, vi } from 'vitest';
+import { runToolLoop, calculatorTool, createHTTPTool, evaluateExpression } from './tools/index';
+import { openAIFrontdoor } from './frontdoors/index';
+
+const request = {
+    model: 'gpt-4o',
+    messages: [{ role: 'user', content: 'what is 6 * 7?' }],
+    tools: [{ type: 'function', function: { name: 'lookup_order', parameters: {} } }],
+    stream: false,
+} as any;
+
+function reply(message: Record<string, unknown>, finishReason = 'stop') {
+    return {
+        id: 'r', object: 'chat.completion', created: 0, model: 'gpt-4o', sourceAPIType: 'openai',
+        usage: { promptTokens: 10, completionTokens: 5, totalTokens: 15 },
+        choices: [{ index: 0, finishReason, message: { role: 'assistant', content: '', ...message } }],
+    } as any;
+}
+
+const call = (id: string, name: string, args: string) => ({ id, type: 'function', function: { name, arguments: args } });
+
+/** Provider that returns the scripted replies in order and records each request. */
+function scripted(...replies: any[]) {
+    const requests: any[] = [];
+    return {
+        requests,
+        provider: {
+            name: 'mock',
+            apiType: 'openai',
+            complete: async (req: any) => {
+                requests.push(req);
+                return replies.shift();
+            },
+        } as any,
+    };
+}
+
+describe('runToolLoop', () => {
+    it('should execute gateway tools and re-call the provider with the results', async () => {
+        const { provider, requests } = scripted(
+            reply({ toolCalls: [call('c1', 'calculator', '{"expression":"6 * 7"}')] }, 'tool_calls'),
+            reply({ content: 'It is 42.' }),
+        );
+        const saveEvent = vi.fn(async () => { });
+
+        const response = await runToolLoop(provider, request, {
+            tools: [calculatorTool],
+            interactionId: 'i1',
+            tenantId: 't1',
+            events: { saveEvent },
+        });
+
+        expect(response.choices[0]!.message.content).toBe('It is 42.');
+        expect(response.usage).toEqual({ promptTokens: 20, completionTokens: 10, totalTokens: 30 });
+        expect(requests[0].tools.map((t: any) => t.function.name)).toEqual(['lookup_order', 'calculator']);
+        expect(requests[1].messages.slice(1)).toEqual([
+            expect.objectContaining({ role: 'assistant', toolCalls: [expect.objectContaining({ id: 'c1' })] }),
+            { role: 'tool', content: '42', toolCallId: 'c1' },
+        ]);
+        expect(saveEvent).toHaveBeenCalledWith(expect.objectContaining({
+            type: 'tool_execute',
+            interactionId: 'i1',
+            payload: expect.objectContaining({ tool: 'calculator', callId: 'c1', result: '42' }),
+        }));
+    });
+
+    it('should return only client calls from a mixed response', async () => {
+        const { provider, requests } = scripted(reply({
+            toolCalls: [call('c1', 'calculator', '{"expression":"1+1"}'), call('c2', 'lookup_order', '{}')],
+        }, 'tool_calls'));
+        const execute = vi.spyOn(calculatorTool, 'execute');
+
+        const response = await runToolLoop(provider, request, { tools: [calculatorTool], interactionId: 'i', tenantId: 't' });
+
+        expect(requests).toHaveLength(1);
+        expect(execute).not.toHaveBeenCalled();
+        expect(response.choices[0]!.message.toolCalls).toEqual([call('c2', 'lookup_order', '{}')]);
+        expect(response.choices[0]!.finishReason).toBe('tool_calls');
+        execute.mockRestore();
+    });
+
+    it('should stop after max iterations and hide gateway calls from the client', async () => {
+        const again = () => reply({ content: 'checking', toolCalls: [call('c', 'calculator', '{"expression":"1"}')] }, 'tool_calls');
+        const { provider, requests } = scripted(again(), again(), again());
+
+        const response = await runToolLoop(provider, { ...request, toolChoice: 'required' }, {
+            tools: [calculatorTool],
+            maxIterations: 2,
+            interactionId: 'i',
+            tenantId: 't',
+        });
+
+        expect(requests).toHaveLength(3);
+        expect(requests.map((r) => r.toolChoice)).toEqual(['required', 'auto', 'none']);
+        expect(response.choices[0]!.message.toolCalls).toBeUndefined();
+        expect(response.choices[0]!.finishReason).toBe('stop');
+    });
+
+    it('should report tool failures to the model and truncate long results in events', async () => {
+        const { provider, requests } = scripted(
+            reply({ toolCalls: [call('c1', 'calculator', 'not json'), call('c2', 'search', '{"query":"x"}')] }, 'tool_calls'),
+            reply({ content: 'done' }),
+        );
+        const events: any[] = [];
+        const search = { name: 'search', parameters: {}, execute: async () => 'x'.repeat(5000) };
+
+        await runToolLoop(provider, request, {
+            tools: [calculatorTool, search],
+            interactionId: 'i',
+            tenantId: 't',
+            events: { saveEvent: async (e) => { events.push(e); } },
+        });
+
+        expect(requests[1].messages[2].content).toMatch(/^Error: /);
+        expect(requests[1].messages[3].content).toHaveLength(5000);
+        expect(events[0].payload.error).toBeDefined();
+        expect(events[1].payload.result).toHaveLength(1001);
+    });
+});
+
+describe('calculator', () => {
+    it('should follow operator precedence', () => {
+        expect(evaluateExpression('(2 + 3) * sqrt(16) / 4')).toBe(5);
+        expect(evaluateExpression('2 ^ 3 ^ 2')).toBe(512);
+        expect(evaluateExpression('-2 ^ 2')).toBe(-4);
+        expect(evaluateExpression('10 % 4 + .5')).toBe(2.5);
+    });
+
+    it('should reject anything but arithmetic', () => {
+        expect(() => evaluateExpression('constructor(1)')).toThrow("unexpected 'constructor'");
+        expect(() => evaluateExpression('2 +')).toThrow('unexpected end');
+        expect(() => evaluateExpression('3 4')).toThrow("unexpected '4'");
+    });
+});
+
+describe('createHTTPTool', () => {
+    it('should POST the arguments and return the response body', async () => {
+        const fetch = vi.fn(async () => new Response('result text'));
+        const tool = createHTTPTool({ name: 'web_search', url: 'https://search.test/q', headers: { 'X-Key': 'k' }, fetch: fetch as any });
+
+        const result = await tool.execute({ query: 'llm gateways' }, { interactionId: 'i', tenantId: 't' });
+
+        expect(result).toBe('result text');
+        const [url, init] = fetch.mock.calls[0] as any;
+        expect(url).toBe('https://search.test/q');
+        expect(init.headers['X-Key']).toBe('k');
+        expect(JSON.parse(init.body)).toEqual({ tool: 'web_search', arguments: { query: 'llm gateways' }, interactionId: 'i', tenantId: 't' });
+        expect(tool.parameters).toMatchObject({ required: ['query'] });
+    });
+
+    it('should fail on non-2xx responses', async () => {
+        const tool = createHTTPTool({ name: 'x', url: 'https://x.test', fetch: (async () => new Response('', { status: 502 })) as any });
+
+        await expect(tool.execute({}, { interactionId: 'i', tenantId: 't' })).rejects.toThrow('Tool endpoint returned 502');
+    });
+});
+
+describe('frontdoor gateway tools', () => {
+    it('should run the tool loop for non-streaming chat completions', async () => {
+        const { provider } = scripted(
+            reply({ toolCalls: [call('c1', 'calculator', '{"expression":"6*7"}')] }, 'tool_calls'),
+            reply({ content: '42' }),
+        );
+
+        const result = await openAIFrontdoor.handle({
+            request: new Request('http://localhost/v1/chat/completions', {
+                method: 'POST',
+                body: JSON.stringify({ model: 'gpt-4o', messages: [{ role: 'user', content: '6*7?' }] }),
+            }),
+            provider,
+            auth: { tenantId: 't', scopes: [], metadata: {} },
+            interactionId: 'i',
+            gatewayTools: [calculatorTool],
+        } as any);
+
+        const body = await result.response.json() as any;
+        expect(body.choices[0].message.content).toBe('42');
+        expect(body.usage.total_tokens).toBe(30);
+    });
+});
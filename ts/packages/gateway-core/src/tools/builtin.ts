@@ -0,0 +1,236 @@
+/**
+ * Built-in gateway tools.
+ *
+ * @module tools/builtin
+ */
+
+import type { GatewayTool, ToolExecutionContext } from './types.js';
+
+// ============================================================================
+// HTTP Tool
+// ============================================================================
+
+/**
+ * HTTP tool options.
+ */
+export interface HTTPToolOptions {
+    /** Tool name. */
+    name: string;
+
+    /** Description shown to the model. */
+    description?: string | undefined;
+
+    /** Endpoint the arguments are POSTed to. */
+    url: string;
+
+    /** JSON Schema for the arguments (default: a single required `query` string). */
+    parameters?: Record<string, unknown> | undefined;
+
+    /** Extra headers. */
+    headers?: Record<string, string> | undefined;
+
+    /** Timeout in milliseconds (default: 10000). */
+    timeoutMs?: number | undefined;
+
+    /** Custom fetch implementation. */
+    fetch?: typeof fetch | undefined;
+}
+
+/** Default schema for search-style tools. */
+const QUERY_PARAMETERS: Record<string, unknown> = {
+    type: 'object',
+    properties: {
+        query: { type: 'string', description: 'The search query.' },
+    },
+    required: ['query'],
+};
+
+/**
+ * Creates a tool backed by an HTTP endpoint (web search, retrieval, ...).
+ * The endpoint receives the arguments plus the interaction and tenant IDs,
+ * and its response body is returned to the model verbatim.
+ */
+export function createHTTPTool(options: HTTPToolOptions): GatewayTool {
+    const { url, headers = {}, timeoutMs = 10000 } = options;
+    const fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+
+    return {
+        name: options.name,
+        description: options.description,
+        parameters: options.parameters ?? QUERY_PARAMETERS,
+
+        async execute(args: Record<string, unknown>, ctx: ToolExecutionContext): Promise<string> {
+            const controller = new AbortController();
+            const timeoutId = setTimeout(() => controller.abort(), timeoutMs);
+
+            let response: Response;
+            try {
+                response = await fetchFn(url, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        ...headers,
+                    },
+                    body: JSON.stringify({
+                        tool: options.name,
+                        arguments: args,
+                        interactionId: ctx.interactionId,
+                        tenantId: ctx.tenantId,
+                    }),
+                    signal: controller.signal,
+                });
+            } finally {
+                clearTimeout(timeoutId);
+            }
+
+            if (!response.ok) {
+                throw new Error(`Tool endpoint returned ${response.status}`);
+            }
+
+            return response.text();
+        },
+    };
+}
+
+// ============================================================================
+// Calculator
+// ============================================================================
+
+/**
+ * Evaluates arithmetic expressions without eval: numbers, + - * / % ^,
+ * parentheses, the constants pi and e, and common math functions.
+ */
+export const calculatorTool: GatewayTool = {
+    name: 'calculator',
+    description: 'Evaluates an arithmetic expression, e.g. "(2 + 3) * sqrt(16) / 4". '
+        + 'Supports + - * / % ^, parentheses, pi, e, and sqrt, abs, round, floor, ceil, ln, log, sin, cos, tan.',
+    parameters: {
+        type: 'object',
+        properties: {
+            expression: { type: 'string', description: 'The expression to evaluate.' },
+        },
+        required: ['expression'],
+    },
+
+    async execute(args: Record<string, unknown>): Promise<string> {
+        if (typeof args.expression !== 'string') {
+            throw new Error('expression must be a string');
+        }
+        const value = evaluateExpression(args.expression);
+        if (!Number.isFinite(value)) {
+            throw new Error('result is not a finite number');
+        }
+        return String(value);
+    },
+};
+
+const FUNCTIONS: Record<string, (x: number) => number> = {
+    sqrt: Math.sqrt,
+    abs: Math.abs,
+    round: Math.round,
+    floor: Math.floor,
+    ceil: Math.ceil,
+    ln: Math.log,
+    log: Math.log10,
+    sin: Math.sin,
+    cos: Math.cos,
+    tan: Math.tan,
+};
+
+const CONSTANTS: Record<string, number> = {
+    pi: Math.PI,
+    e: Math.E,
+};
+
+/**
+ * Evaluates an arithmetic expression. Throws on syntax errors.
+ */
+export function evaluateExpression(expression: string): number {
+    const tokens = expression.match(/\d+\.?\d*(?:e[+-]?\d+)?|\.\d+|[a-z_]+|\S/gi) ?? [];
+    let pos = 0;
+
+    const peek = (): string | undefined => tokens[pos];
+    const next = (): string => {
+        const token = tokens[pos++];
+        if (token === undefined) throw new Error('unexpected end of expression');
+        return token;
+    };
+    const expect = (token: string): void => {
+        const actual = next();
+        if (actual !== token) throw new Error(`expected '${token}' but found '${actual}'`);
+    };
+
+    // expr := term (('+' | '-') term)*
+    const expr = (): number => {
+        let value = term();
+        while (peek() === '+' || peek() === '-') {
+            value = next() === '+' ? value + term() : value - term();
+        }
+        return value;
+    };
+
+    // term := unary (('*' | '/' | '%') unary)*
+    const term = (): number => {
+        let value = unary();
+        while (peek() === '*' || peek() === '/' || peek() === '%') {
+            const op = next();
+            const rhs = unary();
+            value = op === '*' ? value * rhs : op === '/' ? value / rhs : value % rhs;
+        }
+        return value;
+    };
+
+    // unary := ('-' | '+') unary | power
+    const unary = (): number => {
+        if (peek() === '-') {
+            next();
+            return -unary();
+        }
+        if (peek() === '+') {
+            next();
+            return unary();
+        }
+        return power();
+    };
+
+    // power := primary ('^' unary)?   (right-associative)
+    const power = (): number => {
+        const base = primary();
+        if (peek() === '^') {
+            next();
+            return base ** unary();
+        }
+        return base;
+    };
+
+    // primary := number | constant | function '(' expr ')' | '(' expr ')'
+    const primary = (): number => {
+        const token = next();
+        if (token === '(') {
+            const value = expr();
+            expect(')');
+            return value;
+        }
+        if (/^[\d.]/.test(token)) {
+            return Number(token);
+        }
+        const name = token.toLowerCase();
+        if (Object.hasOwn(CONSTANTS, name)) {
+            return CONSTANTS[name]!;
+        }
+        if (Object.hasOwn(FUNCTIONS, name)) {
+            const fn = FUNCTIONS[name]!;
+            expect('(');
+            const value = expr();
+            expect(')');
+            return fn(value);
+        }
+        throw new Error(`unexpected '${token}'`);
+    };
+
+    const value = expr();
+    if (pos < tokens.length) {
+        throw new Error(`unexpected '${tokens[pos]}'`);
+    }
+    return value;
+}
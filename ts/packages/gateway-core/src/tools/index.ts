@@ -0,0 +1,28 @@
+/**
+ * Gateway tools module exports.
+ *
+ * @module tools
+ */
+
+export {
+    createToolRegistry,
+    toToolDefinition,
+    type GatewayTool,
+    type ToolExecutionContext,
+    type ToolRegistry,
+} from './types.js';
+
+export {
+    calculatorTool,
+    createHTTPTool,
+    evaluateExpression,
+    type HTTPToolOptions,
+} from './builtin.js';
+
+export {
+    runToolLoop,
+    withGatewayTools,
+    DEFAULT_MAX_TOOL_ITERATIONS,
+    TOOL_RESULT_EVENT_LIMIT,
+    type ToolLoopOptions,
+} from './loop.js';
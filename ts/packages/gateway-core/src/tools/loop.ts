@@ -0,0 +1,250 @@
+/**
+ * Gateway tool execution loop.
+ *
+ * Calls the provider with the gateway tools added to the request; while the
+ * model asks only for gateway tools, executes them, appends the results,
+ * and calls the provider again. Calls for client tools are always returned
+ * to the client, and gateway calls are never shown to it.
+ *
+ * @module tools/loop
+ */
+
+import type { CanonicalRequest, CanonicalResponse, Message, ToolCall, Usage } from '../domain/types.js';
+import { createInteractionEvent } from '../domain/events.js';
+import type { Provider } from '../ports/provider.js';
+import type { InteractionStore } from '../ports/storage.js';
+import type { Logger } from '../utils/logging.js';
+import { errCallBudgetExceeded, isCallBudgetExceeded, type RequestBudget } from '../callbudget/budget.js';
+import { type GatewayTool, toToolDefinition } from './types.js';
+
+// ============================================================================
+// Options
+// ============================================================================
+
+/** Default number of tool rounds before the model must answer. */
+export const DEFAULT_MAX_TOOL_ITERATIONS = 5;
+
+/** Characters of a tool result kept in its interaction event. */
+export const TOOL_RESULT_EVENT_LIMIT = 1000;
+
+/**
+ * Tool loop options.
+ */
+export interface ToolLoopOptions {
+    /** Gateway tools exposed to the model. */
+    tools: GatewayTool[];
+
+    /** Maximum tool rounds (default: DEFAULT_MAX_TOOL_ITERATIONS). */
+    maxIterations?: number | undefined;
+
+    /** Interaction ID, for tool context and events. */
+    interactionId: string;
+
+    /** Tenant ID, for tool context. */
+    tenantId: string;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+
+    /** Where tool_execute events are saved (optional). */
+    events?: Pick<InteractionStore, 'saveEvent'> | undefined;
+
+    /** The request's call budget; no round is run once it is spent (optional). */
+    budget?: RequestBudget | undefined;
+}
+
+// ============================================================================
+// Loop
+// ============================================================================
+
+/**
+ * Adds the gateway tool definitions to a request. A client tool with the
+ * same name as a gateway tool is replaced, since the gateway would
+ * intercept its calls anyway.
+ */
+export function withGatewayTools(request: CanonicalRequest, tools: GatewayTool[]): CanonicalRequest {
+    const names = new Set(tools.map((t) => t.name));
+    return {
+        ...request,
+        tools: [
+            ...(request.tools ?? []).filter((t) => !names.has(t.function.name)),
+            ...tools.map(toToolDefinition),
+        ],
+    };
+}
+
+/**
+ * Completes a request, executing gateway tool calls until the model
+ * answers, calls a client tool, or runs out of iterations. Usage in the
+ * returned response covers every provider call.
+ */
+export async function runToolLoop(
+    provider: Provider,
+    request: CanonicalRequest,
+    options: ToolLoopOptions,
+): Promise<CanonicalResponse> {
+    const { logger } = options;
+    const maxIterations = options.maxIterations ?? DEFAULT_MAX_TOOL_ITERATIONS;
+    const owned = new Map(options.tools.map((t) => [t.name, t]));
+    const usage: Usage = { promptTokens: 0, completionTokens: 0, totalTokens: 0 };
+
+    let current = withGatewayTools(request, options.tools);
+    let iterations = 0;
+    let previous: CanonicalResponse | undefined;
+
+    for (;;) {
+        let response: CanonicalResponse;
+        try {
+            response = await provider.complete(current, iterations > 0 ? { attempt: 'tool_loop_iteration' } : undefined);
+        } catch (error) {
+            // The budget ran out while the tools ran
+            if (previous && isCallBudgetExceeded(error)) {
+                return { ...bestResult(previous, owned, options), usage };
+            }
+            throw error;
+        }
+        addUsage(usage, response.usage);
+
+        const message = response.choices[0]?.message;
+        const calls = message?.toolCalls ?? [];
+        const gatewayCalls = calls.filter((c) => owned.has(c.function.name));
+
+        if (!message || gatewayCalls.length === 0) {
+            return { ...response, usage };
+        }
+
+        if (gatewayCalls.length < calls.length) {
+            // The client has to answer its own calls first; the model can
+            // re-issue the gateway calls once it has those results
+            logger?.debug('gateway_tools_deferred', {
+                tools: gatewayCalls.map((c) => c.function.name).join(', '),
+            });
+            return { ...withoutGatewayCalls(response, owned), usage };
+        }
+
+        if (iterations >= maxIterations) {
+            logger?.warn('gateway_tool_iterations_exhausted', { iterations });
+            return { ...withoutGatewayCalls(response, owned), usage };
+        }
+        if (options.budget && !options.budget.allows()) {
+            return { ...bestResult(response, owned, options), usage };
+        }
+        iterations++;
+        previous = response;
+
+        const results = await Promise.all(
+            gatewayCalls.map((call) => executeCall(owned.get(call.function.name)!, call, options)),
+        );
+
+        current = {
+            ...current,
+            messages: [...current.messages, message, ...results],
+            // Force an answer on the last round, and don't force the same
+            // tool again after it has run
+            toolChoice: iterations >= maxIterations
+                ? 'none'
+                : current.toolChoice === 'required' || typeof current.toolChoice === 'object'
+                    ? 'auto'
+                    : current.toolChoice,
+        };
+    }
+}
+
+/**
+ * Executes one tool call and returns its result message. Failures are
+ * reported to the model rather than failing the request.
+ */
+async function executeCall(tool: GatewayTool, call: ToolCall, options: ToolLoopOptions): Promise<Message> {
+    const start = Date.now();
+    let result: string;
+    let error: string | undefined;
+
+    try {
+        const args = call.function.arguments ? JSON.parse(call.function.arguments) as unknown : {};
+        if (typeof args !== 'object' || args === null || Array.isArray(args)) {
+            throw new Error('arguments must be a JSON object');
+        }
+        result = await tool.execute(args as Record<string, unknown>, {
+            interactionId: options.interactionId,
+            tenantId: options.tenantId,
+        });
+    } catch (err) {
+        error = err instanceof Error ? err.message : String(err);
+        result = `Error: ${error}`;
+    }
+
+    const durationMs = Date.now() - start;
+    options.logger?.info('tool_execute', { tool: tool.name, callId: call.id, durationMs, error });
+
+    if (options.events) {
+        const event = createInteractionEvent('tool_execute', options.interactionId, {
+            tool: tool.name,
+            callId: call.id,
+            arguments: call.function.arguments,
+            durationMs,
+            result: truncate(result, TOOL_RESULT_EVENT_LIMIT),
+            error,
+        });
+        await options.events.saveEvent(event).catch((err: unknown) => {
+            options.logger?.warn('tool_event_save_failed', {
+                error: err instanceof Error ? err.message : String(err),
+            });
+        });
+    }
+
+    return { role: 'tool', content: result, toolCallId: call.id };
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * The response to return when the call budget stops the loop: the last
+ * one, without its gateway calls. One with nothing left to show fails
+ * the request instead.
+ */
+function bestResult(response: CanonicalResponse, owned: Map<string, GatewayTool>, options: ToolLoopOptions): CanonicalResponse {
+    const limit = options.budget?.exhausted ?? 'max_calls';
+    options.logger?.warn('gateway_tool_budget_exhausted', { limit });
+    const best = withoutGatewayCalls(response, owned);
+    const message = best.choices[0]?.message;
+    if (!message?.content && !message?.toolCalls?.length) {
+        throw errCallBudgetExceeded(limit);
+    }
+    return best;
+}
+
+/**
+ * Removes gateway tool calls from every choice.
+ */
+function withoutGatewayCalls(response: CanonicalResponse, owned: Map<string, GatewayTool>): CanonicalResponse {
+    return {
+        ...response,
+        choices: response.choices.map((choice) => {
+            const remaining = choice.message.toolCalls?.filter((c) => !owned.has(c.function.name));
+            if (remaining?.length === choice.message.toolCalls?.length) {
+                return choice;
+            }
+            const hasCalls = remaining !== undefined && remaining.length > 0;
+            return {
+                ...choice,
+                message: { ...choice.message, toolCalls: hasCalls ? remaining : undefined },
+                finishReason: !hasCalls && choice.finishReason === 'tool_calls' ? 'stop' : choice.finishReason,
+            };
+        }),
+    };
+}
+
+function addUsage(total: Usage, usage: Usage): void {
+    total.promptTokens += usage.promptTokens;
+    total.completionTokens += usage.completionTokens;
+    total.totalTokens += usage.totalTokens;
+    if (usage.reasoningTokens !== undefined) {
+        total.reasoningTokens = (total.reasoningTokens ?? 0) + usage.reasoningTokens;
+    }
+}
+
+function truncate(value: string, limit: number): string {
+    return value.length > limit ? `${value.slice(0, limit)}…` : value;
+}
@@ -0,0 +1,106 @@
+/**
+ * Gateway tool types and registry.
+ *
+ * Gateway tools are executed by the gateway itself: their definitions are
+ * added to the request's tools and, when the model calls one, the gateway
+ * runs it and feeds the result back before answering the client.
+ *
+ * @module tools/types
+ */
+
+import type { ToolDefinition } from '../domain/types.js';
+
+// ============================================================================
+// Gateway Tool
+// ============================================================================
+
+/**
+ * Context passed to a tool execution.
+ */
+export interface ToolExecutionContext {
+    /** Interaction the call belongs to. */
+    interactionId: string;
+
+    /** Tenant that made the request. */
+    tenantId: string;
+}
+
+/**
+ * A tool the gateway executes on the model's behalf.
+ */
+export interface GatewayTool {
+    /** Tool name the model calls. */
+    readonly name: string;
+
+    /** Description shown to the model. */
+    readonly description?: string | undefined;
+
+    /** JSON Schema for the arguments. */
+    readonly parameters: Record<string, unknown>;
+
+    /**
+     * Executes the tool. `args` is the parsed arguments object; the result
+     * is passed to the model as the tool message content. Throwing reports
+     * the error message to the model instead.
+     */
+    execute(args: Record<string, unknown>, ctx: ToolExecutionContext): Promise<string>;
+}
+
+/**
+ * Converts a gateway tool to a canonical tool definition.
+ */
+export function toToolDefinition(tool: GatewayTool): ToolDefinition {
+    return {
+        type: 'function',
+        function: {
+            name: tool.name,
+            description: tool.description,
+            parameters: tool.parameters,
+        },
+    };
+}
+
+// ============================================================================
+// Tool Registry
+// ============================================================================
+
+/**
+ * Registry of gateway tools.
+ */
+export interface ToolRegistry {
+    /**
+     * Registers a tool, replacing any tool with the same name.
+     */
+    register(tool: GatewayTool): void;
+
+    /**
+     * Gets a tool by name.
+     */
+    get(name: string): GatewayTool | undefined;
+
+    /**
+     * Lists registered tool names.
+     */
+    list(): string[];
+}
+
+/**
+ * Creates a new tool registry.
+ */
+export function createToolRegistry(): ToolRegistry {
+    const tools = new Map<string, GatewayTool>();
+
+    return {
+        register(tool: GatewayTool): void {
+            tools.set(tool.name, tool);
+        },
+
+        get(name: string): GatewayTool | undefined {
+            return tools.get(name);
+        },
+
+        list(): string[] {
+            return Array.from(tools.keys());
+        },
+    };
+}
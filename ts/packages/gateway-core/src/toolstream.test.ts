@@ -0,0 +1,142 @@
+import { describe, it, expect } from 'vitest';
+import { AnthropicProvider } from './providers/index';
+import { OpenAICodec } from './codecs/openai';
+import { ResponsesHandler, RESPONSES_DONE_FRAME } from './responses/index';
+import type { CanonicalEvent, CanonicalRequest } from './domain/types';
+
+const request: CanonicalRequest = {
+    tenantId: 'tenant-a',
+    model: 'claude-3-5-sonnet-20241022',
+    messages: [{ role: 'user', content: 'Find cats' }],
+    stream: true,
+};
+
+/** The Anthropic stream: a text block, then two tool_use blocks with fine-grained argument deltas. */
+const upstream: object[] = [
+    { type: 'message_start', message: { id: 'msg_1', type: 'message', role: 'assistant', model: 'claude-3-5-sonnet-20241022', content: [], stop_reason: null, stop_sequence: null, usage: { input_tokens: 12, output_tokens: 0 } } },
+    { type: 'content_block_start', index: 0, content_block: { type: 'text', text: '' } },
+    { type: 'content_block_delta', index: 0, delta: { type: 'text_delta', text: 'Searching.' } },
+    { type: 'content_block_stop', index: 0 },
+    { type: 'content_block_start', index: 1, content_block: { type: 'tool_use', id: 'toolu_1', name: 'search', input: {} } },
+    { type: 'content_block_delta', index: 1, delta: { type: 'input_json_delta', partial_json: '' } },
+    { type: 'content_block_delta', index: 1, delta: { type: 'input_json_delta', partial_json: '{"qu' } },
+    { type: 'content_block_delta', index: 1, delta: { type: 'input_json_delta', partial_json: 'ery": "ca' } },
+    { type: 'content_block_delta', index: 1, delta: { type: 'input_json_delta', partial_json: 'ts"}' } },
+    { type: 'content_block_stop', index: 1 },
+    { type: 'content_block_start', index: 2, content_block: { type: 'tool_use', id: 'toolu_2', name: 'lookup', input: {} } },
+    { type: 'content_block_delta', index: 2, delta: { type: 'input_json_delta', partial_json: '{"id": 7}' } },
+    { type: 'content_block_stop', index: 2 },
+    { type: 'message_delta', delta: { stop_reason: 'tool_use', stop_sequence: null }, usage: { output_tokens: 30 } },
+    { type: 'message_stop' },
+];
+
+/**
+ * A fetch whose response body emits one SSE event per `send`, so a test can
+ * observe what the gateway yields before the rest of the stream arrives.
+ */
+function feed() {
+    const encoder = new TextEncoder();
+    let controller!: ReadableStreamDefaultController<Uint8Array>;
+    const body = new ReadableStream<Uint8Array>({
+        start(c) {
+            controller = c;
+        },
+    });
+    return {
+        fetch: async () => new Response(body, { status: 200, headers: { 'Content-Type': 'text/event-stream' } }),
+        send(event: object) {
+            controller.enqueue(encoder.encode(`event: ${(event as { type: string }).type}\ndata: ${JSON.stringify(event)}\n\n`));
+        },
+        sendAll(events: object[]) {
+            for (const event of events) this.send(event);
+            controller.close();
+        },
+    };
+}
+
+const provider = (fetch: () => Promise<Response>) =>
+    new AnthropicProvider({ name: 'anthropic', apiKey: 'k', fetch: fetch as any });
+
+describe('Anthropic tool input streaming', () => {
+    it('should encode each input_json_delta as its own OpenAI tool_calls chunk', async () => {
+        const upstreamFeed = feed();
+        upstreamFeed.sendAll(upstream);
+        const codec = new OpenAICodec();
+
+        const chunks: any[] = [];
+        for await (const event of provider(upstreamFeed.fetch).stream(request)) {
+            if (event.type === 'done') break;
+            chunks.push(JSON.parse(codec.encodeStreamEvent(event, { id: 'chatcmpl-1', model: 'gpt-4o', created: 0 })));
+        }
+
+        const toolDeltas = chunks.flatMap((chunk) => chunk.choices[0]?.delta.tool_calls ?? []);
+        expect(toolDeltas).toEqual([
+            { index: 0, id: 'toolu_1', type: 'function', function: { name: 'search', arguments: '' } },
+            { index: 0, function: { arguments: '' } },
+            { index: 0, function: { arguments: '{"qu' } },
+            { index: 0, function: { arguments: 'ery": "ca' } },
+            { index: 0, function: { arguments: 'ts"}' } },
+            { index: 1, id: 'toolu_2', type: 'function', function: { name: 'lookup', arguments: '' } },
+            { index: 1, function: { arguments: '{"id": 7}' } },
+        ]);
+        expect(chunks.map((chunk) => chunk.choices[0]?.finish_reason).filter(Boolean)).toEqual(['tool_calls']);
+    });
+
+    it('should yield an argument fragment before the block is closed upstream', async () => {
+        const upstreamFeed = feed();
+        for (const event of upstream.slice(0, 7)) upstreamFeed.send(event);
+
+        const events = provider(upstreamFeed.fetch).stream(request);
+        const seen: CanonicalEvent[] = [];
+        while (seen[seen.length - 1]?.toolCall?.function?.arguments !== '{"qu') {
+            const { value } = await events.next();
+            seen.push(value as CanonicalEvent);
+        }
+
+        expect(seen.some((event) => event.type === 'content_block_stop' && event.index === 1)).toBe(false);
+        upstreamFeed.sendAll(upstream.slice(7));
+        await events.return(undefined);
+    });
+
+    it('should forward function_call_arguments.delta events to Responses API clients', async () => {
+        const upstreamFeed = feed();
+        upstreamFeed.sendAll(upstream);
+        const saved: any[] = [];
+        const handler = new ResponsesHandler({
+            storage: { saveResponse: async (record: any) => saved.push(record) } as any,
+            provider: provider(upstreamFeed.fetch),
+        });
+
+        const frames: string[] = [];
+        for await (const frame of handler.handleStream({ model: 'claude-3-5-sonnet-20241022', input: 'Find cats', stream: true }, 'tenant-a')) {
+            frames.push(frame);
+        }
+
+        const events = frames.filter((frame) => frame !== RESPONSES_DONE_FRAME)
+            .map((frame) => JSON.parse(/^data: (.+)$/m.exec(frame)![1]!));
+        const deltas = events.filter((e) => e.type === 'response.function_call_arguments.delta');
+        expect(deltas.map((e) => [e.output_index, e.delta])).toEqual([
+            [1, '{"qu'],
+            [1, 'ery": "ca'],
+            [1, 'ts"}'],
+            [2, '{"id": 7}'],
+        ]);
+
+        const added = events.filter((e) => e.type === 'response.output_item.added' && e.item.type === 'function_call');
+        expect(added.map((e) => e.item)).toEqual([
+            expect.objectContaining({ call_id: 'toolu_1', name: 'search', arguments: '', status: 'in_progress' }),
+            expect.objectContaining({ call_id: 'toolu_2', name: 'lookup', arguments: '', status: 'in_progress' }),
+        ]);
+        expect(events.indexOf(added[0])).toBeLessThan(events.indexOf(deltas[0]));
+
+        const completed = events.find((e) => e.type === 'response.completed');
+        expect(completed.response.output.slice(1)).toEqual([
+            expect.objectContaining({ type: 'function_call', call_id: 'toolu_1', arguments: '{"query": "cats"}', status: 'completed' }),
+            expect.objectContaining({ type: 'function_call', call_id: 'toolu_2', arguments: '{"id": 7}', status: 'completed' }),
+        ]);
+        expect(saved[0].response.choices[0]).toMatchObject({
+            finishReason: 'tool_calls',
+            message: { toolCalls: [{ id: 'toolu_1', function: { name: 'search', arguments: '{"query": "cats"}' } }, { id: 'toolu_2' }] },
+        });
+    });
+});
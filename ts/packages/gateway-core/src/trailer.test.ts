@@ -0,0 +1,81 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+/** Splits an SSE body into frames, dropping the trailing empty one. */
+function frames(body: string): string[] {
+    return body.split('\n\n').filter((frame) => frame !== '');
+}
+
+const chat = { messages: [{ role: 'user', content: 'Hi' }] };
+const messages = { max_tokens: 64, messages: [{ role: 'user', content: 'Hi' }] };
+const responses = { input: 'Hi' };
+
+describe('Gateway usage trailer', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(usageTrailer = true) {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', usageTrailer })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/v1/messages', usageTrailer })
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses', usageTrailer })
+            .provider(new ScriptedProvider('mock', { text: 'Hello', usage: { promptTokens: 10, completionTokens: 5, totalTokens: 15 } }))
+            .start();
+        const gw = gateway;
+        const send = (path: string, body: object, extra: Record<string, string> = {}) =>
+            gw.post(path, { model: 'gpt-4o', stream: true, ...body }, extra);
+        return { send };
+    }
+
+    it.each([
+        ['/v1/chat/completions', chat, 'data: [DONE]'],
+        ['/v1/messages', messages, 'event: message_stop'],
+        ['/v1/responses', responses, 'event: response.completed'],
+    ])('should append gateway.usage after the terminal event on %s', async (path, body, terminal) => {
+        const { send } = await setup();
+
+        const response = await send(path, body);
+        const sent = frames(await response.text());
+
+        const trailer = sent[sent.length - 1]!;
+        const terminalIndex = sent.findIndex((frame) => frame.includes(terminal));
+        expect(terminalIndex).toBeGreaterThanOrEqual(0);
+        expect(terminalIndex).toBeLessThan(sent.length - 1);
+        expect(sent.filter((frame) => frame.startsWith('event: gateway.usage'))).toHaveLength(1);
+        expect(trailer).toMatch(/^event: gateway\.usage\ndata: /);
+        const data = JSON.parse(trailer.slice(trailer.indexOf('data: ') + 'data: '.length));
+        expect(data).toMatchObject({
+            interaction_id: response.headers.get('X-Gateway-Interaction-Id'),
+            provider: 'mock',
+            model: 'gpt-4o',
+            prompt_tokens: 10,
+            completion_tokens: 5,
+            total_tokens: 15,
+        });
+        // gpt-4o catalog pricing: $2.50 in / $10 out per million tokens
+        expect(data.cost_usd).toBeCloseTo(0.000075, 9);
+    });
+
+    it('should omit the trailer when the client sends X-Gateway-No-Trailer', async () => {
+        const { send } = await setup();
+
+        const response = await send('/v1/chat/completions', chat, { 'X-Gateway-No-Trailer': '1' });
+        const sent = frames(await response.text());
+
+        expect(sent[sent.length - 1]).toBe('data: [DONE]');
+        expect(sent.some((frame) => frame.includes('gateway.usage'))).toBe(false);
+    });
+
+    it('should omit the trailer unless the app opts in', async () => {
+        const { send } = await setup(false);
+
+        const sent = frames(await (await send('/v1/messages', messages)).text());
+
+        expect(sent[sent.length - 1]).toContain('event: message_stop');
+        expect(sent.some((frame) => frame.includes('gateway.usage'))).toBe(false);
+    });
+});
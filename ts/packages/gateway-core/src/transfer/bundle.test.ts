@@ -0,0 +1,213 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { bundleChecksum } from './index';
+import { sha256 } from '../utils/crypto';
+import { harness, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/** A store with the thread transfer methods, recording each import batch. */
+function memoryStorage() {
+    const conversations = new Map<string, any>();
+    const responses = new Map<string, any>();
+    const states = new Map<string, any>();
+    const imports = new Map<string, any>();
+    const batches: any[] = [];
+
+    const scoped = (record: any, tenantId: string) =>
+        record && (tenantId === '' || record.tenantId === tenantId) ? structuredClone(record) : null;
+    const page = (items: any[], options: { limit?: number; offset?: number } = {}) => items
+        .sort((a, b) => a.createdAt.getTime() - b.createdAt.getTime())
+        .slice(options.offset ?? 0, (options.offset ?? 0) + (options.limit ?? 50))
+        .map((item) => structuredClone(item));
+    const setState = async (threadKey: string, responseId: string, updatedAt = new Date()) => {
+        states.set(threadKey, { threadKey, keyHash: await sha256(threadKey), responseId, updatedAt });
+    };
+
+    return {
+        conversations,
+        responses,
+        states,
+        batches,
+        saveConversation: async (c: any) => void conversations.set(c.id, structuredClone(c)),
+        getConversation: async (id: string, tenantId: string) => scoped(conversations.get(id), tenantId),
+        listConversations: async (tenantId: string, options?: any) =>
+            page([...conversations.values()].filter((c) => c.tenantId === tenantId), options),
+        saveResponse: async (r: any) => void responses.set(r.id, structuredClone(r)),
+        getResponse: async (id: string, tenantId: string) => scoped(responses.get(id), tenantId),
+        listResponses: async (tenantId: string, options?: any) =>
+            page([...responses.values()].filter((r) => r.tenantId === tenantId), options),
+        setThreadState: (threadKey: string, responseId: string) => setState(threadKey, responseId),
+        getThreadState: async (threadKey: string) => states.get(threadKey)?.responseId ?? null,
+        listThreadState: async (options: { limit?: number; cursor?: string } = {}) => [...states.values()]
+            .filter((e) => !options.cursor || e.keyHash > options.cursor)
+            .sort((a, b) => a.keyHash.localeCompare(b.keyHash))
+            .slice(0, options.limit ?? 50),
+        saveEvent: async () => {},
+        importThreadBatch: async (batch: any) => {
+            batches.push(batch);
+            batch.conversations.forEach((c: any) => conversations.set(c.id, structuredClone(c)));
+            batch.responses.forEach((r: any) => responses.set(r.id, structuredClone(r)));
+            for (const e of batch.threadState) {
+                await setState(e.threadKey, e.responseId, e.updatedAt);
+            }
+        },
+        getThreadImport: async (checksum: string) => imports.get(checksum) ?? null,
+        recordThreadImport: async (record: any) => void imports.set(record.checksum, record),
+    };
+}
+
+/** Running deployments, closed after each test. */
+const deployments: TestGateway[] = [];
+
+afterEach(async () => {
+    await Promise.all(deployments.splice(0).map((gw) => gw.close()));
+});
+
+/** A gateway and admin API over a store; only `tenants` exist. */
+async function deployment(tenants: string[]) {
+    const storage = memoryStorage();
+    const provider = new ScriptedProvider('mock', { text: 'Hello', usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 } });
+    const auth = {
+        authenticate: async (token: string) => token === 'ops'
+            ? { tenantId: 'ops', scopes: ['admin'], metadata: {} }
+            : { tenantId: token, scopes: [], metadata: {} },
+        getTenant: async (id: string) => tenants.includes(id) ? { id, name: id, apiKeys: [] } as any : null,
+    };
+    const logger = spyLogger();
+    const gw = await harness()
+        .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses' })
+        .provider(provider)
+        .config({ routing: { defaultProvider: 'mock', affinity: { ttl: '1h' } } })
+        .options({ auth, storage: storage as any })
+        .start();
+    deployments.push(gw);
+    const admin = new AdminHandler({ storage: storage as any, auth, logger });
+
+    const respond = async (tenantId: string, body: Record<string, unknown>) => {
+        const response = await gw.post('/v1/responses', { model: 'gpt-4o', ...body }, { Authorization: `Bearer ${tenantId}` });
+        return { status: response.status, body: await response.json() };
+    };
+    const post = async (path: string, body: unknown, token = 'ops') => {
+        const response = await admin.handle(new Request(`http://localhost${path}`, {
+            method: 'POST',
+            headers: { Authorization: `Bearer ${token}`, 'Content-Type': 'application/json' },
+            body: JSON.stringify(body),
+        }));
+        return { status: response.status, body: await response.json() };
+    };
+    return { gateway: gw.gateway, storage, provider, logger, respond, post };
+}
+
+/** Waits for the affinity mappings written after each response. */
+const settled = () => new Promise((resolve) => setTimeout(resolve, 10));
+
+describe('Thread export and import', () => {
+    it('should carry a previous_response_id chain to another deployment', async () => {
+        const staging = await deployment(['staging-acme']);
+        await staging.gateway.reload();
+        const first = await staging.respond('staging-acme', { input: 'Hi' });
+        const second = await staging.respond('staging-acme', { input: 'Again', previousResponseId: first.body.id });
+        await settled();
+        await staging.storage.saveConversation({
+            id: 'conv_1', tenantId: 'staging-acme', model: 'gpt-4o', createdAt: new Date(), updatedAt: new Date(),
+            messages: [{ id: 'msg_1', role: 'user', content: 'Hi', timestamp: new Date() }],
+        });
+
+        const exported = await staging.post('/api/export/threads', { tenant_ids: ['staging-acme'] });
+        expect(exported.status).toBe(200);
+        const bundle = exported.body;
+        expect(bundle).toMatchObject({ version: 1, tenants: ['staging-acme'] });
+        expect(bundle.responses.map((r: any) => r.id)).toEqual([first.body.id, second.body.id]);
+        expect(bundle.conversations).toHaveLength(1);
+        expect(bundle.threadState.map((e: any) => e.threadKey))
+            .toContain(`affinity:staging-acme:response:${first.body.id}`);
+
+        // Production already has something else under the first response's ID
+        const production = await deployment(['acme', 'other']);
+        await production.gateway.reload();
+        await production.storage.saveResponse({
+            id: first.body.id, tenantId: 'other', model: 'gpt-4o', status: 'completed',
+            createdAt: new Date(0), updatedAt: new Date(0),
+        });
+
+        const imported = await production.post('/api/import/threads', {
+            bundle, tenant_map: { 'staging-acme': 'acme' }, batch_size: 2,
+        });
+
+        expect(imported.status).toBe(200);
+        expect(imported.body).toMatchObject({
+            checksum: bundle.checksum, duplicate: false, conversations: 1, responses: 2,
+        });
+        const moved = imported.body.remapped[first.body.id];
+        expect(moved).toMatch(/^resp_[0-9a-f]{32}$/);
+        expect(Object.keys(imported.body.remapped)).toEqual([first.body.id]);
+        expect(production.storage.batches.length).toBeGreaterThan(1);
+        expect(production.storage.responses.get(first.body.id).tenantId).toBe('other');
+        expect(production.storage.responses.get(second.body.id))
+            .toMatchObject({ tenantId: 'acme', previousResponseId: moved });
+        expect(production.storage.states.has(`affinity:acme:response:${moved}`)).toBe(true);
+        expect(production.storage.conversations.get('conv_1').tenantId).toBe('acme');
+        expect(production.logger.info).toHaveBeenCalledWith('threads_imported', expect.objectContaining({ audit: true }));
+
+        // Continuing either response resolves its history from the imported data
+        const continued = await production.respond('acme', { input: 'More', previousResponseId: moved });
+        expect(continued.status).toBe(200);
+        expect(production.provider.requests[0]).toMatchObject({
+            messages: [
+                { role: 'user', content: 'Hi' },
+                { role: 'assistant', content: 'Hello' },
+                { role: 'user', content: 'More' },
+            ],
+        });
+        expect((await production.respond('acme', { input: 'More', previousResponseId: second.body.id })).status).toBe(200);
+    });
+
+    it('should write nothing when the same bundle is imported again', async () => {
+        const staging = await deployment(['acme']);
+        await staging.gateway.reload();
+        await staging.respond('acme', { input: 'Hi' });
+        const { body: bundle } = await staging.post('/api/export/threads', { tenant_ids: ['acme'] });
+        const production = await deployment(['acme']);
+
+        await production.post('/api/import/threads', { bundle });
+        const batches = production.storage.batches.length;
+        const again = await production.post('/api/import/threads', { bundle: { ...bundle, exportedAt: 'later' } });
+
+        expect(again.status).toBe(200);
+        expect(again.body).toMatchObject({ duplicate: true, responses: 1 });
+        expect(production.storage.batches).toHaveLength(batches);
+    });
+
+    it('should reject unknown tenants and altered bundles before writing', async () => {
+        const staging = await deployment(['acme']);
+        await staging.gateway.reload();
+        await staging.respond('acme', { input: 'Hi' });
+        const { body: bundle } = await staging.post('/api/export/threads', { tenant_ids: ['acme'] });
+        const production = await deployment(['prod']);
+
+        const unknown = await production.post('/api/import/threads', { bundle });
+        expect(unknown).toEqual({ status: 400, body: { error: 'Unknown tenants: acme' } });
+
+        const altered = { ...bundle, responses: [{ ...bundle.responses[0], model: 'gpt-4o-mini' }] };
+        const tampered = await production.post('/api/import/threads', { bundle: altered, tenant_map: { acme: 'prod' } });
+        expect(tampered.body.error).toBe('Bundle checksum does not match its content');
+        expect(production.storage.batches).toEqual([]);
+
+        // A recomputed checksum makes an edited bundle importable
+        const resealed = { ...altered, checksum: await bundleChecksum(altered) };
+        expect((await production.post('/api/import/threads', { bundle: resealed, tenant_map: { acme: 'prod' } })).status).toBe(200);
+    });
+
+    it('should require operator access and valid bodies', async () => {
+        const { post } = await deployment(['acme']);
+
+        expect((await post('/api/export/threads', { tenant_ids: ['acme'] }, 'acme')).status).toBe(403);
+        expect((await post('/api/import/threads', {}, 'acme')).status).toBe(403);
+        expect((await post('/api/export/threads', {})).body)
+            .toEqual({ error: 'tenant_ids must be a non-empty array of strings' });
+        expect((await post('/api/import/threads', { bundle: { version: 2 } })).body)
+            .toEqual({ error: 'bundle version must be 1' });
+        const empty = { version: 1, checksum: 'x', tenants: [], conversations: [], responses: [], threadState: [] };
+        expect((await post('/api/import/threads', { bundle: empty, batch_size: 0 })).body)
+            .toEqual({ error: 'batch_size must be an integer from 1 to 1000' });
+    });
+});
@@ -0,0 +1,484 @@
+/**
+ * Thread export and import between gateway deployments.
+ *
+ * A thread bundle carries a set of tenants' stored conversations (with
+ * their messages), Responses API records with their threading columns,
+ * and the thread state mappings that point at them, as portable JSON
+ * keyed by the exporting gateway's IDs. Importing writes a bundle into
+ * another deployment's store in batches: tenants can be renamed, records
+ * whose IDs are already taken by something else get new IDs, and previous
+ * response IDs and thread keys are rewritten to follow them, so
+ * previous_response_id chains keep working. Each bundle carries a
+ * checksum of its content, and the target keeps a ledger of imported
+ * checksums, so importing the same bundle again writes nothing.
+ *
+ * @module transfer/bundle
+ */
+
+import type {
+    Conversation,
+    ListOptions,
+    ResponseRecord,
+    StorageProvider,
+    StoredMessage,
+    ThreadImportBatch,
+    ThreadImportRecord,
+    ThreadStateEntry,
+    ThreadTransferStore,
+} from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+import { errInvalidRequest } from '../domain/errors.js';
+import { affinityStateKey, parseAffinityStateKey, responseThreadKey } from '../affinity/affinity.js';
+import { sha256 } from '../utils/crypto.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Bundle format version. */
+export const THREAD_BUNDLE_VERSION = 1;
+
+/** Default number of records written per import transaction. */
+export const DEFAULT_IMPORT_BATCH_SIZE = 100;
+
+/** Largest import batch size accepted. */
+export const MAX_IMPORT_BATCH_SIZE = 1000;
+
+/** Rows read per list call while exporting. */
+const EXPORT_PAGE_SIZE = 100;
+
+/** Thread key prefix of a response's own key (see responseThreadKey). */
+const RESPONSE_THREAD_KEY = responseThreadKey('');
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/** A record with its timestamps as ISO 8601 strings. */
+type Portable<T> = { [K in keyof T]: T[K] extends Date ? string : T[K] };
+
+/** A message, as carried in a bundle. */
+export type BundleMessage = Portable<StoredMessage>;
+
+/** A conversation, as carried in a bundle. */
+export type BundleConversation = Omit<Portable<Conversation>, 'messages'> & { messages: BundleMessage[] };
+
+/** A Responses API record, as carried in a bundle. */
+export type BundleResponse = Portable<ResponseRecord>;
+
+/** A thread state mapping, as carried in a bundle. */
+export type BundleThreadState = Portable<Pick<ThreadStateEntry, 'threadKey' | 'responseId' | 'updatedAt'>>;
+
+/**
+ * A portable export of stored threads, as produced by
+ * /admin/api/export/threads.
+ */
+export interface ThreadBundle {
+    /** Bundle format version. */
+    version: number;
+
+    /** SHA-256 of the bundle's canonical content (everything but exportedAt), hex. */
+    checksum: string;
+
+    /** When the bundle was exported (ISO 8601). */
+    exportedAt: string;
+
+    /** Tenants the bundle was exported for; every record belongs to one. */
+    tenants: string[];
+
+    conversations: BundleConversation[];
+    responses: BundleResponse[];
+    threadState: BundleThreadState[];
+}
+
+/**
+ * Import options.
+ */
+export interface ThreadImportOptions {
+    /** Target tenant for each exported tenant that is renamed. */
+    tenantMap?: Record<string, string> | undefined;
+
+    /** Whether a target tenant exists; imports into unknown tenants are rejected. */
+    tenantExists: (tenantId: string) => Promise<boolean>;
+
+    /** Records written per transaction (default 100). */
+    batchSize?: number | undefined;
+}
+
+/**
+ * Outcome of an import.
+ */
+export interface ThreadImportResult extends ThreadImportRecord {
+    /** The bundle was already imported; nothing was written. */
+    duplicate: boolean;
+}
+
+/** Where an imported record goes. */
+interface Placement {
+    /** ID in the target store. */
+    id: string;
+
+    /** The record is already there (imported before), so it isn't written. */
+    present: boolean;
+}
+
+// ============================================================================
+// Export
+// ============================================================================
+
+/**
+ * Exports tenants' conversations, responses, and the thread state
+ * mappings that belong to them: affinity entries scoped to an exported
+ * tenant, and mappings whose key or value names an exported response.
+ */
+export async function exportThreads(storage: StorageProvider, tenantIds: string[]): Promise<ThreadBundle> {
+    const tenants = [...new Set(tenantIds)].sort();
+    const conversations: Conversation[] = [];
+    const responses: ResponseRecord[] = [];
+    for (const tenantId of tenants) {
+        conversations.push(...await listAll((page) => storage.listConversations(tenantId, page)));
+        responses.push(...await listAll((page) => storage.listResponses(tenantId, page)));
+    }
+
+    const responseIds = new Set(responses.map((r) => r.id));
+    const exported = (entry: ThreadStateEntry): boolean => {
+        const affinity = parseAffinityStateKey(entry.threadKey);
+        if (affinity) {
+            return tenants.includes(affinity.tenantId);
+        }
+        return responseIds.has(entry.responseId) || responseIds.has(responseIdOfKey(entry.threadKey) ?? '');
+    };
+    const threadState: ThreadStateEntry[] = [];
+    let cursor: string | undefined;
+    for (;;) {
+        const page = await storage.listThreadState({ limit: EXPORT_PAGE_SIZE, cursor });
+        threadState.push(...page.filter(exported));
+        if (page.length < EXPORT_PAGE_SIZE) break;
+        cursor = page[page.length - 1]!.keyHash;
+    }
+
+    // Through JSON, so the checksum covers exactly what the client receives
+    const content = JSON.parse(JSON.stringify({
+        version: THREAD_BUNDLE_VERSION,
+        tenants,
+        conversations,
+        responses,
+        threadState: threadState
+            .map(({ threadKey, responseId, updatedAt }) => ({ threadKey, responseId, updatedAt }))
+            .sort((a, b) => a.threadKey.localeCompare(b.threadKey)),
+    })) as Omit<ThreadBundle, 'checksum' | 'exportedAt'>;
+    return {
+        ...content,
+        checksum: await bundleChecksum(content),
+        exportedAt: new Date().toISOString(),
+    };
+}
+
+/**
+ * Lists every row, oldest first, a page at a time.
+ */
+async function listAll<T>(list: (page: ListOptions) => Promise<T[]>): Promise<T[]> {
+    const all: T[] = [];
+    for (let offset = 0; ; offset += EXPORT_PAGE_SIZE) {
+        const page = await list({ limit: EXPORT_PAGE_SIZE, offset, orderBy: 'createdAt', order: 'asc' });
+        all.push(...page);
+        if (page.length < EXPORT_PAGE_SIZE) return all;
+    }
+}
+
+// ============================================================================
+// Import
+// ============================================================================
+
+/**
+ * Imports a bundle. Records already in the store under the same ID,
+ * tenant, and creation time (from an earlier, interrupted import of the
+ * bundle) are left alone; records whose ID is taken by anything else are
+ * written under a new ID derived from the bundle checksum, so a retried
+ * import picks the same one. Throws an invalid request error for a
+ * corrupted bundle or an unknown tenant, before anything is written.
+ */
+export async function importThreads(
+    store: StorageProvider & ThreadTransferStore,
+    bundle: ThreadBundle,
+    options: ThreadImportOptions,
+): Promise<ThreadImportResult> {
+    const checksum = await bundleChecksum(bundle);
+    if (checksum !== bundle.checksum) {
+        throw errInvalidRequest('Bundle checksum does not match its content');
+    }
+    const previous = await store.getThreadImport(checksum);
+    if (previous) {
+        return { ...previous, duplicate: true };
+    }
+
+    // Tenants
+    const tenantOf = (id: string) => options.tenantMap?.[id] ?? id;
+    for (const record of [...bundle.conversations, ...bundle.responses]) {
+        if (!bundle.tenants.includes(record.tenantId)) {
+            throw errInvalidRequest(`Record ${record.id} belongs to tenant '${record.tenantId}', which the bundle does not list`);
+        }
+    }
+    const unknown: string[] = [];
+    for (const tenantId of bundle.tenants.map(tenantOf)) {
+        if (!(await options.tenantExists(tenantId))) {
+            unknown.push(tenantId);
+        }
+    }
+    if (unknown.length > 0) {
+        throw errInvalidRequest(`Unknown tenants: ${unknown.join(', ')}`);
+    }
+
+    // IDs
+    const remapped = new Map<string, string>();
+    const conversations: Conversation[] = [];
+    for (const portable of bundle.conversations) {
+        const conversation = fromPortableConversation(portable, tenantOf(portable.tenantId));
+        const placement = await place(conversation, checksum, (id) => store.getConversation(id, UNSCOPED_TENANT));
+        if (placement.id !== conversation.id) {
+            remapped.set(conversation.id, placement.id);
+            conversation.id = placement.id;
+            // Message IDs are unique across conversations too
+            for (const message of conversation.messages) {
+                message.id = await remapId(message.id, checksum);
+            }
+        }
+        if (!placement.present) {
+            conversations.push(conversation);
+        }
+    }
+    const responses: ResponseRecord[] = [];
+    for (const portable of bundle.responses) {
+        const response = fromPortableResponse(portable, tenantOf(portable.tenantId));
+        const placement = await place(response, checksum, (id) => store.getResponse(id, UNSCOPED_TENANT));
+        if (placement.id !== response.id) {
+            remapped.set(response.id, placement.id);
+            response.id = placement.id;
+        }
+        if (!placement.present) {
+            responses.push(response);
+        }
+    }
+
+    // References
+    const idOf = (id: string) => remapped.get(id) ?? id;
+    const threadKeyOf = (key: string) => {
+        const responseId = responseIdOfKey(key);
+        return responseId === undefined ? key : responseThreadKey(idOf(responseId));
+    };
+    for (const response of responses) {
+        response.previousResponseId = response.previousResponseId && idOf(response.previousResponseId);
+        response.threadKey = response.threadKey && threadKeyOf(response.threadKey);
+    }
+    const threadState = bundle.threadState.map((entry) => {
+        const affinity = parseAffinityStateKey(entry.threadKey);
+        return {
+            threadKey: affinity
+                ? affinityStateKey(tenantOf(affinity.tenantId), threadKeyOf(affinity.threadKey))
+                : threadKeyOf(entry.threadKey),
+            responseId: idOf(entry.responseId),
+            updatedAt: new Date(entry.updatedAt),
+        };
+    });
+
+    // Oldest responses first, so each batch's chains start in stored data
+    responses.sort((a, b) => a.createdAt.getTime() - b.createdAt.getTime());
+    for (const batch of toBatches(conversations, responses, threadState, options.batchSize ?? DEFAULT_IMPORT_BATCH_SIZE)) {
+        await store.importThreadBatch(batch);
+    }
+
+    const record: ThreadImportRecord = {
+        checksum,
+        conversations: conversations.length,
+        responses: responses.length,
+        threadState: threadState.length,
+        remapped: Object.fromEntries(remapped),
+        importedAt: new Date(),
+    };
+    await store.recordThreadImport(record);
+    return { ...record, duplicate: false };
+}
+
+/**
+ * Finds the ID an imported record is stored under.
+ */
+async function place(
+    record: { id: string; tenantId: string; createdAt: Date },
+    checksum: string,
+    get: (id: string) => Promise<{ tenantId: string; createdAt: Date } | null>,
+): Promise<Placement> {
+    const same = (existing: { tenantId: string; createdAt: Date }) =>
+        existing.tenantId === record.tenantId && existing.createdAt.getTime() === record.createdAt.getTime();
+
+    const existing = await get(record.id);
+    if (!existing) {
+        return { id: record.id, present: false };
+    }
+    if (same(existing)) {
+        return { id: record.id, present: true };
+    }
+    const id = await remapId(record.id, checksum);
+    const taken = await get(id);
+    if (taken && !same(taken)) {
+        throw errInvalidRequest(`No free ID for record ${record.id}`);
+    }
+    return { id, present: taken !== null };
+}
+
+/**
+ * Derives a replacement for a taken ID, keeping its prefix (resp_, msg_).
+ */
+async function remapId(id: string, checksum: string): Promise<string> {
+    const prefix = /^[A-Za-z]+_/.exec(id)?.[0] ?? '';
+    return prefix + (await sha256(`${checksum}:${id}`)).slice(0, 32);
+}
+
+function toBatches(
+    conversations: Conversation[],
+    responses: ResponseRecord[],
+    threadState: ThreadImportBatch['threadState'],
+    size: number,
+): ThreadImportBatch[] {
+    const batches: ThreadImportBatch[] = [];
+    let batch: ThreadImportBatch = { conversations: [], responses: [], threadState: [] };
+    let count = 0;
+    const added = () => {
+        if (++count === size) {
+            batches.push(batch);
+            batch = { conversations: [], responses: [], threadState: [] };
+            count = 0;
+        }
+    };
+    for (const conversation of conversations) {
+        batch.conversations.push(conversation);
+        added();
+    }
+    for (const response of responses) {
+        batch.responses.push(response);
+        added();
+    }
+    for (const entry of threadState) {
+        batch.threadState.push(entry);
+        added();
+    }
+    if (count > 0) {
+        batches.push(batch);
+    }
+    return batches;
+}
+
+// ============================================================================
+// Parsing
+// ============================================================================
+
+/**
+ * Checks a bundle's shape, or returns what is wrong with it. The checksum
+ * is verified on import.
+ */
+export function parseThreadBundle(value: unknown): ThreadBundle | string {
+    if (!isObject(value)) {
+        return 'bundle must be a JSON object';
+    }
+    const { version, checksum, tenants, conversations, responses, threadState } = value;
+    if (version !== THREAD_BUNDLE_VERSION) {
+        return `bundle version must be ${THREAD_BUNDLE_VERSION}`;
+    }
+    if (typeof checksum !== 'string' || !checksum) {
+        return 'bundle checksum is required';
+    }
+    if (!Array.isArray(tenants) || !tenants.every((t) => typeof t === 'string' && t)) {
+        return 'bundle tenants must be an array of tenant IDs';
+    }
+    if (!Array.isArray(conversations) || !conversations.every((c) => isObject(c)
+        && hasStrings(c, 'id', 'tenantId') && isTimestamp(c.createdAt) && isTimestamp(c.updatedAt)
+        && Array.isArray(c.messages)
+        && c.messages.every((m) => isObject(m) && hasStrings(m, 'id', 'role', 'content') && isTimestamp(m.timestamp)))) {
+        return 'bundle conversations must have id, tenantId, messages, createdAt, and updatedAt';
+    }
+    if (!Array.isArray(responses) || !responses.every((r) => isObject(r)
+        && hasStrings(r, 'id', 'tenantId', 'model', 'status') && isTimestamp(r.createdAt) && isTimestamp(r.updatedAt))) {
+        return 'bundle responses must have id, tenantId, model, status, createdAt, and updatedAt';
+    }
+    if (!Array.isArray(threadState) || !threadState.every((e) => isObject(e)
+        && hasStrings(e, 'threadKey', 'responseId') && isTimestamp(e.updatedAt))) {
+        return 'bundle threadState entries must have threadKey, responseId, and updatedAt';
+    }
+    return value as unknown as ThreadBundle;
+}
+
+function isObject(value: unknown): value is Record<string, unknown> {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
+
+function hasStrings(value: Record<string, unknown>, ...keys: string[]): boolean {
+    return keys.every((key) => typeof value[key] === 'string' && value[key]);
+}
+
+function isTimestamp(value: unknown): boolean {
+    return typeof value === 'string' && !Number.isNaN(Date.parse(value));
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Type guard to check if a storage provider implements ThreadTransferStore.
+ */
+export function isThreadTransferStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & ThreadTransferStore {
+    return storage !== undefined
+        && typeof storage.importThreadBatch === 'function'
+        && typeof storage.getThreadImport === 'function'
+        && typeof storage.recordThreadImport === 'function';
+}
+
+/**
+ * SHA-256 of a bundle's content in canonical JSON (sorted keys), so
+ * reformatting a bundle file doesn't change it.
+ */
+export async function bundleChecksum(bundle: Omit<ThreadBundle, 'checksum' | 'exportedAt'>): Promise<string> {
+    const { version, tenants, conversations, responses, threadState } = bundle;
+    return sha256(canonicalJSON({ version, tenants, conversations, responses, threadState }));
+}
+
+function canonicalJSON(value: unknown): string {
+    if (Array.isArray(value)) {
+        return `[${value.map((item) => canonicalJSON(item ?? null)).join(',')}]`;
+    }
+    if (isObject(value)) {
+        const fields = Object.keys(value)
+            .filter((key) => value[key] !== undefined)
+            .sort()
+            .map((key) => `${JSON.stringify(key)}:${canonicalJSON(value[key])}`);
+        return `{${fields.join(',')}}`;
+    }
+    return JSON.stringify(value);
+}
+
+/**
+ * The response ID in a response's own thread key, if it is one.
+ */
+function responseIdOfKey(threadKey: string): string | undefined {
+    return threadKey.startsWith(RESPONSE_THREAD_KEY) ? threadKey.slice(RESPONSE_THREAD_KEY.length) : undefined;
+}
+
+function fromPortableConversation(c: BundleConversation, tenantId: string): Conversation {
+    return {
+        ...c,
+        tenantId,
+        messages: c.messages.map((m) => ({ ...m, timestamp: new Date(m.timestamp) })),
+        createdAt: new Date(c.createdAt),
+        updatedAt: new Date(c.updatedAt),
+    };
+}
+
+function fromPortableResponse(r: BundleResponse, tenantId: string): ResponseRecord {
+    return {
+        ...r,
+        tenantId,
+        createdAt: new Date(r.createdAt),
+        updatedAt: new Date(r.updatedAt),
+    };
+}
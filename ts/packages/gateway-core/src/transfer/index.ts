@@ -0,0 +1,23 @@
+/**
+ * Thread transfer exports.
+ *
+ * @module transfer
+ */
+
+export {
+    exportThreads,
+    importThreads,
+    parseThreadBundle,
+    bundleChecksum,
+    isThreadTransferStore,
+    THREAD_BUNDLE_VERSION,
+    DEFAULT_IMPORT_BATCH_SIZE,
+    MAX_IMPORT_BATCH_SIZE,
+    type ThreadBundle,
+    type BundleConversation,
+    type BundleMessage,
+    type BundleResponse,
+    type BundleThreadState,
+    type ThreadImportOptions,
+    type ThreadImportResult,
+} from './bundle.js';
@@ -0,0 +1,131 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { compileTransforms, applyTransforms, withTransforms } from './transforms/index';
+import type { CanonicalEvent } from './domain/types';
+
+const EMAIL = { type: 'regex_replace', pattern: '[\\w.+-]+@[\\w-]+\\.[\\w.]+', replacement: '[email]' } as const;
+
+function completion(content: string) {
+    return {
+        id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'm', sourceAPIType: 'openai' as const,
+        usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+        choices: [{ index: 0, finishReason: 'stop' as const, message: { role: 'assistant' as const, content } }],
+    };
+}
+
+function streamingProvider(events: CanonicalEvent[]) {
+    return {
+        name: 'mock',
+        apiType: 'openai' as const,
+        complete: vi.fn(),
+        async *stream() {
+            yield* events;
+        },
+    };
+}
+
+async function collect(stream: AsyncIterable<CanonicalEvent>) {
+    const events: CanonicalEvent[] = [];
+    for await (const event of stream) {
+        events.push(event);
+    }
+    return events;
+}
+
+const deltas = (...texts: string[]): CanonicalEvent[] => texts.map((contentDelta) => ({ type: 'content_delta', contentDelta }));
+
+describe('response transforms', () => {
+    it('should apply transforms in order and record before/after lengths', () => {
+        const transforms = compileTransforms([EMAIL, { type: 'truncate_chars', maxChars: 12 }]);
+
+        const { response, steps } = applyTransforms(completion('Write to ann@example.com today'), transforms);
+
+        expect(response.choices[0]!.message.content).toBe('Write to [em');
+        expect(steps.map((s) => [s.stage, s.details])).toEqual([
+            ['response_transform', { index: 0, type: 'regex_replace', beforeLength: 30, afterLength: 22 }],
+            ['response_transform', { index: 1, type: 'truncate_chars', beforeLength: 22, afterLength: 12 }],
+        ]);
+    });
+
+    it('should strip markdown formatting and code fences', () => {
+        const [strip] = compileTransforms([{ type: 'strip_markdown' }]);
+        const text = '# Title\n```js\ncode()\n```\nSome **bold** and `x` [link](http://u) snake_case_name\n';
+
+        const { response } = applyTransforms(completion(text), [strip!]);
+
+        expect(response.choices[0]!.message.content).toBe('Title\ncode()\nSome bold and x link snake_case_name\n');
+    });
+
+    it('should extract a JSON path, unwrapping a fenced block', () => {
+        const transforms = compileTransforms([{ type: 'json_extract', path: 'a.1.b' }]);
+
+        expect(applyTransforms(completion('```json\n{"a":[1,{"b":"x"}]}\n```'), transforms).response.choices[0]!.message.content)
+            .toBe('x');
+
+        const missing = applyTransforms(completion('{"a":[]}'), transforms);
+        expect(missing.response.choices[0]!.message.content).toBe('{"a":[]}');
+        expect(missing.steps[0]!.warnings).toEqual(["path 'a.1.b' not found; left unchanged"]);
+    });
+
+    it.each([
+        [[{ type: 'regex_replace', pattern: '(' }], /^transforms\[0\]\.pattern: Invalid regular expression/],
+        [[{ type: 'strip_markdown' }, { type: 'regex_replace' }], /^transforms\[1\]\.pattern is required$/],
+        [[{ type: 'truncate_chars', maxChars: 0 }], /^transforms\[0\]\.max_chars must be a positive integer$/],
+        [[{ type: 'json_extract' }], /^transforms\[0\]\.path is required$/],
+        [[{ type: 'uppercase' }], /^transforms\[0\]: unknown type 'uppercase'$/],
+    ])('should reject invalid config %j', (configs, message) => {
+        expect(() => compileTransforms(configs as any)).toThrow(message);
+    });
+
+    it('should rewrite streams line by line and flush before the finish event', async () => {
+        const onSteps = vi.fn();
+        const provider = withTransforms(streamingProvider([
+            ...deltas('Mail a@b.co', 'm now\nThen ', 'x@y.io'),
+            { type: 'done', finishReason: 'stop' },
+        ]), compileTransforms([EMAIL]), onSteps);
+
+        const events = await collect(provider.stream({ model: 'm', messages: [] } as any));
+
+        expect(events.map((e) => e.contentDelta ?? e.finishReason)).toEqual(['Mail [email] now\n', 'Then [email]', 'stop']);
+        expect(onSteps.mock.calls[0]![0][0].details).toEqual({
+            index: 0, type: 'regex_replace', beforeLength: 28, afterLength: 29,
+        });
+    });
+
+    it('should truncate streams without buffering', async () => {
+        const provider = withTransforms(
+            streamingProvider(deltas('Hel', 'lo wor', 'ld')),
+            compileTransforms([{ type: 'truncate_chars', maxChars: 5 }]),
+            () => { },
+        );
+
+        const events = await collect(provider.stream({ model: 'm', messages: [] } as any));
+
+        expect(events.map((e) => e.contentDelta)).toEqual(['Hel', 'lo']);
+    });
+
+    it('should buffer the whole text for json_extract', async () => {
+        const provider = withTransforms(streamingProvider([
+            ...deltas('{"answer":', '{"text":"hi"}}'),
+            { type: 'done', finishReason: 'stop', usage: { promptTokens: 1, completionTokens: 4, totalTokens: 5 } },
+        ]), compileTransforms([{ type: 'json_extract', path: 'answer.text' }]), () => { });
+
+        const events = await collect(provider.stream({ model: 'm', messages: [] } as any));
+
+        expect(events.map((e) => e.contentDelta ?? e.type)).toEqual(['hi', 'done']);
+    });
+
+    it('should fail the config load on a bad regex', async () => {
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'support', frontdoor: 'openai', path: '/v1', transforms: [{ type: 'regex_replace', pattern: '[' }] }],
+                    providers: [],
+                }),
+            },
+            auth: { authenticate: async () => null, getTenant: async () => null },
+        });
+
+        await expect(gateway.reload()).rejects.toThrow(/^Invalid config for app 'support': transforms\[0\]\.pattern/);
+    });
+});
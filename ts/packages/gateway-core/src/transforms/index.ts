@@ -0,0 +1,15 @@
+/**
+ * Response transform exports.
+ *
+ * @module transforms
+ */
+
+export {
+    compileTransforms,
+    applyTransforms,
+    transformStream,
+    withTransforms,
+    TransformingProvider,
+    type ResponseTransform,
+    type TransformUnit,
+} from './response.js';
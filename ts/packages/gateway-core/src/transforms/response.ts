@@ -0,0 +1,419 @@
+/**
+ * Response post-processing transforms.
+ *
+ * An app's `transforms` list rewrites response text after the provider
+ * call and before the frontdoor encodes it, in order: regex replacement,
+ * truncation, markdown stripping, and JSON extraction. Streams stay
+ * incremental where the transforms allow it: truncation works on any
+ * chunk, regex replacement and markdown stripping on complete lines, and
+ * JSON extraction buffers the whole text block before emitting it.
+ *
+ * @module transforms/response
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Message,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { ResponseTransformConfig, ResponseTransformType } from '../ports/config.js';
+import type { TransformationStep } from '../recorder/interaction.js';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * How much text a transform needs to see at once: any chunk, complete
+ * lines, or the whole text block.
+ */
+export type TransformUnit = 'chunk' | 'line' | 'text';
+
+/**
+ * A compiled transform.
+ */
+export interface ResponseTransform {
+    /** Transform type. */
+    readonly type: ResponseTransformType;
+
+    /** Smallest piece of streamed text the transform can handle. */
+    readonly unit: TransformUnit;
+
+    /**
+     * Starts one response's text. The returned function is fed the text
+     * in order, in pieces no smaller than `unit`.
+     */
+    start(warn: (warning: string) => void): (text: string) => string;
+}
+
+/** Progression of units, from least to most buffering. */
+const UNIT_ORDER: TransformUnit[] = ['chunk', 'line', 'text'];
+
+// ============================================================================
+// Compilation
+// ============================================================================
+
+/**
+ * Compiles an app's transform list, throwing on the first invalid entry.
+ */
+export function compileTransforms(configs: ResponseTransformConfig[] | undefined): ResponseTransform[] {
+    return (configs ?? []).map((config, i) => compileTransform(config, `transforms[${i}]`));
+}
+
+function compileTransform(config: ResponseTransformConfig, field: string): ResponseTransform {
+    switch (config.type) {
+        case 'regex_replace': {
+            if (typeof config.pattern !== 'string' || config.pattern === '') {
+                throw new Error(`${field}.pattern is required`);
+            }
+            let pattern: RegExp;
+            try {
+                const flags = config.flags ?? '';
+                pattern = new RegExp(config.pattern, flags.includes('g') ? flags : `${flags}g`);
+            } catch (error) {
+                throw new Error(`${field}.pattern: ${(error as Error).message}`);
+            }
+            const replacement = config.replacement ?? '';
+            return {
+                type: config.type,
+                unit: 'line',
+                start: (warn) => {
+                    let warned = false;
+                    return (text) => {
+                        const replaced = text.replace(pattern, replacement);
+                        if (!warned && replaced !== text) {
+                            warned = true;
+                            warn('response text was rewritten by a gateway pattern');
+                        }
+                        return replaced;
+                    };
+                },
+            };
+        }
+
+        case 'truncate_chars': {
+            const maxChars = config.maxChars;
+            if (typeof maxChars !== 'number' || !Number.isInteger(maxChars) || maxChars < 1) {
+                throw new Error(`${field}.max_chars must be a positive integer`);
+            }
+            return {
+                type: config.type,
+                unit: 'chunk',
+                start: (warn) => {
+                    let remaining = maxChars;
+                    let warned = false;
+                    return (text) => {
+                        const chars = Array.from(text);
+                        const kept = chars.length <= remaining ? text : chars.slice(0, remaining).join('');
+                        if (!warned && kept !== text) {
+                            warned = true;
+                            warn(`response text truncated to ${maxChars} characters`);
+                        }
+                        remaining = Math.max(0, remaining - chars.length);
+                        return kept;
+                    };
+                },
+            };
+        }
+
+        case 'strip_markdown':
+            return {
+                type: config.type,
+                unit: 'line',
+                start: () => stripMarkdown,
+            };
+
+        case 'json_extract': {
+            if (typeof config.path !== 'string' || config.path === '') {
+                throw new Error(`${field}.path is required`);
+            }
+            const path = config.path.split('.');
+            return {
+                type: config.type,
+                unit: 'text',
+                start: (warn) => (text) => extractJSON(text, path, warn),
+            };
+        }
+
+        default:
+            throw new Error(`${field}: unknown type '${(config as { type: unknown }).type}'`);
+    }
+}
+
+// ============================================================================
+// Built-in Transforms
+// ============================================================================
+
+/** Code fence lines, dropped entirely. */
+const FENCE = /^\s*(```|~~~)/;
+
+/** Inline markdown, stripped to its text. Order matters: links before emphasis. */
+const INLINE_MARKDOWN: Array<[RegExp, string]> = [
+    [/^\s{0,3}#{1,6}\s+/, ''],
+    [/^\s*>\s?/, ''],
+    [/!?\[([^\]]*)\]\([^)]*\)/g, '$1'],
+    [/`([^`]+)`/g, '$1'],
+    [/(\*\*|__)(\S(?:.*?\S)?)\1/g, '$2'],
+    [/\*(\S(?:.*?\S)?)\*/g, '$1'],
+    [/(^|\W)_(\S(?:.*?\S)?)_(?=\W|$)/g, '$1$2'],
+];
+
+/**
+ * Strips markdown formatting line by line, keeping the text.
+ */
+function stripMarkdown(text: string): string {
+    return text
+        .split(/(?<=\n)/)
+        .filter((line) => !FENCE.test(line))
+        .map((line) => INLINE_MARKDOWN.reduce((out, [pattern, replacement]) => out.replace(pattern, replacement), line))
+        .join('');
+}
+
+/**
+ * Replaces JSON text with the value at a dotted path: strings as-is,
+ * anything else re-serialized. Text that isn't JSON, or lacks the path,
+ * is left unchanged with a warning.
+ */
+function extractJSON(text: string, path: string[], warn: (warning: string) => void): string {
+    let value: unknown;
+    try {
+        value = JSON.parse(unfence(text));
+    } catch {
+        warn('response is not JSON; left unchanged');
+        return text;
+    }
+
+    for (const key of path) {
+        if (value === null || typeof value !== 'object' || !Object.hasOwn(value, key)) {
+            warn(`path '${path.join('.')}' not found; left unchanged`);
+            return text;
+        }
+        value = (value as Record<string, unknown>)[key];
+    }
+    return typeof value === 'string' ? value : JSON.stringify(value);
+}
+
+/**
+ * Unwraps text that is a single fenced code block, as models often send JSON.
+ */
+function unfence(text: string): string {
+    const match = text.trim().match(/^(```|~~~)[^\n]*\n([\s\S]*?)\n?\1$/);
+    return match ? match[2]! : text;
+}
+
+// ============================================================================
+// Applying Transforms
+// ============================================================================
+
+/**
+ * One response's pass through a transform list, with each transform's
+ * before and after lengths.
+ */
+class TransformRun {
+    private readonly transforms: ResponseTransform[];
+    private readonly totals: Array<{ before: number; after: number; applied: boolean; warnings: string[] }>;
+
+    constructor(transforms: ResponseTransform[]) {
+        this.transforms = transforms;
+        this.totals = transforms.map(() => ({ before: 0, after: 0, applied: false, warnings: [] }));
+    }
+
+    /**
+     * Starts a chain for one choice; its state (e.g., characters left
+     * before truncation) carries across the pieces it is fed.
+     */
+    chain(): (text: string) => string {
+        const steps = this.transforms.map((t, i) => t.start((w) => this.totals[i]!.warnings.push(w)));
+        return (text) => steps.reduce((input, step, i) => {
+            const output = step(input);
+            const totals = this.totals[i]!;
+            totals.before += Array.from(input).length;
+            totals.after += Array.from(output).length;
+            totals.applied = true;
+            return output;
+        }, text);
+    }
+
+    /**
+     * Describes each transform that saw text, for interaction recording.
+     */
+    steps(): TransformationStep[] {
+        const steps: TransformationStep[] = [];
+        this.transforms.forEach((t, i) => {
+            const totals = this.totals[i]!;
+            if (!totals.applied) return;
+            steps.push({
+                stage: 'response_transform',
+                timestamp: new Date(),
+                description: `Applied ${t.type} to the response text`,
+                details: { index: i, type: t.type, beforeLength: totals.before, afterLength: totals.after },
+                warnings: totals.warnings.length > 0 ? totals.warnings : undefined,
+            });
+        });
+        return steps;
+    }
+}
+
+/**
+ * Applies transforms to a complete response's message text.
+ */
+export function applyTransforms(
+    response: CanonicalResponse,
+    transforms: ResponseTransform[],
+): { response: CanonicalResponse; steps: TransformationStep[] } {
+    const run = new TransformRun(transforms);
+    const choices = response.choices.map((choice) => {
+        if (!choice.message.content) {
+            return choice;
+        }
+        return { ...choice, message: withText(choice.message, run.chain()(choice.message.content)) };
+    });
+    return { response: { ...response, choices }, steps: run.steps() };
+}
+
+/**
+ * Sets a message's text, collapsing rich text parts into the first one so
+ * encoders that prefer them serve the transformed text too.
+ */
+function withText(message: Message, content: string): Message {
+    const parts = message.richContent?.parts;
+    if (!parts?.some((p) => p.type === 'text')) {
+        return { ...message, content };
+    }
+    let placed = false;
+    const rewritten = parts.flatMap((p) => {
+        if (p.type !== 'text') return [p];
+        if (placed) return [];
+        placed = true;
+        return [{ ...p, text: content }];
+    });
+    return { ...message, content, richContent: { ...message.richContent, parts: rewritten } };
+}
+
+/**
+ * Applies transforms to a stream's content deltas. Text is held back until
+ * a piece the strictest transform can handle is complete, and flushed at
+ * the first non-content event so it always precedes finish and usage events.
+ */
+export async function* transformStream(
+    events: AsyncIterable<CanonicalEvent>,
+    transforms: ResponseTransform[],
+    onSteps: (steps: TransformationStep[]) => void,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const unit = transforms.reduce<TransformUnit>(
+        (widest, t) => UNIT_ORDER.indexOf(t.unit) > UNIT_ORDER.indexOf(widest) ? t.unit : widest,
+        'chunk',
+    );
+    const run = new TransformRun(transforms);
+    const choices = new Map<number, { chain: (text: string) => string; pending: string; last: CanonicalEvent }>();
+
+    const ready = (pending: string, final: boolean): number => {
+        if (final || unit === 'chunk') return pending.length;
+        if (unit === 'line') return pending.lastIndexOf('\n') + 1;
+        return 0;
+    };
+
+    function* flush(): Generator<CanonicalEvent> {
+        for (const choice of choices.values()) {
+            if (choice.pending === '') continue;
+            const output = choice.chain(choice.pending);
+            choice.pending = '';
+            if (output !== '') {
+                yield {
+                    ...choice.last,
+                    contentDelta: output,
+                    finishReason: undefined,
+                    usage: undefined,
+                    toolCall: undefined,
+                };
+            }
+        }
+    }
+
+    try {
+        for await (const event of events) {
+            if (!event.contentDelta) {
+                yield* flush();
+                yield event;
+                continue;
+            }
+
+            const index = event.choiceIndex ?? 0;
+            let choice = choices.get(index);
+            if (!choice) {
+                choice = { chain: run.chain(), pending: '', last: event };
+                choices.set(index, choice);
+            }
+            choice.last = event;
+            choice.pending += event.contentDelta;
+
+            const final = event.finishReason !== undefined || event.usage !== undefined;
+            const cut = ready(choice.pending, final);
+            const output = cut > 0 ? choice.chain(choice.pending.slice(0, cut)) : '';
+            choice.pending = choice.pending.slice(cut);
+
+            if (output !== '' || final || event.toolCall) {
+                yield { ...event, contentDelta: output || undefined };
+            }
+        }
+        yield* flush();
+    } finally {
+        onSteps(run.steps());
+    }
+}
+
+// ============================================================================
+// Transforming Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so its responses pass through an app's transforms.
+ */
+export class TransformingProvider extends ProviderWrapper {
+    private readonly transforms: ResponseTransform[];
+    private readonly onSteps: (steps: TransformationStep[]) => void;
+
+    constructor(
+        inner: Provider,
+        transforms: ResponseTransform[],
+        onSteps: (steps: TransformationStep[]) => void,
+    ) {
+        super(inner);
+        this.transforms = transforms;
+        this.onSteps = onSteps;
+    }
+
+    /**
+     * Completes a request and transforms the response text.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const { response, steps } = applyTransforms(await this.inner.complete(request, options), this.transforms);
+        this.onSteps(steps);
+        return response;
+    }
+
+    /**
+     * Streams a request, transforming content deltas.
+     */
+    stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        return transformStream(this.inner.stream(request, options), this.transforms, this.onSteps);
+    }
+}
+
+/**
+ * Applies transforms to a provider's responses. Returns the provider
+ * unchanged when there are none.
+ */
+export function withTransforms(
+    provider: Provider,
+    transforms: ResponseTransform[] | undefined,
+    onSteps: (steps: TransformationStep[]) => void,
+): Provider {
+    if (!transforms || transforms.length === 0) {
+        return provider;
+    }
+    return new TransformingProvider(provider, transforms, onSteps);
+}
@@ -0,0 +1,133 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const chat = { messages: [{ role: 'user', content: 'Tell me a story' }] };
+const messages = { max_tokens: 16, messages: [{ role: 'user', content: 'Tell me a story' }] };
+const responses = { input: 'Tell me a story', max_output_tokens: 16 };
+const cohere = { message: 'Tell me a story', max_tokens: 16 };
+
+/** Parses an SSE body's data frames, skipping [DONE]. */
+function sseData(body: string): any[] {
+    return body.split('\n\n')
+        .map((frame) => frame.split('\n').find((line) => line.startsWith('data: '))?.slice('data: '.length))
+        .filter((data): data is string => data !== undefined && data !== '[DONE]')
+        .map((data) => JSON.parse(data));
+}
+
+function warningCodes(response: Response): string[] {
+    return JSON.parse(response.headers.get('X-Gateway-Warnings') ?? '[]').map((w: { code: string }) => w.code);
+}
+
+describe('Length-stopped responses', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/v1/messages' })
+            .app({ name: 'resp', frontdoor: 'responses', path: '/v1/responses' })
+            .app({ name: 'cohere', frontdoor: 'cohere', path: '/cohere' })
+            .provider(new ScriptedProvider('mock', {
+                text: 'Once upon a',
+                finishReason: 'length',
+                usage: { promptTokens: 10, completionTokens: 16, totalTokens: 26 },
+            }))
+            .start();
+        const gw = gateway;
+        const send = (path: string, body: object, stream = false) => gw.post(path, { model: 'gpt-4o', stream, ...body });
+        const admin = new AdminHandler({
+            auth: {
+                authenticate: async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }),
+                getTenant: async () => null,
+            },
+            metadataIndex: gw.gateway.metadataIndex,
+        });
+        const list = async (query: string) => (await admin.handle(new Request(
+            `http://localhost/api/interactions?${query}`,
+            { headers: { Authorization: 'Bearer acme' } },
+        ))).json();
+        return { send, list };
+    }
+
+    it('should keep finish_reason length on chat completions and warn', async () => {
+        const { send } = await setup();
+
+        const response = await send('/v1/chat/completions', chat);
+        expect((await response.json()).choices[0].finish_reason).toBe('length');
+        expect(warningCodes(response)).toEqual(['max_tokens_reached']);
+
+        const events = sseData(await (await send('/v1/chat/completions', chat, true)).text());
+        expect(events.some((e) => e.choices?.[0]?.finish_reason === 'length')).toBe(true);
+        expect(events[events.length - 1]).toEqual([expect.objectContaining({ code: 'max_tokens_reached' })]);
+    });
+
+    it('should send stop_reason max_tokens on Anthropic messages, streamed in message_delta', async () => {
+        const { send } = await setup();
+
+        const response = await send('/v1/messages', messages);
+        expect((await response.json()).stop_reason).toBe('max_tokens');
+        expect(warningCodes(response)).toEqual(['max_tokens_reached']);
+
+        const body = await (await send('/v1/messages', messages, true)).text();
+        expect(body).toContain('event: message_delta\ndata: ');
+        const delta = sseData(body).find((e) => e.type === 'message_delta');
+        expect(delta.delta.stop_reason).toBe('max_tokens');
+        expect(body.indexOf('event: message_delta')).toBeLessThan(body.indexOf('event: message_stop'));
+    });
+
+    it('should mark Responses API responses incomplete for max_output_tokens', async () => {
+        const { send } = await setup();
+
+        const response = await send('/v1/responses', responses);
+        const json = await response.json();
+        expect(json.status).toBe('incomplete');
+        expect(json.incompleteDetails).toEqual({ reason: 'max_output_tokens' });
+        expect(json.output[0].status).toBe('incomplete');
+        expect(warningCodes(response)).toEqual(['max_tokens_reached']);
+
+        const body = await (await send('/v1/responses', responses, true)).text();
+        expect(body).not.toContain('event: response.completed');
+        const terminal = sseData(body).find((e) => e.type === 'response.incomplete');
+        expect(terminal.response).toMatchObject({
+            status: 'incomplete',
+            incomplete_details: { reason: 'max_output_tokens' },
+            output: [{ type: 'message', status: 'incomplete' }],
+        });
+        expect(body).toContain('data: [DONE]');
+    });
+
+    it('should send finish_reason MAX_TOKENS on Cohere chat', async () => {
+        const { send } = await setup();
+
+        const response = await send('/cohere/v1/chat', cohere);
+        expect((await response.json()).finish_reason).toBe('MAX_TOKENS');
+        expect(warningCodes(response)).toEqual(['max_tokens_reached']);
+
+        const lines = (await (await send('/cohere/v1/chat', cohere, true)).text()).trim().split('\n').map((l) => JSON.parse(l));
+        expect(lines[lines.length - 1]).toMatchObject({ event_type: 'stream-end', finish_reason: 'MAX_TOKENS' });
+    });
+
+    it('should find length-stopped interactions per app and model in the admin API', async () => {
+        const { send, list } = await setup();
+
+        await (await send('/v1/chat/completions', chat)).text();
+        await (await send('/v1/chat/completions', chat, true)).text();
+        await (await send('/v1/messages', messages)).text();
+
+        await vi.waitFor(async () => expect((await list('finish_reason=length')).total).toBe(3));
+        const found = await list('finish_reason=length');
+        expect(found.interactions[0]).toMatchObject({ type: 'request', model: 'gpt-4o' });
+        expect(found.breakdown).toEqual([
+            { app: 'chat', model: 'gpt-4o', count: 2 },
+            { app: 'claude', model: 'gpt-4o', count: 1 },
+        ]);
+        expect((await list('finish_reason=length&app=claude')).total).toBe(1);
+        expect((await list('finish_reason=length&model=gpt-4o-mini')).total).toBe(0);
+    });
+});
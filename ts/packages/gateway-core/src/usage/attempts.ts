@@ -0,0 +1,111 @@
+/**
+ * In-memory interaction attempt store.
+ *
+ * @module usage/attempts
+ */
+
+import type {
+    AttemptStore,
+    AttemptUsageRow,
+    InteractionAttemptRecord,
+    StorageProvider,
+} from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Attempt Store
+// ============================================================================
+
+/** Default number of interactions the memory store keeps attempts for. */
+export const DEFAULT_ATTEMPTS_SIZE = 50_000;
+
+/**
+ * Process-local attempts of the most recent interactions.
+ * Used when the configured storage provider does not implement AttemptStore.
+ */
+export class MemoryAttemptStore implements AttemptStore {
+    /** Keyed by interaction ID, oldest first. */
+    private readonly records = new Map<string, InteractionAttemptRecord[]>();
+    private readonly maxEntries: number;
+
+    constructor(options: { maxEntries?: number | undefined } = {}) {
+        this.maxEntries = options.maxEntries ?? DEFAULT_ATTEMPTS_SIZE;
+    }
+
+    async saveAttempts(records: InteractionAttemptRecord[]): Promise<void> {
+        const interactionId = records[0]?.interactionId;
+        if (interactionId === undefined) return;
+        this.records.delete(interactionId);
+        this.records.set(interactionId, records.map((r) => ({ ...r })));
+        for (const id of this.records.keys()) {
+            if (this.records.size <= this.maxEntries) break;
+            this.records.delete(id);
+        }
+    }
+
+    async listAttempts(interactionId: string, tenantId: string): Promise<InteractionAttemptRecord[]> {
+        return (this.records.get(interactionId) ?? [])
+            .filter((r) => tenantId === UNSCOPED_TENANT || r.tenantId === tenantId)
+            .sort((a, b) => a.attempt - b.attempt)
+            .map((r) => ({ ...r }));
+    }
+
+    async aggregateAttempts(tenantId: string, from: Date, to: Date): Promise<AttemptUsageRow[]> {
+        return aggregateAttemptRecords([...this.records.values()].flat().filter((r) =>
+            r.tenantId === tenantId && r.createdAt >= from && r.createdAt < to));
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Groups attempts by UTC day, model, and reason, ordered by day, model,
+ * then reason.
+ */
+export function aggregateAttemptRecords(records: Iterable<InteractionAttemptRecord>): AttemptUsageRow[] {
+    const groups = new Map<string, AttemptUsageRow>();
+    for (const record of records) {
+        const day = record.createdAt.toISOString().slice(0, 10);
+        const key = `${day}\u0000${record.model}\u0000${record.reason}`;
+        let row = groups.get(key);
+        if (!row) {
+            row = {
+                day,
+                model: record.model,
+                reason: record.reason,
+                attempts: 0,
+                errors: 0,
+                promptTokens: 0,
+                completionTokens: 0,
+                totalTokens: 0,
+                costUsd: 0,
+            };
+            groups.set(key, row);
+        }
+        row.attempts++;
+        row.errors += record.outcome === 'error' ? 1 : 0;
+        row.promptTokens += record.usage?.promptTokens ?? 0;
+        row.completionTokens += record.usage?.completionTokens ?? 0;
+        row.totalTokens += record.usage?.totalTokens ?? 0;
+        row.costUsd += record.costUsd ?? 0;
+    }
+
+    return [...groups.values()].sort((a, b) =>
+        a.day.localeCompare(b.day) || a.model.localeCompare(b.model) || a.reason.localeCompare(b.reason));
+}
+
+/**
+ * Type guard to check if a storage provider implements AttemptStore.
+ */
+export function isAttemptStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & AttemptStore {
+    return (
+        storage !== undefined &&
+        typeof storage.saveAttempts === 'function' &&
+        typeof storage.listAttempts === 'function' &&
+        typeof storage.aggregateAttempts === 'function'
+    );
+}
@@ -0,0 +1,45 @@
+/**
+ * Usage report exports.
+ *
+ * @module usage
+ */
+
+export {
+    UsageReports,
+    USAGE_REPORT_PATH,
+    USAGE_REPORT_SCHEMA_VERSION,
+    DEFAULT_USAGE_REPORT_DAYS,
+    MAX_USAGE_REPORT_DAYS,
+    DEFAULT_USAGE_REPORT_RATE,
+    type UsageReport,
+    type UsageReportBucket,
+    type UsageReportTotals,
+    type UsageReportRange,
+    type UsageReportRate,
+    type UsageReportGrouping,
+    type UsageReportsOptions,
+} from './report.js';
+
+export {
+    MemoryUsageStatsStore,
+    DEFAULT_USAGE_STATS_SIZE,
+    aggregateRequestStats,
+    matchesClassification,
+    isUsageStatsStore,
+} from './store.js';
+
+export {
+    MemoryAttemptStore,
+    DEFAULT_ATTEMPTS_SIZE,
+    aggregateAttemptRecords,
+    isAttemptStore,
+} from './attempts.js';
+
+export {
+    USAGE_TRAILER_EVENT,
+    NO_TRAILER_HEADER,
+    buildUsageTrailer,
+    appendUsageTrailer,
+    isEventStream,
+    type UsageTrailer,
+} from './trailer.js';
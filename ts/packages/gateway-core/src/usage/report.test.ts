@@ -0,0 +1,121 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { AdminHandler } from '../admin/index';
+import { errServer } from '../domain/errors';
+import { MemoryUsageStatsStore, UsageReports } from './index';
+import { harness, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+const usage = { promptTokens: 10, completionTokens: 5, totalTokens: 15 };
+
+describe('GET /v1/usage', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const auth = {
+            authenticate: async (token: string) => ({ tenantId: token, scopes: [], metadata: {} }),
+            getTenant: async () => null,
+        };
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(new ScriptedProvider('mock', (request) => request.model === 'broken'
+                ? { error: errServer('upstream exploded') }
+                : { text: 'ok', usage }))
+            .options({ auth })
+            .start();
+        const gw = gateway;
+        const chat = (tenant: string, model: string) => gw.post(
+            '/v1/chat/completions',
+            { model, messages: [{ role: 'user', content: 'Hi' }] },
+            { Authorization: `Bearer ${tenant}` },
+        );
+        const report = (tenant: string, query = '') =>
+            gw.request(`/v1/usage${query}`, { headers: { Authorization: `Bearer ${tenant}` } });
+        return { gateway: gw.gateway, auth, chat, report };
+    }
+
+    it('should report only the calling tenant usage by model and day', async () => {
+        const { chat, report } = await setup();
+        await chat('acme', 'gpt-4o');
+        await chat('acme', 'gpt-4o');
+        await chat('acme', 'broken');
+        await chat('globex', 'gpt-4o');
+
+        const response = await report('acme');
+        expect(response.status).toBe(200);
+        const body = await response.json();
+        const today = new Date().toISOString().slice(0, 10);
+
+        expect(body).toMatchObject({ object: 'usage.report', schema_version: 1, end_date: today });
+        expect(body.data).toEqual([
+            expect.objectContaining({ object: 'usage.bucket', date: today, model: 'broken', requests: 1, errors: 1, total_tokens: 0 }),
+            expect.objectContaining({ date: today, model: 'gpt-4o', requests: 2, errors: 0, prompt_tokens: 20, total_tokens: 30 }),
+        ]);
+        expect(body.data[1].p95_latency_ms).toEqual(expect.any(Number));
+        expect(body.totals).toEqual({ requests: 3, errors: 1, prompt_tokens: 20, completion_tokens: 10, total_tokens: 30 });
+    });
+
+    it('should never widen the report to another tenant', async () => {
+        const { auth, gateway, chat, report } = await setup();
+        await chat('globex', 'gpt-4o');
+        await chat('globex', 'gpt-4o');
+
+        for (const query of ['?tenant_id=globex', '?tenant=globex&tenantId=globex', '?tenant_id=&scope=all', '?tenant_id=%00']) {
+            const body = await (await report('acme', query)).json();
+            expect(body.data).toEqual([]);
+            expect(body.totals.requests).toBe(0);
+        }
+
+        // The admin route only lets a tenant read its own report too
+        const admin = new AdminHandler({ auth, usage: gateway.usageReports });
+        const denied = await admin.handle(new Request('http://localhost/api/tenants/globex/usage', {
+            headers: { Authorization: 'Bearer acme' },
+        }));
+        expect(denied.status).toBe(404);
+        expect((await (await report('globex')).json()).totals.requests).toBe(2);
+    });
+
+    it('should reject invalid ranges', async () => {
+        const { report } = await setup();
+
+        expect((await report('acme', '?start_date=yesterday')).status).toBe(400);
+        expect((await report('acme', '?start_date=2025-02-01&end_date=2025-01-01')).status).toBe(400);
+        expect((await report('acme', '?start_date=2025-01-01&end_date=2025-06-01')).status).toBe(400);
+    });
+
+    it('should rate limit reports per tenant', async () => {
+        const { report } = await setup();
+
+        for (let i = 0; i < 10; i++) {
+            expect((await report('acme')).status).toBe(200);
+        }
+        const limited = await report('acme');
+
+        expect(limited.status).toBe(429);
+        expect(Number(limited.headers.get('Retry-After'))).toBeGreaterThan(0);
+        expect((await report('globex')).status).toBe(200);
+    });
+});
+
+describe('UsageReports', () => {
+    it('should compute nearest-rank p95 latency per day and model', async () => {
+        const store = new MemoryUsageStatsStore();
+        const reports = new UsageReports({ store, now: () => Date.parse('2025-01-02T12:00:00Z') });
+        for (let latencyMs = 1; latencyMs <= 20; latencyMs++) {
+            await store.recordRequestStat({
+                tenantId: 'acme', interactionId: `i${latencyMs}`, model: 'gpt-4o',
+                promptTokens: 1, completionTokens: 1, totalTokens: 2, latencyMs, error: false,
+                createdAt: new Date('2025-01-02T08:00:00Z'),
+            });
+        }
+
+        const report = await reports.report('acme', reports.parseRange(new URLSearchParams()));
+
+        expect(report.start_date).toBe('2024-12-27');
+        expect(report.data).toHaveLength(1);
+        expect(report.data[0]).toMatchObject({ date: '2025-01-02', requests: 20, p95_latency_ms: 19 });
+    });
+});
@@ -0,0 +1,382 @@
+/**
+ * Tenant usage reports.
+ *
+ * Every request's outcome (tokens, latency, and whether it failed) is
+ * recorded per tenant. Tenants read their own aggregates from GET /v1/usage
+ * with their normal API key; operators read any tenant's from the admin
+ * API. Both go through the same storage aggregation, and a report is only
+ * ever computed for one tenant, so the data-plane endpoint has no way to
+ * reach another tenant's numbers. Reports contain aggregates only, never
+ * individual requests.
+ *
+ * With group_by=reason, buckets count provider attempts instead of client
+ * requests, split by why each call was made (primary, retry, failover,
+ * hedge, tool_loop_iteration), with their estimated cost, so the extra
+ * spend from failover, hedging, and tool loops can be seen.
+ *
+ * With group_by=end_user, buckets are split by the salted hash of the
+ * end-user ID clients send (OpenAI `user`, Anthropic `metadata.user_id`);
+ * requests without one share a bucket with a null end_user.
+ *
+ * With group_by=language, buckets are split by the detected language of
+ * the response, for apps that classify responses, and count the requests
+ * the safety classifier flagged; unclassified requests share a bucket with
+ * a null language.
+ *
+ * @module usage/report
+ */
+
+import type {
+    AttemptStore,
+    AttemptUsageRow,
+    RequestStatClassificationFilter,
+    RequestStatRecord,
+    UsageStatsRow,
+    UsageStatsStore,
+} from '../ports/storage.js';
+import type { AttemptReason } from '../ports/provider.js';
+import type { Logger } from '../utils/logging.js';
+import { errInvalidRequest } from '../domain/errors.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Data-plane path of the tenant usage report. */
+export const USAGE_REPORT_PATH = '/v1/usage';
+
+/** Version of the report format; bumped on incompatible changes. */
+export const USAGE_REPORT_SCHEMA_VERSION = 1;
+
+/** Days covered when no range is given (ending today). */
+export const DEFAULT_USAGE_REPORT_DAYS = 7;
+
+/** Longest range a report may cover. */
+export const MAX_USAGE_REPORT_DAYS = 90;
+
+/** Default report rate per tenant: reports are aggregations, so they're limited. */
+export const DEFAULT_USAGE_REPORT_RATE: UsageReportRate = { limit: 10, windowMs: 60_000 };
+
+const DAY_MS = 86_400_000;
+const DATE_PATTERN = /^\d{4}-\d{2}-\d{2}$/;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Per-tenant report rate limit.
+ */
+export interface UsageReportRate {
+    /** Reports allowed per window. */
+    limit: number;
+
+    /** Window length in ms. */
+    windowMs: number;
+}
+
+/**
+ * How report buckets are split: by model, or by model and attempt reason,
+ * end user, or response language.
+ */
+export type UsageReportGrouping = 'model' | 'reason' | 'end_user' | 'language';
+
+/**
+ * Inclusive range of UTC days.
+ */
+export interface UsageReportRange {
+    /** First day (YYYY-MM-DD). */
+    start: string;
+
+    /** Last day (YYYY-MM-DD). */
+    end: string;
+}
+
+/** Request counts and tokens, as reported. Grouped by reason, requests count attempts and cost is added. */
+export interface UsageReportTotals {
+    requests: number;
+    errors: number;
+    prompt_tokens: number;
+    completion_tokens: number;
+    total_tokens: number;
+    cost_usd?: number | undefined;
+}
+
+/**
+ * One model's usage on one day (and for one attempt reason, end user, or
+ * response language, when grouped by them), as reported.
+ */
+export interface UsageReportBucket extends UsageReportTotals {
+    object: 'usage.bucket';
+    date: string;
+    model: string;
+    reason?: AttemptReason | undefined;
+    end_user?: string | null | undefined;
+    language?: string | null | undefined;
+    safety_flagged?: number | undefined;
+    p95_latency_ms: number | null;
+}
+
+/**
+ * Usage report response body.
+ */
+export interface UsageReport {
+    object: 'usage.report';
+    schema_version: number;
+    start_date: string;
+    end_date: string;
+    group_by: UsageReportGrouping;
+    data: UsageReportBucket[];
+    totals: UsageReportTotals;
+}
+
+/**
+ * Usage report options.
+ */
+export interface UsageReportsOptions {
+    /** Request stats storage. */
+    store: UsageStatsStore;
+
+    /** Attempt storage, for reports grouped by reason (optional). */
+    attempts?: Pick<AttemptStore, 'aggregateAttempts'> | undefined;
+
+    /** Logger for failed writes. */
+    logger?: Logger | undefined;
+
+    /** Per-tenant report rate limit (default: 10 per minute). */
+    rate?: UsageReportRate | undefined;
+
+    /** Time source (for testing). */
+    now?: (() => number) | undefined;
+}
+
+// ============================================================================
+// Usage Reports
+// ============================================================================
+
+/**
+ * Records request stats and builds tenant usage reports.
+ */
+export class UsageReports {
+    private readonly store: UsageStatsStore;
+    private readonly attempts?: Pick<AttemptStore, 'aggregateAttempts'> | undefined;
+    private readonly logger?: Logger | undefined;
+    private readonly rate: UsageReportRate;
+    private readonly now: () => number;
+    private readonly windows = new Map<string, { start: number; count: number }>();
+
+    constructor(options: UsageReportsOptions) {
+        this.store = options.store;
+        this.attempts = options.attempts;
+        this.logger = options.logger;
+        this.rate = options.rate ?? DEFAULT_USAGE_REPORT_RATE;
+        this.now = options.now ?? Date.now;
+    }
+
+    /**
+     * Records a request's outcome. Never throws or waits on the write.
+     */
+    record(record: RequestStatRecord): void {
+        this.store.recordRequestStat(record).catch((error: unknown) => {
+            this.logger?.error('request_stat_record_failed', {
+                tenantId: record.tenantId,
+                interactionId: record.interactionId,
+                error: error instanceof Error ? error.message : String(error),
+            });
+        });
+    }
+
+    /**
+     * Counts a report against the tenant's rate limit. Returns 0 when the
+     * report may run, or the seconds until the window resets.
+     */
+    throttle(tenantId: string): number {
+        const now = this.now();
+        let window = this.windows.get(tenantId);
+        if (!window || now - window.start >= this.rate.windowMs) {
+            this.prune(now);
+            window = { start: now, count: 0 };
+            this.windows.set(tenantId, window);
+        }
+        if (window.count >= this.rate.limit) {
+            return Math.max(1, Math.ceil((window.start + this.rate.windowMs - now) / 1000));
+        }
+        window.count++;
+        return 0;
+    }
+
+    /**
+     * Builds one tenant's report over an inclusive range of UTC days.
+     */
+    async report(tenantId: string, range: UsageReportRange, groupBy: UsageReportGrouping = 'model'): Promise<UsageReport> {
+        const from = new Date(`${range.start}T00:00:00.000Z`);
+        const to = new Date(Date.parse(`${range.end}T00:00:00.000Z`) + DAY_MS);
+        if (groupBy === 'reason') {
+            if (!this.attempts) {
+                throw errInvalidRequest('group_by=reason is not available').withParam('group_by');
+            }
+            return formatAttemptReport(await this.attempts.aggregateAttempts(tenantId, from, to), range);
+        }
+        if (groupBy === 'end_user') {
+            return formatReport(await this.store.aggregateUsageStats(tenantId, from, to, { byEndUser: true }), range, groupBy);
+        }
+        if (groupBy === 'language') {
+            return formatReport(await this.store.aggregateUsageStats(tenantId, from, to, { byLanguage: true }), range, groupBy);
+        }
+        return formatReport(await this.store.aggregateUsageStats(tenantId, from, to), range);
+    }
+
+    /**
+     * Finds the tenant's request stats for an end-user hash, newest first,
+     * or undefined when the store can't look them up.
+     */
+    async findByEndUser(tenantId: string, endUser: string): Promise<RequestStatRecord[] | undefined> {
+        return this.store.findRequestStatsByEndUser?.(tenantId, endUser);
+    }
+
+    /**
+     * Finds the tenant's request stats matching a classification filter,
+     * newest first, or undefined when the store can't look them up.
+     */
+    async findByClassification(
+        tenantId: string,
+        filter: RequestStatClassificationFilter,
+    ): Promise<RequestStatRecord[] | undefined> {
+        return this.store.findRequestStatsByClassification?.(tenantId, filter);
+    }
+
+    /**
+     * Parses the group_by query parameter (default: model).
+     */
+    parseGrouping(params: URLSearchParams): UsageReportGrouping {
+        const groupBy = params.get('group_by') ?? 'model';
+        if (groupBy !== 'model' && groupBy !== 'reason' && groupBy !== 'end_user' && groupBy !== 'language') {
+            throw errInvalidRequest("group_by must be 'model', 'reason', 'end_user', or 'language'").withParam('group_by');
+        }
+        return groupBy;
+    }
+
+    /**
+     * Parses a report range from start_date/end_date query parameters
+     * (inclusive UTC days). Defaults to the last 7 days, today included.
+     */
+    parseRange(params: URLSearchParams): UsageReportRange {
+        const today = new Date(this.now()).toISOString().slice(0, 10);
+        const end = params.get('end_date') ?? today;
+        const start = params.get('start_date')
+            ?? new Date(Date.parse(`${end}T00:00:00.000Z`) - (DEFAULT_USAGE_REPORT_DAYS - 1) * DAY_MS).toISOString().slice(0, 10);
+
+        for (const [name, value] of [['start_date', start], ['end_date', end]] as const) {
+            if (!DATE_PATTERN.test(value) || Number.isNaN(Date.parse(`${value}T00:00:00.000Z`))) {
+                throw errInvalidRequest(`${name} must be a date (YYYY-MM-DD)`).withParam(name);
+            }
+        }
+        const days = (Date.parse(end) - Date.parse(start)) / DAY_MS + 1;
+        if (days < 1) {
+            throw errInvalidRequest('start_date must not be after end_date').withParam('start_date');
+        }
+        if (days > MAX_USAGE_REPORT_DAYS) {
+            throw errInvalidRequest(`range must not exceed ${MAX_USAGE_REPORT_DAYS} days`).withParam('start_date');
+        }
+        return { start, end };
+    }
+
+    /**
+     * Drops rate windows that have ended.
+     */
+    private prune(now: number): void {
+        for (const [tenantId, window] of this.windows) {
+            if (now - window.start >= this.rate.windowMs) {
+                this.windows.delete(tenantId);
+            }
+        }
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Shapes aggregated rows as a versioned report.
+ */
+function formatReport(
+    rows: UsageStatsRow[],
+    range: UsageReportRange,
+    groupBy: Exclude<UsageReportGrouping, 'reason'> = 'model',
+): UsageReport {
+    const totals: UsageReportTotals = { requests: 0, errors: 0, prompt_tokens: 0, completion_tokens: 0, total_tokens: 0 };
+    const data = rows.map((row): UsageReportBucket => {
+        totals.requests += row.requests;
+        totals.errors += row.errors;
+        totals.prompt_tokens += row.promptTokens;
+        totals.completion_tokens += row.completionTokens;
+        totals.total_tokens += row.totalTokens;
+        return {
+            object: 'usage.bucket',
+            date: row.day,
+            model: row.model,
+            ...(groupBy === 'end_user' && { end_user: row.endUser ?? null }),
+            ...(groupBy === 'language' && { language: row.language ?? null, safety_flagged: row.safetyFlagged ?? 0 }),
+            requests: row.requests,
+            errors: row.errors,
+            prompt_tokens: row.promptTokens,
+            completion_tokens: row.completionTokens,
+            total_tokens: row.totalTokens,
+            p95_latency_ms: row.p95LatencyMs ?? null,
+        };
+    });
+    return {
+        object: 'usage.report',
+        schema_version: USAGE_REPORT_SCHEMA_VERSION,
+        start_date: range.start,
+        end_date: range.end,
+        group_by: groupBy,
+        data,
+        totals,
+    };
+}
+
+/**
+ * Shapes aggregated attempts as a versioned report grouped by reason.
+ */
+function formatAttemptReport(rows: AttemptUsageRow[], range: UsageReportRange): UsageReport {
+    const totals: UsageReportTotals = {
+        requests: 0,
+        errors: 0,
+        prompt_tokens: 0,
+        completion_tokens: 0,
+        total_tokens: 0,
+        cost_usd: 0,
+    };
+    const data = rows.map((row): UsageReportBucket => {
+        totals.requests += row.attempts;
+        totals.errors += row.errors;
+        totals.prompt_tokens += row.promptTokens;
+        totals.completion_tokens += row.completionTokens;
+        totals.total_tokens += row.totalTokens;
+        totals.cost_usd! += row.costUsd;
+        return {
+            object: 'usage.bucket',
+            date: row.day,
+            model: row.model,
+            reason: row.reason,
+            requests: row.attempts,
+            errors: row.errors,
+            prompt_tokens: row.promptTokens,
+            completion_tokens: row.completionTokens,
+            total_tokens: row.totalTokens,
+            cost_usd: row.costUsd,
+            p95_latency_ms: null,
+        };
+    });
+    return {
+        object: 'usage.report',
+        schema_version: USAGE_REPORT_SCHEMA_VERSION,
+        start_date: range.start,
+        end_date: range.end,
+        group_by: 'reason',
+        data,
+        totals,
+    };
+}
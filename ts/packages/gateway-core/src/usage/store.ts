@@ -0,0 +1,160 @@
+/**
+ * In-memory usage stats store.
+ *
+ * @module usage/store
+ */
+
+import type {
+    RequestStatClassificationFilter,
+    RequestStatRecord,
+    StorageProvider,
+    UsageStatsRow,
+    UsageStatsStore,
+} from '../ports/storage.js';
+import { UNSCOPED_TENANT } from '../ports/storage.js';
+
+// ============================================================================
+// Memory Usage Stats Store
+// ============================================================================
+
+/** Default number of request stats the memory store keeps. */
+export const DEFAULT_USAGE_STATS_SIZE = 100_000;
+
+/**
+ * Process-local usage stats store, holding the most recent requests.
+ * Used when the configured storage provider does not implement UsageStatsStore.
+ */
+export class MemoryUsageStatsStore implements UsageStatsStore {
+    /** Keyed by interaction ID, oldest first. */
+    private readonly records = new Map<string, RequestStatRecord>();
+    private readonly maxEntries: number;
+
+    constructor(options: { maxEntries?: number | undefined } = {}) {
+        this.maxEntries = options.maxEntries ?? DEFAULT_USAGE_STATS_SIZE;
+    }
+
+    async recordRequestStat(record: RequestStatRecord): Promise<void> {
+        this.records.set(record.interactionId, { ...record });
+        for (const id of this.records.keys()) {
+            if (this.records.size <= this.maxEntries) break;
+            this.records.delete(id);
+        }
+    }
+
+    async aggregateUsageStats(
+        tenantId: string,
+        from: Date,
+        to: Date,
+        options: { byEndUser?: boolean | undefined; byLanguage?: boolean | undefined } = {},
+    ): Promise<UsageStatsRow[]> {
+        return aggregateRequestStats([...this.records.values()].filter((r) =>
+            r.tenantId === tenantId && r.createdAt >= from && r.createdAt < to), options);
+    }
+
+    async findRequestStatsByEndUser(tenantId: string, endUser: string): Promise<RequestStatRecord[]> {
+        return [...this.records.values()]
+            .filter((r) => r.endUser === endUser && (tenantId === UNSCOPED_TENANT || r.tenantId === tenantId))
+            .sort((a, b) => b.createdAt.getTime() - a.createdAt.getTime())
+            .map((r) => ({ ...r }));
+    }
+
+    async findRequestStatsByClassification(
+        tenantId: string,
+        filter: RequestStatClassificationFilter,
+    ): Promise<RequestStatRecord[]> {
+        return [...this.records.values()]
+            .filter((r) => (tenantId === UNSCOPED_TENANT || r.tenantId === tenantId) && matchesClassification(r, filter))
+            .sort((a, b) => b.createdAt.getTime() - a.createdAt.getTime())
+            .map((r) => ({ ...r }));
+    }
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+/**
+ * Groups request stats by UTC day and model (and end user, with
+ * byEndUser, or response language, with byLanguage), ordered by day,
+ * model, then end user or language. Grouped by language, rows also count
+ * safety-flagged requests. p95 latency is the nearest-rank value: the
+ * ceil(0.95 * n)th smallest.
+ */
+export function aggregateRequestStats(
+    records: Iterable<RequestStatRecord>,
+    options: { byEndUser?: boolean | undefined; byLanguage?: boolean | undefined } = {},
+): UsageStatsRow[] {
+    const { byEndUser = false, byLanguage = false } = options;
+    const groups = new Map<string, { row: UsageStatsRow; latencies: number[] }>();
+    for (const record of records) {
+        const day = record.createdAt.toISOString().slice(0, 10);
+        const endUser = byEndUser ? record.endUser : undefined;
+        const language = byLanguage ? record.language : undefined;
+        const key = `${day}\u0000${record.model}\u0000${endUser ?? ''}\u0000${language ?? ''}`;
+        let group = groups.get(key);
+        if (!group) {
+            group = {
+                row: { day, model: record.model, requests: 0, errors: 0, promptTokens: 0, completionTokens: 0, totalTokens: 0 },
+                latencies: [],
+            };
+            if (byEndUser) {
+                group.row.endUser = endUser;
+            }
+            if (byLanguage) {
+                group.row.language = language;
+                group.row.safetyFlagged = 0;
+            }
+            groups.set(key, group);
+        }
+        if (byLanguage && record.safetyFlags?.length) {
+            group.row.safetyFlagged!++;
+        }
+        group.row.requests++;
+        group.row.errors += record.error ? 1 : 0;
+        group.row.promptTokens += record.promptTokens;
+        group.row.completionTokens += record.completionTokens;
+        group.row.totalTokens += record.totalTokens;
+        if (record.latencyMs !== undefined) {
+            group.latencies.push(record.latencyMs);
+        }
+    }
+
+    return [...groups.values()]
+        .map(({ row, latencies }) => {
+            if (latencies.length > 0) {
+                latencies.sort((a, b) => a - b);
+                row.p95LatencyMs = latencies[Math.ceil(latencies.length * 0.95) - 1];
+            }
+            return row;
+        })
+        .sort((a, b) => a.day.localeCompare(b.day) || a.model.localeCompare(b.model)
+            || (a.endUser ?? '').localeCompare(b.endUser ?? '')
+            || (a.language ?? '').localeCompare(b.language ?? ''));
+}
+
+/**
+ * Whether a request stat matches a classification filter.
+ */
+export function matchesClassification(record: RequestStatRecord, filter: RequestStatClassificationFilter): boolean {
+    if (filter.language !== undefined && record.language !== filter.language) {
+        return false;
+    }
+    if (filter.safety !== undefined) {
+        const flags = record.safetyFlags ?? [];
+        return filter.safety === '*' ? flags.length > 0 : flags.includes(filter.safety);
+    }
+    return true;
+}
+
+/**
+ * Type guard to check if a storage provider implements UsageStatsStore.
+ */
+export function isUsageStatsStore(
+    storage: StorageProvider | undefined,
+): storage is StorageProvider & UsageStatsStore {
+    return (
+        storage !== undefined &&
+        typeof storage.recordRequestStat === 'function' &&
+        typeof storage.aggregateUsageStats === 'function'
+    );
+}
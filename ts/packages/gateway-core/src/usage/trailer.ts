@@ -0,0 +1,118 @@
+/**
+ * Gateway usage trailer for streamed responses.
+ *
+ * Apps with usage_trailer set get one extra SSE event at the very end of
+ * each stream, after the protocol's own terminal event ([DONE],
+ * message_stop, response.completed) and before the connection closes:
+ *
+ *     event: gateway.usage
+ *     data: {"interaction_id":"...","provider":"openai","model":"gpt-4o",
+ *            "prompt_tokens":12,"completion_tokens":40,"total_tokens":52,
+ *            "cost_usd":0.00043}
+ *
+ * The request's gateway warnings, if any, ride along under `warnings`.
+ *
+ * It is an extension event type, never part of the native protocol, and
+ * it is never injected mid-stream. Clients opt out per request with
+ * X-Gateway-No-Trailer.
+ *
+ * @module usage/trailer
+ */
+
+import type { Usage } from '../domain/types.js';
+import type { GatewayWarning } from '../warnings/collector.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** SSE event type of the usage trailer. */
+export const USAGE_TRAILER_EVENT = 'gateway.usage';
+
+/** Request header that suppresses the usage trailer. */
+export const NO_TRAILER_HEADER = 'X-Gateway-No-Trailer';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Body of the usage trailer event.
+ */
+export interface UsageTrailer {
+    /** Gateway interaction ID. */
+    interaction_id: string;
+
+    /** Provider that served the stream. */
+    provider: string;
+
+    /** Model that served the stream. */
+    model: string;
+
+    /** Prompt tokens, when the provider reported usage. */
+    prompt_tokens?: number | undefined;
+
+    /** Completion tokens, when the provider reported usage. */
+    completion_tokens?: number | undefined;
+
+    /** Total tokens, when the provider reported usage. */
+    total_tokens?: number | undefined;
+
+    /** Estimated cost (USD), when the model has pricing. */
+    cost_usd?: number | undefined;
+
+    /** Gateway warnings for the request, when there are any. */
+    warnings?: GatewayWarning[] | undefined;
+}
+
+// ============================================================================
+// Trailer
+// ============================================================================
+
+/**
+ * Builds the trailer body for a finished stream.
+ */
+export function buildUsageTrailer(fields: {
+    interactionId: string;
+    provider: string;
+    model: string;
+    usage?: Usage | undefined;
+    costUsd?: number | undefined;
+    warnings?: GatewayWarning[] | undefined;
+}): UsageTrailer {
+    const { usage } = fields;
+    return {
+        interaction_id: fields.interactionId,
+        provider: fields.provider,
+        model: fields.model,
+        prompt_tokens: usage?.promptTokens,
+        completion_tokens: usage?.completionTokens,
+        total_tokens: usage?.totalTokens,
+        cost_usd: usage ? fields.costUsd : undefined,
+        warnings: fields.warnings?.length ? fields.warnings : undefined,
+    };
+}
+
+/**
+ * Passes an SSE body through and appends the usage trailer once it has
+ * closed. The trailer is built only then, so it sees the stream's final
+ * usage. A body that errors gets no trailer.
+ */
+export function appendUsageTrailer(
+    body: ReadableStream<Uint8Array>,
+    trailer: () => UsageTrailer,
+): ReadableStream<Uint8Array> {
+    const encoder = new TextEncoder();
+    return body.pipeThrough(new TransformStream<Uint8Array, Uint8Array>({
+        flush(controller) {
+            controller.enqueue(encoder.encode(`event: ${USAGE_TRAILER_EVENT}\ndata: ${JSON.stringify(trailer())}\n\n`));
+        },
+    }));
+}
+
+/**
+ * Whether a response is an SSE stream.
+ */
+export function isEventStream(response: Response): boolean {
+    return response.headers.get('Content-Type')?.startsWith('text/event-stream') ?? false;
+}
@@ -0,0 +1,120 @@
+import { describe, it, expect, afterEach, vi } from 'vitest';
+import { AdminHandler } from './admin/index';
+import { compareUsage, resolveUsageCheck, type UsageCheckOptions } from './usagecheck/index';
+import { WARNINGS_HEADER } from './warnings/collector';
+import { harness, ScriptedProvider, type TestGateway } from './__tests__/harness/index';
+
+const options: UsageCheckOptions = { threshold: 0.5, minTokens: 20 };
+const labels = { provider: 'mock', model: 'gpt-4o' };
+const usage = (completionTokens: number, reasoningTokens?: number) =>
+    ({ promptTokens: 10, completionTokens, totalTokens: 10 + completionTokens, reasoningTokens });
+
+/** About 100 tokens of relayed text. */
+const STORY = 'the cat sat on the mat and '.repeat(14).trim();
+
+describe('compareUsage', () => {
+    const cases = [
+        { name: 'agreeing counts', usage: usage(110), counted: 100, ratio: 1.1, discrepancy: false },
+        { name: 'an over-reported count', usage: usage(400), counted: 100, ratio: 4, discrepancy: true },
+        { name: 'an under-reported count, as for a truncated stream billed as short', usage: usage(30), counted: 100, ratio: 0.3, discrepancy: true },
+        { name: 'reasoning tokens, which are not relayed', usage: usage(1100, 1000), counted: 100, ratio: 1, discrepancy: false },
+        { name: 'nothing relayed', usage: usage(50), counted: 0, ratio: null, discrepancy: true },
+    ];
+
+    it.each(cases)('should compare $name', ({ usage, counted, ratio, discrepancy }) => {
+        expect(compareUsage(usage, counted, options, labels)).toMatchObject({ ratio, discrepancy, countedTokens: counted });
+    });
+
+    it('should not compare short outputs', () => {
+        expect(compareUsage(usage(5), 12, options, labels)).toBeUndefined();
+        expect(compareUsage(usage(5), 12, { ...options, minTokens: 0 }, labels)?.discrepancy).toBe(true);
+    });
+
+    it('should resolve defaults, and nothing when turned off', () => {
+        expect(resolveUsageCheck(undefined)).toEqual({ threshold: 0.5, minTokens: 20 });
+        expect(resolveUsageCheck({ threshold: 0.2 })).toEqual({ threshold: 0.2, minTokens: 20 });
+        expect(resolveUsageCheck({ enabled: false })).toBeUndefined();
+    });
+});
+
+describe('Usage checks', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup(completionTokens: number, config: object = {}) {
+        const provider = new ScriptedProvider('mock', { text: STORY, usage: usage(completionTokens) });
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1' })
+            .provider(provider)
+            .config(config)
+            .start();
+        const gw = gateway;
+        const admin = new AdminHandler({
+            metadataIndex: gw.gateway.metadataIndex,
+            usageDiscrepancies: () => gw.gateway.usageDiscrepancyStats(),
+        });
+        const get = async (path: string) => (await admin.handle(new Request(`http://localhost${path}`))).json();
+        const ask = (stream = false) => gw.post('/v1/chat/completions', {
+            model: 'gpt-4o',
+            stream,
+            messages: [{ role: 'user', content: 'Tell me a story' }],
+        });
+        return { gw, get, ask };
+    }
+
+    it('should flag responses and streams whose reported usage disagrees with the output', async () => {
+        const { get, ask } = await setup(900);
+
+        const response = await ask();
+        await (await ask(true)).text();
+
+        expect(response.status).toBe(200);
+        expect(response.headers.get(WARNINGS_HEADER)).toBeNull();
+        await vi.waitFor(async () => expect((await get('/api/interactions?has_usage_discrepancy=true')).total).toBe(2));
+        const found = await get('/api/interactions?has_usage_discrepancy=true');
+        expect(found.interactions[0].metadata).toMatchObject({
+            usage_discrepancy: 'true',
+            usage_reported_tokens: '900',
+            provider: 'mock',
+            model: 'gpt-4o',
+        });
+        expect(Number(found.interactions[0].metadata.usage_ratio)).toBeGreaterThan(5);
+        expect(found.breakdown).toEqual([
+            { provider: 'mock', model: 'gpt-4o', count: 2, reportedTokens: 1800, countedTokens: expect.any(Number) },
+        ]);
+        expect((await get('/api/interactions?has_usage_discrepancy=true&provider=other')).total).toBe(0);
+        expect((await get('/api/stats')).usageDiscrepancies).toEqual([{
+            provider: 'mock',
+            model: 'gpt-4o',
+            checked: 2,
+            discrepancies: 2,
+            reportedTokens: 1800,
+            countedTokens: expect.any(Number),
+        }]);
+    });
+
+    it('should count agreeing responses without flagging them', async () => {
+        const { gw, get, ask } = await setup(100);
+
+        await ask();
+        await (await ask(true)).text();
+
+        await vi.waitFor(async () => expect((await get('/api/stats')).usageDiscrepancies).toEqual([
+            expect.objectContaining({ checked: 2, discrepancies: 0 }),
+        ]));
+        expect((await gw.gateway.metadataIndex.findByMetadata('*', 'usage_discrepancy', 'true'))).toHaveLength(0);
+        expect((await get('/api/interactions?has_usage_discrepancy=false')).error).toBeDefined();
+    });
+
+    it('should check nothing when turned off', async () => {
+        const { get, ask } = await setup(900, { usageCheck: { enabled: false } });
+
+        await ask();
+
+        expect((await get('/api/stats')).usageDiscrepancies).toEqual([]);
+    });
+});
@@ -0,0 +1,279 @@
+/**
+ * Verification of provider-reported output usage.
+ *
+ * Providers bill for the completion tokens they report, and sometimes
+ * report far more or fewer than they sent: a stream cut off early but
+ * counted as whole, or a miscounting upstream. At the end of each response
+ * or stream the output relayed (text, thinking, and tool call arguments)
+ * is counted with the model's tokenizer and compared with the completion
+ * tokens reported, less any reasoning tokens (which are not relayed). A
+ * relative difference over the threshold is a discrepancy: the gateway
+ * records it on the interaction, indexes it for the admin API, and counts
+ * it per provider and model. Clients see no difference.
+ *
+ * @module usagecheck/check
+ */
+
+import type {
+    APIType,
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+    Usage,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+import type { UsageCheckConfig } from '../ports/config.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Default relative difference over which counts disagree. */
+export const DEFAULT_USAGE_CHECK_THRESHOLD = 0.5;
+
+/** Default count below which outputs are not compared. */
+export const DEFAULT_USAGE_CHECK_MIN_TOKENS = 20;
+
+/** Metadata index key interactions with a discrepancy are found by (value: true). */
+export const USAGE_DISCREPANCY_METADATA_KEY = 'usage_discrepancy';
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * Resolved usage check settings.
+ */
+export interface UsageCheckOptions {
+    /** Relative difference, of the larger count, over which counts disagree. */
+    threshold: number;
+
+    /** Outputs where both counts are below this are not compared. */
+    minTokens: number;
+}
+
+/**
+ * One response's reported and counted output tokens.
+ */
+export interface UsageComparison {
+    /** Provider that served the response. */
+    provider: string;
+
+    /** Model that served the response. */
+    model: string;
+
+    /** Completion tokens the provider reported, less reasoning tokens. */
+    reportedTokens: number;
+
+    /** Tokens counted in the output relayed. */
+    countedTokens: number;
+
+    /** Reported over counted tokens (null when nothing was relayed). */
+    ratio: number | null;
+
+    /** Whether the counts differ by more than the threshold. */
+    discrepancy: boolean;
+}
+
+/**
+ * Checks and discrepancies for one provider and model, for /admin/api/stats.
+ */
+export interface UsageDiscrepancyStats {
+    /** Provider name. */
+    provider: string;
+
+    /** Model name. */
+    model: string;
+
+    /** Responses compared. */
+    checked: number;
+
+    /** Responses whose counts disagreed. */
+    discrepancies: number;
+
+    /** Reported tokens over counted ones, summed across discrepancies. */
+    reportedTokens: number;
+
+    /** Counted tokens, summed across discrepancies. */
+    countedTokens: number;
+}
+
+/** Counts output tokens as a model's tokenizer would. */
+export type OutputTokenCounter = (text: string, model: string, apiType: APIType) => number;
+
+// ============================================================================
+// Comparison
+// ============================================================================
+
+/**
+ * Resolves the usage check settings, or undefined when checks are off.
+ */
+export function resolveUsageCheck(config: UsageCheckConfig | undefined): UsageCheckOptions | undefined {
+    if (config?.enabled === false) {
+        return undefined;
+    }
+    return {
+        threshold: config?.threshold ?? DEFAULT_USAGE_CHECK_THRESHOLD,
+        minTokens: config?.minTokens ?? DEFAULT_USAGE_CHECK_MIN_TOKENS,
+    };
+}
+
+/**
+ * Compares reported usage with the tokens counted in the output relayed.
+ * Returns undefined when both are below the minimum.
+ */
+export function compareUsage(
+    usage: Usage,
+    countedTokens: number,
+    options: UsageCheckOptions,
+    labels: { provider: string; model: string },
+): UsageComparison | undefined {
+    const reportedTokens = Math.max(0, usage.completionTokens - (usage.reasoningTokens ?? 0));
+    const larger = Math.max(reportedTokens, countedTokens);
+    if (larger < options.minTokens) {
+        return undefined;
+    }
+    return {
+        ...labels,
+        reportedTokens,
+        countedTokens,
+        ratio: countedTokens > 0 ? Math.round((reportedTokens / countedTokens) * 100) / 100 : null,
+        discrepancy: Math.abs(reportedTokens - countedTokens) / larger > options.threshold,
+    };
+}
+
+/**
+ * Counts checks and discrepancies per provider and model.
+ */
+export class UsageDiscrepancies {
+    private readonly entries = new Map<string, UsageDiscrepancyStats>();
+
+    /**
+     * Records one comparison.
+     */
+    record(comparison: UsageComparison): void {
+        const key = `${comparison.provider}\u0000${comparison.model}`;
+        let entry = this.entries.get(key);
+        if (!entry) {
+            entry = {
+                provider: comparison.provider,
+                model: comparison.model,
+                checked: 0,
+                discrepancies: 0,
+                reportedTokens: 0,
+                countedTokens: 0,
+            };
+            this.entries.set(key, entry);
+        }
+        entry.checked++;
+        if (comparison.discrepancy) {
+            entry.discrepancies++;
+            entry.reportedTokens += comparison.reportedTokens;
+            entry.countedTokens += comparison.countedTokens;
+        }
+    }
+
+    /**
+     * Returns the counters, sorted by provider and model.
+     */
+    stats(): UsageDiscrepancyStats[] {
+        return [...this.entries.values()]
+            .map((entry) => ({ ...entry }))
+            .sort((a, b) => a.provider.localeCompare(b.provider) || a.model.localeCompare(b.model));
+    }
+}
+
+// ============================================================================
+// Checking Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so each response's reported output usage is compared
+ * with the output relayed.
+ */
+export class UsageCheckingProvider extends ProviderWrapper {
+    private readonly options: UsageCheckOptions;
+    private readonly count: OutputTokenCounter;
+    private readonly onChecked: (comparison: UsageComparison) => void;
+
+    constructor(
+        inner: Provider,
+        options: UsageCheckOptions,
+        count: OutputTokenCounter,
+        onChecked: (comparison: UsageComparison) => void,
+    ) {
+        super(inner);
+        this.options = options;
+        this.count = count;
+        this.onChecked = onChecked;
+    }
+
+    /**
+     * Completes a request, checking the usage of every choice's output.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, options);
+        let output = '';
+        for (const choice of response.choices) {
+            output += choice.message.content;
+            for (const call of choice.message.toolCalls ?? []) {
+                output += call.function.arguments;
+            }
+            for (const part of choice.message.richContent?.parts ?? []) {
+                output += part.thinking ?? '';
+            }
+        }
+        this.check(response.usage, output, response.model || request.model);
+        return response;
+    }
+
+    /**
+     * Streams a request, checking usage once the stream ends. The check is
+     * made at the done event, since consumers stop reading there.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        let output = '';
+        let usage: Usage | undefined;
+        let model: string | undefined;
+        let checked = false;
+        for await (const event of this.inner.stream(request, options)) {
+            output += (event.contentDelta ?? '') + (event.thinkingDelta ?? '') + (event.toolCall?.function?.arguments ?? '');
+            usage = event.usage ?? usage;
+            model = event.providerModel ?? event.model ?? model;
+            if (event.type === 'done' && !checked) {
+                checked = true;
+                this.check(usage, output, model ?? request.model);
+            }
+            yield event;
+        }
+        if (!checked) {
+            this.check(usage, output, model ?? request.model);
+        }
+    }
+
+    private check(usage: Usage | undefined, output: string, model: string): void {
+        // Nothing to verify when the provider reports no usage
+        if (!usage) {
+            return;
+        }
+        const counted = this.count(output, model, this.apiType);
+        const comparison = compareUsage(usage, counted, this.options, { provider: this.name, model });
+        if (comparison) {
+            this.onChecked(comparison);
+        }
+    }
+}
+
+/**
+ * Checks a provider's reported output usage when `options` is given.
+ * Returns the provider unchanged otherwise.
+ */
+export function withUsageCheck(
+    provider: Provider,
+    options: UsageCheckOptions | undefined,
+    count: OutputTokenCounter,
+    onChecked: (comparison: UsageComparison) => void,
+): Provider {
+    return options ? new UsageCheckingProvider(provider, options, count, onChecked) : provider;
+}
@@ -0,0 +1,20 @@
+/**
+ * Provider usage check exports.
+ *
+ * @module usagecheck
+ */
+
+export {
+    UsageCheckingProvider,
+    UsageDiscrepancies,
+    withUsageCheck,
+    resolveUsageCheck,
+    compareUsage,
+    DEFAULT_USAGE_CHECK_THRESHOLD,
+    DEFAULT_USAGE_CHECK_MIN_TOKENS,
+    USAGE_DISCREPANCY_METADATA_KEY,
+    type UsageCheckOptions,
+    type UsageComparison,
+    type UsageDiscrepancyStats,
+    type OutputTokenCounter,
+} from './check.js';
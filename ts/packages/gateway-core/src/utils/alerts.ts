@@ -0,0 +1,65 @@
+/**
+ * Alert webhook delivery, shared by provider probes and app SLOs.
+ *
+ * @module utils/alerts
+ */
+
+import type { AlertWebhookConfig } from '../ports/config.js';
+import type { Logger } from './logging.js';
+import { expandEnvRefs } from './headers.js';
+
+// ============================================================================
+// Delivery
+// ============================================================================
+
+/** Timeout for an alert webhook request. */
+export const ALERT_WEBHOOK_TIMEOUT_MS = 10_000;
+
+/**
+ * What an alert is sent with.
+ */
+export interface AlertSender {
+    /** Fetch implementation. */
+    fetch: typeof fetch;
+
+    /** Variables for ${env:VAR} references in webhook headers. */
+    env: Record<string, string | undefined>;
+
+    /** Logger. */
+    logger?: Logger | undefined;
+}
+
+/**
+ * POSTs an alert body to a webhook. Failures are logged as `failedEvent`
+ * with `fields`, never thrown.
+ */
+export async function postAlert(
+    sender: AlertSender,
+    config: AlertWebhookConfig,
+    body: unknown,
+    failedEvent: string,
+    fields: Record<string, unknown>,
+): Promise<void> {
+    const headers: Record<string, string> = { 'Content-Type': 'application/json' };
+    for (const [name, template] of Object.entries(config.headers ?? {})) {
+        const value = expandEnvRefs(template, sender.env);
+        if (value !== undefined) headers[name] = value;
+    }
+    try {
+        const response = await sender.fetch(config.webhook, {
+            method: 'POST',
+            headers,
+            body: JSON.stringify(body),
+            signal: AbortSignal.timeout(ALERT_WEBHOOK_TIMEOUT_MS),
+        });
+        await response.body?.cancel();
+        if (!response.ok) {
+            sender.logger?.warn(failedEvent, { ...fields, status: response.status });
+        }
+    } catch (error) {
+        sender.logger?.warn(failedEvent, {
+            ...fields,
+            error: error instanceof Error ? error.message : String(error),
+        });
+    }
+}
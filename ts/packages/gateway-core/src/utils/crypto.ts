@@ -13,7 +13,13 @@
  */
 export async function sha256(input: string): Promise<string> {
     const encoder = new TextEncoder();
-    const data = encoder.encode(input);
+    return sha256Bytes(encoder.encode(input));
+}
+
+/**
+ * Hashes bytes using SHA-256.
+ */
+export async function sha256Bytes(data: Uint8Array): Promise<string> {
     const hashBuffer = await crypto.subtle.digest('SHA-256', data);
     const hashArray = new Uint8Array(hashBuffer);
     return arrayToHex(hashArray);
@@ -0,0 +1,33 @@
+/**
+ * Duration string parsing.
+ *
+ * @module utils/duration
+ */
+
+// ============================================================================
+// Duration Parsing
+// ============================================================================
+
+const UNIT_MS: Record<string, number> = {
+    ms: 1,
+    s: 1000,
+    m: 60 * 1000,
+    h: 60 * 60 * 1000,
+    d: 24 * 60 * 60 * 1000,
+};
+
+/**
+ * Parses a duration string (e.g., "500ms", "30s", "5m", "24h", "28d") to milliseconds.
+ * Returns the fallback for missing or malformed values.
+ */
+export function parseDuration(value: string | undefined, fallbackMs: number): number {
+    if (!value) return fallbackMs;
+
+    const match = value.trim().match(/^(\d+)(ms|s|m|h|d)$/);
+    if (!match) return fallbackMs;
+
+    const amount = parseInt(match[1] ?? '0', 10);
+    const unit = UNIT_MS[match[2] ?? ''];
+
+    return unit === undefined ? fallbackMs : amount * unit;
+}
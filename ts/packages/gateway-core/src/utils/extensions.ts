@@ -0,0 +1,36 @@
+/**
+ * Gateway extensions to provider response formats.
+ *
+ * What the gateway adds to a response travels in a header, as JSON kept
+ * to printable ASCII so the value is a valid header, or under an
+ * x_gateway_* key of a JSON body, which clients that ignore unknown fields
+ * parse unchanged.
+ *
+ * @module utils/extensions
+ */
+
+/**
+ * Serializes a value as compact JSON with every character outside
+ * printable ASCII escaped.
+ */
+export function asciiJSON(value: unknown): string {
+    return JSON.stringify(value)
+        .replace(/[^\x20-\x7e]/g, (c) => `\\u${c.charCodeAt(0).toString(16).padStart(4, '0')}`);
+}
+
+/**
+ * Adds a key to a JSON object body. Anything else (arrays, invalid JSON)
+ * is returned unchanged.
+ */
+export function embedExtension(body: string, key: string, value: unknown): string {
+    let parsed: unknown;
+    try {
+        parsed = JSON.parse(body);
+    } catch {
+        return body;
+    }
+    if (typeof parsed !== 'object' || parsed === null || Array.isArray(parsed)) {
+        return body;
+    }
+    return JSON.stringify({ ...parsed, [key]: value });
+}
@@ -0,0 +1,166 @@
+/**
+ * Declarative upstream header rules.
+ *
+ * Apps and providers can forward selected client headers (passthrough),
+ * set fixed headers (inject, with ${env:VAR} expansion), and remove
+ * headers (strip). Rules are applied in that order, app rules before
+ * provider rules, so an injected value beats a passed-through one and a
+ * strip beats both. Hop-by-hop and credential headers are never touched.
+ *
+ * @module utils/headers
+ */
+
+import type { UpstreamHeaderSet } from '../domain/types.js';
+import type { HeaderRulesConfig } from '../ports/config.js';
+
+// ============================================================================
+// Denylist
+// ============================================================================
+
+/**
+ * Headers that header rules may never forward, set, or strip: credentials
+ * (which the provider sets from its own key) and hop-by-hop/framing headers.
+ */
+export const PROTECTED_HEADERS: ReadonlySet<string> = new Set([
+    'authorization',
+    'proxy-authorization',
+    'x-api-key',
+    'api-key',
+    'cookie',
+    'set-cookie',
+    'host',
+    'connection',
+    'keep-alive',
+    'proxy-connection',
+    'transfer-encoding',
+    'te',
+    'trailer',
+    'upgrade',
+    'content-length',
+]);
+
+/**
+ * Throws if rules reference a protected header.
+ */
+export function validateHeaderRules(rules: HeaderRulesConfig): void {
+    const names = [
+        ...(rules.passthrough ?? []),
+        ...Object.keys(rules.inject ?? {}),
+        ...(rules.strip ?? []),
+    ];
+    for (const name of names) {
+        if (PROTECTED_HEADERS.has(name.toLowerCase())) {
+            throw new Error(`header '${name}' is protected and cannot be passed through, injected, or stripped`);
+        }
+    }
+}
+
+// ============================================================================
+// Upstream Headers
+// ============================================================================
+
+/**
+ * Resolved headers for one upstream request. Serializes to header names
+ * only, so injected secrets never reach logs or stored requests.
+ */
+export class UpstreamHeaders implements UpstreamHeaderSet {
+    constructor(
+        private readonly set: ReadonlyMap<string, string>,
+        private readonly strip: ReadonlySet<string>,
+    ) { }
+
+    /** Set header names, then stripped ones prefixed with '-'. */
+    get names(): string[] {
+        return [...this.set.keys(), ...Array.from(this.strip, (name) => `-${name}`)];
+    }
+
+    apply(headers: Record<string, string>): Record<string, string> {
+        const result: Record<string, string> = {};
+        for (const [name, value] of Object.entries(headers)) {
+            const lower = name.toLowerCase();
+            if (PROTECTED_HEADERS.has(lower) || (!this.set.has(lower) && !this.strip.has(lower))) {
+                result[name] = value;
+            }
+        }
+        for (const [name, value] of this.set) {
+            if (!this.strip.has(name) && !PROTECTED_HEADERS.has(name)) {
+                result[name] = value;
+            }
+        }
+        return result;
+    }
+
+    toJSON(): { headers: string[] } {
+        return { headers: this.names };
+    }
+}
+
+/**
+ * Resolves header rules (lowest precedence first) against the client's
+ * request headers. Returns undefined when no rule applies. Injected values
+ * referencing an unset variable are skipped and reported via `onUnset`.
+ */
+export function resolveUpstreamHeaders(
+    client: Headers,
+    rules: Array<HeaderRulesConfig | undefined>,
+    env: Record<string, string | undefined>,
+    onUnset?: (header: string, variable: string) => void,
+): UpstreamHeaders | undefined {
+    const set = new Map<string, string>();
+    const strip = new Set<string>();
+    const active = rules.filter((r): r is HeaderRulesConfig => r !== undefined);
+
+    for (const r of active) {
+        for (const name of r.passthrough ?? []) {
+            const lower = name.toLowerCase();
+            const value = client.get(lower);
+            if (value !== null && !PROTECTED_HEADERS.has(lower)) {
+                set.set(lower, value);
+            }
+        }
+    }
+
+    for (const r of active) {
+        for (const [name, template] of Object.entries(r.inject ?? {})) {
+            const lower = name.toLowerCase();
+            if (PROTECTED_HEADERS.has(lower)) continue;
+            const value = expandEnvRefs(template, env, (variable) => onUnset?.(lower, variable));
+            if (value !== undefined) {
+                set.set(lower, value);
+            }
+        }
+    }
+
+    for (const r of active) {
+        for (const name of r.strip ?? []) {
+            const lower = name.toLowerCase();
+            if (!PROTECTED_HEADERS.has(lower)) {
+                strip.add(lower);
+                set.delete(lower);
+            }
+        }
+    }
+
+    return set.size > 0 || strip.size > 0 ? new UpstreamHeaders(set, strip) : undefined;
+}
+
+/**
+ * Expands ${env:VAR} references. Returns undefined if any variable is unset.
+ */
+export function expandEnvRefs(
+    template: string,
+    env: Record<string, string | undefined>,
+    onUnset?: (variable: string) => void,
+): string | undefined {
+    let missing = false;
+    const value = template.replace(/\$\{env:([^}]+)\}/g, (_, variable: string) => {
+        const resolved = env[variable];
+        if (resolved === undefined) {
+            missing = true;
+            onUnset?.(variable);
+            return '';
+        }
+        return resolved;
+    });
+    return missing ? undefined : value;
+}
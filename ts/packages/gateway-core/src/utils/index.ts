@@ -21,6 +21,7 @@ export {
 // Crypto
 export {
     sha256,
+    sha256Bytes,
     sha256WithSalt,
     randomUUID,
     randomBytes,
@@ -39,8 +40,48 @@ export {
 export {
     type LogLevel,
     type Logger,
+    LOG_LEVELS,
     ConsoleLogger,
     NullLogger,
     defaultLogger,
     requestLogger,
 } from './logging.js';
+
+// Duration
+export { parseDuration } from './duration.js';
+
+// Stream throttling
+export {
+    throttleStream,
+    maybeThrottle,
+    describeThrottle,
+    estimateTokens,
+    TokenBucket,
+    systemClock,
+    type ThrottleClock,
+} from './throttle.js';
+
+// Request timings
+export {
+    TimingRecorder,
+    timeStream,
+    LatencyStats,
+    percentile,
+    DEFAULT_LATENCY_WINDOW,
+    type TimingPhase,
+    type TimingRecorderOptions,
+    type Percentiles,
+    type LatencySummary,
+} from './timings.js';
+
+// Response extensions
+export { asciiJSON, embedExtension } from './extensions.js';
+
+// Upstream header rules
+export {
+    UpstreamHeaders,
+    resolveUpstreamHeaders,
+    validateHeaderRules,
+    expandEnvRefs,
+    PROTECTED_HEADERS,
+} from './headers.js';
@@ -10,7 +10,8 @@
 
 export type LogLevel = 'debug' | 'info' | 'warn' | 'error';
 
-const LOG_LEVELS: Record<LogLevel, number> = {
+/** Level severities; an entry is logged at or above the threshold. */
+export const LOG_LEVELS: Record<LogLevel, number> = {
     debug: 0,
     info: 1,
     warn: 2,
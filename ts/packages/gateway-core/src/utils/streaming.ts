@@ -27,6 +27,10 @@ export function createSSEStream(
                 for await (const event of generator) {
                     // Skip empty events
                     if (event.type === 'done') {
+                        if (event.warning || (metadata?.includeUsage && event.usage)) {
+                            const sseData = codec.encodeStreamEvent(event, metadata);
+                            controller.enqueue(encoder.encode(`data: ${sseData}\n\n`));
+                        }
                         controller.enqueue(encoder.encode('data: [DONE]\n\n'));
                         break;
                     }
@@ -35,13 +39,10 @@ export function createSSEStream(
                     controller.enqueue(encoder.encode(`data: ${sseData}\n\n`));
                 }
             } catch (error) {
-                // Encode error as SSE event
-                const errorEvent: CanonicalEvent = {
-                    type: 'error',
-                    error: error instanceof Error ? error : new Error(String(error)),
-                };
-                const sseData = codec.encodeStreamEvent(errorEvent, metadata);
-                controller.enqueue(encoder.encode(`data: ${sseData}\n\n`));
+                // Encode error in the API's error envelope so clients can tell
+                // a failed stream (e.g. upstream timeout) from a finished one
+                const { body } = codec.encodeError(error instanceof Error ? error : new Error(String(error)));
+                controller.enqueue(encoder.encode(`data: ${body}\n\n`));
             } finally {
                 controller.close();
             }
@@ -66,21 +67,18 @@ export function createAnthropicSSEStream(
                 for await (const event of generator) {
                     // Skip empty events
                     if (event.type === 'done') {
-                        controller.enqueue(encoder.encode('event: message_stop\ndata: {}\n\n'));
+                        const data = event.warning ? JSON.stringify({ type: 'message_stop', warning: event.warning }) : '{}';
+                        controller.enqueue(encoder.encode(`event: message_stop\ndata: ${data}\n\n`));
                         break;
                     }
 
-                    const eventType = mapCanonicalToAnthropicEventType(event.type);
+                    const eventType = mapCanonicalToAnthropicEventType(event);
                     const sseData = codec.encodeStreamEvent(event, metadata);
                     controller.enqueue(encoder.encode(`event: ${eventType}\ndata: ${sseData}\n\n`));
                 }
             } catch (error) {
-                const errorEvent: CanonicalEvent = {
-                    type: 'error',
-                    error: error instanceof Error ? error : new Error(String(error)),
-                };
-                const sseData = codec.encodeStreamEvent(errorEvent, metadata);
-                controller.enqueue(encoder.encode(`event: error\ndata: ${sseData}\n\n`));
+                const { body } = codec.encodeError(error instanceof Error ? error : new Error(String(error)));
+                controller.enqueue(encoder.encode(`event: error\ndata: ${body}\n\n`));
             } finally {
                 controller.close();
             }
@@ -89,10 +87,14 @@ export function createAnthropicSSEStream(
 }
 
 /**
- * Maps canonical event type to Anthropic event type.
+ * Maps a canonical event to its Anthropic event type. A stop event
+ * carrying a finish reason is sent as message_delta, with the reason.
  */
-function mapCanonicalToAnthropicEventType(type: CanonicalEvent['type']): string {
-    switch (type) {
+function mapCanonicalToAnthropicEventType(event: CanonicalEvent): string {
+    if (event.type === 'message_stop' && event.finishReason && !event.contentDelta) {
+        return 'message_delta';
+    }
+    switch (event.type) {
         case 'message_start':
             return 'message_start';
         case 'content_delta':
@@ -197,6 +199,7 @@ export interface StreamAccumulator {
     content: string;
     toolCalls: Map<number, { id: string; name: string; arguments: string }>;
     finishReason?: string;
+    stopSequence?: string;
     usage?: {
         promptTokens: number;
         completionTokens: number;
@@ -246,6 +249,10 @@ export function accumulateEvent(
         acc.finishReason = event.finishReason;
     }
 
+    if (event.stopSequence) {
+        acc.stopSequence = event.stopSequence;
+    }
+
     if (event.usage) {
         acc.usage = event.usage;
     }
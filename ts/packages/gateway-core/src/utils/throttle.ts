@@ -0,0 +1,121 @@
+/**
+ * Output pacing for streamed responses.
+ *
+ * Content deltas are metered through a token bucket sized from an estimate
+ * of their token count. The throttle is a pull-based generator: while it
+ * waits, it stops pulling from the provider stream, so backpressure reaches
+ * the upstream read loop instead of events queueing in memory. Only content
+ * is paced; done, error, and metadata events pass through immediately.
+ *
+ * @module utils/throttle
+ */
+
+import type { CanonicalEvent } from '../domain/types.js';
+import type { StreamThrottleConfig } from '../ports/config.js';
+
+// ============================================================================
+// Clock
+// ============================================================================
+
+/**
+ * Time source for the throttle; replaceable in tests.
+ */
+export interface ThrottleClock {
+    /** Current time in milliseconds. */
+    now(): number;
+
+    /** Resolves after `ms` milliseconds. */
+    sleep(ms: number): Promise<void>;
+}
+
+/** Wall-clock time source. */
+export const systemClock: ThrottleClock = {
+    now: () => Date.now(),
+    sleep: (ms) => new Promise((resolve) => setTimeout(resolve, ms)),
+};
+
+// ============================================================================
+// Token Bucket
+// ============================================================================
+
+/**
+ * Token bucket that refills continuously at the configured rate.
+ */
+export class TokenBucket {
+    private readonly rate: number;
+    private readonly capacity: number;
+    private tokens: number;
+    private updatedAt: number;
+
+    constructor(config: StreamThrottleConfig, private readonly clock: ThrottleClock = systemClock) {
+        this.rate = config.tokensPerSecond;
+        this.capacity = config.burst ?? config.tokensPerSecond;
+        this.tokens = this.capacity;
+        this.updatedAt = clock.now();
+    }
+
+    /**
+     * Takes `count` tokens, waiting until the bucket has refilled enough.
+     * A single take larger than the burst size is allowed and paid off as debt.
+     */
+    async take(count: number): Promise<void> {
+        this.refill();
+        this.tokens -= count;
+        if (this.tokens < 0) {
+            await this.clock.sleep((-this.tokens / this.rate) * 1000);
+            this.refill();
+        }
+    }
+
+    private refill(): void {
+        const now = this.clock.now();
+        this.tokens = Math.min(this.capacity, this.tokens + ((now - this.updatedAt) / 1000) * this.rate);
+        this.updatedAt = now;
+    }
+}
+
+// ============================================================================
+// Stream Throttle
+// ============================================================================
+
+/**
+ * Estimates the token count of a text fragment (~4 characters per token).
+ */
+export function estimateTokens(text: string): number {
+    return text.length === 0 ? 0 : Math.ceil(text.length / 4);
+}
+
+/**
+ * Paces content deltas from `source` to the configured rate.
+ */
+export async function* throttleStream(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    config: StreamThrottleConfig,
+    clock: ThrottleClock = systemClock,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const bucket = new TokenBucket(config, clock);
+    for await (const event of source) {
+        if (event.contentDelta) {
+            await bucket.take(estimateTokens(event.contentDelta));
+        }
+        yield event;
+    }
+}
+
+/**
+ * Applies the throttle when configured; returns `source` unchanged otherwise.
+ */
+export function maybeThrottle(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    config: StreamThrottleConfig | undefined,
+    clock?: ThrottleClock,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    return config && config.tokensPerSecond > 0 ? throttleStream(source, config, clock) : source;
+}
+
+/**
+ * Describes the effective throttle for interaction metadata.
+ */
+export function describeThrottle(config: StreamThrottleConfig): string {
+    return `${config.tokensPerSecond} tokens/s, burst ${config.burst ?? config.tokensPerSecond}`;
+}
@@ -0,0 +1,260 @@
+/**
+ * Per-phase request timing and latency percentiles.
+ *
+ * The gateway creates one TimingRecorder per request and hands it to the
+ * frontdoor through its context; frontdoors time the phases they run.
+ * A streamed response outlives the handler, so the stream wrapper defers
+ * the recorder's completion until the last event has been pulled.
+ *
+ * @module utils/timings
+ */
+
+import type { CanonicalEvent } from '../domain/types.js';
+import type { InteractionTimings } from '../domain/events.js';
+
+// ============================================================================
+// Timing Recorder
+// ============================================================================
+
+/** A phase that can be timed individually. */
+export type TimingPhase = Exclude<keyof InteractionTimings, 'totalMs' | 'queueDepth'>;
+
+/**
+ * Timing recorder options.
+ */
+export interface TimingRecorderOptions {
+    /** Request arrival time; defaults to construction time. */
+    startedAt?: number | undefined;
+
+    /** Called once with the final timings when the request completes. */
+    onFinish?: ((timings: InteractionTimings) => void) | undefined;
+
+    /** Time source in milliseconds; replaceable in tests. */
+    clock?: (() => number) | undefined;
+}
+
+/**
+ * Collects phase timings for a single request.
+ */
+export class TimingRecorder {
+    readonly timings: InteractionTimings = {};
+    private readonly clock: () => number;
+    private readonly startedAt: number;
+    private readonly onFinish?: ((timings: InteractionTimings) => void) | undefined;
+    private pending = 0;
+    private settled = false;
+    private finished = false;
+
+    constructor(options: TimingRecorderOptions = {}) {
+        this.clock = options.clock ?? Date.now;
+        this.startedAt = options.startedAt ?? this.clock();
+        this.onFinish = options.onFinish;
+    }
+
+    /** Current time in milliseconds. */
+    now(): number {
+        return this.clock();
+    }
+
+    /**
+     * Records the time elapsed since `since` as `phase`.
+     */
+    record(phase: TimingPhase, since: number): void {
+        this.timings[phase] = this.clock() - since;
+    }
+
+    /**
+     * Records the time elapsed since the request arrived as `phase`.
+     */
+    recordSinceStart(phase: TimingPhase): void {
+        this.timings[phase] = this.clock() - this.startedAt;
+    }
+
+    /**
+     * Adds a provider call's concurrency queue wait. Calls that started at
+     * once (depth 0) still record a zero wait.
+     */
+    recordQueue(waitMs: number, depth: number): void {
+        this.timings.queueWaitMs = (this.timings.queueWaitMs ?? 0) + waitMs;
+        this.timings.queueDepth = Math.max(this.timings.queueDepth ?? 0, depth);
+    }
+
+    /**
+     * Runs `fn` and records its duration as `phase`, even if it throws.
+     */
+    async time<T>(phase: TimingPhase, fn: () => Promise<T>): Promise<T> {
+        const start = this.clock();
+        try {
+            return await fn();
+        } finally {
+            this.record(phase, start);
+        }
+    }
+
+    /**
+     * Holds completion open until the returned callback is called, for work
+     * that continues after the handler returns (streams).
+     */
+    defer(): () => void {
+        this.pending++;
+        let released = false;
+        return () => {
+            if (released) return;
+            released = true;
+            this.pending--;
+            this.maybeFinish();
+        };
+    }
+
+    /**
+     * Marks the handler as returned. Completes the recorder unless work
+     * is still deferred.
+     */
+    settle(): void {
+        this.settled = true;
+        this.maybeFinish();
+    }
+
+    private maybeFinish(): void {
+        if (this.finished || !this.settled || this.pending > 0) return;
+        this.finished = true;
+        this.timings.totalMs = this.clock() - this.startedAt;
+        this.onFinish?.(this.timings);
+    }
+}
+
+/**
+ * Times a provider stream: time to the first event carrying content (from
+ * the call and from the request's arrival) and time to the end of the stream (including early termination by the
+ * consumer). The recorder is held open from this call, since the stream
+ * is typically first pulled after the handler has returned.
+ */
+export function timeStream(
+    source: AsyncGenerator<CanonicalEvent, void, void>,
+    timings: TimingRecorder,
+): AsyncGenerator<CanonicalEvent, void, void> {
+    const release = timings.defer();
+    return (async function* () {
+        const start = timings.now();
+        let first = true;
+        try {
+            for await (const event of source) {
+                if (first && hasContent(event)) {
+                    timings.record('providerTtfbMs', start);
+                    timings.recordSinceStart('firstTokenMs');
+                    first = false;
+                }
+                yield event;
+            }
+        } finally {
+            timings.record('providerTotalMs', start);
+            release();
+        }
+    })();
+}
+
+function hasContent(event: CanonicalEvent): boolean {
+    return (
+        !!event.contentDelta ||
+        !!event.thinkingDelta ||
+        event.toolCall !== undefined ||
+        event.contentBlock !== undefined
+    );
+}
+
+// ============================================================================
+// Latency Percentiles
+// ============================================================================
+
+/** p50/p95/p99 for one phase, in milliseconds. */
+export interface Percentiles {
+    p50: number;
+    p95: number;
+    p99: number;
+}
+
+/** Latency percentiles for one provider and model. */
+export interface LatencySummary {
+    provider: string;
+    model: string;
+
+    /** Number of samples the percentiles are computed over. */
+    count: number;
+
+    /** Percentiles per phase; phases with no samples are omitted. */
+    phases: Partial<Record<keyof InteractionTimings, Percentiles>>;
+}
+
+/** Default number of recent samples kept per provider and model. */
+export const DEFAULT_LATENCY_WINDOW = 1000;
+
+/**
+ * Keeps a sliding window of recent timings per provider and model and
+ * computes percentiles over it on demand.
+ */
+export class LatencyStats {
+    private readonly samples = new Map<string, { provider: string; model: string; timings: InteractionTimings[] }>();
+
+    constructor(private readonly window: number = DEFAULT_LATENCY_WINDOW) { }
+
+    /**
+     * Adds a completed request's timings.
+     */
+    record(provider: string, model: string, timings: InteractionTimings): void {
+        const key = `${provider}\u0000${model}`;
+        let entry = this.samples.get(key);
+        if (!entry) {
+            entry = { provider, model, timings: [] };
+            this.samples.set(key, entry);
+        }
+        entry.timings.push({ ...timings });
+        if (entry.timings.length > this.window) {
+            entry.timings.shift();
+        }
+    }
+
+    /**
+     * Returns percentiles per provider and model, sorted by provider then model.
+     */
+    summary(): LatencySummary[] {
+        return Array.from(this.samples.values())
+            .map(({ provider, model, timings }) => {
+                const phases: LatencySummary['phases'] = {};
+                for (const phase of TIMING_KEYS) {
+                    const values = timings
+                        .map((t) => t[phase])
+                        .filter((v): v is number => v !== undefined)
+                        .sort((a, b) => a - b);
+                    if (values.length > 0) {
+                        phases[phase] = {
+                            p50: percentile(values, 50),
+                            p95: percentile(values, 95),
+                            p99: percentile(values, 99),
+                        };
+                    }
+                }
+                return { provider, model, count: timings.length, phases };
+            })
+            .sort((a, b) => a.provider.localeCompare(b.provider) || a.model.localeCompare(b.model));
+    }
+}
+
+const TIMING_KEYS: (keyof InteractionTimings)[] = [
+    'authMs',
+    'prePipelineMs',
+    'queueWaitMs',
+    'providerTtfbMs',
+    'firstTokenMs',
+    'providerTotalMs',
+    'postPipelineMs',
+    'encodeMs',
+    'totalMs',
+];
+
+/**
+ * Nearest-rank percentile of an ascending, non-empty list.
+ */
+export function percentile(sorted: number[], p: number): number {
+    const rank = Math.ceil((p / 100) * sorted.length);
+    return sorted[Math.min(sorted.length, Math.max(1, rank)) - 1]!;
+}
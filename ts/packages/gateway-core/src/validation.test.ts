@@ -0,0 +1,130 @@
+import { describe, it, expect, vi } from 'vitest';
+import { openAIFrontdoor, anthropicFrontdoor, responsesFrontdoor } from './frontdoors/index';
+
+const provider = {
+    name: 'mock',
+    apiType: 'openai',
+    complete: vi.fn(),
+    stream: vi.fn(),
+} as any;
+
+function context(path: string, body: unknown) {
+    return {
+        request: new Request(`http://localhost${path}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: typeof body === 'string' ? body : JSON.stringify(body),
+        }),
+        provider,
+        auth: { tenantId: 'tenant-a', scopes: ['*'], metadata: {} },
+        interactionId: 'int-1',
+        storage: {} as any,
+    } as any;
+}
+
+const userMessage = { role: 'user', content: 'Hi' };
+
+/** Malformed request corpus: [name, body, expected message]. */
+const openaiCases: [string, unknown, string][] = [
+    ['invalid JSON', '{"model": ', 'Invalid JSON in request body'],
+    ['non-object body', [1, 2], 'Request body must be a JSON object, got array'],
+    ['missing messages', { model: 'gpt-4o' }, 'messages: is required'],
+    ['empty messages', { model: 'gpt-4o', messages: [] }, 'messages: must not be empty'],
+    ['string temperature', { model: 'gpt-4o', messages: [userMessage], temperature: '0.7' }, 'temperature: must be a number, got string'],
+    ['temperature out of range', { model: 'gpt-4o', messages: [userMessage], temperature: 3 }, 'temperature: must be <= 2'],
+    ['bad role', { model: 'gpt-4o', messages: [userMessage, userMessage, { role: 'bot', content: 'x' }] }, 'messages[2].role: must be one of system, developer, user, assistant, tool, function'],
+    ['tool message without id', { model: 'gpt-4o', messages: [{ role: 'tool', content: 'x' }] }, 'messages[0].tool_call_id: is required'],
+    ['fractional max_tokens', { model: 'gpt-4o', messages: [userMessage], max_tokens: 1.5 }, 'max_tokens: must be an integer'],
+    ['both token limits', { model: 'gpt-4o', messages: [userMessage], max_tokens: 10, max_completion_tokens: 10 }, 'max_completion_tokens: cannot be combined with max_tokens'],
+    ['tool without function', { model: 'gpt-4o', messages: [userMessage], tools: [{ type: 'function' }] }, 'tools[0].function: is required'],
+    ['tool_choice without tools', { model: 'gpt-4o', messages: [userMessage], tool_choice: 'required' }, 'tool_choice: requires tools to be provided'],
+    ['tool_choice naming an undefined tool', { model: 'gpt-4o', messages: [userMessage], tools: [{ type: 'function', function: { name: 'lookup' } }], tool_choice: { type: 'function', function: { name: 'search' } } }, "tool_choice.function.name: no tool named 'search' in tools"],
+    ['string parallel_tool_calls', { model: 'gpt-4o', messages: [userMessage], parallel_tool_calls: 'false' }, 'parallel_tool_calls: must be a boolean, got string'],
+    ['top_logprobs without logprobs', { model: 'gpt-4o', messages: [userMessage], top_logprobs: 3 }, 'top_logprobs: requires logprobs to be true'],
+    ['json_schema without schema', { model: 'gpt-4o', messages: [userMessage], response_format: { type: 'json_schema' } }, 'response_format.json_schema: is required'],
+    ['template without name', { model: 'gpt-4o', messages: [userMessage], template: { variables: {} } }, 'template.name: is required'],
+    ['object template variable', { model: 'gpt-4o', messages: [userMessage], template: { name: 't', variables: { user: { id: 1 } } } }, 'template.variables.user: must be a string, number, or boolean, got object'],
+];
+
+const anthropicCases: [string, unknown, string][] = [
+    ['missing max_tokens', { model: 'claude-sonnet-4', messages: [userMessage] }, 'max_tokens: is required'],
+    ['zero max_tokens', { model: 'claude-sonnet-4', max_tokens: 0, messages: [userMessage] }, 'max_tokens: must be >= 1'],
+    ['empty messages', { model: 'claude-sonnet-4', max_tokens: 10, messages: [] }, 'messages: must not be empty'],
+    ['system role in messages', { model: 'claude-sonnet-4', max_tokens: 10, messages: [{ role: 'system', content: 'x' }] }, 'messages[0].role: must be one of user, assistant'],
+    ['unknown block type', { model: 'claude-sonnet-4', max_tokens: 10, messages: [{ role: 'user', content: [{ type: 'video' }] }] }, 'messages[0].content[0].type: must be one of text, image, tool_use, tool_result, thinking, redacted_thinking, document'],
+    ['tool without input_schema', { model: 'claude-sonnet-4', max_tokens: 10, messages: [userMessage], tools: [{ name: 'get_weather' }] }, 'tools[0].input_schema: is required'],
+    ['tool_choice naming an undefined tool', { model: 'claude-sonnet-4', max_tokens: 10, messages: [userMessage], tools: [{ name: 't', input_schema: {} }], tool_choice: { type: 'tool', name: 'u' } }, "tool_choice.name: no tool named 'u' in tools"],
+    ['tool_choice tool without name', { model: 'claude-sonnet-4', max_tokens: 10, messages: [userMessage], tools: [{ name: 't', input_schema: {} }], tool_choice: { type: 'tool' } }, 'tool_choice.name: is required'],
+    ['thinking budget over max_tokens', { model: 'claude-sonnet-4', max_tokens: 2000, messages: [userMessage], thinking: { type: 'enabled', budget_tokens: 4000 } }, 'thinking.budget_tokens: must be less than max_tokens'],
+];
+
+const responsesCases: [string, unknown, string][] = [
+    ['missing model', { input: 'hi' }, 'model: is required'],
+    ['missing input', { model: 'gpt-4o' }, 'input: is required'],
+    ['numeric input', { model: 'gpt-4o', input: 42 }, 'input: must be an array, got number'],
+    ['bad item role', { model: 'gpt-4o', input: [{ type: 'message', role: 'robot', content: 'x' }] }, 'input[0].role: must be one of user, assistant, system, developer'],
+    ['string max_output_tokens', { model: 'gpt-4o', input: 'hi', max_output_tokens: '100' }, 'max_output_tokens: must be a number, got string'],
+    ['string template', { model: 'gpt-4o', input: 'hi', template: 'support' }, 'template: must be an object, got string'],
+];
+
+describe('request validation', () => {
+    describe('openai frontdoor', () => {
+        it.each(openaiCases)('rejects %s', async (_name, body, message) => {
+            const { response } = await openAIFrontdoor.handle(context('/v1/chat/completions', body));
+            const json = await response.json();
+
+            expect(response.status).toBe(400);
+            expect(json.error.type).toBe('invalid_request_error');
+            expect(json.error.message).toContain(message);
+            expect(provider.complete).not.toHaveBeenCalled();
+        });
+
+        it('reports the field path as param', async () => {
+            const body = { model: 'gpt-4o', messages: [userMessage, { role: 'bot', content: 'x' }] };
+            const { response } = await openAIFrontdoor.handle(context('/v1/chat/completions', body));
+            const json = await response.json();
+
+            expect(json.error.param).toBe('messages[1].role');
+        });
+    });
+
+    describe('anthropic frontdoor', () => {
+        it.each(anthropicCases)('rejects %s', async (_name, body, message) => {
+            const { response } = await anthropicFrontdoor.handle(context('/v1/messages', body));
+            const json = await response.json();
+
+            expect(response.status).toBe(400);
+            expect(json.type).toBe('error');
+            expect(json.error.type).toBe('invalid_request_error');
+            expect(json.error.message).toBe(message);
+        });
+    });
+
+    describe('responses frontdoor', () => {
+        it.each(responsesCases)('rejects %s', async (_name, body, message) => {
+            const { response } = await responsesFrontdoor.handle(context('/v1/responses', body));
+            const json = await response.json();
+
+            expect(response.status).toBe(400);
+            expect(json.error.type).toBe('invalid_request_error');
+            expect(json.error.message).toBe(message);
+        });
+    });
+
+    it('does not reject unknown fields', async () => {
+        provider.complete.mockResolvedValueOnce({
+            id: 'chatcmpl-1',
+            object: 'chat.completion',
+            created: 0,
+            model: 'gpt-4o',
+            choices: [{ index: 0, message: { role: 'assistant', content: 'ok' }, finishReason: 'stop' }],
+            usage: { promptTokens: 1, completionTokens: 1, totalTokens: 2 },
+            sourceAPIType: 'openai',
+        });
+
+        const body = { model: 'gpt-4o', messages: [userMessage], some_future_field: true };
+        const { response } = await openAIFrontdoor.handle(context('/v1/chat/completions', body));
+
+        expect(response.status).toBe(200);
+    });
+});
@@ -0,0 +1,128 @@
+import { describe, it, expect, vi } from 'vitest';
+import { Gateway } from './gateway';
+import { AdminHandler } from './admin/index';
+import { AnthropicProvider, OpenAIProvider, validateProviderVersioning } from './providers/index';
+
+const request = { model: 'test-model', messages: [{ role: 'user', content: 'hi' }] } as any;
+
+function capture(body: unknown) {
+    const calls: { url: string; headers: Record<string, string> }[] = [];
+    const fetch = vi.fn(async (url: string, init: RequestInit) => {
+        calls.push({ url, headers: init.headers as Record<string, string> });
+        return Response.json(body);
+    });
+    return { fetch, calls };
+}
+
+const anthropicMessage = {
+    id: 'msg_1', type: 'message', role: 'assistant', model: 'claude-sonnet-4',
+    content: [{ type: 'text', text: 'ok' }], stop_reason: 'end_turn', stop_sequence: null,
+    usage: { input_tokens: 1, output_tokens: 1 },
+};
+
+const openaiCompletion = {
+    id: 'chatcmpl-1', object: 'chat.completion', created: 0, model: 'gpt-4o',
+    choices: [{ index: 0, message: { role: 'assistant', content: 'ok' }, finish_reason: 'stop' }],
+    usage: { prompt_tokens: 1, completion_tokens: 1, total_tokens: 2 },
+};
+
+describe('validateProviderVersioning', () => {
+    const anthropic = { name: 'anthropic', type: 'anthropic', apiKey: 'k' };
+
+    it('should reject unknown beta features unless allowed', () => {
+        expect(() => validateProviderVersioning({ ...anthropic, betaFeatures: ['prompt-caching-2024-07-31'] })).not.toThrow();
+        expect(() => validateProviderVersioning({ ...anthropic, betaFeatures: ['prompt-cacheing-2024-07-31'] }))
+            .toThrow(/unknown feature 'prompt-cacheing-2024-07-31'/);
+        expect(() => validateProviderVersioning({ ...anthropic, betaFeatures: ['new-thing-2026-01-01'], allowUnknown: true }))
+            .not.toThrow();
+    });
+
+    it('should reject settings that do not fit the provider type', () => {
+        expect(() => validateProviderVersioning({ ...anthropic, apiVersion: 'latest' })).toThrow(/not an anthropic-version date/);
+        expect(() => validateProviderVersioning({ ...anthropic, organization: 'org-1' })).toThrow(/OpenAI-compatible/);
+        expect(() => validateProviderVersioning({ name: 'openai', type: 'openai', apiKey: 'k', betaFeatures: ['x'] }))
+            .toThrow(/only supported for anthropic/);
+    });
+});
+
+describe('Provider version headers', () => {
+    it('should send the pinned anthropic-version and beta features', async () => {
+        const pinned = capture(anthropicMessage);
+        const defaults = capture(anthropicMessage);
+
+        await new AnthropicProvider({
+            name: 'anthropic', apiKey: 'k', fetch: pinned.fetch as any,
+            apiVersion: '2024-01-01', betaFeatures: ['prompt-caching-2024-07-31', 'pdfs-2024-09-25'],
+        }).complete(request);
+        await new AnthropicProvider({ name: 'anthropic', apiKey: 'k', fetch: defaults.fetch as any }).complete(request);
+
+        expect(pinned.calls[0]!.headers).toMatchObject({
+            'anthropic-version': '2024-01-01',
+            'anthropic-beta': 'prompt-caching-2024-07-31,pdfs-2024-09-25',
+        });
+        expect(defaults.calls[0]!.headers['anthropic-version']).toBe('2023-06-01');
+        expect(defaults.calls[0]!.headers).not.toHaveProperty('anthropic-beta');
+    });
+
+    it('should send OpenAI organization, project, and api-version', async () => {
+        const upstream = capture(openaiCompletion);
+
+        await new OpenAIProvider({
+            name: 'azure', apiKey: 'k', fetch: upstream.fetch as any,
+            organization: 'org-1', project: 'proj_1', apiVersion: '2024-10-21',
+        }).complete(request);
+
+        expect(upstream.calls[0]!.url).toBe('https://api.openai.com/v1/chat/completions?api-version=2024-10-21');
+        expect(upstream.calls[0]!.headers).toMatchObject({ 'OpenAI-Organization': 'org-1', 'OpenAI-Project': 'proj_1' });
+    });
+});
+
+describe('Gateway version pinning', () => {
+    const gatewayWith = (betaFeatures: string[]) => {
+        const upstream = capture(anthropicMessage);
+        const gateway = new Gateway({
+            config: {
+                load: async () => ({
+                    apps: [{ name: 'claude', frontdoor: 'anthropic', path: '/v1/messages' }],
+                    providers: [{ name: 'anthropic', type: 'anthropic', apiKey: 'k', apiVersion: '2024-01-01', betaFeatures }],
+                    routing: { defaultProvider: 'anthropic' },
+                }),
+            },
+            auth: {
+                authenticate: async (token: string) => ({ tenantId: token, scopes: ['admin'], metadata: {} }),
+                getTenant: async () => null,
+            },
+            httpClientFactory: () => ({ fetch: upstream.fetch as any }),
+        });
+        return { gateway, upstream };
+    };
+
+    it('should fail the config load on a typo', async () => {
+        const { gateway } = gatewayWith(['prompt-caching']);
+
+        await expect(gateway.reload()).rejects.toThrow("Invalid config for provider 'anthropic': beta_features: unknown feature");
+    });
+
+    it('should report the effective values on the admin overview', async () => {
+        const { gateway } = gatewayWith(['prompt-caching-2024-07-31']);
+        await gateway.reload();
+        const admin = new AdminHandler({
+            providerHealth: () => gateway.providerHealth(),
+            auth: {
+                authenticate: async () => ({ tenantId: 'ops', scopes: ['admin'], metadata: {} }),
+                getTenant: async () => null,
+            },
+        });
+
+        const overview = await (await admin.handle(new Request('http://localhost/api/overview', {
+            headers: { Authorization: 'Bearer ops' },
+        }))).json();
+
+        expect(overview.providers).toEqual([{
+            name: 'anthropic',
+            type: 'anthropic',
+            apiVersion: '2024-01-01',
+            betaFeatures: ['prompt-caching-2024-07-31'],
+        }]);
+    });
+});
@@ -0,0 +1,135 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { openaiCodec, anthropicCodec } from '../codecs/index';
+import { formatWarningsHeader, MAX_WARNINGS_HEADER_BYTES, type GatewayWarning } from './collector';
+import { harness, logged, spyLogger, ScriptedProvider, type TestGateway } from '../__tests__/harness/index';
+
+/** Splits an SSE body into frames, dropping the trailing empty one. */
+function frames(body: string): string[] {
+    return body.split('\n\n').filter((frame) => frame !== '');
+}
+
+/** The JSON data of an SSE frame. */
+function data(frame: string): any {
+    return JSON.parse(frame.slice(frame.indexOf('data: ') + 'data: '.length));
+}
+
+const expected = [
+    { code: 'model_rewrite', message: "model 'legacy' was rerouted by the gateway to another model" },
+    { code: 'max_tokens', message: "max_tokens 100000 exceeds the model's output token limit; clamped to 16384" },
+];
+
+describe('Gateway warnings', () => {
+    let gateway: TestGateway | undefined;
+
+    afterEach(async () => {
+        await gateway?.close();
+        gateway = undefined;
+    });
+
+    async function setup() {
+        const logger = spyLogger();
+        const modelRouting = { rewrites: [{ modelExact: 'legacy', model: 'gpt-4o', provider: 'mock' }] };
+        gateway = await harness()
+            .app({ name: 'chat', frontdoor: 'openai', path: '/v1', modelRouting })
+            .app({ name: 'embed', frontdoor: 'openai', path: '/embed', modelRouting, embedWarnings: true })
+            .app({ name: 'trailer', frontdoor: 'openai', path: '/trailer', modelRouting, usageTrailer: true })
+            .app({ name: 'claude', frontdoor: 'anthropic', path: '/claude', modelRouting })
+            .app({ name: 'claude-embed', frontdoor: 'anthropic', path: '/claude-embed', modelRouting, embedWarnings: true })
+            .provider(new ScriptedProvider('mock', { text: 'Hello' }))
+            .options({ logger })
+            .start();
+        const gw = gateway;
+        const send = (path: string, body: Record<string, unknown>) =>
+            gw.post(path, { messages: [{ role: 'user', content: 'Hi' }], ...body });
+        const persisted = () => logged(logger.info, 'interaction_metadata')
+            .filter((fields) => fields.gateway_warnings !== undefined)
+            .map((fields) => JSON.parse(fields.gateway_warnings));
+        return { send, persisted };
+    }
+
+    it('should report warnings in a header and persist them with the interaction', async () => {
+        const { send, persisted } = await setup();
+
+        const response = await send('/v1/chat/completions', { model: 'legacy', max_tokens: 100000 });
+        const body = await response.json();
+
+        expect(response.status).toBe(200);
+        expect(JSON.parse(response.headers.get('X-Gateway-Warnings')!)).toEqual(expected);
+        expect(response.headers.get('X-Gateway-Warnings')).not.toContain("'gpt-4o'");
+        expect(body).not.toHaveProperty('x_gateway_warnings');
+        expect(persisted()).toEqual([expected]);
+    });
+
+    it('should send no header when nothing was changed', async () => {
+        const { send, persisted } = await setup();
+
+        const response = await send('/v1/chat/completions', { model: 'gpt-4o', max_tokens: 100 });
+
+        expect(response.headers.has('X-Gateway-Warnings')).toBe(false);
+        expect(persisted()).toEqual([]);
+    });
+
+    it('should cap the header, noting how many warnings were left out', () => {
+        const warnings: GatewayWarning[] = Array.from({ length: 100 }, (_, i) => ({
+            code: 'response_transform',
+            message: `transform ${i} rewrote the response text — «${'x'.repeat(40)}»`,
+        }));
+
+        const value = formatWarningsHeader(warnings);
+        const parsed = JSON.parse(value);
+
+        expect(value.length).toBeLessThanOrEqual(MAX_WARNINGS_HEADER_BYTES);
+        expect(value).toMatch(/^[\x20-\x7e]+$/);
+        expect(parsed.slice(0, -1)).toEqual(warnings.slice(0, parsed.length - 1));
+        expect(parsed[parsed.length - 1]).toEqual({
+            code: 'warnings_truncated',
+            message: `${warnings.length - parsed.length + 1} more warnings omitted`,
+        });
+        expect(formatWarningsHeader(warnings.slice(0, 2))).toBe(JSON.stringify(warnings.slice(0, 2))
+            .replace(/—/g, '\\u2014').replace(/«/g, '\\u00ab').replace(/»/g, '\\u00bb'));
+    });
+
+    it('should embed warnings in the body when the app opts in, without breaking SDK parsing', async () => {
+        const { send } = await setup();
+        const request = { model: 'legacy', max_tokens: 100000 };
+
+        const plain = await (await send('/v1/chat/completions', request)).text();
+        const embedded = await (await send('/embed/chat/completions', request)).text();
+        const { x_gateway_warnings, ...rest } = JSON.parse(embedded);
+
+        expect(x_gateway_warnings).toEqual(expected);
+        expect(rest).toEqual(JSON.parse(plain));
+        expect(openaiCodec.decodeResponse(embedded)).toEqual(openaiCodec.decodeResponse(plain));
+
+        const plainMessage = await (await send('/claude/v1/messages', request)).text();
+        const embeddedMessage = await (await send('/claude-embed/v1/messages', request)).text();
+
+        expect(JSON.parse(embeddedMessage).x_gateway_warnings).toEqual(expected);
+        expect({ ...anthropicCodec.decodeResponse(embeddedMessage), created: 0 })
+            .toEqual({ ...anthropicCodec.decodeResponse(plainMessage), created: 0 });
+    });
+
+    it('should end a stream with a gateway.warnings event', async () => {
+        const { send, persisted } = await setup();
+
+        const sent = frames(await (await send('/v1/chat/completions', { model: 'legacy', stream: true })).text());
+
+        expect(sent[sent.length - 2]).toBe('data: [DONE]');
+        expect(sent[sent.length - 1]).toMatch(/^event: gateway\.warnings\ndata: /);
+        expect(data(sent[sent.length - 1]!)).toEqual([expected[0]]);
+        expect(persisted()).toEqual([[expected[0]]]);
+
+        const clean = frames(await (await send('/v1/chat/completions', { model: 'gpt-4o', stream: true })).text());
+        expect(clean[clean.length - 1]).toBe('data: [DONE]');
+    });
+
+    it('should carry a stream\'s warnings in the usage trailer when the app has it', async () => {
+        const { send } = await setup();
+
+        const sent = frames(await (await send('/trailer/chat/completions', { model: 'legacy', stream: true })).text());
+
+        expect(sent.some((frame) => frame.includes('gateway.warnings'))).toBe(false);
+        expect(sent[sent.length - 1]).toMatch(/^event: gateway\.usage\n/);
+        expect(data(sent[sent.length - 1]!).warnings).toEqual([expected[0]]);
+    });
+});
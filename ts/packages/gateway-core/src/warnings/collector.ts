@@ -0,0 +1,152 @@
+/**
+ * Gateway warnings surfaced to clients.
+ *
+ * Anything the gateway changes or drops on a request's behalf (a model
+ * rewrite, a clamped max_tokens, an unsupported parameter, a truncated
+ * response) is noted in the request's WarningCollector. The client sees
+ * the list without the protocol's shape changing:
+ *
+ *     X-Gateway-Warnings: [{"code":"max_tokens","message":"..."}]
+ *
+ * on non-streaming responses, and in an extension event at the end of
+ * streams (the gateway.usage trailer's `warnings`, when the app has the
+ * trailer on, otherwise a gateway.warnings event of its own). Apps can
+ * also opt in to an `x_gateway_warnings` key on JSON response bodies;
+ * clients that ignore unknown fields parse those bodies unchanged.
+ *
+ * @module warnings/collector
+ */
+
+import type { TransformationStep } from '../recorder/interaction.js';
+import { asciiJSON, embedExtension } from '../utils/extensions.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Response header carrying the warnings of a non-streaming response. */
+export const WARNINGS_HEADER = 'X-Gateway-Warnings';
+
+/** SSE event type carrying a stream's warnings when there is no usage trailer. */
+export const WARNINGS_EVENT = 'gateway.warnings';
+
+/** Vendor-extension key warnings are embedded under in response bodies. */
+export const WARNINGS_BODY_KEY = 'x_gateway_warnings';
+
+/**
+ * Largest X-Gateway-Warnings value, in bytes. Proxies commonly cap a
+ * header line at 8 KiB; warnings get half of that.
+ */
+export const MAX_WARNINGS_HEADER_BYTES = 4096;
+
+// ============================================================================
+// Types
+// ============================================================================
+
+/**
+ * A warning about something the gateway did to a request or response.
+ */
+export interface GatewayWarning {
+    /** Machine-readable code; the transformation stage for step warnings. */
+    code: string;
+
+    /** Human-readable description. */
+    message: string;
+}
+
+// ============================================================================
+// Collector
+// ============================================================================
+
+/**
+ * Collects one request's warnings, in the order they were raised.
+ * Repeats (the same code and message) are kept once.
+ */
+export class WarningCollector {
+    private readonly warnings: GatewayWarning[] = [];
+    private readonly seen = new Set<string>();
+
+    /**
+     * Adds a warning.
+     */
+    add(code: string, message: string): void {
+        const key = `${code}\u0000${message}`;
+        if (this.seen.has(key)) return;
+        this.seen.add(key);
+        this.warnings.push({ code, message });
+    }
+
+    /**
+     * Adds the warnings of transformation steps, coded by their stage.
+     */
+    addSteps(steps: TransformationStep[]): void {
+        for (const step of steps) {
+            for (const message of step.warnings ?? []) {
+                this.add(step.stage, message);
+            }
+        }
+    }
+
+    /** The warnings so far. */
+    list(): GatewayWarning[] {
+        return [...this.warnings];
+    }
+
+    /** Number of warnings so far. */
+    get size(): number {
+        return this.warnings.length;
+    }
+}
+
+// ============================================================================
+// Encoding
+// ============================================================================
+
+/**
+ * Formats warnings as an X-Gateway-Warnings value: compact JSON, with
+ * non-ASCII characters escaped so the value is a valid header. Warnings
+ * are dropped from the end until it fits in maxBytes, and a final
+ * `warnings_truncated` entry counts those left out.
+ */
+export function formatWarningsHeader(
+    warnings: GatewayWarning[],
+    maxBytes: number = MAX_WARNINGS_HEADER_BYTES,
+): string {
+    let value = asciiJSON(warnings);
+    for (let kept = warnings.length - 1; value.length > maxBytes && kept >= 0; kept--) {
+        const omitted = warnings.length - kept;
+        value = asciiJSON([
+            ...warnings.slice(0, kept),
+            { code: 'warnings_truncated', message: `${omitted} more warning${omitted === 1 ? '' : 's'} omitted` },
+        ]);
+    }
+    return value;
+}
+
+/**
+ * Adds warnings to a JSON object body under the vendor-extension key.
+ * Anything else (arrays, invalid JSON) is returned unchanged.
+ */
+export function embedWarnings(body: string, warnings: GatewayWarning[]): string {
+    return embedExtension(body, WARNINGS_BODY_KEY, warnings);
+}
+
+/**
+ * Passes an SSE body through and appends a gateway.warnings event once it
+ * has closed, if there are warnings by then. A body that errors gets no
+ * event.
+ */
+export function appendWarningsEvent(
+    body: ReadableStream<Uint8Array>,
+    warnings: () => GatewayWarning[],
+): ReadableStream<Uint8Array> {
+    const encoder = new TextEncoder();
+    return body.pipeThrough(new TransformStream<Uint8Array, Uint8Array>({
+        flush(controller) {
+            const list = warnings();
+            if (list.length > 0) {
+                controller.enqueue(encoder.encode(`event: ${WARNINGS_EVENT}\ndata: ${JSON.stringify(list)}\n\n`));
+            }
+        },
+    }));
+}
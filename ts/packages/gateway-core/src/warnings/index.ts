@@ -0,0 +1,25 @@
+/**
+ * Gateway warning exports.
+ *
+ * @module warnings
+ */
+
+export {
+    WarningCollector,
+    WARNINGS_HEADER,
+    WARNINGS_EVENT,
+    WARNINGS_BODY_KEY,
+    MAX_WARNINGS_HEADER_BYTES,
+    formatWarningsHeader,
+    embedWarnings,
+    appendWarningsEvent,
+    type GatewayWarning,
+} from './collector.js';
+
+export {
+    TruncationWatchingProvider,
+    withTruncationWatch,
+    TRUNCATED_WARNING,
+    TRUNCATED_MESSAGE,
+    FINISH_REASON_METADATA_KEY,
+} from './truncation.js';
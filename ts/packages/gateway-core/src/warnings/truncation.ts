@@ -0,0 +1,78 @@
+/**
+ * Detection of output cut off at max_tokens.
+ *
+ * Clients often miss a finish_reason of "length" and take a truncated
+ * answer for a whole one. The gateway watches each response (or stream)
+ * for a length stop so it can warn the client and index the interaction
+ * for the admin API.
+ *
+ * @module warnings/truncation
+ */
+
+import type {
+    CanonicalRequest,
+    CanonicalResponse,
+    CanonicalEvent,
+} from '../domain/types.js';
+import type { Provider, ProviderCallOptions } from '../ports/provider.js';
+import { ProviderWrapper } from '../providers/passthrough.js';
+
+// ============================================================================
+// Constants
+// ============================================================================
+
+/** Warning code for output cut off at max_tokens. */
+export const TRUNCATED_WARNING = 'max_tokens_reached';
+
+/** Warning message for output cut off at max_tokens. */
+export const TRUNCATED_MESSAGE = 'output stopped at the max_tokens limit (finish_reason "length"); the response is incomplete';
+
+/** Metadata index key truncated interactions are found by (value: length). */
+export const FINISH_REASON_METADATA_KEY = 'finish_reason';
+
+// ============================================================================
+// Watching Provider
+// ============================================================================
+
+/**
+ * Wraps a provider so length stops are reported.
+ */
+export class TruncationWatchingProvider extends ProviderWrapper {
+    private readonly onTruncated: () => void;
+
+    constructor(inner: Provider, onTruncated: () => void) {
+        super(inner);
+        this.onTruncated = onTruncated;
+    }
+
+    /**
+     * Completes a request, reporting it if any choice stopped at the cap.
+     */
+    async complete(request: CanonicalRequest, options?: ProviderCallOptions): Promise<CanonicalResponse> {
+        const response = await this.inner.complete(request, options);
+        if (response.choices.some((choice) => choice.finishReason === 'length')) {
+            this.onTruncated();
+        }
+        return response;
+    }
+
+    /**
+     * Streams a request, reporting it when a stop event says the cap was
+     * reached.
+     */
+    async *stream(request: CanonicalRequest, options?: ProviderCallOptions): AsyncGenerator<CanonicalEvent, void, void> {
+        for await (const event of this.inner.stream(request, options)) {
+            if (event.finishReason === 'length') {
+                this.onTruncated();
+            }
+            yield event;
+        }
+    }
+}
+
+/**
+ * Reports a provider's length stops to `onTruncated`.
+ */
+export function withTruncationWatch(provider: Provider, onTruncated: () => void): Provider {
+    return new TruncationWatchingProvider(provider, onTruncated);
+}